@@ -17,8 +17,18 @@ import (
 	_ "github.com/omnigen/backend/docs" // Import generated docs
 	"github.com/omnigen/backend/internal/adapters"
 	"github.com/omnigen/backend/internal/api"
+	"github.com/omnigen/backend/internal/api/handlers"
+	"github.com/omnigen/backend/internal/api/middleware"
+	"github.com/omnigen/backend/internal/assetcheck"
 	"github.com/omnigen/backend/internal/auth"
 	"github.com/omnigen/backend/internal/aws"
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/health"
+	"github.com/omnigen/backend/internal/local"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/moderation"
 	"github.com/omnigen/backend/internal/repository"
 	"github.com/omnigen/backend/internal/service"
 	"github.com/omnigen/backend/pkg/logger"
@@ -57,180 +67,629 @@ func main() {
 		zap.String("aws_region", cfg.AWSRegion),
 	)
 
+	// A mistyped model would otherwise only fail when the first job reaches it
+	models := adapters.ModelVersions{
+		GPT4o:   cfg.GPT4oModel,
+		Veo:     cfg.VeoModel,
+		Kling:   cfg.KlingModel,
+		Minimax: cfg.MinimaxModel,
+	}
+	if err := models.Validate(); err != nil {
+		zapLogger.Fatal("Invalid Replicate model configuration", zap.Error(err))
+	}
+	if cfg.ModelOverrideEnabled {
+		zapLogger.Warn("MODEL_OVERRIDE_ENABLED is set; POST /generate honors X-Model-Override")
+	}
+
 	// Check system dependencies
 	if err := checkDependencies(); err != nil {
 		zapLogger.Fatal("System dependency check failed", zap.Error(err))
 	}
 	zapLogger.Info("System dependencies verified (ffmpeg found)")
 
+	// AWS, Cognito and Replicate, or in-process stand-ins when ENVIRONMENT=local
+	b, err := newBackends(cfg, models, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize backends", zap.Error(err))
+	}
+	defer b.close()
+
+	parserService := service.NewParserService(
+		b.scriptGenerator,
+		zapLogger,
+	)
+	parserService.SetSceneSimilarityThreshold(cfg.SceneSimilarityThreshold)
+	zapLogger.Info("Parser service initialized")
+
+	// Initialize Asset Service
+	assetService := service.NewAssetService(
+		b.s3Service,
+		zapLogger,
+	)
+	zapLogger.Info("Asset service initialized")
+
+	// Optional ElevenLabs TTS for customers who need specific narrator voices
+	var elevenLabsTTS adapters.TTSAdapter
+	if cfg.ElevenLabsAPIKey != "" {
+		elevenLabsTTS = adapters.NewElevenLabsTTSAdapter(adapters.ElevenLabsConfig{
+			APIKey:  cfg.ElevenLabsAPIKey,
+			ModelID: cfg.ElevenLabsModelID,
+			VoiceIDs: map[string]string{
+				"male":   cfg.ElevenLabsMaleVoice,
+				"female": cfg.ElevenLabsFemaleVoice,
+			},
+			Stability:       cfg.ElevenLabsStability,
+			SimilarityBoost: cfg.ElevenLabsSimilarity,
+		}, zapLogger)
+		zapLogger.Info("TTS adapter initialized with ElevenLabs API")
+	} else if cfg.TTSProvider == string(adapters.TTSProviderElevenLabs) {
+		zapLogger.Warn("TTS_PROVIDER is elevenlabs but ELEVENLABS_API_KEY is not set - falling back to OpenAI TTS")
+	}
+
+	// Cookie configuration for httpOnly tokens
+	cookieConfig := auth.CookieConfig{
+		Secure:   cfg.Environment == "production", // HTTPS only in production
+		Domain:   "",                              // Empty for same-origin cookies
+		SameSite: http.SameSiteLaxMode,            // Lax mode for production compatibility (allows top-level navigation)
+	}
+
+	clipAspectPolicy, err := composition.ParseClipAspectPolicy(cfg.ClipAspectPolicy)
+	if err != nil {
+		zapLogger.Fatal("Invalid CLIP_ASPECT_POLICY", zap.Error(err))
+	}
+
+	// Audio post-processing settings
+	narrationPacing, err := domain.ParseNarrationPacing(cfg.NarrationWordsPerSecond)
+	if err != nil {
+		zapLogger.Fatal("Invalid NARRATION_WORDS_PER_SECOND", zap.Error(err))
+	}
+	audioConfig := handlers.AudioConfig{
+		SFXMaxDuration:  cfg.SFXMaxDuration,
+		MusicLUFS:       cfg.MusicLUFS,
+		NarrationLUFS:   cfg.NarrationLUFS,
+		MusicBeatSync:   cfg.MusicBeatSync,
+		NarrationPacing: narrationPacing,
+	}
+
+	webhookConfig := service.DefaultWebhookConfig()
+	webhookConfig.Timeout = time.Duration(cfg.WebhookTimeoutSeconds) * time.Second
+	webhookConfig.AllowPrivateNetworks = cfg.WebhookAllowPrivateNetworks
+	if webhookConfig.AllowPrivateNetworks {
+		zapLogger.Warn("Webhook callbacks may reach private networks (WEBHOOK_ALLOW_PRIVATE_NETWORKS is set)")
+	}
+
+	// Dependencies GET /readyz verifies before the load balancer routes jobs here
+	readinessChecks := []health.Check{
+		health.Binary("ffmpeg", health.ExecCommand),
+		health.Binary("ffprobe", health.ExecCommand),
+		health.Ping("dynamodb", cfg.JobTable, b.jobRepo),
+		health.Ping("s3", cfg.AssetsBucket, b.s3Service),
+	}
+	if cfg.ReadinessCheckReplicate {
+		readinessChecks = append(readinessChecks, health.ReplicateTokens(
+			&http.Client{Timeout: 2 * time.Second},
+			health.ReplicateAccountURL,
+			b.replicateTokens,
+		))
+	}
+	readiness := health.NewChecker(time.Duration(cfg.ReadinessCacheSeconds)*time.Second, health.DefaultCheckTimeout, readinessChecks...)
+
+	// A bad banned claim pattern would otherwise only fail when the first pharmaceutical job reaches it
+	complianceChecker, err := compliance.NewChecker(compliance.Config{
+		Strict:          cfg.ComplianceStrict,
+		RequiredPhrases: cfg.ComplianceRequiredPhrases,
+		BannedClaims:    cfg.ComplianceBannedClaims,
+	})
+	if err != nil {
+		zapLogger.Fatal("Invalid compliance configuration", zap.Error(err))
+	}
+
+	// Prompt moderation: the denylist always applies; OpenAI moderation when there's an OpenAI key
+	var moderationChecker *moderation.Checker
+	if cfg.ModerationEnabled {
+		var client moderation.Client
+		if b.moderationClient != nil {
+			client = b.moderationClient
+		}
+		moderationChecker, err = moderation.NewChecker(client, moderation.Config{
+			Denylist:        cfg.ModerationDenylist,
+			PharmaAllowlist: cfg.ModerationPharmaAllowlist,
+		})
+		if err != nil {
+			zapLogger.Fatal("Invalid moderation configuration", zap.Error(err))
+		}
+	}
+
+	// Pipeline metrics; EMF lines on stdout become CloudWatch metrics via the awslogs driver
+	var recorder metrics.Recorder
+	switch cfg.MetricsBackend {
+	case "emf":
+		recorder = metrics.NewEMF(os.Stdout, cfg.MetricsNamespace, metrics.Dimensions{"environment": cfg.Environment})
+	case "", "none":
+	default:
+		zapLogger.Fatal("Unknown METRICS_BACKEND (expected emf or none)", zap.String("metrics_backend", cfg.MetricsBackend))
+	}
+
+	// Uploads are scanned by clamd when one is configured (typically a ClamAV sidecar)
+	var assetScanner assetcheck.Scanner
+	if cfg.ClamdAddr != "" {
+		assetScanner = assetcheck.NewClamdScanner(cfg.ClamdAddr, time.Duration(cfg.ClamdTimeoutSeconds)*time.Second)
+	} else if b.assetRepo != nil {
+		zapLogger.Warn("CLAMD_ADDR not set; uploads are verified without malware scanning")
+	}
+
+	// Initialize HTTP server with goroutine-based async architecture
+	server := api.NewServer(&api.ServerConfig{
+		Port:                   cfg.Port,
+		Environment:            cfg.Environment,
+		Logger:                 zapLogger,
+		JobRepo:                b.jobRepo,
+		S3Service:              b.s3Service,
+		UsageRepo:              b.usageRepo,
+		IdempotencyRepo:        b.idempotencyRepo,
+		ScriptRepo:             b.scriptRepo,
+		AssetRepo:              b.assetRepo,
+		JobEventRepo:           b.jobEventRepo,
+		JobLockRepo:            b.jobLockRepo,
+		UserBucketRepo:         b.userBucketRepo,
+		PreferencesRepo:        b.preferencesRepo,
+		StageTimingsRepo:       b.stageTimingsRepo,
+		BrandRepo:              b.brandRepo,
+		AssetKeyPrefix:         cfg.AssetKeyPrefix,
+		AssetScanner:           assetScanner,
+		ParserService:          parserService,
+		AssetService:           assetService,
+		AdapterFactory:         b.adapterFactory, // Video generation (Veo 3.1, Kling)
+		MinimaxAdapter:         b.musicAdapter,   // Audio generation
+		MusicFallbackAdapter:   b.musicFallback,  // Music while Minimax is failing
+		TTSAdapter:             b.ttsAdapter,     // Text-to-speech for narrator voiceover
+		ElevenLabsTTS:          elevenLabsTTS,    // Optional ElevenLabs narrator voices
+		TTSProvider:            cfg.TTSProvider,  // Default narrator provider
+		GPT4oAdapter:           b.gpt4oAdapter,   // GPT-4o for narration generation
+		SFXAdapter:             b.sfxAdapter,     // Sound effects for "sfx" sync points
+		Audio:                  audioConfig,      // Sound effect length and loudness targets
+		TmpBudgetBytes:         cfg.TmpBudgetMB * 1024 * 1024,
+		LLMTokenBudget:         cfg.LLMTokenBudgetPerJob,
+		TmpJanitorInterval:     time.Duration(cfg.TmpJanitorIntervalMinutes) * time.Minute,
+		TmpJanitorTTL:          time.Duration(cfg.TmpJanitorTTLHours) * time.Hour,
+		StageTimingsInterval:   time.Duration(cfg.StageTimingsPersistMinutes) * time.Minute,
+		ThumbnailWebP:          cfg.ThumbnailWebP,
+		SceneTransitions:       cfg.SceneTransitions,
+		ClipAspectPolicy:       clipAspectPolicy,
+		ClipQuality:            composition.ClipQualityGate{BlackLuminance: cfg.ClipBlackLuminance, MinMotion: cfg.ClipMinMotion},
+		MaxActiveJobs:          cfg.MaxActiveJobsPerUser,
+		JobRetentionDays:       cfg.JobRetentionDays,
+		InternalAPIToken:       cfg.InternalAPIToken,
+		Webhooks:               webhookConfig,
+		ReplicateWebhooks:      b.replicateWebhooks,
+		ReplicateWebhookSecret: b.replicateWebhookSecret,
+		Readiness:              readiness,
+		Metrics:                recorder,
+		ModelOverrides:         cfg.ModelOverrideEnabled,
+		Compliance:             complianceChecker,
+		Moderation:             moderationChecker,
+		ReadRateLimit:          middleware.RateLimitBudget{Requests: cfg.RateLimitReadPerSecond, Per: time.Second},
+		WriteRateLimit:         middleware.RateLimitBudget{Requests: cfg.RateLimitGeneratePerMinute, Per: time.Minute},
+		MaxBodyBytes:           cfg.MaxBodyBytes,
+		MaxJSONBodyBytes:       cfg.MaxJSONBodyBytes,
+		AssetsBucket:           cfg.AssetsBucket,
+		APIKeys:                b.apiKeys,
+		JWTValidator:           b.jwtValidator,
+		AdminGroup:             cfg.AdminGroup,
+		CookieConfig:           cookieConfig,
+		CloudFrontDomain:       cfg.CloudFrontDomain,
+		CognitoDomain:          cfg.CognitoDomain,
+		OpenAIKey:              cfg.OpenAIKey,
+		ReadTimeout:            time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout:           time.Duration(cfg.WriteTimeout) * time.Second,
+	})
+
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%s", cfg.Port),
+		Handler:      server.Router(),
+		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+	}
+
+	// Start server in goroutine
+	go func() {
+		zapLogger.Info("Starting HTTP server", zap.String("address", httpServer.Addr))
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zapLogger.Fatal("Failed to start HTTP server", zap.Error(err))
+		}
+	}()
+
+	// Wait for interrupt signal for graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	zapLogger.Info("Shutting down server...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		zapLogger.Fatal("Server forced to shutdown", zap.Error(err))
+	}
+
+	zapLogger.Info("Server exited cleanly")
+}
+
+// environmentLocal runs the API against in-process stand-ins for AWS, Cognito and Replicate
+const environmentLocal = "local"
+
+// backends are the storage, identity and model providers the API runs against
+type backends struct {
+	jobRepo                *repository.DynamoDBRepository
+	usageRepo              *repository.DynamoDBUsageRepository
+	idempotencyRepo        repository.IdempotencyRepository     // nil ignores Idempotency-Key
+	scriptRepo             repository.ScriptsRepository         // nil disables the script library
+	assetRepo              repository.AssetsRepository          // nil skips upload verification
+	jobEventRepo           repository.JobEventsRepository       // nil keeps no job audit trail
+	jobLockRepo            repository.JobLocksRepository        // nil lets a job's compositions overlap
+	userBucketRepo         repository.UserBucketsRepository     // nil stores every job in ASSETS_BUCKET
+	preferencesRepo        repository.PreferencesRepository     // nil disables saved generation preferences
+	stageTimingsRepo       repository.StageTimingsRepository    // nil keeps job ETA timings in memory
+	brandRepo              repository.BrandGuidelinesRepository // nil disables brand guidelines
+	s3Service              *repository.S3AssetRepository
+	jwtValidator           *auth.JWTValidator
+	scriptGenerator        adapters.ScriptGenerator
+	gpt4oAdapter           *adapters.GPT4oAdapter // nil disables two-pass narration and brand extraction
+	adapterFactory         *adapters.AdapterFactory
+	musicAdapter           adapters.MusicGenerator
+	musicFallback          adapters.MusicGenerator           // nil fails music generation with Minimax
+	sfxAdapter             adapters.SFXGenerator             // nil disables sound effects
+	ttsAdapter             adapters.TTSAdapter               // nil disables narrator voiceover
+	moderationClient       *adapters.OpenAIModerationAdapter // nil moderates prompts with the denylist only
+	replicateTokens        adapters.TokenSource
+	apiKeys                []string
+	replicateWebhooks      *adapters.ReplicateWebhooks
+	replicateWebhookSecret string
+	close                  func()
+}
+
+// newBackends selects the backends for cfg.Environment
+func newBackends(cfg *Config, models adapters.ModelVersions, logger *zap.Logger) (*backends, error) {
+	if cfg.Environment == environmentLocal {
+		return newLocalBackends(cfg, logger)
+	}
+	return newAWSBackends(cfg, models, logger)
+}
+
+// newLocalBackends keeps jobs in memory, stores assets under LOCAL_DATA_DIR and replaces the
+// models with mocks returning sample media, so no credentials are needed
+func newLocalBackends(cfg *Config, logger *zap.Logger) (*backends, error) {
+	l, err := local.Start(local.Config{
+		DataDir:              cfg.LocalDataDir,
+		S3Addr:               cfg.LocalS3Addr,
+		DevToken:             cfg.LocalDevToken,
+		MockDelay:            time.Duration(cfg.LocalMockDelayMS) * time.Millisecond,
+		AssetsBucket:         cfg.AssetsBucket,
+		JobTable:             cfg.JobTable,
+		UsageTable:           cfg.UsageTable,
+		IdempotencyTable:     cfg.IdempotencyTable,
+		ScriptsTable:         cfg.ScriptsTable,
+		AssetsTable:          cfg.AssetsTable,
+		JobEventsTable:       cfg.JobEventsTable,
+		JobLocksTable:        cfg.JobLocksTable,
+		UserBucketsTable:     cfg.UserBucketsTable,
+		PreferencesTable:     cfg.PreferencesTable,
+		BrandGuidelinesTable: cfg.BrandGuidelinesTable,
+		Upload: repository.UploadOptions{
+			PartSize:    cfg.S3UploadPartSizeMB * 1024 * 1024,
+			Concurrency: cfg.S3UploadConcurrency,
+		},
+	}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start local backends: %w", err)
+	}
+	logger.Warn("Running with local backends: mock models, in-memory jobs, static dev token",
+		zap.String("data_dir", cfg.LocalDataDir),
+	)
+
+	return &backends{
+		jobRepo:         l.JobRepo,
+		usageRepo:       l.UsageRepo,
+		idempotencyRepo: l.IdempotencyRepo,
+		scriptRepo:      l.ScriptRepo,
+		assetRepo:       l.AssetRepo,
+		jobEventRepo:    l.JobEventRepo,
+		jobLockRepo:     l.JobLockRepo,
+		userBucketRepo:  l.UserBucketRepo,
+		preferencesRepo: l.PreferencesRepo,
+		brandRepo:       l.BrandRepo,
+		s3Service:       l.S3Service,
+		jwtValidator:    l.JWTValidator,
+		scriptGenerator: l.ScriptGenerator,
+		adapterFactory:  l.AdapterFactory,
+		musicAdapter:    l.MusicAdapter,
+		ttsAdapter:      l.TTSAdapter,
+		replicateTokens: adapters.StaticToken(""),
+		close: func() {
+			if err := l.Close(); err != nil {
+				logger.Warn("Failed to stop local backends", zap.Error(err))
+			}
+		},
+	}, nil
+}
+
+// newAWSBackends connects to DynamoDB, S3, Secrets Manager, Cognito and Replicate
+func newAWSBackends(cfg *Config, models adapters.ModelVersions, logger *zap.Logger) (*backends, error) {
 	// Initialize AWS SDK configuration
 	awsConfig, err := aws.NewConfig(context.Background(), cfg.AWSRegion)
 	if err != nil {
-		zapLogger.Fatal("Failed to initialize AWS config", zap.Error(err))
+		return nil, fmt.Errorf("failed to initialize AWS config: %w", err)
 	}
 
 	// Initialize AWS clients
 	awsClients := aws.NewClients(awsConfig)
-	zapLogger.Info("AWS clients initialized successfully")
+	logger.Info("AWS clients initialized successfully")
 
 	// Initialize repositories
 	jobRepo := repository.NewDynamoDBRepository(
 		awsClients.DynamoDB,
 		cfg.JobTable,
-		zapLogger,
+		logger,
 	)
 
 	s3Service := repository.NewS3Service(
 		awsClients.S3,
 		cfg.AssetsBucket,
-		zapLogger,
+		repository.UploadOptions{
+			PartSize:    cfg.S3UploadPartSizeMB * 1024 * 1024,
+			Concurrency: cfg.S3UploadConcurrency,
+		},
+		repository.PresignCacheOptions{MaxEntries: cfg.PresignCacheSize},
+		logger,
 	)
 
 	usageRepo := repository.NewUsageRepository(
 		awsClients.DynamoDB,
 		cfg.UsageTable,
-		zapLogger,
+		logger,
 	)
 
+	var idempotencyRepo repository.IdempotencyRepository
+	if cfg.IdempotencyTable != "" {
+		idempotencyRepo = repository.NewIdempotencyRepository(awsClients.DynamoDB, cfg.IdempotencyTable, logger)
+	} else {
+		logger.Warn("IDEMPOTENCY_TABLE not set; Idempotency-Key headers on POST /generate are ignored")
+	}
+
+	var scriptRepo repository.ScriptsRepository
+	if cfg.ScriptsTable != "" {
+		scriptRepo = repository.NewScriptRepository(awsClients.DynamoDB, cfg.ScriptsTable, logger)
+	} else {
+		logger.Warn("SCRIPTS_TABLE not set; generated scripts are not kept in the script library")
+	}
+
+	var assetRepo repository.AssetsRepository
+	if cfg.AssetsTable != "" {
+		assetRepo = repository.NewAssetRepository(awsClients.DynamoDB, cfg.AssetsTable, logger)
+	} else {
+		logger.Warn("ASSETS_TABLE not set; uploaded assets are used without verification")
+	}
+
+	var jobEventRepo repository.JobEventsRepository
+	if cfg.JobEventsTable != "" {
+		jobEventRepo = repository.NewJobEventRepository(awsClients.DynamoDB, cfg.JobEventsTable, logger)
+	} else {
+		logger.Warn("JOB_EVENTS_TABLE not set; jobs keep no event audit trail")
+	}
+
+	var jobLockRepo repository.JobLocksRepository
+	if cfg.JobLocksTable != "" {
+		jobLockRepo = repository.NewJobLockRepository(awsClients.DynamoDB, cfg.JobLocksTable, logger)
+	} else {
+		logger.Warn("JOB_LOCKS_TABLE not set; a job's compositions aren't serialized")
+	}
+
+	var userBucketRepo repository.UserBucketsRepository
+	if cfg.UserBucketsTable != "" {
+		userBucketRepo = repository.NewUserBucketRepository(awsClients.DynamoDB, cfg.UserBucketsTable, logger)
+	}
+
+	var preferencesRepo repository.PreferencesRepository
+	if cfg.PreferencesTable != "" {
+		preferencesRepo = repository.NewPreferencesRepository(awsClients.DynamoDB, cfg.PreferencesTable, logger)
+	} else {
+		logger.Warn("PREFERENCES_TABLE not set; generation preferences are disabled")
+	}
+
+	var stageTimingsRepo repository.StageTimingsRepository
+	if cfg.StageTimingsTable != "" {
+		stageTimingsRepo = repository.NewStageTimingsRepository(awsClients.DynamoDB, cfg.StageTimingsTable, logger)
+	} else {
+		logger.Warn("STAGE_TIMINGS_TABLE not set; job ETAs start from static estimates on every restart")
+	}
+
+	var brandRepo repository.BrandGuidelinesRepository
+	if cfg.BrandGuidelinesTable != "" {
+		brandRepo = repository.NewBrandGuidelinesRepository(awsClients.DynamoDB, cfg.BrandGuidelinesTable, logger)
+	} else {
+		logger.Warn("BRAND_GUIDELINES_TABLE not set; brand guidelines are disabled")
+	}
+
 	// Initialize services
 	secretsService := service.NewSecretsService(
 		awsClients.SecretsManager,
 		cfg.ReplicateSecretARN,
 		cfg.OpenAISecretARN,
-		zapLogger,
+		time.Duration(cfg.SecretsCacheTTLSeconds)*time.Second,
+		logger,
 	)
 
 	// Retrieve API keys from Secrets Manager
 	apiKeys, err := secretsService.GetAPIKeys(context.Background())
 	if err != nil {
-		zapLogger.Fatal("Failed to retrieve API keys from Secrets Manager", zap.Error(err))
+		return nil, fmt.Errorf("failed to retrieve API keys from Secrets Manager: %w", err)
 	}
-	zapLogger.Info("API keys loaded successfully", zap.Int("count", len(apiKeys)))
+	logger.Info("API keys loaded successfully", zap.Int("count", len(apiKeys)))
 
-	// Initialize parser service for script generation with GPT-4o
 	// Get the Replicate API key from Secrets Manager
 	replicateAPIKey, err := secretsService.GetReplicateAPIKey(context.Background())
 	if err != nil {
-		zapLogger.Fatal("Failed to retrieve Replicate API key", zap.Error(err))
+		return nil, fmt.Errorf("failed to retrieve Replicate API key: %w", err)
 	}
 
 	// Create GPT-4o adapter for intelligent script generation
-	gpt4oAdapter := adapters.NewGPT4oAdapter(replicateAPIKey, zapLogger)
+	gpt4oAdapter := adapters.NewGPT4oAdapter(replicateAPIKey, models.GPT4o, logger)
 
-	parserService := service.NewParserService(
-		gpt4oAdapter,
-		zapLogger,
-	)
-	zapLogger.Info("Parser service initialized with GPT-4o")
+	// Initialize video and audio generation adapters
+	adapterFactory := adapters.NewAdapterFactory(replicateAPIKey, models, logger)
+	minimaxAdapter := adapters.NewMinimaxAdapter(replicateAPIKey, models.Minimax, logger)
+	sfxAdapter := adapters.NewSFXAdapter(replicateAPIKey, cfg.SFXModel, logger)
+	var musicGenAdapter *adapters.MusicGenAdapter
+	if cfg.MusicFallbackEnabled {
+		musicGenAdapter = adapters.NewMusicGenAdapter(replicateAPIKey, cfg.MusicFallbackModel, logger)
+	}
 
-	// Initialize Asset Service
-	assetService := service.NewAssetService(
-		s3Service,
-		zapLogger,
-	)
-	zapLogger.Info("Asset service initialized")
+	// Requests read the key from the secrets cache, so a rotated key is picked up after the
+	// cache TTL or as soon as Replicate rejects the old one
+	replicateTokens := secretsService.ReplicateTokenSource()
+	gpt4oAdapter.SetTokenSource(replicateTokens)
+	adapterFactory.SetTokenSource(replicateTokens)
+	minimaxAdapter.SetTokenSource(replicateTokens)
+	sfxAdapter.SetTokenSource(replicateTokens)
+	if musicGenAdapter != nil {
+		musicGenAdapter.SetTokenSource(replicateTokens)
+	}
+	logger.Info("Video and audio generation adapters initialized (Veo 3.1, Kling)")
+
+	// Replicate reports finished predictions by webhook when a public URL is configured;
+	// without one (or without a signing secret) predictions are polled as before
+	var replicateWebhooks *adapters.ReplicateWebhooks
+	replicateWebhookSecret := cfg.ReplicateWebhookSecret
+	if cfg.ReplicateWebhookURL != "" {
+		if replicateWebhookSecret == "" {
+			replicateWebhookSecret, err = adapters.FetchReplicateWebhookSecret(context.Background(), replicateAPIKey)
+			if err != nil {
+				logger.Warn("Failed to fetch Replicate webhook signing secret - polling predictions instead",
+					zap.Error(err),
+				)
+			}
+		}
+		if replicateWebhookSecret != "" {
+			// Webhooks reach whichever task the load balancer picks; the table shares them
+			// with the task waiting on the prediction
+			var predictionEvents adapters.PredictionEventStore
+			if cfg.PredictionEventsTable != "" {
+				predictionEvents = repository.NewPredictionEventRepository(awsClients.DynamoDB, cfg.PredictionEventsTable, logger)
+			} else {
+				logger.Warn("PREDICTION_EVENTS_TABLE not set; webhooks only reach the task that receives them, so predictions are still polled at their regular interval")
+			}
+			replicateWebhooks = adapters.NewReplicateWebhooks(
+				cfg.ReplicateWebhookURL,
+				time.Duration(cfg.ReplicateSafetyPollSeconds)*time.Second,
+				predictionEvents,
+				logger,
+			)
+			gpt4oAdapter.SetReplicateWebhooks(replicateWebhooks)
+			adapterFactory.SetReplicateWebhooks(replicateWebhooks)
+			minimaxAdapter.SetReplicateWebhooks(replicateWebhooks)
+			sfxAdapter.SetReplicateWebhooks(replicateWebhooks)
+			if musicGenAdapter != nil {
+				musicGenAdapter.SetReplicateWebhooks(replicateWebhooks)
+			}
+			logger.Info("Replicate webhooks enabled", zap.String("url", cfg.ReplicateWebhookURL))
+		}
+	}
 
-	// Initialize video and audio generation adapters
-	veoAdapter := adapters.NewVeoAdapter(replicateAPIKey, zapLogger)
-	minimaxAdapter := adapters.NewMinimaxAdapter(replicateAPIKey, zapLogger)
-	zapLogger.Info("Video and audio generation adapters initialized (Veo 3.1)")
+	// The fallback music model is an interface value only when it's enabled, so it's nil otherwise
+	var musicFallback adapters.MusicGenerator
+	if musicGenAdapter != nil {
+		musicFallback = musicGenAdapter
+	}
 
 	// Initialize TTS adapter for narrator voiceover generation
 	// Try to get OpenAI API key from Secrets Manager or environment variable
 	var ttsAdapter adapters.TTSAdapter
 	openaiAPIKey, err := secretsService.GetOpenAIAPIKey(context.Background())
 	if err != nil {
-		zapLogger.Warn("OpenAI API key not available - narrator voiceover generation will not be available",
+		logger.Warn("OpenAI API key not available - narrator voiceover generation will not be available",
 			zap.Error(err),
 		)
 		// ttsAdapter will remain nil - this is handled gracefully in generateNarratorVoiceover
 	} else if openaiAPIKey != "" {
-		ttsAdapter = adapters.NewOpenAITTSAdapter(openaiAPIKey, zapLogger)
-		zapLogger.Info("TTS adapter initialized with OpenAI TTS API")
+		openaiTTS := adapters.NewOpenAITTSAdapter(openaiAPIKey, logger)
+		openaiTTS.SetTokenSource(secretsService.OpenAITokenSource())
+		ttsAdapter = openaiTTS
+		logger.Info("TTS adapter initialized with OpenAI TTS API")
 	} else {
-		zapLogger.Warn("OPENAI_API_KEY not configured - narrator voiceover generation will not be available")
+		logger.Warn("OPENAI_API_KEY not configured - narrator voiceover generation will not be available")
+	}
+
+	// Prompts are screened by OpenAI moderation with the same key; without one only the denylist applies
+	var moderationClient *adapters.OpenAIModerationAdapter
+	if openaiAPIKey != "" {
+		moderationClient = adapters.NewOpenAIModerationAdapter(openaiAPIKey, logger)
+		moderationClient.SetTokenSource(secretsService.OpenAITokenSource())
+	}
+
+	// Scripts are written by GPT-4o on Replicate unless SCRIPT_LLM_PROVIDER picks the OpenAI API.
+	// Narration and brand extraction stay on Replicate either way.
+	var scriptGenerator adapters.ScriptGenerator = gpt4oAdapter
+	switch adapters.ScriptLLMProvider(cfg.ScriptLLMProvider) {
+	case adapters.ScriptLLMProviderReplicate:
+	case adapters.ScriptLLMProviderOpenAI:
+		if openaiAPIKey == "" {
+			return nil, fmt.Errorf("SCRIPT_LLM_PROVIDER is openai but no OpenAI API key is available")
+		}
+		openaiScripts := adapters.NewOpenAIScriptAdapter(openaiAPIKey, cfg.OpenAIScriptModel, logger)
+		openaiScripts.SetTokenSource(secretsService.OpenAITokenSource())
+		scriptGenerator = openaiScripts
+		logger.Info("Script generation uses the OpenAI API", zap.String("model", cfg.OpenAIScriptModel))
+	default:
+		return nil, fmt.Errorf("unknown SCRIPT_LLM_PROVIDER %q (expected replicate or openai)", cfg.ScriptLLMProvider)
 	}
 
 	// Initialize JWT validator
 	jwksURL := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json",
 		cfg.AWSRegion, cfg.CognitoUserPoolID)
 
-	jwtValidator := auth.NewJWTValidator(jwksURL, cfg.JWTIssuer, cfg.CognitoClientID, zapLogger)
+	jwtValidator := auth.NewJWTValidator(jwksURL, cfg.JWTIssuer, cfg.CognitoClientID, logger)
 
 	// Fetch JWKS keys at startup
 	if err := jwtValidator.FetchJWKS(); err != nil {
 		if cfg.Environment == "production" {
-			zapLogger.Fatal("Failed to fetch JWKS", zap.Error(err))
+			return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
 		} else {
-			zapLogger.Warn("Failed to fetch JWKS (continuing in development mode)", zap.Error(err))
+			logger.Warn("Failed to fetch JWKS (continuing in development mode)", zap.Error(err))
 		}
 	} else {
-		zapLogger.Info("JWT validator initialized successfully")
-	}
-
-	// Cookie configuration for httpOnly tokens
-	cookieConfig := auth.CookieConfig{
-		Secure:   cfg.Environment == "production", // HTTPS only in production
-		Domain:   "",                              // Empty for same-origin cookies
-		SameSite: http.SameSiteLaxMode,            // Lax mode for production compatibility (allows top-level navigation)
+		logger.Info("JWT validator initialized successfully")
 	}
 
-	// Initialize HTTP server with goroutine-based async architecture
-	server := api.NewServer(&api.ServerConfig{
-		Port:             cfg.Port,
-		Environment:      cfg.Environment,
-		Logger:           zapLogger,
-		JobRepo:          jobRepo,
-		S3Service:        s3Service,
-		UsageRepo:        usageRepo,
-		ParserService:    parserService,
-		AssetService:     assetService,
-		VeoAdapter:       veoAdapter,     // Video generation (Veo 3.1)
-		MinimaxAdapter:   minimaxAdapter, // Audio generation
-		TTSAdapter:       ttsAdapter,     // Text-to-speech for narrator voiceover
-		GPT4oAdapter:     gpt4oAdapter,   // GPT-4o for narration generation
-		AssetsBucket:     cfg.AssetsBucket,
-		APIKeys:          apiKeys,
-		JWTValidator:     jwtValidator,
-		CookieConfig:     cookieConfig,
-		CloudFrontDomain: cfg.CloudFrontDomain,
-		CognitoDomain:    cfg.CognitoDomain,
-		OpenAIKey:        cfg.OpenAIKey,
-		ReadTimeout:      time.Duration(cfg.ReadTimeout) * time.Second,
-		WriteTimeout:     time.Duration(cfg.WriteTimeout) * time.Second,
-	})
-
-	httpServer := &http.Server{
-		Addr:         fmt.Sprintf(":%s", cfg.Port),
-		Handler:      server.Router(),
-		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
-	}
-
-	// Start server in goroutine
-	go func() {
-		zapLogger.Info("Starting HTTP server", zap.String("address", httpServer.Addr))
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			zapLogger.Fatal("Failed to start HTTP server", zap.Error(err))
-		}
-	}()
-
-	// Wait for interrupt signal for graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	zapLogger.Info("Shutting down server...")
-
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := httpServer.Shutdown(ctx); err != nil {
-		zapLogger.Fatal("Server forced to shutdown", zap.Error(err))
-	}
-
-	zapLogger.Info("Server exited cleanly")
+	return &backends{
+		jobRepo:                jobRepo,
+		usageRepo:              usageRepo,
+		idempotencyRepo:        idempotencyRepo,
+		scriptRepo:             scriptRepo,
+		assetRepo:              assetRepo,
+		jobEventRepo:           jobEventRepo,
+		jobLockRepo:            jobLockRepo,
+		userBucketRepo:         userBucketRepo,
+		preferencesRepo:        preferencesRepo,
+		stageTimingsRepo:       stageTimingsRepo,
+		brandRepo:              brandRepo,
+		s3Service:              s3Service,
+		jwtValidator:           jwtValidator,
+		scriptGenerator:        scriptGenerator,
+		gpt4oAdapter:           gpt4oAdapter,
+		adapterFactory:         adapterFactory,
+		musicAdapter:           minimaxAdapter,
+		sfxAdapter:             sfxAdapter,
+		musicFallback:          musicFallback,
+		ttsAdapter:             ttsAdapter,
+		moderationClient:       moderationClient,
+		replicateTokens:        replicateTokens,
+		apiKeys:                apiKeys,
+		replicateWebhooks:      replicateWebhooks,
+		replicateWebhookSecret: replicateWebhookSecret,
+		close:                  jwtValidator.Stop,
+	}, nil
 }
 
 // Config holds all application configuration
@@ -242,12 +701,25 @@ type Config struct {
 	WriteTimeout int    `envconfig:"WRITE_TIMEOUT" default:"30"`
 
 	// AWS configuration
-	AWSRegion          string `envconfig:"AWS_REGION" required:"true"`
-	AssetsBucket       string `envconfig:"ASSETS_BUCKET" required:"true"`
-	JobTable           string `envconfig:"JOB_TABLE" required:"true"`
-	UsageTable         string `envconfig:"USAGE_TABLE" required:"true"`
-	ReplicateSecretARN string `envconfig:"REPLICATE_SECRET_ARN"` // Optional: if not set, will use REPLICATE_API_KEY env var
-	OpenAISecretARN    string `envconfig:"OPENAI_SECRET_ARN"`    // Optional: if not set, will use OPENAI_API_KEY env var
+	AWSRegion            string `envconfig:"AWS_REGION" required:"true"`
+	AssetsBucket         string `envconfig:"ASSETS_BUCKET" required:"true"`
+	JobTable             string `envconfig:"JOB_TABLE" required:"true"`
+	UsageTable           string `envconfig:"USAGE_TABLE" required:"true"`
+	IdempotencyTable     string `envconfig:"IDEMPOTENCY_TABLE"`      // Optional: POST /generate Idempotency-Key records; keys are ignored if unset
+	ScriptsTable         string `envconfig:"SCRIPTS_TABLE"`          // Optional: script library; generated scripts aren't kept if unset
+	AssetsTable          string `envconfig:"ASSETS_TABLE"`           // Optional: upload verification results; uploads are used unverified if unset
+	JobEventsTable       string `envconfig:"JOB_EVENTS_TABLE"`       // Optional: job audit trails; none are kept if unset
+	JobLocksTable        string `envconfig:"JOB_LOCKS_TABLE"`        // Optional: composition locks; a job's compositions may overlap if unset
+	UserBucketsTable     string `envconfig:"USER_BUCKETS_TABLE"`     // Optional: per-user assets buckets; every job uses ASSETS_BUCKET if unset
+	PreferencesTable     string `envconfig:"PREFERENCES_TABLE"`      // Optional: per-user generation defaults; /preferences is disabled if unset
+	StageTimingsTable    string `envconfig:"STAGE_TIMINGS_TABLE"`    // Optional: step timings job ETAs are estimated from; kept in memory if unset
+	BrandGuidelinesTable string `envconfig:"BRAND_GUIDELINES_TABLE"` // Optional: users' brand guidelines; use_brand_guidelines and /brand-guidelines are disabled if unset
+	AssetKeyPrefix       string `envconfig:"ASSET_KEY_PREFIX"`       // Optional: prefix for new jobs' asset keys, e.g. env/staging/
+	ReplicateSecretARN   string `envconfig:"REPLICATE_SECRET_ARN"`   // Optional: if not set, will use REPLICATE_API_KEY env var
+	OpenAISecretARN      string `envconfig:"OPENAI_SECRET_ARN"`      // Optional: if not set, will use OPENAI_API_KEY env var
+
+	// Secrets Manager values are re-fetched after this long, or sooner when a provider rejects a key
+	SecretsCacheTTLSeconds int `envconfig:"SECRETS_CACHE_TTL_SECONDS" default:"300"`
 
 	// Authentication configuration
 	CognitoUserPoolID string `envconfig:"COGNITO_USER_POOL_ID" required:"true"`
@@ -261,8 +733,165 @@ type Config struct {
 	// OpenAI configuration (optional, for title generation)
 	OpenAIKey string `envconfig:"GPT4O_API_KEY"` // OpenAI API key for title generation
 
+	// Script generation provider: replicate (GPT-4o on Replicate) or openai (Chat Completions with the OpenAI API key)
+	ScriptLLMProvider string `envconfig:"SCRIPT_LLM_PROVIDER" default:"replicate"`
+	OpenAIScriptModel string `envconfig:"OPENAI_SCRIPT_MODEL"` // Defaults to adapters.DefaultOpenAIScriptModel
+
 	// TTS configuration (for narrator voiceover generation)
-	TTSAPIKey string `envconfig:"TTS_API_KEY"` // OpenAI TTS API key for narrator voiceover
+	TTSAPIKey   string `envconfig:"TTS_API_KEY"`                   // OpenAI TTS API key for narrator voiceover
+	TTSProvider string `envconfig:"TTS_PROVIDER" default:"openai"` // Default narrator provider: openai or elevenlabs
+
+	// ElevenLabs TTS configuration (optional)
+	ElevenLabsAPIKey      string  `envconfig:"ELEVENLABS_API_KEY"`
+	ElevenLabsModelID     string  `envconfig:"ELEVENLABS_MODEL_ID"`
+	ElevenLabsMaleVoice   string  `envconfig:"ELEVENLABS_VOICE_MALE"`   // Voice ID used for "male"
+	ElevenLabsFemaleVoice string  `envconfig:"ELEVENLABS_VOICE_FEMALE"` // Voice ID used for "female"
+	ElevenLabsStability   float64 `envconfig:"ELEVENLABS_STABILITY" default:"0.5"`
+	ElevenLabsSimilarity  float64 `envconfig:"ELEVENLABS_SIMILARITY_BOOST" default:"0.75"`
+
+	// Sound effect configuration (opt-in per request with generate_sfx)
+	SFXModel       string  `envconfig:"SFX_MODEL"`                    // Replicate text-to-audio model (defaults to adapters.DefaultSFXModel)
+	SFXMaxDuration float64 `envconfig:"SFX_MAX_DURATION" default:"3"` // Seconds each sound effect is trimmed to
+
+	// Background music falls back to this Replicate model when Minimax fails twice
+	MusicFallbackEnabled bool   `envconfig:"MUSIC_FALLBACK_ENABLED" default:"true"`
+	MusicFallbackModel   string `envconfig:"MUSIC_FALLBACK_MODEL"` // Defaults to adapters.DefaultMusicGenModel
+
+	// Replicate models as owner/model or owner/model:<version hash> (empty uses the adapters' pinned defaults)
+	GPT4oModel           string `envconfig:"REPLICATE_GPT4O_MODEL"`                  // Must start with openai/gpt-4o
+	VeoModel             string `envconfig:"REPLICATE_VEO_MODEL"`                    // Must start with google/veo-
+	KlingModel           string `envconfig:"REPLICATE_KLING_MODEL"`                  // Must start with kwaivgi/kling-; no version, the latest runs
+	MinimaxModel         string `envconfig:"REPLICATE_MINIMAX_MODEL"`                // Must start with minimax/music-
+	ModelOverrideEnabled bool   `envconfig:"MODEL_OVERRIDE_ENABLED" default:"false"` // Honor X-Model-Override on POST /generate (testing new versions only)
+
+	// Loudness normalization targets (EBU R128 integrated loudness, LUFS)
+	MusicLUFS     float64 `envconfig:"MUSIC_LOUDNESS_LUFS" default:"-23"`
+	NarrationLUFS float64 `envconfig:"NARRATION_LOUDNESS_LUFS" default:"-16"`
+
+	// Shift background music so its strongest beat near the script's first "beat" sync point lands on it
+	MusicBeatSync bool `envconfig:"MUSIC_BEAT_SYNC" default:"true"`
+
+	// Adjacent scenes sharing this much of their wording get one script retry, then a job warning (0 disables)
+	SceneSimilarityThreshold float64 `envconfig:"SCENE_SIMILARITY_THRESHOLD" default:"0.6"`
+
+	// Narrator pace by language, for narration word budgets and length checks
+	NarrationWordsPerSecond []string `envconfig:"NARRATION_WORDS_PER_SECOND"` // Comma-separated language:words_per_second entries, e.g. de:1.9; unlisted languages use domain.DefaultNarrationPacing
+
+	// S3 multipart upload tuning for clips and final videos
+	S3UploadPartSizeMB  int64 `envconfig:"S3_UPLOAD_PART_SIZE_MB" default:"16"` // Files larger than one part are uploaded in parts (min 5)
+	S3UploadConcurrency int   `envconfig:"S3_UPLOAD_CONCURRENCY" default:"5"`   // Parts uploaded in parallel
+
+	// Presigned download URL cache (URLs are reused until 80% of their validity has elapsed)
+	PresignCacheSize int `envconfig:"PRESIGN_CACHE_SIZE" default:"10000"`
+
+	// Temp storage available to video composition (0 disables the pre-flight check)
+	TmpBudgetMB int64 `envconfig:"TMP_BUDGET_MB" default:"512"`
+
+	// LLM tokens (prompt and completion) one job's script and narration calls may consume; a call whose
+	// estimated prompt doesn't fit fails before it is sent (0 disables the budget)
+	LLMTokenBudgetPerJob int `envconfig:"LLM_TOKEN_BUDGET_PER_JOB" default:"100000"`
+
+	// Orphaned job directories in /tmp are deleted at startup and on this interval (0 sweeps at startup only)
+	TmpJanitorIntervalMinutes int `envconfig:"TMP_JANITOR_INTERVAL_MINUTES" default:"15"`
+	TmpJanitorTTLHours        int `envconfig:"TMP_JANITOR_TTL_HOURS" default:"6"` // Job directories untouched this long go whatever the job's state
+
+	// Step timings job ETAs are estimated from are saved to STAGE_TIMINGS_TABLE on this interval
+	StageTimingsPersistMinutes int `envconfig:"STAGE_TIMINGS_PERSIST_MINUTES" default:"5"`
+
+	// Job thumbnails are JPEGs at each of handlers.ThumbnailWidths; this adds WebP copies
+	ThumbnailWebP bool `envconfig:"THUMBNAIL_WEBP" default:"false"`
+
+	// New jobs render their scripts' scene transitions (fades, wipes, whip pans) as xfade blends; jobs
+	// created without keep hard cuts when recomposed
+	SceneTransitions bool `envconfig:"SCENE_TRANSITIONS" default:"true"`
+
+	// Generated clips whose frame doesn't have the job's aspect ratio are padded into it ("normalize") or
+	// requested once more with a new seed ("regenerate")
+	ClipAspectPolicy string `envconfig:"CLIP_ASPECT_POLICY" default:"normalize"`
+
+	// Generated clips whose sampled frames are all darker than this mean luminance (0-255; black is 16),
+	// or differ from each other by less than CLIP_MIN_MOTION, are requested once more and fail their
+	// job if the retry is no better (0 turns each check off)
+	ClipBlackLuminance float64 `envconfig:"CLIP_BLACK_LUMINANCE" default:"20"`
+	ClipMinMotion      float64 `envconfig:"CLIP_MIN_MOTION" default:"0.5"`
+
+	// Jobs a user may have generating at once; more are queued (0 disables the limit)
+	MaxActiveJobsPerUser int `envconfig:"MAX_ACTIVE_JOBS_PER_USER" default:"2"`
+
+	// Jobs are deleted with their assets this many days after creation (0 keeps them forever)
+	JobRetentionDays int `envconfig:"JOB_RETENTION_DAYS" default:"7"`
+
+	// Bearer token for the /internal/jobs and /internal/usage endpoints (empty disables them)
+	InternalAPIToken string `envconfig:"INTERNAL_API_TOKEN"`
+
+	// Free tier per-user request budgets, scaled up for paid tiers and kept in memory per instance (0 disables a budget)
+	RateLimitReadPerSecond     int `envconfig:"RATE_LIMIT_READ_PER_SECOND" default:"10"`    // Any /api/v1 request
+	RateLimitGeneratePerMinute int `envconfig:"RATE_LIMIT_GENERATE_PER_MINUTE" default:"5"` // Each mutating endpoint, e.g. POST /generate and scene regeneration
+
+	// Request body limits in bytes (413 above them)
+	MaxBodyBytes     int64 `envconfig:"MAX_BODY_BYTES" default:"10485760"`    // Any route
+	MaxJSONBodyBytes int64 `envconfig:"MAX_JSON_BODY_BYTES" default:"131072"` // /api/v1 routes, e.g. /generate and /brand-guidelines
+
+	// Pharmaceutical script compliance checks (jobs with a voice and side effects)
+	ComplianceStrict          bool     `envconfig:"COMPLIANCE_STRICT" default:"false"` // Fail non-compliant jobs instead of storing warnings on them
+	ComplianceRequiredPhrases []string `envconfig:"COMPLIANCE_REQUIRED_PHRASES"`       // Comma-separated; empty uses compliance.DefaultRequiredPhrases
+	ComplianceBannedClaims    []string `envconfig:"COMPLIANCE_BANNED_CLAIMS"`          // Comma-separated regular expressions; empty uses compliance.DefaultBannedClaims
+
+	// Prompt moderation before any credits are spent on a job
+	ModerationEnabled         bool     `envconfig:"MODERATION_ENABLED" default:"true"`
+	ModerationDenylist        []string `envconfig:"MODERATION_DENYLIST"`         // Comma-separated category:term entries; empty uses moderation.DefaultDenylist
+	ModerationPharmaAllowlist []string `envconfig:"MODERATION_PHARMA_ALLOWLIST"` // Comma-separated terms pharmaceutical jobs may use; empty uses moderation.DefaultPharmaAllowlist
+
+	// Cognito group whose members may use the /api/v1/admin support endpoints (empty disables them)
+	AdminGroup string `envconfig:"ADMIN_GROUP" default:"admin"`
+
+	// Job completion webhooks
+	WebhookTimeoutSeconds       int  `envconfig:"WEBHOOK_TIMEOUT_SECONDS" default:"10"`           // Per delivery attempt
+	WebhookAllowPrivateNetworks bool `envconfig:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" default:"false"` // Local development only: disables the SSRF guard
+
+	// Replicate prediction webhooks (optional; predictions are polled when not set)
+	ReplicateWebhookURL        string `envconfig:"REPLICATE_WEBHOOK_URL"`                      // Public URL of POST /internal/replicate/webhook
+	ReplicateWebhookSecret     string `envconfig:"REPLICATE_WEBHOOK_SECRET"`                   // whsec_ signing secret; fetched from Replicate when empty
+	ReplicateSafetyPollSeconds int    `envconfig:"REPLICATE_SAFETY_POLL_SECONDS" default:"60"` // Polling interval while waiting on a webhook shared through PREDICTION_EVENTS_TABLE
+	PredictionEventsTable      string `envconfig:"PREDICTION_EVENTS_TABLE"`                    // Shares webhooks between tasks; without it predictions keep their regular polling interval
+
+	// Malware scanning of uploads during verification (needs ASSETS_TABLE)
+	ClamdAddr           string `envconfig:"CLAMD_ADDR"`                          // host:port of a clamd (ClamAV) daemon; empty skips malware scanning
+	ClamdTimeoutSeconds int    `envconfig:"CLAMD_TIMEOUT_SECONDS" default:"120"` // Per scan, including streaming the asset
+
+	// GET /readyz dependency checks
+	ReadinessCacheSeconds   int  `envconfig:"READINESS_CACHE_SECONDS" default:"5"`       // How long a readiness report is reused
+	ReadinessCheckReplicate bool `envconfig:"READINESS_CHECK_REPLICATE" default:"false"` // Also verify the Replicate API token
+
+	// Pipeline metrics
+	MetricsBackend   string `envconfig:"METRICS_BACKEND" default:"none"`      // emf (CloudWatch Embedded Metric Format on stdout) or none
+	MetricsNamespace string `envconfig:"METRICS_NAMESPACE" default:"OmniGen"` // CloudWatch namespace of EMF metrics
+
+	// ENVIRONMENT=local backends (see newLocalBackends)
+	LocalDataDir     string `envconfig:"LOCAL_DATA_DIR" default:"./.localdata"`     // Local S3 objects and rendered sample media
+	LocalS3Addr      string `envconfig:"LOCAL_S3_ADDR" default:"127.0.0.1:4569"`    // Presigned URLs point here, so the browser must reach it
+	LocalDevToken    string `envconfig:"LOCAL_DEV_TOKEN" default:"local-dev-token"` // Bearer token accepted for the dev user
+	LocalMockDelayMS int    `envconfig:"LOCAL_MOCK_DELAY_MS" default:"2000"`        // How long each mock video, music and TTS call takes
+}
+
+// localDefaults fill the AWS and Cognito settings envconfig requires but ENVIRONMENT=local
+// doesn't connect to
+var localDefaults = map[string]string{
+	"AWS_REGION":             "us-east-1",
+	"ASSETS_BUCKET":          "omnigen-local-assets",
+	"JOB_TABLE":              "omnigen-local-jobs",
+	"USAGE_TABLE":            "omnigen-local-usage",
+	"IDEMPOTENCY_TABLE":      "omnigen-local-idempotency",
+	"SCRIPTS_TABLE":          "omnigen-local-scripts",
+	"ASSETS_TABLE":           "omnigen-local-assets",
+	"JOB_EVENTS_TABLE":       "omnigen-local-job-events",
+	"JOB_LOCKS_TABLE":        "omnigen-local-job-locks",
+	"USER_BUCKETS_TABLE":     "omnigen-local-user-buckets",
+	"PREFERENCES_TABLE":      "omnigen-local-preferences",
+	"BRAND_GUIDELINES_TABLE": "omnigen-local-brand-guidelines",
+	"COGNITO_USER_POOL_ID":   "local",
+	"COGNITO_CLIENT_ID":      "local",
+	"JWT_ISSUER":             "local",
 }
 
 func loadConfig() (*Config, error) {
@@ -297,6 +926,14 @@ func loadConfig() (*Config, error) {
 		log.Printf("No .env file found in working directory %s, using environment variables only", wd)
 	}
 
+	if os.Getenv("ENVIRONMENT") == environmentLocal {
+		for key, value := range localDefaults {
+			if _, set := os.LookupEnv(key); !set {
+				os.Setenv(key, value)
+			}
+		}
+	}
+
 	var cfg Config
 	if err := envconfig.Process("", &cfg); err != nil {
 		return nil, fmt.Errorf("failed to process environment variables: %w", err)
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+)
+
+// clipExtensions are the files compose takes from its clip directory
+var clipExtensions = []string{".mp4", ".mov", ".webm"}
+
+// localBucket is the bucket compose stages its inputs in
+const localBucket = "assets"
+
+// compose runs the composition service on a directory of clips, printing each command it runs
+func (c *cli) compose(ctx context.Context, args []string) error {
+	fs, verbose := c.flags("compose")
+	clipDir := fs.String("clips", "", "directory of scene clips, composed in file name order")
+	jobFile := fs.String("job", "", "JSON job spec, as stored for a job")
+	music := fs.String("music", "", "music track to mix in")
+	narration := fs.String("narration", "", "narrator audio to mix in")
+	logo := fs.String("logo", "", "logo image, placed by the job's logo_overlay")
+	outDir := fs.String("out", ".", "directory the final videos are written to")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *clipDir == "" || *jobFile == "" {
+		fmt.Fprintln(c.stderr, "compose needs -clips and -job")
+		fs.Usage()
+		return errUsage
+	}
+
+	job, err := readJobSpec(*jobFile)
+	if err != nil {
+		return err
+	}
+	clips, err := clipFiles(*clipDir)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger(*verbose)
+	s3Dir, err := os.MkdirTemp("", "omnigen-cli-s3-")
+	if err != nil {
+		return fmt.Errorf("failed to create local storage: %w", err)
+	}
+	defer os.RemoveAll(s3Dir)
+	local, err := repository.StartLocalS3(s3Dir, "127.0.0.1:0", logger)
+	if err != nil {
+		return fmt.Errorf("failed to start local storage: %w", err)
+	}
+	defer local.Close()
+	s3Service := repository.NewS3Service(local.Client(), localBucket, repository.UploadOptions{},
+		repository.PresignCacheOptions{Disabled: true}, logger)
+
+	composer := composition.NewService(s3Service, localBucket, 0, nil, nil, composition.ClipQualityGate{}, logger).
+		WithRunner(&printingRunner{Runner: c.runner, out: c.stdout})
+	mp4Path, webmPath, err := composer.ComposeFiles(ctx, job, composition.LocalInputs{
+		Clips:     clips,
+		Music:     *music,
+		Narration: *narration,
+		Logo:      *logo,
+	}, *outDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "wrote %s\n", mp4Path)
+	if webmPath != "" {
+		fmt.Fprintf(c.stdout, "wrote %s\n", webmPath)
+	}
+	return nil
+}
+
+// readJobSpec reads a job as the API stores it. A spec without IDs composes as a local job.
+func readJobSpec(path string) (*domain.Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job spec: %w", err)
+	}
+	var job domain.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job spec %s: %w", path, err)
+	}
+	if job.JobID == "" {
+		job.JobID = "local"
+	}
+	if job.UserID == "" {
+		job.UserID = "local"
+	}
+	if job.AspectRatio == "" {
+		job.AspectRatio = domain.AspectRatio16x9
+	}
+	return &job, nil
+}
+
+// clipFiles lists the clips in dir in name order
+func clipFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clip directory: %w", err)
+	}
+	var clips []string
+	for _, entry := range entries {
+		if !entry.IsDir() && slices.Contains(clipExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			clips = append(clips, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("no clips (%s) in %s", strings.Join(clipExtensions, ", "), dir)
+	}
+	return clips, nil
+}
+
+// printingRunner prints each command before its runner runs it
+type printingRunner struct {
+	ffmpegexec.Runner
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (r *printingRunner) Run(cmd *exec.Cmd) error {
+	r.print(cmd.Args)
+	return r.Runner.Run(cmd)
+}
+
+func (r *printingRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	r.print(cmd.Args)
+	return r.Runner.Output(cmd)
+}
+
+func (r *printingRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	r.print(cmd.Args)
+	return r.Runner.CombinedOutput(cmd)
+}
+
+func (r *printingRunner) Exec(ctx context.Context, spec ffmpegexec.Spec) error {
+	name := spec.Name
+	if name == "" {
+		name = "ffmpeg"
+	}
+	r.print(append([]string{name}, spec.Args...))
+	return r.Runner.Exec(ctx, spec)
+}
+
+func (r *printingRunner) print(args []string) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	quoted[0] = filepath.Base(args[0])
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintln(r.out, strings.Join(quoted, " "))
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes arg for a POSIX shell, so printed commands can be pasted and rerun
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
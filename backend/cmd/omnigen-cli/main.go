@@ -0,0 +1,97 @@
+// Command omnigen-cli runs pieces of the generation pipeline on local files, for debugging a
+// composition or a script without deploying the API:
+//
+//	omnigen-cli compose -clips DIR -job job.json [-music FILE] [-narration FILE] [-logo FILE] [-out DIR]
+//	omnigen-cli script -prompt prompt.txt [-duration 30] [-aspect 16:9] [-model veo] [-provider mock]
+//	omnigen-cli validate-script [-duration N] [-model veo] [-pharma] script.json
+//	omnigen-cli probe FILE...
+//
+// Each subcommand uses the same packages as the API, so what it prints is what a job would run.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// cli is what every subcommand runs with; tests swap the runner and environment
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+	runner ffmpegexec.Runner // Runs ffmpeg and ffprobe
+	getenv func(string) string
+}
+
+// subcommands maps each subcommand to its implementation
+var subcommands = map[string]func(c *cli, ctx context.Context, args []string) error{
+	"compose":         (*cli).compose,
+	"script":          (*cli).script,
+	"validate-script": (*cli).validateScript,
+	"probe":           (*cli).probe,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{stdout: os.Stdout, stderr: os.Stderr, runner: ffmpegexec.ExecRunner{}, getenv: os.Getenv}
+	os.Exit(c.run(ctx, os.Args[1:]))
+}
+
+// run runs the subcommand args name and returns the process exit code: 2 for bad usage, 1 when
+// the subcommand fails
+func (c *cli) run(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return 2
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(c.stderr, "omnigen-cli: unknown subcommand %q\n", args[0])
+		c.usage()
+		return 2
+	}
+	if err := cmd(c, ctx, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(c.stderr, "omnigen-cli %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage reports bad arguments a subcommand has already explained
+var errUsage = errors.New("usage")
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: omnigen-cli <compose|script|validate-script|probe> [flags]")
+}
+
+// flags returns a flag set for subcommand name, with the -v flag every subcommand takes
+func (c *cli) flags(name string) (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet("omnigen-cli "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	verbose := fs.Bool("v", false, "log the pipeline's progress to stderr")
+	return fs, verbose
+}
+
+// logger logs to stderr: warnings and errors only, unless verbose
+func (c *cli) logger(verbose bool) *zap.Logger {
+	level := zapcore.WarnLevel
+	if verbose {
+		level = zapcore.DebugLevel
+	}
+	encoder := zap.NewDevelopmentEncoderConfig()
+	encoder.TimeKey = ""
+	return zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(encoder), zapcore.AddSync(c.stderr), level))
+}
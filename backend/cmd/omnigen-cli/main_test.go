@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// fakeRunner stands in for ffmpeg and ffprobe: every ffmpeg command writes a placeholder to its
+// output, and ffprobe reports 4s 30fps 1080p clips for the fixtures and 8s for everything else
+type fakeRunner struct {
+	fail string // Output name whose command fails
+}
+
+func (r *fakeRunner) Run(cmd *exec.Cmd) error {
+	_, err := r.CombinedOutput(cmd)
+	return err
+}
+
+func (r *fakeRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	return r.CombinedOutput(cmd)
+}
+
+func (r *fakeRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return r.run(cmd.Args[0], cmd.Args[1:])
+}
+
+func (r *fakeRunner) Exec(ctx context.Context, spec ffmpegexec.Spec) error {
+	name := spec.Name
+	if name == "" {
+		name = "ffmpeg"
+	}
+	_, err := r.run(name, spec.Args)
+	return err
+}
+
+func (r *fakeRunner) run(name string, args []string) ([]byte, error) {
+	path := args[len(args)-1]
+	if filepath.Base(name) == "ffprobe" {
+		return r.probe(args, path)
+	}
+	if filepath.Base(path) == r.fail {
+		return []byte("encoder failed"), errors.New("exit status 1")
+	}
+	return nil, os.WriteFile(path, []byte("video"), 0o644)
+}
+
+func (r *fakeRunner) probe(args []string, path string) ([]byte, error) {
+	duration := 8.0
+	if strings.Contains(path, "clips") || strings.HasPrefix(filepath.Base(path), "clip-") {
+		duration = 4
+	}
+	if filepath.Ext(path) == ".mp3" {
+		if slices.Contains(args, "v:0") {
+			return []byte(`{"streams": [], "format": {"duration": "8"}}`), nil
+		}
+		return []byte("8\n"), nil
+	}
+	switch args[slices.Index(args, "-show_entries")+1] {
+	case "stream=r_frame_rate":
+		return []byte("30/1\n"), nil
+	case "stream=width,height":
+		return []byte("1920x1080\n"), nil
+	case "format=duration":
+		return []byte(fmt.Sprintf("%g\n", duration)), nil
+	}
+	return json.Marshal(map[string]any{
+		"streams": []map[string]any{{
+			"codec_type":   "video",
+			"codec_name":   "h264",
+			"width":        1920,
+			"height":       1080,
+			"pix_fmt":      "yuv420p",
+			"r_frame_rate": "30/1",
+		}},
+		"format": map[string]any{"duration": fmt.Sprint(duration)},
+	})
+}
+
+// runCLI runs args with the fake runner, returning the exit code, stdout and stderr
+func runCLI(t *testing.T, runner *fakeRunner, env map[string]string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdout: &stdout, stderr: &stderr, runner: runner, getenv: func(key string) string { return env[key] }}
+	code := c.run(context.Background(), args)
+	return code, stdout.String(), stderr.String()
+}
+
+// checkGolden compares got with testdata/name, rewriting it under -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(want), got)
+}
+
+func TestCompose_PrintsCommands(t *testing.T) {
+	out := t.TempDir()
+	code, stdout, stderr := runCLI(t, &fakeRunner{}, nil,
+		"compose", "-clips", "testdata/clips", "-job", "testdata/job.json", "-music", "testdata/music.mp3", "-logo", "testdata/logo.png", "-out", out)
+	require.Equal(t, 0, code, stderr)
+
+	checkGolden(t, "compose.golden", strings.ReplaceAll(stdout, out, "$OUT"))
+	for _, ext := range []string{".mp4", ".webm"} {
+		matches, err := filepath.Glob(filepath.Join(out, "video-*"+ext))
+		require.NoError(t, err)
+		require.Len(t, matches, 1, "the final videos are written to -out")
+	}
+}
+
+func TestCompose_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		runner   *fakeRunner
+		wantCode int
+		wantErr  string
+	}{
+		{name: "missing flags", args: []string{"compose", "-clips", "testdata/clips"}, wantCode: 2, wantErr: "compose needs -clips and -job"},
+		{name: "no clips", args: []string{"compose", "-clips", "testdata", "-job", "testdata/job.json"}, wantCode: 1, wantErr: "no clips"},
+		{name: "bad job spec", args: []string{"compose", "-clips", "testdata/clips", "-job", "testdata/prompt.txt"}, wantCode: 1, wantErr: "failed to parse job spec"},
+		{
+			name:     "ffmpeg fails",
+			args:     []string{"compose", "-clips", "testdata/clips", "-job", "testdata/job.json", "-logo", "testdata/logo.png"},
+			runner:   &fakeRunner{fail: "video_with_text.mp4"},
+			wantCode: 1,
+			wantErr:  "ffmpeg text overlay failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := tt.runner
+			if runner == nil {
+				runner = &fakeRunner{}
+			}
+			code, _, stderr := runCLI(t, runner, nil, append(tt.args, "-out", t.TempDir())...)
+			require.Equal(t, tt.wantCode, code)
+			require.Contains(t, stderr, tt.wantErr)
+		})
+	}
+}
+
+func TestScriptThenValidate(t *testing.T) {
+	code, stdout, stderr := runCLI(t, &fakeRunner{}, nil, "script", "-prompt", "testdata/prompt.txt", "-duration", "16", "-provider", "mock")
+	require.Equal(t, 0, code, stderr)
+	var script struct {
+		Title         string `json:"title"`
+		TotalDuration int    `json:"total_duration"`
+		Scenes        []any  `json:"scenes"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &script))
+	require.Equal(t, 16, script.TotalDuration)
+	require.Len(t, script.Scenes, 2, "16s of veo is two 8s clips")
+
+	path := filepath.Join(t.TempDir(), "script.json")
+	require.NoError(t, os.WriteFile(path, []byte(stdout), 0o644))
+	code, stdout, stderr = runCLI(t, &fakeRunner{}, nil, "validate-script", path)
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, path+" is valid: 2 scenes, 16s\n", stdout)
+
+	code, _, stderr = runCLI(t, &fakeRunner{}, nil, "validate-script", "-duration", "30", path)
+	require.Equal(t, 1, code, "a script for 16s doesn't fill 30s")
+	require.Contains(t, stderr, "is invalid")
+}
+
+func TestScript_ProviderNeedsKey(t *testing.T) {
+	code, _, stderr := runCLI(t, &fakeRunner{}, nil, "script", "-prompt", "testdata/prompt.txt", "-provider", "openai")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "needs OPENAI_API_KEY")
+
+	code, _, stderr = runCLI(t, &fakeRunner{}, nil, "script", "-prompt", "testdata/prompt.txt", "-provider", "claude")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, `unknown provider "claude"`)
+}
+
+func TestValidateScript_ReportsErrors(t *testing.T) {
+	code, stdout, stderr := runCLI(t, &fakeRunner{}, nil, "validate-script", "testdata/invalid-script.json")
+	require.Equal(t, 1, code)
+	require.Empty(t, stdout)
+	require.Equal(t, "omnigen-cli validate-script: testdata/invalid-script.json is invalid: script title is empty\n", stderr)
+}
+
+func TestProbe(t *testing.T) {
+	code, stdout, stderr := runCLI(t, &fakeRunner{}, nil, "probe", "testdata/clips/01-open.mp4", "testdata/music.mp3")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t,
+		`{"path":"testdata/clips/01-open.mp4","codec":"h264","width":1920,"height":1080,"fps":30,"pix_fmt":"yuv420p","duration":4}`+"\n"+
+			`{"path":"testdata/music.mp3","duration":8}`+"\n",
+		stdout)
+}
+
+func TestRun_Usage(t *testing.T) {
+	code, _, stderr := runCLI(t, &fakeRunner{}, nil)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "usage: omnigen-cli")
+
+	code, _, stderr = runCLI(t, &fakeRunner{}, nil, "render")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, `unknown subcommand "render"`)
+}
+
+func TestShellQuote(t *testing.T) {
+	require.Equal(t, "/tmp/job/final.mp4", shellQuote("/tmp/job/final.mp4"))
+	require.Equal(t, "'[0:v]scale=1920:1080'", shellQuote("[0:v]scale=1920:1080"))
+	require.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/omnigen/backend/internal/composition"
+)
+
+// probedFile is one line of probe's output
+type probedFile struct {
+	Path string `json:"path"`
+	composition.MediaInfo
+}
+
+// probe prints what the pipeline's ffprobe helpers report for each file, one JSON object a line
+func (c *cli) probe(ctx context.Context, args []string) error {
+	fs, _ := c.flags("probe")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "probe takes one or more files")
+		fs.Usage()
+		return errUsage
+	}
+
+	out := json.NewEncoder(c.stdout)
+	for _, path := range fs.Args() {
+		info, err := composition.ProbeMedia(ctx, c.runner, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := out.Encode(probedFile{Path: path, MediaInfo: info}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/service"
+	"go.uber.org/zap"
+)
+
+// providerMock writes scripts without calling an LLM, as the local API does
+const providerMock = "mock"
+
+// script generates a script for a prompt file and prints it as JSON
+func (c *cli) script(ctx context.Context, args []string) error {
+	fs, verbose := c.flags("script")
+	promptFile := fs.String("prompt", "", "file holding the prompt")
+	duration := fs.Int("duration", 30, "ad length in seconds")
+	aspect := fs.String("aspect", "16:9", "aspect ratio: 16:9, 9:16 or 1:1")
+	model := fs.String("model", string(adapters.DefaultAdapterType), "video model the scenes are planned for")
+	voice := fs.String("voice", "", "narrator voice (male or female); adds narration")
+	sideEffects := fs.String("side-effects", "", "side effects disclosure, for pharmaceutical ads")
+	provider := fs.String("provider", string(adapters.DefaultScriptLLMProvider), "script LLM: replicate, openai or mock")
+	llmModel := fs.String("llm-model", "", "model of the script LLM; empty uses the provider's default")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *promptFile == "" {
+		fmt.Fprintln(c.stderr, "script needs -prompt")
+		fs.Usage()
+		return errUsage
+	}
+	prompt, err := os.ReadFile(*promptFile)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt: %w", err)
+	}
+
+	logger := c.logger(*verbose)
+	generator, err := c.scriptGenerator(*provider, *llmModel, logger)
+	if err != nil {
+		return err
+	}
+	script, err := service.NewParserService(generator, logger).GenerateScript(ctx, service.ParseRequest{
+		UserID:      "local",
+		Prompt:      strings.TrimSpace(string(prompt)),
+		Duration:    *duration,
+		AspectRatio: *aspect,
+		VideoModel:  *model,
+		Voice:       *voice,
+		SideEffects: *sideEffects,
+	})
+	if err != nil {
+		return err
+	}
+
+	out := json.NewEncoder(c.stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(script)
+}
+
+// scriptGenerator returns the generator for provider, keyed from REPLICATE_API_KEY or
+// OPENAI_API_KEY
+func (c *cli) scriptGenerator(provider, model string, logger *zap.Logger) (adapters.ScriptGenerator, error) {
+	switch provider {
+	case providerMock:
+		return adapters.NewMockScriptGenerator(logger), nil
+	case string(adapters.ScriptLLMProviderReplicate):
+		key := c.getenv("REPLICATE_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("the replicate provider needs REPLICATE_API_KEY")
+		}
+		return adapters.NewGPT4oAdapter(key, model, logger), nil
+	case string(adapters.ScriptLLMProviderOpenAI):
+		key := c.getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("the openai provider needs OPENAI_API_KEY")
+		}
+		return adapters.NewOpenAIScriptAdapter(key, model, logger), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q (expected replicate, openai or mock)", provider)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// validateScript checks a script JSON file with the validator generated scripts go through
+func (c *cli) validateScript(ctx context.Context, args []string) error {
+	fs, _ := c.flags("validate-script")
+	duration := fs.Int("duration", 0, "requested ad length in seconds; 0 uses the script's total_duration")
+	model := fs.String("model", string(adapters.DefaultAdapterType), "video model the scenes must fit")
+	pharma := fs.Bool("pharma", false, "check it as a pharmaceutical ad, which needs side effects text")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "validate-script takes one script file")
+		fs.Usage()
+		return errUsage
+	}
+	videoModel, err := adapters.ParseAdapterType(*model)
+	if err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read script: %w", err)
+	}
+	var script domain.Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return fmt.Errorf("failed to parse script %s: %w", path, err)
+	}
+	requested := *duration
+	if requested == 0 {
+		requested = script.TotalDuration
+	}
+
+	if err := adapters.ValidateScript(&script, requested, *pharma, videoModel, nil); err != nil {
+		return fmt.Errorf("%s is invalid: %w", path, err)
+	}
+	fmt.Fprintf(c.stdout, "%s is valid: %d scenes, %ds\n", path, len(script.Scenes), script.TotalDuration)
+	return nil
+}
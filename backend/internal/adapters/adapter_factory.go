@@ -2,6 +2,7 @@ package adapters
 
 import (
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -10,36 +11,131 @@ import (
 type AdapterType string
 
 const (
-	AdapterTypeVeo AdapterType = "veo"
-	// Legacy support
+	AdapterTypeVeo   AdapterType = "veo"
 	AdapterTypeKling AdapterType = "kling"
 	// Future adapters:
 	// AdapterTypeRunway AdapterType = "runway"
 	// AdapterTypePika AdapterType = "pika"
 )
 
+// DefaultAdapterType is used when a request does not specify a video model
+const DefaultAdapterType = AdapterTypeVeo
+
+// clipDurations lists the clip lengths (seconds) each model can generate
+var clipDurations = map[AdapterType][]int{
+	AdapterTypeVeo:   {4, 6, 8},
+	AdapterTypeKling: {5, 10},
+}
+
+// ParseAdapterType validates a user-supplied model name against the supported
+// adapters. An empty name resolves to the default adapter.
+func ParseAdapterType(model string) (AdapterType, error) {
+	if model == "" {
+		return DefaultAdapterType, nil
+	}
+	adapterType := AdapterType(model)
+	if _, ok := clipDurations[adapterType]; !ok {
+		return "", fmt.Errorf("unsupported video model: %s", model)
+	}
+	return adapterType, nil
+}
+
+// ClipDurations returns the clip lengths (seconds) supported by the adapter type
+func ClipDurations(adapterType AdapterType) []int {
+	return clipDurations[adapterType]
+}
+
+// IsValidClipDuration reports whether a single scene duration can be generated by the model
+func IsValidClipDuration(adapterType AdapterType, seconds float64) bool {
+	for _, d := range clipDurations[adapterType] {
+		if seconds == float64(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAchievableDuration reports whether a total duration can be formed by
+// summing clips the model supports
+func IsAchievableDuration(adapterType AdapterType, total int) bool {
+	durations := clipDurations[adapterType]
+	if len(durations) == 0 || total <= 0 {
+		return false
+	}
+
+	reachable := make([]bool, total+1)
+	reachable[0] = true
+	for sum := 1; sum <= total; sum++ {
+		for _, d := range durations {
+			if d <= sum && reachable[sum-d] {
+				reachable[sum] = true
+				break
+			}
+		}
+	}
+	return reachable[total]
+}
+
 // AdapterFactory creates video generation adapters
 type AdapterFactory struct {
 	replicateToken string
+	tokens         TokenSource   // nil sends replicateToken
+	models         ModelVersions // Veo and Kling models; empty fields use the defaults
 	logger         *zap.Logger
+	webhooks       *ReplicateWebhooks
+
+	// Set by NewMockAdapterFactory: adapters return sample clips instead of calling Replicate
+	mockMedia MockMedia
+	mockDelay time.Duration
 }
 
-// NewAdapterFactory creates a new adapter factory
-func NewAdapterFactory(replicateToken string, logger *zap.Logger) *AdapterFactory {
+// NewAdapterFactory creates a new adapter factory whose adapters submit to models
+func NewAdapterFactory(replicateToken string, models ModelVersions, logger *zap.Logger) *AdapterFactory {
 	return &AdapterFactory{
 		replicateToken: replicateToken,
+		models:         models,
 		logger:         logger,
 	}
 }
 
+// NewMockAdapterFactory creates a factory for ENVIRONMENT=local whose adapters return clips
+// from media after delay
+func NewMockAdapterFactory(media MockMedia, delay time.Duration, logger *zap.Logger) *AdapterFactory {
+	return &AdapterFactory{
+		logger:    logger,
+		mockMedia: media,
+		mockDelay: delay,
+	}
+}
+
+// SetReplicateWebhooks makes adapters created from now on report completion by webhook
+func (f *AdapterFactory) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	f.webhooks = webhooks
+}
+
+// SetTokenSource makes adapters created from now on send the token tokens supplies
+func (f *AdapterFactory) SetTokenSource(tokens TokenSource) {
+	f.tokens = tokens
+}
+
 // CreateAdapter creates a video generation adapter of the specified type
 func (f *AdapterFactory) CreateAdapter(adapterType AdapterType) (VideoGeneratorAdapter, error) {
+	if f.mockMedia != nil {
+		if _, ok := clipDurations[adapterType]; !ok {
+			return nil, fmt.Errorf("unknown adapter type: %s", adapterType)
+		}
+		return NewMockVideoAdapter(adapterType, f.mockMedia, f.mockDelay, f.logger), nil
+	}
 	switch adapterType {
 	case AdapterTypeVeo:
-		return NewVeoAdapter(f.replicateToken, f.logger), nil
+		return f.newVeoAdapter(), nil
 	case AdapterTypeKling:
-		// Legacy support - map to Veo
-		return NewVeoAdapter(f.replicateToken, f.logger), nil
+		adapter := NewKlingAdapter(f.replicateToken, f.models.Kling, f.logger)
+		adapter.SetReplicateWebhooks(f.webhooks)
+		if f.tokens != nil {
+			adapter.SetTokenSource(f.tokens)
+		}
+		return adapter, nil
 	default:
 		return nil, fmt.Errorf("unknown adapter type: %s", adapterType)
 	}
@@ -47,5 +143,18 @@ func (f *AdapterFactory) CreateAdapter(adapterType AdapterType) (VideoGeneratorA
 
 // GetDefaultAdapter returns the default adapter (Veo 3.1)
 func (f *AdapterFactory) GetDefaultAdapter() VideoGeneratorAdapter {
-	return NewVeoAdapter(f.replicateToken, f.logger)
+	if f.mockMedia != nil {
+		return NewMockVideoAdapter(DefaultAdapterType, f.mockMedia, f.mockDelay, f.logger)
+	}
+	return f.newVeoAdapter()
+}
+
+// newVeoAdapter creates a Veo adapter with the factory's webhooks and tokens
+func (f *AdapterFactory) newVeoAdapter() *VeoAdapter {
+	adapter := NewVeoAdapter(f.replicateToken, f.models.Veo, f.logger)
+	adapter.SetReplicateWebhooks(f.webhooks)
+	if f.tokens != nil {
+		adapter.SetTokenSource(f.tokens)
+	}
+	return adapter
 }
@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseAdapterType(t *testing.T) {
+	tests := []struct {
+		model   string
+		want    AdapterType
+		wantErr bool
+	}{
+		{model: "", want: AdapterTypeVeo},
+		{model: "veo", want: AdapterTypeVeo},
+		{model: "kling", want: AdapterTypeKling},
+		{model: "minimax", wantErr: true},
+		{model: "VEO", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, err := ParseAdapterType(tt.model)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAdapterType(%q) error = %v, wantErr %v", tt.model, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAdapterType(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAdapter(t *testing.T) {
+	factory := NewAdapterFactory("test-token", ModelVersions{}, zap.NewNop())
+
+	veo, err := factory.CreateAdapter(AdapterTypeVeo)
+	if err != nil {
+		t.Fatalf("CreateAdapter(veo) error = %v", err)
+	}
+	if _, ok := veo.(*VeoAdapter); !ok {
+		t.Errorf("CreateAdapter(veo) returned %T, want *VeoAdapter", veo)
+	}
+
+	kling, err := factory.CreateAdapter(AdapterTypeKling)
+	if err != nil {
+		t.Fatalf("CreateAdapter(kling) error = %v", err)
+	}
+	if _, ok := kling.(*KlingAdapter); !ok {
+		t.Errorf("CreateAdapter(kling) returned %T, want *KlingAdapter", kling)
+	}
+
+	if _, err := factory.CreateAdapter(AdapterType("runway")); err == nil {
+		t.Error("CreateAdapter(runway) expected error, got nil")
+	}
+}
+
+func TestIsAchievableDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    AdapterType
+		duration int
+		want     bool
+	}{
+		{name: "veo even duration", model: AdapterTypeVeo, duration: 30, want: true},
+		{name: "veo odd duration", model: AdapterTypeVeo, duration: 33, want: false},
+		{name: "veo minimum", model: AdapterTypeVeo, duration: 10, want: true},
+		{name: "kling multiple of five", model: AdapterTypeKling, duration: 15, want: true},
+		{name: "kling non multiple of five", model: AdapterTypeKling, duration: 12, want: false},
+		{name: "unknown model", model: AdapterType("runway"), duration: 30, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAchievableDuration(tt.model, tt.duration); got != tt.want {
+				t.Errorf("IsAchievableDuration(%s, %d) = %v, want %v", tt.model, tt.duration, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// maxBrandDocumentChars caps the document text sent to GPT-4o (~15k tokens)
+const maxBrandDocumentChars = 60000
+
+// BrandGuidelinesExtraction is the structured brand data GPT-4o extracts from a document
+type BrandGuidelinesExtraction struct {
+	Colors     ExtractedColors     `json:"colors"`
+	Typography ExtractedTypography `json:"typography"`
+	BrandVoice ExtractedBrandVoice `json:"brand_voice"`
+	ImageStyle ExtractedStyle      `json:"image_style"`
+	VideoStyle ExtractedStyle      `json:"video_style"`
+}
+
+// ExtractedColors lists palette entries as hex codes or named colors
+type ExtractedColors struct {
+	Primary   []string `json:"primary"`
+	Secondary []string `json:"secondary"`
+	Accent    []string `json:"accent"`
+}
+
+// ExtractedTypography describes the brand typefaces
+type ExtractedTypography struct {
+	Headings string `json:"headings"`
+	Body     string `json:"body"`
+}
+
+// ExtractedBrandVoice describes how the brand speaks
+type ExtractedBrandVoice struct {
+	Adjectives []string `json:"adjectives"`
+	Tone       string   `json:"tone"`
+}
+
+// ExtractedStyle describes a visual treatment
+type ExtractedStyle struct {
+	Description string `json:"description"`
+}
+
+// IsEmpty reports whether the extraction found no brand information at all
+func (e *BrandGuidelinesExtraction) IsEmpty() bool {
+	return len(e.Colors.Primary)+len(e.Colors.Secondary)+len(e.Colors.Accent) == 0 &&
+		e.Typography.Headings == "" && e.Typography.Body == "" &&
+		len(e.BrandVoice.Adjectives) == 0 && e.BrandVoice.Tone == "" &&
+		e.ImageStyle.Description == "" && e.VideoStyle.Description == ""
+}
+
+const brandExtractionPrompt = `You extract brand guidelines from brand books for a video advertising tool.
+
+Return ONLY a JSON object with exactly this structure (use empty strings/arrays when the document does not say):
+{
+  "colors": {"primary": ["#0A84FF"], "secondary": [], "accent": []},
+  "typography": {"headings": "font and treatment for headlines", "body": "font for body copy"},
+  "brand_voice": {"adjectives": ["confident", "warm"], "tone": "one sentence"},
+  "image_style": {"description": "photography / illustration guidance in 1-2 sentences"},
+  "video_style": {"description": "motion, pacing and cinematography guidance in 1-2 sentences"}
+}
+
+Rules:
+- Prefer hex codes for colors; include the color name only when no code is given
+- Only report what the document states or clearly shows; never invent values
+- Keep each description under 300 characters`
+
+// ExtractBrandGuidelines asks GPT-4o to turn a brand document into structured guidelines.
+// Pass the document text layer, or page image URLs for scanned documents (vision path).
+func (g *GPT4oAdapter) ExtractBrandGuidelines(ctx context.Context, documentText string, pageImageURLs []string) (*BrandGuidelinesExtraction, error) {
+	logger := trace.Logger(ctx, g.logger)
+	if strings.TrimSpace(documentText) == "" && len(pageImageURLs) == 0 {
+		return nil, fmt.Errorf("document has no text or page images to analyze")
+	}
+	if len(documentText) > maxBrandDocumentChars {
+		documentText = documentText[:maxBrandDocumentChars]
+	}
+
+	logger.Info("Extracting brand guidelines with GPT-4o",
+		zap.Int("text_length", len(documentText)),
+		zap.Int("page_images", len(pageImageURLs)),
+	)
+
+	content := []map[string]interface{}{
+		{
+			"type": "text",
+			"text": brandExtractionPrompt + "\n\nBRAND DOCUMENT:\n" + documentText,
+		},
+	}
+	for _, url := range pageImageURLs {
+		content = append(content, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]string{"url": url},
+		})
+	}
+
+	resp, err := g.createPrediction(ctx, map[string]interface{}{
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": content},
+		},
+		"temperature":           0.1, // Extraction, not creativity
+		"max_completion_tokens": 1500,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status != "succeeded" {
+		predictionID := resp.ID
+		err := pollPrediction(ctx, predictionID, PollOptions{
+			Interval: 3 * time.Second,
+			Timeout:  3 * time.Minute,
+			LogEvery: 10,
+			Label:    "GPT-4o brand extraction",
+			Logger:   g.logger,
+			Webhooks: g.webhooks,
+			Canceler: g,
+		}, func(ctx context.Context) (string, string, error) {
+			r, err := g.pollStatus(ctx, predictionID)
+			if err != nil {
+				return "", "", err
+			}
+			resp = r
+			return r.Status, r.Error, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("brand extraction failed: %w", err)
+		}
+	}
+
+	extraction, err := ParseBrandGuidelinesExtraction(strings.Join(resp.Output, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Brand guidelines extracted",
+		zap.Int("colors", len(extraction.Colors.Primary)+len(extraction.Colors.Secondary)+len(extraction.Colors.Accent)),
+		zap.Int("voice_adjectives", len(extraction.BrandVoice.Adjectives)),
+	)
+
+	return extraction, nil
+}
+
+// ParseBrandGuidelinesExtraction parses GPT-4o output into a BrandGuidelinesExtraction,
+// tolerating prose and code fences around the JSON
+func ParseBrandGuidelinesExtraction(output string) (*BrandGuidelinesExtraction, error) {
+	raw, err := ExtractJSON(output)
+	if err != nil {
+		return nil, fmt.Errorf("brand extraction returned no JSON: %w", err)
+	}
+
+	var extraction BrandGuidelinesExtraction
+	if err := json.Unmarshal([]byte(raw), &extraction); err != nil {
+		return nil, fmt.Errorf("failed to parse brand extraction: %w", err)
+	}
+	if extraction.IsEmpty() {
+		return nil, fmt.Errorf("no brand guidelines found in document")
+	}
+
+	return &extraction, nil
+}
+
+// createPrediction submits a GPT-4o prediction and returns the initial response
+func (g *GPT4oAdapter) createPrediction(ctx context.Context, input map[string]interface{}) (*GPT4oResponse, error) {
+	payload, err := json.Marshal(GPT4oRequest{
+		Version:                g.modelVersion,
+		Input:                  input,
+		ReplicateWebhookFields: g.webhooks.requestFields(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var gpt4oResp GPT4oResponse
+	err = retry.Do(ctx, retry.APIConfig(), func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST",
+			"https://api.replicate.com/v1/predictions",
+			bytes.NewReader(payload))
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		token, err := authorize(ctx, httpReq, g.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
+		httpReq.Header.Set("Content-Type", "application/json")
+		// Don't use Prefer: wait to avoid 60-second timeout - we'll poll instead
+
+		resp, err := g.httpClient.Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("failed to execute request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+			if resp.StatusCode == http.StatusUnauthorized {
+				return unauthorizedError(ctx, g.tokens, token, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
+			}
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
+			}
+			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		}
+
+		if err := json.Unmarshal(body, &gpt4oResp); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &gpt4oResp, nil
+}
@@ -0,0 +1,57 @@
+package adapters
+
+import (
+	"testing"
+)
+
+func TestParseBrandGuidelinesExtraction(t *testing.T) {
+	t.Run("fenced JSON with commentary", func(t *testing.T) {
+		output := "Here are the guidelines I found:\n```json\n" +
+			`{"colors": {"primary": ["#0A84FF"], "secondary": ["#F4E1C1"], "accent": []},` +
+			`"typography": {"headings": "Helvetica Neue Bold", "body": "Inter"},` +
+			`"brand_voice": {"adjectives": ["confident", "warm"], "tone": "Friendly expert"},` +
+			`"image_style": {"description": "Natural light photography"},` +
+			`"video_style": {"description": "Bright, airy lifestyle footage"}}` +
+			"\n```\nLet me know if you need more."
+
+		e, err := ParseBrandGuidelinesExtraction(output)
+		if err != nil {
+			t.Fatalf("ParseBrandGuidelinesExtraction() error = %v", err)
+		}
+		if len(e.Colors.Primary) != 1 || e.Colors.Primary[0] != "#0A84FF" {
+			t.Errorf("Colors.Primary = %v", e.Colors.Primary)
+		}
+		if e.Typography.Headings != "Helvetica Neue Bold" || e.Typography.Body != "Inter" {
+			t.Errorf("Typography = %+v", e.Typography)
+		}
+		if len(e.BrandVoice.Adjectives) != 2 {
+			t.Errorf("BrandVoice.Adjectives = %v", e.BrandVoice.Adjectives)
+		}
+		if e.VideoStyle.Description != "Bright, airy lifestyle footage" {
+			t.Errorf("VideoStyle = %q", e.VideoStyle.Description)
+		}
+	})
+
+	t.Run("partial extraction", func(t *testing.T) {
+		e, err := ParseBrandGuidelinesExtraction(`{"brand_voice": {"adjectives": ["bold"]}}`)
+		if err != nil {
+			t.Fatalf("ParseBrandGuidelinesExtraction() error = %v", err)
+		}
+		if e.IsEmpty() {
+			t.Error("expected non-empty extraction")
+		}
+	})
+
+	errorCases := map[string]string{
+		"no JSON":          "I could not find any brand guidelines in this document.",
+		"empty extraction": `{"colors": {"primary": [], "secondary": [], "accent": []}, "typography": {"headings": "", "body": ""}}`,
+		"malformed JSON":   `{"colors": {"primary": "#0A84FF"}}`,
+	}
+	for name, output := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseBrandGuidelinesExtraction(output); err == nil {
+				t.Errorf("expected error for %q", output)
+			}
+		})
+	}
+}
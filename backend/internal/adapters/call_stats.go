@@ -0,0 +1,84 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CallStats tallies the provider calls finished on a context: how many there were, how many
+// status checks their predictions took, the time from each submission to its terminal status
+// and the tokens LLM calls consumed. It is safe for concurrent use.
+type CallStats struct {
+	mu      sync.Mutex
+	calls   int
+	polls   int
+	latency time.Duration
+	tokens  TokenUsage
+}
+
+type callStatsKey struct{}
+
+// WithCallStats returns a context whose provider calls are tallied in the returned CallStats,
+// alongside any provenance recorder it carries
+func WithCallStats(ctx context.Context) (context.Context, *CallStats) {
+	stats := &CallStats{}
+	return context.WithValue(ctx, callStatsKey{}, stats), stats
+}
+
+// Calls is the number of provider calls finished, failed ones included
+func (s *CallStats) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// Polls is the number of prediction status checks across the calls
+func (s *CallStats) Polls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
+
+// Latency is the submission-to-terminal time summed over the calls
+func (s *CallStats) Latency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency
+}
+
+// Tokens is the LLM tokens consumed across the calls
+func (s *CallStats) Tokens() TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens
+}
+
+// countProviderCall adds a call submitted at submitted, finishing now, to ctx's CallStats
+func countProviderCall(ctx context.Context, submitted time.Time) {
+	if stats, ok := ctx.Value(callStatsKey{}).(*CallStats); ok {
+		stats.mu.Lock()
+		stats.calls++
+		stats.latency += time.Since(submitted)
+		stats.mu.Unlock()
+	}
+}
+
+// countPolls adds a prediction's status checks to ctx's CallStats
+func countPolls(ctx context.Context, polls int) {
+	if stats, ok := ctx.Value(callStatsKey{}).(*CallStats); ok {
+		stats.mu.Lock()
+		stats.polls += polls
+		stats.mu.Unlock()
+	}
+}
+
+// countTokens adds an LLM call's token usage to ctx's CallStats
+func countTokens(ctx context.Context, usage TokenUsage) {
+	if stats, ok := ctx.Value(callStatsKey{}).(*CallStats); ok {
+		stats.mu.Lock()
+		stats.tokens.PromptTokens += usage.PromptTokens
+		stats.tokens.CompletionTokens += usage.CompletionTokens
+		stats.mu.Unlock()
+	}
+}
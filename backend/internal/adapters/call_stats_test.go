@@ -0,0 +1,39 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallStats(t *testing.T) {
+	ctx, stats := WithCallStats(context.Background())
+
+	// A prediction that failed and its retry, the way a clip's calls add up
+	PollUntilComplete(ctx, &fakeVideoGenerator{steps: []fakeStatusStep{status("starting"), status("failed")}}, "pred-1", testPollOptions())
+	_, err := PollUntilComplete(ctx, &fakeVideoGenerator{steps: []fakeStatusStep{status("processing"), status("processing"), status("succeeded")}}, "pred-2", testPollOptions())
+	if err != nil {
+		t.Fatalf("PollUntilComplete() error = %v", err)
+	}
+	// A synchronous call counts without polls
+	recordProviderCall(ctx, openAIProvider, "tts-1", "", time.Now().Add(-time.Second), errors.New("rate limited"))
+
+	if stats.Calls() != 3 {
+		t.Errorf("calls = %d, want 3", stats.Calls())
+	}
+	if stats.Polls() != 5 {
+		t.Errorf("polls = %d, want 5", stats.Polls())
+	}
+	if stats.Latency() < time.Second {
+		t.Errorf("latency = %v, want at least the synchronous call's second", stats.Latency())
+	}
+}
+
+func TestCallStatsOnlyCountsItsContext(t *testing.T) {
+	_, stats := WithCallStats(context.Background())
+	PollUntilComplete(context.Background(), &fakeVideoGenerator{steps: []fakeStatusStep{status("succeeded")}}, "pred-1", testPollOptions())
+	if stats.Calls() != 0 || stats.Polls() != 0 || stats.Latency() != 0 {
+		t.Errorf("stats = %d calls, %d polls, %v; want none", stats.Calls(), stats.Polls(), stats.Latency())
+	}
+}
@@ -0,0 +1,544 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// TTSProvider identifies a text-to-speech backend
+type TTSProvider string
+
+const (
+	TTSProviderOpenAI     TTSProvider = "openai"
+	TTSProviderElevenLabs TTSProvider = "elevenlabs"
+)
+
+// DefaultTTSProvider is used when neither the request nor server config selects one
+const DefaultTTSProvider = TTSProviderOpenAI
+
+// ElevenLabsConfig holds ElevenLabs TTS settings
+type ElevenLabsConfig struct {
+	APIKey          string
+	ModelID         string            // Defaults to eleven_multilingual_v2
+	VoiceIDs        map[string]string // Maps "male"/"female" to ElevenLabs voice IDs
+	Stability       float64           // 0-1, higher is more consistent delivery
+	SimilarityBoost float64           // 0-1, higher stays closer to the original voice
+}
+
+// defaultElevenLabsVoices are ElevenLabs premade voices used when config does not override them
+var defaultElevenLabsVoices = map[string]string{
+	"male":   "pNInz6obpgDQGcFmaJgB", // Adam
+	"female": "21m00Tcm4TlvDq8ikWAM", // Rachel
+}
+
+// ElevenLabsTTSAdapter implements text-to-speech using the ElevenLabs streaming API.
+type ElevenLabsTTSAdapter struct {
+	apiKey          string
+	modelID         string
+	voiceIDs        map[string]string
+	stability       float64
+	similarityBoost float64
+	httpClient      *http.Client
+	logger          *zap.Logger
+	baseURL         string
+	retryDelays     []time.Duration
+
+	voicesMu        sync.Mutex // Guards the cached voice list
+	voices          []Voice
+	voicesFetchedAt time.Time
+}
+
+// NewElevenLabsTTSAdapter creates a new ElevenLabs TTS adapter.
+func NewElevenLabsTTSAdapter(cfg ElevenLabsConfig, logger *zap.Logger) *ElevenLabsTTSAdapter {
+	voiceIDs := make(map[string]string, len(defaultElevenLabsVoices))
+	for name, id := range defaultElevenLabsVoices {
+		voiceIDs[name] = id
+	}
+	for name, id := range cfg.VoiceIDs {
+		if id != "" {
+			voiceIDs[name] = id
+		}
+	}
+
+	modelID := cfg.ModelID
+	if modelID == "" {
+		modelID = "eleven_multilingual_v2"
+	}
+	stability := cfg.Stability
+	if stability == 0 {
+		stability = 0.5
+	}
+	similarity := cfg.SimilarityBoost
+	if similarity == 0 {
+		similarity = 0.75
+	}
+
+	return &ElevenLabsTTSAdapter{
+		apiKey:          cfg.APIKey,
+		modelID:         modelID,
+		voiceIDs:        voiceIDs,
+		stability:       stability,
+		similarityBoost: similarity,
+		httpClient: &http.Client{
+			Timeout: 90 * time.Second,
+		},
+		logger:      logger,
+		baseURL:     "https://api.elevenlabs.io",
+		retryDelays: []time.Duration{1 * time.Second, 2 * time.Second},
+	}
+}
+
+// elevenLabsTTSRequest matches the ElevenLabs text-to-speech API schema.
+type elevenLabsTTSRequest struct {
+	Text          string                  `json:"text"`
+	ModelID       string                  `json:"model_id"`
+	LanguageCode  string                  `json:"language_code,omitempty"` // ISO 639-1; only models that enforce a language accept it
+	VoiceSettings elevenLabsVoiceSettings `json:"voice_settings"`
+}
+
+// elevenLabsLanguageCode returns the language_code to send modelID for the BCP-47 language tag.
+// Only the v2.5 models enforce a language, and the others reject the field; the multilingual
+// models detect the language from the text instead.
+func elevenLabsLanguageCode(modelID, language string) string {
+	if language == "" || !strings.HasSuffix(modelID, "_v2_5") {
+		return ""
+	}
+	return domain.BaseLanguage(language)
+}
+
+type elevenLabsVoiceSettings struct {
+	Stability       float64 `json:"stability"`
+	SimilarityBoost float64 `json:"similarity_boost"`
+}
+
+// elevenLabsErrorResponse is the error body ElevenLabs returns on failure
+type elevenLabsErrorResponse struct {
+	Detail struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"detail"`
+}
+
+// retryableElevenLabsStatuses are detail.status codes that succeed when retried
+var retryableElevenLabsStatuses = map[string]bool{
+	"system_busy":                  true,
+	"too_many_concurrent_requests": true,
+	"rate_limit_exceeded":          true,
+}
+
+// resolveVoiceID maps "male"/"female" to configured voice IDs; anything else is
+// treated as an ElevenLabs voice ID chosen by the customer
+func (t *ElevenLabsTTSAdapter) resolveVoiceID(voice string) (string, error) {
+	if id, ok := t.voiceIDs[voice]; ok {
+		return id, nil
+	}
+	if IsElevenLabsVoiceID(voice) {
+		return voice, nil
+	}
+	return "", fmt.Errorf("invalid voice selection: %s (expected 'male', 'female' or an ElevenLabs voice ID)", voice)
+}
+
+// IsElevenLabsVoiceID reports whether voice looks like an ElevenLabs voice ID
+func IsElevenLabsVoiceID(voice string) bool {
+	if len(voice) < 16 || len(voice) > 40 {
+		return false
+	}
+	for _, r := range voice {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateVoiceover generates speech audio from the provided text using the configured voice.
+func (t *ElevenLabsTTSAdapter) GenerateVoiceover(ctx context.Context, text string, voice string) (_ []byte, err error) {
+	logger := trace.Logger(ctx, t.logger)
+	startTime := time.Now()
+
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("empty text for TTS")
+	}
+
+	voiceID, err := t.resolveVoiceID(voice)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		recordProviderCall(ctx, string(TTSProviderElevenLabs), t.modelID, "", startTime, err)
+	}()
+
+	logger.Info("Generating voiceover with ElevenLabs TTS",
+		zap.String("voice", voice),
+		zap.String("voice_id", voiceID),
+		zap.Int("text_length", len(text)),
+		zap.String("model", t.modelID),
+	)
+
+	reqPayload := elevenLabsTTSRequest{
+		Text:         text,
+		ModelID:      t.modelID,
+		LanguageCode: elevenLabsLanguageCode(t.modelID, narrationLanguage(ctx)),
+		VoiceSettings: elevenLabsVoiceSettings{
+			Stability:       t.stability,
+			SimilarityBoost: t.similarityBoost,
+		},
+	}
+
+	attempts := len(t.retryDelays) + 1
+	var lastErr error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			logger.Warn("Retrying ElevenLabs TTS request",
+				zap.Int("attempt", attempt+1),
+				zap.Int("max_attempts", attempts),
+				zap.Error(lastErr),
+			)
+
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("tts retry cancelled: %w", ctx.Err())
+			case <-time.After(t.retryDelays[attempt-1]):
+				// continue
+			}
+		}
+
+		audioData, err := t.callElevenLabsTTS(ctx, voiceID, reqPayload)
+		if err == nil {
+			logger.Info("Generated voiceover with ElevenLabs TTS",
+				zap.String("voice", voice),
+				zap.Int("audio_size_bytes", len(audioData)),
+				zap.Duration("duration", time.Since(startTime)),
+				zap.Int("attempt", attempt+1),
+			)
+			return audioData, nil
+		}
+
+		lastErr = err
+
+		if !isRetryableError(err) {
+			logger.Error("ElevenLabs TTS request failed with non-retryable error",
+				zap.Error(err),
+				zap.Int("attempt", attempt+1),
+			)
+			return nil, fmt.Errorf("tts generation failed: %w", err)
+		}
+	}
+
+	logger.Error("ElevenLabs TTS generation failed after maximum retries",
+		zap.Error(lastErr),
+		zap.Int("attempts", attempts),
+	)
+	return nil, fmt.Errorf("tts generation failed after %d attempts: %w", attempts, lastErr)
+}
+
+func (t *ElevenLabsTTSAdapter) callElevenLabsTTS(ctx context.Context, voiceID string, reqPayload elevenLabsTTSRequest) ([]byte, error) {
+	payload, err := json.Marshal(reqPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal TTS request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/text-to-speech/%s/stream?output_format=mp3_44100_128",
+		t.baseURL, url.PathEscape(voiceID))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TTS request: %w", err)
+	}
+
+	httpReq.Header.Set("xi-api-key", t.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "audio/mpeg")
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("network error calling ElevenLabs TTS: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, classifyElevenLabsError(resp.StatusCode, body)
+	}
+
+	// The stream endpoint sends audio as chunked transfer; read until EOF
+	var audio bytes.Buffer
+	if _, err := io.Copy(&audio, resp.Body); err != nil {
+		return nil, &retryableError{err: fmt.Errorf("ElevenLabs audio stream interrupted: %w", err)}
+	}
+
+	if !isMP3Audio(audio.Bytes()) {
+		return nil, fmt.Errorf("ElevenLabs returned malformed audio (%d bytes, content-type %q)",
+			audio.Len(), resp.Header.Get("Content-Type"))
+	}
+
+	return audio.Bytes(), nil
+}
+
+// classifyElevenLabsError maps an ElevenLabs error response onto retryable / non-retryable errors
+func classifyElevenLabsError(status int, body []byte) error {
+	var parsed elevenLabsErrorResponse
+	_ = json.Unmarshal(body, &parsed)
+
+	message := parsed.Detail.Message
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	if parsed.Detail.Status != "" {
+		message = parsed.Detail.Status + ": " + message
+	}
+	var apiErr error = &APIError{Provider: ProviderElevenLabs, StatusCode: status, Body: message}
+	if parsed.Detail.Status == "quota_exceeded" {
+		// Sent as a 401, but the key is fine; the plan's characters ran out
+		apiErr = &InsufficientCreditsError{Provider: ProviderElevenLabs, StatusCode: status, Body: parsed.Detail.Message}
+	}
+
+	// 429 covers both rate limiting and the per-plan concurrency cap; both clear on retry
+	if status == http.StatusTooManyRequests || isRetryableStatus(status) || retryableElevenLabsStatuses[parsed.Detail.Status] {
+		return &retryableError{err: apiErr}
+	}
+	// 401 (bad key, quota_exceeded), 400/422 (invalid voice or text) will not succeed on retry
+	return apiErr
+}
+
+// isMP3Audio checks for an ID3 tag or an MPEG audio frame sync at the start of the data
+func isMP3Audio(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	if bytes.HasPrefix(data, []byte("ID3")) {
+		return true
+	}
+	return data[0] == 0xFF && data[1]&0xE0 == 0xE0
+}
+
+// GenerateVoiceoverWithDuration generates TTS audio at the specified speed and returns duration.
+// ElevenLabs speed settings are limited to 0.7-1.2x, so speed is applied with ffmpeg atempo.
+func (t *ElevenLabsTTSAdapter) GenerateVoiceoverWithDuration(ctx context.Context, text string, voice string, speed float64) ([]byte, float64, error) {
+	audioData, err := t.GenerateVoiceover(ctx, text, voice)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "elevenlabs-tts-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := tmpDir + "/voiceover.mp3"
+	if err := os.WriteFile(inputPath, audioData, 0o644); err != nil {
+		return nil, 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	outputPath := inputPath
+	if speed > 0 && speed != 1.0 {
+		outputPath = tmpDir + "/voiceover-tempo.mp3"
+		cmd := exec.CommandContext(ctx, "ffmpeg",
+			"-i", inputPath,
+			"-filter:a", "atempo="+strconv.FormatFloat(speed, 'f', -1, 64),
+			"-y", outputPath,
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return nil, 0, fmt.Errorf("failed to apply speed %.2f: %w (%s)", speed, err, strings.TrimSpace(string(output)))
+		}
+		if audioData, err = os.ReadFile(outputPath); err != nil {
+			return nil, 0, fmt.Errorf("failed to read sped-up audio: %w", err)
+		}
+	}
+
+	duration, err := getAudioDurationFromFile(outputPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get audio duration: %w", err)
+	}
+
+	return audioData, duration, nil
+}
+
+// elevenLabsVoicesTTL is how long the account's voice list is cached
+const elevenLabsVoicesTTL = 10 * time.Minute
+
+// elevenLabsVoicesResponse is the part of GET /v1/voices the adapter reads
+type elevenLabsVoicesResponse struct {
+	Voices []struct {
+		VoiceID string `json:"voice_id"`
+		Name    string `json:"name"`
+	} `json:"voices"`
+}
+
+// Voices lists "male" and "female" followed by the other voices on the account, by ID. The list
+// is cached for elevenLabsVoicesTTL; when ElevenLabs can't be reached only the configured male
+// and female voices are returned.
+func (t *ElevenLabsTTSAdapter) Voices(ctx context.Context) ([]Voice, error) {
+	t.voicesMu.Lock()
+	defer t.voicesMu.Unlock()
+	if t.voices != nil && time.Since(t.voicesFetchedAt) < elevenLabsVoicesTTL {
+		return slices.Clone(t.voices), nil
+	}
+
+	names, err := t.fetchVoiceNames(ctx)
+	if err != nil {
+		trace.Logger(ctx, t.logger).Warn("Failed to list ElevenLabs voices, using the configured ones", zap.Error(err))
+	}
+
+	voices := make([]Voice, 0, 2+len(names))
+	configured := make(map[string]bool, len(t.voiceIDs))
+	for _, name := range []string{"male", "female"} {
+		id := t.voiceIDs[name]
+		configured[id] = true
+		label := strings.ToUpper(name[:1]) + name[1:]
+		if voiceName, ok := names[id]; ok {
+			label = fmt.Sprintf("%s (%s)", label, voiceName)
+		}
+		voices = append(voices, Voice{ID: name, Label: label, Provider: TTSProviderElevenLabs})
+	}
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		if !configured[id] {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int { return strings.Compare(names[a], names[b]) })
+	for _, id := range ids {
+		voices = append(voices, Voice{ID: id, Label: names[id], Provider: TTSProviderElevenLabs})
+	}
+
+	if err == nil {
+		t.voices = voices
+		t.voicesFetchedAt = time.Now()
+	}
+	return slices.Clone(voices), nil
+}
+
+// fetchVoiceNames returns the name of each voice on the account, by voice ID
+func (t *ElevenLabsTTSAdapter) fetchVoiceNames(ctx context.Context) (map[string]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, t.baseURL+"/v1/voices", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create voices request: %w", err)
+	}
+	httpReq.Header.Set("xi-api-key", t.apiKey)
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("network error listing ElevenLabs voices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, classifyElevenLabsError(resp.StatusCode, body)
+	}
+
+	var parsed elevenLabsVoicesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode ElevenLabs voices: %w", err)
+	}
+	names := make(map[string]string, len(parsed.Voices))
+	for _, v := range parsed.Voices {
+		if v.VoiceID != "" {
+			names[v.VoiceID] = v.Name
+		}
+	}
+	return names, nil
+}
+
+// TTSRouter selects the TTS adapter for a job's provider
+type TTSRouter struct {
+	adapters        map[TTSProvider]TTSAdapter
+	defaultProvider TTSProvider
+	logger          *zap.Logger
+}
+
+// NewTTSRouter creates a router over the configured adapters. Nil adapters are skipped,
+// so an unconfigured provider (e.g. no ElevenLabs key) falls back to OpenAI.
+func NewTTSRouter(defaultProvider TTSProvider, configured map[TTSProvider]TTSAdapter, logger *zap.Logger) *TTSRouter {
+	available := make(map[TTSProvider]TTSAdapter)
+	for provider, adapter := range configured {
+		if adapter != nil {
+			available[provider] = adapter
+		}
+	}
+	if defaultProvider == "" {
+		defaultProvider = DefaultTTSProvider
+	}
+	return &TTSRouter{adapters: available, defaultProvider: defaultProvider, logger: logger}
+}
+
+// Resolve returns the adapter for the requested provider (empty means the default),
+// falling back to OpenAI when that provider is not configured. The returned provider
+// is the one actually used; the adapter is nil when no TTS is configured at all.
+func (r *TTSRouter) Resolve(provider string) (TTSAdapter, TTSProvider) {
+	if r == nil {
+		return nil, ""
+	}
+
+	requested := TTSProvider(provider)
+	if requested == "" {
+		requested = r.defaultProvider
+	}
+	if adapter, ok := r.adapters[requested]; ok {
+		return adapter, requested
+	}
+
+	if adapter, ok := r.adapters[TTSProviderOpenAI]; ok {
+		if r.logger != nil {
+			r.logger.Warn("TTS provider not configured, falling back to OpenAI",
+				zap.String("requested_provider", string(requested)),
+			)
+		}
+		return adapter, TTSProviderOpenAI
+	}
+	return nil, ""
+}
+
+// Voices lists the voices of every configured provider, the default provider's first. A
+// provider whose voices can't be listed is left out.
+func (r *TTSRouter) Voices(ctx context.Context) []Voice {
+	if r == nil {
+		return nil
+	}
+
+	providers := make([]TTSProvider, 0, len(r.adapters))
+	for provider := range r.adapters {
+		providers = append(providers, provider)
+	}
+	slices.SortFunc(providers, func(a, b TTSProvider) int {
+		if (a == r.defaultProvider) != (b == r.defaultProvider) {
+			if a == r.defaultProvider {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(string(a), string(b))
+	})
+
+	var voices []Voice
+	for _, provider := range providers {
+		listed, err := r.adapters[provider].Voices(ctx)
+		if err != nil {
+			if r.logger != nil {
+				r.logger.Warn("Failed to list TTS voices", zap.String("provider", string(provider)), zap.Error(err))
+			}
+			continue
+		}
+		voices = append(voices, listed...)
+	}
+	return voices
+}
@@ -0,0 +1,340 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeMP3 starts with an MPEG frame sync so it passes the audio sanity check
+var fakeMP3 = []byte{0xFF, 0xFB, 0x90, 0x64, 0x00, 0x00, 0x00, 0x00}
+
+func newTestElevenLabsAdapter(serverURL string) *ElevenLabsTTSAdapter {
+	adapter := NewElevenLabsTTSAdapter(ElevenLabsConfig{
+		APIKey:          "test-key",
+		VoiceIDs:        map[string]string{"female": "femaleVoiceId12345"},
+		Stability:       0.4,
+		SimilarityBoost: 0.9,
+	}, zap.NewNop())
+	adapter.baseURL = serverURL
+	adapter.retryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	return adapter
+}
+
+func TestElevenLabsTTSAdapter_Success(t *testing.T) {
+	var gotPath, gotKey string
+	var gotBody elevenLabsTTSRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("xi-api-key")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		// Stream the audio in chunks like the real endpoint
+		w.Header().Set("Content-Type", "audio/mpeg")
+		flusher := w.(http.Flusher)
+		for _, b := range fakeMP3 {
+			w.Write([]byte{b})
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	audio, err := adapter.GenerateVoiceover(context.Background(), "Ask your doctor about Aero.", "female")
+	if err != nil {
+		t.Fatalf("GenerateVoiceover() error = %v", err)
+	}
+
+	if string(audio) != string(fakeMP3) {
+		t.Errorf("audio = % x, want % x", audio, fakeMP3)
+	}
+	if gotPath != "/v1/text-to-speech/femaleVoiceId12345/stream" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotKey != "test-key" {
+		t.Errorf("xi-api-key = %q", gotKey)
+	}
+	if gotBody.VoiceSettings.Stability != 0.4 || gotBody.VoiceSettings.SimilarityBoost != 0.9 {
+		t.Errorf("voice settings = %+v", gotBody.VoiceSettings)
+	}
+	if gotBody.ModelID != "eleven_multilingual_v2" {
+		t.Errorf("model_id = %q", gotBody.ModelID)
+	}
+}
+
+func TestElevenLabsTTSAdapter_LanguageCode(t *testing.T) {
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody = nil
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Write(fakeMP3)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		model    string
+		language string
+		want     any // nil when language_code is left out
+	}{
+		{"eleven_turbo_v2_5", "es-MX", "es"},
+		{"eleven_flash_v2_5", "de", "de"},
+		{"eleven_turbo_v2_5", "", nil},
+		{"eleven_multilingual_v2", "es-MX", nil}, // Detects the language itself and rejects the field
+	}
+	for _, tt := range tests {
+		adapter := newTestElevenLabsAdapter(server.URL)
+		adapter.modelID = tt.model
+		ctx := WithNarrationLanguage(context.Background(), tt.language)
+		if _, err := adapter.GenerateVoiceover(ctx, "Pregúntale a tu médico.", "female"); err != nil {
+			t.Fatalf("%s: GenerateVoiceover() error = %v", tt.model, err)
+		}
+		if got := gotBody["language_code"]; got != tt.want {
+			t.Errorf("%s in %q: language_code = %v, want %v", tt.model, tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestElevenLabsTTSAdapter_CustomVoiceID(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write(append([]byte("ID3"), fakeMP3...))
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	if _, err := adapter.GenerateVoiceover(context.Background(), "Hello", "customVoiceAbc123XYZ"); err != nil {
+		t.Fatalf("GenerateVoiceover() error = %v", err)
+	}
+	if gotPath != "/v1/text-to-speech/customVoiceAbc123XYZ/stream" {
+		t.Errorf("path = %q", gotPath)
+	}
+
+	if _, err := adapter.GenerateVoiceover(context.Background(), "Hello", "robot"); err == nil || !strings.Contains(err.Error(), "invalid voice") {
+		t.Errorf("expected invalid voice error, got %v", err)
+	}
+}
+
+func TestElevenLabsTTSAdapter_Unauthorized(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"detail": {"status": "invalid_api_key", "message": "Invalid API key"}}`))
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	_, err := adapter.GenerateVoiceover(context.Background(), "Hello", "male")
+	if err == nil {
+		t.Fatal("expected error for 401")
+	}
+	if isRetryableError(err) {
+		t.Error("401 should not be retryable")
+	}
+	if !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("error should include ElevenLabs message, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("calls = %d, want 1 (no retry)", n)
+	}
+}
+
+func TestElevenLabsTTSAdapter_RateLimitedThenSucceeds(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"detail": {"status": "too_many_concurrent_requests", "message": "Too many concurrent requests"}}`))
+			return
+		}
+		w.Write(fakeMP3)
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	if _, err := adapter.GenerateVoiceover(context.Background(), "Hello", "male"); err != nil {
+		t.Fatalf("GenerateVoiceover() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("calls = %d, want 2 (one retry after 429)", n)
+	}
+}
+
+func TestElevenLabsTTSAdapter_MalformedAudio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	_, err := adapter.GenerateVoiceover(context.Background(), "Hello", "male")
+	if err == nil || !strings.Contains(err.Error(), "malformed audio") {
+		t.Errorf("expected malformed audio error, got %v", err)
+	}
+}
+
+func TestClassifyElevenLabsError(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+	}{
+		{"bad request", 400, `{"detail": {"status": "invalid_text", "message": "Text too long"}}`, false},
+		{"quota exceeded", 401, `{"detail": {"status": "quota_exceeded", "message": "Quota exceeded"}}`, false},
+		{"voice not found", 404, `{"detail": {"status": "voice_not_found"}}`, false},
+		{"rate limited", 429, `{"detail": {"status": "rate_limit_exceeded"}}`, true},
+		{"system busy", 503, `{"detail": {"status": "system_busy"}}`, true},
+		{"server error with plain body", 500, `upstream failure`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyElevenLabsError(tt.status, []byte(tt.body))
+			if isRetryableError(err) != tt.retryable {
+				t.Errorf("retryable = %v, want %v (%v)", isRetryableError(err), tt.retryable, err)
+			}
+		})
+	}
+}
+
+func TestTTSRouter_Resolve(t *testing.T) {
+	openai := NewOpenAITTSAdapter("key", zap.NewNop())
+	elevenlabs := NewElevenLabsTTSAdapter(ElevenLabsConfig{APIKey: "key"}, zap.NewNop())
+
+	t.Run("both configured", func(t *testing.T) {
+		router := NewTTSRouter(TTSProviderOpenAI, map[TTSProvider]TTSAdapter{
+			TTSProviderOpenAI:     openai,
+			TTSProviderElevenLabs: elevenlabs,
+		}, zap.NewNop())
+
+		if adapter, provider := router.Resolve("elevenlabs"); adapter != TTSAdapter(elevenlabs) || provider != TTSProviderElevenLabs {
+			t.Errorf("Resolve(elevenlabs) = %T, %q", adapter, provider)
+		}
+		if adapter, provider := router.Resolve(""); adapter != TTSAdapter(openai) || provider != TTSProviderOpenAI {
+			t.Errorf("Resolve(\"\") = %T, %q", adapter, provider)
+		}
+	})
+
+	t.Run("elevenlabs key missing falls back to openai", func(t *testing.T) {
+		router := NewTTSRouter(TTSProviderElevenLabs, map[TTSProvider]TTSAdapter{
+			TTSProviderOpenAI:     openai,
+			TTSProviderElevenLabs: nil,
+		}, zap.NewNop())
+
+		if adapter, provider := router.Resolve(""); adapter != TTSAdapter(openai) || provider != TTSProviderOpenAI {
+			t.Errorf("Resolve(\"\") = %T, %q, want OpenAI fallback", adapter, provider)
+		}
+	})
+
+	t.Run("nothing configured", func(t *testing.T) {
+		var router *TTSRouter
+		if adapter, provider := router.Resolve("openai"); adapter != nil || provider != "" {
+			t.Errorf("nil router Resolve() = %T, %q", adapter, provider)
+		}
+	})
+}
+
+func TestElevenLabsTTSAdapter_Voices(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/v1/voices" || r.Header.Get("xi-api-key") != "test-key" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"voices": [
+			{"voice_id": "zoeVoiceId1234567", "name": "Zoe"},
+			{"voice_id": "femaleVoiceId12345", "name": "Brand Narrator"},
+			{"voice_id": "benVoiceId12345678", "name": "Ben"}
+		]}`))
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	want := []Voice{
+		{ID: "male", Label: "Male", Provider: TTSProviderElevenLabs},
+		{ID: "female", Label: "Female (Brand Narrator)", Provider: TTSProviderElevenLabs},
+		{ID: "benVoiceId12345678", Label: "Ben", Provider: TTSProviderElevenLabs},
+		{ID: "zoeVoiceId1234567", Label: "Zoe", Provider: TTSProviderElevenLabs},
+	}
+	for i := 0; i < 2; i++ {
+		voices, err := adapter.Voices(context.Background())
+		if err != nil {
+			t.Fatalf("Voices() error = %v", err)
+		}
+		if len(voices) != len(want) {
+			t.Fatalf("Voices() = %+v, want %+v", voices, want)
+		}
+		for j := range want {
+			if voices[j] != want[j] {
+				t.Errorf("voice %d = %+v, want %+v", j, voices[j], want[j])
+			}
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("voices endpoint called %d times, want 1 (cached)", calls.Load())
+	}
+}
+
+func TestElevenLabsTTSAdapter_VoicesFallback(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	for i := 0; i < 2; i++ {
+		voices, err := adapter.Voices(context.Background())
+		if err != nil {
+			t.Fatalf("Voices() error = %v", err)
+		}
+		if len(voices) != 2 || voices[0].ID != "male" || voices[1].ID != "female" {
+			t.Errorf("Voices() = %+v, want the configured male and female voices", voices)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("voices endpoint called %d times, want 2 (failures aren't cached)", calls.Load())
+	}
+}
+
+func TestTTSRouter_Voices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"voices": []}`))
+	}))
+	defer server.Close()
+
+	router := NewTTSRouter(TTSProviderElevenLabs, map[TTSProvider]TTSAdapter{
+		TTSProviderOpenAI:     NewOpenAITTSAdapter("key", zap.NewNop()),
+		TTSProviderElevenLabs: newTestElevenLabsAdapter(server.URL),
+	}, zap.NewNop())
+
+	var got []string
+	for _, voice := range router.Voices(context.Background()) {
+		got = append(got, string(voice.Provider)+"/"+voice.ID)
+	}
+	want := "elevenlabs/male elevenlabs/female openai/male openai/female"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Voices() = %v, want %s", got, want)
+	}
+
+	var unconfigured *TTSRouter
+	if voices := unconfigured.Voices(context.Background()); voices != nil {
+		t.Errorf("nil router Voices() = %v", voices)
+	}
+}
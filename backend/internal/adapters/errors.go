@@ -0,0 +1,143 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// Provider names in errors, as users know them; Veo, Minimax and MusicGen run on Replicate
+const (
+	ProviderReplicate  = "Replicate"
+	ProviderOpenAI     = "OpenAI"
+	ProviderElevenLabs = "ElevenLabs"
+)
+
+// APIError is an error status returned by a provider's API
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string // Response body, or the provider's message when the body was parsed
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// InsufficientCreditsError is returned when the provider account can't pay for a request: a 402,
+// or OpenAI's 429 with code insufficient_quota
+type InsufficientCreditsError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *InsufficientCreditsError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): Payment required - %s account has insufficient credits or a billing issue. Please check the %s account balance. Response: %s",
+		e.Provider, e.StatusCode, e.Provider, e.Provider, e.Body)
+}
+
+// Unwrap exposes the status as an *APIError
+func (e *InsufficientCreditsError) Unwrap() error {
+	return &APIError{Provider: e.Provider, StatusCode: e.StatusCode, Body: e.Body}
+}
+
+// TimeoutError is returned when a provider request or prediction didn't finish in time
+type TimeoutError struct {
+	Provider string
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s request timed out: %v", e.Provider, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// ContentPolicyError is returned when a provider refused an input or output under its content
+// policy
+type ContentPolicyError struct {
+	Provider string
+	Reason   string // The provider's explanation
+	Err      error  // The failure it was recognized in, if any
+}
+
+func (e *ContentPolicyError) Error() string {
+	return fmt.Sprintf("%s rejected the request under its content policy: %s", e.Provider, e.Reason)
+}
+
+func (e *ContentPolicyError) Unwrap() error { return e.Err }
+
+// newStatusError builds the typed error of an error status from provider
+func newStatusError(provider string, status int, body []byte) error {
+	if status == http.StatusPaymentRequired {
+		return &InsufficientCreditsError{Provider: provider, StatusCode: status, Body: string(body)}
+	}
+	return &APIError{Provider: provider, StatusCode: status, Body: string(body)}
+}
+
+// replicateStatusError turns an error status from the Replicate API into an error for retry.Do:
+// a rejected key is retried once it has been rotated, other 4xx errors aren't, 5xx errors are
+func replicateStatusError(ctx context.Context, tokens TokenSource, token string, status int, body []byte) error {
+	err := newStatusError(ProviderReplicate, status, body)
+	switch {
+	case status == http.StatusUnauthorized:
+		return unauthorizedError(ctx, tokens, token, err)
+	case status >= 400 && status < 500:
+		return retry.NewNonRetryableError(err)
+	}
+	return err
+}
+
+// requestError wraps the error of a request to provider that got no response, marking timeouts
+func requestError(provider string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &TimeoutError{Provider: provider, Err: err}
+	}
+	return fmt.Errorf("request failed: %w", err)
+}
+
+// contentPolicyMarkers are phrases providers use in failed predictions refused for their content
+var contentPolicyMarkers = []string{
+	"content policy",
+	"flagged as sensitive",
+	"(E005)",
+	"safety filter",
+	"nsfw",
+}
+
+// isContentPolicyMessage reports whether a provider's failure message is a content policy refusal
+func isContentPolicyMessage(message string) bool {
+	lower := strings.ToLower(message)
+	for _, marker := range contentPolicyMarkers {
+		if strings.Contains(lower, strings.ToLower(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryable reports whether a failed provider call may succeed if made again: timeouts, rate
+// limits and server errors may, a refused or unpaid request and other client errors won't.
+// Errors that carry no typed error are assumed not to.
+func IsRetryable(err error) bool {
+	var policy *ContentPolicyError
+	var credits *InsufficientCreditsError
+	var timeout *TimeoutError
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &policy), errors.As(err, &credits):
+		return false
+	case errors.As(err, &timeout):
+		return true
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+func TestReplicateStatusError(t *testing.T) {
+	tests := []struct {
+		status       int
+		wantCredits  bool
+		nonRetryable bool // Stops retry.Do
+		retryable    bool // IsRetryable
+	}{
+		{http.StatusPaymentRequired, true, true, false},
+		{http.StatusUnprocessableEntity, false, true, false},
+		{http.StatusTooManyRequests, false, true, true},
+		{http.StatusBadGateway, false, false, true},
+	}
+	for _, tt := range tests {
+		err := replicateStatusError(context.Background(), StaticToken("token"), "token", tt.status, []byte("body"))
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Provider != ProviderReplicate || apiErr.Body != "body" {
+			t.Errorf("%d: err = %v, want an *APIError with the status and body", tt.status, err)
+		}
+		var credits *InsufficientCreditsError
+		if errors.As(err, &credits) != tt.wantCredits {
+			t.Errorf("%d: insufficient credits = %v, want %v", tt.status, !tt.wantCredits, tt.wantCredits)
+		}
+		if retry.IsNonRetryable(err) != tt.nonRetryable {
+			t.Errorf("%d: non-retryable = %v, want %v", tt.status, !tt.nonRetryable, tt.nonRetryable)
+		}
+		if IsRetryable(err) != tt.retryable {
+			t.Errorf("%d: IsRetryable = %v, want %v", tt.status, !tt.retryable, tt.retryable)
+		}
+	}
+}
+
+func TestRequestErrorMarksTimeouts(t *testing.T) {
+	var timeout *TimeoutError
+	if err := requestError(ProviderOpenAI, context.DeadlineExceeded); !errors.As(err, &timeout) || timeout.Provider != ProviderOpenAI {
+		t.Errorf("err = %v, want a *TimeoutError", err)
+	}
+	if err := requestError(ProviderOpenAI, errors.New("connection refused")); errors.As(err, &timeout) || IsRetryable(err) {
+		t.Errorf("err = %v, want a plain request error", err)
+	}
+}
+
+func TestContentPolicyMessages(t *testing.T) {
+	tests := map[string]bool{
+		"The input or output was flagged as sensitive. Please try again with different inputs. (E005)": true,
+		"NSFW content detected":              true,
+		"Prompt violates our content policy": true,
+		"CUDA out of memory":                 false,
+		"provider returned no error details": false,
+	}
+	for message, want := range tests {
+		if got := isContentPolicyMessage(message); got != want {
+			t.Errorf("%q: content policy = %v, want %v", message, got, want)
+		}
+	}
+}
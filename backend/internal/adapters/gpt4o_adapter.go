@@ -17,29 +17,55 @@ import (
 
 	"github.com/omnigen/backend/internal/domain"
 	"github.com/omnigen/backend/internal/prompts"
+	"github.com/omnigen/backend/internal/trace"
 	"github.com/omnigen/backend/pkg/retry"
 )
 
 // GPT4oAdapter implements script generation via OpenAI GPT-4o on Replicate
 type GPT4oAdapter struct {
-	apiToken     string
+	tokens       TokenSource
 	httpClient   *http.Client
 	logger       *zap.Logger
 	modelVersion string
+	webhooks     *ReplicateWebhooks
 }
 
-// NewGPT4oAdapter creates a new GPT-4o adapter
-func NewGPT4oAdapter(apiToken string, logger *zap.Logger) *GPT4oAdapter {
+// NewGPT4oAdapter creates a new GPT-4o adapter; an empty model uses DefaultGPT4oModel
+func NewGPT4oAdapter(apiToken, model string, logger *zap.Logger) *GPT4oAdapter {
+	if model == "" {
+		model = DefaultGPT4oModel
+	}
 	return &GPT4oAdapter{
-		apiToken: apiToken,
+		tokens: StaticToken(apiToken),
 		httpClient: &http.Client{
 			Timeout: 120 * time.Second, // GPT-4o can take a while for complex scripts
 		},
 		logger:       logger,
-		modelVersion: "openai/gpt-4o:ad45308bffd6defaaa05dff12658b454a3a8dcfd7cc1440420a74d87a48caa9e",
+		modelVersion: model,
 	}
 }
 
+// SetReplicateWebhooks makes new predictions report completion by webhook; nil polls only
+func (g *GPT4oAdapter) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	g.webhooks = webhooks
+}
+
+// SetTokenSource makes requests use the token tokens supplies at the time they're sent,
+// instead of the one passed to NewGPT4oAdapter
+func (g *GPT4oAdapter) SetTokenSource(tokens TokenSource) {
+	g.tokens = tokens
+}
+
+// ReplicateWebhooks returns the webhooks new predictions report to
+func (g *GPT4oAdapter) ReplicateWebhooks() *ReplicateWebhooks {
+	return g.webhooks
+}
+
+// ModelVersion returns the model version predictions on ctx are submitted with
+func (g *GPT4oAdapter) ModelVersion(ctx context.Context) string {
+	return modelFor(ctx, ModelGPT4o, g.modelVersion)
+}
+
 // ScriptGenerationRequest represents the input for script generation - SIMPLE interface
 type ScriptGenerationRequest struct {
 	Prompt      string // Free-form prompt with ALL context (product, audience, vibe, etc.)
@@ -57,106 +83,124 @@ type ScriptGenerationRequest struct {
 	// Style reference image - will be analyzed and converted to text description
 	StyleReferenceImage string
 
-	// Video model for generation (veo, kling, minimax) - affects prompt optimization
+	// Video model for generation (veo, kling) - affects prompt optimization and scene duration validation
 	VideoModel string
+
+	// Brand guidelines (optional) - rendered as a BRAND GUIDELINES system prompt section
+	BrandGuidelines *prompts.BrandGuidelines
+
+	// Product photos (optional) - GPT-4o assigns them to scenes via assigned_image_ref
+	ProductImages []prompts.ProductImage
+
+	// Scene plan (optional) - the exact scenes and durations to write, enforced on the output
+	ScenePlan []prompts.PlannedScene
+
+	// Distinct scripts GenerateScriptVariants writes in one response (0 or 1 writes one)
+	Variants int
+
+	// Language of the narration, voiceovers, title and call to action (zero value writes English)
+	Language prompts.Language
+
+	// Adjacent scenes of a previous draft that repeated each other, to rewrite apart (optional)
+	RepeatedScenes []prompts.RepeatedScenes
 }
 
 // GPT4oRequest matches the Replicate OpenAI GPT-4o API schema
 type GPT4oRequest struct {
 	Version string                 `json:"version"`
 	Input   map[string]interface{} `json:"input"`
+	ReplicateWebhookFields
 }
 
 // GPT4oResponse represents the Replicate API response
 type GPT4oResponse struct {
-	ID     string   `json:"id"`
-	Status string   `json:"status"`
-	Output []string `json:"output,omitempty"` // Array of strings (streaming)
-	Error  string   `json:"error,omitempty"`
+	ID      string            `json:"id"`
+	Status  string            `json:"status"`
+	Output  []string          `json:"output,omitempty"` // Array of strings (streaming)
+	Error   string            `json:"error,omitempty"`
+	Metrics *predictionTokens `json:"metrics,omitempty"` // Set once the prediction has finished
 }
 
-// GenerateScript generates a structured ad script using GPT-4o
-func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGenerationRequest) (*domain.Script, error) {
-	g.logger.Info("Generating script with GPT-4o",
-		zap.String("prompt", req.Prompt[:min(100, len(req.Prompt))]),
-		zap.Int("duration", req.Duration),
-	)
+// predictionTokens is the token usage in the metrics of a finished language model prediction
+type predictionTokens struct {
+	InputTokenCount  int `json:"input_token_count"`
+	OutputTokenCount int `json:"output_token_count"`
+}
 
-	// Analyze style reference image if provided
-	var styleDescription string
-	if req.StyleReferenceImage != "" {
-		var err error
-		styleDescription, err = g.AnalyzeStyleReference(ctx, req.StyleReferenceImage)
-		if err != nil {
-			g.logger.Warn("Failed to analyze style reference image, continuing without it",
-				zap.Error(err),
-			)
-			// Continue without style description rather than failing completely
-			styleDescription = ""
-		}
+// recordUsage logs and counts the tokens a finished GPT-4o prediction consumed; a prediction
+// without metrics counts none
+func (g *GPT4oAdapter) recordUsage(ctx context.Context, call string, resp *GPT4oResponse) {
+	if resp.Metrics == nil {
+		return
 	}
+	recordTokenUsage(ctx, g.logger, replicateProvider, g.ModelVersion(ctx), call, TokenUsage{
+		PromptTokens:     resp.Metrics.InputTokenCount,
+		CompletionTokens: resp.Metrics.OutputTokenCount,
+	})
+}
 
-	// Build user prompt from request
-	userPrompt := buildUserPrompt(req)
+// ScriptGenerator turns a generation request into validated ad scripts
+type ScriptGenerator interface {
+	// GenerateScript writes one script, ignoring req.Variants
+	GenerateScript(ctx context.Context, req *ScriptGenerationRequest) (*domain.Script, error)
 
-	g.logger.Debug("Generated user prompt",
-		zap.String("prompt", userPrompt),
-	)
+	// GenerateScriptVariants writes req.Variants distinct scripts for the same brief
+	GenerateScriptVariants(ctx context.Context, req *ScriptGenerationRequest) ([]*domain.Script, error)
 
-	// Build enhanced system prompt if options provided
-	systemPrompt := prompts.AdScriptSystemPrompt + "\n\n" + prompts.AdScriptFewShotExamples
-	if req.EnhancedOptions != nil {
-		systemPrompt = prompts.BuildEnhancedSystemPrompt(systemPrompt, req.EnhancedOptions)
-		g.logger.Info("Using enhanced system prompt",
-			zap.String("style", req.EnhancedOptions.Style),
-			zap.String("tone", req.EnhancedOptions.Tone),
-			zap.String("platform", req.EnhancedOptions.Platform),
-			zap.Bool("pro_cinematography", req.EnhancedOptions.ProCinematography),
-		)
-	}
+	// AnalyzeStyleReference describes the visual style of the image at imageURL
+	AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error)
+}
 
-	// Add pharmaceutical guidance for pharma ads (when Voice and SideEffects are provided)
-	isPharmaceuticalAd := req.Voice != "" && req.SideEffects != ""
-	if isPharmaceuticalAd {
-		systemPrompt += "\n\n" + prompts.PharmaceuticalAdGuidance
-		g.logger.Info("Added pharmaceutical ad guidance to system prompt")
+// GenerateScript generates a structured ad script using GPT-4o
+func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGenerationRequest) (*domain.Script, error) {
+	single := *req
+	single.Variants = 0
+	scripts, err := g.generateScripts(ctx, &single)
+	if err != nil {
+		return nil, err
 	}
+	return scripts[0], nil
+}
 
-	// Add model-specific guidance based on target video model
-	targetModel := req.VideoModel
-	if targetModel == "" {
-		targetModel = prompts.DefaultVideoModel
-	}
-	if guidance, ok := prompts.ModelPromptGuidance[targetModel]; ok {
-		systemPrompt += "\n\n" + guidance
-		g.logger.Info("Added model-specific guidance to system prompt",
-			zap.String("video_model", targetModel),
-		)
-	}
+// GenerateScriptVariants generates req.Variants scripts for A/B testing in a single GPT-4o
+// call, so they are written against each other and differ in hook and structure
+func (g *GPT4oAdapter) GenerateScriptVariants(ctx context.Context, req *ScriptGenerationRequest) ([]*domain.Script, error) {
+	return g.generateScripts(ctx, req)
+}
 
-	// Determine temperature based on creative boost
-	temperature := 0.7 // Default: creative but not random
-	if req.EnhancedOptions != nil && req.EnhancedOptions.CreativeBoost {
-		temperature = 0.9 // Boosted creativity
-		g.logger.Info("Using creative boost", zap.Float64("temperature", temperature))
+// generateScripts asks GPT-4o for max(req.Variants, 1) scripts and validates each of them
+func (g *GPT4oAdapter) generateScripts(ctx context.Context, req *ScriptGenerationRequest) ([]*domain.Script, error) {
+	count := max(req.Variants, 1)
+	logger := trace.Logger(ctx, g.logger)
+	logger.Info("Generating script with GPT-4o",
+		zap.String("prompt", req.Prompt[:min(100, len(req.Prompt))]),
+		zap.Int("duration", req.Duration),
+		zap.Int("variants", count),
+	)
+
+	p := buildScriptPrompt(logger, req)
+	if err := checkTokenBudget(ctx, "script", estimatePromptTokens(0, p.System, p.User), p.MaxTokens); err != nil {
+		return nil, err
 	}
+	styleDescription := analyzeStyleReference(ctx, logger, g, req)
 
 	// Build Replicate API request
 	gpt4oReq := GPT4oRequest{
-		Version: g.modelVersion,
+		Version:                useModel(ctx, g.logger, ModelGPT4o, g.modelVersion),
+		ReplicateWebhookFields: g.webhooks.requestFields(),
 		Input: map[string]interface{}{
 			"messages": []map[string]string{
 				{
 					"role":    "system",
-					"content": systemPrompt,
+					"content": p.System,
 				},
 				{
 					"role":    "user",
-					"content": userPrompt,
+					"content": p.User,
 				},
 			},
-			"temperature":           temperature,
-			"max_completion_tokens": 8192, // Sufficient for complex 60s scripts with multiple scenes
+			"temperature":           p.Temperature,
+			"max_completion_tokens": p.MaxTokens,
 			"top_p":                 0.9,
 		},
 	}
@@ -167,6 +211,7 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 	}
 
 	// Submit prediction to Replicate with retry logic
+	submitted := time.Now()
 	var gpt4oResp GPT4oResponse
 	err = retry.Do(ctx, retry.APIConfig(), func() error {
 		httpReq, err := http.NewRequestWithContext(ctx, "POST",
@@ -176,18 +221,22 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", "Bearer "+g.apiToken)
+		token, err := authorize(ctx, httpReq, g.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 		// Don't use Prefer: wait to avoid 60-second timeout - we'll poll instead
 
 		resp, err := g.httpClient.Do(httpReq)
 		if err != nil {
-			g.logger.Error("Failed to send request to Replicate API",
+			logger.Error("Failed to send request to Replicate API",
 				zap.Error(err),
 				zap.String("url", "https://api.replicate.com/v1/predictions"),
 			)
 			// Network errors are retryable
-			return fmt.Errorf("request failed: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -197,27 +246,15 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 		}
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
-			errorMsg := fmt.Sprintf("API error (status %d): %s", resp.StatusCode, string(body))
-			g.logger.Error("Replicate API returned error",
+			logger.Error("Replicate API returned error",
 				zap.Int("status_code", resp.StatusCode),
 				zap.String("response_body", string(body)),
-				zap.String("error_message", errorMsg),
 			)
-
-			// 4xx errors are non-retryable
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				if resp.StatusCode == 402 { // Payment Required
-					return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): Payment required - Replicate account has insufficient credits or billing issue. Please check your Replicate account balance. Response: %s", resp.StatusCode, string(body)))
-				}
-				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
-			}
-
-			// 5xx errors are retryable
-			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+			return replicateStatusError(ctx, g.tokens, token, resp.StatusCode, body)
 		}
 
 		if err := json.Unmarshal(body, &gpt4oResp); err != nil {
-			g.logger.Error("Failed to unmarshal initial response",
+			logger.Error("Failed to unmarshal initial response",
 				zap.Error(err),
 				zap.String("body_preview", string(body)[:min(1000, len(body))]),
 			)
@@ -237,7 +274,7 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 		initialOutput += part
 	}
 
-	g.logger.Info("Initial GPT-4o response",
+	logger.Info("Initial GPT-4o response",
 		zap.String("prediction_id", gpt4oResp.ID),
 		zap.String("status", gpt4oResp.Status),
 		zap.Int("initial_output_chunks", len(gpt4oResp.Output)),
@@ -253,7 +290,7 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 	if !needsPolling && len(initialOutput) > 0 {
 		trimmed := strings.TrimSpace(initialOutput)
 		if !strings.HasSuffix(trimmed, "}") {
-			g.logger.Warn("Initial output appears truncated, will poll",
+			logger.Warn("Initial output appears truncated, will poll",
 				zap.String("output_end", trimmed[max(0, len(trimmed)-100):]),
 			)
 			needsPolling = true
@@ -261,84 +298,35 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 	}
 
 	if needsPolling {
-		g.logger.Info("Waiting for GPT-4o completion",
+		logger.Info("Waiting for GPT-4o completion",
 			zap.String("prediction_id", gpt4oResp.ID),
 			zap.String("status", gpt4oResp.Status),
 		)
 
-		// Poll for completion (max 5 minutes for script generation)
 		// Longer timeout because script generation can be complex with vision analysis
-		maxAttempts := 60 // 60 * 5s = 5 minutes
-		pollInterval := 5 * time.Second
-
-		for attempt := 0; attempt < maxAttempts; attempt++ {
-			select {
-			case <-ctx.Done():
-				return nil, fmt.Errorf("context cancelled while polling: %w", ctx.Err())
-			default:
-			}
-
-			time.Sleep(pollInterval)
-
-			pollResp, err := g.pollStatus(ctx, gpt4oResp.ID)
-			if err != nil {
-				g.logger.Warn("Failed to poll status, retrying",
-					zap.Error(err),
-					zap.Int("attempt", attempt+1),
-					zap.Int("max_attempts", maxAttempts),
-				)
-				continue
-			}
-
-			// Concatenate output to check actual content length
-			var currentOutput string
-			for _, part := range pollResp.Output {
-				currentOutput += part
-			}
-
-			g.logger.Info("Poll status check",
-				zap.String("status", pollResp.Status),
-				zap.Int("output_chunks", len(pollResp.Output)),
-				zap.Int("output_chars", len(currentOutput)),
-				zap.Int("attempt", attempt+1),
-				zap.String("output_preview", currentOutput[:min(200, len(currentOutput))]),
-			)
-
-			if pollResp.Status == "succeeded" && len(pollResp.Output) > 0 {
-				g.logger.Info("Generation succeeded, using final output",
-					zap.Int("final_output_chunks", len(pollResp.Output)),
-					zap.Int("final_output_chars", len(currentOutput)),
-				)
-				gpt4oResp = *pollResp
-				break
-			}
-
-			if pollResp.Status == "failed" || pollResp.Status == "canceled" {
-				return nil, fmt.Errorf("GPT-4o generation failed: %s", pollResp.Error)
-			}
-
-			// Update response for next iteration even if not succeeded yet
-			gpt4oResp = *pollResp
+		final, err := g.waitForOutput(ctx, gpt4oResp.ID, "GPT-4o script", 5*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("GPT-4o generation failed: %w", err)
 		}
+		gpt4oResp = *final
+	} else {
+		// Finished within the submission request, so no poll recorded it
+		recordProviderCall(ctx, replicateProvider, g.ModelVersion(ctx), gpt4oResp.ID, submitted, nil)
 	}
+	g.recordUsage(ctx, "script", &gpt4oResp)
 
 	// Extract and parse JSON response
 	if len(gpt4oResp.Output) == 0 {
 		return nil, fmt.Errorf("no output from GPT-4o after polling (final status: %s)", gpt4oResp.Status)
 	}
 
-	// Check if we timed out without succeeding
-	if gpt4oResp.Status != "succeeded" {
-		return nil, fmt.Errorf("GPT-4o generation did not complete in time (status: %s)", gpt4oResp.Status)
-	}
-
 	// Combine output array into single string
 	var scriptJSON string
 	for i, part := range gpt4oResp.Output {
 		scriptJSON += part
 		// Log suspicious chunks
 		if i < 5 || i >= len(gpt4oResp.Output)-5 {
-			g.logger.Debug("Output chunk",
+			logger.Debug("Output chunk",
 				zap.Int("chunk_index", i),
 				zap.Int("chunk_length", len(part)),
 				zap.String("chunk_preview", part[:min(50, len(part))]),
@@ -346,7 +334,7 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 		}
 	}
 
-	g.logger.Info("Received GPT-4o output",
+	logger.Info("Received GPT-4o output",
 		zap.Int("output_length", len(scriptJSON)),
 		zap.Int("output_chunks", len(gpt4oResp.Output)),
 		zap.String("final_status", gpt4oResp.Status),
@@ -354,44 +342,222 @@ func (g *GPT4oAdapter) GenerateScript(ctx context.Context, req *ScriptGeneration
 		zap.String("output_end", scriptJSON[max(0, len(scriptJSON)-200):]),
 	)
 
-	// Try to find where valid JSON ends
-	lastBrace := strings.LastIndex(scriptJSON, "}")
-	if lastBrace > 0 && lastBrace < len(scriptJSON)-1 {
-		g.logger.Warn("Found content after last closing brace",
-			zap.Int("json_end_pos", lastBrace),
-			zap.Int("total_length", len(scriptJSON)),
-			zap.String("garbage_after_json", scriptJSON[lastBrace+1:min(lastBrace+100, len(scriptJSON))]),
+	// Log full output for debugging truncation issues
+	logger.Debug("Full GPT-4o output", zap.String("full_output", scriptJSON))
+
+	return finishScripts(logger, req, p, scriptJSON, styleDescription)
+}
+
+// analyzeStyleReference describes req's style reference image, if it has one. A failed analysis
+// returns "", since the script is still usable without the style.
+func analyzeStyleReference(ctx context.Context, logger *zap.Logger, styles ScriptGenerator, req *ScriptGenerationRequest) string {
+	if req.StyleReferenceImage == "" {
+		return ""
+	}
+	styleDescription, err := styles.AnalyzeStyleReference(ctx, req.StyleReferenceImage)
+	if err != nil {
+		logger.Warn("Failed to analyze style reference image, continuing without it",
+			zap.Error(err),
 		)
-		// Truncate to last valid JSON closing brace
-		scriptJSON = scriptJSON[:lastBrace+1]
-		g.logger.Info("Truncated to last valid JSON brace", zap.Int("new_length", len(scriptJSON)))
+		return ""
 	}
+	return styleDescription
+}
 
-	// Log full output for debugging truncation issues
-	g.logger.Debug("Full GPT-4o output", zap.String("full_output", scriptJSON))
+// scriptPrompt is the chat completion that writes the scripts for a request, whichever
+// provider runs it
+type scriptPrompt struct {
+	System      string
+	User        string
+	Temperature float64
+	MaxTokens   int    // Shared by all the variants
+	Count       int    // Scripts the response holds
+	VideoModel  string // The request's video model, or prompts.DefaultVideoModel
+}
+
+// buildScriptPrompt assembles the system and user prompts for req and their sampling settings
+func buildScriptPrompt(logger *zap.Logger, req *ScriptGenerationRequest) scriptPrompt {
+	count := max(req.Variants, 1)
+
+	// Build user prompt from request
+	userPrompt := buildUserPrompt(req)
+
+	logger.Debug("Generated user prompt",
+		zap.String("prompt", userPrompt),
+	)
+
+	// Build enhanced system prompt if options provided
+	systemPrompt := prompts.AdScriptSystemPrompt + "\n\n" + prompts.AdScriptFewShotExamples
+	if req.EnhancedOptions != nil {
+		systemPrompt = prompts.BuildEnhancedSystemPrompt(systemPrompt, req.EnhancedOptions)
+		logger.Info("Using enhanced system prompt",
+			zap.String("style", req.EnhancedOptions.Style),
+			zap.String("tone", req.EnhancedOptions.Tone),
+			zap.String("platform", req.EnhancedOptions.Platform),
+			zap.Bool("pro_cinematography", req.EnhancedOptions.ProCinematography),
+		)
+	}
+
+	// Add brand guidelines after creative direction so brand rules take precedence
+	if section := prompts.BuildBrandGuidelinesSection(req.BrandGuidelines); section != "" {
+		systemPrompt += "\n\n" + section
+		logger.Info("Added brand guidelines to system prompt",
+			zap.String("brand", req.BrandGuidelines.Name),
+		)
+	}
+
+	if section := prompts.BuildProductImagesSection(req.ProductImages); section != "" {
+		systemPrompt += "\n\n" + section
+		logger.Info("Added product images to system prompt",
+			zap.Int("num_images", len(req.ProductImages)),
+		)
+	}
+
+	// Add pharmaceutical guidance for pharma ads (when Voice and SideEffects are provided)
+	isPharmaceuticalAd := req.Voice != "" && req.SideEffects != ""
+	if isPharmaceuticalAd {
+		systemPrompt += "\n\n" + prompts.PharmaceuticalAdGuidance
+		logger.Info("Added pharmaceutical ad guidance to system prompt")
+	}
+
+	// After the pharmaceutical guidance, whose English pacing it replaces
+	if section := prompts.BuildLanguageSection(req.Language); section != "" {
+		systemPrompt += "\n\n" + section
+		logger.Info("Added language to system prompt",
+			zap.String("language", req.Language.Tag),
+			zap.Float64("words_per_second", req.Language.WordsPerSecond),
+		)
+	}
+
+	// Add model-specific guidance based on target video model
+	targetModel := req.VideoModel
+	if targetModel == "" {
+		targetModel = prompts.DefaultVideoModel
+	}
+	if guidance, ok := prompts.ModelPromptGuidance[targetModel]; ok {
+		systemPrompt += "\n\n" + guidance
+		logger.Info("Added model-specific guidance to system prompt",
+			zap.String("video_model", targetModel),
+		)
+	}
+
+	if section := prompts.BuildScenePlanSection(req.ScenePlan); section != "" {
+		systemPrompt += "\n\n" + section
+		logger.Info("Added scene plan to system prompt", zap.Int("num_scenes", len(req.ScenePlan)))
+	}
+
+	if section := prompts.BuildRepeatedScenesSection(req.RepeatedScenes); section != "" {
+		systemPrompt += "\n\n" + section
+		logger.Info("Added repeated scenes to system prompt", zap.Int("num_pairs", len(req.RepeatedScenes)))
+	}
+
+	// Variants are asked for last so the wrapper overrides "respond with a single script"
+	if section := prompts.BuildVariantsSection(count); section != "" {
+		systemPrompt += "\n\n" + section
+		logger.Info("Added A/B variants to system prompt", zap.Int("variants", count))
+	}
+
+	// Determine temperature based on creative boost
+	temperature := 0.7 // Default: creative but not random
+	if req.EnhancedOptions != nil && req.EnhancedOptions.CreativeBoost {
+		temperature = 0.9 // Boosted creativity
+		logger.Info("Using creative boost", zap.Float64("temperature", temperature))
+	}
+
+	return scriptPrompt{
+		System:      systemPrompt,
+		User:        userPrompt,
+		Temperature: temperature,
+		MaxTokens:   min(8192*count, maxScriptCompletionTokens), // 8192 is sufficient for one complex 60s script
+		Count:       count,
+		VideoModel:  targetModel,
+	}
+}
 
-	// Parse JSON into Script struct
-	script, err := g.parseScriptJSON(scriptJSON, styleDescription)
+// finishScripts parses the model's output for p into scripts, fits their scenes to the video
+// model and validates them against req
+func finishScripts(logger *zap.Logger, req *ScriptGenerationRequest, p scriptPrompt, output, styleDescription string) ([]*domain.Script, error) {
+	count, targetModel := p.Count, p.VideoModel
+	isPharmaceuticalAd := req.Voice != "" && req.SideEffects != ""
+
+	// Parse JSON into Script structs
+	var scripts []*domain.Script
+	var err error
+	if count > 1 {
+		scripts, err = parseScriptVariantsJSON(logger, output, styleDescription, count)
+	} else {
+		var script *domain.Script
+		script, err = parseScriptJSON(logger, output, styleDescription)
+		scripts = []*domain.Script{script}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse script JSON: %w", err)
 	}
 
-	// Validate script
-	if err := validateScript(script, req.Duration, isPharmaceuticalAd); err != nil {
-		return nil, fmt.Errorf("script validation failed: %w", err)
+	videoModel := AdapterType(targetModel)
+	for i, script := range scripts {
+		variantLogger := logger
+		if count > 1 {
+			variantLogger = logger.With(zap.Int("variant", i+1))
+		}
+
+		// Snap scene durations to clip lengths the video model can generate. A planned script's
+		// durations are checked against the plan as written, so its scenes are only retimed.
+		if len(req.ScenePlan) > 0 {
+			retimeScenes(script)
+		} else if len(ClipDurations(videoModel)) > 0 {
+			adjustments, err := NormalizeSceneDurations(script, req.Duration, videoModel)
+			if err != nil {
+				return nil, variantError(count, i, fmt.Errorf("script validation failed: %w", err))
+			}
+			for _, adj := range adjustments {
+				variantLogger.Warn("Normalized scene duration for video model",
+					zap.String("video_model", targetModel),
+					zap.Int("scene_number", adj.SceneNumber),
+					zap.Float64("original_duration", adj.Original),
+					zap.Float64("normalized_duration", adj.Normalized),
+				)
+			}
+		}
+
+		// Scenes referencing an image that wasn't offered fall back to the continuity frame
+		for _, rejected := range ResolveImageAssignments(script, req.ProductImages) {
+			variantLogger.Warn("Dropped scene image assignment",
+				zap.Int("scene_number", rejected.SceneNumber),
+				zap.String("assigned_image_ref", rejected.Ref),
+				zap.String("reason", rejected.Reason),
+			)
+		}
+
+		// Validate script
+		if err := ValidateScript(script, req.Duration, isPharmaceuticalAd, videoModel, req.ScenePlan); err != nil {
+			return nil, variantError(count, i, fmt.Errorf("script validation failed: %w", err))
+		}
+
+		variantLogger.Info("Script generated successfully",
+			zap.String("title", script.Title),
+			zap.Int("num_scenes", len(script.Scenes)),
+			zap.Int("total_duration", script.TotalDuration),
+		)
 	}
 
-	g.logger.Info("Script generated successfully",
-		zap.String("title", script.Title),
-		zap.Int("num_scenes", len(script.Scenes)),
-		zap.Int("total_duration", script.TotalDuration),
-	)
+	return scripts, nil
+}
+
+// maxScriptCompletionTokens is GPT-4o's output limit, shared by all the variants of a request
+const maxScriptCompletionTokens = 16384
 
-	return script, nil
+// variantError names the variant an error is about when several were requested
+func variantError(count, index int, err error) error {
+	if count <= 1 {
+		return err
+	}
+	return fmt.Errorf("variant %d: %w", index+1, err)
 }
 
 // pollStatus checks the status of a GPT-4o prediction
 func (g *GPT4oAdapter) pollStatus(ctx context.Context, predictionID string) (*GPT4oResponse, error) {
+	logger := trace.Logger(ctx, g.logger)
 	url := fmt.Sprintf("https://api.replicate.com/v1/predictions/%s", predictionID)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -399,7 +565,11 @@ func (g *GPT4oAdapter) pollStatus(ctx context.Context, predictionID string) (*GP
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("Authorization", "Bearer "+g.apiToken)
+	token, err := authorize(ctx, httpReq, g.tokens)
+	if err != nil {
+		return nil, err
+	}
+	trace.SetHeader(httpReq)
 
 	// Use a separate client with shorter timeout for polling requests
 	pollClient := &http.Client{
@@ -408,7 +578,7 @@ func (g *GPT4oAdapter) pollStatus(ctx context.Context, predictionID string) (*GP
 
 	resp, err := pollClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, requestError(ProviderReplicate, err)
 	}
 	defer resp.Body.Close()
 
@@ -417,18 +587,22 @@ func (g *GPT4oAdapter) pollStatus(ctx context.Context, predictionID string) (*GP
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	g.logger.Debug("Poll response received",
+	logger.Debug("Poll response received",
 		zap.Int("body_length", len(body)),
 		zap.String("body_preview", string(body)[:min(500, len(body))]),
 	)
 
+	if resp.StatusCode == http.StatusUnauthorized && g.tokens.TokenRejected(ctx, token) {
+		// The key was rotated since this poll was sent; poll again with the new one
+		return g.pollStatus(ctx, predictionID)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newStatusError(ProviderReplicate, resp.StatusCode, body)
 	}
 
 	var gpt4oResp GPT4oResponse
 	if err := json.Unmarshal(body, &gpt4oResp); err != nil {
-		g.logger.Error("Failed to unmarshal poll response",
+		logger.Error("Failed to unmarshal poll response",
 			zap.Error(err),
 			zap.String("body", string(body)[:min(1000, len(body))]),
 		)
@@ -438,15 +612,67 @@ func (g *GPT4oAdapter) pollStatus(ctx context.Context, predictionID string) (*GP
 	return &gpt4oResp, nil
 }
 
+// CancelPrediction stops a running prediction
+func (g *GPT4oAdapter) CancelPrediction(ctx context.Context, predictionID string) error {
+	return cancelReplicatePrediction(ctx, g.httpClient, fmt.Sprintf("https://api.replicate.com/v1/predictions/%s/cancel", predictionID), g.tokens)
+}
+
+// waitForOutput waits for a GPT-4o prediction to succeed with output. Replicate can report
+// succeeded before the streamed output is complete, so that counts as still processing.
+func (g *GPT4oAdapter) waitForOutput(ctx context.Context, predictionID, label string, timeout time.Duration) (*GPT4oResponse, error) {
+	var final *GPT4oResponse
+	err := pollPrediction(ctx, predictionID, PollOptions{
+		Interval: 5 * time.Second,
+		Timeout:  timeout,
+		LogEvery: 6,
+		Label:    label,
+		Logger:   g.logger,
+		Model:    g.ModelVersion(ctx),
+		Webhooks: g.webhooks,
+		Canceler: g,
+	}, func(ctx context.Context) (string, string, error) {
+		r, err := g.pollStatus(ctx, predictionID)
+		if err != nil {
+			return "", "", err
+		}
+		final = r
+		if r.Status == "succeeded" && len(r.Output) == 0 {
+			return string(PredictionProcessing), "", nil
+		}
+		return r.Status, r.Error, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return final, nil
+}
+
+// styleAnalysisPrompt asks a vision model for a style description to append to scene prompts
+const styleAnalysisPrompt = `Analyze this image and describe its visual style in detail for video generation. Focus on:
+
+1. **Color Palette**: Dominant colors, color grading, saturation level
+2. **Lighting**: Lighting style (natural, dramatic, soft, hard), shadows, highlights
+3. **Mood & Atmosphere**: Overall feeling, emotional tone
+4. **Composition**: Framing style, visual balance, focal points
+5. **Texture & Detail**: Surface qualities, level of detail, sharpness
+6. **Cinematography**: Camera feel (static, dynamic), depth of field, perspective
+
+Provide a concise 2-3 sentence description that captures the essence of this visual style, suitable for adding to video generation prompts.`
+
 // AnalyzeStyleReference uses GPT-4o Vision to analyze a reference image and extract style description
 func (g *GPT4oAdapter) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
-	g.logger.Info("Analyzing style reference image with GPT-4o Vision",
+	logger := trace.Logger(ctx, g.logger)
+	logger.Info("Analyzing style reference image with GPT-4o Vision",
 		zap.String("image_url", imageURL[:min(100, len(imageURL))]),
 	)
+	if err := checkTokenBudget(ctx, "style analysis", estimatePromptTokens(1, styleAnalysisPrompt), 500); err != nil {
+		return "", err
+	}
 
 	// Build vision request with image
 	gpt4oReq := GPT4oRequest{
-		Version: g.modelVersion,
+		Version:                useModel(ctx, g.logger, ModelGPT4o, g.modelVersion),
+		ReplicateWebhookFields: g.webhooks.requestFields(),
 		Input: map[string]interface{}{
 			"messages": []map[string]interface{}{
 				{
@@ -454,16 +680,7 @@ func (g *GPT4oAdapter) AnalyzeStyleReference(ctx context.Context, imageURL strin
 					"content": []map[string]interface{}{
 						{
 							"type": "text",
-							"text": `Analyze this image and describe its visual style in detail for video generation. Focus on:
-
-1. **Color Palette**: Dominant colors, color grading, saturation level
-2. **Lighting**: Lighting style (natural, dramatic, soft, hard), shadows, highlights
-3. **Mood & Atmosphere**: Overall feeling, emotional tone
-4. **Composition**: Framing style, visual balance, focal points
-5. **Texture & Detail**: Surface qualities, level of detail, sharpness
-6. **Cinematography**: Camera feel (static, dynamic), depth of field, perspective
-
-Provide a concise 2-3 sentence description that captures the essence of this visual style, suitable for adding to video generation prompts.`,
+							"text": styleAnalysisPrompt,
 						},
 						{
 							"type": "image_url",
@@ -485,6 +702,7 @@ Provide a concise 2-3 sentence description that captures the essence of this vis
 	}
 
 	// Submit prediction to Replicate with retry logic
+	submitted := time.Now()
 	var gpt4oResp GPT4oResponse
 	err = retry.Do(ctx, retry.APIConfig(), func() error {
 		httpReq, err := http.NewRequestWithContext(ctx, "POST",
@@ -494,14 +712,18 @@ Provide a concise 2-3 sentence description that captures the essence of this vis
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", "Bearer "+g.apiToken)
+		token, err := authorize(ctx, httpReq, g.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 		// Don't use Prefer: wait to avoid 60-second timeout - we'll poll instead
 
 		resp, err := g.httpClient.Do(httpReq)
 		if err != nil {
 			// Network errors are retryable
-			return fmt.Errorf("request failed: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -511,15 +733,7 @@ Provide a concise 2-3 sentence description that captures the essence of this vis
 		}
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
-			// 4xx errors are non-retryable
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				if resp.StatusCode == 402 { // Payment Required
-					return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): Payment required - Replicate account has insufficient credits or billing issue. Please check your Replicate account balance. Response: %s", resp.StatusCode, string(body)))
-				}
-				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
-			}
-			// 5xx errors are retryable
-			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+			return replicateStatusError(ctx, g.tokens, token, resp.StatusCode, body)
 		}
 
 		if err := json.Unmarshal(body, &gpt4oResp); err != nil {
@@ -535,70 +749,33 @@ Provide a concise 2-3 sentence description that captures the essence of this vis
 
 	// If output not ready, poll for completion
 	if gpt4oResp.Status != "succeeded" {
-		g.logger.Info("Waiting for GPT-4o Vision analysis",
+		logger.Info("Waiting for GPT-4o Vision analysis",
 			zap.String("prediction_id", gpt4oResp.ID),
 		)
 
-		// Poll for completion (max 2 minutes for vision analysis)
-		maxAttempts := 24 // 24 * 5s = 2 minutes
-		pollInterval := 5 * time.Second
-
-		for attempt := 0; attempt < maxAttempts; attempt++ {
-			select {
-			case <-ctx.Done():
-				return "", fmt.Errorf("context cancelled: %w", ctx.Err())
-			default:
-			}
-
-			time.Sleep(pollInterval)
-
-			pollResp, err := g.pollStatus(ctx, gpt4oResp.ID)
-			if err != nil {
-				g.logger.Warn("Failed to poll vision status, retrying",
-					zap.Error(err),
-					zap.Int("attempt", attempt+1),
-					zap.Int("max_attempts", maxAttempts),
-				)
-				continue
-			}
-
-			g.logger.Info("Vision poll status check",
-				zap.String("status", pollResp.Status),
-				zap.Int("output_length", len(pollResp.Output)),
-				zap.Int("attempt", attempt+1),
-			)
-
-			if pollResp.Status == "succeeded" && len(pollResp.Output) > 0 {
-				gpt4oResp = *pollResp
-				break
-			}
-
-			if pollResp.Status == "failed" || pollResp.Status == "canceled" {
-				return "", fmt.Errorf("Vision analysis failed: %s", pollResp.Error)
-			}
-
-			// Update response for next iteration
-			gpt4oResp = *pollResp
+		final, err := g.waitForOutput(ctx, gpt4oResp.ID, "GPT-4o Vision", 2*time.Minute)
+		if err != nil {
+			return "", fmt.Errorf("Vision analysis failed: %w", err)
 		}
+		gpt4oResp = *final
+	} else {
+		// Finished within the submission request, so no poll recorded it
+		recordProviderCall(ctx, replicateProvider, g.ModelVersion(ctx), gpt4oResp.ID, submitted, nil)
 	}
+	g.recordUsage(ctx, "style analysis", &gpt4oResp)
 
 	// Extract style description from output
 	if len(gpt4oResp.Output) == 0 {
 		return "", fmt.Errorf("no output from GPT-4o Vision after polling (final status: %s)", gpt4oResp.Status)
 	}
 
-	// Check if we timed out without succeeding
-	if gpt4oResp.Status != "succeeded" {
-		return "", fmt.Errorf("GPT-4o Vision analysis did not complete in time (status: %s)", gpt4oResp.Status)
-	}
-
 	// Concatenate all output chunks (GPT-4o streams response)
 	var styleDescription string
 	for _, chunk := range gpt4oResp.Output {
 		styleDescription += chunk
 	}
 
-	g.logger.Info("Style analysis complete",
+	logger.Info("Style analysis complete",
 		zap.String("style_description", styleDescription[:min(200, len(styleDescription))]),
 	)
 
@@ -608,13 +785,18 @@ Provide a concise 2-3 sentence description that captures the essence of this vis
 // GenerateText generates simple text completion via Replicate GPT-4o.
 // Used for disclaimer shortening, narration expansion, etc.
 func (g *GPT4oAdapter) GenerateText(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
-	g.logger.Info("Generating text with GPT-4o",
+	logger := trace.Logger(ctx, g.logger)
+	logger.Info("Generating text with GPT-4o",
 		zap.String("user_prompt", userPrompt[:min(100, len(userPrompt))]),
 	)
+	if err := checkTokenBudget(ctx, "text", estimatePromptTokens(0, systemPrompt, userPrompt), 1000); err != nil {
+		return "", err
+	}
 
 	// Build Replicate API request (same pattern as AnalyzeStyleReference)
 	gpt4oReq := GPT4oRequest{
-		Version: g.modelVersion,
+		Version:                useModel(ctx, g.logger, ModelGPT4o, g.modelVersion),
+		ReplicateWebhookFields: g.webhooks.requestFields(),
 		Input: map[string]interface{}{
 			"messages": []map[string]string{
 				{
@@ -637,6 +819,7 @@ func (g *GPT4oAdapter) GenerateText(ctx context.Context, systemPrompt, userPromp
 	}
 
 	// Submit prediction to Replicate with retry logic
+	submitted := time.Now()
 	var gpt4oResp GPT4oResponse
 	err = retry.Do(ctx, retry.APIConfig(), func() error {
 		httpReq, err := http.NewRequestWithContext(ctx, "POST",
@@ -646,12 +829,16 @@ func (g *GPT4oAdapter) GenerateText(ctx context.Context, systemPrompt, userPromp
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", "Bearer "+g.apiToken)
+		token, err := authorize(ctx, httpReq, g.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 
 		resp, err := g.httpClient.Do(httpReq)
 		if err != nil {
-			return fmt.Errorf("request failed: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -661,10 +848,7 @@ func (g *GPT4oAdapter) GenerateText(ctx context.Context, systemPrompt, userPromp
 		}
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
-			}
-			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+			return replicateStatusError(ctx, g.tokens, token, resp.StatusCode, body)
 		}
 
 		if err := json.Unmarshal(body, &gpt4oResp); err != nil {
@@ -678,37 +862,18 @@ func (g *GPT4oAdapter) GenerateText(ctx context.Context, systemPrompt, userPromp
 		return "", err
 	}
 
-	// Poll for completion if not ready (max 1 minute for text generation)
+	// Wait for completion if not ready (max 1 minute for text generation)
 	if gpt4oResp.Status != "succeeded" {
-		maxAttempts := 12 // 12 * 5s = 1 minute
-		pollInterval := 5 * time.Second
-
-		for attempt := 0; attempt < maxAttempts; attempt++ {
-			select {
-			case <-ctx.Done():
-				return "", fmt.Errorf("context cancelled: %w", ctx.Err())
-			default:
-			}
-
-			time.Sleep(pollInterval)
-
-			pollResp, err := g.pollStatus(ctx, gpt4oResp.ID)
-			if err != nil {
-				continue
-			}
-
-			if pollResp.Status == "succeeded" && len(pollResp.Output) > 0 {
-				gpt4oResp = *pollResp
-				break
-			}
-
-			if pollResp.Status == "failed" || pollResp.Status == "canceled" {
-				return "", fmt.Errorf("text generation failed: %s", pollResp.Error)
-			}
-
-			gpt4oResp = *pollResp
+		final, err := g.waitForOutput(ctx, gpt4oResp.ID, "GPT-4o text", time.Minute)
+		if err != nil {
+			return "", fmt.Errorf("text generation failed: %w", err)
 		}
+		gpt4oResp = *final
+	} else {
+		// Finished within the submission request, so no poll recorded it
+		recordProviderCall(ctx, replicateProvider, g.ModelVersion(ctx), gpt4oResp.ID, submitted, nil)
 	}
+	g.recordUsage(ctx, "text", &gpt4oResp)
 
 	if len(gpt4oResp.Output) == 0 {
 		return "", fmt.Errorf("no output from GPT-4o")
@@ -739,6 +904,11 @@ func buildUserPrompt(req *ScriptGenerationRequest) string {
 		prompt += "\n**Starting Image:** A starting image will be provided for the first scene (leave start_image_url empty in JSON)"
 	}
 
+	if prompts.BuildLanguageSection(req.Language) != "" {
+		prompt += fmt.Sprintf("\n**Language:** %s (%s) for the title, narration, voiceovers and call to action; scene descriptions stay in English",
+			req.Language.Name, req.Language.Tag)
+	}
+
 	// Add pharmaceutical ad instructions if Voice and SideEffects are provided
 	// Two-pass system: First pass generates scenes only, second pass generates narration with exact timing
 	isPharmaceuticalAd := req.Voice != "" && req.SideEffects != ""
@@ -771,23 +941,34 @@ This is a pharmaceutical advertisement.
 - Leave start_image_url empty (will be populated server-side)
 - Return ONLY valid JSON matching the Script schema, no markdown or explanations`
 
+	if req.Variants > 1 {
+		prompt += fmt.Sprintf("\n- Write %d distinct scripts and wrap them in the A/B variants JSON described in the system prompt", req.Variants)
+	}
+
 	return prompt
 }
 
 // parseScriptJSON parses the GPT-4o JSON output into a Script struct
-func (g *GPT4oAdapter) parseScriptJSON(scriptJSON string, styleDescription string) (*domain.Script, error) {
-	// Try to extract JSON if wrapped in markdown code blocks
-	cleaned := extractJSON(scriptJSON)
+func parseScriptJSON(logger *zap.Logger, scriptJSON string, styleDescription string) (*domain.Script, error) {
+	// Extract the script object from surrounding prose or markdown fences
+	cleaned, err := ExtractJSON(scriptJSON)
+	if err != nil {
+		return nil, err
+	}
 
 	var script domain.Script
 	if err := json.Unmarshal([]byte(cleaned), &script); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal script: %w (JSON: %s)", err, cleaned[:min(200, len(cleaned))])
 	}
 
+	for i := range script.Scenes {
+		script.Scenes[i].NegativePrompt = cleanNegativePrompt(logger, script.Scenes[i])
+	}
+
 	// Add style description to script and append to each scene's generation_prompt
 	if styleDescription != "" {
 		script.StyleDescription = styleDescription
-		g.logger.Info("Appending style description to scene prompts",
+		logger.Info("Appending style description to scene prompts",
 			zap.String("style_description", styleDescription[:min(150, len(styleDescription))]),
 			zap.Int("num_scenes", len(script.Scenes)),
 		)
@@ -801,31 +982,77 @@ func (g *GPT4oAdapter) parseScriptJSON(scriptJSON string, styleDescription strin
 	return &script, nil
 }
 
-// extractJSON extracts JSON from markdown code blocks if present
-func extractJSON(s string) string {
-	// Remove markdown code blocks if present
-	if len(s) > 7 && s[:3] == "```" {
-		// Find first newline after ```
-		start := 3
-		for start < len(s) && s[start] != '\n' {
-			start++
-		}
-		start++
+// cleanNegativePrompt trims a scene's negative prompt, dropping one too long for the video
+// models rather than failing an otherwise usable script
+func cleanNegativePrompt(logger *zap.Logger, scene domain.Scene) string {
+	negative := strings.TrimSpace(scene.NegativePrompt)
+	if len(negative) > MaxNegativePromptLength {
+		logger.Warn("Dropping over-long negative prompt",
+			zap.Int("scene", scene.SceneNumber),
+			zap.Int("length", len(negative)),
+		)
+		return ""
+	}
+	return negative
+}
 
-		// Find closing ```
-		end := len(s)
-		if idx := bytes.Index([]byte(s[start:]), []byte("```")); idx != -1 {
-			end = start + idx
+// parseScriptVariantsJSON parses a {"variants": [...]} response into count scripts. Extra
+// scripts are dropped; too few is an error, since every requested variant was paid for.
+func parseScriptVariantsJSON(logger *zap.Logger, output string, styleDescription string, count int) ([]*domain.Script, error) {
+	cleaned, err := ExtractJSON(output)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapper struct {
+		Variants []json.RawMessage `json:"variants"`
+	}
+	if err := json.Unmarshal([]byte(cleaned), &wrapper); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal variants: %w (JSON: %s)", err, cleaned[:min(200, len(cleaned))])
+	}
+	if len(wrapper.Variants) < count {
+		return nil, fmt.Errorf("expected %d script variants, got %d", count, len(wrapper.Variants))
+	}
+
+	scripts := make([]*domain.Script, count)
+	for i, raw := range wrapper.Variants[:count] {
+		script, err := parseScriptJSON(logger, string(raw), styleDescription)
+		if err != nil {
+			return nil, variantError(count, i, err)
 		}
+		scripts[i] = script
+	}
+	return scripts, nil
+}
+
+// MaxNegativePromptLength is the longest negative prompt sent to a video model
+const MaxNegativePromptLength = 500
+
+// ValidateGenerationPrompt rejects empty, truncated or placeholder scene prompts.
+// Used for GPT-4o output and for user-edited prompts.
+func ValidateGenerationPrompt(prompt string) error {
+	if prompt == "" {
+		return fmt.Errorf("has empty generation_prompt")
+	}
+
+	// Check for minimum prompt length (catch truncated/garbage responses)
+	if len(prompt) < 50 {
+		return fmt.Errorf("has suspiciously short generation_prompt (%d chars)", len(prompt))
+	}
 
-		return s[start:end]
+	// Check for placeholder text that GPT-4 sometimes outputs
+	lowered := strings.ToLower(prompt)
+	if strings.Contains(lowered, "[insert") || strings.Contains(lowered, "[placeholder") || strings.Contains(lowered, "[tbd") {
+		return fmt.Errorf("contains placeholder text in generation_prompt")
 	}
 
-	return s
+	return nil
 }
 
-// validateScript ensures the generated script meets requirements
-func validateScript(script *domain.Script, requestedDuration int, isPharmaceutical bool) error {
+// ValidateScript ensures a generated script meets requirements
+// When videoModel is set, every scene must use a clip length the model supports. With a scene
+// plan, the scenes must match it exactly (see checkScenePlan).
+func ValidateScript(script *domain.Script, requestedDuration int, isPharmaceutical bool, videoModel AdapterType, plan []prompts.PlannedScene) error {
 	if script.Title == "" {
 		return fmt.Errorf("script title is empty")
 	}
@@ -834,6 +1061,11 @@ func validateScript(script *domain.Script, requestedDuration int, isPharmaceutic
 		return fmt.Errorf("script has no scenes")
 	}
 
+	// A planned script is checked scene by scene first, so a mismatch names the scene
+	if err := checkScenePlan(script, plan); err != nil {
+		return err
+	}
+
 	// Validate total duration matches request (allow 10% variance)
 	if script.TotalDuration < requestedDuration-5 || script.TotalDuration > requestedDuration+5 {
 		return fmt.Errorf("total duration %d doesn't match requested %d", script.TotalDuration, requestedDuration)
@@ -846,19 +1078,13 @@ func validateScript(script *domain.Script, requestedDuration int, isPharmaceutic
 			return fmt.Errorf("scene %d has incorrect scene_number %d", i+1, scene.SceneNumber)
 		}
 
-		if scene.GenerationPrompt == "" {
-			return fmt.Errorf("scene %d has empty generation_prompt", i+1)
+		if err := ValidateGenerationPrompt(scene.GenerationPrompt); err != nil {
+			return fmt.Errorf("scene %d %w", i+1, err)
 		}
 
-		// Check for minimum prompt length (catch truncated/garbage responses)
-		if len(scene.GenerationPrompt) < 50 {
-			return fmt.Errorf("scene %d has suspiciously short generation_prompt (%d chars)", i+1, len(scene.GenerationPrompt))
-		}
-
-		// Check for placeholder text that GPT-4 sometimes outputs
-		lowered := strings.ToLower(scene.GenerationPrompt)
-		if strings.Contains(lowered, "[insert") || strings.Contains(lowered, "[placeholder") || strings.Contains(lowered, "[tbd") {
-			return fmt.Errorf("scene %d contains placeholder text in generation_prompt", i+1)
+		if videoModel != "" && !IsValidClipDuration(videoModel, scene.Duration) {
+			return fmt.Errorf("scene %d has duration %.1fs, %s supports only %v second clips",
+				i+1, scene.Duration, videoModel, ClipDurations(videoModel))
 		}
 
 		totalDuration += scene.Duration
@@ -885,7 +1111,7 @@ func validateScript(script *domain.Script, requestedDuration int, isPharmaceutic
 	return nil
 }
 
-// GenerateNarrationForScenes generates narration given scene timings and a word budget.
+// GenerateNarrationForScenes generates narration in language given scene timings and a word budget.
 func (g *GPT4oAdapter) GenerateNarrationForScenes(
 	ctx context.Context,
 	scenes []domain.Scene,
@@ -894,8 +1120,10 @@ func (g *GPT4oAdapter) GenerateNarrationForScenes(
 	targetWords int,
 	productName string,
 	productDescription string,
+	language prompts.Language,
 ) (string, error) {
-	g.logger.Info("Generating narration for scenes",
+	logger := trace.Logger(ctx, g.logger)
+	logger.Info("Generating narration for scenes",
 		zap.Int("num_scenes", len(scenes)),
 		zap.Float64("total_duration", totalDuration),
 		zap.Float64("disclaimer_duration", disclaimerDuration),
@@ -938,7 +1166,7 @@ Write ONE continuous narration script that flows naturally across all scenes.
 - End the main narration by %.1fs so there is a clean beat before the disclaimer
 - Tone: warm, trusting, empowering, never pushy or sales-y
 - Do NOT mention scene numbers or transitions
-- Do NOT include the side effects disclaimer (that will be added separately)
+- Do NOT include the side effects disclaimer (that will be added separately)%s
 
 **Output Format**:
 Write only the narration text, then on a new line write the word count in this format:
@@ -951,6 +1179,7 @@ Write only the narration text, then on a new line write the word count in this f
 		wordMin, wordMax, targetWords,
 		sceneDesc.String(),
 		narrationEndTime,
+		narrationLanguageInstruction(language),
 	)
 
 	systemPrompt := "You are an expert pharmaceutical advertising copywriter. You write clear, compliant, emotionally resonant narration that matches video timing precisely."
@@ -958,14 +1187,24 @@ Write only the narration text, then on a new line write the word count in this f
 	return g.GenerateText(ctx, systemPrompt, prompt)
 }
 
-// ExpandNarration expands a short narration to target word count.
+// narrationLanguageInstruction is the instruction line writing narration in language, or "" for English
+func narrationLanguageInstruction(language prompts.Language) string {
+	if prompts.BuildLanguageSection(language) == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n- Write it in %s (%s), as a native speaker would; every word count above is in %s words", language.Name, language.Tag, language.Name)
+}
+
+// ExpandNarration expands a short narration to target word count, keeping it in language.
 func (g *GPT4oAdapter) ExpandNarration(
 	ctx context.Context,
 	currentNarration string,
 	currentWords int,
 	targetWords int,
+	language prompts.Language,
 ) (string, error) {
-	g.logger.Info("Expanding narration",
+	logger := trace.Logger(ctx, g.logger)
+	logger.Info("Expanding narration",
 		zap.Int("current_words", currentWords),
 		zap.Int("target_words", targetWords),
 	)
@@ -982,7 +1221,7 @@ Expand this script to %d-%d words while:
 - Keeping the EXACT ending line unchanged
 - Adding more emotional benefit language
 - Adding patient relatability
-- Subtly reinforcing the mechanism of action
+- Subtly reinforcing the mechanism of action%s
 
 Output only the expanded narration text, then the word count:
 <word_count>NUMBER</word_count>`,
@@ -990,6 +1229,7 @@ Output only the expanded narration text, then the word count:
 		int(float64(targetWords)*0.95), int(float64(targetWords)*1.05),
 		currentNarration,
 		int(float64(targetWords)*0.95), int(float64(targetWords)*1.05),
+		narrationLanguageInstruction(language),
 	)
 
 	return g.GenerateText(ctx, systemPrompt, userPrompt)
@@ -52,6 +52,7 @@ func TestValidateScript(t *testing.T) {
 		script           *domain.Script
 		requestedDur     int
 		isPharmaceutical bool
+		videoModel       AdapterType
 		wantErr          bool
 		errContains      string
 	}{
@@ -232,18 +233,59 @@ func TestValidateScript(t *testing.T) {
 			isPharmaceutical: false,
 			wantErr:          false,
 		},
+		{
+			name:         "kling accepts 10 second scenes",
+			script:       validScript(),
+			requestedDur: 30,
+			videoModel:   AdapterTypeKling,
+			wantErr:      false,
+		},
+		{
+			name:         "veo rejects 10 second scenes",
+			script:       validScript(),
+			requestedDur: 30,
+			videoModel:   AdapterTypeVeo,
+			wantErr:      true,
+			errContains:  "veo supports only [4 6 8] second clips",
+		},
+		{
+			name: "veo accepts 4, 6 and 8 second scenes",
+			script: func() *domain.Script {
+				s := validScript()
+				s.TotalDuration = 18
+				s.Scenes[0].Duration = 4
+				s.Scenes[1].Duration = 6
+				s.Scenes[2].Duration = 8
+				return s
+			}(),
+			requestedDur: 18,
+			videoModel:   AdapterTypeVeo,
+			wantErr:      false,
+		},
+		{
+			name: "kling rejects 6 second scenes",
+			script: func() *domain.Script {
+				s := validScript()
+				s.Scenes[1].Duration = 6
+				return s
+			}(),
+			requestedDur: 30,
+			videoModel:   AdapterTypeKling,
+			wantErr:      true,
+			errContains:  "scene 2 has duration 6.0s",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateScript(tt.script, tt.requestedDur, tt.isPharmaceutical)
+			err := ValidateScript(tt.script, tt.requestedDur, tt.isPharmaceutical, tt.videoModel, nil)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("validateScript() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("ValidateScript() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if tt.wantErr && tt.errContains != "" {
 				if err == nil || !contains(err.Error(), tt.errContains) {
-					t.Errorf("validateScript() error = %v, want error containing %q", err, tt.errContains)
+					t.Errorf("ValidateScript() error = %v, want error containing %q", err, tt.errContains)
 				}
 			}
 		})
@@ -369,9 +411,11 @@ func TestParseNarrationResponse_WordCountAccuracy(t *testing.T) {
 
 func TestExtractJSON(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    string
-		expected string
+		name        string
+		input       string
+		expected    string
+		wantErr     bool
+		errContains string
 	}{
 		{
 			name:     "plain JSON",
@@ -393,22 +437,70 @@ func TestExtractJSON(t *testing.T) {
 			input:    "```json\n{\"title\": \"Test\"}",
 			expected: `{"title": "Test"}`,
 		},
+		{
+			name:     "prose before JSON",
+			input:    "Here is the script:\n{\"title\": \"Test\"}",
+			expected: `{"title": "Test"}`,
+		},
+		{
+			name:     "prose before fenced JSON",
+			input:    "Sure! Here is your ad script.\n\n```json\n{\"title\": \"Test\"}\n```",
+			expected: `{"title": "Test"}`,
+		},
+		{
+			name:     "fence inside string value",
+			input:    "```json\n{\"title\": \"Use ```code``` here\", \"scenes\": []}\n```",
+			expected: `{"title": "Use ` + "```code```" + ` here", "scenes": []}`,
+		},
+		{
+			name:     "braces and escaped quotes inside strings",
+			input:    `{"title": "A \"quoted\" {brace} title", "n": {"x": 1}}`,
+			expected: `{"title": "A \"quoted\" {brace} title", "n": {"x": 1}}`,
+		},
+		{
+			name:     "trailing commentary",
+			input:    "{\"title\": \"Test\"}\n\nLet me know if you want changes to {anything}.",
+			expected: `{"title": "Test"}`,
+		},
+		{
+			name:     "stray brace in prose before JSON",
+			input:    "Template {product_name was filled in:\n{\"title\": \"Test\"}",
+			expected: `{"title": "Test"}`,
+		},
+		{
+			name:        "truncated JSON",
+			input:       `{"title": "Test", "scenes": [{"scene_number": 1`,
+			wantErr:     true,
+			errContains: "may be truncated",
+		},
+		{
+			name:        "no JSON at all",
+			input:       "I'm sorry, I can't help with that.",
+			wantErr:     true,
+			errContains: "no JSON object found",
+		},
+		{
+			name:        "empty output",
+			input:       "   ",
+			wantErr:     true,
+			errContains: "output is empty",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractJSON(tt.input)
-			// Trim whitespace for comparison since extraction may include newlines
-			resultTrimmed := result
-			expectedTrimmed := tt.expected
-			for len(resultTrimmed) > 0 && (resultTrimmed[0] == '\n' || resultTrimmed[0] == ' ') {
-				resultTrimmed = resultTrimmed[1:]
+			result, err := ExtractJSON(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractJSON() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			for len(resultTrimmed) > 0 && (resultTrimmed[len(resultTrimmed)-1] == '\n' || resultTrimmed[len(resultTrimmed)-1] == ' ') {
-				resultTrimmed = resultTrimmed[:len(resultTrimmed)-1]
+			if tt.wantErr {
+				if !contains(err.Error(), tt.errContains) {
+					t.Errorf("ExtractJSON() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
 			}
-			if resultTrimmed != expectedTrimmed {
-				t.Errorf("extractJSON() = %q, want %q", resultTrimmed, expectedTrimmed)
+			if result != tt.expected {
+				t.Errorf("ExtractJSON() = %q, want %q", result, tt.expected)
 			}
 		})
 	}
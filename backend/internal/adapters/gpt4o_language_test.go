@@ -0,0 +1,92 @@
+package adapters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+	"go.uber.org/zap"
+)
+
+func TestBuildScriptPrompt_Language(t *testing.T) {
+	sideEffects := "May cause drowsiness, nausea or dizziness. Do not take if pregnant."
+	tests := []struct {
+		name       string
+		language   prompts.Language
+		wantSystem []string
+		wantUser   []string
+		unwanted   []string
+	}{
+		{
+			name:     "english",
+			language: prompts.Language{Name: "English", WordsPerSecond: 2.5},
+			unwanted: []string{"## LANGUAGE", "**Language:**"},
+		},
+		{
+			name:     "spanish",
+			language: prompts.Language{Tag: "es-MX", Name: "Spanish", WordsPerSecond: 2.2},
+			wantSystem: []string{
+				"## LANGUAGE: Spanish (es-MX)",
+				"about 2.2 words per second",
+				"side_effects_text is the exception",
+			},
+			wantUser: []string{"**Language:** Spanish (es-MX) for the title, narration, voiceovers and call to action"},
+		},
+		{
+			name:       "german",
+			language:   prompts.Language{Tag: "de-DE", Name: "German", WordsPerSecond: 2.0},
+			wantSystem: []string{"## LANGUAGE: German (de-DE)", "about 2.0 words per second"},
+			wantUser:   []string{"**Language:** German (de-DE)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := buildScriptPrompt(zap.NewNop(), &ScriptGenerationRequest{
+				Prompt:      "An allergy relief ad for busy parents",
+				Duration:    30,
+				AspectRatio: "16:9",
+				Voice:       "female",
+				SideEffects: sideEffects,
+				Language:    tt.language,
+			})
+
+			for _, want := range tt.wantSystem {
+				if !strings.Contains(p.System, want) {
+					t.Errorf("system prompt should contain %q", want)
+				}
+			}
+			for _, want := range tt.wantUser {
+				if !strings.Contains(p.User, want) {
+					t.Errorf("user prompt should contain %q, got:\n%s", want, p.User)
+				}
+			}
+			for _, text := range tt.unwanted {
+				if strings.Contains(p.System, text) || strings.Contains(p.User, text) {
+					t.Errorf("prompts should not contain %q", text)
+				}
+			}
+
+			// The disclosure is passed on exactly as the user wrote it, whatever the language
+			if !strings.Contains(p.User, "**SIDE EFFECTS TEXT (STORE VERBATIM - DO NOT MODIFY):**\n"+sideEffects) {
+				t.Errorf("user prompt should carry the side effects verbatim, got:\n%s", p.User)
+			}
+
+			// The language's pacing comes after, and so replaces, the pharmaceutical guidance's English rate
+			if section := strings.Index(p.System, "## LANGUAGE"); section >= 0 &&
+				section < strings.Index(p.System, prompts.PharmaceuticalAdGuidance) {
+				t.Error("language section should follow the pharmaceutical guidance")
+			}
+		})
+	}
+}
+
+func TestNarrationLanguageInstruction(t *testing.T) {
+	if got := narrationLanguageInstruction(prompts.Language{Name: "English"}); got != "" {
+		t.Errorf("English narration instruction = %q, want none", got)
+	}
+	got := narrationLanguageInstruction(prompts.Language{Tag: "es", Name: "Spanish", WordsPerSecond: 2.2})
+	if !strings.Contains(got, "Write it in Spanish (es)") || !strings.Contains(got, "in Spanish words") {
+		t.Errorf("Spanish narration instruction = %q", got)
+	}
+}
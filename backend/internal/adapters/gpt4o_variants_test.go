@@ -0,0 +1,75 @@
+package adapters
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const variantsOutput = "Here are your variants:\n```json\n" + `{"variants": [
+	{"title": "Morning Ritual", "total_duration": 16, "scenes": [{"scene_number": 1, "duration": 8, "generation_prompt": "Sunrise over a kitchen counter"}]},
+	{"title": "Night Owl", "total_duration": 16, "scenes": [{"scene_number": 1, "duration": 8, "generation_prompt": "Neon-lit desk at midnight"}]},
+	{"title": "Spare", "total_duration": 16, "scenes": [{"scene_number": 1, "duration": 8, "generation_prompt": "Unused"}]}
+]}` + "\n```"
+
+func TestParseScriptVariantsJSON(t *testing.T) {
+	scripts, err := parseScriptVariantsJSON(zap.NewNop(), variantsOutput, "soft film grain", 2)
+	if err != nil {
+		t.Fatalf("parseScriptVariantsJSON() error = %v", err)
+	}
+	if len(scripts) != 2 {
+		t.Fatalf("got %d scripts, want the 2 requested", len(scripts))
+	}
+	if scripts[0].Title != "Morning Ritual" || scripts[1].Title != "Night Owl" {
+		t.Errorf("titles = %q, %q; want the variants in order", scripts[0].Title, scripts[1].Title)
+	}
+	for i, script := range scripts {
+		if !strings.HasSuffix(script.Scenes[0].GenerationPrompt, ". Style: soft film grain") {
+			t.Errorf("variant %d prompt = %q, want the style description appended", i+1, script.Scenes[0].GenerationPrompt)
+		}
+	}
+}
+
+func TestParseScriptJSON_NegativePrompts(t *testing.T) {
+	output := `{"title": "Hands", "total_duration": 16, "scenes": [
+		{"scene_number": 1, "duration": 8, "generation_prompt": "Close-up of hands", "negative_prompt": "  extra fingers, distorted hands  "},
+		{"scene_number": 2, "duration": 8, "generation_prompt": "Label close-up", "negative_prompt": "` + strings.Repeat("x", MaxNegativePromptLength+1) + `"}
+	]}`
+
+	script, err := parseScriptJSON(zap.NewNop(), output, "")
+	if err != nil {
+		t.Fatalf("parseScriptJSON() error = %v", err)
+	}
+	if got := script.Scenes[0].NegativePrompt; got != "extra fingers, distorted hands" {
+		t.Errorf("scene 1 negative prompt = %q, want it trimmed", got)
+	}
+	if got := script.Scenes[1].NegativePrompt; got != "" {
+		t.Errorf("scene 2 negative prompt has %d characters, want an over-long one dropped", len(got))
+	}
+}
+
+func TestParseScriptVariantsJSON_TooFew(t *testing.T) {
+	_, err := parseScriptVariantsJSON(zap.NewNop(), variantsOutput, "", 4)
+	if err == nil || !strings.Contains(err.Error(), "expected 4 script variants, got 3") {
+		t.Errorf("parseScriptVariantsJSON() error = %v, want a missing variants error", err)
+	}
+
+	// A single script isn't a variants response
+	_, err = parseScriptVariantsJSON(zap.NewNop(), `{"title": "Solo", "scenes": []}`, "", 2)
+	if err == nil {
+		t.Error("parseScriptVariantsJSON(single script) expected error, got nil")
+	}
+}
+
+func TestBuildUserPrompt_Variants(t *testing.T) {
+	req := &ScriptGenerationRequest{Prompt: "A running shoe", Duration: 30, AspectRatio: "16:9"}
+	if strings.Contains(buildUserPrompt(req), "A/B variants") {
+		t.Error("single script prompt asks for variants")
+	}
+
+	req.Variants = 3
+	if prompt := buildUserPrompt(req); !strings.Contains(prompt, "Write 3 distinct scripts") {
+		t.Errorf("variants prompt should ask for 3 scripts, got:\n%s", prompt)
+	}
+}
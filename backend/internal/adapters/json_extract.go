@@ -0,0 +1,104 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExtractJSON returns the first complete top-level JSON object in LLM output.
+// It tolerates leading prose, markdown fences (including fences inside string
+// values) and trailing commentary. Braces inside JSON strings are ignored.
+func ExtractJSON(s string) (string, error) {
+	for start := strings.IndexByte(s, '{'); start != -1; {
+		if end, ok := findObjectEnd(s, start); ok {
+			candidate := s[start : end+1]
+			if json.Valid([]byte(candidate)) {
+				return candidate, nil
+			}
+		}
+
+		next := strings.IndexByte(s[start+1:], '{')
+		if next == -1 {
+			break
+		}
+		start += next + 1
+	}
+
+	// Fall back to stripping a markdown fence around the whole payload
+	if fenced := stripCodeFence(s); fenced != s && json.Valid([]byte(fenced)) {
+		return fenced, nil
+	}
+
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return "", fmt.Errorf("no JSON object found: output is empty")
+	}
+	if strings.IndexByte(trimmed, '{') == -1 {
+		return "", fmt.Errorf("no JSON object found in output (preview: %q)", previewText(trimmed))
+	}
+	return "", fmt.Errorf("no complete JSON object found, output may be truncated (length %d, preview: %q)",
+		len(trimmed), previewText(trimmed))
+}
+
+// findObjectEnd returns the index of the brace closing the object opened at start
+func findObjectEnd(s string, start int) (int, bool) {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i, c == '}'
+			}
+		}
+	}
+	return 0, false
+}
+
+// stripCodeFence removes a leading ```lang line and the matching closing fence
+func stripCodeFence(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "```") {
+		return s
+	}
+
+	start := strings.IndexByte(trimmed, '\n')
+	if start == -1 {
+		return s
+	}
+	body := trimmed[start+1:]
+	if end := strings.LastIndex(body, "```"); end != -1 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
+
+// previewText shortens output for inclusion in error messages
+func previewText(s string) string {
+	const maxPreview = 200
+	if len(s) <= maxPreview {
+		return s
+	}
+	return s[:maxPreview] + "..."
+}
@@ -0,0 +1,288 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// KlingAdapter implements VideoGeneratorAdapter for Kling V2.5 Turbo Pro
+type KlingAdapter struct {
+	tokens     TokenSource
+	httpClient *http.Client
+	logger     *zap.Logger
+	model      string
+	webhooks   *ReplicateWebhooks
+}
+
+// NewKlingAdapter creates a new Kling adapter; an empty model uses DefaultKlingModel
+func NewKlingAdapter(apiToken, model string, logger *zap.Logger) *KlingAdapter {
+	if model == "" {
+		model = DefaultKlingModel
+	}
+	return &KlingAdapter{
+		tokens: StaticToken(apiToken),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second, // Async operation - just for initial request acknowledgment
+		},
+		logger: logger,
+		// Official model on Replicate - uses the model predictions endpoint,
+		// which always runs the latest version (no version hash required)
+		model: model,
+	}
+}
+
+// SetReplicateWebhooks makes new predictions report completion by webhook; nil polls only
+func (k *KlingAdapter) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	k.webhooks = webhooks
+}
+
+// SetTokenSource makes requests use the token tokens supplies at the time they're sent,
+// instead of the one passed to NewKlingAdapter
+func (k *KlingAdapter) SetTokenSource(tokens TokenSource) {
+	k.tokens = tokens
+}
+
+// ReplicateWebhooks returns the webhooks new predictions report to
+func (k *KlingAdapter) ReplicateWebhooks() *ReplicateWebhooks {
+	return k.webhooks
+}
+
+// ModelVersion returns the model predictions on ctx run; Replicate picks its latest version
+func (k *KlingAdapter) ModelVersion(ctx context.Context) string {
+	return modelFor(ctx, ModelKling, k.model)
+}
+
+// klingRequest matches the Replicate model predictions API schema
+type klingRequest struct {
+	Input map[string]interface{} `json:"input"`
+	ReplicateWebhookFields
+}
+
+// GenerateVideo submits a video generation request to Kling
+func (k *KlingAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequest) (*VideoGenerationResult, error) {
+	logger := trace.Logger(ctx, k.logger)
+	logger.Info("Generating video with Kling",
+		zap.String("prompt", req.Prompt),
+		zap.Int("duration", req.Duration),
+		zap.String("aspect_ratio", req.AspectRatio),
+	)
+
+	fullPrompt := req.Prompt
+	if req.Style != "" {
+		fullPrompt = fmt.Sprintf("%s. Style: %s", req.Prompt, req.Style)
+	}
+
+	jsonData, err := json.Marshal(klingRequest{Input: k.buildInput(req, fullPrompt), ReplicateWebhookFields: k.webhooks.requestFields()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	model := useModel(ctx, logger, ModelKling, k.model)
+	url := fmt.Sprintf("https://api.replicate.com/v1/models/%s/predictions", model)
+
+	var klingResp VeoResponse
+	err = retry.Do(ctx, retry.APIConfig(), func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		token, err := authorize(ctx, httpReq, k.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Prefer", "wait=0") // Don't wait for completion
+
+		resp, err := k.httpClient.Do(httpReq)
+		if err != nil {
+			// Network errors are retryable
+			return requestError(ProviderReplicate, err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			logger.Error("Kling API error",
+				zap.Int("status_code", resp.StatusCode),
+				zap.String("response_body", string(body)),
+				zap.String("model", model),
+			)
+			return replicateStatusError(ctx, k.tokens, token, resp.StatusCode, body)
+		}
+
+		if err := json.Unmarshal(body, &klingResp); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Kling prediction created successfully",
+		zap.String("prediction_id", klingResp.ID),
+		zap.String("status", klingResp.Status),
+	)
+
+	return k.toResult(&klingResp), nil
+}
+
+// buildInput constructs the Kling prediction input for req with its final prompt.
+// Kling schema: prompt, negative_prompt, aspect_ratio, duration (5 or 10), start_image, seed, cfg_scale (0-1)
+func (k *KlingAdapter) buildInput(req *VideoGenerationRequest, prompt string) map[string]interface{} {
+	input := map[string]interface{}{
+		"prompt":       prompt,
+		"aspect_ratio": k.mapAspectRatio(req.AspectRatio),
+		"duration":     k.mapDuration(req.Duration),
+	}
+	if req.StartImageURL != "" {
+		input["start_image"] = req.StartImageURL
+	}
+	if req.NegativePrompt != "" {
+		input["negative_prompt"] = req.NegativePrompt
+	}
+	if req.Seed != nil {
+		input["seed"] = *req.Seed
+	}
+	if guidance, ok := req.Params[ParamGuidance]; ok {
+		input["cfg_scale"] = guidance
+	}
+	return input
+}
+
+// GetStatus checks the status of a video generation job
+func (k *KlingAdapter) GetStatus(ctx context.Context, predictionID string) (*VideoGenerationResult, error) {
+	url := fmt.Sprintf("https://api.replicate.com/v1/predictions/%s", predictionID)
+
+	var klingResp VeoResponse
+	err := retry.Do(ctx, retry.APIConfig(), func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		token, err := authorize(ctx, httpReq, k.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
+
+		resp, err := k.httpClient.Do(httpReq)
+		if err != nil {
+			return requestError(ProviderReplicate, err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return replicateStatusError(ctx, k.tokens, token, resp.StatusCode, body)
+		}
+
+		if err := json.Unmarshal(body, &klingResp); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k.toResult(&klingResp), nil
+}
+
+// CancelPrediction stops a running prediction
+func (k *KlingAdapter) CancelPrediction(ctx context.Context, predictionID string) error {
+	return cancelReplicatePrediction(ctx, k.httpClient, fmt.Sprintf("https://api.replicate.com/v1/predictions/%s/cancel", predictionID), k.tokens)
+}
+
+// GetModelName returns the name of the model
+func (k *KlingAdapter) GetModelName() string {
+	return "Kling V2.5 Turbo Pro"
+}
+
+// GetCostPerSecond returns the approximate cost per second of video
+// Based on Replicate pricing for Kling V2.5 Turbo Pro
+func (k *KlingAdapter) GetCostPerSecond() float64 {
+	return 0.07
+}
+
+// toResult maps a Replicate prediction to our result format
+func (k *KlingAdapter) toResult(resp *VeoResponse) *VideoGenerationResult {
+	result := &VideoGenerationResult{
+		PredictionID: resp.ID,
+	}
+
+	switch resp.Status {
+	case "succeeded":
+		result.Status = "completed"
+		if url, ok := extractOutputURL(resp.Output); ok {
+			result.VideoURL = url
+		}
+	case "failed", "canceled":
+		result.Status = resp.Status
+		result.Error = resp.Error
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("Generation failed with status: %s (no error details provided)", resp.Status)
+		}
+	default:
+		result.Status = "processing"
+	}
+
+	return result
+}
+
+// mapAspectRatio maps our aspect ratio format to Kling's format
+func (k *KlingAdapter) mapAspectRatio(ar string) string {
+	switch ar {
+	case "16:9", "9:16", "1:1":
+		return ar
+	default:
+		return "16:9"
+	}
+}
+
+// mapDuration maps scene duration to Kling's supported durations
+// Kling ONLY supports 5 or 10 seconds
+func (k *KlingAdapter) mapDuration(seconds int) int {
+	if seconds <= 7 {
+		return 5
+	}
+	return 10
+}
+
+// extractOutputURL extracts the video URL from a Replicate prediction output
+func extractOutputURL(output interface{}) (string, bool) {
+	switch val := output.(type) {
+	case string:
+		return val, true
+	case []interface{}:
+		if len(val) > 0 {
+			if url, ok := val[0].(string); ok {
+				return url, true
+			}
+		}
+	}
+	return "", false
+}
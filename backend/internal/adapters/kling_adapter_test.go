@@ -0,0 +1,55 @@
+package adapters
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestKlingBuildInput(t *testing.T) {
+	k := NewKlingAdapter("", "", zap.NewNop())
+	seed := int64(4242)
+
+	tests := []struct {
+		name string
+		req  *VideoGenerationRequest
+		want map[string]interface{}
+	}{
+		{
+			name: "prompt only",
+			req:  &VideoGenerationRequest{Duration: 5, AspectRatio: "1:1"},
+			want: map[string]interface{}{"prompt": "A bottle on a counter", "aspect_ratio": "1:1", "duration": 5},
+		},
+		{
+			name: "all parameters",
+			req: &VideoGenerationRequest{
+				Duration:       10,
+				AspectRatio:    "16:9",
+				StartImageURL:  "https://example.com/frame.jpg",
+				NegativePrompt: "extra fingers, warped text",
+				Seed:           &seed,
+				Params:         map[string]float64{ParamGuidance: 0.7},
+			},
+			// Kling calls the start frame start_image and guidance cfg_scale
+			want: map[string]interface{}{
+				"prompt":          "A bottle on a counter",
+				"aspect_ratio":    "16:9",
+				"duration":        10,
+				"start_image":     "https://example.com/frame.jpg",
+				"negative_prompt": "extra fingers, warped text",
+				"seed":            int64(4242),
+				"cfg_scale":       0.7,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := k.buildInput(tt.req, "A bottle on a counter")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildInput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
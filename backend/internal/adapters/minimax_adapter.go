@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/omnigen/backend/internal/trace"
 	"go.uber.org/zap"
 
 	"github.com/omnigen/backend/pkg/retry"
@@ -17,24 +18,49 @@ import (
 
 // MinimaxAdapter implements music generation via Minimax music-1.5
 type MinimaxAdapter struct {
-	apiToken     string
+	tokens       TokenSource
 	httpClient   *http.Client
 	logger       *zap.Logger
 	modelVersion string
+	webhooks     *ReplicateWebhooks
 }
 
-// NewMinimaxAdapter creates a new Minimax music adapter
-func NewMinimaxAdapter(apiToken string, logger *zap.Logger) *MinimaxAdapter {
+// NewMinimaxAdapter creates a new Minimax music adapter; an empty model uses DefaultMinimaxModel
+func NewMinimaxAdapter(apiToken, model string, logger *zap.Logger) *MinimaxAdapter {
+	if model == "" {
+		model = DefaultMinimaxModel
+	}
 	return &MinimaxAdapter{
-		apiToken: apiToken,
+		tokens: StaticToken(apiToken),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second, // Async operation - just for initial request acknowledgment
 		},
 		logger:       logger,
-		modelVersion: "minimax/music-1.5:70c8395540eae909be2c09a0b4897d22ee2455a5e5c9826b71161743b5cc45f1",
+		modelVersion: model,
 	}
 }
 
+// SetReplicateWebhooks makes new predictions report completion by webhook; nil polls only
+func (m *MinimaxAdapter) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	m.webhooks = webhooks
+}
+
+// SetTokenSource makes requests use the token tokens supplies at the time they're sent,
+// instead of the one passed to NewMinimaxAdapter
+func (m *MinimaxAdapter) SetTokenSource(tokens TokenSource) {
+	m.tokens = tokens
+}
+
+// ReplicateWebhooks returns the webhooks new predictions report to
+func (m *MinimaxAdapter) ReplicateWebhooks() *ReplicateWebhooks {
+	return m.webhooks
+}
+
+// ModelVersion returns the model version predictions on ctx are submitted with
+func (m *MinimaxAdapter) ModelVersion(ctx context.Context) string {
+	return modelFor(ctx, ModelMinimax, m.modelVersion)
+}
+
 // MusicGenerationRequest represents the input for music generation
 type MusicGenerationRequest struct {
 	Prompt     string // User's video prompt (we'll derive music prompt from this)
@@ -49,12 +75,23 @@ type MusicGenerationResult struct {
 	Status       string
 	AudioURL     string
 	Error        string
+	Provider     MusicProvider // Set by MusicChain to the provider that produced the track
+}
+
+// MusicGenerator is the minimal contract needed to submit and poll a music prediction
+type MusicGenerator interface {
+	// GenerateMusic submits a music request and returns immediately
+	GenerateMusic(ctx context.Context, req *MusicGenerationRequest) (*MusicGenerationResult, error)
+
+	// GetStatus checks the status of a music prediction
+	GetStatus(ctx context.Context, predictionID string) (*MusicGenerationResult, error)
 }
 
 // MinimaxRequest matches the Minimax API schema
 type MinimaxRequest struct {
 	Version string                 `json:"version"`
 	Input   map[string]interface{} `json:"input"`
+	ReplicateWebhookFields
 }
 
 // MinimaxResponse represents the Replicate API response
@@ -70,7 +107,8 @@ type MinimaxResponse struct {
 
 // GenerateMusic generates background music using Minimax
 func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGenerationRequest) (*MusicGenerationResult, error) {
-	m.logger.Info("Generating music with Minimax",
+	logger := trace.Logger(ctx, m.logger)
+	logger.Info("Generating music with Minimax",
 		zap.String("mood", req.MusicMood),
 		zap.String("style", req.MusicStyle),
 		zap.Int("duration", req.Duration),
@@ -82,14 +120,14 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 	// Generate instrumental lyrics structure
 	lyrics := m.generateLyrics(req.Duration)
 
-	m.logger.Debug("Generated music parameters",
+	logger.Debug("Generated music parameters",
 		zap.String("music_prompt", musicPrompt),
 		zap.String("lyrics", lyrics),
 	)
 
 	// Build Minimax API request
 	minimaxReq := MinimaxRequest{
-		Version: m.modelVersion,
+		Version: useModel(ctx, logger, ModelMinimax, m.modelVersion),
 		Input: map[string]interface{}{
 			"prompt":       musicPrompt,
 			"lyrics":       lyrics,
@@ -97,6 +135,7 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 			"bitrate":      256000,
 			"audio_format": "mp3",
 		},
+		ReplicateWebhookFields: m.webhooks.requestFields(),
 	}
 
 	payload, err := json.Marshal(minimaxReq)
@@ -104,7 +143,7 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	m.logger.Info("Submitting Minimax API request",
+	logger.Info("Submitting Minimax API request",
 		zap.Int("duration_seconds", req.Duration),
 		zap.Int("sample_rate", 44100),
 		zap.Int("bitrate", 256000),
@@ -121,14 +160,18 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", "Bearer "+m.apiToken)
+		token, err := authorize(ctx, httpReq, m.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 		httpReq.Header.Set("Prefer", "wait=0") // Don't wait for completion (async)
 
 		resp, err := m.httpClient.Do(httpReq)
 		if err != nil {
 			// Network errors are retryable
-			return fmt.Errorf("request failed: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -138,12 +181,7 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 		}
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-			// 4xx errors are non-retryable
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
-			}
-			// 5xx errors are retryable
-			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+			return replicateStatusError(ctx, m.tokens, token, resp.StatusCode, body)
 		}
 
 		if err := json.Unmarshal(body, &minimaxResp); err != nil {
@@ -170,7 +208,7 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 		}
 	}
 
-	m.logger.Info("Minimax prediction created successfully",
+	logger.Info("Minimax prediction created successfully",
 		zap.String("prediction_id", result.PredictionID),
 		zap.String("status", result.Status),
 		zap.String("created_at", minimaxResp.CreatedAt),
@@ -181,6 +219,7 @@ func (m *MinimaxAdapter) GenerateMusic(ctx context.Context, req *MusicGeneration
 
 // GetStatus checks the status of a music generation prediction
 func (m *MinimaxAdapter) GetStatus(ctx context.Context, predictionID string) (*MusicGenerationResult, error) {
+	logger := trace.Logger(ctx, m.logger)
 	url := fmt.Sprintf("https://api.replicate.com/v1/predictions/%s", predictionID)
 
 	var minimaxResp MinimaxResponse
@@ -190,12 +229,16 @@ func (m *MinimaxAdapter) GetStatus(ctx context.Context, predictionID string) (*M
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", "Bearer "+m.apiToken)
+		token, err := authorize(ctx, httpReq, m.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 
 		resp, err := m.httpClient.Do(httpReq)
 		if err != nil {
 			// Network errors are retryable
-			return fmt.Errorf("request failed: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -205,12 +248,7 @@ func (m *MinimaxAdapter) GetStatus(ctx context.Context, predictionID string) (*M
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			// 4xx errors are non-retryable
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
-			}
-			// 5xx errors are retryable
-			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+			return replicateStatusError(ctx, m.tokens, token, resp.StatusCode, body)
 		}
 
 		if err := json.Unmarshal(body, &minimaxResp); err != nil {
@@ -231,7 +269,7 @@ func (m *MinimaxAdapter) GetStatus(ctx context.Context, predictionID string) (*M
 	}
 
 	// Log the full response for debugging
-	m.logger.Debug("Minimax GetStatus response",
+	logger.Debug("Minimax GetStatus response",
 		zap.String("prediction_id", minimaxResp.ID),
 		zap.String("status", minimaxResp.Status),
 		zap.String("error", minimaxResp.Error),
@@ -248,6 +286,11 @@ func (m *MinimaxAdapter) GetStatus(ctx context.Context, predictionID string) (*M
 	return result, nil
 }
 
+// CancelPrediction stops a running prediction
+func (m *MinimaxAdapter) CancelPrediction(ctx context.Context, predictionID string) error {
+	return cancelReplicatePrediction(ctx, m.httpClient, fmt.Sprintf("https://api.replicate.com/v1/predictions/%s/cancel", predictionID), m.tokens)
+}
+
 // generateMusicPrompt creates a 10-300 character music prompt from video context
 func (m *MinimaxAdapter) generateMusicPrompt(videoPrompt, mood, style string) string {
 	// Extract key visual elements from video prompt (first few words)
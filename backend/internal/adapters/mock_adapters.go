@@ -0,0 +1,377 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// MockMedia supplies the sample media returned by the mock adapters used in ENVIRONMENT=local
+type MockMedia interface {
+	// VideoURL returns a URL serving an MP4 clip of the given length and aspect ratio
+	VideoURL(ctx context.Context, seconds int, aspectRatio string) (string, error)
+
+	// AudioURL returns a URL serving an MP3 track of the given length
+	AudioURL(ctx context.Context, seconds int) (string, error)
+
+	// Audio returns an MP3 of the given length
+	Audio(ctx context.Context, seconds float64) ([]byte, error)
+}
+
+// mockProvider names the mock adapters in provenance entries
+const mockProvider = "mock"
+
+// mockPredictionIDs numbers the predictions of all mock adapters
+var mockPredictionIDs atomic.Int64
+
+// newMockPredictionID returns a unique prediction ID for kind
+func newMockPredictionID(kind string) string {
+	return fmt.Sprintf("mock-%s-%d", kind, mockPredictionIDs.Add(1))
+}
+
+// mockWait sleeps for delay, returning early with the context's error if it is canceled
+func mockWait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// MockVideoAdapter implements VideoGeneratorAdapter with sample clips. Predictions complete
+// after delay and return their URL immediately, so the pipeline never polls.
+type MockVideoAdapter struct {
+	model   AdapterType
+	media   MockMedia
+	delay   time.Duration
+	logger  *zap.Logger
+	results sync.Map // prediction ID -> *VideoGenerationResult
+}
+
+// NewMockVideoAdapter creates a mock adapter standing in for model
+func NewMockVideoAdapter(model AdapterType, media MockMedia, delay time.Duration, logger *zap.Logger) *MockVideoAdapter {
+	return &MockVideoAdapter{
+		model:  model,
+		media:  media,
+		delay:  delay,
+		logger: logger,
+	}
+}
+
+// GenerateVideo waits for the adapter's delay and returns a sample clip of the requested length
+func (m *MockVideoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequest) (*VideoGenerationResult, error) {
+	submitted := time.Now()
+	predictionID := newMockPredictionID("video")
+	url, err := m.mockClip(ctx, req)
+	recordProviderCall(ctx, mockProvider, m.GetModelName(), predictionID, submitted, err)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VideoGenerationResult{
+		VideoURL:     url,
+		PredictionID: predictionID,
+		Status:       string(PredictionSucceeded),
+	}
+	m.results.Store(result.PredictionID, result)
+	trace.Logger(ctx, m.logger).Info("Mock video generated",
+		zap.String("model", string(m.model)),
+		zap.String("prediction_id", result.PredictionID),
+		zap.Int("duration", req.Duration),
+		zap.String("aspect_ratio", req.AspectRatio),
+	)
+	return result, nil
+}
+
+// mockClip waits for the adapter's delay and returns the URL of a clip matching req
+func (m *MockVideoAdapter) mockClip(ctx context.Context, req *VideoGenerationRequest) (string, error) {
+	if err := mockWait(ctx, m.delay); err != nil {
+		return "", err
+	}
+	url, err := m.media.VideoURL(ctx, req.Duration, req.AspectRatio)
+	if err != nil {
+		return "", fmt.Errorf("mock video unavailable: %w", err)
+	}
+	return url, nil
+}
+
+// GetStatus returns the result of a prediction made by this adapter
+func (m *MockVideoAdapter) GetStatus(ctx context.Context, predictionID string) (*VideoGenerationResult, error) {
+	result, ok := m.results.Load(predictionID)
+	if !ok {
+		return nil, fmt.Errorf("unknown mock prediction %s", predictionID)
+	}
+	return result.(*VideoGenerationResult), nil
+}
+
+// GetModelName returns the name of the mocked model
+func (m *MockVideoAdapter) GetModelName() string {
+	return "mock-" + string(m.model)
+}
+
+// GetCostPerSecond returns 0; mock clips are free
+func (m *MockVideoAdapter) GetCostPerSecond() float64 {
+	return 0
+}
+
+// MockMusicAdapter implements MusicGenerator with sample tracks
+type MockMusicAdapter struct {
+	media   MockMedia
+	delay   time.Duration
+	logger  *zap.Logger
+	results sync.Map // prediction ID -> *MusicGenerationResult
+}
+
+// NewMockMusicAdapter creates a mock music adapter
+func NewMockMusicAdapter(media MockMedia, delay time.Duration, logger *zap.Logger) *MockMusicAdapter {
+	return &MockMusicAdapter{
+		media:  media,
+		delay:  delay,
+		logger: logger,
+	}
+}
+
+// GenerateMusic waits for the adapter's delay and returns a sample track of the requested length
+func (m *MockMusicAdapter) GenerateMusic(ctx context.Context, req *MusicGenerationRequest) (*MusicGenerationResult, error) {
+	submitted := time.Now()
+	predictionID := newMockPredictionID("music")
+	url, err := m.mockTrack(ctx, req)
+	recordProviderCall(ctx, mockProvider, "mock-minimax", predictionID, submitted, err)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MusicGenerationResult{
+		PredictionID: predictionID,
+		Status:       string(PredictionSucceeded),
+		AudioURL:     url,
+	}
+	m.results.Store(result.PredictionID, result)
+	trace.Logger(ctx, m.logger).Info("Mock music generated",
+		zap.String("prediction_id", result.PredictionID),
+		zap.Int("duration", req.Duration),
+	)
+	return result, nil
+}
+
+// mockTrack waits for the adapter's delay and returns the URL of a track matching req
+func (m *MockMusicAdapter) mockTrack(ctx context.Context, req *MusicGenerationRequest) (string, error) {
+	if err := mockWait(ctx, m.delay); err != nil {
+		return "", err
+	}
+	url, err := m.media.AudioURL(ctx, req.Duration)
+	if err != nil {
+		return "", fmt.Errorf("mock music unavailable: %w", err)
+	}
+	return url, nil
+}
+
+// GetStatus returns the result of a prediction made by this adapter
+func (m *MockMusicAdapter) GetStatus(ctx context.Context, predictionID string) (*MusicGenerationResult, error) {
+	result, ok := m.results.Load(predictionID)
+	if !ok {
+		return nil, fmt.Errorf("unknown mock prediction %s", predictionID)
+	}
+	return result.(*MusicGenerationResult), nil
+}
+
+// mockWordsPerSecond is the speaking rate MockTTSAdapter assumes at 1.0x
+const mockWordsPerSecond = 2.5
+
+// MockTTSAdapter implements TTSAdapter with sample audio as long as the text would take to read
+type MockTTSAdapter struct {
+	media  MockMedia
+	delay  time.Duration
+	logger *zap.Logger
+}
+
+// NewMockTTSAdapter creates a mock text-to-speech adapter
+func NewMockTTSAdapter(media MockMedia, delay time.Duration, logger *zap.Logger) *MockTTSAdapter {
+	return &MockTTSAdapter{
+		media:  media,
+		delay:  delay,
+		logger: logger,
+	}
+}
+
+// GenerateVoiceover returns sample audio for text at 1.0x speed
+func (m *MockTTSAdapter) GenerateVoiceover(ctx context.Context, text string, voice string) ([]byte, error) {
+	audio, _, err := m.GenerateVoiceoverWithDuration(ctx, text, voice, 1.0)
+	return audio, err
+}
+
+// Voices returns the same male and female voices as OpenAI
+func (m *MockTTSAdapter) Voices(ctx context.Context) ([]Voice, error) {
+	return slices.Clone(openAIVoices), nil
+}
+
+// GenerateVoiceoverWithDuration returns sample audio for text at speed and its duration
+func (m *MockTTSAdapter) GenerateVoiceoverWithDuration(ctx context.Context, text string, voice string, speed float64) ([]byte, float64, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, 0, fmt.Errorf("text is required")
+	}
+	if err := mockWait(ctx, m.delay); err != nil {
+		return nil, 0, err
+	}
+
+	duration := mockSpeechDuration(text, speed)
+	audio, err := m.media.Audio(ctx, duration)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mock voiceover unavailable: %w", err)
+	}
+	trace.Logger(ctx, m.logger).Info("Mock voiceover generated",
+		zap.String("voice", voice),
+		zap.Float64("duration", duration),
+	)
+	return audio, duration, nil
+}
+
+// mockSpeechDuration estimates how long text takes to read at speed, rounded up to 0.1s
+func mockSpeechDuration(text string, speed float64) float64 {
+	if speed <= 0 {
+		speed = 1.0
+	}
+	seconds := float64(len(strings.Fields(text))) / (mockWordsPerSecond * speed)
+	return math.Max(1, math.Ceil(seconds*10)/10)
+}
+
+// MockScriptGenerator implements ScriptGenerator with a fixed scene template, producing
+// scripts that pass the same validation as GPT-4o output
+type MockScriptGenerator struct {
+	logger *zap.Logger
+}
+
+// NewMockScriptGenerator creates a mock script generator
+func NewMockScriptGenerator(logger *zap.Logger) *MockScriptGenerator {
+	return &MockScriptGenerator{logger: logger}
+}
+
+// GenerateScript builds a script splitting req.Duration into scenes of the video model's longest
+// clip, or into the scene plan's scenes when there is one
+func (m *MockScriptGenerator) GenerateScript(ctx context.Context, req *ScriptGenerationRequest) (*domain.Script, error) {
+	videoModel := AdapterType(req.VideoModel)
+	if videoModel == "" {
+		videoModel = DefaultAdapterType
+	}
+	durations := ClipDurations(videoModel)
+	if len(durations) == 0 {
+		return nil, fmt.Errorf("unsupported video model: %s", videoModel)
+	}
+	if req.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+
+	longest := durations[len(durations)-1]
+	numScenes := (req.Duration + longest - 1) / longest
+	if len(req.ScenePlan) > 0 {
+		numScenes = len(req.ScenePlan)
+	}
+	subject := req.Prompt[:min(80, len(req.Prompt))]
+
+	script := &domain.Script{
+		Title:         "Local preview: " + subject,
+		TotalDuration: req.Duration,
+		Status:        "draft",
+		AudioSpec: domain.AudioSpec{
+			EnableAudio: true,
+			MusicMood:   "upbeat",
+			MusicStyle:  "electronic",
+		},
+		Metadata: domain.Metadata{
+			ProductName:  "Local Product",
+			CallToAction: "Try it today",
+		},
+	}
+	var narration []string
+	for i := 0; i < numScenes; i++ {
+		action := fmt.Sprintf("Scene %d of %d showcasing %s.", i+1, numScenes, subject)
+		duration, location := float64(req.Duration)/float64(numScenes), "INT. STUDIO - DAY"
+		if len(req.ScenePlan) > 0 {
+			duration = float64(req.ScenePlan[i].Duration)
+			if req.ScenePlan[i].Hint != "" {
+				location = req.ScenePlan[i].Hint
+			}
+		}
+		script.Scenes = append(script.Scenes, domain.Scene{
+			SceneNumber: i + 1,
+			Duration:    duration,
+			Location:    location,
+			Action:      action,
+			ShotType:    domain.ShotMedium,
+			CameraAngle: domain.AngleEyeLevel,
+			CameraMove:  domain.MoveStatic,
+			Lighting:    domain.LightStudio,
+			ColorGrade:  domain.GradeWarm,
+			Mood:        domain.MoodEnergetic,
+			VisualStyle: domain.StyleCinematic,
+			GenerationPrompt: fmt.Sprintf("Medium shot in a bright studio, scene %d of %d: %s. Warm light, steady camera.",
+				i+1, numScenes, subject),
+		})
+		narration = append(narration, action)
+	}
+	if req.Voice != "" {
+		script.AudioSpec.NarratorScript = strings.Join(narration, " ")
+	}
+	if req.Voice != "" && req.SideEffects != "" {
+		script.AudioSpec.SideEffectsText = req.SideEffects
+	}
+
+	if len(req.ScenePlan) == 0 {
+		if _, err := NormalizeSceneDurations(script, req.Duration, videoModel); err != nil {
+			return nil, fmt.Errorf("script validation failed: %w", err)
+		}
+	} else {
+		retimeScenes(script)
+	}
+	ResolveImageAssignments(script, req.ProductImages)
+	if err := ValidateScript(script, req.Duration, req.Voice != "" && req.SideEffects != "", videoModel, req.ScenePlan); err != nil {
+		return nil, fmt.Errorf("script validation failed: %w", err)
+	}
+
+	trace.Logger(ctx, m.logger).Info("Mock script generated",
+		zap.Int("num_scenes", len(script.Scenes)),
+		zap.Int("total_duration", script.TotalDuration),
+	)
+	return script, nil
+}
+
+// GenerateScriptVariants builds max(req.Variants, 1) scripts, numbered in their titles and
+// scene prompts so the variants' videos can be told apart
+func (m *MockScriptGenerator) GenerateScriptVariants(ctx context.Context, req *ScriptGenerationRequest) ([]*domain.Script, error) {
+	count := max(req.Variants, 1)
+	scripts := make([]*domain.Script, count)
+	for i := range scripts {
+		script, err := m.GenerateScript(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if count > 1 {
+			script.Title = fmt.Sprintf("%s (variant %d)", script.Title, i+1)
+			for j := range script.Scenes {
+				script.Scenes[j].GenerationPrompt += fmt.Sprintf(" Variant %d.", i+1)
+			}
+		}
+		scripts[i] = script
+	}
+	return scripts, nil
+}
+
+// AnalyzeStyleReference returns a fixed style description without looking at the image
+func (m *MockScriptGenerator) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
+	return "Soft natural light, muted warm palette, shallow depth of field.", nil
+}
@@ -0,0 +1,186 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// fakeMockMedia records the media requested of it
+type fakeMockMedia struct {
+	videos []string
+	audio  []float64
+}
+
+func (f *fakeMockMedia) VideoURL(ctx context.Context, seconds int, aspectRatio string) (string, error) {
+	url := fmt.Sprintf("http://fixtures/video-%ds-%s.mp4", seconds, aspectRatio)
+	f.videos = append(f.videos, url)
+	return url, nil
+}
+
+func (f *fakeMockMedia) AudioURL(ctx context.Context, seconds int) (string, error) {
+	return fmt.Sprintf("http://fixtures/music-%ds.mp3", seconds), nil
+}
+
+func (f *fakeMockMedia) Audio(ctx context.Context, seconds float64) ([]byte, error) {
+	f.audio = append(f.audio, seconds)
+	return []byte("mp3"), nil
+}
+
+func TestMockAdapterFactory(t *testing.T) {
+	media := &fakeMockMedia{}
+	factory := NewMockAdapterFactory(media, 0, zap.NewNop())
+
+	adapter, err := factory.CreateAdapter(AdapterTypeKling)
+	if err != nil {
+		t.Fatalf("CreateAdapter(kling) error = %v", err)
+	}
+	if got := adapter.GetModelName(); got != "mock-kling" {
+		t.Errorf("GetModelName() = %q, want mock-kling", got)
+	}
+	if _, err := factory.CreateAdapter(AdapterType("runway")); err == nil {
+		t.Error("CreateAdapter(runway) expected error, got nil")
+	}
+
+	var recorded []domain.ProvenanceEntry
+	ctx := WithProvenance(context.Background(), func(entry domain.ProvenanceEntry) { recorded = append(recorded, entry) })
+	result, err := adapter.GenerateVideo(ctx, &VideoGenerationRequest{Duration: 5, AspectRatio: "9:16"})
+	if err != nil {
+		t.Fatalf("GenerateVideo() error = %v", err)
+	}
+	if result.VideoURL != "http://fixtures/video-5s-9:16.mp4" {
+		t.Errorf("VideoURL = %q", result.VideoURL)
+	}
+	if NormalizeStatus(result.Status) != PredictionSucceeded {
+		t.Errorf("Status = %q, want succeeded", result.Status)
+	}
+	if len(recorded) != 1 || recorded[0].PredictionID != result.PredictionID || recorded[0].Provider != "mock" {
+		t.Errorf("provenance = %+v, want one mock entry for %s", recorded, result.PredictionID)
+	}
+
+	status, err := adapter.GetStatus(context.Background(), result.PredictionID)
+	if err != nil || status.VideoURL != result.VideoURL {
+		t.Errorf("GetStatus() = %+v, %v; want the generated clip", status, err)
+	}
+	if _, ok := factory.GetDefaultAdapter().(*MockVideoAdapter); !ok {
+		t.Error("GetDefaultAdapter() is not a mock adapter")
+	}
+}
+
+func TestMockAdaptersHonorCancellation(t *testing.T) {
+	media := &fakeMockMedia{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	video := NewMockVideoAdapter(AdapterTypeVeo, media, time.Hour, zap.NewNop())
+	if _, err := video.GenerateVideo(ctx, &VideoGenerationRequest{Duration: 8}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateVideo() error = %v, want context.Canceled", err)
+	}
+	music := NewMockMusicAdapter(media, time.Hour, zap.NewNop())
+	if _, err := music.GenerateMusic(ctx, &MusicGenerationRequest{Duration: 8}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateMusic() error = %v, want context.Canceled", err)
+	}
+	if len(media.videos) != 0 {
+		t.Errorf("canceled generation still requested media: %v", media.videos)
+	}
+}
+
+func TestMockMusicAdapter(t *testing.T) {
+	music := NewMockMusicAdapter(&fakeMockMedia{}, time.Millisecond, zap.NewNop())
+
+	result, err := music.GenerateMusic(context.Background(), &MusicGenerationRequest{Duration: 24})
+	if err != nil {
+		t.Fatalf("GenerateMusic() error = %v", err)
+	}
+	if result.AudioURL != "http://fixtures/music-24s.mp3" {
+		t.Errorf("AudioURL = %q", result.AudioURL)
+	}
+	polled, err := PollMusicUntilComplete(context.Background(), music, result.PredictionID, PollOptions{Interval: time.Millisecond})
+	if err != nil || polled.AudioURL != result.AudioURL {
+		t.Errorf("PollMusicUntilComplete() = %+v, %v", polled, err)
+	}
+}
+
+func TestMockTTSAdapter(t *testing.T) {
+	media := &fakeMockMedia{}
+	tts := NewMockTTSAdapter(media, 0, zap.NewNop())
+
+	// Ten words at 2.5 words per second, read 1.4x faster
+	_, duration, err := tts.GenerateVoiceoverWithDuration(context.Background(),
+		"one two three four five six seven eight nine ten", "female", 1.4)
+	if err != nil {
+		t.Fatalf("GenerateVoiceoverWithDuration() error = %v", err)
+	}
+	if duration != 2.9 {
+		t.Errorf("duration = %v, want 2.9", duration)
+	}
+	if len(media.audio) != 1 || media.audio[0] != duration {
+		t.Errorf("audio requested = %v, want one %vs clip", media.audio, duration)
+	}
+
+	if _, err := tts.GenerateVoiceover(context.Background(), "  ", "male"); err == nil {
+		t.Error("GenerateVoiceover(blank) expected error, got nil")
+	}
+}
+
+func TestMockScriptGenerator(t *testing.T) {
+	generator := NewMockScriptGenerator(zap.NewNop())
+
+	tests := []struct {
+		name       string
+		req        ScriptGenerationRequest
+		wantScenes int
+	}{
+		{name: "veo", req: ScriptGenerationRequest{Prompt: "A running shoe", Duration: 30}, wantScenes: 4},
+		{name: "kling", req: ScriptGenerationRequest{Prompt: "A running shoe", Duration: 20, VideoModel: "kling"}, wantScenes: 2},
+		{
+			name:       "pharma",
+			req:        ScriptGenerationRequest{Prompt: "Allergy relief", Duration: 16, Voice: "female", SideEffects: "May cause drowsiness."},
+			wantScenes: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := generator.GenerateScript(context.Background(), &tt.req)
+			if err != nil {
+				t.Fatalf("GenerateScript() error = %v", err)
+			}
+			if len(script.Scenes) != tt.wantScenes {
+				t.Errorf("got %d scenes, want %d", len(script.Scenes), tt.wantScenes)
+			}
+			if script.TotalDuration != tt.req.Duration {
+				t.Errorf("TotalDuration = %d, want %d", script.TotalDuration, tt.req.Duration)
+			}
+			if tt.req.Voice != "" && script.AudioSpec.NarratorScript == "" {
+				t.Error("narrated script has no narrator_script")
+			}
+			if script.AudioSpec.SideEffectsText != tt.req.SideEffects {
+				t.Errorf("SideEffectsText = %q, want %q", script.AudioSpec.SideEffectsText, tt.req.SideEffects)
+			}
+		})
+	}
+
+	if _, err := generator.GenerateScript(context.Background(), &ScriptGenerationRequest{Prompt: "x", Duration: 10, VideoModel: "runway"}); err == nil {
+		t.Error("GenerateScript(runway) expected error, got nil")
+	}
+}
+
+func TestMockScriptGenerator_Variants(t *testing.T) {
+	generator := NewMockScriptGenerator(zap.NewNop())
+
+	scripts, err := generator.GenerateScriptVariants(context.Background(), &ScriptGenerationRequest{Prompt: "A running shoe", Duration: 16, Variants: 3})
+	if err != nil {
+		t.Fatalf("GenerateScriptVariants() error = %v", err)
+	}
+	if len(scripts) != 3 {
+		t.Fatalf("got %d scripts, want 3", len(scripts))
+	}
+	if scripts[0].Title == scripts[1].Title || scripts[0].Scenes[0].GenerationPrompt == scripts[2].Scenes[0].GenerationPrompt {
+		t.Error("variants should be distinguishable")
+	}
+}
@@ -0,0 +1,195 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Replicate models the adapters submit to when none is configured. Versioned models are pinned
+// to a hash so a new upstream release never changes output without a config change.
+const (
+	DefaultGPT4oModel   = "openai/gpt-4o:ad45308bffd6defaaa05dff12658b454a3a8dcfd7cc1440420a74d87a48caa9e"
+	DefaultVeoModel     = "google/veo-3.1:a55204f92195a6c535170095e221116968f43614517d8ad32b338fa12ee4460b"
+	DefaultKlingModel   = "kwaivgi/kling-v2.5-turbo-pro"
+	DefaultMinimaxModel = "minimax/music-1.5:70c8395540eae909be2c09a0b4897d22ee2455a5e5c9826b71161743b5cc45f1"
+)
+
+// ModelKey names a Replicate model slot that can be configured or overridden
+type ModelKey string
+
+const (
+	ModelGPT4o   ModelKey = "gpt4o"
+	ModelVeo     ModelKey = "veo"
+	ModelKling   ModelKey = "kling"
+	ModelMinimax ModelKey = "minimax"
+)
+
+// modelSpec is what a model slot accepts
+type modelSpec struct {
+	prefix   string // Required start of owner/model, so a typo can't send Veo prompts to a music model
+	versions bool   // Accepts owner/model:version; false for adapters using the models endpoint
+}
+
+var modelSpecs = map[ModelKey]modelSpec{
+	ModelGPT4o:   {prefix: "openai/gpt-4o", versions: true},
+	ModelVeo:     {prefix: "google/veo-", versions: true},
+	ModelKling:   {prefix: "kwaivgi/kling-", versions: false},
+	ModelMinimax: {prefix: "minimax/music-", versions: true},
+}
+
+// modelPattern matches owner/model with an optional :version hash
+var modelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*/[a-z0-9][a-z0-9._-]*(:[0-9a-f]{64})?$`)
+
+// ModelVersions holds the Replicate model each adapter submits to. Empty fields use the
+// adapter's default.
+type ModelVersions struct {
+	GPT4o   string
+	Veo     string
+	Kling   string
+	Minimax string
+}
+
+// WithDefaults returns m with empty fields set to the default models
+func (m ModelVersions) WithDefaults() ModelVersions {
+	if m.GPT4o == "" {
+		m.GPT4o = DefaultGPT4oModel
+	}
+	if m.Veo == "" {
+		m.Veo = DefaultVeoModel
+	}
+	if m.Kling == "" {
+		m.Kling = DefaultKlingModel
+	}
+	if m.Minimax == "" {
+		m.Minimax = DefaultMinimaxModel
+	}
+	return m
+}
+
+// Validate checks every configured model against its slot; empty fields are valid
+func (m ModelVersions) Validate() error {
+	for _, model := range []struct {
+		key     ModelKey
+		version string
+	}{
+		{ModelGPT4o, m.GPT4o},
+		{ModelVeo, m.Veo},
+		{ModelKling, m.Kling},
+		{ModelMinimax, m.Minimax},
+	} {
+		if model.version == "" {
+			continue
+		}
+		if err := ValidateModelVersion(model.key, model.version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateModelVersion checks that version is a Replicate model the key's adapter can submit to:
+// owner/model, or owner/model:<64-character hash> where the adapter pins versions, starting with
+// the expected owner and model family.
+func ValidateModelVersion(key ModelKey, version string) error {
+	spec, ok := modelSpecs[key]
+	if !ok {
+		return fmt.Errorf("unknown model %q (expected one of %s)", key, strings.Join(modelKeys(), ", "))
+	}
+	if !modelPattern.MatchString(version) {
+		return fmt.Errorf("%s model %q must be owner/model or owner/model:<64-character version hash>", key, version)
+	}
+	if !strings.HasPrefix(version, spec.prefix) {
+		return fmt.Errorf("%s model %q must start with %q", key, version, spec.prefix)
+	}
+	if !spec.versions && strings.Contains(version, ":") {
+		return fmt.Errorf("%s model %q must not include a version: it always runs the model's latest version", key, version)
+	}
+	return nil
+}
+
+// ModelOverrideHeader carries per-request model overrides, when the server allows them
+const ModelOverrideHeader = "X-Model-Override"
+
+// ParseModelOverrides reads an X-Model-Override value such as
+// "veo=google/veo-3.1:<hash>,minimax=minimax/music-1.5", validating each model against its slot
+func ParseModelOverrides(header string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, version, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("override %q must be key=owner/model", part)
+		}
+		key, version = strings.TrimSpace(key), strings.TrimSpace(version)
+		if _, duplicate := overrides[key]; duplicate {
+			return nil, fmt.Errorf("%s is overridden more than once", key)
+		}
+		if err := ValidateModelVersion(ModelKey(key), version); err != nil {
+			return nil, err
+		}
+		overrides[key] = version
+	}
+	if len(overrides) == 0 {
+		return nil, fmt.Errorf("no overrides given")
+	}
+	return overrides, nil
+}
+
+type modelOverridesKey struct{}
+
+// WithModelOverrides returns a context whose adapter calls submit to the given models (keyed by
+// ModelKey) instead of the configured ones
+func WithModelOverrides(ctx context.Context, overrides map[string]string) context.Context {
+	if len(overrides) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, modelOverridesKey{}, overrides)
+}
+
+// modelFor returns the model to submit key's predictions to on ctx: its override, if it has
+// one, otherwise configured
+func modelFor(ctx context.Context, key ModelKey, configured string) string {
+	if overrides, ok := ctx.Value(modelOverridesKey{}).(map[string]string); ok {
+		if version, ok := overrides[string(key)]; ok {
+			return version
+		}
+	}
+	return configured
+}
+
+// loggedModels records which models this process has logged, so each is logged once even
+// though the video adapters are created per job
+var loggedModels sync.Map
+
+// useModel returns the model to submit key's predictions to on ctx, logging it the first time
+// the process uses it
+func useModel(ctx context.Context, logger *zap.Logger, key ModelKey, configured string) string {
+	version := modelFor(ctx, key, configured)
+	if _, logged := loggedModels.LoadOrStore(string(key)+"="+version, true); !logged && logger != nil {
+		logger.Info("Using Replicate model",
+			zap.String("model", string(key)),
+			zap.String("version", version),
+			zap.Bool("override", version != configured),
+		)
+	}
+	return version
+}
+
+// modelKeys lists the model slots, sorted
+func modelKeys() []string {
+	keys := make([]string, 0, len(modelSpecs))
+	for key := range modelSpecs {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	return keys
+}
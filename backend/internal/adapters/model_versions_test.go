@@ -0,0 +1,120 @@
+package adapters
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const testVersionHash = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestModelVersionsDefaultsAreValid(t *testing.T) {
+	if err := (ModelVersions{}).WithDefaults().Validate(); err != nil {
+		t.Fatalf("default models are invalid: %v", err)
+	}
+	if err := (ModelVersions{}).Validate(); err != nil {
+		t.Fatalf("empty config is invalid: %v", err)
+	}
+}
+
+func TestValidateModelVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     ModelKey
+		version string
+		wantErr string
+	}{
+		{name: "pinned veo", key: ModelVeo, version: "google/veo-3.1-fast:" + testVersionHash},
+		{name: "unpinned veo", key: ModelVeo, version: "google/veo-3"},
+		{name: "kling model", key: ModelKling, version: "kwaivgi/kling-v2.1"},
+		{name: "other owner", key: ModelVeo, version: "bytedance/seedance-1:" + testVersionHash, wantErr: `must start with "google/veo-"`},
+		{name: "music model for video", key: ModelVeo, version: DefaultMinimaxModel, wantErr: `must start with "google/veo-"`},
+		{name: "short hash", key: ModelGPT4o, version: "openai/gpt-4o:ad45", wantErr: "64-character"},
+		{name: "no owner", key: ModelMinimax, version: "music-1.5", wantErr: "owner/model"},
+		{name: "kling version", key: ModelKling, version: "kwaivgi/kling-v2.1:" + testVersionHash, wantErr: "must not include a version"},
+		{name: "unknown key", key: "runway", version: "runway/gen-3", wantErr: "expected one of gpt4o, kling, minimax, veo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateModelVersion(tt.key, tt.version)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateModelVersion() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateModelVersion() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModelVersionsValidateNamesTheBadModel(t *testing.T) {
+	err := ModelVersions{Veo: DefaultVeoModel, Minimax: "minimax/music-1.5:latest"}.Validate()
+	if err == nil || !strings.HasPrefix(err.Error(), "minimax model") {
+		t.Fatalf("Validate() error = %v, want the minimax model reported", err)
+	}
+}
+
+func TestParseModelOverrides(t *testing.T) {
+	overrides, err := ParseModelOverrides(" veo=google/veo-3.1:" + testVersionHash + ", kling=kwaivgi/kling-v2.1 ")
+	if err != nil {
+		t.Fatalf("ParseModelOverrides() error = %v", err)
+	}
+	if len(overrides) != 2 || overrides["veo"] != "google/veo-3.1:"+testVersionHash || overrides["kling"] != "kwaivgi/kling-v2.1" {
+		t.Errorf("overrides = %v", overrides)
+	}
+
+	for _, header := range []string{
+		"veo",
+		"veo=kwaivgi/kling-v2.1",
+		"veo=google/veo-3,veo=google/veo-3.1",
+		"sfx=sepal/audiogen",
+		" , ",
+	} {
+		if _, err := ParseModelOverrides(header); err == nil {
+			t.Errorf("ParseModelOverrides(%q) succeeded, want an error", header)
+		}
+	}
+}
+
+func TestModelOverridesApplyPerContext(t *testing.T) {
+	veo := NewVeoAdapter("token", "", nil)
+	kling := NewKlingAdapter("token", "kwaivgi/kling-v2.1", nil)
+	ctx := WithModelOverrides(context.Background(), map[string]string{"veo": "google/veo-3"})
+
+	if got := veo.ModelVersion(ctx); got != "google/veo-3" {
+		t.Errorf("overridden veo model = %q", got)
+	}
+	if got := veo.ModelVersion(context.Background()); got != DefaultVeoModel {
+		t.Errorf("configured veo model = %q, want the default", got)
+	}
+	if got := kling.ModelVersion(ctx); got != "kwaivgi/kling-v2.1" {
+		t.Errorf("kling model = %q, want the configured one", got)
+	}
+}
+
+func TestUseModelLogsEachVersionOnce(t *testing.T) {
+	loggedModels.Clear()
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	override := WithModelOverrides(context.Background(), map[string]string{"veo": "google/veo-3"})
+
+	for range 3 {
+		useModel(context.Background(), logger, ModelVeo, DefaultVeoModel)
+		useModel(override, logger, ModelVeo, DefaultVeoModel)
+	}
+
+	entries := logs.FilterMessage("Using Replicate model").All()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want one per version", len(entries))
+	}
+	if entries[0].ContextMap()["override"] != false || entries[1].ContextMap()["override"] != true {
+		t.Errorf("entries = %v", entries)
+	}
+}
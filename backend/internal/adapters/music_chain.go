@@ -0,0 +1,134 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// MusicProvider names a music adapter in a MusicChain
+type MusicProvider string
+
+const (
+	MusicProviderMinimax  MusicProvider = "minimax"
+	MusicProviderMusicGen MusicProvider = "musicgen"
+)
+
+// musicAttemptsPerProvider is how many predictions a provider gets before the chain moves on:
+// a retry gets past one-off failures without waiting out a longer outage
+const musicAttemptsPerProvider = 2
+
+// MusicSource is one music adapter in a MusicChain
+type MusicSource struct {
+	Provider  MusicProvider
+	Generator MusicGenerator
+}
+
+// MusicChain generates music with the first of its adapters that succeeds, so a Minimax outage
+// falls back to a secondary model instead of failing every job
+type MusicChain struct {
+	sources []MusicSource
+	logger  *zap.Logger
+}
+
+// NewMusicChain creates a chain that tries sources in order. Sources without a generator are
+// skipped, so an unconfigured fallback leaves the primary on its own.
+func NewMusicChain(sources []MusicSource, logger *zap.Logger) *MusicChain {
+	configured := make([]MusicSource, 0, len(sources))
+	for _, source := range sources {
+		if source.Generator != nil {
+			configured = append(configured, source)
+		}
+	}
+	return &MusicChain{sources: configured, logger: logger}
+}
+
+// Configured reports whether the chain has any adapter to generate music with
+func (c *MusicChain) Configured() bool {
+	return c != nil && len(c.sources) > 0
+}
+
+// Generate submits req to each provider in turn and polls it to completion. A prediction that
+// fails or times out is tried once more before the chain moves on; a provider that can't be
+// submitted to (its requests are already retried) is skipped straight away. The result's
+// Provider is the one that produced the track.
+func (c *MusicChain) Generate(ctx context.Context, req *MusicGenerationRequest, opts PollOptions) (*MusicGenerationResult, error) {
+	if !c.Configured() {
+		return nil, fmt.Errorf("no music provider configured")
+	}
+	logger := trace.Logger(ctx, c.logger)
+
+	var failures []error
+	for i, source := range c.sources {
+		for attempt := 1; attempt <= musicAttemptsPerProvider; attempt++ {
+			result, err := c.attempt(ctx, source, req, opts)
+			if err == nil {
+				if i > 0 {
+					logger.Warn("Music generated by fallback provider",
+						zap.String("provider", string(source.Provider)),
+						zap.String("primary", string(c.sources[0].Provider)),
+					)
+				}
+				result.Provider = source.Provider
+				return result, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			failures = append(failures, fmt.Errorf("%s attempt %d: %w", source.Provider, attempt, err))
+
+			retryable := isPredictionFailure(err)
+			logger.Warn("Music generation failed",
+				zap.String("provider", string(source.Provider)),
+				zap.Int("attempt", attempt),
+				zap.Bool("retrying", retryable && attempt < musicAttemptsPerProvider),
+				zap.Error(err),
+			)
+			if !retryable {
+				break
+			}
+		}
+	}
+	return nil, fmt.Errorf("all music providers failed: %w", errors.Join(failures...))
+}
+
+// attempt runs one prediction on source
+func (c *MusicChain) attempt(ctx context.Context, source MusicSource, req *MusicGenerationRequest, opts PollOptions) (*MusicGenerationResult, error) {
+	result, err := source.Generator.GenerateMusic(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("submit failed: %w", err)
+	}
+	if result.AudioURL == "" {
+		opts.Label = source.Provider.label()
+		result, err = PollMusicUntilComplete(ctx, source.Generator, result.PredictionID, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result.AudioURL == "" {
+		return nil, &GenerationError{PredictionID: result.PredictionID, Status: PredictionFailed, Message: "prediction returned no audio"}
+	}
+	return result, nil
+}
+
+// isPredictionFailure reports whether err is a prediction that failed or timed out, rather
+// than a request the provider rejected
+func isPredictionFailure(err error) bool {
+	var genErr *GenerationError
+	return errors.As(err, &genErr) || errors.Is(err, ErrPollTimeout)
+}
+
+// label names the provider in poll logs and metrics
+func (p MusicProvider) label() string {
+	switch p {
+	case MusicProviderMinimax:
+		return "Minimax music"
+	case MusicProviderMusicGen:
+		return "MusicGen music"
+	default:
+		return string(p) + " music"
+	}
+}
@@ -0,0 +1,127 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeMusicGenerator plays back one outcome per prediction: "" succeeds, "failed" fails once
+// polled, "timeout" never finishes and "reject" can't be submitted
+type fakeMusicGenerator struct {
+	outcomes []string
+	calls    int
+}
+
+func (f *fakeMusicGenerator) GenerateMusic(_ context.Context, _ *MusicGenerationRequest) (*MusicGenerationResult, error) {
+	outcome := ""
+	if f.calls < len(f.outcomes) {
+		outcome = f.outcomes[f.calls]
+	}
+	f.calls++
+	id := fmt.Sprintf("%s-%d", outcome, f.calls)
+	switch outcome {
+	case "reject":
+		return nil, errors.New("API error (status 422): invalid input")
+	case "":
+		return &MusicGenerationResult{PredictionID: id, Status: "succeeded", AudioURL: "https://replicate.delivery/" + id + ".mp3"}, nil
+	default:
+		return &MusicGenerationResult{PredictionID: id, Status: "starting"}, nil
+	}
+}
+
+func (f *fakeMusicGenerator) GetStatus(_ context.Context, predictionID string) (*MusicGenerationResult, error) {
+	if strings.HasPrefix(predictionID, "failed") {
+		return &MusicGenerationResult{PredictionID: predictionID, Status: "failed", Error: "model error"}, nil
+	}
+	return &MusicGenerationResult{PredictionID: predictionID, Status: "processing"}, nil
+}
+
+func TestMusicChain_Generate(t *testing.T) {
+	tests := []struct {
+		name         string
+		primary      []string
+		fallback     []string
+		wantProvider MusicProvider
+		wantCalls    [2]int
+		wantErr      bool
+	}{
+		{name: "primary succeeds", wantProvider: MusicProviderMinimax, wantCalls: [2]int{1, 0}},
+		{name: "primary retried once", primary: []string{"failed"}, wantProvider: MusicProviderMinimax, wantCalls: [2]int{2, 0}},
+		{name: "timeouts fall back", primary: []string{"timeout", "timeout"}, wantProvider: MusicProviderMusicGen, wantCalls: [2]int{2, 1}},
+		{name: "rejected request isn't retried", primary: []string{"reject"}, wantProvider: MusicProviderMusicGen, wantCalls: [2]int{1, 1}},
+		{
+			name:      "every provider fails",
+			primary:   []string{"failed", "timeout"},
+			fallback:  []string{"failed", "failed"},
+			wantCalls: [2]int{2, 2},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &fakeMusicGenerator{outcomes: tt.primary}
+			fallback := &fakeMusicGenerator{outcomes: tt.fallback}
+			chain := NewMusicChain([]MusicSource{
+				{Provider: MusicProviderMinimax, Generator: primary},
+				{Provider: MusicProviderMusicGen, Generator: fallback},
+			}, zap.NewNop())
+
+			opts := testPollOptions()
+			opts.Timeout = 20 * opts.Interval
+			result, err := chain.Generate(context.Background(), &MusicGenerationRequest{Duration: 30}, opts)
+			if got := [2]int{primary.calls, fallback.calls}; got != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Generate() = %+v, want error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if result.Provider != tt.wantProvider || result.AudioURL == "" {
+				t.Errorf("result = %+v, want audio from %s", result, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestMusicChain_SkipsUnconfiguredSources(t *testing.T) {
+	var chain *MusicChain
+	if chain.Configured() {
+		t.Error("nil chain is configured")
+	}
+
+	chain = NewMusicChain([]MusicSource{{Provider: MusicProviderMusicGen}}, zap.NewNop())
+	if chain.Configured() {
+		t.Error("chain without generators is configured")
+	}
+	if _, err := chain.Generate(context.Background(), &MusicGenerationRequest{}, testPollOptions()); err == nil {
+		t.Error("Generate() without providers expected error, got nil")
+	}
+}
+
+func TestMusicChain_StopsWhenCanceled(t *testing.T) {
+	primary := &fakeMusicGenerator{outcomes: []string{"timeout"}}
+	fallback := &fakeMusicGenerator{}
+	chain := NewMusicChain([]MusicSource{
+		{Provider: MusicProviderMinimax, Generator: primary},
+		{Provider: MusicProviderMusicGen, Generator: fallback},
+	}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := chain.Generate(ctx, &MusicGenerationRequest{}, testPollOptions()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Generate() error = %v, want context.Canceled", err)
+	}
+	if primary.calls != 1 || fallback.calls != 0 {
+		t.Errorf("calls = %d, %d; a canceled job shouldn't try again", primary.calls, fallback.calls)
+	}
+}
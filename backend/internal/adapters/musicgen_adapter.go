@@ -0,0 +1,219 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// DefaultMusicGenModel is the Replicate model the fallback music adapter submits to
+const DefaultMusicGenModel = "meta/musicgen"
+
+// MaxMusicGenDuration is the longest track requested from MusicGen; quality drops on longer
+// generations, and shorter tracks are looped to the video's length anyway
+const MaxMusicGenDuration = 30
+
+// MusicGenAdapter implements MusicGenerator via Meta's MusicGen on Replicate. It takes the same
+// requests as MinimaxAdapter, so it can stand in for it when Minimax is failing.
+type MusicGenAdapter struct {
+	tokens     TokenSource
+	httpClient *http.Client
+	logger     *zap.Logger
+	model      string
+	baseURL    string
+	webhooks   *ReplicateWebhooks
+}
+
+// NewMusicGenAdapter creates a new MusicGen music adapter; an empty model uses DefaultMusicGenModel
+func NewMusicGenAdapter(apiToken, model string, logger *zap.Logger) *MusicGenAdapter {
+	if model == "" {
+		model = DefaultMusicGenModel
+	}
+	return &MusicGenAdapter{
+		tokens: StaticToken(apiToken),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second, // Async operation - just for initial request acknowledgment
+		},
+		logger:  logger,
+		model:   model,
+		baseURL: "https://api.replicate.com",
+	}
+}
+
+// SetReplicateWebhooks makes new predictions report completion by webhook; nil polls only
+func (m *MusicGenAdapter) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	m.webhooks = webhooks
+}
+
+// SetTokenSource makes requests use the token tokens supplies at the time they're sent,
+// instead of the one passed to NewMusicGenAdapter
+func (m *MusicGenAdapter) SetTokenSource(tokens TokenSource) {
+	m.tokens = tokens
+}
+
+// ReplicateWebhooks returns the webhooks new predictions report to
+func (m *MusicGenAdapter) ReplicateWebhooks() *ReplicateWebhooks {
+	return m.webhooks
+}
+
+// ModelVersion returns the model predictions run; Replicate picks its latest version
+func (m *MusicGenAdapter) ModelVersion(context.Context) string {
+	return m.model
+}
+
+// musicGenRequest matches the Replicate model predictions API schema
+type musicGenRequest struct {
+	Input map[string]interface{} `json:"input"`
+	ReplicateWebhookFields
+}
+
+// musicGenResponse represents a Replicate prediction
+type musicGenResponse struct {
+	ID     string      `json:"id"`
+	Status string      `json:"status"`
+	Output interface{} `json:"output,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// GenerateMusic submits a music prediction
+func (m *MusicGenAdapter) GenerateMusic(ctx context.Context, req *MusicGenerationRequest) (*MusicGenerationResult, error) {
+	logger := trace.Logger(ctx, m.logger)
+	duration := min(max(req.Duration, 1), MaxMusicGenDuration)
+	prompt := musicGenPrompt(req.Prompt, req.MusicMood, req.MusicStyle)
+
+	logger.Info("Generating music with MusicGen",
+		zap.String("mood", req.MusicMood),
+		zap.String("style", req.MusicStyle),
+		zap.Int("duration", duration),
+		zap.String("model", m.model),
+	)
+
+	payload, err := json.Marshal(musicGenRequest{Input: map[string]interface{}{
+		"prompt":                 prompt,
+		"duration":               duration,
+		"model_version":          "stereo-large",
+		"output_format":          "mp3",
+		"normalization_strategy": "loudness",
+	}, ReplicateWebhookFields: m.webhooks.requestFields()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/models/%s/predictions", m.baseURL, m.model)
+
+	var resp musicGenResponse
+	if err := m.do(ctx, "POST", url, payload, &resp); err != nil {
+		return nil, err
+	}
+
+	logger.Info("MusicGen prediction created",
+		zap.String("prediction_id", resp.ID),
+		zap.String("status", resp.Status),
+	)
+
+	return toMusicGenResult(&resp), nil
+}
+
+// GetStatus checks the status of a music prediction
+func (m *MusicGenAdapter) GetStatus(ctx context.Context, predictionID string) (*MusicGenerationResult, error) {
+	url := fmt.Sprintf("%s/v1/predictions/%s", m.baseURL, predictionID)
+
+	var resp musicGenResponse
+	if err := m.do(ctx, "GET", url, nil, &resp); err != nil {
+		return nil, err
+	}
+	return toMusicGenResult(&resp), nil
+}
+
+// CancelPrediction stops a running prediction
+func (m *MusicGenAdapter) CancelPrediction(ctx context.Context, predictionID string) error {
+	return cancelReplicatePrediction(ctx, m.httpClient, fmt.Sprintf("%s/v1/predictions/%s/cancel", m.baseURL, predictionID), m.tokens)
+}
+
+// do sends a Replicate request with retries; 4xx responses are not retried
+func (m *MusicGenAdapter) do(ctx context.Context, method, url string, payload []byte, out *musicGenResponse) error {
+	return retry.Do(ctx, retry.APIConfig(), func() error {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		token, err := authorize(ctx, httpReq, m.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
+		if payload != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+			httpReq.Header.Set("Prefer", "wait=0") // Don't wait for completion (async)
+		}
+
+		resp, err := m.httpClient.Do(httpReq)
+		if err != nil {
+			// Network errors are retryable
+			return fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			if resp.StatusCode == http.StatusUnauthorized {
+				return unauthorizedError(ctx, m.tokens, token, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody)))
+			}
+			// 4xx errors are non-retryable
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody)))
+			}
+			// 5xx errors are retryable
+			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		}
+
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+		return nil
+	})
+}
+
+// toMusicGenResult maps a Replicate prediction to our result format
+func toMusicGenResult(resp *musicGenResponse) *MusicGenerationResult {
+	result := &MusicGenerationResult{
+		PredictionID: resp.ID,
+		Status:       resp.Status,
+		Error:        resp.Error,
+	}
+	if resp.Status == "succeeded" {
+		if url, ok := extractOutputURL(resp.Output); ok {
+			result.AudioURL = url
+		}
+	}
+	return result
+}
+
+// musicGenPrompt describes the track in MusicGen's terms. Unlike Minimax it has no lyrics
+// input, so the prompt asks for an instrumental outright.
+func musicGenPrompt(videoPrompt, mood, style string) string {
+	prompt := strings.Join(strings.Fields(fmt.Sprintf("Instrumental %s %s background music", style, mood)), " ")
+	if keywords := extractKeywords(videoPrompt, 3); keywords != "" {
+		prompt += " for a video about " + keywords
+	}
+	return prompt + ", no vocals"
+}
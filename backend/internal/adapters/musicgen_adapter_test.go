@@ -0,0 +1,70 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMusicGenAdapter_GenerateAndPoll(t *testing.T) {
+	var gotPath string
+	var gotBody musicGenRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			gotPath = r.URL.Path
+			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "music-1", "status": "starting"}`))
+		case http.MethodGet:
+			w.Write([]byte(`{"id": "music-1", "status": "succeeded", "output": "https://replicate.delivery/music.mp3"}`))
+		}
+	}))
+	defer server.Close()
+
+	adapter := NewMusicGenAdapter("token", "", zap.NewNop())
+	adapter.baseURL = server.URL
+
+	result, err := adapter.GenerateMusic(context.Background(), &MusicGenerationRequest{
+		Prompt:     "A runner on a mountain trail",
+		Duration:   45,
+		MusicMood:  "energetic",
+		MusicStyle: "electronic",
+	})
+	if err != nil {
+		t.Fatalf("GenerateMusic() error = %v", err)
+	}
+	if result.PredictionID != "music-1" || result.AudioURL != "" {
+		t.Errorf("result = %+v", result)
+	}
+	if gotPath != "/v1/models/meta/musicgen/predictions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotBody.Input["duration"] != float64(MaxMusicGenDuration) {
+		t.Errorf("duration = %v, want it capped at %d", gotBody.Input["duration"], MaxMusicGenDuration)
+	}
+	if want := "Instrumental electronic energetic background music for a video about runner mountain trail, no vocals"; gotBody.Input["prompt"] != want {
+		t.Errorf("prompt = %q, want %q", gotBody.Input["prompt"], want)
+	}
+
+	polled, err := PollMusicUntilComplete(context.Background(), adapter, result.PredictionID, testPollOptions())
+	if err != nil {
+		t.Fatalf("PollMusicUntilComplete() error = %v", err)
+	}
+	if polled.AudioURL != "https://replicate.delivery/music.mp3" {
+		t.Errorf("audio url = %q", polled.AudioURL)
+	}
+}
+
+func TestMusicGenPrompt_WithoutPreferences(t *testing.T) {
+	if got, want := musicGenPrompt("", "", ""), "Instrumental background music, no vocals"; got != want {
+		t.Errorf("musicGenPrompt() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// DefaultModerationModel is the OpenAI moderation model prompts are checked with
+const DefaultModerationModel = "omni-moderation-latest"
+
+// ModerationResult is the moderation verdict on one input
+type ModerationResult struct {
+	Flagged    bool
+	Categories []string // Flagged categories, sorted (e.g. "self-harm", "violence/graphic")
+}
+
+// OpenAIModerationAdapter checks text against OpenAI's usage policies with the moderation
+// endpoint, which is free to call
+type OpenAIModerationAdapter struct {
+	tokens     TokenSource
+	httpClient *http.Client
+	logger     *zap.Logger
+	model      string
+	baseURL    string
+	retry      retry.Config
+}
+
+// NewOpenAIModerationAdapter creates a new OpenAI moderation adapter
+func NewOpenAIModerationAdapter(apiKey string, logger *zap.Logger) *OpenAIModerationAdapter {
+	return &OpenAIModerationAdapter{
+		tokens: StaticToken(apiKey),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		logger:  logger,
+		model:   DefaultModerationModel,
+		baseURL: "https://api.openai.com",
+		retry:   retry.APIConfig(),
+	}
+}
+
+// SetTokenSource makes requests use the key tokens supplies at the time they're sent,
+// instead of the one passed to NewOpenAIModerationAdapter
+func (m *OpenAIModerationAdapter) SetTokenSource(tokens TokenSource) {
+	m.tokens = tokens
+}
+
+// openAIModerationRequest matches the OpenAI moderation API schema
+type openAIModerationRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openAIModerationResponse holds one result per input, in order
+type openAIModerationResponse struct {
+	ID      string `json:"id"`
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Moderate checks inputs in one request and returns a result for each of them, in order
+func (m *OpenAIModerationAdapter) Moderate(ctx context.Context, inputs []string) ([]ModerationResult, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	logger := trace.Logger(ctx, m.logger)
+
+	payload, err := json.Marshal(openAIModerationRequest{Model: m.model, Input: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var modResp openAIModerationResponse
+	err = retry.Do(ctx, m.retry, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/v1/moderations", bytes.NewReader(payload))
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+		token, err := authorize(ctx, httpReq, m.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := m.httpClient.Do(httpReq)
+		if err != nil {
+			// Network errors are retryable
+			return fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			logger.Warn("OpenAI moderation returned error",
+				zap.Int("status_code", resp.StatusCode),
+				zap.String("response_body", string(body)),
+			)
+			return openAIStatusError(ctx, m.tokens, token, resp.StatusCode, body)
+		}
+
+		if err := json.Unmarshal(body, &modResp); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(modResp.Results) != len(inputs) {
+		return nil, fmt.Errorf("moderation returned %d results for %d inputs", len(modResp.Results), len(inputs))
+	}
+
+	results := make([]ModerationResult, len(inputs))
+	for i, r := range modResp.Results {
+		results[i].Flagged = r.Flagged
+		for category, flagged := range r.Categories {
+			if flagged {
+				results[i].Categories = append(results[i].Categories, category)
+			}
+		}
+		sort.Strings(results[i].Categories)
+	}
+	return results, nil
+}
@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+func newTestModerationAdapter(serverURL string) *OpenAIModerationAdapter {
+	adapter := NewOpenAIModerationAdapter("test-key", zap.NewNop())
+	adapter.baseURL = serverURL
+	adapter.retry = retry.Config{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 2}
+	return adapter
+}
+
+func TestOpenAIModerationAdapter_Moderate(t *testing.T) {
+	var gotPath string
+	var gotBody openAIModerationRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Write([]byte(`{"id": "modr-1", "results": [
+			{"flagged": false, "categories": {"violence": false}},
+			{"flagged": true, "categories": {"violence/graphic": true, "violence": true, "sexual": false}}
+		]}`))
+	}))
+	defer server.Close()
+
+	results, err := newTestModerationAdapter(server.URL).Moderate(context.Background(), []string{"A sunny beach", "A gory fight"})
+	if err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+
+	want := []ModerationResult{
+		{Flagged: false},
+		{Flagged: true, Categories: []string{"violence", "violence/graphic"}},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	if gotPath != "/v1/moderations" {
+		t.Errorf("path = %q, want /v1/moderations", gotPath)
+	}
+	if gotBody.Model != DefaultModerationModel || len(gotBody.Input) != 2 {
+		t.Errorf("request = %+v, want both inputs in one request", gotBody)
+	}
+}
+
+func TestOpenAIModerationAdapter_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"results": [{"flagged": false, "categories": {}}]}`))
+	}))
+	defer server.Close()
+
+	if _, err := newTestModerationAdapter(server.URL).Moderate(context.Background(), []string{"A sunny beach"}); err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want one retry", got)
+	}
+}
+
+func TestOpenAIModerationAdapter_ResultCountMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestModerationAdapter(server.URL).Moderate(context.Background(), []string{"A sunny beach"})
+	if err == nil || !strings.Contains(err.Error(), "0 results for 1 inputs") {
+		t.Errorf("Moderate() error = %v, want a result count error", err)
+	}
+}
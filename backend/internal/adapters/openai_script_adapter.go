@@ -0,0 +1,337 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// ScriptLLMProvider names the service that runs the script generation model
+type ScriptLLMProvider string
+
+const (
+	ScriptLLMProviderReplicate ScriptLLMProvider = "replicate" // GPT-4o predictions on Replicate
+	ScriptLLMProviderOpenAI    ScriptLLMProvider = "openai"    // OpenAI Chat Completions API
+)
+
+// DefaultScriptLLMProvider is used when SCRIPT_LLM_PROVIDER isn't set
+const DefaultScriptLLMProvider = ScriptLLMProviderReplicate
+
+// DefaultOpenAIScriptModel is the OpenAI chat model scripts are written with
+const DefaultOpenAIScriptModel = "gpt-4o"
+
+// openAIProvider names OpenAI in provenance entries
+const openAIProvider = "openai"
+
+// OpenAIScriptAdapter implements script generation via the OpenAI Chat Completions API. It
+// writes the same prompts as GPT4oAdapter and validates the output the same way, but gets the
+// whole completion in one response instead of polling a prediction.
+type OpenAIScriptAdapter struct {
+	tokens     TokenSource
+	httpClient *http.Client
+	logger     *zap.Logger
+	model      string
+	baseURL    string
+	retry      retry.Config
+}
+
+// NewOpenAIScriptAdapter creates a new OpenAI script adapter; an empty model uses
+// DefaultOpenAIScriptModel
+func NewOpenAIScriptAdapter(apiKey, model string, logger *zap.Logger) *OpenAIScriptAdapter {
+	if model == "" {
+		model = DefaultOpenAIScriptModel
+	}
+	return &OpenAIScriptAdapter{
+		tokens: StaticToken(apiKey),
+		httpClient: &http.Client{
+			Timeout: 5 * time.Minute, // The whole script arrives in one response
+		},
+		logger:  logger,
+		model:   model,
+		baseURL: "https://api.openai.com",
+		retry:   retry.APIConfig(),
+	}
+}
+
+// SetTokenSource makes requests use the key tokens supplies at the time they're sent,
+// instead of the one passed to NewOpenAIScriptAdapter
+func (o *OpenAIScriptAdapter) SetTokenSource(tokens TokenSource) {
+	o.tokens = tokens
+}
+
+// openAIChatRequest matches the OpenAI Chat Completions API schema
+type openAIChatRequest struct {
+	Model               string                `json:"model"`
+	Messages            []openAIChatMessage   `json:"messages"`
+	Temperature         float64               `json:"temperature"`
+	TopP                float64               `json:"top_p,omitempty"`
+	MaxCompletionTokens int                   `json:"max_completion_tokens"`
+	ResponseFormat      *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream              bool                  `json:"stream"`
+}
+
+// openAIChatMessage is a chat message; Content is a string, or []openAIContentPart to send images
+type openAIChatMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// openAIContentPart is a text or image_url part of a message
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// openAIChatResponse is the part of a chat completion the adapter reads
+type openAIChatResponse struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// estimateChatTokens estimates the prompt tokens of messages
+func estimateChatTokens(messages []openAIChatMessage) int {
+	var texts []string
+	images := 0
+	for _, message := range messages {
+		switch content := message.Content.(type) {
+		case string:
+			texts = append(texts, content)
+		case []openAIContentPart:
+			for _, part := range content {
+				if part.ImageURL != nil {
+					images++
+				} else {
+					texts = append(texts, part.Text)
+				}
+			}
+		}
+	}
+	return estimatePromptTokens(images, texts...)
+}
+
+// openAIErrorResponse is the body OpenAI returns with an error status
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// GenerateScript generates a structured ad script using GPT-4o
+func (o *OpenAIScriptAdapter) GenerateScript(ctx context.Context, req *ScriptGenerationRequest) (*domain.Script, error) {
+	single := *req
+	single.Variants = 0
+	scripts, err := o.generateScripts(ctx, &single)
+	if err != nil {
+		return nil, err
+	}
+	return scripts[0], nil
+}
+
+// GenerateScriptVariants generates req.Variants scripts for A/B testing in a single completion
+func (o *OpenAIScriptAdapter) GenerateScriptVariants(ctx context.Context, req *ScriptGenerationRequest) ([]*domain.Script, error) {
+	return o.generateScripts(ctx, req)
+}
+
+// generateScripts asks for max(req.Variants, 1) scripts in JSON mode and validates each of them
+func (o *OpenAIScriptAdapter) generateScripts(ctx context.Context, req *ScriptGenerationRequest) ([]*domain.Script, error) {
+	logger := trace.Logger(ctx, o.logger)
+	logger.Info("Generating script with OpenAI",
+		zap.String("model", o.model),
+		zap.String("prompt", req.Prompt[:min(100, len(req.Prompt))]),
+		zap.Int("duration", req.Duration),
+		zap.Int("variants", max(req.Variants, 1)),
+	)
+
+	styleDescription := analyzeStyleReference(ctx, logger, o, req)
+	p := buildScriptPrompt(logger, req)
+
+	// Both the single script and the variants wrapper are JSON objects, so JSON mode fits either
+	output, err := o.complete(ctx, "script", openAIChatRequest{
+		Model: o.model,
+		Messages: []openAIChatMessage{
+			{Role: "system", Content: p.System},
+			{Role: "user", Content: p.User},
+		},
+		Temperature:         p.Temperature,
+		TopP:                0.9,
+		MaxCompletionTokens: p.MaxTokens,
+		ResponseFormat:      &openAIResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Received OpenAI output",
+		zap.Int("output_length", len(output)),
+		zap.String("output_preview", output[:min(500, len(output))]),
+	)
+	logger.Debug("Full OpenAI output", zap.String("full_output", output))
+
+	return finishScripts(logger, req, p, output, styleDescription)
+}
+
+// AnalyzeStyleReference uses GPT-4o vision to describe the style of a reference image
+func (o *OpenAIScriptAdapter) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
+	logger := trace.Logger(ctx, o.logger)
+	logger.Info("Analyzing style reference image with OpenAI vision",
+		zap.String("image_url", imageURL[:min(100, len(imageURL))]),
+	)
+
+	styleDescription, err := o.complete(ctx, "style analysis", openAIChatRequest{
+		Model: o.model,
+		Messages: []openAIChatMessage{
+			{Role: "user", Content: []openAIContentPart{
+				{Type: "text", Text: styleAnalysisPrompt},
+				{Type: "image_url", ImageURL: &openAIImageURL{URL: imageURL}},
+			}},
+		},
+		Temperature:         0.3, // Lower temperature for consistent style analysis
+		MaxCompletionTokens: 500,
+	})
+	if err != nil {
+		return "", fmt.Errorf("vision analysis failed: %w", err)
+	}
+
+	logger.Info("Style analysis complete",
+		zap.String("style_description", styleDescription[:min(200, len(styleDescription))]),
+	)
+	return styleDescription, nil
+}
+
+// complete sends a chat completion, retrying rate limits, 5xx errors and rotated keys with the
+// same backoff as Replicate submissions, and returns the message content. Errors carry the
+// status as an *APIError so failed jobs are classified like Replicate ones.
+func (o *OpenAIScriptAdapter) complete(ctx context.Context, label string, chatReq openAIChatRequest) (_ string, err error) {
+	logger := trace.Logger(ctx, o.logger)
+	chatReq.Stream = false
+	if err := checkTokenBudget(ctx, label, estimateChatTokens(chatReq.Messages), chatReq.MaxCompletionTokens); err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(chatReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	submitted := time.Now()
+	var chatResp openAIChatResponse
+	defer func() {
+		recordProviderCall(ctx, openAIProvider, o.model, chatResp.ID, submitted, err)
+	}()
+
+	err = retry.Do(ctx, o.retry, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+		token, err := authorize(ctx, httpReq, o.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := o.httpClient.Do(httpReq)
+		if err != nil {
+			// Network errors are retryable
+			return requestError(ProviderOpenAI, err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			logger.Error("OpenAI API returned error",
+				zap.String("call", label),
+				zap.Int("status_code", resp.StatusCode),
+				zap.String("response_body", string(body)),
+			)
+			return openAIStatusError(ctx, o.tokens, token, resp.StatusCode, body)
+		}
+
+		if err := json.Unmarshal(body, &chatResp); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if chatResp.Usage != nil {
+		recordTokenUsage(ctx, o.logger, openAIProvider, o.model, label, TokenUsage{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CompletionTokens: chatResp.Usage.CompletionTokens,
+		})
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no output from OpenAI %s", label)
+	}
+	choice := chatResp.Choices[0]
+	if choice.FinishReason == "content_filter" {
+		return "", &ContentPolicyError{Provider: ProviderOpenAI, Reason: fmt.Sprintf("%s output was withheld by the content filter", label)}
+	}
+	if choice.FinishReason == "length" {
+		return "", fmt.Errorf("OpenAI %s output was truncated at %d tokens", label, chatReq.MaxCompletionTokens)
+	}
+	content := strings.TrimSpace(choice.Message.Content)
+	if content == "" {
+		return "", fmt.Errorf("no output from OpenAI %s (finish reason: %s)", label, choice.FinishReason)
+	}
+	return content, nil
+}
+
+// openAIStatusError turns an error status into an error for retry.Do. Rate limits and 5xx
+// errors are retried; an exhausted quota (a 429 with code insufficient_quota) is reported as
+// payment required, like a 402, since waiting won't fix it.
+func openAIStatusError(ctx context.Context, tokens TokenSource, token string, status int, body []byte) error {
+	var apiErr openAIErrorResponse
+	_ = json.Unmarshal(body, &apiErr) // The raw body is reported either way
+
+	switch {
+	case status == http.StatusUnauthorized:
+		return unauthorizedError(ctx, tokens, token, newStatusError(ProviderOpenAI, status, body))
+	case status == http.StatusPaymentRequired || (status == http.StatusTooManyRequests && apiErr.Error.Code == "insufficient_quota"):
+		return retry.NewNonRetryableError(&InsufficientCreditsError{Provider: ProviderOpenAI, StatusCode: status, Body: string(body)})
+	case status == http.StatusTooManyRequests:
+		return &APIError{Provider: ProviderOpenAI, StatusCode: status, Body: "rate limit exceeded: " + string(body)}
+	case status >= 400 && status < 500:
+		return retry.NewNonRetryableError(newStatusError(ProviderOpenAI, status, body))
+	default:
+		// 5xx errors are retryable
+		return newStatusError(ProviderOpenAI, status, body)
+	}
+}
@@ -0,0 +1,295 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+const openAITestScript = `{
+	"title": "Morning Ritual",
+	"total_duration": 16,
+	"scenes": [
+		{"scene_number": 1, "duration": 8, "generation_prompt": "Slow push-in on a steaming ceramic mug on a sunlit kitchen counter, warm morning light"},
+		{"scene_number": 2, "duration": 8, "generation_prompt": "Close-up of hands wrapping around the mug, soft bokeh window light, gentle steam rising"}
+	],
+	"audio_spec": {"enable_audio": true, "music_mood": "calm", "music_style": "acoustic"}
+}`
+
+func newTestOpenAIScriptAdapter(serverURL string) *OpenAIScriptAdapter {
+	adapter := NewOpenAIScriptAdapter("test-key", "", zap.NewNop())
+	adapter.baseURL = serverURL
+	adapter.retry = retry.Config{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 2}
+	return adapter
+}
+
+// writeChatCompletion replies with a chat completion whose message is content
+func writeChatCompletion(t *testing.T, w http.ResponseWriter, content string) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"id": "chatcmpl-test",
+		"choices": []map[string]interface{}{
+			{"message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"},
+		},
+	})
+	if err != nil {
+		t.Errorf("encode response: %v", err)
+	}
+}
+
+func TestOpenAIScriptAdapter_GenerateScriptJSONMode(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		writeChatCompletion(t, w, openAITestScript)
+	}))
+	defer server.Close()
+
+	script, err := newTestOpenAIScriptAdapter(server.URL).GenerateScript(context.Background(), &ScriptGenerationRequest{
+		Prompt:      "A cozy coffee brand ad",
+		Duration:    16,
+		AspectRatio: "16:9",
+	})
+	if err != nil {
+		t.Fatalf("GenerateScript() error = %v", err)
+	}
+	if script.Title != "Morning Ritual" || len(script.Scenes) != 2 {
+		t.Errorf("script = %q with %d scenes, want the completion's script", script.Title, len(script.Scenes))
+	}
+
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", gotPath)
+	}
+	if gotAuth != "Bearer test-key" {
+		t.Errorf("Authorization = %q, want the API key", gotAuth)
+	}
+	if gotBody["model"] != DefaultOpenAIScriptModel {
+		t.Errorf("model = %v, want %s", gotBody["model"], DefaultOpenAIScriptModel)
+	}
+	if format, _ := gotBody["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("response_format = %v, want json_object", gotBody["response_format"])
+	}
+	if gotBody["stream"] != false {
+		t.Errorf("stream = %v, want false", gotBody["stream"])
+	}
+	messages, _ := gotBody["messages"].([]interface{})
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want system and user", len(messages))
+	}
+	system, _ := messages[0].(map[string]interface{})
+	if system["role"] != "system" || !strings.Contains(system["content"].(string), "JSON") {
+		t.Errorf("first message = %v, want the system prompt asking for JSON", system["role"])
+	}
+}
+
+func TestOpenAIScriptAdapter_ValidatesOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeChatCompletion(t, w, `{"title": "Too Short", "total_duration": 16, "scenes": []}`)
+	}))
+	defer server.Close()
+
+	_, err := newTestOpenAIScriptAdapter(server.URL).GenerateScript(context.Background(), &ScriptGenerationRequest{
+		Prompt:   "A cozy coffee brand ad",
+		Duration: 16,
+	})
+	if err == nil || !strings.Contains(err.Error(), "script has no scenes") {
+		t.Errorf("GenerateScript() error = %v, want the script validation error", err)
+	}
+}
+
+func TestOpenAIScriptAdapter_RetriesRateLimits(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"}}`))
+			return
+		}
+		writeChatCompletion(t, w, openAITestScript)
+	}))
+	defer server.Close()
+
+	_, err := newTestOpenAIScriptAdapter(server.URL).GenerateScript(context.Background(), &ScriptGenerationRequest{
+		Prompt:   "A cozy coffee brand ad",
+		Duration: 16,
+	})
+	if err != nil {
+		t.Fatalf("GenerateScript() error = %v, want the retry to succeed", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want the rate-limited request retried once", got)
+	}
+}
+
+func TestOpenAIScriptAdapter_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantCalls int32
+		wantErr   string
+	}{
+		{
+			name:      "exhausted rate limit",
+			status:    http.StatusTooManyRequests,
+			body:      `{"error": {"message": "Rate limit reached", "code": "rate_limit_exceeded"}}`,
+			wantCalls: 3,
+			wantErr:   "API error (status 429): rate limit exceeded",
+		},
+		{
+			name:      "insufficient quota",
+			status:    http.StatusTooManyRequests,
+			body:      `{"error": {"message": "You exceeded your current quota", "code": "insufficient_quota"}}`,
+			wantCalls: 1,
+			wantErr:   "API error (status 429): Payment required",
+		},
+		{
+			name:      "payment required",
+			status:    http.StatusPaymentRequired,
+			body:      `{"error": {"message": "Payment required"}}`,
+			wantCalls: 1,
+			wantErr:   "API error (status 402): Payment required",
+		},
+		{
+			name:      "server error",
+			status:    http.StatusBadGateway,
+			body:      `bad gateway`,
+			wantCalls: 3,
+			wantErr:   "API error (status 502)",
+		},
+		{
+			name:      "bad request",
+			status:    http.StatusBadRequest,
+			body:      `{"error": {"message": "Invalid model"}}`,
+			wantCalls: 1,
+			wantErr:   "API error (status 400)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := newTestOpenAIScriptAdapter(server.URL).GenerateScript(context.Background(), &ScriptGenerationRequest{
+				Prompt:   "A cozy coffee brand ad",
+				Duration: 16,
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GenerateScript() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestOpenAIScriptAdapter_TruncatedOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "chatcmpl-test", "choices": [{"message": {"content": "{\"title\": \"Cut"}, "finish_reason": "length"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestOpenAIScriptAdapter(server.URL).GenerateScript(context.Background(), &ScriptGenerationRequest{
+		Prompt:   "A cozy coffee brand ad",
+		Duration: 16,
+	})
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("GenerateScript() error = %v, want a truncation error", err)
+	}
+}
+
+func TestOpenAIScriptAdapter_AnalyzeStyleReference(t *testing.T) {
+	var gotBody struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				ImageURL struct {
+					URL string `json:"url"`
+				} `json:"image_url"`
+			} `json:"content"`
+		} `json:"messages"`
+		ResponseFormat *openAIResponseFormat `json:"response_format"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		writeChatCompletion(t, w, "  Warm golden-hour light with soft film grain.  ")
+	}))
+	defer server.Close()
+
+	style, err := newTestOpenAIScriptAdapter(server.URL).AnalyzeStyleReference(context.Background(), "https://example.com/style.jpg")
+	if err != nil {
+		t.Fatalf("AnalyzeStyleReference() error = %v", err)
+	}
+	if style != "Warm golden-hour light with soft film grain." {
+		t.Errorf("style = %q, want the trimmed description", style)
+	}
+
+	if len(gotBody.Messages) != 1 || len(gotBody.Messages[0].Content) != 2 {
+		t.Fatalf("messages = %+v, want one user message with text and image parts", gotBody.Messages)
+	}
+	parts := gotBody.Messages[0].Content
+	if parts[0].Type != "text" || parts[0].Text != styleAnalysisPrompt {
+		t.Errorf("first part = %q, want the style analysis prompt", parts[0].Type)
+	}
+	if parts[1].Type != "image_url" || parts[1].ImageURL.URL != "https://example.com/style.jpg" {
+		t.Errorf("second part = %+v, want the image URL", parts[1])
+	}
+	if gotBody.ResponseFormat != nil {
+		t.Errorf("response_format = %+v, want prose for style analysis", gotBody.ResponseFormat)
+	}
+}
+
+func TestOpenAIScriptAdapter_StyleReferenceInScript(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			writeChatCompletion(t, w, "Soft film grain.")
+			return
+		}
+		writeChatCompletion(t, w, openAITestScript)
+	}))
+	defer server.Close()
+
+	script, err := newTestOpenAIScriptAdapter(server.URL).GenerateScript(context.Background(), &ScriptGenerationRequest{
+		Prompt:              "A cozy coffee brand ad",
+		Duration:            16,
+		StyleReferenceImage: "https://example.com/style.jpg",
+	})
+	if err != nil {
+		t.Fatalf("GenerateScript() error = %v", err)
+	}
+	if script.StyleDescription != "Soft film grain." {
+		t.Errorf("style description = %q, want the analyzed style", script.StyleDescription)
+	}
+	if !strings.HasSuffix(script.Scenes[0].GenerationPrompt, ". Style: Soft film grain.") {
+		t.Errorf("scene prompt = %q, want the style appended", script.Scenes[0].GenerationPrompt)
+	}
+}
@@ -0,0 +1,45 @@
+package adapters
+
+import "github.com/omnigen/backend/internal/domain"
+
+// Resolution is a video frame size in pixels
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// outputResolutions lists the frame sizes each model returns for an aspect ratio, across its
+// resolution settings
+var outputResolutions = map[AdapterType]map[string][]Resolution{
+	AdapterTypeVeo: {
+		domain.AspectRatio16x9: {{1280, 720}, {1920, 1080}},
+		domain.AspectRatio9x16: {{720, 1280}, {1080, 1920}},
+		domain.AspectRatio1x1:  {{720, 720}, {1080, 1080}},
+	},
+	AdapterTypeKling: {
+		domain.AspectRatio16x9: {{1280, 720}, {1920, 1080}},
+		domain.AspectRatio9x16: {{720, 1280}, {1080, 1920}},
+		domain.AspectRatio1x1:  {{720, 720}, {960, 960}, {1080, 1080}},
+	},
+}
+
+// OutputResolutions returns the frame sizes the model returns for aspectRatio, or nil when
+// either is unknown
+func OutputResolutions(adapterType AdapterType, aspectRatio string) []Resolution {
+	return outputResolutions[adapterType][aspectRatio]
+}
+
+// IsExpectedResolution reports whether a width x height clip is one the model returns for
+// aspectRatio. Any size is expected when the model or aspect ratio is unknown.
+func IsExpectedResolution(adapterType AdapterType, aspectRatio string, width, height int) bool {
+	expected := OutputResolutions(adapterType, aspectRatio)
+	if expected == nil {
+		return true
+	}
+	for _, r := range expected {
+		if r.Width == width && r.Height == height {
+			return true
+		}
+	}
+	return false
+}
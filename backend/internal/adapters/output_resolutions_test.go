@@ -0,0 +1,55 @@
+package adapters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func TestOutputResolutions_MatchTheirAspectRatio(t *testing.T) {
+	shapes := map[string]float64{
+		domain.AspectRatio16x9: 16.0 / 9,
+		domain.AspectRatio9x16: 9.0 / 16,
+		domain.AspectRatio1x1:  1,
+	}
+	for _, model := range []AdapterType{AdapterTypeVeo, AdapterTypeKling} {
+		for aspectRatio, want := range shapes {
+			resolutions := OutputResolutions(model, aspectRatio)
+			if len(resolutions) == 0 {
+				t.Fatalf("OutputResolutions(%s, %s) is empty", model, aspectRatio)
+			}
+			for _, r := range resolutions {
+				if got := float64(r.Width) / float64(r.Height); math.Abs(got-want) > 0.01 {
+					t.Errorf("%s %s resolution %dx%d has ratio %.3f, want %.3f", model, aspectRatio, r.Width, r.Height, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestIsExpectedResolution(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         AdapterType
+		aspectRatio   string
+		width, height int
+		want          bool
+	}{
+		{"veo 720p landscape", AdapterTypeVeo, domain.AspectRatio16x9, 1280, 720, true},
+		{"veo 1080p portrait", AdapterTypeVeo, domain.AspectRatio9x16, 1080, 1920, true},
+		{"veo landscape for a portrait request", AdapterTypeVeo, domain.AspectRatio9x16, 1280, 720, false},
+		{"veo landscape for a square request", AdapterTypeVeo, domain.AspectRatio1x1, 1920, 1080, false},
+		{"encoder padding", AdapterTypeVeo, domain.AspectRatio16x9, 1920, 1088, false},
+		{"kling square", AdapterTypeKling, domain.AspectRatio1x1, 960, 960, true},
+		{"unknown aspect ratio", AdapterTypeVeo, "4:3", 640, 480, true},
+		{"unknown model", AdapterType("minimax"), domain.AspectRatio16x9, 640, 480, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsExpectedResolution(tt.model, tt.aspectRatio, tt.width, tt.height); got != tt.want {
+				t.Errorf("IsExpectedResolution(%s, %s, %d, %d) = %v, want %v", tt.model, tt.aspectRatio, tt.width, tt.height, got, tt.want)
+			}
+		})
+	}
+}
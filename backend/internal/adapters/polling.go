@@ -0,0 +1,287 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/omnigen/backend/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// PredictionStatus is the normalized lifecycle state of a provider prediction
+type PredictionStatus string
+
+const (
+	PredictionQueued     PredictionStatus = "queued"
+	PredictionProcessing PredictionStatus = "processing"
+	PredictionSucceeded  PredictionStatus = "succeeded"
+	PredictionFailed     PredictionStatus = "failed"
+	PredictionCanceled   PredictionStatus = "canceled"
+)
+
+// NormalizeStatus maps provider and adapter status strings onto PredictionStatus.
+// Unknown values are treated as still processing.
+func NormalizeStatus(status string) PredictionStatus {
+	switch status {
+	case "starting", "queued", "pending":
+		return PredictionQueued
+	case "succeeded", "completed":
+		return PredictionSucceeded
+	case "failed", "error":
+		return PredictionFailed
+	case "canceled", "cancelled":
+		return PredictionCanceled
+	default:
+		return PredictionProcessing
+	}
+}
+
+// IsTerminal reports whether the prediction will not change state again
+func (s PredictionStatus) IsTerminal() bool {
+	return s == PredictionSucceeded || s == PredictionFailed || s == PredictionCanceled
+}
+
+// ErrPollTimeout is returned when a prediction does not finish within PollOptions.Timeout
+var ErrPollTimeout = errors.New("prediction polling timed out")
+
+// GenerationError is returned when the provider reports a failed or canceled prediction
+type GenerationError struct {
+	PredictionID string
+	Status       PredictionStatus
+	Message      string // error string reported by the provider
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("prediction %s %s: %s", e.PredictionID, e.Status, e.Message)
+}
+
+// PollOptions configures PollUntilComplete
+type PollOptions struct {
+	Interval time.Duration // delay between status checks
+	Timeout  time.Duration // overall polling deadline (0 = until ctx is done)
+	LogEvery int           // log progress every N polls (0 = never)
+	Label    string        // human-readable name used in log messages and as the metrics adapter, e.g. "Veo clip"
+	Logger   *zap.Logger
+
+	// Model is the model version recorded in the job's provenance. Defaults to the adapter's
+	// ModelVersion.
+	Model string
+
+	// Webhooks wakes the poll as soon as Replicate reports the prediction finished. When they
+	// share events through a store, polling slows to the safety interval, which only matters if
+	// a webhook is lost. Defaults to the adapter's webhooks; nil polls at Interval.
+	Webhooks *ReplicateWebhooks
+
+	// Canceler stops the prediction when ctx is canceled before it finishes.
+	// Defaults to the adapter when it implements PredictionCanceler.
+	Canceler PredictionCanceler
+}
+
+// PollUntilComplete polls a video prediction until it reaches a terminal state.
+// Transient GetStatus errors are logged and retried on the next tick.
+func PollUntilComplete(ctx context.Context, generator VideoGenerator, predictionID string, opts PollOptions) (*VideoGenerationResult, error) {
+	opts = withAdapterDefaults(ctx, opts, generator)
+	var result *VideoGenerationResult
+	err := pollPrediction(ctx, predictionID, opts, func(ctx context.Context) (string, string, error) {
+		r, err := generator.GetStatus(ctx, predictionID)
+		if err != nil {
+			return "", "", err
+		}
+		result = r
+		return r.Status, r.Error, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MusicStatusChecker is implemented by music adapters that expose prediction status
+type MusicStatusChecker interface {
+	GetStatus(ctx context.Context, predictionID string) (*MusicGenerationResult, error)
+}
+
+// PollMusicUntilComplete polls a music prediction until it reaches a terminal state
+func PollMusicUntilComplete(ctx context.Context, checker MusicStatusChecker, predictionID string, opts PollOptions) (*MusicGenerationResult, error) {
+	opts = withAdapterDefaults(ctx, opts, checker)
+	var result *MusicGenerationResult
+	err := pollPrediction(ctx, predictionID, opts, func(ctx context.Context) (string, string, error) {
+		r, err := checker.GetStatus(ctx, predictionID)
+		if err != nil {
+			return "", "", err
+		}
+		result = r
+		return r.Status, r.Error, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PollSFXUntilComplete polls a sound effect prediction until it reaches a terminal state
+func PollSFXUntilComplete(ctx context.Context, generator SFXGenerator, predictionID string, opts PollOptions) (*SFXGenerationResult, error) {
+	opts = withAdapterDefaults(ctx, opts, generator)
+	var result *SFXGenerationResult
+	err := pollPrediction(ctx, predictionID, opts, func(ctx context.Context) (string, string, error) {
+		r, err := generator.GetStatus(ctx, predictionID)
+		if err != nil {
+			return "", "", err
+		}
+		result = r
+		return r.Status, r.Error, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pollPrediction drives the shared polling loop; check returns the raw status and provider error.
+// A webhook only triggers an immediate check: the state is always read back from the API, so
+// each adapter keeps a single parse path. Polling starts as soon as a prediction is submitted,
+// so the time it takes and the checks it makes are recorded as the prediction's latency, and
+// its start as the submission time in the job's provenance.
+func pollPrediction(
+	ctx context.Context,
+	predictionID string,
+	opts PollOptions,
+	check func(ctx context.Context) (status string, providerErr string, err error),
+) (err error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	start := time.Now()
+	polls := 0
+	defer func() {
+		dims := metrics.Dimensions{"adapter": opts.Label, "outcome": predictionOutcome(err)}
+		rec := metrics.FromContext(ctx)
+		metrics.Since(rec, metrics.PredictionLatency, start, dims)
+		rec.Histogram(metrics.PredictionPolls, float64(polls), metrics.UnitCount, dims)
+		countPolls(ctx, polls)
+		recordProviderCall(ctx, replicateProvider, opts.Model, predictionID, start, err)
+	}()
+
+	// When the caller stops waiting (the job was canceled or timed out), stop the prediction too
+	if opts.Canceler != nil {
+		defer func() {
+			if err != nil && ctx.Err() != nil {
+				cancelAbandonedPrediction(ctx, opts.Canceler, predictionID, opts, logger)
+			}
+		}()
+	}
+
+	pollCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	interval := opts.Interval
+	var webhook <-chan PredictionEvent
+	if opts.Webhooks != nil {
+		events, unsubscribe := opts.Webhooks.subscribe(pollCtx, predictionID, opts.Interval)
+		defer unsubscribe()
+		webhook = events
+		interval = opts.Webhooks.pollInterval(interval)
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-pollCtx.Done():
+				timer.Stop()
+				return pollDoneError(ctx, predictionID, attempt, lastErr)
+			case <-timer.C:
+			case event := <-webhook:
+				timer.Stop()
+				logger.Debug("Prediction webhook received",
+					zap.String("label", opts.Label),
+					zap.String("prediction_id", predictionID),
+					zap.String("status", string(event.Status)),
+				)
+				// If the API hasn't caught up with the webhook yet, go back to regular polling
+				webhook = nil
+				interval = opts.Interval
+			}
+		}
+
+		polls++
+		rawStatus, providerErr, err := check(pollCtx)
+		if err != nil {
+			if pollCtx.Err() != nil {
+				return pollDoneError(ctx, predictionID, attempt, err)
+			}
+			lastErr = err
+			logger.Warn("Prediction status check failed, retrying",
+				zap.String("label", opts.Label),
+				zap.String("prediction_id", predictionID),
+				zap.Int("attempt", attempt),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		status := NormalizeStatus(rawStatus)
+		switch status {
+		case PredictionSucceeded:
+			return nil
+		case PredictionFailed, PredictionCanceled:
+			if providerErr == "" {
+				providerErr = "provider returned no error details"
+			}
+			genErr := &GenerationError{
+				PredictionID: predictionID,
+				Status:       status,
+				Message:      providerErr,
+			}
+			if isContentPolicyMessage(providerErr) {
+				return &ContentPolicyError{Provider: ProviderReplicate, Reason: providerErr, Err: genErr}
+			}
+			return genErr
+		}
+
+		if opts.LogEvery > 0 && attempt%opts.LogEvery == 0 {
+			logger.Info("Prediction still processing",
+				zap.String("label", opts.Label),
+				zap.String("prediction_id", predictionID),
+				zap.Int("attempt", attempt),
+				zap.String("status", string(status)),
+			)
+		}
+	}
+}
+
+// predictionOutcome names how polling ended, for metrics
+func predictionOutcome(err error) string {
+	var genErr *GenerationError
+	switch {
+	case err == nil:
+		return string(PredictionSucceeded)
+	case errors.As(err, &genErr):
+		return string(genErr.Status)
+	case errors.Is(err, ErrPollTimeout):
+		return "timeout"
+	default:
+		return "abandoned" // The caller stopped waiting
+	}
+}
+
+// pollDoneError distinguishes caller cancellation from the polling deadline, which is a
+// *TimeoutError wrapping ErrPollTimeout
+func pollDoneError(ctx context.Context, predictionID string, attempts int, lastErr error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if lastErr != nil {
+		return &TimeoutError{Provider: ProviderReplicate, Err: fmt.Errorf("%w after %d attempts (prediction %s, last error: %v)", ErrPollTimeout, attempts, predictionID, lastErr)}
+	}
+	return &TimeoutError{Provider: ProviderReplicate, Err: fmt.Errorf("%w after %d attempts (prediction %s)", ErrPollTimeout, attempts, predictionID)}
+}
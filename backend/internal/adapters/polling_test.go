@@ -0,0 +1,309 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/metrics"
+)
+
+// fakeVideoGenerator replays a scripted sequence of GetStatus responses
+type fakeVideoGenerator struct {
+	steps []fakeStatusStep
+	calls int
+	// onCall runs before each GetStatus response is returned
+	onCall func(call int)
+}
+
+type fakeStatusStep struct {
+	result *VideoGenerationResult
+	err    error
+}
+
+func (f *fakeVideoGenerator) GenerateVideo(ctx context.Context, req *VideoGenerationRequest) (*VideoGenerationResult, error) {
+	return &VideoGenerationResult{PredictionID: "pred-1", Status: "processing"}, nil
+}
+
+func (f *fakeVideoGenerator) GetStatus(ctx context.Context, predictionID string) (*VideoGenerationResult, error) {
+	call := f.calls
+	f.calls++
+	if f.onCall != nil {
+		f.onCall(call)
+	}
+	if call >= len(f.steps) {
+		return &VideoGenerationResult{PredictionID: predictionID, Status: "processing"}, nil
+	}
+	return f.steps[call].result, f.steps[call].err
+}
+
+func status(s string) fakeStatusStep {
+	return fakeStatusStep{result: &VideoGenerationResult{PredictionID: "pred-1", Status: s}}
+}
+
+func testPollOptions() PollOptions {
+	return PollOptions{Interval: time.Millisecond, Timeout: time.Second, Label: "test"}
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	tests := map[string]PredictionStatus{
+		"starting":   PredictionQueued,
+		"queued":     PredictionQueued,
+		"processing": PredictionProcessing,
+		"succeeded":  PredictionSucceeded,
+		"completed":  PredictionSucceeded,
+		"failed":     PredictionFailed,
+		"canceled":   PredictionCanceled,
+		"cancelled":  PredictionCanceled,
+		"mystery":    PredictionProcessing,
+	}
+
+	for raw, want := range tests {
+		if got := NormalizeStatus(raw); got != want {
+			t.Errorf("NormalizeStatus(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestPollUntilComplete(t *testing.T) {
+	t.Run("succeeds after queued and processing", func(t *testing.T) {
+		succeeded := status("completed")
+		succeeded.result.VideoURL = "https://example.com/clip.mp4"
+		gen := &fakeVideoGenerator{steps: []fakeStatusStep{status("starting"), status("processing"), succeeded}}
+
+		result, err := PollUntilComplete(context.Background(), gen, "pred-1", testPollOptions())
+		if err != nil {
+			t.Fatalf("PollUntilComplete() error = %v", err)
+		}
+		if result.VideoURL != "https://example.com/clip.mp4" {
+			t.Errorf("VideoURL = %q, want clip URL", result.VideoURL)
+		}
+		if gen.calls != 3 {
+			t.Errorf("GetStatus calls = %d, want 3", gen.calls)
+		}
+	})
+
+	t.Run("failed returns provider error", func(t *testing.T) {
+		failed := status("failed")
+		failed.result.Error = "content flagged"
+		gen := &fakeVideoGenerator{steps: []fakeStatusStep{status("processing"), failed}}
+
+		_, err := PollUntilComplete(context.Background(), gen, "pred-1", testPollOptions())
+		var genErr *GenerationError
+		if !errors.As(err, &genErr) {
+			t.Fatalf("expected GenerationError, got %v", err)
+		}
+		if genErr.Status != PredictionFailed || genErr.Message != "content flagged" {
+			t.Errorf("GenerationError = %+v, want failed/content flagged", genErr)
+		}
+	})
+
+	t.Run("canceled is reported distinctly", func(t *testing.T) {
+		gen := &fakeVideoGenerator{steps: []fakeStatusStep{status("canceled")}}
+
+		_, err := PollUntilComplete(context.Background(), gen, "pred-1", testPollOptions())
+		var genErr *GenerationError
+		if !errors.As(err, &genErr) || genErr.Status != PredictionCanceled {
+			t.Fatalf("expected canceled GenerationError, got %v", err)
+		}
+		if genErr.Message == "" {
+			t.Error("expected fallback message when provider gives no error details")
+		}
+	})
+
+	t.Run("transient status errors are retried", func(t *testing.T) {
+		gen := &fakeVideoGenerator{steps: []fakeStatusStep{
+			{err: errors.New("API error: status 503")},
+			{err: errors.New("connection reset")},
+			status("succeeded"),
+		}}
+
+		if _, err := PollUntilComplete(context.Background(), gen, "pred-1", testPollOptions()); err != nil {
+			t.Fatalf("PollUntilComplete() error = %v", err)
+		}
+		if gen.calls != 3 {
+			t.Errorf("GetStatus calls = %d, want 3", gen.calls)
+		}
+	})
+
+	t.Run("times out with last error", func(t *testing.T) {
+		gen := &fakeVideoGenerator{steps: []fakeStatusStep{{err: errors.New("connection reset")}}}
+		opts := testPollOptions()
+		opts.Timeout = 20 * time.Millisecond
+
+		_, err := PollUntilComplete(context.Background(), gen, "pred-1", opts)
+		if !errors.Is(err, ErrPollTimeout) {
+			t.Fatalf("expected ErrPollTimeout, got %v", err)
+		}
+		var timeout *TimeoutError
+		if !errors.As(err, &timeout) || !IsRetryable(err) {
+			t.Errorf("expected a retryable *TimeoutError, got %v", err)
+		}
+	})
+
+	t.Run("context cancellation stops polling", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		gen := &fakeVideoGenerator{onCall: func(call int) {
+			if call == 1 {
+				cancel()
+			}
+		}}
+
+		_, err := PollUntilComplete(ctx, gen, "pred-1", testPollOptions())
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if gen.calls != 2 {
+			t.Errorf("GetStatus calls = %d, want 2", gen.calls)
+		}
+	})
+}
+
+type fakeMusicChecker struct {
+	statuses []string
+	calls    int
+}
+
+func (f *fakeMusicChecker) GetStatus(ctx context.Context, predictionID string) (*MusicGenerationResult, error) {
+	s := f.statuses[min(f.calls, len(f.statuses)-1)]
+	f.calls++
+	result := &MusicGenerationResult{PredictionID: predictionID, Status: s}
+	if s == "succeeded" {
+		result.AudioURL = "https://example.com/music.mp3"
+	}
+	return result, nil
+}
+
+func TestPollMusicUntilComplete(t *testing.T) {
+	checker := &fakeMusicChecker{statuses: []string{"starting", "processing", "succeeded"}}
+
+	result, err := PollMusicUntilComplete(context.Background(), checker, "pred-2", testPollOptions())
+	if err != nil {
+		t.Fatalf("PollMusicUntilComplete() error = %v", err)
+	}
+	if result.AudioURL != "https://example.com/music.mp3" {
+		t.Errorf("AudioURL = %q, want music URL", result.AudioURL)
+	}
+}
+
+// recordingCanceler records the predictions it was asked to cancel
+type recordingCanceler struct {
+	canceled []string
+}
+
+func (r *recordingCanceler) CancelPrediction(ctx context.Context, predictionID string) error {
+	r.canceled = append(r.canceled, predictionID)
+	return nil
+}
+
+func TestPollCancelsAbandonedPrediction(t *testing.T) {
+	canceler := &recordingCanceler{}
+	opts := testPollOptions()
+	opts.Canceler = canceler
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := pollPrediction(ctx, "pred-1", opts, func(ctx context.Context) (string, string, error) {
+		cancel() // The job was canceled while the prediction was running
+		return "processing", "", nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(canceler.canceled) != 1 || canceler.canceled[0] != "pred-1" {
+		t.Errorf("canceled = %v, want [pred-1]", canceler.canceled)
+	}
+
+	// Finished predictions and the poll's own timeout leave the prediction alone
+	canceler.canceled = nil
+	if err := pollPrediction(context.Background(), "pred-2", opts, func(ctx context.Context) (string, string, error) {
+		return "succeeded", "", nil
+	}); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	opts.Timeout = 5 * time.Millisecond
+	if err := pollPrediction(context.Background(), "pred-3", opts, func(ctx context.Context) (string, string, error) {
+		return "processing", "", nil
+	}); !errors.Is(err, ErrPollTimeout) {
+		t.Fatalf("err = %v, want ErrPollTimeout", err)
+	}
+	if len(canceler.canceled) != 0 {
+		t.Errorf("canceled = %v, want none", canceler.canceled)
+	}
+}
+
+// observation is one measurement a fakeRecorder received
+type observation struct {
+	name  string
+	value float64
+	dims  metrics.Dimensions
+}
+
+// fakeRecorder keeps every measurement it receives
+type fakeRecorder struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (r *fakeRecorder) record(name string, value float64, dims metrics.Dimensions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, observation{name: name, value: value, dims: dims})
+}
+
+func (r *fakeRecorder) Counter(name string, delta float64, dims metrics.Dimensions) {
+	r.record(name, delta, dims)
+}
+
+func (r *fakeRecorder) Histogram(name string, value float64, _ metrics.Unit, dims metrics.Dimensions) {
+	r.record(name, value, dims)
+}
+
+func (r *fakeRecorder) Gauge(name string, value float64, _ metrics.Unit, dims metrics.Dimensions) {
+	r.record(name, value, dims)
+}
+
+func TestPollRecordsPredictionMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []fakeStatusStep
+		timeout time.Duration
+		outcome string
+		polls   float64
+	}{
+		{name: "succeeded", steps: []fakeStatusStep{status("starting"), status("processing"), status("succeeded")}, outcome: "succeeded", polls: 3},
+		{name: "failed", steps: []fakeStatusStep{status("failed")}, outcome: "failed", polls: 1},
+		{name: "timed out", timeout: 5 * time.Millisecond, outcome: "timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &fakeRecorder{}
+			opts := testPollOptions()
+			if tt.timeout > 0 {
+				opts.Timeout = tt.timeout
+			}
+			PollUntilComplete(metrics.WithRecorder(context.Background(), rec), &fakeVideoGenerator{steps: tt.steps}, "pred-1", opts)
+
+			if len(rec.observations) != 2 {
+				t.Fatalf("got %d observations, want latency and poll count", len(rec.observations))
+			}
+			latency, polls := rec.observations[0], rec.observations[1]
+			if latency.name != metrics.PredictionLatency || polls.name != metrics.PredictionPolls {
+				t.Errorf("metric names = %q, %q", latency.name, polls.name)
+			}
+			want := metrics.Dimensions{"adapter": "test", "outcome": tt.outcome}
+			for _, o := range rec.observations {
+				if len(o.dims) != len(want) || o.dims["adapter"] != want["adapter"] || o.dims["outcome"] != want["outcome"] {
+					t.Errorf("%s dims = %v, want %v", o.name, o.dims, want)
+				}
+			}
+			if tt.polls > 0 && polls.value != tt.polls {
+				t.Errorf("polls = %v, want %v", polls.value, tt.polls)
+			}
+		})
+	}
+}
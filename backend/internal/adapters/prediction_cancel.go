@@ -0,0 +1,78 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/pkg/retry"
+	"go.uber.org/zap"
+)
+
+// cancelPredictionTimeout bounds the cancel call made after the caller stopped waiting
+const cancelPredictionTimeout = 10 * time.Second
+
+// PredictionCanceler is implemented by adapters whose predictions can be stopped early,
+// so abandoned work isn't run (and billed) to completion
+type PredictionCanceler interface {
+	CancelPrediction(ctx context.Context, predictionID string) error
+}
+
+// cancelReplicatePrediction POSTs to a prediction's cancel URL. Canceling a prediction that
+// already finished is a no-op on Replicate's side.
+func cancelReplicatePrediction(ctx context.Context, client *http.Client, url string, tokens TokenSource) error {
+	return retry.Do(ctx, retry.APIConfig(), func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+		token, err := authorize(ctx, httpReq, tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			// Network errors are retryable
+			return fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode == http.StatusUnauthorized {
+				return unauthorizedError(ctx, tokens, token, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
+			}
+			// 4xx errors are non-retryable
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body)))
+			}
+			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		}
+		return nil
+	})
+}
+
+// cancelAbandonedPrediction stops a prediction nobody is waiting for anymore. It runs after
+// the caller's context is done, so it uses a fresh one carrying the same IDs.
+func cancelAbandonedPrediction(parent context.Context, canceler PredictionCanceler, predictionID string, opts PollOptions, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(trace.Detach(parent), cancelPredictionTimeout)
+	defer cancel()
+
+	if err := canceler.CancelPrediction(ctx, predictionID); err != nil {
+		logger.Warn("Failed to cancel abandoned prediction",
+			zap.String("label", opts.Label),
+			zap.String("prediction_id", predictionID),
+			zap.Error(err),
+		)
+		return
+	}
+	logger.Info("Canceled abandoned prediction",
+		zap.String("label", opts.Label),
+		zap.String("prediction_id", predictionID),
+	)
+}
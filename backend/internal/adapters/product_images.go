@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+// RejectedImageAssignment records a scene whose assigned_image_ref was dropped
+type RejectedImageAssignment struct {
+	SceneNumber int
+	Ref         string
+	Reason      string
+}
+
+// ResolveImageAssignments checks each scene's assigned_image_ref against the images offered to
+// GPT-4o. Refs are matched case-insensitively and normalized; a ref to an image that wasn't
+// offered, or to one an earlier scene already uses, is cleared so the scene falls back to the
+// continuity frame. It returns the assignments it dropped.
+func ResolveImageAssignments(script *domain.Script, images []prompts.ProductImage) []RejectedImageAssignment {
+	known := make(map[string]string, len(images))
+	for _, image := range images {
+		known[strings.ToLower(image.Ref)] = image.Ref
+	}
+	usedBy := make(map[string]int)
+
+	var rejected []RejectedImageAssignment
+	for i := range script.Scenes {
+		scene := &script.Scenes[i]
+		ref := strings.TrimSpace(scene.AssignedImageRef)
+		if ref == "" {
+			scene.AssignedImageRef = ""
+			continue
+		}
+
+		canonical, ok := known[strings.ToLower(ref)]
+		switch {
+		case !ok:
+			rejected = append(rejected, RejectedImageAssignment{SceneNumber: scene.SceneNumber, Ref: ref, Reason: "no such image"})
+			scene.AssignedImageRef = ""
+		case usedBy[canonical] != 0:
+			rejected = append(rejected, RejectedImageAssignment{
+				SceneNumber: scene.SceneNumber,
+				Ref:         ref,
+				Reason:      fmt.Sprintf("already assigned to scene %d", usedBy[canonical]),
+			})
+			scene.AssignedImageRef = ""
+		default:
+			usedBy[canonical] = scene.SceneNumber
+			scene.AssignedImageRef = canonical
+		}
+	}
+	return rejected
+}
@@ -0,0 +1,52 @@
+package adapters
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestResolveImageAssignments(t *testing.T) {
+	// As returned by GPT-4o: one valid ref, one in the wrong case, one invented and one reused
+	scriptJSON := `{"scenes": [
+		{"scene_number": 1, "assigned_image_ref": "image_2"},
+		{"scene_number": 2},
+		{"scene_number": 3, "assigned_image_ref": " IMAGE_1 "},
+		{"scene_number": 4, "assigned_image_ref": "image_7"},
+		{"scene_number": 5, "assigned_image_ref": "image_2"}
+	]}`
+	var script domain.Script
+	if err := json.Unmarshal([]byte(scriptJSON), &script); err != nil {
+		t.Fatalf("failed to parse script: %v", err)
+	}
+
+	images := []prompts.ProductImage{{Ref: "image_1"}, {Ref: "image_2"}}
+	rejected := ResolveImageAssignments(&script, images)
+
+	var refs []string
+	for _, scene := range script.Scenes {
+		refs = append(refs, scene.AssignedImageRef)
+	}
+	if want := []string{"image_2", "", "image_1", "", ""}; !reflect.DeepEqual(refs, want) {
+		t.Fatalf("unexpected assignments %q, want %q", refs, want)
+	}
+
+	want := []RejectedImageAssignment{
+		{SceneNumber: 4, Ref: "image_7", Reason: "no such image"},
+		{SceneNumber: 5, Ref: "image_2", Reason: "already assigned to scene 1"},
+	}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Fatalf("unexpected rejections %+v, want %+v", rejected, want)
+	}
+}
+
+func TestResolveImageAssignmentsWithoutImages(t *testing.T) {
+	script := &domain.Script{Scenes: []domain.Scene{{SceneNumber: 1, AssignedImageRef: "image_1"}}}
+	rejected := ResolveImageAssignments(script, nil)
+	if len(rejected) != 1 || script.Scenes[0].AssignedImageRef != "" {
+		t.Fatalf("expected the ref dropped when no images were offered, got %+v (%q)", rejected, script.Scenes[0].AssignedImageRef)
+	}
+}
@@ -0,0 +1,73 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// replicateProvider names Replicate in provenance entries
+const replicateProvider = "replicate"
+
+// ProvenanceRecorder is called with each provider call an adapter finishes. The adapter does
+// not know the pipeline step, so entry.Step is left for the recorder to fill in.
+type ProvenanceRecorder func(entry domain.ProvenanceEntry)
+
+type provenanceKey struct{}
+
+// WithProvenance returns a context whose adapter calls are reported to record
+func WithProvenance(ctx context.Context, record ProvenanceRecorder) context.Context {
+	return context.WithValue(ctx, provenanceKey{}, record)
+}
+
+// WithProvenanceSeed returns a context whose recorded adapter calls carry the seed they were
+// submitted with, so a clip can be regenerated from it. Without a recorder it returns ctx.
+func WithProvenanceSeed(ctx context.Context, seed int64) context.Context {
+	record, ok := ctx.Value(provenanceKey{}).(ProvenanceRecorder)
+	if !ok {
+		return ctx
+	}
+	return WithProvenance(ctx, func(entry domain.ProvenanceEntry) {
+		entry.Seed = &seed
+		record(entry)
+	})
+}
+
+// modelVersionSource is implemented by adapters that can name the model version they submit
+// predictions made on ctx with
+type modelVersionSource interface {
+	ModelVersion(ctx context.Context) string
+}
+
+// recordProviderCall reports a finished provider call to ctx's recorder, if it has one, and
+// counts it in ctx's CallStats. err is the call's outcome: a *GenerationError carries the
+// provider's own error message.
+func recordProviderCall(ctx context.Context, provider, model, predictionID string, submitted time.Time, err error) {
+	countProviderCall(ctx, submitted)
+	record, ok := ctx.Value(provenanceKey{}).(ProvenanceRecorder)
+	if !ok {
+		return
+	}
+
+	entry := domain.ProvenanceEntry{
+		Provider:     provider,
+		Model:        model,
+		PredictionID: predictionID,
+		SubmittedAt:  submitted.Unix(),
+		CompletedAt:  time.Now().Unix(),
+		Status:       predictionOutcome(err),
+	}
+	var genErr *GenerationError
+	switch {
+	case errors.As(err, &genErr):
+		entry.Error = genErr.Message
+	case err != nil:
+		entry.Error = err.Error()
+		if ctx.Err() == nil && !errors.Is(err, ErrPollTimeout) {
+			entry.Status = string(PredictionFailed) // A synchronous call the provider rejected
+		}
+	}
+	record(entry)
+}
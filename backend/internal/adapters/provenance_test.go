@@ -0,0 +1,147 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// provenanceLog collects the entries adapters record on its context
+type provenanceLog struct {
+	entries []domain.ProvenanceEntry
+}
+
+func (l *provenanceLog) context() context.Context {
+	return WithProvenance(context.Background(), func(entry domain.ProvenanceEntry) {
+		l.entries = append(l.entries, entry)
+	})
+}
+
+// versionedVideoGenerator reports a model version like the Replicate adapters do
+type versionedVideoGenerator struct {
+	*fakeVideoGenerator
+}
+
+func (versionedVideoGenerator) ModelVersion(context.Context) string {
+	return "google/veo-3.1:abc123"
+}
+
+func TestPollRecordsProvenance(t *testing.T) {
+	failed := fakeStatusStep{result: &VideoGenerationResult{PredictionID: "pred-1", Status: "failed", Error: "CUDA out of memory"}}
+	tests := []struct {
+		name    string
+		steps   []fakeStatusStep
+		timeout time.Duration
+		status  string
+		errMsg  string
+	}{
+		{name: "succeeded", steps: []fakeStatusStep{status("processing"), status("succeeded")}, status: "succeeded"},
+		{name: "failed", steps: []fakeStatusStep{failed}, status: "failed", errMsg: "CUDA out of memory"},
+		{name: "timed out", timeout: 5 * time.Millisecond, status: "timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &provenanceLog{}
+			opts := testPollOptions()
+			if tt.timeout > 0 {
+				opts.Timeout = tt.timeout
+			}
+			before := time.Now().Unix()
+			generator := versionedVideoGenerator{&fakeVideoGenerator{steps: tt.steps}}
+			PollUntilComplete(log.context(), generator, "pred-1", opts)
+
+			if len(log.entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(log.entries))
+			}
+			entry := log.entries[0]
+			if entry.Provider != "replicate" || entry.Model != "google/veo-3.1:abc123" || entry.PredictionID != "pred-1" {
+				t.Errorf("entry = %+v", entry)
+			}
+			if entry.Status != tt.status {
+				t.Errorf("status = %q, want %q", entry.Status, tt.status)
+			}
+			if tt.errMsg != "" && entry.Error != tt.errMsg {
+				t.Errorf("error = %q, want the provider's message %q", entry.Error, tt.errMsg)
+			}
+			if tt.status == "succeeded" && entry.Error != "" {
+				t.Errorf("error = %q, want none", entry.Error)
+			}
+			if entry.SubmittedAt < before || entry.CompletedAt < entry.SubmittedAt {
+				t.Errorf("submitted_at = %d, completed_at = %d", entry.SubmittedAt, entry.CompletedAt)
+			}
+		})
+	}
+}
+
+func TestPollWithoutRecorderRecordsNothing(t *testing.T) {
+	// No recorder on the context is the common case outside the job pipeline
+	if _, err := PollUntilComplete(context.Background(), &fakeVideoGenerator{steps: []fakeStatusStep{status("succeeded")}}, "pred-1", testPollOptions()); err != nil {
+		t.Fatalf("PollUntilComplete() error = %v", err)
+	}
+}
+
+func TestElevenLabsTTSRecordsProvenance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/text-to-speech/femaleVoiceId12345/stream" {
+			w.Write(fakeMP3)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"detail": {"status": "invalid_api_key", "message": "Invalid API key"}}`))
+	}))
+	defer server.Close()
+
+	adapter := newTestElevenLabsAdapter(server.URL)
+	log := &provenanceLog{}
+	ctx := log.context()
+
+	if _, err := adapter.GenerateVoiceover(ctx, "Hello", "female"); err != nil {
+		t.Fatalf("GenerateVoiceover() error = %v", err)
+	}
+	if _, err := adapter.GenerateVoiceover(ctx, "Hello", "customVoiceAbc123XYZ"); err == nil {
+		t.Fatal("expected error for 401")
+	}
+	// Rejected before any request is made, so there is no provider call to record
+	if _, err := adapter.GenerateVoiceover(ctx, "Hello", "robot"); err == nil {
+		t.Fatal("expected invalid voice error")
+	}
+
+	if len(log.entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(log.entries))
+	}
+	ok, rejected := log.entries[0], log.entries[1]
+	if ok.Provider != "elevenlabs" || ok.Model != "eleven_multilingual_v2" || ok.Status != "succeeded" || ok.Error != "" {
+		t.Errorf("success entry = %+v", ok)
+	}
+	if rejected.Status != "failed" || rejected.Error == "" {
+		t.Errorf("failure entry = %+v", rejected)
+	}
+}
+
+func TestWithProvenanceSeed(t *testing.T) {
+	log := &provenanceLog{}
+	ctx := WithProvenanceSeed(log.context(), 4242)
+
+	recordProviderCall(ctx, replicateProvider, "google/veo-3.1", "pred-1", time.Now(), nil)
+	recordProviderCall(log.context(), replicateProvider, "google/veo-3.1", "pred-2", time.Now(), nil)
+
+	if len(log.entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(log.entries))
+	}
+	if log.entries[0].Seed == nil || *log.entries[0].Seed != 4242 {
+		t.Errorf("seed = %v, want 4242", log.entries[0].Seed)
+	}
+	if log.entries[1].Seed != nil {
+		t.Errorf("seed = %d on a call made without one", *log.entries[1].Seed)
+	}
+
+	// Without a recorder there is nothing to carry the seed
+	if ctx := WithProvenanceSeed(context.Background(), 1); ctx != context.Background() {
+		t.Error("WithProvenanceSeed() wrapped a context without a recorder")
+	}
+}
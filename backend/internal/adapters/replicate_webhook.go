@@ -0,0 +1,364 @@
+package adapters
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultSafetyPollInterval is how often predictions are still polled when webhooks are on,
+// in case a webhook is lost
+const DefaultSafetyPollInterval = 60 * time.Second
+
+// webhookEventTTL is how long a routed prediction is remembered, both to hand its event to a
+// waiter that subscribes after the webhook arrived and to drop Replicate's redeliveries
+const webhookEventTTL = 30 * time.Minute
+
+// replicateWebhookTolerance is the allowed clock skew for a webhook's signed timestamp
+const replicateWebhookTolerance = 5 * time.Minute
+
+// ErrInvalidWebhookSignature is returned for webhooks that aren't signed with the account's secret
+var ErrInvalidWebhookSignature = errors.New("invalid replicate webhook signature")
+
+// ReplicateWebhookFields are the prediction request fields that ask Replicate to POST the
+// finished prediction to us. Embedded in each adapter's request body; empty when webhooks are off.
+type ReplicateWebhookFields struct {
+	Webhook             string   `json:"webhook,omitempty"`
+	WebhookEventsFilter []string `json:"webhook_events_filter,omitempty"`
+}
+
+// PredictionEvent is a terminal prediction state reported by a webhook
+type PredictionEvent struct {
+	ID     string
+	Status PredictionStatus
+	Error  string
+}
+
+// PredictionEventStore shares webhook events between servers. Replicate's webhooks reach
+// whichever server the load balancer picks, which often isn't the one waiting on the prediction.
+type PredictionEventStore interface {
+	// SavePredictionEvent records a terminal event, reporting false if it was already recorded
+	SavePredictionEvent(ctx context.Context, event PredictionEvent) (bool, error)
+	// GetPredictionEvent returns the prediction's recorded event, or nil if none has arrived
+	GetPredictionEvent(ctx context.Context, predictionID string) (*PredictionEvent, error)
+}
+
+// webhookEntry tracks a routed prediction until it expires
+type webhookEntry struct {
+	event     PredictionEvent
+	claimed   bool // Handed to a waiter
+	expiresAt time.Time
+}
+
+// ReplicateWebhooks routes Replicate's prediction webhooks to the goroutines waiting on them,
+// keyed by prediction ID. A nil *ReplicateWebhooks disables webhooks and adapters poll only.
+//
+// With a store, webhooks received by any server are recorded there and waiters check it at
+// their regular poll interval, only asking Replicate itself every safety interval. Without
+// one, a webhook only reaches waiters on the server that received it, so predictions are still
+// polled at their regular interval and webhooks just cut the wait short when they land here.
+type ReplicateWebhooks struct {
+	url            string
+	safetyInterval time.Duration
+	store          PredictionEventStore // nil keeps events in this server
+	logger         *zap.Logger
+	now            func() time.Time
+
+	mu      sync.Mutex
+	waiters map[string]chan PredictionEvent
+	routed  map[string]*webhookEntry
+}
+
+// NewReplicateWebhooks creates a dispatcher for webhooks delivered to url, sharing them with
+// other servers through store when it is non-nil
+func NewReplicateWebhooks(url string, safetyPollInterval time.Duration, store PredictionEventStore, logger *zap.Logger) *ReplicateWebhooks {
+	if safetyPollInterval <= 0 {
+		safetyPollInterval = DefaultSafetyPollInterval
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &ReplicateWebhooks{
+		url:            url,
+		safetyInterval: safetyPollInterval,
+		store:          store,
+		logger:         logger,
+		now:            time.Now,
+		waiters:        make(map[string]chan PredictionEvent),
+		routed:         make(map[string]*webhookEntry),
+	}
+}
+
+// requestFields returns the webhook fields for a new prediction
+func (w *ReplicateWebhooks) requestFields() ReplicateWebhookFields {
+	if w == nil {
+		return ReplicateWebhookFields{}
+	}
+	return ReplicateWebhookFields{
+		Webhook:             w.url,
+		WebhookEventsFilter: []string{"completed"}, // Only terminal states: succeeded, failed or canceled
+	}
+}
+
+// pollInterval is how often Replicate is asked about a prediction, normally polled every
+// interval, while its webhook is awaited. Only a shared store lets a webhook reach us wherever
+// it was delivered, so without one the regular interval is kept.
+func (w *ReplicateWebhooks) pollInterval(interval time.Duration) time.Duration {
+	if w.store == nil || w.safetyInterval <= interval {
+		return interval
+	}
+	return w.safetyInterval
+}
+
+// subscribe returns a channel that receives the prediction's terminal event. If the webhook
+// already arrived, the event is waiting on the channel. With a store, it is checked every
+// storeInterval for webhooks other servers received. Call the returned func when done.
+func (w *ReplicateWebhooks) subscribe(ctx context.Context, predictionID string, storeInterval time.Duration) (<-chan PredictionEvent, func()) {
+	events := make(chan PredictionEvent, 1)
+
+	w.mu.Lock()
+	delivered := false
+	if entry, ok := w.routed[predictionID]; ok && !entry.claimed {
+		entry.claimed = true
+		events <- entry.event
+		delivered = true
+	}
+	w.waiters[predictionID] = events
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	if w.store != nil && !delivered {
+		go w.watchStore(ctx, predictionID, storeInterval, events, done)
+	}
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() { close(done) })
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.waiters[predictionID] == events {
+			delete(w.waiters, predictionID)
+		}
+	}
+}
+
+// watchStore checks the store for the prediction's event every interval until it is found or
+// the waiter is done, handing it to the waiter unless a local webhook got there first
+func (w *ReplicateWebhooks) watchStore(ctx context.Context, predictionID string, interval time.Duration, events chan PredictionEvent, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		event, err := w.store.GetPredictionEvent(ctx, predictionID)
+		if err != nil {
+			// The safety poll still finds the prediction's outcome
+			w.logger.Warn("Failed to check stored prediction event",
+				zap.String("prediction_id", predictionID),
+				zap.Error(err),
+			)
+			continue
+		}
+		if event == nil {
+			continue
+		}
+
+		w.mu.Lock()
+		if entry, ok := w.routed[predictionID]; !ok || !entry.claimed {
+			w.routed[predictionID] = &webhookEntry{event: *event, claimed: true, expiresAt: w.now().Add(webhookEventTTL)}
+			events <- *event
+		}
+		w.mu.Unlock()
+		return
+	}
+}
+
+// Dispatch routes a webhook event to the goroutine waiting on its prediction, recording it in
+// the store for waiters on other servers, and reports whether the event was new. Non-terminal
+// events and redeliveries are ignored. An error means the event couldn't be recorded and the
+// webhook should be redelivered.
+func (w *ReplicateWebhooks) Dispatch(ctx context.Context, event PredictionEvent) (bool, error) {
+	if !event.Status.IsTerminal() {
+		return false, nil
+	}
+
+	fresh := true
+	if w.store != nil {
+		var err error
+		if fresh, err = w.store.SavePredictionEvent(ctx, event); err != nil {
+			return false, fmt.Errorf("failed to record prediction event: %w", err)
+		}
+	}
+	return w.dispatchLocal(event) && fresh, nil
+}
+
+// dispatchLocal hands the event to a waiter on this server, reporting whether it was new here
+func (w *ReplicateWebhooks) dispatchLocal(event PredictionEvent) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	for id, entry := range w.routed {
+		if now.After(entry.expiresAt) {
+			delete(w.routed, id)
+		}
+	}
+
+	if _, seen := w.routed[event.ID]; seen {
+		return false
+	}
+	entry := &webhookEntry{event: event, expiresAt: now.Add(webhookEventTTL)}
+	w.routed[event.ID] = entry
+
+	if events, ok := w.waiters[event.ID]; ok {
+		entry.claimed = true
+		events <- event // Buffered, and only one event is ever sent per prediction
+	}
+	return true
+}
+
+// replicateWebhookSource is implemented by adapters that register Replicate webhooks
+type replicateWebhookSource interface {
+	ReplicateWebhooks() *ReplicateWebhooks
+}
+
+// withAdapterDefaults fills opts.Webhooks, opts.Canceler and opts.Model from the adapter when
+// the caller didn't set them
+func withAdapterDefaults(ctx context.Context, opts PollOptions, adapter interface{}) PollOptions {
+	if opts.Webhooks == nil {
+		if source, ok := adapter.(replicateWebhookSource); ok {
+			opts.Webhooks = source.ReplicateWebhooks()
+		}
+	}
+	if opts.Canceler == nil {
+		if canceler, ok := adapter.(PredictionCanceler); ok {
+			opts.Canceler = canceler
+		}
+	}
+	if opts.Model == "" {
+		if source, ok := adapter.(modelVersionSource); ok {
+			opts.Model = source.ModelVersion(ctx)
+		}
+	}
+	return opts
+}
+
+// ParsePredictionEvent reads the prediction in a webhook body
+func ParsePredictionEvent(body []byte) (PredictionEvent, error) {
+	var prediction struct {
+		ID     string          `json:"id"`
+		Status string          `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &prediction); err != nil {
+		return PredictionEvent{}, fmt.Errorf("invalid prediction: %w", err)
+	}
+	if prediction.ID == "" {
+		return PredictionEvent{}, errors.New("invalid prediction: missing id")
+	}
+
+	// error is usually a string, but may be null or an object
+	var providerErr string
+	if len(prediction.Error) > 0 && string(prediction.Error) != "null" {
+		if err := json.Unmarshal(prediction.Error, &providerErr); err != nil {
+			providerErr = string(prediction.Error)
+		}
+	}
+
+	return PredictionEvent{
+		ID:     prediction.ID,
+		Status: NormalizeStatus(prediction.Status),
+		Error:  providerErr,
+	}, nil
+}
+
+// VerifyReplicateWebhook checks a webhook's signature. Replicate signs
+// "{webhook-id}.{webhook-timestamp}.{body}" with HMAC-SHA256, keyed with the base64 part of
+// the account's "whsec_" secret, and sends one or more "v1,{base64 signature}" values.
+func VerifyReplicateWebhook(secret string, header http.Header, body []byte, now time.Time) error {
+	id := header.Get("webhook-id")
+	timestamp := header.Get("webhook-timestamp")
+	signatures := header.Get("webhook-signature")
+	if id == "" || timestamp == "" || signatures == "" {
+		return fmt.Errorf("%w: missing webhook headers", ErrInvalidWebhookSignature)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrInvalidWebhookSignature)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > replicateWebhookTolerance || skew < -replicateWebhookTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidWebhookSignature)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil || len(key) == 0 {
+		return fmt.Errorf("%w: invalid signing secret", ErrInvalidWebhookSignature)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, signature := range strings.Fields(signatures) {
+		version, encoded, ok := strings.Cut(signature, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+// FetchReplicateWebhookSecret reads the account's webhook signing secret from the Replicate API
+func FetchReplicateWebhookSecret(ctx context.Context, apiToken string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.replicate.com/v1/webhooks/default/secret", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var secret struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil || secret.Key == "" {
+		return "", fmt.Errorf("failed to parse webhook secret response")
+	}
+	return secret.Key, nil
+}
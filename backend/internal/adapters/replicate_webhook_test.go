@@ -0,0 +1,376 @@
+package adapters
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+
+// signWebhook signs a body the way Replicate does
+func signWebhook(secret, id string, timestamp time.Time, body []byte) http.Header {
+	key, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + ts + "."))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set("webhook-id", id)
+	header.Set("webhook-timestamp", ts)
+	header.Set("webhook-signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return header
+}
+
+func TestVerifyReplicateWebhook(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":"pred-1","status":"succeeded"}`)
+
+	tests := []struct {
+		name    string
+		header  func() http.Header
+		body    []byte
+		wantErr bool
+	}{
+		{
+			name:   "valid signature",
+			header: func() http.Header { return signWebhook(testWebhookSecret, "msg-1", now, body) },
+			body:   body,
+		},
+		{
+			name: "one of several signatures matches",
+			header: func() http.Header {
+				h := signWebhook(testWebhookSecret, "msg-1", now, body)
+				h.Set("webhook-signature", "v1,bm90LWl0 "+h.Get("webhook-signature"))
+				return h
+			},
+			body: body,
+		},
+		{
+			name:    "wrong secret",
+			header:  func() http.Header { return signWebhook("whsec_c29tZW9uZS1lbHNlcy1zZWNyZXQ=", "msg-1", now, body) },
+			body:    body,
+			wantErr: true,
+		},
+		{
+			name:    "tampered body",
+			header:  func() http.Header { return signWebhook(testWebhookSecret, "msg-1", now, body) },
+			body:    []byte(`{"id":"pred-1","status":"failed"}`),
+			wantErr: true,
+		},
+		{
+			name:    "stale timestamp",
+			header:  func() http.Header { return signWebhook(testWebhookSecret, "msg-1", now.Add(-10*time.Minute), body) },
+			body:    body,
+			wantErr: true,
+		},
+		{
+			name:    "missing headers",
+			header:  func() http.Header { return http.Header{} },
+			body:    body,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		err := VerifyReplicateWebhook(testWebhookSecret, tt.header(), tt.body, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidWebhookSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidWebhookSignature", tt.name, err)
+		}
+	}
+}
+
+func TestParsePredictionEvent(t *testing.T) {
+	event, err := ParsePredictionEvent([]byte(`{"id":"pred-1","status":"failed","error":"out of memory"}`))
+	if err != nil {
+		t.Fatalf("ParsePredictionEvent: %v", err)
+	}
+	if event.ID != "pred-1" || event.Status != PredictionFailed || event.Error != "out of memory" {
+		t.Errorf("event = %+v", event)
+	}
+
+	event, err = ParsePredictionEvent([]byte(`{"id":"pred-2","status":"succeeded","error":null}`))
+	if err != nil || event.Error != "" || event.Status != PredictionSucceeded {
+		t.Errorf("null error: event = %+v, err = %v", event, err)
+	}
+
+	event, _ = ParsePredictionEvent([]byte(`{"id":"pred-3","status":"failed","error":{"detail":"bad input"}}`))
+	if event.Error != `{"detail":"bad input"}` {
+		t.Errorf("object error = %q", event.Error)
+	}
+
+	if _, err := ParsePredictionEvent([]byte(`{"status":"succeeded"}`)); err == nil {
+		t.Error("expected an error for a prediction without an id")
+	}
+}
+
+// mustDispatch dispatches event, failing the test if it couldn't be recorded
+func mustDispatch(t *testing.T, w *ReplicateWebhooks, event PredictionEvent) bool {
+	t.Helper()
+	fresh, err := w.Dispatch(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	return fresh
+}
+
+// memoryEventStore is a PredictionEventStore shared by the ReplicateWebhooks of several servers
+type memoryEventStore struct {
+	mu     sync.Mutex
+	events map[string]PredictionEvent
+}
+
+func newMemoryEventStore() *memoryEventStore {
+	return &memoryEventStore{events: make(map[string]PredictionEvent)}
+}
+
+func (s *memoryEventStore) SavePredictionEvent(_ context.Context, event PredictionEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.events[event.ID]; ok {
+		return false, nil
+	}
+	s.events[event.ID] = event
+	return true, nil
+}
+
+func (s *memoryEventStore) GetPredictionEvent(_ context.Context, predictionID string) (*PredictionEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event, ok := s.events[predictionID]
+	if !ok {
+		return nil, nil
+	}
+	return &event, nil
+}
+
+func TestReplicateWebhooksDispatch(t *testing.T) {
+	w := NewReplicateWebhooks("https://api.example.com/internal/replicate/webhook", time.Minute, nil, nil)
+	events, unsubscribe := w.subscribe(context.Background(), "pred-1", time.Millisecond)
+	defer unsubscribe()
+
+	if mustDispatch(t, w, PredictionEvent{ID: "pred-1", Status: PredictionProcessing}) {
+		t.Error("non-terminal events should be ignored")
+	}
+	if !mustDispatch(t, w, PredictionEvent{ID: "pred-1", Status: PredictionSucceeded}) {
+		t.Fatal("first terminal event should be dispatched")
+	}
+
+	select {
+	case event := <-events:
+		if event.Status != PredictionSucceeded {
+			t.Errorf("status = %s, want succeeded", event.Status)
+		}
+	default:
+		t.Fatal("waiter did not receive the event")
+	}
+}
+
+func TestReplicateWebhooksDuplicateDelivery(t *testing.T) {
+	w := NewReplicateWebhooks("https://api.example.com/hook", time.Minute, nil, nil)
+	events, unsubscribe := w.subscribe(context.Background(), "pred-1", time.Millisecond)
+	defer unsubscribe()
+
+	event := PredictionEvent{ID: "pred-1", Status: PredictionSucceeded}
+	if !mustDispatch(t, w, event) {
+		t.Fatal("first delivery should be dispatched")
+	}
+	if mustDispatch(t, w, event) {
+		t.Error("redelivery should be ignored")
+	}
+
+	<-events
+	select {
+	case <-events:
+		t.Error("waiter received the event twice")
+	default:
+	}
+}
+
+func TestReplicateWebhooksEarlyEvent(t *testing.T) {
+	w := NewReplicateWebhooks("https://api.example.com/hook", time.Minute, nil, nil)
+
+	// The webhook can beat the waiter when the prediction finishes right after creation
+	mustDispatch(t, w, PredictionEvent{ID: "pred-1", Status: PredictionFailed, Error: "nsfw"})
+
+	events, unsubscribe := w.subscribe(context.Background(), "pred-1", time.Millisecond)
+	defer unsubscribe()
+	select {
+	case event := <-events:
+		if event.Error != "nsfw" {
+			t.Errorf("error = %q, want nsfw", event.Error)
+		}
+	default:
+		t.Fatal("early event was not handed to the waiter")
+	}
+}
+
+func TestReplicateWebhooksExpire(t *testing.T) {
+	clock := time.Unix(1_700_000_000, 0)
+	w := NewReplicateWebhooks("https://api.example.com/hook", time.Minute, nil, nil)
+	w.now = func() time.Time { return clock }
+
+	mustDispatch(t, w, PredictionEvent{ID: "pred-1", Status: PredictionSucceeded})
+	clock = clock.Add(webhookEventTTL + time.Second)
+	mustDispatch(t, w, PredictionEvent{ID: "pred-2", Status: PredictionSucceeded})
+
+	if _, ok := w.routed["pred-1"]; ok {
+		t.Error("expired prediction should have been pruned")
+	}
+	if len(w.routed) != 1 {
+		t.Errorf("routed = %d entries, want 1", len(w.routed))
+	}
+}
+
+func TestReplicateWebhookRequestFields(t *testing.T) {
+	w := NewReplicateWebhooks("https://api.example.com/internal/replicate/webhook", time.Minute, nil, nil)
+
+	payload, _ := json.Marshal(VeoRequest{Version: "v", Input: map[string]interface{}{}, ReplicateWebhookFields: w.requestFields()})
+	var got map[string]interface{}
+	json.Unmarshal(payload, &got)
+	if got["webhook"] != "https://api.example.com/internal/replicate/webhook" {
+		t.Errorf("webhook = %v", got["webhook"])
+	}
+	if filter, _ := got["webhook_events_filter"].([]interface{}); len(filter) != 1 || filter[0] != "completed" {
+		t.Errorf("webhook_events_filter = %v, want [completed]", got["webhook_events_filter"])
+	}
+
+	// Without webhooks the request is unchanged
+	var disabled *ReplicateWebhooks
+	payload, _ = json.Marshal(VeoRequest{Version: "v", Input: map[string]interface{}{}, ReplicateWebhookFields: disabled.requestFields()})
+	if strings.Contains(string(payload), "webhook") {
+		t.Errorf("payload = %s, want no webhook fields", payload)
+	}
+}
+
+func TestPollWokenByWebhook(t *testing.T) {
+	// A long safety interval: only the webhook can finish this poll within the test timeout
+	w := NewReplicateWebhooks("https://api.example.com/hook", time.Hour, nil, nil)
+	opts := testPollOptions()
+	opts.Webhooks = w
+
+	var calls int32
+	err := pollPrediction(context.Background(), "pred-1", opts, func(ctx context.Context) (string, string, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			mustDispatch(t, w, PredictionEvent{ID: "pred-1", Status: PredictionSucceeded})
+			return "processing", "", nil
+		}
+		return "succeeded", "", nil
+	})
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("status checks = %d, want 2", calls)
+	}
+}
+
+func TestPollSafetyIntervalWhenWebhookLost(t *testing.T) {
+	w := NewReplicateWebhooks("https://api.example.com/hook", 20*time.Millisecond, newMemoryEventStore(), nil)
+	opts := testPollOptions()
+	opts.Webhooks = w
+
+	var calls int32
+	start := time.Now()
+	err := pollPrediction(context.Background(), "pred-1", opts, func(ctx context.Context) (string, string, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return "processing", "", nil
+		}
+		return "succeeded", "", nil
+	})
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("elapsed = %v, want polling at the 20ms safety interval", elapsed)
+	}
+}
+
+func TestPollFallsBackWithoutWebhooks(t *testing.T) {
+	veo := NewVeoAdapter("token", "", nil)
+	if opts := withAdapterDefaults(context.Background(), testPollOptions(), veo); opts.Webhooks != nil {
+		t.Error("adapter without webhooks should poll only")
+	}
+
+	w := NewReplicateWebhooks("https://api.example.com/hook", time.Minute, nil, nil)
+	veo.SetReplicateWebhooks(w)
+	if opts := withAdapterDefaults(context.Background(), testPollOptions(), veo); opts.Webhooks != w {
+		t.Error("adapter webhooks should be used by default")
+	}
+
+	// Generators that don't register webhooks keep polling at Interval
+	gen := &fakeVideoGenerator{steps: []fakeStatusStep{status("processing"), status("succeeded")}}
+	if _, err := PollUntilComplete(context.Background(), gen, "pred-1", testPollOptions()); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if gen.calls != 2 {
+		t.Errorf("status checks = %d, want 2", gen.calls)
+	}
+}
+
+func TestReplicateWebhooksAcrossServers(t *testing.T) {
+	store := newMemoryEventStore()
+	receiver := NewReplicateWebhooks("https://api.example.com/hook", time.Hour, store, nil)
+	waiter := NewReplicateWebhooks("https://api.example.com/hook", time.Hour, store, nil)
+
+	events, unsubscribe := waiter.subscribe(context.Background(), "pred-1", time.Millisecond)
+	defer unsubscribe()
+
+	// The load balancer delivered the webhook to the other server
+	if !mustDispatch(t, receiver, PredictionEvent{ID: "pred-1", Status: PredictionSucceeded}) {
+		t.Fatal("first delivery should be dispatched")
+	}
+	if mustDispatch(t, waiter, PredictionEvent{ID: "pred-1", Status: PredictionSucceeded}) {
+		t.Error("redelivery to another server should be ignored")
+	}
+
+	select {
+	case event := <-events:
+		if event.Status != PredictionSucceeded {
+			t.Errorf("status = %s, want succeeded", event.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter did not receive the event from the store")
+	}
+	select {
+	case <-events:
+		t.Error("waiter received the event twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestPollKeepsIntervalWithoutStore(t *testing.T) {
+	// Without a store, a webhook delivered to another server never reaches this one, so the
+	// long safety interval must not slow polling down
+	w := NewReplicateWebhooks("https://api.example.com/hook", time.Hour, nil, nil)
+	opts := testPollOptions()
+	opts.Webhooks = w
+
+	var calls int32
+	err := pollPrediction(context.Background(), "pred-1", opts, func(ctx context.Context) (string, string, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return "processing", "", nil
+		}
+		return "succeeded", "", nil
+	})
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("status checks = %d, want 3", calls)
+	}
+}
@@ -0,0 +1,135 @@
+package adapters
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// sceneDurationTolerance is how far (seconds) the normalized total may drift from the request
+const sceneDurationTolerance = 1.0
+
+// DurationAdjustment records a scene whose duration was changed during normalization
+type DurationAdjustment struct {
+	SceneNumber int
+	Original    float64
+	Normalized  float64
+}
+
+// NormalizeSceneDurations snaps every scene to a clip length the model supports,
+// rebalances the scenes so the total stays within ±1s of the requested duration,
+// and recomputes start times and total_duration. It returns the scenes it changed.
+func NormalizeSceneDurations(script *domain.Script, requestedDuration int, model AdapterType) ([]DurationAdjustment, error) {
+	allowed := append([]int(nil), ClipDurations(model)...)
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("no clip durations known for video model %q", model)
+	}
+	if len(script.Scenes) == 0 {
+		return nil, fmt.Errorf("script has no scenes")
+	}
+	sort.Ints(allowed)
+
+	original := make([]float64, len(script.Scenes))
+	durations := make([]int, len(script.Scenes))
+	for i, scene := range script.Scenes {
+		original[i] = scene.Duration
+		durations[i] = nearestClipDuration(allowed, scene.Duration)
+	}
+
+	rebalanceDurations(durations, original, allowed, requestedDuration)
+
+	total := 0
+	for _, d := range durations {
+		total += d
+	}
+	if math.Abs(float64(total-requestedDuration)) > sceneDurationTolerance {
+		return nil, fmt.Errorf("cannot fit %d scenes into %ds using %v second clips (closest total %ds)",
+			len(durations), requestedDuration, allowed, total)
+	}
+
+	var adjustments []DurationAdjustment
+	var startTime float64
+	for i := range script.Scenes {
+		normalized := float64(durations[i])
+		if normalized != original[i] {
+			adjustments = append(adjustments, DurationAdjustment{
+				SceneNumber: i + 1,
+				Original:    original[i],
+				Normalized:  normalized,
+			})
+		}
+		script.Scenes[i].Duration = normalized
+		script.Scenes[i].StartTime = startTime
+		startTime += normalized
+	}
+	script.TotalDuration = total
+
+	return adjustments, nil
+}
+
+// nearestClipDuration snaps seconds to the closest allowed value (ties round up)
+func nearestClipDuration(allowed []int, seconds float64) int {
+	best := allowed[0]
+	for _, d := range allowed[1:] {
+		if math.Abs(float64(d)-seconds) <= math.Abs(float64(best)-seconds) {
+			best = d
+		}
+	}
+	return best
+}
+
+// rebalanceDurations greedily moves scenes one allowed step up or down until the
+// total can no longer get closer to target. Scenes whose original duration was
+// rounded the opposite way are adjusted first, keeping changes minimal.
+func rebalanceDurations(durations []int, original []float64, allowed []int, target int) {
+	for {
+		total := 0
+		for _, d := range durations {
+			total += d
+		}
+		diff := target - total
+		if diff == 0 {
+			return
+		}
+
+		bestScene, bestValue := -1, 0
+		bestRemaining := math.Abs(float64(diff))
+		bestBias := math.Inf(-1)
+
+		for i, d := range durations {
+			next, ok := stepClipDuration(allowed, d, diff > 0)
+			if !ok {
+				continue
+			}
+			remaining := math.Abs(float64(diff - (next - d)))
+			// bias > 0 when the move drifts back toward what the script asked for
+			bias := math.Abs(float64(d)-original[i]) - math.Abs(float64(next)-original[i])
+			if remaining < bestRemaining || (remaining == bestRemaining && bestScene != -1 && bias > bestBias) {
+				bestScene, bestValue = i, next
+				bestRemaining, bestBias = remaining, bias
+			}
+		}
+
+		if bestScene == -1 {
+			return
+		}
+		durations[bestScene] = bestValue
+	}
+}
+
+// stepClipDuration returns the next allowed duration above (up) or below current
+func stepClipDuration(allowed []int, current int, up bool) (int, bool) {
+	idx := sort.SearchInts(allowed, current)
+	if up {
+		if idx+1 < len(allowed) {
+			return allowed[idx+1], true
+		}
+		return 0, false
+	}
+	if idx > 0 {
+		return allowed[idx-1], true
+	}
+	return 0, false
+}
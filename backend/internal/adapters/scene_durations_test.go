@@ -0,0 +1,88 @@
+package adapters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func scriptWithDurations(durations ...float64) *domain.Script {
+	script := &domain.Script{Title: "Test Ad"}
+	for i, d := range durations {
+		script.Scenes = append(script.Scenes, domain.Scene{SceneNumber: i + 1, Duration: d})
+	}
+	return script
+}
+
+func TestNormalizeSceneDurations(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     AdapterType
+		target    int
+		durations []float64
+		wantTotal int
+		wantErr   string
+	}{
+		{name: "veo 10s from 5s scenes", model: AdapterTypeVeo, target: 10, durations: []float64{5, 5}, wantTotal: 10},
+		{name: "veo 15s rounds within tolerance", model: AdapterTypeVeo, target: 15, durations: []float64{5, 5, 5}, wantTotal: 16},
+		{name: "veo 16s already valid", model: AdapterTypeVeo, target: 16, durations: []float64{8, 8}, wantTotal: 16},
+		{name: "veo 30s with 7s scenes", model: AdapterTypeVeo, target: 30, durations: []float64{7, 7, 7, 7, 2}, wantTotal: 30},
+		{name: "veo 60s from short total", model: AdapterTypeVeo, target: 60, durations: []float64{7, 7, 7, 7, 7, 7, 7, 7}, wantTotal: 60},
+		{name: "kling 15s from 6s scenes", model: AdapterTypeKling, target: 15, durations: []float64{6, 6, 3}, wantTotal: 15},
+		{name: "kling 16s within tolerance", model: AdapterTypeKling, target: 16, durations: []float64{8, 8}, wantTotal: 15},
+		{name: "single 30s scene cannot fit", model: AdapterTypeVeo, target: 30, durations: []float64{30}, wantErr: "cannot fit 1 scenes into 30s"},
+		{name: "too many scenes for target", model: AdapterTypeVeo, target: 10, durations: []float64{4, 4, 4, 4}, wantErr: "closest total 16s"},
+		{name: "unknown model", model: AdapterType("runway"), target: 30, durations: []float64{8}, wantErr: "no clip durations known"},
+		{name: "no scenes", model: AdapterTypeVeo, target: 30, wantErr: "no scenes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := scriptWithDurations(tt.durations...)
+			_, err := NormalizeSceneDurations(script, tt.target, tt.model)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NormalizeSceneDurations() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeSceneDurations() error = %v", err)
+			}
+
+			if script.TotalDuration != tt.wantTotal {
+				t.Errorf("TotalDuration = %d, want %d", script.TotalDuration, tt.wantTotal)
+			}
+
+			var start float64
+			for _, scene := range script.Scenes {
+				if !IsValidClipDuration(tt.model, scene.Duration) {
+					t.Errorf("scene %d has invalid duration %.1f for %s", scene.SceneNumber, scene.Duration, tt.model)
+				}
+				if scene.StartTime != start {
+					t.Errorf("scene %d start_time = %.1f, want %.1f", scene.SceneNumber, scene.StartTime, start)
+				}
+				start += scene.Duration
+			}
+		})
+	}
+}
+
+func TestNormalizeSceneDurations_ReportsAdjustments(t *testing.T) {
+	script := scriptWithDurations(8, 5, 8, 6, 4)
+
+	adjustments, err := NormalizeSceneDurations(script, 30, AdapterTypeVeo)
+	if err != nil {
+		t.Fatalf("NormalizeSceneDurations() error = %v", err)
+	}
+	if len(adjustments) != 1 {
+		t.Fatalf("expected 1 adjustment, got %+v", adjustments)
+	}
+	if adjustments[0].SceneNumber != 2 || adjustments[0].Original != 5 {
+		t.Errorf("unexpected adjustment %+v", adjustments[0])
+	}
+	if script.TotalDuration != 30 {
+		t.Errorf("TotalDuration = %d, want 30", script.TotalDuration)
+	}
+}
@@ -0,0 +1,68 @@
+package adapters
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+// ScenePlanMismatchError reports the first scene of a script that breaks the user's scene plan
+type ScenePlanMismatchError struct {
+	SceneNumber     int     // 1-based
+	PlannedScenes   int     // Scenes in the plan
+	Scenes          int     // Scenes in the script
+	PlannedDuration float64 // Seconds the plan gives the scene; 0 when the plan has no such scene
+	Duration        float64 // Seconds the script gives it; 0 when the script has no such scene
+}
+
+func (e *ScenePlanMismatchError) Error() string {
+	switch {
+	case e.SceneNumber > e.Scenes:
+		return fmt.Sprintf("scene %d is missing: the scene plan has %d scenes, the script %d",
+			e.SceneNumber, e.PlannedScenes, e.Scenes)
+	case e.SceneNumber > e.PlannedScenes:
+		return fmt.Sprintf("scene %d isn't in the scene plan: the scene plan has %d scenes, the script %d",
+			e.SceneNumber, e.PlannedScenes, e.Scenes)
+	default:
+		return fmt.Sprintf("scene %d is %gs, the scene plan asks for %gs", e.SceneNumber, e.Duration, e.PlannedDuration)
+	}
+}
+
+// checkScenePlan requires script to have exactly the planned scenes, each at its planned
+// duration. It returns a *ScenePlanMismatchError for the first scene that differs.
+func checkScenePlan(script *domain.Script, plan []prompts.PlannedScene) error {
+	if len(plan) == 0 {
+		return nil
+	}
+
+	for i := 0; i < max(len(plan), len(script.Scenes)); i++ {
+		mismatch := &ScenePlanMismatchError{
+			SceneNumber:   i + 1,
+			PlannedScenes: len(plan),
+			Scenes:        len(script.Scenes),
+		}
+		if i < len(plan) {
+			mismatch.PlannedDuration = float64(plan[i].Duration)
+		}
+		if i < len(script.Scenes) {
+			mismatch.Duration = script.Scenes[i].Duration
+		}
+		if i >= len(plan) || i >= len(script.Scenes) || mismatch.Duration != mismatch.PlannedDuration {
+			return mismatch
+		}
+	}
+	return nil
+}
+
+// retimeScenes lays script's scenes end to end from their durations, setting each start time and
+// the total duration, as NormalizeSceneDurations does for the scenes it snaps
+func retimeScenes(script *domain.Script) {
+	var startTime float64
+	for i := range script.Scenes {
+		script.Scenes[i].StartTime = startTime
+		startTime += script.Scenes[i].Duration
+	}
+	script.TotalDuration = int(math.Round(startTime))
+}
@@ -0,0 +1,115 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/prompts"
+	"go.uber.org/zap"
+)
+
+func plannedScript(durations ...float64) *domain.Script {
+	script := &domain.Script{
+		Title:     "Test Ad",
+		AudioSpec: domain.AudioSpec{MusicMood: "upbeat", MusicStyle: "electronic"},
+	}
+	for i, d := range durations {
+		script.Scenes = append(script.Scenes, domain.Scene{
+			SceneNumber:      i + 1,
+			Duration:         d,
+			GenerationPrompt: fmt.Sprintf("Scene %d: a detailed scene description with plenty of visual information", i+1),
+		})
+		script.TotalDuration += int(d)
+	}
+	return script
+}
+
+func TestValidateScript_ScenePlan(t *testing.T) {
+	plan := []prompts.PlannedScene{{Duration: 8}, {Duration: 8, Hint: "kitchen"}, {Duration: 8}, {Duration: 6}}
+
+	tests := []struct {
+		name    string
+		script  *domain.Script
+		want    *ScenePlanMismatchError
+		wantMsg string
+	}{
+		{name: "matches the plan", script: plannedScript(8, 8, 8, 6)},
+		{
+			name:    "wrong duration",
+			script:  plannedScript(8, 8, 6, 8),
+			want:    &ScenePlanMismatchError{SceneNumber: 3, PlannedScenes: 4, Scenes: 4, PlannedDuration: 8, Duration: 6},
+			wantMsg: "scene 3 is 6s, the scene plan asks for 8s",
+		},
+		{
+			name:    "missing scene",
+			script:  plannedScript(8, 8, 8),
+			want:    &ScenePlanMismatchError{SceneNumber: 4, PlannedScenes: 4, Scenes: 3, PlannedDuration: 6},
+			wantMsg: "scene 4 is missing",
+		},
+		{
+			name:    "extra scene",
+			script:  plannedScript(8, 8, 8, 6, 4),
+			want:    &ScenePlanMismatchError{SceneNumber: 5, PlannedScenes: 4, Scenes: 5, Duration: 4},
+			wantMsg: "scene 5 isn't in the scene plan",
+		},
+		{
+			// Valid clip lengths within the total's tolerance still break the plan
+			name:    "other valid clip lengths",
+			script:  plannedScript(6, 8, 8, 8),
+			want:    &ScenePlanMismatchError{SceneNumber: 1, PlannedScenes: 4, Scenes: 4, PlannedDuration: 8, Duration: 6},
+			wantMsg: "scene 1 is 6s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScript(tt.script, 30, false, AdapterTypeVeo, plan)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateScript() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var mismatch *ScenePlanMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("ValidateScript() error = %v, want a ScenePlanMismatchError", err)
+			}
+			if *mismatch != *tt.want {
+				t.Errorf("mismatch = %+v, want %+v", *mismatch, *tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestMockScriptGenerator_ScenePlan(t *testing.T) {
+	generator := NewMockScriptGenerator(zap.NewNop())
+
+	script, err := generator.GenerateScript(context.Background(), &ScriptGenerationRequest{
+		Prompt:    "A running shoe",
+		Duration:  30,
+		ScenePlan: []prompts.PlannedScene{{Duration: 8}, {Duration: 8, Hint: "EXT. TRACK - DAWN"}, {Duration: 8}, {Duration: 6}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateScript() error = %v", err)
+	}
+
+	var durations, starts []float64
+	for _, scene := range script.Scenes {
+		durations = append(durations, scene.Duration)
+		starts = append(starts, scene.StartTime)
+	}
+	if fmt.Sprint(durations) != "[8 8 8 6]" || fmt.Sprint(starts) != "[0 8 16 24]" {
+		t.Errorf("scenes run %v starting at %v, want the plan's [8 8 8 6] laid end to end", durations, starts)
+	}
+	if script.Scenes[1].Location != "EXT. TRACK - DAWN" {
+		t.Errorf("scene 2 location = %q, want the plan's hint", script.Scenes[1].Location)
+	}
+}
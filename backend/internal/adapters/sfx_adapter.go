@@ -0,0 +1,226 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// DefaultSFXModel is the Replicate text-to-audio model used for sound effects
+const DefaultSFXModel = "sepal/audiogen"
+
+// SFXGenerationRequest represents a sound effect generation request
+type SFXGenerationRequest struct {
+	Prompt   string  // Sound description, e.g. "water pouring sound"
+	Duration float64 // Requested length in seconds (rounded up to whole seconds)
+}
+
+// SFXGenerationResult represents the result of a sound effect prediction
+type SFXGenerationResult struct {
+	PredictionID string
+	Status       string // raw Replicate status (see NormalizeStatus)
+	AudioURL     string
+	Error        string
+}
+
+// SFXGenerator is the minimal contract needed to submit and poll a sound effect prediction
+type SFXGenerator interface {
+	// GenerateSFX submits a sound effect request and returns immediately
+	GenerateSFX(ctx context.Context, req *SFXGenerationRequest) (*SFXGenerationResult, error)
+
+	// GetStatus checks the status of a sound effect prediction
+	GetStatus(ctx context.Context, predictionID string) (*SFXGenerationResult, error)
+}
+
+// SFXAdapter implements SFXGenerator via a Replicate text-to-audio model
+type SFXAdapter struct {
+	tokens     TokenSource
+	httpClient *http.Client
+	logger     *zap.Logger
+	model      string
+	baseURL    string
+	webhooks   *ReplicateWebhooks
+}
+
+// NewSFXAdapter creates a new sound effect adapter; an empty model uses DefaultSFXModel
+func NewSFXAdapter(apiToken, model string, logger *zap.Logger) *SFXAdapter {
+	if model == "" {
+		model = DefaultSFXModel
+	}
+	return &SFXAdapter{
+		tokens: StaticToken(apiToken),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second, // Async operation - just for initial request acknowledgment
+		},
+		logger:  logger,
+		model:   model,
+		baseURL: "https://api.replicate.com",
+	}
+}
+
+// SetReplicateWebhooks makes new predictions report completion by webhook; nil polls only
+func (s *SFXAdapter) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	s.webhooks = webhooks
+}
+
+// SetTokenSource makes requests use the token tokens supplies at the time they're sent,
+// instead of the one passed to NewSFXAdapter
+func (s *SFXAdapter) SetTokenSource(tokens TokenSource) {
+	s.tokens = tokens
+}
+
+// ReplicateWebhooks returns the webhooks new predictions report to
+func (s *SFXAdapter) ReplicateWebhooks() *ReplicateWebhooks {
+	return s.webhooks
+}
+
+// ModelVersion returns the model predictions run; Replicate picks its latest version
+func (s *SFXAdapter) ModelVersion(context.Context) string {
+	return s.model
+}
+
+// sfxRequest matches the Replicate model predictions API schema
+type sfxRequest struct {
+	Input map[string]interface{} `json:"input"`
+	ReplicateWebhookFields
+}
+
+// sfxResponse represents a Replicate prediction
+type sfxResponse struct {
+	ID     string      `json:"id"`
+	Status string      `json:"status"`
+	Output interface{} `json:"output,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// GenerateSFX submits a sound effect prediction
+func (s *SFXAdapter) GenerateSFX(ctx context.Context, req *SFXGenerationRequest) (*SFXGenerationResult, error) {
+	logger := trace.Logger(ctx, s.logger)
+	duration := int(math.Ceil(req.Duration))
+	if duration < 1 {
+		duration = 1
+	}
+
+	logger.Info("Generating sound effect",
+		zap.String("prompt", req.Prompt),
+		zap.Int("duration", duration),
+		zap.String("model", s.model),
+	)
+
+	payload, err := json.Marshal(sfxRequest{Input: map[string]interface{}{
+		"prompt":        req.Prompt,
+		"duration":      duration,
+		"output_format": "mp3",
+	}, ReplicateWebhookFields: s.webhooks.requestFields()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/models/%s/predictions", s.baseURL, s.model)
+
+	var resp sfxResponse
+	if err := s.do(ctx, "POST", url, payload, &resp); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Sound effect prediction created",
+		zap.String("prediction_id", resp.ID),
+		zap.String("status", resp.Status),
+	)
+
+	return toSFXResult(&resp), nil
+}
+
+// GetStatus checks the status of a sound effect prediction
+func (s *SFXAdapter) GetStatus(ctx context.Context, predictionID string) (*SFXGenerationResult, error) {
+	url := fmt.Sprintf("%s/v1/predictions/%s", s.baseURL, predictionID)
+
+	var resp sfxResponse
+	if err := s.do(ctx, "GET", url, nil, &resp); err != nil {
+		return nil, err
+	}
+	return toSFXResult(&resp), nil
+}
+
+// CancelPrediction stops a running prediction
+func (s *SFXAdapter) CancelPrediction(ctx context.Context, predictionID string) error {
+	return cancelReplicatePrediction(ctx, s.httpClient, fmt.Sprintf("%s/v1/predictions/%s/cancel", s.baseURL, predictionID), s.tokens)
+}
+
+// do sends a Replicate request with retries; 4xx responses are not retried
+func (s *SFXAdapter) do(ctx context.Context, method, url string, payload []byte, out *sfxResponse) error {
+	return retry.Do(ctx, retry.APIConfig(), func() error {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		token, err := authorize(ctx, httpReq, s.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
+		if payload != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+			httpReq.Header.Set("Prefer", "wait=0") // Don't wait for completion (async)
+		}
+
+		resp, err := s.httpClient.Do(httpReq)
+		if err != nil {
+			// Network errors are retryable
+			return fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			if resp.StatusCode == http.StatusUnauthorized {
+				return unauthorizedError(ctx, s.tokens, token, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody)))
+			}
+			// 4xx errors are non-retryable
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				return retry.NewNonRetryableError(fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody)))
+			}
+			// 5xx errors are retryable
+			return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		}
+
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse response: %w", err))
+		}
+		return nil
+	})
+}
+
+// toSFXResult maps a Replicate prediction to our result format
+func toSFXResult(resp *sfxResponse) *SFXGenerationResult {
+	result := &SFXGenerationResult{
+		PredictionID: resp.ID,
+		Status:       resp.Status,
+		Error:        resp.Error,
+	}
+	if resp.Status == "succeeded" {
+		if url, ok := extractOutputURL(resp.Output); ok {
+			result.AudioURL = url
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSFXAdapter_GenerateAndPoll(t *testing.T) {
+	var gotPath string
+	var gotBody sfxRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			gotPath = r.URL.Path
+			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "sfx-1", "status": "starting"}`))
+		case http.MethodGet:
+			w.Write([]byte(`{"id": "sfx-1", "status": "succeeded", "output": "https://replicate.delivery/sfx.mp3"}`))
+		}
+	}))
+	defer server.Close()
+
+	adapter := NewSFXAdapter("token", "", zap.NewNop())
+	adapter.baseURL = server.URL
+
+	result, err := adapter.GenerateSFX(context.Background(), &SFXGenerationRequest{Prompt: "water pouring sound", Duration: 2.5})
+	if err != nil {
+		t.Fatalf("GenerateSFX() error = %v", err)
+	}
+	if result.PredictionID != "sfx-1" || result.AudioURL != "" {
+		t.Errorf("result = %+v", result)
+	}
+	if gotPath != "/v1/models/sepal/audiogen/predictions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotBody.Input["prompt"] != "water pouring sound" || gotBody.Input["duration"] != float64(3) {
+		t.Errorf("input = %v", gotBody.Input)
+	}
+
+	polled, err := PollSFXUntilComplete(context.Background(), adapter, result.PredictionID, testPollOptions())
+	if err != nil {
+		t.Fatalf("PollSFXUntilComplete() error = %v", err)
+	}
+	if polled.AudioURL != "https://replicate.delivery/sfx.mp3" {
+		t.Errorf("audio url = %q", polled.AudioURL)
+	}
+}
+
+func TestSFXAdapter_FailedPrediction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "sfx-2", "status": "failed", "error": "NSFW content detected"}`))
+	}))
+	defer server.Close()
+
+	adapter := NewSFXAdapter("token", "custom/sfx-model", zap.NewNop())
+	adapter.baseURL = server.URL
+
+	_, err := PollSFXUntilComplete(context.Background(), adapter, "sfx-2", testPollOptions())
+	var genErr *GenerationError
+	if !errors.As(err, &genErr) {
+		t.Fatalf("expected *GenerationError, got %v", err)
+	}
+	var policy *ContentPolicyError
+	if !errors.As(err, &policy) {
+		t.Errorf("expected the NSFW refusal to be a *ContentPolicyError, got %v", err)
+	}
+	if genErr.Message != "NSFW content detected" {
+		t.Errorf("message = %q", genErr.Message)
+	}
+}
+
+func TestSFXAdapter_ForwardsRequestID(t *testing.T) {
+	var gotIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIDs = append(gotIDs, r.Header.Get(trace.Header))
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "sfx-3", "status": "starting"}`))
+			return
+		}
+		w.Write([]byte(`{"id": "sfx-3", "status": "succeeded", "output": "https://replicate.delivery/sfx.mp3"}`))
+	}))
+	defer server.Close()
+
+	core, logs := observer.New(zap.InfoLevel)
+	adapter := NewSFXAdapter("token", "", zap.New(core))
+	adapter.baseURL = server.URL
+
+	ctx := trace.WithJobID(trace.WithRequestID(context.Background(), "req-42"), "job-7")
+	result, err := adapter.GenerateSFX(ctx, &SFXGenerationRequest{Prompt: "door creak", Duration: 1})
+	if err != nil {
+		t.Fatalf("GenerateSFX() error = %v", err)
+	}
+	if _, err := PollSFXUntilComplete(ctx, adapter, result.PredictionID, testPollOptions()); err != nil {
+		t.Fatalf("PollSFXUntilComplete() error = %v", err)
+	}
+
+	if len(gotIDs) != 2 || gotIDs[0] != "req-42" || gotIDs[1] != "req-42" {
+		t.Errorf("X-Request-ID headers = %v, want req-42 on the submit and the status check", gotIDs)
+	}
+	if logs.Len() == 0 {
+		t.Fatal("expected adapter log lines")
+	}
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "req-42" || fields["job_id"] != "job-7" {
+			t.Errorf("%q fields = %v, want request_id and job_id", entry.Message, fields)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// TokenSource supplies the API token an adapter sends with each request. Tokens backed by
+// Secrets Manager can be rotated while the process runs.
+type TokenSource interface {
+	// Token returns the token to send now
+	Token(ctx context.Context) (string, error)
+
+	// TokenRejected reports that the provider answered 401 to token. It returns true when a
+	// different token is now available, i.e. when the request is worth sending again.
+	TokenRejected(ctx context.Context, token string) bool
+}
+
+// StaticToken is a TokenSource for a token that never changes, such as one passed to an
+// adapter's constructor
+type StaticToken string
+
+// Token returns t
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// TokenRejected always returns false: there is no other token to try
+func (t StaticToken) TokenRejected(ctx context.Context, token string) bool {
+	return false
+}
+
+// authorize sets req's bearer token from tokens and returns the token it sent
+func authorize(ctx context.Context, req *http.Request, tokens TokenSource) (string, error) {
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get API token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return token, nil
+}
+
+// unauthorizedError turns a 401 into an error for retry.Do: retryable when the token has
+// been rotated since it was sent, so the next attempt goes out with the new one
+func unauthorizedError(ctx context.Context, tokens TokenSource, token string, err error) error {
+	if tokens.TokenRejected(ctx, token) {
+		return err
+	}
+	return retry.NewNonRetryableError(err)
+}
@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// rotatingTokens hands out current until a request is rejected, then switches to next
+type rotatingTokens struct {
+	mu       sync.Mutex
+	current  string
+	next     string
+	rejected []string
+}
+
+func (r *rotatingTokens) Token(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current, nil
+}
+
+func (r *rotatingTokens) TokenRejected(ctx context.Context, token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = append(r.rejected, token)
+	if r.next != "" {
+		r.current, r.next = r.next, ""
+	}
+	return r.current != token
+}
+
+// keyServer accepts only the key in valid, recording the keys it was sent
+func keyServer(t *testing.T, valid string, seen *[]string) *httptest.Server {
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*seen = append(*seen, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"detail": "Invalid token"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "sfx-1", "status": "starting"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRotatedTokenIsRetried(t *testing.T) {
+	var seen []string
+	server := keyServer(t, "r8_new", &seen)
+
+	tokens := &rotatingTokens{current: "r8_old", next: "r8_new"}
+	adapter := NewSFXAdapter("r8_old", "", zap.NewNop())
+	adapter.baseURL = server.URL
+	adapter.SetTokenSource(tokens)
+
+	if _, err := adapter.GenerateSFX(context.Background(), &SFXGenerationRequest{Prompt: "door creak", Duration: 2}); err != nil {
+		t.Fatalf("GenerateSFX() error = %v", err)
+	}
+	if len(seen) != 2 || seen[0] != "r8_old" || seen[1] != "r8_new" {
+		t.Errorf("keys sent = %v, want the old key then the rotated one", seen)
+	}
+	if len(tokens.rejected) != 1 || tokens.rejected[0] != "r8_old" {
+		t.Errorf("rejected = %v, want [r8_old]", tokens.rejected)
+	}
+}
+
+func TestRejectedStaticTokenIsNotRetried(t *testing.T) {
+	var seen []string
+	server := keyServer(t, "r8_new", &seen)
+
+	adapter := NewSFXAdapter("r8_old", "", zap.NewNop())
+	adapter.baseURL = server.URL
+
+	_, err := adapter.GenerateSFX(context.Background(), &SFXGenerationRequest{Prompt: "door creak", Duration: 2})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("GenerateSFX() error = %v, want a 401", err)
+	}
+	if len(seen) != 1 {
+		t.Errorf("sent %d requests, want 1", len(seen))
+	}
+}
@@ -0,0 +1,155 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// Prompt size estimation. GPT-4o's tokenizer averages about 4 characters of English per token;
+// other languages and punctuation-heavy text such as JSON schemas take more tokens, which the
+// safety factor covers. Each chat message adds a few tokens of framing.
+const (
+	charsPerToken             = 4.0
+	tokenEstimateSafetyFactor = 1.3
+	messageTokenOverhead      = 4
+	imageTokenEstimate        = 765 // A high-detail 1024x1024 image: 4 tiles of 170 plus 85
+)
+
+// TokenUsage is the tokens an LLM call, or several, consumed
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Total is the prompt and completion tokens together
+func (u TokenUsage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// EstimateTokens estimates, on the high side, the tokens text takes in a prompt
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / charsPerToken * tokenEstimateSafetyFactor))
+}
+
+// estimatePromptTokens estimates the prompt tokens of a chat call sending messages and images
+func estimatePromptTokens(images int, messages ...string) int {
+	tokens := images * imageTokenEstimate
+	for _, message := range messages {
+		tokens += EstimateTokens(message) + messageTokenOverhead
+	}
+	return tokens
+}
+
+// TokenBudget caps the LLM tokens one job may consume across its calls. It is safe for
+// concurrent use.
+type TokenBudget struct {
+	mu    sync.Mutex
+	limit int // <= 0 is unlimited
+	used  TokenUsage
+}
+
+// NewTokenBudget returns a budget of limit tokens, of which used are already spent (by a job's
+// earlier runs); limit <= 0 only tallies usage
+func NewTokenBudget(limit int, used TokenUsage) *TokenBudget {
+	return &TokenBudget{limit: limit, used: used}
+}
+
+// Used is the tokens spent so far
+func (b *TokenBudget) Used() TokenUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Remaining is the tokens left in the budget, or -1 for an unlimited budget
+func (b *TokenBudget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 {
+		return -1
+	}
+	return max(b.limit-b.used.Total(), 0)
+}
+
+// check returns a *PromptTooLargeError if a call estimated at promptTokens, completing with up
+// to maxCompletionTokens, could go over the budget
+func (b *TokenBudget) check(call string, promptTokens, maxCompletionTokens int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 {
+		return nil
+	}
+	remaining := b.limit - b.used.Total()
+	if promptTokens+maxCompletionTokens <= remaining {
+		return nil
+	}
+	return &PromptTooLargeError{
+		Call:                call,
+		EstimatedTokens:     promptTokens,
+		MaxCompletionTokens: maxCompletionTokens,
+		RemainingTokens:     max(remaining, 0),
+		Limit:               b.limit,
+	}
+}
+
+func (b *TokenBudget) add(usage TokenUsage) {
+	b.mu.Lock()
+	b.used.PromptTokens += usage.PromptTokens
+	b.used.CompletionTokens += usage.CompletionTokens
+	b.mu.Unlock()
+}
+
+type tokenBudgetKey struct{}
+
+// WithTokenBudget returns a context whose LLM calls are checked against and counted in budget
+func WithTokenBudget(ctx context.Context, budget *TokenBudget) context.Context {
+	return context.WithValue(ctx, tokenBudgetKey{}, budget)
+}
+
+// PromptTooLargeError is returned before an LLM call whose estimated prompt, with its
+// completion, doesn't fit in what is left of the job's token budget. No request was made.
+type PromptTooLargeError struct {
+	Call                string // What the call was for, e.g. "script"
+	EstimatedTokens     int
+	MaxCompletionTokens int
+	RemainingTokens     int
+	Limit               int
+}
+
+func (e *PromptTooLargeError) Error() string {
+	return fmt.Sprintf("%s prompt too large: about %d prompt tokens plus up to %d completion tokens, with %d of the job's %d token budget left; shorten the brand guidelines or prompt",
+		e.Call, e.EstimatedTokens, e.MaxCompletionTokens, e.RemainingTokens, e.Limit)
+}
+
+// checkTokenBudget checks an LLM call's estimated prompt against ctx's token budget, if any
+func checkTokenBudget(ctx context.Context, call string, promptTokens, maxCompletionTokens int) error {
+	if budget, ok := ctx.Value(tokenBudgetKey{}).(*TokenBudget); ok {
+		return budget.check(call, promptTokens, maxCompletionTokens)
+	}
+	return nil
+}
+
+// recordTokenUsage logs the tokens an LLM call consumed and counts them in ctx's token budget
+// and CallStats
+func recordTokenUsage(ctx context.Context, logger *zap.Logger, provider, model, call string, usage TokenUsage) {
+	trace.Logger(ctx, logger).Info("LLM token usage",
+		zap.String("provider", provider),
+		zap.String("model", model),
+		zap.String("call", call),
+		zap.Int("prompt_tokens", usage.PromptTokens),
+		zap.Int("completion_tokens", usage.CompletionTokens),
+	)
+	if budget, ok := ctx.Value(tokenBudgetKey{}).(*TokenBudget); ok {
+		budget.add(usage)
+	}
+	countTokens(ctx, usage)
+}
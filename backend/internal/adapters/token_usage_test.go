@@ -0,0 +1,177 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return data
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"one character", "a", 1},
+		{"english", strings.Repeat("word ", 80), 130},
+		{"runes, not bytes", strings.Repeat("日本語", 40), 39},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("%s: EstimateTokens() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEstimatePromptTokens(t *testing.T) {
+	text := strings.Repeat("word ", 80)
+	if got, want := estimatePromptTokens(0, text, text), 2*(130+messageTokenOverhead); got != want {
+		t.Errorf("two messages = %d, want %d", got, want)
+	}
+	if got, want := estimatePromptTokens(1, text), imageTokenEstimate+130+messageTokenOverhead; got != want {
+		t.Errorf("message with an image = %d, want %d", got, want)
+	}
+}
+
+func TestTokenBudget(t *testing.T) {
+	budget := NewTokenBudget(1000, TokenUsage{PromptTokens: 200, CompletionTokens: 100})
+	if got := budget.Remaining(); got != 700 {
+		t.Fatalf("remaining = %d, want 700 after the seeded usage", got)
+	}
+	if err := budget.check("script", 500, 200); err != nil {
+		t.Errorf("check of a call that fits exactly: %v", err)
+	}
+
+	err := budget.check("script", 600, 200)
+	var tooLarge *PromptTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("check = %v, want a *PromptTooLargeError", err)
+	}
+	if tooLarge.RemainingTokens != 700 || tooLarge.Limit != 1000 || tooLarge.EstimatedTokens != 600 {
+		t.Errorf("error = %+v", tooLarge)
+	}
+	if !strings.Contains(err.Error(), "script prompt too large") || !strings.Contains(err.Error(), "shorten the brand guidelines or prompt") {
+		t.Errorf("message = %q, want the call and what to shorten", err.Error())
+	}
+	if IsRetryable(err) {
+		t.Error("a prompt over budget should not be retryable")
+	}
+
+	budget.add(TokenUsage{PromptTokens: 650, CompletionTokens: 100})
+	if got := budget.Used(); got.PromptTokens != 850 || got.CompletionTokens != 200 {
+		t.Errorf("used = %+v, want 850 prompt and 200 completion tokens", got)
+	}
+	if got := budget.Remaining(); got != 0 {
+		t.Errorf("remaining = %d, want 0 once spent past the limit", got)
+	}
+	if err := budget.check("text", 1, 0); !errors.As(err, &tooLarge) || tooLarge.RemainingTokens != 0 {
+		t.Errorf("check of a spent budget = %v, want a *PromptTooLargeError with none left", err)
+	}
+}
+
+func TestTokenBudgetUnlimited(t *testing.T) {
+	budget := NewTokenBudget(0, TokenUsage{})
+	if err := budget.check("script", 1_000_000, 16384); err != nil {
+		t.Errorf("check of an unlimited budget: %v", err)
+	}
+	if budget.Remaining() != -1 {
+		t.Errorf("remaining = %d, want -1", budget.Remaining())
+	}
+	if err := checkTokenBudget(context.Background(), "script", 1_000_000, 16384); err != nil {
+		t.Errorf("check without a budget: %v", err)
+	}
+}
+
+func TestGPT4oRecordsPredictionMetrics(t *testing.T) {
+	var resp GPT4oResponse
+	if err := json.Unmarshal(readFixture(t, "replicate_gpt4o_prediction.json"), &resp); err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	budget := NewTokenBudget(10000, TokenUsage{})
+	ctx, stats := WithCallStats(WithTokenBudget(context.Background(), budget))
+
+	g := NewGPT4oAdapter("test-token", "", zap.NewNop())
+	g.recordUsage(ctx, "style analysis", &resp)
+	g.recordUsage(ctx, "text", &GPT4oResponse{Status: "succeeded"}) // No metrics
+
+	want := TokenUsage{PromptTokens: 1843, CompletionTokens: 412}
+	if got := stats.Tokens(); got != want {
+		t.Errorf("call stats tokens = %+v, want %+v", got, want)
+	}
+	if got := budget.Used(); got != want {
+		t.Errorf("budget used = %+v, want %+v", got, want)
+	}
+}
+
+func TestGPT4oRejectsPromptOverBudget(t *testing.T) {
+	ctx := WithTokenBudget(context.Background(), NewTokenBudget(500, TokenUsage{}))
+	g := NewGPT4oAdapter("test-token", "", zap.NewNop())
+	g.httpClient = nil // Fails the test by panicking if a request is made
+
+	_, err := g.GenerateText(ctx, "You shorten disclaimers.", strings.Repeat("brand guideline ", 100))
+	var tooLarge *PromptTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Call != "text" {
+		t.Fatalf("GenerateText() error = %v, want a *PromptTooLargeError", err)
+	}
+}
+
+func TestOpenAIScriptAdapterRecordsUsage(t *testing.T) {
+	fixture := readFixture(t, "openai_chat_completion.json")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	budget := NewTokenBudget(10000, TokenUsage{})
+	ctx, stats := WithCallStats(WithTokenBudget(context.Background(), budget))
+	if _, err := newTestOpenAIScriptAdapter(server.URL).AnalyzeStyleReference(ctx, "https://example.com/style.jpg"); err != nil {
+		t.Fatalf("AnalyzeStyleReference() error = %v", err)
+	}
+
+	want := TokenUsage{PromptTokens: 1205, CompletionTokens: 38}
+	if got := stats.Tokens(); got != want {
+		t.Errorf("call stats tokens = %+v, want %+v", got, want)
+	}
+	if got := budget.Used(); got != want {
+		t.Errorf("budget used = %+v, want %+v", got, want)
+	}
+}
+
+func TestOpenAIScriptAdapterRejectsPromptOverBudget(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		writeChatCompletion(t, w, openAITestScript)
+	}))
+	defer server.Close()
+
+	// The style image alone is estimated over this budget
+	ctx := WithTokenBudget(context.Background(), NewTokenBudget(700, TokenUsage{}))
+	_, err := newTestOpenAIScriptAdapter(server.URL).AnalyzeStyleReference(ctx, "https://example.com/style.jpg")
+	var tooLarge *PromptTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Call != "style analysis" {
+		t.Fatalf("AnalyzeStyleReference() error = %v, want a *PromptTooLargeError", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("%d requests sent, want none for a prompt over budget", requests.Load())
+	}
+}
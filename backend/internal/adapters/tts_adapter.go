@@ -10,10 +10,13 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
 	"go.uber.org/zap"
 )
 
@@ -26,11 +29,22 @@ type TTSAdapter interface {
 	// GenerateVoiceoverWithDuration generates speech audio and returns duration.
 	// Speed parameter allows 1.4x for side effects disclaimers.
 	GenerateVoiceoverWithDuration(ctx context.Context, text string, voice string, speed float64) ([]byte, float64, error)
+
+	// Voices lists the narrator voices the adapter accepts.
+	Voices(ctx context.Context) ([]Voice, error)
+}
+
+// Voice is a narrator voice; ID is what GenerateVoiceover takes as the voice.
+type Voice struct {
+	ID       string      `json:"id"`
+	Label    string      `json:"label"`
+	Provider TTSProvider `json:"provider"`
 }
 
 // OpenAITTSAdapter implements text-to-speech using the OpenAI TTS API.
 type OpenAITTSAdapter struct {
 	apiKey     string
+	tokens     TokenSource // nil sends apiKey
 	httpClient *http.Client
 	logger     *zap.Logger
 	model      string
@@ -50,12 +64,72 @@ func NewOpenAITTSAdapter(apiKey string, logger *zap.Logger) *OpenAITTSAdapter {
 	}
 }
 
+// SetTokenSource makes requests use the key tokens supplies at the time they're sent,
+// instead of the one passed to NewOpenAITTSAdapter.
+func (t *OpenAITTSAdapter) SetTokenSource(tokens TokenSource) {
+	t.tokens = tokens
+}
+
+// tokenSource returns the source of the key requests are sent with.
+func (t *OpenAITTSAdapter) tokenSource() TokenSource {
+	if t.tokens != nil {
+		return t.tokens
+	}
+	return StaticToken(t.apiKey)
+}
+
 // voiceMap provides the OpenAI voice IDs for supported narrator voices.
 var voiceMap = map[string]string{
 	"male":   "onyx",
 	"female": "nova",
 }
 
+// languageVoiceMap replaces voiceMap's voices in languages another OpenAI voice reads more
+// naturally, keyed by primary language subtag
+var languageVoiceMap = map[string]map[string]string{
+	domain.LanguageSpanish: {"male": "echo", "female": "shimmer"},
+	domain.LanguageGerman:  {"male": "onyx", "female": "alloy"},
+}
+
+// openAIVoiceFor returns the OpenAI voice that reads voice in the BCP-47 language tag
+func openAIVoiceFor(voice, language string) (string, bool) {
+	if voices, ok := languageVoiceMap[domain.BaseLanguage(language)]; ok {
+		if openAIVoice, ok := voices[voice]; ok {
+			return openAIVoice, true
+		}
+	}
+	openAIVoice, ok := voiceMap[voice]
+	return openAIVoice, ok
+}
+
+type narrationLanguageKey struct{}
+
+// WithNarrationLanguage returns a context whose TTS calls speak the BCP-47 language tag; an
+// empty tag keeps ctx, which speaks English
+func WithNarrationLanguage(ctx context.Context, language string) context.Context {
+	if language == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, narrationLanguageKey{}, language)
+}
+
+// narrationLanguage returns the BCP-47 tag TTS calls on ctx speak, or "" for English
+func narrationLanguage(ctx context.Context) string {
+	language, _ := ctx.Value(narrationLanguageKey{}).(string)
+	return language
+}
+
+// openAIVoices lists the voices in voiceMap; OpenAI has no endpoint to enumerate them.
+var openAIVoices = []Voice{
+	{ID: "male", Label: "Male (Onyx)", Provider: TTSProviderOpenAI},
+	{ID: "female", Label: "Female (Nova)", Provider: TTSProviderOpenAI},
+}
+
+// Voices returns the fixed male and female narrator voices.
+func (t *OpenAITTSAdapter) Voices(ctx context.Context) ([]Voice, error) {
+	return slices.Clone(openAIVoices), nil
+}
+
 // openAITTSRequest matches the OpenAI TTS API schema.
 type openAITTSRequest struct {
 	Model          string  `json:"model"`
@@ -79,16 +153,19 @@ func (e *retryableError) Unwrap() error {
 }
 
 // GenerateVoiceover generates speech audio from the provided text using the configured voice.
-func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, voice string) ([]byte, error) {
+func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, voice string) (_ []byte, err error) {
+	logger := trace.Logger(ctx, t.logger)
 	startTime := time.Now()
 
-	t.logger.Info("Generating voiceover with OpenAI TTS",
+	language := narrationLanguage(ctx)
+	logger.Info("Generating voiceover with OpenAI TTS",
 		zap.String("voice", voice),
+		zap.String("language", language),
 		zap.Int("text_length", len(text)),
 		zap.String("model", t.model),
 	)
 
-	openAIVoice, ok := voiceMap[voice]
+	openAIVoice, ok := openAIVoiceFor(voice, language)
 	if !ok {
 		return nil, fmt.Errorf("invalid voice selection: %s (expected 'male' or 'female')", voice)
 	}
@@ -100,6 +177,9 @@ func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, v
 		ResponseFormat: "mp3",
 		Speed:          1.0,
 	}
+	defer func() {
+		recordProviderCall(ctx, string(TTSProviderOpenAI), t.model, "", startTime, err)
+	}()
 
 	attempts := 3
 	delays := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second}
@@ -108,7 +188,7 @@ func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, v
 
 	for attempt := 0; attempt < attempts; attempt++ {
 		if attempt > 0 {
-			t.logger.Warn("Retrying OpenAI TTS request",
+			logger.Warn("Retrying OpenAI TTS request",
 				zap.Int("attempt", attempt+1),
 				zap.Int("max_attempts", attempts),
 				zap.Error(lastErr),
@@ -125,7 +205,7 @@ func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, v
 		audioData, err := t.callOpenAITTS(ctx, reqPayload)
 		if err == nil {
 			duration := time.Since(startTime)
-			t.logger.Info("Generated voiceover with OpenAI TTS",
+			logger.Info("Generated voiceover with OpenAI TTS",
 				zap.String("voice", voice),
 				zap.Int("audio_size_bytes", len(audioData)),
 				zap.Duration("duration", duration),
@@ -137,7 +217,7 @@ func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, v
 		lastErr = err
 
 		if !isRetryableError(err) {
-			t.logger.Error("OpenAI TTS request failed with non-retryable error",
+			logger.Error("OpenAI TTS request failed with non-retryable error",
 				zap.Error(err),
 				zap.Int("attempt", attempt+1),
 			)
@@ -145,7 +225,7 @@ func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, v
 		}
 	}
 
-	t.logger.Error("OpenAI TTS generation failed after maximum retries",
+	logger.Error("OpenAI TTS generation failed after maximum retries",
 		zap.Error(lastErr),
 		zap.Int("attempts", attempts),
 	)
@@ -153,6 +233,7 @@ func (t *OpenAITTSAdapter) GenerateVoiceover(ctx context.Context, text string, v
 }
 
 func (t *OpenAITTSAdapter) callOpenAITTS(ctx context.Context, reqPayload openAITTSRequest) ([]byte, error) {
+	logger := trace.Logger(ctx, t.logger)
 	payload, err := json.Marshal(reqPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal TTS request: %w", err)
@@ -163,10 +244,14 @@ func (t *OpenAITTSAdapter) callOpenAITTS(ctx context.Context, reqPayload openAIT
 		return nil, fmt.Errorf("failed to create TTS request: %w", err)
 	}
 
-	httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+	tokens := t.tokenSource()
+	token, err := authorize(ctx, httpReq, tokens)
+	if err != nil {
+		return nil, err
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	t.logger.Debug("Calling OpenAI TTS API",
+	logger.Debug("Calling OpenAI TTS API",
 		zap.String("endpoint", t.endpoint),
 		zap.String("voice", reqPayload.Voice),
 		zap.String("model", reqPayload.Model),
@@ -184,9 +269,10 @@ func (t *OpenAITTSAdapter) callOpenAITTS(ctx context.Context, reqPayload openAIT
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		apiErr := fmt.Errorf("openai tts error (status %d): %s", resp.StatusCode, string(body))
+		apiErr := newStatusError(ProviderOpenAI, resp.StatusCode, body)
 
-		if isRetryableStatus(resp.StatusCode) {
+		// A rejected key is worth retrying only once it has been rotated
+		if isRetryableStatus(resp.StatusCode) || (resp.StatusCode == http.StatusUnauthorized && tokens.TokenRejected(ctx, token)) {
 			return nil, &retryableError{err: apiErr}
 		}
 
@@ -212,11 +298,12 @@ func isRetryableStatus(status int) bool {
 
 // GenerateVoiceoverWithDuration generates TTS audio at specified speed and returns duration.
 func (t *OpenAITTSAdapter) GenerateVoiceoverWithDuration(ctx context.Context, text string, voice string, speed float64) ([]byte, float64, error) {
+	logger := trace.Logger(ctx, t.logger)
 	if text == "" {
 		return nil, 0, fmt.Errorf("empty text for TTS")
 	}
 
-	openAIVoice, ok := voiceMap[voice]
+	openAIVoice, ok := openAIVoiceFor(voice, narrationLanguage(ctx))
 	if !ok {
 		return nil, 0, fmt.Errorf("invalid voice selection: %s (expected 'male' or 'female')", voice)
 	}
@@ -229,13 +316,15 @@ func (t *OpenAITTSAdapter) GenerateVoiceoverWithDuration(ctx context.Context, te
 		Speed:          speed,
 	}
 
-	t.logger.Info("Generating voiceover with duration",
+	logger.Info("Generating voiceover with duration",
 		zap.String("voice", voice),
 		zap.Int("text_length", len(text)),
 		zap.Float64("speed", speed),
 	)
 
+	submitted := time.Now()
 	audioData, err := t.callOpenAITTS(ctx, reqPayload)
+	recordProviderCall(ctx, string(TTSProviderOpenAI), t.model, "", submitted, err)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to generate TTS: %w", err)
 	}
@@ -257,7 +346,7 @@ func (t *OpenAITTSAdapter) GenerateVoiceoverWithDuration(ctx context.Context, te
 		return nil, 0, fmt.Errorf("failed to get audio duration: %w", err)
 	}
 
-	t.logger.Info("Generated voiceover with duration",
+	logger.Info("Generated voiceover with duration",
 		zap.Int("audio_size_bytes", len(audioData)),
 		zap.Float64("duration_seconds", duration),
 	)
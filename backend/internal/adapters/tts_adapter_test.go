@@ -310,3 +310,35 @@ func TestOpenAITTSAdapter_GenerateVoiceover_SpeedParameter(t *testing.T) {
 		t.Error("callOpenAITTS should return data")
 	}
 }
+
+func TestOpenAIVoiceFor(t *testing.T) {
+	tests := []struct {
+		voice, language, want string
+	}{
+		{"male", "", "onyx"},
+		{"female", "en-GB", "nova"},
+		{"male", "es-MX", "echo"},
+		{"female", "es", "shimmer"},
+		{"female", "de-DE", "alloy"},
+		{"female", "fr", "nova"},
+	}
+	for _, tt := range tests {
+		got, ok := openAIVoiceFor(tt.voice, tt.language)
+		if !ok || got != tt.want {
+			t.Errorf("openAIVoiceFor(%q, %q) = %q, %v; want %q", tt.voice, tt.language, got, ok, tt.want)
+		}
+	}
+	if _, ok := openAIVoiceFor("robot", "es"); ok {
+		t.Error("openAIVoiceFor should reject an unknown voice in any language")
+	}
+}
+
+func TestNarrationLanguage(t *testing.T) {
+	ctx := context.Background()
+	if got := narrationLanguage(WithNarrationLanguage(ctx, "")); got != "" {
+		t.Errorf("narrationLanguage() = %q, want English by default", got)
+	}
+	if got := narrationLanguage(WithNarrationLanguage(ctx, "de-DE")); got != "de-DE" {
+		t.Errorf("narrationLanguage() = %q, want de-DE", got)
+	}
+}
@@ -12,36 +12,61 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/omnigen/backend/internal/trace"
 	"github.com/omnigen/backend/pkg/retry"
 )
 
 // VeoAdapter implements VideoGeneratorAdapter for Google Veo 3.1
 type VeoAdapter struct {
-	apiToken     string
+	tokens       TokenSource
 	httpClient   *http.Client
 	logger       *zap.Logger
 	modelVersion string
+	webhooks     *ReplicateWebhooks
 }
 
-// NewVeoAdapter creates a new Veo 3.1 adapter
-func NewVeoAdapter(apiToken string, logger *zap.Logger) *VeoAdapter {
+// NewVeoAdapter creates a new Veo 3.1 adapter; an empty model uses DefaultVeoModel
+func NewVeoAdapter(apiToken, model string, logger *zap.Logger) *VeoAdapter {
+	if model == "" {
+		model = DefaultVeoModel
+	}
 	return &VeoAdapter{
-		apiToken: apiToken,
+		tokens: StaticToken(apiToken),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second, // Async operation - just for initial request acknowledgment
 		},
 		logger: logger,
-		// Veo 3.1 model on Replicate with specific version hash
 		// Replicate HTTP API requires the full version hash for direct API calls
-		// Version hash obtained from: https://replicate.com/google/veo-3.1
-		modelVersion: "google/veo-3.1:a55204f92195a6c535170095e221116968f43614517d8ad32b338fa12ee4460b",
+		modelVersion: model,
 	}
 }
 
+// SetReplicateWebhooks makes new predictions report completion by webhook; nil polls only
+func (v *VeoAdapter) SetReplicateWebhooks(webhooks *ReplicateWebhooks) {
+	v.webhooks = webhooks
+}
+
+// SetTokenSource makes requests use the token tokens supplies at the time they're sent,
+// instead of the one passed to NewVeoAdapter
+func (v *VeoAdapter) SetTokenSource(tokens TokenSource) {
+	v.tokens = tokens
+}
+
+// ReplicateWebhooks returns the webhooks new predictions report to
+func (v *VeoAdapter) ReplicateWebhooks() *ReplicateWebhooks {
+	return v.webhooks
+}
+
+// ModelVersion returns the model version predictions on ctx are submitted with
+func (v *VeoAdapter) ModelVersion(ctx context.Context) string {
+	return modelFor(ctx, ModelVeo, v.modelVersion)
+}
+
 // VeoRequest matches the Veo 3.1 API schema on Replicate
 type VeoRequest struct {
 	Version string                 `json:"version"`
 	Input   map[string]interface{} `json:"input"`
+	ReplicateWebhookFields
 }
 
 // VeoResponse represents the Replicate API response
@@ -114,7 +139,8 @@ func (v *VeoAdapter) sanitizePromptForVeo(prompt string) string {
 
 // GenerateVideo submits a video generation request to Veo 3.1
 func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequest) (*VideoGenerationResult, error) {
-	v.logger.Info("Generating video with Veo 3.1",
+	logger := trace.Logger(ctx, v.logger)
+	logger.Info("Generating video with Veo 3.1",
 		zap.String("prompt", req.Prompt),
 		zap.Int("duration", req.Duration),
 		zap.String("aspect_ratio", req.AspectRatio),
@@ -132,25 +158,14 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 	// CRITICAL: Sanitize prompt to avoid Veo moderation flags
 	fullPrompt = v.sanitizePromptForVeo(fullPrompt)
 
-	// Construct Veo API request input
-	// Veo 3.1 API schema: prompt, aspect_ratio, duration, image, last_frame, reference_images, negative_prompt, resolution
-	input := map[string]interface{}{
-		"prompt":       fullPrompt,
-		"aspect_ratio": aspectRatio,
-		"duration":     v.mapDuration(req.Duration),
-	}
-
-	// Add image if provided (Veo uses "image" not "start_image")
+	input := v.buildInput(req, fullPrompt)
 	if req.StartImageURL != "" {
-		input["image"] = req.StartImageURL
-		v.logger.Info("Using start image for video generation",
+		logger.Info("Using start image for video generation",
 			zap.String("image_url", req.StartImageURL),
 		)
 	}
-
-	// Add negative_prompt if provided
-	if req.NegativePrompt != "" {
-		input["negative_prompt"] = req.NegativePrompt
+	if _, ok := req.Params[ParamGuidance]; ok {
+		logger.Debug("Veo has no guidance input, ignoring it")
 	}
 
 	// Note: Veo 3.1 also supports:
@@ -162,14 +177,16 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 	// Construct Veo API request
 	// Replicate API format: either "model-name" or "model-name:version-hash"
 	// If modelVersion doesn't have a colon, Replicate will use the latest version
+	modelVersion := useModel(ctx, logger, ModelVeo, v.modelVersion)
 	veoReq := VeoRequest{
-		Version: v.modelVersion,
-		Input:   input,
+		Version:                modelVersion,
+		Input:                  input,
+		ReplicateWebhookFields: v.webhooks.requestFields(),
 	}
 
 	// Log the full request for debugging
-	v.logger.Debug("Veo API request",
-		zap.String("version", v.modelVersion),
+	logger.Debug("Veo API request",
+		zap.String("version", modelVersion),
 		zap.Any("input", input),
 	)
 
@@ -179,15 +196,15 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	v.logger.Info("Submitting Veo API request",
+	logger.Info("Submitting Veo API request",
 		zap.Int("clip_duration_seconds", v.mapDuration(req.Duration)),
 		zap.String("aspect_ratio", aspectRatio),
 		zap.Bool("has_image", req.StartImageURL != ""),
-		zap.String("model_version", v.modelVersion),
+		zap.String("model_version", modelVersion),
 	)
 
 	// Log the full request payload for debugging
-	v.logger.Debug("Veo API request payload",
+	logger.Debug("Veo API request payload",
 		zap.Any("input", input),
 	)
 
@@ -205,7 +222,11 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", v.apiToken))
+		token, err := authorize(ctx, httpReq, v.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 		httpReq.Header.Set("Prefer", "wait=0") // Don't wait for completion
 
@@ -213,7 +234,7 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 		resp, err := v.httpClient.Do(httpReq)
 		if err != nil {
 			// Network errors are retryable
-			return fmt.Errorf("failed to execute request: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -226,39 +247,28 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 			// Log full error details for debugging
 			errorBody := string(body)
-			v.logger.Error("Veo API error",
+			logger.Error("Veo API error",
 				zap.Int("status_code", resp.StatusCode),
 				zap.String("response_body", errorBody),
 				zap.String("request_url", httpReq.URL.String()),
-				zap.String("model_version", v.modelVersion),
+				zap.String("model_version", modelVersion),
 			)
 
 			// Log the request payload for debugging 422 errors
 			if resp.StatusCode == 422 {
-				v.logger.Error("Veo API validation error - request payload",
+				logger.Error("Veo API validation error - request payload",
 					zap.Any("request_input", veoReq.Input),
 					zap.String("full_request", string(jsonData)),
 				)
 			}
 
-			// 4xx errors are non-retryable (client errors)
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				var errMsg string
-				switch resp.StatusCode {
-				case 422:
-					errMsg = fmt.Sprintf("API error (status %d): Invalid request parameters or model version. Check that model version '%s' is correct and parameters match Veo 3.1 schema. Response: %s", resp.StatusCode, v.modelVersion, errorBody)
-				case 402:
-					errMsg = fmt.Sprintf("API error (status %d): Payment required - Replicate account has insufficient credits. Response: %s", resp.StatusCode, errorBody)
-				case 404:
-					errMsg = fmt.Sprintf("API error (status %d): Model not found. Check that model version '%s' exists on Replicate. Response: %s", resp.StatusCode, v.modelVersion, errorBody)
-				default:
-					errMsg = fmt.Sprintf("API error: status %d, body: %s", resp.StatusCode, errorBody)
-				}
-				return retry.NewNonRetryableError(fmt.Errorf("%s", errMsg))
+			switch resp.StatusCode {
+			case 422:
+				logger.Error("Invalid request parameters or model version; check the model version and that parameters match the Veo 3.1 schema")
+			case 404:
+				logger.Error("Model not found; check that the model version exists on Replicate")
 			}
-
-			// 5xx errors are retryable (server errors)
-			return fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, errorBody)
+			return replicateStatusError(ctx, v.tokens, token, resp.StatusCode, body)
 		}
 
 		// Parse response
@@ -273,7 +283,7 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 		return nil, err
 	}
 
-	v.logger.Info("Veo prediction created successfully",
+	logger.Info("Veo prediction created successfully",
 		zap.String("prediction_id", veoResp.ID),
 		zap.String("status", veoResp.Status),
 		zap.String("created_at", veoResp.CreatedAt),
@@ -301,8 +311,31 @@ func (v *VeoAdapter) GenerateVideo(ctx context.Context, req *VideoGenerationRequ
 	return result, nil
 }
 
+// buildInput constructs the Veo prediction input for req with its final prompt.
+// Veo 3.1 API schema: prompt, aspect_ratio, duration, image, last_frame, reference_images, negative_prompt, resolution, seed
+func (v *VeoAdapter) buildInput(req *VideoGenerationRequest, prompt string) map[string]interface{} {
+	input := map[string]interface{}{
+		"prompt":       prompt,
+		"aspect_ratio": v.mapAspectRatio(req.AspectRatio),
+		"duration":     v.mapDuration(req.Duration),
+	}
+
+	// Veo uses "image" not "start_image"
+	if req.StartImageURL != "" {
+		input["image"] = req.StartImageURL
+	}
+	if req.NegativePrompt != "" {
+		input["negative_prompt"] = req.NegativePrompt
+	}
+	if req.Seed != nil {
+		input["seed"] = *req.Seed
+	}
+	return input
+}
+
 // GetStatus checks the status of a video generation job
 func (v *VeoAdapter) GetStatus(ctx context.Context, predictionID string) (*VideoGenerationResult, error) {
+	logger := trace.Logger(ctx, v.logger)
 	url := fmt.Sprintf("https://api.replicate.com/v1/predictions/%s", predictionID)
 
 	var veoResp VeoResponse
@@ -313,14 +346,18 @@ func (v *VeoAdapter) GetStatus(ctx context.Context, predictionID string) (*Video
 			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 		}
 
-		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", v.apiToken))
+		token, err := authorize(ctx, httpReq, v.tokens)
+		if err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		trace.SetHeader(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 
 		// Execute request
 		resp, err := v.httpClient.Do(httpReq)
 		if err != nil {
 			// Network errors are retryable
-			return fmt.Errorf("failed to execute request: %w", err)
+			return requestError(ProviderReplicate, err)
 		}
 		defer resp.Body.Close()
 
@@ -331,12 +368,7 @@ func (v *VeoAdapter) GetStatus(ctx context.Context, predictionID string) (*Video
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			// 4xx errors are non-retryable
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				return retry.NewNonRetryableError(fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, string(body)))
-			}
-			// 5xx errors are retryable
-			return fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, string(body))
+			return replicateStatusError(ctx, v.tokens, token, resp.StatusCode, body)
 		}
 
 		// Parse response
@@ -367,7 +399,7 @@ func (v *VeoAdapter) GetStatus(ctx context.Context, predictionID string) (*Video
 
 	// Handle failed status - check both Error field and status
 	if veoResp.Status == "failed" || veoResp.Status == "canceled" {
-		result.Status = "failed"
+		result.Status = v.mapStatus(veoResp.Status)
 		if veoResp.Error != "" {
 			result.Error = veoResp.Error
 		} else if veoResp.Logs != "" {
@@ -384,7 +416,7 @@ func (v *VeoAdapter) GetStatus(ctx context.Context, predictionID string) (*Video
 		}
 
 		// Log full response for debugging
-		v.logger.Error("Veo generation failed",
+		logger.Error("Veo generation failed",
 			zap.String("prediction_id", veoResp.ID),
 			zap.String("status", veoResp.Status),
 			zap.String("error", veoResp.Error),
@@ -396,6 +428,11 @@ func (v *VeoAdapter) GetStatus(ctx context.Context, predictionID string) (*Video
 	return result, nil
 }
 
+// CancelPrediction stops a running prediction
+func (v *VeoAdapter) CancelPrediction(ctx context.Context, predictionID string) error {
+	return cancelReplicatePrediction(ctx, v.httpClient, fmt.Sprintf("https://api.replicate.com/v1/predictions/%s/cancel", predictionID), v.tokens)
+}
+
 // GetModelName returns the name of the model
 func (v *VeoAdapter) GetModelName() string {
 	return "Google Veo 3.1"
@@ -451,8 +488,10 @@ func (v *VeoAdapter) mapStatus(status string) string {
 		return "processing"
 	case "succeeded":
 		return "completed"
-	case "failed", "canceled":
+	case "failed":
 		return "failed"
+	case "canceled":
+		return "canceled"
 	default:
 		return "processing"
 	}
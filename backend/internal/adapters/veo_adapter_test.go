@@ -0,0 +1,64 @@
+package adapters
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestVeoBuildInput(t *testing.T) {
+	v := NewVeoAdapter("", "", zap.NewNop())
+	seed := int64(4242)
+
+	tests := []struct {
+		name string
+		req  *VideoGenerationRequest
+		want map[string]interface{}
+	}{
+		{
+			name: "prompt only",
+			req:  &VideoGenerationRequest{Duration: 8, AspectRatio: "9:16"},
+			want: map[string]interface{}{"prompt": "A bottle on a counter", "aspect_ratio": "9:16", "duration": 8},
+		},
+		{
+			name: "all parameters",
+			req: &VideoGenerationRequest{
+				Duration:       8,
+				AspectRatio:    "16:9",
+				StartImageURL:  "https://example.com/frame.jpg",
+				NegativePrompt: "extra fingers, warped text",
+				Seed:           &seed,
+				Params:         map[string]float64{ParamGuidance: 0.7},
+			},
+			// Veo has no guidance input
+			want: map[string]interface{}{
+				"prompt":          "A bottle on a counter",
+				"aspect_ratio":    "16:9",
+				"duration":        8,
+				"image":           "https://example.com/frame.jpg",
+				"negative_prompt": "extra fingers, warped text",
+				"seed":            int64(4242),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.buildInput(tt.req, "A bottle on a counter")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildInput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVeoBuildInput_SeedZero(t *testing.T) {
+	v := NewVeoAdapter("", "", zap.NewNop())
+	seed := int64(0)
+
+	got := v.buildInput(&VideoGenerationRequest{Duration: 8, Seed: &seed}, "prompt")
+	if got["seed"] != int64(0) {
+		t.Errorf("seed = %v, want a pinned seed of 0 to be sent", got["seed"])
+	}
+}
@@ -2,8 +2,23 @@ package adapters
 
 import (
 	"context"
+	"math"
+	"math/rand/v2"
 )
 
+// ParamGuidance is the provider-neutral generation parameter for how closely the model follows
+// the prompt, from 0 (loosely) to 1 (strictly). Adapters map it to their own input name.
+const ParamGuidance = "guidance"
+
+// MaxSeed is the largest seed the video models accept (an unsigned 32-bit integer)
+const MaxSeed int64 = math.MaxUint32
+
+// NewSeed returns a random seed to send when a scene doesn't pin one, so the seed the clip was
+// generated with is known and can be reused
+func NewSeed() int64 {
+	return rand.Int64N(MaxSeed + 1)
+}
+
 // VideoGenerationRequest represents a video generation request
 type VideoGenerationRequest struct {
 	Prompt         string
@@ -12,23 +27,31 @@ type VideoGenerationRequest struct {
 	Style          string // optional style modifiers
 	StartImageURL  string // optional: URL to start image (first frame)
 	NegativePrompt string // optional: things to avoid in the video
+
+	Seed   *int64             // optional: 0 to MaxSeed; the same seed and prompt reproduce a clip
+	Params map[string]float64 // optional: provider-neutral parameters such as ParamGuidance
 }
 
 // VideoGenerationResult represents the result of a video generation
 type VideoGenerationResult struct {
-	VideoURL    string
+	VideoURL     string
 	PredictionID string // ID from the model provider for tracking
-	Status      string // "processing", "completed", "failed"
-	Error       string // error message if failed
+	Status       string // "processing", "completed", "failed", "canceled" (see NormalizeStatus)
+	Error        string // error message if failed
 }
 
-// VideoGeneratorAdapter is the interface for video generation models
-type VideoGeneratorAdapter interface {
+// VideoGenerator is the minimal contract needed to submit and poll a video prediction
+type VideoGenerator interface {
 	// GenerateVideo submits a video generation request and returns immediately
 	GenerateVideo(ctx context.Context, req *VideoGenerationRequest) (*VideoGenerationResult, error)
 
 	// GetStatus checks the status of a video generation job
 	GetStatus(ctx context.Context, predictionID string) (*VideoGenerationResult, error)
+}
+
+// VideoGeneratorAdapter is the interface for video generation models
+type VideoGeneratorAdapter interface {
+	VideoGenerator
 
 	// GetModelName returns the name of the model
 	GetModelName() string
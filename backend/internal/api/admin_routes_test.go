@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/api/handlers"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// adminRoutes mounts the admin API the way setupRoutes does, with claims standing in for the
+// validated JWT. The handler has no repository: a request that reaches it panics.
+func adminRoutes(claims *domain.UserClaims) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	logger := zap.NewNop()
+	registerAdminRoutes(router.Group("/api/v1/admin", func(c *gin.Context) {
+		if claims != nil {
+			auth.SetUserClaims(c, claims)
+		}
+	}, auth.RequireGroup("admin", logger)), handlers.NewAdminJobsHandler(nil, nil, logger))
+	return router
+}
+
+func adminRoutePaths(t *testing.T, router *gin.Engine) []gin.RouteInfo {
+	t.Helper()
+	var routes []gin.RouteInfo
+	for _, route := range router.Routes() {
+		if strings.HasPrefix(route.Path, "/api/v1/admin/") {
+			routes = append(routes, route)
+		}
+	}
+	require.Len(t, routes, 3)
+	return routes
+}
+
+func TestAdminRoutesRejectNonAdmins(t *testing.T) {
+	for name, claims := range map[string]*domain.UserClaims{
+		"no groups":    {Sub: "user-1", SubscriptionTier: "enterprise"},
+		"other groups": {Sub: "user-1", Groups: []string{"beta", "administrators"}},
+	} {
+		router := adminRoutes(claims)
+		for _, route := range adminRoutePaths(t, router) {
+			path := strings.ReplaceAll(route.Path, ":id", "job-1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(route.Method, path, nil))
+			require.Equal(t, http.StatusForbidden, w.Code, "%s: %s %s", name, route.Method, route.Path)
+		}
+	}
+}
+
+func TestAdminRoutesRequireAuthentication(t *testing.T) {
+	router := adminRoutes(nil)
+	for _, route := range adminRoutePaths(t, router) {
+		path := strings.ReplaceAll(route.Path, ":id", "job-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(route.Method, path, nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", route.Method, route.Path)
+	}
+}
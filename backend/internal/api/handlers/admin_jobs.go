@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// adminJobStore is the subset of the job repository the admin API needs
+type adminJobStore interface {
+	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
+	ListJobsByStatus(ctx context.Context, query repository.StatusJobsQuery) (*repository.JobsPage, error)
+}
+
+// jobRequeuer reruns failed jobs; GenerateHandler implements it
+type jobRequeuer interface {
+	RequeueJob(ctx context.Context, job *domain.Job) (bool, error)
+}
+
+// AdminJobsHandler serves the support staff job API under /api/v1/admin/jobs. It reads every
+// user's jobs, so its routes must sit behind auth.RequireGroup.
+type AdminJobsHandler struct {
+	jobs     adminJobStore
+	requeuer jobRequeuer
+	logger   *zap.Logger
+}
+
+// NewAdminJobsHandler creates a new admin jobs handler
+func NewAdminJobsHandler(
+	jobRepo *repository.DynamoDBRepository,
+	generateHandler *GenerateHandler,
+	logger *zap.Logger,
+) *AdminJobsHandler {
+	h := &AdminJobsHandler{logger: logger}
+	if jobRepo != nil {
+		h.jobs = jobRepo
+	}
+	if generateHandler != nil {
+		h.requeuer = generateHandler
+	}
+	return h
+}
+
+// AdminJobSummary is one job of an admin job list
+type AdminJobSummary struct {
+	JobID        string  `json:"job_id"`
+	UserID       string  `json:"user_id"`
+	Status       string  `json:"status"`
+	Stage        string  `json:"stage,omitempty"`
+	FailureStage string  `json:"failure_stage,omitempty"`
+	ErrorMessage *string `json:"error_message,omitempty"` // As shown to the user
+	FailureError string  `json:"failure_error,omitempty"` // Raw internal error
+	Model        string  `json:"model,omitempty"`
+	Duration     int     `json:"duration,omitempty"`
+	Requeues     int     `json:"requeues,omitempty"`
+	CreatedAt    int64   `json:"created_at"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+// AdminJobsFilters echoes the filters applied to an admin job list page
+type AdminJobsFilters struct {
+	Status        string `json:"status"`
+	FailureStage  string `json:"failure_stage,omitempty"`
+	CreatedAfter  int64  `json:"created_after,omitempty"`  // Unix seconds, inclusive
+	CreatedBefore int64  `json:"created_before,omitempty"` // Unix seconds, inclusive
+}
+
+// AdminListJobsResponse represents one page of jobs across all users
+type AdminListJobsResponse struct {
+	Jobs       []AdminJobSummary `json:"jobs"`
+	Count      int               `json:"count"` // Jobs in this page
+	PageSize   int               `json:"page_size"`
+	NextCursor string            `json:"next_cursor,omitempty"` // Pass as ?cursor= to fetch the next page; omitted on the last page
+	Truncated  bool              `json:"truncated,omitempty"`   // The scan limit was reached before the page filled
+	Filters    AdminJobsFilters  `json:"filters"`
+}
+
+// AdminRequeueResponse represents the response after requeueing a failed job
+type AdminRequeueResponse struct {
+	JobID    string `json:"job_id"`
+	UserID   string `json:"user_id"`
+	Status   string `json:"status"` // processing, or queued behind the owner's active jobs
+	Requeues int    `json:"requeues"`
+}
+
+// parseAdminJobsQuery reads the admin job list query parameters. The list reads one status
+// partition of StatusJobsIndex, so status defaults to failed rather than matching everything.
+func parseAdminJobsQuery(c *gin.Context) (repository.StatusJobsQuery, AdminJobsFilters, *errors.APIError) {
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultJobsPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxJobsPageSize {
+		pageSize = defaultJobsPageSize
+	}
+
+	filters := AdminJobsFilters{
+		Status:       c.DefaultQuery("status", domain.StatusFailed),
+		FailureStage: c.Query("failure_stage"),
+	}
+	if filters.CreatedAfter, err = parseTimeParam(c.Query("created_after")); err != nil {
+		return repository.StatusJobsQuery{}, filters, errors.NewValidationError("created_after", "Must be unix seconds or an RFC3339 timestamp")
+	}
+	if filters.CreatedBefore, err = parseTimeParam(c.Query("created_before")); err != nil {
+		return repository.StatusJobsQuery{}, filters, errors.NewValidationError("created_before", "Must be unix seconds or an RFC3339 timestamp")
+	}
+	if filters.CreatedAfter > 0 && filters.CreatedBefore > 0 && filters.CreatedAfter > filters.CreatedBefore {
+		return repository.StatusJobsQuery{}, filters, errors.NewValidationError("created_after", "created_after must not be later than created_before")
+	}
+
+	startKey, err := repository.DecodeStatusJobsCursor(c.Query("cursor"))
+	if err != nil {
+		return repository.StatusJobsQuery{}, filters, errors.NewValidationError("cursor", "Invalid pagination cursor")
+	}
+
+	return repository.StatusJobsQuery{
+		Limit:             pageSize,
+		Status:            filters.Status,
+		FailureStage:      filters.FailureStage,
+		CreatedAfter:      filters.CreatedAfter,
+		CreatedBefore:     filters.CreatedBefore,
+		ExclusiveStartKey: startKey,
+	}, filters, nil
+}
+
+// ListJobs handles GET /api/v1/admin/jobs
+// @Summary List jobs across all users
+// @Description Lists every user's jobs in a status, newest first, with their internal failure details
+// @Tags admin
+// @Produce json
+// @Param status query string false "Job status" default(failed)
+// @Param failure_stage query string false "Exact failure stage, e.g. scene_2_generating"
+// @Param created_after query string false "Only jobs created at or after this time (unix seconds or RFC3339)"
+// @Param created_before query string false "Only jobs created at or before this time (unix seconds or RFC3339)"
+// @Param page_size query int false "Jobs per page (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from a previous page's next_cursor"
+// @Success 200 {object} AdminListJobsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse "Not an admin"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/admin/jobs [get]
+// @Security BearerAuth
+func (h *AdminJobsHandler) ListJobs(c *gin.Context) {
+	query, filters, apiErr := parseAdminJobsQuery(c)
+	if apiErr != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{Error: apiErr})
+		return
+	}
+
+	page, err := h.jobs.ListJobsByStatus(c.Request.Context(), query)
+	if stderrors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list jobs for admin", zap.String("status", query.Status), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	nextCursor, err := repository.EncodeJobsCursor(page.LastEvaluatedKey)
+	if err != nil {
+		h.logger.Error("Failed to encode pagination cursor", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	jobs := make([]AdminJobSummary, len(page.Jobs))
+	for i, job := range page.Jobs {
+		jobs[i] = AdminJobSummary{
+			JobID:        job.JobID,
+			UserID:       job.UserID,
+			Status:       job.Status,
+			Stage:        job.Stage.String(),
+			FailureStage: job.FailureStage,
+			ErrorMessage: job.ErrorMessage,
+			FailureError: job.FailureError,
+			Model:        job.Model,
+			Duration:     job.Duration,
+			Requeues:     job.Requeues,
+			CreatedAt:    job.CreatedAt,
+			UpdatedAt:    job.UpdatedAt,
+		}
+	}
+
+	h.logger.Info("Admin listed jobs",
+		zap.String("admin_id", auth.MustGetUserID(c)),
+		zap.String("status", query.Status),
+		zap.String("failure_stage", query.FailureStage),
+		zap.Int("count", len(jobs)),
+	)
+
+	c.JSON(http.StatusOK, AdminListJobsResponse{
+		Jobs:       jobs,
+		Count:      len(jobs),
+		PageSize:   query.Limit,
+		NextCursor: nextCursor,
+		Truncated:  page.Truncated,
+		Filters:    filters,
+	})
+}
+
+// GetJob handles GET /api/v1/admin/jobs/:id
+// @Summary Get any user's job
+// @Description Returns the full job record, including fields hidden from its owner such as the raw failure error and clip versions. The webhook signing secret is never returned.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} domain.Job
+// @Failure 403 {object} errors.ErrorResponse "Not an admin"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/admin/jobs/{id} [get]
+// @Security BearerAuth
+func (h *AdminJobsHandler) GetJob(c *gin.Context) {
+	job, ok := h.loadJob(c)
+	if !ok {
+		return
+	}
+
+	h.logger.Info("Admin viewed job",
+		zap.String("admin_id", auth.MustGetUserID(c)),
+		zap.String("job_id", job.JobID),
+		zap.String("user_id", job.UserID),
+	)
+	c.JSON(http.StatusOK, job)
+}
+
+// RequeueJob handles POST /api/v1/admin/jobs/:id/requeue
+// @Summary Rerun a failed job
+// @Description Reruns a failed job as its owner, from its script when one was generated. The job counts against the owner's active job limit and may be queued. The rerun is not charged.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} AdminRequeueResponse
+// @Failure 403 {object} errors.ErrorResponse "Not an admin"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job has not failed, is a variant parent, or was modified concurrently"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/admin/jobs/{id}/requeue [post]
+// @Security BearerAuth
+func (h *AdminJobsHandler) RequeueJob(c *gin.Context) {
+	if h.requeuer == nil {
+		c.JSON(http.StatusServiceUnavailable, errors.ErrorResponse{
+			Error: errors.ErrServiceUnavailable,
+		})
+		return
+	}
+
+	job, ok := h.loadJob(c)
+	if !ok {
+		return
+	}
+
+	started, err := h.requeuer.RequeueJob(c.Request.Context(), job)
+	switch {
+	case stderrors.Is(err, errJobNotFailed):
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				fmt.Sprintf("Only failed jobs can be requeued (status: %s)", job.Status), nil),
+		})
+		return
+	case stderrors.Is(err, errVariantParent):
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"Variant parents can't be requeued; requeue each failed variant", map[string]interface{}{
+					"variant_job_ids": job.VariantJobIDs,
+				}),
+		})
+		return
+	case stderrors.Is(err, repository.ErrVersionConflict), stderrors.Is(err, repository.ErrJobCancelRequested):
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"Job was modified by another request. Please retry.", nil),
+		})
+		return
+	case err != nil:
+		h.logger.Error("Failed to requeue job", zap.String("job_id", job.JobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	// A started pipeline owns job now, so the response is built from what requeueing returned
+	status := domain.StatusQueued
+	if started {
+		status = domain.StatusProcessing
+	}
+	h.logger.Info("Admin requeued job",
+		zap.String("admin_id", auth.MustGetUserID(c)),
+		zap.String("job_id", job.JobID),
+		zap.String("user_id", job.UserID),
+		zap.String("status", status),
+	)
+	c.JSON(http.StatusAccepted, AdminRequeueResponse{
+		JobID:    job.JobID,
+		UserID:   job.UserID,
+		Status:   status,
+		Requeues: job.Requeues,
+	})
+}
+
+// loadJob reads the job named by the :id path parameter, responding with an error when it can't
+func (h *AdminJobsHandler) loadJob(c *gin.Context) (*domain.Job, bool) {
+	jobID := c.Param("id")
+	job, err := h.jobs.GetJob(c.Request.Context(), jobID)
+	if stderrors.Is(err, repository.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("Failed to get job for admin", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return nil, false
+	}
+	return job, true
+}
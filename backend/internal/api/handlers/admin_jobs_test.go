@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeAdminJobStore serves jobs by ID and records the list query it was given
+type fakeAdminJobStore struct {
+	jobs  map[string]*domain.Job
+	page  *repository.JobsPage
+	query repository.StatusJobsQuery
+}
+
+func (f *fakeAdminJobStore) GetJob(_ context.Context, jobID string) (*domain.Job, error) {
+	job, ok := f.jobs[jobID]
+	if !ok {
+		return nil, repository.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (f *fakeAdminJobStore) ListJobsByStatus(_ context.Context, query repository.StatusJobsQuery) (*repository.JobsPage, error) {
+	f.query = query
+	return f.page, nil
+}
+
+// fakeRequeuer resets jobs the way GenerateHandler does without running them
+type fakeRequeuer struct {
+	requeued []string
+	startNow bool
+}
+
+func (f *fakeRequeuer) RequeueJob(_ context.Context, job *domain.Job) (bool, error) {
+	if err := resetFailedJob(job, time.Now(), 0); err != nil {
+		return false, err
+	}
+	f.requeued = append(f.requeued, job.JobID)
+	return f.startNow, nil
+}
+
+func adminRouter(h *AdminJobsHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := router.Group("/api/v1/admin", func(c *gin.Context) {
+		auth.SetUserClaims(c, &domain.UserClaims{Sub: "support-1", Groups: []string{"admin"}})
+	}, auth.RequireGroup("admin", zap.NewNop()))
+	admin.GET("/jobs", h.ListJobs)
+	admin.GET("/jobs/:id", h.GetJob)
+	admin.POST("/jobs/:id/requeue", h.RequeueJob)
+	return router
+}
+
+func failedJob(jobID, userID string) *domain.Job {
+	message := "Scene generation failed. Please try again. (Error: timeout)"
+	return &domain.Job{
+		JobID:           jobID,
+		UserID:          userID,
+		Status:          domain.StatusFailed,
+		Stage:           domain.SceneGenerating(2),
+		FailureStage:    "scene_2_generating",
+		FailureError:    "kling: prediction p-123 failed: CUDA out of memory",
+		ErrorMessage:    &message,
+		ScenesCompleted: 1,
+		ClipVersions:    map[string]string{"scene-1-v1": "s3://bucket/users/" + userID + "/jobs/" + jobID + "/clips/scene-1-v1.mp4"},
+		CallbackSecret:  "whsec_never_shown",
+		CreatedAt:       1000,
+	}
+}
+
+func TestAdminListJobs_AcrossUsersWithInternalFields(t *testing.T) {
+	store := &fakeAdminJobStore{page: &repository.JobsPage{
+		Jobs: []*domain.Job{failedJob("job-2", "user-b"), failedJob("job-1", "user-a")},
+		LastEvaluatedKey: map[string]types.AttributeValue{
+			"job_id":     &types.AttributeValueMemberS{Value: "job-1"},
+			"status":     &types.AttributeValueMemberS{Value: domain.StatusFailed},
+			"created_at": &types.AttributeValueMemberN{Value: "1000"},
+		},
+	}}
+	router := adminRouter(&AdminJobsHandler{jobs: store, logger: zap.NewNop()})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs?failure_stage=scene_2_generating&created_after=900&page_size=2", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.Equal(t, repository.StatusJobsQuery{
+		Limit:        2,
+		Status:       domain.StatusFailed, // The default
+		FailureStage: "scene_2_generating",
+		CreatedAfter: 900,
+	}, store.query)
+
+	var resp AdminListJobsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Jobs, 2)
+	require.Equal(t, "user-b", resp.Jobs[0].UserID)
+	require.Equal(t, "user-a", resp.Jobs[1].UserID)
+	require.Equal(t, "kling: prediction p-123 failed: CUDA out of memory", resp.Jobs[0].FailureError)
+	require.NotEmpty(t, resp.NextCursor)
+
+	// The cursor resumes the same status partition
+	key, err := repository.DecodeStatusJobsCursor(resp.NextCursor)
+	require.NoError(t, err)
+	require.Equal(t, "job-1", key["job_id"].(*types.AttributeValueMemberS).Value)
+}
+
+func TestAdminListJobs_RejectsBadParameters(t *testing.T) {
+	router := adminRouter(&AdminJobsHandler{jobs: &fakeAdminJobStore{}, logger: zap.NewNop()})
+
+	for _, query := range []string{"created_after=yesterday", "created_after=2000&created_before=1000", "cursor=not-a-cursor"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs?"+query, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestAdminGetJob_IncludesHiddenFields(t *testing.T) {
+	store := &fakeAdminJobStore{jobs: map[string]*domain.Job{"job-1": failedJob("job-1", "user-a")}}
+	router := adminRouter(&AdminJobsHandler{jobs: store, logger: zap.NewNop()})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs/job-1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "user-a", body["user_id"])
+	require.Equal(t, "scene_2_generating", body["failure_stage"])
+	require.Equal(t, "kling: prediction p-123 failed: CUDA out of memory", body["failure_error"])
+	require.Contains(t, body["clip_versions"], "scene-1-v1")
+	require.NotContains(t, w.Body.String(), "whsec_never_shown")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminRequeueJob(t *testing.T) {
+	completed := &domain.Job{JobID: "job-done", UserID: "user-a", Status: domain.StatusCompleted}
+	failed := failedJob("job-1", "user-a")
+	store := &fakeAdminJobStore{jobs: map[string]*domain.Job{"job-1": failed, "job-done": completed}}
+	requeuer := &fakeRequeuer{startNow: true}
+	router := adminRouter(&AdminJobsHandler{jobs: store, requeuer: requeuer, logger: zap.NewNop()})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/jobs/job-1/requeue", nil))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	var resp AdminRequeueResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, AdminRequeueResponse{JobID: "job-1", UserID: "user-a", Status: domain.StatusProcessing, Requeues: 1}, resp)
+	require.Equal(t, []string{"job-1"}, requeuer.requeued)
+
+	// Only failed jobs can be rerun
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/jobs/job-done/requeue", nil))
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, []string{"job-1"}, requeuer.requeued)
+
+	// Requeued behind the owner's active jobs
+	queued := failedJob("job-2", "user-a")
+	store.jobs["job-2"] = queued
+	requeuer.startNow = false
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/jobs/job-2/requeue", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, domain.StatusQueued, resp.Status)
+}
+
+func TestResetFailedJob(t *testing.T) {
+	job := failedJob("job-1", "user-a")
+	job.Requeues = 1
+	job.AudioURL = "s3://bucket/jobs/job-1/audio/music.mp3"
+	job.NarratorAudioURL = "s3://bucket/jobs/job-1/audio/narrator.mp3"
+	now := time.Unix(2000, 0)
+
+	require.NoError(t, resetFailedJob(job, now, 7))
+	require.Equal(t, domain.StatusProcessing, job.Status)
+	require.Nil(t, job.ErrorMessage)
+	require.Empty(t, job.FailureStage)
+	require.Empty(t, job.FailureError)
+	require.Empty(t, job.ClipVersions, "the failure deleted the clips")
+	require.Zero(t, job.ScenesCompleted)
+	require.Empty(t, job.AudioURL, "stale audio would count toward progress")
+	require.Empty(t, job.NarratorAudioURL)
+	require.Equal(t, 2, job.Requeues)
+	require.Equal(t, now.AddDate(0, 0, 7).Unix(), job.ExpiresAt, "retention restarts with the rerun")
+	require.Equal(t, "user-a", job.UserID)
+
+	require.ErrorIs(t, resetFailedJob(job, now, 7), errJobNotFailed)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ApproveRequest represents optional edits applied when approving a previewed script
+type ApproveRequest struct {
+	Scenes         []SceneEdit `json:"scenes,omitempty"`
+	NarratorScript *string     `json:"narrator_script,omitempty"`
+}
+
+// SceneEdit replaces the generation prompt of a single scene
+type SceneEdit struct {
+	SceneNumber      int    `json:"scene_number"`
+	GenerationPrompt string `json:"generation_prompt"`
+}
+
+// ApproveResponse represents the response after approving a script
+type ApproveResponse struct {
+	JobID               string `json:"job_id"`
+	Status              string `json:"status"`
+	NumClips            int    `json:"num_clips"`
+	EstimatedCompletion int    `json:"estimated_completion_seconds"`
+}
+
+// ApproveJob handles POST /api/v1/jobs/:id/approve
+// @Summary Approve a previewed script
+// @Description Resumes video generation for a job created with preview=true, optionally applying edited scene prompts and narrator script. The job's credits are charged here rather than when the preview was created.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body ApproveRequest false "Optional script edits"
+// @Success 202 {object} ApproveResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 402 {object} errors.ErrorResponse "Not enough credits (details include the required credits, remaining balance and cost breakdown)"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse "Invalid script edits"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/approve [post]
+// @Security BearerAuth
+func (h *GenerateHandler) ApproveJob(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := auth.MustGetUserID(c)
+
+	var req ApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	job, err := h.jobRepo.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		if err == repository.ErrJobNotFound {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error: errors.ErrJobNotFound,
+			})
+			return
+		}
+
+		h.logger.Error("Failed to get job for approval", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	// Verify job belongs to the current user (security check)
+	if job.UserID != userID {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return
+	}
+
+	if job.Status != domain.StatusScriptReady {
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				fmt.Sprintf("Job is not awaiting approval (status: %s)", job.Status), nil),
+		})
+		return
+	}
+
+	// DynamoDB TTL deletion is lazy, so an expired preview may still be readable
+	if job.TTL > 0 && job.TTL < time.Now().Unix() {
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"Script preview has expired. Please generate a new video.", nil),
+		})
+		return
+	}
+
+	if errs := applyApprovalEdits(job, req, h.audioConfig.NarrationPacing); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	if err := job.AdvanceStage(domain.StageScriptComplete); err != nil {
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				fmt.Sprintf("Job is not awaiting approval (stage: %s)", job.Stage), nil),
+		})
+		return
+	}
+	now := time.Now()
+	job.Status = domain.StatusProcessing
+	job.UpdatedAt = now.Unix()
+	job.ExpiresAt, job.TTL = jobExpiry(now, h.retentionDays)
+
+	// The preview wasn't charged when it was created; its credits are taken now, priced like
+	// the estimate for its duration
+	charged := false
+	if h.usageService != nil && job.CreditsCharged == 0 {
+		adapterType, _ := adapters.ParseAdapterType(job.Model)
+		cost := pricing.QuoteJob(job.Duration, pricing.EstimateSceneCount(job.Duration, adapterType), adapterType)
+		if _, err := h.usageService.ChargeJob(c.Request.Context(), job, subscriptionTier(c), cost); err != nil {
+			respondChargeError(c, h.logger, userID, err, cost)
+			return
+		}
+		charged = true
+	}
+
+	// Approved jobs count against the active job limit like new ones
+	startNow, err := h.saveNewJob(c.Request.Context(), job, h.jobRepo.UpdateJob)
+	if err != nil {
+		if charged {
+			h.refundCredits(job)
+		}
+		if stderrors.Is(err, repository.ErrJobCancelRequested) {
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict, "Job was canceled", nil),
+			})
+			return
+		}
+		if stderrors.Is(err, repository.ErrVersionConflict) {
+			// Another approval or edit got there first; a retry sees its result
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict,
+					"Job was modified by another request. Please retry.", nil),
+			})
+			return
+		}
+		h.logger.Error("Failed to update approved job", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	if startNow {
+		h.runInBackground(c.Request.Context(), job, func(ctx context.Context) {
+			h.resumeApprovedJob(ctx, job)
+		})
+	}
+
+	h.logger.Info("Script approved, generation resumed",
+		zap.String("job_id", jobID),
+		zap.Int("edited_scenes", len(req.Scenes)),
+		zap.Bool("edited_narrator_script", req.NarratorScript != nil),
+	)
+
+	c.JSON(http.StatusAccepted, ApproveResponse{
+		JobID:               jobID,
+		Status:              job.Status,
+		NumClips:            len(job.Scenes),
+		EstimatedCompletion: EstimatedCompletionSeconds,
+	})
+}
+
+// applyApprovalEdits validates user edits against the stored script and applies them to the job,
+// capping narration at pacing's words per second. Edits are applied only if all of them are valid.
+func applyApprovalEdits(job *domain.Job, req ApproveRequest, pacing domain.NarrationPacing) validation.Errors {
+	var errs validation.Errors
+	seen := make(map[int]bool, len(req.Scenes))
+	prompts := make(map[int]string, len(req.Scenes))
+
+	for i, edit := range req.Scenes {
+		field := fmt.Sprintf("scenes[%d]", i)
+		if !validation.ValidateSceneNumber(&errs, field+".scene_number", edit.SceneNumber, len(job.Scenes)) {
+			continue
+		}
+		if seen[edit.SceneNumber] {
+			errs.Add(field+".scene_number", fmt.Sprintf("Scene %d is edited more than once", edit.SceneNumber))
+			continue
+		}
+		seen[edit.SceneNumber] = true
+
+		prompt := strings.TrimSpace(edit.GenerationPrompt)
+		if validation.ValidateScenePrompt(&errs, field+".generation_prompt", edit.SceneNumber, prompt) {
+			prompts[edit.SceneNumber] = prompt
+		}
+	}
+
+	var narratorScript string
+	if req.NarratorScript != nil {
+		narratorScript = strings.TrimSpace(*req.NarratorScript)
+		// A natural speaking rate in the job's language (~150 wpm in English)
+		maxWords := int(float64(job.Duration) * pacing.WordsPerSecond(job.Language))
+
+		switch words := len(strings.Fields(narratorScript)); {
+		// Pharmaceutical narration is regenerated to fit the disclaimer timing budget
+		case job.SideEffectsText != "":
+			errs.Add("narrator_script",
+				"Narrator script cannot be edited for pharmaceutical ads; it is generated to fit the side effects disclosure")
+		case narratorScript == "":
+			errs.Add("narrator_script", "Narrator script cannot be empty")
+		case words > maxWords:
+			errs.Add("narrator_script",
+				fmt.Sprintf("Narrator script is too long for a %ds video (%d words, max %d)", job.Duration, words, maxWords))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for sceneNumber, prompt := range prompts {
+		job.Scenes[sceneNumber-1].GenerationPrompt = prompt
+	}
+	if req.NarratorScript != nil {
+		job.AudioSpec.NarratorScript = narratorScript
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestApplyApprovalEdits(t *testing.T) {
+	validPrompt := "Wide establishing shot of a sunlit kitchen, camera slowly dollies toward a steaming mug on the counter"
+
+	newJob := func() *domain.Job {
+		return &domain.Job{
+			Duration: 10,
+			Scenes: []domain.Scene{
+				{SceneNumber: 1, GenerationPrompt: "original prompt one"},
+				{SceneNumber: 2, GenerationPrompt: "original prompt two"},
+			},
+			AudioSpec: domain.AudioSpec{NarratorScript: "Original narration."},
+		}
+	}
+	strPtr := func(s string) *string { return &s }
+
+	t.Run("valid edits are applied", func(t *testing.T) {
+		job := newJob()
+		errs := applyApprovalEdits(job, ApproveRequest{
+			Scenes:         []SceneEdit{{SceneNumber: 2, GenerationPrompt: "  " + validPrompt + "  "}},
+			NarratorScript: strPtr("Start every morning right."),
+		}, nil)
+
+		require.Empty(t, errs)
+		require.Equal(t, "original prompt one", job.Scenes[0].GenerationPrompt)
+		require.Equal(t, validPrompt, job.Scenes[1].GenerationPrompt)
+		require.Equal(t, "Start every morning right.", job.AudioSpec.NarratorScript)
+	})
+
+	t.Run("all invalid edits are reported together", func(t *testing.T) {
+		errs := applyApprovalEdits(newJob(), ApproveRequest{
+			Scenes: []SceneEdit{
+				{SceneNumber: 0, GenerationPrompt: validPrompt},
+				{SceneNumber: 2, GenerationPrompt: ""},
+			},
+			NarratorScript: strPtr(""),
+		}, nil)
+
+		require.Len(t, errs, 3)
+		require.Equal(t, "scenes[0].scene_number", errs[0].Field)
+		require.Equal(t, "scenes[1].generation_prompt", errs[1].Field)
+		require.Equal(t, "narrator_script", errs[2].Field)
+	})
+
+	t.Run("empty request leaves script untouched", func(t *testing.T) {
+		job := newJob()
+		require.Empty(t, applyApprovalEdits(job, ApproveRequest{}, nil))
+		require.Equal(t, newJob(), job)
+	})
+
+	tests := []struct {
+		name            string
+		mutateJob       func(*domain.Job)
+		req             ApproveRequest
+		expectedField   string
+		expectedMessage string
+	}{
+		{
+			name:            "short prompt is rejected",
+			req:             ApproveRequest{Scenes: []SceneEdit{{SceneNumber: 1, GenerationPrompt: "a mug"}}},
+			expectedField:   "scenes[1].generation_prompt",
+			expectedMessage: "Scene 1 prompt has suspiciously short generation_prompt",
+		},
+		{
+			name:            "placeholder prompt is rejected",
+			req:             ApproveRequest{Scenes: []SceneEdit{{SceneNumber: 1, GenerationPrompt: validPrompt + " [insert product]"}}},
+			expectedField:   "scenes[1].generation_prompt",
+			expectedMessage: "placeholder text",
+		},
+		{
+			name:            "out of range scene is rejected",
+			req:             ApproveRequest{Scenes: []SceneEdit{{SceneNumber: 3, GenerationPrompt: validPrompt}}},
+			expectedField:   "scenes[1].scene_number",
+			expectedMessage: "Invalid scene number 3. Job has 2 scenes.",
+		},
+		{
+			name: "duplicate scene is rejected",
+			req: ApproveRequest{Scenes: []SceneEdit{
+				{SceneNumber: 1, GenerationPrompt: validPrompt},
+				{SceneNumber: 1, GenerationPrompt: validPrompt},
+			}},
+			expectedField:   "scenes[2].scene_number",
+			expectedMessage: "Scene 1 is edited more than once",
+		},
+		{
+			name:            "pharma narrator edit is rejected",
+			mutateJob:       func(j *domain.Job) { j.SideEffectsText = "May cause drowsiness." },
+			req:             ApproveRequest{NarratorScript: strPtr("New narration.")},
+			expectedField:   "narrator_script",
+			expectedMessage: "cannot be edited for pharmaceutical ads",
+		},
+		{
+			name:            "blank narrator script is rejected",
+			req:             ApproveRequest{NarratorScript: strPtr("   ")},
+			expectedField:   "narrator_script",
+			expectedMessage: "Narrator script cannot be empty",
+		},
+		{
+			name:            "narrator script too long is rejected",
+			req:             ApproveRequest{NarratorScript: strPtr(strings.Repeat("word ", 26))},
+			expectedField:   "narrator_script",
+			expectedMessage: "too long for a 10s video (26 words, max 25)",
+		},
+		{
+			name:            "German narrator script is held to German pacing",
+			mutateJob:       func(j *domain.Job) { j.Language = "de-DE" },
+			req:             ApproveRequest{NarratorScript: strPtr(strings.Repeat("Wort ", 21))},
+			expectedField:   "narrator_script",
+			expectedMessage: "too long for a 10s video (21 words, max 20)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := newJob()
+			if tt.mutateJob != nil {
+				tt.mutateJob(job)
+			}
+			// A valid edit alongside the invalid one must not be partially applied
+			tt.req.Scenes = append([]SceneEdit{{SceneNumber: 2, GenerationPrompt: validPrompt}}, tt.req.Scenes...)
+
+			errs := applyApprovalEdits(job, tt.req, domain.DefaultNarrationPacing)
+
+			require.Len(t, errs, 1)
+			require.Equal(t, tt.expectedField, errs[0].Field)
+			require.Contains(t, errs[0].Message, tt.expectedMessage)
+			require.Equal(t, "original prompt two", job.Scenes[1].GenerationPrompt)
+			require.Equal(t, "Original narration.", job.AudioSpec.NarratorScript)
+		})
+	}
+}
+
+func TestApproveJob_ChargesPreview(t *testing.T) {
+	ctx := context.Background()
+	dynamo := repository.NewLocalDynamoDB()
+	jobRepo := dynamo.JobRepository("jobs", zap.NewNop())
+	usageRepo := dynamo.UsageRepository("usage", zap.NewNop())
+
+	now := time.Now().Unix()
+	// user-123 is at the active job limit, so the approved preview is queued rather than run
+	require.NoError(t, jobRepo.CreateJob(ctx, &domain.Job{JobID: "job-active", UserID: "user-123", Status: domain.StatusProcessing, CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, jobRepo.CreateJob(ctx, &domain.Job{
+		JobID:     "job-preview",
+		UserID:    "user-123",
+		Status:    domain.StatusScriptReady,
+		Stage:     domain.StageScriptReady,
+		Preview:   true,
+		Duration:  16,
+		Model:     "veo",
+		Scenes:    []domain.Scene{{SceneNumber: 1, Duration: 8}, {SceneNumber: 2, Duration: 8}},
+		TTL:       now + 3600,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	generate := NewGenerateHandler(GenerateHandlerDeps{
+		JobRepo:              jobRepo,
+		UsageService:         service.NewUsageService(usageRepo, zap.NewNop()),
+		MaxActiveJobsPerUser: 1,
+		AssetsBucket:         "assets",
+	}, zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, c.GetHeader("X-Test-User"))
+	})
+	v1.POST("/jobs/:id/approve", generate.ApproveJob)
+
+	charges, err := usageRepo.ListCharges(ctx, "user-123", repository.GetCurrentPeriod())
+	require.NoError(t, err)
+	require.Empty(t, charges, "the preview wasn't charged when it was created")
+
+	w := serveAsUser(router, http.MethodPost, "/api/v1/jobs/job-preview/approve", "user-123", nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	job, err := jobRepo.GetJob(ctx, "job-preview")
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusQueued, job.Status)
+	require.Positive(t, job.CreditsCharged)
+	usage, err := usageRepo.GetUsage(ctx, "user-123", job.CreditPeriod)
+	require.NoError(t, err)
+	require.Equal(t, usage.MonthlyCredits-job.CreditsCharged, usage.CreditsRemaining)
+	charges, err = usageRepo.ListCharges(ctx, "user-123", job.CreditPeriod)
+	require.NoError(t, err)
+	require.Len(t, charges, 1)
+	require.Equal(t, job.CreditsCharged, charges[0].Credits)
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/assetcheck"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ListAssets page size bounds
+const (
+	defaultAssetsPageSize = 20
+	maxAssetsPageSize     = 100
+
+	// libraryURLExpiry is how long a listed asset's presigned URL stays valid
+	libraryURLExpiry = time.Hour
+
+	// imageHeaderBytes is how much of an image is read for its dimensions; a JPEG's can follow a
+	// large EXIF block
+	imageHeaderBytes = 256 * 1024
+)
+
+// S3 user metadata cached on an upload the first time the library lists it
+const (
+	dimensionsMetadataKey = "dimensions"  // "WIDTHxHEIGHT", or "unknown" for an unreadable image header
+	uploadedAtMetadataKey = "uploaded-at" // Unix time of the upload, as caching the metadata resets LastModified
+	unknownDimensions     = "unknown"
+)
+
+// AssetLibraryHandler serves the user's uploads, so an image uploaded once can be reused by
+// later jobs
+type AssetLibraryHandler struct {
+	s3Service    *repository.S3AssetRepository
+	assetsBucket string
+	locator      *repository.AssetLocator
+	logger       *zap.Logger
+}
+
+// NewAssetLibraryHandler creates a new asset library handler
+func NewAssetLibraryHandler(
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	locator *repository.AssetLocator,
+	logger *zap.Logger,
+) *AssetLibraryHandler {
+	return &AssetLibraryHandler{
+		s3Service:    s3Service,
+		assetsBucket: assetsBucket,
+		locator:      locator,
+		logger:       logger,
+	}
+}
+
+// LibraryAsset is one of the user's uploads
+type LibraryAsset struct {
+	Key         string `json:"key"` // Usable as a generate request's start_image or style_reference_image
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	UploadedAt  int64  `json:"uploaded_at"`      // Unix timestamp
+	Width       int    `json:"width,omitempty"`  // Images only, when their header could be read
+	Height      int    `json:"height,omitempty"` // Images only, when their header could be read
+	URL         string `json:"url"`              // Presigned download URL, valid for an hour
+}
+
+// ListAssetsResponse is one page of the user's uploads, in key order
+type ListAssetsResponse struct {
+	Assets     []LibraryAsset `json:"assets"`
+	Count      int            `json:"count"`
+	PageSize   int            `json:"page_size"`
+	NextCursor string         `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// ListAssets handles GET /api/v1/assets
+// @Summary List uploaded assets
+// @Description Get a page of the user's uploaded product and style reference images and videos. An image's dimensions are read the first time it is listed and cached on the object, so the UI can leave out images too small to use.
+// @Tags upload
+// @Produce json
+// @Param cursor query string false "Opaque cursor from a previous response's next_cursor"
+// @Param page_size query int false "Page size (1-100)" default(20)
+// @Success 200 {object} ListAssetsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/assets [get]
+// @Security BearerAuth
+func (h *AssetLibraryHandler) ListAssets(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultAssetsPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxAssetsPageSize {
+		pageSize = defaultAssetsPageSize
+	}
+
+	page, err := h.s3Service.ListObjectsPage(c.Request.Context(), h.assetsBucket, h.locator.UploadPrefix(userID), c.Query("cursor"), int32(pageSize))
+	if stderrors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list assets", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	assets := make([]LibraryAsset, 0, len(page.Objects))
+	for _, object := range page.Objects {
+		asset, err := h.describe(c.Request.Context(), object)
+		if stderrors.Is(err, repository.ErrObjectNotFound) {
+			continue // Deleted since the listing
+		}
+		if err != nil {
+			h.logger.Error("Failed to describe asset", zap.String("s3_key", object.Key), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error: errors.ErrInternalServer,
+			})
+			return
+		}
+		assets = append(assets, asset)
+	}
+
+	c.JSON(http.StatusOK, ListAssetsResponse{
+		Assets:     assets,
+		Count:      len(assets),
+		PageSize:   pageSize,
+		NextCursor: page.NextToken,
+	})
+}
+
+// describe reads a listed upload's content type and metadata, caching its dimensions on it if
+// it is an image listed for the first time
+func (h *AssetLibraryHandler) describe(ctx context.Context, object repository.ListedObject) (LibraryAsset, error) {
+	info, err := h.s3Service.ObjectInfo(ctx, h.assetsBucket, object.Key)
+	if err != nil {
+		return LibraryAsset{}, err
+	}
+	asset := LibraryAsset{
+		Key:         object.Key,
+		ContentType: info.ContentType,
+		SizeBytes:   object.Size,
+		UploadedAt:  object.LastModified.Unix(),
+	}
+	if asset.ContentType == "" || asset.ContentType == "application/octet-stream" {
+		asset.ContentType = assetContentType(object.Key)
+	}
+	if uploadedAt, err := strconv.ParseInt(info.Metadata[uploadedAtMetadataKey], 10, 64); err == nil {
+		asset.UploadedAt = uploadedAt
+	}
+
+	if strings.HasPrefix(asset.ContentType, "image/") {
+		dimensions, ok := info.Metadata[dimensionsMetadataKey]
+		if !ok {
+			dimensions = h.cacheDimensions(ctx, object.Key, asset.ContentType, asset.UploadedAt, info.Metadata)
+		}
+		asset.Width, asset.Height = parseDimensions(dimensions)
+	}
+
+	asset.URL, err = h.s3Service.GetPresignedURLInBucket(ctx, h.assetsBucket, object.Key, libraryURLExpiry)
+	if err != nil {
+		return LibraryAsset{}, err
+	}
+	return asset, nil
+}
+
+// cacheDimensions reads an image's dimensions from its header and stores them in its metadata,
+// with its upload time. It returns them as they are stored, or "" when the image can't be read;
+// failing to store them only means they are read again next time.
+func (h *AssetLibraryHandler) cacheDimensions(ctx context.Context, key, contentType string, uploadedAt int64, metadata map[string]string) string {
+	body, err := h.s3Service.OpenObject(ctx, h.assetsBucket, key)
+	if err != nil {
+		h.logger.Warn("Failed to open asset for its dimensions", zap.String("s3_key", key), zap.Error(err))
+		return ""
+	}
+	header, err := io.ReadAll(io.LimitReader(body, imageHeaderBytes))
+	body.Close()
+	if err != nil {
+		h.logger.Warn("Failed to read asset for its dimensions", zap.String("s3_key", key), zap.Error(err))
+		return ""
+	}
+
+	dimensions := unknownDimensions
+	if width, height, ok := assetcheck.ImageSize(header); ok {
+		dimensions = fmt.Sprintf("%dx%d", width, height)
+	}
+
+	cached := make(map[string]string, len(metadata)+2)
+	for name, value := range metadata {
+		cached[name] = value
+	}
+	cached[dimensionsMetadataKey] = dimensions
+	cached[uploadedAtMetadataKey] = strconv.FormatInt(uploadedAt, 10)
+	_ = h.s3Service.ReplaceObjectMetadata(ctx, h.assetsBucket, key, contentType, cached)
+	return dimensions
+}
+
+// parseDimensions reads dimensions cached as "WIDTHxHEIGHT"; anything else is no dimensions
+func parseDimensions(dimensions string) (width, height int) {
+	w, h, ok := strings.Cut(dimensions, "x")
+	if !ok {
+		return 0, 0
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil {
+		return 0, 0
+	}
+	return width, height
+}
+
+// DeleteAsset handles DELETE /api/v1/assets/*key
+// @Summary Delete an uploaded asset
+// @Description Delete one of the user's uploads. The key is a listed asset's key, the rest of the path after /assets/; its slashes may also be URL-encoded. Jobs that already used the asset keep their videos.
+// @Tags upload
+// @Param key path string true "Asset key"
+// @Success 204 "Deleted"
+// @Failure 403 {object} errors.ErrorResponse "Asset belongs to another user"
+// @Failure 404 {object} errors.ErrorResponse "Asset not found"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/assets/{key} [delete]
+// @Security BearerAuth
+func (h *AssetLibraryHandler) DeleteAsset(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !h.locator.OwnsUpload(userID, key) {
+		h.logger.Warn("User attempted to delete an asset outside their uploads",
+			zap.String("user_id", userID),
+			zap.String("s3_key", key),
+		)
+		c.JSON(http.StatusForbidden, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrForbidden, "Asset must be one you uploaded", nil),
+		})
+		return
+	}
+
+	if _, err := h.s3Service.ObjectInfo(c.Request.Context(), h.assetsBucket, key); err != nil {
+		if stderrors.Is(err, repository.ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrNotFound, "Asset not found", nil),
+			})
+			return
+		}
+		h.logger.Error("Failed to look up asset", zap.String("s3_key", key), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+	if err := h.s3Service.DeleteFile(c.Request.Context(), h.assetsBucket, key); err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	h.logger.Info("Asset deleted", zap.String("user_id", userID), zap.String("s3_key", key))
+	c.Status(http.StatusNoContent)
+}
+
+// objectStat is the subset of the S3 repository checking a referenced asset exists uses
+type objectStat interface {
+	ObjectInfo(ctx context.Context, bucket, key string) (*repository.ObjectInfo, error)
+}
+
+// resolveImageRef resolves an image a generate request gives as the key of one of the user's
+// uploads to the asset URL an upload returns, so the job uses it as if it had just been
+// uploaded. URLs are returned as they are. A key that isn't the user's, or no longer exists, is
+// added to errs.
+func resolveImageRef(ctx context.Context, storage objectStat, bucket string, locator *repository.AssetLocator, userID, field, ref string, errs *validation.Errors) string {
+	if ref == "" || strings.Contains(ref, "://") {
+		return ref
+	}
+	key := strings.TrimPrefix(ref, "/")
+	if !locator.OwnsUpload(userID, key) {
+		errs.Add(field, "Asset must be one you uploaded")
+		return ref
+	}
+	if _, err := storage.ObjectInfo(ctx, bucket, key); err != nil {
+		if stderrors.Is(err, repository.ErrObjectNotFound) {
+			errs.Add(field, "Asset not found")
+		} else {
+			errs.Add(field, "Asset could not be checked; try again shortly")
+		}
+		return ref
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, key)
+}
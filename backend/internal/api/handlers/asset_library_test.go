@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// assetLibraryTestRouter serves the asset library and POST /generate from a local S3 as the user
+// in the X-Test-User header. user-123 has an active job with a limit of one, so new jobs are queued.
+func assetLibraryTestRouter(t *testing.T) (*gin.Engine, *repository.S3AssetRepository, *repository.DynamoDBRepository) {
+	s3Service := newComposeTestS3(t)
+	jobRepo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+
+	now := time.Now().Unix()
+	require.NoError(t, jobRepo.CreateJob(context.Background(), &domain.Job{
+		JobID:     "job-active",
+		UserID:    "user-123",
+		Status:    domain.StatusProcessing,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	generate := NewGenerateHandler(GenerateHandlerDeps{
+		S3Service:            s3Service,
+		JobRepo:              jobRepo,
+		MaxActiveJobsPerUser: 1,
+		AssetsBucket:         "assets",
+	}, zap.NewNop())
+	library := NewAssetLibraryHandler(s3Service, "assets", nil, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, c.GetHeader("X-Test-User"))
+	})
+	v1.GET("/assets", library.ListAssets)
+	v1.DELETE("/assets/*key", library.DeleteAsset)
+	v1.POST("/generate", generate.Generate)
+	return router, s3Service, jobRepo
+}
+
+// serveAsUser sends a request as userID, with body as JSON unless it is nil
+func serveAsUser(router *gin.Engine, method, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("X-Test-User", userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// uploadTestAsset stores data at key in the local S3 as an upload would
+func uploadTestAsset(t *testing.T, s3Service *repository.S3AssetRepository, key, contentType string, data []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), filepath.Base(key))
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	_, err := s3Service.UploadFile(context.Background(), "assets", key, path, contentType)
+	require.NoError(t, err)
+}
+
+// testPNG encodes a blank PNG of the given size
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+// listAssets fetches one page of the user's asset library
+func listAssets(t *testing.T, router *gin.Engine, userID, query string) ListAssetsResponse {
+	t.Helper()
+	w := serveAsUser(router, http.MethodGet, "/api/v1/assets"+query, userID, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page ListAssetsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func TestListAssets_PaginatesUploadsWithDimensions(t *testing.T) {
+	router, s3Service, _ := assetLibraryTestRouter(t)
+	uploadTestAsset(t, s3Service, "users/user-123/uploads/product_images/1_bottle.png", "image/png", testPNG(t, 800, 600))
+	uploadTestAsset(t, s3Service, "users/user-123/uploads/product_images/2_icon.png", "image/png", testPNG(t, 32, 32))
+	uploadTestAsset(t, s3Service, "users/user-123/uploads/style_references/3_mood.mp4", "video/mp4", []byte("not really an mp4"))
+	uploadTestAsset(t, s3Service, "users/user-123/jobs/job-1/final/video.mp4", "video/mp4", []byte("a job's output"))
+	uploadTestAsset(t, s3Service, "users/user-456/uploads/product_images/1_other.png", "image/png", testPNG(t, 800, 600))
+
+	page := listAssets(t, router, "user-123", "?page_size=2")
+	require.Equal(t, 2, page.Count)
+	require.NotEmpty(t, page.NextCursor)
+	bottle, icon := page.Assets[0], page.Assets[1]
+	require.Equal(t, "users/user-123/uploads/product_images/1_bottle.png", bottle.Key)
+	require.Equal(t, "image/png", bottle.ContentType)
+	require.Equal(t, 800, bottle.Width)
+	require.Equal(t, 600, bottle.Height)
+	require.NotZero(t, bottle.SizeBytes)
+	require.NotZero(t, bottle.UploadedAt)
+	require.NotEmpty(t, bottle.URL)
+	require.Equal(t, 32, icon.Width, "tiny images are listed for the UI to filter")
+
+	page = listAssets(t, router, "user-123", "?page_size=2&cursor="+url.QueryEscape(page.NextCursor))
+	require.Equal(t, 1, page.Count)
+	require.Empty(t, page.NextCursor)
+	require.Equal(t, "users/user-123/uploads/style_references/3_mood.mp4", page.Assets[0].Key)
+	require.Equal(t, "video/mp4", page.Assets[0].ContentType)
+	require.Zero(t, page.Assets[0].Width)
+
+	// The dimensions are cached on the object, and its upload time survives the rewrite
+	info, err := s3Service.ObjectInfo(context.Background(), "assets", bottle.Key)
+	require.NoError(t, err)
+	require.Equal(t, "800x600", info.Metadata[dimensionsMetadataKey])
+	require.Equal(t, "image/png", info.ContentType)
+	again := listAssets(t, router, "user-123", "?page_size=1")
+	require.Equal(t, bottle.UploadedAt, again.Assets[0].UploadedAt)
+	require.Equal(t, 800, again.Assets[0].Width)
+
+	other := listAssets(t, router, "user-456", "")
+	require.Equal(t, 1, other.Count)
+	require.Equal(t, "users/user-456/uploads/product_images/1_other.png", other.Assets[0].Key)
+}
+
+func TestDeleteAsset_OnlyDeletesOwnUploads(t *testing.T) {
+	router, s3Service, _ := assetLibraryTestRouter(t)
+	mine := "users/user-123/uploads/product_images/1_bottle.png"
+	theirs := "users/user-456/uploads/product_images/1_other.png"
+	uploadTestAsset(t, s3Service, mine, "image/png", testPNG(t, 8, 8))
+	uploadTestAsset(t, s3Service, theirs, "image/png", testPNG(t, 8, 8))
+
+	w := serveAsUser(router, http.MethodDelete, "/api/v1/assets/"+url.PathEscape(theirs), "user-123", nil)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	_, err := s3Service.ObjectInfo(context.Background(), "assets", theirs)
+	require.NoError(t, err, "another user's upload is kept")
+
+	w = serveAsUser(router, http.MethodDelete, "/api/v1/assets/"+url.PathEscape("users/user-123/jobs/job-1/final/video.mp4"), "user-123", nil)
+	require.Equal(t, http.StatusForbidden, w.Code, "job outputs aren't library assets")
+
+	w = serveAsUser(router, http.MethodDelete, "/api/v1/assets/"+url.PathEscape("users/user-123/uploads/product_images/missing.png"), "user-123", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveAsUser(router, http.MethodDelete, "/api/v1/assets/"+url.PathEscape(mine), "user-123", nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	_, err = s3Service.ObjectInfo(context.Background(), "assets", mine)
+	require.ErrorIs(t, err, repository.ErrObjectNotFound)
+
+	// The key's slashes may also be sent as path separators
+	uploadTestAsset(t, s3Service, mine, "image/png", testPNG(t, 8, 8))
+	w = serveAsUser(router, http.MethodDelete, "/api/v1/assets/"+mine, "user-123", nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	_, err = s3Service.ObjectInfo(context.Background(), "assets", mine)
+	require.ErrorIs(t, err, repository.ErrObjectNotFound)
+
+	w = serveAsUser(router, http.MethodDelete, "/api/v1/assets/users/user-123/uploads/../../user-456/uploads/product_images/1_other.png", "user-123", nil)
+	require.Equal(t, http.StatusForbidden, w.Code, "a key can't climb out of the user's uploads")
+	_, err = s3Service.ObjectInfo(context.Background(), "assets", theirs)
+	require.NoError(t, err)
+}
+
+func TestGenerate_ReusesUploadedAssetKeys(t *testing.T) {
+	router, s3Service, jobRepo := assetLibraryTestRouter(t)
+	product := "users/user-123/uploads/product_images/1_bottle.png"
+	style := "users/user-123/uploads/style_references/2_mood.png"
+	uploadTestAsset(t, s3Service, product, "image/png", testPNG(t, 800, 600))
+	uploadTestAsset(t, s3Service, style, "image/png", testPNG(t, 800, 600))
+	uploadTestAsset(t, s3Service, "users/user-456/uploads/product_images/1_other.png", "image/png", testPNG(t, 800, 600))
+
+	generate := func(startImage, styleImage string) *httptest.ResponseRecorder {
+		return serveAsUser(router, http.MethodPost, "/api/v1/generate", "user-123", GenerateRequest{
+			Prompt:              "A cold brew coffee ad for busy mornings",
+			Duration:            16,
+			AspectRatio:         "16:9",
+			StartImage:          startImage,
+			StyleReferenceImage: styleImage,
+		})
+	}
+
+	w := generate(product, style)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response GenerateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	job, err := jobRepo.GetJob(context.Background(), response.JobID)
+	require.NoError(t, err)
+	require.Equal(t, "https://assets.s3.amazonaws.com/"+product, job.StartImage, "stored as the upload's asset URL")
+	require.Equal(t, "https://assets.s3.amazonaws.com/"+style, job.StyleReferenceImage)
+
+	w = generate("users/user-456/uploads/product_images/1_other.png", "")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "start_image")
+
+	w = generate("", "users/user-123/uploads/style_references/missing.png")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "style_reference_image")
+	require.Contains(t, w.Body.String(), "Asset not found")
+
+	w = generate("product.png", "")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, "a bare name is neither a URL nor an upload key")
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/assetcheck"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// assetStatusUnverified is reported for an asset that couldn't be verified, e.g. while the
+// scanner is down; nothing is recorded so it is verified again on the next reference
+const assetStatusUnverified = "unverified"
+
+// assetContentTypes maps upload extensions to the content type an asset is verified as
+var assetContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+	".pdf":  "application/pdf",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+}
+
+// AssetVerifier checks uploaded assets before jobs use them. Each result is recorded, so an
+// asset is read and scanned once: when the frontend verifies it after uploading, or else the
+// first time a job references it.
+type AssetVerifier struct {
+	repo     repository.AssetsRepository
+	storage  objectOpener
+	verifier *assetcheck.Verifier
+	bucket   string
+	locator  *repository.AssetLocator
+	logger   *zap.Logger
+}
+
+// NewAssetVerifier creates an asset verifier. scanner may be nil to skip malware scanning.
+func NewAssetVerifier(
+	repo repository.AssetsRepository,
+	storage objectOpener,
+	scanner assetcheck.Scanner,
+	assetsBucket string,
+	locator *repository.AssetLocator,
+	logger *zap.Logger,
+) *AssetVerifier {
+	return &AssetVerifier{
+		repo:     repo,
+		storage:  storage,
+		verifier: assetcheck.NewVerifier(scanner),
+		bucket:   assetsBucket,
+		locator:  locator,
+		logger:   logger,
+	}
+}
+
+// errAssetUnreadable is returned when an asset's object can't be opened
+var errAssetUnreadable = stderrors.New("asset could not be read")
+
+// verify returns the recorded result for key, verifying the asset first if it has none.
+// contentType overrides the type implied by the key's extension.
+func (v *AssetVerifier) verify(ctx context.Context, userID, key, contentType string) (*domain.Asset, error) {
+	asset, err := v.repo.GetAsset(ctx, key)
+	if err == nil {
+		return asset, nil
+	}
+	if !stderrors.Is(err, repository.ErrAssetNotFound) {
+		return nil, err
+	}
+
+	if contentType == "" {
+		contentType = assetContentType(key)
+	}
+	body, err := v.storage.OpenObject(ctx, v.bucket, key)
+	if err != nil {
+		v.logger.Info("Asset to verify could not be opened", zap.String("asset_key", key), zap.Error(err))
+		return nil, errAssetUnreadable
+	}
+	defer body.Close()
+
+	result, err := v.verifier.Verify(ctx, body, contentType)
+	if err != nil {
+		v.logger.Warn("Asset verification failed", zap.String("asset_key", key), zap.Error(err))
+		return nil, err
+	}
+
+	asset = &domain.Asset{
+		AssetKey:    key,
+		UserID:      userID,
+		Status:      domain.AssetStatusVerified,
+		ContentType: result.ContentType,
+		SizeBytes:   result.SizeBytes,
+		Width:       result.Width,
+		Height:      result.Height,
+		Threat:      result.Threat,
+		VerifiedAt:  time.Now().Unix(),
+	}
+	if result.Rejection != nil {
+		asset.Status = domain.AssetStatusRejected
+		asset.Reason = result.Rejection.Reason
+		asset.Message = result.Rejection.Message
+		v.logger.Warn("Asset rejected",
+			zap.String("asset_key", key),
+			zap.String("user_id", userID),
+			zap.String("reason", asset.Reason),
+			zap.String("threat", asset.Threat),
+		)
+	}
+	if err := v.repo.SaveAsset(ctx, asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// assetRef is an asset a job references, with the request field it came from
+type assetRef struct {
+	field string
+	key   string
+}
+
+// require verifies the assets a job references. It returns an ASSET_NOT_VERIFIED error listing
+// every asset that was rejected or couldn't be verified, or nil when all of them are verified.
+func (v *AssetVerifier) require(ctx context.Context, userID string, refs []assetRef) *errors.APIError {
+	var failed []map[string]interface{}
+	for _, ref := range refs {
+		asset, err := v.verify(ctx, userID, ref.key, "")
+		if err != nil {
+			failed = append(failed, map[string]interface{}{
+				"field":   ref.field,
+				"asset":   ref.key,
+				"status":  assetStatusUnverified,
+				"message": "Asset could not be verified yet; try again shortly",
+			})
+			continue
+		}
+		if asset.Status != domain.AssetStatusVerified {
+			failed = append(failed, map[string]interface{}{
+				"field":   ref.field,
+				"asset":   ref.key,
+				"status":  asset.Status,
+				"reason":  asset.Reason,
+				"message": asset.Message,
+			})
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.NewAPIError(errors.ErrAssetNotVerified, "", map[string]interface{}{
+		"assets": failed,
+	})
+}
+
+// assetContentType returns the content type implied by key's extension
+func assetContentType(key string) string {
+	if contentType, ok := assetContentTypes[strings.ToLower(filepath.Ext(key))]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// jobAssetRefs lists the user's uploads a generate request references. Start and style images
+// given as external URLs aren't uploads and are left out.
+func jobAssetRefs(locator *repository.AssetLocator, userID string, req GenerateRequest) []assetRef {
+	var refs []assetRef
+	for i, img := range req.ProductImages {
+		refs = append(refs, assetRef{field: fmt.Sprintf("product_images[%d].asset", i), key: img.Asset})
+	}
+	if req.LogoOverlay != nil && req.LogoOverlay.Asset != "" {
+		refs = append(refs, assetRef{field: "logo_overlay.asset", key: req.LogoOverlay.Asset})
+	}
+	if key, ok := ownedAssetKey(locator, userID, req.StartImage); ok {
+		refs = append(refs, assetRef{field: "start_image", key: key})
+	}
+	if key, ok := ownedAssetKey(locator, userID, req.StyleReferenceImage); ok {
+		refs = append(refs, assetRef{field: "style_reference_image", key: key})
+	}
+	return refs
+}
+
+// VerifyAssetRequest asks for one uploaded asset to be verified
+type VerifyAssetRequest struct {
+	Asset       string `json:"asset" binding:"required"` // S3 key or asset URL of the upload
+	ContentType string `json:"content_type,omitempty"`   // Defaults to the type implied by the file extension
+}
+
+// VerifyAsset godoc
+// @Summary Verify an uploaded asset
+// @Description Checks that an upload's bytes match its type, are within size and pixel limits, carry no PDF JavaScript and pass the malware scan. Results are recorded, so verifying the same asset again returns the first result. Jobs only accept verified assets, verifying any that haven't been.
+// @Tags upload
+// @Accept json
+// @Produce json
+// @Param body body VerifyAssetRequest true "Asset to verify"
+// @Success 200 {object} domain.Asset "Verification result; status is verified or rejected"
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse "Asset belongs to another user"
+// @Failure 404 {object} errors.ErrorResponse "Asset not found"
+// @Failure 503 {object} errors.ErrorResponse "Asset could not be scanned; retry later"
+// @Router /api/v1/assets/verify [post]
+// @Security BearerAuth
+func (v *AssetVerifier) VerifyAsset(c *gin.Context) {
+	var req VerifyAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	userID := auth.MustGetUserID(c)
+	key, ok := ownedAssetKey(v.locator, userID, strings.TrimSpace(req.Asset))
+	if !ok {
+		c.JSON(http.StatusForbidden, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrForbidden, "Asset must be one you uploaded", nil),
+		})
+		return
+	}
+
+	asset, err := v.verify(c.Request.Context(), userID, key, strings.TrimSpace(req.ContentType))
+	switch {
+	case stderrors.Is(err, errAssetUnreadable):
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrNotFound, "Asset not found", nil),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrServiceUnavailable, "Asset could not be verified; retry later", nil),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, asset)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeScanner flags content containing "EICAR" as malware
+type fakeScanner struct {
+	scans int
+	err   error
+}
+
+func (s *fakeScanner) Scan(_ context.Context, body io.Reader) (string, error) {
+	s.scans++
+	data, err := io.ReadAll(body)
+	if err != nil || s.err != nil {
+		return "", s.err
+	}
+	if strings.Contains(string(data), "EICAR") {
+		return "Win.Test.EICAR_HDB-1", nil
+	}
+	return "", nil
+}
+
+func newTestAssetVerifier(t *testing.T, scanner *fakeScanner) (*AssetVerifier, repository.AssetsRepository) {
+	t.Helper()
+	infected := append(encodeProductImage(t, "png", 600, 600), []byte("EICAR")...)
+	store := fakeObjectStore{
+		"users/user-123/uploads/product_images/1_bottle.png": encodeProductImage(t, "png", 600, 600),
+		"users/user-123/uploads/product_images/2_box.jpg":    encodeProductImage(t, "jpeg", 600, 600),
+		"users/user-123/uploads/product_images/3_page.png":   []byte("<!DOCTYPE html><html></html>"),
+		"users/user-123/uploads/product_images/4_virus.png":  infected,
+		"users/user-456/uploads/product_images/1_bottle.png": encodeProductImage(t, "png", 600, 600),
+	}
+	repo := repository.NewLocalDynamoDB().AssetRepository("assets", zap.NewNop())
+	return NewAssetVerifier(repo, store, scanner, "assets", nil, zap.NewNop()), repo
+}
+
+func postVerifyAsset(v *AssetVerifier, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/assets/verify", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	}, v.VerifyAsset)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/assets/verify", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestVerifyAsset(t *testing.T) {
+	scanner := &fakeScanner{}
+	v, repo := newTestAssetVerifier(t, scanner)
+
+	w := postVerifyAsset(v, `{"asset":"https://assets.s3.amazonaws.com/users/user-123/uploads/product_images/1_bottle.png"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var asset domain.Asset
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &asset))
+	require.Equal(t, domain.AssetStatusVerified, asset.Status)
+	require.Equal(t, "image/png", asset.ContentType)
+	require.Equal(t, 600, asset.Width)
+
+	// The recorded result is returned without scanning again
+	require.Equal(t, http.StatusOK, postVerifyAsset(v, `{"asset":"users/user-123/uploads/product_images/1_bottle.png"}`).Code)
+	require.Equal(t, 1, scanner.scans)
+
+	w = postVerifyAsset(v, `{"asset":"users/user-123/uploads/product_images/4_virus.png"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &asset))
+	require.Equal(t, domain.AssetStatusRejected, asset.Status)
+	require.Equal(t, "malware", asset.Reason)
+	require.NotContains(t, w.Body.String(), "EICAR", "the threat name stays internal")
+	stored, err := repo.GetAsset(context.Background(), "users/user-123/uploads/product_images/4_virus.png")
+	require.NoError(t, err)
+	require.Equal(t, "Win.Test.EICAR_HDB-1", stored.Threat)
+
+	// An explicit content type overrides the extension
+	w = postVerifyAsset(v, `{"asset":"users/user-123/uploads/product_images/2_box.jpg","content_type":"application/pdf"}`)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &asset))
+	require.Equal(t, "type_mismatch", asset.Reason)
+
+	require.Equal(t, http.StatusForbidden, postVerifyAsset(v, `{"asset":"users/user-456/uploads/product_images/1_bottle.png"}`).Code)
+	require.Equal(t, http.StatusNotFound, postVerifyAsset(v, `{"asset":"users/user-123/uploads/product_images/missing.png"}`).Code)
+	require.Equal(t, http.StatusBadRequest, postVerifyAsset(v, `{}`).Code)
+}
+
+func TestVerifyAsset_ScannerUnavailable(t *testing.T) {
+	scanner := &fakeScanner{err: io.ErrUnexpectedEOF}
+	v, repo := newTestAssetVerifier(t, scanner)
+
+	require.Equal(t, http.StatusServiceUnavailable, postVerifyAsset(v, `{"asset":"users/user-123/uploads/product_images/1_bottle.png"}`).Code)
+	_, err := repo.GetAsset(context.Background(), "users/user-123/uploads/product_images/1_bottle.png")
+	require.ErrorIs(t, err, repository.ErrAssetNotFound, "nothing is recorded, so the asset is verified again later")
+}
+
+func TestAssetVerifierRequire(t *testing.T) {
+	v, _ := newTestAssetVerifier(t, &fakeScanner{})
+	ctx := context.Background()
+
+	require.Nil(t, v.require(ctx, "user-123", []assetRef{
+		{field: "product_images[0].asset", key: "users/user-123/uploads/product_images/1_bottle.png"},
+		{field: "product_images[1].asset", key: "users/user-123/uploads/product_images/2_box.jpg"},
+	}))
+
+	apiErr := v.require(ctx, "user-123", []assetRef{
+		{field: "product_images[0].asset", key: "users/user-123/uploads/product_images/1_bottle.png"},
+		{field: "product_images[1].asset", key: "users/user-123/uploads/product_images/4_virus.png"},
+		{field: "start_image", key: "users/user-123/uploads/product_images/3_page.png"},
+		{field: "logo_overlay.asset", key: "users/user-123/uploads/product_images/missing.png"},
+	})
+	require.NotNil(t, apiErr)
+	require.Equal(t, "ASSET_NOT_VERIFIED", apiErr.Code)
+	require.Equal(t, http.StatusUnprocessableEntity, apiErr.Status)
+	failed := apiErr.Details["assets"].([]map[string]interface{})
+	require.Len(t, failed, 3, "every failed asset is reported")
+	require.Equal(t, "product_images[1].asset", failed[0]["field"])
+	require.Equal(t, "malware", failed[0]["reason"])
+	require.Equal(t, "start_image", failed[1]["field"])
+	require.Equal(t, "type_mismatch", failed[1]["reason"])
+	require.Equal(t, domain.AssetStatusRejected, failed[1]["status"])
+	require.Equal(t, "unverified", failed[2]["status"])
+}
+
+func TestJobAssetRefs(t *testing.T) {
+	refs := jobAssetRefs(nil, "user-123", GenerateRequest{
+		ProductImages:       []domain.ProductImage{{Asset: "users/user-123/uploads/product_images/1_bottle.png"}},
+		LogoOverlay:         &domain.LogoOverlay{Asset: "users/user-123/uploads/logo.png"},
+		StartImage:          "https://assets.s3.amazonaws.com/users/user-123/uploads/start.jpg",
+		StyleReferenceImage: "https://example.com/style.jpg",
+	})
+	require.Equal(t, []assetRef{
+		{field: "product_images[0].asset", key: "users/user-123/uploads/product_images/1_bottle.png"},
+		{field: "logo_overlay.asset", key: "users/user-123/uploads/logo.png"},
+		{field: "start_image", key: "users/user-123/uploads/start.jpg"},
+	}, refs, "external URLs aren't uploads")
+}
+
+func TestGenerateRejectsUnverifiedAssets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v, _ := newTestAssetVerifier(t, &fakeScanner{})
+	handler := &GenerateHandler{logger: zap.NewNop(), assets: v}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/generate", strings.NewReader(
+		`{"prompt":"A sunrise over a mountain lake","duration":20,"aspect_ratio":"16:9",`+
+			`"start_image":"https://assets.s3.amazonaws.com/users/user-123/uploads/product_images/3_page.png"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(auth.UserIDKey, "user-123")
+	handler.Generate(c)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	var resp errors.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "ASSET_NOT_VERIFIED", resp.Error.Code, w.Body.String())
+	failed := resp.Error.Details["assets"].([]interface{})
+	require.Len(t, failed, 1)
+	require.Equal(t, "start_image", failed[0].(map[string]interface{})["field"])
+	require.Equal(t, "type_mismatch", failed[0].(map[string]interface{})["reason"])
+}
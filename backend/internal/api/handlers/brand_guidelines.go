@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// BrandGuidelinesHandler handles brand guideline requests
+type BrandGuidelinesHandler struct {
+	brandRepo         repository.BrandGuidelinesRepository
+	extractionService *service.BrandExtractionService
+	locator           *repository.AssetLocator
+	logger            *zap.Logger
+}
+
+// NewBrandGuidelinesHandler creates a new brand guidelines handler. A nil extractionService
+// leaves ExtractGuidelines unavailable.
+func NewBrandGuidelinesHandler(
+	brandRepo repository.BrandGuidelinesRepository,
+	extractionService *service.BrandExtractionService,
+	locator *repository.AssetLocator,
+	logger *zap.Logger,
+) *BrandGuidelinesHandler {
+	return &BrandGuidelinesHandler{
+		brandRepo:         brandRepo,
+		extractionService: extractionService,
+		locator:           locator,
+		logger:            logger,
+	}
+}
+
+// BrandGuidelinesRequest creates a guidelines record. Fields left out can be filled from the
+// source document with POST /brand-guidelines/:id/extract.
+type BrandGuidelinesRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Colors         []string `json:"colors"`
+	Typography     string   `json:"typography"`
+	BrandVoice     []string `json:"brand_voice"`
+	ImageStyle     string   `json:"image_style"`
+	VideoStyle     string   `json:"video_style"`
+	LogoURLs       []string `json:"logo_urls"`
+	SourceDocument string   `json:"source_document"` // S3 key of an uploaded brand book PDF
+	IsActive       bool     `json:"is_active"`       // Apply to use_brand_guidelines requests, replacing the active guidelines
+}
+
+// ListBrandGuidelinesResponse is all of the user's guidelines, newest first
+type ListBrandGuidelinesResponse struct {
+	Guidelines []*domain.BrandGuidelines `json:"guidelines"`
+	Count      int                       `json:"count"`
+}
+
+// CreateGuidelines handles POST /api/v1/brand-guidelines
+// @Summary Create brand guidelines
+// @Description Store a set of brand guidelines. With is_active they become the ones use_brand_guidelines applies, and the user's other guidelines are deactivated.
+// @Tags brand-guidelines
+// @Accept json
+// @Produce json
+// @Param request body BrandGuidelinesRequest true "Brand guidelines"
+// @Success 201 {object} domain.BrandGuidelines
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/brand-guidelines [post]
+// @Security BearerAuth
+func (h *BrandGuidelinesHandler) CreateGuidelines(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+	ctx := c.Request.Context()
+
+	var req BrandGuidelinesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	now := time.Now().Unix()
+	guidelines := &domain.BrandGuidelines{
+		GuidelineID:    fmt.Sprintf("bg-%s", uuid.New().String()),
+		UserID:         userID,
+		Name:           strings.TrimSpace(req.Name),
+		Colors:         req.Colors,
+		Typography:     strings.TrimSpace(req.Typography),
+		BrandVoice:     req.BrandVoice,
+		ImageStyle:     strings.TrimSpace(req.ImageStyle),
+		VideoStyle:     strings.TrimSpace(req.VideoStyle),
+		LogoURLs:       req.LogoURLs,
+		SourceDocument: strings.TrimPrefix(strings.TrimSpace(req.SourceDocument), "/"),
+		IsActive:       req.IsActive,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	errs := validation.ValidateBrandGuidelines(guidelines)
+	if guidelines.Name == "" {
+		errs.Add("name", "Name is required")
+	}
+	if guidelines.SourceDocument != "" && !h.locator.OwnsUpload(userID, guidelines.SourceDocument) {
+		errs.Add("source_document", "Asset must be one you uploaded")
+	}
+	if len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	if err := h.brandRepo.CreateBrandGuidelines(ctx, guidelines); err != nil {
+		h.respondSaveError(c, guidelines, err)
+		return
+	}
+	if guidelines.IsActive {
+		if err := h.deactivateOthers(ctx, guidelines); err != nil {
+			h.respondSaveError(c, guidelines, err)
+			return
+		}
+	}
+
+	h.logger.Info("Brand guidelines created",
+		zap.String("guideline_id", guidelines.GuidelineID),
+		zap.String("user_id", userID),
+		zap.Bool("is_active", guidelines.IsActive),
+	)
+	c.JSON(http.StatusCreated, guidelines)
+}
+
+// ListGuidelines handles GET /api/v1/brand-guidelines
+// @Summary List brand guidelines
+// @Description Get all of the user's brand guidelines, newest first
+// @Tags brand-guidelines
+// @Produce json
+// @Success 200 {object} ListBrandGuidelinesResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/brand-guidelines [get]
+// @Security BearerAuth
+func (h *BrandGuidelinesHandler) ListGuidelines(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	all, err := h.brandRepo.ListBrandGuidelinesByUser(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to list brand guidelines", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	if all == nil {
+		all = []*domain.BrandGuidelines{}
+	}
+	c.JSON(http.StatusOK, ListBrandGuidelinesResponse{
+		Guidelines: all,
+		Count:      len(all),
+	})
+}
+
+// GetGuidelines handles GET /api/v1/brand-guidelines/:id
+// @Summary Get brand guidelines
+// @Description Get one of the user's brand guidelines
+// @Tags brand-guidelines
+// @Produce json
+// @Param id path string true "Guideline ID"
+// @Success 200 {object} domain.BrandGuidelines
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/brand-guidelines/{id} [get]
+// @Security BearerAuth
+func (h *BrandGuidelinesHandler) GetGuidelines(c *gin.Context) {
+	guidelines, ok := h.ownedGuidelines(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, guidelines)
+}
+
+// ActivateGuidelines handles POST /api/v1/brand-guidelines/:id/activate
+// @Summary Activate brand guidelines
+// @Description Make these the guidelines use_brand_guidelines applies, deactivating the user's others
+// @Tags brand-guidelines
+// @Produce json
+// @Param id path string true "Guideline ID"
+// @Success 200 {object} domain.BrandGuidelines
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/brand-guidelines/{id}/activate [post]
+// @Security BearerAuth
+func (h *BrandGuidelinesHandler) ActivateGuidelines(c *gin.Context) {
+	guidelines, ok := h.ownedGuidelines(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if !guidelines.IsActive {
+		guidelines.IsActive = true
+		guidelines.UpdatedAt = time.Now().Unix()
+		if err := h.brandRepo.UpdateBrandGuidelines(ctx, guidelines); err != nil {
+			h.respondSaveError(c, guidelines, err)
+			return
+		}
+	}
+	if err := h.deactivateOthers(ctx, guidelines); err != nil {
+		h.respondSaveError(c, guidelines, err)
+		return
+	}
+
+	h.logger.Info("Brand guidelines activated",
+		zap.String("guideline_id", guidelines.GuidelineID),
+		zap.String("user_id", guidelines.UserID),
+	)
+	c.JSON(http.StatusOK, guidelines)
+}
+
+// deactivateOthers clears is_active on the user's guidelines other than active, so
+// use_brand_guidelines applies only one set
+func (h *BrandGuidelinesHandler) deactivateOthers(ctx context.Context, active *domain.BrandGuidelines) error {
+	all, err := h.brandRepo.ListBrandGuidelinesByUser(ctx, active.UserID)
+	if err != nil {
+		return err
+	}
+	for _, g := range all {
+		if g.GuidelineID == active.GuidelineID || !g.IsActive {
+			continue
+		}
+		g.IsActive = false
+		g.UpdatedAt = time.Now().Unix()
+		if err := h.brandRepo.UpdateBrandGuidelines(ctx, g); err != nil && !stderrors.Is(err, repository.ErrBrandGuidelinesNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedGuidelines loads the :id guidelines, responding 404 if they're missing or another
+// user's (security check)
+func (h *BrandGuidelinesHandler) ownedGuidelines(c *gin.Context) (*domain.BrandGuidelines, bool) {
+	guidelineID := c.Param("id")
+	guidelines, err := h.brandRepo.GetBrandGuidelines(c.Request.Context(), guidelineID)
+	if err != nil && err != repository.ErrBrandGuidelinesNotFound {
+		h.logger.Error("Failed to get brand guidelines", zap.String("guideline_id", guidelineID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return nil, false
+	}
+	if err != nil || guidelines.UserID != auth.MustGetUserID(c) {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrNotFound, "Brand guidelines not found", nil),
+		})
+		return nil, false
+	}
+	return guidelines, true
+}
+
+// respondSaveError reports a failed guidelines write, as a validation error if the record was
+// too large to store
+func (h *BrandGuidelinesHandler) respondSaveError(c *gin.Context, guidelines *domain.BrandGuidelines, err error) {
+	var tooLarge *repository.ItemTooLargeError
+	if stderrors.As(err, &tooLarge) {
+		var errs validation.Errors
+		errs.Add(tooLarge.Field, fmt.Sprintf("Brand guidelines are too large to save (%d bytes); shorten %s", tooLarge.Size, tooLarge.Field))
+		respondValidationErrors(c, errs)
+		return
+	}
+	h.logger.Error("Failed to save brand guidelines", zap.String("guideline_id", guidelines.GuidelineID), zap.Error(err))
+	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+		Error: errors.ErrDatabaseError,
+	})
+}
+
+// ExtractGuidelinesResponse represents the result of parsing a brand document
+type ExtractGuidelinesResponse struct {
+	Guidelines   *domain.BrandGuidelines `json:"guidelines"`
+	FilledFields []string                `json:"filled_fields"`
+}
+
+// ExtractGuidelines handles POST /api/v1/brand-guidelines/:id/extract
+// @Summary Extract brand guidelines from the uploaded document
+// @Description Parses the uploaded brand book PDF with GPT-4o and fills empty colors, typography, brand voice, image style and video style fields. Values already entered by the user are kept.
+// @Tags brand-guidelines
+// @Produce json
+// @Param id path string true "Guideline ID"
+// @Success 200 {object} ExtractGuidelinesResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "No document uploaded"
+// @Failure 422 {object} errors.ErrorResponse "Document too large or unreadable, or guidelines over their field limits"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/brand-guidelines/{id}/extract [post]
+// @Security BearerAuth
+func (h *BrandGuidelinesHandler) ExtractGuidelines(c *gin.Context) {
+	guidelines, ok := h.ownedGuidelines(c)
+	if !ok {
+		return
+	}
+	guidelineID := guidelines.GuidelineID
+	ctx := c.Request.Context()
+
+	filled, err := h.extractionService.Extract(ctx, guidelines)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, service.ErrNoSourceDocument):
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict, "Upload a brand document before extracting guidelines", nil),
+			})
+		case stderrors.Is(err, service.ErrUnreadableDocument):
+			var errs validation.Errors
+			errs.Add("source_document", err.Error())
+			respondValidationErrors(c, errs)
+		default:
+			h.logger.Error("Brand guideline extraction failed", zap.String("guideline_id", guidelineID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error: errors.NewServiceError("gpt4o", "Failed to extract brand guidelines from document"),
+			})
+		}
+		return
+	}
+
+	// Extracted values are already bounded; this catches fields stored over their limits before
+	if errs := validation.ValidateBrandGuidelines(guidelines); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	guidelines.UpdatedAt = time.Now().Unix()
+	if err := h.brandRepo.UpdateBrandGuidelines(ctx, guidelines); err != nil {
+		h.respondSaveError(c, guidelines, err)
+		return
+	}
+
+	h.logger.Info("Brand guidelines extracted from document",
+		zap.String("guideline_id", guidelineID),
+		zap.Strings("filled_fields", filled),
+	)
+
+	if filled == nil {
+		filled = []string{}
+	}
+	c.JSON(http.StatusOK, ExtractGuidelinesResponse{
+		Guidelines:   guidelines,
+		FilledFields: filled,
+	})
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/internal/validation"
+	backenderrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeBrandRepo serves guidelines from memory, refusing records too large to store as a
+// DynamoDB implementation must
+type fakeBrandRepo struct {
+	guidelines map[string]*domain.BrandGuidelines
+}
+
+func (f *fakeBrandRepo) GetBrandGuidelines(ctx context.Context, guidelineID string) (*domain.BrandGuidelines, error) {
+	if g, ok := f.guidelines[guidelineID]; ok {
+		return g, nil
+	}
+	return nil, repository.ErrBrandGuidelinesNotFound
+}
+
+func (f *fakeBrandRepo) CreateBrandGuidelines(ctx context.Context, g *domain.BrandGuidelines) error {
+	if err := repository.CheckRecordSize(g); err != nil {
+		return err
+	}
+	f.guidelines[g.GuidelineID] = g
+	return nil
+}
+
+func (f *fakeBrandRepo) ListBrandGuidelinesByUser(ctx context.Context, userID string) ([]*domain.BrandGuidelines, error) {
+	var all []*domain.BrandGuidelines
+	for _, g := range f.guidelines {
+		if g.UserID == userID {
+			all = append(all, g)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt > all[j].CreatedAt })
+	return all, nil
+}
+
+func (f *fakeBrandRepo) GetActiveBrandGuidelines(ctx context.Context, userID string) (*domain.BrandGuidelines, error) {
+	for _, g := range f.guidelines {
+		if g.UserID == userID && g.IsActive {
+			return g, nil
+		}
+	}
+	return nil, repository.ErrBrandGuidelinesNotFound
+}
+
+func (f *fakeBrandRepo) UpdateBrandGuidelines(ctx context.Context, g *domain.BrandGuidelines) error {
+	if err := repository.CheckRecordSize(g); err != nil {
+		return err
+	}
+	f.guidelines[g.GuidelineID] = g
+	return nil
+}
+
+func TestResolveBrandGuidelines(t *testing.T) {
+	own := &domain.BrandGuidelines{GuidelineID: "bg-1", UserID: "user-123", IsActive: true, Name: "Acme"}
+	inactive := &domain.BrandGuidelines{GuidelineID: "bg-2", UserID: "user-123", Name: "Acme Old"}
+	foreign := &domain.BrandGuidelines{GuidelineID: "bg-3", UserID: "user-999", IsActive: true}
+
+	handler := &GenerateHandler{
+		logger: zap.NewNop(),
+		brandRepo: &fakeBrandRepo{guidelines: map[string]*domain.BrandGuidelines{
+			own.GuidelineID: own, inactive.GuidelineID: inactive, foreign.GuidelineID: foreign,
+		}},
+	}
+
+	t.Run("not requested", func(t *testing.T) {
+		g, apiErr := handler.resolveBrandGuidelines(context.Background(), "user-123", GenerateRequest{})
+		require.Nil(t, apiErr)
+		require.Nil(t, g)
+	})
+
+	t.Run("active guidelines", func(t *testing.T) {
+		g, apiErr := handler.resolveBrandGuidelines(context.Background(), "user-123", GenerateRequest{UseBrandGuidelines: true})
+		require.Nil(t, apiErr)
+		require.Equal(t, "bg-1", g.GuidelineID)
+	})
+
+	t.Run("explicit guideline id", func(t *testing.T) {
+		g, apiErr := handler.resolveBrandGuidelines(context.Background(), "user-123", GenerateRequest{GuidelineID: "bg-2"})
+		require.Nil(t, apiErr)
+		require.Equal(t, "bg-2", g.GuidelineID)
+	})
+
+	t.Run("no active guidelines", func(t *testing.T) {
+		_, apiErr := handler.resolveBrandGuidelines(context.Background(), "user-456", GenerateRequest{UseBrandGuidelines: true})
+		require.NotNil(t, apiErr)
+		require.Equal(t, http.StatusNotFound, apiErr.Status)
+	})
+
+	t.Run("repository not configured", func(t *testing.T) {
+		unconfigured := &GenerateHandler{logger: zap.NewNop()}
+		_, apiErr := unconfigured.resolveBrandGuidelines(context.Background(), "user-123", GenerateRequest{UseBrandGuidelines: true})
+		require.NotNil(t, apiErr)
+		require.Equal(t, http.StatusNotImplemented, apiErr.Status)
+	})
+}
+
+func TestGenerateBrandGuidelineNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := &GenerateHandler{
+		logger: zap.NewNop(),
+		brandRepo: &fakeBrandRepo{guidelines: map[string]*domain.BrandGuidelines{
+			"bg-foreign": {GuidelineID: "bg-foreign", UserID: "user-999", IsActive: true},
+		}},
+	}
+
+	for name, guidelineID := range map[string]string{
+		"missing guideline id": "bg-missing",
+		"foreign guideline id": "bg-foreign",
+	} {
+		t.Run(name, func(t *testing.T) {
+			body, err := json.Marshal(map[string]interface{}{
+				"prompt":       "A sunrise run through the city for a new sneaker launch",
+				"duration":     30,
+				"aspect_ratio": "16:9",
+				"guideline_id": guidelineID,
+			})
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req, err := http.NewRequest(http.MethodPost, "/api/v1/generate", bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+			c.Set(auth.UserIDKey, "user-123")
+
+			handler.Generate(c)
+
+			require.Equal(t, http.StatusNotFound, w.Code)
+
+			var resp backenderrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, "NOT_FOUND", resp.Error.Code)
+			require.Equal(t, "Brand guidelines not found", resp.Error.Message)
+		})
+	}
+}
+
+func TestExtractGuidelines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeBrandRepo{guidelines: map[string]*domain.BrandGuidelines{
+		"bg-nodoc":   {GuidelineID: "bg-nodoc", UserID: "user-123"},
+		"bg-foreign": {GuidelineID: "bg-foreign", UserID: "user-999", SourceDocument: "users/user-999/uploads/brand.pdf"},
+	}}
+	handler := NewBrandGuidelinesHandler(repo, service.NewBrandExtractionService(nil, nil, "assets", nil, zap.NewNop()), nil, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		guidelineID string
+		wantStatus  int
+		wantCode    string
+	}{
+		{"missing guidelines", "bg-missing", http.StatusNotFound, "NOT_FOUND"},
+		{"foreign guidelines", "bg-foreign", http.StatusNotFound, "NOT_FOUND"},
+		{"no source document", "bg-nodoc", http.StatusConflict, "CONFLICT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req, err := http.NewRequest(http.MethodPost, "/api/v1/brand-guidelines/"+tt.guidelineID+"/extract", nil)
+			require.NoError(t, err)
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: tt.guidelineID}}
+			c.Set(auth.UserIDKey, "user-123")
+
+			handler.ExtractGuidelines(c)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+
+			var resp backenderrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tt.wantCode, resp.Error.Code)
+		})
+	}
+}
+
+// brandRequest runs a brand guidelines handler as user-123
+func brandRequest(t *testing.T, handle gin.HandlerFunc, method, path, id, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	if id != "" {
+		c.Params = gin.Params{{Key: "id", Value: id}}
+	}
+	c.Set(auth.UserIDKey, "user-123")
+	handle(c)
+	return w
+}
+
+func TestBrandGuidelinesCRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeBrandRepo{guidelines: map[string]*domain.BrandGuidelines{
+		"bg-old":     {GuidelineID: "bg-old", UserID: "user-123", Name: "Acme 2023", IsActive: true, CreatedAt: 100},
+		"bg-foreign": {GuidelineID: "bg-foreign", UserID: "user-999", Name: "Other", IsActive: true, CreatedAt: 200},
+	}}
+	handler := NewBrandGuidelinesHandler(repo, nil, nil, zap.NewNop())
+
+	w := brandRequest(t, handler.CreateGuidelines, http.MethodPost, "/api/v1/brand-guidelines", "",
+		`{"name": " Acme ", "colors": ["#0A84FF"], "is_active": true}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created domain.BrandGuidelines
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.True(t, strings.HasPrefix(created.GuidelineID, "bg-"))
+	require.Equal(t, "Acme", created.Name)
+	require.Equal(t, "user-123", created.UserID)
+	require.False(t, repo.guidelines["bg-old"].IsActive, "creating active guidelines deactivates the others")
+	require.True(t, repo.guidelines["bg-foreign"].IsActive, "other users' guidelines are left alone")
+
+	active, err := repo.GetActiveBrandGuidelines(context.Background(), "user-123")
+	require.NoError(t, err)
+	require.Equal(t, created.GuidelineID, active.GuidelineID)
+
+	w = brandRequest(t, handler.ListGuidelines, http.MethodGet, "/api/v1/brand-guidelines", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListBrandGuidelinesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Equal(t, 2, list.Count)
+
+	w = brandRequest(t, handler.ActivateGuidelines, http.MethodPost, "/api/v1/brand-guidelines/bg-old/activate", "bg-old", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.True(t, repo.guidelines["bg-old"].IsActive)
+	require.False(t, repo.guidelines[created.GuidelineID].IsActive)
+
+	for _, id := range []string{"bg-foreign", "bg-missing"} {
+		w = brandRequest(t, handler.GetGuidelines, http.MethodGet, "/api/v1/brand-guidelines/"+id, id, "")
+		require.Equal(t, http.StatusNotFound, w.Code, id)
+		w = brandRequest(t, handler.ActivateGuidelines, http.MethodPost, "/api/v1/brand-guidelines/"+id+"/activate", id, "")
+		require.Equal(t, http.StatusNotFound, w.Code, id)
+	}
+	require.True(t, repo.guidelines["bg-foreign"].IsActive)
+}
+
+func TestCreateBrandGuidelines_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewBrandGuidelinesHandler(&fakeBrandRepo{guidelines: map[string]*domain.BrandGuidelines{}}, nil, nil, zap.NewNop())
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{"missing name", `{"colors": ["#fff"]}`, http.StatusBadRequest, ""},
+		{"blank name", `{"name": "   "}`, http.StatusUnprocessableEntity, "name"},
+		{"foreign document", `{"name": "Acme", "source_document": "users/user-999/uploads/brand.pdf"}`, http.StatusUnprocessableEntity, "source_document"},
+		{"too many colors", `{"name": "Acme", "colors": [` + strings.Repeat(`"#fff",`, validation.MaxBrandListItems) + `"#000"]}`, http.StatusUnprocessableEntity, "colors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := brandRequest(t, handler.CreateGuidelines, http.MethodPost, "/api/v1/brand-guidelines", "", tt.body)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantField != "" {
+				require.Contains(t, w.Body.String(), `"`+tt.wantField+`"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+func buildCaptionsKey(job *domain.Job) string {
+	return jobAssetPrefix(job) + "captions/narrator.vtt"
+}
+
+// captionSegment is a stretch of the narration rendered at one TTS speed
+type captionSegment struct {
+	Text  string
+	Speed float64 // 1.0 for the main narration; the disclaimer is spoken faster
+}
+
+// splitCaptionCues breaks text into cues of up to composition.CaptionMaxLines lines of composition.CaptionMaxLineChars.
+// A cue never spans two sentences, so each one reads on its own. Words longer than a line are
+// kept whole.
+func splitCaptionCues(text string) []string {
+	var cues []string
+	for _, sentence := range captionSentences(text) {
+		lines := wrapCaptionLines(sentence, composition.CaptionMaxLineChars)
+		for i := 0; i < len(lines); i += composition.CaptionMaxLines {
+			cues = append(cues, strings.Join(lines[i:min(i+composition.CaptionMaxLines, len(lines))], "\n"))
+		}
+	}
+	return cues
+}
+
+// captionSentences splits text at sentence ends, keeping any unterminated remainder
+func captionSentences(text string) []string {
+	var sentences []string
+	end := 0
+	for _, loc := range sentencePattern.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, text[loc[0]:loc[1]])
+		end = loc[1]
+	}
+	sentences = append(sentences, text[end:])
+
+	kept := sentences[:0]
+	for _, sentence := range sentences {
+		if sentence = strings.Join(strings.Fields(sentence), " "); sentence != "" {
+			kept = append(kept, sentence)
+		}
+	}
+	return kept
+}
+
+// wrapCaptionLines greedily fills lines of at most maxChars runes
+func wrapCaptionLines(text string, maxChars int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > maxChars {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// timeCaptionCues splits the segments into cues and divides duration, the measured length of
+// the narration audio, between them in proportion to how long each takes to say: its character
+// count over its segment's speed. Text spoken at 1.4x gets 1/1.4 of the time per character.
+func timeCaptionCues(segments []captionSegment, duration float64) []domain.CaptionCue {
+	var texts []string
+	var weights []float64
+	total := 0.0
+	for _, segment := range segments {
+		speed := segment.Speed
+		if speed <= 0 {
+			speed = 1.0
+		}
+		for _, text := range splitCaptionCues(segment.Text) {
+			weight := float64(utf8.RuneCountInString(text)) / speed
+			texts = append(texts, text)
+			weights = append(weights, weight)
+			total += weight
+		}
+	}
+	if len(texts) == 0 || duration <= 0 {
+		return nil
+	}
+
+	cues := make([]domain.CaptionCue, len(texts))
+	at := 0.0
+	seen := 0.0
+	for i, text := range texts {
+		seen += weights[i]
+		end := duration * seen / total
+		if i == len(texts)-1 {
+			end = duration
+		}
+		cues[i] = domain.CaptionCue{Start: roundMillis(at), End: roundMillis(end), Text: text}
+		at = end
+	}
+	return cues
+}
+
+func roundMillis(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
+
+// buildWebVTT renders the cues as a WebVTT file
+func buildWebVTT(cues []domain.CaptionCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, cue := range cues {
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n%s\n", i+1, vttTimestamp(cue.Start), vttTimestamp(cue.End), escapeVTTText(cue.Text))
+	}
+	return b.String()
+}
+
+// vttTimestamp formats seconds as HH:MM:SS.mmm
+func vttTimestamp(seconds float64) string {
+	ms := int64(math.Round(math.Max(seconds, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
+
+// escapeVTTText escapes the characters WebVTT cue text reserves for markup. Escaping ">" also
+// keeps "-->" out of the cue.
+func escapeVTTText(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// publishCaptions times caption cues to the narration and uploads them as WebVTT, returning the
+// cues and the uploaded key. Captions never fail the job: if the upload fails the cues are still
+// returned, with an empty key, for burning in.
+func (h *GenerateHandler) publishCaptions(ctx context.Context, job *domain.Job, segments []captionSegment, duration float64) ([]domain.CaptionCue, string) {
+	cues := timeCaptionCues(segments, duration)
+	if len(cues) == 0 {
+		return nil, ""
+	}
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "captions")
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		h.log(ctx).Warn("Skipping captions upload (failed to create temp dir)", zap.Error(err))
+		return cues, ""
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "narrator.vtt")
+	if err := os.WriteFile(path, []byte(buildWebVTT(cues)), 0o644); err != nil {
+		h.log(ctx).Warn("Skipping captions upload (failed to write WebVTT)", zap.Error(err))
+		return cues, ""
+	}
+
+	key := buildCaptionsKey(job)
+	if _, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), key, path, "text/vtt"); err != nil {
+		h.log(ctx).Warn("Failed to upload captions", zap.Error(err))
+		return cues, ""
+	}
+
+	h.log(ctx).Info("Narrator captions uploaded",
+		zap.String("s3_key", key),
+		zap.Int("cues", len(cues)),
+	)
+	return cues, key
+}
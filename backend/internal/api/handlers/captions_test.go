@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+const captionsNarration = "Meet Zyloprim, the once-daily tablet that keeps your joints moving so you can get back " +
+	"to the things you love. Ask your doctor. Zyloprim: because every step counts"
+
+func TestSplitCaptionCues(t *testing.T) {
+	cues := splitCaptionCues(captionsNarration)
+	if len(cues) != 4 {
+		t.Fatalf("expected 4 cues, got %d: %q", len(cues), cues)
+	}
+	if cues[2] != "Ask your doctor." {
+		t.Fatalf("expected a short sentence to get its own cue, got %q", cues[2])
+	}
+	if cues[3] != "Zyloprim: because every step counts" {
+		t.Fatalf("expected the unterminated remainder as the last cue, got %q", cues[3])
+	}
+
+	var words []string
+	for i, cue := range cues {
+		lines := strings.Split(cue, "\n")
+		if len(lines) > composition.CaptionMaxLines {
+			t.Fatalf("cue %d has %d lines", i, len(lines))
+		}
+		for _, line := range lines {
+			if n := utf8.RuneCountInString(line); n > composition.CaptionMaxLineChars {
+				t.Fatalf("cue %d line of %d characters: %q", i, n, line)
+			}
+		}
+		words = append(words, strings.Fields(cue)...)
+	}
+	if got, want := strings.Join(words, " "), strings.Join(strings.Fields(captionsNarration), " "); got != want {
+		t.Fatalf("splitting changed the text:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestSplitCaptionCuesKeepsLongWordsWhole(t *testing.T) {
+	word := strings.Repeat("x", 50)
+	cues := splitCaptionCues("Visit " + word + " today.")
+	if len(cues) != 2 || cues[0] != "Visit\n"+word || cues[1] != "today." {
+		t.Fatalf("expected the long word kept whole on its own line, got %q", cues)
+	}
+	if cues := splitCaptionCues(" \n "); cues != nil {
+		t.Fatalf("expected no cues for blank text, got %q", cues)
+	}
+}
+
+func TestTimeCaptionCuesSpansNarration(t *testing.T) {
+	cues := timeCaptionCues([]captionSegment{{Text: captionsNarration, Speed: 1.0}}, 12.5)
+	if len(cues) != 4 {
+		t.Fatalf("expected 4 cues, got %d", len(cues))
+	}
+	if cues[0].Start != 0 || cues[len(cues)-1].End != 12.5 {
+		t.Fatalf("expected cues to span the narration, got %.3f-%.3f", cues[0].Start, cues[len(cues)-1].End)
+	}
+
+	total := 0
+	for _, cue := range cues {
+		total += utf8.RuneCountInString(cue.Text)
+	}
+	for i, cue := range cues {
+		if i > 0 && cue.Start != cues[i-1].End {
+			t.Fatalf("cue %d starts at %.3f, previous ends at %.3f", i, cue.Start, cues[i-1].End)
+		}
+		want := 12.5 * float64(utf8.RuneCountInString(cue.Text)) / float64(total)
+		if got := cue.End - cue.Start; math.Abs(got-want) > 0.002 {
+			t.Fatalf("cue %d shown for %.3fs, want %.3fs", i, got, want)
+		}
+	}
+}
+
+func TestTimeCaptionCuesCompressesFasterSegment(t *testing.T) {
+	// Same length of text, but the disclaimer is read at 1.4x
+	narration := "Ask your doctor if Zyloprim is right for you."
+	disclaimer := "May cause nausea, dizziness and joint pain."
+	disclaimer += strings.Repeat(".", len(narration)-len(disclaimer))
+
+	cues := timeCaptionCues([]captionSegment{
+		{Text: narration, Speed: 1.0},
+		{Text: disclaimer, Speed: 1.4},
+	}, 12)
+	if len(cues) != 2 {
+		t.Fatalf("expected 2 cues, got %d: %+v", len(cues), cues)
+	}
+
+	narrationTime := cues[0].End - cues[0].Start
+	disclaimerTime := cues[1].End - cues[1].Start
+	if math.Abs(narrationTime/disclaimerTime-1.4) > 0.001 {
+		t.Fatalf("expected the disclaimer cue 1.4x shorter, got %.3fs vs %.3fs", narrationTime, disclaimerTime)
+	}
+	if math.Abs(narrationTime+disclaimerTime-12) > 0.001 {
+		t.Fatalf("expected cues to fill the narration, got %.3fs", narrationTime+disclaimerTime)
+	}
+}
+
+func TestTimeCaptionCuesWithoutNarration(t *testing.T) {
+	if cues := timeCaptionCues([]captionSegment{{Text: "Hello.", Speed: 1}}, 0); cues != nil {
+		t.Fatalf("expected no cues without a measured duration, got %+v", cues)
+	}
+	if cues := timeCaptionCues(nil, 10); cues != nil {
+		t.Fatalf("expected no cues without text, got %+v", cues)
+	}
+}
+
+func TestBuildWebVTT(t *testing.T) {
+	vtt := buildWebVTT([]domain.CaptionCue{
+		{Start: 0, End: 2.5, Text: "Don't wait for <relief> & comfort.\nAsk today."},
+		{Start: 2.5, End: 3725.25, Text: "Side effects --> mild"},
+	})
+	want := "WEBVTT\n" +
+		"\n1\n00:00:00.000 --> 00:00:02.500\nDon't wait for &lt;relief&gt; &amp; comfort.\nAsk today.\n" +
+		"\n2\n00:00:02.500 --> 01:02:05.250\nSide effects --&gt; mild\n"
+	if vtt != want {
+		t.Fatalf("unexpected WebVTT:\ngot  %q\nwant %q", vtt, want)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// errNonCompliant marks a job failed by strict compliance checks
+var errNonCompliant = errors.New("script is not compliant")
+
+// checkScriptCompliance runs the pharmaceutical compliance checks on a newly generated script,
+// storing any issues on the job. Returns an error wrapping errNonCompliant when strict checks
+// should fail the job; otherwise the issues are only warnings.
+func (h *GenerateHandler) checkScriptCompliance(ctx context.Context, job *domain.Job, req GenerateRequest, script *domain.Script) error {
+	if h.compliance == nil || !compliance.IsPharmaceutical(job.Voice, job.SideEffects) {
+		return nil
+	}
+	issues := h.compliance.CheckScript(compliance.Input{
+		Script:       script,
+		SideEffects:  job.SideEffects,
+		Duration:     float64(job.Duration),
+		CallToAction: req.CallToAction,
+	})
+	return h.recordComplianceIssues(ctx, job, issues)
+}
+
+// checkNarrationCompliance checks two-pass narration, which is written after the script. It
+// returns the issues for the caller to record, since narration runs alongside other steps.
+func (h *GenerateHandler) checkNarrationCompliance(ctx context.Context, narration string) ([]domain.ComplianceIssue, error) {
+	if h.compliance == nil {
+		return nil, nil
+	}
+	issues := h.compliance.CheckNarration(narration)
+	return issues, h.reportComplianceIssues(ctx, issues)
+}
+
+// recordComplianceIssues adds issues to the job and fails it in strict mode
+func (h *GenerateHandler) recordComplianceIssues(ctx context.Context, job *domain.Job, issues []domain.ComplianceIssue) error {
+	job.ComplianceIssues = append(job.ComplianceIssues, issues...)
+	return h.reportComplianceIssues(ctx, issues)
+}
+
+// reportComplianceIssues logs issues and fails in strict mode
+func (h *GenerateHandler) reportComplianceIssues(ctx context.Context, issues []domain.ComplianceIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	for _, issue := range issues {
+		h.log(ctx).Warn("Compliance check failed",
+			zap.String("rule", issue.Rule),
+			zap.Int("scene_number", issue.SceneNumber),
+			zap.String("message", issue.Message),
+			zap.Bool("strict", h.compliance.Strict()),
+		)
+	}
+	if !h.compliance.Strict() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errNonCompliant, compliance.Summary(issues))
+}
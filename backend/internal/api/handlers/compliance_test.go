@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const pharmaSideEffects = "May cause dizziness and nausea."
+
+// fixedScriptGenerator returns the same script for every request
+type fixedScriptGenerator struct {
+	script *domain.Script
+}
+
+func (g fixedScriptGenerator) GenerateScript(ctx context.Context, req *adapters.ScriptGenerationRequest) (*domain.Script, error) {
+	return g.script, nil
+}
+
+func (g fixedScriptGenerator) GenerateScriptVariants(ctx context.Context, req *adapters.ScriptGenerationRequest) ([]*domain.Script, error) {
+	return []*domain.Script{g.script}, nil
+}
+
+func (g fixedScriptGenerator) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
+	return "", nil
+}
+
+// paraphrasedPharmaScript rewords the side effects and promises instant relief
+func paraphrasedPharmaScript() *domain.Script {
+	return &domain.Script{
+		Title:         "Restura",
+		TotalDuration: 16,
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 8, Action: "She feels instant relief", GenerationPrompt: storedScenePrompt},
+			{SceneNumber: 2, Duration: 8, Action: "The Restura box on the counter", GenerationPrompt: "Close-up of the Restura box on a pharmacy shelf, clinical light"},
+		},
+		AudioSpec: domain.AudioSpec{
+			NarratorScript:       "Restura. Ask your doctor.",
+			SideEffectsText:      "Might make you dizzy.",
+			SideEffectsStartTime: 12.8,
+		},
+		Metadata: domain.Metadata{ProductName: "Restura"},
+	}
+}
+
+func complianceHandler(t *testing.T, strict bool) (*GenerateHandler, *repository.DynamoDBRepository, *domain.Job) {
+	checker, err := compliance.NewChecker(compliance.Config{Strict: strict})
+	require.NoError(t, err)
+
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	parser := service.NewParserService(fixedScriptGenerator{paraphrasedPharmaScript()}, zap.NewNop())
+	h := NewGenerateHandler(GenerateHandlerDeps{
+		ParserService: parser,
+		JobRepo:       repo,
+		Compliance:    checker,
+	}, zap.NewNop())
+
+	job := &domain.Job{
+		JobID:       "job-pharma",
+		UserID:      "user-123",
+		Status:      domain.StatusProcessing,
+		Stage:       domain.StageScriptGenerating,
+		Prompt:      "Launch ad for Restura, a sleep aid",
+		Duration:    16,
+		AspectRatio: "16:9",
+		Model:       "veo",
+		Voice:       "female",
+		SideEffects: pharmaSideEffects,
+		CreatedAt:   1000,
+		UpdatedAt:   1000,
+	}
+	require.NoError(t, repo.CreateJob(context.Background(), job))
+	return h, repo, job
+}
+
+func complianceRequest(job *domain.Job) GenerateRequest {
+	return GenerateRequest{Prompt: job.Prompt, Duration: job.Duration, AspectRatio: job.AspectRatio, Voice: job.Voice, SideEffects: job.SideEffects}
+}
+
+func TestGenerateScriptStep_ComplianceWarnings(t *testing.T) {
+	h, repo, job := complianceHandler(t, false)
+
+	script, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.True(t, ok)
+	require.NotNil(t, script)
+
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StageScriptComplete, stored.Stage)
+	require.Equal(t, []string{compliance.RuleSideEffectsVerbatim, compliance.RuleBannedClaim}, issueRules(stored.ComplianceIssues))
+	require.Equal(t, 1, stored.ComplianceIssues[1].SceneNumber)
+	require.Equal(t, pharmaSideEffects, stored.SideEffectsText, "the user's disclosure is still used")
+}
+
+func TestGenerateScriptStep_StrictComplianceFailsJob(t *testing.T) {
+	h, repo, job := complianceHandler(t, true)
+
+	script, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.False(t, ok)
+	require.Nil(t, script)
+
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusFailed, stored.Status)
+	require.NotNil(t, stored.ErrorMessage)
+	require.Contains(t, *stored.ErrorMessage, complianceFailureMessage)
+	require.Len(t, stored.ComplianceIssues, 2)
+	require.Len(t, stored.Scenes, 2, "the script is kept for review")
+}
+
+func TestGenerateScriptStep_SkipsNonPharmaJobs(t *testing.T) {
+	h, repo, job := complianceHandler(t, true)
+	job.Voice = ""
+
+	_, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.True(t, ok)
+
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Empty(t, stored.ComplianceIssues)
+}
+
+func TestCheckNarrationCompliance(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		h, _, _ := complianceHandler(t, strict)
+
+		issues, err := h.checkNarrationCompliance(context.Background(), "Sleep well. Ask your doctor about Restura.")
+		require.NoError(t, err)
+		require.Empty(t, issues)
+
+		issues, err = h.checkNarrationCompliance(context.Background(), "Sleep well with Restura.")
+		require.Equal(t, []string{compliance.RuleRequiredPhrase}, issueRules(issues))
+		if strict {
+			require.ErrorIs(t, err, errNonCompliant)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func issueRules(issues []domain.ComplianceIssue) []string {
+	rules := make([]string, len(issues))
+	for i, issue := range issues {
+		rules[i] = issue.Rule
+	}
+	return rules
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// compositionLockTTL is how long a composition holds its job's lock. It outlasts any
+// composition, and frees the job if the process holding it dies.
+const compositionLockTTL = 30 * time.Minute
+
+// compositionLockPoll is how often a composition waiting on another run's lock retries it
+var compositionLockPoll = 5 * time.Second
+
+// compositionLocker is the subset of the job lock repository compositions take turns with
+type compositionLocker interface {
+	AcquireJobLock(ctx context.Context, jobID, owner string, ttl time.Duration) error
+	ReleaseJobLock(ctx context.Context, jobID, owner string) error
+}
+
+// findComposedVideo returns the final videos an earlier run already composed from the same
+// inputs, restoring the encoding it recorded and adding them to the asset ledger. The WebM is
+// optional, as it is for a fresh composition.
+func findComposedVideo(ctx context.Context, s3Service *repository.S3AssetRepository, bucket string, job *domain.Job, comp composition.Plan) (string, string, bool) {
+	video, err := s3Service.ObjectInfo(ctx, bucket, comp.VideoKey)
+	if err != nil || video.Metadata[composition.HashMetadataKey] != comp.Hash {
+		return "", "", false
+	}
+	ledger := repository.AssetLedgerFrom(ctx)
+	ledger.Record(comp.VideoKey, video.Size)
+
+	var encoding domain.OutputEncoding
+	if err := json.Unmarshal([]byte(video.Metadata[composition.EncodingMetadataKey]), &encoding); err == nil {
+		job.Encoding = &encoding
+	}
+
+	webmKey := ""
+	if webm, err := s3Service.ObjectInfo(ctx, bucket, comp.WebMKey); err == nil && webm.Metadata[composition.HashMetadataKey] == comp.Hash {
+		ledger.Record(comp.WebMKey, webm.Size)
+		webmKey = comp.WebMKey
+	}
+	return comp.VideoKey, webmKey, true
+}
+
+// composeOnce runs compose for job's clips unless a run already composed the same inputs, in
+// which case its final videos are returned instead. Runs of the same job take turns through
+// locks, so a retry waits for the run it overlaps and then reuses its output; a nil locker
+// skips the lock.
+func composeOnce(
+	ctx context.Context,
+	s3Service *repository.S3AssetRepository,
+	bucket string,
+	locks compositionLocker,
+	logger *zap.Logger,
+	job *domain.Job,
+	clips []composition.ClipVideo,
+	compose func(comp composition.Plan) (string, string, error),
+) (string, string, error) {
+	comp, err := composition.NewPlan(ctx, s3Service, bucket, job, clips)
+	if err != nil {
+		return "", "", err
+	}
+	if videoKey, webmKey, ok := findComposedVideo(ctx, s3Service, bucket, job, comp); ok {
+		logger.Info("Final video already composed from these inputs, reusing it",
+			zap.String("composition", comp.Hash),
+			zap.String("video_key", videoKey),
+		)
+		return videoKey, webmKey, nil
+	}
+
+	release, err := lockComposition(ctx, locks, logger, job.JobID)
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+
+	// The run that held the lock may have just composed the same inputs
+	if videoKey, webmKey, ok := findComposedVideo(ctx, s3Service, bucket, job, comp); ok {
+		logger.Info("Final video composed by a concurrent run, reusing it",
+			zap.String("composition", comp.Hash),
+			zap.String("video_key", videoKey),
+		)
+		return videoKey, webmKey, nil
+	}
+	return compose(comp)
+}
+
+// lockComposition takes job's composition lock, waiting while another run holds it. The
+// returned func releases it.
+func lockComposition(ctx context.Context, locks compositionLocker, logger *zap.Logger, jobID string) (func(), error) {
+	if locks == nil {
+		return func() {}, nil
+	}
+
+	owner := uuid.New().String()
+	for waited := false; ; waited = true {
+		err := locks.AcquireJobLock(ctx, jobID, owner, compositionLockTTL)
+		if err == nil {
+			break
+		}
+		if !stderrors.Is(err, repository.ErrJobLocked) {
+			return nil, fmt.Errorf("failed to lock composition: %w", err)
+		}
+		if !waited {
+			logger.Info("Another run is composing this job, waiting for it")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for another composition of the job: %w", ctx.Err())
+		case <-time.After(compositionLockPoll):
+		}
+	}
+
+	return func() {
+		// Detached so a canceled composition still frees the job for its retry
+		ctx, cancel := context.WithTimeout(trace.Detach(ctx), repository.JobEventTimeout)
+		defer cancel()
+		if err := locks.ReleaseJobLock(ctx, jobID, owner); err != nil {
+			logger.Warn("Failed to release composition lock; it expires on its own", zap.Error(err))
+		}
+	}, nil
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeCompositionLocker is held by someone else for its first busy attempts
+type fakeCompositionLocker struct {
+	mu       sync.Mutex
+	busy     int
+	attempts int
+	released int
+}
+
+func (f *fakeCompositionLocker) AcquireJobLock(ctx context.Context, jobID, owner string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.busy < 0 || f.attempts <= f.busy {
+		return repository.ErrJobLocked
+	}
+	return nil
+}
+
+func (f *fakeCompositionLocker) ReleaseJobLock(ctx context.Context, jobID, owner string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.released++
+	return nil
+}
+
+func newComposeTestS3(t *testing.T) *repository.S3AssetRepository {
+	t.Helper()
+	local, err := repository.StartLocalS3(t.TempDir(), "127.0.0.1:0", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { local.Close() })
+	return repository.NewS3Service(local.Client(), "assets", repository.UploadOptions{PartSize: manager.MinUploadPartSize},
+		repository.PresignCacheOptions{Disabled: true}, zap.NewNop())
+}
+
+// uploadComposeClip uploads data as scene n's clip, returning it as the pipeline would
+func uploadComposeClip(t *testing.T, s3Service *repository.S3AssetRepository, job *domain.Job, n int, data string) composition.ClipVideo {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	url, err := s3Service.UploadFile(context.Background(), "assets", composition.SceneClipKey(job, n), path, "video/mp4")
+	require.NoError(t, err)
+	return composition.ClipVideo{VideoURL: url, Duration: 4}
+}
+
+// fakeCompose uploads a marked final video for comp, as ComposeFinalVideo does once the video
+// passes its integrity check
+func fakeCompose(t *testing.T, s3Service *repository.S3AssetRepository, calls *int) func(comp composition.Plan) (string, string, error) {
+	return func(comp composition.Plan) (string, string, error) {
+		*calls++
+		path := filepath.Join(t.TempDir(), "final.mp4")
+		require.NoError(t, os.WriteFile(path, []byte("final video"), 0o644))
+		_, err := s3Service.UploadJobAssetWithMetadata(context.Background(), "assets", comp.VideoKey, path, comp.ContentType,
+			map[string]string{composition.HashMetadataKey: comp.Hash, composition.EncodingMetadataKey: `{"codec":"h264"}`})
+		return comp.VideoKey, "", err
+	}
+}
+
+func TestComposeOnce_ReusesMatchingComposition(t *testing.T) {
+	ctx := context.Background()
+	s3Service := newComposeTestS3(t)
+	job := &domain.Job{JobID: "job-1", UserID: "user-1", AspectRatio: "16:9"}
+	clips := []composition.ClipVideo{
+		uploadComposeClip(t, s3Service, job, 1, "scene one"),
+		uploadComposeClip(t, s3Service, job, 2, "scene two"),
+	}
+
+	calls := 0
+	videoKey, _, err := composeOnce(ctx, s3Service, "assets", nil, zap.NewNop(), job, clips, fakeCompose(t, s3Service, &calls))
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+	require.Regexp(t, `^users/user-1/jobs/job-1/final/video-[0-9a-f]{16}\.mp4$`, videoKey)
+
+	// A retry of the same inputs returns the earlier output without composing
+	retried := &domain.Job{JobID: "job-1", UserID: "user-1", AspectRatio: "16:9"}
+	retryKey, webmKey, err := composeOnce(ctx, s3Service, "assets", nil, zap.NewNop(), retried, clips, fakeCompose(t, s3Service, &calls))
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+	require.Equal(t, videoKey, retryKey)
+	require.Empty(t, webmKey)
+	require.NotNil(t, retried.Encoding)
+	require.Equal(t, "h264", retried.Encoding.Codec)
+
+	// A scene rerun writes new content to the same clip key, which composes anew
+	clips[1] = uploadComposeClip(t, s3Service, job, 2, "scene two, take two")
+	rerunKey, _, err := composeOnce(ctx, s3Service, "assets", nil, zap.NewNop(), job, clips, fakeCompose(t, s3Service, &calls))
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.NotEqual(t, videoKey, rerunKey)
+}
+
+func TestComposeOnce_UnmarkedOutputIsComposedAgain(t *testing.T) {
+	ctx := context.Background()
+	s3Service := newComposeTestS3(t)
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	clips := []composition.ClipVideo{uploadComposeClip(t, s3Service, job, 1, "scene one")}
+
+	comp, err := composition.NewPlan(ctx, s3Service, "assets", job, clips)
+	require.NoError(t, err)
+	// A crashed upload of the same key, without the marker written after the integrity check
+	path := filepath.Join(t.TempDir(), "partial.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("trunc"), 0o644))
+	_, err = s3Service.UploadFile(ctx, "assets", comp.VideoKey, path, "video/mp4")
+	require.NoError(t, err)
+
+	calls := 0
+	_, _, err = composeOnce(ctx, s3Service, "assets", nil, zap.NewNop(), job, clips, fakeCompose(t, s3Service, &calls))
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+}
+
+func TestComposeOnce_WaitsForLock(t *testing.T) {
+	defer func(poll time.Duration) { compositionLockPoll = poll }(compositionLockPoll)
+	compositionLockPoll = time.Millisecond
+
+	s3Service := newComposeTestS3(t)
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	clips := []composition.ClipVideo{uploadComposeClip(t, s3Service, job, 1, "scene one")}
+
+	// Held for good: the run gives up when its context does, without composing
+	held := &fakeCompositionLocker{busy: -1}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	_, _, err := composeOnce(ctx, s3Service, "assets", held, zap.NewNop(), job, clips, fakeCompose(t, s3Service, &calls))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Zero(t, calls)
+	require.Zero(t, held.released)
+
+	// Freed by the other run: this one composes and releases the lock
+	freed := &fakeCompositionLocker{busy: 3}
+	_, _, err = composeOnce(context.Background(), s3Service, "assets", freed, zap.NewNop(), job, clips, fakeCompose(t, s3Service, &calls))
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+	require.Equal(t, 4, freed.attempts)
+	require.Equal(t, 1, freed.released)
+}
+
+// recordingRunner stands in for ffmpeg and ffprobe, like the composition package's test runner.
+// Each ffmpeg command is recorded and writes a placeholder to its output, unless the output's
+// name is in fail; ffprobe describes every file as a 1080p h264 video at fps, clips lasting
+// clipDuration and everything else total. Source clips are width x height instead when those
+// are set. Commands asking for progress get a report halfway through and a final one.
+type recordingRunner struct {
+	mu           sync.Mutex
+	calls        [][]string // ffmpeg arguments, in order
+	fps          string
+	clipDuration float64
+	total        float64
+	width        int
+	height       int
+	fail         map[string]bool
+}
+
+var sourceClipName = regexp.MustCompile(`^(clip-\d+\.mp4|video\.mp4)$`)
+
+func (r *recordingRunner) Run(cmd *exec.Cmd) error {
+	_, err := r.CombinedOutput(cmd)
+	return err
+}
+
+func (r *recordingRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	return r.CombinedOutput(cmd)
+}
+
+func (r *recordingRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return r.run(cmd.Args[0], cmd.Args[1:])
+}
+
+func (r *recordingRunner) Exec(ctx context.Context, spec ffmpegexec.Spec) error {
+	name := spec.Name
+	if name == "" {
+		name = "ffmpeg"
+	}
+	if spec.Progress != nil {
+		spec.Progress(ffmpegexec.Progress{OutTime: time.Duration(r.total * float64(time.Second) / 2)})
+	}
+	if _, err := r.run(name, spec.Args); err != nil {
+		return err
+	}
+	if spec.Progress != nil {
+		spec.Progress(ffmpegexec.Progress{OutTime: time.Duration(r.total * float64(time.Second)), Done: true})
+	}
+	return nil
+}
+
+func (r *recordingRunner) run(name string, args []string) ([]byte, error) {
+	path := args[len(args)-1]
+	if name == "ffprobe" {
+		return r.probe(args, path)
+	}
+
+	r.mu.Lock()
+	r.calls = append(r.calls, args)
+	r.mu.Unlock()
+	if r.fail[filepath.Base(path)] {
+		return []byte("encoder failed"), errors.New("exit status 1")
+	}
+	return nil, os.WriteFile(path, []byte("video"), 0o644)
+}
+
+func (r *recordingRunner) probe(args []string, path string) ([]byte, error) {
+	duration, width, height := r.total, 1920, 1080
+	if sourceClipName.MatchString(filepath.Base(path)) {
+		duration = r.clipDuration
+		if r.width > 0 {
+			width, height = r.width, r.height
+		}
+	}
+	entries := args[slices.Index(args, "-show_entries")+1]
+	switch entries {
+	case "stream=r_frame_rate":
+		return []byte(r.fps + "\n"), nil
+	case "stream=width,height":
+		return []byte(fmt.Sprintf("%dx%d\n", width, height)), nil
+	}
+	return json.Marshal(map[string]any{
+		"streams": []map[string]any{{
+			"codec_type":   "video",
+			"codec_name":   "h264",
+			"width":        width,
+			"height":       height,
+			"pix_fmt":      "yuv420p",
+			"r_frame_rate": r.fps,
+		}},
+		"format": map[string]any{"duration": fmt.Sprint(duration)},
+	})
+}
+
+// outputs lists the files the recorded commands wrote, by name
+func (r *recordingRunner) outputs() []string {
+	names := make([]string, len(r.calls))
+	for i, args := range r.calls {
+		names[i] = filepath.Base(args[len(args)-1])
+	}
+	return names
+}
+
+// overlayFiltergraphs lists the filtergraphs of the recorded commands that drew text, in order
+func overlayFiltergraphs(r *recordingRunner) []string {
+	var filtergraphs []string
+	for _, args := range r.calls {
+		for i, arg := range args[:len(args)-1] {
+			if (arg == "-vf" || arg == "-filter_complex") && strings.Contains(args[i+1], "drawtext=") {
+				filtergraphs = append(filtergraphs, args[i+1])
+			}
+		}
+	}
+	return filtergraphs
+}
@@ -1,6 +1,11 @@
 package handlers
 
-import "time"
+import (
+	"time"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"go.uber.org/zap"
+)
 
 // Video generation constants
 const (
@@ -23,4 +28,70 @@ const (
 
 	// MaxConcurrentGenerations is the maximum number of concurrent video generations
 	MaxConcurrentGenerations = 10
+
+	// PreviewApprovalWindow is how long a previewed script waits for approval before the job expires
+	PreviewApprovalWindow = 24 * time.Hour
+
+	// AssetURLExpiry is the validity of presigned asset URLs returned to clients.
+	// Every endpoint uses the same expiry so the S3 repository's presign cache is shared across them;
+	// it is kept short because URLs signed with temporary credentials die when the session does.
+	AssetURLExpiry = 1 * time.Hour
 )
+
+// Narration timing constants
+const (
+	// NarrationOverrunTolerance is how far (seconds) narration may exceed the video before it is sped up
+	NarrationOverrunTolerance = 0.5
+
+	// MaxNarrationSpeed is the fastest global speed-up applied before the script is truncated
+	// Beyond ~1.5x TTS narration stops sounding natural
+	MaxNarrationSpeed = 1.5
+)
+
+// Music fitting constants
+const (
+	// MusicDurationTolerance is how far (seconds) music may differ from the video before it is looped or trimmed
+	MusicDurationTolerance = 0.1
+
+	// MusicLoopCrossfade is the crossfade (seconds) applied at each seam when looping a short track
+	MusicLoopCrossfade = 1.0
+
+	// MusicFadeOut is the fade-out (seconds) that ends the music exactly at the video duration
+	MusicFadeOut = 1.5
+)
+
+// DefaultSFXMaxDuration is the longest (seconds) a generated sound effect is kept when no limit is configured
+const DefaultSFXMaxDuration = 3.0
+
+// videoPollOptions returns polling settings for video clip predictions
+func videoPollOptions(logger *zap.Logger, modelName string) adapters.PollOptions {
+	return adapters.PollOptions{
+		Interval: PollInterval,
+		Timeout:  VideoGenerationMaxAttempts * PollInterval,
+		LogEvery: 12, // every minute at 5s intervals
+		Label:    modelName,
+		Logger:   logger,
+	}
+}
+
+// audioPollOptions returns polling settings for music predictions
+func audioPollOptions(logger *zap.Logger) adapters.PollOptions {
+	return adapters.PollOptions{
+		Interval: PollInterval,
+		Timeout:  AudioGenerationMaxAttempts * PollInterval,
+		LogEvery: 12,
+		Label:    "Minimax music",
+		Logger:   logger,
+	}
+}
+
+// sfxPollOptions returns polling settings for sound effect predictions
+func sfxPollOptions(logger *zap.Logger) adapters.PollOptions {
+	return adapters.PollOptions{
+		Interval: PollInterval,
+		Timeout:  AudioGenerationMaxAttempts * PollInterval,
+		LogEvery: 12,
+		Label:    "Sound effect",
+		Logger:   logger,
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/s3util"
+	"go.uber.org/zap"
+)
+
+// styleAnalyzer describes an image's visual style for video prompts (GPT-4o vision)
+type styleAnalyzer interface {
+	AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error)
+}
+
+// continuityMode returns how the job's scenes follow on from each other; jobs that didn't choose
+// start each scene on the previous clip's last frame
+func continuityMode(job *domain.Job) string {
+	if job.ContinuityMode == "" {
+		return domain.ContinuityFrame
+	}
+	return job.ContinuityMode
+}
+
+// continuityFrame returns the start image continuity gives the scene after a clip ending on
+// lastFrameURL: the frame itself in frame mode, none otherwise
+func continuityFrame(job *domain.Job, lastFrameURL string) string {
+	if continuityMode(job) != domain.ContinuityFrame {
+		return ""
+	}
+	return lastFrameURL
+}
+
+// continuityFallback describes what a scene starts on when its own start image can't be used
+func continuityFallback(job *domain.Job) string {
+	if continuityMode(job) != domain.ContinuityFrame {
+		return "generating it without a start image"
+	}
+	return "using the previous scene's last frame"
+}
+
+// startImageForScene picks the start image of scene i of numScenes, which follows a clip ending
+// on lastFrameURL ("" for the first scene):
+//  1. The last scene uses the product image provided by the user (pharmaceutical ads), whatever
+//     the continuity mode.
+//  2. Scenes GPT-4o assigned one of the user's product photos start on it.
+//  3. Other scenes start on the previous clip's last frame in frame mode, and without a start
+//     image in style and none modes.
+func (h *GenerateHandler) startImageForScene(ctx context.Context, job *domain.Job, scene domain.Scene, i, numScenes int, lastFrameURL string) string {
+	continuity := continuityFrame(job, lastFrameURL)
+
+	assignedImage, hasAssignedImage := productImageForScene(job, scene)
+	if scene.AssignedImageRef != "" && !hasAssignedImage {
+		h.log(ctx).Warn("Scene references an unknown product image; using the continuity frame",
+			zap.Int("scene", i+1),
+			zap.String("assigned_image_ref", scene.AssignedImageRef),
+		)
+		h.recordWarning(ctx, job, fmt.Sprintf("Scene %d references an unknown product image; %s", i+1, continuityFallback(job)))
+	}
+
+	if i == numScenes-1 && strings.TrimSpace(job.StartImage) != "" {
+		// Extract S3 key from the product image URL
+		s3Key := s3util.Key(job.StartImage)
+
+		// Generate presigned URL for video API access (valid for 1 hour)
+		presignedURL, err := h.s3Service.GetPresignedURL(ctx, s3Key, 1*time.Hour)
+		if err != nil {
+			h.log(ctx).Error("Failed to generate presigned URL for product image",
+				zap.String("s3_key", s3Key),
+				zap.String("original_url", job.StartImage),
+				zap.Error(err),
+			)
+			// Fall back to direct URL if presigning fails (shouldn't happen, but be safe)
+			return job.StartImage
+		}
+		h.log(ctx).Info("Using product image for last scene (side effects segment)",
+			zap.Int("scene", i+1),
+			zap.String("product_image_url", presignedURL),
+		)
+		return presignedURL
+	}
+
+	if hasAssignedImage {
+		presignedURL, err := h.s3Service.GetPresignedURL(ctx, assignedImage.Asset, 1*time.Hour)
+		if err != nil {
+			h.log(ctx).Warn("Failed to presign assigned product image; using the continuity frame",
+				zap.Int("scene", i+1),
+				zap.String("s3_key", assignedImage.Asset),
+				zap.Error(err),
+			)
+			h.recordWarning(ctx, job, fmt.Sprintf("Scene %d's product image could not be read; %s", i+1, continuityFallback(job)))
+			return continuity
+		}
+		h.log(ctx).Info("Using assigned product image as start image",
+			zap.Int("scene", i+1),
+			zap.String("assigned_image_ref", assignedImage.Ref),
+		)
+		return presignedURL
+	}
+
+	switch {
+	case continuity != "":
+		h.log(ctx).Info("Using last frame for visual continuity",
+			zap.Int("scene", i+1),
+		)
+	case i == numScenes-1 && continuityMode(job) == domain.ContinuityFrame:
+		h.log(ctx).Warn("Product image missing for last scene; falling back to continuity frame",
+			zap.Int("scene", i+1),
+		)
+	case i > 0 && continuityMode(job) != domain.ContinuityFrame:
+		h.log(ctx).Info("Generating scene without start image",
+			zap.Int("scene", i+1),
+			zap.String("continuity_mode", continuityMode(job)),
+		)
+	default:
+		h.log(ctx).Info("No continuity frame available, generating scene without start image",
+			zap.Int("scene", i+1),
+		)
+	}
+	return continuity
+}
+
+// analyzeContinuityStyle reads scene 1's visual style from its clip's last frame in style mode.
+// The description is cached on the job, so it is read once however often the job runs.
+// Without one, later scenes are generated without the style.
+func (h *GenerateHandler) analyzeContinuityStyle(ctx context.Context, job *domain.Job, lastFrameURL string) {
+	if continuityMode(job) != domain.ContinuityStyle || job.ContinuityStyle != "" {
+		return
+	}
+	if h.styles == nil || lastFrameURL == "" {
+		h.log(ctx).Warn("Scene 1's last frame can't be analyzed; generating later scenes without its style",
+			zap.Bool("analyzer_configured", h.styles != nil),
+		)
+		h.recordWarning(ctx, job, "Scene 1's style could not be read; later scenes are generated without it")
+		return
+	}
+
+	style, err := h.styles.AnalyzeStyleReference(h.withProvenance(ctx, job, "continuity_style"), lastFrameURL)
+	if err != nil || strings.TrimSpace(style) == "" {
+		h.log(ctx).Warn("Failed to analyze scene 1's style; generating later scenes without it",
+			zap.Error(err),
+		)
+		h.recordWarning(ctx, job, "Scene 1's style could not be read; later scenes are generated without it")
+		return
+	}
+	job.ContinuityStyle = strings.TrimSpace(style)
+	h.log(ctx).Info("Read scene 1's style for continuity",
+		zap.Int("style_length", len(job.ContinuityStyle)),
+	)
+}
+
+// withContinuityStyle appends the job's continuity style to the prompt of the scenes after the
+// first in style mode
+func withContinuityStyle(job *domain.Job, scene domain.Scene, sceneNum int) domain.Scene {
+	if continuityMode(job) != domain.ContinuityStyle || job.ContinuityStyle == "" || sceneNum == 1 {
+		return scene
+	}
+	scene.GenerationPrompt = strings.TrimSpace(scene.GenerationPrompt) +
+		"\n\nVisual style, matching the previous scenes: " + job.ContinuityStyle
+	return scene
+}
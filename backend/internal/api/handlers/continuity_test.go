@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeStyleAnalyzer describes every image with the same style, recording the images it was shown
+type fakeStyleAnalyzer struct {
+	style  string
+	err    error
+	images []string
+}
+
+func (f *fakeStyleAnalyzer) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
+	f.images = append(f.images, imageURL)
+	return f.style, f.err
+}
+
+// continuityHandler returns a handler presigning from a local S3 and recording its events
+func continuityHandler(t *testing.T) (*GenerateHandler, *fakeJobEventStore) {
+	h := NewGenerateHandler(GenerateHandlerDeps{
+		S3Service:    newComposeTestS3(t),
+		AssetsBucket: "assets",
+	}, zap.NewNop())
+	events := &fakeJobEventStore{}
+	h.events = events
+	return h, events
+}
+
+// pharmaContinuityJob is a three scene pharmaceutical job with a product image for its last scene
+func pharmaContinuityJob(mode string) *domain.Job {
+	return &domain.Job{
+		JobID:          "job-continuity",
+		UserID:         "user-123",
+		StartImage:     "s3://assets/users/user-123/uploads/product.png",
+		SideEffects:    pharmaSideEffects,
+		ContinuityMode: mode,
+	}
+}
+
+func TestStartImageForScene_ContinuityModes(t *testing.T) {
+	const lastFrame = "https://example.com/scene-frame.jpg"
+
+	tests := []struct {
+		mode       string
+		wantMiddle string
+	}{
+		{mode: "", wantMiddle: lastFrame},
+		{mode: domain.ContinuityFrame, wantMiddle: lastFrame},
+		{mode: domain.ContinuityStyle, wantMiddle: ""},
+		{mode: domain.ContinuityNone, wantMiddle: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run("mode "+tt.mode, func(t *testing.T) {
+			h, _ := continuityHandler(t)
+			job := pharmaContinuityJob(tt.mode)
+			ctx := context.Background()
+
+			require.Empty(t, h.startImageForScene(ctx, job, domain.Scene{SceneNumber: 1}, 0, 3, ""), "scene 1 is pure generation")
+			require.Equal(t, tt.wantMiddle, h.startImageForScene(ctx, job, domain.Scene{SceneNumber: 2}, 1, 3, lastFrame))
+
+			last := h.startImageForScene(ctx, job, domain.Scene{SceneNumber: 3}, 2, 3, lastFrame)
+			require.Contains(t, last, "users/user-123/uploads/product.png", "the last scene starts on the product image in every mode")
+			require.Contains(t, last, "X-Amz-Signature")
+		})
+	}
+}
+
+func TestStartImageForScene_UnknownProductImageFallsBack(t *testing.T) {
+	h, events := continuityHandler(t)
+	job := pharmaContinuityJob(domain.ContinuityNone)
+	scene := domain.Scene{SceneNumber: 2, AssignedImageRef: "missing"}
+
+	require.Empty(t, h.startImageForScene(context.Background(), job, scene, 1, 3, "https://example.com/scene-frame.jpg"))
+	require.Len(t, events.events, 1)
+	require.Equal(t, "Scene 2 references an unknown product image; generating it without a start image", events.events[0].Message)
+}
+
+func TestAnalyzeContinuityStyle_AnalyzesOnceAndStylesLaterScenes(t *testing.T) {
+	h, _ := continuityHandler(t)
+	analyzer := &fakeStyleAnalyzer{style: "  Warm golden-hour light, soft focus, muted earth tones.\n"}
+	h.styles = analyzer
+	job := pharmaContinuityJob(domain.ContinuityStyle)
+
+	h.analyzeContinuityStyle(context.Background(), job, "https://example.com/scene-1-frame.jpg")
+	require.Equal(t, []string{"https://example.com/scene-1-frame.jpg"}, analyzer.images)
+	require.Equal(t, "Warm golden-hour light, soft focus, muted earth tones.", job.ContinuityStyle)
+
+	// A resumed job reuses the cached style
+	h.analyzeContinuityStyle(context.Background(), job, "https://example.com/scene-1-frame-rerun.jpg")
+	require.Len(t, analyzer.images, 1)
+
+	first := withContinuityStyle(job, domain.Scene{GenerationPrompt: "A woman wakes up rested"}, 1)
+	require.Equal(t, "A woman wakes up rested", first.GenerationPrompt)
+	later := withContinuityStyle(job, domain.Scene{GenerationPrompt: "She pours coffee "}, 2)
+	require.Equal(t, "She pours coffee\n\nVisual style, matching the previous scenes: Warm golden-hour light, soft focus, muted earth tones.", later.GenerationPrompt)
+}
+
+func TestAnalyzeContinuityStyle_OtherModesSkipAnalysis(t *testing.T) {
+	for _, mode := range []string{"", domain.ContinuityFrame, domain.ContinuityNone} {
+		h, _ := continuityHandler(t)
+		analyzer := &fakeStyleAnalyzer{style: "Neon noir"}
+		h.styles = analyzer
+		job := pharmaContinuityJob(mode)
+
+		h.analyzeContinuityStyle(context.Background(), job, "https://example.com/scene-1-frame.jpg")
+		require.Empty(t, analyzer.images, mode)
+		require.Empty(t, job.ContinuityStyle, mode)
+
+		job.ContinuityStyle = "Neon noir"
+		scene := withContinuityStyle(job, domain.Scene{GenerationPrompt: "She pours coffee"}, 2)
+		require.Equal(t, "She pours coffee", scene.GenerationPrompt, mode)
+	}
+}
+
+func TestAnalyzeContinuityStyle_FailureLeavesPromptsUnstyled(t *testing.T) {
+	h, events := continuityHandler(t)
+	h.styles = &fakeStyleAnalyzer{err: errors.New("vision model unavailable")}
+	job := pharmaContinuityJob(domain.ContinuityStyle)
+
+	h.analyzeContinuityStyle(context.Background(), job, "https://example.com/scene-1-frame.jpg")
+	require.Empty(t, job.ContinuityStyle)
+	require.Len(t, events.events, 1)
+	require.Equal(t, domain.JobEventWarning, events.events[0].Type)
+
+	scene := withContinuityStyle(job, domain.Scene{GenerationPrompt: "She pours coffee"}, 2)
+	require.Equal(t, "She pours coffee", scene.GenerationPrompt)
+}
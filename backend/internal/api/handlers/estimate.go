@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/validation"
+	"go.uber.org/zap"
+)
+
+// EstimateGenerate handles POST /api/v1/generate/estimate
+// @Summary Estimate the cost of a generation job
+// @Description Prices a POST /generate body without creating a job or calling any model: the credits charged per job and in total, each planned scene's cost, narration characters, music seconds and the expected wall-clock time. Assets and brand guidelines aren't checked.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param request body GenerateRequest true "Video generation parameters, as for POST /generate"
+// @Success 200 {object} pricing.Estimate
+// @Failure 400 {object} errors.ErrorResponse "Malformed JSON body"
+// @Failure 401 {object} errors.ErrorResponse "Unauthorized"
+// @Failure 422 {object} errors.ErrorResponse "Field validation errors"
+// @Router /api/v1/generate/estimate [post]
+// @Security BearerAuth
+func (h *GenerateHandler) EstimateGenerate(c *gin.Context) {
+	var req GenerateRequest
+	explicit, ok := h.bindGenerateRequest(c, &req)
+	if !ok {
+		return
+	}
+	if _, ok := h.applyUserPreferences(c, &req, explicit); !ok {
+		return
+	}
+
+	// The same checks as POST /generate, so a request that estimates cleanly can be submitted
+	ttsProvider := h.resolveTTSProvider(req)
+	input := req.validationInput(ttsProvider)
+	if errs := validation.ValidateGenerate(input); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	adapterType, _ := adapters.ParseAdapterType(req.Model)
+	estimate := pricing.EstimateJob(pricing.Plan{
+		Duration:    req.Duration,
+		Model:       adapterType,
+		Narration:   req.Voice != "" && ttsProvider != "",
+		SideEffects: len(req.SideEffects),
+		Music:       h.music.Configured(),
+		Variants:    req.Variants,
+		Preview:     req.Preview,
+	})
+
+	h.logger.Debug("Estimated job cost",
+		zap.String("model", string(adapterType)),
+		zap.Int("duration", req.Duration),
+		zap.Int("total_credits", estimate.TotalCredits),
+	)
+	c.JSON(http.StatusOK, estimate)
+}
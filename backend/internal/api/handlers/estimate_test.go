@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func postEstimate(h *GenerateHandler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/generate/estimate", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(auth.UserIDKey, "user-1")
+	h.EstimateGenerate(c)
+	return w
+}
+
+func TestEstimateGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// No job repository, usage service or models: estimating must not touch any of them
+	h := &GenerateHandler{
+		logger: zap.NewNop(),
+		music: adapters.NewMusicChain([]adapters.MusicSource{
+			{Provider: adapters.MusicProviderMinimax, Generator: adapters.NewMockMusicAdapter(nil, 0, zap.NewNop())},
+		}, zap.NewNop()),
+		ttsRouter: adapters.NewTTSRouter(adapters.TTSProviderOpenAI, map[adapters.TTSProvider]adapters.TTSAdapter{
+			adapters.TTSProviderOpenAI: adapters.NewMockTTSAdapter(nil, 0, zap.NewNop()),
+		}, zap.NewNop()),
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		totalCredits int
+		scenes       int
+		narration    bool
+	}{
+		{
+			name:         "non-pharma veo ad",
+			body:         `{"prompt":"A sunrise over a mountain lake","duration":30,"aspect_ratio":"16:9"}`,
+			totalCredits: 30*2 + 4*5,
+			scenes:       4,
+		},
+		{
+			name:         "pharma kling ad with narration",
+			body:         `{"prompt":"A sunrise over a mountain lake","duration":60,"aspect_ratio":"16:9","model":"kling","voice":"female","side_effects":"May cause drowsiness.","start_image":"https://assets.s3.amazonaws.com/users/user-1/uploads/product.png"}`,
+			totalCredits: 60*1 + 6*5,
+			scenes:       6,
+			narration:    true,
+		},
+		{
+			name:         "variants",
+			body:         `{"prompt":"A sunrise over a mountain lake","duration":20,"aspect_ratio":"9:16","variants":2}`,
+			totalCredits: 2 * (20*2 + 3*5),
+			scenes:       3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postEstimate(h, tt.body)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var estimate pricing.Estimate
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &estimate))
+			require.Equal(t, tt.totalCredits, estimate.TotalCredits)
+			require.Len(t, estimate.Scenes, tt.scenes)
+			require.Equal(t, tt.narration, estimate.TTSCharacters > 0)
+			require.NotZero(t, estimate.MusicSeconds)
+			require.Less(t, estimate.WallClock.MinSeconds, estimate.WallClock.MaxSeconds)
+		})
+	}
+}
+
+func TestEstimateGenerate_ValidatesLikeGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &GenerateHandler{logger: zap.NewNop()}
+
+	w := postEstimate(h, `{"prompt":"short","duration":7,"aspect_ratio":"4:3"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "VALIDATION_FAILED")
+
+	require.Equal(t, http.StatusBadRequest, postEstimate(h, `{`).Code)
+}
+
+func TestEstimateGenerate_ChecksVoiceIDsAgainstTheNarratingProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tts := adapters.NewMockTTSAdapter(nil, 0, zap.NewNop())
+	body := `{"prompt":"A sunrise over a mountain lake","duration":30,"aspect_ratio":"16:9","voice":"21m00Tcm4TlvDq8ikWAM","side_effects":"May cause drowsiness.","start_image":"https://assets.s3.amazonaws.com/users/user-1/uploads/product.png"}`
+	handler := func(defaultProvider adapters.TTSProvider) *GenerateHandler {
+		return &GenerateHandler{
+			logger: zap.NewNop(),
+			ttsRouter: adapters.NewTTSRouter(defaultProvider, map[adapters.TTSProvider]adapters.TTSAdapter{
+				adapters.TTSProviderOpenAI:     tts,
+				adapters.TTSProviderElevenLabs: tts,
+			}, zap.NewNop()),
+		}
+	}
+
+	// No tts_provider in the request: the server default narrates
+	w := postEstimate(handler(adapters.TTSProviderElevenLabs), body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = postEstimate(handler(adapters.TTSProviderOpenAI), body)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "ElevenLabs voices are not available")
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -11,10 +12,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/omnigen/backend/internal/adapters"
 	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/composition"
 	"github.com/omnigen/backend/internal/concurrency"
 	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobeta"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/moderation"
+	"github.com/omnigen/backend/internal/pricing"
 	"github.com/omnigen/backend/internal/repository"
 	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/validation"
 	"github.com/omnigen/backend/pkg/errors"
 	"go.uber.org/zap"
 )
@@ -22,73 +31,290 @@ import (
 // GenerateHandler handles video generation requests with goroutine-based async processing
 type GenerateHandler struct {
 	parserService     *service.ParserService
-	veoAdapter        *adapters.VeoAdapter
-	minimaxAdapter    *adapters.MinimaxAdapter
-	ttsAdapter        adapters.TTSAdapter // Text-to-speech adapter for narrator voiceover
+	adapterFactory    *adapters.AdapterFactory // Creates the video adapter chosen per job
+	music             *adapters.MusicChain     // Minimax, then the fallback music model
+	ttsRouter         *adapters.TTSRouter      // Selects the narrator TTS adapter per job (OpenAI or ElevenLabs)
 	gpt4oAdapter      *adapters.GPT4oAdapter
 	disclaimerService *service.DisclaimerService
 	s3Service         *repository.S3AssetRepository
 	jobRepo           *repository.DynamoDBRepository
+	brandRepo         repository.BrandGuidelinesRepository // Optional; nil disables brand guidelines
+	usageService      *service.UsageService                // Optional; nil disables credit enforcement
+	storage           storageCounter                       // Optional; nil skips storage accounting
+	webhooks          *service.WebhookService              // Optional; nil disables job callbacks
+	idempotency       *idempotencyGuard                    // Optional; nil ignores Idempotency-Key
+	scripts           repository.ScriptsRepository         // Optional; nil disables the script library
+	preferences       repository.PreferencesRepository     // Optional; nil applies no saved preferences
 	assetsBucket      string
 	logger            *zap.Logger
-	semaphore         *concurrency.Semaphore // Limits concurrent video generations
+	sfxAdapter        adapters.SFXGenerator        // Optional; nil disables sound effects
+	audioConfig       AudioConfig                  // Sound effect length and loudness targets
+	tmpBudget         int64                        // Bytes of /tmp a composition may use; <= 0 disables the check
+	tokenBudget       int                          // LLM tokens a job may consume; <= 0 disables the budget
+	thumbnailWebP     bool                         // Also write WebP job thumbnails
+	sceneTransitions  bool                         // New jobs render their scripts' scene transitions
+	clipAspectPolicy  composition.ClipAspectPolicy // What happens to generated clips of the wrong shape; "" normalizes them
+	retentionDays     int                          // Days jobs are kept; <= 0 keeps them forever
+	semaphore         *concurrency.Semaphore       // Limits concurrent video generations
+	dispatcher        *jobDispatcher               // Per-user active job limit; nil disables queueing
+	cancellations     *jobCancellations            // Running pipelines, stopped by POST /jobs/:id/cancel
+	metrics           metrics.Recorder             // Stage durations and job outcomes; Nop when not configured
+	provenance        provenanceStore              // Records each provider call on the job; nil records nothing
+	stepStats         stepStatsStore               // Records each step's time and credits on the job; nil records nothing
+	stageTimings      *jobeta.Estimator            // Rolling step durations that running jobs' ETAs are estimated from
+	events            repository.JobEventAppender  // Job audit trail; nil records nothing
+	locks             compositionLocker            // Keeps runs of a job from composing at once; nil skips the lock
+	composer          *composition.Service         // Stores clips and composes final videos
+	styles            styleAnalyzer                // Reads scene 1's style in style continuity mode; nil skips it
+	modelOverrides    bool                         // Honor X-Model-Override; testing only
+	compliance        *compliance.Checker          // Pharmaceutical script checks; nil skips them
+	assets            *AssetVerifier               // Verifies referenced uploads; nil skips verification
+	locator           *repository.AssetLocator     // Freezes new jobs' asset bucket and prefix; nil keeps the legacy layout
+	moderation        *moderation.Checker          // Screens prompts before credits are spent; nil skips it
+}
+
+// GenerateHandlerDeps holds what NewGenerateHandler builds the handler from. Unset optional
+// dependencies disable their feature, as noted on each field.
+type GenerateHandlerDeps struct {
+	ParserService     *service.ParserService
+	AdapterFactory    *adapters.AdapterFactory // Creates the video adapter chosen per job
+	Music             *adapters.MusicChain     // Minimax, then the fallback music model
+	TTSRouter         *adapters.TTSRouter      // Selects the narrator TTS adapter per job
+	GPT4oAdapter      *adapters.GPT4oAdapter   // Also reads scene 1's style in style continuity mode
+	DisclaimerService *service.DisclaimerService
+	S3Service         *repository.S3AssetRepository
+	JobRepo           *repository.DynamoDBRepository
+	BrandRepo         repository.BrandGuidelinesRepository // nil disables brand guidelines
+	UsageService      *service.UsageService                // nil disables credit enforcement
+	StorageUsage      *repository.DynamoDBUsageRepository  // nil skips storage accounting
+	Webhooks          *service.WebhookService              // nil disables job callbacks
+	IdempotencyRepo   repository.IdempotencyRepository     // nil ignores Idempotency-Key
+	ScriptRepo        repository.ScriptsRepository         // nil disables the script library
+	PreferencesRepo   repository.PreferencesRepository     // nil applies no saved preferences
+	SFXAdapter        adapters.SFXGenerator                // nil disables sound effects
+	JobEvents         repository.JobEventsRepository       // nil records no audit trail
+	JobLocks          repository.JobLocksRepository        // nil lets runs of a job compose at once
+
+	AudioConfig          AudioConfig                  // Sound effect length and loudness targets
+	TmpBudget            int64                        // Bytes of /tmp a composition may use; <= 0 disables the check
+	TokenBudget          int                          // LLM tokens a job may consume; <= 0 disables the budget
+	ThumbnailWebP        bool                         // Also write WebP job thumbnails
+	SceneTransitions     bool                         // New jobs render their scripts' scene transitions
+	ClipAspectPolicy     composition.ClipAspectPolicy // What happens to generated clips of the wrong shape; "" normalizes them
+	ClipQuality          composition.ClipQualityGate  // Rejects unusable clips before composition
+	MaxActiveJobsPerUser int                          // Jobs a user may run at once before new ones queue; <= 0 disables queueing
+	RetentionDays        int                          // Days jobs are kept; <= 0 keeps them forever
+	AssetsBucket         string
+	ModelOverrides       bool // Honor X-Model-Override; testing only
+
+	Metrics       metrics.Recorder         // Stage durations and job outcomes; nil records nothing
+	Compliance    *compliance.Checker      // Pharmaceutical script checks; nil skips them
+	AssetVerifier *AssetVerifier           // Verifies referenced uploads; nil skips verification
+	AssetLocator  *repository.AssetLocator // Freezes new jobs' asset bucket and prefix; nil keeps the legacy layout
+	Moderation    *moderation.Checker      // Screens prompts before credits are spent; nil skips it
 }
 
 // NewGenerateHandler creates a new generate handler
-func NewGenerateHandler(
-	parserService *service.ParserService,
-	veoAdapter *adapters.VeoAdapter,
-	minimaxAdapter *adapters.MinimaxAdapter,
-	ttsAdapter adapters.TTSAdapter,
-	gpt4oAdapter *adapters.GPT4oAdapter,
-	disclaimerService *service.DisclaimerService,
-	s3Service *repository.S3AssetRepository,
-	jobRepo *repository.DynamoDBRepository,
-	assetsBucket string,
-	logger *zap.Logger,
-) *GenerateHandler {
-	return &GenerateHandler{
-		parserService:     parserService,
-		veoAdapter:        veoAdapter,
-		minimaxAdapter:    minimaxAdapter,
-		ttsAdapter:        ttsAdapter,
-		gpt4oAdapter:      gpt4oAdapter,
-		disclaimerService: disclaimerService,
-		s3Service:         s3Service,
+func NewGenerateHandler(deps GenerateHandlerDeps, logger *zap.Logger) *GenerateHandler {
+	jobRepo := deps.JobRepo
+	h := &GenerateHandler{
+		parserService:     deps.ParserService,
+		adapterFactory:    deps.AdapterFactory,
+		music:             deps.Music,
+		ttsRouter:         deps.TTSRouter,
+		gpt4oAdapter:      deps.GPT4oAdapter,
+		disclaimerService: deps.DisclaimerService,
+		s3Service:         deps.S3Service,
 		jobRepo:           jobRepo,
-		assetsBucket:      assetsBucket,
+		brandRepo:         deps.BrandRepo,
+		usageService:      deps.UsageService,
+		webhooks:          deps.Webhooks,
+		scripts:           deps.ScriptRepo,
+		preferences:       deps.PreferencesRepo,
+		sfxAdapter:        deps.SFXAdapter,
+		audioConfig:       deps.AudioConfig,
+		tmpBudget:         deps.TmpBudget,
+		tokenBudget:       deps.TokenBudget,
+		thumbnailWebP:     deps.ThumbnailWebP,
+		sceneTransitions:  deps.SceneTransitions,
+		clipAspectPolicy:  deps.ClipAspectPolicy,
+		retentionDays:     deps.RetentionDays,
+		assetsBucket:      deps.AssetsBucket,
 		logger:            logger,
 		semaphore:         concurrency.NewSemaphore(MaxConcurrentGenerations),
+		metrics:           metrics.Nop{},
+		modelOverrides:    deps.ModelOverrides,
+		compliance:        deps.Compliance,
+		assets:            deps.AssetVerifier,
+		locator:           deps.AssetLocator,
+		moderation:        deps.Moderation,
+	}
+	if deps.Metrics != nil {
+		h.metrics = deps.Metrics
+	}
+	var cancelFlags cancelStore
+	if jobRepo != nil {
+		cancelFlags = jobRepo
+	}
+	h.cancellations = newJobCancellations(cancelFlags, logger)
+	h.stageTimings = jobeta.NewEstimator()
+	if deps.StorageUsage != nil {
+		h.storage = deps.StorageUsage
+	}
+	if jobRepo != nil {
+		h.provenance = jobRepo
+		h.stepStats = jobRepo
+	}
+	if deps.JobEvents != nil {
+		h.events = deps.JobEvents
 	}
+	if deps.JobLocks != nil {
+		h.locks = deps.JobLocks
+	}
+	h.composer = composition.NewService(deps.S3Service, deps.AssetsBucket, deps.TmpBudget, h.events, jobRepo, deps.ClipQuality, logger)
+	if deps.GPT4oAdapter != nil {
+		h.styles = deps.GPT4oAdapter
+	}
+	if deps.IdempotencyRepo != nil && jobRepo != nil {
+		h.idempotency = newIdempotencyGuard(deps.IdempotencyRepo, jobRepo, logger)
+	}
+	if deps.MaxActiveJobsPerUser > 0 && jobRepo != nil {
+		h.dispatcher = newJobDispatcher(jobRepo, deps.MaxActiveJobsPerUser, h.startQueuedJob, logger)
+	}
+	return h
 }
 
-// GenerateRequest represents a video generation request - SIMPLE interface
+// GenerateRequest represents a video generation request - SIMPLE interface.
+// Field values are checked by validation.ValidateGenerate rather than binding tags
+// so every invalid field is reported in a single 422 response.
 type GenerateRequest struct {
-	Prompt      string `json:"prompt" binding:"required,min=10,max=2000"`
-	Duration    int    `json:"duration" binding:"required,min=10,max=60"`
-	AspectRatio string `json:"aspect_ratio" binding:"required,oneof=16:9 9:16 1:1"`
+	Prompt      string `json:"prompt"`       // 10-2000 characters
+	Duration    int    `json:"duration"`     // 10-60 seconds, achievable with the model's clip lengths
+	AspectRatio string `json:"aspect_ratio"` // 16:9, 9:16 or 1:1
+
+	// Video generation model (defaults to veo)
+	Model string `json:"model,omitempty"` // veo or kling
 
 	// Pharmaceutical ad configuration
-	Voice       string `json:"voice,omitempty"`
+	Voice       string `json:"voice,omitempty"` // male, female, or an ElevenLabs voice ID when ElevenLabs narrates
 	SideEffects string `json:"side_effects,omitempty"`
+	TTSProvider string `json:"tts_provider,omitempty"` // openai or elevenlabs (defaults to server config)
+
+	// How side effects too long to fit on screen are shown: paginate (default) or scroll
+	SideEffectsOverflow string `json:"side_effects_overflow,omitempty"`
+
+	// BCP-47 tag of the language to write the narration, voiceovers, title and call to action in,
+	// e.g. es or de-DE (defaults to English). Side effects text is used as given, never translated.
+	Language string `json:"language,omitempty"`
 
 	// Image options - TWO separate use cases:
-	StartImage          string `json:"start_image,omitempty" binding:"omitempty,url"`           // Used ONLY for first scene initialization
-	StyleReferenceImage string `json:"style_reference_image,omitempty" binding:"omitempty,url"` // Used to guide visual style across ALL clips
+	StartImage          string `json:"start_image,omitempty"`           // Used ONLY for first scene initialization; a URL or an uploaded asset's key
+	StyleReferenceImage string `json:"style_reference_image,omitempty"` // Used to guide visual style across ALL clips; a URL or an uploaded asset's key
 
 	// Video title (Phase 1 - UI enhancement)
-	Title string `json:"title,omitempty" binding:"omitempty,max=100"` // Optional video title
+	Title string `json:"title,omitempty"` // Optional video title
 
 	// Enhanced prompt options (Phase 1 - all optional)
-	Style             string `json:"style,omitempty" binding:"omitempty,oneof=cinematic documentary energetic minimal dramatic playful"`
-	Tone              string `json:"tone,omitempty" binding:"omitempty,oneof=premium friendly edgy inspiring humorous"`
-	Tempo             string `json:"tempo,omitempty" binding:"omitempty,oneof=slow medium fast"`
-	Platform          string `json:"platform,omitempty" binding:"omitempty,oneof=instagram tiktok youtube facebook"`
-	Audience          string `json:"audience,omitempty" binding:"omitempty,max=200"`
-	Goal              string `json:"goal,omitempty" binding:"omitempty,oneof=awareness sales engagement signups"`
-	CallToAction      string `json:"call_to_action,omitempty" binding:"omitempty,max=100"`
+	Style             string `json:"style,omitempty"`    // cinematic, documentary, energetic, minimal, dramatic, playful
+	Tone              string `json:"tone,omitempty"`     // premium, friendly, edgy, inspiring, humorous
+	Tempo             string `json:"tempo,omitempty"`    // slow, medium, fast
+	Platform          string `json:"platform,omitempty"` // instagram, tiktok, youtube, facebook
+	Audience          string `json:"audience,omitempty"`
+	Goal              string `json:"goal,omitempty"` // awareness, sales, engagement, signups
+	CallToAction      string `json:"call_to_action,omitempty"`
 	ProCinematography bool   `json:"pro_cinematography,omitempty"`
 	CreativeBoost     bool   `json:"creative_boost,omitempty"`
+
+	// Preview stops after script generation so the script can be reviewed via POST /jobs/:id/approve
+	Preview bool `json:"preview,omitempty"`
+
+	// A/B variants: 1-3 distinct scripts for the same brief, each generated and charged as a
+	// child job of this one. Can't be combined with preview.
+	Variants int `json:"variants,omitempty"`
+
+	// Generate sound effects for the script's "sfx" sync points
+	GenerateSFX bool `json:"generate_sfx,omitempty"`
+
+	// Complete the job without background music if every music model fails, instead of failing it
+	AllowSilentFallback bool `json:"allow_silent_fallback,omitempty"`
+
+	// Burn the narrator captions into the video; a WebVTT file is published either way
+	BurnCaptions bool `json:"burn_captions,omitempty"`
+
+	// Logo composited over the video: an uploaded asset (S3 key or URL) or the brand guidelines' logo
+	LogoOverlay *domain.LogoOverlay `json:"logo_overlay,omitempty"`
+
+	// Static closing card with the call to action, appended after the last clip
+	EndCard *domain.EndCard `json:"end_card,omitempty"`
+
+	// Encoding of the final MP4: resolution, codec, CRF or bitrate cap, and container
+	Output *domain.OutputSpec `json:"output,omitempty"`
+
+	// Uploaded product photos (S3 keys, up to 8) that GPT-4o assigns to scenes as start images
+	ProductImages []domain.ProductImage `json:"product_images,omitempty"`
+
+	// Exact scenes to write, in order: each a clip length the model supports, together adding
+	// up to duration, with an optional location or focus hint. The script must match it.
+	ScenePlan []domain.PlannedScene `json:"scene_plan,omitempty"`
+
+	// How each scene follows on from the last: frame (default) starts it on the previous clip's
+	// last frame, style only carries over scene 1's look through the prompts, none starts fresh.
+	// The last scene of a pharmaceutical ad starts on the product image in every mode.
+	ContinuityMode string `json:"continuity_mode,omitempty"`
+
+	// Brand guidelines: apply the user's active guidelines, or a specific set by ID
+	UseBrandGuidelines bool   `json:"use_brand_guidelines,omitempty"`
+	GuidelineID        string `json:"guideline_id,omitempty"`
+
+	// Webhook POSTed when the job completes, fails or is canceled, signed with the secret when one is given
+	CallbackURL    string `json:"callback_url,omitempty"`    // Public http(s) URL
+	CallbackSecret string `json:"callback_secret,omitempty"` // 16-256 characters
+}
+
+// validationInput trims the request and maps it onto the shared validator input; narrator is
+// the TTS provider that will narrate it
+func (r *GenerateRequest) validationInput(narrator adapters.TTSProvider) validation.GenerateInput {
+	r.Prompt = strings.TrimSpace(r.Prompt)
+	r.Voice = strings.TrimSpace(r.Voice)
+	r.SideEffects = strings.TrimSpace(r.SideEffects)
+	r.StartImage = strings.TrimSpace(r.StartImage)
+	r.StyleReferenceImage = strings.TrimSpace(r.StyleReferenceImage)
+	r.CallbackURL = strings.TrimSpace(r.CallbackURL)
+	r.Language = domain.CanonicalLanguageTag(r.Language)
+
+	return validation.GenerateInput{
+		Prompt:              r.Prompt,
+		Duration:            r.Duration,
+		AspectRatio:         r.AspectRatio,
+		Model:               r.Model,
+		Voice:               r.Voice,
+		SideEffects:         r.SideEffects,
+		SideEffectsOverflow: r.SideEffectsOverflow,
+		TTSProvider:         r.TTSProvider,
+		NarratorProvider:    string(narrator),
+		Language:            r.Language,
+		StartImage:          r.StartImage,
+		StyleReferenceImage: r.StyleReferenceImage,
+		Title:               r.Title,
+		Style:               r.Style,
+		Tone:                r.Tone,
+		Tempo:               r.Tempo,
+		Platform:            r.Platform,
+		Audience:            r.Audience,
+		Goal:                r.Goal,
+		CallToAction:        r.CallToAction,
+		CallbackURL:         r.CallbackURL,
+		CallbackSecret:      r.CallbackSecret,
+		LogoOverlay:         r.LogoOverlay,
+		EndCard:             r.EndCard,
+		Output:              r.Output,
+		ProductImages:       r.ProductImages,
+		ScenePlan:           r.ScenePlan,
+		ContinuityMode:      r.ContinuityMode,
+		Preview:             r.Preview,
+		Variants:            r.Variants,
+		BrandGuidelines:     r.UseBrandGuidelines || r.GuidelineID != "",
+	}
 }
 
 // GenerateResponse represents a video generation response
@@ -98,23 +324,315 @@ type GenerateResponse struct {
 	NumClips            int    `json:"num_clips"`
 	CreatedAt           int64  `json:"created_at"`
 	EstimatedCompletion int    `json:"estimated_completion_seconds"`
+
+	// Child jobs generating the A/B variants; the returned job tracks them all
+	VariantJobIDs []string `json:"variant_job_ids,omitempty"`
+}
+
+// respondValidationErrors writes a 422 with every field error found
+func respondValidationErrors(c *gin.Context, errs validation.Errors) {
+	c.JSON(http.StatusUnprocessableEntity, errors.ErrorResponse{
+		Error: errs.APIError(),
+	})
+}
+
+// resolveTTSProvider returns the provider that will narrate the request, or "" when no TTS is configured
+func (h *GenerateHandler) resolveTTSProvider(req GenerateRequest) adapters.TTSProvider {
+	_, provider := h.ttsRouter.Resolve(req.TTSProvider)
+	return provider
+}
+
+// resolveBrandGuidelines loads the guidelines requested by use_brand_guidelines or guideline_id.
+// Returns nil guidelines when the request does not ask for any.
+func (h *GenerateHandler) resolveBrandGuidelines(ctx context.Context, userID string, req GenerateRequest) (*domain.BrandGuidelines, *errors.APIError) {
+	if !req.UseBrandGuidelines && req.GuidelineID == "" {
+		return nil, nil
+	}
+
+	if h.brandRepo == nil {
+		return nil, errors.NewAPIError(errors.ErrNotImplemented, "Brand guidelines are not available", nil)
+	}
+
+	var guidelines *domain.BrandGuidelines
+	var err error
+	if req.GuidelineID != "" {
+		guidelines, err = h.brandRepo.GetBrandGuidelines(ctx, req.GuidelineID)
+	} else {
+		guidelines, err = h.brandRepo.GetActiveBrandGuidelines(ctx, userID)
+	}
+
+	if err != nil {
+		if err == repository.ErrBrandGuidelinesNotFound {
+			return nil, errors.NewAPIError(errors.ErrNotFound, "Brand guidelines not found", nil)
+		}
+		h.logger.Error("Failed to load brand guidelines",
+			zap.String("user_id", userID),
+			zap.String("guideline_id", req.GuidelineID),
+			zap.Error(err),
+		)
+		return nil, errors.ErrDatabaseError
+	}
+
+	// Guidelines owned by another user are reported as missing (security check)
+	if guidelines.UserID != userID {
+		return nil, errors.NewAPIError(errors.ErrNotFound, "Brand guidelines not found", nil)
+	}
+
+	return guidelines, nil
+}
+
+// runInBackground runs a pipeline function in a goroutine, limited by the
+// generation semaphore and guarded against panics. The pipeline's context is detached from
+// parent, the request starting the job, but keeps its request ID and adds the job ID.
+func (h *GenerateHandler) runInBackground(parent context.Context, job *domain.Job, run func(ctx context.Context)) {
+	jobID := job.JobID
+	traced := trace.WithJobID(trace.Detach(parent), jobID)
+	log := trace.Logger(traced, h.logger)
+	go func() {
+		// Runs last: the job has reached a terminal state, so the user's next queued job may start
+		defer h.jobFinished(job.UserID)
+		// Runs once the job's own outcome is stored, including after a panic
+		defer h.settleVariantFamily(job)
+
+		// Registered before the slot is awaited, so a cancel also stops a job that is still waiting
+		ctx := adapters.WithModelOverrides(metrics.WithRecorder(traced, h.metrics), job.ModelOverrides)
+		ctx = adapters.WithNarrationLanguage(ctx, job.Language)
+		ctx, done := h.cancellations.start(repository.WithAssetLedger(ctx, job), jobID)
+		defer done()
+
+		// Acquire semaphore slot (blocks if all slots are in use)
+		if err := h.semaphore.Acquire(ctx); err != nil {
+			if h.stopIfCanceled(ctx, job) {
+				return
+			}
+			log.Error("Failed to acquire semaphore", zap.Error(err))
+			h.markJobFailed(job, "System overloaded, please try again", err.Error())
+			return
+		}
+		defer h.semaphore.Release()
+
+		// Add panic recovery
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("Panic in video generation", zap.Any("panic", r))
+				h.markJobFailed(job, "Internal error during generation", fmt.Sprintf("panic: %v", r))
+			}
+		}()
+
+		run(ctx)
+	}()
+}
+
+// log returns the handler's logger with the request and job IDs ctx carries
+func (h *GenerateHandler) log(ctx context.Context) *zap.Logger {
+	return trace.Logger(ctx, h.logger)
+}
+
+// markJobFailed fails a job whose pipeline couldn't run to completion, refunding its credits
+func (h *GenerateHandler) markJobFailed(job *domain.Job, errorMessage, cause string) {
+	h.refundCredits(job)
+	if err := h.jobRepo.MarkJobFailed(context.Background(), job.JobID, errorMessage, "internal", cause); err != nil {
+		return
+	}
+	recordJobOutcome(h.metrics, job, outcomeFailed, "internal")
+	h.notifyJobFinished(job.JobID)
+}
+
+// jobFinished frees the user's active job slot
+func (h *GenerateHandler) jobFinished(userID string) {
+	if h.dispatcher != nil {
+		h.dispatcher.finish(context.Background(), userID)
+	}
 }
 
-// isValidVeoDuration checks if duration can be formed by summing 4, 6, or 8 second clips
-// Veo 3.1 constraint: each clip must be exactly 4, 6, or 8 seconds
-func isValidVeoDuration(duration int) bool {
-	// Valid durations that can be formed with 4, 6, 8 second clips (10-60 range)
-	validDurations := []int{
-		10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30,
-		32, 34, 36, 38, 40, 42, 44, 46, 48, 50,
-		52, 54, 56, 58, 60,
-	}
-	for _, valid := range validDurations {
-		if duration == valid {
-			return true
+// PipelineRunning reports whether this server is running the job's generation pipeline
+func (h *GenerateHandler) PipelineRunning(jobID string) bool {
+	return h.cancellations.isRunning(jobID)
+}
+
+// RunJobQueue starts jobs left queued by a previous server and keeps promoting queued
+// jobs until ctx is done. It returns immediately when the job limit is disabled.
+func (h *GenerateHandler) RunJobQueue(ctx context.Context) {
+	if h.dispatcher != nil {
+		h.dispatcher.run(ctx)
+	}
+}
+
+// saveNewJob stores a job, queued when the user is at their active job limit.
+// It reports whether the job should start now.
+func (h *GenerateHandler) saveNewJob(ctx context.Context, job *domain.Job, save func(ctx context.Context, job *domain.Job) error) (bool, error) {
+	if h.dispatcher == nil {
+		return true, save(ctx, job)
+	}
+	startNow, err := h.dispatcher.admit(ctx, job, save)
+	if err == nil && !startNow {
+		h.recordStage(ctx, job, "Queued behind the user's other active jobs", 0)
+	}
+	return startNow, err
+}
+
+// assignAssetLocation freezes the bucket and key prefix a new job's assets are stored under
+func (h *GenerateHandler) assignAssetLocation(ctx context.Context, job *domain.Job) error {
+	if h.locator == nil {
+		return nil
+	}
+	return h.locator.Assign(ctx, job)
+}
+
+// startQueuedJob runs a job promoted from the queue
+func (h *GenerateHandler) startQueuedJob(job *domain.Job) {
+	h.startSavedJob(context.Background(), job)
+}
+
+// startSavedJob runs a stored job from what it saved. Jobs with a script (approved previews
+// and scripted jobs being rerun) resume from it; other jobs start from their prompt.
+func (h *GenerateHandler) startSavedJob(parent context.Context, job *domain.Job) {
+	if len(job.Scenes) > 0 {
+		if !h.enterStage(parent, job, domain.StageScriptComplete) {
+			return
+		}
+		h.runInBackground(parent, job, func(ctx context.Context) {
+			h.resumeApprovedJob(ctx, job)
+		})
+		return
+	}
+
+	if !h.enterStage(parent, job, domain.StageScriptGenerating) {
+		return
+	}
+	h.runInBackground(parent, job, func(ctx context.Context) {
+		var brand *domain.BrandGuidelines
+		if job.BrandGuidelineID != "" && h.brandRepo != nil {
+			guidelines, err := h.brandRepo.GetBrandGuidelines(ctx, job.BrandGuidelineID)
+			if err != nil {
+				h.failJob(ctx, job, domain.StageQueued.String(), "Brand guidelines could not be loaded. Please try again.", err)
+				return
+			}
+			brand = guidelines
 		}
+		h.generateVideoAsync(ctx, job, generateRequestFromJob(job), brand)
+	})
+}
+
+// errJobNotFailed is returned when requeueing a job that hasn't failed
+var errJobNotFailed = stderrors.New("job has not failed")
+
+// errVariantParent is returned when requeueing an A/B variant parent; its variants are requeued instead
+var errVariantParent = stderrors.New("job is a variant parent")
+
+// RequeueJob reruns a failed job for its owner, from its script when one was generated and
+// from its prompt otherwise. The job counts against the owner's active job limit, so it may
+// be queued instead of started; the result reports whether it started now. The failure
+// deleted the job's assets and refunded its credits, and the rerun is not charged again.
+func (h *GenerateHandler) RequeueJob(ctx context.Context, job *domain.Job) (bool, error) {
+	if job.IsVariantParent() {
+		return false, errVariantParent
+	}
+	if err := resetFailedJob(job, time.Now(), h.retentionDays); err != nil {
+		return false, err
+	}
+
+	// Recorded first so the trail reads in order when the rerun is queued
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventRetry,
+		Stage:   job.Stage.String(),
+		Message: fmt.Sprintf("Rerun requested after the job failed (rerun %d)", job.Requeues),
+	})
+	startNow, err := h.saveNewJob(ctx, job, h.jobRepo.UpdateJob)
+	if err != nil {
+		return false, err
+	}
+	if job.ParentJobID != "" {
+		h.reopenVariantParent(ctx, job.ParentJobID)
+	}
+	if startNow {
+		h.startSavedJob(ctx, job)
 	}
-	return false
+
+	h.log(trace.WithJobID(ctx, job.JobID)).Info("Failed job requeued",
+		zap.String("user_id", job.UserID),
+		zap.Int("requeues", job.Requeues),
+		zap.Bool("started", startNow),
+	)
+	return startNow, nil
+}
+
+// resetFailedJob clears a failed job's failure and progress so it can run again
+func resetFailedJob(job *domain.Job, now time.Time, retentionDays int) error {
+	if job.Status != domain.StatusFailed {
+		return errJobNotFailed
+	}
+
+	// A rerun starts over from wherever the failure stopped it
+	job.Status = domain.StatusProcessing
+	job.Stage = domain.StageRequeued
+	job.ErrorMessage = nil
+	job.FailureStage = ""
+	job.FailureError = ""
+	job.Requeues++
+	job.UpdatedAt = now.Unix()
+	job.ExpiresAt, job.TTL = jobExpiry(now, retentionDays)
+
+	// The rerun generates everything again, replacing clips a composition failure kept
+	job.ScenesCompleted = 0
+	job.SceneVideoURLs = nil
+	job.SceneVersions = nil
+	job.ClipVersions = nil
+	job.PromptVersions = nil
+	job.NarratorAudioURL = ""
+	job.AudioURL = ""
+	job.ThumbnailURL = ""
+	job.Thumbnails = nil
+	return nil
+}
+
+// generateRequestFromJob rebuilds the pipeline options of a job saved by Generate
+func generateRequestFromJob(job *domain.Job) GenerateRequest {
+	return GenerateRequest{
+		Prompt:              job.Prompt,
+		Duration:            job.Duration,
+		AspectRatio:         job.AspectRatio,
+		Model:               job.Model,
+		Voice:               job.Voice,
+		SideEffects:         job.SideEffects,
+		SideEffectsOverflow: job.SideEffectsOverflow,
+		TTSProvider:         job.TTSProvider,
+		Language:            job.Language,
+		StartImage:          job.StartImage,
+		StyleReferenceImage: job.StyleReferenceImage,
+		Title:               job.Title,
+		Style:               job.Style,
+		Tone:                job.Tone,
+		Tempo:               job.Tempo,
+		Platform:            job.Platform,
+		Audience:            job.Audience,
+		Goal:                job.Goal,
+		CallToAction:        job.CallToAction,
+		ProCinematography:   job.ProCinematography,
+		CreativeBoost:       job.CreativeBoost,
+		Preview:             job.Preview,
+		GenerateSFX:         job.GenerateSFX,
+		AllowSilentFallback: job.AllowSilentFallback,
+		BurnCaptions:        job.BurnCaptions,
+		LogoOverlay:         job.LogoOverlay,
+		EndCard:             job.EndCard,
+		Output:              job.OutputSpec,
+		ProductImages:       job.ProductImages,
+		ScenePlan:           job.ScenePlan,
+		ContinuityMode:      job.ContinuityMode,
+		GuidelineID:         job.BrandGuidelineID,
+	}
+}
+
+// refundCredits returns a failed job's credits for the scenes it never generated.
+// It uses its own context so a timed-out job can still be refunded.
+func (h *GenerateHandler) refundCredits(job *domain.Job) {
+	if h.usageService == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	h.usageService.RefundJob(ctx, job)
 }
 
 // Generate handles POST /api/v1/generate - FULLY ASYNC (returns instantly)
@@ -124,100 +642,139 @@ func isValidVeoDuration(duration int) bool {
 // @Accept json
 // @Produce json
 // @Param request body GenerateRequest true "Video generation parameters"
+// @Param Idempotency-Key header string false "Client key (up to 255 printable ASCII characters) making retries safe for 24 hours"
+// @Success 200 {object} GenerateResponse "Replay of an earlier request with the same Idempotency-Key"
 // @Success 202 {object} GenerateResponse
-// @Failure 400 {object} errors.ErrorResponse
+// @Failure 400 {object} errors.ErrorResponse "Malformed JSON body"
 // @Failure 401 {object} errors.ErrorResponse "Unauthorized"
+// @Failure 402 {object} errors.ErrorResponse "Not enough credits (details include the required credits, remaining balance and cost breakdown)"
+// @Failure 404 {object} errors.ErrorResponse "Brand guidelines not found"
+// @Failure 409 {object} errors.ErrorResponse "Idempotency-Key reused with a different body, or its first request is still in progress"
+// @Failure 422 {object} errors.ErrorResponse "Field validation errors, or referenced assets failed verification (ASSET_NOT_VERIFIED)"
 // @Failure 500 {object} errors.ErrorResponse
 // @Router /api/v1/generate [post]
 // @Security BearerAuth
 func (h *GenerateHandler) Generate(c *gin.Context) {
 	var req GenerateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Invalid request body", zap.Error(err))
+	explicit, ok := h.bindGenerateRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+	if idempotencyKey != "" && !validIdempotencyKey(idempotencyKey) {
 		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
-				"validation_error": err.Error(),
-			}),
+			Error: errors.NewAPIError(errors.ErrInvalidRequest,
+				"Idempotency-Key must be 1-255 printable ASCII characters", nil),
 		})
 		return
 	}
+	// Taken before preferences and defaults are filled in, so it reflects what the client sent
+	fingerprint := requestFingerprint(req)
 
-	isPharmaceuticalAd := strings.TrimSpace(req.Voice) != "" || strings.TrimSpace(req.SideEffects) != ""
-
-	if isPharmaceuticalAd {
-		trimmedVoice := strings.TrimSpace(req.Voice)
-		trimmedSideEffects := strings.TrimSpace(req.SideEffects)
-
-		// Voice validation
-		if trimmedVoice == "" {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error: errors.NewValidationError("voice", "Please select a narrator voice (male or female)"),
-			})
-			return
-		}
+	preferred, ok := h.applyUserPreferences(c, &req, explicit)
+	if !ok {
+		return
+	}
 
-		if trimmedVoice != "male" && trimmedVoice != "female" {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error: errors.NewValidationError("voice", "Invalid voice selection. Choose 'male' or 'female'"),
-			})
-			return
-		}
+	h.createJob(c, req, newJobOptions{
+		preferred:      preferred,
+		idempotencyKey: idempotencyKey,
+		fingerprint:    fingerprint,
+	})
+}
 
-		// Side effects validation
-		if trimmedSideEffects == "" {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error: errors.NewValidationError("side_effects", "Side effects disclosure is required for pharmaceutical ads"),
-			})
-			return
-		}
+// newJobOptions is what createJob needs to know about a request besides its GenerateRequest
+type newJobOptions struct {
+	preferred      []string // Fields filled in from the user's preferences
+	idempotencyKey string
+	fingerprint    string
+	duplicatedFrom string // The job a duplicate was copied from, whose images must still exist
+}
 
-		sideEffectsLength := len(trimmedSideEffects)
-		if sideEffectsLength < 10 {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error: errors.NewValidationError("side_effects", "Side effects text must be at least 10 characters"),
-			})
-			return
-		}
+// createJob validates req and the assets it references, then charges, saves and starts its job
+// and writes the response
+func (h *GenerateHandler) createJob(c *gin.Context, req GenerateRequest, opts newJobOptions) {
+	// Resolve the TTS provider so voices are checked against, and the job records, the one that
+	// will actually narrate
+	ttsProvider := h.resolveTTSProvider(req)
+	input := req.validationInput(ttsProvider)
+	if errs := validation.ValidateGenerate(input); len(errs) > 0 {
+		h.logger.Info("Generate request failed validation", zap.String("errors", errs.Error()))
+		respondValidationErrors(c, errs)
+		return
+	}
+	isPharmaceuticalAd := input.IsPharmaceutical()
 
-		if sideEffectsLength > 500 {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error: errors.NewValidationError("side_effects",
-					fmt.Sprintf("Side effects text cannot exceed 500 characters (currently: %d)", sideEffectsLength)),
-			})
-			return
-		}
+	// Resolve the default model so the job records which adapter it used
+	adapterType, _ := adapters.ParseAdapterType(req.Model)
+	req.Model = string(adapterType)
 
-		// Product image validation
-		if strings.TrimSpace(req.StartImage) == "" {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error: errors.NewValidationError("start_image", "Product image is required for pharmaceutical ads"),
-			})
-			return
-		}
+	// Get user ID from auth context
+	userID := auth.MustGetUserID(c)
 
-		// Persist trimmed values
-		req.Voice = trimmedVoice
-		req.SideEffects = trimmedSideEffects
+	modelOverrides, apiErr := h.modelOverridesFor(c, userID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, errors.ErrorResponse{
+			Error: apiErr,
+		})
+		return
 	}
 
-	req.StartImage = strings.TrimSpace(req.StartImage)
-
-	// Validate duration can be formed by 4, 6, or 8 second clips (Veo 3.1 constraint)
-	if req.Duration < 10 || req.Duration > 60 || !isValidVeoDuration(req.Duration) {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error: errors.NewValidationError("duration", "Duration must be between 10-60 seconds and achievable with 4, 6, or 8 second clips"),
+	brandGuidelines, apiErr := h.resolveBrandGuidelines(c.Request.Context(), userID, req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, errors.ErrorResponse{
+			Error: apiErr,
 		})
 		return
 	}
 
-	// Get user ID from auth context
-	userID := auth.MustGetUserID(c)
+	// Assets are checked against storage up front so a bad one fails the request, not the job
+	var imageErrs validation.Errors
+	req.StartImage = resolveImageRef(c.Request.Context(), h.s3Service, h.assetsBucket, h.locator, userID, "start_image", req.StartImage, &imageErrs)
+	req.StyleReferenceImage = resolveImageRef(c.Request.Context(), h.s3Service, h.assetsBucket, h.locator, userID, "style_reference_image", req.StyleReferenceImage, &imageErrs)
+	if opts.duplicatedFrom != "" {
+		// A duplicate's images were checked for the original job, and may have been deleted since
+		requireStoredImage(c.Request.Context(), h.s3Service, h.assetsBucket, h.locator, userID, "start_image", req.StartImage, &imageErrs)
+		requireStoredImage(c.Request.Context(), h.s3Service, h.assetsBucket, h.locator, userID, "style_reference_image", req.StyleReferenceImage, &imageErrs)
+	}
+	if len(imageErrs) > 0 {
+		h.logger.Info("Generate request references invalid assets", zap.String("errors", imageErrs.Error()))
+		respondValidationErrors(c, imageErrs)
+		return
+	}
+	if req.LogoOverlay != nil {
+		logo, errs := checkLogoOverlay(c.Request.Context(), h.s3Service, h.assetsBucket, h.locator, userID, req.LogoOverlay, brandGuidelines)
+		if len(errs) > 0 {
+			h.logger.Info("Generate request has an invalid logo", zap.String("errors", errs.Error()))
+			respondValidationErrors(c, errs)
+			return
+		}
+		req.LogoOverlay = logo
+	}
+	if len(req.ProductImages) > 0 {
+		images, errs := checkProductImages(c.Request.Context(), h.s3Service, h.assetsBucket, h.locator, userID, req.ProductImages)
+		if len(errs) > 0 {
+			h.logger.Info("Generate request has invalid product images", zap.String("errors", errs.Error()))
+			respondValidationErrors(c, errs)
+			return
+		}
+		req.ProductImages = images
+	}
+	if h.assets != nil {
+		if apiErr := h.assets.require(c.Request.Context(), userID, jobAssetRefs(h.locator, userID, req)); apiErr != nil {
+			h.logger.Info("Generate request references unverified assets", zap.String("user_id", userID))
+			c.JSON(apiErr.Status, errors.ErrorResponse{Error: apiErr})
+			return
+		}
+	}
 
 	h.logger.Info("Starting fully async video generation",
 		zap.String("user_id", userID),
 		zap.String("prompt", req.Prompt),
 		zap.Int("duration", req.Duration),
 		zap.String("aspect_ratio", req.AspectRatio),
+		zap.String("model", req.Model),
 		zap.String("voice", req.Voice),
 		zap.Int("side_effects_length", len(req.SideEffects)),
 		zap.Bool("is_pharmaceutical_ad", isPharmaceuticalAd),
@@ -230,14 +787,22 @@ func (h *GenerateHandler) Generate(c *gin.Context) {
 		JobID:       jobID,
 		UserID:      userID,
 		Status:      domain.StatusProcessing,
-		Stage:       "script_generating",
+		Stage:       domain.StageScriptGenerating,
 		Prompt:      req.Prompt,
 		Duration:    req.Duration,
 		AspectRatio: req.AspectRatio,
-		Model:       h.veoAdapter.GetModelName(),
+		StartImage:  req.StartImage,
+		Model:       req.Model,
 
-		Voice:       req.Voice,
-		SideEffects: req.SideEffects,
+		// Kept so a queued job can be started later with the same options
+		StyleReferenceImage: req.StyleReferenceImage,
+		Preview:             req.Preview,
+
+		Voice:               req.Voice,
+		SideEffects:         req.SideEffects,
+		SideEffectsOverflow: req.SideEffectsOverflow,
+		TTSProvider:         string(ttsProvider),
+		Language:            req.Language,
 
 		// Enhanced prompt options (Phase 1)
 		Style:             req.Style,
@@ -250,55 +815,128 @@ func (h *GenerateHandler) Generate(c *gin.Context) {
 		ProCinematography: req.ProCinematography,
 		CreativeBoost:     req.CreativeBoost,
 
+		GenerateSFX:         req.GenerateSFX,
+		AllowSilentFallback: req.AllowSilentFallback,
+		BurnCaptions:        req.BurnCaptions,
+		LogoOverlay:         req.LogoOverlay,
+		EndCard:             req.EndCard,
+		OutputSpec:          req.Output,
+		RenderTransitions:   h.sceneTransitions,
+		PreferencesApplied:  opts.preferred,
+		DuplicatedFrom:      opts.duplicatedFrom,
+
+		ProductImages:  req.ProductImages,
+		ScenePlan:      req.ScenePlan,
+		ContinuityMode: req.ContinuityMode,
+
+		ModelOverrides: modelOverrides,
+
+		CallbackURL:    req.CallbackURL,
+		CallbackSecret: req.CallbackSecret,
+
 		CreatedAt: now,
 		UpdatedAt: now,
-		TTL:       time.Now().Add(7 * 24 * time.Hour).Unix(),
 	}
+	job.ExpiresAt, job.TTL = jobExpiry(time.Unix(now, 0), h.retentionDays)
 
-	// Set title if provided
-	if req.Title != "" {
-		job.Title = req.Title
+	// Set title if provided; otherwise the job is listed under its prompt until the script is written
+	job.Title = req.Title
+	if job.Title == "" {
+		job.Title = fallbackTitle(req.Prompt)
 	}
 
-	// Save job to database
-	if err := h.jobRepo.CreateJob(c.Request.Context(), job); err != nil {
-		h.logger.Error("Failed to create job", zap.Error(err))
+	if brandGuidelines != nil {
+		job.BrandGuidelineID = brandGuidelines.GuidelineID
+	}
+
+	// Variants are copied from the job, so they store their assets alongside it
+	if err := h.assignAssetLocation(c.Request.Context(), job); err != nil {
+		h.logger.Error("Failed to assign job asset location", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error: errors.ErrInternalServer,
 		})
 		return
 	}
 
-	// Launch async video generation in goroutine with semaphore limiting
-	go func() {
-		// Acquire semaphore slot (blocks if all slots are in use)
-		if err := h.semaphore.Acquire(context.Background()); err != nil {
-			h.logger.Error("Failed to acquire semaphore",
-				zap.String("job_id", jobID),
-				zap.Error(err),
+	// Claim the idempotency key before anything is charged or started, so a retry can
+	// only ever find this job
+	useIdempotencyKey := opts.idempotencyKey != "" && h.idempotency != nil
+	if useIdempotencyKey {
+		existing, err := h.idempotency.reserve(c.Request.Context(), userID, opts.idempotencyKey, opts.fingerprint, jobID)
+		if err != nil {
+			respondIdempotencyError(c, h.logger, userID, err)
+			return
+		}
+		if existing != nil {
+			h.logger.Info("Replaying job for repeated Idempotency-Key",
+				zap.String("user_id", userID),
+				zap.String("job_id", existing.JobID),
 			)
-			h.jobRepo.MarkJobFailed(context.Background(), jobID, "System overloaded, please try again")
+			respondReplayedJob(c, existing)
 			return
 		}
-		defer h.semaphore.Release()
+	}
 
-		// Add panic recovery
-		defer func() {
-			if r := recover(); r != nil {
-				h.logger.Error("Panic in video generation",
-					zap.String("job_id", jobID),
-					zap.Any("panic", r),
-				)
-				h.jobRepo.MarkJobFailed(context.Background(), jobID, "Internal error during generation")
+	// A variant parent only writes the scripts; its variants are charged and generated instead
+	var variants []*domain.Job
+	if req.Variants > 1 {
+		job.Variants = req.Variants
+		variants = newVariantJobs(job)
+	}
+
+	// Take the job's credits before any work is queued. The script doesn't exist yet,
+	// so the price uses the fewest scenes that cover the duration. Previews are charged when
+	// they're approved, so one left to expire costs nothing.
+	if h.usageService != nil && !req.Preview {
+		cost := pricing.QuoteJob(req.Duration, pricing.EstimateSceneCount(req.Duration, adapterType), adapterType)
+		var err error
+		if len(variants) > 0 {
+			err = h.chargeVariants(c.Request.Context(), variants, subscriptionTier(c), cost)
+		} else {
+			_, err = h.usageService.ChargeJob(c.Request.Context(), job, subscriptionTier(c), cost)
+		}
+		if err != nil {
+			if useIdempotencyKey {
+				h.idempotency.release(userID, opts.idempotencyKey, jobID)
 			}
-		}()
+			respondChargeError(c, h.logger, userID, err, cost)
+			return
+		}
+	}
 
-		h.generateVideoAsync(context.Background(), job, req)
-	}()
+	// Save job to database, queued if the user already has their limit of active jobs
+	err := h.createVariantJobs(c.Request.Context(), variants)
+	startNow := false
+	if err == nil {
+		startNow, err = h.saveNewJob(c.Request.Context(), job, h.jobRepo.CreateJob)
+	}
+	if err != nil {
+		h.logger.Error("Failed to create job", zap.Error(err))
+		h.refundCredits(job)
+		for _, variant := range variants {
+			h.refundCredits(variant)
+		}
+		if useIdempotencyKey {
+			h.idempotency.release(userID, opts.idempotencyKey, jobID)
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	// Launch async video generation in goroutine with semaphore limiting;
+	// queued jobs are started by the dispatcher when a slot frees up
+	if startNow {
+		h.runInBackground(c.Request.Context(), job, func(ctx context.Context) {
+			h.generateVideoAsync(ctx, job, req, brandGuidelines)
+		})
+	}
 
 	h.logger.Info("Job created, async generation queued",
 		zap.String("job_id", jobID),
-		zap.String("stage", "script_generating"),
+		zap.String("status", job.Status),
+		zap.Stringer("stage", job.Stage),
 		zap.Int("available_slots", h.semaphore.Available()),
 	)
 
@@ -306,9 +944,10 @@ func (h *GenerateHandler) Generate(c *gin.Context) {
 	response := GenerateResponse{
 		JobID:               jobID,
 		Status:              job.Status,
-		NumClips:            0, // Will be set after script generation
+		NumClips:            len(req.ScenePlan), // Without a scene plan, set after script generation
 		CreatedAt:           job.CreatedAt,
 		EstimatedCompletion: EstimatedCompletionSeconds, // ~5 minutes total
+		VariantJobIDs:       job.VariantJobIDs,
 	}
 
 	c.JSON(http.StatusAccepted, response)
@@ -2,52 +2,59 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
-	"unicode/utf8"
 
 	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/composition"
 	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/moderation"
+	"github.com/omnigen/backend/internal/prompts"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
 	"github.com/omnigen/backend/internal/service"
 	"go.uber.org/zap"
 )
 
-// ClipVideo represents a generated video clip
-type ClipVideo struct {
-	VideoURL     string
-	LastFrameURL string
-	Duration     float64
-}
-
 // S3 Key Generation Helpers
 //
-// These helpers centralize S3 key construction for all job assets.
-// Every key follows the pattern: users/{userID}/jobs/{jobID}/{type}/{filename}
+// These helpers, with the composition package's, centralize S3 key construction for all job
+// assets.
+// Every key follows the pattern: {prefix}users/{userID}/jobs/{jobID}/{type}/{filename}, where
+// {prefix} is the key prefix recorded on the job (see repository.AssetLocator; "" for jobs from
+// before prefixes). The assets are stored in the job's bucket (jobAssetBucket).
 //
 // Folder structure:
 //
-//   users/{userID}/jobs/{jobID}/
+//   {prefix}users/{userID}/jobs/{jobID}/
 //     ├── clips/
-//     │   ├── scene-001.mp4          (buildSceneClipKey)
+//     │   ├── scene-001.mp4          (composition.SceneClipKey)
 //     │   ├── scene-002.mp4
 //     │   └── scene-NNN.mp4
 //     ├── thumbnails/
-//     │   ├── scene-001.jpg          (buildSceneThumbnailKey)
+//     │   ├── scene-001.jpg          (composition.SceneThumbnailKey)
 //     │   ├── scene-002.jpg
-//     │   └── job-thumbnail.jpg      (buildJobThumbnailKey)
+//     │   ├── job-thumbnail-320.jpg  (buildJobThumbnailKey; one per ThumbnailWidths entry,
+//     │   ├── job-thumbnail-640.jpg   plus .webp copies when WebP thumbnails are enabled)
+//     │   ├── job-thumbnail-1280.jpg
+//     │   └── scrub/
+//     │       ├── sprite.jpg         (buildScrubSpriteKey; final video frames for hover-scrub)
+//     │       └── thumbnails.vtt     (buildScrubVTTKey; WebVTT index into the sprite sheet)
 //     ├── audio/
-//     │   ├── background-music.mp3   (buildAudioKey)
-//     │   └── narrator-voiceover.mp3 (buildNarratorAudioKey)
+//     │   ├── background-music.mp3   (composition.AudioKey)
+//     │   └── narrator-voiceover.mp3 (composition.NarratorAudioKey)
+//     ├── captions/
+//     │   └── narrator.vtt           (buildCaptionsKey)
 //     └── final/
-//         └── video.mp4               (buildFinalVideoKey)
+//         ├── video-{hash}.mp4        (composition.FinalVideoKey; .mov for a mov output spec)
+//         └── video-{hash}.webm       (composition.FinalWebMKey; {hash} identifies the composition's inputs)
 //
 // Usage notes:
 //   - Clips: Raw scene videos generated per scene (no audio)
@@ -55,61 +62,48 @@ type ClipVideo struct {
 //   - Audio: Separate tracks (music via Minimax, narrator via TTS)
 //   - Final: Composited video without audio tracks, ready for playback
 
-func buildSceneClipKey(userID, jobID string, sceneNumber int) string {
-	return fmt.Sprintf("users/%s/jobs/%s/clips/scene-%03d.mp4", userID, jobID, sceneNumber)
-}
-
-func buildSceneThumbnailKey(userID, jobID string, sceneNumber int) string {
-	return fmt.Sprintf("users/%s/jobs/%s/thumbnails/scene-%03d.jpg", userID, jobID, sceneNumber)
-}
-
-func buildAudioKey(userID, jobID string) string {
-	return fmt.Sprintf("users/%s/jobs/%s/audio/background-music.mp3", userID, jobID)
-}
-
-func buildNarratorAudioKey(userID, jobID string) string {
-	return fmt.Sprintf("users/%s/jobs/%s/audio/narrator-voiceover.mp3", userID, jobID)
-}
-
-func buildFinalVideoKey(userID, jobID string) string {
-	return fmt.Sprintf("users/%s/jobs/%s/final/video.mp4", userID, jobID)
-}
-
-func buildFinalWebMKey(userID, jobID string) string {
-	return fmt.Sprintf("users/%s/jobs/%s/final/video.webm", userID, jobID)
-}
-
-func buildJobThumbnailKey(userID, jobID string) string {
-	return fmt.Sprintf("users/%s/jobs/%s/thumbnails/job-thumbnail.jpg", userID, jobID)
-}
-
-// buildVersionedSceneClipKey returns S3 key for a specific clip version
-func buildVersionedSceneClipKey(userID, jobID string, sceneNumber, version int) string {
-	return fmt.Sprintf("users/%s/jobs/%s/clips/scene-%03d-v%d.mp4", userID, jobID, sceneNumber, version)
+func buildRawAudioKey(job *domain.Job) string {
+	return jobAssetPrefix(job) + "audio/background-music-raw.mp3"
 }
 
-// buildVersionedSceneThumbnailKey returns S3 key for a specific scene thumbnail version
-func buildVersionedSceneThumbnailKey(userID, jobID string, sceneNumber, version int) string {
-	return fmt.Sprintf("users/%s/jobs/%s/thumbnails/scene-%03d-v%d.jpg", userID, jobID, sceneNumber, version)
+// buildJobThumbnailKey returns S3 key for one size and format of the job thumbnail
+func buildJobThumbnailKey(job *domain.Job, width int, format string) string {
+	return jobAssetPrefix(job) + fmt.Sprintf("thumbnails/job-thumbnail-%d.%s", width, format)
 }
 
 const (
 	scriptFailureMessage      = "Script generation failed. Please check your prompt and try again."
 	narratorFailureMessage    = "Voiceover generation failed. Please try again later."
 	sceneFailureMessageFormat = "Video generation failed at scene %d. Please try again."
+	clipQualityMessageFormat  = "Video generation failed at scene %d: the generated clip failed quality check. Please try again."
 	audioFailureMessage       = "Background music generation failed. Please try again."
 	compositionFailureMessage = "Video composition failed. Please try again."
+	complianceFailureMessage  = "The generated script did not pass pharmaceutical compliance checks. Please revise your prompt and try again."
+	scenePlanFailureMessage   = "The generated script did not follow the scene plan. Please try again."
+	stageFailureMessage       = "Video generation stopped unexpectedly. Please try again."
+	tmpSpaceFailureMessage    = "Video composition failed: the server ran out of temporary storage. Please try again in a few minutes."
 )
 
+// scriptFailure is the user message for a failed script generation
+func scriptFailure(err error) string {
+	var mismatch *adapters.ScenePlanMismatchError
+	if errors.As(err, &mismatch) {
+		return scenePlanFailureMessage
+	}
+	return scriptFailureMessage
+}
+
+// failJob fails a job that stopped at stage, refunding its credits
 func (h *GenerateHandler) failJob(
 	ctx context.Context,
 	job *domain.Job,
+	stage string,
 	userMessage string,
 	internalErr error,
 	fields ...zap.Field,
 ) {
 	logFields := []zap.Field{
-		zap.String("job_id", job.JobID),
+		zap.String("stage", stage),
 		zap.String("user_message", userMessage),
 	}
 	if internalErr != nil {
@@ -119,66 +113,142 @@ func (h *GenerateHandler) failJob(
 		logFields = append(logFields, fields...)
 	}
 
-	h.logger.Error("Job failed", logFields...)
-	h.cleanupJobAssets(job.UserID, job.JobID)
+	// Stages interrupted by a cancel fail with context errors; the job is canceled, not failed
+	if h.cancellations.requested(ctx, job.JobID) {
+		h.finishCanceledJob(job)
+		return
+	}
 
-	// Build detailed error message for user
-	errorMessage := userMessage
-	if internalErr != nil {
-		// Extract meaningful error details
-		errStr := internalErr.Error()
-
-		// Add technical details in a user-friendly way
-		// Check for common error patterns and provide helpful context
-		if strings.Contains(errStr, "Payment required") || strings.Contains(errStr, "status 402") || strings.Contains(errStr, "status 402") {
-			// HTTP 402 - Payment Required (Replicate credits/billing issue)
-			errorMessage = "Script generation failed due to insufficient Replicate API credits. Please check your Replicate account balance and billing settings."
-		} else if strings.Contains(errStr, "API error") || (strings.Contains(errStr, "status") && !strings.Contains(errStr, "exit status")) {
-			// API errors - include status code if available (but not ffmpeg/process exit codes)
-			// For 422 errors, try to extract more details from the response
-			if strings.Contains(errStr, "422") {
-				// Try to extract the actual error message from Replicate
-				if strings.Contains(errStr, "Response:") {
-					// Extract the response body which should contain the actual error
-					parts := strings.Split(errStr, "Response:")
-					if len(parts) > 1 {
-						responseBody := strings.TrimSpace(parts[1])
-						// Limit response body length
-						if len(responseBody) > 200 {
-							responseBody = responseBody[:200] + "..."
-						}
-						errorMessage = fmt.Sprintf("%s (API Error: HTTP 422 - %s)", userMessage, responseBody)
-					} else {
-						errorMessage = fmt.Sprintf("%s (API Error: %s)", userMessage, extractAPIError(errStr))
-					}
-				} else {
-					errorMessage = fmt.Sprintf("%s (API Error: %s)", userMessage, extractAPIError(errStr))
-				}
-			} else {
-				errorMessage = fmt.Sprintf("%s (API Error: %s)", userMessage, extractAPIError(errStr))
-			}
-		} else if strings.Contains(errStr, "timeout") || strings.Contains(errStr, "context deadline") {
-			errorMessage = fmt.Sprintf("%s (Request timed out. The service may be busy. Please try again.)", userMessage)
-		} else if strings.Contains(errStr, "authentication") || strings.Contains(errStr, "unauthorized") || strings.Contains(errStr, "401") {
-			errorMessage = fmt.Sprintf("%s (Authentication failed. Please check API configuration.)", userMessage)
-		} else if strings.Contains(errStr, "rate limit") || strings.Contains(errStr, "429") {
-			errorMessage = fmt.Sprintf("%s (Rate limit exceeded. Please wait a moment and try again.)", userMessage)
-		} else {
-			// For other errors, include a sanitized version of the error
-			// Truncate very long error messages
-			if len(errStr) > 200 {
-				errStr = errStr[:200] + "..."
-			}
-			errorMessage = fmt.Sprintf("%s (Error: %s)", userMessage, errStr)
-		}
+	h.log(ctx).Error("Job failed", logFields...)
+	if !keepsAssetsOnFailure(stage) {
+		h.cleanupJobAssets(job)
 	}
+	h.refundCredits(job)
 
-	if err := h.jobRepo.MarkJobFailed(ctx, job.JobID, errorMessage); err != nil {
-		h.logger.Error("Failed to mark job failed",
-			zap.String("job_id", job.JobID),
+	errorMessage := failureMessage(userMessage, internalErr)
+
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventFailed,
+		Stage:   stage,
+		Message: errorMessage,
+	})
+
+	var cause string
+	if internalErr != nil {
+		cause = internalErr.Error()
+	}
+	if err := h.jobRepo.MarkJobFailed(ctx, job.JobID, errorMessage, stage, cause); err != nil {
+		h.log(ctx).Error("Failed to mark job failed",
 			zap.Error(err),
 		)
+		return
+	}
+	recordJobOutcome(h.metrics, job, outcomeFailed, stage)
+	h.notifyJobFinished(job.JobID)
+}
+
+// enterStage moves job to stage, failing it when its current stage doesn't lead there, as when
+// a run resumes in the wrong place. Returns false if the job was failed.
+func (h *GenerateHandler) enterStage(ctx context.Context, job *domain.Job, stage domain.JobStage) bool {
+	if err := job.AdvanceStage(stage); err != nil {
+		h.failJob(ctx, job, job.Stage.String(), stageFailureMessage, err)
+		return false
+	}
+	return true
+}
+
+// failureMessage is what a job that failed with userMessage tells the user about err. Typed
+// adapter errors are described from their provider and status; errors without one, like those
+// wrapped by code that predates them, fall back to legacyFailureMessage.
+func failureMessage(userMessage string, err error) string {
+	if err == nil {
+		return userMessage
+	}
+
+	var (
+		flagged  *moderation.FlaggedError
+		tooLarge *adapters.PromptTooLargeError
+		policy   *adapters.ContentPolicyError
+		credits  *adapters.InsufficientCreditsError
+		timeout  *adapters.TimeoutError
+		apiErr   *adapters.APIError
+	)
+	switch {
+	case errors.As(err, &flagged):
+		// Names the flagged prompt and category without the provider's wording
+		return moderationFailure(flagged.Flag)
+	case errors.Is(err, composition.ErrInsufficientTmpSpace):
+		return tmpSpaceFailureMessage
+	case errors.As(err, &tooLarge):
+		return fmt.Sprintf("%s (The prompt is too large for the job's token budget. Please shorten the brand guidelines or prompt and try again.)", userMessage)
+	case errors.As(err, &policy):
+		return fmt.Sprintf("%s (%s rejected the content under its content policy. Please revise your prompts and try again.)", userMessage, policy.Provider)
+	case errors.As(err, &credits):
+		return fmt.Sprintf("%s (Insufficient %s API credits. Please check your %s account balance and billing settings.)", userMessage, credits.Provider, credits.Provider)
+	case errors.As(err, &timeout):
+		return fmt.Sprintf("%s (%s request timed out. The service may be busy. Please try again.)", userMessage, timeout.Provider)
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("%s (%s)", userMessage, apiErrorDetail(apiErr))
+	}
+	return legacyFailureMessage(userMessage, err.Error())
+}
+
+// apiErrorDetail describes an error status from a provider for a failure message
+func apiErrorDetail(apiErr *adapters.APIError) string {
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return fmt.Sprintf("Authentication with %s failed. Please check API configuration.", apiErr.Provider)
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		return fmt.Sprintf("%s rate limit exceeded. Please wait a moment and try again.", apiErr.Provider)
+	case apiErr.StatusCode == http.StatusUnprocessableEntity:
+		// Replicate explains rejected inputs in the body
+		body := strings.TrimSpace(apiErr.Body)
+		if len(body) > 200 {
+			body = body[:200] + "..."
+		}
+		return fmt.Sprintf("%s API Error: HTTP 422 - %s", apiErr.Provider, body)
+	case adapters.IsRetryable(apiErr):
+		return fmt.Sprintf("%s API Error: HTTP %d. The service may be busy. Please try again.", apiErr.Provider, apiErr.StatusCode)
 	}
+	return fmt.Sprintf("%s API Error: HTTP %d", apiErr.Provider, apiErr.StatusCode)
+}
+
+// legacyFailureMessage classifies an error by its text, for errors that carry no typed adapter
+// error
+func legacyFailureMessage(userMessage, errStr string) string {
+	switch {
+	case strings.Contains(errStr, "Payment required") || strings.Contains(errStr, "status 402"):
+		// HTTP 402 - Payment Required (Replicate credits or OpenAI quota/billing issue)
+		provider := adapters.ProviderReplicate
+		if strings.Contains(errStr, "OpenAI") {
+			provider = adapters.ProviderOpenAI
+		}
+		return fmt.Sprintf("%s (Insufficient %s API credits. Please check your %s account balance and billing settings.)", userMessage, provider, provider)
+	case strings.Contains(errStr, "API error") || (strings.Contains(errStr, "status") && !strings.Contains(errStr, "exit status")):
+		// API errors - include status code if available (but not ffmpeg/process exit codes).
+		// For 422 errors, the response body explains what Replicate rejected.
+		if strings.Contains(errStr, "422") {
+			if parts := strings.Split(errStr, "Response:"); len(parts) > 1 {
+				responseBody := strings.TrimSpace(parts[1])
+				if len(responseBody) > 200 {
+					responseBody = responseBody[:200] + "..."
+				}
+				return fmt.Sprintf("%s (API Error: HTTP 422 - %s)", userMessage, responseBody)
+			}
+		}
+		return fmt.Sprintf("%s (API Error: %s)", userMessage, extractAPIError(errStr))
+	case strings.Contains(errStr, "timeout") || strings.Contains(errStr, "context deadline"):
+		return fmt.Sprintf("%s (Request timed out. The service may be busy. Please try again.)", userMessage)
+	case strings.Contains(errStr, "authentication") || strings.Contains(errStr, "unauthorized") || strings.Contains(errStr, "401"):
+		return fmt.Sprintf("%s (Authentication failed. Please check API configuration.)", userMessage)
+	case strings.Contains(errStr, "rate limit") || strings.Contains(errStr, "429"):
+		return fmt.Sprintf("%s (Rate limit exceeded. Please wait a moment and try again.)", userMessage)
+	}
+	// Include a sanitized version of other errors, truncating very long ones
+	if len(errStr) > 200 {
+		errStr = errStr[:200] + "..."
+	}
+	return fmt.Sprintf("%s (Error: %s)", userMessage, errStr)
 }
 
 // extractAPIError extracts meaningful information from API error messages
@@ -211,7 +281,14 @@ func extractAPIError(errStr string) string {
 	return errStr
 }
 
-func (h *GenerateHandler) cleanupJobAssets(userID, jobID string) {
+// keepsAssetsOnFailure reports whether a job that failed at stage keeps its S3 assets. A job that
+// failed composing has every clip and its audio, so POST /jobs/:id/recompose can finish it; one
+// that failed earlier can only be rerun from its script.
+func keepsAssetsOnFailure(stage string) bool {
+	return stage == domain.StageComposing.String()
+}
+
+func (h *GenerateHandler) cleanupJobAssets(job *domain.Job) {
 	if h.s3Service == nil || h.assetsBucket == "" {
 		return
 	}
@@ -219,43 +296,168 @@ func (h *GenerateHandler) cleanupJobAssets(userID, jobID string) {
 	cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	prefix := fmt.Sprintf("users/%s/jobs/%s/", userID, jobID)
-	if err := h.s3Service.DeletePrefix(cleanupCtx, h.assetsBucket, prefix); err != nil {
+	prefix := jobAssetPrefix(job)
+	if _, err := h.s3Service.DeletePrefix(cleanupCtx, jobAssetBucket(job, h.assetsBucket), prefix); err != nil {
+		// Whatever is left stays counted; deleting the job later releases it
 		h.logger.Warn("Failed to cleanup S3 assets after job failure",
-			zap.String("job_id", jobID),
+			zap.String("job_id", job.JobID),
 			zap.String("prefix", prefix),
 			zap.Error(err),
 		)
+		return
 	}
+	h.clearJobAssets(cleanupCtx, job)
 }
 
 // generateVideoAsync runs the entire video generation pipeline in a goroutine
-func (h *GenerateHandler) generateVideoAsync(ctx context.Context, job *domain.Job, req GenerateRequest) {
+func (h *GenerateHandler) generateVideoAsync(ctx context.Context, job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) {
+	// A job canceled while it waited for a generation slot stops before any provider call
+	if h.stopIfCanceled(ctx, job) {
+		return
+	}
+
+	// A prompt the video models would reject fails before anything is spent on the script
+	if !h.moderatePrompt(ctx, job) {
+		return
+	}
+
+	// A/B variant parents generate their variants' scripts, then hand off to them
+	if job.Variants > 1 {
+		h.generateVariantsAsync(ctx, job, req, brand)
+		return
+	}
+
 	// Create job-specific context with timeout
-	jobCtx, cancel := context.WithTimeout(ctx, VideoGenerationTimeout)
+	jobCtx, cancel := context.WithTimeout(h.withTokenBudget(ctx, job), VideoGenerationTimeout)
 	defer cancel()
 
-	h.logger.Info("Starting async video generation",
-		zap.String("job_id", job.JobID),
+	h.log(ctx).Info("Starting async video generation",
+		zap.Bool("preview", req.Preview),
 	)
+	h.recordStage(ctx, job, "Generation started", 0)
 
-	// STEP 1: Generate script with GPT-4o (happens in background now!)
-	h.logger.Info("Generating script with GPT-4o", zap.String("job_id", job.JobID))
-	job.Stage = "script_generating"
-	if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-		h.logger.Error("Failed to update job stage",
-			zap.String("job_id", job.JobID),
-			zap.String("stage", "script_generating"),
+	script, ok := h.generateScriptStep(jobCtx, job, req, brand)
+	if !ok || h.stopIfCanceled(jobCtx, job) {
+		return
+	}
+
+	// Preview mode: stop after the script and wait for the user to approve it
+	if req.Preview {
+		h.holdForApproval(jobCtx, job)
+		return
+	}
+
+	h.generateFromScript(jobCtx, job, script)
+}
+
+// resumeApprovedJob runs the remaining pipeline for a previewed job using its stored script
+func (h *GenerateHandler) resumeApprovedJob(ctx context.Context, job *domain.Job) {
+	jobCtx, cancel := context.WithTimeout(h.withTokenBudget(ctx, job), VideoGenerationTimeout)
+	defer cancel()
+
+	h.log(ctx).Info("Resuming approved job",
+		zap.Int("num_scenes", len(job.Scenes)),
+	)
+	h.recordStage(ctx, job, "Generation started from the stored script", 0)
+
+	if h.stopIfCanceled(jobCtx, job) {
+		return
+	}
+	h.generateFromScript(jobCtx, job, scriptFromJob(job))
+}
+
+// holdForApproval parks a previewed job in script_ready until it is approved or expires
+func (h *GenerateHandler) holdForApproval(ctx context.Context, job *domain.Job) {
+	if !h.enterStage(ctx, job, domain.StageScriptReady) {
+		return
+	}
+	job.Status = domain.StatusScriptReady
+	job.UpdatedAt = time.Now().Unix()
+	// Unapproved previews expire sooner than full jobs; DynamoDB TTL removes them
+	job.TTL = time.Now().Add(PreviewApprovalWindow).Unix()
+
+	if err := h.saveJobProgress(ctx, job); err != nil {
+		h.log(ctx).Error("Failed to store script for approval",
 			zap.Error(err),
 		)
+		return
+	}
+
+	h.recordStage(ctx, job, "Script ready for approval", 0)
+	h.log(ctx).Info("Script ready for approval",
+		zap.Int("num_scenes", len(job.Scenes)),
+		zap.Int64("expires_at", job.TTL),
+	)
+}
+
+// scriptFromJob rebuilds the script embedded in a job record
+func scriptFromJob(job *domain.Job) *domain.Script {
+	var totalDuration float64
+	for _, scene := range job.Scenes {
+		totalDuration += scene.Duration
+	}
+
+	return &domain.Script{
+		UserID:        job.UserID,
+		Title:         job.Title,
+		TotalDuration: int(totalDuration),
+		Scenes:        job.Scenes,
+		AudioSpec:     job.AudioSpec,
+		Metadata:      job.ScriptMetadata,
+	}
+}
+
+// warnRepeatedScenes records a warning for each pair of adjacent scenes in script that still
+// describe nearly the same shot after the parser's retry. The job goes on with them.
+func (h *GenerateHandler) warnRepeatedScenes(ctx context.Context, job *domain.Job, script *domain.Script) {
+	for _, pair := range h.parserService.RepeatedScenes(script) {
+		h.recordWarning(ctx, job, fmt.Sprintf(
+			"Scenes %d and %d are nearly identical (%.0f%% similar) and may look like a stutter; regenerate one of them to vary it",
+			pair.First+1, pair.First+2, pair.Similarity*100))
 	}
+}
+
+// generateScriptStep generates the script with GPT-4o and embeds it in the job.
+// Returns false if the job was failed.
+func (h *GenerateHandler) generateScriptStep(jobCtx context.Context, job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) (*domain.Script, bool) {
+	state := &jobState{job: job, req: req, brand: brand}
+	if !h.pipeline(nil).writeScript(jobCtx, state) {
+		return nil, false
+	}
+	return state.script, true
+}
+
+// writeScript generates a job's script
+func (h *GenerateHandler) writeScript(ctx context.Context, job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) (*domain.Script, error) {
+	start := time.Now()
+	scriptCtx, scriptDone := h.trackStep(h.withProvenance(ctx, job, metricStageScript), job, domain.StepScript)
+	script, err := h.parserService.GenerateScript(scriptCtx, h.scriptParseRequest(job, req, brand))
+	timeStage(ctx, job, metricStageScript, start)
+	if err != nil {
+		return nil, err
+	}
+	scriptDone(0)
+	return script, nil
+}
 
-	script, err := h.parserService.GenerateScript(jobCtx, service.ParseRequest{
+// scriptLanguage is the language a job's script and narration are written in
+func (h *GenerateHandler) scriptLanguage(job *domain.Job) prompts.Language {
+	return prompts.Language{
+		Tag:            job.Language,
+		Name:           domain.LanguageName(job.Language),
+		WordsPerSecond: h.audioConfig.NarrationPacing.WordsPerSecond(job.Language),
+	}
+}
+
+// scriptParseRequest builds the script writer's input for a job
+func (h *GenerateHandler) scriptParseRequest(job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) service.ParseRequest {
+	return service.ParseRequest{
 		UserID:      job.UserID,
 		Prompt:      req.Prompt,
 		Duration:    req.Duration,
 		AspectRatio: req.AspectRatio,
 		StartImage:  req.StartImage,
+		VideoModel:  job.Model,
 
 		// Style reference image - will be analyzed and converted to text
 		StyleReferenceImage: req.StyleReferenceImage,
@@ -274,21 +476,24 @@ func (h *GenerateHandler) generateVideoAsync(ctx context.Context, job *domain.Jo
 		CallToAction:      req.CallToAction,
 		ProCinematography: req.ProCinematography,
 		CreativeBoost:     req.CreativeBoost,
-	})
-	if err != nil {
-		h.logger.Error("Script generation failed with error",
-			zap.String("job_id", job.JobID),
-			zap.String("stage", "script_generating"),
-			zap.Error(err),
-			zap.String("error_type", fmt.Sprintf("%T", err)),
-			zap.String("error_string", err.Error()),
-		)
-		h.failJob(jobCtx, job, scriptFailureMessage, err, zap.String("stage", "script_generating"))
-		return
+
+		BrandGuidelines: brand,
+		ProductImages:   req.ProductImages,
+		ScenePlan:       req.ScenePlan,
+
+		Language:                job.Language,
+		NarrationWordsPerSecond: h.audioConfig.NarrationPacing.WordsPerSecond(job.Language),
 	}
+}
 
-	// Embed script in job record
-	job.Title = script.Title
+// embedScript stores a generated script on its job
+func (h *GenerateHandler) embedScript(ctx context.Context, job *domain.Job, script *domain.Script) {
+	if !job.TitleEdited {
+		job.Title = script.Title
+		if job.Title == "" {
+			job.Title = fallbackTitle(job.Prompt)
+		}
+	}
 	job.Scenes = script.Scenes
 	job.AudioSpec = script.AudioSpec
 	job.ScriptMetadata = script.Metadata
@@ -299,649 +504,403 @@ func (h *GenerateHandler) generateVideoAsync(ctx context.Context, job *domain.Jo
 	if job.SideEffectsText != "" {
 		// Default to 80% of duration for side effects start time
 		job.SideEffectsStartTime = float64(job.Duration) * 0.8
-		h.logger.Info("Using user-provided side effects text for FDA compliance",
-			zap.String("job_id", job.JobID),
+		h.log(ctx).Info("Using user-provided side effects text for FDA compliance",
 			zap.Int("text_length", len(job.SideEffectsText)),
 			zap.Float64("side_effects_start_time", job.SideEffectsStartTime),
 		)
 	}
+}
 
-	// Update job with embedded script
-	job.Stage = "script_complete"
-	if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-		h.logger.Error("Failed to update job stage",
-			zap.String("job_id", job.JobID),
-			zap.String("stage", "script_complete"),
-			zap.Error(err),
-		)
-	}
-
-	h.logger.Info("Script generated and embedded in job",
-		zap.String("job_id", job.JobID),
-		zap.String("title", script.Title),
-		zap.Int("num_scenes", len(script.Scenes)),
-		zap.String("audio_mood", script.AudioSpec.MusicMood),
-		zap.String("audio_style", script.AudioSpec.MusicStyle),
-	)
-
-	// Log each scene for visibility
-	for i, scene := range script.Scenes {
-		h.logger.Info("Scene details",
-			zap.String("job_id", job.JobID),
-			zap.Int("scene_number", i+1),
-			zap.Float64("start_time", scene.StartTime),
-			zap.Float64("duration", scene.Duration),
-			zap.String("shot_type", string(scene.ShotType)),
-			zap.String("camera_angle", string(scene.CameraAngle)),
-			zap.String("lighting", string(scene.Lighting)),
-			zap.String("color_grade", string(scene.ColorGrade)),
-			zap.String("mood", string(scene.Mood)),
-			zap.String("generation_prompt", scene.GenerationPrompt),
-		)
+// generateFromScript runs the clip, audio and composition steps for a job whose script is ready
+func (h *GenerateHandler) generateFromScript(jobCtx context.Context, job *domain.Job, script *domain.Script) {
+	if !h.moderateScenes(jobCtx, job, script.Scenes) {
+		return
 	}
 
-	// STEP 2: Generate video clips sequentially (must be first to get actual duration)
-	var clipVideos []ClipVideo
-	// Start with empty lastFrameURL so the first scene is pure AI generation
-	var lastFrameURL string
-
-	// Initialize arrays for accumulating scene data
-	sceneVideoURLs := make([]string, 0, len(script.Scenes))
-	var jobThumbnailURL string // Raw S3 URL for the job thumbnail (not presigned)
-
-	for i, scene := range script.Scenes {
-		job.Stage = fmt.Sprintf("scene_%d_generating", i+1)
-		job.ScenesCompleted = i // Number completed so far (i is 0-indexed)
-		if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-			h.logger.Error("Failed to update job stage",
-				zap.String("job_id", job.JobID),
-				zap.String("stage", job.Stage),
-				zap.Error(err),
-			)
-		}
-
-		h.logger.Info("Generating scene",
-			zap.String("job_id", job.JobID),
-			zap.Int("scene", i+1),
-			zap.Int("total", len(script.Scenes)),
-		)
-
-		// Image selection logic for pharmaceutical ads:
-		// 1. Scenes 1..N-1 use the previous clip's last frame for continuity.
-		// 2. Last scene (N) uses the product image provided by the user.
-		if i == len(script.Scenes)-1 && strings.TrimSpace(req.StartImage) != "" {
-			// Extract S3 key from the product image URL
-			s3Key := extractS3Key(req.StartImage)
-
-			// Generate presigned URL for video API access (valid for 1 hour)
-			presignedURL, err := h.s3Service.GetPresignedURL(jobCtx, s3Key, 1*time.Hour)
-			if err != nil {
-				h.logger.Error("Failed to generate presigned URL for product image",
-					zap.String("job_id", job.JobID),
-					zap.String("s3_key", s3Key),
-					zap.String("original_url", req.StartImage),
-					zap.Error(err),
-				)
-				// Fall back to direct URL if presigning fails (shouldn't happen, but be safe)
-				scene.StartImageURL = req.StartImage
-			} else {
-				scene.StartImageURL = presignedURL
-				h.logger.Info("Using product image for last scene (side effects segment)",
-					zap.String("job_id", job.JobID),
-					zap.Int("scene", i+1),
-					zap.String("product_image_url", presignedURL),
-				)
-			}
-		} else {
-			scene.StartImageURL = lastFrameURL
-			if lastFrameURL != "" {
-				h.logger.Info("Using last frame for visual continuity",
-					zap.String("job_id", job.JobID),
-					zap.Int("scene", i+1),
-				)
-			} else if i != len(script.Scenes)-1 {
-				h.logger.Info("No continuity frame available, generating scene without start image",
-					zap.String("job_id", job.JobID),
-					zap.Int("scene", i+1),
-				)
-			} else {
-				h.logger.Warn("Product image missing for last scene; falling back to continuity frame",
-					zap.String("job_id", job.JobID),
-					zap.Int("scene", i+1),
-				)
-			}
-		}
-
-		// Call Veo API (synchronous polling in this goroutine)
-		clipResult, err := h.generateClip(jobCtx, job.UserID, job.JobID, scene, req.AspectRatio, i+1)
-		if err != nil {
-			h.failJob(jobCtx, job, fmt.Sprintf(sceneFailureMessageFormat, i+1), err,
-				zap.String("stage", fmt.Sprintf("scene_%d_generating", i+1)),
-				zap.Int("scene", i+1),
-			)
-			return
-		}
-
-		clipVideos = append(clipVideos, clipResult)
-		lastFrameURL = clipResult.LastFrameURL
-
-		// Accumulate scene data
-		sceneVideoURLs = append(sceneVideoURLs, clipResult.VideoURL)
+	videoAdapter := videoAdapterForJob(h.adapterFactory, h.log(jobCtx), job)
+	h.pipeline(videoAdapter).generate(jobCtx, &jobState{job: job, script: script})
+}
 
-		// Extract and upload job thumbnail from first scene only
-		// Note: clipResult.LastFrameURL is presigned (for Veo API continuity) and should NOT be stored in DB
-		if i == 0 {
-			jobThumbnail, err := h.extractJobThumbnail(jobCtx, job.UserID, job.JobID, clipResult.VideoURL)
-			if err != nil {
-				h.logger.Warn("Failed to extract job thumbnail, continuing without it",
-					zap.String("job_id", job.JobID),
-					zap.Error(err),
-				)
-				// Continue - this is not critical
-			} else {
-				// Store raw S3 URL (not presigned) - will be presigned when served via API
-				jobThumbnailURL = jobThumbnail
-				h.logger.Info("Job thumbnail set from first scene",
-					zap.String("job_id", job.JobID),
-					zap.String("thumbnail_url", jobThumbnail),
-				)
-			}
-		}
+// checkpointStage stores the stage a job just entered
+func (h *GenerateHandler) checkpointStage(ctx context.Context, job *domain.Job) error {
+	return h.jobRepo.UpdateJobStage(ctx, job)
+}
 
-		// Initialize versioning for this scene (version 1)
-		if job.SceneVersions == nil {
-			job.SceneVersions = make(map[int]int)
-		}
-		if job.ClipVersions == nil {
-			job.ClipVersions = make(map[string]string)
-		}
-		sceneNum := i + 1
-		job.SceneVersions[sceneNum] = 1
-		job.ClipVersions[fmt.Sprintf("scene-%d-v1", sceneNum)] = clipResult.VideoURL
-
-		// Update job with accumulated data
-		job.Stage = fmt.Sprintf("scene_%d_complete", i+1)
-		job.ScenesCompleted = i + 1
-		job.SceneVideoURLs = sceneVideoURLs
-		job.ThumbnailURL = jobThumbnailURL
-
-		if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-			h.logger.Error("Failed to update job progress",
-				zap.String("job_id", job.JobID),
-				zap.Int("scenes_completed", job.ScenesCompleted),
-				zap.Error(err),
-			)
-		}
+// generateScene generates a scene's clip with the job's video adapter
+func (r sceneRenderer) generateScene(ctx context.Context, job *domain.Job, scene domain.Scene, sceneNumber int) (composition.ClipVideo, error) {
+	start := time.Now()
+	clip, err := r.generateClip(r.withProvenance(ctx, job, domain.SceneStep(sceneNumber)), r.adapter, job, scene, job.AspectRatio, sceneNumber)
+	timeStage(ctx, job, metricStageScene, start)
+	return clip, err
+}
 
-		h.logger.Info("Scene completed and job updated",
-			zap.String("job_id", job.JobID),
-			zap.Int("scene_number", i+1),
-			zap.Int("total_scenes", len(script.Scenes)),
-			zap.Int("scenes_completed", job.ScenesCompleted),
-		)
+// narratorOutput is what narrating a job produced. The narrator runs alongside the music, so
+// it only reads the job; apply sets its outputs on the job from the pipeline's goroutine.
+type narratorOutput struct {
+	URL  string        // Raw S3 URL of the narrator track
+	Fit  *narrationFit // How the track was fitted to the video
+	Cues []domain.CaptionCue
+	// CaptionsKey is the uploaded WebVTT's key, empty if the upload failed
+	CaptionsKey string
+
+	// Two-pass narration only
+	DisclaimerSpec       *domain.DisclaimerSpec
+	Budget               float64 // Seconds the narration was written for
+	Words                int     // Words the narration was written for
+	SideEffectsStartTime *float64
+
+	// ComplianceIssues are the narration's compliance check findings, set even when they fail it
+	ComplianceIssues []domain.ComplianceIssue
+}
 
-		h.logger.Info("Scene completed",
-			zap.String("job_id", job.JobID),
-			zap.Int("scene", i+1),
-			zap.String("video_url", clipResult.VideoURL),
-		)
+// apply sets the narration's outputs on job
+func (n *narratorOutput) apply(job *domain.Job) {
+	job.ComplianceIssues = append(job.ComplianceIssues, n.ComplianceIssues...)
+	if n.DisclaimerSpec != nil {
+		job.DisclaimerSpec = n.DisclaimerSpec
+		job.NarrationBudget = n.Budget
+		job.NarrationWords = n.Words
 	}
-
-	// STEP 3: Calculate actual video duration from generated clips
-	var actualVideoDuration float64
-	for _, clip := range clipVideos {
-		actualVideoDuration += clip.Duration
+	recordNarrationFit(job, n.Fit)
+	if n.SideEffectsStartTime != nil {
+		job.SideEffectsStartTime = *n.SideEffectsStartTime
 	}
-
-	h.logger.Info("Video clips generation complete",
-		zap.String("job_id", job.JobID),
-		zap.Int("num_clips", len(clipVideos)),
-		zap.Float64("actual_video_duration", actualVideoDuration),
-		zap.Int("requested_duration", job.Duration),
-	)
-
-	// Update side effects start time based on actual video duration
-	if job.SideEffectsText != "" {
-		job.SideEffectsStartTime = actualVideoDuration * 0.8
-		h.logger.Info("Updated side effects start time for actual video duration",
-			zap.String("job_id", job.JobID),
-			zap.Float64("side_effects_start_time", job.SideEffectsStartTime),
-		)
+	if len(n.Cues) > 0 {
+		job.Captions = n.Cues
 	}
+	if n.CaptionsKey != "" {
+		job.CaptionsKey = n.CaptionsKey
+	}
+	if n.URL != "" {
+		job.NarratorAudioURL = n.URL
+	}
+}
 
-	// STEP 4: Generate narrator voiceover AND background audio in parallel
-	// Both use the actual video duration for proper timing
-	type audioResult struct {
-		url string
-		err error
-	}
-
-	narratorChan := make(chan audioResult, 1)
-	musicChan := make(chan audioResult, 1)
-
-	// Start narrator voiceover generation (if configured)
-	// Use two-pass system for pharmaceutical ads with side effects
-	isPharmaceuticalAd := job.Voice != "" && job.SideEffectsText != ""
-	needsNarrator := job.Voice != "" && (job.AudioSpec.NarratorScript != "" || isPharmaceuticalAd)
-	if needsNarrator {
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					h.logger.Error("Panic in narrator generation",
-						zap.String("job_id", job.JobID),
-						zap.Any("panic", r),
-					)
-					narratorChan <- audioResult{err: fmt.Errorf("narrator generation panic: %v", r)}
-				}
-			}()
-
-			h.logger.Info("Generating narrator voiceover (parallel with music)",
-				zap.String("job_id", job.JobID),
-				zap.Float64("target_duration", actualVideoDuration),
-				zap.Bool("two_pass", isPharmaceuticalAd),
-			)
-
-			var narratorURL string
-			var err error
-
-			if isPharmaceuticalAd {
-				// Use two-pass system for pharmaceutical ads
-				narratorURL, err = h.generateNarratorVoiceoverTwoPass(
-					jobCtx,
-					job,
-					script,
-					actualVideoDuration,
-				)
-			} else {
-				// Use legacy single-pass for non-pharmaceutical ads
-				narratorURL, err = h.generateNarratorVoiceover(
-					jobCtx,
-					job.UserID,
-					job.JobID,
-					job.Voice,
-					job.AudioSpec.NarratorScript,
-					job.SideEffectsStartTime,
-					int(actualVideoDuration),
-				)
-			}
-			narratorChan <- audioResult{url: narratorURL, err: err}
-		}()
+// narrate generates a job's narrator voiceover fitted to videoDuration, using the two-pass
+// system for pharmaceutical ads with side effects. The output may be non-nil on error, holding
+// the compliance issues that failed it.
+func (h *GenerateHandler) narrate(ctx context.Context, job *domain.Job, script *domain.Script, videoDuration float64) (*narratorOutput, error) {
+	defer timeStage(ctx, job, metricStageNarrator, time.Now())
+
+	narratorCtx, narratorDone := h.trackStep(h.withProvenance(ctx, job, metricStageNarrator), job, domain.StepNarrator)
+	var out *narratorOutput
+	var err error
+	if isTwoPassNarration(job) {
+		out, err = h.generateNarratorVoiceoverTwoPass(narratorCtx, job, script, videoDuration)
 	} else {
-		// No narrator needed - send empty result
-		if job.Voice == "" {
-			h.logger.Info("Skipping narrator voiceover (voice not configured)", zap.String("job_id", job.JobID))
-		} else {
-			h.logger.Warn("Skipping narrator voiceover (narrator script missing)", zap.String("job_id", job.JobID))
+		// Use legacy single-pass for non-pharmaceutical ads
+		out = &narratorOutput{}
+		out.URL, out.Fit, err = h.generateNarratorVoiceover(
+			narratorCtx,
+			job,
+			job.Voice,
+			job.TTSProvider,
+			job.AudioSpec.NarratorScript,
+			job.SideEffectsStartTime,
+			videoDuration,
+		)
+		if err == nil {
+			out.Cues, out.CaptionsKey = h.publishCaptions(ctx, job, []captionSegment{{Text: out.Fit.Text, Speed: 1.0}}, out.Fit.Duration)
 		}
-		narratorChan <- audioResult{url: "", err: nil}
 	}
-
-	// Start background music generation
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				h.logger.Error("Panic in music generation",
-					zap.String("job_id", job.JobID),
-					zap.Any("panic", r),
-				)
-				musicChan <- audioResult{err: fmt.Errorf("music generation panic: %v", r)}
-			}
-		}()
-
-		h.logger.Info("Generating background music (parallel with narrator)",
-			zap.String("job_id", job.JobID),
-			zap.Float64("target_duration", actualVideoDuration),
-		)
-
-		audioURL, err := h.generateAudio(jobCtx, job.UserID, job.JobID, script)
-		musicChan <- audioResult{url: audioURL, err: err}
-	}()
-
-	// Update job stage
-	job.Stage = "audio_generating"
-	if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-		h.logger.Error("Failed to update job stage",
-			zap.String("job_id", job.JobID),
-			zap.String("stage", "audio_generating"),
-			zap.Error(err),
-		)
+	if err == nil {
+		narratorDone(0)
 	}
+	return out, err
+}
 
-	// Wait for both audio tracks to complete
-	h.logger.Info("Waiting for audio generation to complete", zap.String("job_id", job.JobID))
+// generateMusic generates a job's background music fitted to videoDuration
+func (h *GenerateHandler) generateMusic(ctx context.Context, job *domain.Job, script *domain.Script, videoDuration float64) (*musicTrack, error) {
+	defer timeStage(ctx, job, metricStageAudio, time.Now())
+	return h.generateAudio(h.withProvenance(ctx, job, domain.StepMusic), job, script, videoDuration)
+}
 
-	narratorRes := <-narratorChan
-	if narratorRes.err != nil {
-		h.failJob(jobCtx, job, narratorFailureMessage, narratorRes.err, zap.String("stage", "narrator_generating"))
-		return
-	}
-	if narratorRes.url != "" {
-		job.NarratorAudioURL = narratorRes.url
-		h.logger.Info("Narrator voiceover complete",
-			zap.String("job_id", job.JobID),
-			zap.String("narrator_url", narratorRes.url),
-		)
-	}
+// generateSoundEffects generates a job's sound effects at points
+func (h *GenerateHandler) generateSoundEffects(ctx context.Context, job *domain.Job, points []domain.SyncPoint) []domain.SFXClip {
+	return h.generateSFX(h.withProvenance(ctx, job, "sfx"), job, points)
+}
 
-	musicRes := <-musicChan
-	if musicRes.err != nil {
-		h.failJob(jobCtx, job, audioFailureMessage, musicRes.err, zap.String("stage", "audio_generating"))
-		return
+// composeFinal composes a job's final video, returning its MP4 and WebM keys
+func (h *GenerateHandler) composeFinal(ctx context.Context, job *domain.Job, clips []composition.ClipVideo) (string, string, error) {
+	start := time.Now()
+	composeCtx, composeDone := h.trackStep(ctx, job, domain.StepComposition)
+	mp4Key, webmKey, err := h.composeVideo(composeCtx, job, clips)
+	timeStage(ctx, job, metricStageComposition, start)
+	if err != nil {
+		return "", "", err
 	}
-	job.AudioURL = musicRes.url
-	h.logger.Info("Background music complete",
-		zap.String("job_id", job.JobID),
-		zap.String("audio_url", musicRes.url),
-	)
+	composeDone(0)
+	return mp4Key, webmKey, nil
+}
 
-	job.Stage = "audio_complete"
-	if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-		h.logger.Error("Failed to update job with audio URLs",
-			zap.String("job_id", job.JobID),
-			zap.Error(err),
-		)
-	}
+// publishScrubPreview publishes the hover-scrub preview of a job's video at videoKey
+func (h *GenerateHandler) publishScrubPreview(ctx context.Context, job *domain.Job, videoKey string) {
+	publishScrubSprite(ctx, h.s3Service, jobAssetBucket(job, h.assetsBucket), h.log(ctx), job, videoKey)
+}
 
-	// STEP 5: Compose final video
-	job.Stage = "composing"
-	if err := h.jobRepo.UpdateJob(jobCtx, job); err != nil {
-		h.logger.Error("Failed to update job stage",
-			zap.String("job_id", job.JobID),
-			zap.String("stage", "composing"),
+// completeJob marks a composed job complete with both MP4 and WebM keys, recording the final
+// uploads and how the video was encoded first
+func (h *GenerateHandler) completeJob(ctx context.Context, job *domain.Job, mp4Key, webmKey string, composeStart time.Time) {
+	if err := h.saveJobProgress(ctx, job); err != nil {
+		h.log(ctx).Error("Failed to record composition output",
 			zap.Error(err),
 		)
 	}
-	h.logger.Info("Composing final video (video track only)", zap.String("job_id", job.JobID))
-
-	mp4Key, webmKey, err := h.composeVideo(
-		jobCtx,
-		job,
-		clipVideos,
-	)
-	if err != nil {
-		h.failJob(jobCtx, job, compositionFailureMessage, err, zap.String("stage", "composing"))
+	err := h.jobRepo.MarkJobComplete(ctx, job.JobID, mp4Key, webmKey)
+	if errors.Is(err, repository.ErrJobNotProcessing) {
+		// Canceled, failed or rerun while this run composed; its newer status stands
+		h.log(ctx).Warn("Job left processing during composition, not marking it complete")
 		return
 	}
-
-	// STEP 6: Mark job complete (with both MP4 and WebM keys)
-	err = h.jobRepo.MarkJobComplete(jobCtx, job.JobID, mp4Key, webmKey)
 	if err != nil {
-		h.logger.Error("Failed to mark job complete", zap.String("job_id", job.JobID), zap.Error(err))
+		h.log(ctx).Error("Failed to mark job complete", zap.Error(err))
 		return
 	}
+	recordJobOutcome(h.metrics, job, outcomeCompleted, "")
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:       domain.JobEventCompleted,
+		Stage:      job.Stage.String(),
+		Message:    "Video ready",
+		DurationMs: time.Since(composeStart).Milliseconds(),
+	})
+	h.notifyJobFinished(job.JobID)
 
-	h.logger.Info("Video generation complete",
-		zap.String("job_id", job.JobID),
+	h.log(ctx).Info("Video generation complete",
 		zap.String("mp4_key", mp4Key),
 		zap.String("webm_key", webmKey),
 	)
 }
 
-// generateClip generates a single video clip using Veo 3.1
+// videoAdapterForJob returns the video adapter for the model recorded on the job.
+// Jobs created before per-job model selection (or with an unknown model) use the default adapter.
+func videoAdapterForJob(factory *adapters.AdapterFactory, logger *zap.Logger, job *domain.Job) adapters.VideoGeneratorAdapter {
+	adapterType, err := adapters.ParseAdapterType(job.Model)
+	if err == nil {
+		if adapter, err := factory.CreateAdapter(adapterType); err == nil {
+			return adapter
+		}
+	}
+
+	logger.Warn("Unknown video model on job, using default adapter",
+		zap.String("model", job.Model),
+	)
+	return factory.GetDefaultAdapter()
+}
+
+// sceneVideoRequest builds the video request for scene. A scene without a pinned seed gets a
+// random one, returned so it can be recorded and reused to regenerate the clip.
+func sceneVideoRequest(scene domain.Scene, aspectRatio string) (*adapters.VideoGenerationRequest, int64) {
+	seed := adapters.NewSeed()
+	if scene.Seed != nil {
+		seed = *scene.Seed
+	}
+	return &adapters.VideoGenerationRequest{
+		Prompt:         scene.GenerationPrompt,
+		Duration:       int(scene.Duration),
+		AspectRatio:    aspectRatio,
+		StartImageURL:  scene.StartImageURL,
+		NegativePrompt: scene.NegativePrompt,
+		Seed:           &seed,
+		Params:         scene.GenerationParams,
+	}, seed
+}
+
+// generateClip generates a single video clip using the job's video model. Under
+// ClipAspectRegenerate a clip of the wrong shape is requested once more with a new seed, and the
+// retry is normalized if it is no better; a scene with a pinned seed would get the same clip
+// back, so its clip is normalized straight away. A black or frozen clip is requested once more
+// with a new seed whatever the scene's, as it can't be kept; a retry that is no better fails
+// the clip with its *composition.ClipQualityError.
 func (h *GenerateHandler) generateClip(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	videoAdapter adapters.VideoGeneratorAdapter,
+	job *domain.Job,
 	scene domain.Scene,
 	aspectRatio string,
 	clipNumber int,
-) (ClipVideo, error) {
-	h.logger.Info("Calling Veo adapter",
-		zap.String("job_id", jobID),
+) (composition.ClipVideo, error) {
+	h.log(ctx).Info("Calling video adapter",
 		zap.Int("scene", scene.SceneNumber),
+		zap.String("model", videoAdapter.GetModelName()),
 		zap.String("prompt", scene.GenerationPrompt),
 	)
 
-	// Call Veo adapter
-	req := &adapters.VideoGenerationRequest{
-		Prompt:        scene.GenerationPrompt,
-		Duration:      int(scene.Duration),
-		AspectRatio:   aspectRatio,
-		StartImageURL: scene.StartImageURL,
-	}
+	req, seed := sceneVideoRequest(scene, aspectRatio)
+	ctx = adapters.WithProvenanceSeed(ctx, seed)
+	ctx, clipDone := h.trackStep(ctx, job, domain.SceneStep(clipNumber))
 
-	result, err := h.veoAdapter.GenerateVideo(ctx, req)
+	videoURL, err := h.requestClip(ctx, videoAdapter, scene, req)
 	if err != nil {
-		return ClipVideo{}, fmt.Errorf("veo API failed: %w", err)
+		return composition.ClipVideo{}, err
+	}
+
+	// Download video, extract last frame, upload to S3
+	policy := h.clipAspectPolicy
+	if scene.Seed != nil {
+		policy = composition.ClipAspectNormalize
+	}
+	clipURL, lastFrameURL, err := h.processVideo(ctx, job, clipNumber, videoURL, scene.Duration, policy)
+	var mismatch *composition.ClipAspectError
+	var rejected *composition.ClipQualityError
+	switch {
+	case errors.As(err, &mismatch):
+		h.log(ctx).Warn("Requesting clip again for its aspect ratio",
+			zap.Int("scene", scene.SceneNumber),
+			zap.Int("width", mismatch.Width),
+			zap.Int("height", mismatch.Height),
+			zap.String("aspect_ratio", mismatch.AspectRatio),
+		)
+	case errors.As(err, &rejected):
+		h.log(ctx).Warn("Requesting clip again after it failed its quality check",
+			zap.Int("scene", scene.SceneNumber),
+			zap.String("reason", rejected.Reason),
+			zap.String("detail", rejected.Detail),
+		)
 	}
-
-	// Poll until complete (max 10 minutes)
-	maxAttempts := VideoGenerationMaxAttempts // 120 × 5s = 10 minutes
-	pollInterval := PollInterval
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		select {
-		case <-ctx.Done():
-			return ClipVideo{}, ctx.Err()
-		default:
-		}
-
-		if attempt > 0 {
-			time.Sleep(pollInterval)
-			result, err = h.veoAdapter.GetStatus(ctx, result.PredictionID)
-			if err != nil {
-				h.logger.Warn("Veo polling failed, retrying", zap.Error(err))
-				continue
-			}
+	if mismatch != nil || rejected != nil {
+		retry := *req
+		seed = adapters.NewSeed()
+		retry.Seed = &seed
+		ctx = adapters.WithProvenanceSeed(ctx, seed)
+		if videoURL, err = h.requestClip(ctx, videoAdapter, scene, &retry); err != nil {
+			return composition.ClipVideo{}, err
 		}
+		clipURL, lastFrameURL, err = h.processVideo(ctx, job, clipNumber, videoURL, scene.Duration, composition.ClipAspectNormalize)
+	}
+	if err != nil {
+		return composition.ClipVideo{}, fmt.Errorf("video processing failed: %w", err)
+	}
+	clipDone(sceneCredits(job, scene))
 
-		if result.Status == "succeeded" || result.Status == "completed" {
-			// Download video, extract last frame, upload to S3
-			clipURL, lastFrameURL, err := h.processVideo(ctx, userID, jobID, clipNumber, result.VideoURL)
-			if err != nil {
-				return ClipVideo{}, fmt.Errorf("video processing failed: %w", err)
-			}
+	return composition.ClipVideo{
+		VideoURL:     clipURL,
+		LastFrameURL: lastFrameURL,
+		Duration:     scene.Duration,
+	}, nil
+}
 
-			return ClipVideo{
-				VideoURL:     clipURL,
-				LastFrameURL: lastFrameURL,
-				Duration:     scene.Duration,
-			}, nil
-		}
+// requestClip submits req to the video model and waits for the clip, returning its URL
+func (h *GenerateHandler) requestClip(
+	ctx context.Context,
+	videoAdapter adapters.VideoGeneratorAdapter,
+	scene domain.Scene,
+	req *adapters.VideoGenerationRequest,
+) (string, error) {
+	result, err := videoAdapter.GenerateVideo(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("%s API failed: %w", videoAdapter.GetModelName(), err)
+	}
 
-		if result.Status == "failed" || result.Status == "canceled" {
-			// Provide better error message if error is empty
-			errorMsg := result.Error
-			if errorMsg == "" {
-				errorMsg = "Unknown error - Veo returned failed status without error details"
-			}
-			h.logger.Error("Veo generation failed",
-				zap.String("job_id", jobID),
+	if result.VideoURL == "" {
+		predictionID := result.PredictionID
+		result, err = adapters.PollUntilComplete(ctx, videoAdapter, predictionID,
+			videoPollOptions(h.log(ctx), videoAdapter.GetModelName()))
+		if err != nil {
+			h.log(ctx).Error("Video generation failed",
 				zap.Int("scene", scene.SceneNumber),
-				zap.String("prediction_id", result.PredictionID),
-				zap.String("error", errorMsg),
-			)
-			return ClipVideo{}, fmt.Errorf("veo generation failed: %s", errorMsg)
-		}
-
-		// Log only every 12th attempt (every minute instead of every 5 seconds)
-		if attempt%12 == 0 {
-			h.logger.Info("Veo still processing",
-				zap.String("job_id", jobID),
-				zap.Int("attempt", attempt),
-				zap.Int("max_attempts", maxAttempts),
-				zap.String("status", result.Status),
-				zap.String("prediction_id", result.PredictionID),
-			)
-		}
-
-		// Log every 60th attempt (every 5 minutes) with more detail
-		if attempt > 0 && attempt%60 == 0 {
-			h.logger.Warn("Veo generation taking longer than expected",
-				zap.String("job_id", jobID),
-				zap.Int("attempt", attempt),
-				zap.Int("max_attempts", maxAttempts),
-				zap.String("status", result.Status),
-				zap.String("prediction_id", result.PredictionID),
-				zap.String("error", result.Error),
+				zap.String("prediction_id", predictionID),
+				zap.Error(err),
 			)
+			return "", fmt.Errorf("%s generation failed: %w", videoAdapter.GetModelName(), err)
 		}
 	}
-
-	return ClipVideo{}, fmt.Errorf("clip generation timed out after %d attempts", maxAttempts)
+	return result.VideoURL, nil
 }
 
-// processVideo downloads video from Replicate, extracts last frame, uploads both to S3
+// processVideo downloads video from Replicate, verifies it, extracts last frame, uploads both to
+// S3. aspectPolicy says what happens to a clip of the wrong shape.
 func (h *GenerateHandler) processVideo(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	job *domain.Job,
 	clipNumber int,
 	videoURL string,
+	expectedDuration float64,
+	aspectPolicy composition.ClipAspectPolicy,
 ) (string, string, error) {
-	// Create temp directory
-	tmpDir := filepath.Join("/tmp", jobID, fmt.Sprintf("clip-%d", clipNumber))
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create temp dir: %w", err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	// Download video from Replicate URL
-	h.logger.Info("Downloading video from Replicate",
-		zap.String("job_id", jobID),
-		zap.Int("clip", clipNumber),
-		zap.String("url", videoURL),
-	)
-	videoPath := filepath.Join(tmpDir, "video.mp4")
-	if err := h.downloadFile(ctx, videoURL, videoPath); err != nil {
-		return "", "", fmt.Errorf("failed to download video: %w", err)
-	}
-
-	// Extract last frame using ffmpeg
-	h.logger.Info("Extracting last frame with ffmpeg",
-		zap.String("job_id", jobID),
-		zap.Int("clip", clipNumber),
-	)
-	lastFramePath := filepath.Join(tmpDir, "last_frame.jpg")
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-sseof", "-1",
-		"-i", videoPath,
-		"-update", "1",
-		"-q:v", "2",
-		"-y", lastFramePath,
-	)
-	if err := cmd.Run(); err != nil {
-		h.logger.Warn("Failed to extract last frame, continuing without it",
-			zap.String("job_id", jobID),
-			zap.Int("clip", clipNumber),
-			zap.Error(err),
-		)
-		lastFramePath = "" // Continue without last frame
-	} else {
-		h.logger.Info("Last frame extracted successfully",
-			zap.String("job_id", jobID),
-			zap.Int("clip", clipNumber),
-		)
-	}
-
-	// Upload video to S3
-	h.logger.Info("Uploading video to S3",
-		zap.String("job_id", jobID),
-		zap.Int("clip", clipNumber),
-	)
-	videoS3Key := buildSceneClipKey(userID, jobID, clipNumber)
-	videoS3URL, err := h.s3Service.UploadFile(ctx, h.assetsBucket, videoS3Key, videoPath, "video/mp4")
-	if err != nil {
-		return "", "", fmt.Errorf("failed to upload video to S3: %w", err)
-	}
-
-	// Upload last frame to S3 (if extracted)
-	var lastFrameS3URL string
-	if lastFramePath != "" {
-		lastFrameS3Key := buildSceneThumbnailKey(userID, jobID, clipNumber)
-		_, err = h.s3Service.UploadFile(ctx, h.assetsBucket, lastFrameS3Key, lastFramePath, "image/jpeg")
-		if err != nil {
-			h.logger.Warn("Failed to upload last frame, continuing",
-				zap.String("job_id", jobID),
-				zap.Error(err),
-			)
-			lastFrameS3URL = "" // Continue without last frame URL
-		} else {
-			// Generate presigned URL for Veo API access (valid for 1 hour)
-			lastFrameS3URL, err = h.s3Service.GetPresignedURL(ctx, lastFrameS3Key, 1*time.Hour)
-			if err != nil {
-				h.logger.Warn("Failed to generate presigned URL for last frame, continuing",
-					zap.String("job_id", jobID),
-					zap.Error(err),
-				)
-				lastFrameS3URL = "" // Continue without last frame URL
-			}
-		}
-	}
-
-	h.logger.Info("Video processed and uploaded",
-		zap.String("job_id", jobID),
-		zap.Int("clip", clipNumber),
-		zap.String("s3_url", videoS3URL),
-	)
-
-	return videoS3URL, lastFrameS3URL, nil
+	return h.composer.DownloadAndProcessClip(ctx, job, clipNumber, 0, videoURL, expectedDuration, aspectPolicy)
 }
 
-// extractJobThumbnail extracts a middle frame from the first scene video and uploads as job thumbnail
+// extractJobThumbnail extracts the first frame of the first scene video and uploads every
+// thumbnail rendition. It returns the raw S3 URL of the widest JPEG (the job's thumbnail_url)
+// and the uploaded renditions.
 func (h *GenerateHandler) extractJobThumbnail(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	job *domain.Job,
 	videoURL string,
-) (string, error) {
-	h.logger.Info("Extracting job thumbnail from first scene",
-		zap.String("job_id", jobID),
+) (string, []domain.ThumbnailRendition, error) {
+	h.log(ctx).Info("Extracting job thumbnail from first scene",
 		zap.String("video_url", videoURL),
 	)
 
 	// Create temp directory
-	tmpDir := filepath.Join("/tmp", jobID, "thumbnail")
+	tmpDir := filepath.Join("/tmp", job.JobID, "thumbnail")
 	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return "", nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Download video using S3 SDK (videoURL is a raw S3 URL, not presigned)
-	videoPath := filepath.Join(tmpDir, "video.mp4")
-	videoS3Key := extractS3Key(videoURL)
-	if err := h.s3Service.DownloadFile(ctx, h.assetsBucket, videoS3Key, videoPath); err != nil {
-		return "", fmt.Errorf("failed to download video: %w", err)
-	}
-
-	// Extract the very first frame of the video for the thumbnail
-	thumbnailPath := filepath.Join(tmpDir, "thumbnail.jpg")
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-ss", "0", // Seek to the very beginning
-		"-i", videoPath,
-		"-frames:v", "1", // Extract exactly 1 frame
-		"-vf", "scale=1280:-1", // Scale to 1280px width, maintain aspect ratio
-		"-q:v", "2", // High quality
-		"-y", thumbnailPath,
-	)
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to extract thumbnail: %w", err)
+	// Stream the clip from S3 straight into ffmpeg (videoURL is a raw S3 URL, not presigned)
+	renditions := thumbnailRenditions(tmpDir, h.thumbnailWebP)
+	videoS3Key := s3util.Key(videoURL)
+	if err := h.extractThumbnailFromStream(ctx, jobAssetBucket(job, h.assetsBucket), videoS3Key, renditions); err != nil {
+		// MP4s with the moov atom at the end can't be demuxed from a pipe; fall back to a local copy
+		h.log(ctx).Warn("Streaming thumbnail extraction failed, downloading the clip instead",
+			zap.Error(err),
+		)
+		videoPath := filepath.Join(tmpDir, "video.mp4")
+		if err := h.s3Service.DownloadFile(ctx, jobAssetBucket(job, h.assetsBucket), videoS3Key, videoPath); err != nil {
+			return "", nil, fmt.Errorf("failed to download video: %w", err)
+		}
+		if err := ffmpegexec.Exec(ctx, "thumbnail", ffmpegexec.Spec{Args: thumbnailArgs(videoPath, renditions), Timeout: ffmpegexec.FrameTimeout}); err != nil {
+			return "", nil, fmt.Errorf("failed to extract thumbnail: %w", err)
+		}
+		os.Remove(videoPath)
 	}
 
 	// Upload to S3
-	thumbnailS3Key := buildJobThumbnailKey(userID, jobID)
-	thumbnailURL, err := h.s3Service.UploadFile(ctx, h.assetsBucket, thumbnailS3Key, thumbnailPath, "image/jpeg")
-	if err != nil {
-		return "", fmt.Errorf("failed to upload thumbnail to S3: %w", err)
+	var thumbnailURL string
+	uploaded := make([]domain.ThumbnailRendition, 0, len(renditions))
+	for _, r := range renditions {
+		key := buildJobThumbnailKey(job, r.Width, r.Format)
+		url, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), key, r.Path, r.contentType())
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to upload %dpx %s thumbnail to S3: %w", r.Width, r.Format, err)
+		}
+		if r.Format == thumbnailFormatJPEG {
+			thumbnailURL = url // Renditions are ordered smallest first
+		}
+		uploaded = append(uploaded, domain.ThumbnailRendition{Width: r.Width, Format: r.Format, Key: key})
 	}
 
-	h.logger.Info("Job thumbnail extracted and uploaded successfully",
-		zap.String("job_id", jobID),
+	h.log(ctx).Info("Job thumbnail extracted and uploaded successfully",
 		zap.String("thumbnail_url", thumbnailURL),
+		zap.Int("renditions", len(uploaded)),
 	)
 
-	return thumbnailURL, nil
+	return thumbnailURL, uploaded, nil
+}
+
+// extractThumbnailFromStream pipes an S3 object into ffmpeg without writing the clip to /tmp
+func (h *GenerateHandler) extractThumbnailFromStream(ctx context.Context, bucket, s3Key string, renditions []thumbnailRendition) error {
+	body, err := h.s3Service.OpenObject(ctx, bucket, s3Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return ffmpegexec.Exec(ctx, "thumbnail", ffmpegexec.Spec{Args: thumbnailArgs("pipe:0", renditions), Stdin: body, Timeout: ffmpegexec.FrameTimeout})
 }
 
-// generateAudio generates background music using Minimax
+// generateAudio generates background music with Minimax, falling back to the secondary music
+// model if it keeps failing
 func (h *GenerateHandler) generateAudio(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	job *domain.Job,
 	script *domain.Script,
-) (string, error) {
-	h.logger.Info("Calling Minimax adapter", zap.String("job_id", jobID))
+	targetDuration float64,
+) (*musicTrack, error) {
+	h.log(ctx).Info("Generating background music")
+	ctx, musicDone := h.trackStep(ctx, job, domain.StepMusic)
 
 	req := &adapters.MusicGenerationRequest{
 		Prompt:     script.Title,
@@ -950,54 +909,19 @@ func (h *GenerateHandler) generateAudio(
 		MusicStyle: script.AudioSpec.MusicStyle,
 	}
 
-	result, err := h.minimaxAdapter.GenerateMusic(ctx, req)
+	result, err := h.music.Generate(ctx, req, audioPollOptions(h.log(ctx)))
 	if err != nil {
-		return "", fmt.Errorf("minimax API failed: %w", err)
+		return nil, fmt.Errorf("music generation failed: %w", err)
 	}
 
-	// Poll until complete (max 5 minutes)
-	maxAttempts := AudioGenerationMaxAttempts // 60 × 5s = 5 minutes
-	pollInterval := PollInterval
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		select {
-		case <-ctx.Done():
-			return "", ctx.Err()
-		default:
-		}
-
-		if attempt > 0 {
-			time.Sleep(pollInterval)
-			result, err = h.minimaxAdapter.GetStatus(ctx, result.PredictionID)
-			if err != nil {
-				h.logger.Warn("Minimax polling failed, retrying", zap.Error(err))
-				continue
-			}
-		}
-
-		if result.Status == "succeeded" || result.Status == "completed" {
-			// Download and upload to S3
-			audioS3URL, err := h.processAudio(ctx, userID, jobID, result.AudioURL)
-			if err != nil {
-				return "", fmt.Errorf("audio processing failed: %w", err)
-			}
-			return audioS3URL, nil
-		}
-
-		if result.Status == "failed" || result.Status == "canceled" {
-			return "", fmt.Errorf("minimax generation failed: %s", result.Error)
-		}
-
-		// Log only every 12th attempt (every minute instead of every 5 seconds)
-		if attempt%12 == 0 {
-			h.logger.Debug("Minimax still processing",
-				zap.String("job_id", jobID),
-				zap.Int("attempt", attempt),
-			)
-		}
+	// Download, fit to the video and upload to S3
+	track, err := h.processAudio(ctx, job, result.AudioURL, targetDuration, script.AudioSpec.SyncPoints)
+	if err != nil {
+		return nil, fmt.Errorf("audio processing failed: %w", err)
 	}
-
-	return "", fmt.Errorf("audio generation timed out")
+	track.Provider = result.Provider
+	musicDone(0)
+	return track, nil
 }
 
 // generateNarratorVoiceoverTwoPass generates narrator voiceover using the two-pass system.
@@ -1008,13 +932,14 @@ func (h *GenerateHandler) generateNarratorVoiceoverTwoPass(
 	job *domain.Job,
 	script *domain.Script,
 	actualDuration float64,
-) (string, error) {
-	if h.ttsAdapter == nil {
-		return "", fmt.Errorf("tts adapter not configured")
+) (*narratorOutput, error) {
+	ttsAdapter, _ := h.ttsRouter.Resolve(job.TTSProvider)
+	if ttsAdapter == nil {
+		return nil, fmt.Errorf("tts adapter not configured")
 	}
 
 	if h.disclaimerService == nil {
-		return "", fmt.Errorf("disclaimer service not configured")
+		return nil, fmt.Errorf("disclaimer service not configured")
 	}
 
 	voice := job.Voice
@@ -1022,15 +947,14 @@ func (h *GenerateHandler) generateNarratorVoiceoverTwoPass(
 		voice = "male"
 	}
 
-	h.logger.Info("Starting two-pass narrator voiceover generation",
-		zap.String("job_id", job.JobID),
+	h.log(ctx).Info("Starting two-pass narrator voiceover generation",
 		zap.Float64("actual_duration", actualDuration),
 		zap.String("voice", voice),
 	)
 
 	tmpDir := filepath.Join("/tmp", job.JobID, "narrator")
 	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
@@ -1039,23 +963,23 @@ func (h *GenerateHandler) generateNarratorVoiceoverTwoPass(
 		ctx,
 		job.SideEffectsText,
 		int(actualDuration),
+		job.TTSProvider,
 		voice,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to compute disclaimer spec: %w", err)
+		return nil, fmt.Errorf("failed to compute disclaimer spec: %w", err)
 	}
-	job.DisclaimerSpec = disclaimerSpec
+	out := &narratorOutput{DisclaimerSpec: disclaimerSpec}
 
 	// Step 2: Calculate narration budget
+	language := h.scriptLanguage(job)
 	budgetSeconds, budgetWords := service.CalculateNarrationBudget(
 		int(actualDuration),
 		disclaimerSpec.AudioDuration,
+		language.WordsPerSecond,
 	)
-	job.NarrationBudget = budgetSeconds
-	job.NarrationWords = budgetWords
 
-	h.logger.Info("Narration budget calculated",
-		zap.String("job_id", job.JobID),
+	h.log(ctx).Info("Narration budget calculated",
 		zap.Float64("total_duration", actualDuration),
 		zap.Float64("disclaimer_duration", disclaimerSpec.AudioDuration),
 		zap.Float64("narration_budget_seconds", budgetSeconds),
@@ -1067,16 +991,12 @@ func (h *GenerateHandler) generateNarratorVoiceoverTwoPass(
 	if budgetSeconds < 5 {
 		if disclaimerSpec.Tier != domain.DisclaimerTierTextOnly {
 			// Switch to text-only mode
-			h.logger.Warn("Narration budget too short, switching to text-only disclaimer",
-				zap.String("job_id", job.JobID),
-			)
+			h.log(ctx).Warn("Narration budget too short, switching to text-only disclaimer")
 			disclaimerSpec.Tier = domain.DisclaimerTierTextOnly
 			disclaimerSpec.UseAudio = false
 			disclaimerSpec.AudioDuration = 0
 			budgetSeconds = actualDuration - service.CalculateMusicTail(int(actualDuration))
-			budgetWords = int(budgetSeconds * 2.5)
-			job.NarrationBudget = budgetSeconds
-			job.NarrationWords = budgetWords
+			budgetWords = int(budgetSeconds * language.WordsPerSecond)
 		}
 	}
 
@@ -1089,18 +1009,18 @@ func (h *GenerateHandler) generateNarratorVoiceoverTwoPass(
 		budgetWords,
 		script.Metadata.ProductName,
 		job.Prompt, // Product description
+		language,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate narration: %w", err)
+		return nil, fmt.Errorf("failed to generate narration: %w", err)
 	}
 
 	narration, wordCount, err := adapters.ParseNarrationResponse(narrationResponse)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse narration: %w", err)
+		return nil, fmt.Errorf("failed to parse narration: %w", err)
 	}
 
-	h.logger.Info("Narration generated",
-		zap.String("job_id", job.JobID),
+	h.log(ctx).Info("Narration generated",
 		zap.Int("word_count", wordCount),
 		zap.Int("target_words", budgetWords),
 	)
@@ -1108,430 +1028,308 @@ func (h *GenerateHandler) generateNarratorVoiceoverTwoPass(
 	// Step 4: Expand if too short (≥15% under target)
 	minWords := int(float64(budgetWords) * 0.85)
 	if wordCount < minWords {
-		h.logger.Info("Narration too short, expanding",
-			zap.String("job_id", job.JobID),
+		h.log(ctx).Info("Narration too short, expanding",
 			zap.Int("current_words", wordCount),
 			zap.Int("target_words", budgetWords),
 		)
 
-		expandedResponse, err := h.gpt4oAdapter.ExpandNarration(ctx, narration, wordCount, budgetWords)
+		expandedResponse, err := h.gpt4oAdapter.ExpandNarration(ctx, narration, wordCount, budgetWords, language)
 		if err != nil {
-			h.logger.Warn("Failed to expand narration, using original",
-				zap.String("job_id", job.JobID),
+			h.log(ctx).Warn("Failed to expand narration, using original",
 				zap.Error(err),
 			)
 		} else {
 			narration, wordCount, _ = adapters.ParseNarrationResponse(expandedResponse)
-			h.logger.Info("Narration expanded",
-				zap.String("job_id", job.JobID),
+			h.log(ctx).Info("Narration expanded",
 				zap.Int("new_word_count", wordCount),
 			)
 		}
 	}
 
-	// Step 5: Generate TTS for main narration
-	mainAudioData, err := h.ttsAdapter.GenerateVoiceover(ctx, narration, voice)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate main narration TTS: %w", err)
+	// The narration is final, so it gets the required phrase and banned claim checks the
+	// script's narrator script would have had
+	spoken := narration
+	if disclaimerSpec.UseAudio {
+		spoken += " " + disclaimerSpec.AudioText
 	}
-
-	mainAudioPath := filepath.Join(tmpDir, "narrator-main.mp3")
-	if err := os.WriteFile(mainAudioPath, mainAudioData, 0o644); err != nil {
-		return "", fmt.Errorf("failed to write main audio: %w", err)
+	out.Budget, out.Words = budgetSeconds, budgetWords
+	out.ComplianceIssues, err = h.checkNarrationCompliance(ctx, spoken)
+	if err != nil {
+		return out, err
 	}
 
-	h.logger.Info("Main narration TTS generated",
-		zap.String("job_id", job.JobID),
-		zap.Int("audio_size_bytes", len(mainAudioData)),
-	)
-
-	// Step 6: Handle disclaimer audio (if not text-only)
-	var finalAudioPath string
+	// Step 5: Generate disclaimer audio once (if not text-only); it is never truncated
+	var disclaimerAudioPath string
+	var disclaimerDuration float64
 	if disclaimerSpec.UseAudio {
 		// Generate disclaimer TTS at 1.4x speed
-		disclaimerAudioData, _, err := h.ttsAdapter.GenerateVoiceoverWithDuration(
+		disclaimerAudioData, duration, err := ttsAdapter.GenerateVoiceoverWithDuration(
 			ctx,
 			disclaimerSpec.AudioText,
 			voice,
 			disclaimerSpec.Speed,
 		)
 		if err != nil {
-			return "", fmt.Errorf("failed to generate disclaimer TTS: %w", err)
+			return nil, fmt.Errorf("failed to generate disclaimer TTS: %w", err)
 		}
+		disclaimerDuration = duration
 
-		disclaimerAudioPath := filepath.Join(tmpDir, "narrator-disclaimer.mp3")
+		disclaimerAudioPath = filepath.Join(tmpDir, "narrator-disclaimer.mp3")
 		if err := os.WriteFile(disclaimerAudioPath, disclaimerAudioData, 0o644); err != nil {
-			return "", fmt.Errorf("failed to write disclaimer audio: %w", err)
+			return nil, fmt.Errorf("failed to write disclaimer audio: %w", err)
 		}
 
-		h.logger.Info("Disclaimer TTS generated",
-			zap.String("job_id", job.JobID),
+		h.log(ctx).Info("Disclaimer TTS generated",
 			zap.Int("audio_size_bytes", len(disclaimerAudioData)),
 			zap.Float64("speed", disclaimerSpec.Speed),
 		)
+	} else {
+		h.log(ctx).Info("Using text-only disclaimer mode (no disclaimer audio)")
+	}
 
-		// Concatenate main + disclaimer
-		finalAudioPath = filepath.Join(tmpDir, "narrator-voiceover.mp3")
-		concatFile := filepath.Join(tmpDir, "concat.txt")
-		concatContent := fmt.Sprintf("file '%s'\nfile '%s'\n", mainAudioPath, disclaimerAudioPath)
-		if err := os.WriteFile(concatFile, []byte(concatContent), 0o644); err != nil {
-			return "", fmt.Errorf("failed to write concat file: %w", err)
+	// Step 6: Generate main narration TTS and fit it to the video, keeping the disclaimer's time
+	// free; the disclaimer is already at its own speed, so only the narration is sped up
+	render := func(ctx context.Context, text string) (string, error) {
+		mainAudioData, err := ttsAdapter.GenerateVoiceover(ctx, text, voice)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate main narration TTS: %w", err)
 		}
-
-		cmd := exec.CommandContext(ctx, "ffmpeg",
-			"-f", "concat",
-			"-safe", "0",
-			"-i", concatFile,
-			"-c", "copy",
-			"-y", finalAudioPath,
-		)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return "", fmt.Errorf("failed to concatenate audio: %w (%s)", err, strings.TrimSpace(string(output)))
+		mainAudioPath := filepath.Join(tmpDir, "narrator-main.mp3")
+		if err := os.WriteFile(mainAudioPath, mainAudioData, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write main audio: %w", err)
 		}
 
-		h.logger.Info("Main narration and disclaimer concatenated",
-			zap.String("job_id", job.JobID),
-		)
-	} else {
-		// Text-only mode: just use main narration
-		finalAudioPath = mainAudioPath
-		h.logger.Info("Using text-only disclaimer mode (no disclaimer audio)",
-			zap.String("job_id", job.JobID),
+		h.log(ctx).Info("Main narration TTS generated",
+			zap.Int("audio_size_bytes", len(mainAudioData)),
 		)
+		return mainAudioPath, nil
 	}
 
+	fit, err := h.fitNarrationToVideo(ctx, job.JobID, narration, actualDuration, disclaimerDuration, tmpDir, render)
+	if err != nil {
+		return nil, err
+	}
+	mainDuration := fit.Duration
+	if disclaimerAudioPath != "" {
+		fit.Path, err = concatNarration(ctx, tmpDir, fit.Path, disclaimerAudioPath)
+		if err != nil {
+			return nil, err
+		}
+		fit.Duration += disclaimerDuration
+		h.log(ctx).Info("Main narration and disclaimer concatenated")
+	}
+	fit.Path, fit.Loudness = h.normalizeAudioFile(ctx, job.JobID, "narration", fit.Path, h.audioConfig.narrationTarget())
+	out.Fit = fit
+	finalAudioPath := fit.Path
+
 	// Step 7: Upload to S3
-	h.logger.Info("Uploading narrator audio to S3",
-		zap.String("job_id", job.JobID),
-	)
-	s3Key := buildNarratorAudioKey(job.UserID, job.JobID)
-	narratorAudioURL, err := h.s3Service.UploadFile(ctx, h.assetsBucket, s3Key, finalAudioPath, "audio/mpeg")
+	h.log(ctx).Info("Uploading narrator audio to S3")
+	s3Key := composition.NarratorAudioKey(job)
+	out.URL, err = h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), s3Key, finalAudioPath, "audio/mpeg")
 	if err != nil {
-		return "", fmt.Errorf("failed to upload narrator audio: %w", err)
+		return nil, fmt.Errorf("failed to upload narrator audio: %w", err)
 	}
 
 	// Update side effects start time based on actual disclaimer timing
-	job.SideEffectsStartTime = h.calculateSideEffectsStartTime(actualDuration, disclaimerSpec)
+	sideEffectsStart := composition.SideEffectsStartTime(actualDuration, disclaimerSpec)
+	if disclaimerDuration > 0 && fit.Speed > 1.0 {
+		// Speed-up moves the disclaimer earlier; start the overlay with it
+		sideEffectsStart = math.Min(sideEffectsStart, mainDuration)
+	}
+	out.SideEffectsStartTime = &sideEffectsStart
 
-	h.logger.Info("Narrator voiceover uploaded",
-		zap.String("job_id", job.JobID),
+	h.log(ctx).Info("Narrator voiceover uploaded",
 		zap.String("s3_key", s3Key),
-		zap.String("url", narratorAudioURL),
-		zap.Float64("side_effects_start_time", job.SideEffectsStartTime),
+		zap.String("url", out.URL),
+		zap.Float64("side_effects_start_time", sideEffectsStart),
 	)
 
-	return narratorAudioURL, nil
+	// The disclaimer is read faster than the narration, so its captions go by faster too
+	segments := []captionSegment{{Text: fit.Text, Speed: fit.Speed}}
+	if disclaimerAudioPath != "" {
+		segments = append(segments, captionSegment{Text: disclaimerSpec.AudioText, Speed: disclaimerSpec.Speed})
+	}
+	out.Cues, out.CaptionsKey = h.publishCaptions(ctx, job, segments, fit.Duration)
+
+	return out, nil
 }
 
-// calculateSideEffectsStartTime calculates the dynamic side effects start time.
-func (h *GenerateHandler) calculateSideEffectsStartTime(actualDuration float64, disclaimerSpec *domain.DisclaimerSpec) float64 {
-	if disclaimerSpec == nil || !disclaimerSpec.UseAudio {
-		return 0 // No audio disclaimer
+// concatNarration joins the fitted main narration and the disclaimer read after it into one
+// track, returning its path
+func concatNarration(ctx context.Context, tmpDir, mainPath, disclaimerPath string) (string, error) {
+	combinedPath := filepath.Join(tmpDir, "narrator-voiceover.mp3")
+	concatFile := filepath.Join(tmpDir, "concat.txt")
+	concatContent := fmt.Sprintf("file '%s'\nfile '%s'\n", mainPath, disclaimerPath)
+	if err := os.WriteFile(concatFile, []byte(concatContent), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write concat file: %w", err)
 	}
-	musicTail := service.CalculateMusicTail(int(actualDuration))
-	return actualDuration - disclaimerSpec.AudioDuration - musicTail
+
+	if err := ffmpegexec.Exec(ctx, "concat_narration", ffmpegexec.Spec{
+		Args: []string{
+			"-f", "concat",
+			"-safe", "0",
+			"-i", concatFile,
+			"-c", "copy",
+			"-y", combinedPath,
+		},
+		Timeout: ffmpegexec.FrameTimeout,
+	}); err != nil {
+		return "", fmt.Errorf("failed to concatenate audio: %w", err)
+	}
+	return combinedPath, nil
 }
 
 // generateNarratorVoiceover generates narrator voiceover for non-pharmaceutical ads.
-// This is a simple single-pass TTS generation without any speed manipulation.
+// This is a single-pass TTS generation; narration that overruns the video is
+// sped up (and truncated if needed) by fitNarrationToVideo.
 //
 // DEPRECATED: For pharmaceutical ads, use generateNarratorVoiceoverTwoPass instead,
 // which handles the two-pass narration system with dynamic disclaimer timing.
 func (h *GenerateHandler) generateNarratorVoiceover(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	job *domain.Job,
 	voice string,
+	ttsProvider string,
 	narratorScript string,
 	_ float64, // sideEffectsStartTime - no longer used (kept for API compatibility)
-	_ int, // duration - no longer used (kept for API compatibility)
-) (string, error) {
-	if h.ttsAdapter == nil {
-		return "", fmt.Errorf("tts adapter not configured")
+	targetDuration float64,
+) (string, *narrationFit, error) {
+	ttsAdapter, _ := h.ttsRouter.Resolve(ttsProvider)
+	if ttsAdapter == nil {
+		return "", nil, fmt.Errorf("tts adapter not configured")
 	}
 
 	if strings.TrimSpace(narratorScript) == "" {
-		return "", fmt.Errorf("narrator script is empty")
+		return "", nil, fmt.Errorf("narrator script is empty")
 	}
 
-	h.logger.Info("Generating narrator voiceover (legacy single-pass for non-pharma ads)",
-		zap.String("job_id", jobID),
+	h.log(ctx).Info("Generating narrator voiceover (legacy single-pass for non-pharma ads)",
 		zap.String("voice", voice),
 		zap.Int("script_length", len(narratorScript)),
 	)
 
-	// Generate TTS audio at normal speed
-	audioData, err := h.ttsAdapter.GenerateVoiceover(ctx, narratorScript, voice)
-	if err != nil {
-		return "", fmt.Errorf("tts generation failed: %w", err)
-	}
-
-	h.logger.Info("TTS generation successful",
-		zap.String("job_id", jobID),
-		zap.Int("audio_size_bytes", len(audioData)),
-	)
-
-	tmpDir := filepath.Join("/tmp", jobID, "narrator")
+	tmpDir := filepath.Join("/tmp", job.JobID, "narrator")
 	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return "", nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
+	// Generate TTS audio at normal speed
 	audioPath := filepath.Join(tmpDir, "narrator.mp3")
-	if err := os.WriteFile(audioPath, audioData, 0o644); err != nil {
-		return "", fmt.Errorf("failed to write narrator audio: %w", err)
+	render := func(ctx context.Context, text string) (string, error) {
+		audioData, err := ttsAdapter.GenerateVoiceover(ctx, text, voice)
+		if err != nil {
+			return "", fmt.Errorf("tts generation failed: %w", err)
+		}
+
+		h.log(ctx).Info("TTS generation successful",
+			zap.Int("audio_size_bytes", len(audioData)),
+		)
+
+		if err := os.WriteFile(audioPath, audioData, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write narrator audio: %w", err)
+		}
+		return audioPath, nil
+	}
+
+	fit, err := h.fitNarrationToVideo(ctx, job.JobID, narratorScript, targetDuration, 0, tmpDir, render)
+	if err != nil {
+		return "", nil, err
 	}
+	fit.Path, fit.Loudness = h.normalizeAudioFile(ctx, job.JobID, "narration", fit.Path, h.audioConfig.narrationTarget())
 
 	// Upload to S3
-	h.logger.Info("Uploading narrator audio to S3", zap.String("job_id", jobID))
-	s3Key := buildNarratorAudioKey(userID, jobID)
-	narratorAudioURL, err := h.s3Service.UploadFile(ctx, h.assetsBucket, s3Key, audioPath, "audio/mpeg")
+	h.log(ctx).Info("Uploading narrator audio to S3")
+	s3Key := composition.NarratorAudioKey(job)
+	narratorAudioURL, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), s3Key, fit.Path, "audio/mpeg")
 	if err != nil {
-		return "", fmt.Errorf("failed to upload narrator audio: %w", err)
+		return "", nil, fmt.Errorf("failed to upload narrator audio: %w", err)
 	}
 
-	h.logger.Info("Narrator voiceover uploaded",
-		zap.String("job_id", jobID),
+	h.log(ctx).Info("Narrator voiceover uploaded",
 		zap.String("s3_key", s3Key),
 		zap.String("url", narratorAudioURL),
+		zap.Float64("narration_duration", fit.Duration),
+		zap.Float64("narration_speed", fit.Speed),
 	)
 
-	return narratorAudioURL, nil
+	return narratorAudioURL, fit, nil
 }
 
-// processAudio downloads audio from Replicate, uploads to S3
-func (h *GenerateHandler) processAudio(ctx context.Context, userID string, jobID string, audioURL string) (string, error) {
-	tmpDir := filepath.Join("/tmp", jobID, "audio")
+// processAudio downloads audio from Replicate, fits it to the video duration and uploads
+// both the raw and the fitted track to S3. Fitting is best effort: if it fails the raw
+// track is served as-is.
+func (h *GenerateHandler) processAudio(ctx context.Context, job *domain.Job, audioURL string, targetDuration float64, syncPoints []domain.SyncPoint) (*musicTrack, error) {
+	tmpDir := filepath.Join("/tmp", job.JobID, "audio")
 	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
 	// Download audio
-	h.logger.Info("Downloading audio from Replicate",
-		zap.String("job_id", jobID),
+	h.log(ctx).Info("Downloading audio from Replicate",
 		zap.String("url", audioURL),
 	)
-	audioPath := filepath.Join(tmpDir, "music.mp3")
-	if err := h.downloadFile(ctx, audioURL, audioPath); err != nil {
-		return "", fmt.Errorf("failed to download audio: %w", err)
+	rawPath := filepath.Join(tmpDir, "music-raw.mp3")
+	if err := h.downloadFile(ctx, audioURL, rawPath); err != nil {
+		return nil, fmt.Errorf("failed to download audio: %w", err)
 	}
 
-	// Upload to S3
-	h.logger.Info("Uploading audio to S3",
-		zap.String("job_id", jobID),
-	)
-	audioS3Key := buildAudioKey(userID, jobID)
-	audioS3URL, err := h.s3Service.UploadFile(ctx, h.assetsBucket, audioS3Key, audioPath, "audio/mpeg")
+	// Keep the track as generated for debugging
+	rawURL, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), buildRawAudioKey(job), rawPath, "audio/mpeg")
 	if err != nil {
-		return "", fmt.Errorf("failed to upload audio to S3: %w", err)
+		return nil, fmt.Errorf("failed to upload raw audio to S3: %w", err)
 	}
 
-	h.logger.Info("Audio processed and uploaded", zap.String("job_id", jobID), zap.String("s3_url", audioS3URL))
-	return audioS3URL, nil
-}
+	track := &musicTrack{RawURL: rawURL, Plan: musicFitPlan{Mode: musicFitNone, Copies: 1}}
+	audioPath := rawPath
 
-// detectAvailableFont returns the first available font file path from a prioritized list.
-func detectAvailableFont(logger *zap.Logger) string {
-	fontPaths := []string{
-		// Alpine Linux (Docker container)
-		"/usr/share/fonts/ttf-dejavu/DejaVuSans-Bold.ttf",
-		"/usr/share/fonts/ttf-dejavu/DejaVuSans.ttf",
-		// Debian/Ubuntu
-		"/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf",
-		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
-		"/usr/share/fonts/truetype/liberation/LiberationSans-Bold.ttf",
-		"/usr/share/fonts/truetype/liberation2/LiberationSans-Bold.ttf",
-	}
-
-	for _, path := range fontPaths {
-		if _, err := os.Stat(path); err == nil {
-			logger.Info("Font detected for text overlay", zap.String("font_path", path))
-			return path
+	// Land a beat on the script's first beat sync point before fitting the track's length
+	if h.audioConfig.MusicBeatSync {
+		if target, ok := firstBeatSyncPoint(syncPoints, targetDuration); ok {
+			audioPath, track.SyncOffset = syncMusicToBeat(ctx, h.log(ctx), rawPath, tmpDir, target)
 		}
 	}
 
-	logger.Warn("No preferred fonts found, using ffmpeg default font")
-	return ""
-}
-
-// escapeFfmpegText escapes special characters so they are safe for ffmpeg drawtext filters.
-func escapeFfmpegText(text string) string {
-	replacer := strings.NewReplacer(
-		"\\", "\\\\", // Backslashes (must be first)
-		"'", "\\'", // Single quotes
-		":", "\\:", // Colons
-		"%", "\\%", // Percent signs
-		"\n", "\\n", // Newlines → \n
-		"\"", "\\\"", // Double quotes
-	)
-	return replacer.Replace(text)
-}
-
-type drawtextConfig struct {
-	Filter         string
-	OverlayStart   float64
-	OverlayEnd     float64
-	RuneCount      int
-	BaseFontSize   float64
-	MaxChars       int
-	EstimatedWidth float64
-	RenderedText   string
-}
-
-func buildDrawtextConfig(
-	logger *zap.Logger,
-	text string,
-	sideEffectsStartTime float64,
-	totalDuration float64,
-	videoWidth int,
-	videoHeight int,
-) (*drawtextConfig, error) {
-	trimmedText := strings.TrimSpace(text)
-	if trimmedText == "" {
-		return nil, nil
-	}
-
-	if totalDuration <= 0 {
-		logger.Warn("Skipping text overlay (unknown video duration)",
-			zap.Float64("video_duration", totalDuration),
-		)
-		return nil, nil
-	}
-
-	overlayStart := sideEffectsStartTime
-	if overlayStart <= 0 {
-		overlayStart = totalDuration * 0.8
-		logger.Warn("Non-positive side effects start time; defaulting to last 20%",
-			zap.Float64("original_start_time", sideEffectsStartTime),
-			zap.Float64("fallback_start_time", overlayStart),
-		)
-	}
-
-	overlayEnd := totalDuration
-	if overlayStart >= overlayEnd {
-		logger.Warn("Skipping text overlay (start time beyond duration)",
-			zap.Float64("side_effects_start_time", overlayStart),
-			zap.Float64("video_duration", overlayEnd),
+	if rawDuration, err := composition.ProbeAudioDuration(ctx, rawPath); err != nil {
+		h.log(ctx).Warn("Failed to probe music duration, using track as generated",
+			zap.Error(err),
 		)
-		return nil, nil
-	}
-
-	runeCount := utf8.RuneCountInString(trimmedText)
-	baseFontSize := 36.0
-	if runeCount > 360 {
-		baseFontSize = 32.0
-	}
-	if runeCount > 440 {
-		baseFontSize = 28.0
-	}
-
-	if videoWidth <= 0 {
-		videoWidth = 1920
-	}
-	if videoHeight <= 0 {
-		videoHeight = 1080
-	}
-
-	scaleFactor := float64(videoHeight) / 1080.0
-	fontSizePixels := baseFontSize * scaleFactor
-	if fontSizePixels < 18 {
-		fontSizePixels = 18
-	}
-
-	lineSpacingPixels := int(math.Round(fontSizePixels * (8.0 / 36.0)))
-	wrappedText, maxChars := wrapText(trimmedText, videoWidth, fontSizePixels)
-
-	estimatedWidthPx := float64(maxChars) * fontSizePixels * 0.6
-	if estimatedWidthPx > float64(videoWidth) {
-		estimatedWidthPx = float64(videoWidth)
-	}
-
-	fontFile := detectAvailableFont(logger)
-	escapedText := escapeFfmpegText(wrappedText)
-
-	filterParts := []string{
-		fmt.Sprintf("text='%s'", escapedText),
-		fmt.Sprintf("fontsize=%.2f", fontSizePixels),
-	}
-	if fontFile != "" {
-		filterParts = append(filterParts, fmt.Sprintf("fontfile='%s'", fontFile))
-	}
-	filterParts = append(filterParts,
-		"fontcolor=white",
-		"bordercolor=black",
-		"borderw=2",
-		fmt.Sprintf("x=(w-%.2f)/2", estimatedWidthPx),
-		"y=h-h*0.2",
-		fmt.Sprintf("line_spacing=%d", lineSpacingPixels),
-		fmt.Sprintf("enable='between(t,%.2f,%.2f)'", overlayStart, overlayEnd),
-	)
-
-	return &drawtextConfig{
-		Filter:         fmt.Sprintf("drawtext=%s", strings.Join(filterParts, ":")),
-		OverlayStart:   overlayStart,
-		OverlayEnd:     overlayEnd,
-		RuneCount:      runeCount,
-		BaseFontSize:   baseFontSize,
-		MaxChars:       maxChars,
-		EstimatedWidth: estimatedWidthPx,
-		RenderedText:   wrappedText,
-	}, nil
-}
-
-func wrapText(text string, videoWidth int, fontSize float64) (string, int) {
-	totalRunes := utf8.RuneCountInString(text)
-	if totalRunes <= 60 {
-		estimatedWidth := float64(totalRunes) * fontSize * 0.6
-		if estimatedWidth <= float64(videoWidth)*0.8 {
-			return text, totalRunes
-		}
-	}
-
-	maxCharsPerLine := int((float64(videoWidth) * 0.8) / (fontSize * 0.6))
-	if maxCharsPerLine >= totalRunes {
-		return text, totalRunes
-	}
-	if maxCharsPerLine < 20 {
-		maxCharsPerLine = 20
-	}
-
-	lines := make([]string, 0)
-	for _, rawLine := range strings.Split(text, "\n") {
-		words := strings.Fields(rawLine)
-		if len(words) == 0 {
-			lines = append(lines, "")
-			continue
-		}
-
-		var currentWords []string
-		currentLen := 0
-		for _, word := range words {
-			wordLen := utf8.RuneCountInString(word)
-			additionalLen := wordLen
-			if currentLen > 0 {
-				additionalLen++ // account for space
-			}
-
-			if currentLen+additionalLen > maxCharsPerLine {
-				lines = append(lines, strings.Join(currentWords, " "))
-				currentWords = []string{word}
-				currentLen = wordLen
+	} else {
+		track.RawDuration = rawDuration
+		plan := planMusicFit(rawDuration+track.SyncOffset, targetDuration)
+		if plan.Mode != musicFitNone {
+			fittedPath := filepath.Join(tmpDir, "music.mp3")
+			if err := applyMusicFit(ctx, audioPath, fittedPath, plan); err != nil {
+				h.log(ctx).Warn("Failed to fit music to video, using track as generated",
+					zap.Error(err),
+				)
 			} else {
-				if currentLen > 0 {
-					currentLen++ // space
-				}
-				currentWords = append(currentWords, word)
-				currentLen += wordLen
+				track.Plan = plan
+				audioPath = fittedPath
 			}
 		}
+		h.log(ctx).Info("Music fitted to video",
+			zap.Float64("raw_duration", rawDuration),
+			zap.Float64("target_duration", targetDuration),
+			zap.String("fit", track.Plan.Mode),
+			zap.Int("copies", track.Plan.Copies),
+		)
+	}
 
-		if len(currentWords) > 0 {
-			lines = append(lines, strings.Join(currentWords, " "))
-		}
+	audioPath, track.Loudness = h.normalizeAudioFile(ctx, job.JobID, "music", audioPath, h.audioConfig.musicTarget())
+
+	// Upload to S3
+	h.log(ctx).Info("Uploading audio to S3")
+	audioS3Key := composition.AudioKey(job)
+	audioS3URL, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), audioS3Key, audioPath, "audio/mpeg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload audio to S3: %w", err)
 	}
+	track.URL = audioS3URL
 
-	return strings.Join(lines, "\n"), maxCharsPerLine
+	h.log(ctx).Info("Audio processed and uploaded", zap.String("s3_url", audioS3URL))
+	return track, nil
 }
 
 // composeVideo concatenates video clips, applies text overlay, and muxes audio tracks.
@@ -1541,544 +1339,20 @@ func wrapText(text string, videoWidth int, fontSize float64) (string, int) {
 //   - Both tracks are combined and embedded into the final video
 //   - Separate audio files (audio_url, narrator_audio_url) are kept for backwards compatibility
 //
-// Output: final/video.mp4 and final/video.webm with embedded audio
+// Output: final/video-{hash}.mp4 and final/video-{hash}.webm with embedded audio. A
+// composition of the same inputs is only done once (see composeOnce).
 // Returns: (mp4Key, webmKey, error) - webmKey may be empty if WebM encoding fails
 func (h *GenerateHandler) composeVideo(
 	ctx context.Context,
 	job *domain.Job,
-	clips []ClipVideo,
+	clips []composition.ClipVideo,
 ) (string, string, error) {
-	userID := job.UserID
-	jobID := job.JobID
-
-	var totalDuration float64
-	for _, clip := range clips {
-		totalDuration += clip.Duration
-	}
-
-	// Determine text overlay settings based on disclaimer tier
-	var overlayText string
-	var overlayStart float64
-
-	if job.DisclaimerSpec != nil {
-		switch job.DisclaimerSpec.Tier {
-		case domain.DisclaimerTierTextOnly:
-			// Text-only: show abbreviated disclaimer for last 4-5 seconds
-			overlayText = job.DisclaimerSpec.AudioText // Abbreviated version
-			overlayStart = math.Max(0, totalDuration-5.0)
-			h.logger.Info("Using text-only disclaimer overlay",
-				zap.String("job_id", jobID),
-				zap.Float64("overlay_start", overlayStart),
-				zap.String("tier", string(job.DisclaimerSpec.Tier)),
-			)
-
-		case domain.DisclaimerTierShort, domain.DisclaimerTierFull:
-			// Audio disclaimer: show full text starting when audio disclaimer begins
-			overlayText = job.DisclaimerSpec.FullText
-			overlayStart = h.calculateSideEffectsStartTime(totalDuration, job.DisclaimerSpec)
-			h.logger.Info("Using audio-synced disclaimer overlay",
-				zap.String("job_id", jobID),
-				zap.Float64("overlay_start", overlayStart),
-				zap.String("tier", string(job.DisclaimerSpec.Tier)),
-			)
-		}
-	} else {
-		// Fallback to existing behavior for non-pharmaceutical ads or legacy jobs
-		overlayText = job.SideEffectsText
-		overlayStart = job.SideEffectsStartTime
-	}
-
-	trimmedText := strings.TrimSpace(overlayText)
-
-	h.logger.Info("Composing final video",
-		zap.String("job_id", jobID),
-		zap.Int("num_clips", len(clips)),
-		zap.Bool("has_side_effects", trimmedText != ""),
-		zap.Float64("side_effects_start_time", overlayStart),
-		zap.Float64("video_duration_estimate", totalDuration),
-	)
-
-	if overlayStart > 0 && trimmedText == "" {
-		return "", "", fmt.Errorf("side effects text is required when sideEffectsStartTime is provided")
-	}
-
-	tmpDir := filepath.Join("/tmp", jobID, "composition")
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create temp dir: %w", err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	// Download all clips from S3
-	h.logger.Info("Downloading clips from S3 for composition",
-		zap.String("job_id", jobID),
-		zap.Int("num_clips", len(clips)),
-	)
-	var clipPaths []string
-	for i, clip := range clips {
-		clipPath := filepath.Join(tmpDir, fmt.Sprintf("clip-%d.mp4", i+1))
-		if err := h.s3Service.DownloadFile(ctx, h.assetsBucket, extractS3Key(clip.VideoURL), clipPath); err != nil {
-			return "", "", fmt.Errorf("failed to download clip %d: %w", i+1, err)
-		}
-		clipPaths = append(clipPaths, clipPath)
-	}
-
-	// Create concat file for ffmpeg
-	concatFile := filepath.Join(tmpDir, "concat.txt")
-	f, err := os.Create(concatFile)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to create concat file: %w", err)
-	}
-	for _, path := range clipPaths {
-		fmt.Fprintf(f, "file '%s'\n", path)
-	}
-	if err := f.Close(); err != nil {
-		return "", "", fmt.Errorf("failed to close concat file: %w", err)
-	}
-
-	// Concatenate clips (video track only)
-	h.logger.Info("Concatenating video clips (video track only)",
-		zap.String("job_id", jobID),
-		zap.Int("num_clips", len(clipPaths)),
-	)
-	finalVideo := filepath.Join(tmpDir, "final.mp4")
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-f", "concat",
-		"-safe", "0",
-		"-i", concatFile,
-		"-c:v", "copy",
-		"-an", // Explicitly drop audio streams (frontend handles audio tracks)
-		"-y", finalVideo,
-	)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		h.logger.Error("ffmpeg concat failed",
-			zap.String("job_id", jobID),
-			zap.String("output", string(output)),
-			zap.Error(err),
-		)
-		return "", "", fmt.Errorf("ffmpeg concat failed: %w", err)
-	}
-
-	// Detect source FPS for interpolation decision
-	sourceFPS := probeVideoFPS(finalVideo)
-	needsInterpolation := sourceFPS > 0 && sourceFPS < 30
-	h.logger.Info("Video FPS detected",
-		zap.String("job_id", jobID),
-		zap.Float64("source_fps", sourceFPS),
-		zap.Bool("needs_interpolation", needsInterpolation),
-	)
-
-	if trimmedText != "" && totalDuration > 0 {
-		videoWidth, videoHeight, err := probeVideoDimensions(finalVideo)
-		if err != nil {
-			h.logger.Warn("Failed to probe video dimensions, using defaults",
-				zap.String("job_id", jobID),
-				zap.Error(err),
-			)
-			videoWidth = 1920
-			videoHeight = 1080
-		}
-
-		config, err := buildDrawtextConfig(h.logger, trimmedText, overlayStart, totalDuration, videoWidth, videoHeight)
-		if err != nil {
-			return "", "", err
-		}
-		if config != nil {
-			h.logger.Info("Applying side effects text overlay",
-				zap.String("job_id", jobID),
-				zap.Float64("overlay_start", config.OverlayStart),
-				zap.Float64("overlay_end", config.OverlayEnd),
-				zap.Int("rune_count", config.RuneCount),
-				zap.Float64("base_font_size", config.BaseFontSize),
-			)
-
-			videoWithText := filepath.Join(tmpDir, "video_with_text.mp4")
-			// Combine FPS interpolation with text overlay if needed (single re-encode)
-			vfFilter := config.Filter
-			if needsInterpolation {
-				vfFilter = "fps=30," + config.Filter
-				h.logger.Info("Combining FPS interpolation with text overlay",
-					zap.String("job_id", jobID),
-				)
-			}
-			cmd = exec.CommandContext(ctx, "ffmpeg",
-				"-i", finalVideo,
-				"-vf", vfFilter,
-				"-c:v", "libx264",
-				"-preset", "medium",
-				"-crf", "21",
-				"-an",
-				"-y", videoWithText,
-			)
-			if output, err := cmd.CombinedOutput(); err != nil {
-				h.logger.Error("ffmpeg text overlay failed",
-					zap.String("job_id", jobID),
-					zap.String("output", string(output)),
-					zap.Error(err),
-				)
-				return "", "", fmt.Errorf("ffmpeg text overlay failed: %w", err)
-			}
-
-			h.logger.Info("Text overlay applied successfully", zap.String("job_id", jobID))
-			finalVideo = videoWithText
-		}
-	} else if trimmedText == "" {
-		h.logger.Info("Skipping text overlay (no side effects text)", zap.String("job_id", jobID))
-	} else {
-		h.logger.Warn("Skipping text overlay (unknown video duration)",
-			zap.String("job_id", jobID),
-			zap.Float64("video_duration", totalDuration),
-		)
-	}
-
-	// Apply FPS interpolation if needed and not already done via text overlay
-	if needsInterpolation && !strings.Contains(finalVideo, "video_with_text") {
-		h.logger.Info("Applying standalone FPS interpolation to 30fps",
-			zap.String("job_id", jobID),
-			zap.Float64("source_fps", sourceFPS),
-		)
-		interpolatedVideo := filepath.Join(tmpDir, "interpolated.mp4")
-		cmd := exec.CommandContext(ctx, "ffmpeg",
-			"-i", finalVideo,
-			"-vf", "fps=30",
-			"-c:v", "libx264",
-			"-preset", "medium",
-			"-crf", "21",
-			"-an",
-			"-y", interpolatedVideo,
-		)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			h.logger.Warn("FPS interpolation failed, using original video",
-				zap.String("job_id", jobID),
-				zap.Float64("source_fps", sourceFPS),
-				zap.String("output", string(output)),
-				zap.Error(err),
-			)
-			// Graceful fallback: continue with original FPS video
-		} else {
-			h.logger.Info("FPS interpolation complete",
-				zap.String("job_id", jobID),
-			)
-			finalVideo = interpolatedVideo
-		}
-	}
-
-	// AUDIO MUXING: Download and mix audio tracks into video
-	musicPath := ""
-	narratorPath := ""
-
-	// Download background music if available
-	if job.AudioURL != "" {
-		musicPath = filepath.Join(tmpDir, "background-music.mp3")
-		musicS3Key := extractS3Key(job.AudioURL)
-		if err := h.s3Service.DownloadFile(ctx, h.assetsBucket, musicS3Key, musicPath); err != nil {
-			h.logger.Warn("Failed to download background music, continuing without it",
-				zap.String("job_id", jobID),
-				zap.Error(err),
-			)
-			musicPath = ""
-		}
-	}
-
-	// Download narrator audio if available
-	if job.NarratorAudioURL != "" {
-		narratorPath = filepath.Join(tmpDir, "narrator-voiceover.mp3")
-		narratorS3Key := extractS3Key(job.NarratorAudioURL)
-		if err := h.s3Service.DownloadFile(ctx, h.assetsBucket, narratorS3Key, narratorPath); err != nil {
-			h.logger.Warn("Failed to download narrator audio, continuing without it",
-				zap.String("job_id", jobID),
-				zap.Error(err),
-			)
-			narratorPath = ""
-		}
-	}
-
-	// Mux audio into video if we have any audio tracks
-	if musicPath != "" || narratorPath != "" {
-		videoWithAudio := filepath.Join(tmpDir, "video_with_audio.mp4")
-
-		var muxErr error
-		if musicPath != "" && narratorPath != "" {
-			// Both audio tracks - mix them together
-			muxErr = h.muxVideoWithMixedAudio(ctx, jobID, finalVideo, musicPath, narratorPath, videoWithAudio)
-		} else if narratorPath != "" {
-			// Narrator only
-			muxErr = h.muxVideoWithSingleAudio(ctx, jobID, finalVideo, narratorPath, 1.0, videoWithAudio)
-		} else {
-			// Music only
-			muxErr = h.muxVideoWithSingleAudio(ctx, jobID, finalVideo, musicPath, 0.3, videoWithAudio)
-		}
-
-		if muxErr != nil {
-			h.logger.Warn("Audio muxing failed, continuing with video-only output",
-				zap.String("job_id", jobID),
-				zap.Error(muxErr),
-			)
-			// Keep finalVideo as-is (no audio)
-		} else {
-			h.logger.Info("Audio muxing complete",
-				zap.String("job_id", jobID),
-				zap.Bool("has_music", musicPath != ""),
-				zap.Bool("has_narrator", narratorPath != ""),
-			)
-			finalVideo = videoWithAudio
-		}
-	}
-
-	// Upload final MP4 video to S3
-	h.logger.Info("Uploading final MP4 video to S3",
-		zap.String("job_id", jobID),
-	)
-	mp4S3Key := buildFinalVideoKey(userID, jobID)
-	_, err = h.s3Service.UploadFile(ctx, h.assetsBucket, mp4S3Key, finalVideo, "video/mp4")
-	if err != nil {
-		return "", "", fmt.Errorf("failed to upload MP4 video: %w", err)
-	}
-
-	// Transcode to WebM (VP9) for web-optimized delivery
-	h.logger.Info("Transcoding to WebM format",
-		zap.String("job_id", jobID),
-	)
-	webmVideo := filepath.Join(tmpDir, "final.webm")
-	cmd = exec.CommandContext(ctx, "ffmpeg",
-		"-i", finalVideo,
-		"-c:v", "libvpx-vp9",
-		"-c:a", "libopus", // Use Opus for WebM audio
-		"-b:a", "128k",
-		"-crf", "30",
-		"-b:v", "0",
-		"-row-mt", "1",
-		"-y", webmVideo,
-	)
-
-	var webmS3Key string
-	if output, err := cmd.CombinedOutput(); err != nil {
-		h.logger.Warn("WebM transcode failed, MP4 still available",
-			zap.String("job_id", jobID),
-			zap.String("output", string(output)),
-			zap.Error(err),
-		)
-		// Don't fail - MP4 is still available
-	} else {
-		// Upload WebM to S3
-		webmS3Key = buildFinalWebMKey(userID, jobID)
-		_, err = h.s3Service.UploadFile(ctx, h.assetsBucket, webmS3Key, webmVideo, "video/webm")
-		if err != nil {
-			h.logger.Warn("Failed to upload WebM, MP4 still available",
-				zap.String("job_id", jobID),
-				zap.Error(err),
-			)
-			webmS3Key = "" // Clear key since upload failed
-		} else {
-			h.logger.Info("WebM uploaded successfully",
-				zap.String("job_id", jobID),
-				zap.String("webm_key", webmS3Key),
-			)
-		}
-	}
-
-	h.logger.Info("Video composition complete",
-		zap.String("job_id", jobID),
-		zap.String("mp4_key", mp4S3Key),
-		zap.String("webm_key", webmS3Key),
-	)
-
-	return mp4S3Key, webmS3Key, nil
-}
-
-// muxVideoWithMixedAudio combines video with two audio tracks (music at 30%, narrator at 100%)
-func (h *GenerateHandler) muxVideoWithMixedAudio(
-	ctx context.Context,
-	jobID string,
-	videoPath string,
-	musicPath string,
-	narratorPath string,
-	outputPath string,
-) error {
-	h.logger.Info("Muxing video with mixed audio (music + narrator)",
-		zap.String("job_id", jobID),
-	)
-
-	// Mix music at 30% volume with narrator at 100%, then mux with video
-	// Uses amix filter to combine audio streams
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-i", videoPath,
-		"-i", musicPath,
-		"-i", narratorPath,
-		"-filter_complex", "[1:a]volume=0.3[music];[2:a]volume=1.0[narrator];[music][narrator]amix=inputs=2:duration=longest[audio]",
-		"-map", "0:v",
-		"-map", "[audio]",
-		"-c:v", "copy",
-		"-c:a", "aac",
-		"-b:a", "192k",
-		"-shortest",
-		"-y", outputPath,
-	)
-
-	if output, err := cmd.CombinedOutput(); err != nil {
-		h.logger.Error("ffmpeg audio mux failed",
-			zap.String("job_id", jobID),
-			zap.String("output", string(output)),
-			zap.Error(err),
-		)
-		return fmt.Errorf("ffmpeg audio mux failed: %w", err)
-	}
-
-	return nil
-}
-
-// muxVideoWithSingleAudio combines video with a single audio track at specified volume
-func (h *GenerateHandler) muxVideoWithSingleAudio(
-	ctx context.Context,
-	jobID string,
-	videoPath string,
-	audioPath string,
-	volume float64,
-	outputPath string,
-) error {
-	h.logger.Info("Muxing video with single audio track",
-		zap.String("job_id", jobID),
-		zap.Float64("volume", volume),
-	)
-
-	volumeFilter := fmt.Sprintf("volume=%.1f", volume)
-
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-i", videoPath,
-		"-i", audioPath,
-		"-af", volumeFilter,
-		"-map", "0:v",
-		"-map", "1:a",
-		"-c:v", "copy",
-		"-c:a", "aac",
-		"-b:a", "192k",
-		"-shortest",
-		"-y", outputPath,
-	)
-
-	if output, err := cmd.CombinedOutput(); err != nil {
-		h.logger.Error("ffmpeg single audio mux failed",
-			zap.String("job_id", jobID),
-			zap.String("output", string(output)),
-			zap.Error(err),
-		)
-		return fmt.Errorf("ffmpeg single audio mux failed: %w", err)
-	}
-
-	return nil
+	return composeOnce(ctx, h.s3Service, jobAssetBucket(job, h.assetsBucket), h.locks, h.log(ctx), job, clips, func(comp composition.Plan) (string, string, error) {
+		return h.composer.ComposeFinalVideo(ctx, job, clips, comp)
+	})
 }
 
 // downloadFile downloads a file from URL to local path
 func (h *GenerateHandler) downloadFile(ctx context.Context, url string, destPath string) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
-	}
-
-	out, err := os.Create(destPath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
-
-// extractS3Key extracts the S3 key from an S3 URL
-func extractS3Key(s3URL string) string {
-	// Strip query parameters first (handles presigned URLs stored in DB)
-	// Check for both literal "?" and URL-encoded "%3F"
-	if idx := strings.Index(s3URL, "?"); idx != -1 {
-		s3URL = s3URL[:idx]
-	}
-	if idx := strings.Index(s3URL, "%3F"); idx != -1 {
-		s3URL = s3URL[:idx]
-	}
-	if idx := strings.Index(s3URL, "%3f"); idx != -1 {
-		s3URL = s3URL[:idx]
-	}
-
-	// Extract key from URL like https://bucket.s3.amazonaws.com/key or https://s3.amazonaws.com/bucket/key
-	parts := strings.Split(s3URL, "/")
-	if len(parts) < 4 {
-		return s3URL
-	}
-
-	// Find where the key starts (after bucket name)
-	for i, part := range parts {
-		if strings.HasSuffix(part, ".amazonaws.com") {
-			if i+1 < len(parts) {
-				return strings.Join(parts[i+1:], "/")
-			}
-		}
-	}
-
-	// Fallback: return everything after the first 3 slashes
-	return strings.Join(parts[3:], "/")
-}
-
-func probeVideoDimensions(videoPath string) (int, int, error) {
-	cmd := exec.Command(
-		"ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=width,height",
-		"-of", "csv=p=0:s=x",
-		videoPath,
-	)
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, 0, fmt.Errorf("ffprobe failed: %w", err)
-	}
-
-	var width, height int
-	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%dx%d", &width, &height); err != nil {
-		return 0, 0, fmt.Errorf("failed to parse ffprobe dimensions: %w", err)
-	}
-	return width, height, nil
-}
-
-// probeVideoFPS returns the frame rate of a video file as a float64.
-// Returns 0 on error (caller should handle gracefully).
-func probeVideoFPS(videoPath string) float64 {
-	cmd := exec.Command(
-		"ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=r_frame_rate",
-		"-of", "default=noprint_wrappers=1:nokey=1",
-		videoPath,
-	)
-	output, err := cmd.Output()
-	if err != nil {
-		return 0
-	}
-
-	// Output format is "num/den" (e.g., "24/1" or "30000/1001")
-	fpsStr := strings.TrimSpace(string(output))
-	parts := strings.Split(fpsStr, "/")
-	if len(parts) != 2 {
-		return 0
-	}
-
-	num, err := strconv.ParseFloat(parts[0], 64)
-	if err != nil {
-		return 0
-	}
-	den, err := strconv.ParseFloat(parts[1], 64)
-	if err != nil || den == 0 {
-		return 0
-	}
-
-	return num / den
+	return composition.DownloadFile(ctx, h.log(ctx), url, destPath)
 }
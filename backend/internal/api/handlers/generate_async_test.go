@@ -1,10 +1,29 @@
 package handlers
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/pkg/retry"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
 
 func TestS3KeyGenerationHelpers(t *testing.T) {
-	userID := "user123"
-	jobID := "job456"
+	job := &domain.Job{UserID: "user123", JobID: "job456"}
+	prefixed := &domain.Job{UserID: "user123", JobID: "job456", AssetBucket: "tenant-assets", AssetPrefix: "env/staging/"}
 
 	testCases := []struct {
 		name string
@@ -13,33 +32,48 @@ func TestS3KeyGenerationHelpers(t *testing.T) {
 	}{
 		{
 			name: "scene clip key",
-			got:  buildSceneClipKey(userID, jobID, 3),
+			got:  composition.SceneClipKey(job, 3),
 			want: "users/user123/jobs/job456/clips/scene-003.mp4",
 		},
 		{
 			name: "scene thumbnail key",
-			got:  buildSceneThumbnailKey(userID, jobID, 5),
+			got:  composition.SceneThumbnailKey(job, 5),
 			want: "users/user123/jobs/job456/thumbnails/scene-005.jpg",
 		},
 		{
 			name: "job thumbnail key",
-			got:  buildJobThumbnailKey(userID, jobID),
-			want: "users/user123/jobs/job456/thumbnails/job-thumbnail.jpg",
+			got:  buildJobThumbnailKey(job, 320, "jpg"),
+			want: "users/user123/jobs/job456/thumbnails/job-thumbnail-320.jpg",
+		},
+		{
+			name: "webp job thumbnail key",
+			got:  buildJobThumbnailKey(job, 1280, "webp"),
+			want: "users/user123/jobs/job456/thumbnails/job-thumbnail-1280.webp",
 		},
 		{
 			name: "background music key",
-			got:  buildAudioKey(userID, jobID),
+			got:  composition.AudioKey(job),
 			want: "users/user123/jobs/job456/audio/background-music.mp3",
 		},
 		{
 			name: "narrator audio key",
-			got:  buildNarratorAudioKey(userID, jobID),
+			got:  composition.NarratorAudioKey(job),
 			want: "users/user123/jobs/job456/audio/narrator-voiceover.mp3",
 		},
 		{
 			name: "final video key",
-			got:  buildFinalVideoKey(userID, jobID),
-			want: "users/user123/jobs/job456/final/video.mp4",
+			got:  composition.FinalVideoKey(job, "0123abcd"),
+			want: "users/user123/jobs/job456/final/video-0123abcd.mp4",
+		},
+		{
+			name: "prefixed scene clip key",
+			got:  composition.SceneClipKey(prefixed, 3),
+			want: "env/staging/users/user123/jobs/job456/clips/scene-003.mp4",
+		},
+		{
+			name: "prefixed final video key",
+			got:  composition.FinalVideoKey(prefixed, "0123abcd"),
+			want: "env/staging/users/user123/jobs/job456/final/video-0123abcd.mp4",
 		},
 	}
 
@@ -51,3 +85,277 @@ func TestS3KeyGenerationHelpers(t *testing.T) {
 		})
 	}
 }
+
+func TestFailureMessage(t *testing.T) {
+	const failed = "Scene generation failed."
+	tests := []struct {
+		name      string
+		err       error
+		want      string
+		retryable bool
+	}{
+		{
+			name: "insufficient credits",
+			err:  retry.NewNonRetryableError(&adapters.InsufficientCreditsError{Provider: adapters.ProviderReplicate, StatusCode: 402, Body: "{}"}),
+			want: "Scene generation failed. (Insufficient Replicate API credits. Please check your Replicate account balance and billing settings.)",
+		},
+		{
+			name: "exhausted OpenAI quota",
+			err:  fmt.Errorf("script generation failed: %w", &adapters.InsufficientCreditsError{Provider: adapters.ProviderOpenAI, StatusCode: 429}),
+			want: "Scene generation failed. (Insufficient OpenAI API credits. Please check your OpenAI account balance and billing settings.)",
+		},
+		{
+			name: "content policy",
+			err:  &adapters.ContentPolicyError{Provider: adapters.ProviderReplicate, Reason: "flagged as sensitive (E005)"},
+			want: "Scene generation failed. (Replicate rejected the content under its content policy. Please revise your prompts and try again.)",
+		},
+		{
+			name: "prompt over token budget",
+			err:  fmt.Errorf("script generation failed: %w", &adapters.PromptTooLargeError{Call: "script", EstimatedTokens: 90000, Limit: 100000}),
+			want: "Scene generation failed. (The prompt is too large for the job's token budget. Please shorten the brand guidelines or prompt and try again.)",
+		},
+		{
+			name:      "timeout",
+			err:       fmt.Errorf("scene 2: %w", &adapters.TimeoutError{Provider: adapters.ProviderReplicate, Err: adapters.ErrPollTimeout}),
+			want:      "Scene generation failed. (Replicate request timed out. The service may be busy. Please try again.)",
+			retryable: true,
+		},
+		{
+			name: "rejected input",
+			err:  &adapters.APIError{Provider: adapters.ProviderReplicate, StatusCode: 422, Body: ` {"detail": "duration must be 4, 6 or 8"} `},
+			want: `Scene generation failed. (Replicate API Error: HTTP 422 - {"detail": "duration must be 4, 6 or 8"})`,
+		},
+		{
+			name: "unauthorized",
+			err:  &adapters.APIError{Provider: adapters.ProviderElevenLabs, StatusCode: 401, Body: "invalid_api_key: Invalid API key"},
+			want: "Scene generation failed. (Authentication with ElevenLabs failed. Please check API configuration.)",
+		},
+		{
+			name:      "rate limited",
+			err:       &adapters.APIError{Provider: adapters.ProviderOpenAI, StatusCode: 429, Body: "rate limit exceeded"},
+			want:      "Scene generation failed. (OpenAI rate limit exceeded. Please wait a moment and try again.)",
+			retryable: true,
+		},
+		{
+			name:      "server error",
+			err:       fmt.Errorf("max retries exceeded (3 attempts): %w", &adapters.APIError{Provider: adapters.ProviderReplicate, StatusCode: 503}),
+			want:      "Scene generation failed. (Replicate API Error: HTTP 503. The service may be busy. Please try again.)",
+			retryable: true,
+		},
+		{
+			name: "other client error",
+			err:  &adapters.APIError{Provider: adapters.ProviderReplicate, StatusCode: 404, Body: "model not found"},
+			want: "Scene generation failed. (Replicate API Error: HTTP 404)",
+		},
+		{
+			name: "legacy payment error",
+			err:  errors.New("OpenAI API error (status 402): Payment required"),
+			want: "Scene generation failed. (Insufficient OpenAI API credits. Please check your OpenAI account balance and billing settings.)",
+		},
+		{
+			name: "legacy status error",
+			err:  errors.New("API error: status 500, body: upstream"),
+			want: "Scene generation failed. (API Error: HTTP 500)",
+		},
+		{
+			name: "untyped error",
+			err:  errors.New("no clip in response"),
+			want: "Scene generation failed. (Error: no clip in response)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureMessage(failed, tt.err); got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			if got := adapters.IsRetryable(tt.err); got != tt.retryable {
+				t.Errorf("retryable = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+
+	if got := failureMessage(failed, nil); got != failed {
+		t.Errorf("message without an error = %q, want the user message", got)
+	}
+}
+
+// resizingVideoAdapter returns clipURL for every request, and calls onRequest with the request's
+// number (from 1) first so a test can change what the clip probes as
+type resizingVideoAdapter struct {
+	clipURL   string
+	onRequest func(n int)
+	requests  []*adapters.VideoGenerationRequest
+}
+
+func (a *resizingVideoAdapter) GenerateVideo(ctx context.Context, req *adapters.VideoGenerationRequest) (*adapters.VideoGenerationResult, error) {
+	a.requests = append(a.requests, req)
+	a.onRequest(len(a.requests))
+	return &adapters.VideoGenerationResult{VideoURL: a.clipURL, Status: "succeeded"}, nil
+}
+
+func (a *resizingVideoAdapter) GetStatus(ctx context.Context, predictionID string) (*adapters.VideoGenerationResult, error) {
+	return nil, errors.New("not polled")
+}
+
+func (a *resizingVideoAdapter) GetModelName() string { return "veo" }
+
+func (a *resizingVideoAdapter) GetCostPerSecond() float64 { return 0 }
+
+func TestGenerateClip_RegeneratesWrongAspectRatioOnce(t *testing.T) {
+	pinned := int64(42)
+	tests := []struct {
+		name         string
+		policy       composition.ClipAspectPolicy
+		seed         *int64
+		retrySize    [2]int // What the second request's clip probes as
+		wantRequests int
+		wantNormal   bool // Whether the kept clip was normalized
+	}{
+		{name: "retry has the right shape", policy: composition.ClipAspectRegenerate, retrySize: [2]int{1080, 1920}, wantRequests: 2},
+		{name: "retry is no better", policy: composition.ClipAspectRegenerate, retrySize: [2]int{1920, 1080}, wantRequests: 2, wantNormal: true},
+		{name: "pinned seed would repeat the clip", policy: composition.ClipAspectRegenerate, seed: &pinned, wantRequests: 1, wantNormal: true},
+		{name: "normalize policy", policy: composition.ClipAspectNormalize, wantRequests: 1, wantNormal: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &recordingRunner{fps: "24/1", clipDuration: 8}
+			adapter := &resizingVideoAdapter{clipURL: servedClip(t), onRequest: func(n int) {
+				runner.width, runner.height = 1920, 1080
+				if n > 1 {
+					runner.width, runner.height = tt.retrySize[0], tt.retrySize[1]
+				}
+			}}
+			h := &GenerateHandler{
+				clipAspectPolicy: tt.policy,
+				composer:         composition.NewService(newComposeTestS3(t), "assets", 0, nil, nil, composition.ClipQualityGate{}, zap.NewNop()).WithRunner(runner),
+				logger:           zap.NewNop(),
+			}
+			job := &domain.Job{JobID: fmt.Sprintf("job-clip-retry-%d", i), UserID: "user-1", AspectRatio: domain.AspectRatio9x16}
+			scene := domain.Scene{SceneNumber: 1, Duration: 8, GenerationPrompt: storedScenePrompt, Seed: tt.seed}
+
+			clip, err := h.generateClip(context.Background(), adapter, job, scene, job.AspectRatio, 1)
+			require.NoError(t, err)
+			require.Contains(t, clip.VideoURL, composition.SceneClipKey(job, 1))
+			require.Len(t, adapter.requests, tt.wantRequests)
+			if tt.wantRequests > 1 {
+				require.NotEqual(t, *adapter.requests[0].Seed, *adapter.requests[1].Seed, "the retry is a new sample")
+			}
+			require.Equal(t, tt.wantNormal, slices.Contains(runner.outputs(), "video-normalized.mp4"))
+		})
+	}
+}
+
+// servedClip serves a placeholder clip for the download
+func servedClip(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("generated clip"))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// readFrameStats returns ffmpeg output captured in testdata
+func readFrameStats(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+// frameStatsRunner answers the quality check's ffmpeg run with the captured output for the
+// downloaded clip: clips whose body is "black clip" measure as black
+type frameStatsRunner struct {
+	*recordingRunner
+	t *testing.T
+}
+
+func (r *frameStatsRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	measuresFrames := slices.ContainsFunc(cmd.Args, func(arg string) bool { return strings.Contains(arg, "signalstats") })
+	if !measuresFrames {
+		return r.recordingRunner.CombinedOutput(cmd)
+	}
+	clip, err := os.ReadFile(cmd.Args[slices.Index(cmd.Args, "-i")+1])
+	require.NoError(r.t, err)
+	if string(clip) == "black clip" {
+		return readFrameStats(r.t, "frame-stats-black.txt"), nil
+	}
+	return readFrameStats(r.t, "frame-stats-good.txt"), nil
+}
+
+// servedClips serves each of bodies as a clip of its own, returning their URLs in order
+func servedClips(t *testing.T, bodies ...string) []string {
+	urls := make([]string, len(bodies))
+	for i, body := range bodies {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	return urls
+}
+
+// sequenceVideoAdapter returns the clip URLs in turn, one per request
+type sequenceVideoAdapter struct {
+	clipURLs []string
+	requests []*adapters.VideoGenerationRequest
+}
+
+func (a *sequenceVideoAdapter) GenerateVideo(ctx context.Context, req *adapters.VideoGenerationRequest) (*adapters.VideoGenerationResult, error) {
+	a.requests = append(a.requests, req)
+	if len(a.requests) > len(a.clipURLs) {
+		return nil, errors.New("no more clips")
+	}
+	return &adapters.VideoGenerationResult{VideoURL: a.clipURLs[len(a.requests)-1], Status: "succeeded"}, nil
+}
+
+func (a *sequenceVideoAdapter) GetStatus(ctx context.Context, predictionID string) (*adapters.VideoGenerationResult, error) {
+	return nil, errors.New("not polled")
+}
+
+func (a *sequenceVideoAdapter) GetModelName() string { return "veo" }
+
+func (a *sequenceVideoAdapter) GetCostPerSecond() float64 { return 0 }
+
+func TestGenerateClip_RegeneratesRejectedClipOnce(t *testing.T) {
+	pinned := int64(42)
+	tests := []struct {
+		name    string
+		clips   []string
+		seed    *int64
+		wantErr bool
+	}{
+		{name: "black clip then a good one", clips: []string{"black clip", "generated clip"}},
+		{name: "pinned seed is retried too", clips: []string{"black clip", "generated clip"}, seed: &pinned},
+		{name: "retry is no better", clips: []string{"black clip", "black clip"}, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &recordingRunner{fps: "24/1", clipDuration: 8, width: 1280, height: 720}
+			adapter := &sequenceVideoAdapter{clipURLs: servedClips(t, tt.clips...)}
+			h := &GenerateHandler{
+				composer: composition.NewService(newComposeTestS3(t), "assets", 0, nil, nil,
+					composition.ClipQualityGate{BlackLuminance: 20, MinMotion: 0.5}, zap.NewNop()).
+					WithRunner(&frameStatsRunner{recordingRunner: runner, t: t}),
+				logger: zap.NewNop(),
+			}
+			job := &domain.Job{JobID: fmt.Sprintf("job-clip-quality-%d", i), UserID: "user-1", AspectRatio: domain.AspectRatio16x9}
+			scene := domain.Scene{SceneNumber: 1, Duration: 8, GenerationPrompt: storedScenePrompt, Seed: tt.seed}
+
+			clip, err := h.generateClip(context.Background(), adapter, job, scene, job.AspectRatio, 1)
+			require.Len(t, adapter.requests, 2, "a rejected clip is requested once more")
+			require.NotEqual(t, *adapter.requests[0].Seed, *adapter.requests[1].Seed, "the retry is a new sample")
+			if tt.wantErr {
+				var rejected *composition.ClipQualityError
+				require.True(t, errors.As(err, &rejected), "got %v", err)
+				require.Equal(t, "black", rejected.Reason)
+				require.Empty(t, runner.outputs(), "nothing is kept of a rejected clip")
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, clip.VideoURL, composition.SceneClipKey(job, 1))
+			require.Equal(t, []string{"last_frame.jpg"}, runner.outputs(), "only the accepted clip is processed")
+		})
+	}
+}
@@ -10,11 +10,22 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/omnigen/backend/internal/auth"
-	backenderrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/omnigen/backend/internal/validation"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
+// validationErrorResponse decodes the 422 body with typed field errors
+type validationErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details struct {
+			Errors validation.Errors `json:"errors"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
 func TestGenerateValidationErrors(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -95,6 +106,15 @@ func TestGenerateValidationErrors(t *testing.T) {
 			expectedMessage: "Duration must be between 10-60 seconds and achievable with 4, 6, or 8 second clips",
 			expectedField:   "duration",
 		},
+		{
+			name: "duration not achievable with Kling clips",
+			mutate: func(payload map[string]interface{}) {
+				payload["model"] = "kling"
+				payload["duration"] = 12
+			},
+			expectedMessage: "Duration must be between 10-60 seconds and achievable with 5 or 10 second clips",
+			expectedField:   "duration",
+		},
 	}
 
 	for _, tc := range tests {
@@ -115,19 +135,76 @@ func TestGenerateValidationErrors(t *testing.T) {
 
 			handler.Generate(c)
 
-			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code)
 
-			var resp backenderrors.ErrorResponse
+			var resp validationErrorResponse
 			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
-			require.NotNil(t, resp.Error)
+			require.Equal(t, "VALIDATION_FAILED", resp.Error.Code)
 			require.Equal(t, tc.expectedMessage, resp.Error.Message)
-
-			if tc.expectedField != "" {
-				require.NotNil(t, resp.Error.Details)
-				field, ok := resp.Error.Details["field"].(string)
-				require.True(t, ok, "expected field detail to be a string")
-				require.Equal(t, tc.expectedField, field)
-			}
+			require.Len(t, resp.Error.Details.Errors, 1)
+			require.Equal(t, tc.expectedField, resp.Error.Details.Errors[0].Field)
+			require.Equal(t, tc.expectedMessage, resp.Error.Details.Errors[0].Message)
 		})
 	}
 }
+
+func TestGenerateReportsAllValidationErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := &GenerateHandler{
+		logger: zap.NewNop(),
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"prompt":       "short",
+		"duration":     33,
+		"aspect_ratio": "4:3",
+		"tempo":        "presto",
+	})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req, err := http.NewRequest(http.MethodPost, "/api/v1/generate", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	c.Set(auth.UserIDKey, "user-123")
+
+	handler.Generate(c)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var resp validationErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	fields := make([]string, 0, len(resp.Error.Details.Errors))
+	for _, fe := range resp.Error.Details.Errors {
+		fields = append(fields, fe.Field)
+	}
+	require.Equal(t, []string{"prompt", "aspect_ratio", "duration", "tempo"}, fields)
+
+	tempo, ok := resp.Error.Details.Errors.Field("tempo")
+	require.True(t, ok)
+	require.Equal(t, []string{"slow", "medium", "fast"}, tempo.AllowedValues)
+}
+
+func TestGenerateMalformedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := &GenerateHandler{
+		logger: zap.NewNop(),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req, err := http.NewRequest(http.MethodPost, "/api/v1/generate", strings.NewReader(`{"duration": "thirty"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	c.Set(auth.UserIDKey, "user-123")
+
+	handler.Generate(c)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -8,9 +8,12 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/validation"
 	"github.com/omnigen/backend/pkg/errors"
 	"go.uber.org/zap"
 )
@@ -105,11 +108,10 @@ func (h *GenerateTitleHandler) GenerateTitle(c *gin.Context) {
 
 	// Check if OpenAI key is available
 	if h.openAIKey == "" {
-		h.logger.Warn("OpenAI key not configured, using mock title")
-		// Return a mock title for local testing
-		mockTitle := generateMockTitle(req.Prompt)
+		h.logger.Warn("OpenAI key not configured, deriving title from prompt")
+		// Derive one from the prompt, as jobs without a script title do
 		c.JSON(http.StatusOK, GenerateTitleResponse{
-			Title: mockTitle,
+			Title: fallbackTitle(req.Prompt),
 		})
 		return
 	}
@@ -244,33 +246,44 @@ Return ONLY the title text, nothing else. No quotes, no explanations, just the t
 	})
 }
 
-// generateMockTitle generates a simple mock title for local testing
-func generateMockTitle(prompt string) string {
-	// Extract first few words and create a simple title
-	words := strings.Fields(prompt)
+// fallbackTitleWords is how much of the prompt a fallback title keeps
+const fallbackTitleWords = 8
+
+// fallbackTitle derives a title from the first words of a prompt, for jobs without a generated
+// one. Punctuation around words and characters that can't be displayed are dropped.
+func fallbackTitle(prompt string) string {
+	var words []string
+	for _, field := range strings.Fields(prompt) {
+		word := strings.TrimFunc(strings.Map(printableRune, field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if word == "" {
+			continue
+		}
+		words = append(words, word)
+		if len(words) == fallbackTitleWords {
+			break
+		}
+	}
 	if len(words) == 0 {
 		return "Untitled Video"
 	}
 
-	// Take first 3-5 words and capitalize
-	titleWords := words[:min(5, len(words))]
-	title := strings.Join(titleWords, " ")
-
-	// Capitalize first letter of each word
-	titleParts := strings.Fields(title)
-	for i, part := range titleParts {
-		if len(part) > 0 {
-			titleParts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
-		}
+	title := strings.Join(words, " ")
+	first, size := utf8.DecodeRuneInString(title)
+	title = string(unicode.ToUpper(first)) + title[size:]
+	if runes := []rune(title); len(runes) > validation.MaxTitleLength {
+		title = strings.TrimSpace(string(runes[:validation.MaxTitleLength-3])) + "..."
 	}
-	title = strings.Join(titleParts, " ")
+	return title
+}
 
-	// Ensure max 60 characters
-	if len(title) > 60 {
-		title = title[:57] + "..."
+// printableRune drops control and format characters, for use with strings.Map
+func printableRune(r rune) rune {
+	if !unicode.IsPrint(r) {
+		return -1
 	}
-
-	return title
+	return r
 }
 
 func min(a, b int) int {
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFallbackTitle(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"launch ad for a cold brew coffee brand", "Launch ad for a cold brew coffee brand"},
+		{"a sunrise run through the city for a new sneaker launch", "A sunrise run through the city for a"},
+		{`"Restura" — relief, finally!! Talk to your doctor`, "Restura relief finally Talk to your doctor"},
+		{"café​ au\tlait\x07 ads", "Café au lait ads"},
+		{"don't over-think it", "Don't over-think it"},
+		{"  *** --- !!! ", "Untitled Video"},
+		{"", "Untitled Video"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, fallbackTitle(tt.prompt), tt.prompt)
+	}
+
+	long := fallbackTitle(strings.Repeat("supercalifragilistic ", 8))
+	require.LessOrEqual(t, utf8.RuneCountInString(long), validation.MaxTitleLength)
+	require.True(t, strings.HasSuffix(long, "..."))
+}
+
+func TestEmbedScript_FallsBackToPromptTitle(t *testing.T) {
+	h := &GenerateHandler{logger: zap.NewNop()}
+
+	job := &domain.Job{Prompt: "launch ad for a cold brew coffee brand"}
+	h.embedScript(t.Context(), job, &domain.Script{Scenes: []domain.Scene{{SceneNumber: 1}}})
+	require.Equal(t, "Launch ad for a cold brew coffee brand", job.Title)
+
+	job = &domain.Job{Prompt: "launch ad", Title: "Launch ad"}
+	h.embedScript(t.Context(), job, &domain.Script{Title: "Cold Brew Mornings"})
+	require.Equal(t, "Cold Brew Mornings", job.Title)
+
+	job = &domain.Job{Prompt: "launch ad", Title: "Sunrise 10K", TitleEdited: true}
+	h.embedScript(t.Context(), job, &domain.Script{Title: "Cold Brew Mornings"})
+	require.Equal(t, "Sunrise 10K", job.Title, "a renamed job keeps its title")
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/health"
 	"github.com/omnigen/backend/internal/repository"
 	"go.uber.org/zap"
 )
@@ -14,22 +15,63 @@ import (
 type HealthHandler struct {
 	jobRepo   *repository.DynamoDBRepository
 	s3Service *repository.S3AssetRepository
+	readiness *health.Checker
 	logger    *zap.Logger
 }
 
-// NewHealthHandler creates a new health handler
+// NewHealthHandler creates a new health handler. readiness runs the checks behind GET /readyz.
 func NewHealthHandler(
 	jobRepo *repository.DynamoDBRepository,
 	s3Service *repository.S3AssetRepository,
+	readiness *health.Checker,
 	logger *zap.Logger,
 ) *HealthHandler {
 	return &HealthHandler{
 		jobRepo:   jobRepo,
 		s3Service: s3Service,
+		readiness: readiness,
 		logger:    logger,
 	}
 }
 
+// Liveness handles GET /healthz: the process is up and serving requests. It checks no
+// dependencies, so a dependency outage doesn't get healthy servers restarted.
+// @Summary Liveness check
+// @Description Report that the API process is up
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness handles GET /readyz: whether every dependency a job needs is reachable. Reports
+// are cached for a few seconds, so frequent load balancer polling is cheap.
+// @Summary Readiness check
+// @Description Check ffmpeg, ffprobe, DynamoDB, S3 and (optionally) Replicate, with each dependency's status
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.readiness.Check(c.Request.Context())
+	if !report.Ready() {
+		for name, check := range report.Checks {
+			if check.Status != health.StatusOK {
+				h.logger.Warn("Readiness check failed",
+					zap.String("dependency", name),
+					zap.String("error", check.Error),
+				)
+			}
+		}
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status    string            `json:"status"`
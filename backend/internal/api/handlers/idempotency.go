@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry POST /generate without creating a second job
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses that return the job of an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// idempotencyKeyTTL is how long a key maps to its job
+	idempotencyKeyTTL = 24 * time.Hour
+
+	// idempotencyPendingWindow is how long a key whose job doesn't exist yet is treated as
+	// belonging to a request still in flight. After it, the first request is assumed to have
+	// died before creating its job and the key is taken over.
+	idempotencyPendingWindow = time.Minute
+
+	maxIdempotencyKeyLength = 255
+)
+
+var (
+	errIdempotencyKeyConflict = stderrors.New("idempotency key used with a different request")
+	errIdempotencyKeyPending  = stderrors.New("idempotency key request in progress")
+)
+
+// idempotencyJobStore is the subset of the job repository the idempotency guard needs
+type idempotencyJobStore interface {
+	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
+}
+
+// idempotencyGuard maps (user, Idempotency-Key) to the job the key's first request created
+type idempotencyGuard struct {
+	store  repository.IdempotencyRepository
+	jobs   idempotencyJobStore
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+func newIdempotencyGuard(store repository.IdempotencyRepository, jobs idempotencyJobStore, logger *zap.Logger) *idempotencyGuard {
+	return &idempotencyGuard{
+		store:  store,
+		jobs:   jobs,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// validIdempotencyKey reports whether a client key is 1-255 printable ASCII characters
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint hashes the decoded request, so retries that re-encode the same body
+// (different key order or whitespace) still match
+func requestFingerprint(req GenerateRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// reserve claims key for jobID before any work starts. When an earlier request with the same
+// key and body created a job, that job is returned instead. It fails with
+// errIdempotencyKeyConflict for a different body and errIdempotencyKeyPending while the
+// earlier request hasn't created its job yet.
+func (g *idempotencyGuard) reserve(ctx context.Context, userID, key, fingerprint, jobID string) (*domain.Job, error) {
+	now := g.now()
+	record := &domain.IdempotencyRecord{
+		Key:         repository.IdempotencyRecordKey(userID, key),
+		UserID:      userID,
+		JobID:       jobID,
+		RequestHash: fingerprint,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.Add(idempotencyKeyTTL).Unix(),
+	}
+
+	// A second attempt follows taking over the key of a request that died
+	for attempt := 0; attempt < 2; attempt++ {
+		existing, err := g.store.ReserveIdempotencyKey(ctx, record, now.Unix())
+		if err == nil {
+			return nil, nil
+		}
+		if !stderrors.Is(err, repository.ErrIdempotencyKeyInUse) {
+			return nil, err
+		}
+		if existing.RequestHash != fingerprint {
+			return nil, errIdempotencyKeyConflict
+		}
+
+		job, err := g.jobs.GetJob(ctx, existing.JobID)
+		if err == nil {
+			return job, nil
+		}
+		if !stderrors.Is(err, repository.ErrJobNotFound) {
+			return nil, err
+		}
+
+		if now.Sub(time.Unix(existing.CreatedAt, 0)) < idempotencyPendingWindow {
+			return nil, errIdempotencyKeyPending
+		}
+		g.logger.Warn("Taking over idempotency key whose request never created its job",
+			zap.String("user_id", userID),
+			zap.String("abandoned_job_id", existing.JobID),
+		)
+		if err := g.store.ReleaseIdempotencyKey(ctx, record.Key, existing.JobID); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errIdempotencyKeyPending
+}
+
+// release frees a key whose request failed before creating its job, so the client's retry
+// runs instead of waiting out the pending window
+func (g *idempotencyGuard) release(userID, key, jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := g.store.ReleaseIdempotencyKey(ctx, repository.IdempotencyRecordKey(userID, key), jobID); err != nil {
+		g.logger.Warn("Failed to release idempotency key",
+			zap.String("user_id", userID),
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+	}
+}
+
+// respondIdempotencyError reports a failed reservation
+func respondIdempotencyError(c *gin.Context, logger *zap.Logger, userID string, err error) {
+	switch {
+	case stderrors.Is(err, errIdempotencyKeyConflict):
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"Idempotency-Key was already used with a different request body", nil),
+		})
+	case stderrors.Is(err, errIdempotencyKeyPending):
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"A request with this Idempotency-Key is still in progress. Retry shortly.", nil),
+		})
+	default:
+		logger.Error("Failed to reserve idempotency key", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+	}
+}
+
+// respondReplayedJob returns the job created by an earlier request with the same key
+func respondReplayedJob(c *gin.Context, job *domain.Job) {
+	c.Header(IdempotentReplayedHeader, "true")
+	c.JSON(http.StatusOK, GenerateResponse{
+		JobID:               job.JobID,
+		Status:              job.Status,
+		NumClips:            len(job.Scenes),
+		CreatedAt:           job.CreatedAt,
+		EstimatedCompletion: EstimatedCompletionSeconds,
+		VariantJobIDs:       job.VariantJobIDs,
+	})
+}
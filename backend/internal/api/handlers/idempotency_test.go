@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeIdempotencyStore keeps records in memory, expiring them like the DynamoDB condition does
+type fakeIdempotencyStore struct {
+	records map[string]domain.IdempotencyRecord
+}
+
+func (s *fakeIdempotencyStore) ReserveIdempotencyKey(_ context.Context, record *domain.IdempotencyRecord, now int64) (*domain.IdempotencyRecord, error) {
+	if existing, ok := s.records[record.Key]; ok && existing.ExpiresAt > now {
+		return &existing, repository.ErrIdempotencyKeyInUse
+	}
+	s.records[record.Key] = *record
+	return nil, nil
+}
+
+func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, recordKey, jobID string) error {
+	if existing, ok := s.records[recordKey]; ok && existing.JobID == jobID {
+		delete(s.records, recordKey)
+	}
+	return nil
+}
+
+type fakeJobLookup map[string]*domain.Job
+
+func (f fakeJobLookup) GetJob(_ context.Context, jobID string) (*domain.Job, error) {
+	if job, ok := f[jobID]; ok {
+		return job, nil
+	}
+	return nil, repository.ErrJobNotFound
+}
+
+var idempotentRequestBody = []byte(`{"prompt":"A sunrise over a mountain lake","duration":20,"aspect_ratio":"16:9"}`)
+
+func idempotentRequestFingerprint(t *testing.T, body []byte) string {
+	t.Helper()
+	var req GenerateRequest
+	require.NoError(t, json.Unmarshal(body, &req))
+	return requestFingerprint(req)
+}
+
+// newIdempotencyTestHandler returns a handler whose user-1 already used "retry-1" for the
+// request in idempotentRequestBody, creating job-1 at createdAt
+func newIdempotencyTestHandler(t *testing.T, now time.Time, createdAt time.Time, jobs fakeJobLookup) (*GenerateHandler, *fakeIdempotencyStore) {
+	store := &fakeIdempotencyStore{records: map[string]domain.IdempotencyRecord{
+		repository.IdempotencyRecordKey("user-1", "retry-1"): {
+			Key:         repository.IdempotencyRecordKey("user-1", "retry-1"),
+			UserID:      "user-1",
+			JobID:       "job-1",
+			RequestHash: idempotentRequestFingerprint(t, idempotentRequestBody),
+			CreatedAt:   createdAt.Unix(),
+			ExpiresAt:   createdAt.Add(idempotencyKeyTTL).Unix(),
+		},
+	}}
+	guard := newIdempotencyGuard(store, jobs, zap.NewNop())
+	guard.now = func() time.Time { return now }
+	return &GenerateHandler{logger: zap.NewNop(), idempotency: guard}, store
+}
+
+func postGenerate(handler *GenerateHandler, userID, key string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/generate", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set(IdempotencyKeyHeader, key)
+	c.Set(auth.UserIDKey, userID)
+	handler.Generate(c)
+	return w
+}
+
+func TestGenerateIdempotencyReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Unix(1_700_000_000, 0)
+	jobs := fakeJobLookup{"job-1": {JobID: "job-1", UserID: "user-1", Status: domain.StatusProcessing, CreatedAt: now.Add(-time.Minute).Unix()}}
+	handler, _ := newIdempotencyTestHandler(t, now, now.Add(-time.Minute), jobs)
+
+	// Same body re-encoded with a different key order and spacing
+	retry := []byte(`{ "aspect_ratio": "16:9", "duration": 20, "prompt": "A sunrise over a mountain lake" }`)
+	w := postGenerate(handler, "user-1", "retry-1", retry)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	var resp GenerateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "job-1", resp.JobID)
+	require.Equal(t, domain.StatusProcessing, resp.Status)
+}
+
+func TestGenerateIdempotencyConflictingBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Unix(1_700_000_000, 0)
+	jobs := fakeJobLookup{"job-1": {JobID: "job-1", UserID: "user-1", Status: domain.StatusProcessing}}
+	handler, store := newIdempotencyTestHandler(t, now, now.Add(-time.Minute), jobs)
+
+	body := []byte(`{"prompt":"A sunset over a desert canyon","duration":20,"aspect_ratio":"16:9"}`)
+	w := postGenerate(handler, "user-1", "retry-1", body)
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, "job-1", store.records[repository.IdempotencyRecordKey("user-1", "retry-1")].JobID)
+}
+
+func TestGenerateIdempotencyInvalidKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := &GenerateHandler{logger: zap.NewNop()}
+
+	w := postGenerate(handler, "user-1", "has spaces", idempotentRequestBody)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIdempotencyGuardReserve(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	fingerprint := idempotentRequestFingerprint(t, idempotentRequestBody)
+	ctx := context.Background()
+
+	t.Run("keys are scoped per user", func(t *testing.T) {
+		handler, store := newIdempotencyTestHandler(t, now, now.Add(-time.Minute), fakeJobLookup{})
+		job, err := handler.idempotency.reserve(ctx, "user-2", "retry-1", fingerprint, "job-2")
+		require.NoError(t, err)
+		require.Nil(t, job)
+		require.Equal(t, "job-2", store.records[repository.IdempotencyRecordKey("user-2", "retry-1")].JobID)
+	})
+
+	t.Run("expired key starts a new job", func(t *testing.T) {
+		handler, store := newIdempotencyTestHandler(t, now, now.Add(-idempotencyKeyTTL-time.Second),
+			fakeJobLookup{"job-1": {JobID: "job-1", Status: domain.StatusCompleted}})
+		job, err := handler.idempotency.reserve(ctx, "user-1", "retry-1", fingerprint, "job-2")
+		require.NoError(t, err)
+		require.Nil(t, job, "an expired key must not replay its old job")
+		require.Equal(t, "job-2", store.records[repository.IdempotencyRecordKey("user-1", "retry-1")].JobID)
+	})
+
+	t.Run("first request still creating its job", func(t *testing.T) {
+		handler, _ := newIdempotencyTestHandler(t, now, now.Add(-time.Second), fakeJobLookup{})
+		_, err := handler.idempotency.reserve(ctx, "user-1", "retry-1", fingerprint, "job-2")
+		require.ErrorIs(t, err, errIdempotencyKeyPending)
+	})
+
+	t.Run("first request died before creating its job", func(t *testing.T) {
+		handler, store := newIdempotencyTestHandler(t, now, now.Add(-2*idempotencyPendingWindow), fakeJobLookup{})
+		job, err := handler.idempotency.reserve(ctx, "user-1", "retry-1", fingerprint, "job-2")
+		require.NoError(t, err)
+		require.Nil(t, job)
+		require.Equal(t, "job-2", store.records[repository.IdempotencyRecordKey("user-1", "retry-1")].JobID)
+	})
+
+	t.Run("failed request releases its key", func(t *testing.T) {
+		handler, store := newIdempotencyTestHandler(t, now, now.Add(-time.Second), fakeJobLookup{})
+		handler.idempotency.release("user-1", "retry-1", "job-1")
+		require.Empty(t, store.records)
+	})
+}
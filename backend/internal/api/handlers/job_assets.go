@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+)
+
+// jobAssetStore is the subset of the S3 repository needed to delete a job's assets
+type jobAssetStore interface {
+	DeletePrefix(ctx context.Context, bucket, prefix string) (int, error)
+	DeleteFile(ctx context.Context, bucket, key string) error
+}
+
+// jobAssetPrefix is the S3 prefix every asset generated for a job is stored under
+func jobAssetPrefix(job *domain.Job) string {
+	return repository.JobAssetPrefix(job)
+}
+
+// jobAssetBucket is the bucket every asset generated for a job is stored in; defaultBucket for
+// jobs from before buckets were recorded
+func jobAssetBucket(job *domain.Job, defaultBucket string) string {
+	return repository.JobAssetBucket(job, defaultBucket)
+}
+
+// deleteJobAssets deletes everything under the job's prefix, then any generated asset the job
+// record points at outside it (jobs from before the users/{user}/jobs/{job}/ layout). It
+// returns how many objects were deleted and every error encountered.
+func deleteJobAssets(ctx context.Context, store jobAssetStore, bucket string, job *domain.Job) (int, error) {
+	bucket = jobAssetBucket(job, bucket)
+	deleted, err := store.DeletePrefix(ctx, bucket, jobAssetPrefix(job))
+	errs := []error{err}
+
+	for _, key := range legacyJobAssetKeys(job, bucket) {
+		if err := store.DeleteFile(ctx, bucket, key); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// legacyJobAssetKeys returns the keys of generated assets the job references outside its
+// prefix. User uploads (start image, logo, product photos) are never included: other jobs
+// may still use them.
+func legacyJobAssetKeys(job *domain.Job, bucket string) []string {
+	refs := []string{job.VideoKey, job.WebMVideoKey, job.ThumbnailURL, job.AudioURL, job.MusicRawURL, job.NarratorAudioURL, job.CaptionsKey}
+	refs = append(refs, job.SceneVideoURLs...)
+	for _, url := range job.ClipVersions {
+		refs = append(refs, url)
+	}
+	for _, t := range job.Thumbnails {
+		refs = append(refs, t.Key)
+	}
+	for _, v := range job.SceneVoiceovers {
+		refs = append(refs, v.URL)
+	}
+	for _, clip := range job.SFX {
+		refs = append(refs, clip.URL)
+	}
+
+	prefix := jobAssetPrefix(job)
+	seen := make(map[string]bool)
+	var keys []string
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		refBucket, key, err := s3util.ParseURL(ref)
+		if err != nil || (refBucket != "" && refBucket != bucket) {
+			continue
+		}
+		if strings.HasPrefix(key, prefix) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJobAssetStore records deletions; prefixes holds how many objects each prefix contains
+type fakeJobAssetStore struct {
+	prefixes  map[string]int
+	prefixErr error
+	refuse    map[string]bool
+
+	deletedPrefixes []string
+	deletedFiles    []string
+	buckets         []string // Bucket of each deletion
+}
+
+func (s *fakeJobAssetStore) DeletePrefix(_ context.Context, bucket, prefix string) (int, error) {
+	s.deletedPrefixes = append(s.deletedPrefixes, prefix)
+	s.buckets = append(s.buckets, bucket)
+	return s.prefixes[prefix], s.prefixErr
+}
+
+func (s *fakeJobAssetStore) DeleteFile(_ context.Context, bucket, key string) error {
+	if s.refuse[key] {
+		return errors.New("access denied")
+	}
+	s.deletedFiles = append(s.deletedFiles, key)
+	s.buckets = append(s.buckets, bucket)
+	return nil
+}
+
+func TestDeleteJobAssetsByPrefix(t *testing.T) {
+	job := &domain.Job{
+		JobID:            "job456",
+		UserID:           "user123",
+		VideoKey:         "users/user123/jobs/job456/final/video.mp4",
+		WebMVideoKey:     "users/user123/jobs/job456/final/video.webm",
+		NarratorAudioURL: "https://assets.s3.amazonaws.com/users/user123/jobs/job456/audio/narrator-voiceover.mp3",
+		ClipVersions:     map[string]string{"scene-1-v2": "https://assets.s3.amazonaws.com/users/user123/jobs/job456/clips/scene-001-v2.mp4"},
+		StartImage:       "https://assets.s3.amazonaws.com/users/user123/uploads/bottle.png",
+	}
+	store := &fakeJobAssetStore{prefixes: map[string]int{"users/user123/jobs/job456/": 14}}
+
+	deleted, err := deleteJobAssets(context.Background(), store, "assets", job)
+	require.NoError(t, err)
+	require.Equal(t, 14, deleted)
+	require.Equal(t, []string{"users/user123/jobs/job456/"}, store.deletedPrefixes)
+	require.Empty(t, store.deletedFiles, "everything is under the prefix; uploads are left alone")
+}
+
+func TestDeleteJobAssetsInJobLocation(t *testing.T) {
+	job := &domain.Job{
+		JobID:       "job456",
+		UserID:      "user123",
+		AssetBucket: "tenant-assets",
+		AssetPrefix: "env/staging/",
+		VideoKey:    "env/staging/users/user123/jobs/job456/final/video.mp4",
+	}
+	store := &fakeJobAssetStore{prefixes: map[string]int{"env/staging/users/user123/jobs/job456/": 6}}
+
+	deleted, err := deleteJobAssets(context.Background(), store, "assets", job)
+	require.NoError(t, err)
+	require.Equal(t, 6, deleted)
+	require.Equal(t, []string{"env/staging/users/user123/jobs/job456/"}, store.deletedPrefixes)
+	require.Equal(t, []string{"tenant-assets"}, store.buckets, "the job's bucket, not the default")
+	require.Empty(t, store.deletedFiles)
+}
+
+func TestDeleteJobAssetsLegacyLayout(t *testing.T) {
+	// A job from before assets were grouped under users/{user}/jobs/{job}/
+	job := &domain.Job{
+		JobID:          "job456",
+		UserID:         "user123",
+		VideoKey:       "videos/job456/final.mp4",
+		ThumbnailURL:   "https://assets.s3.amazonaws.com/thumbnails/job456.jpg?X-Amz-Signature=abc",
+		AudioURL:       "https://s3.us-east-1.amazonaws.com/assets/audio/job456.mp3",
+		SceneVideoURLs: []string{"https://assets.s3.amazonaws.com/videos/job456/scene-1.mp4", "https://assets.s3.amazonaws.com/videos/job456/scene-1.mp4"},
+		SFX:            []domain.SFXClip{{URL: "https://other-bucket.s3.amazonaws.com/sfx/job456-1.mp3"}},
+		CaptionsKey:    "users/user123/jobs/job456/captions/narrator.vtt",
+	}
+	store := &fakeJobAssetStore{
+		prefixes: map[string]int{"users/user123/jobs/job456/": 1},
+		refuse:   map[string]bool{"audio/job456.mp3": true},
+	}
+
+	deleted, err := deleteJobAssets(context.Background(), store, "assets", job)
+	require.ErrorContains(t, err, "access denied")
+	require.Equal(t, 4, deleted, "one object under the prefix plus three legacy keys")
+	require.Equal(t, []string{"videos/job456/final.mp4", "thumbnails/job456.jpg", "videos/job456/scene-1.mp4"}, store.deletedFiles)
+}
+
+func TestDeleteJobAssetsContinuesAfterPrefixFailure(t *testing.T) {
+	job := &domain.Job{JobID: "job456", UserID: "user123", VideoKey: "videos/job456/final.mp4"}
+	store := &fakeJobAssetStore{prefixErr: errors.New("list failed")}
+
+	deleted, err := deleteJobAssets(context.Background(), store, "assets", job)
+	require.ErrorContains(t, err, "list failed")
+	require.Equal(t, 1, deleted)
+	require.Equal(t, []string{"videos/job456/final.mp4"}, store.deletedFiles)
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// cancelWatchInterval is how often a running job's cancel flag is re-read, picking up
+	// cancels received by other servers while the pipeline is inside a long poll
+	cancelWatchInterval = 15 * time.Second
+
+	// cancelCheckTimeout bounds a single read of the cancel flag
+	cancelCheckTimeout = 5 * time.Second
+)
+
+// errJobCanceled is the cause of a pipeline context canceled by a cancel request
+var errJobCanceled = stderrors.New("job canceled")
+
+// cancelStore is the subset of the job repository the cancellation registry needs
+type cancelStore interface {
+	IsCancelRequested(ctx context.Context, jobID string) (bool, error)
+}
+
+// jobCancellations tracks the pipelines running on this server so a cancel request can stop
+// them immediately. Cancels received by another server reach the pipeline through the job's
+// cancel_requested flag, which is checked at stage boundaries and watched while a stage runs.
+type jobCancellations struct {
+	store         cancelStore
+	watchInterval time.Duration
+	logger        *zap.Logger
+
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc
+}
+
+func newJobCancellations(store cancelStore, logger *zap.Logger) *jobCancellations {
+	return &jobCancellations{
+		store:         store,
+		watchInterval: cancelWatchInterval,
+		logger:        logger,
+		running:       make(map[string]context.CancelCauseFunc),
+	}
+}
+
+// start registers a job's pipeline and returns the context it runs under. The returned
+// func must be called when the pipeline returns.
+func (c *jobCancellations) start(parent context.Context, jobID string) (context.Context, func()) {
+	if c == nil {
+		return parent, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+
+	c.mu.Lock()
+	c.running[jobID] = cancel
+	c.mu.Unlock()
+
+	if c.store != nil && c.watchInterval > 0 {
+		go c.watch(ctx, jobID)
+	}
+
+	return ctx, func() {
+		c.mu.Lock()
+		delete(c.running, jobID)
+		c.mu.Unlock()
+		cancel(context.Canceled)
+	}
+}
+
+// watch cancels the job's context once its cancel flag is set, until the pipeline returns
+func (c *jobCancellations) watch(ctx context.Context, jobID string) {
+	ticker := time.NewTicker(c.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.requested(ctx, jobID)
+		}
+	}
+}
+
+// cancel stops a job's pipeline if it runs on this server, reporting whether it did
+func (c *jobCancellations) cancel(jobID string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	cancel, ok := c.running[jobID]
+	c.mu.Unlock()
+
+	if ok {
+		cancel(errJobCanceled)
+	}
+	return ok
+}
+
+// isRunning reports whether the job's pipeline runs on this server
+func (c *jobCancellations) isRunning(jobID string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.running[jobID]
+	return ok
+}
+
+// requested reports whether the job was asked to cancel, either on this server (through ctx)
+// or on another one (through the stored flag). A stored cancel also cancels ctx, so stages
+// still running stop as well.
+func (c *jobCancellations) requested(ctx context.Context, jobID string) bool {
+	if context.Cause(ctx) == errJobCanceled {
+		return true
+	}
+	if c == nil || c.store == nil {
+		return false
+	}
+
+	// The flag is read even after ctx timed out, so a cancel isn't reported as a failure
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelCheckTimeout)
+	defer cancel()
+
+	requested, err := c.store.IsCancelRequested(checkCtx, jobID)
+	if err != nil {
+		c.logger.Warn("Failed to check job cancel flag", zap.String("job_id", jobID), zap.Error(err))
+		return false
+	}
+	if requested {
+		c.cancel(jobID)
+	}
+	return requested
+}
+
+// CancelJobResponse represents the response to a cancel request
+type CancelJobResponse struct {
+	JobID           string `json:"job_id"`
+	Status          string `json:"status"`
+	CancelRequested bool   `json:"cancel_requested"`
+}
+
+// CancelJob handles POST /api/v1/jobs/:id/cancel
+// @Summary Cancel a job
+// @Description Cancels a queued, previewed or in-progress job. Jobs without a running pipeline are canceled immediately (200). Running jobs are flagged and stop at the next stage boundary, canceling in-flight predictions (202); the job then moves to the canceled status. Credits are refunded for scenes that were not generated.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} CancelJobResponse "Job canceled"
+// @Success 202 {object} CancelJobResponse "Cancel requested; the pipeline is stopping"
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job already finished, or is a variant parent whose variants are generating"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/cancel [post]
+// @Security BearerAuth
+func (h *GenerateHandler) CancelJob(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := auth.MustGetUserID(c)
+	ctx := c.Request.Context()
+
+	job, err := h.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		h.respondJobLookupError(c, jobID, err)
+		return
+	}
+
+	// Verify job belongs to the current user (security check)
+	if job.UserID != userID {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return
+	}
+
+	switch job.Status {
+	case domain.StatusPending, domain.StatusQueued, domain.StatusScriptReady:
+		err := h.jobRepo.CancelIdleJob(ctx, jobID)
+		if err == nil {
+			// Nothing is running, so the job is finished here
+			h.cleanupJobAssets(job)
+			h.refundCredits(job)
+			h.notifyJobFinished(job.JobID)
+			h.settleVariantFamily(job)
+
+			h.logger.Info("Job canceled", zap.String("job_id", jobID), zap.String("previous_status", job.Status))
+			c.JSON(http.StatusOK, CancelJobResponse{
+				JobID:           jobID,
+				Status:          domain.StatusCanceled,
+				CancelRequested: true,
+			})
+			return
+		}
+		if !stderrors.Is(err, repository.ErrJobNotCancelable) {
+			h.respondCancelError(c, jobID, err)
+			return
+		}
+
+		// The job started (or finished) since it was read
+		job, err = h.jobRepo.GetJob(ctx, jobID)
+		if err != nil {
+			h.respondJobLookupError(c, jobID, err)
+			return
+		}
+		if job.Status != domain.StatusProcessing {
+			respondNotCancelable(c, job.Status)
+			return
+		}
+	case domain.StatusProcessing:
+	default:
+		respondNotCancelable(c, job.Status)
+		return
+	}
+
+	// Once its scripts are written, a variant parent only follows its variants
+	if job.IsVariantParent() && job.Stage.IsVariants() {
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"Variants are already generating; cancel each variant instead", map[string]interface{}{
+					"variant_job_ids": job.VariantJobIDs,
+				}),
+		})
+		return
+	}
+
+	if !job.CancelRequested {
+		if err := h.jobRepo.RequestJobCancel(ctx, jobID); err != nil {
+			if stderrors.Is(err, repository.ErrJobNotCancelable) {
+				respondNotCancelable(c, "finished")
+				return
+			}
+			h.respondCancelError(c, jobID, err)
+			return
+		}
+	}
+
+	// Stop the pipeline now if it runs here; otherwise its server picks up the flag
+	local := h.cancellations.cancel(jobID)
+	h.logger.Info("Job cancel requested",
+		zap.String("job_id", jobID),
+		zap.Stringer("stage", job.Stage),
+		zap.Bool("running_on_this_server", local),
+	)
+
+	c.JSON(http.StatusAccepted, CancelJobResponse{
+		JobID:           jobID,
+		Status:          domain.StatusProcessing,
+		CancelRequested: true,
+	})
+}
+
+// respondJobLookupError reports a failed job read for the cancel endpoint
+func (h *GenerateHandler) respondJobLookupError(c *gin.Context, jobID string, err error) {
+	if err == repository.ErrJobNotFound {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return
+	}
+
+	h.logger.Error("Failed to get job for cancel", zap.String("job_id", jobID), zap.Error(err))
+	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+		Error: errors.ErrDatabaseError,
+	})
+}
+
+// respondCancelError reports a failed cancel write
+func (h *GenerateHandler) respondCancelError(c *gin.Context, jobID string, err error) {
+	h.logger.Error("Failed to cancel job", zap.String("job_id", jobID), zap.Error(err))
+	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+		Error: errors.ErrDatabaseError,
+	})
+}
+
+func respondNotCancelable(c *gin.Context, status string) {
+	c.JSON(http.StatusConflict, errors.ErrorResponse{
+		Error: errors.NewAPIError(errors.ErrConflict,
+			fmt.Sprintf("Job can no longer be canceled (status: %s)", status), nil),
+	})
+}
+
+// stopIfCanceled finishes the job as canceled when a cancel was requested, reporting whether
+// the pipeline must stop. Stages call it at their boundaries.
+func (h *GenerateHandler) stopIfCanceled(ctx context.Context, job *domain.Job) bool {
+	if !h.cancellations.requested(ctx, job.JobID) {
+		return false
+	}
+	h.finishCanceledJob(job)
+	return true
+}
+
+// finishCanceledJob moves a canceled job to the canceled status, then removes its partial
+// assets and refunds the scenes it never generated
+func (h *GenerateHandler) finishCanceledJob(job *domain.Job) {
+	h.logger.Info("Stopping canceled job",
+		zap.String("job_id", job.JobID),
+		zap.Stringer("stage", job.Stage),
+		zap.Int("scenes_completed", job.ScenesCompleted),
+	)
+	defer h.notifyJobFinished(job.JobID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := h.jobRepo.MarkJobCanceled(ctx, job.JobID); err != nil {
+		// A job that isn't recorded as canceled keeps its assets and charge
+		h.logger.Error("Failed to finish canceled job",
+			zap.String("job_id", job.JobID),
+			zap.Error(err),
+		)
+		return
+	}
+	recordJobOutcome(h.metrics, job, outcomeCanceled, "")
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventCanceled,
+		Stage:   job.Stage.String(),
+		Message: "Canceled by the user",
+	})
+
+	h.cleanupJobAssets(job)
+	h.refundCredits(job)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/concurrency"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeCancelStore is the cancel flag as another server would set it
+type fakeCancelStore struct {
+	mu    sync.Mutex
+	flags map[string]bool
+}
+
+func (s *fakeCancelStore) IsCancelRequested(_ context.Context, jobID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flags[jobID], nil
+}
+
+func (s *fakeCancelStore) set(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[jobID] = true
+}
+
+// runFakePipeline runs stages the way generateVideoAsync does: the cancel signal is checked
+// before every stage and before the job is marked complete, and a failed stage is reported as
+// canceled when a cancel caused it. It returns the stages that ran and whether the pipeline stopped for a cancel.
+func runFakePipeline(ctx context.Context, c *jobCancellations, jobID string, stages []func(ctx context.Context) error) ([]int, bool) {
+	var ran []int
+	for i, stage := range stages {
+		if c.requested(ctx, jobID) {
+			return ran, true
+		}
+		ran = append(ran, i)
+		if err := stage(ctx); err != nil {
+			return ran, c.requested(ctx, jobID)
+		}
+	}
+	return ran, c.requested(ctx, jobID)
+}
+
+func TestJobCancellationObservedAtEachStageBoundary(t *testing.T) {
+	const numStages = 5 // script, scene 1, scene 2, audio, compose
+	for cancelDuring := 0; cancelDuring < numStages; cancelDuring++ {
+		c := newJobCancellations(nil, zap.NewNop())
+		ctx, done := c.start(context.Background(), "job-1")
+
+		stages := make([]func(ctx context.Context) error, numStages)
+		for i := range stages {
+			stages[i] = func(ctx context.Context) error {
+				if i == cancelDuring {
+					require.True(t, c.cancel("job-1"), "the running job should be registered")
+				}
+				return nil
+			}
+		}
+
+		ran, canceled := runFakePipeline(ctx, c, "job-1", stages)
+		done()
+
+		require.True(t, canceled, "cancel during stage %d", cancelDuring)
+		require.Len(t, ran, cancelDuring+1, "no stage may start after the cancel during stage %d", cancelDuring)
+	}
+}
+
+func TestJobCancellationInterruptsPollingStage(t *testing.T) {
+	c := newJobCancellations(nil, zap.NewNop())
+	ctx, done := c.start(context.Background(), "job-1")
+	defer done()
+
+	polling := make(chan struct{})
+	stages := []func(ctx context.Context) error{
+		func(ctx context.Context) error {
+			// A prediction poll: only the context ends it
+			close(polling)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		func(ctx context.Context) error {
+			t.Error("stage after the canceled poll ran")
+			return nil
+		},
+	}
+
+	go func() {
+		<-polling
+		c.cancel("job-1")
+	}()
+
+	ran, canceled := runFakePipeline(ctx, c, "job-1", stages)
+	require.True(t, canceled)
+	require.Equal(t, []int{0}, ran)
+	require.ErrorIs(t, context.Cause(ctx), errJobCanceled)
+}
+
+func TestJobCancellationFromAnotherServer(t *testing.T) {
+	store := &fakeCancelStore{flags: make(map[string]bool)}
+	c := newJobCancellations(store, zap.NewNop())
+	c.watchInterval = 10 * time.Millisecond
+
+	t.Run("flag seen at the next boundary", func(t *testing.T) {
+		ctx, done := c.start(context.Background(), "job-1")
+		defer done()
+
+		stages := []func(ctx context.Context) error{
+			func(context.Context) error { store.set("job-1"); return nil },
+			func(context.Context) error { t.Error("stage after the cancel ran"); return nil },
+		}
+		ran, canceled := runFakePipeline(ctx, c, "job-1", stages)
+		require.True(t, canceled)
+		require.Equal(t, []int{0}, ran)
+	})
+
+	t.Run("flag interrupts a running poll", func(t *testing.T) {
+		ctx, done := c.start(context.Background(), "job-2")
+		defer done()
+
+		stages := []func(ctx context.Context) error{
+			func(ctx context.Context) error {
+				store.set("job-2")
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(5 * time.Second):
+					t.Error("the watcher did not cancel the poll")
+					return nil
+				}
+			},
+		}
+		_, canceled := runFakePipeline(ctx, c, "job-2", stages)
+		require.True(t, canceled)
+	})
+}
+
+func TestJobCancellationTimeoutIsNotACancel(t *testing.T) {
+	c := newJobCancellations(&fakeCancelStore{flags: make(map[string]bool)}, zap.NewNop())
+	ctx, done := c.start(context.Background(), "job-1")
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	<-timeoutCtx.Done()
+	require.False(t, c.requested(timeoutCtx, "job-1"), "a timed-out job is failed, not canceled")
+
+	done()
+	require.False(t, c.cancel("job-1"), "finished jobs are unregistered")
+}
+
+func TestJobCancellationStopsJobWaitingForSlot(t *testing.T) {
+	ctx := context.Background()
+	jobRepo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	job := &domain.Job{JobID: "job-1", UserID: "user-123", Status: domain.StatusProcessing}
+	require.NoError(t, jobRepo.CreateJob(ctx, job))
+
+	h := &GenerateHandler{
+		jobRepo:       jobRepo,
+		metrics:       metrics.Nop{},
+		logger:        zap.NewNop(),
+		semaphore:     concurrency.NewSemaphore(1),
+		cancellations: newJobCancellations(nil, zap.NewNop()),
+	}
+	// Another generation holds the only slot
+	require.NoError(t, h.semaphore.Acquire(ctx))
+	defer h.semaphore.Release()
+
+	h.runInBackground(ctx, job, func(context.Context) {
+		t.Error("the canceled job ran")
+	})
+	require.Eventually(t, func() bool { return h.PipelineRunning("job-1") }, 5*time.Second, time.Millisecond,
+		"the waiting job should be cancelable")
+	require.True(t, h.cancellations.cancel("job-1"))
+
+	require.Eventually(t, func() bool {
+		stored, err := jobRepo.GetJob(ctx, "job-1")
+		return err == nil && stored.Status == domain.StatusCanceled
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0, h.semaphore.Available(), "the slot is still held by the other generation")
+}
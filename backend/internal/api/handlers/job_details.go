@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// UpdateJobRequest holds the edits to a job; omitted fields are left unchanged
+type UpdateJobRequest struct {
+	Title *string `json:"title,omitempty"`
+	Note  *string `json:"note,omitempty"` // An empty note removes it
+}
+
+// UpdateJobResponse is a job's title and note after an update
+type UpdateJobResponse struct {
+	JobID     string `json:"job_id"`
+	Title     string `json:"title,omitempty"`
+	Note      string `json:"note,omitempty"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// UpdateJob handles PATCH /api/v1/jobs/:id
+// @Summary Rename a job
+// @Description Change a job's title or note. A renamed job keeps its title when its script is written.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body UpdateJobRequest true "Fields to change"
+// @Success 200 {object} UpdateJobResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id} [patch]
+// @Security BearerAuth
+func (h *JobsHandler) UpdateJob(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := auth.MustGetUserID(c)
+
+	var req UpdateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+	details := repository.JobDetails{Title: trimmed(req.Title), Note: trimmed(req.Note)}
+	if errs := validation.ValidateJobDetails(validation.JobDetailsInput{Title: details.Title, Note: details.Note}); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	job, err := h.jobRepo.GetJob(c.Request.Context(), jobID)
+	if err == nil && job.UserID != userID {
+		h.logger.Warn("User attempted to update job belonging to another user",
+			zap.String("job_id", jobID),
+			zap.String("job_user_id", job.UserID),
+			zap.String("requesting_user_id", userID),
+		)
+		err = repository.ErrJobNotFound
+	}
+	if err == nil {
+		job, err = h.jobRepo.UpdateJobDetails(c.Request.Context(), jobID, details)
+	}
+	if err != nil {
+		if err == repository.ErrJobNotFound {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error: errors.ErrJobNotFound,
+			})
+			return
+		}
+
+		h.logger.Error("Failed to update job", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	h.logger.Info("Job updated",
+		zap.String("job_id", jobID),
+		zap.String("user_id", userID),
+		zap.Bool("title_changed", details.Title != nil),
+		zap.Bool("note_changed", details.Note != nil),
+	)
+	c.JSON(http.StatusOK, UpdateJobResponse{
+		JobID:     job.JobID,
+		Title:     job.Title,
+		Note:      job.Note,
+		UpdatedAt: job.UpdatedAt,
+	})
+}
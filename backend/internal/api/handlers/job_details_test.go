@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// jobsTestRouter serves the job routes as user-123, with a job of theirs and one of user-456
+func jobsTestRouter(t *testing.T) (*gin.Engine, *repository.DynamoDBRepository) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	for _, job := range []*domain.Job{
+		{JobID: "job-1", UserID: "user-123", Status: domain.StatusProcessing, Stage: domain.SceneGenerating(1), Prompt: "A sunrise run", Title: "A sunrise run", CreatedAt: 1000},
+		{JobID: "job-other", UserID: "user-456", Status: domain.StatusCompleted, Title: "Theirs", CreatedAt: 1000},
+	} {
+		require.NoError(t, repo.CreateJob(context.Background(), job))
+	}
+
+	h := NewJobsHandler(repo, nil, nil, nil, "", zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.GET("/jobs", h.ListJobs)
+	v1.PATCH("/jobs/:id", h.UpdateJob)
+	return router, repo
+}
+
+func patchJob(router *gin.Engine, jobID, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/v1/jobs/"+jobID, bytes.NewBufferString(body)))
+	return w
+}
+
+func TestUpdateJob_RenamesJob(t *testing.T) {
+	router, repo := jobsTestRouter(t)
+
+	w := patchJob(router, "job-1", `{"title": "  Sunrise 10K  ", "note": "Send to legal"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response UpdateJobResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, "Sunrise 10K", response.Title)
+	require.Equal(t, "Send to legal", response.Note)
+
+	job, err := repo.GetJob(context.Background(), "job-1")
+	require.NoError(t, err)
+	require.Equal(t, "Sunrise 10K", job.Title)
+	require.True(t, job.TitleEdited)
+	require.Equal(t, domain.SceneGenerating(1), job.Stage, "only the title and note were written")
+
+	// A note alone leaves the title; an empty note removes it
+	w = patchJob(router, "job-1", `{"note": ""}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	job, err = repo.GetJob(context.Background(), "job-1")
+	require.NoError(t, err)
+	require.Equal(t, "Sunrise 10K", job.Title)
+	require.Empty(t, job.Note)
+
+	// The list shows the new title
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list ListJobsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Jobs, 1)
+	require.Equal(t, "Sunrise 10K", list.Jobs[0].Title)
+}
+
+func TestUpdateJob_Validation(t *testing.T) {
+	router, _ := jobsTestRouter(t)
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"blank title", `{"title": "   "}`, "title"},
+		{"long title", `{"title": "` + strings.Repeat("t", validation.MaxTitleLength+1) + `"}`, "title"},
+		{"long note", `{"note": "` + strings.Repeat("n", validation.MaxNoteLength+1) + `"}`, "note"},
+		{"nothing to change", `{}`, "title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := patchJob(router, "job-1", tt.body)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+			var response errors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			var fieldErrors []validation.FieldError
+			data, _ := json.Marshal(response.Error.Details["errors"])
+			require.NoError(t, json.Unmarshal(data, &fieldErrors))
+			require.Len(t, fieldErrors, 1)
+			require.Equal(t, tt.field, fieldErrors[0].Field)
+		})
+	}
+
+	w := patchJob(router, "job-1", `{"title": 42}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateJob_HidesOtherUsersJobs(t *testing.T) {
+	router, repo := jobsTestRouter(t)
+
+	for _, id := range []string{"job-other", "job-missing"} {
+		w := patchJob(router, id, `{"title": "Mine now"}`)
+		require.Equal(t, http.StatusNotFound, w.Code, id)
+		require.Contains(t, w.Body.String(), errors.ErrJobNotFound.Code)
+	}
+
+	job, err := repo.GetJob(context.Background(), "job-other")
+	require.NoError(t, err)
+	require.Equal(t, "Theirs", job.Title)
+}
+
+func TestPipelineOutput_KeepsOwnersTitle(t *testing.T) {
+	pipelineCopy := &domain.Job{JobID: "job-1", Title: "Script title", Stage: domain.StageScriptComplete}
+	apply := pipelineOutput(pipelineCopy)
+
+	renamed := &domain.Job{JobID: "job-1", Title: "Sunrise 10K", TitleEdited: true}
+	apply(renamed)
+	require.Equal(t, "Sunrise 10K", renamed.Title)
+	require.Equal(t, domain.StageScriptComplete, renamed.Stage)
+
+	untouched := &domain.Job{JobID: "job-1", Title: "A sunrise run"}
+	apply(untouched)
+	require.Equal(t, "Script title", untouched.Title)
+}
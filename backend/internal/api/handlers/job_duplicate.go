@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// DuplicateJob handles POST /api/v1/jobs/:id/duplicate
+// @Summary Duplicate a job
+// @Description Creates a new job with the settings of an existing one and starts it. Fields in the body replace the copied ones whole, as in POST /generate, and the result is validated like a new request. The user's preferences are not applied; the copied job already holds the values that applied to it.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body GenerateRequest false "Settings to change"
+// @Success 202 {object} GenerateResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 402 {object} errors.ErrorResponse "Insufficient credits"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse "Field validation errors, or a copied asset no longer exists"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/duplicate [post]
+// @Security BearerAuth
+func (h *GenerateHandler) DuplicateJob(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := auth.MustGetUserID(c)
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	original, err := h.jobRepo.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		if err == repository.ErrJobNotFound {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error: errors.ErrJobNotFound,
+			})
+			return
+		}
+
+		h.logger.Error("Failed to get job to duplicate", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	// Verify job belongs to the current user (security check)
+	if original.UserID != userID {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return
+	}
+
+	copied := generateRequestFromJob(original)
+	copied.Variants = original.Variants
+	req, err := applyOverrides(copied, body)
+	if err != nil {
+		h.logger.Info("Invalid duplicate overrides", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	h.logger.Info("Duplicating job",
+		zap.String("job_id", jobID),
+		zap.Int("overridden_fields", len(explicitFields(body))),
+	)
+	h.createJob(c, req, newJobOptions{duplicatedFrom: original.JobID})
+}
+
+// applyOverrides returns req with the top-level fields of a JSON object body in place of its own.
+// Each replaces the copied value whole, so an override of e.g. "output" doesn't keep the rest of
+// the original's output spec, and null clears a field. An empty body changes nothing.
+func applyOverrides(req GenerateRequest, body []byte) (GenerateRequest, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return req, nil
+	}
+	var overrides map[string]json.RawMessage
+	if err := json.Unmarshal(body, &overrides); err != nil {
+		return req, err
+	}
+
+	base, err := json.Marshal(req)
+	if err != nil {
+		return req, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(base, &fields); err != nil {
+		return req, err
+	}
+	maps.Copy(fields, overrides)
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return req, err
+	}
+	var out GenerateRequest
+	if err := binding.JSON.BindBody(merged, &out); err != nil {
+		return req, err
+	}
+	return out, nil
+}
+
+// requireStoredImage adds an error to errs when an image URL of one of the user's assets no
+// longer has an object behind it. Other URLs can't be checked and are left to the job; keys were
+// already checked by resolveImageRef.
+func requireStoredImage(ctx context.Context, storage objectStat, bucket string, locator *repository.AssetLocator, userID, field, url string, errs *validation.Errors) {
+	if !strings.Contains(url, "://") {
+		return
+	}
+	key, ok := ownedAssetKey(locator, userID, url)
+	if !ok {
+		return
+	}
+	if _, err := storage.ObjectInfo(ctx, bucket, key); err != nil {
+		if stderrors.Is(err, repository.ErrObjectNotFound) {
+			errs.Add(field, fmt.Sprintf("Asset %s no longer exists", key))
+		} else {
+			errs.Add(field, "Asset could not be checked; try again shortly")
+		}
+	}
+}
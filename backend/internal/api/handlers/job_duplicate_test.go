@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// duplicateTestRouter serves POST /jobs/:id/duplicate from a local S3 as the user in the
+// X-Test-User header. user-123 has an active job with a limit of one, so duplicates are queued.
+func duplicateTestRouter(t *testing.T) (*gin.Engine, *repository.S3AssetRepository, *repository.DynamoDBRepository) {
+	s3Service := newComposeTestS3(t)
+	jobRepo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+
+	now := time.Now().Unix()
+	require.NoError(t, jobRepo.CreateJob(context.Background(), &domain.Job{
+		JobID:     "job-active",
+		UserID:    "user-123",
+		Status:    domain.StatusProcessing,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	generate := NewGenerateHandler(GenerateHandlerDeps{
+		S3Service:            s3Service,
+		JobRepo:              jobRepo,
+		MaxActiveJobsPerUser: 1,
+		AssetsBucket:         "assets",
+	}, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, c.GetHeader("X-Test-User"))
+	})
+	v1.POST("/jobs/:id/duplicate", generate.DuplicateJob)
+	return router, s3Service, jobRepo
+}
+
+// createOriginalJob stores a completed job of user-123's to be duplicated
+func createOriginalJob(t *testing.T, jobRepo *repository.DynamoDBRepository, job *domain.Job) *domain.Job {
+	t.Helper()
+	now := time.Now().Unix()
+	job.UserID = "user-123"
+	job.Status = domain.StatusCompleted
+	job.Stage = domain.StageComplete
+	job.CreatedAt, job.UpdatedAt = now, now
+	require.NoError(t, jobRepo.CreateJob(context.Background(), job))
+	return job
+}
+
+// duplicateJob duplicates jobID as userID and returns the job created
+func duplicateJob(t *testing.T, router *gin.Engine, jobRepo *repository.DynamoDBRepository, jobID, userID string, overrides interface{}) *domain.Job {
+	t.Helper()
+	w := serveAsUser(router, http.MethodPost, "/api/v1/jobs/"+jobID+"/duplicate", userID, overrides)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response GenerateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	job, err := jobRepo.GetJob(context.Background(), response.JobID)
+	require.NoError(t, err)
+	return job
+}
+
+func TestDuplicateJob_CopiesSettingsWithOverrides(t *testing.T) {
+	router, _, jobRepo := duplicateTestRouter(t)
+	original := createOriginalJob(t, jobRepo, &domain.Job{
+		JobID:       "job-original",
+		Prompt:      "A cold brew coffee ad for busy mornings",
+		Title:       "Cold Brew Mornings",
+		Duration:    16,
+		AspectRatio: "16:9",
+		Model:       "veo",
+		Style:       "cinematic",
+		Tone:        "friendly",
+		OutputSpec:  &domain.OutputSpec{Resolution: "720p", Codec: "h265"},
+		VideoKey:    "users/user-123/jobs/job-original/final/video.mp4",
+	})
+
+	job := duplicateJob(t, router, jobRepo, original.JobID, "user-123", nil)
+	require.NotEqual(t, original.JobID, job.JobID)
+	require.Equal(t, original.JobID, job.DuplicatedFrom)
+	require.Equal(t, domain.StatusQueued, job.Status, "duplicates start like any new job")
+	require.Equal(t, original.Prompt, job.Prompt)
+	require.Equal(t, original.Title, job.Title)
+	require.Equal(t, "16:9", job.AspectRatio)
+	require.Equal(t, "cinematic", job.Style)
+	require.Equal(t, original.OutputSpec, job.OutputSpec)
+	require.Empty(t, job.VideoKey, "none of the original's results are copied")
+
+	job = duplicateJob(t, router, jobRepo, original.JobID, "user-123", map[string]interface{}{
+		"aspect_ratio": "9:16",
+		"tone":         nil,
+		"output":       map[string]string{"resolution": "1080p"},
+	})
+	require.Equal(t, original.JobID, job.DuplicatedFrom)
+	require.Equal(t, "9:16", job.AspectRatio)
+	require.Empty(t, job.Tone, "null clears a copied setting")
+	require.Equal(t, "cinematic", job.Style, "settings the body leaves out are kept")
+	require.Equal(t, &domain.OutputSpec{Resolution: "1080p"}, job.OutputSpec, "an override replaces the copied value whole")
+
+	stored, err := jobRepo.GetJob(context.Background(), original.JobID)
+	require.NoError(t, err)
+	require.Equal(t, "16:9", stored.AspectRatio, "the original is left as it was")
+	require.Empty(t, stored.DuplicatedFrom)
+}
+
+func TestDuplicateJob_ValidatesOverrides(t *testing.T) {
+	router, _, jobRepo := duplicateTestRouter(t)
+	original := createOriginalJob(t, jobRepo, &domain.Job{
+		JobID:       "job-original-validated",
+		Prompt:      "A cold brew coffee ad for busy mornings",
+		Duration:    16,
+		AspectRatio: "16:9",
+	})
+	path := "/api/v1/jobs/" + original.JobID + "/duplicate"
+
+	w := serveAsUser(router, http.MethodPost, path, "user-123", map[string]interface{}{"tone": "sarcastic"})
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"field":"tone"`)
+
+	w = serveAsUser(router, http.MethodPost, path, "user-123", map[string]interface{}{"duration": "long"})
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = serveAsUser(router, http.MethodPost, path, "user-456", nil)
+	require.Equal(t, http.StatusNotFound, w.Code, "another user's job can't be duplicated")
+
+	w = serveAsUser(router, http.MethodPost, "/api/v1/jobs/job-missing/duplicate", "user-123", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDuplicateJob_RequiresCopiedImagesToExist(t *testing.T) {
+	router, s3Service, jobRepo := duplicateTestRouter(t)
+	startImage := "users/user-123/uploads/product_images/1_bottle.png"
+	uploadTestAsset(t, s3Service, startImage, "image/png", testPNG(t, 800, 600))
+	original := createOriginalJob(t, jobRepo, &domain.Job{
+		JobID:       "job-original-images",
+		Prompt:      "A cold brew coffee ad for busy mornings",
+		Duration:    16,
+		AspectRatio: "16:9",
+		StartImage:  "https://assets.s3.amazonaws.com/" + startImage,
+	})
+
+	job := duplicateJob(t, router, jobRepo, original.JobID, "user-123", nil)
+	require.Equal(t, original.StartImage, job.StartImage)
+
+	require.NoError(t, s3Service.DeleteFile(context.Background(), "assets", startImage))
+	w := serveAsUser(router, http.MethodPost, "/api/v1/jobs/"+original.JobID+"/duplicate", "user-123", nil)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"field":"start_image"`)
+	require.Contains(t, w.Body.String(), "Asset "+startImage+" no longer exists")
+
+	// Replacing the deleted image lets the job be duplicated again
+	replacement := "users/user-123/uploads/product_images/2_can.png"
+	uploadTestAsset(t, s3Service, replacement, "image/png", testPNG(t, 800, 600))
+	job = duplicateJob(t, router, jobRepo, original.JobID, "user-123", map[string]string{"start_image": replacement})
+	require.Equal(t, "https://assets.s3.amazonaws.com/"+replacement, job.StartImage)
+}
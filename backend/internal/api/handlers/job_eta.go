@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobeta"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// DefaultStageTimingsInterval is how often the stage timings ETAs are estimated from are saved
+const DefaultStageTimingsInterval = 5 * time.Minute
+
+// PersistStageTimings loads the stage timings stored by earlier servers, then saves this
+// server's every interval while runs have been recorded, until ctx is done. Each server saves
+// its own averages whole, so with several running the last to save wins; each still estimates
+// from what it has timed itself.
+func (h *GenerateHandler) PersistStageTimings(ctx context.Context, store repository.StageTimingsRepository, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultStageTimingsInterval
+	}
+	timings, err := store.GetStageTimings(ctx)
+	if err != nil {
+		h.logger.Warn("Failed to load stage timings, estimating ETAs from defaults", zap.Error(err))
+	} else {
+		h.stageTimings.Load(timings)
+		h.logger.Info("Loaded stage timings", zap.Int("timings", len(timings)))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.saveStageTimings(ctx, store)
+		}
+	}
+}
+
+// saveStageTimings stores the stage timings if runs were recorded since they were last saved
+func (h *GenerateHandler) saveStageTimings(ctx context.Context, store repository.StageTimingsRepository) {
+	timings, changed := h.stageTimings.Changed()
+	if !changed {
+		return
+	}
+	if err := store.PutStageTimings(ctx, timings); err != nil {
+		h.logger.Warn("Failed to save stage timings", zap.Error(err))
+	}
+}
+
+// jobETA returns the seconds job has left as of now and when the pipeline made the estimate,
+// or zeros for a job without one
+func jobETA(job *domain.Job, now time.Time) (int, int64) {
+	seconds, ok := jobeta.Countdown(job, now)
+	if !ok {
+		return 0, 0
+	}
+	return seconds, job.ETAUpdatedAt
+}
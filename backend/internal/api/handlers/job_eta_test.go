@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobeta"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPersistStageTimings_LoadsAndSaves(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewLocalDynamoDB().StageTimingsRepository("stage-timings", zap.NewNop())
+	require.NoError(t, store.PutStageTimings(ctx, map[string]domain.StageTiming{
+		"composition/veo/16-30": {Seconds: 12, Samples: 8},
+	}))
+
+	h := &GenerateHandler{stageTimings: jobeta.NewEstimator(), logger: zap.NewNop()}
+	stopped, stop := context.WithCancel(ctx)
+	stop()
+	h.PersistStageTimings(stopped, store, time.Minute)
+
+	job := &domain.Job{
+		Status:          domain.StatusProcessing,
+		Stage:           domain.StageComposing,
+		Model:           "veo",
+		Duration:        24,
+		Scenes:          make([]domain.Scene, 3),
+		ScenesCompleted: 3,
+		AudioURL:        "https://example.com/music.mp3",
+	}
+	seconds, ok := h.stageTimings.Estimate(job)
+	require.True(t, ok)
+	require.Equal(t, 12, seconds, "timings stored by an earlier server are loaded")
+
+	h.saveStageTimings(ctx, store)
+	timings, err := store.GetStageTimings(ctx)
+	require.NoError(t, err)
+	require.Len(t, timings, 1, "nothing is saved until a run is recorded")
+
+	h.stageTimings.Record(job, domain.StepComposition, 20*time.Second)
+	h.saveStageTimings(ctx, store)
+	timings, err = store.GetStageTimings(ctx)
+	require.NoError(t, err)
+	saved := timings["composition/veo/16-30"]
+	require.Equal(t, 9, saved.Samples, "runs are averaged into the loaded timings")
+	require.InDelta(t, 12+8.0/9, saved.Seconds, 1e-9)
+}
+
+func TestJobETA(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	job := &domain.Job{Status: domain.StatusProcessing, ETASeconds: 100, ETAUpdatedAt: now.Add(-30 * time.Second).Unix()}
+
+	seconds, updatedAt := jobETA(job, now)
+	require.Equal(t, 70, seconds)
+	require.Equal(t, job.ETAUpdatedAt, updatedAt)
+
+	job.Status = domain.StatusCompleted
+	seconds, updatedAt = jobETA(job, now)
+	require.Zero(t, seconds, "a finished job's last estimate isn't reported")
+	require.Zero(t, updatedAt)
+}
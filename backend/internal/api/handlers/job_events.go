@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ListJobEvents page size bounds
+const (
+	defaultJobEventsPageSize = 100
+	maxJobEventsPageSize     = 500
+)
+
+// callEvent is the event for a finished provider call
+func callEvent(entry domain.ProvenanceEntry) domain.JobEvent {
+	return domain.JobEvent{
+		Type:       domain.JobEventCall,
+		Stage:      entry.Step,
+		Provider:   entry.Provider,
+		Model:      entry.Model,
+		Status:     entry.Status,
+		DurationMs: (entry.CompletedAt - entry.SubmittedAt) * 1000,
+	}
+}
+
+// appendJobEvent adds event to job's audit trail
+func (h *GenerateHandler) appendJobEvent(ctx context.Context, job *domain.Job, event domain.JobEvent) {
+	repository.RecordJobEvent(ctx, h.events, h.logger, job.JobID, event)
+}
+
+// recordStage records that job moved to its current stage, and how long the stage it finished
+// took when took is positive
+func (h *GenerateHandler) recordStage(ctx context.Context, job *domain.Job, message string, took time.Duration) {
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:       domain.JobEventStage,
+		Stage:      job.Stage.String(),
+		Message:    message,
+		DurationMs: took.Milliseconds(),
+	})
+}
+
+// recordWarning records something that failed without failing job. message is shown to the
+// job's owner, so it leaves out the internal error the logs carry.
+func (h *GenerateHandler) recordWarning(ctx context.Context, job *domain.Job, message string) {
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventWarning,
+		Stage:   job.Stage.String(),
+		Message: message,
+	})
+}
+
+// appendJobEvent adds event to job's audit trail
+func (h *RegenerateHandler) appendJobEvent(ctx context.Context, job *domain.Job, event domain.JobEvent) {
+	repository.RecordJobEvent(ctx, h.events, h.logger, job.JobID, event)
+}
+
+// jobEventJobs is the subset of the job repository the events endpoint reads
+type jobEventJobs interface {
+	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
+}
+
+// JobEventsHandler serves jobs' audit trails to their owners
+type JobEventsHandler struct {
+	jobRepo jobEventJobs
+	events  repository.JobEventsRepository
+	logger  *zap.Logger
+}
+
+// NewJobEventsHandler creates a new job events handler
+func NewJobEventsHandler(jobRepo *repository.DynamoDBRepository, events repository.JobEventsRepository, logger *zap.Logger) *JobEventsHandler {
+	return &JobEventsHandler{
+		jobRepo: jobRepo,
+		events:  events,
+		logger:  logger,
+	}
+}
+
+// ListJobEventsResponse is one page of a job's events, oldest first
+type ListJobEventsResponse struct {
+	JobID      string             `json:"job_id"`
+	Events     []*domain.JobEvent `json:"events"`
+	Count      int                `json:"count"`
+	PageSize   int                `json:"page_size"`
+	Dropped    int                `json:"dropped,omitempty"`     // Events not kept once the job reached its event limit
+	NextCursor string             `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// ListJobEvents handles GET /api/v1/jobs/:id/events
+// @Summary List a job's events
+// @Description Get a page of the job's audit trail, oldest first: stage transitions, provider calls and how long they took, warnings the job carried on past, reruns and how each run ended. Events are kept for 90 days.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param cursor query string false "Opaque cursor from a previous response's next_cursor"
+// @Param page_size query int false "Page size (1-500)" default(100)
+// @Success 200 {object} ListJobEventsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/events [get]
+// @Security BearerAuth
+func (h *JobEventsHandler) ListJobEvents(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := auth.MustGetUserID(c)
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultJobEventsPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxJobEventsPageSize {
+		pageSize = defaultJobEventsPageSize
+	}
+	startKey, err := repository.DecodeJobEventsCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+
+	job, err := h.jobRepo.GetJob(c.Request.Context(), jobID)
+	if err == nil && job.UserID != userID {
+		h.logger.Warn("User attempted to read events of job belonging to another user",
+			zap.String("job_id", jobID),
+			zap.String("job_user_id", job.UserID),
+			zap.String("requesting_user_id", userID),
+		)
+		err = repository.ErrJobNotFound
+	}
+	if stderrors.Is(err, repository.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get job", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	page, err := h.events.ListJobEvents(c.Request.Context(), jobID, repository.JobEventsQuery{
+		Limit:             pageSize,
+		ExclusiveStartKey: startKey,
+	})
+	if stderrors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list job events", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	nextCursor, err := repository.EncodeJobsCursor(page.LastEvaluatedKey)
+	if err != nil {
+		h.logger.Error("Failed to encode pagination cursor", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	events := page.Events
+	if events == nil {
+		events = []*domain.JobEvent{}
+	}
+	c.JSON(http.StatusOK, ListJobEventsResponse{
+		JobID:      jobID,
+		Events:     events,
+		Count:      len(events),
+		PageSize:   pageSize,
+		Dropped:    page.Dropped,
+		NextCursor: nextCursor,
+	})
+}
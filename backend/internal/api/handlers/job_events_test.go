@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeJobEventStore records the events appended to it
+type fakeJobEventStore struct {
+	mu     sync.Mutex
+	events []domain.JobEvent
+	err    error
+}
+
+func (f *fakeJobEventStore) AppendJobEvent(ctx context.Context, event domain.JobEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeJobEventStore) types() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	types := make([]string, len(f.events))
+	for i, event := range f.events {
+		types[i] = event.Type + ":" + event.Stage
+	}
+	return types
+}
+
+func TestGenerateScriptStep_RecordsStages(t *testing.T) {
+	h, _, job := complianceHandler(t, false)
+	events := &fakeJobEventStore{}
+	h.events = events
+
+	_, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.True(t, ok)
+
+	require.Equal(t, []string{"stage:script_generating", "stage:script_complete"}, events.types())
+	written := events.events[1]
+	require.Equal(t, job.JobID, written.JobID)
+	require.Equal(t, "Script written with 2 scenes", written.Message)
+	require.NotZero(t, written.Time)
+}
+
+func TestGenerateScriptStep_WarnsRepeatedScenes(t *testing.T) {
+	h, _, job := complianceHandler(t, false)
+	events := &fakeJobEventStore{}
+	h.events = events
+	// The retry returns the same draft, so its repeated scenes are kept with a warning
+	script := paraphrasedPharmaScript()
+	script.Scenes[1].Action = script.Scenes[0].Action
+	script.Scenes[1].GenerationPrompt = script.Scenes[0].GenerationPrompt
+	h.parserService = service.NewParserService(fixedScriptGenerator{script}, zap.NewNop())
+
+	_, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.True(t, ok)
+
+	require.Equal(t, []string{"stage:script_generating", "warning:script_generating", "stage:script_complete"}, events.types())
+	require.Contains(t, events.events[1].Message, "Scenes 1 and 2 are nearly identical (100% similar)")
+}
+
+func TestGenerateScriptStep_RecordsFailure(t *testing.T) {
+	h, repo, job := complianceHandler(t, true)
+	events := &fakeJobEventStore{}
+	h.events = events
+
+	_, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.False(t, ok)
+
+	require.Equal(t, []string{"stage:script_generating", "failed:script_generating"}, events.types())
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, *stored.ErrorMessage, events.events[1].Message, "the event carries the error the owner already sees")
+}
+
+func TestGenerateScriptStep_FailsJobResumedPastTheScript(t *testing.T) {
+	h, repo, job := complianceHandler(t, false)
+	events := &fakeJobEventStore{}
+	h.events = events
+	job.Stage = domain.StageComposing
+
+	_, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.False(t, ok)
+
+	require.Equal(t, []string{"failed:composing"}, events.types())
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusFailed, stored.Status)
+	require.Contains(t, *stored.ErrorMessage, stageFailureMessage)
+	require.Equal(t, "composing", stored.FailureStage)
+}
+
+func TestWithProvenance_RecordsCallEvents(t *testing.T) {
+	events := &fakeJobEventStore{}
+	ctx := withProvenance(context.Background(), nil, events, zap.NewNop(), "job-1", "sfx", nil)
+
+	_, err := requestSFX(ctx, pollingSFX("p-bad", &adapters.SFXGenerationResult{PredictionID: "p-bad", Status: "failed", Error: "model crashed"}), "rain", 3, zap.NewNop())
+	require.Error(t, err)
+
+	require.Equal(t, []string{"call:sfx"}, events.types())
+	call := events.events[0]
+	require.Equal(t, "job-1", call.JobID)
+	require.Equal(t, "replicate", call.Provider)
+	require.Equal(t, "failed", call.Status)
+	require.Empty(t, call.Message, "provider errors aren't shown to the owner")
+}
+
+func TestAppendJobEvent_BestEffort(t *testing.T) {
+	h := &GenerateHandler{events: &fakeJobEventStore{err: stderrors.New("table unavailable")}, logger: zap.NewNop()}
+	h.recordStage(context.Background(), &domain.Job{JobID: "job-1"}, "Writing the script", 0)
+
+	// Without a store nothing is recorded
+	(&GenerateHandler{logger: zap.NewNop()}).recordWarning(context.Background(), &domain.Job{JobID: "job-1"}, "Audio ready")
+}
+
+func newTestJobEventsHandler(t *testing.T) (*gin.Engine, repository.JobEventsRepository) {
+	local := repository.NewLocalDynamoDB()
+	jobs := local.JobRepository("jobs", zap.NewNop())
+	events := local.JobEventRepository("job-events", zap.NewNop())
+	for _, job := range []*domain.Job{
+		{JobID: "job-1", UserID: "user-123", Status: domain.StatusCompleted},
+		{JobID: "job-2", UserID: "user-456", Status: domain.StatusCompleted},
+	} {
+		require.NoError(t, jobs.CreateJob(context.Background(), job))
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.GET("/jobs/:id/events", NewJobEventsHandler(jobs, events, zap.NewNop()).ListJobEvents)
+	return router, events
+}
+
+func getJobEvents(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestListJobEvents(t *testing.T) {
+	router, events := newTestJobEventsHandler(t)
+	for _, event := range []domain.JobEvent{
+		{JobID: "job-1", Type: domain.JobEventStage, Stage: "script_generating"},
+		{JobID: "job-1", Type: domain.JobEventCall, Stage: "script", Provider: "openai"},
+		{JobID: "job-1", Type: domain.JobEventCompleted, Stage: "completed"},
+		{JobID: "job-2", Type: domain.JobEventStage, Stage: "script_generating"},
+	} {
+		require.NoError(t, events.AppendJobEvent(context.Background(), event))
+	}
+
+	w := getJobEvents(router, "/api/v1/jobs/job-1/events?page_size=2")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var first ListJobEventsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+	require.Equal(t, 2, first.Count)
+	require.Equal(t, []int64{1, 2}, []int64{first.Events[0].Sequence, first.Events[1].Sequence})
+	require.NotEmpty(t, first.NextCursor)
+
+	w = getJobEvents(router, "/api/v1/jobs/job-1/events?page_size=2&cursor="+first.NextCursor)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var second ListJobEventsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+	require.Len(t, second.Events, 1)
+	require.Equal(t, domain.JobEventCompleted, second.Events[0].Type)
+	require.Empty(t, second.NextCursor)
+
+	// Another user's job is indistinguishable from a missing one
+	require.Equal(t, http.StatusNotFound, getJobEvents(router, "/api/v1/jobs/job-2/events").Code)
+	require.Equal(t, http.StatusNotFound, getJobEvents(router, "/api/v1/jobs/missing/events").Code)
+
+	require.Equal(t, http.StatusBadRequest, getJobEvents(router, "/api/v1/jobs/job-1/events?cursor=not-a-cursor").Code)
+}
+
+func TestListJobEvents_NoEvents(t *testing.T) {
+	router, _ := newTestJobEventsHandler(t)
+
+	w := getJobEvents(router, "/api/v1/jobs/job-1/events")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(t, `{"job_id":"job-1","events":[],"count":0,"page_size":100}`, w.Body.String())
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// MaxConcurrentExports is how many exports one user may have running at once
+const MaxConcurrentExports = 2
+
+const (
+	// exportTimeout bounds one export; a 60s job's assets are a few hundred MB
+	exportTimeout = 30 * time.Minute
+
+	// exportIDLayout names each export by when it started, e.g. exports/20261014T153000Z.zip
+	exportIDLayout = "20060102T150405Z"
+
+	// exportTagging marks export archives so the bucket's lifecycle rules expire them early
+	exportTagging = "omnigen-export=true"
+)
+
+// Export statuses
+const (
+	ExportProcessing = "processing"
+	ExportCompleted  = "completed"
+	ExportFailed     = "failed"
+)
+
+// exportAllowlist is the assets an export includes, as path.Match patterns relative to the job's
+// prefix. Raw music, sound effects before mixing and earlier exports are left out.
+var exportAllowlist = []string{
+	"final/video.mp4",
+	"final/video.mov",
+	"final/video.webm",
+	"clips/scene-*.mp4",
+	"audio/background-music.mp3",
+	"audio/narrator-voiceover.mp3",
+	"audio/scene-*-voiceover.mp3",
+	"thumbnails/*.jpg",
+	"thumbnails/*.webp",
+	"captions/*.vtt",
+}
+
+// exportFolderUnsafe matches the characters dropped from an export's top-level folder name
+var exportFolderUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ExportResponse describes one export of a job's assets
+type ExportResponse struct {
+	ExportID     string `json:"export_id"`
+	Status       string `json:"status"` // processing, completed or failed
+	CreatedAt    int64  `json:"created_at"`
+	SizeBytes    int64  `json:"size_bytes,omitempty"`
+	DownloadURL  string `json:"download_url,omitempty"`
+	URLExpiresAt int64  `json:"url_expires_at,omitempty"` // When download_url stops working
+	Error        string `json:"error,omitempty"`
+}
+
+// ListExportsResponse lists a job's exports, newest first
+type ListExportsResponse struct {
+	Exports []ExportResponse `json:"exports"`
+	Count   int              `json:"count"`
+}
+
+// jobExportStore is the subset of the S3 repository needed to export a job's assets
+type jobExportStore interface {
+	ListObjectSizes(ctx context.Context, bucket, prefix string) (map[string]int64, error)
+	OpenObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	UploadStream(ctx context.Context, bucket, key string, body io.Reader, contentType, tagging string) error
+	GetPresignedURLInBucket(ctx context.Context, bucket, key string, duration time.Duration) (string, error)
+}
+
+// jobExportJobs is the subset of the job repository needed to export a job's assets
+type jobExportJobs interface {
+	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
+}
+
+// ExportsHandler zips a job's assets into a single download
+type ExportsHandler struct {
+	jobRepo      jobExportJobs
+	store        jobExportStore
+	assetsBucket string
+	running      *exportTracker
+	logger       *zap.Logger
+}
+
+// NewExportsHandler creates a new exports handler
+func NewExportsHandler(jobRepo *repository.DynamoDBRepository, store jobExportStore, assetsBucket string, logger *zap.Logger) *ExportsHandler {
+	return &ExportsHandler{
+		jobRepo:      jobRepo,
+		store:        store,
+		assetsBucket: assetsBucket,
+		running:      newExportTracker(),
+		logger:       logger,
+	}
+}
+
+// CreateExport handles POST /api/v1/jobs/:id/export
+// @Summary Export a job's assets
+// @Description Starts zipping the final video, scene clips, audio, thumbnails and captions of a completed job. List exports to download the archive once it is ready.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} ExportResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 429 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/export [post]
+// @Security BearerAuth
+func (h *ExportsHandler) CreateExport(c *gin.Context) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != domain.StatusCompleted {
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict, "Only completed jobs can be exported", map[string]interface{}{
+				"status": job.Status,
+			}),
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	export := &ExportResponse{ExportID: now.Format(exportIDLayout), Status: ExportProcessing, CreatedAt: now.Unix()}
+	if !h.running.start(job.UserID, job.JobID, export) {
+		c.JSON(http.StatusTooManyRequests, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrRateLimited,
+				fmt.Sprintf("You can run %d exports at once. Try again when one finishes.", MaxConcurrentExports), nil),
+		})
+		return
+	}
+
+	ctx := trace.WithJobID(trace.Detach(c.Request.Context()), job.JobID)
+	go h.runExport(ctx, job, export)
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// ListExports handles GET /api/v1/jobs/:id/exports
+// @Summary List a job's exports
+// @Description Lists exports of a job's assets, newest first, with download URLs for finished archives
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} ListExportsResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/exports [get]
+// @Security BearerAuth
+func (h *ExportsHandler) ListExports(c *gin.Context) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+
+	bucket := jobAssetBucket(job, h.assetsBucket)
+	prefix := exportPrefix(job)
+	sizes, err := h.store.ListObjectSizes(c.Request.Context(), bucket, prefix)
+	if err != nil {
+		h.logger.Error("Failed to list exports", zap.String("job_id", job.JobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrStorageError,
+		})
+		return
+	}
+
+	exports := h.running.list(job.JobID)
+	urlExpiresAt := time.Now().Add(AssetURLExpiry).Unix()
+	for key, size := range sizes {
+		id := strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".zip")
+		created, err := time.Parse(exportIDLayout, id)
+		if err != nil {
+			continue
+		}
+		url, err := h.store.GetPresignedURLInBucket(c.Request.Context(), bucket, key, AssetURLExpiry)
+		if err != nil {
+			h.logger.Warn("Failed to presign export", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		exports = append(exports, ExportResponse{
+			ExportID:     id,
+			Status:       ExportCompleted,
+			CreatedAt:    created.Unix(),
+			SizeBytes:    size,
+			DownloadURL:  url,
+			URLExpiresAt: urlExpiresAt,
+		})
+	}
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].ExportID > exports[j].ExportID
+	})
+
+	c.JSON(http.StatusOK, ListExportsResponse{Exports: exports, Count: len(exports)})
+}
+
+// ownedJob loads the job named in the path, responding 404 if it doesn't belong to the user
+func (h *ExportsHandler) ownedJob(c *gin.Context) (*domain.Job, bool) {
+	jobID := c.Param("id")
+	userID := auth.MustGetUserID(c)
+
+	job, err := h.jobRepo.GetJob(c.Request.Context(), jobID)
+	if err == nil && job.UserID != userID {
+		h.logger.Warn("User attempted to export job belonging to another user",
+			zap.String("job_id", jobID),
+			zap.String("job_user_id", job.UserID),
+			zap.String("requesting_user_id", userID),
+		)
+		err = repository.ErrJobNotFound
+	}
+	if err == repository.ErrJobNotFound {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("Failed to get job", zap.String("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return nil, false
+	}
+	return job, true
+}
+
+// runExport zips the job's allowlisted assets into an archive under its exports prefix. The
+// archive is streamed: each asset is copied from S3 into the ZIP as the upload reads it, so
+// memory use doesn't grow with the job's size.
+func (h *ExportsHandler) runExport(ctx context.Context, job *domain.Job, export *ExportResponse) {
+	log := trace.Logger(ctx, h.logger)
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			log.Error("Job export failed", zap.String("export_id", export.ExportID), zap.Error(err))
+		}
+		h.running.finish(job.UserID, job.JobID, export.ExportID, err)
+	}()
+
+	bucket := jobAssetBucket(job, h.assetsBucket)
+	prefix := jobAssetPrefix(job)
+	sizes, err := h.store.ListObjectSizes(ctx, bucket, prefix)
+	if err != nil {
+		return
+	}
+	keys := exportKeys(prefix, sizes)
+	if len(keys) == 0 {
+		err = fmt.Errorf("job has no assets to export")
+		return
+	}
+
+	key := exportKey(job, export.ExportID)
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(h.writeExportZip(ctx, writer, bucket, exportFolder(job), prefix, keys))
+	}()
+	err = h.store.UploadStream(ctx, bucket, key, reader, "application/zip", exportTagging)
+	// Unblocks the ZIP writer if the upload stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return
+	}
+
+	log.Info("Job export complete",
+		zap.String("export_id", export.ExportID),
+		zap.String("key", key),
+		zap.Int("files", len(keys)),
+	)
+}
+
+// writeExportZip writes each key in bucket into a ZIP under folder, one after the other. Entries are
+// stored rather than deflated: the videos, audio and images are already compressed.
+func (h *ExportsHandler) writeExportZip(ctx context.Context, w io.Writer, bucket, folder, prefix string, keys []string) error {
+	archive := zip.NewWriter(w)
+	modified := time.Now()
+	for _, key := range keys {
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     exportEntryName(folder, prefix, key),
+			Method:   zip.Store,
+			Modified: modified,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add %s to export: %w", key, err)
+		}
+		object, err := h.store.OpenObject(ctx, bucket, key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s for export: %w", key, err)
+		}
+		_, err = io.Copy(entry, object)
+		object.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy %s into export: %w", key, err)
+		}
+	}
+	return archive.Close()
+}
+
+// exportKeys returns the keys under prefix an export includes, in name order
+func exportKeys(prefix string, sizes map[string]int64) []string {
+	var keys []string
+	for key := range sizes {
+		if exportable(strings.TrimPrefix(key, prefix)) && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exportable reports whether an asset, named relative to its job's prefix, is on the allowlist
+func exportable(name string) bool {
+	for _, pattern := range exportAllowlist {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// exportEntryName is the path of an asset inside an export: its path under the job's prefix,
+// inside a folder named for the job
+func exportEntryName(folder, prefix, key string) string {
+	return folder + "/" + strings.TrimPrefix(key, prefix)
+}
+
+// exportFolder names an export's top-level folder after the job's title, e.g.
+// "sunrise-10k-job-1234", falling back to the job ID alone
+func exportFolder(job *domain.Job) string {
+	slug := strings.Trim(exportFolderUnsafe.ReplaceAllString(strings.ToLower(job.Title), "-"), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	if slug == "" {
+		return job.JobID
+	}
+	return slug + "-" + job.JobID
+}
+
+// exportPrefix is the S3 prefix a job's export archives are stored under
+func exportPrefix(job *domain.Job) string {
+	return jobAssetPrefix(job) + "exports/"
+}
+
+// exportKey returns the S3 key of one export archive
+func exportKey(job *domain.Job, exportID string) string {
+	return exportPrefix(job) + exportID + ".zip"
+}
+
+// exportTracker tracks the exports running on this server, and the last failure of each job
+// until its next export starts. Finished archives are found by listing S3.
+type exportTracker struct {
+	mu      sync.Mutex
+	perUser map[string]int
+	byJob   map[string][]*ExportResponse
+}
+
+func newExportTracker() *exportTracker {
+	return &exportTracker{perUser: make(map[string]int), byJob: make(map[string][]*ExportResponse)}
+}
+
+// start records a new export, returning false if the user is at MaxConcurrentExports
+func (t *exportTracker) start(userID, jobID string, export *ExportResponse) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.perUser[userID] >= MaxConcurrentExports {
+		return false
+	}
+	t.perUser[userID]++
+
+	kept := []*ExportResponse{export}
+	for _, e := range t.byJob[jobID] {
+		if e.Status == ExportProcessing {
+			kept = append(kept, e)
+		}
+	}
+	t.byJob[jobID] = kept
+	return true
+}
+
+// finish records an export's outcome; a successful one is dropped, as S3 now lists it
+func (t *exportTracker) finish(userID, jobID, exportID string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.perUser[userID]--; t.perUser[userID] <= 0 {
+		delete(t.perUser, userID)
+	}
+
+	var kept []*ExportResponse
+	for _, e := range t.byJob[jobID] {
+		if e.ExportID == exportID {
+			if err == nil {
+				continue
+			}
+			e.Status, e.Error = ExportFailed, "Export failed. Please try again."
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) == 0 {
+		delete(t.byJob, jobID)
+		return
+	}
+	t.byJob[jobID] = kept
+}
+
+// list returns copies of the job's running and failed exports
+func (t *exportTracker) list(jobID string) []ExportResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	exports := make([]ExportResponse, 0, len(t.byJob[jobID]))
+	for _, e := range t.byJob[jobID] {
+		exports = append(exports, *e)
+	}
+	return exports
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeExportStore keeps objects in memory; uploads wait on release when it is set
+type fakeExportStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	release chan struct{}
+	done    chan string
+}
+
+func newFakeExportStore(objects map[string]string) *fakeExportStore {
+	s := &fakeExportStore{objects: make(map[string][]byte), done: make(chan string, 10)}
+	for key, body := range objects {
+		s.objects[key] = []byte(body)
+	}
+	return s
+}
+
+func (s *fakeExportStore) ListObjectSizes(_ context.Context, _, prefix string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make(map[string]int64)
+	for key, body := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			sizes[key] = int64(len(body))
+		}
+	}
+	return sizes, nil
+}
+
+func (s *fakeExportStore) OpenObject(_ context.Context, _, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return io.NopCloser(bytes.NewReader(s.objects[key])), nil
+}
+
+func (s *fakeExportStore) UploadStream(_ context.Context, _, key string, body io.Reader, _, _ string) error {
+	if s.release != nil {
+		<-s.release
+	}
+	data, err := io.ReadAll(body)
+	if err == nil {
+		s.mu.Lock()
+		s.objects[key] = data
+		s.mu.Unlock()
+	}
+	s.done <- key
+	return err
+}
+
+func (s *fakeExportStore) GetPresignedURLInBucket(_ context.Context, bucket, key string, _ time.Duration) (string, error) {
+	return "https://signed.example.com/" + bucket + "/" + key, nil
+}
+
+var jobAssets = map[string]string{
+	"users/user-123/jobs/job-1/final/video.mp4":                  "final",
+	"users/user-123/jobs/job-1/clips/scene-001.mp4":              "clip 1",
+	"users/user-123/jobs/job-1/clips/scene-002-v2.mp4":           "clip 2",
+	"users/user-123/jobs/job-1/audio/background-music.mp3":       "music",
+	"users/user-123/jobs/job-1/audio/background-music-raw.mp3":   "raw music",
+	"users/user-123/jobs/job-1/audio/narrator-voiceover.mp3":     "narrator",
+	"users/user-123/jobs/job-1/audio/scene-001-voiceover.mp3":    "voiceover",
+	"users/user-123/jobs/job-1/audio/sfx/scene-001-whoosh.mp3":   "sfx",
+	"users/user-123/jobs/job-1/thumbnails/job-thumbnail-320.jpg": "thumb",
+	"users/user-123/jobs/job-1/captions/narrator.vtt":            "WEBVTT",
+	"users/user-123/jobs/job-1/exports/20261014T100000Z.zip":     "old export",
+	"users/user-123/jobs/job-1/tmp/scene-001.mp4":                "scratch",
+}
+
+func TestExportKeys_Allowlist(t *testing.T) {
+	sizes := make(map[string]int64)
+	for key := range jobAssets {
+		sizes[key] = 1
+	}
+	sizes["users/user-123/jobs/job-10/final/video.mp4"] = 1
+
+	require.Equal(t, []string{
+		"users/user-123/jobs/job-1/audio/background-music.mp3",
+		"users/user-123/jobs/job-1/audio/narrator-voiceover.mp3",
+		"users/user-123/jobs/job-1/audio/scene-001-voiceover.mp3",
+		"users/user-123/jobs/job-1/captions/narrator.vtt",
+		"users/user-123/jobs/job-1/clips/scene-001.mp4",
+		"users/user-123/jobs/job-1/clips/scene-002-v2.mp4",
+		"users/user-123/jobs/job-1/final/video.mp4",
+		"users/user-123/jobs/job-1/thumbnails/job-thumbnail-320.jpg",
+	}, exportKeys("users/user-123/jobs/job-1/", sizes))
+}
+
+func TestExportEntryNames(t *testing.T) {
+	require.Equal(t, "sunrise-10k-job-1", exportFolder(&domain.Job{JobID: "job-1", Title: "Sunrise 10K!"}))
+	require.Equal(t, "job-1", exportFolder(&domain.Job{JobID: "job-1", Title: "***"}))
+	require.Equal(t, "job-1", exportFolder(&domain.Job{JobID: "job-1"}))
+	require.LessOrEqual(t, len(exportFolder(&domain.Job{JobID: "job-1", Title: strings.Repeat("long title ", 10)})), 50+len("-job-1"))
+
+	require.Equal(t, "sunrise-10k-job-1/clips/scene-001.mp4",
+		exportEntryName("sunrise-10k-job-1", "users/user-123/jobs/job-1/", "users/user-123/jobs/job-1/clips/scene-001.mp4"))
+	require.Equal(t, "users/user-123/jobs/job-1/exports/20261014T100000Z.zip", exportKey(&domain.Job{UserID: "user-123", JobID: "job-1"}, "20261014T100000Z"))
+}
+
+// exportsTestRouter serves the export routes as user-123, with a completed job of theirs, a
+// running one, and one of user-456
+func exportsTestRouter(t *testing.T, store *fakeExportStore) *gin.Engine {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	for _, job := range []*domain.Job{
+		{JobID: "job-1", UserID: "user-123", Status: domain.StatusCompleted, Title: "Sunrise 10K", CreatedAt: 1000},
+		{JobID: "job-2", UserID: "user-123", Status: domain.StatusCompleted, Title: "Second", CreatedAt: 1000},
+		{JobID: "job-3", UserID: "user-123", Status: domain.StatusCompleted, Title: "Third", CreatedAt: 1000},
+		{JobID: "job-running", UserID: "user-123", Status: domain.StatusProcessing, CreatedAt: 1000},
+		{JobID: "job-other", UserID: "user-456", Status: domain.StatusCompleted, CreatedAt: 1000},
+	} {
+		require.NoError(t, repo.CreateJob(context.Background(), job))
+	}
+
+	h := NewExportsHandler(repo, store, "assets", zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.POST("/jobs/:id/export", h.CreateExport)
+	v1.GET("/jobs/:id/exports", h.ListExports)
+	return router
+}
+
+func serveExports(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestCreateExport_ZipsAllowlistedAssets(t *testing.T) {
+	store := newFakeExportStore(jobAssets)
+	router := exportsTestRouter(t, store)
+
+	w := serveExports(router, http.MethodPost, "/api/v1/jobs/job-1/export")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var export ExportResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	require.Equal(t, ExportProcessing, export.Status)
+
+	key := <-store.done
+	require.Equal(t, exportKey(&domain.Job{UserID: "user-123", JobID: "job-1"}, export.ExportID), key)
+
+	store.mu.Lock()
+	archive := store.objects[key]
+	store.mu.Unlock()
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	require.Equal(t, []string{
+		"sunrise-10k-job-1/audio/background-music.mp3",
+		"sunrise-10k-job-1/audio/narrator-voiceover.mp3",
+		"sunrise-10k-job-1/audio/scene-001-voiceover.mp3",
+		"sunrise-10k-job-1/captions/narrator.vtt",
+		"sunrise-10k-job-1/clips/scene-001.mp4",
+		"sunrise-10k-job-1/clips/scene-002-v2.mp4",
+		"sunrise-10k-job-1/final/video.mp4",
+		"sunrise-10k-job-1/thumbnails/job-thumbnail-320.jpg",
+	}, names)
+	final, err := reader.File[6].Open()
+	require.NoError(t, err)
+	body, err := io.ReadAll(final)
+	require.NoError(t, err)
+	require.Equal(t, "final", string(body))
+
+	// The new export is listed alongside the earlier one, newest first, once it is finished
+	require.Eventually(t, func() bool {
+		w = serveExports(router, http.MethodGet, "/api/v1/jobs/job-1/exports")
+		var list ListExportsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return list.Count == 2 && list.Exports[0].Status == ExportCompleted
+	}, time.Second, 10*time.Millisecond)
+	var list ListExportsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Equal(t, export.ExportID, list.Exports[0].ExportID)
+	require.Equal(t, int64(len(archive)), list.Exports[0].SizeBytes)
+	require.Equal(t, "https://signed.example.com/assets/"+key, list.Exports[0].DownloadURL, "presigned in the bucket the export was written to")
+	require.NotZero(t, list.Exports[0].URLExpiresAt)
+	require.Equal(t, "20261014T100000Z", list.Exports[1].ExportID)
+}
+
+func TestCreateExport_LimitsConcurrentExports(t *testing.T) {
+	store := newFakeExportStore(map[string]string{
+		"users/user-123/jobs/job-1/final/video.mp4": "one",
+		"users/user-123/jobs/job-2/final/video.mp4": "two",
+		"users/user-123/jobs/job-3/final/video.mp4": "three",
+	})
+	store.release = make(chan struct{})
+	router := exportsTestRouter(t, store)
+
+	require.Equal(t, http.StatusAccepted, serveExports(router, http.MethodPost, "/api/v1/jobs/job-1/export").Code)
+	require.Equal(t, http.StatusAccepted, serveExports(router, http.MethodPost, "/api/v1/jobs/job-2/export").Code)
+	require.Equal(t, http.StatusTooManyRequests, serveExports(router, http.MethodPost, "/api/v1/jobs/job-3/export").Code)
+
+	w := serveExports(router, http.MethodGet, "/api/v1/jobs/job-1/exports")
+	var list ListExportsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Exports, 1)
+	require.Equal(t, ExportProcessing, list.Exports[0].Status)
+
+	// Finishing an export frees its slot
+	store.release <- struct{}{}
+	<-store.done
+	require.Eventually(t, func() bool {
+		return serveExports(router, http.MethodPost, "/api/v1/jobs/job-3/export").Code == http.StatusAccepted
+	}, time.Second, 10*time.Millisecond)
+	close(store.release)
+}
+
+func TestCreateExport_RequiresOwnedCompletedJob(t *testing.T) {
+	router := exportsTestRouter(t, newFakeExportStore(nil))
+
+	require.Equal(t, http.StatusNotFound, serveExports(router, http.MethodPost, "/api/v1/jobs/job-other/export").Code)
+	require.Equal(t, http.StatusNotFound, serveExports(router, http.MethodGet, "/api/v1/jobs/job-other/exports").Code)
+	require.Equal(t, http.StatusNotFound, serveExports(router, http.MethodPost, "/api/v1/jobs/job-missing/export").Code)
+	require.Equal(t, http.StatusConflict, serveExports(router, http.MethodPost, "/api/v1/jobs/job-running/export").Code)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+)
+
+// saveJobProgress writes the pipeline's copy of a job. If another request wrote the job since
+// the pipeline read it, the stored job is reloaded and the pipeline's output is written on top,
+// so the other request's changes survive.
+// Assets uploaded since the last save are written too, and counted against the user's storage
+// once the write succeeds.
+func (h *GenerateHandler) saveJobProgress(ctx context.Context, job *domain.Job) error {
+	ledger := repository.AssetLedgerFrom(ctx)
+	total := ledger.Stage(job)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, job, pipelineOutput(job)); err != nil {
+		return err
+	}
+	countStorage(ctx, h.storage, job.UserID, ledger.Commit(total), h.logger)
+	return nil
+}
+
+// pipelineOutput snapshots the fields the pipeline produces and returns a function that copies
+// them onto a freshly read job
+func pipelineOutput(job *domain.Job) func(*domain.Job) {
+	out := *job
+	return func(dst *domain.Job) {
+		dst.Status = out.Status
+		dst.Stage = out.Stage
+		dst.TTL = out.TTL
+
+		dst.ScriptID = out.ScriptID
+		if !dst.TitleEdited { // Renamed by the owner meanwhile
+			dst.Title = out.Title
+		}
+		dst.Scenes = out.Scenes
+		dst.AudioSpec = out.AudioSpec
+		dst.ScriptMetadata = out.ScriptMetadata
+		dst.SideEffectsText = out.SideEffectsText
+		dst.SideEffectsStartTime = out.SideEffectsStartTime
+		dst.ComplianceIssues = out.ComplianceIssues
+		dst.ModerationFlags = out.ModerationFlags
+
+		dst.ScenesCompleted = out.ScenesCompleted
+		dst.ETASeconds = out.ETASeconds
+		dst.ETAUpdatedAt = out.ETAUpdatedAt
+		dst.SceneVideoURLs = out.SceneVideoURLs
+		dst.ThumbnailURL = out.ThumbnailURL
+		dst.Thumbnails = out.Thumbnails
+		dst.SceneVersions = out.SceneVersions
+		dst.ClipVersions = out.ClipVersions
+		dst.SceneVoiceovers = out.SceneVoiceovers
+		dst.Assets = out.Assets
+		dst.ContinuityStyle = out.ContinuityStyle
+
+		dst.AudioURL = out.AudioURL
+		dst.NarratorAudioURL = out.NarratorAudioURL
+		dst.DisclaimerSpec = out.DisclaimerSpec
+		dst.NarrationBudget = out.NarrationBudget
+		dst.NarrationWords = out.NarrationWords
+		dst.NarrationDuration = out.NarrationDuration
+		dst.NarrationSpeed = out.NarrationSpeed
+		dst.NarrationTruncated = out.NarrationTruncated
+		dst.MusicProvider = out.MusicProvider
+		dst.MusicRawURL = out.MusicRawURL
+		dst.MusicRawDuration = out.MusicRawDuration
+		dst.MusicFit = out.MusicFit
+		dst.MusicLoopCount = out.MusicLoopCount
+		dst.MusicSyncOffset = out.MusicSyncOffset
+		dst.MusicLoudness = out.MusicLoudness
+		dst.NarrationLoudness = out.NarrationLoudness
+		dst.SFX = out.SFX
+		dst.Captions = out.Captions
+		dst.CaptionsKey = out.CaptionsKey
+		dst.ScrubSpriteKey = out.ScrubSpriteKey
+		dst.ScrubVTTKey = out.ScrubVTTKey
+		dst.Encoding = out.Encoding
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineOutputKeepsOtherWritersFields(t *testing.T) {
+	pipeline := &domain.Job{
+		JobID:           "job-1",
+		Status:          domain.StatusProcessing,
+		Stage:           domain.SceneComplete(2),
+		ScenesCompleted: 2,
+		SceneVideoURLs:  []string{"s3://clips/scene-001.mp4", "s3://clips/scene-002.mp4"},
+		SceneVersions:   map[int]int{1: 1, 2: 1},
+		Version:         3,
+	}
+	apply := pipelineOutput(pipeline)
+
+	// Later pipeline changes aren't part of this write
+	pipeline.Stage = domain.SceneGenerating(3)
+
+	fresh := &domain.Job{
+		JobID:           "job-1",
+		Status:          domain.StatusProcessing,
+		Stage:           domain.SceneGenerating(2),
+		ScenesCompleted: 1,
+		WebhookAttempts: 1,
+		CallbackURL:     "https://example.com/hook",
+		Version:         5,
+	}
+	apply(fresh)
+
+	require.Equal(t, domain.SceneComplete(2), fresh.Stage)
+	require.Equal(t, 2, fresh.ScenesCompleted)
+	require.Equal(t, []string{"s3://clips/scene-001.mp4", "s3://clips/scene-002.mp4"}, fresh.SceneVideoURLs)
+	require.Equal(t, map[int]int{1: 1, 2: 1}, fresh.SceneVersions)
+	require.Equal(t, 1, fresh.WebhookAttempts)
+	require.Equal(t, "https://example.com/hook", fresh.CallbackURL)
+	require.Equal(t, int64(5), fresh.Version, "the reloaded version is the one the retry must match")
+}
+
+func TestCurrentCompositionProgress(t *testing.T) {
+	progress := &domain.CompositionProgress{Pass: "webm", Percent: 30}
+	require.Equal(t, progress, currentCompositionProgress(&domain.Job{Stage: domain.StageComposing, CompositionProgress: progress}))
+	require.Nil(t, currentCompositionProgress(&domain.Job{Stage: domain.SceneGenerating(1), CompositionProgress: progress}), "a report from an earlier run is stale")
+}
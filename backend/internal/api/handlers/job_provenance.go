@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// provenanceStore is the subset of the job repository that records provider calls on a job
+type provenanceStore interface {
+	AppendJobProvenance(ctx context.Context, jobID string, entry domain.ProvenanceEntry) (int64, error)
+}
+
+// withProvenance returns ctx with every provider call the adapters finish on it appended to the
+// job's provenance under step, as the call finishes, and recorded as a call event in the job's
+// trail. onRecorded, if set, runs with each entry once it has been stored and the job version
+// the append produced.
+func withProvenance(
+	ctx context.Context,
+	store provenanceStore,
+	events repository.JobEventAppender,
+	logger *zap.Logger,
+	jobID, step string,
+	onRecorded func(entry domain.ProvenanceEntry, version int64),
+) context.Context {
+	if store == nil && events == nil {
+		return ctx
+	}
+	return adapters.WithProvenance(ctx, func(entry domain.ProvenanceEntry) {
+		entry.Step = step
+		repository.RecordJobEvent(ctx, events, logger, jobID, callEvent(entry))
+		if store == nil {
+			return
+		}
+		// Detached so a canceled job still records the prediction it abandoned
+		version, err := store.AppendJobProvenance(trace.Detach(ctx), jobID, entry)
+		if err != nil {
+			trace.Logger(ctx, logger).Warn("Failed to record job provenance",
+				zap.String("step", step),
+				zap.String("prediction_id", entry.PredictionID),
+				zap.Error(err),
+			)
+			return
+		}
+		if onRecorded != nil {
+			onRecorded(entry, version)
+		}
+	})
+}
+
+// withProvenance records the provider calls made on ctx under step. The pipeline saves its
+// progress with UpdateJobWithRetry, which reloads the job (and its provenance) after an append.
+func (h *GenerateHandler) withProvenance(ctx context.Context, job *domain.Job, step string) context.Context {
+	return withProvenance(ctx, h.provenance, h.events, h.logger, job.JobID, step, nil)
+}
+
+// withProvenance records the provider calls made on ctx under step, keeping job's version and
+// provenance in step with each append so the whole-item write that ends a regeneration neither
+// conflicts with the entries nor drops them. Regeneration makes its calls one at a time.
+func (h *RegenerateHandler) withProvenance(ctx context.Context, job *domain.Job, step string) context.Context {
+	return withProvenance(ctx, h.provenance, h.events, h.logger, job.JobID, step, func(entry domain.ProvenanceEntry, version int64) {
+		if version == job.Version+1 {
+			job.Version = version
+			job.Provenance = append(job.Provenance, entry)
+		}
+	})
+}
+
+// ProvenanceResponse is one provider call made for a job, as shown to its owner. The provider's
+// raw error is left out; support staff see it through the admin API.
+type ProvenanceResponse struct {
+	Step         string `json:"step"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model,omitempty"`
+	PredictionID string `json:"prediction_id,omitempty"`
+	SubmittedAt  int64  `json:"submitted_at"`
+	CompletedAt  int64  `json:"completed_at"`
+	Status       string `json:"status"`
+	Seed         *int64 `json:"seed,omitempty"` // Seed a video clip was generated with; pass it to regenerate to reproduce the clip
+}
+
+// buildProvenanceResponses converts a job's provenance for its owner
+func buildProvenanceResponses(job *domain.Job) []ProvenanceResponse {
+	if len(job.Provenance) == 0 {
+		return nil
+	}
+	entries := make([]ProvenanceResponse, len(job.Provenance))
+	for i, entry := range job.Provenance {
+		entries[i] = ProvenanceResponse{
+			Step:         entry.Step,
+			Provider:     entry.Provider,
+			Model:        entry.Model,
+			PredictionID: entry.PredictionID,
+			SubmittedAt:  entry.SubmittedAt,
+			CompletedAt:  entry.CompletedAt,
+			Status:       entry.Status,
+			Seed:         entry.Seed,
+		}
+	}
+	return entries
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeProvenanceStore appends entries and bumps a version the way the repository does
+type fakeProvenanceStore struct {
+	mu       sync.Mutex
+	entries  []domain.ProvenanceEntry
+	version  int64
+	canceled bool // An append arrived on a canceled context
+}
+
+func (f *fakeProvenanceStore) AppendJobProvenance(ctx context.Context, jobID string, entry domain.ProvenanceEntry) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ctx.Err() != nil {
+		f.canceled = true
+	}
+	f.entries = append(f.entries, entry)
+	f.version++
+	return f.version, nil
+}
+
+func pollingSFX(predictionID string, final *adapters.SFXGenerationResult) *fakeSFXGenerator {
+	return &fakeSFXGenerator{
+		submit: map[string]*adapters.SFXGenerationResult{"rain": {PredictionID: predictionID, Status: "starting"}},
+		status: final,
+	}
+}
+
+func TestWithProvenance_RecordsSucceededAndFailedPredictions(t *testing.T) {
+	store := &fakeProvenanceStore{}
+	ctx := withProvenance(context.Background(), store, nil, zap.NewNop(), "job-1", "sfx", nil)
+
+	_, err := requestSFX(ctx, pollingSFX("p-ok", &adapters.SFXGenerationResult{PredictionID: "p-ok", Status: "succeeded", AudioURL: "https://replicate.delivery/rain.mp3"}), "rain", 3, zap.NewNop())
+	require.NoError(t, err)
+	_, err = requestSFX(ctx, pollingSFX("p-bad", &adapters.SFXGenerationResult{PredictionID: "p-bad", Status: "failed", Error: "model crashed: CUDA out of memory"}), "rain", 3, zap.NewNop())
+	require.Error(t, err)
+
+	require.Len(t, store.entries, 2)
+	ok, failed := store.entries[0], store.entries[1]
+	require.Equal(t, "sfx", ok.Step)
+	require.Equal(t, "replicate", ok.Provider)
+	require.Equal(t, "p-ok", ok.PredictionID)
+	require.Equal(t, "succeeded", ok.Status)
+	require.Empty(t, ok.Error)
+	require.NotZero(t, ok.SubmittedAt)
+
+	require.Equal(t, "p-bad", failed.PredictionID)
+	require.Equal(t, "failed", failed.Status)
+	require.Equal(t, "model crashed: CUDA out of memory", failed.Error)
+}
+
+func TestWithProvenance_RecordsAbandonedPredictions(t *testing.T) {
+	store := &fakeProvenanceStore{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // The job was canceled while the prediction ran
+
+	ctx = withProvenance(ctx, store, nil, zap.NewNop(), "job-1", "sfx", nil)
+	_, err := requestSFX(ctx, pollingSFX("p-1", &adapters.SFXGenerationResult{PredictionID: "p-1", Status: "processing"}), "rain", 3, zap.NewNop())
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.Len(t, store.entries, 1)
+	require.Equal(t, "abandoned", store.entries[0].Status)
+	require.False(t, store.canceled, "the append outlives the job's context")
+}
+
+func TestWithProvenance_NoStore(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, ctx, withProvenance(ctx, nil, nil, zap.NewNop(), "job-1", "sfx", nil))
+}
+
+func TestRegenerateProvenanceKeepsJobInStep(t *testing.T) {
+	store := &fakeProvenanceStore{version: 3}
+	h := &RegenerateHandler{provenance: store, logger: zap.NewNop()}
+	job := &domain.Job{JobID: "job-1", Version: 3}
+	succeeded := &adapters.SFXGenerationResult{PredictionID: "p-1", Status: "succeeded", AudioURL: "https://replicate.delivery/rain.mp3"}
+
+	_, err := requestSFX(h.withProvenance(context.Background(), job, "scene_2"), pollingSFX("p-1", succeeded), "rain", 3, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, int64(4), job.Version, "the regeneration's final write must not conflict with its own entries")
+	require.Len(t, job.Provenance, 1)
+	require.Equal(t, "scene_2", job.Provenance[0].Step)
+
+	// Someone else wrote the job in between: the copy stays stale so the final write reloads
+	store.version++
+	_, err = requestSFX(h.withProvenance(context.Background(), job, "scene_3"), pollingSFX("p-2", succeeded), "rain", 3, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, int64(4), job.Version)
+	require.Len(t, job.Provenance, 1)
+}
+
+func TestBuildProvenanceResponses_OmitsProviderErrors(t *testing.T) {
+	job := &domain.Job{Provenance: []domain.ProvenanceEntry{{
+		Step:         "scene_1",
+		Provider:     "replicate",
+		Model:        "google/veo-3.1:a552",
+		PredictionID: "p-1",
+		SubmittedAt:  100,
+		CompletedAt:  160,
+		Status:       "failed",
+		Error:        "E6716: director out of memory",
+	}}}
+
+	owner, err := json.Marshal(buildProvenanceResponses(job))
+	require.NoError(t, err)
+	require.Contains(t, string(owner), `"prediction_id":"p-1"`)
+	require.Contains(t, string(owner), `"model":"google/veo-3.1:a552"`)
+	require.NotContains(t, string(owner), "director out of memory")
+
+	// The admin API returns the job itself, raw error included
+	admin, err := json.Marshal(job)
+	require.NoError(t, err)
+	require.Contains(t, string(admin), "director out of memory")
+
+	require.Nil(t, buildProvenanceResponses(&domain.Job{}))
+}
+
+func TestGenerateClip_RecordsSeed(t *testing.T) {
+	h := &GenerateHandler{logger: zap.NewNop()}
+	adapter := adapters.NewMockAdapterFactory(unavailableMedia{}, 0, zap.NewNop()).GetDefaultAdapter()
+	pinned := int64(4242)
+
+	for _, scene := range []domain.Scene{
+		{SceneNumber: 1, Duration: 8, GenerationPrompt: storedScenePrompt, Seed: &pinned},
+		{SceneNumber: 2, Duration: 8, GenerationPrompt: storedScenePrompt},
+	} {
+		store := &fakeProvenanceStore{}
+		ctx := withProvenance(context.Background(), store, nil, zap.NewNop(), "job-1", "scene", nil)
+		_, err := h.generateClip(ctx, adapter, &domain.Job{UserID: "user-123", JobID: "job-1"}, scene, "16:9", scene.SceneNumber)
+		require.Error(t, err)
+
+		require.Len(t, store.entries, 1)
+		seed := store.entries[0].Seed
+		require.NotNil(t, seed, "even a failed clip records the seed it was submitted with")
+		if scene.Seed != nil {
+			require.Equal(t, pinned, *seed)
+		} else {
+			require.GreaterOrEqual(t, *seed, int64(0))
+			require.LessOrEqual(t, *seed, adapters.MaxSeed)
+		}
+	}
+}
+
+func TestSceneVideoRequest(t *testing.T) {
+	pinned := int64(7)
+	scene := domain.Scene{
+		Duration:         8,
+		GenerationPrompt: storedScenePrompt,
+		StartImageURL:    "https://example.com/frame.jpg",
+		NegativePrompt:   "warped text",
+		Seed:             &pinned,
+		GenerationParams: map[string]float64{adapters.ParamGuidance: 0.6},
+	}
+
+	req, seed := sceneVideoRequest(scene, "9:16")
+	require.Equal(t, pinned, seed)
+	require.Equal(t, &adapters.VideoGenerationRequest{
+		Prompt:         storedScenePrompt,
+		Duration:       8,
+		AspectRatio:    "9:16",
+		StartImageURL:  "https://example.com/frame.jpg",
+		NegativePrompt: "warped text",
+		Seed:           &pinned,
+		Params:         map[string]float64{adapters.ParamGuidance: 0.6},
+	}, req)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxActiveJobsPerUser is how many jobs a user may have generating at once
+const DefaultMaxActiveJobsPerUser = 2
+
+// queueSweepInterval is how often queued jobs are reconsidered, picking up slots freed on
+// other servers and by pipelines that died without finishing
+const queueSweepInterval = time.Minute
+
+// jobQueueStore is the subset of the job repository the dispatcher needs
+type jobQueueStore interface {
+	CountActiveJobs(ctx context.Context, userID string, activeSince int64) (int, error)
+	ListQueuedJobs(ctx context.Context, userID string) ([]*domain.Job, error)
+	ClaimQueuedJob(ctx context.Context, jobID string) error
+}
+
+// jobDispatcher enforces the per-user active job limit. Jobs over the limit are saved as
+// queued and started oldest first as the user's active jobs finish.
+type jobDispatcher struct {
+	store  jobQueueStore
+	limit  int
+	start  func(job *domain.Job) // Runs a claimed job's pipeline in the background
+	logger *zap.Logger
+	now    func() time.Time
+
+	// mu guards the maps only and is never held across store calls. Admission and promotion
+	// for a user are serialized by that user's lock, so one user's slow queries don't stall others.
+	mu      sync.Mutex
+	running map[string]int // Jobs started by this server, per user
+	users   map[string]*userLock
+}
+
+// userLock serializes admission and promotion for one user. It is dropped once nobody holds
+// or waits for it.
+type userLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newJobDispatcher(store jobQueueStore, limit int, start func(job *domain.Job), logger *zap.Logger) *jobDispatcher {
+	return &jobDispatcher{
+		store:   store,
+		limit:   limit,
+		start:   start,
+		logger:  logger,
+		now:     time.Now,
+		running: make(map[string]int),
+		users:   make(map[string]*userLock),
+	}
+}
+
+// lockUser takes userID's lock and returns the function that releases it
+func (d *jobDispatcher) lockUser(userID string) func() {
+	d.mu.Lock()
+	lock, ok := d.users[userID]
+	if !ok {
+		lock = &userLock{}
+		d.users[userID] = lock
+	}
+	lock.refs++
+	d.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		d.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(d.users, userID)
+		}
+		d.mu.Unlock()
+	}
+}
+
+// addRunning adjusts how many of userID's jobs this server is running
+func (d *jobDispatcher) addRunning(userID string, delta int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.running[userID] = max(d.running[userID]+delta, 0)
+	if d.running[userID] == 0 {
+		delete(d.running, userID)
+	}
+}
+
+// activeJobs counts the user's active jobs. DynamoDB's index lags behind writes, so this
+// server's own count is used when it is higher. Callers must hold the user's lock.
+func (d *jobDispatcher) activeJobs(ctx context.Context, userID string) (int, error) {
+	since := d.now().Add(-VideoGenerationTimeout).Unix()
+	active, err := d.store.CountActiveJobs(ctx, userID, since)
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return max(active, d.running[userID]), nil
+}
+
+// admit saves a new job, as queued when the user is at the limit or already has jobs waiting.
+// It reports whether the job may start now; the caller starts it and the dispatcher counts it
+// as running until finish is called.
+func (d *jobDispatcher) admit(ctx context.Context, job *domain.Job, save func(ctx context.Context, job *domain.Job) error) (bool, error) {
+	defer d.lockUser(job.UserID)()
+
+	startNow := true
+	active, err := d.activeJobs(ctx, job.UserID)
+	if err == nil {
+		var queued []*domain.Job
+		queued, err = d.store.ListQueuedJobs(ctx, job.UserID)
+		startNow = active < d.limit && len(queued) == 0
+	}
+	if err != nil {
+		// Fail open: a counting error shouldn't block generation
+		d.logger.Warn("Failed to check active job limit, starting job",
+			zap.String("job_id", job.JobID),
+			zap.String("user_id", job.UserID),
+			zap.Error(err),
+		)
+	}
+
+	if !startNow {
+		if err := job.AdvanceStage(domain.StageQueued); err != nil {
+			return false, err
+		}
+		job.Status = domain.StatusQueued
+	}
+	if err := save(ctx, job); err != nil {
+		return false, err
+	}
+
+	if startNow {
+		d.addRunning(job.UserID, 1)
+	} else {
+		d.logger.Info("Job queued behind user's active jobs",
+			zap.String("job_id", job.JobID),
+			zap.String("user_id", job.UserID),
+			zap.Int("active_jobs", active),
+			zap.Int("limit", d.limit),
+		)
+	}
+	return startNow, nil
+}
+
+// finish records that one of the user's jobs reached a terminal state and starts queued jobs
+func (d *jobDispatcher) finish(ctx context.Context, userID string) {
+	d.addRunning(userID, -1)
+	d.promote(ctx, userID)
+}
+
+// promote starts the user's oldest queued jobs while they have free slots
+func (d *jobDispatcher) promote(ctx context.Context, userID string) {
+	defer d.lockUser(userID)()
+
+	active, err := d.activeJobs(ctx, userID)
+	if err != nil {
+		d.logger.Warn("Failed to count active jobs for promotion", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	if active >= d.limit {
+		return
+	}
+
+	queued, err := d.store.ListQueuedJobs(ctx, userID)
+	if err != nil {
+		d.logger.Warn("Failed to list queued jobs", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+
+	for _, job := range queued {
+		if active >= d.limit {
+			return
+		}
+		if err := d.store.ClaimQueuedJob(ctx, job.JobID); err != nil {
+			if stderrors.Is(err, repository.ErrJobNotQueued) {
+				// Most likely another server started it, taking the slot. If the job was
+				// deleted instead, the next sweep fills the slot.
+				active++
+			} else {
+				d.logger.Warn("Failed to claim queued job", zap.String("job_id", job.JobID), zap.Error(err))
+			}
+			continue
+		}
+
+		active++
+		d.addRunning(userID, 1)
+		d.logger.Info("Promoting queued job",
+			zap.String("job_id", job.JobID),
+			zap.String("user_id", userID),
+			zap.Int("active_jobs", active),
+		)
+		job.Status = domain.StatusProcessing
+		d.start(job)
+	}
+}
+
+// sweep promotes queued jobs for every user with a queue, oldest first
+func (d *jobDispatcher) sweep(ctx context.Context) {
+	queued, err := d.store.ListQueuedJobs(ctx, "")
+	if err != nil {
+		d.logger.Warn("Failed to list queued jobs", zap.Error(err))
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, job := range queued {
+		if seen[job.UserID] {
+			continue
+		}
+		seen[job.UserID] = true
+		d.promote(ctx, job.UserID)
+	}
+}
+
+// run resumes jobs left queued by a previous server, then sweeps periodically until ctx is done
+func (d *jobDispatcher) run(ctx context.Context) {
+	d.sweep(ctx)
+
+	ticker := time.NewTicker(queueSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.sweep(ctx)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobprogress"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeJobQueueStore keeps jobs in memory and claims them the way the conditional update does
+type fakeJobQueueStore struct {
+	mu   sync.Mutex
+	jobs map[string]*domain.Job
+
+	stolen map[string]bool // Jobs another server claims first
+}
+
+func newFakeJobQueueStore() *fakeJobQueueStore {
+	return &fakeJobQueueStore{jobs: make(map[string]*domain.Job), stolen: make(map[string]bool)}
+}
+
+func (s *fakeJobQueueStore) save(_ context.Context, job *domain.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *job
+	s.jobs[job.JobID] = &stored
+	return nil
+}
+
+func (s *fakeJobQueueStore) CountActiveJobs(_ context.Context, userID string, activeSince int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, job := range s.jobs {
+		if job.UserID == userID && job.Status == domain.StatusProcessing && job.UpdatedAt >= activeSince {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *fakeJobQueueStore) ListQueuedJobs(_ context.Context, userID string) ([]*domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var queued []*domain.Job
+	for _, job := range s.jobs {
+		if job.Status == domain.StatusQueued && (userID == "" || job.UserID == userID) {
+			stored := *job
+			queued = append(queued, &stored)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].CreatedAt < queued[j].CreatedAt })
+	return queued, nil
+}
+
+func (s *fakeJobQueueStore) ClaimQueuedJob(_ context.Context, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if s.stolen[jobID] {
+		job.Status = domain.StatusProcessing
+	}
+	if !ok || job.Status != domain.StatusQueued {
+		return repository.ErrJobNotQueued
+	}
+	job.Status = domain.StatusProcessing
+	return nil
+}
+
+// complete marks a stored job as finished
+func (s *fakeJobQueueStore) complete(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID].Status = domain.StatusCompleted
+}
+
+func (s *fakeJobQueueStore) status(jobID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[jobID].Status
+}
+
+// newTestDispatcher returns a dispatcher that records the jobs it starts instead of running them
+func newTestDispatcher(store *fakeJobQueueStore, limit int, now time.Time) (*jobDispatcher, *[]string) {
+	started := &[]string{}
+	d := newJobDispatcher(store, limit, func(job *domain.Job) {
+		*started = append(*started, job.JobID)
+	}, zap.NewNop())
+	d.now = func() time.Time { return now }
+	return d, started
+}
+
+func testQueueJob(jobID, userID string, createdAt int64) *domain.Job {
+	return &domain.Job{
+		JobID:     jobID,
+		UserID:    userID,
+		Status:    domain.StatusProcessing,
+		Stage:     domain.StageScriptGenerating,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+}
+
+func TestJobDispatcher_QueuesJobsOverLimit(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	store := newFakeJobQueueStore()
+	d, _ := newTestDispatcher(store, 2, now)
+	ctx := context.Background()
+
+	for i, jobID := range []string{"job-1", "job-2", "job-3"} {
+		job := testQueueJob(jobID, "user-1", now.Unix()+int64(i))
+		startNow, err := d.admit(ctx, job, store.save)
+		require.NoError(t, err)
+		require.Equal(t, i < 2, startNow, jobID)
+	}
+
+	require.Equal(t, domain.StatusProcessing, store.status("job-1"))
+	require.Equal(t, domain.StatusProcessing, store.status("job-2"))
+	require.Equal(t, domain.StatusQueued, store.status("job-3"))
+	require.Equal(t, domain.StageQueued, store.jobs["job-3"].Stage)
+
+	// Other users have their own limit
+	startNow, err := d.admit(ctx, testQueueJob("job-4", "user-2", now.Unix()), store.save)
+	require.NoError(t, err)
+	require.True(t, startNow)
+}
+
+func TestJobDispatcher_SlowUserDoesNotBlockOthers(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	store := newFakeJobQueueStore()
+	d, _ := newTestDispatcher(store, 2, now)
+	ctx := context.Background()
+
+	// user-1's save hangs while holding user-1's lock
+	saving := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = d.admit(ctx, testQueueJob("job-1", "user-1", now.Unix()), func(ctx context.Context, job *domain.Job) error {
+			close(saving)
+			<-release
+			return store.save(ctx, job)
+		})
+	}()
+	<-saving
+
+	startNow, err := d.admit(ctx, testQueueJob("job-2", "user-2", now.Unix()), store.save)
+	require.NoError(t, err)
+	require.True(t, startNow)
+
+	close(release)
+	<-done
+	require.Empty(t, d.users)
+}
+
+func TestJobDispatcher_NewJobWaitsBehindExistingQueue(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	store := newFakeJobQueueStore()
+	d, _ := newTestDispatcher(store, 2, now)
+	ctx := context.Background()
+
+	// A job left queued while the user was at the limit keeps its place
+	queued := testQueueJob("job-queued", "user-1", now.Unix()-60)
+	queued.Status = domain.StatusQueued
+	require.NoError(t, store.save(ctx, queued))
+
+	startNow, err := d.admit(ctx, testQueueJob("job-new", "user-1", now.Unix()), store.save)
+	require.NoError(t, err)
+	require.False(t, startNow)
+	require.Equal(t, domain.StatusQueued, store.status("job-new"))
+}
+
+func TestJobDispatcher_FinishPromotesOldestQueuedJob(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	store := newFakeJobQueueStore()
+	d, started := newTestDispatcher(store, 2, now)
+	ctx := context.Background()
+
+	for i, jobID := range []string{"job-1", "job-2", "job-3", "job-4", "job-5"} {
+		_, err := d.admit(ctx, testQueueJob(jobID, "user-1", now.Unix()+int64(i)), store.save)
+		require.NoError(t, err)
+	}
+	require.Empty(t, *started)
+
+	store.complete("job-1")
+	d.finish(ctx, "user-1")
+	require.Equal(t, []string{"job-3"}, *started)
+	require.Equal(t, domain.StatusProcessing, store.status("job-3"))
+
+	store.complete("job-2")
+	d.finish(ctx, "user-1")
+	store.complete("job-3")
+	d.finish(ctx, "user-1")
+	require.Equal(t, []string{"job-3", "job-4", "job-5"}, *started)
+}
+
+func TestJobDispatcher_SkipsJobsClaimedElsewhere(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	store := newFakeJobQueueStore()
+	d, started := newTestDispatcher(store, 1, now)
+	ctx := context.Background()
+
+	for i, jobID := range []string{"job-1", "job-2", "job-3"} {
+		_, err := d.admit(ctx, testQueueJob(jobID, "user-1", now.Unix()+int64(i)), store.save)
+		require.NoError(t, err)
+	}
+
+	// Another server promotes job-2 between our list and claim, taking the free slot
+	store.stolen["job-2"] = true
+	store.complete("job-1")
+	d.finish(ctx, "user-1")
+	require.Empty(t, *started)
+	require.Equal(t, domain.StatusQueued, store.status("job-3"))
+
+	store.complete("job-2")
+	d.finish(ctx, "user-1")
+	require.Equal(t, []string{"job-3"}, *started)
+}
+
+func TestJobDispatcher_RunResumesQueuedJobsAfterRestart(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	store := newFakeJobQueueStore()
+	ctx := context.Background()
+
+	// Jobs persisted before the restart: user-1's earlier pipeline died with the old server
+	// and stopped updating its job, user-2 still has one running elsewhere
+	orphaned := testQueueJob("job-orphaned", "user-1", now.Add(-2*VideoGenerationTimeout).Unix())
+	running := testQueueJob("job-running", "user-2", now.Add(-time.Minute).Unix())
+	require.NoError(t, store.save(ctx, orphaned))
+	require.NoError(t, store.save(ctx, running))
+	for i, job := range []*domain.Job{
+		testQueueJob("user-1-a", "user-1", now.Unix()-30),
+		testQueueJob("user-1-b", "user-1", now.Unix()-20),
+		testQueueJob("user-1-c", "user-1", now.Unix()-10),
+		testQueueJob("user-2-a", "user-2", now.Unix()-25),
+		testQueueJob("user-2-b", "user-2", now.Unix()-15),
+	} {
+		job.Status = domain.StatusQueued
+		job.CreatedAt += int64(i) // Keep creation times distinct
+		require.NoError(t, store.save(ctx, job))
+	}
+
+	d, started := newTestDispatcher(store, 2, now)
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel() // Only the startup sweep runs
+	d.run(runCtx)
+
+	sort.Strings(*started)
+	require.Equal(t, []string{"user-1-a", "user-1-b", "user-2-a"}, *started)
+	require.Equal(t, domain.StatusQueued, store.status("user-1-c"))
+	require.Equal(t, domain.StatusQueued, store.status("user-2-b"))
+}
+
+func TestProgress_Queued(t *testing.T) {
+	require.Equal(t, 0, jobprogress.Percent(&domain.Job{Status: domain.StatusQueued, Stage: domain.StageQueued}))
+	require.Equal(t, "Waiting for your other videos to finish", formatStageName(domain.StageQueued))
+
+	// A queued new job hasn't generated its script; a queued approved preview has
+	require.Empty(t, buildStagesCompleted(&domain.Job{Stage: domain.StageQueued}))
+	completed := buildStagesCompleted(&domain.Job{Stage: domain.StageQueued, Scenes: make([]domain.Scene, 3)})
+	require.Len(t, completed, 1)
+	require.Equal(t, "script_complete", completed[0].Name)
+}
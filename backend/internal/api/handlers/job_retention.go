@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	stderrors "errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// DefaultJobRetentionDays matches the 7-day TTL every job had before retention was configurable
+const DefaultJobRetentionDays = 7
+
+// jobRetentionGrace is how long after expires_at DynamoDB TTL removes a job's record. The sweep
+// needs the record to find the job's assets, so it gets this long to reach the job first.
+const jobRetentionGrace = 48 * time.Hour
+
+// retentionSweepInterval is how often each server looks for expired jobs
+const retentionSweepInterval = time.Hour
+
+// expirableStatuses are the statuses the retention sweep deletes; unfinished jobs are left alone
+var expirableStatuses = []string{domain.StatusCompleted, domain.StatusFailed, domain.StatusCanceled}
+
+// jobExpiry returns the expires_at and DynamoDB TTL of a job retained for retentionDays from
+// start. Both are 0, keeping the job forever, when retentionDays <= 0.
+func jobExpiry(start time.Time, retentionDays int) (expiresAt, ttl int64) {
+	if retentionDays <= 0 {
+		return 0, 0
+	}
+	expiry := start.AddDate(0, 0, retentionDays)
+	return expiry.Unix(), expiry.Add(jobRetentionGrace).Unix()
+}
+
+// expiredJobStore is the subset of the job repository the retention sweep needs
+type expiredJobStore interface {
+	ListExpiredJobs(ctx context.Context, status string, expiredBy int64) ([]*domain.Job, error)
+	DeleteJob(ctx context.Context, jobID string) error
+}
+
+// RetentionSweepResult summarizes one retention sweep
+type RetentionSweepResult struct {
+	Expired        int `json:"expired"`         // Jobs past their expires_at
+	Deleted        int `json:"deleted"`         // Jobs whose assets and record were deleted
+	Failed         int `json:"failed"`          // Jobs left for the next sweep
+	DeletedObjects int `json:"deleted_objects"` // S3 objects deleted
+}
+
+// sweepExpiredJobs deletes the assets and record of every finished job whose retention expired
+// by now. A job whose assets can't all be deleted keeps its record, so the next sweep retries it.
+func sweepExpiredJobs(ctx context.Context, store expiredJobStore, assets jobAssetStore, storage storageCounter, bucket string, now time.Time, logger *zap.Logger) (RetentionSweepResult, error) {
+	var result RetentionSweepResult
+	var errs []error
+	for _, status := range expirableStatuses {
+		jobs, err := store.ListExpiredJobs(ctx, status, now.Unix())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Expired += len(jobs)
+
+		for _, job := range jobs {
+			deletedObjects, err := deleteJobAssets(ctx, assets, bucket, job)
+			result.DeletedObjects += deletedObjects
+			if err == nil {
+				err = store.DeleteJob(ctx, job.JobID)
+			}
+			if err != nil {
+				logger.Warn("Failed to delete expired job",
+					zap.String("job_id", job.JobID),
+					zap.String("user_id", job.UserID),
+					zap.Int64("expires_at", job.ExpiresAt),
+					zap.Error(err),
+				)
+				result.Failed++
+				continue
+			}
+			releaseJobStorage(ctx, storage, job, logger)
+			result.Deleted++
+		}
+	}
+	return result, stderrors.Join(errs...)
+}
+
+// JobRetentionHandler deletes jobs whose retention expired, on a timer and from an internal endpoint
+type JobRetentionHandler struct {
+	jobRepo      *repository.DynamoDBRepository
+	s3Service    *repository.S3AssetRepository
+	storage      storageCounter // Optional; nil skips storage accounting
+	assetsBucket string
+	token        string // Bearer token for POST /internal/jobs/retention-sweep
+	logger       *zap.Logger
+}
+
+// NewJobRetentionHandler creates a retention handler. The internal endpoint accepts requests
+// bearing token.
+func NewJobRetentionHandler(
+	jobRepo *repository.DynamoDBRepository,
+	s3Service *repository.S3AssetRepository,
+	storageUsage *repository.DynamoDBUsageRepository,
+	assetsBucket string,
+	token string,
+	logger *zap.Logger,
+) *JobRetentionHandler {
+	h := &JobRetentionHandler{
+		jobRepo:      jobRepo,
+		s3Service:    s3Service,
+		assetsBucket: assetsBucket,
+		token:        token,
+		logger:       logger,
+	}
+	if storageUsage != nil {
+		h.storage = storageUsage
+	}
+	return h
+}
+
+// Run sweeps expired jobs now and then every retentionSweepInterval until ctx is done
+func (h *JobRetentionHandler) Run(ctx context.Context) {
+	h.sweep(ctx)
+
+	ticker := time.NewTicker(retentionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.sweep(ctx)
+		}
+	}
+}
+
+// SweepExpired handles POST /internal/jobs/retention-sweep, for schedulers outside the API
+// server. Requests must carry "Authorization: Bearer <INTERNAL_API_TOKEN>".
+func (h *JobRetentionHandler) SweepExpired(c *gin.Context) {
+	if !internalTokenValid(c, h.token) {
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error: errors.ErrUnauthorized,
+		})
+		return
+	}
+
+	result, err := h.sweep(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// internalTokenValid reports whether the request bears the internal API token. It's always false
+// when no token is configured.
+func internalTokenValid(c *gin.Context, token string) bool {
+	bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+func (h *JobRetentionHandler) sweep(ctx context.Context) (RetentionSweepResult, error) {
+	result, err := sweepExpiredJobs(ctx, h.jobRepo, h.s3Service, h.storage, h.assetsBucket, time.Now(), h.logger)
+	if err != nil {
+		h.logger.Error("Retention sweep could not list expired jobs", zap.Error(err))
+	}
+	if result.Expired > 0 {
+		h.logger.Info("Retention sweep finished",
+			zap.Int("expired", result.Expired),
+			zap.Int("deleted", result.Deleted),
+			zap.Int("failed", result.Failed),
+			zap.Int("deleted_objects", result.DeletedObjects),
+		)
+	}
+	return result, err
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJobExpiry(t *testing.T) {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	expiresAt, ttl := jobExpiry(created, 30)
+	require.Equal(t, time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC).Unix(), expiresAt)
+	require.Equal(t, time.Date(2025, 4, 2, 12, 0, 0, 0, time.UTC).Unix(), ttl, "TTL trails expiry so the sweep can clean up first")
+
+	expiresAt, ttl = jobExpiry(created, DefaultJobRetentionDays)
+	require.Equal(t, created.Add(7*24*time.Hour).Unix(), expiresAt)
+	require.Equal(t, created.Add(9*24*time.Hour).Unix(), ttl)
+
+	for _, days := range []int{0, -1} {
+		expiresAt, ttl = jobExpiry(created, days)
+		require.Zero(t, expiresAt, "retention disabled keeps jobs forever")
+		require.Zero(t, ttl)
+	}
+}
+
+// fakeExpiredJobStore filters its jobs the way ListExpiredJobs' query does
+type fakeExpiredJobStore struct {
+	jobs      []*domain.Job
+	listErr   map[string]error
+	deleteErr map[string]error
+	deleted   []string
+}
+
+func (s *fakeExpiredJobStore) ListExpiredJobs(_ context.Context, status string, expiredBy int64) ([]*domain.Job, error) {
+	if err := s.listErr[status]; err != nil {
+		return nil, err
+	}
+	var jobs []*domain.Job
+	for _, job := range s.jobs {
+		if job.Status == status && job.ExpiresAt != 0 && job.ExpiresAt <= expiredBy {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *fakeExpiredJobStore) DeleteJob(_ context.Context, jobID string) error {
+	if err := s.deleteErr[jobID]; err != nil {
+		return err
+	}
+	s.deleted = append(s.deleted, jobID)
+	return nil
+}
+
+func TestSweepExpiredJobs(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Second).Unix()
+	store := &fakeExpiredJobStore{jobs: []*domain.Job{
+		{JobID: "job-old", UserID: "u1", Status: domain.StatusCompleted, ExpiresAt: expired},
+		{JobID: "job-due", UserID: "u1", Status: domain.StatusCompleted, ExpiresAt: now.Unix()},
+		{JobID: "job-fresh", UserID: "u1", Status: domain.StatusCompleted, ExpiresAt: now.Add(time.Second).Unix()},
+		{JobID: "job-forever", UserID: "u1", Status: domain.StatusCompleted},
+		{JobID: "job-failed", UserID: "u1", Status: domain.StatusFailed, ExpiresAt: expired},
+		{JobID: "job-running", UserID: "u1", Status: domain.StatusProcessing, ExpiresAt: expired},
+		{JobID: "job-locked", UserID: "u2", Status: domain.StatusCompleted, ExpiresAt: expired},
+	}}
+	assets := &fakeJobAssetStore{
+		prefixes: map[string]int{"users/u1/jobs/job-old/": 12, "users/u1/jobs/job-due/": 3},
+		refuse:   map[string]bool{"videos/job-locked/final.mp4": true},
+	}
+	store.jobs[6].VideoKey = "videos/job-locked/final.mp4"
+
+	result, err := sweepExpiredJobs(context.Background(), store, assets, nil, "assets", now, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, RetentionSweepResult{Expired: 4, Deleted: 3, Failed: 1, DeletedObjects: 15}, result)
+	require.Equal(t, []string{"job-old", "job-due", "job-failed"}, store.deleted, "a job whose assets remain keeps its record for the next sweep")
+}
+
+func TestSweepExpiredJobsContinuesAfterListFailure(t *testing.T) {
+	now := time.Now()
+	store := &fakeExpiredJobStore{
+		jobs: []*domain.Job{
+			{JobID: "job-completed", UserID: "u1", Status: domain.StatusCompleted, ExpiresAt: now.Unix()},
+			{JobID: "job-canceled", UserID: "u1", Status: domain.StatusCanceled, ExpiresAt: now.Unix()},
+		},
+		listErr: map[string]error{domain.StatusCompleted: errors.New("throttled")},
+	}
+
+	result, err := sweepExpiredJobs(context.Background(), store, &fakeJobAssetStore{}, nil, "assets", now, zap.NewNop())
+	require.ErrorContains(t, err, "throttled")
+	require.Equal(t, 1, result.Deleted)
+	require.Equal(t, []string{"job-canceled"}, store.deleted)
+}
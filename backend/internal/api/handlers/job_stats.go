@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// stepStatsStore is the subset of the job repository that records each step's stats on a job
+type stepStatsStore interface {
+	RecordJobStepStats(ctx context.Context, jobID, step string, stats domain.StepStats) (int64, error)
+}
+
+// trackStep returns ctx with the provider calls made on it tallied, and a function that records
+// step's stats on the job, with the credits it cost, once the step has succeeded. Each step is
+// written as it finishes, so a job that fails later keeps the stats of the steps it got through.
+// Its time also goes into the rolling averages jobs' ETAs are estimated from.
+func (h *GenerateHandler) trackStep(ctx context.Context, job *domain.Job, step string) (context.Context, func(credits int)) {
+	start := time.Now()
+	ctx, calls := adapters.WithCallStats(ctx)
+	return ctx, func(credits int) {
+		elapsed := time.Since(start)
+		if h.stageTimings != nil {
+			h.stageTimings.Record(job, step, elapsed)
+		}
+		if h.stepStats == nil {
+			return
+		}
+		stats := stepStats(calls, elapsed, credits)
+		// Detached so a step that finished just as the job was canceled is still recorded
+		if _, err := h.stepStats.RecordJobStepStats(trace.Detach(ctx), job.JobID, step, stats); err != nil {
+			trace.Logger(ctx, h.logger).Warn("Failed to record job step stats",
+				zap.String("step", step),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// stepStats builds a step's stats from its provider calls. Latency is the time the provider
+// spent on them, or elapsed for a step that made none, such as composition; every call after
+// the first is a retry. Tokens are those of the step's LLM calls.
+func stepStats(calls *adapters.CallStats, elapsed time.Duration, credits int) domain.StepStats {
+	latency := elapsed
+	if calls.Calls() > 0 {
+		latency = calls.Latency()
+	}
+	tokens := calls.Tokens()
+	return domain.StepStats{
+		LatencySeconds:   math.Round(latency.Seconds()*100) / 100,
+		Polls:            calls.Polls(),
+		Retries:          max(calls.Calls()-1, 0),
+		Credits:          credits,
+		PromptTokens:     tokens.PromptTokens,
+		CompletionTokens: tokens.CompletionTokens,
+	}
+}
+
+// withTokenBudget returns ctx with job's LLM calls held to the handler's per-job token budget,
+// counting the tokens its earlier runs already consumed, such as a previewed job's script
+func (h *GenerateHandler) withTokenBudget(ctx context.Context, job *domain.Job) context.Context {
+	var used adapters.TokenUsage
+	for _, stats := range job.StepStats {
+		used.PromptTokens += stats.PromptTokens
+		used.CompletionTokens += stats.CompletionTokens
+	}
+	return adapters.WithTokenBudget(ctx, adapters.NewTokenBudget(h.tokenBudget, used))
+}
+
+// sceneCredits is what generating scene's clip costs with job's model
+func sceneCredits(job *domain.Job, scene domain.Scene) int {
+	return pricing.SceneCredits(int(math.Round(scene.Duration)), adapters.AdapterType(job.Model))
+}
+
+// buildUsageResponse itemizes the stats of a job's finished steps, or returns nil before any
+// has finished
+func buildUsageResponse(job *domain.Job) *pricing.Usage {
+	if len(job.StepStats) == 0 {
+		return nil
+	}
+	usage := pricing.SummarizeUsage(job.StepStats)
+	return &usage
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTrackStep_CountsRetriesAndPolls(t *testing.T) {
+	h, repo, job := complianceHandler(t, false)
+
+	ctx, done := h.trackStep(context.Background(), job, domain.StepMusic)
+	_, err := requestSFX(ctx, pollingSFX("p-bad", &adapters.SFXGenerationResult{PredictionID: "p-bad", Status: "failed", Error: "model crashed"}), "rain", 3, zap.NewNop())
+	require.Error(t, err)
+	_, err = requestSFX(ctx, pollingSFX("p-ok", &adapters.SFXGenerationResult{PredictionID: "p-ok", Status: "succeeded", AudioURL: "https://replicate.delivery/rain.mp3"}), "rain", 3, zap.NewNop())
+	require.NoError(t, err)
+	done(0)
+
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	stats := stored.StepStats[domain.StepMusic]
+	require.Equal(t, 1, stats.Retries)
+	require.Equal(t, 2, stats.Polls)
+	require.Zero(t, stats.Credits)
+}
+
+func TestGenerateScriptStep_RecordsStats(t *testing.T) {
+	h, repo, job := complianceHandler(t, false)
+
+	_, ok := h.generateScriptStep(context.Background(), job, complianceRequest(job), nil)
+	require.True(t, ok)
+
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Contains(t, stored.StepStats, domain.StepScript)
+	require.Zero(t, stored.StepStats[domain.StepScript].Credits, "scripts are included in the scene prices")
+}
+
+func TestStepStats_SurviveFailureAfterSceneTwo(t *testing.T) {
+	h, repo, job := complianceHandler(t, false)
+	ctx := context.Background()
+	scene := domain.Scene{Duration: 8}
+
+	// Scenes 1 and 2 finish and are saved; scene 3 fails the job
+	for i := 1; i <= 2; i++ {
+		_, done := h.trackStep(ctx, job, domain.SceneStep(i))
+		done(sceneCredits(job, scene))
+		job.ScenesCompleted = i
+		require.NoError(t, h.saveJobProgress(ctx, job))
+	}
+	h.failJob(ctx, job, domain.SceneGenerating(3).String(), "Scene 3 could not be generated", errors.New("veo API failed"))
+
+	stored, err := repo.GetJob(ctx, job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusFailed, stored.Status)
+	require.Len(t, stored.StepStats, 2)
+
+	credits := pricing.SceneCredits(8, adapters.AdapterType(job.Model))
+	require.Positive(t, credits)
+
+	router := jobStatsRouter(repo)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.JobID, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var detail JobResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	require.NotNil(t, detail.Stats)
+	require.Len(t, detail.Stats.Scenes, 2)
+	require.Equal(t, 1, detail.Stats.Scenes[0].Scene)
+	require.Equal(t, 2, detail.Stats.Scenes[1].Scene)
+	require.Equal(t, credits, detail.Stats.Scenes[1].Credits)
+	require.Equal(t, 2*credits, detail.Stats.TotalCredits)
+	require.Equal(t, 2*credits, detail.TotalCredits)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list struct {
+		Jobs []map[string]json.RawMessage `json:"jobs"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Jobs, 1)
+	require.JSONEq(t, strconv.Itoa(2*credits), string(list.Jobs[0]["total_credits"]))
+	require.NotContains(t, list.Jobs[0], "stats", "lists carry the total only")
+}
+
+func TestBuildUsageResponse_NoStats(t *testing.T) {
+	require.Nil(t, buildUsageResponse(&domain.Job{}))
+}
+
+// jobStatsRouter serves the job routes as user-123
+func jobStatsRouter(repo *repository.DynamoDBRepository) *gin.Engine {
+	h := NewJobsHandler(repo, nil, nil, nil, "", zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.GET("/jobs", h.ListJobs)
+	v1.GET("/jobs/:id", h.GetJob)
+	return router
+}
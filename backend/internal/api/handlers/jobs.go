@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobprogress"
+	"github.com/omnigen/backend/internal/pricing"
 	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
 	"github.com/omnigen/backend/internal/service"
 	"github.com/omnigen/backend/pkg/errors"
 	"go.uber.org/zap"
@@ -19,6 +26,7 @@ type JobsHandler struct {
 	jobRepo      *repository.DynamoDBRepository
 	s3Service    *repository.S3AssetRepository
 	assetService *service.AssetService
+	storage      storageCounter // Optional; nil skips storage accounting
 	assetsBucket string
 	logger       *zap.Logger
 }
@@ -28,16 +36,21 @@ func NewJobsHandler(
 	jobRepo *repository.DynamoDBRepository,
 	s3Service *repository.S3AssetRepository,
 	assetService *service.AssetService,
+	storageUsage *repository.DynamoDBUsageRepository,
 	assetsBucket string,
 	logger *zap.Logger,
 ) *JobsHandler {
-	return &JobsHandler{
+	h := &JobsHandler{
 		jobRepo:      jobRepo,
 		s3Service:    s3Service,
 		assetService: assetService,
 		assetsBucket: assetsBucket,
 		logger:       logger,
 	}
+	if storageUsage != nil {
+		h.storage = storageUsage
+	}
+	return h
 }
 
 // JobResponse represents a job status response
@@ -47,33 +60,148 @@ type JobResponse struct {
 	Stage           string  `json:"stage,omitempty"`
 	ProgressPercent int     `json:"progress_percent"`
 	Prompt          string  `json:"prompt"`
+	Title           string  `json:"title,omitempty"`
+	Note            string  `json:"note,omitempty"`
 	Duration        int     `json:"duration"`
 	VideoURL        *string `json:"video_url,omitempty"`      // MP4 format
 	WebMVideoURL    *string `json:"webm_video_url,omitempty"` // WebM format (VP9)
 	Model           string  `json:"model,omitempty"`
+	ScriptID        string  `json:"script_id,omitempty"`       // Script library entry; rerun with POST /generate/from-script/:id
+	DuplicatedFrom  string  `json:"duplicated_from,omitempty"` // Job this one copies the settings of
+	ETASeconds      int     `json:"eta_seconds,omitempty"`     // Seconds a processing job has left, from the rolling timings of its remaining steps
+	ETAUpdatedAt    int64   `json:"eta_updated_at,omitempty"`  // When the estimate was made, as the job started or finished a step
 	CreatedAt       int64   `json:"created_at"`
 	UpdatedAt       int64   `json:"updated_at"`
 	CompletedAt     *int64  `json:"completed_at,omitempty"`
 	ErrorMessage    *string `json:"error_message,omitempty"`
 
+	// Frame of the final video as encoded, so a player can size itself before loading it
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	// 1-based position among the user's queued jobs (only populated by GetJob for queued jobs)
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// Completion webhook delivery (only populated by GetJob for jobs with a callback_url)
+	CallbackURL        string `json:"callback_url,omitempty"`
+	WebhookAttempts    int    `json:"webhook_attempts,omitempty"`
+	WebhookDeliveredAt *int64 `json:"webhook_delivered_at,omitempty"`
+
+	// Cancellation (POST /jobs/:id/cancel); a processing job with cancel_requested is stopping
+	CancelRequested bool   `json:"cancel_requested,omitempty"`
+	CanceledAt      *int64 `json:"canceled_at,omitempty"`
+
 	// Progress fields
-	ThumbnailURL     string   `json:"thumbnail_url,omitempty"`
-	AudioURL         string   `json:"audio_url,omitempty"`
-	NarratorAudioURL string   `json:"narrator_audio_url,omitempty"`
-	ScenesCompleted  int      `json:"scenes_completed,omitempty"`
-	SceneVideoURLs   []string `json:"scene_video_urls,omitempty"`
+	ThumbnailURL     string            `json:"thumbnail_url,omitempty"`
+	Thumbnails       map[string]string `json:"thumbnails,omitempty"`      // JPEG thumbnail by width ("320", "640", "1280")
+	ThumbnailsWebP   map[string]string `json:"thumbnails_webp,omitempty"` // WebP thumbnail by width, when enabled
+	AudioURL         string            `json:"audio_url,omitempty"`
+	NarratorAudioURL string            `json:"narrator_audio_url,omitempty"`
+	MusicProvider    string            `json:"music_provider,omitempty"` // Model that made the music; empty if the job has none
+	ScenesCompleted  int               `json:"scenes_completed,omitempty"`
+	SceneVideoURLs   []string          `json:"scene_video_urls,omitempty"`
 
 	// Side effects fields for text overlay
 	SideEffectsText      string   `json:"side_effects_text,omitempty"`
 	SideEffectsStartTime *float64 `json:"side_effects_start_time,omitempty"`
+
+	// Pharmaceutical compliance checks the script failed; warnings unless strict checks are on
+	ComplianceIssues []domain.ComplianceIssue `json:"compliance_issues,omitempty"`
+
+	// Prompts content moderation flagged, failing the job before any clip was generated
+	ModerationFlags []domain.ModerationFlag `json:"moderation_flags,omitempty"`
+
+	// Scene reorders POST /jobs/:id/scenes/reorder/undo can still revert
+	UndoableReorders int `json:"undoable_reorders,omitempty"`
+
+	// Scenes the request planned, when it gave a scene_plan
+	ScenePlan []domain.PlannedScene `json:"scene_plan,omitempty"`
+
+	// How each scene followed on from the last: frame, style or none
+	ContinuityMode string `json:"continuity_mode,omitempty"`
+
+	// BCP-47 tag of the narration's language, when the request gave one
+	Language string `json:"language,omitempty"`
+
+	// Narration timing after fitting the voiceover to the video
+	NarrationDuration  float64 `json:"narration_duration,omitempty"`
+	NarrationSpeed     float64 `json:"narration_speed,omitempty"`
+	NarrationTruncated bool    `json:"narration_truncated,omitempty"`
+
+	// Narrator captions as WebVTT (only populated by GetJob)
+	CaptionsURL string `json:"captions_url,omitempty"`
+
+	// Hover-scrub preview: a sprite sheet of frames and the WebVTT index into it, whose cues
+	// name the sheet "sprite.jpg" (only populated by GetJob)
+	ScrubSpriteURL string `json:"scrub_sprite_url,omitempty"`
+	ScrubVTTURL    string `json:"scrub_vtt_url,omitempty"`
+
+	// Platform renditions of the final video (only populated by GetJob)
+	Renditions []RenditionResponse `json:"renditions,omitempty"`
+
+	// Per-scene storyboard data (only populated by GetJob)
+	Scenes []SceneResponse `json:"scenes,omitempty"`
+
+	// Per-scene voiceover clips with timeline placement (only populated by GetJob)
+	SceneVoiceovers []SceneVoiceoverResponse `json:"scene_voiceovers,omitempty"`
+
+	// Sound effects placed at the script's sfx sync points (only populated by GetJob)
+	SFX []SFXResponse `json:"sfx,omitempty"`
+
+	// Provider calls made for the job, with their prediction IDs (only populated by GetJob)
+	Provenance []ProvenanceResponse `json:"provenance,omitempty"`
+
+	// Time, retries and credits of each finished step (only populated by GetJob)
+	Stats *pricing.Usage `json:"stats,omitempty"`
+
+	// Credits of the steps finished so far
+	TotalCredits int `json:"total_credits"`
+
+	// A/B variants: a variant's parent and position, or a parent's variants (only populated by GetJob)
+	ParentJobID  string           `json:"parent_job_id,omitempty"`
+	VariantIndex int              `json:"variant_index,omitempty"`
+	Variants     []VariantSummary `json:"variants,omitempty"`
+}
+
+// SceneResponse represents a single scene of a job's storyboard
+type SceneResponse struct {
+	SceneNumber      int     `json:"scene_number"`
+	Duration         float64 `json:"duration"`
+	ShotType         string  `json:"shot_type,omitempty"`
+	GenerationPrompt string  `json:"generation_prompt"`
+	ClipURL          string  `json:"clip_url,omitempty"`
+	ThumbnailURL     string  `json:"thumbnail_url,omitempty"`
+	Version          int     `json:"version"`
+	TrimStart        float64 `json:"trim_start,omitempty"` // Seconds cut from the start of the clip as generated
+	TrimEnd          float64 `json:"trim_end,omitempty"`   // Seconds cut from its end
+
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	Seed           *int64 `json:"seed,omitempty"` // Pinned seed; clips without one record theirs in provenance
+}
+
+// SceneVoiceoverResponse represents a scene voiceover clip and where it plays in the video
+type SceneVoiceoverResponse struct {
+	SceneNumber int     `json:"scene_number"`
+	URL         string  `json:"url"`
+	StartTime   float64 `json:"start_time"`
+	Duration    float64 `json:"duration"`
 }
 
-// ListJobsResponse represents a list of jobs
+// SFXResponse represents a generated sound effect and when it plays in the video
+type SFXResponse struct {
+	Timestamp   float64 `json:"timestamp"`
+	Description string  `json:"description"`
+	URL         string  `json:"url"`
+}
+
+// ListJobsResponse represents one page of jobs
 type ListJobsResponse struct {
-	Jobs       []JobResponse `json:"jobs"`
-	TotalCount int           `json:"total_count"`
-	Page       int           `json:"page"`
-	PageSize   int           `json:"page_size"`
+	Jobs       []JobResponse   `json:"jobs"`
+	Count      int             `json:"count"` // Jobs in this page
+	PageSize   int             `json:"page_size"`
+	NextCursor string          `json:"next_cursor,omitempty"` // Pass as ?cursor= to fetch the next page; omitted on the last page
+	Truncated  bool            `json:"truncated,omitempty"`   // The scan limit was reached before the page filled
+	Filters    ListJobsFilters `json:"filters"`               // Filters applied to this page
 }
 
 // GetJob handles GET /api/v1/jobs/:id
@@ -124,7 +252,7 @@ func (h *JobsHandler) GetJob(c *gin.Context) {
 	// Generate presigned URL if video is completed (MP4)
 	var videoURL *string
 	if job.Status == "completed" && job.VideoKey != "" {
-		url, err := h.s3Service.GetPresignedURL(c.Request.Context(), job.VideoKey, 7*24*time.Hour)
+		url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, job.VideoKey, AssetURLExpiry)
 		if err != nil {
 			h.logger.Error("Failed to generate presigned URL for MP4",
 				zap.String("job_id", jobID),
@@ -138,7 +266,7 @@ func (h *JobsHandler) GetJob(c *gin.Context) {
 	// Generate presigned URL for WebM video if available
 	var webmVideoURL *string
 	if job.Status == "completed" && job.WebMVideoKey != "" {
-		url, err := h.s3Service.GetPresignedURL(c.Request.Context(), job.WebMVideoKey, 7*24*time.Hour)
+		url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, job.WebMVideoKey, AssetURLExpiry)
 		if err != nil {
 			h.logger.Warn("Failed to generate presigned URL for WebM",
 				zap.String("job_id", jobID),
@@ -152,7 +280,7 @@ func (h *JobsHandler) GetJob(c *gin.Context) {
 	// Generate presigned URL for background music
 	var audioURL string
 	if job.AudioURL != "" {
-		url, err := h.s3Service.GetPresignedURL(c.Request.Context(), extractS3Key(job.AudioURL), 7*24*time.Hour)
+		url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, s3util.Key(job.AudioURL), AssetURLExpiry)
 		if err != nil {
 			h.logger.Warn("Failed to generate presigned URL for audio",
 				zap.String("job_id", jobID),
@@ -166,7 +294,7 @@ func (h *JobsHandler) GetJob(c *gin.Context) {
 	// Generate presigned URL for narrator audio
 	var narratorAudioURL string
 	if job.NarratorAudioURL != "" {
-		url, err := h.s3Service.GetPresignedURL(c.Request.Context(), extractS3Key(job.NarratorAudioURL), 7*24*time.Hour)
+		url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, s3util.Key(job.NarratorAudioURL), AssetURLExpiry)
 		if err != nil {
 			h.logger.Warn("Failed to generate presigned URL for narrator audio",
 				zap.String("job_id", jobID),
@@ -180,7 +308,7 @@ func (h *JobsHandler) GetJob(c *gin.Context) {
 	// Generate presigned URL for thumbnail
 	var thumbnailURL string
 	if job.ThumbnailURL != "" {
-		url, err := h.s3Service.GetPresignedURL(c.Request.Context(), extractS3Key(job.ThumbnailURL), 7*24*time.Hour)
+		url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, s3util.Key(job.ThumbnailURL), AssetURLExpiry)
 		if err != nil {
 			h.logger.Warn("Failed to generate presigned URL for thumbnail",
 				zap.String("job_id", jobID),
@@ -197,41 +325,286 @@ func (h *JobsHandler) GetJob(c *gin.Context) {
 		sideEffectsStartTime = &job.SideEffectsStartTime
 	}
 
+	presign := newPresignCache(h.s3Service, h.logger).forJob(job)
+	thumbnails, thumbnailsWebP := buildThumbnailResponses(c.Request.Context(), job, presign, AssetURLExpiry)
+
+	etaSeconds, etaUpdatedAt := jobETA(job, time.Now())
 	response := JobResponse{
 		JobID:                job.JobID,
 		Status:               job.Status,
-		Stage:                job.Stage,
-		ProgressPercent:      calculateDynamicProgress(job.Stage, len(job.Scenes)),
+		Stage:                job.Stage.String(),
+		ProgressPercent:      jobprogress.Percent(job),
 		Prompt:               job.Prompt,
+		Title:                job.Title,
+		Note:                 job.Note,
 		Duration:             job.Duration,
 		VideoURL:             videoURL,
 		WebMVideoURL:         webmVideoURL,
 		Model:                job.Model,
+		ScriptID:             job.ScriptID,
+		DuplicatedFrom:       job.DuplicatedFrom,
+		ETASeconds:           etaSeconds,
+		ETAUpdatedAt:         etaUpdatedAt,
 		CreatedAt:            job.CreatedAt,
 		UpdatedAt:            job.UpdatedAt,
 		CompletedAt:          job.CompletedAt,
 		ErrorMessage:         job.ErrorMessage,
 		ThumbnailURL:         thumbnailURL,
+		Thumbnails:           thumbnails,
+		ThumbnailsWebP:       thumbnailsWebP,
 		AudioURL:             audioURL,
 		NarratorAudioURL:     narratorAudioURL,
+		MusicProvider:        job.MusicProvider,
 		ScenesCompleted:      job.ScenesCompleted,
 		SceneVideoURLs:       job.SceneVideoURLs,
 		SideEffectsText:      job.SideEffectsText,
 		SideEffectsStartTime: sideEffectsStartTime,
+		ComplianceIssues:     job.ComplianceIssues,
+		ModerationFlags:      job.ModerationFlags,
+		UndoableReorders:     len(job.SceneOrderHistory),
+		ScenePlan:            job.ScenePlan,
+		ContinuityMode:       continuityMode(job),
+		Language:             job.Language,
+		NarrationDuration:    job.NarrationDuration,
+		NarrationSpeed:       job.NarrationSpeed,
+		NarrationTruncated:   job.NarrationTruncated,
+		CaptionsURL:          presign.get(c.Request.Context(), job.CaptionsKey, AssetURLExpiry),
+		ScrubSpriteURL:       presign.get(c.Request.Context(), job.ScrubSpriteKey, AssetURLExpiry),
+		ScrubVTTURL:          presign.get(c.Request.Context(), job.ScrubVTTKey, AssetURLExpiry),
+		Scenes:               buildSceneResponses(c.Request.Context(), job, presign, AssetURLExpiry),
+		SceneVoiceovers:      buildSceneVoiceoverResponses(c.Request.Context(), job, presign, AssetURLExpiry),
+		SFX:                  buildSFXResponses(c.Request.Context(), job, presign, AssetURLExpiry),
+		Renditions:           buildRenditionResponses(c.Request.Context(), job, presign, AssetURLExpiry),
+		Provenance:           buildProvenanceResponses(job),
+		Stats:                buildUsageResponse(job),
+		TotalCredits:         pricing.TotalCredits(job.StepStats),
+		CallbackURL:          job.CallbackURL,
+		WebhookAttempts:      job.WebhookAttempts,
+		WebhookDeliveredAt:   job.WebhookDeliveredAt,
+		CancelRequested:      job.CancelRequested,
+		CanceledAt:           job.CanceledAt,
+	}
+	response.Width, response.Height = videoDimensions(job)
+
+	if job.Status == domain.StatusQueued {
+		response.QueuePosition = h.queuePosition(c.Request.Context(), job)
+	}
+
+	response.ParentJobID = job.ParentJobID
+	response.VariantIndex = job.VariantIndex
+	if job.IsVariantParent() {
+		h.addVariants(c.Request.Context(), &response, presign)
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// addVariants lists a parent's variants on its response, with the status aggregated from them
+func (h *JobsHandler) addVariants(ctx context.Context, response *JobResponse, presign *presignCache) {
+	variants, err := h.jobRepo.ListVariantJobs(ctx, response.JobID)
+	if err != nil {
+		h.logger.Warn("Failed to list variant jobs", zap.String("job_id", response.JobID), zap.Error(err))
+		return
+	}
+	response.Variants = buildVariantSummaries(ctx, variants, presign, AssetURLExpiry)
+	if summary, ok := summarizeVariants(variants); ok && !isTerminalStatus(response.Status) {
+		response.Status = summary.Status
+		response.Stage = summary.Stage.String()
+		response.ProgressPercent = summary.Progress
+	}
+}
+
+// queuePosition returns the job's 1-based place in its user's queue, or 0 if it can't be determined
+func (h *JobsHandler) queuePosition(ctx context.Context, job *domain.Job) int {
+	queued, err := h.jobRepo.ListQueuedJobs(ctx, job.UserID)
+	if err != nil {
+		h.logger.Warn("Failed to get queue position", zap.String("job_id", job.JobID), zap.Error(err))
+		return 0
+	}
+	for i, queuedJob := range queued {
+		if queuedJob.JobID == job.JobID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// urlPresigner is the subset of the S3 repository needed to presign asset URLs
+type urlPresigner interface {
+	GetPresignedURLInBucket(ctx context.Context, bucket, key string, duration time.Duration) (string, error)
+}
+
+// presignCache memoizes presigned URLs for the lifetime of a single request,
+// so a key referenced by several response fields is only signed once
+type presignCache struct {
+	presigner urlPresigner
+	bucket    string // "" for the presigner's default bucket
+	urls      map[string]string
+	logger    *zap.Logger
+}
+
+func newPresignCache(presigner urlPresigner, logger *zap.Logger) *presignCache {
+	return &presignCache{
+		presigner: presigner,
+		urls:      make(map[string]string),
+		logger:    logger,
+	}
+}
+
+// forJob returns a view of the cache that signs keys in the job's assets bucket
+func (p *presignCache) forJob(job *domain.Job) *presignCache {
+	return &presignCache{presigner: p.presigner, bucket: job.AssetBucket, urls: p.urls, logger: p.logger}
+}
+
+// get returns a presigned URL for key, or an empty string if signing fails
+func (p *presignCache) get(ctx context.Context, key string, duration time.Duration) string {
+	if key == "" {
+		return ""
+	}
+	cacheKey := p.bucket + "/" + key
+	if url, ok := p.urls[cacheKey]; ok {
+		return url
+	}
+
+	url, err := p.presigner.GetPresignedURLInBucket(ctx, p.bucket, key, duration)
+	if err != nil {
+		p.logger.Warn("Failed to generate presigned URL",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		url = ""
+	}
+	p.urls[cacheKey] = url
+	return url
+}
+
+// sceneAssetKeys resolves the S3 keys of a scene's current clip and thumbnail.
+// Regenerated scenes (SceneVersions set) use versioned keys, falling back to the
+// scene's current clip when the version has none; scenes that have not been
+// generated yet return empty keys. The thumbnail always follows the clip key
+// used: a clip stored under the job's clips prefix has the one beside it, so
+// reordered scenes keep theirs.
+func sceneAssetKeys(job *domain.Job, sceneNumber int) (clipKey, thumbnailKey string) {
+	version := job.SceneVersions[sceneNumber]
+	versionedClip := false
+
+	if version > 0 {
+		versionKey := fmt.Sprintf("scene-%d-v%d", sceneNumber, version)
+		if clipURL := job.ClipVersions[versionKey]; clipURL != "" {
+			clipKey = s3util.Key(clipURL)
+			versionedClip = true
+		}
+	}
+	if clipKey == "" && sceneNumber <= len(job.SceneVideoURLs) && job.SceneVideoURLs[sceneNumber-1] != "" {
+		clipKey = s3util.Key(job.SceneVideoURLs[sceneNumber-1])
+	}
+	if clipKey == "" {
+		return "", ""
+	}
+
+	if thumbnailKey = thumbnailBesideClip(job, clipKey); thumbnailKey != "" {
+		return clipKey, thumbnailKey
+	}
+	if versionedClip {
+		thumbnailKey = composition.VersionedSceneThumbnailKey(job, sceneNumber, version)
+	} else {
+		thumbnailKey = composition.SceneThumbnailKey(job, sceneNumber)
+	}
+	return clipKey, thumbnailKey
+}
+
+// thumbnailBesideClip returns the thumbnail key stored with a clip under the job's clips
+// prefix, or "" for a clip stored elsewhere
+func thumbnailBesideClip(job *domain.Job, clipKey string) string {
+	name, ok := strings.CutPrefix(clipKey, jobAssetPrefix(job)+"clips/")
+	if !ok || !strings.HasSuffix(name, ".mp4") {
+		return ""
+	}
+	return jobAssetPrefix(job) + "thumbnails/" + strings.TrimSuffix(name, ".mp4") + ".jpg"
+}
+
+// buildSceneResponses builds the storyboard payload for a job, presigning each
+// scene's clip and thumbnail through the shared per-request cache
+func buildSceneResponses(ctx context.Context, job *domain.Job, cache *presignCache, duration time.Duration) []SceneResponse {
+	if len(job.Scenes) == 0 {
+		return nil
+	}
+
+	scenes := make([]SceneResponse, len(job.Scenes))
+	for i, scene := range job.Scenes {
+		sceneNumber := i + 1
+		clipKey, thumbnailKey := sceneAssetKeys(job, sceneNumber)
+
+		scenes[i] = SceneResponse{
+			SceneNumber:      sceneNumber,
+			Duration:         scene.Duration,
+			ShotType:         string(scene.ShotType),
+			GenerationPrompt: scene.GenerationPrompt,
+			ClipURL:          cache.get(ctx, clipKey, duration),
+			ThumbnailURL:     cache.get(ctx, thumbnailKey, duration),
+			Version:          job.SceneVersions[sceneNumber],
+			NegativePrompt:   scene.NegativePrompt,
+			Seed:             scene.Seed,
+		}
+		if trim, ok := currentClipTrim(job, sceneNumber); ok {
+			scenes[i].TrimStart = trim.TrimStart
+			scenes[i].TrimEnd = trim.TrimEnd
+		}
+	}
+	return scenes
+}
+
+// buildSceneVoiceoverResponses presigns each scene voiceover clip through the shared per-request cache
+func buildSceneVoiceoverResponses(ctx context.Context, job *domain.Job, cache *presignCache, duration time.Duration) []SceneVoiceoverResponse {
+	if len(job.SceneVoiceovers) == 0 {
+		return nil
+	}
+
+	voiceovers := make([]SceneVoiceoverResponse, len(job.SceneVoiceovers))
+	for i, v := range job.SceneVoiceovers {
+		voiceovers[i] = SceneVoiceoverResponse{
+			SceneNumber: v.SceneNumber,
+			URL:         cache.get(ctx, s3util.Key(v.URL), duration),
+			StartTime:   v.StartTime,
+			Duration:    v.Duration,
+		}
+	}
+	return voiceovers
+}
+
+// buildSFXResponses presigns each sound effect through the shared per-request cache
+func buildSFXResponses(ctx context.Context, job *domain.Job, cache *presignCache, duration time.Duration) []SFXResponse {
+	if len(job.SFX) == 0 {
+		return nil
+	}
+
+	sfx := make([]SFXResponse, len(job.SFX))
+	for i, clip := range job.SFX {
+		sfx[i] = SFXResponse{
+			Timestamp:   clip.Timestamp,
+			Description: clip.Description,
+			URL:         cache.get(ctx, s3util.Key(clip.URL), duration),
+		}
+	}
+	return sfx
+}
+
 // ListJobs handles GET /api/v1/jobs
 // @Summary List jobs
-// @Description Get a list of video generation jobs
+// @Description Get a page of video generation jobs, newest first. Follow next_cursor to fetch older jobs.
+// @Description Filters are applied before paging, so each page holds up to page_size matching jobs; a page may be
+// @Description short with truncated=true when the scan limit is reached, in which case next_cursor continues the search.
 // @Tags jobs
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Opaque cursor from a previous response's next_cursor"
+// @Param page_size query int false "Page size (1-100)" default(20)
 // @Param status query string false "Filter by status"
+// @Param q query string false "Case-insensitive substring of the prompt or title"
+// @Param created_after query string false "Only jobs created at or after this time (unix seconds or RFC3339)"
+// @Param created_before query string false "Only jobs created at or before this time (unix seconds or RFC3339)"
+// @Param sort query string false "Order within each page: created_at (newest first), duration (longest first) or status" default(created_at)
 // @Success 200 {object} ListJobsResponse
+// @Failure 400 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Router /api/v1/jobs [get]
 // @Security BearerAuth
@@ -240,23 +613,31 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 	userID := auth.MustGetUserID(c)
 
 	// Get query parameters
-	pageSizeStr := c.DefaultQuery("page_size", "20")
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	query, filters, apiErr := parseListJobsQuery(c)
+	if apiErr != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{Error: apiErr})
+		return
 	}
 
-	// Get optional status filter
-	status := c.Query("status")
-
 	h.logger.Info("Listing jobs",
 		zap.String("user_id", userID),
-		zap.Int("page_size", pageSize),
-		zap.String("status_filter", status),
+		zap.Int("page_size", query.Limit),
+		zap.String("status_filter", query.Status),
+		zap.String("search", query.Search),
+		zap.Int64("created_after", query.CreatedAfter),
+		zap.Int64("created_before", query.CreatedBefore),
+		zap.String("sort", filters.Sort),
+		zap.Bool("has_cursor", query.ExclusiveStartKey != nil),
 	)
 
-	// Get jobs for user with optional status filter
-	jobs, err := h.jobRepo.GetJobsByUser(c.Request.Context(), userID, pageSize, status)
+	// Get one page of matching jobs for user
+	page, err := h.jobRepo.GetJobsByUser(c.Request.Context(), userID, query)
+	if stderrors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to list jobs",
 			zap.String("user_id", userID),
@@ -267,14 +648,29 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 		})
 		return
 	}
+	sortJobs(page.Jobs, filters.Sort)
+
+	nextCursor, err := repository.EncodeJobsCursor(page.LastEvaluatedKey)
+	if err != nil {
+		h.logger.Error("Failed to encode pagination cursor",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
 
 	// Convert to response format
-	jobResponses := make([]JobResponse, len(jobs))
-	for i, job := range jobs {
+	presign := newPresignCache(h.s3Service, h.logger)
+	jobResponses := make([]JobResponse, len(page.Jobs))
+	now := time.Now()
+	for i, job := range page.Jobs {
 		// Convert VideoKey to presigned URL if present (MP4)
 		var videoURL *string
 		if job.VideoKey != "" {
-			url, err := h.s3Service.GetPresignedURL(c.Request.Context(), job.VideoKey, 1*time.Hour)
+			url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, job.VideoKey, AssetURLExpiry)
 			if err != nil {
 				h.logger.Warn("Failed to generate presigned URL for MP4",
 					zap.String("job_id", job.JobID),
@@ -289,7 +685,7 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 		// Generate presigned URL for WebM video if available
 		var webmVideoURL *string
 		if job.WebMVideoKey != "" {
-			url, err := h.s3Service.GetPresignedURL(c.Request.Context(), job.WebMVideoKey, 1*time.Hour)
+			url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, job.WebMVideoKey, AssetURLExpiry)
 			if err != nil {
 				h.logger.Warn("Failed to generate presigned URL for WebM",
 					zap.String("job_id", job.JobID),
@@ -303,7 +699,7 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 		// Generate presigned URLs for audio (if present)
 		var audioURL string
 		if job.AudioURL != "" {
-			url, err := h.s3Service.GetPresignedURL(c.Request.Context(), extractS3Key(job.AudioURL), 1*time.Hour)
+			url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, s3util.Key(job.AudioURL), AssetURLExpiry)
 			if err != nil {
 				h.logger.Warn("Failed to generate presigned URL for audio",
 					zap.String("job_id", job.JobID),
@@ -316,7 +712,7 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 
 		var narratorAudioURL string
 		if job.NarratorAudioURL != "" {
-			url, err := h.s3Service.GetPresignedURL(c.Request.Context(), extractS3Key(job.NarratorAudioURL), 1*time.Hour)
+			url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, s3util.Key(job.NarratorAudioURL), AssetURLExpiry)
 			if err != nil {
 				h.logger.Warn("Failed to generate presigned URL for narrator audio",
 					zap.String("job_id", job.JobID),
@@ -330,7 +726,7 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 		// Generate presigned URL for thumbnail
 		var thumbnailURL string
 		if job.ThumbnailURL != "" {
-			url, err := h.s3Service.GetPresignedURL(c.Request.Context(), extractS3Key(job.ThumbnailURL), 1*time.Hour)
+			url, err := h.s3Service.GetPresignedURLInBucket(c.Request.Context(), job.AssetBucket, s3util.Key(job.ThumbnailURL), AssetURLExpiry)
 			if err != nil {
 				h.logger.Warn("Failed to generate presigned URL for thumbnail",
 					zap.String("job_id", job.JobID),
@@ -341,41 +737,57 @@ func (h *JobsHandler) ListJobs(c *gin.Context) {
 			}
 		}
 
+		thumbnails, thumbnailsWebP := buildThumbnailResponses(c.Request.Context(), job, presign.forJob(job), AssetURLExpiry)
+
 		// Prepare side effects start time pointer
 		var sideEffectsStartTime *float64
 		if job.SideEffectsStartTime > 0 {
 			sideEffectsStartTime = &job.SideEffectsStartTime
 		}
 
+		etaSeconds, etaUpdatedAt := jobETA(job, now)
 		jobResponses[i] = JobResponse{
 			JobID:                job.JobID,
 			Status:               job.Status,
-			Stage:                job.Stage,
-			ProgressPercent:      calculateDynamicProgress(job.Stage, len(job.Scenes)),
+			Stage:                job.Stage.String(),
+			ProgressPercent:      jobprogress.Percent(job),
 			VideoURL:             videoURL,
 			WebMVideoURL:         webmVideoURL,
 			ErrorMessage:         job.ErrorMessage,
 			Prompt:               job.Prompt,
+			Title:                job.Title,
+			Note:                 job.Note,
 			Duration:             job.Duration,
 			Model:                job.Model,
+			ScriptID:             job.ScriptID,
+			DuplicatedFrom:       job.DuplicatedFrom,
+			ETASeconds:           etaSeconds,
+			ETAUpdatedAt:         etaUpdatedAt,
 			CreatedAt:            job.CreatedAt,
 			UpdatedAt:            job.UpdatedAt,
 			CompletedAt:          job.CompletedAt,
 			ThumbnailURL:         thumbnailURL,
+			Thumbnails:           thumbnails,
+			ThumbnailsWebP:       thumbnailsWebP,
 			AudioURL:             audioURL,
 			NarratorAudioURL:     narratorAudioURL,
+			MusicProvider:        job.MusicProvider,
 			ScenesCompleted:      job.ScenesCompleted,
 			SceneVideoURLs:       job.SceneVideoURLs,
 			SideEffectsText:      job.SideEffectsText,
 			SideEffectsStartTime: sideEffectsStartTime,
+			TotalCredits:         pricing.TotalCredits(job.StepStats),
 		}
+		jobResponses[i].Width, jobResponses[i].Height = videoDimensions(job)
 	}
 
 	response := ListJobsResponse{
 		Jobs:       jobResponses,
-		TotalCount: len(jobResponses),
-		Page:       1,
-		PageSize:   pageSize,
+		Count:      len(jobResponses),
+		PageSize:   query.Limit,
+		NextCursor: nextCursor,
+		Truncated:  page.Truncated,
+		Filters:    filters,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -428,19 +840,31 @@ func (h *JobsHandler) DeleteJob(c *gin.Context) {
 		return
 	}
 
+	// The record is the only way to find the assets again, so keep it unless cleanup can run
+	if h.s3Service == nil || h.assetsBucket == "" {
+		h.logger.Error("Asset storage not configured, refusing to delete job",
+			zap.String("job_id", jobID),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
 	// Delete all S3 assets using batch deletion (best effort - continue even if it fails)
-	prefix := fmt.Sprintf("users/%s/jobs/%s/", userID, jobID)
+	prefix := jobAssetPrefix(job)
 	h.logger.Info("Deleting S3 assets for job",
 		zap.String("job_id", jobID),
 		zap.String("user_id", userID),
 		zap.String("prefix", prefix),
 	)
-	s3Err := h.s3Service.DeletePrefix(c.Request.Context(), h.assetsBucket, prefix)
+	deletedObjects, s3Err := deleteJobAssets(c.Request.Context(), h.s3Service, h.assetsBucket, job)
 	if s3Err != nil {
 		h.logger.Warn("Failed to delete S3 assets (continuing with DB deletion)",
 			zap.String("job_id", jobID),
 			zap.String("user_id", userID),
 			zap.String("prefix", prefix),
+			zap.Int("deleted_objects", deletedObjects),
 			zap.Error(s3Err),
 		)
 		// Continue with deletion even if S3 delete fails
@@ -460,80 +884,15 @@ func (h *JobsHandler) DeleteJob(c *gin.Context) {
 		return
 	}
 
+	releaseJobStorage(c.Request.Context(), h.storage, job, h.logger)
+
 	h.logger.Info("Job deleted successfully",
 		zap.String("job_id", jobID),
 		zap.String("user_id", userID),
 		zap.Bool("s3_cleanup_success", s3Err == nil),
+		zap.Int("deleted_objects", deletedObjects),
 	)
 
 	// Return 204 No Content for successful DELETE (standard HTTP response)
 	c.Status(http.StatusNoContent)
 }
-
-// calculateDynamicProgress calculates progress percentage based on stage and total scenes
-// Progress allocation: Script (5%), Narrator (3%), Scenes (72%), Music (5%), Composition (15%)
-// NOTE: This is duplicated from jobs_stream.go - could be refactored to shared package
-func calculateDynamicProgress(stage string, totalScenes int) int {
-	// Handle edge case
-	if totalScenes == 0 {
-		totalScenes = 3 // Default to 3 scenes if not set
-	}
-
-	// Script generation stages
-	if stage == "script_generating" {
-		return 2
-	}
-	if stage == "script_complete" {
-		return 5
-	}
-
-	// Narrator generation stages
-	if stage == "narrator_generating" {
-		return 6
-	}
-	if stage == "narrator_complete" {
-		return 8
-	}
-
-	// Scene generation stages (72% allocated, split evenly)
-	if len(stage) > 6 && stage[:6] == "scene_" {
-		var sceneNum int
-		var suffix string
-		_, err := fmt.Sscanf(stage, "scene_%d_%s", &sceneNum, &suffix)
-		if err == nil && sceneNum > 0 && sceneNum <= totalScenes {
-			percentPerScene := 72.0 / float64(totalScenes)
-
-			switch suffix {
-			case "generating":
-				progress := 8.0 + float64(sceneNum-1)*percentPerScene
-				return int(progress)
-			case "complete":
-				progress := 8.0 + float64(sceneNum)*percentPerScene
-				if sceneNum == totalScenes {
-					return 80
-				}
-				return int(progress)
-			}
-		}
-	}
-
-	// Music generation stages
-	if stage == "audio_generating" {
-		return 82
-	}
-	if stage == "audio_complete" {
-		return 85
-	}
-
-	// Composition stage
-	if stage == "composing" {
-		return 92
-	}
-
-	// Completion
-	if stage == "complete" {
-		return 100
-	}
-
-	return 0
-}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// MaxBulkDeleteJobs is how many jobs one bulk delete request may name
+const MaxBulkDeleteJobs = 50
+
+// bulkDeleteWorkers bounds how many jobs a bulk delete removes at once
+const bulkDeleteWorkers = 5
+
+// Per-job outcomes of a bulk delete
+const (
+	BulkDeleteDeleted  = "deleted"
+	BulkDeleteNotFound = "not_found" // Also reported for other users' jobs, so IDs can't be probed
+	BulkDeleteError    = "error"
+)
+
+// BulkDeleteJobsRequest is the body of POST /api/v1/jobs/bulk-delete
+type BulkDeleteJobsRequest struct {
+	JobIDs []string `json:"job_ids"`
+}
+
+// BulkDeleteJobResult is the outcome for one job
+type BulkDeleteJobResult struct {
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkDeleteJobsResponse lists one result per requested job, in request order
+type BulkDeleteJobsResponse struct {
+	Results []BulkDeleteJobResult `json:"results"`
+	Deleted int                   `json:"deleted"`
+	Failed  int                   `json:"failed"`
+}
+
+// jobDeleteStore is the subset of the job repository needed to delete jobs
+type jobDeleteStore interface {
+	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
+	DeleteJob(ctx context.Context, jobID string) error
+}
+
+// BulkDeleteJobs handles POST /api/v1/jobs/bulk-delete
+// @Summary Delete several jobs
+// @Description Delete up to 50 jobs and their assets. Each job is reported as deleted, not_found or error.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param request body BulkDeleteJobsRequest true "Jobs to delete"
+// @Success 200 {object} BulkDeleteJobsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/bulk-delete [post]
+// @Security BearerAuth
+func (h *JobsHandler) BulkDeleteJobs(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	var req BulkDeleteJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+	jobIDs, msg := uniqueJobIDs(req.JobIDs)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrInvalidRequest, msg, nil),
+		})
+		return
+	}
+
+	// As with DeleteJob, records are only removed when their assets can be cleaned up too
+	if h.s3Service == nil || h.assetsBucket == "" {
+		h.logger.Error("Asset storage not configured, refusing to delete jobs",
+			zap.Int("job_count", len(jobIDs)),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	deleter := &jobDeleter{jobs: h.jobRepo, assets: h.s3Service, storage: h.storage, bucket: h.assetsBucket, logger: h.logger}
+	results := deleter.deleteUserJobs(c.Request.Context(), userID, jobIDs)
+	resp := BulkDeleteJobsResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case BulkDeleteDeleted:
+			resp.Deleted++
+		case BulkDeleteError:
+			resp.Failed++
+		}
+	}
+
+	h.logger.Info("Bulk job deletion finished",
+		zap.String("user_id", userID),
+		zap.Int("requested", len(jobIDs)),
+		zap.Int("deleted", resp.Deleted),
+		zap.Int("failed", resp.Failed),
+	)
+	c.JSON(http.StatusOK, resp)
+}
+
+// uniqueJobIDs drops repeated IDs, keeping the first occurrence. It returns a message
+// describing what's wrong with the list, or "" when it's valid.
+func uniqueJobIDs(ids []string) ([]string, string) {
+	if len(ids) == 0 {
+		return nil, "job_ids must list at least one job"
+	}
+
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			return nil, "job_ids must not contain empty IDs"
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	if len(unique) > MaxBulkDeleteJobs {
+		return nil, fmt.Sprintf("job_ids may list at most %d jobs", MaxBulkDeleteJobs)
+	}
+	return unique, ""
+}
+
+// jobDeleter deletes jobs with their assets and releases their storage
+type jobDeleter struct {
+	jobs    jobDeleteStore
+	assets  jobAssetStore
+	storage storageCounter // Optional; nil skips storage accounting
+	bucket  string
+	logger  *zap.Logger
+}
+
+// deleteUserJobs deletes userID's jobs and their assets, bulkDeleteWorkers at a time. Every
+// job gets a result, in the order of jobIDs; one failure doesn't stop the others.
+func (d *jobDeleter) deleteUserJobs(ctx context.Context, userID string, jobIDs []string) []BulkDeleteJobResult {
+	results := make([]BulkDeleteJobResult, len(jobIDs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(bulkDeleteWorkers, len(jobIDs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = d.deleteUserJob(ctx, userID, jobIDs[i])
+			}
+		}()
+	}
+	for i := range jobIDs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// deleteUserJob deletes one job for deleteUserJobs. Like DeleteJob, a failed asset cleanup is
+// logged and the record is deleted anyway.
+func (d *jobDeleter) deleteUserJob(ctx context.Context, userID, jobID string) BulkDeleteJobResult {
+	job, err := d.jobs.GetJob(ctx, jobID)
+	if stderrors.Is(err, repository.ErrJobNotFound) {
+		return BulkDeleteJobResult{JobID: jobID, Status: BulkDeleteNotFound}
+	}
+	if err != nil {
+		d.logger.Error("Failed to get job for bulk deletion",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return BulkDeleteJobResult{JobID: jobID, Status: BulkDeleteError, Error: "failed to load job"}
+	}
+	if job.UserID != userID {
+		d.logger.Warn("User attempted to bulk delete another user's job",
+			zap.String("job_id", jobID),
+			zap.String("job_user_id", job.UserID),
+			zap.String("requesting_user_id", userID),
+		)
+		return BulkDeleteJobResult{JobID: jobID, Status: BulkDeleteNotFound}
+	}
+
+	deletedObjects, err := deleteJobAssets(ctx, d.assets, d.bucket, job)
+	if err != nil {
+		d.logger.Warn("Failed to delete S3 assets (continuing with DB deletion)",
+			zap.String("job_id", jobID),
+			zap.String("user_id", userID),
+			zap.Int("deleted_objects", deletedObjects),
+			zap.Error(err),
+		)
+	}
+
+	if err := d.jobs.DeleteJob(ctx, jobID); err != nil {
+		d.logger.Error("Failed to delete job from DynamoDB",
+			zap.String("job_id", jobID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return BulkDeleteJobResult{JobID: jobID, Status: BulkDeleteError, Error: "failed to delete job"}
+	}
+	releaseJobStorage(ctx, d.storage, job, d.logger)
+	return BulkDeleteJobResult{JobID: jobID, Status: BulkDeleteDeleted}
+}
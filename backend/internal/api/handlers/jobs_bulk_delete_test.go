@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeJobDeleteStore serves jobs from a map; getErr and deleteErr fail individual job IDs
+type fakeJobDeleteStore struct {
+	mu        sync.Mutex
+	jobs      map[string]*domain.Job
+	getErr    map[string]error
+	deleteErr map[string]error
+	deleted   []string
+}
+
+func (s *fakeJobDeleteStore) GetJob(_ context.Context, jobID string) (*domain.Job, error) {
+	if err := s.getErr[jobID]; err != nil {
+		return nil, err
+	}
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, repository.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *fakeJobDeleteStore) DeleteJob(_ context.Context, jobID string) error {
+	if err := s.deleteErr[jobID]; err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, jobID)
+	return nil
+}
+
+// lockedJobAssetStore makes fakeJobAssetStore safe for the bulk delete workers
+type lockedJobAssetStore struct {
+	mu    sync.Mutex
+	store *fakeJobAssetStore
+}
+
+func (s *lockedJobAssetStore) DeletePrefix(ctx context.Context, bucket, prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.DeletePrefix(ctx, bucket, prefix)
+}
+
+func (s *lockedJobAssetStore) DeleteFile(ctx context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.DeleteFile(ctx, bucket, key)
+}
+
+func TestDeleteUserJobsReportsEachJob(t *testing.T) {
+	store := &fakeJobDeleteStore{
+		jobs: map[string]*domain.Job{
+			"job-1":     {JobID: "job-1", UserID: "user123"},
+			"job-2":     {JobID: "job-2", UserID: "user123"},
+			"job-other": {JobID: "job-other", UserID: "user999"},
+			"job-stuck": {JobID: "job-stuck", UserID: "user123"},
+		},
+		getErr:    map[string]error{"job-unreadable": errors.New("throttled")},
+		deleteErr: map[string]error{"job-stuck": errors.New("conditional check failed")},
+	}
+	assets := &lockedJobAssetStore{store: &fakeJobAssetStore{}}
+
+	ids := []string{"job-1", "job-missing", "job-other", "job-unreadable", "job-stuck", "job-2"}
+	results := (&jobDeleter{jobs: store, assets: assets, bucket: "assets", logger: zap.NewNop()}).deleteUserJobs(context.Background(), "user123", ids)
+
+	require.Equal(t, []BulkDeleteJobResult{
+		{JobID: "job-1", Status: BulkDeleteDeleted},
+		{JobID: "job-missing", Status: BulkDeleteNotFound},
+		{JobID: "job-other", Status: BulkDeleteNotFound},
+		{JobID: "job-unreadable", Status: BulkDeleteError, Error: "failed to load job"},
+		{JobID: "job-stuck", Status: BulkDeleteError, Error: "failed to delete job"},
+		{JobID: "job-2", Status: BulkDeleteDeleted},
+	}, results)
+	require.ElementsMatch(t, []string{"job-1", "job-2"}, store.deleted)
+	require.NotContains(t, assets.store.deletedPrefixes, "users/user999/jobs/job-other/", "another user's assets are untouched")
+}
+
+func TestDeleteUserJobsDeletesRecordWhenAssetCleanupFails(t *testing.T) {
+	store := &fakeJobDeleteStore{jobs: map[string]*domain.Job{"job-1": {JobID: "job-1", UserID: "user123"}}}
+	assets := &lockedJobAssetStore{store: &fakeJobAssetStore{prefixErr: errors.New("list failed")}}
+
+	results := (&jobDeleter{jobs: store, assets: assets, bucket: "assets", logger: zap.NewNop()}).deleteUserJobs(context.Background(), "user123", []string{"job-1"})
+	require.Equal(t, []BulkDeleteJobResult{{JobID: "job-1", Status: BulkDeleteDeleted}}, results)
+	require.Equal(t, []string{"job-1"}, store.deleted)
+}
+
+func TestDeleteUserJobsManyJobs(t *testing.T) {
+	store := &fakeJobDeleteStore{jobs: map[string]*domain.Job{}}
+	var ids []string
+	for i := range MaxBulkDeleteJobs {
+		id := fmt.Sprintf("job-%02d", i)
+		store.jobs[id] = &domain.Job{JobID: id, UserID: "user123"}
+		ids = append(ids, id)
+	}
+	assets := &lockedJobAssetStore{store: &fakeJobAssetStore{}}
+
+	results := (&jobDeleter{jobs: store, assets: assets, bucket: "assets", logger: zap.NewNop()}).deleteUserJobs(context.Background(), "user123", ids)
+	require.Len(t, results, MaxBulkDeleteJobs)
+	for i, result := range results {
+		require.Equal(t, ids[i], result.JobID, "results keep request order")
+		require.Equal(t, BulkDeleteDeleted, result.Status)
+	}
+	require.Len(t, assets.store.deletedPrefixes, MaxBulkDeleteJobs)
+}
+
+func TestUniqueJobIDs(t *testing.T) {
+	ids, msg := uniqueJobIDs([]string{"job-1", "job-2", "job-1"})
+	require.Empty(t, msg)
+	require.Equal(t, []string{"job-1", "job-2"}, ids)
+
+	_, msg = uniqueJobIDs(nil)
+	require.Contains(t, msg, "at least one")
+
+	_, msg = uniqueJobIDs([]string{"job-1", ""})
+	require.Contains(t, msg, "empty")
+
+	repeated := make([]string, MaxBulkDeleteJobs+10)
+	for i := range repeated {
+		repeated[i] = "job-1"
+	}
+	ids, msg = uniqueJobIDs(repeated)
+	require.Empty(t, msg, "repeats count once")
+	require.Equal(t, []string{"job-1"}, ids)
+
+	var distinct []string
+	for i := range MaxBulkDeleteJobs + 1 {
+		distinct = append(distinct, fmt.Sprintf("job-%d", i))
+	}
+	_, msg = uniqueJobIDs(distinct)
+	require.Contains(t, msg, "at most 50")
+}
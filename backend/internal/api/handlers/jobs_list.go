@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+)
+
+// ListJobs page size bounds
+const (
+	defaultJobsPageSize = 20
+	maxJobsPageSize     = 100
+	maxJobsSearchLength = 200
+)
+
+// Sort orders accepted by ListJobs; created_at (newest first) is the default
+const (
+	jobsSortCreatedAt = "created_at"
+	jobsSortDuration  = "duration"
+	jobsSortStatus    = "status"
+)
+
+// ListJobsFilters echoes the filters applied to a ListJobs page
+type ListJobsFilters struct {
+	Status        string `json:"status,omitempty"`
+	Query         string `json:"q,omitempty"`
+	CreatedAfter  int64  `json:"created_after,omitempty"`  // Unix seconds, inclusive
+	CreatedBefore int64  `json:"created_before,omitempty"` // Unix seconds, inclusive
+	Sort          string `json:"sort"`
+}
+
+// parseListJobsQuery reads the ListJobs query parameters into a repository query.
+// page_size falls back to the default when out of range; every other invalid value is an error.
+func parseListJobsQuery(c *gin.Context) (repository.JobsQuery, ListJobsFilters, *errors.APIError) {
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultJobsPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxJobsPageSize {
+		pageSize = defaultJobsPageSize
+	}
+
+	filters := ListJobsFilters{
+		Status: c.Query("status"),
+		Query:  strings.TrimSpace(c.Query("q")),
+		Sort:   c.DefaultQuery("sort", jobsSortCreatedAt),
+	}
+	if len(filters.Query) > maxJobsSearchLength {
+		return repository.JobsQuery{}, filters, errors.NewValidationError("q", "Search text must be at most 200 characters")
+	}
+
+	switch filters.Sort {
+	case jobsSortCreatedAt, jobsSortDuration, jobsSortStatus:
+	default:
+		return repository.JobsQuery{}, filters, errors.NewValidationError("sort", "Sort must be one of created_at, duration, status")
+	}
+
+	if filters.CreatedAfter, err = parseTimeParam(c.Query("created_after")); err != nil {
+		return repository.JobsQuery{}, filters, errors.NewValidationError("created_after", "Must be unix seconds or an RFC3339 timestamp")
+	}
+	if filters.CreatedBefore, err = parseTimeParam(c.Query("created_before")); err != nil {
+		return repository.JobsQuery{}, filters, errors.NewValidationError("created_before", "Must be unix seconds or an RFC3339 timestamp")
+	}
+	if filters.CreatedAfter > 0 && filters.CreatedBefore > 0 && filters.CreatedAfter > filters.CreatedBefore {
+		return repository.JobsQuery{}, filters, errors.NewValidationError("created_after", "created_after must not be later than created_before")
+	}
+
+	startKey, err := repository.DecodeJobsCursor(c.Query("cursor"))
+	if err != nil {
+		return repository.JobsQuery{}, filters, errors.NewValidationError("cursor", "Invalid pagination cursor")
+	}
+
+	return repository.JobsQuery{
+		Limit:             pageSize,
+		Status:            filters.Status,
+		Search:            filters.Query,
+		CreatedAfter:      filters.CreatedAfter,
+		CreatedBefore:     filters.CreatedBefore,
+		ExclusiveStartKey: startKey,
+	}, filters, nil
+}
+
+// parseTimeParam accepts unix seconds or RFC3339; empty means unbounded (0)
+func parseTimeParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, strconv.ErrRange
+		}
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// sortJobs orders one page of jobs. Pages always advance newest to oldest, so duration and
+// status sorts order the jobs within each page; ties keep newest first.
+func sortJobs(jobs []*domain.Job, order string) {
+	switch order {
+	case jobsSortDuration:
+		sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].Duration > jobs[j].Duration })
+	case jobsSortStatus:
+		sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].Status < jobs[j].Status })
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func newListJobsContext(rawQuery string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/jobs?"+rawQuery, nil)
+	return c
+}
+
+func TestParseListJobsQuery(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		query, filters, apiErr := parseListJobsQuery(newListJobsContext(""))
+		require.Nil(t, apiErr)
+		require.Equal(t, defaultJobsPageSize, query.Limit)
+		require.Empty(t, query.Search)
+		require.Zero(t, query.CreatedAfter)
+		require.Zero(t, query.CreatedBefore)
+		require.Nil(t, query.ExclusiveStartKey)
+		require.Equal(t, ListJobsFilters{Sort: jobsSortCreatedAt}, filters)
+	})
+
+	t.Run("all filters", func(t *testing.T) {
+		query, filters, apiErr := parseListJobsQuery(newListJobsContext(
+			"page_size=5&status=completed&q=+Kitchen+&created_after=1735689600&created_before=2025-02-01T00:00:00Z&sort=duration",
+		))
+		require.Nil(t, apiErr)
+		require.Equal(t, 5, query.Limit)
+		require.Equal(t, "completed", query.Status)
+		require.Equal(t, "Kitchen", query.Search)
+		require.Equal(t, int64(1735689600), query.CreatedAfter)
+		require.Equal(t, int64(1738368000), query.CreatedBefore)
+		require.Equal(t, ListJobsFilters{
+			Status:        "completed",
+			Query:         "Kitchen",
+			CreatedAfter:  1735689600,
+			CreatedBefore: 1738368000,
+			Sort:          jobsSortDuration,
+		}, filters)
+	})
+
+	t.Run("out of range page size falls back to default", func(t *testing.T) {
+		for _, raw := range []string{"page_size=0", "page_size=101", "page_size=abc"} {
+			query, _, apiErr := parseListJobsQuery(newListJobsContext(raw))
+			require.Nil(t, apiErr, raw)
+			require.Equal(t, defaultJobsPageSize, query.Limit, raw)
+		}
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		cases := map[string]string{
+			"sort=newest":             "sort",
+			"created_after=yesterday": "created_after",
+			"created_before=-5":       "created_before",
+			"created_after=1738368000&created_before=1735689600": "created_after",
+			"cursor=not-a-cursor":                                "cursor",
+		}
+		for raw, field := range cases {
+			_, _, apiErr := parseListJobsQuery(newListJobsContext(raw))
+			require.NotNil(t, apiErr, raw)
+			require.Equal(t, "INVALID_REQUEST", apiErr.Code, raw)
+			require.Equal(t, field, apiErr.Details["field"], raw)
+		}
+	})
+}
+
+func TestSortJobs(t *testing.T) {
+	newPage := func() []*domain.Job {
+		return []*domain.Job{
+			{JobID: "newest", Duration: 15, Status: "processing"},
+			{JobID: "middle", Duration: 30, Status: "completed"},
+			{JobID: "oldest", Duration: 15, Status: "completed"},
+		}
+	}
+	ids := func(jobs []*domain.Job) []string {
+		out := make([]string, 0, len(jobs))
+		for _, job := range jobs {
+			out = append(out, job.JobID)
+		}
+		return out
+	}
+
+	jobs := newPage()
+	sortJobs(jobs, jobsSortCreatedAt)
+	require.Equal(t, []string{"newest", "middle", "oldest"}, ids(jobs))
+
+	jobs = newPage()
+	sortJobs(jobs, jobsSortDuration)
+	require.Equal(t, []string{"middle", "newest", "oldest"}, ids(jobs))
+
+	jobs = newPage()
+	sortJobs(jobs, jobsSortStatus)
+	require.Equal(t, []string{"middle", "oldest", "newest"}, ids(jobs))
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakePresigner struct {
+	calls map[string]int
+}
+
+func (f *fakePresigner) GetPresignedURLInBucket(_ context.Context, _, key string, _ time.Duration) (string, error) {
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[key]++
+	return "https://signed.example.com/" + key, nil
+}
+
+func newStoryboardJob() *domain.Job {
+	return &domain.Job{
+		JobID:  "job456",
+		UserID: "user123",
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 8, ShotType: "wide", GenerationPrompt: "opening shot"},
+			{SceneNumber: 2, Duration: 6, ShotType: "close_up", GenerationPrompt: "product reveal"},
+			{SceneNumber: 3, Duration: 8, ShotType: "medium", GenerationPrompt: "closing shot"},
+		},
+		SceneVideoURLs: []string{
+			"https://bucket.s3.amazonaws.com/users/user123/jobs/job456/clips/scene-001.mp4",
+			"https://bucket.s3.amazonaws.com/users/user123/jobs/job456/clips/scene-002.mp4",
+		},
+	}
+}
+
+func TestSceneAssetKeys(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mutate        func(job *domain.Job)
+		sceneNumber   int
+		wantClip      string
+		wantThumbnail string
+	}{
+		{
+			name:          "unversioned scene",
+			sceneNumber:   1,
+			wantClip:      "users/user123/jobs/job456/clips/scene-001.mp4",
+			wantThumbnail: "users/user123/jobs/job456/thumbnails/scene-001.jpg",
+		},
+		{
+			name: "versioned scene",
+			mutate: func(job *domain.Job) {
+				job.SceneVersions = map[int]int{2: 3}
+				job.ClipVersions = map[string]string{
+					"scene-2-v3": "https://bucket.s3.amazonaws.com/users/user123/jobs/job456/clips/scene-002-v3.mp4",
+				}
+			},
+			sceneNumber:   2,
+			wantClip:      "users/user123/jobs/job456/clips/scene-002-v3.mp4",
+			wantThumbnail: "users/user123/jobs/job456/thumbnails/scene-002-v3.jpg",
+		},
+		{
+			name: "versioned scene without clip version entry falls back to current clip",
+			mutate: func(job *domain.Job) {
+				job.SceneVersions = map[int]int{1: 2}
+			},
+			sceneNumber:   1,
+			wantClip:      "users/user123/jobs/job456/clips/scene-001.mp4",
+			wantThumbnail: "users/user123/jobs/job456/thumbnails/scene-001.jpg",
+		},
+		{
+			name: "versioned scene falling back to a clip stored elsewhere",
+			mutate: func(job *domain.Job) {
+				job.SceneVersions = map[int]int{1: 2}
+				job.SceneVideoURLs[0] = "https://bucket.s3.amazonaws.com/legacy/job456-scene-1.mp4"
+			},
+			sceneNumber:   1,
+			wantClip:      "legacy/job456-scene-1.mp4",
+			wantThumbnail: "users/user123/jobs/job456/thumbnails/scene-001.jpg",
+		},
+		{
+			name: "reordered scene keeps the thumbnail beside its clip",
+			mutate: func(job *domain.Job) {
+				job.SceneVideoURLs[0], job.SceneVideoURLs[1] = job.SceneVideoURLs[1], job.SceneVideoURLs[0]
+			},
+			sceneNumber:   1,
+			wantClip:      "users/user123/jobs/job456/clips/scene-002.mp4",
+			wantThumbnail: "users/user123/jobs/job456/thumbnails/scene-002.jpg",
+		},
+		{
+			name:        "scene not generated yet",
+			sceneNumber: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := newStoryboardJob()
+			if tc.mutate != nil {
+				tc.mutate(job)
+			}
+
+			clipKey, thumbnailKey := sceneAssetKeys(job, tc.sceneNumber)
+			require.Equal(t, tc.wantClip, clipKey)
+			require.Equal(t, tc.wantThumbnail, thumbnailKey)
+		})
+	}
+}
+
+func TestBuildThumbnailResponses(t *testing.T) {
+	job := &domain.Job{Thumbnails: []domain.ThumbnailRendition{
+		{Width: 320, Format: "jpg", Key: "users/user123/jobs/job456/thumbnails/job-thumbnail-320.jpg"},
+		{Width: 1280, Format: "jpg", Key: "users/user123/jobs/job456/thumbnails/job-thumbnail-1280.jpg"},
+		{Width: 320, Format: "webp", Key: "users/user123/jobs/job456/thumbnails/job-thumbnail-320.webp"},
+	}}
+	cache := newPresignCache(&fakePresigner{}, zap.NewNop())
+
+	jpeg, webp := buildThumbnailResponses(context.Background(), job, cache, time.Hour)
+	require.Equal(t, map[string]string{
+		"320":  "https://signed.example.com/users/user123/jobs/job456/thumbnails/job-thumbnail-320.jpg",
+		"1280": "https://signed.example.com/users/user123/jobs/job456/thumbnails/job-thumbnail-1280.jpg",
+	}, jpeg)
+	require.Equal(t, map[string]string{
+		"320": "https://signed.example.com/users/user123/jobs/job456/thumbnails/job-thumbnail-320.webp",
+	}, webp)
+
+	// Jobs from before renditions existed only have thumbnail_url
+	jpeg, webp = buildThumbnailResponses(context.Background(), &domain.Job{}, cache, time.Hour)
+	require.Nil(t, jpeg)
+	require.Nil(t, webp)
+}
+
+func TestBuildSceneResponses(t *testing.T) {
+	job := newStoryboardJob()
+	job.SceneVersions = map[int]int{2: 1}
+	job.ClipVersions = map[string]string{
+		"scene-2-v1": "https://bucket.s3.amazonaws.com/users/user123/jobs/job456/clips/scene-002-v1.mp4",
+	}
+
+	presigner := &fakePresigner{}
+	cache := newPresignCache(presigner, zap.NewNop())
+
+	scenes := buildSceneResponses(context.Background(), job, cache, time.Hour)
+	require.Len(t, scenes, 3)
+
+	require.Equal(t, 1, scenes[0].SceneNumber)
+	require.Equal(t, "wide", scenes[0].ShotType)
+	require.Equal(t, "opening shot", scenes[0].GenerationPrompt)
+	require.Equal(t, 0, scenes[0].Version)
+	require.Equal(t, "https://signed.example.com/users/user123/jobs/job456/thumbnails/scene-001.jpg", scenes[0].ThumbnailURL)
+
+	require.Equal(t, 1, scenes[1].Version)
+	require.Equal(t, "https://signed.example.com/users/user123/jobs/job456/clips/scene-002-v1.mp4", scenes[1].ClipURL)
+	require.Equal(t, "https://signed.example.com/users/user123/jobs/job456/thumbnails/scene-002-v1.jpg", scenes[1].ThumbnailURL)
+
+	require.Empty(t, scenes[2].ClipURL)
+	require.Empty(t, scenes[2].ThumbnailURL)
+	require.Equal(t, float64(8), scenes[2].Duration)
+
+	// Signing the same keys again within a request must hit the cache
+	buildSceneResponses(context.Background(), job, cache, time.Hour)
+	require.Len(t, presigner.calls, 4)
+	for key, count := range presigner.calls {
+		require.Equal(t, 1, count, "key %s presigned more than once", key)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"image"
+	"image/gif"
+	_ "image/jpeg" // Register JPEG for logo inspection
+	_ "image/png"  // Register PNG for logo inspection
+	"io"
+	"strings"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"github.com/omnigen/backend/internal/validation"
+)
+
+var (
+	errLogoFormat   = stderrors.New("logo must be a PNG, JPEG or GIF image")
+	errLogoAnimated = stderrors.New("animated GIFs are not supported as logos")
+)
+
+// objectOpener is the subset of the S3 repository needed to read an uploaded asset
+type objectOpener interface {
+	OpenObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// ownedAssetKey resolves asset to an S3 key under the user's prefix. It reports false for
+// anyone else's assets, so only the user's own uploads end up in their video.
+func ownedAssetKey(locator *repository.AssetLocator, userID, asset string) (string, bool) {
+	key := s3util.Key(asset)
+	if !locator.OwnsAsset(userID, key) {
+		return "", false
+	}
+	return key, true
+}
+
+// inspectLogoImage checks that r holds a still image ffmpeg can overlay
+func inspectLogoImage(r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, composition.MaxLogoBytes+1))
+	if err != nil {
+		return fmt.Errorf("failed to read logo: %w", err)
+	}
+	if len(data) > composition.MaxLogoBytes {
+		return fmt.Errorf("logo exceeds %d MB", composition.MaxLogoBytes/(1024*1024))
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return errLogoFormat
+	}
+	if format == "gif" {
+		frames, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return errLogoFormat
+		}
+		if len(frames.Image) > 1 {
+			return errLogoAnimated
+		}
+	}
+	return nil
+}
+
+// checkLogoOverlay resolves the logo asset to one of the user's S3 keys and checks it is a
+// still image. It returns a copy of logo with Asset set to that key.
+func checkLogoOverlay(
+	ctx context.Context,
+	storage objectOpener,
+	bucket string,
+	locator *repository.AssetLocator,
+	userID string,
+	logo *domain.LogoOverlay,
+	guidelines *domain.BrandGuidelines,
+) (*domain.LogoOverlay, validation.Errors) {
+	var errs validation.Errors
+	resolved := *logo
+
+	field := "logo_overlay.asset"
+	asset := logo.Asset
+	if logo.UseGuidelineLogo {
+		field = "logo_overlay.use_guideline_logo"
+		if guidelines == nil || len(guidelines.LogoURLs) == 0 || strings.TrimSpace(guidelines.LogoURLs[0]) == "" {
+			errs.Add(field, "The brand guidelines have no logo")
+			return nil, errs
+		}
+		asset = guidelines.LogoURLs[0]
+	}
+
+	key, ok := ownedAssetKey(locator, userID, asset)
+	if !ok {
+		errs.Add(field, "Logo must be an asset you uploaded")
+		return nil, errs
+	}
+	resolved.Asset = key
+
+	body, err := storage.OpenObject(ctx, bucket, key)
+	if err != nil {
+		errs.Add(field, "Logo asset not found")
+		return nil, errs
+	}
+	defer body.Close()
+
+	if err := inspectLogoImage(body); err != nil {
+		message := "Logo must be a PNG, JPEG or GIF image"
+		switch {
+		case stderrors.Is(err, errLogoAnimated):
+			message = "Animated GIFs are not supported as logos"
+		case !stderrors.Is(err, errLogoFormat):
+			message = "Logo could not be read: " + err.Error()
+		}
+		errs.Add(field, message)
+		return nil, errs
+	}
+	return &resolved, nil
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectStore serves objects from memory by key
+type fakeObjectStore map[string][]byte
+
+func (s fakeObjectStore) OpenObject(_ context.Context, _, key string) (io.ReadCloser, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, stderrors.New("NoSuchKey")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func encodeTestLogo(t *testing.T, format string, frames int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		img.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 128})
+		require.NoError(t, png.Encode(&buf, img))
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil))
+	case "gif":
+		anim := &gif.GIF{}
+		for i := 0; i < frames; i++ {
+			anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9))
+			anim.Delay = append(anim.Delay, 10)
+		}
+		require.NoError(t, gif.EncodeAll(&buf, anim))
+	}
+	return buf.Bytes()
+}
+
+func TestInspectLogoImage(t *testing.T) {
+	require.NoError(t, inspectLogoImage(bytes.NewReader(encodeTestLogo(t, "png", 1))))
+	require.NoError(t, inspectLogoImage(bytes.NewReader(encodeTestLogo(t, "jpeg", 1))))
+	require.NoError(t, inspectLogoImage(bytes.NewReader(encodeTestLogo(t, "gif", 1))), "a single-frame GIF is a still image")
+
+	require.ErrorIs(t, inspectLogoImage(bytes.NewReader(encodeTestLogo(t, "gif", 3))), errLogoAnimated)
+	require.ErrorIs(t, inspectLogoImage(bytes.NewReader([]byte("<svg></svg>"))), errLogoFormat)
+}
+
+func TestCheckLogoOverlay(t *testing.T) {
+	store := fakeObjectStore{
+		"users/u1/uploads/logo.png":  encodeTestLogo(t, "png", 1),
+		"users/u1/uploads/spin.gif":  encodeTestLogo(t, "gif", 2),
+		"users/u2/uploads/logo.png":  encodeTestLogo(t, "png", 1),
+		"users/u1/brand/primary.png": encodeTestLogo(t, "png", 1),
+	}
+	guidelines := &domain.BrandGuidelines{LogoURLs: []string{"https://assets.s3.amazonaws.com/users/u1/brand/primary.png?X-Amz-Signature=abc"}}
+
+	tests := []struct {
+		name       string
+		logo       domain.LogoOverlay
+		guidelines *domain.BrandGuidelines
+		asset      string
+		field      string
+		message    string
+	}{
+		{name: "uploaded key", logo: domain.LogoOverlay{Asset: "/users/u1/uploads/logo.png", Size: 20}, asset: "users/u1/uploads/logo.png"},
+		{name: "guideline logo", logo: domain.LogoOverlay{UseGuidelineLogo: true}, guidelines: guidelines, asset: "users/u1/brand/primary.png"},
+		{
+			name: "another user's asset", logo: domain.LogoOverlay{Asset: "users/u2/uploads/logo.png"},
+			field: "logo_overlay.asset", message: "Logo must be an asset you uploaded",
+		},
+		{
+			name: "path traversal", logo: domain.LogoOverlay{Asset: "users/u1/../u2/uploads/logo.png"},
+			field: "logo_overlay.asset", message: "Logo must be an asset you uploaded",
+		},
+		{
+			name: "missing asset", logo: domain.LogoOverlay{Asset: "users/u1/uploads/missing.png"},
+			field: "logo_overlay.asset", message: "Logo asset not found",
+		},
+		{
+			name: "animated gif", logo: domain.LogoOverlay{Asset: "users/u1/uploads/spin.gif"},
+			field: "logo_overlay.asset", message: "Animated GIFs are not supported as logos",
+		},
+		{
+			name: "guidelines without a logo", logo: domain.LogoOverlay{UseGuidelineLogo: true}, guidelines: &domain.BrandGuidelines{},
+			field: "logo_overlay.use_guideline_logo", message: "The brand guidelines have no logo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logo := tt.logo
+			resolved, errs := checkLogoOverlay(context.Background(), store, "assets", nil, "u1", &logo, tt.guidelines)
+			if tt.message == "" {
+				require.Empty(t, errs)
+				require.Equal(t, tt.asset, resolved.Asset)
+				require.Equal(t, tt.logo.Size, resolved.Size)
+				require.Equal(t, tt.logo.Asset, logo.Asset, "the request's logo must not be modified")
+				return
+			}
+			require.Nil(t, resolved)
+			require.Len(t, errs, 1)
+			require.Equal(t, tt.field, errs[0].Field)
+			require.Equal(t, tt.message, errs[0].Message)
+		})
+	}
+}
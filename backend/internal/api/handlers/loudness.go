@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+// Loudness targets (EBU R128 integrated loudness) used when AudioConfig leaves them unset
+const (
+	DefaultMusicLUFS     = -23.0
+	DefaultNarrationLUFS = -16.0
+
+	loudnessTruePeak = -1.5 // dBTP ceiling
+	loudnessRange    = 11.0 // LU
+)
+
+// AudioConfig holds tunable audio post-processing settings; zero values use the defaults
+type AudioConfig struct {
+	SFXMaxDuration float64 // Sound effects are trimmed to this length (seconds)
+	MusicLUFS      float64 // Integrated loudness target for background music
+	NarrationLUFS  float64 // Integrated loudness target for narration and scene voiceovers
+	MusicBeatSync  bool    // Shift music so a beat lands on the script's first beat sync point
+
+	// Narrator words per second by language, for narration budgets and length checks; nil uses
+	// domain.DefaultNarrationPacing
+	NarrationPacing domain.NarrationPacing
+}
+
+func (c AudioConfig) musicTarget() float64 {
+	if c.MusicLUFS == 0 {
+		return DefaultMusicLUFS
+	}
+	return c.MusicLUFS
+}
+
+func (c AudioConfig) narrationTarget() float64 {
+	if c.NarrationLUFS == 0 {
+		return DefaultNarrationLUFS
+	}
+	return c.NarrationLUFS
+}
+
+// loudnormStats are the measurements ffmpeg's loudnorm filter prints with print_format=json.
+// ffmpeg reports every value as a string.
+type loudnormStats struct {
+	InputI            string `json:"input_i"`
+	InputTP           string `json:"input_tp"`
+	InputLRA          string `json:"input_lra"`
+	InputThresh       string `json:"input_thresh"`
+	OutputI           string `json:"output_i"`
+	OutputTP          string `json:"output_tp"`
+	OutputLRA         string `json:"output_lra"`
+	OutputThresh      string `json:"output_thresh"`
+	NormalizationType string `json:"normalization_type"`
+	TargetOffset      string `json:"target_offset"`
+}
+
+// parseLoudnormStats extracts the loudnorm JSON block from ffmpeg's stderr.
+// The block follows a "[Parsed_loudnorm_N @ 0x...]" line and is the last JSON object printed.
+func parseLoudnormStats(output string) (loudnormStats, error) {
+	var stats loudnormStats
+
+	marker := strings.LastIndex(output, "[Parsed_loudnorm")
+	if marker == -1 {
+		return stats, fmt.Errorf("loudnorm stats not found in ffmpeg output")
+	}
+	start := strings.Index(output[marker:], "{")
+	end := strings.LastIndex(output, "}")
+	if start == -1 || end == -1 || marker+start > end {
+		return stats, fmt.Errorf("loudnorm stats not found in ffmpeg output")
+	}
+
+	if err := json.Unmarshal([]byte(output[marker+start:end+1]), &stats); err != nil {
+		return stats, fmt.Errorf("failed to parse loudnorm stats: %w", err)
+	}
+
+	// Silent input measures as -inf and cannot be normalized
+	if v, err := strconv.ParseFloat(stats.InputI, 64); err != nil || math.IsInf(v, 0) {
+		return stats, fmt.Errorf("unmeasurable input loudness %q", stats.InputI)
+	}
+	return stats, nil
+}
+
+// loudnormFilter builds the loudnorm filter; with measured stats it runs the linear second pass
+func loudnormFilter(target float64, measured *loudnormStats) string {
+	filter := fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g", target, loudnessTruePeak, loudnessRange)
+	if measured != nil {
+		filter += fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+			measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset)
+	}
+	return filter + ":print_format=json"
+}
+
+// normalizeLoudness runs the two-pass loudnorm flow from inputPath into outputPath
+func normalizeLoudness(ctx context.Context, inputPath, outputPath string, target float64) (*domain.LoudnessMeasurement, error) {
+	// Pass 1: measure only
+	output, err := ffmpegexec.CombinedOutput(ctx, "loudness_measure", exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-i", inputPath,
+		"-af", loudnormFilter(target, nil),
+		"-f", "null", "-",
+	))
+	if err != nil {
+		return nil, fmt.Errorf("loudnorm measurement failed: %w (%s)", err, strings.TrimSpace(string(output)))
+	}
+	measured, err := parseLoudnormStats(string(output))
+	if err != nil {
+		return nil, err
+	}
+
+	// Pass 2: apply a linear gain using the measurements; loudnorm resamples internally
+	output, err = ffmpegexec.CombinedOutput(ctx, "loudness_normalize", exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-i", inputPath,
+		"-af", loudnormFilter(target, &measured),
+		"-ar", "44100",
+		"-b:a", "192k",
+		"-y", outputPath,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("loudnorm normalization failed: %w (%s)", err, strings.TrimSpace(string(output)))
+	}
+	normalized, err := parseLoudnormStats(string(output))
+	if err != nil {
+		return nil, err
+	}
+
+	return loudnessMeasurement(measured, normalized, target), nil
+}
+
+// loudnessMeasurement summarizes both passes for the job record
+func loudnessMeasurement(measured, normalized loudnormStats, target float64) *domain.LoudnessMeasurement {
+	parse := func(s string) float64 {
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	return &domain.LoudnessMeasurement{
+		TargetLUFS:     target,
+		InputLUFS:      parse(measured.InputI),
+		InputTruePeak:  parse(measured.InputTP),
+		OutputLUFS:     parse(normalized.OutputI),
+		OutputTruePeak: parse(normalized.OutputTP),
+	}
+}
+
+// normalizeAudioFile normalizes path to target and returns the file to upload.
+// Normalization is best effort: on failure the original file is returned with no measurement.
+func (h *GenerateHandler) normalizeAudioFile(ctx context.Context, jobID, label, path string, target float64) (string, *domain.LoudnessMeasurement) {
+	normalizedPath := strings.TrimSuffix(path, ".mp3") + "-normalized.mp3"
+	measurement, err := normalizeLoudness(ctx, path, normalizedPath, target)
+	if err != nil {
+		h.log(ctx).Warn("Loudness normalization failed, using audio as generated",
+			zap.String("track", label),
+			zap.Error(err),
+		)
+		return path, nil
+	}
+
+	h.log(ctx).Info("Audio loudness normalized",
+		zap.String("track", label),
+		zap.Float64("input_lufs", measurement.InputLUFS),
+		zap.Float64("output_lufs", measurement.OutputLUFS),
+		zap.Float64("target_lufs", target),
+	)
+	return normalizedPath, measurement
+}
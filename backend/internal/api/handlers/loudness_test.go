@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// Captured stderr from: ffmpeg -hide_banner -i music.mp3 -af loudnorm=I=-23:TP=-1.5:LRA=11:print_format=json -f null -
+const loudnormFirstPassOutput = `Input #0, mp3, from 'music.mp3':
+  Duration: 00:00:30.04, start: 0.025057, bitrate: 256 kb/s
+  Stream #0:0: Audio: mp3, 44100 Hz, stereo, fltp, 256 kb/s
+Stream mapping:
+  Stream #0:0 -> #0:0 (mp3 (mp3float) -> pcm_s16le (native))
+Press [q] to stop, [?] for help
+Output #0, null, to 'pipe:':
+  Metadata:
+    encoder         : Lavf60.16.100
+  Stream #0:0: Audio: pcm_s16le, 192000 Hz, stereo, s16, 6144 kb/s
+size=N/A time=00:00:30.01 bitrate=N/A speed= 112x
+video:0kB audio:5625kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: unknown
+[Parsed_loudnorm_0 @ 0x600002d1c000]
+{
+	"input_i" : "-11.42",
+	"input_tp" : "0.35",
+	"input_lra" : "4.10",
+	"input_thresh" : "-21.55",
+	"output_i" : "-22.71",
+	"output_tp" : "-9.94",
+	"output_lra" : "3.60",
+	"output_thresh" : "-32.80",
+	"normalization_type" : "dynamic",
+	"target_offset" : "-0.29"
+}
+`
+
+// Captured stderr from the linear second pass
+const loudnormSecondPassOutput = `Input #0, mp3, from 'music.mp3':
+  Duration: 00:00:30.04, start: 0.025057, bitrate: 256 kb/s
+size=     705kB time=00:00:30.01 bitrate= 192.4kbits/s speed=47.3x
+video:0kB audio:705kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: 0.045232%
+[Parsed_loudnorm_0 @ 0x6000011b4000]
+{
+	"input_i" : "-11.42",
+	"input_tp" : "0.35",
+	"input_lra" : "4.10",
+	"input_thresh" : "-21.55",
+	"output_i" : "-23.02",
+	"output_tp" : "-11.23",
+	"output_lra" : "4.00",
+	"output_thresh" : "-33.13",
+	"normalization_type" : "linear",
+	"target_offset" : "0.02"
+}
+`
+
+// Captured stderr for a silent input
+const loudnormSilentOutput = `[Parsed_loudnorm_0 @ 0x6000011b4000]
+{
+	"input_i" : "-inf",
+	"input_tp" : "-inf",
+	"input_lra" : "0.00",
+	"input_thresh" : "-70.00",
+	"output_i" : "-inf",
+	"output_tp" : "-inf",
+	"output_lra" : "0.00",
+	"output_thresh" : "-70.00",
+	"normalization_type" : "dynamic",
+	"target_offset" : "inf"
+}
+`
+
+func TestParseLoudnormStats(t *testing.T) {
+	stats, err := parseLoudnormStats(loudnormFirstPassOutput)
+	require.NoError(t, err)
+	require.Equal(t, loudnormStats{
+		InputI:            "-11.42",
+		InputTP:           "0.35",
+		InputLRA:          "4.10",
+		InputThresh:       "-21.55",
+		OutputI:           "-22.71",
+		OutputTP:          "-9.94",
+		OutputLRA:         "3.60",
+		OutputThresh:      "-32.80",
+		NormalizationType: "dynamic",
+		TargetOffset:      "-0.29",
+	}, stats)
+
+	stats, err = parseLoudnormStats(loudnormSecondPassOutput)
+	require.NoError(t, err)
+	require.Equal(t, "linear", stats.NormalizationType)
+	require.Equal(t, "-23.02", stats.OutputI)
+}
+
+func TestParseLoudnormStats_Errors(t *testing.T) {
+	_, err := parseLoudnormStats(loudnormSilentOutput)
+	require.ErrorContains(t, err, "unmeasurable input loudness")
+
+	_, err = parseLoudnormStats("size=N/A time=00:00:30.01 bitrate=N/A")
+	require.ErrorContains(t, err, "not found")
+
+	_, err = parseLoudnormStats("[Parsed_loudnorm_0 @ 0x1] \n{\n\t\"input_i\" : ")
+	require.Error(t, err)
+}
+
+func TestLoudnormFilter(t *testing.T) {
+	require.Equal(t, "loudnorm=I=-16:TP=-1.5:LRA=11:print_format=json", loudnormFilter(-16, nil))
+
+	measured, err := parseLoudnormStats(loudnormFirstPassOutput)
+	require.NoError(t, err)
+	require.Equal(t,
+		"loudnorm=I=-23:TP=-1.5:LRA=11"+
+			":measured_I=-11.42:measured_TP=0.35:measured_LRA=4.10:measured_thresh=-21.55:offset=-0.29:linear=true"+
+			":print_format=json",
+		loudnormFilter(-23, &measured))
+}
+
+func TestLoudnessMeasurement(t *testing.T) {
+	measured, err := parseLoudnormStats(loudnormFirstPassOutput)
+	require.NoError(t, err)
+	normalized, err := parseLoudnormStats(loudnormSecondPassOutput)
+	require.NoError(t, err)
+
+	require.Equal(t, &domain.LoudnessMeasurement{
+		TargetLUFS:     -23,
+		InputLUFS:      -11.42,
+		InputTruePeak:  0.35,
+		OutputLUFS:     -23.02,
+		OutputTruePeak: -11.23,
+	}, loudnessMeasurement(measured, normalized, -23))
+}
+
+func TestAudioConfigTargets(t *testing.T) {
+	require.Equal(t, DefaultMusicLUFS, AudioConfig{}.musicTarget())
+	require.Equal(t, DefaultNarrationLUFS, AudioConfig{}.narrationTarget())
+	require.Equal(t, -14.0, AudioConfig{NarrationLUFS: -14}.narrationTarget())
+}
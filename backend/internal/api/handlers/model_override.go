@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// modelOverridesFor reads the request's X-Model-Override header, used to try a new model version
+// on a single job. Unless the server enables overrides the header is ignored, so clients can't
+// choose models in production.
+func (h *GenerateHandler) modelOverridesFor(c *gin.Context, userID string) (map[string]string, *errors.APIError) {
+	header := c.GetHeader(adapters.ModelOverrideHeader)
+	if header == "" {
+		return nil, nil
+	}
+	if !h.modelOverrides {
+		h.logger.Warn("Ignoring X-Model-Override: model overrides are disabled",
+			zap.String("user_id", userID),
+		)
+		return nil, nil
+	}
+
+	overrides, err := adapters.ParseModelOverrides(header)
+	if err != nil {
+		return nil, errors.NewAPIError(errors.ErrInvalidRequest,
+			fmt.Sprintf("Invalid X-Model-Override: %v", err), nil)
+	}
+	h.logger.Info("Job models overridden",
+		zap.String("user_id", userID),
+		zap.Any("model_overrides", overrides),
+	)
+	return overrides, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func modelOverrideContext(header string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/generate", nil)
+	if header != "" {
+		c.Request.Header.Set(adapters.ModelOverrideHeader, header)
+	}
+	return c
+}
+
+func TestModelOverridesFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := &GenerateHandler{logger: zap.NewNop(), modelOverrides: true}
+	disabled := &GenerateHandler{logger: zap.NewNop()}
+
+	overrides, apiErr := enabled.modelOverridesFor(modelOverrideContext("veo=google/veo-3, minimax=minimax/music-01"), "user-1")
+	require.Nil(t, apiErr)
+	require.Equal(t, map[string]string{"veo": "google/veo-3", "minimax": "minimax/music-01"}, overrides)
+
+	_, apiErr = enabled.modelOverridesFor(modelOverrideContext("veo=minimax/music-01"), "user-1")
+	require.NotNil(t, apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.Status)
+	require.Contains(t, apiErr.Message, "google/veo-")
+
+	// Without the flag the header is ignored, valid or not
+	overrides, apiErr = disabled.modelOverridesFor(modelOverrideContext("veo=google/veo-3"), "user-1")
+	require.Nil(t, apiErr)
+	require.Nil(t, overrides)
+	overrides, apiErr = disabled.modelOverridesFor(modelOverrideContext("veo=minimax/music-01"), "user-1")
+	require.Nil(t, apiErr)
+	require.Nil(t, overrides)
+
+	overrides, apiErr = enabled.modelOverridesFor(modelOverrideContext(""), "user-1")
+	require.Nil(t, apiErr)
+	require.Nil(t, overrides)
+}
+
+func TestGenerateRejectsInvalidModelOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := &GenerateHandler{logger: zap.NewNop(), modelOverrides: true}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/generate",
+		strings.NewReader(`{"prompt":"A sunrise over a mountain lake","duration":20,"aspect_ratio":"16:9"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set(adapters.ModelOverrideHeader, "veo=google/veo-3.1:latest")
+	c.Set(auth.UserIDKey, "user-1")
+	handler.Generate(c)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "X-Model-Override")
+}
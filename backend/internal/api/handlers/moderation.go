@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/moderation"
+	"go.uber.org/zap"
+)
+
+const moderationFailureMessage = "Your prompt was flagged by content moderation. Please revise it and try again."
+
+// moderationFailure is the user message for a job failed on flag, naming the prompt and why
+func moderationFailure(flag domain.ModerationFlag) string {
+	where := "Your prompt"
+	if flag.SceneNumber > 0 {
+		where = fmt.Sprintf("The prompt for scene %d", flag.SceneNumber)
+	}
+	return fmt.Sprintf("%s was flagged by content moderation (%s) and would be rejected by the video provider. Please revise it and try again.", where, flag.Category)
+}
+
+// moderatePrompt screens the user's prompt before the script is written.
+// Returns false if the job was failed.
+func (h *GenerateHandler) moderatePrompt(ctx context.Context, job *domain.Job) bool {
+	if h.moderation == nil {
+		return true
+	}
+	pharmaceutical := compliance.IsPharmaceutical(job.Voice, job.SideEffects)
+	return h.recordModeration(ctx, job, h.moderation.CheckPrompt(ctx, job.Prompt, pharmaceutical))
+}
+
+// moderateScenes screens the scene prompts before any clip is submitted, including prompts the
+// user edited while the job waited for approval. Returns false if the job was failed.
+func (h *GenerateHandler) moderateScenes(ctx context.Context, job *domain.Job, scenes []domain.Scene) bool {
+	if h.moderation == nil {
+		return true
+	}
+	pharmaceutical := compliance.IsPharmaceutical(job.Voice, job.SideEffects)
+	return h.recordModeration(ctx, job, h.moderation.CheckScenes(ctx, scenes, pharmaceutical))
+}
+
+// recordModeration fails the job on a flagged prompt, keeping the flag on it. A moderation
+// outage lets the job through, since the providers still apply their own filters.
+func (h *GenerateHandler) recordModeration(ctx context.Context, job *domain.Job, err error) bool {
+	if err == nil {
+		return true
+	}
+	var flagged *moderation.FlaggedError
+	if !errors.As(err, &flagged) {
+		h.log(ctx).Warn("Content moderation unavailable, continuing without it", zap.Error(err))
+		return true
+	}
+
+	job.ModerationFlags = append(job.ModerationFlags, flagged.Flag)
+	h.log(ctx).Warn("Prompt flagged by content moderation",
+		zap.Int("scene_number", flagged.Flag.SceneNumber),
+		zap.String("category", flagged.Flag.Category),
+		zap.String("source", flagged.Flag.Source),
+	)
+	if err := h.saveJobProgress(ctx, job); err != nil {
+		h.log(ctx).Error("Failed to save moderation flags", zap.Error(err))
+	}
+	h.failJob(ctx, job, "moderation", moderationFailureMessage, err)
+	return false
+}
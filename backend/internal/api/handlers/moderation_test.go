@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/moderation"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeModerationClient flags inputs containing its word
+type fakeModerationClient struct {
+	word     string
+	category string
+	err      error
+}
+
+func (f fakeModerationClient) Moderate(ctx context.Context, inputs []string) ([]adapters.ModerationResult, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	results := make([]adapters.ModerationResult, len(inputs))
+	for i, input := range inputs {
+		if f.word != "" && strings.Contains(input, f.word) {
+			results[i] = adapters.ModerationResult{Flagged: true, Categories: []string{f.category}}
+		}
+	}
+	return results, nil
+}
+
+func moderatedHandler(t *testing.T, client moderation.Client) (*GenerateHandler, *offPlanScriptGenerator, *domain.Job, func() *domain.Job) {
+	h, repo, job := complianceHandler(t, false)
+	checker, err := moderation.NewChecker(client, moderation.Config{})
+	require.NoError(t, err)
+	h.moderation = checker
+	generator := &offPlanScriptGenerator{}
+	h.parserService = service.NewParserService(generator, zap.NewNop())
+
+	stored := func() *domain.Job {
+		stored, err := repo.GetJob(context.Background(), job.JobID)
+		require.NoError(t, err)
+		return stored
+	}
+	return h, generator, job, stored
+}
+
+func TestModeration_CleanPromptsContinue(t *testing.T) {
+	h, _, job, stored := moderatedHandler(t, fakeModerationClient{word: "gore", category: "violence/graphic"})
+
+	require.True(t, h.moderatePrompt(context.Background(), job))
+	require.True(t, h.moderateScenes(context.Background(), job, []domain.Scene{
+		{SceneNumber: 1, GenerationPrompt: "A woman wakes up rested in a sunlit bedroom"},
+		{SceneNumber: 2, GenerationPrompt: "Close-up of the Restura box on a marble counter"},
+	}))
+	require.Equal(t, domain.StatusProcessing, stored().Status)
+	require.Empty(t, job.ModerationFlags)
+}
+
+func TestModeration_FlaggedPromptFailsBeforeScript(t *testing.T) {
+	h, generator, job, stored := moderatedHandler(t, fakeModerationClient{word: "Restura", category: "self-harm"})
+
+	h.generateVideoAsync(context.Background(), job, complianceRequest(job), nil)
+	require.Nil(t, generator.req, "no script is written for a flagged prompt")
+
+	failed := stored()
+	require.Equal(t, domain.StatusFailed, failed.Status)
+	require.Equal(t, "moderation", failed.FailureStage)
+	require.NotNil(t, failed.ErrorMessage)
+	require.Equal(t, "Your prompt was flagged by content moderation (self-harm) and would be rejected by the video provider. Please revise it and try again.", *failed.ErrorMessage)
+	require.Equal(t, []domain.ModerationFlag{{Category: "self-harm", Source: moderation.SourceOpenAI}}, failed.ModerationFlags)
+}
+
+func TestModeration_FlaggedSceneFailsBeforeClips(t *testing.T) {
+	h, _, job, stored := moderatedHandler(t, fakeModerationClient{})
+	script := &domain.Script{Scenes: []domain.Scene{
+		{SceneNumber: 1, GenerationPrompt: "A woman wakes up rested in a sunlit bedroom"},
+		{SceneNumber: 2, GenerationPrompt: "A Xanax bottle on the nightstand, soft light"},
+	}}
+
+	// The adapter factory is nil, so reaching clip generation would panic
+	h.generateFromScript(context.Background(), job, script)
+
+	failed := stored()
+	require.Equal(t, domain.StatusFailed, failed.Status)
+	require.NotNil(t, failed.ErrorMessage)
+	require.Contains(t, *failed.ErrorMessage, "The prompt for scene 2 was flagged by content moderation (medication_brand)")
+	require.Equal(t, []domain.ModerationFlag{{SceneNumber: 2, Category: moderation.CategoryMedicationBrand, Source: moderation.SourceDenylist, Term: "xanax"}}, failed.ModerationFlags)
+	require.Contains(t, failed.FailureError, "scene 2 flagged by denylist moderation")
+}
+
+func TestModeration_PharmaJobsMayUseMedicalTerms(t *testing.T) {
+	h, _, job, _ := moderatedHandler(t, fakeModerationClient{word: "suicidal", category: "self-harm"})
+	scenes := []domain.Scene{{SceneNumber: 1, GenerationPrompt: "Doctor discussing the risk of suicidal thoughts with a patient"}}
+
+	require.True(t, h.moderateScenes(context.Background(), job, scenes), "pharmaceutical jobs get the medical allowlist")
+
+	job.Voice = ""
+	require.False(t, h.moderateScenes(context.Background(), job, scenes))
+}
+
+func TestModeration_UnavailableLetsJobsThrough(t *testing.T) {
+	h, _, job, stored := moderatedHandler(t, fakeModerationClient{err: errors.New("connection refused")})
+
+	require.True(t, h.moderatePrompt(context.Background(), job))
+	require.Equal(t, domain.StatusProcessing, stored().Status)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+)
+
+// Music fit modes recorded on the job
+const (
+	musicFitNone = "none" // within MusicDurationTolerance, used as generated
+	musicFitLoop = "loop" // shorter than the video, looped with crossfaded seams then trimmed
+	musicFitTrim = "trim" // longer than the video, trimmed
+)
+
+// musicFitPlan describes how a generated music track is reconciled with the video length
+type musicFitPlan struct {
+	Mode         string
+	Copies       int     // copies of the track joined end to end (1 unless looping)
+	Crossfade    float64 // seam crossfade in seconds
+	Target       float64 // final duration in seconds
+	FadeStart    float64 // fade-out start in seconds
+	FadeDuration float64 // fade-out length in seconds
+}
+
+// musicTrack is the background music after fitting, with the raw upload kept for debugging
+type musicTrack struct {
+	URL         string
+	RawURL      string
+	RawDuration float64
+	Plan        musicFitPlan
+	Loudness    *domain.LoudnessMeasurement
+	Provider    adapters.MusicProvider // Model that generated the track
+	SyncOffset  float64                // Seconds the track was shifted to land a beat on a sync point
+}
+
+// planMusicFit computes the loop/trim parameters for a track of rawDuration seconds.
+// Short tracks are repeated with MusicLoopCrossfade seams until they cover the target;
+// both loops and long tracks are then trimmed with a MusicFadeOut ending exactly at target.
+func planMusicFit(rawDuration, targetDuration float64) musicFitPlan {
+	if rawDuration <= 0 || targetDuration <= 0 || math.Abs(rawDuration-targetDuration) <= MusicDurationTolerance {
+		return musicFitPlan{Mode: musicFitNone, Copies: 1}
+	}
+
+	fade := math.Min(MusicFadeOut, targetDuration/2)
+	plan := musicFitPlan{
+		Mode:         musicFitTrim,
+		Copies:       1,
+		Target:       targetDuration,
+		FadeStart:    targetDuration - fade,
+		FadeDuration: fade,
+	}
+
+	if rawDuration < targetDuration {
+		// Keep the seam short relative to the track so very short loops stay recognizable
+		crossfade := math.Min(MusicLoopCrossfade, rawDuration/4)
+		plan.Mode = musicFitLoop
+		plan.Crossfade = crossfade
+		// N copies with N-1 crossfades last N*raw - (N-1)*crossfade seconds
+		plan.Copies = int(math.Ceil((targetDuration - crossfade) / (rawDuration - crossfade)))
+	}
+	return plan
+}
+
+// musicFitFilter builds the ffmpeg filter graph for a plan; the output pad is [out]
+func musicFitFilter(plan musicFitPlan) string {
+	var b strings.Builder
+	last := "[0:a]"
+
+	if plan.Copies > 1 {
+		fmt.Fprintf(&b, "[0:a]asplit=%d", plan.Copies)
+		for i := 0; i < plan.Copies; i++ {
+			fmt.Fprintf(&b, "[s%d]", i)
+		}
+		b.WriteString(";")
+
+		last = "[s0]"
+		for i := 1; i < plan.Copies; i++ {
+			fmt.Fprintf(&b, "%s[s%d]acrossfade=d=%s:c1=tri:c2=tri[x%d];", last, i, formatSeconds(plan.Crossfade), i)
+			last = fmt.Sprintf("[x%d]", i)
+		}
+	}
+
+	fmt.Fprintf(&b, "%satrim=end=%s,asetpts=PTS-STARTPTS,afade=t=out:st=%s:d=%s[out]",
+		last,
+		formatSeconds(plan.Target),
+		formatSeconds(plan.FadeStart),
+		formatSeconds(plan.FadeDuration),
+	)
+	return b.String()
+}
+
+// formatSeconds renders seconds with millisecond precision for ffmpeg arguments
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(math.Round(s*1000)/1000, 'f', -1, 64)
+}
+
+// applyMusicFit renders the plan from inputPath into outputPath
+func applyMusicFit(ctx context.Context, inputPath, outputPath string, plan musicFitPlan) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", inputPath,
+		"-filter_complex", musicFitFilter(plan),
+		"-map", "[out]",
+		"-y", outputPath,
+	)
+	if output, err := ffmpegexec.CombinedOutput(ctx, "music_fit", cmd); err != nil {
+		return fmt.Errorf("failed to %s music: %w (%s)", plan.Mode, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// recordMusicFit stores the music adjustment on the job for debugging
+func recordMusicFit(job *domain.Job, track *musicTrack) {
+	if track == nil {
+		return
+	}
+	job.MusicProvider = string(track.Provider)
+	job.MusicRawURL = track.RawURL
+	job.MusicRawDuration = roundSeconds(track.RawDuration)
+	job.MusicFit = track.Plan.Mode
+	job.MusicSyncOffset = track.SyncOffset
+	job.MusicLoudness = track.Loudness
+	if track.Plan.Mode == musicFitLoop {
+		job.MusicLoopCount = track.Plan.Copies
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanMusicFit(t *testing.T) {
+	t.Run("within tolerance", func(t *testing.T) {
+		plan := planMusicFit(30.05, 30)
+		require.Equal(t, musicFitNone, plan.Mode)
+		require.Equal(t, 1, plan.Copies)
+	})
+
+	t.Run("unknown duration", func(t *testing.T) {
+		require.Equal(t, musicFitNone, planMusicFit(0, 30).Mode)
+		require.Equal(t, musicFitNone, planMusicFit(20, 0).Mode)
+	})
+
+	t.Run("longer track is trimmed with fade-out", func(t *testing.T) {
+		plan := planMusicFit(47.3, 30)
+		require.Equal(t, musicFitPlan{
+			Mode:         musicFitTrim,
+			Copies:       1,
+			Target:       30,
+			FadeStart:    28.5,
+			FadeDuration: 1.5,
+		}, plan)
+	})
+
+	t.Run("shorter track is looped", func(t *testing.T) {
+		plan := planMusicFit(10, 30)
+		require.Equal(t, musicFitLoop, plan.Mode)
+		require.Equal(t, 1.0, plan.Crossfade)
+		// 3 copies give 28s after two 1s seams, so a fourth is needed
+		require.Equal(t, 4, plan.Copies)
+		require.Equal(t, 28.5, plan.FadeStart)
+		require.GreaterOrEqual(t, float64(plan.Copies)*10-float64(plan.Copies-1)*plan.Crossfade, 30.0)
+	})
+
+	t.Run("exact loop coverage", func(t *testing.T) {
+		// 3 copies of 11s with two 1s seams are exactly 31s
+		require.Equal(t, 3, planMusicFit(11, 31).Copies)
+	})
+
+	t.Run("very short track uses shorter seams", func(t *testing.T) {
+		plan := planMusicFit(2, 8)
+		require.Equal(t, 0.5, plan.Crossfade)
+		require.Equal(t, 5, plan.Copies)
+	})
+
+	t.Run("short video caps the fade-out", func(t *testing.T) {
+		plan := planMusicFit(10, 2)
+		require.Equal(t, 1.0, plan.FadeDuration)
+		require.Equal(t, 1.0, plan.FadeStart)
+	})
+}
+
+func TestMusicFitFilter(t *testing.T) {
+	t.Run("trim", func(t *testing.T) {
+		filter := musicFitFilter(planMusicFit(47.3, 30))
+		require.Equal(t, "[0:a]atrim=end=30,asetpts=PTS-STARTPTS,afade=t=out:st=28.5:d=1.5[out]", filter)
+	})
+
+	t.Run("loop", func(t *testing.T) {
+		filter := musicFitFilter(planMusicFit(10, 24.2))
+		require.Equal(t,
+			"[0:a]asplit=3[s0][s1][s2];"+
+				"[s0][s1]acrossfade=d=1:c1=tri:c2=tri[x1];"+
+				"[x1][s2]acrossfade=d=1:c1=tri:c2=tri[x2];"+
+				"[x2]atrim=end=24.2,asetpts=PTS-STARTPTS,afade=t=out:st=22.7:d=1.5[out]",
+			filter)
+	})
+}
+
+func TestRecordMusicFit(t *testing.T) {
+	job := &domain.Job{}
+	recordMusicFit(job, &musicTrack{
+		URL:         "s3://bucket/background-music.mp3",
+		RawURL:      "s3://bucket/background-music-raw.mp3",
+		RawDuration: 10.0417,
+		Plan:        planMusicFit(10.0417, 30),
+		Provider:    adapters.MusicProviderMusicGen,
+		SyncOffset:  -0.75,
+	})
+
+	require.Equal(t, "s3://bucket/background-music-raw.mp3", job.MusicRawURL)
+	require.Equal(t, 10.04, job.MusicRawDuration)
+	require.Equal(t, musicFitLoop, job.MusicFit)
+	require.Equal(t, 4, job.MusicLoopCount)
+	require.Equal(t, "musicgen", job.MusicProvider)
+	require.Equal(t, -0.75, job.MusicSyncOffset)
+
+	recordMusicFit(job, nil)
+	require.Equal(t, musicFitLoop, job.MusicFit, "nil track leaves the job untouched")
+}
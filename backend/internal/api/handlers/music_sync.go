@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/omnigen/backend/internal/beatsync"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxMusicSyncShift is the furthest the music is moved to land a beat on a sync point.
+	// Further than that and the trimmed intro or the delayed start would be noticeable.
+	MaxMusicSyncShift = 3.0
+
+	// beatSyncSampleRate is the rate music is decoded at for beat detection; 20ms frames
+	// need nothing finer
+	beatSyncSampleRate = 22050
+)
+
+// firstBeatSyncPoint returns the time of the script's earliest "beat" sync point inside the video
+func firstBeatSyncPoint(points []domain.SyncPoint, videoDuration float64) (float64, bool) {
+	first, found := 0.0, false
+	for _, p := range points {
+		if p.Type != "beat" || p.Timestamp < 0 || (videoDuration > 0 && p.Timestamp >= videoDuration) {
+			continue
+		}
+		if !found || p.Timestamp < first {
+			first, found = p.Timestamp, true
+		}
+	}
+	return first, found
+}
+
+// buildMusicSyncArgs returns the ffmpeg arguments that shift a track by offset seconds: a
+// positive offset delays it with leading silence, a negative one trims the intro
+func buildMusicSyncArgs(inputPath, outputPath string, offset float64) []string {
+	filter := fmt.Sprintf("atrim=start=%s,asetpts=PTS-STARTPTS", formatSeconds(-offset))
+	if offset > 0 {
+		filter = fmt.Sprintf("adelay=%d:all=1", int(math.Round(offset*1000)))
+	}
+	return []string{"-i", inputPath, "-af", filter, "-y", outputPath}
+}
+
+// decodeMusicPCM decodes the first seconds of a track as mono float32 samples at
+// beatSyncSampleRate
+func decodeMusicPCM(ctx context.Context, path string, seconds float64) ([]float32, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "error",
+		"-i", path,
+		"-t", formatSeconds(seconds),
+		"-ac", "1",
+		"-ar", fmt.Sprint(beatSyncSampleRate),
+		"-f", "f32le",
+		"pipe:1",
+	)
+	output, err := ffmpegexec.CommandOutput(ctx, "decode_pcm", cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg decode failed: %w", err)
+	}
+	samples := make([]float32, len(output)/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(output[4*i:]))
+	}
+	return samples, nil
+}
+
+// syncMusicToBeat shifts the track at rawPath so its strongest beat near target lands on it,
+// returning the track to use and the offset applied. Without a beat it can trust, or when one
+// is already within beatsync.Tolerance, the track is returned unchanged with a zero offset;
+// beat sync never fails the music.
+func syncMusicToBeat(ctx context.Context, logger *zap.Logger, rawPath, tmpDir string, target float64) (string, float64) {
+	samples, err := decodeMusicPCM(ctx, rawPath, target+MaxMusicSyncShift+1)
+	if err != nil {
+		logger.Warn("Failed to decode music for beat sync, using track as generated", zap.Error(err))
+		return rawPath, 0
+	}
+
+	alignment, ok := beatsync.Align(beatsync.DetectOnsets(samples, beatSyncSampleRate), target, MaxMusicSyncShift)
+	if !ok {
+		logger.Info("No clear beat near the beat sync point, using track as generated",
+			zap.Float64("sync_point", target),
+		)
+		return rawPath, 0
+	}
+	if alignment.Offset == 0 {
+		logger.Info("Music beat already on the beat sync point",
+			zap.Float64("sync_point", target),
+			zap.Float64("beat", alignment.Beat.Time),
+		)
+		return rawPath, 0
+	}
+
+	syncedPath := filepath.Join(tmpDir, "music-synced.mp3")
+	cmd := exec.CommandContext(ctx, "ffmpeg", buildMusicSyncArgs(rawPath, syncedPath, alignment.Offset)...)
+	if output, err := ffmpegexec.CombinedOutput(ctx, "music_sync", cmd); err != nil {
+		logger.Warn("Failed to shift music onto the beat sync point, using track as generated",
+			zap.String("output", strings.TrimSpace(string(output))),
+			zap.Error(err),
+		)
+		return rawPath, 0
+	}
+
+	logger.Info("Music shifted onto the beat sync point",
+		zap.Float64("sync_point", target),
+		zap.Float64("beat", alignment.Beat.Time),
+		zap.Float64("beat_strength_db", alignment.Beat.Strength),
+		zap.Float64("offset", alignment.Offset),
+	)
+	return syncedPath, roundMillis(alignment.Offset)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirstBeatSyncPoint(t *testing.T) {
+	points := []domain.SyncPoint{
+		{Timestamp: 1, Type: "voiceover"},
+		{Timestamp: 12, Type: "beat", SceneNumber: 3},
+		{Timestamp: 4.5, Type: "beat", SceneNumber: 2},
+		{Timestamp: 2, Type: "sfx"},
+		{Timestamp: 31, Type: "beat"},
+	}
+	at, ok := firstBeatSyncPoint(points, 30)
+	require.True(t, ok)
+	require.Equal(t, 4.5, at)
+
+	_, ok = firstBeatSyncPoint(points[:1], 30)
+	require.False(t, ok, "no beat sync points")
+	_, ok = firstBeatSyncPoint([]domain.SyncPoint{{Timestamp: 31, Type: "beat"}, {Timestamp: -1, Type: "beat"}}, 30)
+	require.False(t, ok, "beats outside the video are ignored")
+}
+
+func TestBuildMusicSyncArgs(t *testing.T) {
+	require.Equal(t,
+		[]string{"-i", "raw.mp3", "-af", "adelay=1250:all=1", "-y", "synced.mp3"},
+		buildMusicSyncArgs("raw.mp3", "synced.mp3", 1.25),
+	)
+	require.Equal(t,
+		[]string{"-i", "raw.mp3", "-af", "atrim=start=0.75,asetpts=PTS-STARTPTS", "-y", "synced.mp3"},
+		buildMusicSyncArgs("raw.mp3", "synced.mp3", -0.75),
+	)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+// narrationRenderer synthesizes narration text into an audio file and returns its path
+type narrationRenderer func(ctx context.Context, text string) (string, error)
+
+// narrationFit describes the narration audio after fitting it to the video
+type narrationFit struct {
+	Path      string
+	Text      string  // script as rendered, after any truncation
+	Duration  float64 // seconds, after speed-up
+	Speed     float64 // applied atempo factor
+	Truncated bool    // script was cut at a sentence boundary
+	Loudness  *domain.LoudnessMeasurement
+}
+
+// fitNarrationToVideo renders the narration and reconciles its length with the video.
+// Narration that overruns the target is sped up with atempo; if MaxNarrationSpeed is not
+// enough, the script is truncated at a sentence boundary and rendered again.
+// reserved is time at the end of the target kept for audio played after the narration (e.g. a
+// disclaimer), which is neither rendered nor sped up with it.
+func (h *GenerateHandler) fitNarrationToVideo(
+	ctx context.Context,
+	jobID string,
+	text string,
+	targetDuration float64,
+	reserved float64,
+	tmpDir string,
+	render narrationRenderer,
+) (*narrationFit, error) {
+	path, err := render(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := composition.ProbeAudioDuration(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe narration duration: %w", err)
+	}
+
+	fit := &narrationFit{Path: path, Text: text, Duration: duration, Speed: 1.0}
+	available := targetDuration - reserved
+	speed := requiredNarrationSpeed(duration, available)
+
+	if speed > MaxNarrationSpeed {
+		maxWords := wordsThatFit(text, duration, available*MaxNarrationSpeed)
+		truncated, ok := truncateAtSentence(text, maxWords)
+		if ok {
+			h.log(ctx).Warn("Narration too long even at max speed, truncating script",
+				zap.Float64("narration_duration", duration),
+				zap.Float64("target_duration", available),
+				zap.Int("original_words", len(strings.Fields(text))),
+				zap.Int("truncated_words", len(strings.Fields(truncated))),
+			)
+
+			path, err = render(ctx, truncated)
+			if err != nil {
+				return nil, err
+			}
+			duration, err = composition.ProbeAudioDuration(ctx, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to probe truncated narration duration: %w", err)
+			}
+			fit.Path, fit.Text, fit.Duration, fit.Truncated = path, truncated, duration, true
+			speed = requiredNarrationSpeed(duration, available)
+		} else {
+			h.log(ctx).Warn("Narration too long but has no sentence boundary to truncate at",
+				zap.Float64("narration_duration", duration),
+				zap.Float64("target_duration", available),
+			)
+		}
+		speed = math.Min(speed, MaxNarrationSpeed)
+	}
+
+	if speed > 1.0 {
+		fittedPath := filepath.Join(tmpDir, "narrator-fitted.mp3")
+		if err := applyAtempo(ctx, fit.Path, fittedPath, speed); err != nil {
+			return nil, err
+		}
+		fit.Path = fittedPath
+		fit.Speed = speed
+		if d, err := composition.ProbeAudioDuration(ctx, fittedPath); err == nil {
+			fit.Duration = d
+		} else {
+			fit.Duration = duration / speed
+		}
+
+		h.log(ctx).Info("Narration sped up to fit video",
+			zap.Float64("speed", speed),
+			zap.Float64("original_duration", duration),
+			zap.Float64("final_duration", fit.Duration),
+			zap.Float64("target_duration", available),
+		)
+	}
+
+	return fit, nil
+}
+
+// recordNarrationFit stores the final narration timing on the job for the UI
+func recordNarrationFit(job *domain.Job, fit *narrationFit) {
+	if fit == nil {
+		return
+	}
+	job.NarrationDuration = math.Round(fit.Duration*100) / 100
+	job.NarrationSpeed = math.Round(fit.Speed*100) / 100
+	job.NarrationTruncated = fit.Truncated
+	job.NarrationLoudness = fit.Loudness
+}
+
+// requiredNarrationSpeed returns the atempo factor needed to fit narration into the target,
+// or 1.0 when the overrun is within NarrationOverrunTolerance
+func requiredNarrationSpeed(duration, target float64) float64 {
+	if target <= 0 || duration <= target+NarrationOverrunTolerance {
+		return 1.0
+	}
+	return duration / target
+}
+
+// atempoFilter builds an ffmpeg atempo filter for the factor.
+// A single atempo instance only accepts 0.5-2.0, so larger changes are chained.
+func atempoFilter(factor float64) string {
+	if factor <= 0 {
+		factor = 1.0
+	}
+
+	var stages []string
+	for factor > 2.0 {
+		stages = append(stages, "atempo=2")
+		factor /= 2.0
+	}
+	for factor < 0.5 {
+		stages = append(stages, "atempo=0.5")
+		factor /= 0.5
+	}
+	stages = append(stages, "atempo="+strconv.FormatFloat(math.Round(factor*10000)/10000, 'f', -1, 64))
+
+	return strings.Join(stages, ",")
+}
+
+// wordsThatFit scales the script's word count to the available time
+func wordsThatFit(text string, duration, available float64) int {
+	if duration <= 0 || available <= 0 {
+		return 0
+	}
+	words := len(strings.Fields(text))
+	return int(float64(words) * available / duration)
+}
+
+var sentencePattern = regexp.MustCompile(`[^.!?]*[.!?]+["')\]]*`)
+
+// truncateAtSentence keeps the leading whole sentences that fit within maxWords.
+// Returns false when the text already fits or not even the first sentence fits.
+func truncateAtSentence(text string, maxWords int) (string, bool) {
+	text = strings.TrimSpace(text)
+	if len(strings.Fields(text)) <= maxWords {
+		return text, false
+	}
+
+	var kept []string
+	words := 0
+	for _, sentence := range sentencePattern.FindAllString(text, -1) {
+		sentence = strings.TrimSpace(sentence)
+		n := len(strings.Fields(sentence))
+		if n == 0 {
+			continue
+		}
+		if words+n > maxWords {
+			break
+		}
+		kept = append(kept, sentence)
+		words += n
+	}
+
+	if len(kept) == 0 {
+		return text, false
+	}
+	return strings.Join(kept, " "), true
+}
+
+// applyAtempo re-encodes audio at the given tempo without changing pitch
+func applyAtempo(ctx context.Context, inputPath, outputPath string, factor float64) error {
+	if err := ffmpegexec.Exec(ctx, "atempo", ffmpegexec.Spec{
+		Args: []string{
+			"-i", inputPath,
+			"-filter:a", atempoFilter(factor),
+			"-y", outputPath,
+		},
+		Timeout: ffmpegexec.FrameTimeout,
+	}); err != nil {
+		return fmt.Errorf("failed to apply atempo %.2f: %w", factor, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAtempoFilter(t *testing.T) {
+	tests := []struct {
+		factor float64
+		want   string
+	}{
+		{1.0, "atempo=1"},
+		{1.25, "atempo=1.25"},
+		{2.0, "atempo=2"},
+		{3.0, "atempo=2,atempo=1.5"},
+		{5.0, "atempo=2,atempo=2,atempo=1.25"},
+		{0.4, "atempo=0.5,atempo=0.8"},
+		{1.0 / 3.0, "atempo=0.5,atempo=0.6667"},
+		{0.2, "atempo=0.5,atempo=0.5,atempo=0.8"},
+		{0, "atempo=1"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, atempoFilter(tt.factor), "factor %v", tt.factor)
+	}
+}
+
+func TestRequiredNarrationSpeed(t *testing.T) {
+	require.Equal(t, 1.0, requiredNarrationSpeed(14.0, 15.0), "shorter than video")
+	require.Equal(t, 1.0, requiredNarrationSpeed(15.4, 15.0), "within tolerance")
+	require.InDelta(t, 1.2, requiredNarrationSpeed(18.0, 15.0), 1e-9)
+	require.Equal(t, 1.0, requiredNarrationSpeed(18.0, 0), "unknown target")
+}
+
+func TestTruncateAtSentence(t *testing.T) {
+	text := "Meet the new Aero. It runs all day on a single charge! Why wait? Order yours today at aero.com."
+
+	t.Run("fits already", func(t *testing.T) {
+		got, truncated := truncateAtSentence(text, 100)
+		require.False(t, truncated)
+		require.Equal(t, text, got)
+	})
+
+	t.Run("keeps whole sentences", func(t *testing.T) {
+		got, truncated := truncateAtSentence(text, 15)
+		require.True(t, truncated)
+		require.Equal(t, "Meet the new Aero. It runs all day on a single charge! Why wait?", got)
+	})
+
+	t.Run("keeps first sentence only", func(t *testing.T) {
+		got, truncated := truncateAtSentence(text, 5)
+		require.True(t, truncated)
+		require.Equal(t, "Meet the new Aero.", got)
+	})
+
+	t.Run("first sentence too long", func(t *testing.T) {
+		got, truncated := truncateAtSentence(text, 2)
+		require.False(t, truncated)
+		require.Equal(t, text, got)
+	})
+
+	t.Run("closing quotes stay with sentence", func(t *testing.T) {
+		got, truncated := truncateAtSentence(`She said "go." Then she left. And never came back.`, 6)
+		require.True(t, truncated)
+		require.Equal(t, `She said "go." Then she left.`, got)
+	})
+}
+
+func TestWordsThatFit(t *testing.T) {
+	text := "one two three four five six seven eight nine ten"
+	require.Equal(t, 5, wordsThatFit(text, 20, 10))
+	require.Equal(t, 0, wordsThatFit(text, 0, 10))
+	require.Equal(t, 0, wordsThatFit(text, 20, -1))
+}
+
+// narrationProbeRunner answers duration probes from durations, by file name, and records the
+// atempo factor of each re-encode
+type narrationProbeRunner struct {
+	ffmpegexec.ExecRunner
+	durations map[string]float64
+	tempos    []string
+}
+
+func (r *narrationProbeRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	return []byte(fmt.Sprint(r.durations[filepath.Base(cmd.Args[len(cmd.Args)-1])])), nil
+}
+
+func (r *narrationProbeRunner) Exec(ctx context.Context, spec ffmpegexec.Spec) error {
+	input := filepath.Base(spec.Args[slices.Index(spec.Args, "-i")+1])
+	tempo := spec.Args[slices.Index(spec.Args, "-filter:a")+1]
+	r.tempos = append(r.tempos, input+" "+tempo)
+	factor, err := strconv.ParseFloat(strings.TrimPrefix(tempo, "atempo="), 64)
+	if err != nil {
+		return err
+	}
+	r.durations[filepath.Base(spec.Args[len(spec.Args)-1])] = r.durations[input] / factor
+	return nil
+}
+
+func TestFitNarrationToVideo_KeepsReservedTimeFree(t *testing.T) {
+	runner := &narrationProbeRunner{durations: map[string]float64{"narrator-main.mp3": 27}}
+	ctx := ffmpegexec.WithRunner(context.Background(), runner)
+	h := &GenerateHandler{logger: zap.NewNop()}
+	tmpDir := t.TempDir()
+	render := func(ctx context.Context, text string) (string, error) {
+		return filepath.Join(tmpDir, "narrator-main.mp3"), nil
+	}
+
+	// 27s of narration in a 30s video keeping 6s for the disclaimer after it
+	fit, err := h.fitNarrationToVideo(ctx, "job-fit", "Narration.", 30, 6, tmpDir, render)
+	require.NoError(t, err)
+	require.Equal(t, []string{"narrator-main.mp3 atempo=1.125"}, runner.tempos, "only the narration is sped up")
+	require.InDelta(t, 1.125, fit.Speed, 0.0001)
+	require.InDelta(t, 24, fit.Duration, 0.0001)
+
+	// Without a reserve the same narration fits as it is
+	runner.tempos = nil
+	fit, err = h.fitNarrationToVideo(ctx, "job-fit", "Narration.", 30, 0, tmpDir, render)
+	require.NoError(t, err)
+	require.Empty(t, runner.tempos)
+	require.Equal(t, 1.0, fit.Speed)
+}
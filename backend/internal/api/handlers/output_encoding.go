@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// videoDimensions returns the frame of the job's final video as it was encoded, or zeros before
+// it has been composed
+func videoDimensions(job *domain.Job) (int, int) {
+	if job.Encoding == nil {
+		return 0, 0
+	}
+	return job.Encoding.Width, job.Encoding.Height
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVideoDimensions(t *testing.T) {
+	width, height := videoDimensions(&domain.Job{Encoding: &domain.OutputEncoding{Width: 1080, Height: 1920}})
+	require.Equal(t, 1080, width)
+	require.Equal(t, 1920, height)
+
+	width, height = videoDimensions(&domain.Job{})
+	require.Zero(t, width, "not composed yet")
+	require.Zero(t, height)
+}
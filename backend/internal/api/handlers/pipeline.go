@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobeta"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/transitions"
+	"go.uber.org/zap"
+)
+
+// jobState is what a job's pipeline steps read and build on as the job runs
+type jobState struct {
+	job   *domain.Job
+	req   GenerateRequest
+	brand *domain.BrandGuidelines
+
+	// script is written by scriptStep, or rebuilt from the job when it resumes after approval
+	script *domain.Script
+
+	clips []composition.ClipVideo
+	// lastFrameURL is the previous scene's last frame, empty so the first scene is pure AI
+	// generation
+	lastFrameURL   string
+	sceneVideoURLs []string
+	thumbnailURL   string // Raw S3 URL for the job thumbnail (not presigned)
+	thumbnails     []domain.ThumbnailRendition
+
+	// sceneVoiceovers delivers the scene voiceovers synthesized while the clips generate, or is
+	// nil when the job has none
+	sceneVoiceovers chan []sceneVoiceoverClip
+
+	// videoDuration is the final video's length, end card included, once its clips are done
+	videoDuration float64
+
+	mp4Key       string
+	webmKey      string
+	composeStart time.Time
+}
+
+// stepResult is what a finished step leaves for the pipeline to checkpoint
+type stepResult struct {
+	// Stage is the stage the job enters once the step is done, saved with its progress. Zero
+	// leaves the job where it is.
+	Stage   domain.JobStage
+	Message string        // Timeline message for Stage
+	Elapsed time.Duration // How long the step took, for the timeline
+
+	// Warning is recorded on the job's timeline when the step went on without something
+	Warning string
+
+	// apply sets the step's outputs on the job. Steps that run alongside others return their
+	// outputs this way so that only the pipeline's goroutine writes the job.
+	apply func(job *domain.Job)
+}
+
+// stepError fails a job with a message for the user
+type stepError struct {
+	// Stage is the stage the job failed at. Zero is the stage the job is in.
+	Stage   domain.JobStage
+	Message string
+	Err     error
+	// save stores the job's progress before failing it, so the user can see what failed
+	save   bool
+	fields []zap.Field
+}
+
+func (e *stepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *stepError) Unwrap() error {
+	return e.Err
+}
+
+// pipelineStep is one step of a job's pipeline
+type pipelineStep interface {
+	// Name names the step in logs
+	Name() string
+	// Run does the step's work on the job. A *stepError describes how the job fails.
+	Run(ctx context.Context, state *jobState) (stepResult, error)
+}
+
+// stagedStep is a step that runs in a stage of its own
+type stagedStep interface {
+	pipelineStep
+	// Start returns the stage the job enters to run the step and its timeline message. It may
+	// ready the job for the stage's checkpoint, which follows.
+	Start(state *jobState) (domain.JobStage, string)
+}
+
+// concurrentStep is a step that runs on its own goroutine alongside the others of its stage
+type concurrentStep interface {
+	pipelineStep
+	// failed is what the step returns when it fails with err, as when it panics
+	failed(ctx context.Context, state *jobState, err error) (stepResult, error)
+}
+
+// scriptGenerator writes a job's script and stores it on the job
+type scriptGenerator interface {
+	writeScript(ctx context.Context, job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) (*domain.Script, error)
+	checkScriptCompliance(ctx context.Context, job *domain.Job, req GenerateRequest, script *domain.Script) error
+	embedScript(ctx context.Context, job *domain.Job, script *domain.Script)
+	warnRepeatedScenes(ctx context.Context, job *domain.Job, script *domain.Script)
+	saveScript(ctx context.Context, job *domain.Job)
+}
+
+// videoGenerator generates a job's scene clips
+type videoGenerator interface {
+	startImageForScene(ctx context.Context, job *domain.Job, scene domain.Scene, i, numScenes int, lastFrameURL string) string
+	generateScene(ctx context.Context, job *domain.Job, scene domain.Scene, sceneNumber int) (composition.ClipVideo, error)
+	analyzeContinuityStyle(ctx context.Context, job *domain.Job, lastFrameURL string)
+}
+
+// ttsGenerator synthesizes a job's voiceovers
+type ttsGenerator interface {
+	generateSceneVoiceovers(ctx context.Context, job *domain.Job, scenes []domain.Scene) []sceneVoiceoverClip
+	narrate(ctx context.Context, job *domain.Job, script *domain.Script, videoDuration float64) (*narratorOutput, error)
+}
+
+// musicGenerator generates a job's background music and sound effects
+type musicGenerator interface {
+	generateMusic(ctx context.Context, job *domain.Job, script *domain.Script, videoDuration float64) (*musicTrack, error)
+	generateSoundEffects(ctx context.Context, job *domain.Job, points []domain.SyncPoint) []domain.SFXClip
+}
+
+// assetStore makes images of a job's videos
+type assetStore interface {
+	extractJobThumbnail(ctx context.Context, job *domain.Job, videoURL string) (string, []domain.ThumbnailRendition, error)
+	publishScrubPreview(ctx context.Context, job *domain.Job, videoKey string)
+}
+
+// videoComposer composes a job's clips and audio into its final video
+type videoComposer interface {
+	composeFinal(ctx context.Context, job *domain.Job, clips []composition.ClipVideo) (mp4Key, webmKey string, err error)
+}
+
+// jobStore moves a job through its stages and stores its progress
+type jobStore interface {
+	enterStage(ctx context.Context, job *domain.Job, stage domain.JobStage) bool
+	checkpointStage(ctx context.Context, job *domain.Job) error
+	saveJobProgress(ctx context.Context, job *domain.Job) error
+	recordStage(ctx context.Context, job *domain.Job, message string, took time.Duration)
+	recordWarning(ctx context.Context, job *domain.Job, message string)
+	stopIfCanceled(ctx context.Context, job *domain.Job) bool
+	failJob(ctx context.Context, job *domain.Job, stage string, userMessage string, internalErr error, fields ...zap.Field)
+	completeJob(ctx context.Context, job *domain.Job, mp4Key, webmKey string, composeStart time.Time)
+}
+
+// jobPipeline runs a job's steps. Between them it owns the job's stage: a step's stage is
+// entered and checkpointed before it runs, the stage it finished at is saved with its progress,
+// and a step's error fails the job.
+type jobPipeline struct {
+	scripts  scriptGenerator
+	videos   videoGenerator
+	tts      ttsGenerator
+	music    musicGenerator
+	assets   assetStore
+	composer videoComposer
+	jobs     jobStore
+	eta      *jobeta.Estimator // Estimates the job's time left as stages start and finish; nil skips it
+	logger   *zap.Logger
+}
+
+// sceneRenderer generates scene clips with a job's video adapter
+type sceneRenderer struct {
+	*GenerateHandler
+	adapter adapters.VideoGeneratorAdapter
+}
+
+// pipeline returns the handler's pipeline for a job, generating its clips with videoAdapter
+func (h *GenerateHandler) pipeline(videoAdapter adapters.VideoGeneratorAdapter) *jobPipeline {
+	return &jobPipeline{
+		scripts:  h,
+		videos:   sceneRenderer{GenerateHandler: h, adapter: videoAdapter},
+		tts:      h,
+		music:    h,
+		assets:   h,
+		composer: h,
+		jobs:     h,
+		eta:      h.stageTimings,
+		logger:   h.logger,
+	}
+}
+
+func (p *jobPipeline) log(ctx context.Context) *zap.Logger {
+	return trace.Logger(ctx, p.logger)
+}
+
+// writeScript runs the script step. Returns false if the job was failed.
+func (p *jobPipeline) writeScript(ctx context.Context, state *jobState) bool {
+	return p.runStep(ctx, state, &scriptStep{scripts: p.scripts, logger: p.logger})
+}
+
+// generate runs the scene, audio and composition steps for a job whose script is ready, and
+// completes the job
+func (p *jobPipeline) generate(ctx context.Context, state *jobState) {
+	state.sceneVoiceovers = p.startSceneVoiceovers(ctx, state)
+
+	// Clips generate one at a time, each continuing from the last, and their actual lengths
+	// time the audio
+	state.sceneVideoURLs = make([]string, 0, len(state.script.Scenes))
+	for i := range state.script.Scenes {
+		if i > 0 && p.jobs.stopIfCanceled(ctx, state.job) {
+			return
+		}
+		step := &sceneStep{index: i, videos: p.videos, assets: p.assets, logger: p.logger}
+		if !p.runStep(ctx, state, step) {
+			return
+		}
+	}
+	if p.jobs.stopIfCanceled(ctx, state.job) {
+		return
+	}
+
+	p.fitTimeline(ctx, state)
+	if !p.runAudio(ctx, state) || p.jobs.stopIfCanceled(ctx, state.job) {
+		return
+	}
+
+	if !p.runStep(ctx, state, &composeStep{composer: p.composer, logger: p.logger}) || p.jobs.stopIfCanceled(ctx, state.job) {
+		return
+	}
+
+	// Hover-scrub preview of the final video
+	p.assets.publishScrubPreview(ctx, state.job, state.mp4Key)
+	p.jobs.completeJob(ctx, state.job, state.mp4Key, state.webmKey, state.composeStart)
+}
+
+// runStep enters step's stage and checkpoints it, runs the step, and saves what it finished.
+// Returns false if the job was failed.
+func (p *jobPipeline) runStep(ctx context.Context, state *jobState, step stagedStep) bool {
+	stage, message := step.Start(state)
+	if !p.begin(ctx, state, stage, message) {
+		return false
+	}
+	result, err := step.Run(ctx, state)
+	if err != nil {
+		p.fail(ctx, state, err)
+		return false
+	}
+	return p.finish(ctx, state, result)
+}
+
+// runAudio runs the narrator, music and sound effect steps together, with the job in
+// audio_generating while they do. The first step to fail cancels the others, which are waited
+// for before the job is failed. Returns false if the job was failed.
+func (p *jobPipeline) runAudio(ctx context.Context, state *jobState) bool {
+	audioCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	steps := []concurrentStep{
+		&narratorStep{tts: p.tts, logger: p.logger},
+		&musicStep{music: p.music, logger: p.logger},
+		&soundEffectsStep{music: p.music},
+	}
+	outcomes := make([]<-chan stepOutcome, len(steps))
+	for i, step := range steps {
+		outcomes[i] = p.launch(audioCtx, state, step)
+	}
+	// stop cancels the steps still running and waits for them, so none outlives the stage
+	stop := func(running []<-chan stepOutcome, cause error) {
+		cancel(cause)
+		for _, outcome := range running {
+			<-outcome
+		}
+	}
+
+	if !p.begin(ctx, state, domain.StageAudioGenerating, "Generating music and narration") {
+		stop(outcomes, nil)
+		return false
+	}
+	p.log(ctx).Info("Waiting for audio generation to complete")
+
+	// Taken in order, so the narrator fails the job before a missing track is waited for
+	for i, outcome := range outcomes {
+		out := <-outcome
+		if out.err != nil {
+			stop(outcomes[i+1:], out.err)
+			// A failed step's result holds what it found, such as compliance issues
+			if out.result.apply != nil {
+				out.result.apply(state.job)
+			}
+			p.fail(ctx, state, out.err)
+			return false
+		}
+		if !p.finish(ctx, state, out.result) {
+			stop(outcomes[i+1:], nil)
+			return false
+		}
+	}
+	return p.finish(ctx, state, stepResult{Stage: domain.StageAudioComplete, Message: "Audio ready"})
+}
+
+// stepOutcome is what a step run on its own goroutine returned
+type stepOutcome struct {
+	result stepResult
+	err    error
+}
+
+// launch runs step on its own goroutine, delivering its outcome on the returned channel
+func (p *jobPipeline) launch(ctx context.Context, state *jobState, step concurrentStep) <-chan stepOutcome {
+	outcome := make(chan stepOutcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				p.log(ctx).Error("Panic in pipeline step",
+					zap.String("step", step.Name()),
+					zap.Any("panic", r),
+				)
+				result, err := step.failed(ctx, state, fmt.Errorf("%s generation panic: %v", step.Name(), r))
+				outcome <- stepOutcome{result: result, err: err}
+			}
+		}()
+		result, err := step.Run(ctx, state)
+		outcome <- stepOutcome{result: result, err: err}
+	}()
+	return outcome
+}
+
+// begin moves the job into stage and checkpoints it. Returns false if the job was failed.
+func (p *jobPipeline) begin(ctx context.Context, state *jobState, stage domain.JobStage, message string) bool {
+	if !p.jobs.enterStage(ctx, state.job, stage) {
+		return false
+	}
+	p.updateETA(state.job)
+	if err := p.jobs.checkpointStage(ctx, state.job); err != nil {
+		p.log(ctx).Error("Failed to update job stage",
+			zap.Stringer("stage", state.job.Stage),
+			zap.Error(err),
+		)
+	}
+	p.jobs.recordStage(ctx, state.job, message, 0)
+	return true
+}
+
+// finish takes a step's result onto the job, saving its progress when the step finished a
+// stage. Returns false if the job was failed.
+func (p *jobPipeline) finish(ctx context.Context, state *jobState, result stepResult) bool {
+	if result.apply != nil {
+		result.apply(state.job)
+	}
+	if result.Warning != "" {
+		p.jobs.recordWarning(ctx, state.job, result.Warning)
+	}
+	if result.Stage.IsZero() {
+		return true
+	}
+	if !p.jobs.enterStage(ctx, state.job, result.Stage) {
+		return false
+	}
+	p.updateETA(state.job)
+	if err := p.jobs.saveJobProgress(ctx, state.job); err != nil {
+		p.log(ctx).Error("Failed to save job progress",
+			zap.Stringer("stage", state.job.Stage),
+			zap.Error(err),
+		)
+	}
+	p.jobs.recordStage(ctx, state.job, result.Message, result.Elapsed)
+	return true
+}
+
+// updateETA estimates the time job has left from the stage it just entered, to be saved with it.
+// A job with nothing of its own left to run, such as one waiting for approval, has its estimate
+// cleared.
+func (p *jobPipeline) updateETA(job *domain.Job) {
+	if p.eta == nil {
+		return
+	}
+	if seconds, ok := p.eta.Estimate(job); ok {
+		job.ETASeconds, job.ETAUpdatedAt = seconds, time.Now().Unix()
+	} else {
+		job.ETASeconds, job.ETAUpdatedAt = 0, 0
+	}
+}
+
+// fail fails the job with a step's error. Errors that aren't a *stepError fail it as a stage
+// that stopped unexpectedly.
+func (p *jobPipeline) fail(ctx context.Context, state *jobState, err error) {
+	var failure *stepError
+	if !errors.As(err, &failure) {
+		failure = &stepError{Message: stageFailureMessage, Err: err}
+	}
+	if failure.save {
+		if err := p.jobs.saveJobProgress(ctx, state.job); err != nil {
+			p.log(ctx).Error("Failed to save job before failing it", zap.Error(err))
+		}
+	}
+	stage := state.job.Stage
+	if !failure.Stage.IsZero() {
+		stage = failure.Stage
+	}
+	p.jobs.failJob(ctx, state.job, stage.String(), failure.Message, failure.Err, failure.fields...)
+}
+
+// startSceneVoiceovers synthesizes the job's scene voiceovers while its clips generate, as they
+// only need the script. Returns nil when the job has none.
+func (p *jobPipeline) startSceneVoiceovers(ctx context.Context, state *jobState) chan []sceneVoiceoverClip {
+	if !hasSceneVoiceovers(state.job, state.script.Scenes) {
+		return nil
+	}
+	voiceovers := make(chan []sceneVoiceoverClip, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				p.log(ctx).Error("Panic in scene voiceover generation",
+					zap.Any("panic", r),
+				)
+				voiceovers <- nil
+			}
+		}()
+		voiceovers <- p.tts.generateSceneVoiceovers(ctx, state.job, state.script.Scenes)
+	}()
+	return voiceovers
+}
+
+// fitTimeline measures the final video from its generated clips and places the scene voiceovers
+// and side effects on it. Blended transitions overlap the clips they join, so the music,
+// narration and side effects are fitted to the shorter video composition will produce.
+func (p *jobPipeline) fitTimeline(ctx context.Context, state *jobState) {
+	job := state.job
+	clipLengths := composition.ClipDurations(state.clips)
+	boundaries := composition.SceneBoundaries(job, job.Scenes, clipLengths)
+	state.videoDuration = composition.SumDurations(clipLengths) - transitions.Overlap(boundaries)
+
+	p.log(ctx).Info("Video clips generation complete",
+		zap.Int("num_clips", len(state.clips)),
+		zap.Float64("actual_video_duration", state.videoDuration),
+		zap.Float64("transition_overlap", transitions.Overlap(boundaries)),
+		zap.Int("requested_duration", job.Duration),
+	)
+
+	// Place scene voiceovers on the timeline using the actual clip lengths, each scene starting
+	// where the blend into it does
+	if state.sceneVoiceovers != nil {
+		job.SceneVoiceovers = sceneVoiceoverTimings(<-state.sceneVoiceovers, transitions.Shares(clipLengths, boundaries))
+	}
+
+	// The end card is appended at composition; the music, narration and side effects timing
+	// all cover it
+	if cardDuration := composition.EndCardDuration(job); cardDuration > 0 {
+		state.videoDuration += cardDuration
+		p.log(ctx).Info("Including end card in video duration",
+			zap.Float64("end_card_duration", cardDuration),
+			zap.Float64("video_duration", state.videoDuration),
+		)
+	}
+
+	// Update side effects start time based on actual video duration
+	if job.SideEffectsText != "" {
+		job.SideEffectsStartTime = state.videoDuration * 0.8
+		p.log(ctx).Info("Updated side effects start time for actual video duration",
+			zap.Float64("side_effects_start_time", job.SideEffectsStartTime),
+		)
+	}
+}
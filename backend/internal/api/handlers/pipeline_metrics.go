@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+)
+
+// Pipeline stages timed by metrics.StageDuration
+const (
+	metricStageScript      = "script"
+	metricStageScene       = "scene" // Each scene clip, generated and uploaded
+	metricStageNarrator    = "narrator"
+	metricStageAudio       = "audio" // Background music
+	metricStageComposition = "composition"
+)
+
+// Terminal job outcomes counted by metrics.JobOutcome
+const (
+	outcomeCompleted = "completed"
+	outcomeFailed    = "failed"
+	outcomeCanceled  = "canceled"
+)
+
+// sceneStageNumber matches the scene number in stages like scene_3_generating
+var sceneStageNumber = regexp.MustCompile(`^scene_\d+_`)
+
+// jobMetricDims are the dimensions every metric about a job carries: never its user or ID
+func jobMetricDims(job *domain.Job) metrics.Dimensions {
+	return metrics.Dimensions{
+		"model":           job.Model,
+		"duration_bucket": metrics.DurationBucket(job.Duration),
+	}
+}
+
+// timeStage records how long a pipeline stage of job has taken since start
+func timeStage(ctx context.Context, job *domain.Job, stage string, start time.Time) {
+	dims := jobMetricDims(job)
+	dims["stage"] = stage
+	metrics.Since(metrics.FromContext(ctx), metrics.StageDuration, start, dims)
+}
+
+// metricStage drops the scene number from a job stage, so every scene shares one dimension value
+func metricStage(stage string) string {
+	return sceneStageNumber.ReplaceAllString(stage, "scene_")
+}
+
+// recordJobOutcome counts a job reaching a terminal status. failureStage is the stage a failed
+// job stopped at, and "" otherwise.
+func recordJobOutcome(rec metrics.Recorder, job *domain.Job, outcome, failureStage string) {
+	dims := jobMetricDims(job)
+	dims["outcome"] = outcome
+	dims["failure_stage"] = metricStage(failureStage)
+	if failureStage == "" {
+		dims["failure_stage"] = "none"
+	}
+	rec.Counter(metrics.JobOutcome, 1, dims)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedMetric struct {
+	name string
+	dims metrics.Dimensions
+}
+
+// fakeRecorder keeps every measurement it receives
+type fakeRecorder struct {
+	mu       sync.Mutex
+	recorded []recordedMetric
+}
+
+func (r *fakeRecorder) record(name string, dims metrics.Dimensions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, recordedMetric{name: name, dims: dims})
+}
+
+func (r *fakeRecorder) Counter(name string, _ float64, dims metrics.Dimensions) {
+	r.record(name, dims)
+}
+
+func (r *fakeRecorder) Histogram(name string, _ float64, _ metrics.Unit, dims metrics.Dimensions) {
+	r.record(name, dims)
+}
+
+func (r *fakeRecorder) Gauge(name string, _ float64, _ metrics.Unit, dims metrics.Dimensions) {
+	r.record(name, dims)
+}
+
+// runMeteredPipeline times each stage the way generateFromScript does, failing at failScene
+func runMeteredPipeline(ctx context.Context, rec metrics.Recorder, job *domain.Job, scenes, failScene int) {
+	timeStage(ctx, job, metricStageScript, time.Now())
+	for i := 1; i <= scenes; i++ {
+		timeStage(ctx, job, metricStageScene, time.Now())
+		if i == failScene {
+			recordJobOutcome(rec, job, outcomeFailed, "scene_2_generating")
+			return
+		}
+	}
+	timeStage(ctx, job, metricStageNarrator, time.Now())
+	timeStage(ctx, job, metricStageAudio, time.Now())
+	timeStage(ctx, job, metricStageComposition, time.Now())
+	recordJobOutcome(rec, job, outcomeCompleted, "")
+}
+
+func TestPipelineMetricsNamesAndDimensions(t *testing.T) {
+	rec := &fakeRecorder{}
+	ctx := metrics.WithRecorder(context.Background(), rec)
+	job := &domain.Job{JobID: "job456", UserID: "user123", Model: "kling", Duration: 30}
+
+	runMeteredPipeline(ctx, rec, job, 3, 0)
+
+	var stages []string
+	for _, m := range rec.recorded {
+		require.NotContains(t, m.dims, "user_id")
+		require.NotContains(t, m.dims, "job_id")
+		for _, v := range m.dims {
+			require.NotEqual(t, "user123", v)
+		}
+		require.Equal(t, "kling", m.dims["model"])
+		require.Equal(t, "16-30s", m.dims["duration_bucket"])
+		if m.name == metrics.StageDuration {
+			stages = append(stages, m.dims["stage"])
+		}
+	}
+	require.Equal(t, []string{"script", "scene", "scene", "scene", "narrator", "audio", "composition"}, stages)
+
+	outcome := rec.recorded[len(rec.recorded)-1]
+	require.Equal(t, metrics.JobOutcome, outcome.name)
+	require.Equal(t, "completed", outcome.dims["outcome"])
+	require.Equal(t, "none", outcome.dims["failure_stage"])
+}
+
+func TestPipelineMetricsFailureStage(t *testing.T) {
+	rec := &fakeRecorder{}
+	ctx := metrics.WithRecorder(context.Background(), rec)
+	job := &domain.Job{Model: "veo", Duration: 60}
+
+	runMeteredPipeline(ctx, rec, job, 3, 2)
+
+	outcome := rec.recorded[len(rec.recorded)-1]
+	require.Equal(t, metrics.JobOutcome, outcome.name)
+	require.Equal(t, metrics.Dimensions{
+		"model":           "veo",
+		"duration_bucket": "46-60s",
+		"outcome":         "failed",
+		"failure_stage":   "scene_generating", // Scene numbers would multiply the metric per scene
+	}, outcome.dims)
+}
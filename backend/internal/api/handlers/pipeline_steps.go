@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// scriptStep writes the job's script with GPT-4o and embeds it in the job
+type scriptStep struct {
+	scripts scriptGenerator
+	logger  *zap.Logger
+}
+
+func (s *scriptStep) Name() string { return "script" }
+
+func (s *scriptStep) Start(state *jobState) (domain.JobStage, string) {
+	return domain.StageScriptGenerating, "Writing the script"
+}
+
+func (s *scriptStep) Run(ctx context.Context, state *jobState) (stepResult, error) {
+	log := trace.Logger(ctx, s.logger)
+	job := state.job
+	log.Info("Generating script with GPT-4o")
+
+	start := time.Now()
+	script, err := s.scripts.writeScript(ctx, job, state.req, state.brand)
+	if err != nil {
+		log.Error("Script generation failed with error",
+			zap.Stringer("stage", job.Stage),
+			zap.Error(err),
+			zap.String("error_type", fmt.Sprintf("%T", err)),
+			zap.String("error_string", err.Error()),
+		)
+		return stepResult{}, &stepError{Message: scriptFailure(err), Err: err}
+	}
+
+	// Checked before embedding, which replaces the script's side effects with the user's
+	complianceErr := s.scripts.checkScriptCompliance(ctx, job, state.req, script)
+	s.scripts.embedScript(ctx, job, script)
+	if complianceErr != nil {
+		// The script and its issues are kept so the user can see what failed
+		return stepResult{}, &stepError{Message: complianceFailureMessage, Err: complianceErr, save: true}
+	}
+
+	s.scripts.warnRepeatedScenes(ctx, job, script)
+	s.scripts.saveScript(ctx, job)
+	state.script = script
+
+	log.Info("Script generated and embedded in job",
+		zap.String("title", script.Title),
+		zap.Int("num_scenes", len(script.Scenes)),
+		zap.String("audio_mood", script.AudioSpec.MusicMood),
+		zap.String("audio_style", script.AudioSpec.MusicStyle),
+	)
+
+	// Log each scene for visibility
+	for i, scene := range script.Scenes {
+		log.Info("Scene details",
+			zap.Int("scene_number", i+1),
+			zap.Float64("start_time", scene.StartTime),
+			zap.Float64("duration", scene.Duration),
+			zap.String("shot_type", string(scene.ShotType)),
+			zap.String("camera_angle", string(scene.CameraAngle)),
+			zap.String("lighting", string(scene.Lighting)),
+			zap.String("color_grade", string(scene.ColorGrade)),
+			zap.String("mood", string(scene.Mood)),
+			zap.String("generation_prompt", scene.GenerationPrompt),
+		)
+	}
+
+	return stepResult{
+		Stage:   domain.StageScriptComplete,
+		Message: fmt.Sprintf("Script written with %d scenes", len(script.Scenes)),
+		Elapsed: time.Since(start),
+	}, nil
+}
+
+// sceneStep generates one scene's clip, continuing from the previous scene's last frame. The
+// first scene also sets the job's thumbnail.
+type sceneStep struct {
+	index  int // Position in the script, from 0
+	videos videoGenerator
+	assets assetStore
+	logger *zap.Logger
+}
+
+func (s *sceneStep) Name() string { return "scene" }
+
+func (s *sceneStep) Start(state *jobState) (domain.JobStage, string) {
+	state.job.ScenesCompleted = s.index // Number completed so far
+	return domain.SceneGenerating(s.index + 1), fmt.Sprintf("Generating scene %d of %d", s.index+1, len(state.script.Scenes))
+}
+
+func (s *sceneStep) Run(ctx context.Context, state *jobState) (stepResult, error) {
+	log := trace.Logger(ctx, s.logger)
+	job := state.job
+	sceneNum := s.index + 1
+	numScenes := len(state.script.Scenes)
+	log.Info("Generating scene",
+		zap.Int("scene", sceneNum),
+		zap.Int("total", numScenes),
+	)
+
+	scene := state.script.Scenes[s.index]
+	scene.StartImageURL = s.videos.startImageForScene(ctx, job, scene, s.index, numScenes, state.lastFrameURL)
+	scene = withContinuityStyle(job, scene, sceneNum)
+
+	start := time.Now()
+	clip, err := s.videos.generateScene(ctx, job, scene, sceneNum)
+	if err != nil {
+		message := fmt.Sprintf(sceneFailureMessageFormat, sceneNum)
+		var rejected *composition.ClipQualityError
+		if errors.As(err, &rejected) {
+			message = fmt.Sprintf(clipQualityMessageFormat, sceneNum)
+		}
+		return stepResult{}, &stepError{
+			Message: message,
+			Err:     err,
+			fields:  []zap.Field{zap.Int("scene", sceneNum)},
+		}
+	}
+
+	state.clips = append(state.clips, clip)
+	state.lastFrameURL = clip.LastFrameURL
+	state.sceneVideoURLs = append(state.sceneVideoURLs, clip.VideoURL)
+
+	var result stepResult
+	if s.index == 0 {
+		s.videos.analyzeContinuityStyle(ctx, job, state.lastFrameURL)
+
+		// The job thumbnail comes from the clip itself: clip.LastFrameURL is presigned (for Veo
+		// API continuity) and should NOT be stored in DB
+		thumbnail, renditions, err := s.assets.extractJobThumbnail(ctx, job, clip.VideoURL)
+		if err != nil {
+			log.Warn("Failed to extract job thumbnail, continuing without it",
+				zap.Error(err),
+			)
+			result.Warning = "Thumbnail extraction failed; continuing without a thumbnail"
+		} else {
+			// Store raw S3 URL (not presigned) - will be presigned when served via API
+			state.thumbnailURL = thumbnail
+			state.thumbnails = renditions
+			log.Info("Job thumbnail set from first scene",
+				zap.String("thumbnail_url", thumbnail),
+			)
+		}
+	}
+
+	// Initialize versioning for this scene (version 1)
+	if job.SceneVersions == nil {
+		job.SceneVersions = make(map[int]int)
+	}
+	if job.ClipVersions == nil {
+		job.ClipVersions = make(map[string]string)
+	}
+	job.SceneVersions[sceneNum] = 1
+	job.ClipVersions[fmt.Sprintf("scene-%d-v1", sceneNum)] = clip.VideoURL
+
+	job.ScenesCompleted = sceneNum
+	job.SceneVideoURLs = state.sceneVideoURLs
+	job.ThumbnailURL = state.thumbnailURL
+	job.Thumbnails = state.thumbnails
+
+	log.Info("Scene completed",
+		zap.Int("scene", sceneNum),
+		zap.Int("total_scenes", numScenes),
+		zap.String("video_url", clip.VideoURL),
+	)
+
+	result.Stage = domain.SceneComplete(sceneNum)
+	result.Message = fmt.Sprintf("Scene %d generated", sceneNum)
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
+
+// isTwoPassNarration reports whether a job's narration is written to fit its side effects, as
+// pharmaceutical ads' is
+func isTwoPassNarration(job *domain.Job) bool {
+	return job.Voice != "" && job.SideEffectsText != ""
+}
+
+// narratorStep voices the job's narrator over the video's actual duration, alongside its music
+type narratorStep struct {
+	tts    ttsGenerator
+	logger *zap.Logger
+}
+
+func (s *narratorStep) Name() string { return "narrator" }
+
+func (s *narratorStep) Run(ctx context.Context, state *jobState) (stepResult, error) {
+	log := trace.Logger(ctx, s.logger)
+	job := state.job
+	twoPass := isTwoPassNarration(job)
+	if job.Voice == "" || (job.AudioSpec.NarratorScript == "" && !twoPass) {
+		if job.Voice == "" {
+			log.Info("Skipping narrator voiceover (voice not configured)")
+		} else {
+			log.Warn("Skipping narrator voiceover (narrator script missing)")
+		}
+		return stepResult{}, nil
+	}
+
+	log.Info("Generating narrator voiceover (parallel with music)",
+		zap.Float64("target_duration", state.videoDuration),
+		zap.Bool("two_pass", twoPass),
+	)
+	out, err := s.tts.narrate(ctx, job, state.script, state.videoDuration)
+	if err != nil {
+		result, failure := s.failed(ctx, state, err)
+		if out != nil {
+			result.apply = out.apply
+		}
+		return result, failure
+	}
+	return stepResult{apply: func(job *domain.Job) {
+		out.apply(job)
+		if out.URL == "" {
+			return
+		}
+		log.Info("Narrator voiceover complete",
+			zap.String("narrator_url", out.URL),
+		)
+	}}, nil
+}
+
+func (s *narratorStep) failed(ctx context.Context, state *jobState, err error) (stepResult, error) {
+	failure := &stepError{Stage: domain.StageNarratorGenerating, Message: narratorFailureMessage, Err: err}
+	if errors.Is(err, errNonCompliant) {
+		// The compliance issues are kept for the user to see
+		failure.Message = complianceFailureMessage
+		failure.save = true
+	}
+	return stepResult{}, failure
+}
+
+// musicStep generates the job's background music to the video's actual duration, alongside its
+// narration
+type musicStep struct {
+	music  musicGenerator
+	logger *zap.Logger
+}
+
+func (s *musicStep) Name() string { return "music" }
+
+func (s *musicStep) Run(ctx context.Context, state *jobState) (stepResult, error) {
+	log := trace.Logger(ctx, s.logger)
+	log.Info("Generating background music (parallel with narrator)",
+		zap.Float64("target_duration", state.videoDuration),
+	)
+	track, err := s.music.generateMusic(ctx, state.job, state.script, state.videoDuration)
+	if err != nil {
+		return s.failed(ctx, state, err)
+	}
+	return stepResult{apply: func(job *domain.Job) {
+		job.AudioURL = track.URL
+		recordMusicFit(job, track)
+		log.Info("Background music complete",
+			zap.String("audio_url", job.AudioURL),
+			zap.String("music_provider", job.MusicProvider),
+			zap.String("music_fit", job.MusicFit),
+		)
+	}}, nil
+}
+
+func (s *musicStep) failed(ctx context.Context, state *jobState, err error) (stepResult, error) {
+	if !state.job.AllowSilentFallback {
+		return stepResult{}, &stepError{Message: audioFailureMessage, Err: err}
+	}
+	trace.Logger(ctx, s.logger).Warn("Background music failed, continuing without it (allow_silent_fallback)",
+		zap.Error(err),
+	)
+	return stepResult{Warning: "Background music generation failed; continuing without music"}, nil
+}
+
+// soundEffectsStep generates the sound effects a job asked for at its script's sync points.
+// They are optional and never fail the job.
+type soundEffectsStep struct {
+	music musicGenerator
+}
+
+func (s *soundEffectsStep) Name() string { return "sound effect" }
+
+func (s *soundEffectsStep) Run(ctx context.Context, state *jobState) (stepResult, error) {
+	if !state.job.GenerateSFX {
+		return stepResult{}, nil
+	}
+	points := sfxSyncPoints(state.script.AudioSpec.SyncPoints, state.videoDuration)
+	clips := s.music.generateSoundEffects(ctx, state.job, points)
+	return stepResult{apply: func(job *domain.Job) { job.SFX = clips }}, nil
+}
+
+func (s *soundEffectsStep) failed(ctx context.Context, state *jobState, err error) (stepResult, error) {
+	return stepResult{apply: func(job *domain.Job) { job.SFX = nil }}, nil
+}
+
+// composeStep composes the job's clips and audio into its final video
+type composeStep struct {
+	composer videoComposer
+	logger   *zap.Logger
+}
+
+func (s *composeStep) Name() string { return "composition" }
+
+func (s *composeStep) Start(state *jobState) (domain.JobStage, string) {
+	return domain.StageComposing, "Composing the final video"
+}
+
+func (s *composeStep) Run(ctx context.Context, state *jobState) (stepResult, error) {
+	trace.Logger(ctx, s.logger).Info("Composing final video (video track only)")
+
+	state.composeStart = time.Now()
+	mp4Key, webmKey, err := s.composer.composeFinal(ctx, state.job, state.clips)
+	if err != nil {
+		return stepResult{}, &stepError{Message: compositionFailureMessage, Err: err}
+	}
+	state.mp4Key = mp4Key
+	state.webmKey = webmKey
+	// The job completes in composing once its preview is published
+	return stepResult{Elapsed: time.Since(state.composeStart)}, nil
+}
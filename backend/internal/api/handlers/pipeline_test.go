@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobeta"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeJobSteps stands in for everything a job's pipeline runs against. It records the job's
+// stage transitions, checkpoints and timeline in the order the pipeline made them; the audio
+// steps, which run together, aren't recorded there.
+type fakeJobSteps struct {
+	mu     sync.Mutex
+	events []string
+
+	scriptErr     error
+	complianceErr error
+	sceneErrs     map[int]error
+	narration     func() (*narratorOutput, error)
+	track         func(ctx context.Context) (*musicTrack, error)
+	effects       func() []domain.SFXClip
+	composeErr    error
+	onEnter       func(stage domain.JobStage)
+
+	narratedFor float64
+	etas        []int // The job's ETA at each checkpoint and save
+}
+
+func (f *fakeJobSteps) record(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeJobSteps) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.events)
+}
+
+func (f *fakeJobSteps) writeScript(ctx context.Context, job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) (*domain.Script, error) {
+	if f.scriptErr != nil {
+		return nil, f.scriptErr
+	}
+	return &domain.Script{
+		Title:         "Cold brew mornings",
+		TotalDuration: 16,
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 8, GenerationPrompt: "Coffee pours over ice"},
+			{SceneNumber: 2, Duration: 8, GenerationPrompt: "A commuter sips on the train"},
+		},
+		AudioSpec: domain.AudioSpec{NarratorScript: "Mornings, sorted.", MusicMood: "upbeat"},
+	}, nil
+}
+
+func (f *fakeJobSteps) checkScriptCompliance(ctx context.Context, job *domain.Job, req GenerateRequest, script *domain.Script) error {
+	return f.complianceErr
+}
+
+func (f *fakeJobSteps) embedScript(ctx context.Context, job *domain.Job, script *domain.Script) {
+	job.Scenes = script.Scenes
+	job.AudioSpec = script.AudioSpec
+}
+
+func (f *fakeJobSteps) warnRepeatedScenes(ctx context.Context, job *domain.Job, script *domain.Script) {
+}
+
+func (f *fakeJobSteps) saveScript(ctx context.Context, job *domain.Job) {}
+
+func (f *fakeJobSteps) startImageForScene(ctx context.Context, job *domain.Job, scene domain.Scene, i, numScenes int, lastFrameURL string) string {
+	return lastFrameURL
+}
+
+func (f *fakeJobSteps) generateScene(ctx context.Context, job *domain.Job, scene domain.Scene, sceneNumber int) (composition.ClipVideo, error) {
+	f.record("generate scene %d from %q", sceneNumber, scene.StartImageURL)
+	if err := f.sceneErrs[sceneNumber]; err != nil {
+		return composition.ClipVideo{}, err
+	}
+	return composition.ClipVideo{
+		VideoURL:     fmt.Sprintf("clip-%d.mp4", sceneNumber),
+		LastFrameURL: fmt.Sprintf("frame-%d.jpg", sceneNumber),
+		Duration:     scene.Duration,
+	}, nil
+}
+
+func (f *fakeJobSteps) analyzeContinuityStyle(ctx context.Context, job *domain.Job, lastFrameURL string) {
+}
+
+func (f *fakeJobSteps) generateSceneVoiceovers(ctx context.Context, job *domain.Job, scenes []domain.Scene) []sceneVoiceoverClip {
+	return nil
+}
+
+func (f *fakeJobSteps) narrate(ctx context.Context, job *domain.Job, script *domain.Script, videoDuration float64) (*narratorOutput, error) {
+	f.mu.Lock()
+	f.narratedFor = videoDuration
+	f.mu.Unlock()
+	if f.narration != nil {
+		return f.narration()
+	}
+	return &narratorOutput{URL: "narration.mp3"}, nil
+}
+
+func (f *fakeJobSteps) generateMusic(ctx context.Context, job *domain.Job, script *domain.Script, videoDuration float64) (*musicTrack, error) {
+	if f.track != nil {
+		return f.track(ctx)
+	}
+	return &musicTrack{URL: "music.mp3"}, nil
+}
+
+func (f *fakeJobSteps) generateSoundEffects(ctx context.Context, job *domain.Job, points []domain.SyncPoint) []domain.SFXClip {
+	if f.effects != nil {
+		return f.effects()
+	}
+	return []domain.SFXClip{{Timestamp: 1, Description: "ice clinks", URL: "sfx-1.mp3"}}
+}
+
+func (f *fakeJobSteps) extractJobThumbnail(ctx context.Context, job *domain.Job, videoURL string) (string, []domain.ThumbnailRendition, error) {
+	f.record("thumbnail from %s", videoURL)
+	return "thumbnail.jpg", nil, nil
+}
+
+func (f *fakeJobSteps) publishScrubPreview(ctx context.Context, job *domain.Job, videoKey string) {
+	f.record("scrub preview of %s", videoKey)
+}
+
+func (f *fakeJobSteps) composeFinal(ctx context.Context, job *domain.Job, clips []composition.ClipVideo) (string, string, error) {
+	f.record("compose %d clips", len(clips))
+	if f.composeErr != nil {
+		return "", "", f.composeErr
+	}
+	return "final.mp4", "final.webm", nil
+}
+
+func (f *fakeJobSteps) enterStage(ctx context.Context, job *domain.Job, stage domain.JobStage) bool {
+	if err := job.AdvanceStage(stage); err != nil {
+		f.failJob(ctx, job, job.Stage.String(), stageFailureMessage, err)
+		return false
+	}
+	f.record("enter %s", stage)
+	if f.onEnter != nil {
+		f.onEnter(stage)
+	}
+	return true
+}
+
+func (f *fakeJobSteps) checkpointStage(ctx context.Context, job *domain.Job) error {
+	f.record("checkpoint %s", job.Stage)
+	f.etas = append(f.etas, job.ETASeconds)
+	return nil
+}
+
+func (f *fakeJobSteps) saveJobProgress(ctx context.Context, job *domain.Job) error {
+	f.record("save %s", job.Stage)
+	f.etas = append(f.etas, job.ETASeconds)
+	return nil
+}
+
+func (f *fakeJobSteps) recordStage(ctx context.Context, job *domain.Job, message string, took time.Duration) {
+	f.record("timeline %s", message)
+}
+
+func (f *fakeJobSteps) recordWarning(ctx context.Context, job *domain.Job, message string) {
+	f.record("warning %s", message)
+}
+
+func (f *fakeJobSteps) stopIfCanceled(ctx context.Context, job *domain.Job) bool {
+	return false
+}
+
+func (f *fakeJobSteps) failJob(ctx context.Context, job *domain.Job, stage string, userMessage string, internalErr error, fields ...zap.Field) {
+	f.record("fail at %s: %s", stage, userMessage)
+}
+
+func (f *fakeJobSteps) completeJob(ctx context.Context, job *domain.Job, mp4Key, webmKey string, composeStart time.Time) {
+	f.record("complete with %s and %s", mp4Key, webmKey)
+}
+
+// runJobSteps runs a job from its script to completion against f
+func runJobSteps(f *fakeJobSteps, job *domain.Job) {
+	runJobStepsWithETA(f, job, nil)
+}
+
+// runJobStepsWithETA runs a job like runJobSteps, estimating its ETA with eta
+func runJobStepsWithETA(f *fakeJobSteps, job *domain.Job, eta *jobeta.Estimator) {
+	p := &jobPipeline{scripts: f, videos: f, tts: f, music: f, assets: f, composer: f, jobs: f, eta: eta, logger: zap.NewNop()}
+	state := &jobState{job: job}
+	if p.writeScript(context.Background(), state) {
+		p.generate(context.Background(), state)
+	}
+}
+
+func newPipelineJob() *domain.Job {
+	return &domain.Job{JobID: "job-pipeline", Stage: domain.StageQueued, Voice: "alloy", GenerateSFX: true}
+}
+
+func TestJobPipeline_RunsEveryStepInOrder(t *testing.T) {
+	f := &fakeJobSteps{}
+	job := newPipelineJob()
+	runJobSteps(f, job)
+
+	require.Equal(t, []string{
+		"enter script_generating", "checkpoint script_generating", "timeline Writing the script",
+		"enter script_complete", "save script_complete", "timeline Script written with 2 scenes",
+		"enter scene_1_generating", "checkpoint scene_1_generating", "timeline Generating scene 1 of 2",
+		`generate scene 1 from ""`, "thumbnail from clip-1.mp4",
+		"enter scene_1_complete", "save scene_1_complete", "timeline Scene 1 generated",
+		"enter scene_2_generating", "checkpoint scene_2_generating", "timeline Generating scene 2 of 2",
+		`generate scene 2 from "frame-1.jpg"`,
+		"enter scene_2_complete", "save scene_2_complete", "timeline Scene 2 generated",
+		"enter audio_generating", "checkpoint audio_generating", "timeline Generating music and narration",
+		"enter audio_complete", "save audio_complete", "timeline Audio ready",
+		"enter composing", "checkpoint composing", "timeline Composing the final video",
+		"compose 2 clips",
+		"scrub preview of final.mp4",
+		"complete with final.mp4 and final.webm",
+	}, f.recorded())
+
+	require.Equal(t, 2, job.ScenesCompleted)
+	require.Equal(t, []string{"clip-1.mp4", "clip-2.mp4"}, job.SceneVideoURLs)
+	require.Equal(t, map[int]int{1: 1, 2: 1}, job.SceneVersions)
+	require.Equal(t, "clip-2.mp4", job.ClipVersions["scene-2-v1"])
+	require.Equal(t, "thumbnail.jpg", job.ThumbnailURL)
+	require.Equal(t, 16.0, f.narratedFor, "narration is fitted to the generated clips")
+	require.Equal(t, "narration.mp3", job.NarratorAudioURL)
+	require.Equal(t, "music.mp3", job.AudioURL)
+	require.Len(t, job.SFX, 1)
+}
+
+func TestJobPipeline_EstimatesETAAsStagesChange(t *testing.T) {
+	eta := jobeta.NewEstimator()
+	timings := map[string]float64{"script": 10, "narrator": 20, "scene": 100, "music": 30, "composition": 40}
+	for kind, seconds := range timings {
+		eta.Load(map[string]domain.StageTiming{jobeta.Key(kind, "", 0): {Seconds: seconds, Samples: 1}})
+	}
+	f := &fakeJobSteps{}
+	job := newPipelineJob()
+	job.Status = domain.StatusProcessing
+	runJobStepsWithETA(f, job, eta)
+
+	// Each stage is estimated as it is entered: what it and the stages after it are expected to take
+	require.Equal(t, []int{
+		10 + 100 + 30 + 40, // script_generating: one scene is counted until the script says how many
+		2*100 + 30 + 40,    // script_complete
+		2*100 + 30 + 40,    // scene_1_generating
+		100 + 30 + 40,      // scene_1_complete
+		100 + 30 + 40,      // scene_2_generating
+		30 + 40,            // scene_2_complete
+		30 + 40,            // audio_generating: narrator and music together take as long as the longer
+		40,                 // audio_complete
+		40,                 // composing
+	}, f.etas)
+	require.NotZero(t, job.ETAUpdatedAt)
+}
+
+func TestJobPipeline_FailsAtEachStage(t *testing.T) {
+	providerErr := errors.New("provider unavailable")
+	tests := []struct {
+		name     string
+		setup    func(f *fakeJobSteps)
+		wantTail []string // The last events recorded
+	}{
+		{
+			name:     "script",
+			setup:    func(f *fakeJobSteps) { f.scriptErr = providerErr },
+			wantTail: []string{"timeline Writing the script", "fail at script_generating: " + scriptFailureMessage},
+		},
+		{
+			name:     "script compliance keeps the script",
+			setup:    func(f *fakeJobSteps) { f.complianceErr = fmt.Errorf("%w: missing fair balance", errNonCompliant) },
+			wantTail: []string{"save script_generating", "fail at script_generating: " + complianceFailureMessage},
+		},
+		{
+			name:     "second scene",
+			setup:    func(f *fakeJobSteps) { f.sceneErrs = map[int]error{2: providerErr} },
+			wantTail: []string{`generate scene 2 from "frame-1.jpg"`, "fail at scene_2_generating: " + fmt.Sprintf(sceneFailureMessageFormat, 2)},
+		},
+		{
+			name: "scene clip rejected by the quality check",
+			setup: func(f *fakeJobSteps) {
+				f.sceneErrs = map[int]error{1: fmt.Errorf("video processing failed: %w", &composition.ClipQualityError{Reason: "black"})}
+			},
+			wantTail: []string{`generate scene 1 from ""`, "fail at scene_1_generating: " + fmt.Sprintf(clipQualityMessageFormat, 1)},
+		},
+		{
+			name:     "narrator",
+			setup:    func(f *fakeJobSteps) { f.narration = func() (*narratorOutput, error) { return nil, providerErr } },
+			wantTail: []string{"timeline Generating music and narration", "fail at narrator_generating: " + narratorFailureMessage},
+		},
+		{
+			name: "narrator compliance keeps the issues",
+			setup: func(f *fakeJobSteps) {
+				f.narration = func() (*narratorOutput, error) {
+					return nil, fmt.Errorf("%w: narration drops a side effect", errNonCompliant)
+				}
+			},
+			wantTail: []string{"save audio_generating", "fail at narrator_generating: " + complianceFailureMessage},
+		},
+		{
+			name: "music",
+			setup: func(f *fakeJobSteps) {
+				f.track = func(context.Context) (*musicTrack, error) { return nil, providerErr }
+			},
+			wantTail: []string{"timeline Generating music and narration", "fail at audio_generating: " + audioFailureMessage},
+		},
+		{
+			name:     "music panic",
+			setup:    func(f *fakeJobSteps) { f.track = func(context.Context) (*musicTrack, error) { panic("nil track") } },
+			wantTail: []string{"timeline Generating music and narration", "fail at audio_generating: " + audioFailureMessage},
+		},
+		{
+			name:     "composition",
+			setup:    func(f *fakeJobSteps) { f.composeErr = providerErr },
+			wantTail: []string{"compose 2 clips", "fail at composing: " + compositionFailureMessage},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeJobSteps{}
+			tt.setup(f)
+			runJobSteps(f, newPipelineJob())
+
+			events := f.recorded()
+			require.GreaterOrEqual(t, len(events), len(tt.wantTail))
+			require.Equal(t, tt.wantTail, events[len(events)-len(tt.wantTail):], "nothing runs after the failure")
+		})
+	}
+}
+
+func TestJobPipeline_GoesOnWithoutOptionalAudio(t *testing.T) {
+	f := &fakeJobSteps{
+		track:   func(context.Context) (*musicTrack, error) { return nil, errors.New("music provider unavailable") },
+		effects: func() []domain.SFXClip { panic("sound effect provider returned nothing") },
+	}
+	job := newPipelineJob()
+	job.AllowSilentFallback = true
+	runJobSteps(f, job)
+
+	events := f.recorded()
+	audio := slices.Index(events, "timeline Generating music and narration")
+	require.NotEqual(t, -1, audio)
+	require.Equal(t, []string{
+		"timeline Generating music and narration",
+		"warning Background music generation failed; continuing without music",
+		"enter audio_complete",
+	}, events[audio:audio+3])
+	require.Equal(t, "complete with final.mp4 and final.webm", events[len(events)-1])
+	require.Empty(t, job.AudioURL)
+	require.Equal(t, "narration.mp3", job.NarratorAudioURL)
+	require.Nil(t, job.SFX)
+}
+
+func TestJobPipeline_NarratorFailureCancelsMusic(t *testing.T) {
+	narratorErr := fmt.Errorf("%w: narration drops a side effect", errNonCompliant)
+	var musicErr error
+	f := &fakeJobSteps{
+		narration: func() (*narratorOutput, error) {
+			issues := []domain.ComplianceIssue{{Rule: compliance.RuleSideEffectsVerbatim}}
+			return &narratorOutput{ComplianceIssues: issues}, narratorErr
+		},
+		track: func(ctx context.Context) (*musicTrack, error) {
+			select {
+			case <-ctx.Done():
+				musicErr = context.Cause(ctx)
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return &musicTrack{URL: "music.mp3"}, nil
+			}
+		},
+	}
+	job := newPipelineJob()
+	runJobSteps(f, job)
+
+	require.ErrorIs(t, musicErr, narratorErr, "the music is canceled with the narrator's failure")
+	require.Equal(t, "fail at narrator_generating: "+complianceFailureMessage, f.recorded()[len(f.recorded())-1])
+	require.Equal(t, []string{compliance.RuleSideEffectsVerbatim}, issueRules(job.ComplianceIssues), "the failed narration's issues are kept")
+	require.Empty(t, job.AudioURL)
+}
+
+func TestJobPipeline_GeneratesAudioTracksTogether(t *testing.T) {
+	// Each track waits until the other has started and the job is in audio_generating, so the
+	// pipeline only completes if it runs them together and doesn't wait on either to enter it
+	audioStage := make(chan struct{})
+	narrating := make(chan struct{})
+	scoring := make(chan struct{})
+	await := func(ready <-chan struct{}, what string) error {
+		select {
+		case <-ready:
+			return nil
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("%s didn't happen alongside", what)
+		}
+	}
+
+	f := &fakeJobSteps{
+		onEnter: func(stage domain.JobStage) {
+			if stage == domain.StageAudioGenerating {
+				close(audioStage)
+			}
+		},
+		narration: func() (*narratorOutput, error) {
+			close(narrating)
+			if err := errors.Join(await(scoring, "music"), await(audioStage, "audio_generating")); err != nil {
+				return nil, err
+			}
+			return &narratorOutput{URL: "narration.mp3"}, nil
+		},
+		track: func(context.Context) (*musicTrack, error) {
+			close(scoring)
+			if err := errors.Join(await(narrating, "narration"), await(audioStage, "audio_generating")); err != nil {
+				return nil, err
+			}
+			return &musicTrack{URL: "music.mp3"}, nil
+		},
+	}
+	job := newPipelineJob()
+	runJobSteps(f, job)
+
+	require.Equal(t, "complete with final.mp4 and final.webm", f.recorded()[len(f.recorded())-1])
+	require.Equal(t, "narration.mp3", job.NarratorAudioURL)
+	require.Equal(t, "music.mp3", job.AudioURL)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// PreferencesHandler serves each user's default generation settings
+type PreferencesHandler struct {
+	preferences repository.PreferencesRepository
+	tts         *adapters.TTSRouter // Resolves the provider a preferred voice would be narrated by
+	logger      *zap.Logger
+}
+
+// NewPreferencesHandler creates a new preferences handler
+func NewPreferencesHandler(preferences repository.PreferencesRepository, tts *adapters.TTSRouter, logger *zap.Logger) *PreferencesHandler {
+	return &PreferencesHandler{
+		preferences: preferences,
+		tts:         tts,
+		logger:      logger,
+	}
+}
+
+// PreferencesRequest replaces the user's preferences; omitted or empty fields have none
+type PreferencesRequest struct {
+	AspectRatio    string `json:"aspect_ratio"`    // 16:9, 9:16 or 1:1
+	Model          string `json:"model"`           // veo or kling
+	Style          string `json:"style"`           // cinematic, documentary, energetic, minimal, dramatic, playful
+	Tone           string `json:"tone"`            // premium, friendly, edgy, inspiring, humorous
+	Tempo          string `json:"tempo"`           // slow, medium, fast
+	Platform       string `json:"platform"`        // instagram, tiktok, youtube, facebook
+	Voice          string `json:"voice"`           // male, female, or an ElevenLabs voice ID when ElevenLabs narrates
+	TTSProvider    string `json:"tts_provider"`    // openai or elevenlabs
+	ContinuityMode string `json:"continuity_mode"` // frame, style or none
+}
+
+// GetPreferences handles GET /api/v1/preferences
+// @Summary Get generation preferences
+// @Description Get the user's default generation settings, which fill the fields a POST /generate request leaves out. Settings without a preference are omitted.
+// @Tags preferences
+// @Produce json
+// @Success 200 {object} domain.Preferences
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/preferences [get]
+// @Security BearerAuth
+func (h *PreferencesHandler) GetPreferences(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	prefs, err := h.preferences.GetPreferences(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	if prefs == nil {
+		prefs = &domain.Preferences{}
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// PutPreferences handles PUT /api/v1/preferences
+// @Summary Replace generation preferences
+// @Description Replace the user's default generation settings. Each is validated like the POST /generate field of the same name; an omitted or empty field clears its preference.
+// @Tags preferences
+// @Accept json
+// @Produce json
+// @Param request body PreferencesRequest true "Default settings"
+// @Success 200 {object} domain.Preferences
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/preferences [put]
+// @Security BearerAuth
+func (h *PreferencesHandler) PutPreferences(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	var req PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+	prefs := &domain.Preferences{
+		UserID:         userID,
+		AspectRatio:    strings.TrimSpace(req.AspectRatio),
+		Model:          strings.TrimSpace(req.Model),
+		Style:          strings.TrimSpace(req.Style),
+		Tone:           strings.TrimSpace(req.Tone),
+		Tempo:          strings.TrimSpace(req.Tempo),
+		Platform:       strings.TrimSpace(req.Platform),
+		Voice:          strings.TrimSpace(req.Voice),
+		TTSProvider:    strings.TrimSpace(req.TTSProvider),
+		ContinuityMode: strings.TrimSpace(req.ContinuityMode),
+		UpdatedAt:      time.Now().Unix(),
+	}
+	_, narrator := h.tts.Resolve(prefs.TTSProvider)
+	if errs := validation.ValidatePreferences(validation.PreferencesInput{
+		AspectRatio:      prefs.AspectRatio,
+		Model:            prefs.Model,
+		Style:            prefs.Style,
+		Tone:             prefs.Tone,
+		Tempo:            prefs.Tempo,
+		Platform:         prefs.Platform,
+		Voice:            prefs.Voice,
+		TTSProvider:      prefs.TTSProvider,
+		NarratorProvider: string(narrator),
+		ContinuityMode:   prefs.ContinuityMode,
+	}); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	if err := h.preferences.PutPreferences(c.Request.Context(), prefs); err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	h.logger.Info("Preferences updated", zap.String("user_id", userID))
+	c.JSON(http.StatusOK, prefs)
+}
+
+// applyPreferences fills the settings req leaves out from prefs and lists the JSON fields it
+// filled. explicit holds the fields the request body set: a field set, even to "", keeps the
+// request's value, so sending "" opts out of a preference and gets the system default. The
+// narrator voice is only filled into pharmaceutical ads (with side effects), together with its
+// TTS provider, and only when the request names neither.
+func applyPreferences(req GenerateRequest, explicit map[string]bool, prefs *domain.Preferences) (GenerateRequest, []string) {
+	if prefs == nil {
+		return req, nil
+	}
+	var applied []string
+	fill := func(field string, value *string, preferred string) {
+		if explicit[field] || *value != "" || preferred == "" {
+			return
+		}
+		*value = preferred
+		applied = append(applied, field)
+	}
+	fill("aspect_ratio", &req.AspectRatio, prefs.AspectRatio)
+	fill("model", &req.Model, prefs.Model)
+	fill("style", &req.Style, prefs.Style)
+	fill("tone", &req.Tone, prefs.Tone)
+	fill("tempo", &req.Tempo, prefs.Tempo)
+	fill("platform", &req.Platform, prefs.Platform)
+	fill("continuity_mode", &req.ContinuityMode, prefs.ContinuityMode)
+
+	namesNarrator := explicit["voice"] || explicit["tts_provider"] || req.Voice != "" || req.TTSProvider != ""
+	if strings.TrimSpace(req.SideEffects) != "" && !namesNarrator {
+		fill("voice", &req.Voice, prefs.Voice)
+		fill("tts_provider", &req.TTSProvider, prefs.TTSProvider)
+	}
+	return req, applied
+}
+
+// explicitFields lists the top-level fields of a JSON object body, including those set to ""
+func explicitFields(body []byte) map[string]bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+	explicit := make(map[string]bool, len(fields))
+	for field := range fields {
+		explicit[field] = true
+	}
+	return explicit
+}
+
+// bindGenerateRequest binds a POST /generate body into req and returns the fields it set. It
+// writes the error response and returns false when the body is malformed.
+func (h *GenerateHandler) bindGenerateRequest(c *gin.Context, req *GenerateRequest) (map[string]bool, bool) {
+	body, err := c.GetRawData()
+	if err == nil {
+		err = binding.JSON.BindBody(body, req)
+	}
+	if err != nil {
+		h.logger.Error("Invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return nil, false
+	}
+	return explicitFields(body), true
+}
+
+// applyUserPreferences fills the settings req leaves out from the user's preferences, returning
+// the fields filled. It writes the error response and returns false when they can't be read.
+func (h *GenerateHandler) applyUserPreferences(c *gin.Context, req *GenerateRequest, explicit map[string]bool) ([]string, bool) {
+	userID, ok := auth.GetUserID(c)
+	if h.preferences == nil || !ok {
+		return nil, true
+	}
+	prefs, err := h.preferences.GetPreferences(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return nil, false
+	}
+	var applied []string
+	*req, applied = applyPreferences(*req, explicit, prefs)
+	return applied, true
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestApplyPreferences(t *testing.T) {
+	prefs := &domain.Preferences{
+		AspectRatio: "9:16",
+		Style:       "minimal",
+		Tone:        "edgy",
+		Voice:       "female",
+		TTSProvider: "openai",
+	}
+	tests := []struct {
+		name        string
+		req         GenerateRequest
+		explicit    map[string]bool
+		prefs       *domain.Preferences
+		want        GenerateRequest
+		wantApplied []string
+	}{
+		{
+			name:  "no preferences",
+			req:   GenerateRequest{Prompt: "ad"},
+			prefs: nil,
+			want:  GenerateRequest{Prompt: "ad"},
+		},
+		{
+			name:        "partial document fills only its settings",
+			req:         GenerateRequest{Prompt: "ad"},
+			prefs:       &domain.Preferences{Tempo: "fast"},
+			want:        GenerateRequest{Prompt: "ad", Tempo: "fast"},
+			wantApplied: []string{"tempo"},
+		},
+		{
+			name:        "request values win",
+			req:         GenerateRequest{Prompt: "ad", AspectRatio: "1:1", Tone: "friendly"},
+			explicit:    map[string]bool{"prompt": true, "aspect_ratio": true, "tone": true},
+			prefs:       prefs,
+			want:        GenerateRequest{Prompt: "ad", AspectRatio: "1:1", Style: "minimal", Tone: "friendly"},
+			wantApplied: []string{"style"},
+		},
+		{
+			name:        "explicit empty string opts out",
+			req:         GenerateRequest{Prompt: "ad"},
+			explicit:    map[string]bool{"prompt": true, "aspect_ratio": true, "style": true},
+			prefs:       prefs,
+			want:        GenerateRequest{Prompt: "ad", Tone: "edgy"},
+			wantApplied: []string{"tone"},
+		},
+		{
+			name:        "narrator for a pharmaceutical ad",
+			req:         GenerateRequest{Prompt: "ad", SideEffects: "May cause drowsiness."},
+			prefs:       prefs,
+			want:        GenerateRequest{Prompt: "ad", SideEffects: "May cause drowsiness.", AspectRatio: "9:16", Style: "minimal", Tone: "edgy", Voice: "female", TTSProvider: "openai"},
+			wantApplied: []string{"aspect_ratio", "style", "tone", "voice", "tts_provider"},
+		},
+		{
+			name:        "narrator skipped without side effects",
+			req:         GenerateRequest{Prompt: "ad"},
+			prefs:       &domain.Preferences{Voice: "female", TTSProvider: "openai"},
+			want:        GenerateRequest{Prompt: "ad"},
+			wantApplied: nil,
+		},
+		{
+			name:        "narrator skipped when the request names a provider",
+			req:         GenerateRequest{Prompt: "ad", SideEffects: "Nausea.", TTSProvider: "elevenlabs"},
+			explicit:    map[string]bool{"tts_provider": true},
+			prefs:       &domain.Preferences{Voice: "female", TTSProvider: "openai"},
+			want:        GenerateRequest{Prompt: "ad", SideEffects: "Nausea.", TTSProvider: "elevenlabs"},
+			wantApplied: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, applied := applyPreferences(tt.req, tt.explicit, tt.prefs)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantApplied, applied)
+		})
+	}
+}
+
+func TestExplicitFields(t *testing.T) {
+	require.Equal(t, map[string]bool{"prompt": true, "style": true, "tone": true},
+		explicitFields([]byte(`{"prompt":"ad","style":"","tone":null}`)))
+	require.Nil(t, explicitFields([]byte(`[1,2]`)))
+}
+
+// preferencesTestRouter serves the preferences routes and POST /generate as the user in the
+// X-Test-User header. user-123 has an active job with a limit of one, so new jobs are queued.
+func preferencesTestRouter(t *testing.T) (*gin.Engine, *repository.DynamoDBRepository) {
+	dynamo := repository.NewLocalDynamoDB()
+	jobRepo := dynamo.JobRepository("jobs", zap.NewNop())
+	prefsRepo := dynamo.PreferencesRepository("preferences", zap.NewNop())
+
+	now := time.Now().Unix()
+	require.NoError(t, jobRepo.CreateJob(context.Background(), &domain.Job{
+		JobID:     "job-active",
+		UserID:    "user-123",
+		Status:    domain.StatusProcessing,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	generate := NewGenerateHandler(GenerateHandlerDeps{
+		JobRepo:              jobRepo,
+		PreferencesRepo:      prefsRepo,
+		MaxActiveJobsPerUser: 1,
+	}, zap.NewNop())
+	preferences := NewPreferencesHandler(prefsRepo, nil, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, c.GetHeader("X-Test-User"))
+	})
+	v1.GET("/preferences", preferences.GetPreferences)
+	v1.PUT("/preferences", preferences.PutPreferences)
+	v1.POST("/generate", generate.Generate)
+	return router, jobRepo
+}
+
+func TestPreferences_GetAndPut(t *testing.T) {
+	router, _ := preferencesTestRouter(t)
+
+	w := serveAsUser(router, http.MethodGet, "/api/v1/preferences", "user-123", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{}`, w.Body.String())
+
+	w = serveAsUser(router, http.MethodPut, "/api/v1/preferences", "user-123", PreferencesRequest{AspectRatio: " 9:16 ", Style: "minimal"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveAsUser(router, http.MethodGet, "/api/v1/preferences", "user-123", nil)
+	var prefs domain.Preferences
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prefs))
+	require.Equal(t, "9:16", prefs.AspectRatio)
+	require.Equal(t, "minimal", prefs.Style)
+	require.NotZero(t, prefs.UpdatedAt)
+
+	// Each user sees only their own
+	w = serveAsUser(router, http.MethodGet, "/api/v1/preferences", "user-456", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{}`, w.Body.String())
+	w = serveAsUser(router, http.MethodPut, "/api/v1/preferences", "user-456", PreferencesRequest{Tone: "edgy"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serveAsUser(router, http.MethodGet, "/api/v1/preferences", "user-123", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prefs))
+	require.Equal(t, "minimal", prefs.Style)
+	require.Empty(t, prefs.Tone)
+}
+
+func TestPreferences_PutRejectsInvalidSettings(t *testing.T) {
+	router, _ := preferencesTestRouter(t)
+
+	w := serveAsUser(router, http.MethodPut, "/api/v1/preferences", "user-123", PreferencesRequest{AspectRatio: "4:3", Voice: "robot"})
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "aspect_ratio")
+	require.Contains(t, w.Body.String(), "voice")
+
+	w = serveAsUser(router, http.MethodGet, "/api/v1/preferences", "user-123", nil)
+	require.JSONEq(t, `{}`, w.Body.String(), "nothing saved")
+}
+
+func TestPreferences_PutChecksVoiceIDsAgainstTheNarratingProvider(t *testing.T) {
+	tts := adapters.NewMockTTSAdapter(nil, 0, zap.NewNop())
+	put := func(defaultProvider adapters.TTSProvider, configured map[adapters.TTSProvider]adapters.TTSAdapter) int {
+		prefsRepo := repository.NewLocalDynamoDB().PreferencesRepository("preferences", zap.NewNop())
+		handler := NewPreferencesHandler(prefsRepo, adapters.NewTTSRouter(defaultProvider, configured, zap.NewNop()), zap.NewNop())
+		router := gin.New()
+		router.PUT("/api/v1/preferences", func(c *gin.Context) { c.Set(auth.UserIDKey, c.GetHeader("X-Test-User")) }, handler.PutPreferences)
+		return serveAsUser(router, http.MethodPut, "/api/v1/preferences", "user-123", PreferencesRequest{Voice: "21m00Tcm4TlvDq8ikWAM"}).Code
+	}
+
+	both := map[adapters.TTSProvider]adapters.TTSAdapter{adapters.TTSProviderOpenAI: tts, adapters.TTSProviderElevenLabs: tts}
+	require.Equal(t, http.StatusOK, put(adapters.TTSProviderElevenLabs, both), "ElevenLabs is the server default")
+	require.Equal(t, http.StatusUnprocessableEntity, put(adapters.TTSProviderOpenAI, both))
+	require.Equal(t, http.StatusUnprocessableEntity,
+		put(adapters.TTSProviderElevenLabs, map[adapters.TTSProvider]adapters.TTSAdapter{adapters.TTSProviderOpenAI: tts}),
+		"ElevenLabs isn't configured, so OpenAI would narrate")
+}
+
+func TestGenerate_FillsPreferencesAndRecordsThem(t *testing.T) {
+	router, jobRepo := preferencesTestRouter(t)
+	w := serveAsUser(router, http.MethodPut, "/api/v1/preferences", "user-123", PreferencesRequest{AspectRatio: "9:16", Style: "minimal", Tone: "edgy"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveAsUser(router, http.MethodPost, "/api/v1/generate", "user-123", map[string]interface{}{
+		"prompt":   "A cold brew coffee ad for busy mornings",
+		"duration": 16,
+		"tone":     "",
+	})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response GenerateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	job, err := jobRepo.GetJob(context.Background(), response.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusQueued, job.Status)
+	require.Equal(t, "9:16", job.AspectRatio)
+	require.Equal(t, "minimal", job.Style)
+	require.NotEqual(t, "edgy", job.Tone, "the request opted out of the tone preference")
+	require.Equal(t, []string{"aspect_ratio", "style"}, job.PreferencesApplied)
+}
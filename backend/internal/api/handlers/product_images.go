@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+)
+
+// MinProductImageSide is the smallest shorter side (pixels) a product photo may have. Smaller
+// images come out visibly soft once the video model upscales them to 720p.
+const MinProductImageSide = 512
+
+// checkProductImages resolves every product image to one of the user's S3 keys and checks it
+// is a PNG or JPEG large enough to start a clip from. It returns the images with their keys
+// and refs ("image_1", ...) filled in, or every problem found.
+func checkProductImages(
+	ctx context.Context,
+	storage objectOpener,
+	bucket string,
+	locator *repository.AssetLocator,
+	userID string,
+	images []domain.ProductImage,
+) ([]domain.ProductImage, validation.Errors) {
+	var errs validation.Errors
+	resolved := make([]domain.ProductImage, 0, len(images))
+
+	for i, img := range images {
+		field := fmt.Sprintf("product_images[%d].asset", i)
+		key, ok := ownedAssetKey(locator, userID, img.Asset)
+		if !ok {
+			errs.Add(field, "Product image must be an asset you uploaded")
+			continue
+		}
+		if message := inspectProductImage(ctx, storage, bucket, key); message != "" {
+			errs.Add(field, message)
+			continue
+		}
+		resolved = append(resolved, domain.ProductImage{
+			Asset: key,
+			Hint:  strings.TrimSpace(img.Hint),
+			Ref:   fmt.Sprintf("image_%d", i+1),
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return resolved, nil
+}
+
+// inspectProductImage returns why the object at key can't be used as a product image, or ""
+func inspectProductImage(ctx context.Context, storage objectOpener, bucket, key string) string {
+	body, err := storage.OpenObject(ctx, bucket, key)
+	if err != nil {
+		return "Product image asset not found"
+	}
+	defer body.Close()
+
+	// Only the header is read
+	config, format, err := image.DecodeConfig(body)
+	if err != nil || (format != "png" && format != "jpeg") {
+		return "Product image must be a PNG or JPEG image"
+	}
+	if min(config.Width, config.Height) < MinProductImageSide {
+		return fmt.Sprintf("Product image must be at least %dpx on its shortest side (got %dx%d)",
+			MinProductImageSide, config.Width, config.Height)
+	}
+	return ""
+}
+
+// productImageForScene returns the product image GPT-4o assigned to the scene, if any
+func productImageForScene(job *domain.Job, scene domain.Scene) (domain.ProductImage, bool) {
+	if scene.AssignedImageRef == "" {
+		return domain.ProductImage{}, false
+	}
+	for _, img := range job.ProductImages {
+		if img.Ref == scene.AssignedImageRef {
+			return img, true
+		}
+	}
+	return domain.ProductImage{}, false
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeProductImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if format == "png" {
+		require.NoError(t, png.Encode(&buf, img))
+	} else {
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	}
+	return buf.Bytes()
+}
+
+func TestCheckProductImages(t *testing.T) {
+	store := fakeObjectStore{
+		"users/u1/uploads/bottle.png": encodeProductImage(t, "png", 1024, 768),
+		"users/u1/uploads/box.jpg":    encodeProductImage(t, "jpeg", 512, 900),
+		"users/u1/uploads/thumb.jpg":  encodeProductImage(t, "jpeg", 300, 200),
+		"users/u1/uploads/spin.gif":   encodeTestLogo(t, "gif", 1),
+		"users/u2/uploads/bottle.png": encodeProductImage(t, "png", 1024, 768),
+	}
+
+	images, errs := checkProductImages(context.Background(), store, "assets", nil, "u1", []domain.ProductImage{
+		{Asset: "/users/u1/uploads/bottle.png", Hint: " bottle on a counter "},
+		{Asset: "https://assets.s3.amazonaws.com/users/u1/uploads/box.jpg"},
+	})
+	require.Empty(t, errs)
+	require.Equal(t, []domain.ProductImage{
+		{Asset: "users/u1/uploads/bottle.png", Hint: "bottle on a counter", Ref: "image_1"},
+		{Asset: "users/u1/uploads/box.jpg", Ref: "image_2"},
+	}, images)
+
+	images, errs = checkProductImages(context.Background(), store, "assets", nil, "u1", []domain.ProductImage{
+		{Asset: "users/u1/uploads/bottle.png"},
+		{Asset: "users/u1/uploads/thumb.jpg"},
+		{Asset: "users/u1/uploads/spin.gif"},
+		{Asset: "users/u2/uploads/bottle.png"},
+		{Asset: "users/u1/uploads/missing.png"},
+	})
+	require.Nil(t, images)
+	require.Len(t, errs, 4, "every bad image is reported")
+	require.Equal(t, "product_images[1].asset", errs[0].Field)
+	require.Equal(t, "Product image must be at least 512px on its shortest side (got 300x200)", errs[0].Message)
+	require.Equal(t, "Product image must be a PNG or JPEG image", errs[1].Message)
+	require.Equal(t, "Product image must be an asset you uploaded", errs[2].Message)
+	require.Equal(t, "product_images[4].asset", errs[3].Field)
+	require.Equal(t, "Product image asset not found", errs[3].Message)
+}
+
+func TestProductImageForScene(t *testing.T) {
+	job := &domain.Job{ProductImages: []domain.ProductImage{
+		{Asset: "users/u1/uploads/bottle.png", Ref: "image_1"},
+		{Asset: "users/u1/uploads/box.jpg", Ref: "image_2"},
+	}}
+
+	img, ok := productImageForScene(job, domain.Scene{AssignedImageRef: "image_2"})
+	require.True(t, ok)
+	require.Equal(t, "users/u1/uploads/box.jpg", img.Asset)
+
+	// Unassigned scenes and refs to images the job doesn't have use the continuity frame
+	_, ok = productImageForScene(job, domain.Scene{})
+	require.False(t, ok)
+	_, ok = productImageForScene(job, domain.Scene{AssignedImageRef: "image_9"})
+	require.False(t, ok)
+}
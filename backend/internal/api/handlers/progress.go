@@ -8,6 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobeta"
+	"github.com/omnigen/backend/internal/jobprogress"
 	"github.com/omnigen/backend/internal/repository"
 	"github.com/omnigen/backend/internal/service"
 	"go.uber.org/zap"
@@ -48,8 +50,16 @@ type ProgressResponse struct {
 	StagesCompleted        []StageInfo     `json:"stages_completed"`
 	StagesPending          []StageInfo     `json:"stages_pending"`
 	EstimatedTimeRemaining int             `json:"estimated_time_remaining"`
+	ETASeconds             int             `json:"eta_seconds,omitempty"`    // Seconds left now by the estimate made at ETAUpdatedAt; absent without one
+	ETAUpdatedAt           int64           `json:"eta_updated_at,omitempty"` // When the pipeline last estimated the job, as it started or finished a step
 	Assets                 *ProgressAssets `json:"assets,omitempty"`
 	ErrorMessage           *string         `json:"error_message,omitempty"` // Detailed error message if job failed
+
+	// How far the current ffmpeg pass has got, while the job is composing
+	CompositionProgress *domain.CompositionProgress `json:"composition_progress,omitempty"`
+
+	// A/B variant parents: each variant's progress; the parent's aggregates them. Asset URLs are on GET /jobs/:id.
+	Variants []VariantSummary `json:"variants,omitempty"`
 }
 
 // StageInfo contains information about a pipeline stage
@@ -101,7 +111,11 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 	ticker := time.NewTicker(SSEPollingInterval)
 	defer ticker.Stop()
 
-	lastStage := ""
+	var lastStage domain.JobStage
+	lastPercent := -1
+	var lastComposition *domain.CompositionProgress
+	var lastETAUpdate int64
+	sentProgress := 0
 	ctx := c.Request.Context()
 
 	for {
@@ -127,9 +141,16 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 				continue
 			}
 
-			// Only send update if stage changed (avoid spam)
-			if job.Stage != lastStage {
+			// Only send update if stage, progress or ETA changed (avoid spam); steps that run in
+			// parallel advance progress without a new stage
+			percent := jobprogress.Percent(job)
+			composition := currentCompositionProgress(job)
+			if job.Stage != lastStage || percent != lastPercent || !sameCompositionProgress(composition, lastComposition) ||
+				job.ETAUpdatedAt != lastETAUpdate {
 				lastStage = job.Stage
+				lastPercent = percent
+				lastComposition = composition
+				lastETAUpdate = job.ETAUpdatedAt
 
 				// Build full progress response
 				response, err := h.buildProgressResponse(job)
@@ -140,6 +161,9 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 					)
 					continue
 				}
+				// Never report less than the client has already been sent
+				response.Progress = jobprogress.Monotonic(sentProgress, response.Progress)
+				sentProgress = response.Progress
 
 				// Marshal to JSON
 				data, err := json.Marshal(response)
@@ -153,7 +177,7 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 
 				h.logger.Debug("Sending SSE progress update",
 					zap.String("job_id", jobID),
-					zap.String("stage", job.Stage),
+					zap.Stringer("stage", job.Stage),
 					zap.Int("progress", response.Progress),
 				)
 
@@ -162,8 +186,9 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 				c.Writer.Flush()
 			}
 
-			// Close stream when job reaches terminal state
-			if job.Status == domain.StatusCompleted || job.Status == domain.StatusFailed {
+			// Close stream when job reaches terminal state (or parks awaiting script approval)
+			if job.Status == domain.StatusCompleted || job.Status == domain.StatusFailed ||
+				job.Status == domain.StatusCanceled || job.Status == domain.StatusScriptReady {
 				h.logger.Info("Job terminal state reached, closing SSE stream",
 					zap.String("job_id", jobID),
 					zap.String("status", job.Status),
@@ -175,6 +200,7 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 					h.logger.Error("Failed to build final progress response", zap.Error(err))
 					c.SSEvent("done", gin.H{"status": job.Status})
 				} else {
+					finalResponse.Progress = jobprogress.Monotonic(sentProgress, finalResponse.Progress)
 					finalData, err := json.Marshal(finalResponse)
 					if err != nil {
 						h.logger.Error("Failed to marshal final progress response", zap.Error(err))
@@ -193,12 +219,16 @@ func (h *ProgressHandler) GetProgress(c *gin.Context) {
 
 // buildProgressResponse constructs a complete ProgressResponse from a job
 func (h *ProgressHandler) buildProgressResponse(job *domain.Job) (*ProgressResponse, error) {
-	// Calculate progress percentage and ETA
-	progress := calculateDynamicProgress(job.Stage, len(job.Scenes))
-	eta := calculateETA(job.Stage, time.Unix(job.CreatedAt, 0), len(job.Scenes))
+	// Calculate progress percentage and ETA, preferring the pipeline's estimate from step timings
+	progress := jobprogress.Percent(job)
+	eta := calculateETA(progress, time.Unix(job.CreatedAt, 0))
+	etaSeconds, hasETA := jobeta.Countdown(job, time.Now())
+	if hasETA {
+		eta = etaSeconds
+	}
 
 	// Generate presigned URLs for all assets
-	assets, err := h.assetService.GetJobAssets(context.Background(), job, 1*time.Hour)
+	assets, err := h.assetService.GetJobAssets(context.Background(), job, AssetURLExpiry)
 	if err != nil {
 		h.logger.Warn("Failed to generate asset URLs",
 			zap.String("job_id", job.JobID),
@@ -253,171 +283,165 @@ func (h *ProgressHandler) buildProgressResponse(job *domain.Job) (*ProgressRespo
 		JobID:                  job.JobID,
 		Status:                 job.Status,
 		Progress:               progress,
-		CurrentStage:           job.Stage,
+		CurrentStage:           job.Stage.String(),
 		CurrentStageDisplay:    formatStageName(job.Stage),
 		StagesCompleted:        buildStagesCompleted(job),
 		StagesPending:          buildStagesPending(job),
 		EstimatedTimeRemaining: eta,
+		ETASeconds:             etaSeconds,
 		Assets:                 progressAssets,
 		ErrorMessage:           job.ErrorMessage, // Include error message if job failed
+		CompositionProgress:    currentCompositionProgress(job),
+	}
+
+	if hasETA {
+		response.ETAUpdatedAt = job.ETAUpdatedAt
+	}
+
+	if job.IsVariantParent() {
+		h.addVariants(job, response)
 	}
 
 	return response, nil
 }
 
+// currentCompositionProgress returns the job's last composition report while it is composing;
+// a report left by an earlier run is stale in any other stage
+func currentCompositionProgress(job *domain.Job) *domain.CompositionProgress {
+	if job.Stage != domain.StageComposing {
+		return nil
+	}
+	return job.CompositionProgress
+}
+
+func sameCompositionProgress(a, b *domain.CompositionProgress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// addVariants lists a parent's variants on its progress, with the progress aggregated from them
+func (h *ProgressHandler) addVariants(job *domain.Job, response *ProgressResponse) {
+	ctx := context.Background()
+	variants, err := h.jobRepo.ListVariantJobs(ctx, job.JobID)
+	if err != nil {
+		h.logger.Warn("Failed to list variant jobs", zap.String("job_id", job.JobID), zap.Error(err))
+		return
+	}
+	response.Variants = buildVariantSummaries(ctx, variants, nil, 0)
+	if summary, ok := summarizeVariants(variants); ok && !isTerminalStatus(job.Status) {
+		response.Progress = summary.Progress
+	}
+}
+
 // formatStageName converts internal stage names to user-friendly display names
-func formatStageName(stage string) string {
-	switch stage {
-	case "script_generating":
+func formatStageName(stage domain.JobStage) string {
+	switch stage.Phase {
+	case domain.PhaseQueued:
+		return "Waiting for your other videos to finish"
+	case domain.PhaseScriptGenerating:
 		return "Generating script with AI"
-	case "script_complete":
+	case domain.PhaseScriptComplete:
 		return "Script ready"
-	case "narrator_generating":
+	case domain.PhaseScriptReady:
+		return "Script ready for approval"
+	case domain.PhaseAwaitingScript:
+		return "Writing the variant scripts"
+	case domain.PhaseVariantsGenerating:
+		return "Generating variants"
+	case domain.PhaseVariantsFinished:
+		return fmt.Sprintf("%d of %d variants finished", stage.Finished, stage.Total)
+	case domain.PhaseSceneGenerating:
+		return fmt.Sprintf("Generating scene %d", stage.Scene)
+	case domain.PhaseSceneComplete:
+		return fmt.Sprintf("Scene %d ready", stage.Scene)
+	case domain.PhaseNarratorGenerating:
 		return "Generating narrator voiceover"
-	case "narrator_complete":
+	case domain.PhaseNarratorComplete:
 		return "Narrator audio ready"
-	case "audio_generating":
+	case domain.PhaseAudioGenerating:
 		return "Generating background music"
-	case "audio_complete":
+	case domain.PhaseAudioComplete:
 		return "Audio ready"
-	case "composing":
+	case domain.PhaseComposing:
 		return "Composing final video"
-	case "complete":
+	case domain.PhaseComplete:
 		return "Complete"
-	case "failed":
+	case domain.PhaseFailed:
 		return "Failed"
+	case domain.PhaseCanceled:
+		return "Canceled"
 	default:
-		// Handle scene stages (scene_N_generating, scene_N_complete)
-		if len(stage) > 6 && stage[:6] == "scene_" {
-			var sceneNum int
-			var suffix string
-			_, err := fmt.Sscanf(stage, "scene_%d_%s", &sceneNum, &suffix)
-			if err == nil {
-				switch suffix {
-				case "generating":
-					return fmt.Sprintf("Generating scene %d", sceneNum)
-				case "complete":
-					return fmt.Sprintf("Scene %d ready", sceneNum)
-				}
-			}
-		}
-		return stage
+		return stage.String()
 	}
 }
 
-// buildStagesCompleted builds list of completed stages based on current stage
+// buildStagesCompleted lists the job's completed checklist steps
 func buildStagesCompleted(job *domain.Job) []StageInfo {
 	stages := make([]StageInfo, 0)
-	currentStage := job.Stage
-
-	// Script generation
-	if currentStage != "script_generating" {
-		stages = append(stages, StageInfo{
-			Name:        "script_complete",
-			DisplayName: "Script ready",
-			Progress:    5,
-			CompletedAt: nil, // Could track completion times if needed
-		})
-	}
-
-	// Narrator generation
-	if currentStage != "script_generating" &&
-		currentStage != "script_complete" &&
-		currentStage != "narrator_generating" {
-		stages = append(stages, StageInfo{
-			Name:        "narrator_complete",
-			DisplayName: "Narrator audio ready",
-			Progress:    8,
-		})
-	}
-
-	// Scenes
-	for i := 0; i < job.ScenesCompleted; i++ {
-		stageName := fmt.Sprintf("scene_%d_complete", i+1)
-		progress := calculateDynamicProgress(stageName, len(job.Scenes))
-		stages = append(stages, StageInfo{
-			Name:        stageName,
-			DisplayName: fmt.Sprintf("Scene %d ready", i+1),
-			Progress:    progress,
-		})
-	}
-
-	// Audio (only if stage is past audio)
-	if currentStage == "audio_complete" || currentStage == "composing" || currentStage == "complete" {
-		stages = append(stages, StageInfo{
-			Name:        "audio_complete",
-			DisplayName: "Audio ready",
-			Progress:    85,
-		})
-	}
-
-	// Composition (only if complete)
-	if currentStage == "complete" {
-		stages = append(stages, StageInfo{
-			Name:        "complete",
-			DisplayName: "Complete",
-			Progress:    100,
-			CompletedAt: job.CompletedAt,
-		})
+	for _, step := range jobprogress.Checklist(job) {
+		if !step.Done {
+			continue
+		}
+		name := completedStageName(step)
+		info := StageInfo{Name: name.String(), DisplayName: formatStageName(name), Progress: step.Progress}
+		if step.Kind == jobprogress.StepComposition {
+			info.CompletedAt = job.CompletedAt
+		}
+		stages = append(stages, info)
 	}
-
 	return stages
 }
 
-// buildStagesPending builds list of pending stages based on current stage
+// buildStagesPending lists the job's checklist steps still to finish
 func buildStagesPending(job *domain.Job) []StageInfo {
 	stages := make([]StageInfo, 0)
-	currentStage := job.Stage
-	totalScenes := len(job.Scenes)
-
-	// Narrator (if not yet complete)
-	if currentStage == "script_generating" ||
-		currentStage == "script_complete" ||
-		currentStage == "narrator_generating" {
-		stages = append(stages, StageInfo{
-			Name:        "narrator_generating",
-			DisplayName: "Generating narrator voiceover",
-			Progress:    6,
-		})
-	}
-
-	// Scenes
-	for i := job.ScenesCompleted; i < totalScenes; i++ {
-		stageName := fmt.Sprintf("scene_%d_generating", i+1)
-		progress := calculateDynamicProgress(stageName, totalScenes)
-		stages = append(stages, StageInfo{
-			Name:        stageName,
-			DisplayName: fmt.Sprintf("Generating scene %d", i+1),
-			Progress:    progress,
-		})
+	for _, step := range jobprogress.Checklist(job) {
+		// Scenes are listed once the script says how many there are
+		if step.Done || (step.Kind == jobprogress.StepScene && step.SceneNumber == 0) {
+			continue
+		}
+		name := pendingStageName(step)
+		stages = append(stages, StageInfo{Name: name.String(), DisplayName: formatStageName(name), Progress: step.Progress})
 	}
+	return stages
+}
 
-	// Audio (if not yet started)
-	if currentStage != "audio_generating" && currentStage != "audio_complete" &&
-		currentStage != "composing" && currentStage != "complete" {
-		stages = append(stages, StageInfo{
-			Name:        "audio_generating",
-			DisplayName: "Generating background music",
-			Progress:    82,
-		})
+// completedStageName is the stage the pipeline reports on finishing a step
+func completedStageName(step jobprogress.Step) domain.JobStage {
+	switch step.Kind {
+	case jobprogress.StepScript:
+		return domain.StageScriptComplete
+	case jobprogress.StepNarrator:
+		return domain.StageNarratorComplete
+	case jobprogress.StepScene:
+		return domain.SceneComplete(step.SceneNumber)
+	case jobprogress.StepMusic:
+		return domain.StageAudioComplete
+	default:
+		return domain.StageComplete
 	}
+}
 
-	// Composition (if not yet started)
-	if currentStage != "composing" && currentStage != "complete" {
-		stages = append(stages, StageInfo{
-			Name:        "composing",
-			DisplayName: "Composing final video",
-			Progress:    92,
-		})
+// pendingStageName is the stage the pipeline reports while working on a step
+func pendingStageName(step jobprogress.Step) domain.JobStage {
+	switch step.Kind {
+	case jobprogress.StepScript:
+		return domain.StageScriptGenerating
+	case jobprogress.StepNarrator:
+		return domain.StageNarratorGenerating
+	case jobprogress.StepScene:
+		return domain.SceneGenerating(step.SceneNumber)
+	case jobprogress.StepMusic:
+		return domain.StageAudioGenerating
+	default:
+		return domain.StageComposing
 	}
-
-	return stages
 }
 
 // calculateETA estimates time remaining based on elapsed time and current progress
-func calculateETA(stage string, startTime time.Time, totalScenes int) int {
-	progress := calculateDynamicProgress(stage, totalScenes)
-
+func calculateETA(progress int, startTime time.Time) int {
 	if progress == 0 || progress >= 100 {
 		return 0
 	}
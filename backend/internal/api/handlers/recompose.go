@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// RecomposeRequest changes the overlays of a recomposed video; omitted fields keep the job's
+type RecomposeRequest struct {
+	SideEffectsText      *string             `json:"side_effects_text,omitempty"`       // Empty removes the overlay (not allowed for pharmaceutical ads)
+	SideEffectsStartTime *float64            `json:"side_effects_start_time,omitempty"` // Seconds; fixed for jobs with a disclaimer spec
+	SideEffectsOverflow  *string             `json:"side_effects_overflow,omitempty"`   // "paginate" or "scroll"
+	BurnCaptions         *bool               `json:"burn_captions,omitempty"`
+	LogoOverlay          *domain.LogoOverlay `json:"logo_overlay,omitempty"` // Must be an uploaded asset
+}
+
+// RecomposeResponse is a job's final video after a recompose
+type RecomposeResponse struct {
+	JobID        string `json:"job_id"`
+	Status       string `json:"status"`
+	VideoKey     string `json:"video_key"`
+	WebMVideoKey string `json:"webm_video_key,omitempty"`
+
+	// Final video before the recompose, kept alongside the new one; the same key when nothing
+	// that changes the video changed
+	PreviousVideoKey string `json:"previous_video_key,omitempty"`
+}
+
+// recomposable reports whether job's final video can be composed again from its stored clips:
+// a completed job, or one that failed composing and so kept its clips and audio
+func recomposable(job *domain.Job) bool {
+	switch job.Status {
+	case domain.StatusCompleted:
+		return true
+	case domain.StatusFailed:
+		return keepsAssetsOnFailure(job.FailureStage)
+	}
+	return false
+}
+
+// input describes req's changes to job for validation
+func (r RecomposeRequest) input(job *domain.Job, videoDuration float64) validation.RecomposeInput {
+	return validation.RecomposeInput{
+		SideEffectsText:      r.SideEffectsText,
+		SideEffectsStartTime: r.SideEffectsStartTime,
+		SideEffectsOverflow:  r.SideEffectsOverflow,
+		BurnCaptions:         r.BurnCaptions,
+		LogoOverlay:          r.LogoOverlay,
+		HasSideEffectsText:   job.SideEffectsText != "",
+		Pharmaceutical:       job.SideEffects != "",
+		DisclaimerTimed:      job.DisclaimerSpec != nil,
+		HasCaptions:          len(job.Captions) > 0,
+		VideoDuration:        videoDuration,
+	}
+}
+
+// apply sets req's changes on job. New side effects text without a start time shows from 80% of
+// the way through, as generation places it; removing the text removes its timing too.
+func (r RecomposeRequest) apply(job *domain.Job, videoDuration float64) {
+	if r.SideEffectsStartTime != nil {
+		job.SideEffectsStartTime = *r.SideEffectsStartTime
+	}
+	if r.SideEffectsText != nil {
+		text := *r.SideEffectsText
+		if job.SideEffectsText == "" && r.SideEffectsStartTime == nil {
+			job.SideEffectsStartTime = videoDuration * 0.8
+		}
+		job.SideEffectsText = text
+		if text == "" {
+			job.SideEffectsStartTime = 0
+		}
+		setDisclaimerOverlayText(job, text)
+	}
+	if r.SideEffectsOverflow != nil {
+		job.SideEffectsOverflow = *r.SideEffectsOverflow
+	}
+	if r.BurnCaptions != nil {
+		job.BurnCaptions = *r.BurnCaptions
+	}
+	if r.LogoOverlay != nil {
+		job.LogoOverlay = r.LogoOverlay
+	}
+}
+
+// setDisclaimerOverlayText replaces the text a job's disclaimer spec shows on screen, which
+// composition uses instead of SideEffectsText: the abbreviated text of a text-only disclaimer,
+// the full text of a narrated one. The narration is untouched.
+func setDisclaimerOverlayText(job *domain.Job, text string) {
+	if job.DisclaimerSpec == nil {
+		return
+	}
+	spec := *job.DisclaimerSpec
+	switch spec.Tier {
+	case domain.DisclaimerTierTextOnly:
+		spec.AudioText = text
+	case domain.DisclaimerTierShort, domain.DisclaimerTierFull:
+		spec.FullText = text
+	}
+	job.DisclaimerSpec = &spec
+}
+
+// missingClips lists the scenes of job without a stored clip
+func missingClips(job *domain.Job) []int {
+	var missing []int
+	for i := range job.Scenes {
+		if i >= len(job.SceneVideoURLs) || job.SceneVideoURLs[i] == "" {
+			missing = append(missing, i+1)
+		}
+	}
+	return missing
+}
+
+// RecomposeJob handles POST /api/v1/jobs/:id/recompose
+// @Summary Compose a job's final video again
+// @Description Reruns only the composition stage from the job's stored clips, narration and music, for a job that failed composing or a completed job whose overlays should change. Side effects text, timing and overflow, caption burn-in and the logo overlay can be changed for the recompose. Scenes and audio are left as they are; a failed job is completed by a successful recompose. The previous final video is kept: final videos are named by what they were composed from, so changed overlays compose a new one.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body RecomposeRequest false "Overlay changes"
+// @Success 200 {object} RecomposeResponse
+// @Failure 400 {object} errors.ErrorResponse "Job is not completed and did not fail composing, or is missing clips"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job was modified during the recompose"
+// @Failure 422 {object} errors.ErrorResponse "Invalid overlay changes"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/recompose [post]
+// @Security BearerAuth
+func (h *RegenerateHandler) RecomposeJob(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx := trace.WithJobID(c.Request.Context(), jobID)
+
+	var req RecomposeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !stderrors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+	for _, field := range []*string{req.SideEffectsText, req.SideEffectsOverflow} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	job, ok := h.ownedJob(c, ctx, jobID)
+	if !ok {
+		return
+	}
+	if !recomposable(job) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("status",
+				"Can only recompose completed jobs and jobs that failed composing; requeue a job that failed earlier"),
+		})
+		return
+	}
+	if missing := missingClips(job); len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("scene_video_urls",
+				fmt.Sprintf("Job has no clip for scenes %v to compose", missing)),
+		})
+		return
+	}
+
+	clips := h.buildClipVideosFromJob(job)
+	videoDuration := composition.ClipTimeline(job, clips) + composition.EndCardDuration(job)
+	if errs := validation.ValidateRecompose(req.input(job, videoDuration)); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+	if req.LogoOverlay != nil {
+		logo, errs := checkLogoOverlay(ctx, h.s3Service, h.assetsBucket, h.locator, job.UserID, req.LogoOverlay, nil)
+		if len(errs) > 0 {
+			respondValidationErrors(c, errs)
+			return
+		}
+		req.LogoOverlay = logo
+	}
+
+	failed := job.Status == domain.StatusFailed
+	h.log(ctx).Info("Recompose requested",
+		zap.String("status", job.Status),
+		zap.Int("clips", len(clips)),
+	)
+	start := time.Now()
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventStage,
+		Stage:   "recomposing",
+		Message: fmt.Sprintf("Recomposing the final video from %d clips", len(clips)),
+	})
+
+	previousVideoKey := job.VideoKey
+	req.apply(job, videoDuration)
+	if failed {
+		// Saved only if the composition succeeds; otherwise the job stays failed and can be retried
+		completedAt := start.Unix()
+		job.Status = domain.StatusCompleted
+		job.Stage = domain.StageComplete
+		job.CompletedAt = &completedAt
+		job.ErrorMessage = nil
+		job.FailureStage = ""
+		job.FailureError = ""
+	}
+
+	ctx = repository.WithAssetLedger(metrics.WithRecorder(ctx, h.metrics), job)
+	if !h.finishRecomposition(c, ctx, job, "recomposing", start) {
+		return
+	}
+	h.log(ctx).Info("Job recomposed",
+		zap.Bool("was_failed", failed),
+		zap.String("video_key", job.VideoKey),
+		zap.String("previous_video_key", previousVideoKey),
+	)
+	c.JSON(http.StatusOK, RecomposeResponse{
+		JobID:            job.JobID,
+		Status:           job.Status,
+		VideoKey:         job.VideoKey,
+		WebMVideoKey:     job.WebMVideoKey,
+		PreviousVideoKey: previousVideoKey,
+	})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recomposeRouter serves POST /jobs/:id/recompose as user-123, with runner standing in for ffmpeg
+func recomposeRouter(h *RegenerateHandler, runner *recordingRunner) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/jobs/:id/recompose", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+		if runner != nil {
+			c.Request = c.Request.WithContext(ffmpegexec.WithRunner(c.Request.Context(), runner))
+		}
+	}, h.RecomposeJob)
+	return router
+}
+
+// composingFailedJob is a two-scene job whose composition failed after its clips and music were stored
+func composingFailedJob() *domain.Job {
+	message := "Video composition failed. Please try again later."
+	return &domain.Job{
+		JobID:        "job-recompose",
+		UserID:       "user-123",
+		Status:       domain.StatusFailed,
+		Stage:        domain.StageComposing,
+		FailureStage: domain.StageComposing.String(),
+		FailureError: "ffmpeg no_space: exit status 1",
+		ErrorMessage: &message,
+		Model:        "veo",
+		AspectRatio:  domain.AspectRatio16x9,
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 4, GenerationPrompt: storedScenePrompt},
+			{SceneNumber: 2, Duration: 4, GenerationPrompt: storedScenePrompt + ", pouring"},
+		},
+		SceneVideoURLs:  []string{"s3://assets/clip-1.mp4", "s3://assets/clip-2.mp4"},
+		ScenesCompleted: 2,
+		CreatedAt:       1000,
+		UpdatedAt:       1000,
+	}
+}
+
+// storeComposingFailedJob uploads the failed job's clips and music and saves it, returning the
+// clips the pipeline had composed
+func storeComposingFailedJob(t *testing.T, repo *repository.DynamoDBRepository, s3Service *repository.S3AssetRepository) (*domain.Job, []composition.ClipVideo) {
+	t.Helper()
+	job := composingFailedJob()
+	clips := []composition.ClipVideo{
+		uploadComposeClip(t, s3Service, job, 1, "clip one"),
+		uploadComposeClip(t, s3Service, job, 2, "clip two"),
+	}
+	job.SceneVideoURLs = []string{clips[0].VideoURL, clips[1].VideoURL}
+
+	path := filepath.Join(t.TempDir(), "music.mp3")
+	require.NoError(t, os.WriteFile(path, []byte("music"), 0o644))
+	url, err := s3Service.UploadFile(context.Background(), "assets", composition.AudioKey(job), path, "audio/mpeg")
+	require.NoError(t, err)
+	job.AudioURL = url
+
+	require.NoError(t, repo.CreateJob(context.Background(), job))
+	return job, clips
+}
+
+func recompose(t *testing.T, router *gin.Engine, jobID, body string) RecomposeResponse {
+	t.Helper()
+	w := postRegenerate(router, "/api/v1/jobs/"+jobID+"/recompose", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp RecomposeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestRecomposeJob_RejectsRequests(t *testing.T) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	ctx := context.Background()
+
+	failedComposing := composingFailedJob()
+	failedComposing.SideEffectsText = "May cause drowsiness."
+	require.NoError(t, repo.CreateJob(ctx, failedComposing))
+
+	failedScene := composingFailedJob()
+	failedScene.JobID, failedScene.Stage, failedScene.FailureStage = "job-scene-failed", domain.SceneGenerating(2), domain.SceneGenerating(2).String()
+	require.NoError(t, repo.CreateJob(ctx, failedScene))
+
+	composing := composingFailedJob()
+	composing.JobID, composing.Status, composing.FailureStage = "job-composing", domain.StatusProcessing, ""
+	require.NoError(t, repo.CreateJob(ctx, composing))
+
+	missingClip := composingFailedJob()
+	missingClip.JobID, missingClip.SceneVideoURLs = "job-missing-clip", missingClip.SceneVideoURLs[:1]
+	require.NoError(t, repo.CreateJob(ctx, missingClip))
+
+	someoneElses := composingFailedJob()
+	someoneElses.JobID, someoneElses.UserID = "job-other", "user-456"
+	require.NoError(t, repo.CreateJob(ctx, someoneElses))
+
+	router := recomposeRouter(NewRegenerateHandler(repo, nil, nil, nil, 0, "", nil, nil, nil, nil, zap.NewNop()), nil)
+
+	tests := []struct {
+		name       string
+		jobID      string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "failed generating a scene", jobID: "job-scene-failed", wantStatus: http.StatusBadRequest, wantField: "status"},
+		{name: "still composing", jobID: "job-composing", wantStatus: http.StatusBadRequest, wantField: "status"},
+		{name: "clip missing", jobID: "job-missing-clip", wantStatus: http.StatusBadRequest, wantField: "scene_video_urls"},
+		{name: "someone else's job", jobID: "job-other", wantStatus: http.StatusNotFound},
+		{name: "malformed body", jobID: "job-recompose", body: `{"side_effects_text": 3}`, wantStatus: http.StatusBadRequest},
+		{name: "side effects after the video", jobID: "job-recompose", body: `{"side_effects_start_time": 8}`, wantStatus: http.StatusUnprocessableEntity, wantField: "side_effects_start_time"},
+		{name: "guideline logo", jobID: "job-recompose", body: `{"logo_overlay": {"use_guideline_logo": true}}`, wantStatus: http.StatusUnprocessableEntity, wantField: "logo_overlay.use_guideline_logo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postRegenerate(router, "/api/v1/jobs/"+tt.jobID+"/recompose", tt.body)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantField == "" {
+				return
+			}
+			var body apierrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			details, _ := json.Marshal(body.Error.Details)
+			require.Contains(t, string(details), `"field":"`+tt.wantField+`"`)
+		})
+	}
+
+	stored, err := repo.GetJob(ctx, "job-recompose")
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusFailed, stored.Status, "rejected recomposes leave the job as it was")
+	require.Zero(t, stored.SideEffectsStartTime)
+}
+
+func TestBuildClipVideosFromJob_FollowsStoredScenes(t *testing.T) {
+	// A trimmed second scene and a reordered timeline: each clip keeps its scene's stored length
+	job := &domain.Job{
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 6},
+			{SceneNumber: 2, Duration: 5.5},
+			{SceneNumber: 3, Duration: 4},
+		},
+		SceneVideoURLs: []string{
+			"s3://assets/users/u/jobs/j/clips/scene-003-v0.mp4",
+			"s3://assets/users/u/jobs/j/clips/scene-001-v1.mp4",
+			"s3://assets/users/u/jobs/j/clips/scene-002-v0.mp4",
+		},
+	}
+
+	clips := (&RegenerateHandler{}).buildClipVideosFromJob(job)
+	require.Equal(t, []composition.ClipVideo{
+		{VideoURL: job.SceneVideoURLs[0], Duration: 6},
+		{VideoURL: job.SceneVideoURLs[1], Duration: 5.5},
+		{VideoURL: job.SceneVideoURLs[2], Duration: 4},
+	}, clips)
+}
+
+func TestRecomposable(t *testing.T) {
+	job := composingFailedJob()
+	require.True(t, recomposable(job))
+
+	job.FailureStage = domain.StageAudioGenerating.String()
+	require.False(t, recomposable(job), "earlier failures kept no assets")
+
+	job.Status = domain.StatusCompleted
+	require.True(t, recomposable(job))
+
+	job.Status = domain.StatusCanceled
+	require.False(t, recomposable(job))
+}
+
+func TestRecomposeJob_CompletesJobThatFailedComposing(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	s3Service := newComposeTestS3(t)
+	job, pipelineClips := storeComposingFailedJob(t, repo, s3Service)
+
+	h := NewRegenerateHandler(repo, s3Service, nil, nil, 0, "assets", nil, nil, nil, nil, zap.NewNop())
+	runner := &recordingRunner{fps: "30", clipDuration: 4, total: 8}
+	h.composer = h.composer.WithRunner(runner)
+
+	resp := recompose(t, recomposeRouter(h, runner), job.JobID, "")
+	require.Equal(t, domain.StatusCompleted, resp.Status)
+	require.Empty(t, resp.PreviousVideoKey)
+
+	// The rebuilt clips are the ones the failed run was composing
+	comp, err := composition.NewPlan(ctx, s3Service, "assets", job, pipelineClips)
+	require.NoError(t, err)
+	require.Equal(t, comp.VideoKey, resp.VideoKey)
+
+	stored, err := repo.GetJob(ctx, job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusCompleted, stored.Status)
+	require.Equal(t, domain.StageComplete, stored.Stage)
+	require.NotNil(t, stored.CompletedAt)
+	require.Nil(t, stored.ErrorMessage)
+	require.Empty(t, stored.FailureStage)
+	require.Empty(t, stored.FailureError)
+	require.Equal(t, resp.VideoKey, stored.VideoKey)
+
+	// Only the final video changed
+	require.Equal(t, job.Scenes, stored.Scenes)
+	require.Equal(t, job.SceneVideoURLs, stored.SceneVideoURLs)
+	require.Equal(t, job.AudioURL, stored.AudioURL)
+	require.Equal(t, 2, stored.ScenesCompleted)
+}
+
+func TestRecomposeJob_AfterCompletionKeepsPreviousVideo(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	s3Service := newComposeTestS3(t)
+	job, _ := storeComposingFailedJob(t, repo, s3Service)
+
+	h := NewRegenerateHandler(repo, s3Service, nil, nil, 0, "assets", nil, nil, nil, nil, zap.NewNop())
+	runner := &recordingRunner{fps: "30", clipDuration: 4, total: 8}
+	h.composer = h.composer.WithRunner(runner)
+	router := recomposeRouter(h, runner)
+
+	first := recompose(t, router, job.JobID, "")
+	runner.calls = nil
+
+	second := recompose(t, router, job.JobID, `{"side_effects_text": "  May cause drowsiness and headache.  "}`)
+	require.Equal(t, first.VideoKey, second.PreviousVideoKey)
+	require.NotEqual(t, first.VideoKey, second.VideoKey, "new overlays compose a new final video")
+	require.Contains(t, runner.outputs(), "video_with_text.mp4")
+
+	// The earlier final video is still stored, under its own key
+	_, err := s3Service.ObjectInfo(ctx, "assets", first.VideoKey)
+	require.NoError(t, err)
+	_, err = s3Service.ObjectInfo(ctx, "assets", second.VideoKey)
+	require.NoError(t, err)
+
+	stored, err := repo.GetJob(ctx, job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, second.VideoKey, stored.VideoKey)
+	require.Equal(t, "May cause drowsiness and headache.", stored.SideEffectsText)
+	require.InDelta(t, 6.4, stored.SideEffectsStartTime, 0.001, "new text starts where generation would place it")
+
+	// The same overlays again reuse the video they composed
+	runner.calls = nil
+	third := recompose(t, router, job.JobID, "")
+	require.Equal(t, second.VideoKey, third.VideoKey)
+	require.Empty(t, runner.calls)
+}
+
+func TestFailJob_KeepsAssetsOfCompositionFailures(t *testing.T) {
+	ctx := context.Background()
+	s3Service := newComposeTestS3(t)
+
+	for _, tt := range []struct {
+		stage    domain.JobStage
+		wantKept bool
+	}{
+		{domain.StageComposing, true},
+		{domain.SceneGenerating(2), false},
+	} {
+		h, _, job := complianceHandler(t, false)
+		h.s3Service, h.assetsBucket = s3Service, "assets"
+		uploadComposeClip(t, s3Service, job, 1, "clip one")
+
+		h.failJob(ctx, job, tt.stage.String(), compositionFailureMessage, errors.New("ffmpeg failed"))
+
+		_, err := s3Service.ObjectInfo(ctx, "assets", composition.SceneClipKey(job, 1))
+		require.Equal(t, tt.wantKept, err == nil, "failed at %s: clip kept = %v", tt.stage, err == nil)
+	}
+}
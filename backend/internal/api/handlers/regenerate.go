@@ -2,49 +2,102 @@ package handlers
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/omnigen/backend/internal/adapters"
 	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
 	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
 	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/validation"
 	"github.com/omnigen/backend/pkg/errors"
 	"go.uber.org/zap"
 )
 
 // RegenerateHandler handles scene regeneration requests
 type RegenerateHandler struct {
-	jobRepo      *repository.DynamoDBRepository
-	s3Service    *repository.S3AssetRepository
-	veoAdapter   *adapters.VeoAdapter
-	assetsBucket string
-	logger       *zap.Logger
+	jobRepo        *repository.DynamoDBRepository
+	s3Service      *repository.S3AssetRepository
+	storage        storageCounter // Optional; nil skips storage accounting
+	adapterFactory *adapters.AdapterFactory
+	tmpBudget      int64 // Bytes of /tmp a recomposition may use; <= 0 disables the check
+	assetsBucket   string
+	locator        *repository.AssetLocator    // Which keys are the user's own uploads; nil uses the legacy layout
+	metrics        metrics.Recorder            // Replicate and ffmpeg measurements; nil records nothing
+	provenance     provenanceStore             // Records each provider call on the job; nil records nothing
+	events         repository.JobEventAppender // Job audit trail; nil records nothing
+	locks          compositionLocker           // Keeps runs of a job from composing at once; nil skips the lock
+	composer       *composition.Service        // Stores clips and composes final videos, as generation does
+	logger         *zap.Logger
 }
 
 // NewRegenerateHandler creates a new regenerate handler
 func NewRegenerateHandler(
 	jobRepo *repository.DynamoDBRepository,
 	s3Service *repository.S3AssetRepository,
-	veoAdapter *adapters.VeoAdapter,
+	storageUsage *repository.DynamoDBUsageRepository,
+	adapterFactory *adapters.AdapterFactory,
+	tmpBudget int64,
 	assetsBucket string,
+	locator *repository.AssetLocator,
+	recorder metrics.Recorder,
+	jobEvents repository.JobEventsRepository,
+	jobLocks repository.JobLocksRepository,
 	logger *zap.Logger,
 ) *RegenerateHandler {
-	return &RegenerateHandler{
-		jobRepo:      jobRepo,
-		s3Service:    s3Service,
-		veoAdapter:   veoAdapter,
-		assetsBucket: assetsBucket,
-		logger:       logger,
+	h := &RegenerateHandler{
+		jobRepo:        jobRepo,
+		s3Service:      s3Service,
+		adapterFactory: adapterFactory,
+		tmpBudget:      tmpBudget,
+		assetsBucket:   assetsBucket,
+		locator:        locator,
+		metrics:        recorder,
+		logger:         logger,
 	}
+	if storageUsage != nil {
+		h.storage = storageUsage
+	}
+	if jobRepo != nil {
+		h.provenance = jobRepo
+	}
+	if jobEvents != nil {
+		h.events = jobEvents
+	}
+	if jobLocks != nil {
+		h.locks = jobLocks
+	}
+	h.composer = composition.NewService(s3Service, assetsBucket, tmpBudget, h.events, jobRepo, composition.ClipQualityGate{}, logger)
+	return h
+}
+
+// log returns the handler's logger with the request and job IDs ctx carries
+func (h *RegenerateHandler) log(ctx context.Context) *zap.Logger {
+	return trace.Logger(ctx, h.logger)
 }
 
-// RegenerateRequest represents a scene regeneration request
+// RegenerateRequest represents a scene regeneration request. The scene fields edit the stored
+// scene; the edit is saved once the clip is regenerated, so later cascades and recompositions use it.
 type RegenerateRequest struct {
 	Cascade bool `json:"cascade" form:"cascade"` // Regenerate subsequent scenes too
+
+	GenerationPrompt *string `json:"generation_prompt,omitempty"` // Replaces the scene's prompt
+	Action           *string `json:"action,omitempty"`            // What happens in the scene
+	Mood             *string `json:"mood,omitempty"`              // energetic, calm, dramatic, ...
+	Camera           *string `json:"camera,omitempty"`            // Camera movement: static, dolly_in, pan_left, ...
+
+	NegativePrompt *string `json:"negative_prompt,omitempty"` // Replaces what the video model should avoid; "" clears it
+	Seed           *int64  `json:"seed,omitempty"`            // Pins the clip's seed, e.g. one from the job's provenance, to reproduce it with the edits
 }
 
 // RegenerateResponse represents a scene regeneration response
@@ -54,11 +107,14 @@ type RegenerateResponse struct {
 	NewVersion   int    `json:"new_version"`
 	ClipURL      string `json:"clip_url"`
 	CascadeCount int    `json:"cascade_count,omitempty"` // How many subsequent scenes regenerated
+
+	// Prompt the clip was generated from, including any edits
+	GenerationPrompt string `json:"generation_prompt"`
 }
 
 // RegenerateScene handles POST /api/v1/jobs/:id/scenes/:scene_number/regenerate
 // @Summary Regenerate a specific scene
-// @Description Regenerates a single scene of a completed job with versioning support
+// @Description Regenerates a single scene of a completed job with versioning support. The scene's generation prompt, action, mood, camera movement, negative prompt and seed can be edited for the regeneration; passing the seed recorded in the job's provenance reproduces the clip with the edits; edits are saved only if it succeeds, and each clip version's prompt is kept in prompt_versions.
 // @Tags jobs
 // @Accept json
 // @Produce json
@@ -68,6 +124,8 @@ type RegenerateResponse struct {
 // @Success 200 {object} RegenerateResponse
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job was modified during regeneration"
+// @Failure 422 {object} errors.ErrorResponse "Scene number out of range, or invalid scene edits"
 // @Failure 500 {object} errors.ErrorResponse
 // @Router /api/v1/jobs/{id}/scenes/{scene_number}/regenerate [post]
 // @Security BearerAuth
@@ -75,6 +133,7 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 	jobID := c.Param("id")
 	sceneNumStr := c.Param("scene_number")
 	userID := auth.MustGetUserID(c)
+	ctx := trace.WithJobID(c.Request.Context(), jobID)
 
 	sceneNum, err := strconv.Atoi(sceneNumStr)
 	if err != nil || sceneNum < 1 {
@@ -85,19 +144,25 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 	}
 
 	var req RegenerateRequest
-	// Bind query params and body, ignore errors for optional params
+	// cascade may come from the query string; the body is optional
 	_ = c.ShouldBindQuery(&req)
-	_ = c.ShouldBindJSON(&req)
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
 
-	h.logger.Info("Scene regeneration requested",
-		zap.String("job_id", jobID),
+	h.log(ctx).Info("Scene regeneration requested",
 		zap.Int("scene_number", sceneNum),
 		zap.Bool("cascade", req.Cascade),
 		zap.String("user_id", userID),
 	)
 
 	// Fetch job and validate
-	job, err := h.jobRepo.GetJob(c.Request.Context(), jobID)
+	job, err := h.jobRepo.GetJob(ctx, jobID)
 	if err != nil {
 		if err == repository.ErrJobNotFound {
 			c.JSON(http.StatusNotFound, errors.ErrorResponse{
@@ -105,7 +170,7 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 			})
 			return
 		}
-		h.logger.Error("Failed to get job", zap.String("job_id", jobID), zap.Error(err))
+		h.log(ctx).Error("Failed to get job", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error: errors.ErrDatabaseError,
 		})
@@ -129,56 +194,74 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 	}
 
 	// Validate scene number
-	if sceneNum > len(job.Scenes) || sceneNum < 1 {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error: errors.NewValidationError("scene_number", fmt.Sprintf("Invalid scene number. Job has %d scenes.", len(job.Scenes))),
-		})
+	var errs validation.Errors
+	if !validation.ValidateSceneNumber(&errs, "scene_number", sceneNum, len(job.Scenes)) {
+		respondValidationErrors(c, errs)
 		return
 	}
 
-	// Get start image from previous scene's last frame (or nothing for scene 1)
+	// Edits apply to this regeneration and are saved with its result
+	editedScene, errs := editScene(job.Scenes[sceneNum-1], sceneNum, req)
+	if len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	// The scene is regenerated with the models the job was generated with
+	ctx = adapters.WithModelOverrides(ctx, job.ModelOverrides)
+	regenerateStage, regenerateStart := fmt.Sprintf("scene_%d_regenerating", sceneNum), time.Now()
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventStage,
+		Stage:   regenerateStage,
+		Message: fmt.Sprintf("Regenerating scene %d", sceneNum),
+	})
+
+	// Get start image from previous scene's last frame (or nothing for scene 1, or when the job
+	// doesn't chain frames)
 	var startImageURL string
-	if sceneNum > 1 {
-		// Get the previous scene's thumbnail as start image
+	if sceneNum > 1 && continuityMode(job) == domain.ContinuityFrame {
+		// Get the previous scene's thumbnail as start image; it's stored beside the scene's clip,
+		// which keeps its key when scenes are reordered
 		prevSceneNum := sceneNum - 1
-		prevVersion := 1
-		if job.SceneVersions != nil && job.SceneVersions[prevSceneNum] > 0 {
-			prevVersion = job.SceneVersions[prevSceneNum]
+		_, prevThumbnailKey := sceneAssetKeys(job, prevSceneNum)
+		presignedURL, err := "", stderrors.New("scene has no clip")
+		if prevThumbnailKey != "" {
+			presignedURL, err = h.s3Service.GetPresignedURLInBucket(ctx, job.AssetBucket, prevThumbnailKey, 1*time.Hour)
 		}
-
-		// Try versioned thumbnail first, then fall back to non-versioned
-		prevThumbnailKey := buildVersionedSceneThumbnailKey(job.UserID, jobID, prevSceneNum, prevVersion)
-		presignedURL, err := h.s3Service.GetPresignedURL(c.Request.Context(), prevThumbnailKey, 1*time.Hour)
 		if err != nil {
-			// Fall back to non-versioned thumbnail
-			prevThumbnailKey = buildSceneThumbnailKey(job.UserID, jobID, prevSceneNum)
-			presignedURL, err = h.s3Service.GetPresignedURL(c.Request.Context(), prevThumbnailKey, 1*time.Hour)
-			if err != nil {
-				h.logger.Warn("Could not get previous scene thumbnail for continuity",
-					zap.String("job_id", jobID),
-					zap.Int("prev_scene", prevSceneNum),
-					zap.Error(err),
-				)
-			}
-		}
-		if err == nil {
+			h.log(ctx).Warn("Could not get previous scene thumbnail for continuity",
+				zap.Int("prev_scene", prevSceneNum),
+				zap.Error(err),
+			)
+			h.appendJobEvent(ctx, job, domain.JobEvent{
+				Type:    domain.JobEventWarning,
+				Stage:   regenerateStage,
+				Message: fmt.Sprintf("Scene %d's last frame could not be read; regenerating without it", prevSceneNum),
+			})
+		} else {
 			startImageURL = presignedURL
 		}
 	}
 
-	// Get scene metadata from stored script
-	scene := job.Scenes[sceneNum-1]
+	scene := withContinuityStyle(job, editedScene, sceneNum)
 	scene.StartImageURL = startImageURL
 
-	// Generate new clip
-	ctx := c.Request.Context()
-	clipResult, err := h.generateClip(ctx, job.UserID, jobID, scene, job.AspectRatio, sceneNum)
+	// Generate new clip; uploads are recorded on the job and counted once it is saved
+	ctx = repository.WithAssetLedger(metrics.WithRecorder(ctx, h.metrics), job)
+	videoAdapter := videoAdapterForJob(h.adapterFactory, h.log(ctx), job)
+	currentVersion := job.SceneVersions[sceneNum]
+	newVersion := nextSceneVersion(job, sceneNum)
+	clipResult, err := h.generateClip(h.withProvenance(ctx, job, fmt.Sprintf("scene_%d", sceneNum)), videoAdapter, job, scene, job.AspectRatio, sceneNum, newVersion)
 	if err != nil {
-		h.logger.Error("Scene regeneration failed",
-			zap.String("job_id", jobID),
+		h.log(ctx).Error("Scene regeneration failed",
 			zap.Int("scene_number", sceneNum),
 			zap.Error(err),
 		)
+		h.appendJobEvent(ctx, job, domain.JobEvent{
+			Type:    domain.JobEventFailed,
+			Stage:   regenerateStage,
+			Message: "Scene regeneration failed",
+		})
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error: errors.ErrInternalServer.WithDetails(map[string]interface{}{
 				"message": "Scene regeneration failed",
@@ -196,14 +279,12 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 		job.ClipVersions = make(map[string]string)
 	}
 
-	// Increment version
-	currentVersion := job.SceneVersions[sceneNum]
-	newVersion := currentVersion + 1
-
-	// Store versioned clip
+	// Store versioned clip, with the prompt it replaced and the one it was generated from
 	versionKey := fmt.Sprintf("scene-%d-v%d", sceneNum, newVersion)
+	recordPromptVersions(job, sceneNum, currentVersion, newVersion, editedScene.GenerationPrompt)
 	job.SceneVersions[sceneNum] = newVersion
 	job.ClipVersions[versionKey] = clipResult.VideoURL
+	job.Scenes[sceneNum-1] = editedScene
 
 	// Update current scene URL in array
 	job.SceneVideoURLs[sceneNum-1] = clipResult.VideoURL
@@ -211,39 +292,43 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 	// Handle cascade regeneration if requested
 	cascadeCount := 0
 	if req.Cascade && sceneNum < len(job.Scenes) {
-		h.logger.Info("Cascade regeneration requested",
-			zap.String("job_id", jobID),
+		h.log(ctx).Info("Cascade regeneration requested",
 			zap.Int("starting_scene", sceneNum+1),
 			zap.Int("total_scenes", len(job.Scenes)),
 		)
 
 		// Use the new scene's last frame as start image for next scene
-		nextStartImageURL := clipResult.LastFrameURL
+		nextStartImageURL := continuityFrame(job, clipResult.LastFrameURL)
 
 		for nextScene := sceneNum + 1; nextScene <= len(job.Scenes); nextScene++ {
 			nextSceneData := job.Scenes[nextScene-1]
 			nextSceneData.StartImageURL = nextStartImageURL
+			nextCurrentVersion := job.SceneVersions[nextScene]
+			nextNewVersion := nextSceneVersion(job, nextScene)
 
-			nextClipResult, err := h.generateClip(ctx, job.UserID, jobID, nextSceneData, job.AspectRatio, nextScene)
+			nextClipResult, err := h.generateClip(h.withProvenance(ctx, job, fmt.Sprintf("scene_%d", nextScene)), videoAdapter, job, withContinuityStyle(job, nextSceneData, nextScene), job.AspectRatio, nextScene, nextNewVersion)
 			if err != nil {
-				h.logger.Error("Cascade scene regeneration failed",
-					zap.String("job_id", jobID),
+				h.log(ctx).Error("Cascade scene regeneration failed",
 					zap.Int("scene_number", nextScene),
 					zap.Error(err),
 				)
+				h.appendJobEvent(ctx, job, domain.JobEvent{
+					Type:    domain.JobEventWarning,
+					Stage:   regenerateStage,
+					Message: fmt.Sprintf("Cascade stopped at scene %d, which failed to regenerate", nextScene),
+				})
 				// Continue with partial success
 				break
 			}
 
 			// Update version for cascaded scene
-			nextCurrentVersion := job.SceneVersions[nextScene]
-			nextNewVersion := nextCurrentVersion + 1
 			nextVersionKey := fmt.Sprintf("scene-%d-v%d", nextScene, nextNewVersion)
+			recordPromptVersions(job, nextScene, nextCurrentVersion, nextNewVersion, nextSceneData.GenerationPrompt)
 			job.SceneVersions[nextScene] = nextNewVersion
 			job.ClipVersions[nextVersionKey] = nextClipResult.VideoURL
 			job.SceneVideoURLs[nextScene-1] = nextClipResult.VideoURL
 
-			nextStartImageURL = nextClipResult.LastFrameURL
+			nextStartImageURL = continuityFrame(job, nextClipResult.LastFrameURL)
 			cascadeCount++
 		}
 	}
@@ -252,10 +337,14 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 	clips := h.buildClipVideosFromJob(job)
 	mp4Key, webmKey, err := h.composeVideo(ctx, job, clips)
 	if err != nil {
-		h.logger.Error("Video recomposition failed",
-			zap.String("job_id", jobID),
+		h.log(ctx).Error("Video recomposition failed",
 			zap.Error(err),
 		)
+		h.appendJobEvent(ctx, job, domain.JobEvent{
+			Type:    domain.JobEventFailed,
+			Stage:   regenerateStage,
+			Message: "Video recomposition failed",
+		})
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error: errors.ErrInternalServer.WithDetails(map[string]interface{}{
 				"message": "Video recomposition failed",
@@ -268,11 +357,26 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 	job.VideoKey = mp4Key
 	job.WebMVideoKey = webmKey
 	job.UpdatedAt = time.Now().Unix()
+	publishScrubSprite(ctx, h.s3Service, jobAssetBucket(job, h.assetsBucket), h.log(ctx), job, mp4Key)
 
 	// Save updated job
-	if err := h.jobRepo.UpdateJob(ctx, job); err != nil {
-		h.logger.Error("Failed to update job after regeneration",
-			zap.String("job_id", jobID),
+	ledger := repository.AssetLedgerFrom(ctx)
+	assetTotal := ledger.Stage(job)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, job, sceneEditOutput(job)); err != nil {
+		// Every attempt raced another write, so the client retries against the newer copy
+		if stderrors.Is(err, repository.ErrVersionConflict) {
+			h.appendJobEvent(ctx, job, domain.JobEvent{
+				Type:    domain.JobEventFailed,
+				Stage:   regenerateStage,
+				Message: "Job was modified while the scene was regenerating",
+			})
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict,
+					"Job was modified while the scene was regenerating. Please retry.", nil),
+			})
+			return
+		}
+		h.log(ctx).Error("Failed to update job after regeneration",
 			zap.Error(err),
 		)
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
@@ -280,133 +384,204 @@ func (h *RegenerateHandler) RegenerateScene(c *gin.Context) {
 		})
 		return
 	}
+	countStorage(ctx, h.storage, job.UserID, ledger.Commit(assetTotal), h.log(ctx))
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:       domain.JobEventCompleted,
+		Stage:      regenerateStage,
+		Message:    fmt.Sprintf("Scene %d regenerated as version %d, %d later scenes cascaded", sceneNum, newVersion, cascadeCount),
+		DurationMs: time.Since(regenerateStart).Milliseconds(),
+	})
 
 	// Generate presigned URL for the new clip
-	clipPresignedURL, err := h.s3Service.GetPresignedURL(ctx, extractS3Key(clipResult.VideoURL), 7*24*time.Hour)
+	clipPresignedURL, err := h.s3Service.GetPresignedURLInBucket(ctx, job.AssetBucket, s3util.Key(clipResult.VideoURL), AssetURLExpiry)
 	if err != nil {
 		clipPresignedURL = clipResult.VideoURL
 	}
 
-	h.logger.Info("Scene regeneration complete",
-		zap.String("job_id", jobID),
+	h.log(ctx).Info("Scene regeneration complete",
 		zap.Int("scene_number", sceneNum),
 		zap.Int("new_version", newVersion),
 		zap.Int("cascade_count", cascadeCount),
 	)
 
 	c.JSON(http.StatusOK, RegenerateResponse{
-		JobID:        jobID,
-		SceneNumber:  sceneNum,
-		NewVersion:   newVersion,
-		ClipURL:      clipPresignedURL,
-		CascadeCount: cascadeCount,
+		JobID:            jobID,
+		SceneNumber:      sceneNum,
+		NewVersion:       newVersion,
+		ClipURL:          clipPresignedURL,
+		CascadeCount:     cascadeCount,
+		GenerationPrompt: editedScene.GenerationPrompt,
 	})
 }
 
-// generateClip generates a single video clip using Veo adapter
+// editScene applies a regeneration request's edits to a copy of scene. Without a new
+// generation prompt, a changed action, camera movement or mood is appended to the stored
+// prompt, since the prompt is all the video model sees.
+func editScene(scene domain.Scene, sceneNum int, req RegenerateRequest) (domain.Scene, validation.Errors) {
+	edit := validation.SceneEditInput{
+		GenerationPrompt: trimmed(req.GenerationPrompt),
+		Action:           trimmed(req.Action),
+		Mood:             trimmed(req.Mood),
+		Camera:           trimmed(req.Camera),
+		NegativePrompt:   trimmed(req.NegativePrompt),
+		Seed:             req.Seed,
+	}
+	if errs := validation.ValidateSceneEdit(sceneNum, edit); len(errs) > 0 {
+		return scene, errs
+	}
+
+	var directions []string
+	if edit.Action != nil && *edit.Action != scene.Action {
+		scene.Action = *edit.Action
+		directions = append(directions, "Action: "+strings.TrimSuffix(scene.Action, "."))
+	}
+	if edit.Camera != nil && domain.CameraMove(*edit.Camera) != scene.CameraMove {
+		scene.CameraMove = domain.CameraMove(*edit.Camera)
+		directions = append(directions, "Camera movement: "+strings.ReplaceAll(*edit.Camera, "_", " "))
+	}
+	if edit.Mood != nil && domain.Mood(*edit.Mood) != scene.Mood {
+		scene.Mood = domain.Mood(*edit.Mood)
+		directions = append(directions, "Mood: "+*edit.Mood)
+	}
+
+	if edit.NegativePrompt != nil {
+		scene.NegativePrompt = *edit.NegativePrompt
+	}
+	if edit.Seed != nil {
+		seed := *edit.Seed
+		scene.Seed = &seed
+	}
+
+	switch {
+	case edit.GenerationPrompt != nil:
+		scene.GenerationPrompt = *edit.GenerationPrompt
+	case len(directions) > 0:
+		scene.GenerationPrompt = strings.TrimSpace(scene.GenerationPrompt) + " " + strings.Join(directions, ". ") + "."
+		// The stored prompt may have had room for the edit or not
+		var errs validation.Errors
+		if !validation.ValidateScenePrompt(&errs, "generation_prompt", sceneNum, scene.GenerationPrompt) {
+			return scene, errs
+		}
+	}
+	return scene, nil
+}
+
+// recordPromptVersions keeps the prompt of a scene's current clip version, if it isn't kept
+// yet, and the prompt its new version is generated from
+func recordPromptVersions(job *domain.Job, sceneNum, currentVersion, newVersion int, prompt string) {
+	if job.PromptVersions == nil {
+		job.PromptVersions = make(map[string]string)
+	}
+	currentKey := fmt.Sprintf("scene-%d-v%d", sceneNum, currentVersion)
+	if _, ok := job.PromptVersions[currentKey]; !ok {
+		job.PromptVersions[currentKey] = job.Scenes[sceneNum-1].GenerationPrompt
+	}
+	job.PromptVersions[fmt.Sprintf("scene-%d-v%d", sceneNum, newVersion)] = prompt
+}
+
+// nextSceneVersion returns the version a scene's next clip is stored as. It follows the scene's
+// current version, skipping any whose clip key is still in use: a reorder moves scenes to new
+// numbers but leaves their clips where they are, and undoable orderings keep theirs.
+func nextSceneVersion(job *domain.Job, sceneNum int) int {
+	inUse := make(map[string]bool)
+	use := func(urls []string, versions map[string]string) {
+		for _, url := range urls {
+			inUse[s3util.Key(url)] = true
+		}
+		for _, url := range versions {
+			inUse[s3util.Key(url)] = true
+		}
+	}
+	use(job.SceneVideoURLs, job.ClipVersions)
+	for _, snapshot := range job.SceneOrderHistory {
+		use(snapshot.SceneVideoURLs, snapshot.ClipVersions)
+	}
+
+	version := job.SceneVersions[sceneNum] + 1
+	for inUse[composition.VersionedSceneClipKey(job, sceneNum, version)] {
+		version++
+	}
+	return version
+}
+
+// trimmed returns s without surrounding whitespace, keeping nil as nil
+func trimmed(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	return &t
+}
+
+// generateClip generates a single video clip using the job's video adapter
 // This is a simplified version that reuses logic from generate_async.go
 func (h *RegenerateHandler) generateClip(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	videoAdapter adapters.VideoGeneratorAdapter,
+	job *domain.Job,
 	scene domain.Scene,
 	aspectRatio string,
 	clipNumber int,
-) (ClipVideo, error) {
-	h.logger.Info("Regenerating scene clip",
-		zap.String("job_id", jobID),
+	version int,
+) (composition.ClipVideo, error) {
+	h.log(ctx).Info("Regenerating scene clip",
 		zap.Int("scene", clipNumber),
 		zap.String("prompt", scene.GenerationPrompt),
 	)
 
 	// Call Veo adapter
-	req := &adapters.VideoGenerationRequest{
-		Prompt:        scene.GenerationPrompt,
-		Duration:      int(scene.Duration),
-		AspectRatio:   aspectRatio,
-		StartImageURL: scene.StartImageURL,
-	}
+	req, seed := sceneVideoRequest(scene, aspectRatio)
+	ctx = adapters.WithProvenanceSeed(ctx, seed)
 
-	result, err := h.veoAdapter.GenerateVideo(ctx, req)
+	result, err := videoAdapter.GenerateVideo(ctx, req)
 	if err != nil {
-		return ClipVideo{}, fmt.Errorf("veo API failed: %w", err)
+		return composition.ClipVideo{}, fmt.Errorf("%s API failed: %w", videoAdapter.GetModelName(), err)
 	}
 
-	// Poll until complete (max 10 minutes)
-	maxAttempts := VideoGenerationMaxAttempts
-	pollInterval := PollInterval
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		select {
-		case <-ctx.Done():
-			return ClipVideo{}, ctx.Err()
-		default:
-		}
-
-		if attempt > 0 {
-			time.Sleep(pollInterval)
-			result, err = h.veoAdapter.GetStatus(ctx, result.PredictionID)
-			if err != nil {
-				h.logger.Warn("Veo polling failed, retrying", zap.Error(err))
-				continue
-			}
-		}
-
-		if result.Status == "succeeded" || result.Status == "completed" {
-			// Process and upload the video
-			clipURL, lastFrameURL, err := h.processVideo(ctx, userID, jobID, clipNumber, result.VideoURL)
-			if err != nil {
-				return ClipVideo{}, fmt.Errorf("video processing failed: %w", err)
-			}
-
-			return ClipVideo{
-				VideoURL:     clipURL,
-				LastFrameURL: lastFrameURL,
-				Duration:     scene.Duration,
-			}, nil
-		}
-
-		if result.Status == "failed" || result.Status == "canceled" {
-			errorMsg := result.Error
-			if errorMsg == "" {
-				errorMsg = "Unknown error - Veo returned failed status"
-			}
-			return ClipVideo{}, fmt.Errorf("veo generation failed: %s", errorMsg)
+	if result.VideoURL == "" {
+		result, err = adapters.PollUntilComplete(ctx, videoAdapter, result.PredictionID,
+			videoPollOptions(h.log(ctx), videoAdapter.GetModelName()))
+		if err != nil {
+			return composition.ClipVideo{}, fmt.Errorf("%s generation failed: %w", videoAdapter.GetModelName(), err)
 		}
+	}
 
-		// Log progress periodically
-		if attempt%12 == 0 {
-			h.logger.Info("Veo still processing",
-				zap.String("job_id", jobID),
-				zap.Int("attempt", attempt),
-				zap.String("status", result.Status),
-			)
-		}
+	// Process and upload the video
+	clipURL, lastFrameURL, err := h.processVideo(ctx, job, clipNumber, version, result.VideoURL, scene.Duration)
+	if err != nil {
+		return composition.ClipVideo{}, fmt.Errorf("video processing failed: %w", err)
 	}
 
-	return ClipVideo{}, fmt.Errorf("clip generation timed out after %d attempts", maxAttempts)
+	return composition.ClipVideo{
+		VideoURL:     clipURL,
+		LastFrameURL: lastFrameURL,
+		Duration:     scene.Duration,
+	}, nil
 }
 
-// processVideo downloads video from Replicate, extracts last frame, uploads both to S3
+// processVideo downloads video from Replicate, extracts last frame, uploads both to S3 as the
+// clip's version. A clip of the wrong shape is normalized: the user asked for this one, and can
+// regenerate it again.
 func (h *RegenerateHandler) processVideo(
 	ctx context.Context,
-	userID string,
-	jobID string,
+	job *domain.Job,
 	clipNumber int,
+	version int,
 	videoURL string,
+	expectedDuration float64,
 ) (string, string, error) {
-	return processVideoCommon(ctx, h.s3Service, h.assetsBucket, h.logger, userID, jobID, clipNumber, videoURL)
+	return h.composer.DownloadAndProcessClip(ctx, job, clipNumber, version, videoURL, expectedDuration, composition.ClipAspectNormalize)
 }
 
 // buildClipVideosFromJob constructs ClipVideo slice from job data
-func (h *RegenerateHandler) buildClipVideosFromJob(job *domain.Job) []ClipVideo {
-	clips := make([]ClipVideo, len(job.SceneVideoURLs))
+func (h *RegenerateHandler) buildClipVideosFromJob(job *domain.Job) []composition.ClipVideo {
+	clips := make([]composition.ClipVideo, len(job.SceneVideoURLs))
 	for i, url := range job.SceneVideoURLs {
 		duration := 8.0 // Default duration
 		if i < len(job.Scenes) {
 			duration = job.Scenes[i].Duration
 		}
-		clips[i] = ClipVideo{
+		clips[i] = composition.ClipVideo{
 			VideoURL: url,
 			Duration: duration,
 		}
@@ -414,12 +589,15 @@ func (h *RegenerateHandler) buildClipVideosFromJob(job *domain.Job) []ClipVideo
 	return clips
 }
 
-// composeVideo recomposes the final video from all clips
+// composeVideo recomposes the final video from all clips, unless it was already composed from
+// the same ones
 // Returns: (mp4Key, webmKey, error) - webmKey may be empty if WebM encoding fails
 func (h *RegenerateHandler) composeVideo(
 	ctx context.Context,
 	job *domain.Job,
-	clips []ClipVideo,
+	clips []composition.ClipVideo,
 ) (string, string, error) {
-	return composeVideoCommon(ctx, h.s3Service, h.assetsBucket, h.logger, job, clips)
+	return composeOnce(ctx, h.s3Service, jobAssetBucket(job, h.assetsBucket), h.locks, h.log(ctx), job, clips, func(comp composition.Plan) (string, string, error) {
+		return h.composer.ComposeFinalVideo(ctx, job, clips, comp)
+	})
 }
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const storedScenePrompt = "Wide shot of a sunlit kitchen, a glass bottle of cold brew on the counter, soft morning light"
+
+// unavailableMedia fails every clip, standing in for a video model that errors out
+type unavailableMedia struct{}
+
+func (unavailableMedia) VideoURL(ctx context.Context, seconds int, aspectRatio string) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+func (unavailableMedia) AudioURL(ctx context.Context, seconds int) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+func (unavailableMedia) Audio(ctx context.Context, seconds float64) ([]byte, error) {
+	return nil, errors.New("model unavailable")
+}
+
+// servedMedia serves every clip from one URL, standing in for a video model that succeeds
+type servedMedia struct {
+	url string
+}
+
+func (m servedMedia) VideoURL(ctx context.Context, seconds int, aspectRatio string) (string, error) {
+	return m.url, nil
+}
+
+func (m servedMedia) AudioURL(ctx context.Context, seconds int) (string, error) {
+	return m.url, nil
+}
+
+func (servedMedia) Audio(ctx context.Context, seconds float64) ([]byte, error) {
+	return []byte("audio"), nil
+}
+
+func completedJobWithScenes() *domain.Job {
+	return &domain.Job{
+		JobID:       "job-regen",
+		UserID:      "user-123",
+		Status:      domain.StatusCompleted,
+		Stage:       domain.StageComplete,
+		Model:       "veo",
+		AspectRatio: "16:9",
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 8, Action: "A bottle sits on the counter", Mood: domain.MoodCalm, CameraMove: domain.MoveStatic, GenerationPrompt: storedScenePrompt},
+			{SceneNumber: 2, Duration: 8, Action: "She pours a glass", Mood: domain.MoodCalm, CameraMove: domain.MoveDollyIn, GenerationPrompt: storedScenePrompt + ", pouring"},
+		},
+		SceneVideoURLs: []string{"s3://bucket/clip-1.mp4", "s3://bucket/clip-2.mp4"},
+		CreatedAt:      1000,
+		UpdatedAt:      1000,
+	}
+}
+
+func regenerateRouter(h *RegenerateHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/jobs/:id/scenes/:scene_number/regenerate", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	}, h.RegenerateScene)
+	return router
+}
+
+func postRegenerate(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRegenerateScene_RejectsInvalidEdits(t *testing.T) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	require.NoError(t, repo.CreateJob(context.Background(), completedJobWithScenes()))
+	router := regenerateRouter(NewRegenerateHandler(repo, nil, nil, nil, 0, "", nil, nil, nil, nil, zap.NewNop()))
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"empty prompt", `{"generation_prompt": "   "}`, "generation_prompt"},
+		{"short prompt", `{"generation_prompt": "a bottle"}`, "generation_prompt"},
+		{"placeholder prompt", `{"generation_prompt": "Close-up of [insert product] on the counter with soft morning light"}`, "generation_prompt"},
+		{"unknown mood", `{"mood": "gloomy"}`, "mood"},
+		{"unknown camera", `{"camera": "barrel_roll"}`, "camera"},
+		{"action too long", `{"action": "` + strings.Repeat("x", 501) + `"}`, "action"},
+		{"negative seed", `{"seed": -1}`, "seed"},
+		{"seed out of range", `{"seed": 4294967296}`, "seed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postRegenerate(router, "/api/v1/jobs/job-regen/scenes/1/regenerate", tt.body)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+			var body apierrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			errs, _ := json.Marshal(body.Error.Details["errors"])
+			require.Contains(t, string(errs), `"field":"`+tt.field+`"`)
+		})
+	}
+
+	w := postRegenerate(router, "/api/v1/jobs/job-regen/scenes/1/regenerate", `{"mood": 5}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	stored, err := repo.GetJob(context.Background(), "job-regen")
+	require.NoError(t, err)
+	require.Equal(t, completedJobWithScenes().Scenes, stored.Scenes)
+}
+
+func TestRegenerateScene_FailedGenerationKeepsStoredScene(t *testing.T) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	require.NoError(t, repo.CreateJob(context.Background(), completedJobWithScenes()))
+	factory := adapters.NewMockAdapterFactory(unavailableMedia{}, 0, zap.NewNop())
+	router := regenerateRouter(NewRegenerateHandler(repo, nil, nil, factory, 0, "", nil, nil, nil, nil, zap.NewNop()))
+
+	edited := "Extreme close-up of condensation running down a cold brew bottle, backlit by the morning sun"
+	w := postRegenerate(router, "/api/v1/jobs/job-regen/scenes/1/regenerate", `{"generation_prompt": "`+edited+`", "mood": "energetic"}`)
+	require.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+
+	stored, err := repo.GetJob(context.Background(), "job-regen")
+	require.NoError(t, err)
+	require.Equal(t, storedScenePrompt, stored.Scenes[0].GenerationPrompt)
+	require.Equal(t, domain.MoodCalm, stored.Scenes[0].Mood)
+	require.Empty(t, stored.PromptVersions)
+	require.Empty(t, stored.SceneVersions)
+}
+
+func TestEditScene(t *testing.T) {
+	scene := completedJobWithScenes().Scenes[0]
+	ptr := func(s string) *string { return &s }
+
+	// A new prompt is used as given; the other fields are saved with it
+	edited, errs := editScene(scene, 1, RegenerateRequest{
+		GenerationPrompt: ptr("  Extreme close-up of condensation running down a cold brew bottle at sunrise  "),
+		Camera:           ptr("dolly_in"),
+	})
+	require.Empty(t, errs)
+	require.Equal(t, "Extreme close-up of condensation running down a cold brew bottle at sunrise", edited.GenerationPrompt)
+	require.Equal(t, domain.MoveDollyIn, edited.CameraMove)
+	require.Equal(t, storedScenePrompt, scene.GenerationPrompt, "the stored scene is not modified")
+
+	// Without one, changed directions are appended to the stored prompt
+	edited, errs = editScene(scene, 1, RegenerateRequest{Mood: ptr("energetic"), Camera: ptr("pan_left"), Action: ptr(scene.Action)})
+	require.Empty(t, errs)
+	require.Equal(t, storedScenePrompt+" Camera movement: pan left. Mood: energetic.", edited.GenerationPrompt)
+	require.Equal(t, domain.MoodEnergetic, edited.Mood)
+
+	// A seed and negative prompt are saved for later regenerations, leaving the prompt alone
+	seed := int64(4242)
+	edited, errs = editScene(scene, 1, RegenerateRequest{Seed: &seed, NegativePrompt: ptr(" extra fingers ")})
+	require.Empty(t, errs)
+	require.Equal(t, "extra fingers", edited.NegativePrompt)
+	require.Equal(t, &seed, edited.Seed)
+	require.Equal(t, storedScenePrompt, edited.GenerationPrompt)
+
+	// No edits regenerate the stored scene as it is
+	edited, errs = editScene(scene, 1, RegenerateRequest{Cascade: true})
+	require.Empty(t, errs)
+	require.Equal(t, scene, edited)
+}
+
+func TestRecordPromptVersions(t *testing.T) {
+	job := completedJobWithScenes()
+
+	recordPromptVersions(job, 1, 0, 1, "edited prompt")
+	require.Equal(t, map[string]string{
+		"scene-1-v0": storedScenePrompt,
+		"scene-1-v1": "edited prompt",
+	}, job.PromptVersions)
+
+	// The original prompt isn't overwritten by later versions
+	job.Scenes[0].GenerationPrompt = "edited prompt"
+	recordPromptVersions(job, 1, 1, 2, "edited again")
+	require.Equal(t, storedScenePrompt, job.PromptVersions["scene-1-v0"])
+	require.Equal(t, "edited prompt", job.PromptVersions["scene-1-v1"])
+	require.Equal(t, "edited again", job.PromptVersions["scene-1-v2"])
+}
+
+func TestNextSceneVersion(t *testing.T) {
+	job := completedJobWithScenes()
+	job.UserID, job.JobID = "user-123", "job-regen"
+	require.Equal(t, 1, nextSceneVersion(job, 1))
+
+	// After scenes 1 and 2 swap, scene 1 is the old scene 2, whose version 1 key is its clip
+	versionKey := "s3://bucket/" + composition.VersionedSceneClipKey(job, 1, 1)
+	job.SceneVideoURLs = []string{"s3://bucket/clip-2.mp4", versionKey}
+	job.SceneVersions = map[int]int{2: 1}
+	job.ClipVersions = map[string]string{"scene-2-v1": versionKey}
+	require.Equal(t, 2, nextSceneVersion(job, 1))
+	require.Equal(t, 2, nextSceneVersion(job, 2))
+
+	// Clips of an ordering that can still be restored are kept too
+	job.SceneOrderHistory = []domain.SceneOrderSnapshot{{SceneVideoURLs: []string{"s3://bucket/" + composition.VersionedSceneClipKey(job, 1, 2)}}}
+	require.Equal(t, 3, nextSceneVersion(job, 1))
+}
+
+func TestRegenerateScene_RecomposesPharmaOverlay(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	s3Service := newComposeTestS3(t)
+	clipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("regenerated clip"))
+	}))
+	defer clipServer.Close()
+
+	job := completedJobWithScenes()
+	job.Scenes[0].Duration, job.Scenes[1].Duration = 4, 4
+	job.Voice = "female"
+	job.SideEffects = pharmaSideEffects
+	job.SideEffectsText = pharmaSideEffects
+	job.SideEffectsStartTime = 6.4
+	clips := []composition.ClipVideo{
+		uploadComposeClip(t, s3Service, job, 1, "clip one"),
+		uploadComposeClip(t, s3Service, job, 2, "clip two"),
+	}
+	job.SceneVideoURLs = []string{clips[0].VideoURL, clips[1].VideoURL}
+	require.NoError(t, repo.CreateJob(ctx, job))
+
+	factory := adapters.NewMockAdapterFactory(servedMedia{url: clipServer.URL + "/clip.mp4"}, 0, zap.NewNop())
+	h := NewRegenerateHandler(repo, s3Service, nil, factory, 0, "assets", nil, nil, nil, nil, zap.NewNop())
+	runner := &recordingRunner{fps: "30", clipDuration: 4, total: 8}
+	h.composer = h.composer.WithRunner(runner)
+
+	// The original composition, as the pipeline runs it
+	_, _, err := h.composeVideo(ctx, job, clips)
+	require.NoError(t, err)
+
+	w := postRegenerate(regenerateRouter(h), "/api/v1/jobs/job-regen/scenes/2/regenerate", `{}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	filtergraphs := overlayFiltergraphs(runner)
+	require.Len(t, filtergraphs, 2, "the original composition and the recomposition")
+	for i, filtergraph := range filtergraphs {
+		require.Contains(t, filtergraph, "drawtext=text="+pharmaSideEffects, "composition %d", i+1)
+		require.Contains(t, filtergraph, "enable='between(t,6.40,8.00)'", "composition %d", i+1)
+	}
+	require.Equal(t, filtergraphs[0], filtergraphs[1], "the recomposition draws the overlay the original did")
+
+	stored, err := repo.GetJob(ctx, "job-regen")
+	require.NoError(t, err)
+	require.Equal(t, 1, stored.SceneVersions[2])
+	require.Equal(t, pharmaSideEffects, stored.SideEffectsText)
+	require.Equal(t, 6.4, stored.SideEffectsStartTime)
+}
@@ -0,0 +1,623 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// renditionTimeout bounds one request's renditions; a rendition still processing after it
+	// was interrupted (e.g. by a restart) and may be requested again
+	renditionTimeout = 30 * time.Minute
+
+	// renditionFadeOut is how long a rendition cut to a platform's maximum length fades out
+	renditionFadeOut = 0.5
+
+	// renditionCRF matches the overlay pass's quality; the platform's bitrate cap applies on top
+	renditionCRF = 21
+)
+
+// renditionTarget is one platform placement a rendition can be made for
+type renditionTarget struct {
+	Platform       string
+	Width          int
+	Height         int
+	MaxDuration    float64 // Seconds; 0 for no limit
+	MaxBitrateKbps int
+}
+
+// renditionTargets are the placements renditions can be made for. Durations are the ad
+// placements' limits, which are stricter than the organic upload limits; bitrates stay under
+// each platform's ingest ceiling so it doesn't re-encode the file again.
+var renditionTargets = map[string]renditionTarget{
+	"tiktok_9x16":  {Platform: "TikTok", Width: 1080, Height: 1920, MaxDuration: 60, MaxBitrateKbps: 8000},
+	"reels_9x16":   {Platform: "Instagram Reels", Width: 1080, Height: 1920, MaxDuration: 90, MaxBitrateKbps: 5000},
+	"shorts_9x16":  {Platform: "YouTube Shorts", Width: 1080, Height: 1920, MaxDuration: 60, MaxBitrateKbps: 8000},
+	"youtube_16x9": {Platform: "YouTube", Width: 1920, Height: 1080, MaxBitrateKbps: 12000},
+	"square_1x1":   {Platform: "Instagram feed", Width: 1080, Height: 1080, MaxDuration: 60, MaxBitrateKbps: 5000},
+}
+
+// CreateRenditionsRequest lists the platform targets to make renditions of a job's final video for
+type CreateRenditionsRequest struct {
+	Targets []string `json:"targets" binding:"required"` // e.g. ["tiktok_9x16", "youtube_16x9"]
+	// How the video fills a target of another aspect ratio: crop (default) or pad
+	Fit string `json:"fit,omitempty"`
+	// Where a crop is taken from, -1 (left or top edge) to 1 (right or bottom edge); 0 centers it
+	FocalBias *float64 `json:"focal_bias,omitempty"`
+}
+
+// RenditionResponse describes one rendition of a job's final video
+type RenditionResponse struct {
+	Target       string  `json:"target"`
+	Platform     string  `json:"platform"`
+	Status       string  `json:"status"` // processing, completed or failed
+	Fit          string  `json:"fit"`
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+	Duration     float64 `json:"duration,omitempty"`
+	Trimmed      bool    `json:"trimmed,omitempty"`  // Cut to the platform's maximum length, fading out
+	Outdated     bool    `json:"outdated,omitempty"` // Made from a final video the job has since replaced
+	SizeBytes    int64   `json:"size_bytes,omitempty"`
+	URL          string  `json:"url,omitempty"`
+	URLExpiresAt int64   `json:"url_expires_at,omitempty"` // When url stops working
+	Error        string  `json:"error,omitempty"`
+	CreatedAt    int64   `json:"created_at"`
+	CompletedAt  int64   `json:"completed_at,omitempty"`
+}
+
+// ListRenditionsResponse lists a job's renditions by target
+type ListRenditionsResponse struct {
+	JobID      string              `json:"job_id"`
+	Renditions []RenditionResponse `json:"renditions"`
+	Count      int                 `json:"count"`
+}
+
+// renditionGeometry is how a video is scaled into a target frame: scaled to ScaleWidth x
+// ScaleHeight, then cropped to the frame at X,Y or padded out to it with the video placed at X,Y
+type renditionGeometry struct {
+	ScaleWidth  int
+	ScaleHeight int
+	X           int
+	Y           int
+	Pad         bool
+}
+
+// planRenditionGeometry fits a width x height video into a targetWidth x targetHeight frame.
+// A crop scales the video to cover the frame and cuts the overflow, bias of it from the left or
+// top at -1 to the right or bottom at 1; a pad scales it to fit inside and centers it. Sizes and
+// offsets are even, as 4:2:0 video needs.
+func planRenditionGeometry(width, height, targetWidth, targetHeight int, fit string, bias float64) renditionGeometry {
+	g := renditionGeometry{ScaleWidth: targetWidth, ScaleHeight: targetHeight, Pad: fit == domain.RenditionFitPad}
+	if width <= 0 || height <= 0 {
+		return g
+	}
+	scaleX := float64(targetWidth) / float64(width)
+	scaleY := float64(targetHeight) / float64(height)
+
+	if g.Pad {
+		scale := math.Min(scaleX, scaleY)
+		g.ScaleWidth = min(targetWidth, evenFloor(float64(width)*scale))
+		g.ScaleHeight = min(targetHeight, evenFloor(float64(height)*scale))
+		g.X = evenFloor(float64(targetWidth-g.ScaleWidth) / 2)
+		g.Y = evenFloor(float64(targetHeight-g.ScaleHeight) / 2)
+		return g
+	}
+
+	scale := math.Max(scaleX, scaleY)
+	g.ScaleWidth = max(targetWidth, evenCeil(float64(width)*scale))
+	g.ScaleHeight = max(targetHeight, evenCeil(float64(height)*scale))
+	share := (math.Max(-1, math.Min(1, bias)) + 1) / 2
+	g.X = evenFloor(float64(g.ScaleWidth-targetWidth) * share)
+	g.Y = evenFloor(float64(g.ScaleHeight-targetHeight) * share)
+	return g
+}
+
+// evenFloor rounds down to an even number of pixels
+func evenFloor(v float64) int {
+	return int(math.Floor(v/2+1e-9)) * 2
+}
+
+// evenCeil rounds up to an even number of pixels
+func evenCeil(v float64) int {
+	return int(math.Ceil(v/2-1e-9)) * 2
+}
+
+// renditionPlan is how one rendition is encoded from the final video
+type renditionPlan struct {
+	Target         string
+	Width          int
+	Height         int
+	Geometry       renditionGeometry
+	Duration       float64 // Seconds of the source kept
+	Trimmed        bool
+	MaxBitrateKbps int
+}
+
+// planRendition plans target's rendition of a video in source's format
+func planRendition(name string, target renditionTarget, source composition.ClipFormat, fit string, bias float64) renditionPlan {
+	plan := renditionPlan{
+		Target:         name,
+		Width:          target.Width,
+		Height:         target.Height,
+		Geometry:       planRenditionGeometry(source.Width, source.Height, target.Width, target.Height, fit, bias),
+		Duration:       source.Duration,
+		MaxBitrateKbps: target.MaxBitrateKbps,
+	}
+	if target.MaxDuration > 0 && source.Duration > target.MaxDuration {
+		plan.Duration, plan.Trimmed = target.MaxDuration, true
+	}
+	return plan
+}
+
+// renditionArgs builds the ffmpeg arguments that encode input as plan's rendition. A trimmed
+// rendition fades its picture and sound out over its last renditionFadeOut seconds.
+func renditionArgs(input, output string, plan renditionPlan) []string {
+	g := plan.Geometry
+	filters := []string{fmt.Sprintf("scale=%d:%d:flags=lanczos", g.ScaleWidth, g.ScaleHeight)}
+	if g.Pad {
+		filters = append(filters, fmt.Sprintf("pad=%d:%d:%d:%d:color=black", plan.Width, plan.Height, g.X, g.Y))
+	} else {
+		filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d", plan.Width, plan.Height, g.X, g.Y))
+	}
+	filters = append(filters, "setsar=1")
+
+	args := []string{"-i", input}
+	if plan.Trimmed {
+		fadeStart := formatSeconds(math.Max(0, plan.Duration-renditionFadeOut))
+		fade := fmt.Sprintf("st=%s:d=%s", fadeStart, formatSeconds(renditionFadeOut))
+		filters = append(filters, "fade=t=out:"+fade)
+		args = append(args, "-t", formatSeconds(plan.Duration), "-af", "afade=t=out:"+fade)
+	}
+	args = append(args,
+		"-vf", strings.Join(filters, ","),
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", fmt.Sprint(renditionCRF),
+		"-profile:v", "high",
+	)
+	if plan.MaxBitrateKbps > 0 {
+		args = append(args,
+			"-maxrate", fmt.Sprintf("%dk", plan.MaxBitrateKbps),
+			"-bufsize", fmt.Sprintf("%dk", 2*plan.MaxBitrateKbps),
+		)
+	}
+	return append(args,
+		"-pix_fmt", composition.OutputPixelFormat,
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-movflags", "+faststart",
+		"-y", output,
+	)
+}
+
+// encodeRendition runs ffmpeg to encode input as plan's rendition
+func encodeRendition(ctx context.Context, input, output string, plan renditionPlan) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", renditionArgs(input, output, plan)...)
+	return ffmpegexec.RunCommand(ctx, "rendition_encode", cmd)
+}
+
+// buildRenditionKey returns the S3 key of a job's rendition for target; a new rendition for the
+// target replaces it
+func buildRenditionKey(job *domain.Job, target string) string {
+	return jobAssetPrefix(job) + fmt.Sprintf("renditions/%s.mp4", target)
+}
+
+// RenditionsHandler re-encodes a job's final video for social platforms
+type RenditionsHandler struct {
+	jobRepo      *repository.DynamoDBRepository
+	s3Service    *repository.S3AssetRepository
+	storage      storageCounter // Optional; nil skips storage accounting
+	assetsBucket string
+	probe        func(ctx context.Context, path string) (composition.ClipFormat, error)
+	encode       func(ctx context.Context, input, output string, plan renditionPlan) error
+	logger       *zap.Logger
+}
+
+// NewRenditionsHandler creates a new renditions handler
+func NewRenditionsHandler(
+	jobRepo *repository.DynamoDBRepository,
+	s3Service *repository.S3AssetRepository,
+	storageUsage *repository.DynamoDBUsageRepository,
+	assetsBucket string,
+	logger *zap.Logger,
+) *RenditionsHandler {
+	h := &RenditionsHandler{
+		jobRepo:      jobRepo,
+		s3Service:    s3Service,
+		assetsBucket: assetsBucket,
+		probe:        composition.ProbeClipFormat,
+		encode:       encodeRendition,
+		logger:       logger,
+	}
+	if storageUsage != nil {
+		h.storage = storageUsage
+	}
+	return h
+}
+
+// log returns the handler's logger with the request and job IDs ctx carries
+func (h *RenditionsHandler) log(ctx context.Context) *zap.Logger {
+	return trace.Logger(ctx, h.logger)
+}
+
+// CreateRenditions handles POST /api/v1/jobs/:id/renditions
+// @Summary Make platform renditions of a job's video
+// @Description Starts re-encoding a completed job's final video for each target (tiktok_9x16, reels_9x16, shorts_9x16, youtube_16x9, square_1x1): cropped (or padded) to the platform's aspect ratio and resolution, cut to its maximum length with a fade-out, and kept under its bitrate ceiling. Renditions are made in the background; list them to download each once completed. A new rendition for a target replaces the earlier one.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body CreateRenditionsRequest true "Targets"
+// @Success 202 {object} ListRenditionsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "A requested target is still rendering"
+// @Failure 422 {object} errors.ErrorResponse "Unknown target, fit or focal bias"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/renditions [post]
+// @Security BearerAuth
+func (h *RenditionsHandler) CreateRenditions(c *gin.Context) {
+	var req CreateRenditionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+	if errs := validateRenditionsRequest(req); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != domain.StatusCompleted || job.VideoKey == "" {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("status", "Can only make renditions of completed jobs"),
+		})
+		return
+	}
+
+	now := time.Now()
+	for _, r := range job.Renditions {
+		if slices.Contains(req.Targets, r.Target) && renditionRunning(r, now) {
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict,
+					fmt.Sprintf("The %s rendition is still being made. Try again when it finishes.", r.Target), nil),
+			})
+			return
+		}
+	}
+
+	fit := req.Fit
+	if fit == "" {
+		fit = domain.RenditionFitCrop
+	}
+	var bias float64
+	if req.FocalBias != nil && fit == domain.RenditionFitCrop {
+		bias = *req.FocalBias
+	}
+	// Re-applied to the stored job if another request wrote it meanwhile. A target that request
+	// started is left to it, so no target is made twice at once.
+	var started []domain.Rendition
+	start := func(dst *domain.Job) {
+		started = started[:0]
+		for _, target := range req.Targets {
+			if slices.ContainsFunc(dst.Renditions, func(r domain.Rendition) bool {
+				return r.Target == target && renditionRunning(r, now)
+			}) {
+				continue
+			}
+			r := domain.Rendition{
+				Target:         target,
+				Status:         domain.RenditionProcessing,
+				SourceVideoKey: dst.VideoKey,
+				Fit:            fit,
+				FocalBias:      bias,
+				CreatedAt:      now.Unix(),
+			}
+			dst.Renditions = withRendition(dst.Renditions, r)
+			started = append(started, r)
+		}
+	}
+	start(job)
+
+	ctx := trace.WithJobID(c.Request.Context(), job.JobID)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, job, start); err != nil {
+		if stderrors.Is(err, repository.ErrVersionConflict) {
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict, "Job was modified while renditions were starting. Please retry.", nil),
+			})
+			return
+		}
+		h.log(ctx).Error("Failed to record renditions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	if len(started) == 0 {
+		c.JSON(http.StatusConflict, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrConflict,
+				"The renditions are already being made. Try again when they finish.", nil),
+		})
+		return
+	}
+
+	h.log(ctx).Info("Renditions requested", zap.Strings("targets", req.Targets), zap.String("fit", fit))
+	// Built before the renditions start saving onto job
+	resp := h.renditionsResponse(ctx, job)
+	go h.runRenditions(trace.Detach(ctx), job, started)
+
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// ListRenditions handles GET /api/v1/jobs/:id/renditions
+// @Summary List a job's platform renditions
+// @Description Lists the renditions of a job's final video by target, with download URLs for completed ones
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} ListRenditionsResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/renditions [get]
+// @Security BearerAuth
+func (h *RenditionsHandler) ListRenditions(c *gin.Context) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.renditionsResponse(c.Request.Context(), job))
+}
+
+// validateRenditionsRequest checks a request's targets, fit and focal bias
+func validateRenditionsRequest(req CreateRenditionsRequest) validation.Errors {
+	var errs validation.Errors
+	if len(req.Targets) == 0 {
+		errs.Add("targets", "At least one target is required")
+	}
+	for i, target := range req.Targets {
+		field := fmt.Sprintf("targets[%d]", i)
+		if _, ok := renditionTargets[target]; !ok {
+			errs.Add(field, fmt.Sprintf("Unknown target %q; must be one of: %s", target, strings.Join(renditionTargetNames(), ", ")))
+		} else if slices.Index(req.Targets, target) < i {
+			errs.Add(field, fmt.Sprintf("Target %s is listed more than once", target))
+		}
+	}
+	if req.Fit != "" && req.Fit != domain.RenditionFitCrop && req.Fit != domain.RenditionFitPad {
+		errs.Add("fit", "Fit must be crop or pad")
+	}
+	if req.FocalBias != nil && (*req.FocalBias < -1 || *req.FocalBias > 1) {
+		errs.Add("focal_bias", "Focal bias must be between -1 and 1")
+	}
+	return errs
+}
+
+// renditionTargetNames lists the targets renditions can be made for, in name order
+func renditionTargetNames() []string {
+	names := make([]string, 0, len(renditionTargets))
+	for name := range renditionTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renditionRunning reports whether r is being made, and wasn't interrupted
+func renditionRunning(r domain.Rendition, now time.Time) bool {
+	return r.Status == domain.RenditionProcessing && now.Sub(time.Unix(r.CreatedAt, 0)) < renditionTimeout
+}
+
+// withRendition returns renditions with r in place of the target's earlier rendition
+func withRendition(renditions []domain.Rendition, r domain.Rendition) []domain.Rendition {
+	out := make([]domain.Rendition, 0, len(renditions)+1)
+	for _, existing := range renditions {
+		if existing.Target != r.Target {
+			out = append(out, existing)
+		}
+	}
+	out = append(out, r)
+	sort.Slice(out, func(i, j int) bool { return out[i].Target < out[j].Target })
+	return out
+}
+
+// ownedJob loads the job named in the path, responding 404 if it doesn't belong to the user
+func (h *RenditionsHandler) ownedJob(c *gin.Context) (*domain.Job, bool) {
+	job, err := h.jobRepo.GetJob(c.Request.Context(), c.Param("id"))
+	if err == nil && job.UserID != auth.MustGetUserID(c) {
+		err = repository.ErrJobNotFound
+	}
+	if err == repository.ErrJobNotFound {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return nil, false
+	}
+	if err != nil {
+		h.log(c.Request.Context()).Error("Failed to get job", zap.String("job_id", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return nil, false
+	}
+	return job, true
+}
+
+// runRenditions makes each rendition from the job's final video, one after the other, saving
+// each outcome on the job as it finishes. A target that fails doesn't stop the rest.
+func (h *RenditionsHandler) runRenditions(ctx context.Context, job *domain.Job, renditions []domain.Rendition) {
+	ctx, cancel := context.WithTimeout(ctx, renditionTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			h.log(ctx).Error("Renditions panicked", zap.Any("panic", r))
+		}
+	}()
+
+	fail := func(r domain.Rendition, err error) {
+		h.log(ctx).Error("Rendition failed", zap.String("target", r.Target), zap.Error(err))
+		r.Status, r.Error = domain.RenditionFailed, "Rendition failed. Please try again."
+		r.CompletedAt = time.Now().Unix()
+		if err := h.saveRendition(ctx, job, r, 0); err != nil {
+			h.log(ctx).Error("Failed to record failed rendition", zap.String("target", r.Target), zap.Error(err))
+		}
+	}
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "renditions")
+	source, sourcePath, err := h.downloadSource(ctx, job, renditions[0].SourceVideoKey, tmpDir)
+	defer os.RemoveAll(tmpDir)
+	if err != nil {
+		for _, r := range renditions {
+			fail(r, err)
+		}
+		return
+	}
+
+	for _, r := range renditions {
+		plan := planRendition(r.Target, renditionTargets[r.Target], source, r.Fit, r.FocalBias)
+		outputPath := filepath.Join(tmpDir, r.Target+".mp4")
+		if err := h.encode(ctx, sourcePath, outputPath, plan); err != nil {
+			fail(r, fmt.Errorf("failed to encode rendition: %w", err))
+			continue
+		}
+		info, err := os.Stat(outputPath)
+		if err != nil {
+			fail(r, fmt.Errorf("failed to stat rendition: %w", err))
+			continue
+		}
+		key := buildRenditionKey(job, r.Target)
+		if _, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), key, outputPath, "video/mp4"); err != nil {
+			fail(r, fmt.Errorf("failed to upload rendition: %w", err))
+			continue
+		}
+
+		r.Status, r.Key = domain.RenditionCompleted, key
+		r.Width, r.Height = plan.Width, plan.Height
+		r.Duration, r.Trimmed = roundSeconds(plan.Duration), plan.Trimmed
+		r.SizeBytes, r.CompletedAt = info.Size(), time.Now().Unix()
+		if err := h.saveRendition(ctx, job, r, info.Size()); err != nil {
+			h.log(ctx).Error("Failed to record rendition", zap.String("target", r.Target), zap.Error(err))
+			continue
+		}
+		h.log(ctx).Info("Rendition complete",
+			zap.String("target", r.Target),
+			zap.Int64("size_bytes", r.SizeBytes),
+			zap.Bool("trimmed", r.Trimmed),
+		)
+	}
+}
+
+// downloadSource downloads the final video at key into dir and probes it
+func (h *RenditionsHandler) downloadSource(ctx context.Context, job *domain.Job, key, dir string) (composition.ClipFormat, string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return composition.ClipFormat{}, "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	path := filepath.Join(dir, "source"+filepath.Ext(key))
+	if err := h.s3Service.DownloadFile(ctx, jobAssetBucket(job, h.assetsBucket), key, path); err != nil {
+		return composition.ClipFormat{}, "", fmt.Errorf("failed to download final video: %w", err)
+	}
+	format, err := h.probe(ctx, path)
+	if err != nil {
+		return composition.ClipFormat{}, "", fmt.Errorf("failed to probe final video: %w", err)
+	}
+	return format, path, nil
+}
+
+// saveRendition records r on the job in place of the target's earlier rendition. A completed
+// rendition's file, size bytes, is added to the job's assets and counted against the user's
+// storage once saved; a replaced rendition's size is replaced with it.
+func (h *RenditionsHandler) saveRendition(ctx context.Context, job *domain.Job, r domain.Rendition, size int64) error {
+	var delta int64
+	apply := func(dst *domain.Job) {
+		dst.Renditions = withRendition(dst.Renditions, r)
+		delta = 0
+		if r.Key == "" {
+			return
+		}
+		if dst.Assets == nil {
+			dst.Assets = make(map[string]int64)
+		}
+		delta = size - dst.Assets[r.Key]
+		dst.Assets[r.Key] = size
+	}
+	apply(job)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, job, apply); err != nil {
+		return err
+	}
+	countStorage(ctx, h.storage, job.UserID, delta, h.log(ctx))
+	return nil
+}
+
+// renditionsResponse lists job's renditions with presigned URLs for the completed ones
+func (h *RenditionsHandler) renditionsResponse(ctx context.Context, job *domain.Job) ListRenditionsResponse {
+	presign := newPresignCache(h.s3Service, h.logger).forJob(job)
+	renditions := buildRenditionResponses(ctx, job, presign, AssetURLExpiry)
+	if renditions == nil {
+		renditions = []RenditionResponse{}
+	}
+	return ListRenditionsResponse{JobID: job.JobID, Renditions: renditions, Count: len(renditions)}
+}
+
+// buildRenditionResponses presigns each completed rendition through the shared per-request cache.
+// A rendition interrupted while processing is reported as failed.
+func buildRenditionResponses(ctx context.Context, job *domain.Job, cache *presignCache, duration time.Duration) []RenditionResponse {
+	if len(job.Renditions) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	renditions := make([]RenditionResponse, len(job.Renditions))
+	for i, r := range job.Renditions {
+		resp := RenditionResponse{
+			Target:      r.Target,
+			Platform:    renditionTargets[r.Target].Platform,
+			Status:      r.Status,
+			Fit:         r.Fit,
+			Width:       r.Width,
+			Height:      r.Height,
+			Duration:    r.Duration,
+			Trimmed:     r.Trimmed,
+			Outdated:    r.SourceVideoKey != job.VideoKey,
+			SizeBytes:   r.SizeBytes,
+			Error:       r.Error,
+			CreatedAt:   r.CreatedAt,
+			CompletedAt: r.CompletedAt,
+		}
+		switch {
+		case r.Status == domain.RenditionCompleted:
+			if resp.URL = cache.get(ctx, r.Key, duration); resp.URL != "" {
+				resp.URLExpiresAt = now.Add(duration).Unix()
+			}
+		case r.Status == domain.RenditionProcessing && !renditionRunning(r, now):
+			resp.Status, resp.Error = domain.RenditionFailed, "Rendition was interrupted. Please try again."
+		}
+		renditions[i] = resp
+	}
+	return renditions
+}
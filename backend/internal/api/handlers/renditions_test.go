@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRenditionTargets(t *testing.T) {
+	for name, target := range renditionTargets {
+		var w, h int
+		_, err := fmt.Sscanf(name[strings.LastIndex(name, "_")+1:], "%dx%d", &w, &h)
+		require.NoError(t, err, name)
+		require.Equal(t, w*target.Height, h*target.Width, "%s's frame matches its aspect ratio", name)
+		require.Zero(t, target.Width%2, name)
+		require.Zero(t, target.Height%2, name)
+		require.Positive(t, target.MaxBitrateKbps, name)
+		require.NotEmpty(t, target.Platform, name)
+		if h > w {
+			require.Positive(t, target.MaxDuration, "vertical placement %s has a length limit", name)
+		}
+	}
+}
+
+func TestPlanRenditionGeometry(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		target        [2]int
+		fit           string
+		bias          float64
+		want          renditionGeometry
+	}{
+		{name: "16:9 to 9:16, centered crop", width: 1920, height: 1080, target: [2]int{1080, 1920}, fit: domain.RenditionFitCrop,
+			want: renditionGeometry{ScaleWidth: 3414, ScaleHeight: 1920, X: 1166}},
+		{name: "16:9 to 9:16, crop from the left", width: 1920, height: 1080, target: [2]int{1080, 1920}, fit: domain.RenditionFitCrop, bias: -1,
+			want: renditionGeometry{ScaleWidth: 3414, ScaleHeight: 1920}},
+		{name: "16:9 to 9:16, crop from the right", width: 1920, height: 1080, target: [2]int{1080, 1920}, fit: domain.RenditionFitCrop, bias: 1,
+			want: renditionGeometry{ScaleWidth: 3414, ScaleHeight: 1920, X: 2334}},
+		{name: "16:9 to 9:16, out of range bias is clamped", width: 1920, height: 1080, target: [2]int{1080, 1920}, fit: domain.RenditionFitCrop, bias: 3,
+			want: renditionGeometry{ScaleWidth: 3414, ScaleHeight: 1920, X: 2334}},
+		{name: "16:9 to 9:16, padded", width: 1920, height: 1080, target: [2]int{1080, 1920}, fit: domain.RenditionFitPad,
+			want: renditionGeometry{ScaleWidth: 1080, ScaleHeight: 606, Y: 656, Pad: true}},
+		{name: "9:16 to 16:9, crop toward the top", width: 1080, height: 1920, target: [2]int{1920, 1080}, fit: domain.RenditionFitCrop, bias: -0.5,
+			want: renditionGeometry{ScaleWidth: 1920, ScaleHeight: 3414, Y: 582}},
+		{name: "9:16 to 16:9, padded", width: 1080, height: 1920, target: [2]int{1920, 1080}, fit: domain.RenditionFitPad,
+			want: renditionGeometry{ScaleWidth: 606, ScaleHeight: 1080, X: 656, Pad: true}},
+		{name: "16:9 to 1:1", width: 1920, height: 1080, target: [2]int{1080, 1080}, fit: domain.RenditionFitCrop,
+			want: renditionGeometry{ScaleWidth: 1920, ScaleHeight: 1080, X: 420}},
+		{name: "same aspect ratio, upscaled", width: 1280, height: 720, target: [2]int{1920, 1080}, fit: domain.RenditionFitCrop,
+			want: renditionGeometry{ScaleWidth: 1920, ScaleHeight: 1080}},
+		{name: "unknown size", target: [2]int{1080, 1920}, fit: domain.RenditionFitPad,
+			want: renditionGeometry{ScaleWidth: 1080, ScaleHeight: 1920, Pad: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planRenditionGeometry(tt.width, tt.height, tt.target[0], tt.target[1], tt.fit, tt.bias)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPlanRendition_EnforcesMaxDuration(t *testing.T) {
+	source := composition.ClipFormat{Width: 1920, Height: 1080, Duration: 75}
+
+	tiktok := planRendition("tiktok_9x16", renditionTargets["tiktok_9x16"], source, domain.RenditionFitCrop, 0)
+	require.True(t, tiktok.Trimmed)
+	require.Equal(t, 60.0, tiktok.Duration)
+	require.Equal(t, 8000, tiktok.MaxBitrateKbps)
+
+	reels := planRendition("reels_9x16", renditionTargets["reels_9x16"], source, domain.RenditionFitCrop, 0)
+	require.False(t, reels.Trimmed)
+	require.Equal(t, 75.0, reels.Duration)
+
+	youtube := planRendition("youtube_16x9", renditionTargets["youtube_16x9"], source, domain.RenditionFitCrop, 0)
+	require.False(t, youtube.Trimmed, "YouTube has no length limit")
+	require.Equal(t, renditionGeometry{ScaleWidth: 1920, ScaleHeight: 1080}, youtube.Geometry)
+}
+
+func TestRenditionArgs(t *testing.T) {
+	crop := planRendition("tiktok_9x16", renditionTargets["tiktok_9x16"], composition.ClipFormat{Width: 1920, Height: 1080, Duration: 30}, domain.RenditionFitCrop, 0)
+	require.Equal(t, []string{
+		"-i", "in.mp4",
+		"-vf", "scale=3414:1920:flags=lanczos,crop=1080:1920:1166:0,setsar=1",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-profile:v", "high",
+		"-maxrate", "8000k",
+		"-bufsize", "16000k",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-movflags", "+faststart",
+		"-y", "out.mp4",
+	}, renditionArgs("in.mp4", "out.mp4", crop))
+
+	trimmed := planRendition("square_1x1", renditionTargets["square_1x1"], composition.ClipFormat{Width: 1080, Height: 1920, Duration: 64.2}, domain.RenditionFitPad, 0)
+	require.Equal(t, []string{
+		"-i", "in.mp4",
+		"-t", "60",
+		"-af", "afade=t=out:st=59.5:d=0.5",
+		"-vf", "scale=606:1080:flags=lanczos,pad=1080:1080:236:0:color=black,setsar=1,fade=t=out:st=59.5:d=0.5",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-profile:v", "high",
+		"-maxrate", "5000k",
+		"-bufsize", "10000k",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-movflags", "+faststart",
+		"-y", "out.mp4",
+	}, renditionArgs("in.mp4", "out.mp4", trimmed))
+}
+
+func TestValidateRenditionsRequest(t *testing.T) {
+	bias := func(v float64) *float64 { return &v }
+	require.Empty(t, validateRenditionsRequest(CreateRenditionsRequest{Targets: []string{"tiktok_9x16", "youtube_16x9"}, FocalBias: bias(-0.4)}))
+
+	tests := []struct {
+		name  string
+		req   CreateRenditionsRequest
+		field string
+	}{
+		{"no targets", CreateRenditionsRequest{Targets: []string{}}, "targets"},
+		{"unknown target", CreateRenditionsRequest{Targets: []string{"tiktok_9x16", "vine_1x1"}}, "targets[1]"},
+		{"repeated target", CreateRenditionsRequest{Targets: []string{"reels_9x16", "square_1x1", "reels_9x16"}}, "targets[2]"},
+		{"unknown fit", CreateRenditionsRequest{Targets: []string{"square_1x1"}, Fit: "stretch"}, "fit"},
+		{"bias out of range", CreateRenditionsRequest{Targets: []string{"square_1x1"}, FocalBias: bias(1.5)}, "focal_bias"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := validateRenditionsRequest(tt.req).Field(tt.field)
+			require.True(t, ok, "want an error for %s", tt.field)
+		})
+	}
+}
+
+// renditionTestHandler renders with encode and probes every final video as a 30s 1080p clip.
+// It returns the handler, its job repository and the S3 it stores renditions in.
+func renditionTestHandler(t *testing.T, encode func(ctx context.Context, input, output string, plan renditionPlan) error) (*RenditionsHandler, *repository.DynamoDBRepository, *repository.S3AssetRepository) {
+	t.Helper()
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	s3Service := newComposeTestS3(t)
+	h := NewRenditionsHandler(repo, s3Service, nil, "assets", zap.NewNop())
+	h.probe = func(ctx context.Context, path string) (composition.ClipFormat, error) {
+		return composition.ClipFormat{Width: 1920, Height: 1080, Duration: 30}, nil
+	}
+	h.encode = encode
+	return h, repo, s3Service
+}
+
+func renditionTestJob() *domain.Job {
+	return &domain.Job{
+		JobID:    "job-renditions",
+		UserID:   "user-123",
+		Status:   domain.StatusCompleted,
+		VideoKey: "users/user-123/jobs/job-renditions/final/video-abc.mp4",
+	}
+}
+
+// startRenditions records targets as processing on the stored job, as CreateRenditions does
+func startRenditions(t *testing.T, repo *repository.DynamoDBRepository, job *domain.Job, targets ...string) []domain.Rendition {
+	t.Helper()
+	started := make([]domain.Rendition, len(targets))
+	for i, target := range targets {
+		started[i] = domain.Rendition{
+			Target:         target,
+			Status:         domain.RenditionProcessing,
+			SourceVideoKey: job.VideoKey,
+			Fit:            domain.RenditionFitCrop,
+			CreatedAt:      time.Now().Unix(),
+		}
+		job.Renditions = withRendition(job.Renditions, started[i])
+	}
+	require.NoError(t, repo.UpdateJob(context.Background(), job))
+	return started
+}
+
+func TestRunRenditions_PartialFailure(t *testing.T) {
+	var encoded []string
+	h, repo, s3Service := renditionTestHandler(t, func(ctx context.Context, input, output string, plan renditionPlan) error {
+		encoded = append(encoded, plan.Target)
+		if plan.Target == "reels_9x16" {
+			return errors.New("exit status 1")
+		}
+		return os.WriteFile(output, []byte("rendition of "+plan.Target), 0o644)
+	})
+	storage := &fakeStorageCounter{}
+	h.storage = storage
+
+	ctx := context.Background()
+	job := renditionTestJob()
+	require.NoError(t, repo.CreateJob(ctx, job))
+	master := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(master, []byte("final video"), 0o644))
+	_, err := s3Service.UploadFile(ctx, "assets", job.VideoKey, master, "video/mp4")
+	require.NoError(t, err)
+
+	started := startRenditions(t, repo, job, "reels_9x16", "square_1x1", "tiktok_9x16")
+	h.runRenditions(ctx, job, started)
+	require.Equal(t, []string{"reels_9x16", "square_1x1", "tiktok_9x16"}, encoded, "a failed target doesn't stop the rest")
+
+	stored, err := repo.GetJob(ctx, job.JobID)
+	require.NoError(t, err)
+	require.Len(t, stored.Renditions, 3)
+	byTarget := make(map[string]domain.Rendition)
+	for _, r := range stored.Renditions {
+		byTarget[r.Target] = r
+	}
+
+	reels := byTarget["reels_9x16"]
+	require.Equal(t, domain.RenditionFailed, reels.Status)
+	require.Equal(t, "Rendition failed. Please try again.", reels.Error)
+	require.Empty(t, reels.Key)
+
+	for _, target := range []string{"square_1x1", "tiktok_9x16"} {
+		r := byTarget[target]
+		require.Equal(t, domain.RenditionCompleted, r.Status, target)
+		require.Equal(t, "users/user-123/jobs/job-renditions/renditions/"+target+".mp4", r.Key)
+		require.Equal(t, int64(len("rendition of "+target)), r.SizeBytes)
+		require.Equal(t, r.SizeBytes, stored.Assets[r.Key], "completed renditions are job assets")
+		require.Equal(t, 30.0, r.Duration)
+		require.False(t, r.Trimmed)
+		require.NotZero(t, r.CompletedAt)
+	}
+	require.Equal(t, 1080, byTarget["square_1x1"].Width)
+	require.Equal(t, 1920, byTarget["tiktok_9x16"].Height)
+	require.Equal(t, byTarget["square_1x1"].SizeBytes+byTarget["tiktok_9x16"].SizeBytes, storage.totals["user-123"])
+
+	// Making a target again replaces its file, so storage only grows by the difference
+	h.encode = func(ctx context.Context, input, output string, plan renditionPlan) error {
+		return os.WriteFile(output, []byte("longer rendition of "+plan.Target), 0o644)
+	}
+	before := storage.totals["user-123"]
+	h.runRenditions(ctx, stored, startRenditions(t, repo, stored, "square_1x1"))
+	require.Equal(t, before+int64(len("longer ")), storage.totals["user-123"])
+}
+
+func TestRunRenditions_MissingVideoFailsEveryTarget(t *testing.T) {
+	h, repo, _ := renditionTestHandler(t, func(ctx context.Context, input, output string, plan renditionPlan) error {
+		t.Fatalf("encoded %s without a final video", plan.Target)
+		return nil
+	})
+	ctx := context.Background()
+	job := renditionTestJob()
+	require.NoError(t, repo.CreateJob(ctx, job))
+
+	h.runRenditions(ctx, job, startRenditions(t, repo, job, "tiktok_9x16", "youtube_16x9"))
+
+	stored, err := repo.GetJob(ctx, job.JobID)
+	require.NoError(t, err)
+	require.Len(t, stored.Renditions, 2)
+	for _, r := range stored.Renditions {
+		require.Equal(t, domain.RenditionFailed, r.Status, r.Target)
+	}
+	require.Empty(t, stored.Assets)
+}
+
+func TestBuildRenditionResponses(t *testing.T) {
+	job := renditionTestJob()
+	now := time.Now().Unix()
+	job.Renditions = []domain.Rendition{
+		{Target: "reels_9x16", Status: domain.RenditionProcessing, SourceVideoKey: job.VideoKey, CreatedAt: now},
+		{Target: "square_1x1", Status: domain.RenditionProcessing, SourceVideoKey: job.VideoKey, CreatedAt: now - int64(renditionTimeout.Seconds()) - 1},
+		{Target: "tiktok_9x16", Status: domain.RenditionCompleted, SourceVideoKey: "users/user-123/jobs/job-renditions/final/video-old.mp4",
+			Key: "users/user-123/jobs/job-renditions/renditions/tiktok_9x16.mp4", CreatedAt: now},
+	}
+	cache := newPresignCache(&fakePresigner{}, zap.NewNop())
+
+	got := buildRenditionResponses(context.Background(), job, cache, time.Hour)
+	require.Len(t, got, 3)
+	require.Equal(t, domain.RenditionProcessing, got[0].Status)
+	require.Equal(t, "Instagram Reels", got[0].Platform)
+	require.False(t, got[0].Outdated)
+
+	require.Equal(t, domain.RenditionFailed, got[1].Status, "interrupted renditions are reported as failed")
+	require.NotEmpty(t, got[1].Error)
+	require.True(t, renditionRunning(job.Renditions[0], time.Now()))
+	require.False(t, renditionRunning(job.Renditions[1], time.Now()), "an interrupted target can be requested again")
+
+	require.True(t, got[2].Outdated, "the job's final video has been recomposed since")
+	require.NotEmpty(t, got[2].URL)
+	require.NotZero(t, got[2].URLExpiresAt)
+}
+
+func TestCreateRenditions_RejectsRequests(t *testing.T) {
+	h, repo, _ := renditionTestHandler(t, nil)
+	ctx := context.Background()
+
+	running := renditionTestJob()
+	require.NoError(t, repo.CreateJob(ctx, running))
+	startRenditions(t, repo, running, "tiktok_9x16")
+
+	processing := renditionTestJob()
+	processing.JobID, processing.Status = "job-processing", domain.StatusProcessing
+	require.NoError(t, repo.CreateJob(ctx, processing))
+
+	someoneElses := renditionTestJob()
+	someoneElses.JobID, someoneElses.UserID = "job-other", "user-456"
+	require.NoError(t, repo.CreateJob(ctx, someoneElses))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/jobs/:id/renditions", func(c *gin.Context) { c.Set(auth.UserIDKey, "user-123") }, h.CreateRenditions)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "missing targets", path: "/api/v1/jobs/job-renditions/renditions", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown target", path: "/api/v1/jobs/job-renditions/renditions", body: `{"targets": ["vine_1x1"]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "targets[0]"},
+		{name: "unknown fit", path: "/api/v1/jobs/job-renditions/renditions", body: `{"targets": ["square_1x1"], "fit": "stretch"}`, wantStatus: http.StatusUnprocessableEntity, wantField: "fit"},
+		{name: "not completed", path: "/api/v1/jobs/job-processing/renditions", body: `{"targets": ["square_1x1"]}`, wantStatus: http.StatusBadRequest, wantField: "status"},
+		{name: "someone else's job", path: "/api/v1/jobs/job-other/renditions", body: `{"targets": ["square_1x1"]}`, wantStatus: http.StatusNotFound},
+		{name: "target still rendering", path: "/api/v1/jobs/job-renditions/renditions", body: `{"targets": ["square_1x1", "tiktok_9x16"]}`, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postRegenerate(router, tt.path, tt.body)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantField == "" {
+				return
+			}
+			var body apierrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			details, _ := json.Marshal(body.Error.Details)
+			require.Contains(t, string(details), `"field":"`+tt.wantField+`"`)
+		})
+	}
+
+	stored, err := repo.GetJob(ctx, running.JobID)
+	require.NoError(t, err)
+	require.Len(t, stored.Renditions, 1, "rejected requests start nothing")
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// maxReplicateWebhookBytes bounds a webhook body; predictions carry prompts and output URLs,
+// and GPT-4o outputs are streamed text, so this leaves plenty of headroom
+const maxReplicateWebhookBytes = 4 << 20
+
+// ReplicateWebhookHandler receives Replicate's prediction webhooks
+type ReplicateWebhookHandler struct {
+	webhooks *adapters.ReplicateWebhooks
+	secret   string
+	logger   *zap.Logger
+}
+
+// NewReplicateWebhookHandler creates a handler that verifies webhooks signed with secret
+func NewReplicateWebhookHandler(webhooks *adapters.ReplicateWebhooks, secret string, logger *zap.Logger) *ReplicateWebhookHandler {
+	return &ReplicateWebhookHandler{
+		webhooks: webhooks,
+		secret:   secret,
+		logger:   logger,
+	}
+}
+
+// HandleWebhook handles POST /internal/replicate/webhook. Replicate retries any non-2xx
+// response, so redeliveries and events nobody is waiting on are acknowledged too.
+func (h *ReplicateWebhookHandler) HandleWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxReplicateWebhookBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrInvalidRequest, "Webhook body too large", nil),
+		})
+		return
+	}
+
+	if err := adapters.VerifyReplicateWebhook(h.secret, c.Request.Header, body, time.Now()); err != nil {
+		h.logger.Warn("Rejected Replicate webhook", zap.Error(err))
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrUnauthorized, "Invalid webhook signature", nil),
+		})
+		return
+	}
+
+	event, err := adapters.ParsePredictionEvent(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrInvalidRequest, err.Error(), nil),
+		})
+		return
+	}
+
+	fresh, err := h.webhooks.Dispatch(c.Request.Context(), event)
+	if err != nil {
+		// Replicate redelivers on errors, so the waiter still hears about it
+		h.logger.Error("Failed to record Replicate webhook",
+			zap.String("prediction_id", event.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	if fresh {
+		h.logger.Debug("Replicate webhook dispatched",
+			zap.String("prediction_id", event.ID),
+			zap.String("status", string(event.Status)),
+		)
+	}
+	c.Status(http.StatusNoContent)
+}
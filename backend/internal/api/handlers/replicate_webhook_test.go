@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplicateWebhookHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := []byte("replicate-signing-key")
+	secret := "whsec_" + base64.StdEncoding.EncodeToString(key)
+
+	newRequest := func(body string, signingKey []byte) *http.Request {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, signingKey)
+		mac.Write([]byte("msg-1." + ts + "." + body))
+
+		req := httptest.NewRequest(http.MethodPost, "/internal/replicate/webhook", bytes.NewBufferString(body))
+		req.Header.Set("webhook-id", "msg-1")
+		req.Header.Set("webhook-timestamp", ts)
+		req.Header.Set("webhook-signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		return req
+	}
+
+	events := repository.NewLocalDynamoDB().PredictionEventRepository("prediction-events", zap.NewNop())
+	webhooks := adapters.NewReplicateWebhooks("https://api.example.com/internal/replicate/webhook", time.Minute, events, zap.NewNop())
+	dispatch := func(event adapters.PredictionEvent) bool {
+		fresh, err := webhooks.Dispatch(context.Background(), event)
+		require.NoError(t, err)
+		return fresh
+	}
+	handler := NewReplicateWebhookHandler(webhooks, secret, zap.NewNop())
+	router := gin.New()
+	router.POST("/internal/replicate/webhook", handler.HandleWebhook)
+
+	t.Run("valid signature is dispatched", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, newRequest(`{"id":"pred-1","status":"succeeded"}`, key))
+
+		require.Equal(t, http.StatusNoContent, recorder.Code)
+		require.False(t, dispatch(adapters.PredictionEvent{ID: "pred-1", Status: adapters.PredictionSucceeded}),
+			"the handler should already have routed pred-1")
+
+		// Servers other than the one that received it find it in the store
+		event, err := events.GetPredictionEvent(context.Background(), "pred-1")
+		require.NoError(t, err)
+		require.NotNil(t, event)
+		require.Equal(t, adapters.PredictionSucceeded, event.Status)
+	})
+
+	t.Run("redelivery is acknowledged", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, newRequest(`{"id":"pred-1","status":"succeeded"}`, key))
+		require.Equal(t, http.StatusNoContent, recorder.Code)
+	})
+
+	t.Run("invalid signature is rejected", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, newRequest(`{"id":"pred-2","status":"succeeded"}`, []byte("someone-else")))
+
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		require.True(t, dispatch(adapters.PredictionEvent{ID: "pred-2", Status: adapters.PredictionSucceeded}),
+			"a rejected webhook must not be routed")
+	})
+
+	t.Run("malformed prediction is a bad request", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, newRequest(`{"status":"succeeded"}`, key))
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/api/middleware"
+	"github.com/omnigen/backend/internal/concurrency"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// failingStorageCounter fails every storage update
+type failingStorageCounter struct{}
+
+func (failingStorageCounter) AddStorageBytes(ctx context.Context, userID string, delta int64) error {
+	return errors.New("usage table unavailable")
+}
+
+// tracedPipelineRouter starts a fake pipeline in the background the way Generate does. It logs
+// from the handler and from a helper handed the handler's logger.
+func tracedPipelineRouter(h *GenerateHandler, done chan<- context.Context) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.POST("/generate", func(c *gin.Context) {
+		job := &domain.Job{JobID: "job-trace", UserID: "user123"}
+		h.runInBackground(c.Request.Context(), job, func(ctx context.Context) {
+			h.log(ctx).Info("Generating scene", zap.Int("scene", 1))
+			countStorage(ctx, failingStorageCounter{}, job.UserID, 64, h.log(ctx)) // Fails, so it warns
+			done <- ctx
+		})
+		c.Status(http.StatusAccepted)
+	})
+	return router
+}
+
+func newTracedHandler(logger *zap.Logger) *GenerateHandler {
+	return &GenerateHandler{
+		logger:        logger,
+		semaphore:     concurrency.NewSemaphore(1),
+		cancellations: newJobCancellations(nil, logger),
+	}
+}
+
+func awaitPipeline(t *testing.T, done <-chan context.Context) context.Context {
+	t.Helper()
+	select {
+	case ctx := <-done:
+		return ctx
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline did not run")
+		return nil
+	}
+}
+
+func TestRequestIDReachesBackgroundPipeline(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	h := newTracedHandler(zap.New(core))
+	done := make(chan context.Context, 1)
+
+	req := httptest.NewRequest(http.MethodPost, "/generate", nil)
+	req.Header.Set(trace.Header, "req-from-frontend")
+	w := httptest.NewRecorder()
+	tracedPipelineRouter(h, done).ServeHTTP(w, req)
+	require.Equal(t, "req-from-frontend", w.Header().Get(trace.Header))
+
+	ctx := awaitPipeline(t, done)
+	require.Equal(t, "req-from-frontend", trace.RequestID(ctx))
+	require.Equal(t, "job-trace", trace.JobID(ctx))
+
+	line := logs.FilterMessage("Generating scene").All()
+	require.Len(t, line, 1)
+	require.Equal(t, map[string]interface{}{
+		"request_id": "req-from-frontend",
+		"job_id":     "job-trace",
+		"scene":      int64(1),
+	}, line[0].ContextMap())
+
+	warning := logs.FilterMessage("Failed to update storage usage").All()
+	require.Len(t, warning, 1)
+	require.Equal(t, "req-from-frontend", warning[0].ContextMap()["request_id"])
+	jobIDs := 0
+	for _, field := range warning[0].Context {
+		if field.Key == "job_id" {
+			jobIDs++
+		}
+	}
+	require.Equal(t, 1, jobIDs, "helpers must not add job_id again")
+}
+
+func TestRequestIDGeneratedWhenMissingOrInvalid(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	h := newTracedHandler(zap.New(core))
+	done := make(chan context.Context, 1)
+	router := tracedPipelineRouter(h, done)
+
+	req := httptest.NewRequest(http.MethodPost, "/generate", nil)
+	req.Header.Set(trace.Header, "bad id\nwith newline")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	id := w.Header().Get(trace.Header)
+	require.True(t, trace.ValidRequestID(id))
+	require.NotEqual(t, "bad id\nwith newline", id)
+
+	ctx := awaitPipeline(t, done)
+	require.Equal(t, id, trace.RequestID(ctx))
+	require.Equal(t, id, logs.FilterMessage("Generating scene").All()[0].ContextMap()["request_id"])
+}
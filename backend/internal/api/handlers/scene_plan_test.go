@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/prompts"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// offPlanScriptGenerator records the request and writes a script that breaks its plan
+type offPlanScriptGenerator struct {
+	req *adapters.ScriptGenerationRequest
+}
+
+func (g *offPlanScriptGenerator) GenerateScript(ctx context.Context, req *adapters.ScriptGenerationRequest) (*domain.Script, error) {
+	g.req = req
+	mismatch := &adapters.ScenePlanMismatchError{SceneNumber: 2, PlannedScenes: 2, Scenes: 2, PlannedDuration: 8, Duration: 6}
+	return nil, fmt.Errorf("script validation failed: %w", mismatch)
+}
+
+func (g *offPlanScriptGenerator) GenerateScriptVariants(ctx context.Context, req *adapters.ScriptGenerationRequest) ([]*domain.Script, error) {
+	script, err := g.GenerateScript(ctx, req)
+	return []*domain.Script{script}, err
+}
+
+func (g *offPlanScriptGenerator) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
+	return "", nil
+}
+
+func TestGenerateScriptStep_ScenePlanMismatchFailsJob(t *testing.T) {
+	h, repo, job := complianceHandler(t, false)
+	generator := &offPlanScriptGenerator{}
+	h.parserService = service.NewParserService(generator, zap.NewNop())
+
+	req := complianceRequest(job)
+	req.ScenePlan = []domain.PlannedScene{{Duration: 8, Hint: "bedroom at night"}, {Duration: 8}}
+	_, ok := h.generateScriptStep(context.Background(), job, req, nil)
+	require.False(t, ok)
+
+	require.Equal(t, []prompts.PlannedScene{{Duration: 8, Hint: "bedroom at night"}, {Duration: 8}}, generator.req.ScenePlan)
+	stored, err := repo.GetJob(context.Background(), job.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusFailed, stored.Status)
+	require.Contains(t, *stored.ErrorMessage, scenePlanFailureMessage)
+	require.Contains(t, *stored.ErrorMessage, "scene 2 is 6s, the scene plan asks for 8s")
+}
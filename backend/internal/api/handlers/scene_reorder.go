@@ -0,0 +1,615 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// maxSceneOrderHistory is how many reorders of a job can be undone; older orderings are dropped
+// to keep the job item small
+const maxSceneOrderHistory = 10
+
+// ReorderScenesRequest is a new order for a completed job's scenes
+type ReorderScenesRequest struct {
+	// Current scene numbers in their new order; scenes left out are deleted
+	Order []int `json:"order" binding:"required"`
+}
+
+// ReorderedScene is a scene's place on the timeline after a reorder
+type ReorderedScene struct {
+	SceneNumber      int     `json:"scene_number"`
+	StartTime        float64 `json:"start_time"`
+	Duration         float64 `json:"duration"`
+	GenerationPrompt string  `json:"generation_prompt"`
+}
+
+// ReorderScenesResponse is a job's scene order after a reorder or an undo
+type ReorderScenesResponse struct {
+	JobID                string           `json:"job_id"`
+	Scenes               []ReorderedScene `json:"scenes"`
+	Duration             float64          `json:"duration"` // Seconds, end card included
+	SideEffectsStartTime *float64         `json:"side_effects_start_time,omitempty"`
+	UndoableReorders     int              `json:"undoable_reorders"` // Reorders POST .../reorder/undo can still revert
+}
+
+// sceneTiming is where a job's scenes and what plays over them fall once retimed
+type sceneTiming struct {
+	Scenes               []domain.Scene
+	SceneVoiceovers      []domain.SceneVoiceover
+	ContentDuration      float64 // Seconds of scenes, less their blended transitions, without the end card
+	SideEffectsStartTime float64
+}
+
+// sceneOrder is a job's scenes rearranged and retimed by planSceneOrder
+type sceneOrder struct {
+	sceneTiming
+	SceneVideoURLs []string
+	SceneVersions  map[int]int
+	PromptVersions map[string]string
+	ClipVersions   map[string]string
+	ClipTrims      map[string]domain.ClipTrim
+}
+
+// retimeScenes places scenes back to back, renumbering them and setting their start times; a
+// scene blended into starts as the blend does. previous holds each scene's number before, which
+// its voiceover is found by; the side effects start keeps its share of the video, whose end card
+// comes on top of the scenes.
+func retimeScenes(job *domain.Job, scenes []domain.Scene, previous []int) sceneTiming {
+	timing := sceneTiming{Scenes: make([]domain.Scene, len(scenes))}
+	shares, _ := sceneTimeline(job, scenes)
+	var voiceovers []sceneVoiceoverClip
+	for i, scene := range scenes {
+		scene.SceneNumber = i + 1
+		scene.StartTime = roundSeconds(timing.ContentDuration)
+		timing.ContentDuration += shares[i]
+		timing.Scenes[i] = scene
+
+		// Voiceovers keep their lead-in within their scene's new slot
+		for _, v := range job.SceneVoiceovers {
+			if v.SceneNumber == previous[i] {
+				voiceovers = append(voiceovers, sceneVoiceoverClip{SceneNumber: i + 1, URL: v.URL, Duration: v.Duration})
+			}
+		}
+	}
+	timing.SceneVoiceovers = sceneVoiceoverTimings(voiceovers, shares)
+
+	if job.SideEffectsStartTime > 0 {
+		cardDuration := composition.EndCardDuration(job)
+		previousTotal := sceneDuration(job, job.Scenes) + cardDuration
+		timing.SideEffectsStartTime = job.SideEffectsStartTime
+		if previousTotal > 0 {
+			timing.SideEffectsStartTime = roundSeconds(job.SideEffectsStartTime * (timing.ContentDuration + cardDuration) / previousTotal)
+		}
+	}
+	return timing
+}
+
+// planSceneOrder rearranges job's scenes into order, a list of its current scene numbers with
+// any to delete left out, and retimes them. Their clip versions and trims follow them.
+func planSceneOrder(job *domain.Job, order []int) (sceneOrder, validation.Errors) {
+	var errs validation.Errors
+	if !validation.ValidateSceneOrder(&errs, "order", order, len(job.Scenes)) {
+		return sceneOrder{}, errs
+	}
+	if len(job.SceneVideoURLs) != len(job.Scenes) {
+		errs.Add("order", fmt.Sprintf("Job has clips for %d of its %d scenes", len(job.SceneVideoURLs), len(job.Scenes)))
+		return sceneOrder{}, errs
+	}
+
+	// The last scene was generated from the user's product image, the shot the ad closes on
+	if last := len(job.Scenes); strings.TrimSpace(job.StartImage) != "" {
+		if i := slices.Index(order, last); i >= 0 && i != len(order)-1 {
+			errs.Add(fmt.Sprintf("order[%d]", i), fmt.Sprintf("Scene %d shows the product image and must stay last", last))
+			return sceneOrder{}, errs
+		}
+	}
+
+	scenes := make([]domain.Scene, len(order))
+	planned := sceneOrder{SceneVideoURLs: make([]string, len(order))}
+	renumbered := make(map[int]int, len(order)) // Current scene number -> new one
+	for i, current := range order {
+		scenes[i] = job.Scenes[current-1]
+		planned.SceneVideoURLs[i] = job.SceneVideoURLs[current-1]
+		renumbered[current] = i + 1
+		if version := job.SceneVersions[current]; version > 0 {
+			if planned.SceneVersions == nil {
+				planned.SceneVersions = make(map[int]int)
+			}
+			planned.SceneVersions[i+1] = version
+		}
+	}
+	planned.sceneTiming = retimeScenes(job, scenes, order)
+	planned.PromptVersions = renumberVersions(job.PromptVersions, renumbered)
+	planned.ClipVersions = renumberVersions(job.ClipVersions, renumbered)
+	planned.ClipTrims = renumberVersions(job.ClipTrims, renumbered)
+	return planned, nil
+}
+
+// renumberVersions rekeys "scene-{N}-v{V}" entries to the scenes' new numbers, dropping those of
+// deleted scenes
+func renumberVersions[V any](versions map[string]V, renumbered map[int]int) map[string]V {
+	var out map[string]V
+	for key, value := range versions {
+		var sceneNumber, version int
+		if _, err := fmt.Sscanf(key, "scene-%d-v%d", &sceneNumber, &version); err != nil {
+			continue
+		}
+		newNumber, ok := renumbered[sceneNumber]
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]V)
+		}
+		out[fmt.Sprintf("scene-%d-v%d", newNumber, version)] = value
+	}
+	return out
+}
+
+// sceneDuration is the length of job's video of scenes, in seconds, without the end card
+func sceneDuration(job *domain.Job, scenes []domain.Scene) float64 {
+	_, total := sceneTimeline(job, scenes)
+	return total
+}
+
+// apply replaces job's scene timing with t
+func (t sceneTiming) apply(job *domain.Job) {
+	job.Scenes = t.Scenes
+	job.SceneVoiceovers = t.SceneVoiceovers
+	job.Duration = int(math.Round(t.ContentDuration))
+	job.SideEffectsStartTime = t.SideEffectsStartTime
+}
+
+// apply replaces job's scenes with the planned order
+func (o sceneOrder) apply(job *domain.Job) {
+	o.sceneTiming.apply(job)
+	job.SceneVideoURLs = o.SceneVideoURLs
+	job.SceneVersions = o.SceneVersions
+	job.PromptVersions = o.PromptVersions
+	job.ClipVersions = o.ClipVersions
+	job.ClipTrims = o.ClipTrims
+}
+
+// snapshotSceneOrder records what a reorder of job changes, so it can be undone
+func snapshotSceneOrder(job *domain.Job, now int64) domain.SceneOrderSnapshot {
+	return domain.SceneOrderSnapshot{
+		Scenes:               job.Scenes,
+		SceneVideoURLs:       job.SceneVideoURLs,
+		SceneVersions:        job.SceneVersions,
+		PromptVersions:       job.PromptVersions,
+		ClipVersions:         job.ClipVersions,
+		ClipTrims:            job.ClipTrims,
+		SceneVoiceovers:      job.SceneVoiceovers,
+		Duration:             job.Duration,
+		SideEffectsStartTime: job.SideEffectsStartTime,
+		AudioURL:             job.AudioURL,
+		MusicFit:             job.MusicFit,
+		MusicLoopCount:       job.MusicLoopCount,
+		MusicSyncOffset:      job.MusicSyncOffset,
+		MusicLoudness:        job.MusicLoudness,
+		ReplacedAt:           now,
+	}
+}
+
+// restoreSceneOrder puts job's scenes back as snapshot recorded them
+func restoreSceneOrder(job *domain.Job, snapshot domain.SceneOrderSnapshot) {
+	job.Scenes = snapshot.Scenes
+	job.SceneVideoURLs = snapshot.SceneVideoURLs
+	job.SceneVersions = snapshot.SceneVersions
+	job.PromptVersions = snapshot.PromptVersions
+	job.ClipVersions = snapshot.ClipVersions
+	job.ClipTrims = snapshot.ClipTrims
+	job.SceneVoiceovers = snapshot.SceneVoiceovers
+	job.Duration = snapshot.Duration
+	job.SideEffectsStartTime = snapshot.SideEffectsStartTime
+	job.AudioURL = snapshot.AudioURL
+	job.MusicFit = snapshot.MusicFit
+	job.MusicLoopCount = snapshot.MusicLoopCount
+	job.MusicSyncOffset = snapshot.MusicSyncOffset
+	job.MusicLoudness = snapshot.MusicLoudness
+}
+
+// ReorderScenes handles POST /api/v1/jobs/:id/scenes/reorder
+// @Summary Reorder or delete scenes
+// @Description Rearranges a completed job's scenes without regenerating them: order lists the current scene numbers in their new order, and scenes left out are deleted (at least 2 must remain). Scenes are retimed back to back, the side effects overlay moves with the video's length, the background music is refitted when the length changes, and the final video is recomposed from the existing clips. A scene generated from the product image must stay last. The previous ordering is kept and POST /jobs/{id}/scenes/reorder/undo restores it.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body ReorderScenesRequest true "New scene order"
+// @Success 200 {object} ReorderScenesResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job was modified during the reorder"
+// @Failure 422 {object} errors.ErrorResponse "Invalid scene order"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/scenes/reorder [post]
+// @Security BearerAuth
+func (h *RegenerateHandler) ReorderScenes(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx := trace.WithJobID(c.Request.Context(), jobID)
+
+	var req ReorderScenesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	job, ok := h.completedJob(c, ctx, jobID, "Can only reorder scenes of completed jobs")
+	if !ok {
+		return
+	}
+	planned, errs := planSceneOrder(job, req.Order)
+	if len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	h.log(ctx).Info("Scene reorder requested",
+		zap.Ints("order", req.Order),
+		zap.Int("previous_scenes", len(job.Scenes)),
+	)
+	start := time.Now()
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventStage,
+		Stage:   "scenes_reordering",
+		Message: fmt.Sprintf("Reordering scenes as %s", formatSceneOrder(req.Order)),
+	})
+
+	previousDuration := sceneDuration(job, job.Scenes)
+	job.SceneOrderHistory = append(job.SceneOrderHistory, snapshotSceneOrder(job, start.Unix()))
+	if excess := len(job.SceneOrderHistory) - maxSceneOrderHistory; excess > 0 {
+		job.SceneOrderHistory = job.SceneOrderHistory[excess:]
+	}
+	planned.apply(job)
+
+	ctx = repository.WithAssetLedger(metrics.WithRecorder(ctx, h.metrics), job)
+	if math.Abs(planned.ContentDuration-previousDuration) > 0.01 {
+		if err := refitMusic(ctx, h.s3Service, jobAssetBucket(job, h.assetsBucket), h.log(ctx), job, planned.ContentDuration+composition.EndCardDuration(job)); err != nil {
+			h.log(ctx).Warn("Failed to refit music to the reordered video, keeping the current track", zap.Error(err))
+			h.appendJobEvent(ctx, job, domain.JobEvent{
+				Type:    domain.JobEventWarning,
+				Stage:   "scenes_reordering",
+				Message: "Background music could not be refitted to the new length; it is cut off with the video",
+			})
+		}
+	}
+	if h.finishRecomposition(c, ctx, job, "scenes_reordering", start) {
+		c.JSON(http.StatusOK, sceneOrderResponse(job))
+	}
+}
+
+// UndoSceneReorder handles POST /api/v1/jobs/:id/scenes/reorder/undo
+// @Summary Undo the last scene reorder
+// @Description Restores the scenes, clip versions, timing and music a job had before its last reorder, including deleted scenes, and recomposes the final video from them. Regenerations made since that reorder are discarded with it.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} ReorderScenesResponse
+// @Failure 400 {object} errors.ErrorResponse "No reorder to undo"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job was modified during the undo"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/scenes/reorder/undo [post]
+// @Security BearerAuth
+func (h *RegenerateHandler) UndoSceneReorder(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx := trace.WithJobID(c.Request.Context(), jobID)
+
+	job, ok := h.completedJob(c, ctx, jobID, "Can only reorder scenes of completed jobs")
+	if !ok {
+		return
+	}
+	last := len(job.SceneOrderHistory) - 1
+	if last < 0 {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("scene_order_history", "Job has no scene reorder to undo"),
+		})
+		return
+	}
+
+	start := time.Now()
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventStage,
+		Stage:   "scenes_reorder_undoing",
+		Message: "Restoring the scene order before the last reorder",
+	})
+	restoreSceneOrder(job, job.SceneOrderHistory[last])
+	job.SceneOrderHistory = job.SceneOrderHistory[:last]
+
+	ctx = repository.WithAssetLedger(metrics.WithRecorder(ctx, h.metrics), job)
+	if h.finishRecomposition(c, ctx, job, "scenes_reorder_undoing", start) {
+		c.JSON(http.StatusOK, sceneOrderResponse(job))
+	}
+}
+
+// completedJob fetches the user's job for a scene edit, responding and returning false when it's
+// missing, someone else's or not completed
+func (h *RegenerateHandler) completedJob(c *gin.Context, ctx context.Context, jobID, notCompletedMessage string) (*domain.Job, bool) {
+	job, ok := h.ownedJob(c, ctx, jobID)
+	if !ok {
+		return nil, false
+	}
+	if job.Status != domain.StatusCompleted {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("status", notCompletedMessage),
+		})
+		return nil, false
+	}
+	return job, true
+}
+
+// ownedJob fetches the user's job, responding and returning false when it's missing or someone
+// else's
+func (h *RegenerateHandler) ownedJob(c *gin.Context, ctx context.Context, jobID string) (*domain.Job, bool) {
+	job, err := h.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		if err == repository.ErrJobNotFound {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error: errors.ErrJobNotFound,
+			})
+			return nil, false
+		}
+		h.log(ctx).Error("Failed to get job", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return nil, false
+	}
+
+	// Verify job belongs to the current user
+	if job.UserID != auth.MustGetUserID(c) {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.ErrJobNotFound,
+		})
+		return nil, false
+	}
+	return job, true
+}
+
+// finishRecomposition recomposes the final video from job's clips as now ordered, timed and
+// overlaid and saves it, responding with the error and returning false when either fails
+func (h *RegenerateHandler) finishRecomposition(c *gin.Context, ctx context.Context, job *domain.Job, stage string, start time.Time) bool {
+	mp4Key, webmKey, err := h.composeVideo(ctx, job, h.buildClipVideosFromJob(job))
+	if err != nil {
+		h.log(ctx).Error("Video recomposition failed", zap.Error(err))
+		h.appendJobEvent(ctx, job, domain.JobEvent{
+			Type:    domain.JobEventFailed,
+			Stage:   stage,
+			Message: "Video recomposition failed",
+		})
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer.WithDetails(map[string]interface{}{
+				"message": "Video recomposition failed",
+				"error":   err.Error(),
+			}),
+		})
+		return false
+	}
+
+	job.VideoKey = mp4Key
+	job.WebMVideoKey = webmKey
+	job.UpdatedAt = time.Now().Unix()
+	publishScrubSprite(ctx, h.s3Service, jobAssetBucket(job, h.assetsBucket), h.log(ctx), job, mp4Key)
+
+	ledger := repository.AssetLedgerFrom(ctx)
+	assetTotal := ledger.Stage(job)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, job, sceneEditOutput(job)); err != nil {
+		// Every attempt raced another write, so the client retries against the newer copy
+		if stderrors.Is(err, repository.ErrVersionConflict) {
+			h.appendJobEvent(ctx, job, domain.JobEvent{
+				Type:    domain.JobEventFailed,
+				Stage:   stage,
+				Message: "Job was modified while its video was recomposed",
+			})
+			c.JSON(http.StatusConflict, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrConflict,
+					"Job was modified while its video was recomposed. Please retry.", nil),
+			})
+			return false
+		}
+		h.log(ctx).Error("Failed to update job after recomposition", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return false
+	}
+	countStorage(ctx, h.storage, job.UserID, ledger.Commit(assetTotal), h.log(ctx))
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:       domain.JobEventCompleted,
+		Stage:      stage,
+		Message:    fmt.Sprintf("Video recomposed from %d scenes", len(job.Scenes)),
+		DurationMs: time.Since(start).Milliseconds(),
+	})
+	return true
+}
+
+// sceneEditOutput snapshots the fields a scene edit and its recomposition produce and returns a
+// function that copies them onto a freshly read job. The scenes, their current clips and the
+// final video are taken from the edit as a whole, so they always match each other; clip
+// versions, prompts, trims and assets another request added meanwhile are kept beside the
+// edit's, so none of its uploads drop out of the job's history or storage.
+func sceneEditOutput(job *domain.Job) func(*domain.Job) {
+	out := *job
+	return func(dst *domain.Job) {
+		dst.Status = out.Status
+		dst.Stage = out.Stage
+		dst.CompletedAt = out.CompletedAt
+		dst.ErrorMessage = out.ErrorMessage
+		dst.FailureStage = out.FailureStage
+		dst.FailureError = out.FailureError
+		dst.UpdatedAt = out.UpdatedAt
+
+		dst.Duration = out.Duration
+		dst.Scenes = out.Scenes
+		dst.SceneVoiceovers = out.SceneVoiceovers
+		dst.SceneVideoURLs = out.SceneVideoURLs
+		dst.SceneVersions = out.SceneVersions
+		dst.SceneOrderHistory = out.SceneOrderHistory
+		dst.ClipVersions = mergedMap(dst.ClipVersions, out.ClipVersions)
+		dst.PromptVersions = mergedMap(dst.PromptVersions, out.PromptVersions)
+		dst.ClipTrims = mergedMap(dst.ClipTrims, out.ClipTrims)
+		dst.Assets = mergedMap(dst.Assets, out.Assets)
+
+		dst.SideEffectsText = out.SideEffectsText
+		dst.SideEffectsStartTime = out.SideEffectsStartTime
+		dst.SideEffectsOverflow = out.SideEffectsOverflow
+		dst.DisclaimerSpec = out.DisclaimerSpec
+		dst.BurnCaptions = out.BurnCaptions
+		dst.LogoOverlay = out.LogoOverlay
+
+		dst.AudioURL = out.AudioURL
+		dst.NarratorAudioURL = out.NarratorAudioURL
+		dst.MusicFit = out.MusicFit
+		dst.MusicLoopCount = out.MusicLoopCount
+		dst.MusicSyncOffset = out.MusicSyncOffset
+		dst.MusicLoudness = out.MusicLoudness
+
+		dst.VideoKey = out.VideoKey
+		dst.WebMVideoKey = out.WebMVideoKey
+		dst.ScrubSpriteKey = out.ScrubSpriteKey
+		dst.ScrubVTTKey = out.ScrubVTTKey
+		dst.Encoding = out.Encoding
+	}
+}
+
+// mergedMap returns a copy of dst with src's entries added, src's winning on a shared key
+func mergedMap[K comparable, V any](dst, src map[K]V) map[K]V {
+	if dst == nil && src == nil {
+		return nil
+	}
+	merged := maps.Clone(dst)
+	if merged == nil {
+		merged = make(map[K]V, len(src))
+	}
+	maps.Copy(merged, src)
+	return merged
+}
+
+// sceneOrderResponse describes job's scenes as now ordered
+func sceneOrderResponse(job *domain.Job) ReorderScenesResponse {
+	resp := ReorderScenesResponse{
+		JobID:            job.JobID,
+		Scenes:           make([]ReorderedScene, len(job.Scenes)),
+		Duration:         roundSeconds(sceneDuration(job, job.Scenes) + composition.EndCardDuration(job)),
+		UndoableReorders: len(job.SceneOrderHistory),
+	}
+	for i, scene := range job.Scenes {
+		resp.Scenes[i] = ReorderedScene{
+			SceneNumber:      scene.SceneNumber,
+			StartTime:        scene.StartTime,
+			Duration:         scene.Duration,
+			GenerationPrompt: scene.GenerationPrompt,
+		}
+	}
+	if job.SideEffectsStartTime > 0 {
+		start := job.SideEffectsStartTime
+		resp.SideEffectsStartTime = &start
+	}
+	return resp
+}
+
+// formatSceneOrder renders an order for the job's audit trail, e.g. "3, 1, 2"
+func formatSceneOrder(order []int) string {
+	parts := make([]string, len(order))
+	for i, sceneNumber := range order {
+		parts[i] = fmt.Sprint(sceneNumber)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// buildFittedAudioKey returns the S3 key of the music refitted to a reordered video's length.
+// Each length gets its own key, so undoing a reorder can go back to the track it replaced.
+func buildFittedAudioKey(job *domain.Job, seconds float64) string {
+	return jobAssetPrefix(job) + fmt.Sprintf("audio/background-music-%ss.mp3", formatSeconds(seconds))
+}
+
+// refitMusic fits the job's music as generated to targetDuration seconds, as the pipeline fitted
+// it to the original length, and stores the result on the job. The beat sync isn't redone, since
+// the scene it landed on may have moved. Jobs without a raw track keep their music.
+func refitMusic(ctx context.Context, s3Service *repository.S3AssetRepository, bucket string, logger *zap.Logger, job *domain.Job, targetDuration float64) error {
+	if job.MusicRawURL == "" || job.AudioURL == "" {
+		return nil
+	}
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "music-refit")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rawPath := filepath.Join(tmpDir, "music-raw.mp3")
+	if err := s3Service.DownloadFile(ctx, bucket, s3util.Key(job.MusicRawURL), rawPath); err != nil {
+		return fmt.Errorf("failed to download raw music: %w", err)
+	}
+	rawDuration, err := composition.ProbeAudioDuration(ctx, rawPath)
+	if err != nil {
+		return fmt.Errorf("failed to probe music duration: %w", err)
+	}
+
+	audioPath := rawPath
+	plan := planMusicFit(rawDuration, targetDuration)
+	if plan.Mode != musicFitNone {
+		audioPath = filepath.Join(tmpDir, "music.mp3")
+		if err := applyMusicFit(ctx, rawPath, audioPath, plan); err != nil {
+			return err
+		}
+	}
+
+	target := DefaultMusicLUFS
+	if job.MusicLoudness != nil {
+		target = job.MusicLoudness.TargetLUFS
+	}
+	normalizedPath := filepath.Join(tmpDir, "music-normalized.mp3")
+	loudness, err := normalizeLoudness(ctx, audioPath, normalizedPath, target)
+	if err != nil {
+		logger.Warn("Loudness normalization failed, using refitted music as is", zap.Error(err))
+	} else {
+		audioPath = normalizedPath
+	}
+
+	audioURL, err := s3Service.UploadJobAsset(ctx, bucket, buildFittedAudioKey(job, targetDuration), audioPath, "audio/mpeg")
+	if err != nil {
+		return fmt.Errorf("failed to upload refitted music: %w", err)
+	}
+
+	logger.Info("Music refitted to reordered video",
+		zap.Float64("raw_duration", rawDuration),
+		zap.Float64("target_duration", targetDuration),
+		zap.String("fit", plan.Mode),
+	)
+	job.AudioURL = audioURL
+	job.MusicFit = plan.Mode
+	job.MusicLoopCount = 0
+	if plan.Mode == musicFitLoop {
+		job.MusicLoopCount = plan.Copies
+	}
+	job.MusicSyncOffset = 0
+	job.MusicLoudness = loudness
+	return nil
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// threeSceneJob is a completed job of 8, 6 and 4 second scenes, the second regenerated twice
+func threeSceneJob() *domain.Job {
+	return &domain.Job{
+		JobID:       "job-reorder",
+		UserID:      "user-123",
+		Status:      domain.StatusCompleted,
+		AspectRatio: "16:9",
+		Duration:    18,
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, StartTime: 0, Duration: 8, GenerationPrompt: "Runner laces up at dawn"},
+			{SceneNumber: 2, StartTime: 8, Duration: 6, GenerationPrompt: "Runner crosses a bridge"},
+			{SceneNumber: 3, StartTime: 14, Duration: 4, GenerationPrompt: "The shoe on a wet rock"},
+		},
+		SceneVideoURLs: []string{"s3://bucket/clip-1.mp4", "s3://bucket/clip-2-v2.mp4", "s3://bucket/clip-3.mp4"},
+		SceneVersions:  map[int]int{2: 2},
+		ClipVersions: map[string]string{
+			"scene-2-v1": "s3://bucket/clip-2-v1.mp4",
+			"scene-2-v2": "s3://bucket/clip-2-v2.mp4",
+		},
+		PromptVersions: map[string]string{
+			"scene-2-v0": "Runner on a bridge",
+			"scene-2-v2": "Runner crosses a bridge",
+		},
+	}
+}
+
+func scenePlacement(scenes []domain.Scene) [][3]float64 {
+	placement := make([][3]float64, len(scenes))
+	for i, scene := range scenes {
+		placement[i] = [3]float64{float64(scene.SceneNumber), scene.StartTime, scene.Duration}
+	}
+	return placement
+}
+
+func TestPlanSceneOrder_Retiming(t *testing.T) {
+	tests := []struct {
+		name      string
+		order     []int
+		placement [][3]float64 // scene number, start time, duration
+		prompts   []string
+		duration  float64
+	}{
+		{
+			name:      "unchanged",
+			order:     []int{1, 2, 3},
+			placement: [][3]float64{{1, 0, 8}, {2, 8, 6}, {3, 14, 4}},
+			prompts:   []string{"Runner laces up at dawn", "Runner crosses a bridge", "The shoe on a wet rock"},
+			duration:  18,
+		},
+		{
+			name:      "swap",
+			order:     []int{2, 1, 3},
+			placement: [][3]float64{{1, 0, 6}, {2, 6, 8}, {3, 14, 4}},
+			prompts:   []string{"Runner crosses a bridge", "Runner laces up at dawn", "The shoe on a wet rock"},
+			duration:  18,
+		},
+		{
+			name:      "delete the first scene",
+			order:     []int{2, 3},
+			placement: [][3]float64{{1, 0, 6}, {2, 6, 4}},
+			prompts:   []string{"Runner crosses a bridge", "The shoe on a wet rock"},
+			duration:  10,
+		},
+		{
+			name:      "delete and reverse",
+			order:     []int{3, 1},
+			placement: [][3]float64{{1, 0, 4}, {2, 4, 8}},
+			prompts:   []string{"The shoe on a wet rock", "Runner laces up at dawn"},
+			duration:  12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := threeSceneJob()
+			planned, errs := planSceneOrder(job, tt.order)
+			require.Empty(t, errs)
+			require.Equal(t, tt.placement, scenePlacement(planned.Scenes))
+			require.Equal(t, tt.duration, planned.ContentDuration)
+			for i, prompt := range tt.prompts {
+				require.Equal(t, prompt, planned.Scenes[i].GenerationPrompt)
+			}
+			require.Len(t, planned.SceneVideoURLs, len(tt.order))
+			require.Equal(t, threeSceneJob(), job, "planning leaves the job as it was")
+		})
+	}
+}
+
+func TestPlanSceneOrder_VersionsFollowTheirScene(t *testing.T) {
+	planned, errs := planSceneOrder(threeSceneJob(), []int{3, 2})
+	require.Empty(t, errs)
+
+	require.Equal(t, []string{"s3://bucket/clip-3.mp4", "s3://bucket/clip-2-v2.mp4"}, planned.SceneVideoURLs)
+	require.Equal(t, map[int]int{2: 2}, planned.SceneVersions)
+	require.Equal(t, map[string]string{
+		"scene-2-v1": "s3://bucket/clip-2-v1.mp4",
+		"scene-2-v2": "s3://bucket/clip-2-v2.mp4",
+	}, planned.ClipVersions)
+	require.Equal(t, "Runner on a bridge", planned.PromptVersions["scene-2-v0"])
+
+	// Deleting the regenerated scene drops its versions, which the snapshot keeps for undo
+	planned, errs = planSceneOrder(threeSceneJob(), []int{1, 3})
+	require.Empty(t, errs)
+	require.Nil(t, planned.SceneVersions)
+	require.Nil(t, planned.ClipVersions)
+	require.Nil(t, planned.PromptVersions)
+}
+
+func TestPlanSceneOrder_SideEffectsMoveProportionally(t *testing.T) {
+	tests := []struct {
+		name    string
+		endCard *domain.EndCard
+		start   float64
+		order   []int
+		want    float64
+	}{
+		// 14.4s is 80% of 18s; 80% of the 12s left is 9.6s
+		{name: "deleted scene", start: 14.4, order: []int{1, 3}, want: 9.6},
+		{name: "same length", start: 14.4, order: []int{3, 2, 1}, want: 14.4},
+		// With a 2s end card the video was 20s and becomes 12s: 15s scales to 9s
+		{name: "end card counts", endCard: &domain.EndCard{Duration: 2}, start: 15, order: []int{2, 3}, want: 9},
+		{name: "not pharmaceutical", start: 0, order: []int{2, 3}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := threeSceneJob()
+			job.SideEffectsText = "May cause dizziness."
+			job.SideEffectsStartTime = tt.start
+			job.EndCard = tt.endCard
+
+			planned, errs := planSceneOrder(job, tt.order)
+			require.Empty(t, errs)
+			require.InDelta(t, tt.want, planned.SideEffectsStartTime, 0.001)
+		})
+	}
+}
+
+func TestPlanSceneOrder_VoiceoversFollowTheirScene(t *testing.T) {
+	job := threeSceneJob()
+	job.SceneVoiceovers = []domain.SceneVoiceover{
+		{SceneNumber: 1, URL: "s3://bucket/vo-1.mp3", StartTime: sceneVoiceoverLeadIn, Duration: 3},
+		{SceneNumber: 3, URL: "s3://bucket/vo-3.mp3", StartTime: 14 + sceneVoiceoverLeadIn, Duration: 2},
+	}
+
+	planned, errs := planSceneOrder(job, []int{3, 2, 1})
+	require.Empty(t, errs)
+	require.Equal(t, []domain.SceneVoiceover{
+		{SceneNumber: 1, URL: "s3://bucket/vo-3.mp3", StartTime: sceneVoiceoverLeadIn, Duration: 2},
+		{SceneNumber: 3, URL: "s3://bucket/vo-1.mp3", StartTime: 10 + sceneVoiceoverLeadIn, Duration: 3},
+	}, planned.SceneVoiceovers)
+}
+
+func TestPlanSceneOrder_ProductImageSceneStaysLast(t *testing.T) {
+	job := threeSceneJob()
+	job.StartImage = "s3://bucket/users/user-123/product.png"
+
+	for _, order := range [][]int{{2, 1, 3}, {1, 3}, {1, 2}, {2, 1}} {
+		_, errs := planSceneOrder(job, order)
+		require.Empty(t, errs, "order %v", order)
+	}
+
+	for _, tt := range []struct {
+		order []int
+		field string
+	}{
+		{order: []int{3, 1, 2}, field: "order[0]"},
+		{order: []int{1, 3, 2}, field: "order[1]"},
+		{order: []int{3, 2}, field: "order[0]"},
+	} {
+		_, errs := planSceneOrder(job, tt.order)
+		fe, ok := errs.Field(tt.field)
+		require.True(t, ok, "order %v: errors = %v", tt.order, errs)
+		require.Equal(t, "Scene 3 shows the product image and must stay last", fe.Message)
+	}
+
+	// Without a product image any scene may close the video
+	job.StartImage = ""
+	_, errs := planSceneOrder(job, []int{3, 1, 2})
+	require.Empty(t, errs)
+}
+
+func TestPlanSceneOrder_RejectsInvalidOrders(t *testing.T) {
+	for _, order := range [][]int{{1}, {1, 2, 4}, {1, 1, 2}, {}} {
+		_, errs := planSceneOrder(threeSceneJob(), order)
+		require.NotEmpty(t, errs, "order %v", order)
+	}
+
+	job := threeSceneJob()
+	job.SceneVideoURLs = job.SceneVideoURLs[:2]
+	_, errs := planSceneOrder(job, []int{2, 1})
+	fe, ok := errs.Field("order")
+	require.True(t, ok)
+	require.Equal(t, "Job has clips for 2 of its 3 scenes", fe.Message)
+}
+
+func TestSceneOrderSnapshot_RestoresThePreviousOrder(t *testing.T) {
+	job := threeSceneJob()
+	job.SideEffectsStartTime = 14.4
+	job.AudioURL = "s3://bucket/music-18s.mp3"
+	job.MusicFit = musicFitTrim
+	original := *job
+
+	snapshot := snapshotSceneOrder(job, 1700000000)
+	planned, errs := planSceneOrder(job, []int{3, 1})
+	require.Empty(t, errs)
+	planned.apply(job)
+	job.AudioURL, job.MusicFit = "s3://bucket/music-12s.mp3", musicFitLoop
+
+	require.Equal(t, 12, job.Duration)
+	require.Len(t, job.Scenes, 2)
+
+	restoreSceneOrder(job, snapshot)
+	require.Equal(t, original, *job)
+}
+
+func reorderRouter(h *RegenerateHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	setUser := func(c *gin.Context) { c.Set(auth.UserIDKey, "user-123") }
+	router.POST("/api/v1/jobs/:id/scenes/reorder", setUser, h.ReorderScenes)
+	router.POST("/api/v1/jobs/:id/scenes/reorder/undo", setUser, h.UndoSceneReorder)
+	router.PATCH("/api/v1/jobs/:id/scenes/:scene_number/trim", setUser, h.TrimScene)
+	return router
+}
+
+func TestReorderScenes_RejectsRequests(t *testing.T) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	ctx := context.Background()
+	require.NoError(t, repo.CreateJob(ctx, threeSceneJob()))
+
+	processing := threeSceneJob()
+	processing.JobID, processing.Status = "job-processing", domain.StatusProcessing
+	require.NoError(t, repo.CreateJob(ctx, processing))
+
+	someoneElses := threeSceneJob()
+	someoneElses.JobID, someoneElses.UserID = "job-other", "user-456"
+	require.NoError(t, repo.CreateJob(ctx, someoneElses))
+
+	router := reorderRouter(NewRegenerateHandler(repo, nil, nil, nil, 0, "", nil, nil, nil, nil, zap.NewNop()))
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "missing order", path: "/api/v1/jobs/job-reorder/scenes/reorder", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "one scene left", path: "/api/v1/jobs/job-reorder/scenes/reorder", body: `{"order": [2]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "order"},
+		{name: "unknown scene", path: "/api/v1/jobs/job-reorder/scenes/reorder", body: `{"order": [1, 4]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "order[1]"},
+		{name: "repeated scene", path: "/api/v1/jobs/job-reorder/scenes/reorder", body: `{"order": [1, 2, 1]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "order[2]"},
+		{name: "not completed", path: "/api/v1/jobs/job-processing/scenes/reorder", body: `{"order": [2, 1]}`, wantStatus: http.StatusBadRequest, wantField: "status"},
+		{name: "someone else's job", path: "/api/v1/jobs/job-other/scenes/reorder", body: `{"order": [2, 1]}`, wantStatus: http.StatusNotFound},
+		{name: "nothing to undo", path: "/api/v1/jobs/job-reorder/scenes/reorder/undo", wantStatus: http.StatusBadRequest, wantField: "scene_order_history"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postRegenerate(router, tt.path, tt.body)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+			var body apierrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			if tt.wantField == "" {
+				return
+			}
+			// Validation failures list fields under "errors"; request errors name one field
+			details, _ := json.Marshal(body.Error.Details)
+			require.Contains(t, string(details), `"field":"`+tt.wantField+`"`)
+		})
+	}
+
+	stored, err := repo.GetJob(ctx, "job-reorder")
+	require.NoError(t, err)
+	require.Equal(t, threeSceneJob().Scenes, stored.Scenes, "rejected reorders leave the job as it was")
+	require.Empty(t, stored.SceneOrderHistory)
+}
+
+func TestSceneEditOutput_KeepsConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	jobRepo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	stored := threeSceneJob()
+	stored.Assets = map[string]int64{"jobs/job-reorder/clip-1.mp4": 100}
+	require.NoError(t, jobRepo.CreateJob(ctx, stored))
+
+	edit, err := jobRepo.GetJob(ctx, "job-reorder")
+	require.NoError(t, err)
+
+	// Another request renames the job and finishes a rendition while the scene regenerates
+	other, err := jobRepo.GetJob(ctx, "job-reorder")
+	require.NoError(t, err)
+	other.Title, other.TitleEdited = "Dawn run", true
+	other.Renditions = []domain.Rendition{{Target: "square_1x1", Status: domain.RenditionCompleted, Key: "jobs/job-reorder/square.mp4"}}
+	other.Assets["jobs/job-reorder/square.mp4"] = 50
+	require.NoError(t, jobRepo.UpdateJob(ctx, other))
+
+	edit.SceneVersions[2] = 3
+	edit.ClipVersions["scene-2-v3"] = "s3://bucket/clip-2-v3.mp4"
+	edit.SceneVideoURLs[1] = "s3://bucket/clip-2-v3.mp4"
+	edit.VideoKey = "jobs/job-reorder/final-v3.mp4"
+	edit.Assets["jobs/job-reorder/clip-2-v3.mp4"] = 200
+	require.NoError(t, jobRepo.UpdateJobWithRetry(ctx, edit, sceneEditOutput(edit)))
+
+	saved, err := jobRepo.GetJob(ctx, "job-reorder")
+	require.NoError(t, err)
+	require.Equal(t, "Dawn run", saved.Title)
+	require.Len(t, saved.Renditions, 1)
+	require.Equal(t, 3, saved.SceneVersions[2])
+	require.Equal(t, "s3://bucket/clip-2-v3.mp4", saved.SceneVideoURLs[1])
+	require.Equal(t, "jobs/job-reorder/final-v3.mp4", saved.VideoKey)
+	require.Len(t, saved.ClipVersions, 3)
+	require.Equal(t, map[string]int64{
+		"jobs/job-reorder/clip-1.mp4":    100,
+		"jobs/job-reorder/square.mp4":    50,
+		"jobs/job-reorder/clip-2-v3.mp4": 200,
+	}, saved.Assets)
+}
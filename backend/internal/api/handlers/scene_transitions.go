@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/transitions"
+)
+
+// sceneTimeline is where scenes, at their script durations, play in job's video: each scene's
+// share of it, from its start to the next scene's, and the shares' total
+func sceneTimeline(job *domain.Job, scenes []domain.Scene) ([]float64, float64) {
+	durations := make([]float64, len(scenes))
+	for i, scene := range scenes {
+		durations[i] = scene.Duration
+	}
+	shares := transitions.Shares(durations, composition.SceneBoundaries(job, scenes, durations))
+	return shares, composition.SumDurations(shares)
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// TrimSceneRequest is how much to cut from a scene's clip as generated. Omitted fields keep the
+// scene's current trim; zero for both restores the untrimmed clip.
+type TrimSceneRequest struct {
+	TrimStart *float64 `json:"trim_start,omitempty"` // Seconds cut from the start
+	TrimEnd   *float64 `json:"trim_end,omitempty"`   // Seconds cut from the end
+}
+
+// TrimSceneResponse is a trimmed scene's new clip version and the job's retimed scenes
+type TrimSceneResponse struct {
+	ReorderScenesResponse
+	SceneNumber      int     `json:"scene_number"`
+	NewVersion       int     `json:"new_version"`
+	TrimStart        float64 `json:"trim_start"`
+	TrimEnd          float64 `json:"trim_end"`
+	OriginalDuration float64 `json:"original_duration"` // Seconds, untrimmed
+	TrimmedDuration  float64 `json:"trimmed_duration"`  // Seconds
+}
+
+// sceneTrimArgs builds the ffmpeg arguments that cut input down to the start..end seconds of it.
+// Seeking on the input lands on the exact frame, and re-encoding with the end card's settings
+// keeps the cut from starting on a missing keyframe.
+func sceneTrimArgs(input, output string, start, end float64) []string {
+	return []string{
+		"-ss", formatSeconds(start),
+		"-to", formatSeconds(end),
+		"-i", input,
+		"-avoid_negative_ts", "make_zero",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-movflags", "+faststart",
+		"-y", output,
+	}
+}
+
+// planSceneTrim retimes job's scenes for scene sceneNum's clip lasting duration seconds; scenes
+// after it move by the difference
+func planSceneTrim(job *domain.Job, sceneNum int, duration float64) sceneTiming {
+	scenes := make([]domain.Scene, len(job.Scenes))
+	previous := make([]int, len(job.Scenes))
+	copy(scenes, job.Scenes)
+	for i := range scenes {
+		previous[i] = i + 1
+	}
+	scenes[sceneNum-1].Duration = duration
+	return retimeScenes(job, scenes, previous)
+}
+
+// currentClipTrim returns the trim the scene's current clip version was cut by, if any
+func currentClipTrim(job *domain.Job, sceneNum int) (domain.ClipTrim, bool) {
+	trim, ok := job.ClipTrims[fmt.Sprintf("scene-%d-v%d", sceneNum, job.SceneVersions[sceneNum])]
+	return trim, ok
+}
+
+// TrimScene handles PATCH /api/v1/jobs/:id/scenes/:scene_number/trim
+// @Summary Trim a scene's clip
+// @Description Cuts seconds from the start and end of a completed job's scene clip, frame-accurately, as a new clip version. Trims always apply to the clip as generated, so a second trim replaces the first. The clip must keep at least 2 seconds. Later scenes, voiceovers and the side effects overlay are retimed, the background music is refitted, and the final video is recomposed.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param scene_number path int true "Scene number (1-based)"
+// @Param request body TrimSceneRequest true "Seconds to cut"
+// @Success 200 {object} TrimSceneResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse "Job was modified during the trim"
+// @Failure 422 {object} errors.ErrorResponse "Scene number out of range, or the trim leaves too little"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/jobs/{id}/scenes/{scene_number}/trim [patch]
+// @Security BearerAuth
+func (h *RegenerateHandler) TrimScene(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx := trace.WithJobID(c.Request.Context(), jobID)
+
+	sceneNum, err := strconv.Atoi(c.Param("scene_number"))
+	if err != nil || sceneNum < 1 {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("scene_number", "Invalid scene number"),
+		})
+		return
+	}
+
+	var req TrimSceneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	job, ok := h.completedJob(c, ctx, jobID, "Can only trim scenes of completed jobs")
+	if !ok {
+		return
+	}
+	var errs validation.Errors
+	if !validation.ValidateSceneNumber(&errs, "scene_number", sceneNum, len(job.Scenes)) {
+		respondValidationErrors(c, errs)
+		return
+	}
+	if sceneNum > len(job.SceneVideoURLs) || job.SceneVideoURLs[sceneNum-1] == "" {
+		errs.Add("scene_number", fmt.Sprintf("Scene %d has no clip to trim", sceneNum))
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	// Trims are cut from the clip as generated, never from an earlier cut
+	sourceURL := job.SceneVideoURLs[sceneNum-1]
+	current, wasTrimmed := currentClipTrim(job, sceneNum)
+	if wasTrimmed {
+		sourceURL = current.SourceURL
+	}
+	trim := domain.ClipTrim{SourceURL: sourceURL, TrimStart: current.TrimStart, TrimEnd: current.TrimEnd}
+	if req.TrimStart != nil {
+		trim.TrimStart = *req.TrimStart
+	}
+	if req.TrimEnd != nil {
+		trim.TrimEnd = *req.TrimEnd
+	}
+	if trim.TrimStart == 0 && trim.TrimEnd == 0 && !wasTrimmed {
+		errs.Add("trim_start", "Set trim_start or trim_end to trim the clip")
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	stage, start := fmt.Sprintf("scene_%d_trimming", sceneNum), time.Now()
+	bucket := jobAssetBucket(job, h.assetsBucket)
+	tmpDir := filepath.Join("/tmp", job.JobID, fmt.Sprintf("scene-%d-trim", sceneNum))
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		h.respondTrimFailed(c, ctx, job, stage, fmt.Errorf("failed to create temp dir: %w", err))
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sourcePath := filepath.Join(tmpDir, "source.mp4")
+	if err := h.s3Service.DownloadFile(ctx, bucket, s3util.Key(sourceURL), sourcePath); err != nil {
+		h.respondTrimFailed(c, ctx, job, stage, fmt.Errorf("failed to download clip: %w", err))
+		return
+	}
+	source, err := composition.ProbeClipFormat(ctx, sourcePath)
+	if err != nil {
+		h.respondTrimFailed(c, ctx, job, stage, fmt.Errorf("failed to probe clip: %w", err))
+		return
+	}
+	trim.OriginalDuration = roundSeconds(source.Duration)
+	if errs := validation.ValidateSceneTrim(trim.TrimStart, trim.TrimEnd, source.Duration); len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	h.log(ctx).Info("Scene trim requested",
+		zap.Int("scene_number", sceneNum),
+		zap.Float64("trim_start", trim.TrimStart),
+		zap.Float64("trim_end", trim.TrimEnd),
+		zap.Float64("original_duration", source.Duration),
+	)
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventStage,
+		Stage:   stage,
+		Message: fmt.Sprintf("Trimming scene %d by %ss at the start and %ss at the end", sceneNum, formatSeconds(trim.TrimStart), formatSeconds(trim.TrimEnd)),
+	})
+
+	// Uploads are recorded on the job and counted once it is saved
+	ctx = repository.WithAssetLedger(metrics.WithRecorder(ctx, h.metrics), job)
+	currentVersion := job.SceneVersions[sceneNum]
+	newVersion := nextSceneVersion(job, sceneNum)
+	clipURL, duration := sourceURL, source.Duration
+	if trim.TrimStart > 0 || trim.TrimEnd > 0 {
+		clipURL, duration, err = h.uploadTrimmedClip(ctx, job, sceneNum, newVersion, sourcePath, trim, source.Duration)
+		if err != nil {
+			h.respondTrimFailed(c, ctx, job, stage, err)
+			return
+		}
+		trim.TrimmedDuration = roundSeconds(duration)
+	}
+
+	// The new version keeps the scene's prompt, which the trimmed clip was generated from
+	if job.SceneVersions == nil {
+		job.SceneVersions = make(map[int]int)
+	}
+	if job.ClipVersions == nil {
+		job.ClipVersions = make(map[string]string)
+	}
+	versionKey := fmt.Sprintf("scene-%d-v%d", sceneNum, newVersion)
+	recordPromptVersions(job, sceneNum, currentVersion, newVersion, job.Scenes[sceneNum-1].GenerationPrompt)
+	job.SceneVersions[sceneNum] = newVersion
+	job.ClipVersions[versionKey] = clipURL
+	job.SceneVideoURLs[sceneNum-1] = clipURL
+	if clipURL != sourceURL {
+		if job.ClipTrims == nil {
+			job.ClipTrims = make(map[string]domain.ClipTrim)
+		}
+		job.ClipTrims[versionKey] = trim
+	}
+
+	previousDuration := sceneDuration(job, job.Scenes)
+	timing := planSceneTrim(job, sceneNum, roundSeconds(duration))
+	timing.apply(job)
+	if math.Abs(timing.ContentDuration-previousDuration) > 0.01 {
+		if err := refitMusic(ctx, h.s3Service, bucket, h.log(ctx), job, timing.ContentDuration+composition.EndCardDuration(job)); err != nil {
+			h.log(ctx).Warn("Failed to refit music to the trimmed video, keeping the current track", zap.Error(err))
+			h.appendJobEvent(ctx, job, domain.JobEvent{
+				Type:    domain.JobEventWarning,
+				Stage:   stage,
+				Message: "Background music could not be refitted to the new length; it is cut off with the video",
+			})
+		}
+	}
+	if !h.finishRecomposition(c, ctx, job, stage, start) {
+		return
+	}
+
+	c.JSON(http.StatusOK, TrimSceneResponse{
+		ReorderScenesResponse: sceneOrderResponse(job),
+		SceneNumber:           sceneNum,
+		NewVersion:            newVersion,
+		TrimStart:             trim.TrimStart,
+		TrimEnd:               trim.TrimEnd,
+		OriginalDuration:      trim.OriginalDuration,
+		TrimmedDuration:       roundSeconds(duration),
+	})
+}
+
+// uploadTrimmedClip cuts the clip at sourcePath by trim and uploads it, with its last frame as
+// the thumbnail, as the scene's version. It returns the clip's URL and probed length, falling back
+// to the length asked for when the probe fails.
+func (h *RegenerateHandler) uploadTrimmedClip(ctx context.Context, job *domain.Job, sceneNum, version int, sourcePath string, trim domain.ClipTrim, sourceDuration float64) (string, float64, error) {
+	dir := filepath.Dir(sourcePath)
+	trimmedPath := filepath.Join(dir, "trimmed.mp4")
+	cmd := exec.CommandContext(ctx, "ffmpeg", sceneTrimArgs(sourcePath, trimmedPath, trim.TrimStart, sourceDuration-trim.TrimEnd)...)
+	if err := ffmpegexec.RunCommand(ctx, "scene_trim", cmd); err != nil {
+		return "", 0, fmt.Errorf("failed to trim clip: %w", err)
+	}
+
+	duration := sourceDuration - trim.TrimStart - trim.TrimEnd
+	if format, err := composition.ProbeClipFormat(ctx, trimmedPath); err == nil && format.Duration > 0 {
+		duration = format.Duration
+	}
+
+	bucket := jobAssetBucket(job, h.assetsBucket)
+	clipURL, err := h.s3Service.UploadJobAsset(ctx, bucket, composition.VersionedSceneClipKey(job, sceneNum, version), trimmedPath, "video/mp4")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to upload trimmed clip: %w", err)
+	}
+
+	// The thumbnail doubles as the next scene's continuity frame, so it's the trimmed clip's last
+	lastFramePath := filepath.Join(dir, "last_frame.jpg")
+	if err := composition.ExtractLastFrame(ctx, trimmedPath, lastFramePath); err != nil {
+		h.log(ctx).Warn("Failed to extract trimmed clip's last frame, continuing without it", zap.Error(err))
+	} else if _, err := h.s3Service.UploadJobAsset(ctx, bucket, composition.VersionedSceneThumbnailKey(job, sceneNum, version), lastFramePath, "image/jpeg"); err != nil {
+		h.log(ctx).Warn("Failed to upload trimmed clip's thumbnail", zap.Error(err))
+	}
+	return clipURL, duration, nil
+}
+
+// respondTrimFailed records a failed trim on the job and responds with err
+func (h *RegenerateHandler) respondTrimFailed(c *gin.Context, ctx context.Context, job *domain.Job, stage string, err error) {
+	h.log(ctx).Error("Scene trim failed", zap.Error(err))
+	h.appendJobEvent(ctx, job, domain.JobEvent{
+		Type:    domain.JobEventFailed,
+		Stage:   stage,
+		Message: "Scene trim failed",
+	})
+	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+		Error: errors.ErrInternalServer.WithDetails(map[string]interface{}{
+			"message": "Scene trim failed",
+			"error":   err.Error(),
+		}),
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSceneTrimArgs(t *testing.T) {
+	require.Equal(t, []string{
+		"-ss", "0.5",
+		"-to", "7.25",
+		"-i", "in.mp4",
+		"-avoid_negative_ts", "make_zero",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-movflags", "+faststart",
+		"-y", "out.mp4",
+	}, sceneTrimArgs("in.mp4", "out.mp4", 0.5, 7.25))
+
+	// Seeks are rounded to the millisecond
+	args := sceneTrimArgs("in.mp4", "out.mp4", 1.0/3, 6.0004)
+	require.Equal(t, []string{"-ss", "0.333", "-to", "6"}, args[:4])
+}
+
+func TestPlanSceneTrim_RetimesLaterScenes(t *testing.T) {
+	tests := []struct {
+		name      string
+		scene     int
+		duration  float64
+		placement [][3]float64 // scene number, start time, duration
+		total     float64
+	}{
+		{name: "first scene", scene: 1, duration: 5.5, placement: [][3]float64{{1, 0, 5.5}, {2, 5.5, 6}, {3, 11.5, 4}}, total: 15.5},
+		{name: "middle scene", scene: 2, duration: 3.25, placement: [][3]float64{{1, 0, 8}, {2, 8, 3.25}, {3, 11.25, 4}}, total: 15.25},
+		{name: "last scene", scene: 3, duration: 2, placement: [][3]float64{{1, 0, 8}, {2, 8, 6}, {3, 14, 2}}, total: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := threeSceneJob()
+			timing := planSceneTrim(job, tt.scene, tt.duration)
+			require.Equal(t, tt.placement, scenePlacement(timing.Scenes))
+			require.Equal(t, tt.total, timing.ContentDuration)
+			require.Equal(t, threeSceneJob(), job, "planning leaves the job as it was")
+		})
+	}
+}
+
+func TestPlanSceneTrim_MovesWhatPlaysOverLaterScenes(t *testing.T) {
+	job := threeSceneJob()
+	job.EndCard = &domain.EndCard{Duration: 2}
+	job.SideEffectsText = "May cause dizziness."
+	job.SideEffectsStartTime = 15 // Three quarters of the 20s video
+	job.SceneVoiceovers = []domain.SceneVoiceover{
+		{SceneNumber: 1, URL: "s3://bucket/vo-1.mp3", StartTime: sceneVoiceoverLeadIn, Duration: 3},
+		{SceneNumber: 3, URL: "s3://bucket/vo-3.mp3", StartTime: 14 + sceneVoiceoverLeadIn, Duration: 2},
+	}
+
+	// Cutting scene 2 to 2s makes the video 16s
+	timing := planSceneTrim(job, 2, 2)
+	require.InDelta(t, 12, timing.SideEffectsStartTime, 0.001)
+	require.Equal(t, []domain.SceneVoiceover{
+		{SceneNumber: 1, URL: "s3://bucket/vo-1.mp3", StartTime: sceneVoiceoverLeadIn, Duration: 3},
+		{SceneNumber: 3, URL: "s3://bucket/vo-3.mp3", StartTime: 10 + sceneVoiceoverLeadIn, Duration: 2},
+	}, timing.SceneVoiceovers)
+
+	timing.apply(job)
+	require.Equal(t, 14, job.Duration)
+}
+
+func TestCurrentClipTrim(t *testing.T) {
+	job := threeSceneJob()
+	_, ok := currentClipTrim(job, 2)
+	require.False(t, ok)
+
+	trim := domain.ClipTrim{SourceURL: "s3://bucket/clip-2-v2.mp4", TrimStart: 1, OriginalDuration: 6, TrimmedDuration: 5}
+	job.ClipTrims = map[string]domain.ClipTrim{"scene-2-v3": trim}
+	job.SceneVersions[2] = 3
+	got, ok := currentClipTrim(job, 2)
+	require.True(t, ok)
+	require.Equal(t, trim, got)
+
+	// A regeneration after the trim replaces the trimmed clip
+	job.SceneVersions[2] = 4
+	_, ok = currentClipTrim(job, 2)
+	require.False(t, ok)
+}
+
+func TestTrimScene_RejectsRequests(t *testing.T) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	ctx := context.Background()
+	require.NoError(t, repo.CreateJob(ctx, threeSceneJob()))
+
+	processing := threeSceneJob()
+	processing.JobID, processing.Status = "job-processing", domain.StatusProcessing
+	require.NoError(t, repo.CreateJob(ctx, processing))
+
+	router := reorderRouter(NewRegenerateHandler(repo, nil, nil, nil, 0, "", nil, nil, nil, nil, zap.NewNop()))
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "invalid scene number", path: "/api/v1/jobs/job-reorder/scenes/zero/trim", body: `{"trim_start": 1}`, wantStatus: http.StatusBadRequest, wantField: "scene_number"},
+		{name: "invalid body", path: "/api/v1/jobs/job-reorder/scenes/1/trim", body: `{"trim_start": "1s"}`, wantStatus: http.StatusBadRequest},
+		{name: "scene out of range", path: "/api/v1/jobs/job-reorder/scenes/4/trim", body: `{"trim_start": 1}`, wantStatus: http.StatusUnprocessableEntity, wantField: "scene_number"},
+		{name: "nothing to trim", path: "/api/v1/jobs/job-reorder/scenes/1/trim", body: `{"trim_start": 0}`, wantStatus: http.StatusUnprocessableEntity, wantField: "trim_start"},
+		{name: "not completed", path: "/api/v1/jobs/job-processing/scenes/1/trim", body: `{"trim_start": 1}`, wantStatus: http.StatusBadRequest, wantField: "status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+			var body apierrors.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			if tt.wantField == "" {
+				return
+			}
+			details, _ := json.Marshal(body.Error.Details)
+			require.Contains(t, string(details), `"field":"`+tt.wantField+`"`)
+		})
+	}
+}
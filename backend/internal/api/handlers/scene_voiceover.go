@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// sceneVoiceoverLeadIn delays a scene's voiceover slightly after the cut when the clip has room
+const sceneVoiceoverLeadIn = 0.25
+
+func buildSceneVoiceoverKey(job *domain.Job, sceneNumber int) string {
+	return jobAssetPrefix(job) + fmt.Sprintf("audio/scene-%03d-voiceover.mp3", sceneNumber)
+}
+
+// sceneVoiceoverClip is a generated per-scene voiceover before it is placed on the timeline
+type sceneVoiceoverClip struct {
+	SceneNumber int
+	URL         string
+	Duration    float64
+}
+
+// hasSceneVoiceovers reports whether any scene carries its own voiceover text.
+// Jobs with a full narrator script skip scene voiceovers so the two never talk over each other.
+func hasSceneVoiceovers(job *domain.Job, scenes []domain.Scene) bool {
+	if job.Voice != "" && (job.AudioSpec.NarratorScript != "" || job.SideEffectsText != "") {
+		return false
+	}
+	for _, scene := range scenes {
+		if strings.TrimSpace(scene.VoiceoverText) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSceneVoiceovers synthesizes and uploads one TTS clip per scene with voiceover text.
+// Failures are logged and the scene is skipped; scene voiceovers never fail the job.
+func (h *GenerateHandler) generateSceneVoiceovers(ctx context.Context, job *domain.Job, scenes []domain.Scene) []sceneVoiceoverClip {
+	ttsAdapter, provider := h.ttsRouter.Resolve(job.TTSProvider)
+	if ttsAdapter == nil {
+		h.log(ctx).Warn("Skipping scene voiceovers (tts adapter not configured)")
+		return nil
+	}
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "scene-voiceovers")
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		h.log(ctx).Warn("Skipping scene voiceovers (failed to create temp dir)", zap.Error(err))
+		return nil
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var clips []sceneVoiceoverClip
+	for i, scene := range scenes {
+		text := strings.TrimSpace(scene.VoiceoverText)
+		if text == "" {
+			continue
+		}
+		sceneNumber := i + 1
+		voice := sceneVoiceoverVoice(scene, job, provider)
+
+		stepCtx := h.withProvenance(ctx, job, fmt.Sprintf("scene_%d_voiceover", sceneNumber))
+		clip, err := h.generateSceneVoiceover(stepCtx, ttsAdapter, job, sceneNumber, text, voice, tmpDir)
+		if err != nil {
+			h.log(ctx).Warn("Failed to generate scene voiceover, skipping scene",
+				zap.Int("scene", sceneNumber),
+				zap.Error(err),
+			)
+			continue
+		}
+		clips = append(clips, clip)
+	}
+
+	h.log(ctx).Info("Scene voiceovers generated",
+		zap.Int("clips", len(clips)),
+	)
+	return clips
+}
+
+func (h *GenerateHandler) generateSceneVoiceover(
+	ctx context.Context,
+	ttsAdapter adapters.TTSAdapter,
+	job *domain.Job,
+	sceneNumber int,
+	text string,
+	voice string,
+	tmpDir string,
+) (sceneVoiceoverClip, error) {
+	audioData, err := ttsAdapter.GenerateVoiceover(ctx, text, voice)
+	if err != nil {
+		return sceneVoiceoverClip{}, fmt.Errorf("tts generation failed: %w", err)
+	}
+
+	audioPath := filepath.Join(tmpDir, fmt.Sprintf("scene-%03d-voiceover.mp3", sceneNumber))
+	if err := os.WriteFile(audioPath, audioData, 0o644); err != nil {
+		return sceneVoiceoverClip{}, fmt.Errorf("failed to write scene voiceover: %w", err)
+	}
+
+	duration, err := composition.ProbeAudioDuration(ctx, audioPath)
+	if err != nil {
+		return sceneVoiceoverClip{}, fmt.Errorf("failed to probe scene voiceover duration: %w", err)
+	}
+
+	audioPath, _ = h.normalizeAudioFile(ctx, job.JobID, fmt.Sprintf("scene-%d-voiceover", sceneNumber), audioPath, h.audioConfig.narrationTarget())
+
+	s3Key := buildSceneVoiceoverKey(job, sceneNumber)
+	url, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), s3Key, audioPath, "audio/mpeg")
+	if err != nil {
+		return sceneVoiceoverClip{}, fmt.Errorf("failed to upload scene voiceover: %w", err)
+	}
+
+	return sceneVoiceoverClip{SceneNumber: sceneNumber, URL: url, Duration: duration}, nil
+}
+
+// sceneVoiceoverVoice picks the scene's voice, falling back to the job voice, then "female"
+func sceneVoiceoverVoice(scene domain.Scene, job *domain.Job, provider adapters.TTSProvider) string {
+	voice := strings.ToLower(strings.TrimSpace(scene.VoiceoverVoice))
+	if voice == "male" || voice == "female" {
+		return voice
+	}
+	switch {
+	case job.Voice == "male" || job.Voice == "female":
+		return job.Voice
+	case job.Voice != "" && provider == adapters.TTSProviderElevenLabs:
+		return job.Voice // ElevenLabs voice ID
+	}
+	return "female"
+}
+
+// sceneVoiceoverTimings places voiceover clips on the final video timeline.
+// sceneDurations holds the actual clip length of each scene in order; a clip starts at its
+// scene's cut plus sceneVoiceoverLeadIn when the line still fits inside the scene.
+func sceneVoiceoverTimings(clips []sceneVoiceoverClip, sceneDurations []float64) []domain.SceneVoiceover {
+	sceneStarts := make([]float64, len(sceneDurations))
+	var elapsed float64
+	for i, d := range sceneDurations {
+		sceneStarts[i] = elapsed
+		elapsed += d
+	}
+
+	var timings []domain.SceneVoiceover
+	for _, clip := range clips {
+		idx := clip.SceneNumber - 1
+		if idx < 0 || idx >= len(sceneDurations) {
+			continue
+		}
+
+		start := sceneStarts[idx]
+		if clip.Duration+sceneVoiceoverLeadIn <= sceneDurations[idx] {
+			start += sceneVoiceoverLeadIn
+		}
+
+		timings = append(timings, domain.SceneVoiceover{
+			SceneNumber: clip.SceneNumber,
+			URL:         clip.URL,
+			StartTime:   roundSeconds(start),
+			Duration:    roundSeconds(clip.Duration),
+		})
+	}
+	return timings
+}
+
+// roundSeconds rounds to centiseconds for stable API output
+func roundSeconds(s float64) float64 {
+	return math.Round(s*100) / 100
+}
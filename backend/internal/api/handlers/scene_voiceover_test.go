@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSceneVoiceoverTimings(t *testing.T) {
+	sceneDurations := []float64{8.04, 7.96, 4.0}
+
+	clips := []sceneVoiceoverClip{
+		{SceneNumber: 1, URL: "s3://bucket/scene-001.mp3", Duration: 3.123},
+		{SceneNumber: 2, URL: "s3://bucket/scene-002.mp3", Duration: 7.9},  // no room for the lead-in
+		{SceneNumber: 3, URL: "s3://bucket/scene-003.mp3", Duration: 3.75}, // fits exactly with the lead-in
+		{SceneNumber: 4, URL: "s3://bucket/scene-004.mp3", Duration: 1.0},  // no such scene
+		{SceneNumber: 0, URL: "s3://bucket/scene-000.mp3", Duration: 1.0},  // invalid scene number
+	}
+
+	got := sceneVoiceoverTimings(clips, sceneDurations)
+
+	require.Equal(t, []domain.SceneVoiceover{
+		{SceneNumber: 1, URL: "s3://bucket/scene-001.mp3", StartTime: 0.25, Duration: 3.12},
+		{SceneNumber: 2, URL: "s3://bucket/scene-002.mp3", StartTime: 8.04, Duration: 7.9},
+		{SceneNumber: 3, URL: "s3://bucket/scene-003.mp3", StartTime: 16.25, Duration: 3.75},
+	}, got)
+}
+
+func TestSceneVoiceoverTimings_NoClips(t *testing.T) {
+	require.Nil(t, sceneVoiceoverTimings(nil, []float64{8, 8}))
+}
+
+func TestHasSceneVoiceovers(t *testing.T) {
+	scenes := []domain.Scene{{}, {VoiceoverText: "Feel the difference."}}
+
+	require.True(t, hasSceneVoiceovers(&domain.Job{}, scenes))
+	require.False(t, hasSceneVoiceovers(&domain.Job{}, []domain.Scene{{VoiceoverText: "  "}}))
+
+	narrated := &domain.Job{Voice: "female", AudioSpec: domain.AudioSpec{NarratorScript: "Meet Aero."}}
+	require.False(t, hasSceneVoiceovers(narrated, scenes), "narrator script takes precedence")
+
+	disclaimer := &domain.Job{Voice: "male", SideEffectsText: "May cause drowsiness."}
+	require.False(t, hasSceneVoiceovers(disclaimer, scenes), "side effects narration takes precedence")
+}
+
+func TestSceneVoiceoverVoice(t *testing.T) {
+	tests := []struct {
+		name       string
+		sceneVoice string
+		jobVoice   string
+		provider   adapters.TTSProvider
+		want       string
+	}{
+		{"scene voice wins", "Male", "female", adapters.TTSProviderOpenAI, "male"},
+		{"job voice fallback", "", "male", adapters.TTSProviderOpenAI, "male"},
+		{"unknown scene voice ignored", "narrator", "male", adapters.TTSProviderOpenAI, "male"},
+		{"elevenlabs voice id", "", "customVoiceAbc123XYZ", adapters.TTSProviderElevenLabs, "customVoiceAbc123XYZ"},
+		{"voice id without elevenlabs", "", "customVoiceAbc123XYZ", adapters.TTSProviderOpenAI, "female"},
+		{"default", "", "", adapters.TTSProviderOpenAI, "female"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scene := domain.Scene{VoiceoverVoice: tt.sceneVoice}
+			job := &domain.Job{Voice: tt.jobVoice}
+			require.Equal(t, tt.want, sceneVoiceoverVoice(scene, job, tt.provider))
+		})
+	}
+}
+
+func TestBuildSceneVoiceoverKey(t *testing.T) {
+	require.Equal(t, "users/user-1/jobs/job-1/audio/scene-002-voiceover.mp3", buildSceneVoiceoverKey(&domain.Job{UserID: "user-1", JobID: "job-1"}, 2))
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ListScripts page size bounds
+const (
+	defaultScriptsPageSize = 20
+	maxScriptsPageSize     = 100
+)
+
+// ScriptsHandler serves the script library: scripts written for earlier jobs, kept for reuse
+type ScriptsHandler struct {
+	scriptRepo repository.ScriptsRepository
+	logger     *zap.Logger
+}
+
+// NewScriptsHandler creates a new script library handler
+func NewScriptsHandler(scriptRepo repository.ScriptsRepository, logger *zap.Logger) *ScriptsHandler {
+	return &ScriptsHandler{
+		scriptRepo: scriptRepo,
+		logger:     logger,
+	}
+}
+
+// ListScriptsResponse is one page of the user's scripts, newest first
+type ListScriptsResponse struct {
+	Scripts    []*domain.Script `json:"scripts"`
+	Count      int              `json:"count"`
+	PageSize   int              `json:"page_size"`
+	NextCursor string           `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// ListScripts handles GET /api/v1/scripts
+// @Summary List stored scripts
+// @Description Get a page of the scripts written for the user's jobs, newest first. Scripts expire 30 days after they were written.
+// @Tags scripts
+// @Produce json
+// @Param cursor query string false "Opaque cursor from a previous response's next_cursor"
+// @Param page_size query int false "Page size (1-100)" default(20)
+// @Success 200 {object} ListScriptsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/scripts [get]
+// @Security BearerAuth
+func (h *ScriptsHandler) ListScripts(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultScriptsPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxScriptsPageSize {
+		pageSize = defaultScriptsPageSize
+	}
+	startKey, err := repository.DecodeScriptsCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+
+	page, err := h.scriptRepo.ListScriptsByUser(c.Request.Context(), userID, repository.ScriptsQuery{
+		Limit:             pageSize,
+		ExclusiveStartKey: startKey,
+	})
+	if stderrors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list scripts", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	nextCursor, err := repository.EncodeJobsCursor(page.LastEvaluatedKey)
+	if err != nil {
+		h.logger.Error("Failed to encode pagination cursor", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	scripts := page.Scripts
+	if scripts == nil {
+		scripts = []*domain.Script{}
+	}
+	c.JSON(http.StatusOK, ListScriptsResponse{
+		Scripts:    scripts,
+		Count:      len(scripts),
+		PageSize:   pageSize,
+		NextCursor: nextCursor,
+	})
+}
+
+// GetScript handles GET /api/v1/scripts/:id
+// @Summary Get a stored script
+// @Description Get one of the user's stored scripts with its scenes, audio and the job settings it was written for
+// @Tags scripts
+// @Produce json
+// @Param id path string true "Script ID"
+// @Success 200 {object} domain.Script
+// @Failure 404 {object} errors.ErrorResponse "Script not found or expired"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/scripts/{id} [get]
+// @Security BearerAuth
+func (h *ScriptsHandler) GetScript(c *gin.Context) {
+	script, apiErr := loadOwnedScript(c.Request.Context(), h.scriptRepo, h.logger, auth.MustGetUserID(c), c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, errors.ErrorResponse{Error: apiErr})
+		return
+	}
+	c.JSON(http.StatusOK, script)
+}
+
+// loadOwnedScript loads a user's unexpired script. Scripts owned by another user are
+// reported as missing (security check).
+func loadOwnedScript(ctx context.Context, scripts repository.ScriptsRepository, logger *zap.Logger, userID, scriptID string) (*domain.Script, *errors.APIError) {
+	script, err := scripts.GetScript(ctx, scriptID)
+	if stderrors.Is(err, repository.ErrScriptNotFound) {
+		return nil, errors.ErrScriptNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to load script", zap.String("script_id", scriptID), zap.Error(err))
+		return nil, errors.ErrDatabaseError
+	}
+	if script.UserID != userID {
+		return nil, errors.ErrScriptNotFound
+	}
+	return script, nil
+}
+
+// saveScript stores a copy of a job's new script in the script library and records its ID on
+// the job. The library is a convenience, so a failed write is only logged.
+func (h *GenerateHandler) saveScript(ctx context.Context, job *domain.Job) {
+	if h.scripts == nil {
+		return
+	}
+
+	now := time.Now()
+	script := scriptFromJob(job)
+	script.ScriptID = fmt.Sprintf("script-%s", uuid.New().String())
+	script.CreatedAt = now.Unix()
+	script.UpdatedAt = now.Unix()
+	script.Status = domain.ScriptStatusGenerated
+	script.ExpiresAt = now.Add(repository.ScriptRetention).Unix()
+
+	script.SourceJobID = job.JobID
+	script.Prompt = job.Prompt
+	script.AspectRatio = job.AspectRatio
+	script.Model = job.Model
+	script.StartImage = job.StartImage
+	script.Voice = job.Voice
+	script.TTSProvider = job.TTSProvider
+	script.SideEffects = job.SideEffects
+
+	if err := h.scripts.SaveScript(ctx, script); err != nil {
+		h.log(ctx).Warn("Failed to store script in the library", zap.Error(err))
+		return
+	}
+	job.ScriptID = script.ScriptID
+}
+
+// FromScriptRequest overrides settings of the stored script's job; empty fields keep them
+type FromScriptRequest struct {
+	AspectRatio string `json:"aspect_ratio,omitempty"` // 16:9, 9:16 or 1:1
+	Model       string `json:"model,omitempty"`        // veo or kling; every scene must be a clip length it supports
+	StartImage  string `json:"start_image,omitempty"`  // Product image for the last scene
+}
+
+// GenerateFromScript handles POST /api/v1/generate/from-script/:id
+// @Summary Generate video from a stored script
+// @Description Starts a new job from one of the user's stored scripts, skipping script generation.
+// @Description The aspect ratio, model and start image may be overridden; the script's scene lengths
+// @Description must all be clip lengths the chosen model can generate.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Script ID"
+// @Param request body FromScriptRequest false "Settings to override"
+// @Success 202 {object} GenerateResponse
+// @Failure 400 {object} errors.ErrorResponse "Malformed JSON body"
+// @Failure 401 {object} errors.ErrorResponse "Unauthorized"
+// @Failure 402 {object} errors.ErrorResponse "Not enough credits"
+// @Failure 404 {object} errors.ErrorResponse "Script not found or expired"
+// @Failure 422 {object} errors.ErrorResponse "Field validation errors, or referenced assets failed verification (ASSET_NOT_VERIFIED)"
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/generate/from-script/{id} [post]
+// @Security BearerAuth
+func (h *GenerateHandler) GenerateFromScript(c *gin.Context) {
+	var req FromScriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !stderrors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	userID := auth.MustGetUserID(c)
+	script, apiErr := loadOwnedScript(c.Request.Context(), h.scripts, h.logger, userID, c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, errors.ErrorResponse{Error: apiErr})
+		return
+	}
+
+	aspectRatio := script.AspectRatio
+	if req.AspectRatio != "" {
+		aspectRatio = req.AspectRatio
+	}
+	model := script.Model
+	if req.Model != "" {
+		model = req.Model
+	}
+	startImage := script.StartImage
+	if s := strings.TrimSpace(req.StartImage); s != "" {
+		startImage = s
+	}
+
+	sceneDurations := make([]float64, len(script.Scenes))
+	for i, scene := range script.Scenes {
+		sceneDurations[i] = scene.Duration
+	}
+	if errs := validation.ValidateFromScript(validation.FromScriptInput{
+		AspectRatio:    aspectRatio,
+		Model:          model,
+		StartImage:     startImage,
+		Pharmaceutical: script.Voice != "" || script.SideEffects != "",
+		SceneDurations: sceneDurations,
+	}); len(errs) > 0 {
+		h.logger.Info("Generate from script request failed validation",
+			zap.String("script_id", script.ScriptID),
+			zap.String("errors", errs.Error()),
+		)
+		respondValidationErrors(c, errs)
+		return
+	}
+	if h.assets != nil {
+		if apiErr := h.assets.require(c.Request.Context(), userID, jobAssetRefs(h.locator, userID, GenerateRequest{StartImage: startImage})); apiErr != nil {
+			c.JSON(apiErr.Status, errors.ErrorResponse{Error: apiErr})
+			return
+		}
+	}
+	adapterType, _ := adapters.ParseAdapterType(model)
+	ttsProvider := h.resolveTTSProvider(GenerateRequest{TTSProvider: script.TTSProvider})
+
+	jobID := fmt.Sprintf("job-%s", uuid.New().String())
+	now := time.Now().Unix()
+	job := &domain.Job{
+		JobID:       jobID,
+		UserID:      userID,
+		ScriptID:    script.ScriptID,
+		Status:      domain.StatusProcessing,
+		Stage:       domain.StageScriptComplete,
+		Prompt:      script.Prompt,
+		Duration:    script.TotalDuration,
+		AspectRatio: aspectRatio,
+		StartImage:  startImage,
+		Model:       string(adapterType),
+
+		Voice:       script.Voice,
+		SideEffects: script.SideEffects,
+		TTSProvider: string(ttsProvider),
+
+		RenderTransitions: h.sceneTransitions,
+
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	job.ExpiresAt, job.TTL = jobExpiry(time.Unix(now, 0), h.retentionDays)
+
+	// The new job has none of the original job's product photos or continuity frames
+	scenes := make([]domain.Scene, len(script.Scenes))
+	for i, scene := range script.Scenes {
+		scene.StartImageURL = ""
+		scene.AssignedImageRef = ""
+		scenes[i] = scene
+	}
+	stored := *script
+	stored.Scenes = scenes
+	h.embedScript(c.Request.Context(), job, &stored)
+
+	if err := h.assignAssetLocation(c.Request.Context(), job); err != nil {
+		h.logger.Error("Failed to assign job asset location", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	if h.usageService != nil {
+		cost := pricing.QuoteJob(job.Duration, len(scenes), adapterType)
+		if _, err := h.usageService.ChargeJob(c.Request.Context(), job, subscriptionTier(c), cost); err != nil {
+			respondChargeError(c, h.logger, userID, err, cost)
+			return
+		}
+	}
+
+	startNow, err := h.saveNewJob(c.Request.Context(), job, h.jobRepo.CreateJob)
+	if err != nil {
+		h.logger.Error("Failed to create job", zap.Error(err))
+		h.refundCredits(job)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+	if startNow {
+		h.startSavedJob(c.Request.Context(), job)
+	}
+
+	h.logger.Info("Job created from stored script",
+		zap.String("job_id", jobID),
+		zap.String("script_id", script.ScriptID),
+		zap.String("aspect_ratio", job.AspectRatio),
+		zap.String("model", job.Model),
+		zap.Int("num_scenes", len(job.Scenes)),
+		zap.Bool("started", startNow),
+	)
+
+	c.JSON(http.StatusAccepted, GenerateResponse{
+		JobID:               jobID,
+		Status:              job.Status,
+		NumClips:            len(job.Scenes),
+		CreatedAt:           job.CreatedAt,
+		EstimatedCompletion: EstimatedCompletionSeconds,
+	})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// storedVeoScript is a library script written for Veo's 8 second clips
+func storedVeoScript(id, userID string, expiresAt int64) *domain.Script {
+	return &domain.Script{
+		ScriptID:      id,
+		UserID:        userID,
+		Title:         "Cold Brew Mornings",
+		TotalDuration: 16,
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Duration: 8, GenerationPrompt: storedScenePrompt, StartImageURL: "https://example.com/old-frame.jpg"},
+			{SceneNumber: 2, Duration: 8, GenerationPrompt: storedScenePrompt, AssignedImageRef: "img-1"},
+		},
+		AudioSpec:   domain.AudioSpec{EnableAudio: true, MusicMood: "calm"},
+		CreatedAt:   1000,
+		UpdatedAt:   1000,
+		Status:      domain.ScriptStatusGenerated,
+		ExpiresAt:   expiresAt,
+		SourceJobID: "job-source",
+		Prompt:      "Launch ad for a cold brew coffee",
+		AspectRatio: "16:9",
+		Model:       "veo",
+	}
+}
+
+// scriptsTestRouter serves the script routes as user-123. The user already has an active
+// job at a limit of one, so jobs created from scripts are queued rather than run.
+func scriptsTestRouter(t *testing.T) (*gin.Engine, *repository.DynamoDBRepository, *repository.DynamoDBScriptRepository) {
+	dynamo := repository.NewLocalDynamoDB()
+	jobRepo := dynamo.JobRepository("jobs", zap.NewNop())
+	scriptRepo := dynamo.ScriptRepository("scripts", zap.NewNop())
+
+	now := time.Now().Unix()
+	require.NoError(t, jobRepo.CreateJob(context.Background(), &domain.Job{
+		JobID:     "job-active",
+		UserID:    "user-123",
+		Status:    domain.StatusProcessing,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	// No script generator: a rerun must never call GPT-4o
+	generate := NewGenerateHandler(GenerateHandlerDeps{
+		JobRepo:              jobRepo,
+		ScriptRepo:           scriptRepo,
+		MaxActiveJobsPerUser: 1,
+	}, zap.NewNop())
+	scripts := NewScriptsHandler(scriptRepo, zap.NewNop())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.GET("/scripts", scripts.ListScripts)
+	v1.GET("/scripts/:id", scripts.GetScript)
+	v1.POST("/generate/from-script/:id", generate.GenerateFromScript)
+	return router, jobRepo, scriptRepo
+}
+
+// serveScripts sends a request to the script routes, with body as JSON unless it is nil
+func serveScripts(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(data)))
+	return w
+}
+
+func TestGenerateFromScript_StartsJobFromStoredScript(t *testing.T) {
+	router, jobRepo, scriptRepo := scriptsTestRouter(t)
+	future := time.Now().Add(repository.ScriptRetention).Unix()
+	require.NoError(t, scriptRepo.SaveScript(context.Background(), storedVeoScript("script-1", "user-123", future)))
+
+	w := serveScripts(router, http.MethodPost, "/api/v1/generate/from-script/script-1", FromScriptRequest{
+		AspectRatio: "9:16",
+		StartImage:  "https://example.com/new-product.png",
+	})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response GenerateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 2, response.NumClips)
+
+	job, err := jobRepo.GetJob(context.Background(), response.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusQueued, job.Status)
+	require.Equal(t, "script-1", job.ScriptID)
+	require.Equal(t, "9:16", job.AspectRatio, "overridden")
+	require.Equal(t, "veo", job.Model, "kept from the script")
+	require.Equal(t, "https://example.com/new-product.png", job.StartImage)
+	require.Equal(t, 16, job.Duration)
+	require.Equal(t, "Cold Brew Mornings", job.Title)
+	require.Len(t, job.Scenes, 2)
+	require.Empty(t, job.Scenes[0].StartImageURL, "the old job's frames are not reused")
+	require.Empty(t, job.Scenes[1].AssignedImageRef)
+
+	// A job with a script resumes from it when it leaves the queue
+	require.Equal(t, "calm", scriptFromJob(job).AudioSpec.MusicMood)
+}
+
+func TestGenerateFromScript_RevalidatesSceneDurationsForModel(t *testing.T) {
+	router, jobRepo, scriptRepo := scriptsTestRouter(t)
+	future := time.Now().Add(repository.ScriptRetention).Unix()
+	require.NoError(t, scriptRepo.SaveScript(context.Background(), storedVeoScript("script-1", "user-123", future)))
+
+	// Kling generates 5 and 10 second clips, so Veo's 8 second scenes can't be reused
+	w := serveScripts(router, http.MethodPost, "/api/v1/generate/from-script/script-1", FromScriptRequest{Model: "kling"})
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	var response errors.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var fieldErrors []validation.FieldError
+	data, _ := json.Marshal(response.Error.Details["errors"])
+	require.NoError(t, json.Unmarshal(data, &fieldErrors))
+	require.Len(t, fieldErrors, 2)
+	require.Equal(t, "scenes[0].duration", fieldErrors[0].Field)
+	require.Equal(t, []string{"5", "10"}, fieldErrors[0].AllowedValues)
+
+	page, err := jobRepo.GetJobsByUser(context.Background(), "user-123", repository.JobsQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Jobs, 1, "no job was created")
+}
+
+func TestGenerateFromScript_HidesOtherUsersAndExpiredScripts(t *testing.T) {
+	router, _, scriptRepo := scriptsTestRouter(t)
+	ctx := context.Background()
+	future := time.Now().Add(repository.ScriptRetention).Unix()
+	require.NoError(t, scriptRepo.SaveScript(ctx, storedVeoScript("script-other", "user-456", future)))
+	require.NoError(t, scriptRepo.SaveScript(ctx, storedVeoScript("script-expired", "user-123", time.Now().Unix()-60)))
+
+	for _, id := range []string{"script-other", "script-expired", "script-missing"} {
+		w := serveScripts(router, http.MethodPost, "/api/v1/generate/from-script/"+id, nil)
+		require.Equal(t, http.StatusNotFound, w.Code, id)
+		require.Contains(t, w.Body.String(), errors.ErrScriptNotFound.Code)
+
+		w = serveScripts(router, http.MethodGet, "/api/v1/scripts/"+id, nil)
+		require.Equal(t, http.StatusNotFound, w.Code, id)
+	}
+}
+
+func TestListScripts_Paginates(t *testing.T) {
+	router, _, scriptRepo := scriptsTestRouter(t)
+	ctx := context.Background()
+	future := time.Now().Add(repository.ScriptRetention).Unix()
+	for i, id := range []string{"script-1", "script-2", "script-3"} {
+		script := storedVeoScript(id, "user-123", future)
+		script.CreatedAt = int64(1000 + i)
+		require.NoError(t, scriptRepo.SaveScript(ctx, script))
+	}
+	require.NoError(t, scriptRepo.SaveScript(ctx, storedVeoScript("script-other", "user-456", future)))
+
+	list := func(query string) ListScriptsResponse {
+		w := serveScripts(router, http.MethodGet, "/api/v1/scripts"+query, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response ListScriptsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	first := list("?page_size=2")
+	require.Equal(t, 2, first.Count)
+	require.Equal(t, "script-3", first.Scripts[0].ScriptID)
+	require.NotEmpty(t, first.NextCursor)
+
+	second := list("?page_size=2&cursor=" + first.NextCursor)
+	require.Equal(t, 1, second.Count)
+	require.Equal(t, "script-1", second.Scripts[0].ScriptID)
+	require.Empty(t, second.NextCursor)
+
+	w := serveScripts(router, http.MethodGet, "/api/v1/scripts?cursor=garbage", nil)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSaveScript_StoresJobScriptInLibrary(t *testing.T) {
+	scriptRepo := repository.NewLocalDynamoDB().ScriptRepository("scripts", zap.NewNop())
+	h := &GenerateHandler{scripts: scriptRepo, logger: zap.NewNop()}
+	job := &domain.Job{
+		JobID:           "job-1",
+		UserID:          "user-123",
+		Prompt:          "Launch ad for Restura",
+		AspectRatio:     "16:9",
+		Model:           "veo",
+		Voice:           "female",
+		SideEffects:     pharmaSideEffects,
+		Title:           "Restura",
+		Scenes:          []domain.Scene{{SceneNumber: 1, Duration: 8}, {SceneNumber: 2, Duration: 8}},
+		SideEffectsText: pharmaSideEffects,
+	}
+
+	h.saveScript(context.Background(), job)
+	require.NotEmpty(t, job.ScriptID)
+
+	script, err := scriptRepo.GetScript(context.Background(), job.ScriptID)
+	require.NoError(t, err)
+	require.Equal(t, "user-123", script.UserID)
+	require.Equal(t, "job-1", script.SourceJobID)
+	require.Equal(t, 16, script.TotalDuration)
+	require.Equal(t, pharmaSideEffects, script.SideEffects)
+	require.Equal(t, domain.ScriptStatusGenerated, script.Status)
+	require.InDelta(t, time.Now().Add(repository.ScriptRetention).Unix(), script.ExpiresAt, 5)
+
+	// Without a library the job is left alone
+	job.ScriptID = ""
+	(&GenerateHandler{logger: zap.NewNop()}).saveScript(context.Background(), job)
+	require.Empty(t, job.ScriptID)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	scrubMaxTiles  = 50  // Frames in the sprite sheet at most
+	scrubColumns   = 10  // Tiles per sprite sheet row
+	scrubTileWidth = 160 // Pixels; the height keeps the video's shape
+
+	scrubSpriteName = "sprite.jpg" // Sprite sheet file name, as the WebVTT cues reference it
+)
+
+func buildScrubSpriteKey(job *domain.Job) string {
+	return jobAssetPrefix(job) + "thumbnails/scrub/" + scrubSpriteName
+}
+
+func buildScrubVTTKey(job *domain.Job) string {
+	return jobAssetPrefix(job) + "thumbnails/scrub/thumbnails.vtt"
+}
+
+// scrubGrid lays out a sprite sheet of frames taken every Interval seconds
+type scrubGrid struct {
+	Duration   float64 // Seconds of video the sheet covers
+	Interval   int     // Seconds between frames
+	Tiles      int
+	Columns    int
+	Rows       int
+	TileWidth  int
+	TileHeight int
+}
+
+// scrubCue is the tile of the sprite sheet shown while scrubbing from Start to End
+type scrubCue struct {
+	Start, End          float64
+	X, Y, Width, Height int
+}
+
+// planScrubGrid picks the whole number of seconds between frames that keeps the sprite sheet of
+// a duration second video to scrubMaxTiles tiles, and lays them out scrubColumns to a row. Tiles
+// are scrubTileWidth wide with the shape of a videoWidth x videoHeight frame (16:9 when unknown).
+// ok is false for a video without a duration.
+func planScrubGrid(duration float64, videoWidth, videoHeight int) (scrubGrid, bool) {
+	duration = roundMillis(duration)
+	if duration <= 0 {
+		return scrubGrid{}, false
+	}
+	if videoWidth <= 0 || videoHeight <= 0 {
+		videoWidth, videoHeight = 1920, 1080
+	}
+
+	interval := max(1, int(math.Ceil(duration/scrubMaxTiles)))
+	tiles := int(math.Ceil(duration / float64(interval)))
+	columns := min(tiles, scrubColumns)
+	return scrubGrid{
+		Duration:   duration,
+		Interval:   interval,
+		Tiles:      tiles,
+		Columns:    columns,
+		Rows:       (tiles + columns - 1) / columns,
+		TileWidth:  scrubTileWidth,
+		TileHeight: max(1, int(math.Round(float64(scrubTileWidth*videoHeight)/float64(videoWidth)))),
+	}, true
+}
+
+// cues maps each tile, left to right and top to bottom, to the stretch of video it previews.
+// The last one ends with the video.
+func (g scrubGrid) cues() []scrubCue {
+	cues := make([]scrubCue, g.Tiles)
+	for i := range cues {
+		cues[i] = scrubCue{
+			Start:  float64(i * g.Interval),
+			End:    math.Min(float64((i+1)*g.Interval), g.Duration),
+			X:      i % g.Columns * g.TileWidth,
+			Y:      i / g.Columns * g.TileHeight,
+			Width:  g.TileWidth,
+			Height: g.TileHeight,
+		}
+	}
+	return cues
+}
+
+// buildScrubVTT renders the cues as a WebVTT thumbnail track pointing into sprite
+func buildScrubVTT(sprite string, cues []scrubCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(cue.Start), vttTimestamp(cue.End), sprite, cue.X, cue.Y, cue.Width, cue.Height)
+	}
+	return b.String()
+}
+
+// scrubSpriteArgs takes a frame of input every grid interval, from the first one, and tiles the
+// frames into a single JPEG
+func scrubSpriteArgs(input, output string, grid scrubGrid) []string {
+	return []string{
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d", grid.Interval, grid.TileWidth, grid.TileHeight, grid.Columns, grid.Rows),
+		"-frames:v", "1",
+		"-q:v", "5",
+		"-an",
+		"-y", output,
+	}
+}
+
+// publishScrubSprite builds the hover-scrub preview of the final video at videoKey: a sprite
+// sheet of its frames and a WebVTT index into it, uploaded under thumbnails/scrub/ and recorded
+// on the job. Previews never fail the job; the previous ones are kept if this fails.
+func publishScrubSprite(ctx context.Context, s3Service *repository.S3AssetRepository, bucket string, logger *zap.Logger, job *domain.Job, videoKey string) {
+	tmpDir := filepath.Join("/tmp", job.JobID, "scrub")
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		logger.Warn("Skipping scrub preview (failed to create temp dir)", zap.Error(err))
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	videoPath := filepath.Join(tmpDir, "video"+filepath.Ext(videoKey))
+	if err := s3Service.DownloadFile(ctx, bucket, videoKey, videoPath); err != nil {
+		logger.Warn("Skipping scrub preview (failed to download final video)", zap.Error(err))
+		return
+	}
+	duration, err := composition.ProbeAudioDuration(ctx, videoPath) // Container duration
+	if err != nil {
+		logger.Warn("Skipping scrub preview (failed to probe final video)", zap.Error(err))
+		return
+	}
+	width, height, err := composition.ProbeVideoDimensions(ctx, videoPath)
+	if err != nil {
+		width, height, _ = composition.StandardDimensions(job.AspectRatio)
+	}
+	grid, ok := planScrubGrid(duration, width, height)
+	if !ok {
+		return
+	}
+
+	spritePath := filepath.Join(tmpDir, scrubSpriteName)
+	if err := ffmpegexec.Exec(ctx, "scrub_sprite", ffmpegexec.Spec{Args: scrubSpriteArgs(videoPath, spritePath, grid), Timeout: ffmpegexec.EncodeTimeout, LowPriority: true}); err != nil {
+		logger.Warn("Skipping scrub preview (failed to tile frames)", zap.Error(err))
+		return
+	}
+	vttPath := filepath.Join(tmpDir, "thumbnails.vtt")
+	if err := os.WriteFile(vttPath, []byte(buildScrubVTT(scrubSpriteName, grid.cues())), 0o644); err != nil {
+		logger.Warn("Skipping scrub preview (failed to write WebVTT)", zap.Error(err))
+		return
+	}
+
+	spriteKey := buildScrubSpriteKey(job)
+	if _, err := s3Service.UploadJobAsset(ctx, bucket, spriteKey, spritePath, "image/jpeg"); err != nil {
+		logger.Warn("Failed to upload scrub sprite sheet", zap.Error(err))
+		return
+	}
+	vttKey := buildScrubVTTKey(job)
+	if _, err := s3Service.UploadJobAsset(ctx, bucket, vttKey, vttPath, "text/vtt"); err != nil {
+		logger.Warn("Failed to upload scrub WebVTT", zap.Error(err))
+		return
+	}
+	job.ScrubSpriteKey = spriteKey
+	job.ScrubVTTKey = vttKey
+
+	logger.Info("Scrub preview uploaded",
+		zap.Int("tiles", grid.Tiles),
+		zap.Int("interval_seconds", grid.Interval),
+	)
+}
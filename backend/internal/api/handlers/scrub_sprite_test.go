@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanScrubGrid(t *testing.T) {
+	tests := []struct {
+		name          string
+		duration      float64
+		width, height int
+		want          scrubGrid
+	}{
+		{
+			name:     "one frame a second",
+			duration: 30, width: 1920, height: 1080,
+			want: scrubGrid{Duration: 30, Interval: 1, Tiles: 30, Columns: 10, Rows: 3, TileWidth: 160, TileHeight: 90},
+		},
+		{
+			name:     "fifty seconds fills the sheet",
+			duration: 50, width: 1920, height: 1080,
+			want: scrubGrid{Duration: 50, Interval: 1, Tiles: 50, Columns: 10, Rows: 5, TileWidth: 160, TileHeight: 90},
+		},
+		{
+			name:     "longer videos space frames out",
+			duration: 120, width: 1920, height: 1080,
+			want: scrubGrid{Duration: 120, Interval: 3, Tiles: 40, Columns: 10, Rows: 4, TileWidth: 160, TileHeight: 90},
+		},
+		{
+			name:     "not divisible by the interval",
+			duration: 61, width: 1920, height: 1080,
+			want: scrubGrid{Duration: 61, Interval: 2, Tiles: 31, Columns: 10, Rows: 4, TileWidth: 160, TileHeight: 90},
+		},
+		{
+			name:     "vertical video",
+			duration: 16.04, width: 1080, height: 1920,
+			want: scrubGrid{Duration: 16.04, Interval: 1, Tiles: 17, Columns: 10, Rows: 2, TileWidth: 160, TileHeight: 284},
+		},
+		{
+			name:     "sub-second video",
+			duration: 0.6, width: 1080, height: 1080,
+			want: scrubGrid{Duration: 0.6, Interval: 1, Tiles: 1, Columns: 1, Rows: 1, TileWidth: 160, TileHeight: 160},
+		},
+		{
+			name:     "unknown dimensions are 16:9",
+			duration: 8,
+			want:     scrubGrid{Duration: 8, Interval: 1, Tiles: 8, Columns: 8, Rows: 1, TileWidth: 160, TileHeight: 90},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid, ok := planScrubGrid(tt.duration, tt.width, tt.height)
+			require.True(t, ok)
+			require.Equal(t, tt.want, grid)
+			require.LessOrEqual(t, grid.Tiles, scrubMaxTiles)
+			require.LessOrEqual(t, grid.Tiles, grid.Columns*grid.Rows)
+		})
+	}
+
+	_, ok := planScrubGrid(0, 1920, 1080)
+	require.False(t, ok, "no duration, no preview")
+}
+
+func TestScrubGridCues(t *testing.T) {
+	grid, ok := planScrubGrid(61, 1920, 1080)
+	require.True(t, ok)
+	cues := grid.cues()
+
+	require.Len(t, cues, 31)
+	require.Equal(t, scrubCue{Start: 0, End: 2, X: 0, Y: 0, Width: 160, Height: 90}, cues[0])
+	require.Equal(t, scrubCue{Start: 18, End: 20, X: 1440, Y: 0, Width: 160, Height: 90}, cues[9], "last tile of the first row")
+	require.Equal(t, scrubCue{Start: 20, End: 22, X: 0, Y: 90, Width: 160, Height: 90}, cues[10], "wraps to the next row")
+	require.Equal(t, scrubCue{Start: 60, End: 61, X: 0, Y: 270, Width: 160, Height: 90}, cues[30], "ends with the video")
+}
+
+func TestBuildScrubVTT(t *testing.T) {
+	t.Run("not divisible by the interval", func(t *testing.T) {
+		grid, _ := planScrubGrid(2.5, 1920, 1080)
+		require.Equal(t, "WEBVTT\n"+
+			"\n00:00:00.000 --> 00:00:01.000\nsprite.jpg#xywh=0,0,160,90\n"+
+			"\n00:00:01.000 --> 00:00:02.000\nsprite.jpg#xywh=160,0,160,90\n"+
+			"\n00:00:02.000 --> 00:00:02.500\nsprite.jpg#xywh=320,0,160,90\n",
+			buildScrubVTT(scrubSpriteName, grid.cues()))
+	})
+
+	t.Run("sub-second total", func(t *testing.T) {
+		grid, _ := planScrubGrid(0.4567, 1080, 1080)
+		require.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:00.457\nsprite.jpg#xywh=0,0,160,160\n",
+			buildScrubVTT(scrubSpriteName, grid.cues()))
+	})
+
+	t.Run("past a minute", func(t *testing.T) {
+		grid, _ := planScrubGrid(61, 1920, 1080)
+		vtt := buildScrubVTT(scrubSpriteName, grid.cues())
+		require.Contains(t, vtt, "\n00:00:58.000 --> 00:01:00.000\nsprite.jpg#xywh=1440,180,160,90\n")
+		require.Contains(t, vtt, "\n00:01:00.000 --> 00:01:01.000\nsprite.jpg#xywh=0,270,160,90\n")
+	})
+}
+
+func TestScrubSpriteArgs(t *testing.T) {
+	grid, _ := planScrubGrid(120, 1920, 1080)
+	require.Equal(t, []string{
+		"-i", "final.mp4",
+		"-vf", "fps=1/3,scale=160:90,tile=10x4",
+		"-frames:v", "1",
+		"-q:v", "5",
+		"-an",
+		"-y", "sprite.jpg",
+	}, scrubSpriteArgs("final.mp4", "sprite.jpg", grid))
+}
+
+func TestScrubKeys(t *testing.T) {
+	job := &domain.Job{UserID: "u1", JobID: "j1"}
+	require.Equal(t, "users/u1/jobs/j1/thumbnails/scrub/sprite.jpg", buildScrubSpriteKey(job))
+	require.Equal(t, "users/u1/jobs/j1/thumbnails/scrub/thumbnails.vtt", buildScrubVTTKey(job))
+}
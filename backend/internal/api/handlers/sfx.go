@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+var sfxSlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func buildSFXKey(job *domain.Job, timestamp float64, description string) string {
+	return jobAssetPrefix(job) + fmt.Sprintf("audio/sfx/%.2f-%s.mp3", timestamp, sfxSlug(description))
+}
+
+// sfxSlug turns a sound description into a short S3-safe name
+func sfxSlug(description string) string {
+	slug := strings.Trim(sfxSlugPattern.ReplaceAllString(strings.ToLower(description), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	if slug == "" {
+		return "sfx"
+	}
+	return slug
+}
+
+// sfxSyncPoints returns the script's "sfx" sync points that fall inside the video
+func sfxSyncPoints(points []domain.SyncPoint, videoDuration float64) []domain.SyncPoint {
+	var sfx []domain.SyncPoint
+	for _, p := range points {
+		if p.Type != "sfx" || strings.TrimSpace(p.Description) == "" {
+			continue
+		}
+		if p.Timestamp < 0 || (videoDuration > 0 && p.Timestamp >= videoDuration) {
+			continue
+		}
+		sfx = append(sfx, p)
+	}
+	return sfx
+}
+
+// generateSFX generates, trims and uploads one sound effect per sync point.
+// Individual failures are logged and skipped; sound effects never fail the job.
+func (h *GenerateHandler) generateSFX(ctx context.Context, job *domain.Job, points []domain.SyncPoint) []domain.SFXClip {
+	if h.sfxAdapter == nil || len(points) == 0 {
+		return nil
+	}
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "sfx")
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		h.log(ctx).Warn("Skipping sound effects (failed to create temp dir)", zap.Error(err))
+		return nil
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var clips []domain.SFXClip
+	for i, point := range points {
+		url, err := h.generateSFXClip(ctx, job, point, filepath.Join(tmpDir, fmt.Sprintf("sfx-%03d", i)))
+		if err != nil {
+			h.log(ctx).Warn("Failed to generate sound effect, skipping",
+				zap.Float64("timestamp", point.Timestamp),
+				zap.String("description", point.Description),
+				zap.Error(err),
+			)
+			continue
+		}
+		clips = append(clips, domain.SFXClip{
+			Timestamp:   point.Timestamp,
+			Description: point.Description,
+			URL:         url,
+		})
+	}
+
+	h.log(ctx).Info("Sound effects generated",
+		zap.Int("requested", len(points)),
+		zap.Int("generated", len(clips)),
+	)
+	return clips
+}
+
+// generateSFXClip generates one sound effect and uploads it, returning the S3 URL.
+// pathPrefix is the temp file path without extension.
+func (h *GenerateHandler) generateSFXClip(ctx context.Context, job *domain.Job, point domain.SyncPoint, pathPrefix string) (string, error) {
+	maxDuration := h.audioConfig.SFXMaxDuration
+	if maxDuration <= 0 {
+		maxDuration = DefaultSFXMaxDuration
+	}
+
+	audioURL, err := requestSFX(ctx, h.sfxAdapter, point.Description, maxDuration, h.logger)
+	if err != nil {
+		return "", err
+	}
+
+	rawPath := pathPrefix + "-raw.mp3"
+	if err := h.downloadFile(ctx, audioURL, rawPath); err != nil {
+		return "", fmt.Errorf("failed to download sound effect: %w", err)
+	}
+
+	trimmedPath := pathPrefix + ".mp3"
+	if err := trimSFX(ctx, rawPath, trimmedPath, maxDuration); err != nil {
+		return "", err
+	}
+
+	s3Key := buildSFXKey(job, point.Timestamp, point.Description)
+	url, err := h.s3Service.UploadJobAsset(ctx, jobAssetBucket(job, h.assetsBucket), s3Key, trimmedPath, "audio/mpeg")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload sound effect: %w", err)
+	}
+	return url, nil
+}
+
+// requestSFX submits a sound effect prediction and waits for its audio URL
+func requestSFX(ctx context.Context, generator adapters.SFXGenerator, prompt string, duration float64, logger *zap.Logger) (string, error) {
+	result, err := generator.GenerateSFX(ctx, &adapters.SFXGenerationRequest{
+		Prompt:   prompt,
+		Duration: duration,
+	})
+	if err != nil {
+		return "", fmt.Errorf("sfx API failed: %w", err)
+	}
+
+	if result.AudioURL == "" {
+		result, err = adapters.PollSFXUntilComplete(ctx, generator, result.PredictionID, sfxPollOptions(logger))
+		if err != nil {
+			return "", fmt.Errorf("sfx generation failed: %w", err)
+		}
+	}
+
+	if result.AudioURL == "" {
+		return "", fmt.Errorf("sfx prediction %s returned no audio", result.PredictionID)
+	}
+	return result.AudioURL, nil
+}
+
+// trimSFX cuts a sound effect to maxDuration with a short fade so the cut doesn't click
+func trimSFX(ctx context.Context, inputPath, outputPath string, maxDuration float64) error {
+	const fade = 0.15
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", inputPath,
+		"-t", formatSeconds(maxDuration),
+		"-af", fmt.Sprintf("afade=t=out:st=%s:d=%s", formatSeconds(maxDuration-fade), formatSeconds(fade)),
+		"-y", outputPath,
+	)
+	if output, err := ffmpegexec.CombinedOutput(ctx, "trim_sfx", cmd); err != nil {
+		return fmt.Errorf("failed to trim sound effect: %w (%s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeSFXGenerator returns a scripted result per prompt
+type fakeSFXGenerator struct {
+	submit   map[string]*adapters.SFXGenerationResult // keyed by prompt; missing prompts fail
+	status   *adapters.SFXGenerationResult
+	requests []adapters.SFXGenerationRequest
+}
+
+func (f *fakeSFXGenerator) GenerateSFX(ctx context.Context, req *adapters.SFXGenerationRequest) (*adapters.SFXGenerationResult, error) {
+	f.requests = append(f.requests, *req)
+	result, ok := f.submit[req.Prompt]
+	if !ok {
+		return nil, errors.New("API error (status 422): invalid prompt")
+	}
+	return result, nil
+}
+
+func (f *fakeSFXGenerator) GetStatus(ctx context.Context, predictionID string) (*adapters.SFXGenerationResult, error) {
+	return f.status, nil
+}
+
+func TestRequestSFX(t *testing.T) {
+	t.Run("immediate output", func(t *testing.T) {
+		gen := &fakeSFXGenerator{submit: map[string]*adapters.SFXGenerationResult{
+			"door creak": {PredictionID: "p1", Status: "succeeded", AudioURL: "https://replicate.delivery/creak.mp3"},
+		}}
+		url, err := requestSFX(context.Background(), gen, "door creak", 2.5, zap.NewNop())
+		require.NoError(t, err)
+		require.Equal(t, "https://replicate.delivery/creak.mp3", url)
+		require.Equal(t, 2.5, gen.requests[0].Duration)
+	})
+
+	t.Run("polls until complete", func(t *testing.T) {
+		gen := &fakeSFXGenerator{
+			submit: map[string]*adapters.SFXGenerationResult{"rain": {PredictionID: "p2", Status: "starting"}},
+			status: &adapters.SFXGenerationResult{PredictionID: "p2", Status: "succeeded", AudioURL: "https://replicate.delivery/rain.mp3"},
+		}
+		url, err := requestSFX(context.Background(), gen, "rain", 3, zap.NewNop())
+		require.NoError(t, err)
+		require.Equal(t, "https://replicate.delivery/rain.mp3", url)
+	})
+
+	t.Run("failed prediction", func(t *testing.T) {
+		gen := &fakeSFXGenerator{
+			submit: map[string]*adapters.SFXGenerationResult{"rain": {PredictionID: "p3", Status: "starting"}},
+			status: &adapters.SFXGenerationResult{PredictionID: "p3", Status: "failed", Error: "model crashed"},
+		}
+		_, err := requestSFX(context.Background(), gen, "rain", 3, zap.NewNop())
+		var genErr *adapters.GenerationError
+		require.ErrorAs(t, err, &genErr)
+	})
+}
+
+func TestGenerateSFX_FailuresDegradeGracefully(t *testing.T) {
+	// Audio downloads fail, so every sound effect errors before upload
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	gen := &fakeSFXGenerator{submit: map[string]*adapters.SFXGenerationResult{
+		"water pouring sound": {PredictionID: "p1", Status: "succeeded", AudioURL: server.URL + "/water.mp3"},
+	}}
+	h := &GenerateHandler{sfxAdapter: gen, logger: zap.NewNop()}
+	job := &domain.Job{JobID: "job-sfx-test", UserID: "user-1"}
+
+	clips := h.generateSFX(context.Background(), job, []domain.SyncPoint{
+		{Timestamp: 1, Type: "sfx", Description: "water pouring sound"},
+		{Timestamp: 5, Type: "sfx", Description: "rejected prompt"},
+	})
+
+	require.Empty(t, clips)
+	require.Len(t, gen.requests, 2, "a failed effect must not stop the rest")
+	require.Equal(t, DefaultSFXMaxDuration, gen.requests[0].Duration)
+}
+
+func TestGenerateSFX_NoAdapter(t *testing.T) {
+	h := &GenerateHandler{logger: zap.NewNop()}
+	require.Nil(t, h.generateSFX(context.Background(), &domain.Job{JobID: "job-1"}, []domain.SyncPoint{{Type: "sfx", Description: "rain"}}))
+}
+
+func TestSFXSyncPoints(t *testing.T) {
+	points := []domain.SyncPoint{
+		{Timestamp: 0.5, Type: "beat", Description: "Drop"},
+		{Timestamp: 2, Type: "sfx", Description: "water pouring sound"},
+		{Timestamp: 4, Type: "sfx", Description: "  "},
+		{Timestamp: 9, Type: "sfx", Description: "stream ambience"},
+		{Timestamp: 31, Type: "sfx", Description: "after the video ends"},
+	}
+
+	got := sfxSyncPoints(points, 30)
+	require.Len(t, got, 2)
+	require.Equal(t, "water pouring sound", got[0].Description)
+	require.Equal(t, "stream ambience", got[1].Description)
+}
+
+func TestBuildSFXKey(t *testing.T) {
+	job := &domain.Job{UserID: "u", JobID: "j"}
+	require.Equal(t, "users/u/jobs/j/audio/sfx/4.50-water-pouring-sound.mp3", buildSFXKey(job, 4.5, "Water pouring sound!"))
+	require.Equal(t, "users/u/jobs/j/audio/sfx/0.00-sfx.mp3", buildSFXKey(job, 0, "???"))
+	require.Equal(t, "a-very-long-description-of-a-thunderous", sfxSlug("A very long description of a thunderous crash of waves on rocks"))
+}
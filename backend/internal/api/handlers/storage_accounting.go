@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// storageCounter is the subset of the usage repository that keeps each user's storage total
+type storageCounter interface {
+	AddStorageBytes(ctx context.Context, userID string, delta int64) error
+}
+
+// countStorage applies delta to a user's storage total. A failed update only skews the total
+// until the storage backfill reconciles it, so it's logged rather than failing the caller.
+func countStorage(ctx context.Context, counter storageCounter, userID string, delta int64, logger *zap.Logger) {
+	if counter == nil || delta == 0 {
+		return
+	}
+	if err := counter.AddStorageBytes(ctx, userID, delta); err != nil {
+		logger.Warn("Failed to update storage usage",
+			zap.String("user_id", userID),
+			zap.Int64("delta", delta),
+			zap.Error(err),
+		)
+	}
+}
+
+// releaseJobStorage subtracts a deleted job's recorded assets from its owner's storage total
+func releaseJobStorage(ctx context.Context, counter storageCounter, job *domain.Job, logger *zap.Logger) {
+	countStorage(ctx, counter, job.UserID, -repository.AssetBytes(job.Assets), logger)
+}
+
+// clearJobAssets records that a job whose assets were just deleted has none left, and releases
+// whatever its record held
+func (h *GenerateHandler) clearJobAssets(ctx context.Context, job *domain.Job) {
+	previous, err := h.jobRepo.SetJobAssets(ctx, job.JobID, nil)
+	if err != nil {
+		h.logger.Warn("Failed to clear job asset sizes",
+			zap.String("job_id", job.JobID),
+			zap.Error(err),
+		)
+		return
+	}
+	job.Assets = map[string]int64{}
+	countStorage(ctx, h.storage, job.UserID, -repository.AssetBytes(previous), h.logger)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeStorageCounter keeps per-user storage totals in memory
+type fakeStorageCounter struct {
+	mu     sync.Mutex
+	totals map[string]int64
+	err    error
+	calls  int
+}
+
+func (s *fakeStorageCounter) AddStorageBytes(_ context.Context, userID string, delta int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+	if s.totals == nil {
+		s.totals = make(map[string]int64)
+	}
+	s.totals[userID] += delta
+	return nil
+}
+
+func TestAssetLedgerCountsOnlySavedBytes(t *testing.T) {
+	job := &domain.Job{JobID: "job456", UserID: "user123"}
+	ctx := repository.WithAssetLedger(context.Background(), job)
+	ledger := repository.AssetLedgerFrom(ctx)
+
+	ledger.Record("users/user123/jobs/job456/clips/scene-001.mp4", 1000)
+	ledger.Record("users/user123/jobs/job456/clips/scene-002.mp4", 2000)
+	total := ledger.Stage(job)
+	require.Equal(t, int64(3000), total)
+	require.Len(t, job.Assets, 2)
+	require.Equal(t, int64(3000), ledger.Commit(total))
+
+	// A failed save is never committed, so its bytes are counted by the next save that succeeds
+	ledger.Record("users/user123/jobs/job456/audio/music.mp3", 500)
+	ledger.Stage(job)
+	ledger.Record("users/user123/jobs/job456/final/video.mp4", 4000)
+	total = ledger.Stage(job)
+	require.Equal(t, int64(7500), total)
+	require.Equal(t, int64(4500), ledger.Commit(total))
+
+	// Re-uploading a key replaces its size rather than adding to it
+	ledger.Record("users/user123/jobs/job456/clips/scene-001.mp4", 1200)
+	require.Equal(t, int64(200), ledger.Commit(ledger.Stage(job)))
+}
+
+func TestAssetLedgerStartsFromRecordedAssets(t *testing.T) {
+	// A scene regeneration starts from the sizes already on the record and counts only its change
+	job := &domain.Job{
+		JobID:  "job456",
+		UserID: "user123",
+		Assets: map[string]int64{"users/user123/jobs/job456/clips/scene-001.mp4": 1000},
+	}
+	ledger := repository.AssetLedgerFrom(repository.WithAssetLedger(context.Background(), job))
+
+	ledger.Record("users/user123/jobs/job456/clips/scene-001-v2.mp4", 1500)
+	total := ledger.Stage(job)
+	require.Equal(t, int64(2500), total)
+	require.Equal(t, int64(1500), ledger.Commit(total))
+}
+
+func TestAssetLedgerNilIsNoop(t *testing.T) {
+	job := &domain.Job{JobID: "job456"}
+	ledger := repository.AssetLedgerFrom(context.Background())
+	require.Nil(t, ledger)
+
+	ledger.Record("key", 100)
+	require.Zero(t, ledger.Stage(job))
+	require.Nil(t, job.Assets, "without a ledger the record's assets are left alone")
+	require.Zero(t, ledger.Commit(100))
+}
+
+func TestCountStorage(t *testing.T) {
+	counter := &fakeStorageCounter{}
+	countStorage(context.Background(), counter, "user123", 3000, zap.NewNop())
+	releaseJobStorage(context.Background(), counter, &domain.Job{UserID: "user123", Assets: map[string]int64{"a": 1000, "b": 500}}, zap.NewNop())
+	require.Equal(t, int64(1500), counter.totals["user123"])
+
+	// Nothing to count and nothing to release are not written
+	countStorage(context.Background(), counter, "user123", 0, zap.NewNop())
+	releaseJobStorage(context.Background(), counter, &domain.Job{UserID: "user123"}, zap.NewNop())
+	require.Equal(t, 2, counter.calls)
+
+	// A failed update is logged, and accounting without a counter is skipped
+	countStorage(context.Background(), &fakeStorageCounter{err: errors.New("throttled")}, "user123", 100, zap.NewNop())
+	countStorage(context.Background(), nil, "user123", 100, zap.NewNop())
+}
+
+func TestDeleteUserJobsReleasesStorage(t *testing.T) {
+	store := &fakeJobDeleteStore{
+		jobs: map[string]*domain.Job{
+			"job-1":     {JobID: "job-1", UserID: "user123", Assets: map[string]int64{"a": 1000}},
+			"job-2":     {JobID: "job-2", UserID: "user123", Assets: map[string]int64{"b": 2000, "c": 500}},
+			"job-stuck": {JobID: "job-stuck", UserID: "user123", Assets: map[string]int64{"d": 4000}},
+			"job-other": {JobID: "job-other", UserID: "user999", Assets: map[string]int64{"e": 8000}},
+		},
+		deleteErr: map[string]error{"job-stuck": errors.New("throttled")},
+	}
+	counter := &fakeStorageCounter{totals: map[string]int64{"user123": 7500, "user999": 8000}}
+	deleter := &jobDeleter{
+		jobs:    store,
+		assets:  &lockedJobAssetStore{store: &fakeJobAssetStore{}},
+		storage: counter,
+		bucket:  "assets",
+		logger:  zap.NewNop(),
+	}
+
+	deleter.deleteUserJobs(context.Background(), "user123", []string{"job-1", "job-2", "job-stuck", "job-other"})
+	require.Equal(t, int64(4000), counter.totals["user123"], "only deleted records release their bytes")
+	require.Equal(t, int64(8000), counter.totals["user999"])
+}
+
+func TestSweepExpiredJobsReleasesStorage(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	store := &fakeExpiredJobStore{jobs: []*domain.Job{
+		{JobID: "job-1", UserID: "user123", Status: domain.StatusCompleted, ExpiresAt: now.Unix(), Assets: map[string]int64{"a": 1000, "b": 250}},
+	}}
+	counter := &fakeStorageCounter{totals: map[string]int64{"user123": 1250}}
+
+	_, err := sweepExpiredJobs(context.Background(), store, &fakeJobAssetStore{}, counter, "assets", now, zap.NewNop())
+	require.NoError(t, err)
+	require.Zero(t, counter.totals["user123"])
+}
+
+// fakeAssetBackfillStore lists jobs without sizes by status and records the backfills
+type fakeAssetBackfillStore struct {
+	jobs     map[string][]*domain.Job
+	recorded map[string]bool // Jobs that recorded their own sizes before the backfill
+	backfill map[string]map[string]int64
+}
+
+func (s *fakeAssetBackfillStore) ListJobsWithoutAssets(_ context.Context, status string) ([]*domain.Job, error) {
+	return s.jobs[status], nil
+}
+
+func (s *fakeAssetBackfillStore) BackfillJobAssets(_ context.Context, jobID string, assets map[string]int64) error {
+	if s.recorded[jobID] {
+		return repository.ErrAssetsRecorded
+	}
+	if s.backfill == nil {
+		s.backfill = make(map[string]map[string]int64)
+	}
+	s.backfill[jobID] = assets
+	return nil
+}
+
+// fakeAssetSizer serves object sizes from a map; a missing key is not found
+type fakeAssetSizer struct {
+	sizes   map[string]int64
+	listErr error
+}
+
+func (s *fakeAssetSizer) ListObjectSizes(_ context.Context, _, prefix string) (map[string]int64, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	sizes := make(map[string]int64)
+	for key, size := range s.sizes {
+		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+			sizes[key] = size
+		}
+	}
+	return sizes, nil
+}
+
+func (s *fakeAssetSizer) ObjectSize(_ context.Context, _, key string) (int64, error) {
+	size, ok := s.sizes[key]
+	if !ok {
+		return 0, repository.ErrObjectNotFound
+	}
+	return size, nil
+}
+
+func TestBackfillJobAssets(t *testing.T) {
+	store := &fakeAssetBackfillStore{
+		jobs: map[string][]*domain.Job{
+			domain.StatusCompleted: {
+				{JobID: "job-1", UserID: "user123", VideoKey: "videos/job-1/final.mp4", AudioURL: "https://assets.s3.amazonaws.com/audio/job-1.mp3"},
+				{JobID: "job-raced", UserID: "user123"},
+			},
+			domain.StatusFailed: {{JobID: "job-2", UserID: "user999"}},
+		},
+		recorded: map[string]bool{"job-raced": true},
+	}
+	sizer := &fakeAssetSizer{sizes: map[string]int64{
+		"users/user123/jobs/job-1/clips/scene-001.mp4":     1000,
+		"users/user123/jobs/job-1/final/video.mp4":         3000,
+		"videos/job-1/final.mp4":                           500,
+		"users/user123/jobs/job-raced/final/video.mp4":     9000,
+		"users/user999/jobs/job-2/clips/scene-001.mp4":     200,
+		"users/user999/jobs/job-2-other/final/video.mp4":   7000,
+		"users/user123/uploads/bottle.png":                 100,
+		"users/user123/jobs/job-1/audio/narrator-tts.mp3":  50,
+		"users/user123/jobs/job-1/captions/narrator.vtt":   5,
+		"users/user123/jobs/job-1/thumbnails/thumb-01.jpg": 45,
+	}}
+	counter := &fakeStorageCounter{}
+
+	result, err := backfillJobAssets(context.Background(), store, sizer, counter, "assets", zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, StorageBackfillResult{Scanned: 3, Backfilled: 2, Skipped: 1, Bytes: 4800}, result)
+
+	// The legacy audio key no longer exists and is left out
+	require.Len(t, store.backfill["job-1"], 6)
+	require.Equal(t, int64(500), store.backfill["job-1"]["videos/job-1/final.mp4"])
+	require.Equal(t, map[string]int64{"users/user999/jobs/job-2/clips/scene-001.mp4": 200}, store.backfill["job-2"])
+	require.Equal(t, map[string]int64{"user123": 4600, "user999": 200}, counter.totals, "a job that recorded its own sizes isn't counted twice")
+}
+
+func TestBackfillJobAssetsLeavesUnmeasuredJobs(t *testing.T) {
+	store := &fakeAssetBackfillStore{
+		jobs: map[string][]*domain.Job{domain.StatusCompleted: {{JobID: "job-1", UserID: "user123"}}},
+	}
+	counter := &fakeStorageCounter{}
+
+	result, err := backfillJobAssets(context.Background(), store, &fakeAssetSizer{listErr: errors.New("throttled")}, counter, "assets", zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, StorageBackfillResult{Scanned: 1, Failed: 1}, result)
+	require.Empty(t, store.backfill, "the job keeps no sizes, so the next backfill retries it")
+	require.Zero(t, counter.calls)
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// storageLargestJobs is how many jobs GetStorageUsage lists as the user's largest
+const storageLargestJobs = 5
+
+// JobStorage is the storage one job's assets take up
+type JobStorage struct {
+	JobID      string `json:"job_id"`
+	Title      string `json:"title,omitempty"`
+	Status     string `json:"status"`
+	CreatedAt  int64  `json:"created_at"`
+	Bytes      int64  `json:"bytes"`
+	AssetCount int    `json:"asset_count"`
+}
+
+// StorageUsageResponse is the body of GET /api/v1/usage/storage
+type StorageUsageResponse struct {
+	TotalBytes  int64        `json:"total_bytes"`
+	Jobs        []JobStorage `json:"jobs"`                   // One page of jobs, newest first
+	NextCursor  string       `json:"next_cursor,omitempty"`  // Empty on the last page
+	LargestJobs []JobStorage `json:"largest_jobs,omitempty"` // First page only
+}
+
+// StorageBackfillResult summarizes one storage backfill
+type StorageBackfillResult struct {
+	Scanned    int   `json:"scanned"`    // Finished jobs without recorded asset sizes
+	Backfilled int   `json:"backfilled"` // Jobs whose sizes were recorded and counted
+	Skipped    int   `json:"skipped"`    // Jobs that recorded sizes themselves meanwhile
+	Failed     int   `json:"failed"`     // Jobs left for the next backfill
+	Bytes      int64 `json:"bytes"`      // Bytes added to users' storage totals
+}
+
+// userJobsStore is the subset of the job repository needed to page through a user's jobs
+type userJobsStore interface {
+	GetJobsByUser(ctx context.Context, userID string, query repository.JobsQuery) (*repository.JobsPage, error)
+}
+
+// assetBackfillStore is the subset of the job repository the storage backfill needs
+type assetBackfillStore interface {
+	ListJobsWithoutAssets(ctx context.Context, status string) ([]*domain.Job, error)
+	BackfillJobAssets(ctx context.Context, jobID string, assets map[string]int64) error
+}
+
+// assetSizer is the subset of the S3 repository needed to measure a job's assets
+type assetSizer interface {
+	ListObjectSizes(ctx context.Context, bucket, prefix string) (map[string]int64, error)
+	ObjectSize(ctx context.Context, bucket, key string) (int64, error)
+}
+
+// jobStorage summarizes a job's recorded assets
+func jobStorage(job *domain.Job) JobStorage {
+	return JobStorage{
+		JobID:      job.JobID,
+		Title:      job.Title,
+		Status:     job.Status,
+		CreatedAt:  job.CreatedAt,
+		Bytes:      repository.AssetBytes(job.Assets),
+		AssetCount: len(job.Assets),
+	}
+}
+
+// largestJobs returns the n jobs of userID with the most bytes recorded, largest first
+func largestJobs(ctx context.Context, store userJobsStore, userID string, n int) ([]JobStorage, error) {
+	var largest []JobStorage
+	query := repository.JobsQuery{Limit: maxJobsPageSize}
+	for {
+		page, err := store.GetJobsByUser(ctx, userID, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range page.Jobs {
+			if usage := jobStorage(job); usage.Bytes > 0 {
+				largest = append(largest, usage)
+			}
+		}
+		if len(page.LastEvaluatedKey) == 0 {
+			break
+		}
+		query.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Bytes > largest[j].Bytes })
+	if len(largest) > n {
+		largest = largest[:n]
+	}
+	return largest, nil
+}
+
+// measureJobAssets sizes a job's generated assets from S3: everything under its prefix plus the
+// legacy keys its record points at. Keys that no longer exist are left out.
+func measureJobAssets(ctx context.Context, sizer assetSizer, bucket string, job *domain.Job) (map[string]int64, error) {
+	bucket = jobAssetBucket(job, bucket)
+	assets, err := sizer.ListObjectSizes(ctx, bucket, jobAssetPrefix(job))
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range legacyJobAssetKeys(job, bucket) {
+		size, err := sizer.ObjectSize(ctx, bucket, key)
+		if stderrors.Is(err, repository.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		assets[key] = size
+	}
+	return assets, nil
+}
+
+// backfillJobAssets records asset sizes on every finished job that predates size accounting and
+// adds them to the owners' storage totals. Jobs still running record their own sizes when they
+// finish; a job that does so before the backfill reaches it is skipped, so nothing is counted twice.
+func backfillJobAssets(ctx context.Context, store assetBackfillStore, sizer assetSizer, storage storageCounter, bucket string, logger *zap.Logger) (StorageBackfillResult, error) {
+	var result StorageBackfillResult
+	var errs []error
+	for _, status := range expirableStatuses {
+		jobs, err := store.ListJobsWithoutAssets(ctx, status)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Scanned += len(jobs)
+
+		for _, job := range jobs {
+			assets, err := measureJobAssets(ctx, sizer, bucket, job)
+			if err == nil {
+				err = store.BackfillJobAssets(ctx, job.JobID, assets)
+			}
+			if stderrors.Is(err, repository.ErrAssetsRecorded) {
+				result.Skipped++
+				continue
+			}
+			if err != nil {
+				logger.Warn("Failed to backfill job asset sizes",
+					zap.String("job_id", job.JobID),
+					zap.String("user_id", job.UserID),
+					zap.Error(err),
+				)
+				result.Failed++
+				continue
+			}
+
+			bytes := repository.AssetBytes(assets)
+			countStorage(ctx, storage, job.UserID, bytes, logger)
+			result.Backfilled++
+			result.Bytes += bytes
+		}
+	}
+	return result, stderrors.Join(errs...)
+}
+
+// StorageUsageHandler serves users' storage usage and backfills sizes for older jobs
+type StorageUsageHandler struct {
+	jobRepo      *repository.DynamoDBRepository
+	usageRepo    *repository.DynamoDBUsageRepository
+	s3Service    *repository.S3AssetRepository
+	assetsBucket string
+	token        string // Bearer token for POST /internal/usage/storage-backfill
+	logger       *zap.Logger
+}
+
+// NewStorageUsageHandler creates a storage usage handler. The internal backfill endpoint accepts
+// requests bearing token.
+func NewStorageUsageHandler(
+	jobRepo *repository.DynamoDBRepository,
+	usageRepo *repository.DynamoDBUsageRepository,
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	token string,
+	logger *zap.Logger,
+) *StorageUsageHandler {
+	return &StorageUsageHandler{
+		jobRepo:      jobRepo,
+		usageRepo:    usageRepo,
+		s3Service:    s3Service,
+		assetsBucket: assetsBucket,
+		token:        token,
+		logger:       logger,
+	}
+}
+
+// GetStorageUsage handles GET /api/v1/usage/storage
+// @Summary Get storage usage
+// @Description Get the bytes the user's job assets take up, one page of jobs with their sizes and (on the first page) the largest jobs
+// @Tags usage
+// @Produce json
+// @Param page_size query int false "Jobs per page (default 20, max 100)"
+// @Param cursor query string false "Cursor from the previous page's next_cursor"
+// @Success 200 {object} StorageUsageResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/usage/storage [get]
+// @Security BearerAuth
+func (h *StorageUsageHandler) GetStorageUsage(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+	ctx := c.Request.Context()
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultJobsPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxJobsPageSize {
+		pageSize = defaultJobsPageSize
+	}
+	startKey, err := repository.DecodeJobsCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+
+	total, err := h.usageRepo.GetStorageBytes(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	page, err := h.jobRepo.GetJobsByUser(ctx, userID, repository.JobsQuery{Limit: pageSize, ExclusiveStartKey: startKey})
+	if stderrors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.NewValidationError("cursor", "Invalid pagination cursor"),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list jobs for storage usage",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	nextCursor, err := repository.EncodeJobsCursor(page.LastEvaluatedKey)
+	if err != nil {
+		h.logger.Error("Failed to encode pagination cursor",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrInternalServer,
+		})
+		return
+	}
+
+	resp := StorageUsageResponse{
+		TotalBytes: total,
+		Jobs:       make([]JobStorage, len(page.Jobs)),
+		NextCursor: nextCursor,
+	}
+	for i, job := range page.Jobs {
+		resp.Jobs[i] = jobStorage(job)
+	}
+
+	if startKey == nil {
+		resp.LargestJobs, err = largestJobs(ctx, h.jobRepo, userID, storageLargestJobs)
+		if err != nil {
+			h.logger.Error("Failed to find largest jobs",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error: errors.ErrDatabaseError,
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// BackfillStorage handles POST /internal/usage/storage-backfill, which sizes the assets of jobs
+// that finished before sizes were recorded. Requests must carry
+// "Authorization: Bearer <INTERNAL_API_TOKEN>". Failed jobs are retried by the next request.
+func (h *StorageUsageHandler) BackfillStorage(c *gin.Context) {
+	if !internalTokenValid(c, h.token) {
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error: errors.ErrUnauthorized,
+		})
+		return
+	}
+
+	result, err := backfillJobAssets(c.Request.Context(), h.jobRepo, h.s3Service, h.usageRepo, h.assetsBucket, h.logger)
+	h.logger.Info("Storage backfill finished",
+		zap.Int("scanned", result.Scanned),
+		zap.Int("backfilled", result.Backfilled),
+		zap.Int("skipped", result.Skipped),
+		zap.Int("failed", result.Failed),
+		zap.Int64("bytes", result.Bytes),
+	)
+	if err != nil {
+		h.logger.Error("Storage backfill could not list jobs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
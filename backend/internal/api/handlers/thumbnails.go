@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// ThumbnailWidths are the job thumbnail renditions, smallest first. The widest one also backs
+// thumbnail_url.
+var ThumbnailWidths = []int{320, 640, 1280}
+
+const (
+	thumbnailFormatJPEG = "jpg"
+	thumbnailFormatWebP = "webp"
+)
+
+// thumbnailRendition is one output file of the thumbnail extraction
+type thumbnailRendition struct {
+	Width  int
+	Format string // thumbnailFormatJPEG or thumbnailFormatWebP
+	Path   string
+}
+
+func (r thumbnailRendition) contentType() string {
+	if r.Format == thumbnailFormatWebP {
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+// thumbnailRenditions lists the files written to dir: a JPEG per width, plus a WebP per width
+// when webp is set
+func thumbnailRenditions(dir string, webp bool) []thumbnailRendition {
+	formats := []string{thumbnailFormatJPEG}
+	if webp {
+		formats = append(formats, thumbnailFormatWebP)
+	}
+
+	var renditions []thumbnailRendition
+	for _, format := range formats {
+		for _, width := range ThumbnailWidths {
+			renditions = append(renditions, thumbnailRendition{
+				Width:  width,
+				Format: format,
+				Path:   filepath.Join(dir, fmt.Sprintf("thumbnail-%d.%s", width, format)),
+			})
+		}
+	}
+	return renditions
+}
+
+// thumbnailArgs extracts the very first frame of input once and writes every rendition from it,
+// so the clip is only decoded (or streamed from S3) a single time
+func thumbnailArgs(input string, renditions []thumbnailRendition) []string {
+	var graph strings.Builder
+	fmt.Fprintf(&graph, "[0:v]split=%d", len(renditions))
+	for i := range renditions {
+		fmt.Fprintf(&graph, "[s%d]", i)
+	}
+	for i, r := range renditions {
+		fmt.Fprintf(&graph, ";[s%d]scale=%d:-1[t%d]", i, r.Width, i)
+	}
+
+	args := []string{
+		"-i", input,
+		"-filter_complex", graph.String(),
+	}
+	for i, r := range renditions {
+		args = append(args, "-map", fmt.Sprintf("[t%d]", i), "-frames:v", "1")
+		if r.Format == thumbnailFormatWebP {
+			args = append(args, "-c:v", "libwebp", "-quality", "80")
+		} else {
+			args = append(args, "-q:v", "2") // High quality
+		}
+		args = append(args, "-y", r.Path)
+	}
+	return args
+}
+
+// buildThumbnailResponses presigns the job thumbnail renditions through the shared per-request
+// cache, keyed by width. WebP renditions are returned separately.
+func buildThumbnailResponses(ctx context.Context, job *domain.Job, cache *presignCache, duration time.Duration) (jpeg, webp map[string]string) {
+	for _, t := range job.Thumbnails {
+		url := cache.get(ctx, t.Key, duration)
+		if url == "" {
+			continue
+		}
+		target := &jpeg
+		if t.Format == thumbnailFormatWebP {
+			target = &webp
+		}
+		if *target == nil {
+			*target = make(map[string]string)
+		}
+		(*target)[strconv.Itoa(t.Width)] = url
+	}
+	return jpeg, webp
+}
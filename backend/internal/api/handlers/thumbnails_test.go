@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailArgs(t *testing.T) {
+	// Every rendition comes from one decode of the first frame
+	require.Equal(t, []string{
+		"-i", "pipe:0",
+		"-filter_complex", "[0:v]split=3[s0][s1][s2];[s0]scale=320:-1[t0];[s1]scale=640:-1[t1];[s2]scale=1280:-1[t2]",
+		"-map", "[t0]", "-frames:v", "1", "-q:v", "2", "-y", "thumbs/thumbnail-320.jpg",
+		"-map", "[t1]", "-frames:v", "1", "-q:v", "2", "-y", "thumbs/thumbnail-640.jpg",
+		"-map", "[t2]", "-frames:v", "1", "-q:v", "2", "-y", "thumbs/thumbnail-1280.jpg",
+	}, thumbnailArgs("pipe:0", thumbnailRenditions("thumbs", false)))
+}
+
+func TestThumbnailArgsWithWebP(t *testing.T) {
+	renditions := thumbnailRenditions("thumbs", true)
+	require.Len(t, renditions, 6)
+	require.Equal(t, "image/webp", renditions[3].contentType())
+
+	args := thumbnailArgs("clip.mp4", renditions)
+	require.Equal(t, "[0:v]split=6[s0][s1][s2][s3][s4][s5];"+
+		"[s0]scale=320:-1[t0];[s1]scale=640:-1[t1];[s2]scale=1280:-1[t2];"+
+		"[s3]scale=320:-1[t3];[s4]scale=640:-1[t4];[s5]scale=1280:-1[t5]", args[3])
+	require.Equal(t, []string{
+		"-map", "[t5]", "-frames:v", "1", "-c:v", "libwebp", "-quality", "80", "-y", "thumbs/thumbnail-1280.webp",
+	}, args[len(args)-10:])
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Defaults for TMP_JANITOR_INTERVAL_MINUTES and TMP_JANITOR_TTL_HOURS
+const (
+	DefaultTmpJanitorInterval = 15 * time.Minute
+	DefaultTmpJanitorTTL      = 6 * time.Hour
+)
+
+// tmpJanitorMinIdle is how long a finished job's directory must go untouched before it is
+// deleted. Regenerations, trims and renditions of completed jobs work there without being in
+// the pipeline registry, and none of them leaves its files idle this long.
+const tmpJanitorMinIdle = 15 * time.Minute
+
+// tmpJobStore is the subset of the job repository the janitor needs
+type tmpJobStore interface {
+	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
+}
+
+// pipelineRegistry reports whether a job's pipeline is running on this server
+type pipelineRegistry interface {
+	PipelineRunning(jobID string) bool
+}
+
+// TmpSweepResult summarizes one janitor sweep
+type TmpSweepResult struct {
+	Scanned        int   // Job directories found
+	Deleted        int   // Job directories deleted
+	ReclaimedBytes int64 // Bytes the deleted directories held
+}
+
+// TmpJanitor deletes job working directories that a panic, OOM kill or restart left behind,
+// before they fill the disk. A directory goes once its job has finished and it has been
+// untouched for tmpJanitorMinIdle, or once it has been untouched for the TTL whatever the
+// job's state; a directory whose pipeline is running on this server is never deleted.
+type TmpJanitor struct {
+	root      string
+	jobs      tmpJobStore      // nil deletes by TTL only
+	pipelines pipelineRegistry // nil treats no pipeline as running
+	ttl       time.Duration
+	interval  time.Duration // <= 0 sweeps once, at startup
+	metrics   metrics.Recorder
+	logger    *zap.Logger
+}
+
+// NewTmpJanitor creates a janitor for the job directories under /tmp
+func NewTmpJanitor(
+	jobRepo *repository.DynamoDBRepository,
+	pipelines pipelineRegistry,
+	ttl time.Duration,
+	interval time.Duration,
+	recorder metrics.Recorder,
+	logger *zap.Logger,
+) *TmpJanitor {
+	j := &TmpJanitor{
+		root:      composition.JobTmpRoot,
+		pipelines: pipelines,
+		ttl:       ttl,
+		interval:  interval,
+		metrics:   metrics.Nop{},
+		logger:    logger,
+	}
+	if jobRepo != nil {
+		j.jobs = jobRepo
+	}
+	if ttl <= 0 {
+		j.ttl = DefaultTmpJanitorTTL
+	}
+	if recorder != nil {
+		j.metrics = recorder
+	}
+	return j
+}
+
+// Run sweeps now and then every interval until ctx is done
+func (j *TmpJanitor) Run(ctx context.Context) {
+	j.sweep(ctx, time.Now())
+	if j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.sweep(ctx, time.Now())
+		}
+	}
+}
+
+// sweep deletes the reclaimable job directories under the root
+func (j *TmpJanitor) sweep(ctx context.Context, now time.Time) TmpSweepResult {
+	var result TmpSweepResult
+	entries, err := os.ReadDir(j.root)
+	if err != nil {
+		j.logger.Warn("Temp janitor could not read the temp root", zap.String("root", j.root), zap.Error(err))
+		return result
+	}
+
+	for _, entry := range entries {
+		// Only job directories; other programs' temp files are theirs to clean up
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "job-") {
+			continue
+		}
+		result.Scanned++
+		jobID := entry.Name()
+		dir := filepath.Join(j.root, jobID)
+
+		size, lastModified, err := dirUsage(dir)
+		if err != nil {
+			j.logger.Warn("Temp janitor could not read a job directory", zap.String("job_id", jobID), zap.Error(err))
+			continue
+		}
+		idle := now.Sub(lastModified)
+		if !j.reclaimable(ctx, jobID, idle) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			j.logger.Warn("Temp janitor failed to delete a job directory", zap.String("job_id", jobID), zap.Error(err))
+			continue
+		}
+		j.logger.Info("Deleted orphaned job directory",
+			zap.String("job_id", jobID),
+			zap.Int64("bytes", size),
+			zap.Duration("idle", idle),
+		)
+		result.Deleted++
+		result.ReclaimedBytes += size
+	}
+
+	if result.Deleted > 0 {
+		j.logger.Info("Temp janitor sweep finished",
+			zap.Int("scanned", result.Scanned),
+			zap.Int("deleted", result.Deleted),
+			zap.Int64("reclaimed_bytes", result.ReclaimedBytes),
+		)
+	}
+	j.metrics.Histogram(metrics.TmpReclaimed, float64(result.ReclaimedBytes), metrics.UnitBytes, nil)
+	return result
+}
+
+// reclaimable reports whether the directory of jobID, untouched for idle, can be deleted
+func (j *TmpJanitor) reclaimable(ctx context.Context, jobID string, idle time.Duration) bool {
+	if j.pipelines != nil && j.pipelines.PipelineRunning(jobID) {
+		return false
+	}
+	if idle >= j.ttl {
+		return true
+	}
+	if idle < tmpJanitorMinIdle || j.jobs == nil {
+		return false
+	}
+
+	job, err := j.jobs.GetJob(ctx, jobID)
+	if stderrors.Is(err, repository.ErrJobNotFound) {
+		return true // Deleted, so nothing will work on it again
+	}
+	if err != nil {
+		j.logger.Warn("Temp janitor could not read a job, keeping its directory", zap.String("job_id", jobID), zap.Error(err))
+		return false
+	}
+	return slices.Contains(expirableStatuses, job.Status)
+}
+
+// dirUsage returns the bytes the files under dir hold and the latest time anything in it,
+// dir included, was modified
+func dirUsage(dir string) (int64, time.Time, error) {
+	var size int64
+	var lastModified time.Time
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if stderrors.Is(err, fs.ErrNotExist) {
+				return nil // Removed by its pipeline while we looked
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			if stderrors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if info.ModTime().After(lastModified) {
+			lastModified = info.ModTime()
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, lastModified, err
+}
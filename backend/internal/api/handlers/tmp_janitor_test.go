@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeTmpJobStore struct {
+	jobs map[string]*domain.Job
+	err  error
+}
+
+func (f *fakeTmpJobStore) GetJob(ctx context.Context, jobID string) (*domain.Job, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	job, ok := f.jobs[jobID]
+	if !ok {
+		return nil, repository.ErrJobNotFound
+	}
+	return job, nil
+}
+
+type fakePipelines map[string]bool
+
+func (f fakePipelines) PipelineRunning(jobID string) bool { return f[jobID] }
+
+// writeJobDir creates root/{jobID}/composition holding a file of size bytes, with everything in
+// it last modified idle before now
+func writeJobDir(t *testing.T, root, jobID string, size int, now time.Time, idle time.Duration) string {
+	t.Helper()
+	dir := filepath.Join(root, jobID)
+	work := filepath.Join(dir, "composition")
+	require.NoError(t, os.MkdirAll(work, 0o755))
+	file := writeTmpFile(t, work, "final.mp4", size)
+
+	modified := now.Add(-idle)
+	for _, path := range []string{file, work, dir} {
+		require.NoError(t, os.Chtimes(path, modified, modified))
+	}
+	return dir
+}
+
+func newTestTmpJanitor(root string, jobs tmpJobStore, pipelines pipelineRegistry) *TmpJanitor {
+	return &TmpJanitor{root: root, jobs: jobs, pipelines: pipelines, ttl: 6 * time.Hour, metrics: &fakeRecorder{}, logger: zap.NewNop()}
+}
+
+func TestTmpJanitor_DeletesFinishedAndStaleJobDirectories(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	jobs := &fakeTmpJobStore{jobs: map[string]*domain.Job{
+		"job-completed":  {JobID: "job-completed", Status: domain.StatusCompleted},
+		"job-failed":     {JobID: "job-failed", Status: domain.StatusFailed},
+		"job-canceled":   {JobID: "job-canceled", Status: domain.StatusCanceled},
+		"job-recent":     {JobID: "job-recent", Status: domain.StatusCompleted},
+		"job-processing": {JobID: "job-processing", Status: domain.StatusProcessing},
+		"job-stuck":      {JobID: "job-stuck", Status: domain.StatusProcessing},
+		"job-running":    {JobID: "job-running", Status: domain.StatusProcessing},
+		"job-rerun":      {JobID: "job-rerun", Status: domain.StatusFailed},
+	}}
+	pipelines := fakePipelines{"job-running": true, "job-rerun": true}
+
+	deleted := []string{
+		writeJobDir(t, root, "job-completed", 100, now, time.Hour),
+		writeJobDir(t, root, "job-failed", 200, now, time.Hour),
+		writeJobDir(t, root, "job-canceled", 300, now, time.Hour),
+		writeJobDir(t, root, "job-deleted", 400, now, time.Hour),   // No longer stored
+		writeJobDir(t, root, "job-stuck", 500, now, 7*time.Hour),   // Past the TTL
+		writeJobDir(t, root, "job-unknown", 600, now, 7*time.Hour), // Past the TTL, never stored
+	}
+	kept := []string{
+		writeJobDir(t, root, "job-recent", 1, now, time.Minute),    // Could be a regeneration
+		writeJobDir(t, root, "job-processing", 1, now, time.Hour),  // Running on another server
+		writeJobDir(t, root, "job-running", 1, now, 7*time.Hour),   // Running here, however idle
+		writeJobDir(t, root, "job-rerun", 1, now, time.Hour),       // Retried here after failing
+		writeJobDir(t, root, "brand-doc-123", 1, now, 7*time.Hour), // Not a job directory
+	}
+	loose := writeTmpFile(t, root, "job-notes.txt", 1)
+	old := now.Add(-7 * time.Hour)
+	require.NoError(t, os.Chtimes(loose, old, old))
+
+	janitor := newTestTmpJanitor(root, jobs, pipelines)
+	result := janitor.sweep(context.Background(), now)
+	require.Equal(t, TmpSweepResult{Scanned: 10, Deleted: 6, ReclaimedBytes: 2100}, result)
+
+	for _, dir := range deleted {
+		require.NoDirExists(t, dir)
+	}
+	for _, dir := range kept {
+		require.DirExists(t, dir)
+	}
+	require.FileExists(t, loose)
+
+	rec := janitor.metrics.(*fakeRecorder)
+	require.Equal(t, []recordedMetric{{name: metrics.TmpReclaimed}}, rec.recorded)
+}
+
+func TestTmpJanitor_RecentEditsKeepAFinishedJob(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	dir := writeJobDir(t, root, "job-completed", 100, now, 2*time.Hour)
+
+	// A rendition started writing a minute ago
+	writeTmpFile(t, filepath.Join(dir, "composition"), "rendition.mp4", 10)
+
+	jobs := &fakeTmpJobStore{jobs: map[string]*domain.Job{"job-completed": {JobID: "job-completed", Status: domain.StatusCompleted}}}
+	result := newTestTmpJanitor(root, jobs, nil).sweep(context.Background(), now.Add(time.Minute))
+	require.Equal(t, TmpSweepResult{Scanned: 1}, result)
+	require.DirExists(t, dir)
+}
+
+func TestTmpJanitor_KeepsDirectoriesItCannotCheck(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	unread := writeJobDir(t, root, "job-completed", 100, now, time.Hour)
+	stale := writeJobDir(t, root, "job-stale", 100, now, 7*time.Hour)
+
+	// Without the job store only the TTL applies
+	jobs := &fakeTmpJobStore{err: errors.New("throttled")}
+	result := newTestTmpJanitor(root, jobs, nil).sweep(context.Background(), now)
+	require.Equal(t, TmpSweepResult{Scanned: 2, Deleted: 1, ReclaimedBytes: 100}, result)
+	require.DirExists(t, unread)
+	require.NoDirExists(t, stale)
+
+	result = newTestTmpJanitor(root, nil, nil).sweep(context.Background(), now)
+	require.Equal(t, TmpSweepResult{Scanned: 1}, result)
+	require.DirExists(t, unread)
+}
+
+func TestTmpJanitor_MissingRoot(t *testing.T) {
+	janitor := newTestTmpJanitor(filepath.Join(t.TempDir(), "missing"), nil, nil)
+	require.Equal(t, TmpSweepResult{}, janitor.sweep(context.Background(), time.Now()))
+}
+
+func TestGenerateHandler_PipelineRunning(t *testing.T) {
+	h := &GenerateHandler{cancellations: newJobCancellations(nil, zap.NewNop())}
+	require.False(t, h.PipelineRunning("job-1"))
+
+	_, done := h.cancellations.start(context.Background(), "job-1")
+	require.True(t, h.PipelineRunning("job-1"))
+	require.False(t, h.PipelineRunning("job-2"))
+
+	done()
+	require.False(t, h.PipelineRunning("job-1"))
+	require.False(t, (&GenerateHandler{}).PipelineRunning("job-1"), "no registry runs nothing")
+}
+
+func writeTmpFile(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	return path
+}
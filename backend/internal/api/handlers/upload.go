@@ -18,6 +18,7 @@ import (
 type UploadHandler struct {
 	s3Service    *repository.S3AssetRepository
 	assetsBucket string
+	locator      *repository.AssetLocator
 	logger       *zap.Logger
 }
 
@@ -25,11 +26,13 @@ type UploadHandler struct {
 func NewUploadHandler(
 	s3Service *repository.S3AssetRepository,
 	assetsBucket string,
+	locator *repository.AssetLocator,
 	logger *zap.Logger,
 ) *UploadHandler {
 	return &UploadHandler{
 		s3Service:    s3Service,
 		assetsBucket: assetsBucket,
+		locator:      locator,
 		logger:       logger,
 	}
 }
@@ -107,7 +110,7 @@ func (h *UploadHandler) GetPresignedURL(c *gin.Context) {
 		filename := sanitizeFilename(req.Filename)
 		// Use timestamp to ensure uniqueness
 		timestamp := time.Now().Unix()
-		s3Key = fmt.Sprintf("users/%s/uploads/product_images/%d_%s", userID, timestamp, filename)
+		s3Key = fmt.Sprintf("%sproduct_images/%d_%s", h.locator.UploadPrefix(userID), timestamp, filename)
 	default:
 		h.logger.Warn("Unsupported asset type",
 			zap.String("user_id", userID),
@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Multipart upload limits. Parts are a fixed size so the part count follows from the file size.
+const (
+	MaxMultipartUploadSize int64 = 1024 * 1024 * 1024 // 1GB
+	MultipartPartSize      int64 = 8 * 1024 * 1024    // S3 requires at least 5MB for all but the last part
+
+	// maxMultipartParts is how many parts the largest allowed upload needs
+	maxMultipartParts = int32(MaxMultipartUploadSize / MultipartPartSize)
+
+	// maxPartURLsPerRequest bounds one batch of presigned part URLs
+	maxPartURLsPerRequest = 100
+
+	// multipartPartURLExpiry is how long a presigned part URL stays valid
+	multipartPartURLExpiry = time.Hour
+
+	// multipartUploadMaxAge is how long an upload may stay incomplete before the janitor aborts it
+	multipartUploadMaxAge = 24 * time.Hour
+
+	// multipartJanitorInterval is how often each server looks for abandoned uploads
+	multipartJanitorInterval = time.Hour
+)
+
+// multipartAssetType is an asset type that can be uploaded in parts: the folder under
+// users/{user}/uploads/ it is stored in, and its content types with their allowed extensions
+type multipartAssetType struct {
+	folder       string
+	contentTypes map[string][]string
+}
+
+var multipartAssetTypes = map[string]multipartAssetType{
+	"product_image": {folder: "product_images", contentTypes: map[string][]string{
+		"image/jpeg": {".jpg", ".jpeg"},
+		"image/png":  {".png"},
+		"image/webp": {".webp"},
+	}},
+	"style_reference": {folder: "style_references", contentTypes: map[string][]string{
+		"image/jpeg":      {".jpg", ".jpeg"},
+		"image/png":       {".png"},
+		"image/webp":      {".webp"},
+		"video/mp4":       {".mp4"},
+		"video/quicktime": {".mov"},
+	}},
+}
+
+// multipartUploadStore is the subset of the S3 repository browser multipart uploads use
+type multipartUploadStore interface {
+	CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error)
+	PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, duration time.Duration) (string, error)
+	UploadedParts(ctx context.Context, key, uploadID string) ([]repository.UploadPart, error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []repository.UploadPart) error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+	AbortStaleMultipartUploads(ctx context.Context, prefix string, cutoff time.Time) (int, error)
+}
+
+// MultipartUploadHandler lets the frontend upload large assets in resumable parts, straight to S3
+type MultipartUploadHandler struct {
+	store        multipartUploadStore
+	assetsBucket string
+	locator      *repository.AssetLocator
+	logger       *zap.Logger
+}
+
+// NewMultipartUploadHandler creates a new multipart upload handler
+func NewMultipartUploadHandler(store multipartUploadStore, assetsBucket string, locator *repository.AssetLocator, logger *zap.Logger) *MultipartUploadHandler {
+	return &MultipartUploadHandler{
+		store:        store,
+		assetsBucket: assetsBucket,
+		locator:      locator,
+		logger:       logger,
+	}
+}
+
+// InitiateMultipartRequest represents the request body for starting a multipart upload
+type InitiateMultipartRequest struct {
+	AssetType   string `json:"asset_type" binding:"required"` // product_image or style_reference
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	FileSize    int64  `json:"file_size" binding:"required,min=1"`
+}
+
+// InitiateMultipartResponse represents the response for starting a multipart upload
+type InitiateMultipartResponse struct {
+	UploadID  string `json:"upload_id"`
+	Key       string `json:"key"`
+	PartSize  int64  `json:"part_size"`  // Bytes per part; the last part may be smaller
+	PartCount int32  `json:"part_count"` // Parts to upload, numbered from 1
+	AssetURL  string `json:"asset_url"`
+}
+
+// MultipartPartsRequest represents the request body for presigning a batch of parts
+type MultipartPartsRequest struct {
+	Key         string  `json:"key" binding:"required"`
+	UploadID    string  `json:"upload_id" binding:"required"`
+	PartNumbers []int32 `json:"part_numbers" binding:"required"`
+}
+
+// MultipartPartURL is a presigned URL for PUTting one part
+type MultipartPartURL struct {
+	PartNumber int32  `json:"part_number"`
+	UploadURL  string `json:"upload_url"`
+}
+
+// MultipartPartsResponse represents the response for presigning a batch of parts
+type MultipartPartsResponse struct {
+	Parts     []MultipartPartURL `json:"parts"`
+	ExpiresAt int64              `json:"expires_at"` // When the URLs stop working; request them again to resume
+}
+
+// MultipartCompletedPart is a part the client uploaded, with the ETag header S3 returned for it
+type MultipartCompletedPart struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// CompleteMultipartRequest represents the request body for completing a multipart upload
+type CompleteMultipartRequest struct {
+	Key      string                   `json:"key" binding:"required"`
+	UploadID string                   `json:"upload_id" binding:"required"`
+	Parts    []MultipartCompletedPart `json:"parts" binding:"required"`
+}
+
+// CompleteMultipartResponse represents the response for completing a multipart upload
+type CompleteMultipartResponse struct {
+	Key       string `json:"key"`
+	AssetURL  string `json:"asset_url"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// InitiateUpload handles POST /api/v1/assets/multipart/initiate
+// @Summary Start a multipart upload
+// @Description Starts a resumable upload of a large product image or style reference. Upload file_size in part_count parts of part_size bytes through presigned part URLs, then complete the upload.
+// @Tags upload
+// @Accept json
+// @Produce json
+// @Param body body InitiateMultipartRequest true "Upload request"
+// @Success 200 {object} InitiateMultipartResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/assets/multipart/initiate [post]
+// @Security BearerAuth
+func (h *MultipartUploadHandler) InitiateUpload(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	var req InitiateMultipartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondInvalid(c, "Invalid request body", nil)
+		return
+	}
+
+	assetType, ok := multipartAssetTypes[req.AssetType]
+	if !ok {
+		h.respondInvalid(c, "Unsupported asset type", map[string]interface{}{"asset_type": req.AssetType})
+		return
+	}
+	filename := sanitizeFilename(req.Filename)
+	if !multipartTypeAllowed(assetType, req.ContentType, filename) {
+		h.respondInvalid(c, "Unsupported file type", map[string]interface{}{
+			"content_type": req.ContentType,
+			"filename":     filename,
+		})
+		return
+	}
+	if req.FileSize > MaxMultipartUploadSize {
+		h.respondInvalid(c, "File is too large", map[string]interface{}{"max_size": MaxMultipartUploadSize})
+		return
+	}
+
+	key := fmt.Sprintf("%s%s/%d_%s", h.locator.UploadPrefix(userID), assetType.folder, time.Now().Unix(), filename)
+	uploadID, err := h.store.CreateMultipartUpload(c.Request.Context(), key, req.ContentType)
+	if err != nil {
+		h.logger.Error("Failed to create multipart upload",
+			zap.String("user_id", userID),
+			zap.String("s3_key", key),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrStorageError,
+		})
+		return
+	}
+
+	h.logger.Info("Multipart upload started",
+		zap.String("user_id", userID),
+		zap.String("s3_key", key),
+		zap.String("content_type", req.ContentType),
+		zap.Int64("file_size", req.FileSize),
+	)
+
+	c.JSON(http.StatusOK, InitiateMultipartResponse{
+		UploadID:  uploadID,
+		Key:       key,
+		PartSize:  MultipartPartSize,
+		PartCount: int32((req.FileSize + MultipartPartSize - 1) / MultipartPartSize),
+		AssetURL:  fmt.Sprintf("https://%s.s3.amazonaws.com/%s", h.assetsBucket, key),
+	})
+}
+
+// PresignParts handles POST /api/v1/assets/multipart/parts
+// @Summary Presign upload parts
+// @Description Returns presigned PUT URLs for a batch of part numbers. Request parts again to retry or resume after the URLs expire.
+// @Tags upload
+// @Accept json
+// @Produce json
+// @Param body body MultipartPartsRequest true "Parts to presign"
+// @Success 200 {object} MultipartPartsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/assets/multipart/parts [post]
+// @Security BearerAuth
+func (h *MultipartUploadHandler) PresignParts(c *gin.Context) {
+	var req MultipartPartsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondInvalid(c, "Invalid request body", nil)
+		return
+	}
+	if !h.ownsUploadKey(c, req.Key) {
+		return
+	}
+	if len(req.PartNumbers) == 0 || len(req.PartNumbers) > maxPartURLsPerRequest {
+		h.respondInvalid(c, fmt.Sprintf("Request between 1 and %d parts at a time", maxPartURLsPerRequest), nil)
+		return
+	}
+
+	seen := make(map[int32]bool, len(req.PartNumbers))
+	parts := make([]MultipartPartURL, 0, len(req.PartNumbers))
+	for _, number := range req.PartNumbers {
+		if number < 1 || number > maxMultipartParts || seen[number] {
+			h.respondInvalid(c, fmt.Sprintf("Part numbers must be unique and between 1 and %d", maxMultipartParts),
+				map[string]interface{}{"part_number": number})
+			return
+		}
+		seen[number] = true
+
+		url, err := h.store.PresignUploadPart(c.Request.Context(), req.Key, req.UploadID, number, multipartPartURLExpiry)
+		if err != nil {
+			h.logger.Error("Failed to presign upload part",
+				zap.String("s3_key", req.Key),
+				zap.Int32("part_number", number),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error: errors.ErrStorageError,
+			})
+			return
+		}
+		parts = append(parts, MultipartPartURL{PartNumber: number, UploadURL: url})
+	}
+
+	c.JSON(http.StatusOK, MultipartPartsResponse{
+		Parts:     parts,
+		ExpiresAt: time.Now().Add(multipartPartURLExpiry).Unix(),
+	})
+}
+
+// CompleteUpload handles POST /api/v1/assets/multipart/complete
+// @Summary Complete a multipart upload
+// @Description Checks the parts against what S3 received and assembles them into the asset
+// @Tags upload
+// @Accept json
+// @Produce json
+// @Param body body CompleteMultipartRequest true "Uploaded parts"
+// @Success 200 {object} CompleteMultipartResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/assets/multipart/complete [post]
+// @Security BearerAuth
+func (h *MultipartUploadHandler) CompleteUpload(c *gin.Context) {
+	var req CompleteMultipartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondInvalid(c, "Invalid request body", nil)
+		return
+	}
+	if !h.ownsUploadKey(c, req.Key) {
+		return
+	}
+	if len(req.Parts) == 0 || len(req.Parts) > int(maxMultipartParts) {
+		h.respondInvalid(c, fmt.Sprintf("Uploads have between 1 and %d parts", maxMultipartParts), nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	received, err := h.store.UploadedParts(ctx, req.Key, req.UploadID)
+	if err == repository.ErrUploadNotFound {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrNotFound, "Upload not found. It may have expired; start a new upload.", nil),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list uploaded parts", zap.String("s3_key", req.Key), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrStorageError,
+		})
+		return
+	}
+
+	parts, size, apiErr := matchUploadedParts(req.Parts, received)
+	if apiErr != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{Error: apiErr})
+		return
+	}
+	if size > MaxMultipartUploadSize {
+		h.abort(ctx, req.Key, req.UploadID)
+		h.respondInvalid(c, "File is too large", map[string]interface{}{"max_size": MaxMultipartUploadSize})
+		return
+	}
+
+	if err := h.store.CompleteMultipartUpload(ctx, req.Key, req.UploadID, parts); err != nil {
+		h.logger.Error("Failed to complete multipart upload", zap.String("s3_key", req.Key), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrStorageError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, CompleteMultipartResponse{
+		Key:       req.Key,
+		AssetURL:  fmt.Sprintf("https://%s.s3.amazonaws.com/%s", h.assetsBucket, req.Key),
+		SizeBytes: size,
+	})
+}
+
+// AbortUpload handles DELETE /api/v1/assets/multipart/abort
+// @Summary Abort a multipart upload
+// @Description Abandons an upload and deletes the parts uploaded so far
+// @Tags upload
+// @Param key query string true "Upload key"
+// @Param upload_id query string true "Upload ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/assets/multipart/abort [delete]
+// @Security BearerAuth
+func (h *MultipartUploadHandler) AbortUpload(c *gin.Context) {
+	key, uploadID := c.Query("key"), c.Query("upload_id")
+	if key == "" || uploadID == "" {
+		h.respondInvalid(c, "key and upload_id are required", nil)
+		return
+	}
+	if !h.ownsUploadKey(c, key) {
+		return
+	}
+
+	// Aborting an upload that is already gone is not an error
+	err := h.store.AbortMultipartUpload(c.Request.Context(), key, uploadID)
+	if err != nil && err != repository.ErrUploadNotFound {
+		h.logger.Error("Failed to abort multipart upload", zap.String("s3_key", key), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrStorageError,
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RunJanitor aborts abandoned uploads now and then every multipartJanitorInterval until ctx is
+// done, so their parts stop being billed
+func (h *MultipartUploadHandler) RunJanitor(ctx context.Context) {
+	h.abortStale(ctx)
+
+	ticker := time.NewTicker(multipartJanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.abortStale(ctx)
+		}
+	}
+}
+
+func (h *MultipartUploadHandler) abortStale(ctx context.Context) {
+	cutoff := time.Now().Add(-multipartUploadMaxAge)
+	for _, root := range h.locator.UserAssetRoots() {
+		aborted, err := h.store.AbortStaleMultipartUploads(ctx, root, cutoff)
+		if err != nil {
+			h.logger.Error("Failed to abort stale multipart uploads", zap.String("prefix", root), zap.Error(err))
+			continue
+		}
+		if aborted > 0 {
+			h.logger.Info("Aborted stale multipart uploads", zap.String("prefix", root), zap.Int("aborted", aborted))
+		}
+	}
+}
+
+func (h *MultipartUploadHandler) abort(ctx context.Context, key, uploadID string) {
+	if err := h.store.AbortMultipartUpload(ctx, key, uploadID); err != nil && err != repository.ErrUploadNotFound {
+		h.logger.Warn("Failed to abort multipart upload", zap.String("s3_key", key), zap.Error(err))
+	}
+}
+
+// ownsUploadKey checks the key is one of the user's uploads of an allowed type, responding 403
+// if not. Keys name the object, so they are all a client needs to act on another user's upload.
+func (h *MultipartUploadHandler) ownsUploadKey(c *gin.Context, key string) bool {
+	userID := auth.MustGetUserID(c)
+	if multipartKeyAllowed(h.locator, userID, key) {
+		return true
+	}
+	h.logger.Warn("User attempted to use an upload key outside their uploads",
+		zap.String("user_id", userID),
+		zap.String("s3_key", key),
+	)
+	c.JSON(http.StatusForbidden, errors.ErrorResponse{
+		Error: errors.ErrForbidden,
+	})
+	return false
+}
+
+func (h *MultipartUploadHandler) respondInvalid(c *gin.Context, message string, details map[string]interface{}) {
+	c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+		Error: errors.NewAPIError(errors.ErrInvalidRequest, message, details),
+	})
+}
+
+// multipartKeyAllowed reports whether key is an upload InitiateUpload could have created for userID
+func multipartKeyAllowed(locator *repository.AssetLocator, userID, key string) bool {
+	rest, ok := locator.UploadName(userID, key)
+	if !ok {
+		return false
+	}
+	folder, filename, ok := strings.Cut(rest, "/")
+	if !ok || strings.Contains(filename, "/") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, assetType := range multipartAssetTypes {
+		if assetType.folder != folder {
+			continue
+		}
+		for _, extensions := range assetType.contentTypes {
+			for _, allowed := range extensions {
+				if ext == allowed {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// multipartTypeAllowed reports whether assetType accepts contentType with filename's extension
+func multipartTypeAllowed(assetType multipartAssetType, contentType, filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, allowed := range assetType.contentTypes[strings.ToLower(contentType)] {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// matchUploadedParts checks the client's part list against the parts S3 received: part numbers
+// ascending without repeats, and every ETag the one S3 returned for that part. It returns the
+// parts to complete with and their total size.
+func matchUploadedParts(claimed []MultipartCompletedPart, received []repository.UploadPart) ([]repository.UploadPart, int64, *errors.APIError) {
+	byNumber := make(map[int32]repository.UploadPart, len(received))
+	for _, part := range received {
+		byNumber[part.PartNumber] = part
+	}
+
+	parts := make([]repository.UploadPart, 0, len(claimed))
+	var size int64
+	previous := int32(0)
+	for _, part := range claimed {
+		if part.PartNumber <= previous {
+			return nil, 0, errors.NewAPIError(errors.ErrInvalidRequest, "Parts must be listed in ascending order without repeats",
+				map[string]interface{}{"part_number": part.PartNumber})
+		}
+		previous = part.PartNumber
+
+		uploaded, ok := byNumber[part.PartNumber]
+		if !ok {
+			return nil, 0, errors.NewAPIError(errors.ErrInvalidRequest, fmt.Sprintf("Part %d was not uploaded", part.PartNumber),
+				map[string]interface{}{"part_number": part.PartNumber})
+		}
+		if strings.Trim(part.ETag, `"`) != strings.Trim(uploaded.ETag, `"`) {
+			return nil, 0, errors.NewAPIError(errors.ErrInvalidRequest, fmt.Sprintf("Part %d does not match the uploaded data; upload it again", part.PartNumber),
+				map[string]interface{}{"part_number": part.PartNumber})
+		}
+		parts = append(parts, uploaded)
+		size += uploaded.Size
+	}
+	return parts, size, nil
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeMultipartStore stands in for the S3 multipart API: uploads are keyed by upload ID and
+// hold the parts "received" so far
+type fakeMultipartStore struct {
+	uploads   map[string][]repository.UploadPart
+	completed map[string][]repository.UploadPart
+	aborted   []string
+	initiated map[string]time.Time
+}
+
+func newFakeMultipartStore() *fakeMultipartStore {
+	return &fakeMultipartStore{
+		uploads:   make(map[string][]repository.UploadPart),
+		completed: make(map[string][]repository.UploadPart),
+		initiated: make(map[string]time.Time),
+	}
+}
+
+func (s *fakeMultipartStore) CreateMultipartUpload(_ context.Context, key, _ string) (string, error) {
+	id := fmt.Sprintf("upload-%d", len(s.initiated)+1)
+	s.uploads[id] = nil
+	s.initiated[id] = time.Now()
+	return id, nil
+}
+
+func (s *fakeMultipartStore) PresignUploadPart(_ context.Context, key, uploadID string, partNumber int32, _ time.Duration) (string, error) {
+	return fmt.Sprintf("https://signed.example.com/%s?uploadId=%s&partNumber=%d", key, uploadID, partNumber), nil
+}
+
+func (s *fakeMultipartStore) UploadedParts(_ context.Context, _, uploadID string) ([]repository.UploadPart, error) {
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		return nil, repository.ErrUploadNotFound
+	}
+	return parts, nil
+}
+
+func (s *fakeMultipartStore) CompleteMultipartUpload(_ context.Context, _, uploadID string, parts []repository.UploadPart) error {
+	s.completed[uploadID] = parts
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+func (s *fakeMultipartStore) AbortMultipartUpload(_ context.Context, _, uploadID string) error {
+	if _, ok := s.uploads[uploadID]; !ok {
+		return repository.ErrUploadNotFound
+	}
+	delete(s.uploads, uploadID)
+	s.aborted = append(s.aborted, uploadID)
+	return nil
+}
+
+func (s *fakeMultipartStore) AbortStaleMultipartUploads(ctx context.Context, _ string, cutoff time.Time) (int, error) {
+	aborted := 0
+	for id := range s.uploads {
+		if s.initiated[id].Before(cutoff) {
+			s.AbortMultipartUpload(ctx, "", id)
+			aborted++
+		}
+	}
+	return aborted, nil
+}
+
+func multipartTestRouter(store *fakeMultipartStore) *gin.Engine {
+	return multipartLocatorRouter(store, nil)
+}
+
+// multipartLocatorRouter keys uploads with locator's asset key prefix
+func multipartLocatorRouter(store *fakeMultipartStore, locator *repository.AssetLocator) *gin.Engine {
+	h := NewMultipartUploadHandler(store, "assets", locator, zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.POST("/assets/multipart/initiate", h.InitiateUpload)
+	v1.POST("/assets/multipart/parts", h.PresignParts)
+	v1.POST("/assets/multipart/complete", h.CompleteUpload)
+	v1.DELETE("/assets/multipart/abort", h.AbortUpload)
+	return router
+}
+
+func serveMultipart(router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, &payload))
+	return w
+}
+
+func initiateTestUpload(t *testing.T, router *gin.Engine, fileSize int64) InitiateMultipartResponse {
+	t.Helper()
+	w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/initiate", InitiateMultipartRequest{
+		AssetType:   "style_reference",
+		Filename:    "../brand film.mov",
+		ContentType: "video/quicktime",
+		FileSize:    fileSize,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response InitiateMultipartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestInitiateUpload(t *testing.T) {
+	router := multipartTestRouter(newFakeMultipartStore())
+
+	upload := initiateTestUpload(t, router, 3*MultipartPartSize+1)
+	require.Regexp(t, `^users/user-123/uploads/style_references/\d+_brand film\.mov$`, upload.Key)
+	require.Equal(t, int32(4), upload.PartCount)
+	require.Equal(t, MultipartPartSize, upload.PartSize)
+	require.Equal(t, "https://assets.s3.amazonaws.com/"+upload.Key, upload.AssetURL)
+
+	for name, req := range map[string]InitiateMultipartRequest{
+		"unknown asset type":       {AssetType: "brand_document", Filename: "a.pdf", ContentType: "application/pdf", FileSize: 10},
+		"content type not allowed": {AssetType: "product_image", Filename: "a.mp4", ContentType: "video/mp4", FileSize: 10},
+		"extension does not match": {AssetType: "product_image", Filename: "a.exe", ContentType: "image/png", FileSize: 10},
+		"larger than the max size": {AssetType: "product_image", Filename: "a.png", ContentType: "image/png", FileSize: MaxMultipartUploadSize + 1},
+		"missing file size":        {AssetType: "product_image", Filename: "a.png", ContentType: "image/png"},
+	} {
+		w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/initiate", req)
+		require.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestInitiateUpload_UnderAssetKeyPrefix(t *testing.T) {
+	router := multipartLocatorRouter(newFakeMultipartStore(), repository.NewAssetLocator("assets", "env/staging", nil))
+
+	upload := initiateTestUpload(t, router, 10)
+	require.Regexp(t, `^env/staging/users/user-123/uploads/style_references/\d+_brand film\.mov$`, upload.Key)
+
+	w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/parts", MultipartPartsRequest{
+		Key: upload.Key, UploadID: upload.UploadID, PartNumbers: []int32{1},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Keys under another user's folder are refused whatever the prefix
+	w = serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/parts", MultipartPartsRequest{
+		Key: "env/staging/users/user-456/uploads/style_references/1_a.mov", UploadID: upload.UploadID, PartNumbers: []int32{1},
+	})
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPresignParts_Limits(t *testing.T) {
+	router := multipartTestRouter(newFakeMultipartStore())
+	upload := initiateTestUpload(t, router, 10*MultipartPartSize)
+
+	w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/parts", MultipartPartsRequest{
+		Key: upload.Key, UploadID: upload.UploadID, PartNumbers: []int32{1, 2, 3},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response MultipartPartsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Parts, 3)
+	require.Equal(t, int32(3), response.Parts[2].PartNumber)
+	require.Contains(t, response.Parts[2].UploadURL, "partNumber=3")
+	require.Greater(t, response.ExpiresAt, time.Now().Unix())
+
+	tooMany := make([]int32, maxPartURLsPerRequest+1)
+	for i := range tooMany {
+		tooMany[i] = int32(i + 1)
+	}
+	for name, numbers := range map[string][]int32{
+		"no parts":             {},
+		"batch too large":      tooMany,
+		"part zero":            {0},
+		"beyond the last part": {maxMultipartParts + 1},
+		"repeated part":        {2, 2},
+	} {
+		w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/parts", MultipartPartsRequest{
+			Key: upload.Key, UploadID: upload.UploadID, PartNumbers: numbers,
+		})
+		require.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+
+	// Keys outside the user's uploads are refused whatever the upload ID
+	for _, key := range []string{
+		"users/user-456/uploads/style_references/1_brand.mov",
+		"users/user-123/jobs/job-1/final/video.mp4",
+		"users/user-123/uploads/style_references/../../jobs/job-1/final/video.mp4",
+		"users/user-123/uploads/style_references/1_payload.html",
+	} {
+		w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/parts", MultipartPartsRequest{
+			Key: key, UploadID: upload.UploadID, PartNumbers: []int32{1},
+		})
+		require.Equal(t, http.StatusForbidden, w.Code, key)
+	}
+}
+
+func TestCompleteUpload_ChecksETags(t *testing.T) {
+	store := newFakeMultipartStore()
+	router := multipartTestRouter(store)
+	upload := initiateTestUpload(t, router, 2*MultipartPartSize)
+	store.uploads[upload.UploadID] = []repository.UploadPart{
+		{PartNumber: 1, ETag: `"etag-1"`, Size: MultipartPartSize},
+		{PartNumber: 2, ETag: `"etag-2"`, Size: MultipartPartSize},
+	}
+
+	for name, parts := range map[string][]MultipartCompletedPart{
+		"mismatched etag":   {{PartNumber: 1, ETag: "etag-1"}, {PartNumber: 2, ETag: "etag-stale"}},
+		"part not uploaded": {{PartNumber: 1, ETag: "etag-1"}, {PartNumber: 3, ETag: "etag-3"}},
+		"out of order":      {{PartNumber: 2, ETag: "etag-2"}, {PartNumber: 1, ETag: "etag-1"}},
+		"no parts":          {},
+	} {
+		w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/complete", CompleteMultipartRequest{
+			Key: upload.Key, UploadID: upload.UploadID, Parts: parts,
+		})
+		require.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+	require.Empty(t, store.completed)
+
+	// Browsers expose the ETag header with or without its quotes
+	w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/complete", CompleteMultipartRequest{
+		Key:      upload.Key,
+		UploadID: upload.UploadID,
+		Parts:    []MultipartCompletedPart{{PartNumber: 1, ETag: "etag-1"}, {PartNumber: 2, ETag: `"etag-2"`}},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response CompleteMultipartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 2*MultipartPartSize, response.SizeBytes)
+	require.Equal(t, []repository.UploadPart{
+		{PartNumber: 1, ETag: `"etag-1"`, Size: MultipartPartSize},
+		{PartNumber: 2, ETag: `"etag-2"`, Size: MultipartPartSize},
+	}, store.completed[upload.UploadID])
+
+	// A completed upload can't be completed again
+	w = serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/complete", CompleteMultipartRequest{
+		Key: upload.Key, UploadID: upload.UploadID, Parts: []MultipartCompletedPart{{PartNumber: 1, ETag: "etag-1"}},
+	})
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCompleteUpload_AbortsOversizedUpload(t *testing.T) {
+	store := newFakeMultipartStore()
+	router := multipartTestRouter(store)
+	upload := initiateTestUpload(t, router, MultipartPartSize)
+
+	// The client declared one part but sent more data than any upload may have
+	store.uploads[upload.UploadID] = []repository.UploadPart{{PartNumber: 1, ETag: `"etag-1"`, Size: MaxMultipartUploadSize + 1}}
+	w := serveMultipart(router, http.MethodPost, "/api/v1/assets/multipart/complete", CompleteMultipartRequest{
+		Key: upload.Key, UploadID: upload.UploadID, Parts: []MultipartCompletedPart{{PartNumber: 1, ETag: "etag-1"}},
+	})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, []string{upload.UploadID}, store.aborted)
+	require.Empty(t, store.completed)
+}
+
+func TestAbortUpload(t *testing.T) {
+	store := newFakeMultipartStore()
+	router := multipartTestRouter(store)
+	upload := initiateTestUpload(t, router, MultipartPartSize)
+	path := "/api/v1/assets/multipart/abort?" + url.Values{"key": {upload.Key}, "upload_id": {upload.UploadID}}.Encode()
+
+	require.Equal(t, http.StatusForbidden, serveMultipart(router, http.MethodDelete,
+		"/api/v1/assets/multipart/abort?key=users/user-456/uploads/style_references/1_a.mov&upload_id="+upload.UploadID, nil).Code)
+	require.Contains(t, store.uploads, upload.UploadID)
+
+	require.Equal(t, http.StatusNoContent, serveMultipart(router, http.MethodDelete, path, nil).Code)
+	require.Equal(t, []string{upload.UploadID}, store.aborted)
+	require.NotContains(t, store.uploads, upload.UploadID)
+
+	// Aborting twice is harmless
+	require.Equal(t, http.StatusNoContent, serveMultipart(router, http.MethodDelete, path, nil).Code)
+}
+
+func TestMultipartJanitor_AbortsStaleUploads(t *testing.T) {
+	store := newFakeMultipartStore()
+	router := multipartTestRouter(store)
+	stale := initiateTestUpload(t, router, MultipartPartSize)
+	fresh := initiateTestUpload(t, router, MultipartPartSize)
+	store.initiated[stale.UploadID] = time.Now().Add(-multipartUploadMaxAge - time.Minute)
+
+	h := NewMultipartUploadHandler(store, "assets", nil, zap.NewNop())
+	h.abortStale(context.Background())
+	require.Equal(t, []string{stale.UploadID}, store.aborted)
+	require.Contains(t, store.uploads, fresh.UploadID)
+}
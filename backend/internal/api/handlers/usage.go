@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// UsageHandler serves the user's credit balance and charges
+type UsageHandler struct {
+	usageService *service.UsageService
+	logger       *zap.Logger
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(usageService *service.UsageService, logger *zap.Logger) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+		logger:       logger,
+	}
+}
+
+// subscriptionTier returns the caller's tier from their token claims, or the
+// development mock's tier, defaulting to free
+func subscriptionTier(c *gin.Context) string {
+	if tier, ok := auth.GetSubscriptionTier(c); ok && tier != "" {
+		return tier
+	}
+	if tier := c.GetString("subscription_tier"); tier != "" {
+		return tier
+	}
+	return "free"
+}
+
+// insufficientCreditsError builds the 402 body for a rejected charge
+func insufficientCreditsError(err *repository.InsufficientCreditsError, cost pricing.JobCost) *errors.APIError {
+	return errors.ErrInsufficientCredits.WithDetails(map[string]interface{}{
+		"required":          err.Required,
+		"credits_remaining": err.Remaining,
+		"cost":              cost,
+	})
+}
+
+// GetUsage handles GET /api/v1/usage
+// @Summary Get credit usage
+// @Description Get the current credit balance, this month's consumption (net of refunds) and the cost of each job charged this month
+// @Tags usage
+// @Produce json
+// @Success 200 {object} service.UsageSummary
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /api/v1/usage [get]
+// @Security BearerAuth
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	userID := auth.MustGetUserID(c)
+
+	summary, err := h.usageService.Summary(c.Request.Context(), userID, subscriptionTier(c))
+	if err != nil {
+		h.logger.Error("Failed to get usage",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error: errors.ErrDatabaseError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// respondChargeError writes the response for a failed credit charge: 402 when the
+// balance is too low, 500 otherwise
+func respondChargeError(c *gin.Context, logger *zap.Logger, userID string, err error, cost pricing.JobCost) {
+	var insufficient *repository.InsufficientCreditsError
+	if stderrors.As(err, &insufficient) {
+		c.JSON(http.StatusPaymentRequired, errors.ErrorResponse{
+			Error: insufficientCreditsError(insufficient, cost),
+		})
+		return
+	}
+
+	logger.Error("Failed to charge credits",
+		zap.String("user_id", userID),
+		zap.Error(err),
+	)
+	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+		Error: errors.ErrDatabaseError,
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRespondChargeError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cost := pricing.QuoteJob(30, 4, adapters.AdapterTypeVeo)
+
+	t.Run("insufficient credits is a structured 402", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		respondChargeError(c, zap.NewNop(), "user-1", &repository.InsufficientCreditsError{Required: 80, Remaining: 30}, cost)
+
+		require.Equal(t, http.StatusPaymentRequired, recorder.Code)
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Details struct {
+					Required         int             `json:"required"`
+					CreditsRemaining int             `json:"credits_remaining"`
+					Cost             pricing.JobCost `json:"cost"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		require.Equal(t, "INSUFFICIENT_CREDITS", body.Error.Code)
+		require.Equal(t, 80, body.Error.Details.Required)
+		require.Equal(t, 30, body.Error.Details.CreditsRemaining)
+		require.Equal(t, cost, body.Error.Details.Cost)
+	})
+
+	t.Run("other failures are a 500", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		respondChargeError(c, zap.NewNop(), "user-1", errors.New("throttled"), cost)
+		require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	})
+}
+
+func TestSubscriptionTier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	require.Equal(t, "free", subscriptionTier(c))
+
+	c.Set("subscription_tier", "pro")
+	require.Equal(t, "pro", subscriptionTier(c))
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobprogress"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"go.uber.org/zap"
+)
+
+// VariantSummary is one A/B variant of a parent job
+type VariantSummary struct {
+	JobID           string  `json:"job_id"`
+	VariantIndex    int     `json:"variant_index"`
+	Status          string  `json:"status"`
+	Stage           string  `json:"stage,omitempty"`
+	ProgressPercent int     `json:"progress_percent"`
+	Title           string  `json:"title,omitempty"`
+	ThumbnailURL    string  `json:"thumbnail_url,omitempty"`
+	VideoURL        string  `json:"video_url,omitempty"`
+	ErrorMessage    *string `json:"error_message,omitempty"`
+}
+
+// newVariantJobs creates the child jobs of an A/B variant parent and links them to it. Children
+// copy the parent's brief, uploaded images and brand settings; each waits for its own script,
+// then is charged, generated and refunded as a job of its own. The parent alone calls back.
+func newVariantJobs(parent *domain.Job) []*domain.Job {
+	variants := make([]*domain.Job, parent.Variants)
+	parent.VariantJobIDs = make([]string, parent.Variants)
+	for i := range variants {
+		variant := *parent
+		variant.JobID = fmt.Sprintf("job-%s", uuid.New().String())
+		variant.Status = domain.StatusPending
+		variant.Stage = domain.StageAwaitingScript
+		variant.Variants = 0
+		variant.VariantJobIDs = nil
+		variant.ParentJobID = parent.JobID
+		variant.VariantIndex = i + 1
+		variant.CallbackURL = ""
+		variant.CallbackSecret = ""
+
+		variants[i] = &variant
+		parent.VariantJobIDs[i] = variant.JobID
+	}
+	return variants
+}
+
+// chargeVariants takes each variant's credits. If one can't be charged, the variants already
+// charged are refunded and an insufficient balance is reported for the whole request.
+func (h *GenerateHandler) chargeVariants(ctx context.Context, variants []*domain.Job, subscriptionTier string, cost pricing.JobCost) error {
+	for i, variant := range variants {
+		if _, err := h.usageService.ChargeJob(ctx, variant, subscriptionTier, cost); err != nil {
+			for _, charged := range variants[:i] {
+				h.refundCredits(charged)
+			}
+			var insufficient *repository.InsufficientCreditsError
+			if stderrors.As(err, &insufficient) {
+				err = &repository.InsufficientCreditsError{
+					Required:  cost.Credits * len(variants),
+					Remaining: insufficient.Remaining + cost.Credits*i,
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// createVariantJobs stores a parent's variants before the parent, so its pipeline always finds them
+func (h *GenerateHandler) createVariantJobs(ctx context.Context, variants []*domain.Job) error {
+	for _, variant := range variants {
+		if err := h.jobRepo.CreateJob(ctx, variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateVariantsAsync runs an A/B variant parent: all the variants' scripts are written in one
+// GPT-4o call, then each variant is started as a job of its own, queued if the user is at their
+// active job limit. The parent then follows its variants (see refreshVariantParent).
+func (h *GenerateHandler) generateVariantsAsync(ctx context.Context, job *domain.Job, req GenerateRequest, brand *domain.BrandGuidelines) {
+	jobCtx, cancel := context.WithTimeout(h.withTokenBudget(ctx, job), VideoGenerationTimeout)
+	defer cancel()
+
+	h.log(ctx).Info("Generating A/B variant scripts", zap.Int("variants", job.Variants))
+	if !h.enterStage(jobCtx, job, domain.StageScriptGenerating) {
+		return
+	}
+	if err := h.jobRepo.UpdateJobStage(jobCtx, job); err != nil {
+		h.log(jobCtx).Error("Failed to update job stage",
+			zap.Stringer("stage", job.Stage),
+			zap.Error(err),
+		)
+	}
+
+	scriptStart := time.Now()
+	scriptCtx, scriptDone := h.trackStep(h.withProvenance(jobCtx, job, metricStageScript), job, domain.StepScript)
+	scripts, err := h.parserService.GenerateScriptVariants(scriptCtx, h.scriptParseRequest(job, req, brand), job.Variants)
+	timeStage(jobCtx, job, metricStageScript, scriptStart)
+	if err != nil {
+		h.failJob(jobCtx, job, job.Stage.String(), scriptFailure(err), err)
+		return
+	}
+	scriptDone(0)
+	if h.stopIfCanceled(jobCtx, job) {
+		return
+	}
+
+	variants, err := h.jobRepo.ListVariantJobs(jobCtx, job.JobID)
+	if err != nil {
+		h.failJob(jobCtx, job, job.Stage.String(), "Variants could not be started. Please try again.", err)
+		return
+	}
+
+	// The parent stops counting as an active job once this write lands, before any variant is admitted
+	if !h.enterStage(jobCtx, job, domain.StageVariantsGenerating) {
+		return
+	}
+	if err := h.saveJobProgress(jobCtx, job); err != nil {
+		h.log(jobCtx).Error("Failed to update job stage",
+			zap.Stringer("stage", job.Stage),
+			zap.Error(err),
+		)
+	}
+
+	for _, variant := range variants {
+		if variant.Status != domain.StatusPending || variant.VariantIndex < 1 || variant.VariantIndex > len(scripts) {
+			continue
+		}
+		h.embedScript(jobCtx, variant, scripts[variant.VariantIndex-1])
+		h.warnRepeatedScenes(jobCtx, variant, scripts[variant.VariantIndex-1])
+		h.saveScript(jobCtx, variant)
+		err := variant.AdvanceStage(domain.StageScriptComplete)
+		startNow := false
+		if err == nil {
+			variant.Status = domain.StatusProcessing
+			variant.UpdatedAt = time.Now().Unix()
+			startNow, err = h.saveNewJob(jobCtx, variant, h.jobRepo.UpdateJob)
+		}
+		if err != nil {
+			// Left awaiting its script, so it is failed with the parent's message below
+			h.log(jobCtx).Error("Failed to start variant",
+				zap.String("variant_job_id", variant.JobID),
+				zap.Error(err),
+			)
+			continue
+		}
+		if startNow {
+			h.startSavedJob(ctx, variant)
+		}
+
+		h.log(jobCtx).Info("Variant script ready",
+			zap.String("variant_job_id", variant.JobID),
+			zap.Int("variant", variant.VariantIndex),
+			zap.String("title", variant.Title),
+			zap.Int("num_scenes", len(variant.Scenes)),
+			zap.Bool("started", startNow),
+		)
+	}
+}
+
+// settleVariantFamily runs when a job's pipeline stops. A variant may have finished its parent;
+// a parent that stopped before its variants got a script leaves them to be failed or canceled.
+func (h *GenerateHandler) settleVariantFamily(job *domain.Job) {
+	switch {
+	case job.ParentJobID != "":
+		h.refreshVariantParent(job.ParentJobID)
+	case job.IsVariantParent():
+		h.releaseWaitingVariants(job.JobID)
+		h.refreshVariantParent(job.JobID)
+	}
+}
+
+// releaseWaitingVariants ends the variants of a parent that stopped without writing their
+// scripts, the same way the parent ended, and refunds them
+func (h *GenerateHandler) releaseWaitingVariants(parentJobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	parent, err := h.jobRepo.GetJob(ctx, parentJobID)
+	if err != nil {
+		h.logger.Warn("Failed to load variant parent", zap.String("job_id", parentJobID), zap.Error(err))
+		return
+	}
+	variants, err := h.jobRepo.ListVariantJobs(ctx, parentJobID)
+	if err != nil {
+		return
+	}
+
+	message := scriptFailureMessage
+	if parent.ErrorMessage != nil {
+		message = *parent.ErrorMessage
+	}
+	for _, variant := range variants {
+		if variant.Stage != domain.StageAwaitingScript || variant.Status != domain.StatusPending {
+			continue
+		}
+		h.refundCredits(variant)
+		if parent.Status == domain.StatusCanceled {
+			err = h.jobRepo.MarkJobCanceled(ctx, variant.JobID)
+		} else {
+			err = h.jobRepo.MarkJobFailed(ctx, variant.JobID, message, domain.StageScriptGenerating.String(), "variant parent stopped: "+parent.Status)
+		}
+		if err != nil {
+			h.logger.Error("Failed to end waiting variant", zap.String("job_id", variant.JobID), zap.Error(err))
+		}
+	}
+}
+
+// refreshVariantParent stores the status of a parent aggregated from its variants, and sends the
+// parent's webhook when the last variant finishes. Nothing changes while a variant still waits
+// for its script, since the parent's own pipeline is then still running, or once the parent
+// has finished.
+func (h *GenerateHandler) refreshVariantParent(parentJobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	parent, err := h.jobRepo.GetJob(ctx, parentJobID)
+	if err != nil {
+		h.logger.Warn("Failed to load variant parent", zap.String("job_id", parentJobID), zap.Error(err))
+		return
+	}
+	if isTerminalStatus(parent.Status) {
+		return
+	}
+	variants, err := h.jobRepo.ListVariantJobs(ctx, parentJobID)
+	if err != nil {
+		return
+	}
+	summary, ok := summarizeVariants(variants)
+	if !ok || (summary.Status == parent.Status && summary.Stage == parent.Stage) {
+		return
+	}
+
+	// Variants finishing together race to write the parent; one that already finished it wins
+	finished := false
+	now := time.Now().Unix()
+	apply := func(job *domain.Job) {
+		if isTerminalStatus(job.Status) {
+			finished = false
+			return
+		}
+		finished = isTerminalStatus(summary.Status)
+		job.Status = summary.Status
+		job.Stage = summary.Stage
+		job.UpdatedAt = now
+		if summary.Status == domain.StatusCompleted {
+			job.CompletedAt = &now
+		}
+		if summary.Status == domain.StatusFailed && job.ErrorMessage == nil {
+			message := "Every variant failed. See each variant for details."
+			job.ErrorMessage = &message
+		}
+	}
+	apply(parent)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, parent, apply); err != nil {
+		h.logger.Error("Failed to update variant parent", zap.String("job_id", parentJobID), zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Variant parent updated",
+		zap.String("job_id", parentJobID),
+		zap.String("status", parent.Status),
+		zap.Stringer("stage", parent.Stage),
+	)
+	if finished {
+		h.notifyJobFinished(parentJobID)
+	}
+}
+
+// reopenVariantParent moves a finished parent back to processing after one of its variants
+// was requeued, so it finishes again, and calls back again, once that variant does
+func (h *GenerateHandler) reopenVariantParent(ctx context.Context, parentJobID string) {
+	parent, err := h.jobRepo.GetJob(ctx, parentJobID)
+	if err != nil {
+		h.logger.Warn("Failed to load variant parent", zap.String("job_id", parentJobID), zap.Error(err))
+		return
+	}
+	if !isTerminalStatus(parent.Status) {
+		return
+	}
+
+	reopen := func(job *domain.Job) {
+		job.Status = domain.StatusProcessing
+		job.Stage = domain.StageVariantsGenerating
+		job.ErrorMessage = nil
+		job.CompletedAt = nil
+		job.UpdatedAt = time.Now().Unix()
+	}
+	reopen(parent)
+	if err := h.jobRepo.UpdateJobWithRetry(ctx, parent, reopen); err != nil {
+		h.logger.Error("Failed to reopen variant parent", zap.String("job_id", parentJobID), zap.Error(err))
+	}
+}
+
+// variantSummary is the state of a parent derived from its variants
+type variantSummary struct {
+	Status   string
+	Stage    domain.JobStage
+	Progress int
+}
+
+// summarizeVariants aggregates a parent's status from its variants: processing until every
+// variant finished, then completed if any variant completed, canceled if all were canceled and
+// failed otherwise. Progress averages the variants, counting finished ones as done. It reports
+// false while a variant is still waiting for its script.
+func summarizeVariants(variants []*domain.Job) (variantSummary, bool) {
+	if len(variants) == 0 {
+		return variantSummary{}, false
+	}
+
+	var finished, completed, canceled, progress int
+	for _, variant := range variants {
+		if variant.Stage == domain.StageAwaitingScript && variant.Status == domain.StatusPending {
+			return variantSummary{}, false
+		}
+		if isTerminalStatus(variant.Status) {
+			finished++
+			progress += 100
+		} else {
+			progress += jobprogress.Percent(variant)
+		}
+		switch variant.Status {
+		case domain.StatusCompleted:
+			completed++
+		case domain.StatusCanceled:
+			canceled++
+		}
+	}
+
+	summary := variantSummary{
+		Status:   domain.StatusProcessing,
+		Stage:    domain.VariantsFinished(finished, len(variants)),
+		Progress: progress / len(variants),
+	}
+	switch {
+	case finished < len(variants):
+	case completed > 0:
+		summary.Status, summary.Stage = domain.StatusCompleted, domain.StageComplete
+	case canceled == len(variants):
+		summary.Status, summary.Stage = domain.StatusCanceled, domain.StageCanceled
+	default:
+		summary.Status, summary.Stage = domain.StatusFailed, domain.StageFailed
+	}
+	return summary, true
+}
+
+// isTerminalStatus reports whether a job with status has finished
+func isTerminalStatus(status string) bool {
+	return status == domain.StatusCompleted || status == domain.StatusFailed || status == domain.StatusCanceled
+}
+
+// buildVariantSummaries describes a parent's variants; presign may be nil to leave out asset URLs
+func buildVariantSummaries(ctx context.Context, variants []*domain.Job, presign *presignCache, expiry time.Duration) []VariantSummary {
+	summaries := make([]VariantSummary, 0, len(variants))
+	for _, variant := range variants {
+		summary := VariantSummary{
+			JobID:           variant.JobID,
+			VariantIndex:    variant.VariantIndex,
+			Status:          variant.Status,
+			Stage:           variant.Stage.String(),
+			ProgressPercent: jobprogress.Percent(variant),
+			Title:           variant.Title,
+			ErrorMessage:    variant.ErrorMessage,
+		}
+		if presign != nil {
+			variantPresign := presign.forJob(variant)
+			if variant.ThumbnailURL != "" {
+				summary.ThumbnailURL = variantPresign.get(ctx, s3util.Key(variant.ThumbnailURL), expiry)
+			}
+			if variant.Status == domain.StatusCompleted {
+				summary.VideoURL = variantPresign.get(ctx, variant.VideoKey, expiry)
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func variantParent() *domain.Job {
+	return &domain.Job{
+		JobID:            "job-parent",
+		UserID:           "user-123",
+		Status:           domain.StatusProcessing,
+		Stage:            domain.StageScriptGenerating,
+		Prompt:           "Launch ad for a cold brew concentrate",
+		Duration:         30,
+		Model:            "veo",
+		BrandGuidelineID: "guideline-1",
+		ProductImages:    []domain.ProductImage{{Asset: "users/user-123/uploads/bottle.png", Ref: "image_1"}},
+		Variants:         3,
+		CallbackURL:      "https://example.com/hooks/omnigen",
+		CallbackSecret:   "whsec_0123456789abcdef",
+		CreatedAt:        1000,
+		UpdatedAt:        1000,
+	}
+}
+
+// storeVariantFamily saves parent and its variants, each variant given status and stage
+func storeVariantFamily(t *testing.T, repo *repository.DynamoDBRepository, parent *domain.Job, states ...[2]string) []*domain.Job {
+	variants := newVariantJobs(parent)
+	for i, variant := range variants {
+		variant.Status = states[i][0]
+		variant.Stage, _ = domain.ParseJobStage(states[i][1])
+		require.NoError(t, repo.CreateJob(context.Background(), variant))
+	}
+	require.NoError(t, repo.CreateJob(context.Background(), parent))
+	return variants
+}
+
+func TestNewVariantJobs_LinksChildrenToParent(t *testing.T) {
+	parent := variantParent()
+	variants := newVariantJobs(parent)
+
+	require.Len(t, variants, 3)
+	require.Len(t, parent.VariantJobIDs, 3)
+	require.True(t, parent.IsVariantParent())
+	for i, variant := range variants {
+		require.Equal(t, parent.VariantJobIDs[i], variant.JobID)
+		require.NotEqual(t, parent.JobID, variant.JobID)
+		require.Equal(t, parent.JobID, variant.ParentJobID)
+		require.Equal(t, i+1, variant.VariantIndex)
+		require.Equal(t, domain.StatusPending, variant.Status)
+		require.Equal(t, domain.StageAwaitingScript, variant.Stage)
+
+		// The brief, product image and brand settings are shared
+		require.Equal(t, parent.Prompt, variant.Prompt)
+		require.Equal(t, parent.ProductImages, variant.ProductImages)
+		require.Equal(t, parent.BrandGuidelineID, variant.BrandGuidelineID)
+
+		// Only the parent fans out or calls back
+		require.False(t, variant.IsVariantParent())
+		require.Zero(t, variant.Variants)
+		require.Empty(t, variant.CallbackURL)
+		require.Empty(t, variant.CallbackSecret)
+	}
+}
+
+func TestSummarizeVariants(t *testing.T) {
+	job := func(status string, stage domain.JobStage) *domain.Job {
+		return &domain.Job{Status: status, Stage: stage}
+	}
+	// Progress comes from what a variant stored, not its stage
+	scripted := job(domain.StatusProcessing, domain.StageScriptComplete)
+	scripted.Scenes = make([]domain.Scene, 2)
+	composing := job(domain.StatusProcessing, domain.StageComposing)
+	composing.Scenes, composing.ScenesCompleted, composing.AudioURL = make([]domain.Scene, 2), 2, "s3://bucket/audio.mp3"
+
+	tests := []struct {
+		name     string
+		variants []*domain.Job
+		ok       bool
+		want     variantSummary
+	}{
+		{
+			name:     "waiting for scripts",
+			variants: []*domain.Job{job(domain.StatusPending, domain.StageAwaitingScript), job(domain.StatusProcessing, domain.StageScriptComplete)},
+			ok:       false,
+		},
+		{
+			name:     "running",
+			variants: []*domain.Job{composing, job(domain.StatusQueued, domain.StageQueued)},
+			ok:       true,
+			want:     variantSummary{Status: domain.StatusProcessing, Stage: domain.StageVariantsGenerating, Progress: 42},
+		},
+		{
+			name:     "partly finished",
+			variants: []*domain.Job{job(domain.StatusCompleted, domain.StageComplete), job(domain.StatusFailed, domain.StageFailed), scripted},
+			ok:       true,
+			want:     variantSummary{Status: domain.StatusProcessing, Stage: domain.VariantsFinished(2, 3), Progress: 68},
+		},
+		{
+			name:     "all completed",
+			variants: []*domain.Job{job(domain.StatusCompleted, domain.StageComplete), job(domain.StatusCompleted, domain.StageComplete)},
+			ok:       true,
+			want:     variantSummary{Status: domain.StatusCompleted, Stage: domain.StageComplete, Progress: 100},
+		},
+		{
+			name:     "one completed",
+			variants: []*domain.Job{job(domain.StatusFailed, domain.StageFailed), job(domain.StatusCompleted, domain.StageComplete), job(domain.StatusCanceled, domain.StageCanceled)},
+			ok:       true,
+			want:     variantSummary{Status: domain.StatusCompleted, Stage: domain.StageComplete, Progress: 100},
+		},
+		{
+			name:     "all canceled",
+			variants: []*domain.Job{job(domain.StatusCanceled, domain.StageCanceled), job(domain.StatusCanceled, domain.StageCanceled)},
+			ok:       true,
+			want:     variantSummary{Status: domain.StatusCanceled, Stage: domain.StageCanceled, Progress: 100},
+		},
+		{
+			name:     "none completed",
+			variants: []*domain.Job{job(domain.StatusFailed, domain.StageFailed), job(domain.StatusCanceled, domain.StageCanceled)},
+			ok:       true,
+			want:     variantSummary{Status: domain.StatusFailed, Stage: domain.StageFailed, Progress: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := summarizeVariants(tt.variants)
+			require.Equal(t, tt.ok, ok)
+			if tt.ok {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRefreshVariantParent_StoresAggregatedStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	h := &GenerateHandler{jobRepo: repo, logger: zap.NewNop()}
+
+	parent := variantParent()
+	parent.Stage = domain.StageVariantsGenerating
+	variants := storeVariantFamily(t, repo, parent,
+		[2]string{domain.StatusCompleted, "complete"},
+		[2]string{domain.StatusProcessing, "composing"},
+		[2]string{domain.StatusProcessing, "scene_1_generating"},
+	)
+
+	h.refreshVariantParent(parent.JobID)
+	stored, err := repo.GetJob(ctx, parent.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusProcessing, stored.Status)
+	require.Equal(t, domain.VariantsFinished(1, 3), stored.Stage)
+
+	for _, variant := range variants[1:] {
+		require.NoError(t, repo.MarkJobFailed(ctx, variant.JobID, "Scene generation failed", "scene_1_generating", "timeout"))
+	}
+	h.refreshVariantParent(parent.JobID)
+	stored, err = repo.GetJob(ctx, parent.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusCompleted, stored.Status)
+	require.Equal(t, domain.StageComplete, stored.Stage)
+	require.NotNil(t, stored.CompletedAt)
+
+	// A finished parent isn't rewritten by a late refresh
+	first := stored.Version
+	h.refreshVariantParent(parent.JobID)
+	stored, err = repo.GetJob(ctx, parent.JobID)
+	require.NoError(t, err)
+	require.Equal(t, first, stored.Version)
+}
+
+func TestSettleVariantFamily_FailedParentFailsWaitingVariants(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	h := &GenerateHandler{jobRepo: repo, logger: zap.NewNop()}
+
+	parent := variantParent()
+	variants := storeVariantFamily(t, repo, parent,
+		[2]string{domain.StatusPending, "awaiting_script"},
+		[2]string{domain.StatusCanceled, "canceled"},
+		[2]string{domain.StatusPending, "awaiting_script"},
+	)
+	require.NoError(t, repo.MarkJobFailed(ctx, parent.JobID, scriptFailureMessage, "script_generating", "openai: 500"))
+
+	h.settleVariantFamily(parent)
+
+	for i, variant := range variants {
+		stored, err := repo.GetJob(ctx, variant.JobID)
+		require.NoError(t, err)
+		if i == 1 {
+			require.Equal(t, domain.StatusCanceled, stored.Status, "a variant canceled by the user stays canceled")
+			continue
+		}
+		require.Equal(t, domain.StatusFailed, stored.Status)
+		require.NotNil(t, stored.ErrorMessage)
+		require.Equal(t, scriptFailureMessage, *stored.ErrorMessage)
+	}
+
+	stored, err := repo.GetJob(ctx, parent.JobID)
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusFailed, stored.Status)
+}
+
+func TestGetJob_ListsVariantsOfParent(t *testing.T) {
+	repo := repository.NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+
+	parent := variantParent()
+	parent.Stage = domain.StageVariantsGenerating
+	variants := storeVariantFamily(t, repo, parent,
+		[2]string{domain.StatusCompleted, "complete"},
+		[2]string{domain.StatusProcessing, "composing"},
+		[2]string{domain.StatusFailed, "failed"},
+	)
+	// The composing variant has its clips
+	variants[1].Scenes = make([]domain.Scene, 2)
+	variants[1].ScenesCompleted = 2
+	require.NoError(t, repo.UpdateJob(context.Background(), variants[1]))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewJobsHandler(repo, nil, nil, nil, "", zap.NewNop())
+	router.GET("/api/v1/jobs/:id", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	}, h.GetJob)
+
+	get := func(jobID string) JobResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+jobID, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response JobResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	response := get(parent.JobID)
+	require.Equal(t, domain.StatusProcessing, response.Status)
+	require.Equal(t, "variants_2_of_3_finished", response.Stage)
+	require.Equal(t, 92, response.ProgressPercent, "(100 + 78 + 100) / 3")
+	require.Len(t, response.Variants, 3)
+	for i, summary := range response.Variants {
+		require.Equal(t, variants[i].JobID, summary.JobID)
+		require.Equal(t, i+1, summary.VariantIndex)
+		require.Equal(t, variants[i].Status, summary.Status)
+	}
+	require.Equal(t, 100, response.Variants[0].ProgressPercent)
+
+	child := get(variants[1].JobID)
+	require.Equal(t, parent.JobID, child.ParentJobID)
+	require.Equal(t, 2, child.VariantIndex)
+	require.Empty(t, child.Variants)
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// MaxVoicePreviewChars is the longest text a voice preview reads
+const MaxVoicePreviewChars = 200
+
+// VoiceSampleText is read by a preview that doesn't bring its own text
+const VoiceSampleText = "Hi, I'll be narrating your video. This is how your script will sound in my voice."
+
+// voicePreviewPrefix holds cached previews. They're keyed by content, not by user, so the same
+// voice reading the same text is synthesized once for everyone.
+const voicePreviewPrefix = "voice-previews/"
+
+// VoiceResponse is a narrator voice a job can use
+type VoiceResponse struct {
+	ID         string `json:"id"` // Passed as voice to POST /generate
+	Label      string `json:"label"`
+	Provider   string `json:"provider"` // Passed as tts_provider to POST /generate
+	SampleText string `json:"sample_text"`
+}
+
+// ListVoicesResponse lists the configured providers' voices, the default provider's first
+type ListVoicesResponse struct {
+	Voices []VoiceResponse `json:"voices"`
+	Count  int             `json:"count"`
+}
+
+// VoicePreviewRequest asks for a voice to read a short text
+type VoicePreviewRequest struct {
+	Voice    string `json:"voice" binding:"required"`
+	Provider string `json:"provider,omitempty"` // Defaults to the server's TTS provider
+	Text     string `json:"text,omitempty"`     // Up to 200 characters; defaults to the sample text
+}
+
+// VoicePreviewResponse links to the preview audio
+type VoicePreviewResponse struct {
+	Voice        string `json:"voice"`
+	Provider     string `json:"provider"` // The provider that spoke, after any fallback
+	Text         string `json:"text"`
+	AudioURL     string `json:"audio_url"`      // MP3
+	URLExpiresAt int64  `json:"url_expires_at"` // When audio_url stops working
+	Cached       bool   `json:"cached"`         // The preview was already synthesized
+}
+
+// voicePreviewStore is the subset of the S3 repository needed to cache voice previews
+type voicePreviewStore interface {
+	ObjectSize(ctx context.Context, bucket, key string) (int64, error)
+	UploadStream(ctx context.Context, bucket, key string, body io.Reader, contentType, tagging string) error
+	GetPresignedURL(ctx context.Context, key string, duration time.Duration) (string, error)
+}
+
+// voicePreviewUsage records the characters previews synthesize
+type voicePreviewUsage interface {
+	AddTTSCharacters(ctx context.Context, userID string, chars int) error
+}
+
+// VoicesHandler lists narrator voices and previews them
+type VoicesHandler struct {
+	tts          *adapters.TTSRouter
+	store        voicePreviewStore
+	usage        voicePreviewUsage // nil skips usage tracking
+	assetsBucket string
+	logger       *zap.Logger
+}
+
+// NewVoicesHandler creates a new voices handler. usageRepo may be nil.
+func NewVoicesHandler(
+	tts *adapters.TTSRouter,
+	store voicePreviewStore,
+	usageRepo *repository.DynamoDBUsageRepository,
+	assetsBucket string,
+	logger *zap.Logger,
+) *VoicesHandler {
+	h := &VoicesHandler{
+		tts:          tts,
+		store:        store,
+		assetsBucket: assetsBucket,
+		logger:       logger,
+	}
+	if usageRepo != nil {
+		h.usage = usageRepo
+	}
+	return h
+}
+
+// ListVoices handles GET /api/v1/voices
+// @Summary List narrator voices
+// @Description Lists the voices of each configured TTS provider, the default provider's first, with the text a preview reads by default
+// @Tags voices
+// @Produce json
+// @Success 200 {object} ListVoicesResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /api/v1/voices [get]
+// @Security BearerAuth
+func (h *VoicesHandler) ListVoices(c *gin.Context) {
+	voices := h.tts.Voices(c.Request.Context())
+	response := ListVoicesResponse{Voices: make([]VoiceResponse, 0, len(voices))}
+	for _, voice := range voices {
+		response.Voices = append(response.Voices, VoiceResponse{
+			ID:         voice.ID,
+			Label:      voice.Label,
+			Provider:   string(voice.Provider),
+			SampleText: VoiceSampleText,
+		})
+	}
+	response.Count = len(response.Voices)
+	c.JSON(http.StatusOK, response)
+}
+
+// PreviewVoice handles POST /api/v1/voices/preview
+// @Summary Preview a narrator voice
+// @Description Reads up to 200 characters in a voice and returns a link to the MP3. Previews are cached by voice and text, so repeating one is free; newly synthesized characters count towards usage.
+// @Tags voices
+// @Accept json
+// @Produce json
+// @Param request body VoicePreviewRequest true "Voice and text to preview"
+// @Success 200 {object} VoicePreviewResponse
+// @Failure 400 {object} errors.ErrorResponse "Malformed JSON body"
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse "Field validation errors"
+// @Failure 429 {object} errors.ErrorResponse
+// @Failure 503 {object} errors.ErrorResponse "No TTS provider is configured, or it failed"
+// @Router /api/v1/voices/preview [post]
+// @Security BearerAuth
+func (h *VoicesHandler) PreviewVoice(c *gin.Context) {
+	var req VoicePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+				"validation_error": err.Error(),
+			}),
+		})
+		return
+	}
+
+	userID := auth.MustGetUserID(c)
+	ctx := c.Request.Context()
+	voice := strings.TrimSpace(req.Voice)
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		text = VoiceSampleText
+	}
+
+	var errs validation.Errors
+	if req.Provider != "" && !slices.Contains(validation.TTSProviders, req.Provider) {
+		errs.Add("provider", "Unknown TTS provider", validation.TTSProviders...)
+	}
+	if utf8.RuneCountInString(text) > MaxVoicePreviewChars {
+		errs.Add("text", "Preview text can be at most 200 characters")
+	}
+	if len(errs) > 0 {
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	adapter, provider := h.tts.Resolve(req.Provider)
+	if adapter == nil {
+		c.JSON(http.StatusServiceUnavailable, errors.ErrorResponse{
+			Error: errors.NewAPIError(errors.ErrServiceUnavailable, "Narrator voices are not configured", nil),
+		})
+		return
+	}
+	if provider != adapters.TTSProviderElevenLabs && !slices.Contains(validation.Voices, voice) {
+		errs.Add("voice", "ElevenLabs voices are not available. Choose 'male' or 'female'", validation.Voices...)
+		respondValidationErrors(c, errs)
+		return
+	}
+
+	key := voicePreviewKey(provider, voice, text)
+	cached := true
+	if _, err := h.store.ObjectSize(ctx, h.assetsBucket, key); err != nil {
+		if !stderrors.Is(err, repository.ErrObjectNotFound) {
+			h.logger.Error("Failed to look up voice preview", zap.String("key", key), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{Error: errors.ErrStorageError})
+			return
+		}
+		cached = false
+
+		audio, err := adapter.GenerateVoiceover(ctx, text, voice)
+		if err != nil {
+			h.logger.Warn("Voice preview synthesis failed",
+				zap.String("provider", string(provider)),
+				zap.String("voice", voice),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusServiceUnavailable, errors.ErrorResponse{
+				Error: errors.NewAPIError(errors.ErrServiceUnavailable, "The voice preview could not be generated; try again shortly", nil),
+			})
+			return
+		}
+		if err := h.store.UploadStream(ctx, h.assetsBucket, key, bytes.NewReader(audio), "audio/mpeg", ""); err != nil {
+			h.logger.Error("Failed to store voice preview", zap.String("key", key), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{Error: errors.ErrStorageError})
+			return
+		}
+
+		// Only synthesized characters count; a failure here shouldn't cost the user their preview
+		if h.usage != nil {
+			if err := h.usage.AddTTSCharacters(ctx, userID, utf8.RuneCountInString(text)); err != nil {
+				h.logger.Warn("Failed to record voice preview characters", zap.String("user_id", userID), zap.Error(err))
+			}
+		}
+	}
+
+	url, err := h.store.GetPresignedURL(ctx, key, AssetURLExpiry)
+	if err != nil {
+		h.logger.Error("Failed to sign voice preview URL", zap.String("key", key), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{Error: errors.ErrStorageError})
+		return
+	}
+
+	h.logger.Debug("Voice preview served",
+		zap.String("user_id", userID),
+		zap.String("provider", string(provider)),
+		zap.String("voice", voice),
+		zap.Bool("cached", cached),
+	)
+	c.JSON(http.StatusOK, VoicePreviewResponse{
+		Voice:        voice,
+		Provider:     string(provider),
+		Text:         text,
+		AudioURL:     url,
+		URLExpiresAt: time.Now().Add(AssetURLExpiry).Unix(),
+		Cached:       cached,
+	})
+}
+
+// voicePreviewKey names the cached preview of voice reading text
+func voicePreviewKey(provider adapters.TTSProvider, voice, text string) string {
+	sum := sha256.Sum256([]byte(string(provider) + "\x00" + voice + "\x00" + text))
+	return voicePreviewPrefix + string(provider) + "/" + hex.EncodeToString(sum[:]) + ".mp3"
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakePreviewStore keeps voice previews in memory
+type fakePreviewStore struct {
+	objects map[string][]byte
+	uploads int
+}
+
+func (s *fakePreviewStore) ObjectSize(_ context.Context, _, key string) (int64, error) {
+	body, ok := s.objects[key]
+	if !ok {
+		return 0, fmt.Errorf("failed to head object %s: %w", key, repository.ErrObjectNotFound)
+	}
+	return int64(len(body)), nil
+}
+
+func (s *fakePreviewStore) UploadStream(_ context.Context, _, key string, body io.Reader, _, _ string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	s.uploads++
+	return nil
+}
+
+func (s *fakePreviewStore) GetPresignedURL(_ context.Context, key string, _ time.Duration) (string, error) {
+	return "https://signed.example.com/" + key, nil
+}
+
+// fakePreviewTTS counts the text it is asked to speak
+type fakePreviewTTS struct {
+	voices []adapters.Voice
+	calls  int
+	err    error
+}
+
+func (f *fakePreviewTTS) GenerateVoiceover(_ context.Context, text, voice string) ([]byte, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte("mp3:" + voice + ":" + text), nil
+}
+
+func (f *fakePreviewTTS) GenerateVoiceoverWithDuration(ctx context.Context, text, voice string, _ float64) ([]byte, float64, error) {
+	audio, err := f.GenerateVoiceover(ctx, text, voice)
+	return audio, 1, err
+}
+
+func (f *fakePreviewTTS) Voices(context.Context) ([]adapters.Voice, error) {
+	return f.voices, nil
+}
+
+// fakePreviewUsage totals the characters recorded per user
+type fakePreviewUsage map[string]int
+
+func (u fakePreviewUsage) AddTTSCharacters(_ context.Context, userID string, chars int) error {
+	u[userID] += chars
+	return nil
+}
+
+func voicesTestRouter(tts *fakePreviewTTS, store *fakePreviewStore, usage fakePreviewUsage) *gin.Engine {
+	ttsRouter := adapters.NewTTSRouter(adapters.TTSProviderOpenAI,
+		map[adapters.TTSProvider]adapters.TTSAdapter{adapters.TTSProviderOpenAI: tts}, zap.NewNop())
+	h := NewVoicesHandler(ttsRouter, store, nil, "assets", zap.NewNop())
+	h.usage = usage
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", func(c *gin.Context) {
+		c.Set(auth.UserIDKey, "user-123")
+	})
+	v1.GET("/voices", h.ListVoices)
+	v1.POST("/voices/preview", h.PreviewVoice)
+	return router
+}
+
+func previewVoice(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/voices/preview", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestListVoices(t *testing.T) {
+	tts := &fakePreviewTTS{voices: []adapters.Voice{
+		{ID: "male", Label: "Male (Onyx)", Provider: adapters.TTSProviderOpenAI},
+		{ID: "female", Label: "Female (Nova)", Provider: adapters.TTSProviderOpenAI},
+	}}
+	router := voicesTestRouter(tts, &fakePreviewStore{objects: map[string][]byte{}}, fakePreviewUsage{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/voices", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp ListVoicesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 2, resp.Count)
+	require.Equal(t, VoiceResponse{ID: "male", Label: "Male (Onyx)", Provider: "openai", SampleText: VoiceSampleText}, resp.Voices[0])
+	require.Equal(t, "female", resp.Voices[1].ID)
+}
+
+func TestPreviewVoice_MissSynthesizesThenHitIsCached(t *testing.T) {
+	tts := &fakePreviewTTS{}
+	store := &fakePreviewStore{objects: map[string][]byte{}}
+	usage := fakePreviewUsage{}
+	router := voicesTestRouter(tts, store, usage)
+
+	w := previewVoice(router, `{"voice": "female", "text": "Try our new trail shoe"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var miss VoicePreviewResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &miss))
+	require.False(t, miss.Cached)
+	require.Equal(t, "openai", miss.Provider)
+	key := voicePreviewKey(adapters.TTSProviderOpenAI, "female", "Try our new trail shoe")
+	require.Equal(t, "https://signed.example.com/"+key, miss.AudioURL)
+	require.Equal(t, []byte("mp3:female:Try our new trail shoe"), store.objects[key])
+	require.Equal(t, len("Try our new trail shoe"), usage["user-123"])
+
+	w = previewVoice(router, `{"voice": "female", "text": "Try our new trail shoe"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var hit VoicePreviewResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hit))
+	require.True(t, hit.Cached)
+	require.Equal(t, miss.AudioURL, hit.AudioURL)
+	require.Equal(t, 1, tts.calls, "a cached preview isn't synthesized again")
+	require.Equal(t, 1, store.uploads)
+	require.Equal(t, len("Try our new trail shoe"), usage["user-123"], "cache hits aren't counted")
+
+	// Another voice reading the same text is a different preview
+	w = previewVoice(router, `{"voice": "male", "text": "Try our new trail shoe"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, 2, tts.calls)
+}
+
+func TestPreviewVoice_DefaultsToSampleText(t *testing.T) {
+	tts := &fakePreviewTTS{}
+	store := &fakePreviewStore{objects: map[string][]byte{}}
+	router := voicesTestRouter(tts, store, fakePreviewUsage{})
+
+	w := previewVoice(router, `{"voice": "male"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp VoicePreviewResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, VoiceSampleText, resp.Text)
+	require.Contains(t, store.objects, voicePreviewKey(adapters.TTSProviderOpenAI, "male", VoiceSampleText))
+}
+
+func TestPreviewVoice_Validation(t *testing.T) {
+	tts := &fakePreviewTTS{}
+	router := voicesTestRouter(tts, &fakePreviewStore{objects: map[string][]byte{}}, fakePreviewUsage{})
+
+	for name, body := range map[string]string{
+		"text too long":       `{"voice": "male", "text": "` + strings.Repeat("a", MaxVoicePreviewChars+1) + `"}`,
+		"unknown provider":    `{"voice": "male", "provider": "acme"}`,
+		"elevenlabs voice id": `{"voice": "pNInz6obpgDQGcFmaJgB"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := previewVoice(router, body)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		})
+	}
+	require.Equal(t, http.StatusBadRequest, previewVoice(router, `{}`).Code)
+	require.Zero(t, tts.calls)
+}
+
+func TestPreviewVoice_SynthesisFailureIsNotCached(t *testing.T) {
+	tts := &fakePreviewTTS{err: fmt.Errorf("tts unavailable")}
+	store := &fakePreviewStore{objects: map[string][]byte{}}
+	usage := fakePreviewUsage{}
+	router := voicesTestRouter(tts, store, usage)
+
+	w := previewVoice(router, `{"voice": "male"}`)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	require.Empty(t, store.objects)
+	require.Zero(t, usage["user-123"])
+}
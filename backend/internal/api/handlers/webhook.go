@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/s3util"
+	"github.com/omnigen/backend/internal/service"
+	"go.uber.org/zap"
+)
+
+// webhookLookupTimeout bounds reading the finished job and presigning its URLs
+const webhookLookupTimeout = 10 * time.Second
+
+// notifyJobFinished queues the job's webhook after it was marked completed or failed.
+// The job is re-read so the payload carries the stored status, video key and error.
+func (h *GenerateHandler) notifyJobFinished(jobID string) {
+	if h.webhooks == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookLookupTimeout)
+	defer cancel()
+
+	job, err := h.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		h.logger.Warn("Failed to load job for webhook", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	if job.CallbackURL == "" {
+		return
+	}
+
+	h.webhooks.Enqueue(job.CallbackURL, job.CallbackSecret, h.webhookPayload(ctx, job))
+}
+
+// webhookPayload builds the notification for a finished job, with presigned asset URLs
+func (h *GenerateHandler) webhookPayload(ctx context.Context, job *domain.Job) service.WebhookPayload {
+	payload := service.WebhookPayload{
+		Event:        service.WebhookEventJobFailed,
+		JobID:        job.JobID,
+		Status:       job.Status,
+		ErrorMessage: job.ErrorMessage,
+	}
+	if job.Status == domain.StatusCanceled {
+		payload.Event = service.WebhookEventJobCanceled
+	}
+	if job.Status != domain.StatusCompleted {
+		return payload
+	}
+
+	payload.Event = service.WebhookEventJobCompleted
+	presign := newPresignCache(h.s3Service, h.logger).forJob(job)
+	if job.VideoKey != "" {
+		payload.VideoURL = presign.get(ctx, job.VideoKey, AssetURLExpiry)
+	}
+	if job.ThumbnailURL != "" {
+		payload.ThumbnailURL = presign.get(ctx, s3util.Key(job.ThumbnailURL), AssetURLExpiry)
+	}
+	return payload
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/trace"
 	"go.uber.org/zap"
 )
 
@@ -15,16 +16,17 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 		method := c.Request.Method
 
 		// Skip health checks and GET job status polling
-		if path == "/health" || (method == "GET" && len(path) > 14 && path[:14] == "/api/v1/jobs/") {
+		if path == "/health" || path == "/healthz" || path == "/readyz" || (method == "GET" && len(path) > 14 && path[:14] == "/api/v1/jobs/") {
 			c.Next()
 			return
 		}
 
 		// Start timer
 		start := time.Now()
+		log := trace.Logger(c.Request.Context(), logger) // Carries the request_id set by RequestID
 
 		// Log request start
-		logger.Info("Request started",
+		log.Info("Request started",
 			zap.String("method", method),
 			zap.String("path", path),
 			zap.String("client_ip", c.ClientIP()),
@@ -37,7 +39,7 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 		latency := time.Since(start)
 
 		// Log request completion
-		logger.Info("Request completed",
+		log.Info("Request completed",
 			zap.String("method", method),
 			zap.String("path", path),
 			zap.Int("status", c.Writer.Status()),
@@ -48,7 +50,7 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 		// Log errors if any
 		if len(c.Errors) > 0 {
 			for _, e := range c.Errors {
-				logger.Error("Request error",
+				log.Error("Request error",
 					zap.String("method", method),
 					zap.String("path", path),
 					zap.Error(e.Err),
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// RateLimitBudget is a token bucket: Requests may be made at once, and the bucket refills
+// completely over Per. A budget with Requests <= 0 doesn't limit anything.
+type RateLimitBudget struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled reports whether the budget limits requests
+func (b RateLimitBudget) Enabled() bool {
+	return b.Requests > 0 && b.Per > 0
+}
+
+// tierRateLimitMultipliers scales budgets by subscription tier; configured budgets are the free
+// tier's, and unknown tiers get free budgets
+var tierRateLimitMultipliers = map[string]int{
+	"free":       1,
+	"pro":        6,
+	"enterprise": 30,
+}
+
+// ForTier returns the budget for a user on the given subscription tier
+func (b RateLimitBudget) ForTier(tier string) RateLimitBudget {
+	if multiplier, ok := tierRateLimitMultipliers[tier]; ok {
+		b.Requests *= multiplier
+	}
+	return b
+}
+
+// RateLimitResult is a limiter's decision about one request
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // until the next request is allowed; zero when Allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+// RateLimiter takes one request from key's bucket. MemoryRateLimiter keeps buckets per
+// process; deployments with several instances need an implementation backed by a shared
+// store (Redis, DynamoDB) so a user's budget isn't multiplied by the instance count.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, budget RateLimitBudget) (RateLimitResult, error)
+}
+
+// rateLimitSweepInterval is how often MemoryRateLimiter drops buckets that have refilled
+const rateLimitSweepInterval = time.Minute
+
+// bucket is one key's token bucket; tokens is its level at updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	budget  RateLimitBudget
+}
+
+// MemoryRateLimiter is a RateLimiter holding its buckets in memory
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimiter creates an in-memory rate limiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket if it has one
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, budget RateLimitBudget) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || b.budget != budget {
+		b = &bucket{tokens: float64(budget.Requests), updated: now, budget: budget}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	result := RateLimitResult{Limit: budget.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = b.timeToReach(1)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = b.timeToReach(float64(budget.Requests))
+	return result, nil
+}
+
+// sweep drops buckets that have refilled, which behave the same as missing ones
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.budget.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// refill adds the tokens earned since the bucket was last updated
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(b.budget.Requests), b.tokens+elapsed.Seconds()*b.rate())
+	b.updated = now
+}
+
+// rate is the refill rate in tokens per second
+func (b *bucket) rate() float64 {
+	return float64(b.budget.Requests) / b.budget.Per.Seconds()
+}
+
+// timeToReach is how long until the bucket holds tokens
+func (b *bucket) timeToReach(tokens float64) time.Duration {
+	if b.tokens >= tokens {
+		return 0
+	}
+	return time.Duration((tokens - b.tokens) / b.rate() * float64(time.Second))
+}
+
+// RateLimit returns a middleware spending budget for each request, keyed by name and the
+// authenticated user ID, or the client IP on routes without auth. Authenticated users get the
+// budget of their subscription tier, so place it after the auth middleware. Requests over
+// budget get 429 with Retry-After; every response carries the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (seconds) headers. If the
+// limiter fails, requests are let through.
+func RateLimit(limiter RateLimiter, name string, budget RateLimitBudget, logger *zap.Logger) gin.HandlerFunc {
+	if !budget.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		key := name + ":ip:" + c.ClientIP()
+		tier := "free"
+		if userID, ok := auth.GetUserID(c); ok && userID != "" {
+			key = name + ":user:" + userID
+			if claimed, ok := auth.GetSubscriptionTier(c); ok && claimed != "" {
+				tier = claimed
+			}
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), key, budget.ForTier(tier))
+		if err != nil {
+			logger.Warn("Rate limiter failed, allowing request",
+				zap.String("budget", name),
+				zap.Error(err),
+			)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if result.Allowed {
+			c.Next()
+			return
+		}
+
+		retryAfter := max(ceilSeconds(result.RetryAfter), 1)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, errors.ErrorResponse{
+			Error: errors.ErrRateLimited.WithDetails(map[string]interface{}{
+				"budget":              name,
+				"tier":                tier,
+				"limit":               result.Limit,
+				"retry_after_seconds": retryAfter,
+			}),
+		})
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeClock is a settable time source for MemoryRateLimiter
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter() (*MemoryRateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := NewMemoryRateLimiter()
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+// limitedRouter serves GET /ping under budget, as userID when it isn't empty and on the
+// X-Test-Tier subscription tier when one is given
+func limitedRouter(limiter RateLimiter, budget RateLimitBudget) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ping", func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			auth.SetUserClaims(c, &domain.UserClaims{Sub: userID, SubscriptionTier: c.GetHeader("X-Test-Tier")})
+		}
+	}, RateLimit(limiter, "ping", budget, zap.NewNop()), func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return router
+}
+
+func get(router *gin.Engine, userID, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = remoteAddr
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_Headers(t *testing.T) {
+	limiter, clock := newTestLimiter()
+	router := limitedRouter(limiter, RateLimitBudget{Requests: 5, Per: time.Minute})
+
+	for i := 4; i >= 0; i-- {
+		w := get(router, "user-1", "10.0.0.1:1234")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+		require.Equal(t, strconv.Itoa(i), w.Header().Get("X-RateLimit-Remaining"))
+		require.Empty(t, w.Header().Get("Retry-After"))
+	}
+
+	// One request refills every 12 seconds
+	clock.Advance(3 * time.Second)
+	w := get(router, "user-1", "10.0.0.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "9", w.Header().Get("Retry-After"))
+	require.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	require.Equal(t, "57", w.Header().Get("X-RateLimit-Reset"))
+
+	var body apierrors.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "RATE_LIMITED", body.Error.Code)
+	require.Equal(t, "ping", body.Error.Details["budget"])
+	require.EqualValues(t, 9, body.Error.Details["retry_after_seconds"])
+
+	clock.Advance(9 * time.Second)
+	require.Equal(t, http.StatusOK, get(router, "user-1", "10.0.0.1:1234").Code)
+}
+
+func TestRateLimit_KeysByUserThenIP(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	router := limitedRouter(limiter, RateLimitBudget{Requests: 1, Per: time.Second})
+
+	require.Equal(t, http.StatusOK, get(router, "user-1", "10.0.0.1:1234").Code)
+	require.Equal(t, http.StatusTooManyRequests, get(router, "user-1", "10.0.0.2:1234").Code)
+
+	// Another user behind the same IP has their own budget
+	require.Equal(t, http.StatusOK, get(router, "user-2", "10.0.0.1:1234").Code)
+
+	// Without a user, requests are keyed by client IP
+	require.Equal(t, http.StatusOK, get(router, "", "10.0.0.1:1234").Code)
+	require.Equal(t, http.StatusTooManyRequests, get(router, "", "10.0.0.1:5678").Code)
+	require.Equal(t, http.StatusOK, get(router, "", "10.0.0.2:1234").Code)
+}
+
+func TestRateLimit_ScalesBudgetByTier(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	router := limitedRouter(limiter, RateLimitBudget{Requests: 2, Per: time.Minute})
+
+	spend := func(userID, tier string) (allowed int, last *httptest.ResponseRecorder) {
+		for {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			req.Header.Set("X-Test-User", userID)
+			req.Header.Set("X-Test-Tier", tier)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				return allowed, w
+			}
+			allowed++
+		}
+	}
+
+	for tier, want := range map[string]int{"": 2, "free": 2, "pro": 12, "enterprise": 60, "unknown": 2} {
+		allowed, w := spend("user-"+tier, tier)
+		require.Equal(t, want, allowed, "tier %q", tier)
+		require.Equal(t, strconv.Itoa(want), w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	_, w := spend("user-pro-2", "pro")
+	var body apierrors.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "pro", body.Error.Details["tier"])
+}
+
+func TestRateLimit_DisabledBudget(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	router := limitedRouter(limiter, RateLimitBudget{})
+	for i := 0; i < 20; i++ {
+		w := get(router, "user-1", "10.0.0.1:1234")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+// failingLimiter stands in for a shared store that is down
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, budget RateLimitBudget) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestRateLimit_LimiterErrorAllowsRequest(t *testing.T) {
+	router := limitedRouter(failingLimiter{}, RateLimitBudget{Requests: 1, Per: time.Second})
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, get(router, "user-1", "10.0.0.1:1234").Code)
+	}
+}
+
+func TestMemoryRateLimiter_Concurrent(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	budget := RateLimitBudget{Requests: 10, Per: time.Second}
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "user-a"
+			if i%2 == 1 {
+				key = "user-b"
+			}
+			result, err := limiter.Allow(context.Background(), key, budget)
+			if err != nil {
+				t.Error(err)
+			}
+			if result.Allowed {
+				allowed.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.EqualValues(t, 20, allowed.Load(), "each user's burst is spent exactly once")
+}
+
+func TestMemoryRateLimiter_SweepsRefilledBuckets(t *testing.T) {
+	limiter, clock := newTestLimiter()
+	budget := RateLimitBudget{Requests: 2, Per: time.Second}
+	ctx := context.Background()
+
+	_, err := limiter.Allow(ctx, "user-a", budget)
+	require.NoError(t, err)
+	clock.Advance(rateLimitSweepInterval)
+	_, err = limiter.Allow(ctx, "user-b", budget)
+	require.NoError(t, err)
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	require.Len(t, limiter.buckets, 1)
+	require.Contains(t, limiter.buckets, "user-b")
+}
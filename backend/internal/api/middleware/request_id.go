@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/omnigen/backend/internal/trace"
+)
+
+// RequestIDKey is the gin context key holding the request's correlation ID
+const RequestIDKey = "request_id"
+
+// RequestID accepts the caller's X-Request-ID, or generates one, and stores it in the request
+// context so handlers, pipelines and adapters log and forward it. The ID is echoed in the
+// response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(trace.Header)
+		if !trace.ValidRequestID(id) {
+			id = trace.NewRequestID()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(trace.WithRequestID(c.Request.Context(), id))
+		c.Header(trace.Header, id)
+		c.Next()
+	}
+}
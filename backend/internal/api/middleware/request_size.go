@@ -1,32 +1,71 @@
 package middleware
 
 import (
+	"bytes"
+	stderrors "errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/omnigen/backend/pkg/errors"
 )
 
-// MaxRequestBodySize returns a middleware that limits the request body size
+// Request body limits used when none is configured
+const (
+	DefaultMaxBodyBytes     = 10 << 20  // Any route, including webhooks and future direct uploads
+	DefaultMaxJSONBodyBytes = 128 << 10 // JSON API routes such as /generate and /brand-guidelines
+)
+
+// MaxRequestBodySize returns a middleware that rejects request bodies larger than maxSize bytes
+// with 413. A body that declares its length is rejected before it is read; one sent without a
+// length is read up to the limit first, so handlers never see a truncated body. maxSize <= 0
+// disables the limit.
 func MaxRequestBodySize(maxSize int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+		if maxSize <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
 
-		// Try to parse the body
-		if err := c.Request.ParseForm(); err != nil {
-			if err.Error() == "http: request body too large" {
-				c.JSON(http.StatusRequestEntityTooLarge, errors.ErrorResponse{
-					Error: &errors.APIError{
-						Code:    "REQUEST_TOO_LARGE",
-						Message: "Request body size exceeds maximum allowed limit",
-						Status:  http.StatusRequestEntityTooLarge,
-					},
+		if c.Request.ContentLength > maxSize {
+			abortTooLarge(c, maxSize)
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSize+1))
+			c.Request.Body.Close()
+			if int64(len(body)) > maxSize || isTooLarge(err) {
+				abortTooLarge(c, maxSize)
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, errors.ErrorResponse{
+					Error: errors.ErrInvalidRequest.WithDetails(map[string]interface{}{
+						"validation_error": err.Error(),
+					}),
 				})
-				c.Abort()
 				return
 			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
 		}
 
+		// A body longer than it declared still stops at the limit
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
 		c.Next()
 	}
 }
+
+// isTooLarge reports whether err is an outer MaxRequestBodySize's reader cutting the body off
+func isTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return stderrors.As(err, &tooLarge)
+}
+
+func abortTooLarge(c *gin.Context, maxSize int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errors.ErrorResponse{
+		Error: errors.ErrRequestTooLarge.WithDetails(map[string]interface{}{
+			"max_bytes": maxSize,
+		}),
+	})
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	apierrors "github.com/omnigen/backend/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// sizedRouter echoes the length of POST bodies: up to 1KB on any route, and up to 64 bytes
+// under /api
+func sizedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MaxRequestBodySize(1024))
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "%d", len(body))
+	}
+	router.POST("/upload", echo)
+	router.Group("/api", MaxRequestBodySize(64)).POST("/generate", echo)
+	return router
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	router := sizedRouter()
+	tests := []struct {
+		name      string
+		path      string
+		size      int
+		chunked   bool
+		wantCode  int
+		wantLimit int64
+	}{
+		{name: "small body", path: "/api/generate", size: 64, wantCode: http.StatusOK},
+		{name: "declared over the group limit", path: "/api/generate", size: 65, wantCode: http.StatusRequestEntityTooLarge, wantLimit: 64},
+		{name: "chunked over the group limit", path: "/api/generate", size: 200, chunked: true, wantCode: http.StatusRequestEntityTooLarge, wantLimit: 64},
+		{name: "chunked within the group limit", path: "/api/generate", size: 40, chunked: true, wantCode: http.StatusOK},
+		{name: "larger limit outside the group", path: "/upload", size: 1000, wantCode: http.StatusOK},
+		{name: "over the larger limit", path: "/upload", size: 1025, chunked: true, wantCode: http.StatusRequestEntityTooLarge, wantLimit: 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(strings.Repeat("a", tt.size))
+			if tt.chunked {
+				// A reader of unknown length leaves ContentLength at -1
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			if tt.wantCode == http.StatusOK {
+				require.Equal(t, strconv.Itoa(tt.size), w.Body.String(), "the handler reads the whole body")
+				return
+			}
+			var response struct {
+				Error apierrors.APIError `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Equal(t, "REQUEST_TOO_LARGE", response.Error.Code)
+			require.EqualValues(t, tt.wantLimit, response.Error.Details["max_bytes"])
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -8,9 +9,16 @@ import (
 	"github.com/omnigen/backend/internal/adapters"
 	"github.com/omnigen/backend/internal/api/handlers"
 	"github.com/omnigen/backend/internal/api/middleware"
+	"github.com/omnigen/backend/internal/assetcheck"
 	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/compliance"
+	"github.com/omnigen/backend/internal/composition"
+	"github.com/omnigen/backend/internal/health"
+	"github.com/omnigen/backend/internal/metrics"
+	"github.com/omnigen/backend/internal/moderation"
 	"github.com/omnigen/backend/internal/repository"
 	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/internal/trace"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
@@ -18,27 +26,69 @@ import (
 
 // ServerConfig holds the server configuration
 type ServerConfig struct {
-	Port             string
-	Environment      string
-	Logger           *zap.Logger
-	JobRepo          *repository.DynamoDBRepository
-	S3Service        *repository.S3AssetRepository // For presigned URLs and video uploads/downloads
-	UsageRepo        *repository.DynamoDBUsageRepository
-	ParserService    *service.ParserService   // Script generation service
-	AssetService     *service.AssetService    // Asset URL generation service
-	VeoAdapter       *adapters.VeoAdapter     // Veo 3.1 video generation
-	MinimaxAdapter   *adapters.MinimaxAdapter // Minimax audio generation
-	TTSAdapter       adapters.TTSAdapter      // Text-to-speech adapter for narrator voiceover
-	GPT4oAdapter     *adapters.GPT4oAdapter   // GPT-4o for narration generation
-	AssetsBucket     string                   // S3 bucket for video assets
-	APIKeys          []string                 // Deprecated: Use JWTValidator instead
-	JWTValidator     *auth.JWTValidator
-	CookieConfig     auth.CookieConfig // Cookie configuration for httpOnly tokens
-	CloudFrontDomain string            // For CORS in production
-	CognitoDomain    string            // Cognito hosted UI domain for CORS
-	OpenAIKey        string            // OpenAI API key for title generation
-	ReadTimeout      time.Duration
-	WriteTimeout     time.Duration
+	Port                   string
+	Environment            string
+	Logger                 *zap.Logger
+	JobRepo                *repository.DynamoDBRepository
+	S3Service              *repository.S3AssetRepository // For presigned URLs and video uploads/downloads
+	UsageRepo              *repository.DynamoDBUsageRepository
+	IdempotencyRepo        repository.IdempotencyRepository  // Optional: nil ignores Idempotency-Key on POST /generate
+	ScriptRepo             repository.ScriptsRepository      // Optional: nil disables the script library
+	AssetRepo              repository.AssetsRepository       // Optional: nil skips upload verification
+	JobEventRepo           repository.JobEventsRepository    // Optional: nil keeps no job audit trail
+	JobLockRepo            repository.JobLocksRepository     // Optional: nil lets a job's compositions overlap
+	UserBucketRepo         repository.UserBucketsRepository  // Optional: nil stores every job in AssetsBucket
+	PreferencesRepo        repository.PreferencesRepository  // Optional: nil disables saved generation preferences
+	StageTimingsRepo       repository.StageTimingsRepository // Optional: nil keeps job ETA timings per instance, lost on restart
+	AssetKeyPrefix         string                            // Prefix for new jobs' asset keys; "" for none
+	AssetScanner           assetcheck.Scanner                // Optional malware scanner for upload verification
+	BrandRepo              repository.BrandGuidelinesRepository
+	ParserService          *service.ParserService       // Script generation service
+	AssetService           *service.AssetService        // Asset URL generation service
+	AdapterFactory         *adapters.AdapterFactory     // Video generation adapters (Veo 3.1, Kling)
+	MinimaxAdapter         adapters.MusicGenerator      // Minimax audio generation
+	MusicFallbackAdapter   adapters.MusicGenerator      // Optional second music model used when Minimax keeps failing
+	TTSAdapter             adapters.TTSAdapter          // Text-to-speech adapter for narrator voiceover
+	ElevenLabsTTS          adapters.TTSAdapter          // Optional ElevenLabs narrator voices
+	TTSProvider            string                       // Default TTS provider (openai or elevenlabs)
+	GPT4oAdapter           *adapters.GPT4oAdapter       // GPT-4o for narration generation
+	SFXAdapter             adapters.SFXGenerator        // Optional sound effect generation
+	Audio                  handlers.AudioConfig         // Sound effect length and loudness targets
+	TmpBudgetBytes         int64                        // /tmp available to video composition; <= 0 disables the check
+	LLMTokenBudget         int                          // LLM tokens one job may consume; <= 0 disables the budget
+	TmpJanitorInterval     time.Duration                // How often orphaned job directories in /tmp are deleted; <= 0 only at startup
+	TmpJanitorTTL          time.Duration                // Job directories untouched this long are deleted whatever the job's state; <= 0 uses the default
+	StageTimingsInterval   time.Duration                // How often job ETA timings are saved to StageTimingsRepo; <= 0 uses the default
+	ThumbnailWebP          bool                         // Also write WebP job thumbnails next to the JPEGs
+	SceneTransitions       bool                         // New jobs blend scenes with their scripts' transitions instead of hard cuts
+	ClipAspectPolicy       composition.ClipAspectPolicy // Generated clips of the wrong shape are normalized or requested again; "" normalizes
+	ClipQuality            composition.ClipQualityGate  // Black or frozen generated clips are requested again; zero accepts every clip
+	MaxActiveJobs          int                          // Jobs a user may have generating at once; <= 0 disables queueing
+	JobRetentionDays       int                          // Days jobs and their assets are kept; <= 0 keeps them forever
+	InternalAPIToken       string                       // Bearer token for /internal/jobs and /internal/usage endpoints; empty disables them
+	Webhooks               service.WebhookConfig        // Job completion callback delivery
+	ReplicateWebhooks      *adapters.ReplicateWebhooks  // Optional: routes Replicate prediction webhooks; nil polls only
+	ReplicateWebhookSecret string                       // Signing secret for Replicate webhooks
+	Readiness              *health.Checker              // Dependency checks behind GET /readyz; nil checks nothing
+	Metrics                metrics.Recorder             // Pipeline, Replicate and ffmpeg measurements; nil records nothing
+	ModelOverrides         bool                         // Honor X-Model-Override on POST /generate; testing only
+	Compliance             *compliance.Checker          // Pharmaceutical script checks; nil skips them
+	Moderation             *moderation.Checker          // Screens prompts before credits are spent; nil skips it
+	RateLimiter            middleware.RateLimiter       // Shared request budgets; nil keeps them in memory per instance
+	ReadRateLimit          middleware.RateLimitBudget   // Per-user budget for every /api/v1 request; zero Requests disables it
+	WriteRateLimit         middleware.RateLimitBudget   // Per-user budget for each mutating endpoint; zero Requests disables it
+	MaxBodyBytes           int64                        // Largest request body any route accepts; <= 0 uses middleware.DefaultMaxBodyBytes
+	MaxJSONBodyBytes       int64                        // Largest request body /api/v1 routes accept; <= 0 uses middleware.DefaultMaxJSONBodyBytes
+	AssetsBucket           string                       // S3 bucket for video assets
+	APIKeys                []string                     // Deprecated: Use JWTValidator instead
+	JWTValidator           *auth.JWTValidator
+	AdminGroup             string            // Cognito group allowed to use /api/v1/admin; empty disables it
+	CookieConfig           auth.CookieConfig // Cookie configuration for httpOnly tokens
+	CloudFrontDomain       string            // For CORS in production
+	CognitoDomain          string            // Cognito hosted UI domain for CORS
+	OpenAIKey              string            // OpenAI API key for title generation
+	ReadTimeout            time.Duration
+	WriteTimeout           time.Duration
 }
 
 // Server represents the HTTP server
@@ -60,8 +110,13 @@ func NewServer(config *ServerConfig) *Server {
 
 	// Add middlewares
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger(config.Logger))
-	router.Use(middleware.MaxRequestBodySize(10 * 1024 * 1024)) // 10MB limit
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = middleware.DefaultMaxBodyBytes
+	}
+	router.Use(middleware.MaxRequestBodySize(maxBodyBytes))
 
 	// CORS configuration
 	// Build allowed origins list
@@ -84,8 +139,8 @@ func NewServer(config *ServerConfig) *Server {
 	corsConfig := cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", trace.Header},
+		ExposeHeaders:    []string{"Content-Length", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", trace.Header},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -110,13 +165,30 @@ func (s *Server) Router() *gin.Engine {
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	// Health check endpoint (no auth required)
+	readiness := s.config.Readiness
+	if readiness == nil {
+		readiness = health.NewChecker(0, 0)
+	}
 	healthHandler := handlers.NewHealthHandler(
 		s.config.JobRepo,
 		s.config.S3Service,
+		readiness,
 		s.config.Logger,
 	)
 	s.router.GET("/health", healthHandler.Check)
 	s.router.HEAD("/health", healthHandler.Check) // For Docker HEALTHCHECK
+	s.router.GET("/healthz", healthHandler.Liveness)
+	s.router.GET("/readyz", healthHandler.Readiness) // ALB target group health check
+
+	// Replicate prediction webhooks (no auth - verified by signature)
+	if s.config.ReplicateWebhooks != nil {
+		replicateWebhookHandler := handlers.NewReplicateWebhookHandler(
+			s.config.ReplicateWebhooks,
+			s.config.ReplicateWebhookSecret,
+			s.config.Logger,
+		)
+		s.router.POST("/internal/replicate/webhook", replicateWebhookHandler.HandleWebhook)
+	}
 
 	// Swagger documentation (no auth required for development)
 	if s.config.Environment != "production" {
@@ -129,7 +201,18 @@ func (s *Server) setupRoutes() {
 		s.config.CookieConfig,
 		s.config.Logger,
 	)
-	authGroup := s.router.Group("/api/v1/auth")
+	// Per-user request budgets, scaled by subscription tier; routes without auth are keyed by client IP.
+	// Every mutating /api/v1 route also spends a write budget named after what it does.
+	limiter := s.config.RateLimiter
+	if limiter == nil {
+		limiter = middleware.NewMemoryRateLimiter()
+	}
+	readLimit := middleware.RateLimit(limiter, "api", s.config.ReadRateLimit, s.config.Logger)
+	writeLimit := func(endpoint string) gin.HandlerFunc {
+		return middleware.RateLimit(limiter, endpoint, s.config.WriteRateLimit, s.config.Logger)
+	}
+
+	authGroup := s.router.Group("/api/v1/auth", readLimit)
 	{
 		authGroup.POST("/login", authHandler.Login)                                                          // Exchange Cognito tokens for cookies
 		authGroup.POST("/refresh", authHandler.Refresh)                                                      // Refresh token endpoint
@@ -154,36 +237,152 @@ func (s *Server) setupRoutes() {
 		})
 		s.config.Logger.Warn("Auth disabled for development/local testing - using mock user")
 	}
+	v1.Use(readLimit)
+
+	// JSON bodies are small; a route taking uploads directly would get a group of its own under
+	// the larger limit above
+	maxJSONBodyBytes := s.config.MaxJSONBodyBytes
+	if maxJSONBodyBytes <= 0 {
+		maxJSONBodyBytes = middleware.DefaultMaxJSONBodyBytes
+	}
+	v1.Use(middleware.MaxRequestBodySize(maxJSONBodyBytes))
 
 	{
-		// Initialize disclaimer service for two-pass narration generation
+		// Route narration to the job's TTS provider, falling back to OpenAI
+		ttsRouter := adapters.NewTTSRouter(
+			adapters.TTSProvider(s.config.TTSProvider),
+			map[adapters.TTSProvider]adapters.TTSAdapter{
+				adapters.TTSProviderOpenAI:     s.config.TTSAdapter,
+				adapters.TTSProviderElevenLabs: s.config.ElevenLabsTTS,
+			},
+			s.config.Logger,
+		)
+
+		// Initialize disclaimer service for two-pass narration generation, voiced like the narration
 		var disclaimerService *service.DisclaimerService
-		if ttsAdapter, ok := s.config.TTSAdapter.(*adapters.OpenAITTSAdapter); ok && s.config.GPT4oAdapter != nil {
-			disclaimerService = service.NewDisclaimerService(ttsAdapter, s.config.GPT4oAdapter, s.config.Logger)
+		if s.config.GPT4oAdapter != nil {
+			disclaimerService = service.NewDisclaimerService(ttsRouter, s.config.GPT4oAdapter, s.config.Logger)
+		}
+
+		// Background music comes from Minimax, or the fallback model while Minimax is failing
+		musicChain := adapters.NewMusicChain([]adapters.MusicSource{
+			{Provider: adapters.MusicProviderMinimax, Generator: s.config.MinimaxAdapter},
+			{Provider: adapters.MusicProviderMusicGen, Generator: s.config.MusicFallbackAdapter},
+		}, s.config.Logger)
+
+		// Credit enforcement for generation (requires a usage table)
+		var usageService *service.UsageService
+		if s.config.UsageRepo != nil {
+			usageService = service.NewUsageService(s.config.UsageRepo, s.config.Logger)
+		}
+
+		// Signed callbacks to customer URLs when jobs complete or fail
+		var webhookService *service.WebhookService
+		if s.config.JobRepo != nil {
+			webhookService = service.NewWebhookService(s.config.JobRepo, s.config.Webhooks, s.config.Logger)
+		}
+
+		// New jobs record where their assets go, so later config changes don't move them. Uploads
+		// and other assets of the user are keyed under the same prefix.
+		assetLocator := repository.NewAssetLocator(s.config.AssetsBucket, s.config.AssetKeyPrefix, s.config.UserBucketRepo)
+
+		// Uploads are verified before jobs use them when there is somewhere to record the results
+		var assetVerifier *handlers.AssetVerifier
+		if s.config.AssetRepo != nil && s.config.S3Service != nil {
+			assetVerifier = handlers.NewAssetVerifier(
+				s.config.AssetRepo,
+				s.config.S3Service,
+				s.config.AssetScanner,
+				s.config.AssetsBucket,
+				assetLocator,
+				s.config.Logger,
+			)
 		}
 
 		// Initialize handlers with goroutine-based async architecture
-		generateHandler := handlers.NewGenerateHandler(
-			s.config.ParserService,
-			s.config.VeoAdapter,
-			s.config.MinimaxAdapter,
-			s.config.TTSAdapter,
-			s.config.GPT4oAdapter,
-			disclaimerService,
-			s.config.S3Service,
+		generateHandler := handlers.NewGenerateHandler(handlers.GenerateHandlerDeps{
+			ParserService:        s.config.ParserService,
+			AdapterFactory:       s.config.AdapterFactory,
+			Music:                musicChain,
+			TTSRouter:            ttsRouter,
+			GPT4oAdapter:         s.config.GPT4oAdapter,
+			DisclaimerService:    disclaimerService,
+			S3Service:            s.config.S3Service,
+			JobRepo:              s.config.JobRepo,
+			BrandRepo:            s.config.BrandRepo,
+			UsageService:         usageService,
+			StorageUsage:         s.config.UsageRepo,
+			Webhooks:             webhookService,
+			IdempotencyRepo:      s.config.IdempotencyRepo,
+			ScriptRepo:           s.config.ScriptRepo,
+			PreferencesRepo:      s.config.PreferencesRepo,
+			SFXAdapter:           s.config.SFXAdapter,
+			AudioConfig:          s.config.Audio,
+			TmpBudget:            s.config.TmpBudgetBytes,
+			TokenBudget:          s.config.LLMTokenBudget,
+			ThumbnailWebP:        s.config.ThumbnailWebP,
+			SceneTransitions:     s.config.SceneTransitions,
+			ClipAspectPolicy:     s.config.ClipAspectPolicy,
+			ClipQuality:          s.config.ClipQuality,
+			MaxActiveJobsPerUser: s.config.MaxActiveJobs,
+			RetentionDays:        s.config.JobRetentionDays,
+			AssetsBucket:         s.config.AssetsBucket,
+			Metrics:              s.config.Metrics,
+			ModelOverrides:       s.config.ModelOverrides,
+			Compliance:           s.config.Compliance,
+			AssetVerifier:        assetVerifier,
+			JobEvents:            s.config.JobEventRepo,
+			JobLocks:             s.config.JobLockRepo,
+			AssetLocator:         assetLocator,
+			Moderation:           s.config.Moderation,
+		}, s.config.Logger)
+
+		// Start jobs left queued by a previous server and promote queued jobs freed elsewhere
+		go generateHandler.RunJobQueue(context.Background())
+
+		// Delete job working directories a crash or kill left in /tmp, never a running pipeline's
+		tmpJanitor := handlers.NewTmpJanitor(
 			s.config.JobRepo,
-			s.config.AssetsBucket,
+			generateHandler,
+			s.config.TmpJanitorTTL,
+			s.config.TmpJanitorInterval,
+			s.config.Metrics,
 			s.config.Logger,
 		)
+		go tmpJanitor.Run(context.Background())
+
+		// Start job ETAs from the step timings earlier servers measured, and keep them saved
+		if s.config.StageTimingsRepo != nil {
+			go generateHandler.PersistStageTimings(context.Background(), s.config.StageTimingsRepo, s.config.StageTimingsInterval)
+		}
 
 		jobsHandler := handlers.NewJobsHandler(
 			s.config.JobRepo,
 			s.config.S3Service,
 			s.config.AssetService,
+			s.config.UsageRepo,
 			s.config.AssetsBucket,
 			s.config.Logger,
 		)
 
+		// Delete expired jobs and their assets before DynamoDB TTL drops the records
+		if s.config.JobRetentionDays > 0 && s.config.JobRepo != nil && s.config.S3Service != nil {
+			retentionHandler := handlers.NewJobRetentionHandler(
+				s.config.JobRepo,
+				s.config.S3Service,
+				s.config.UsageRepo,
+				s.config.AssetsBucket,
+				s.config.InternalAPIToken,
+				s.config.Logger,
+			)
+			go retentionHandler.Run(context.Background())
+
+			// Lets an external scheduler trigger a sweep (no JWT - verified by bearer token)
+			if s.config.InternalAPIToken != "" {
+				s.router.POST("/internal/jobs/retention-sweep", retentionHandler.SweepExpired)
+			}
+		}
+
 		progressHandler := handlers.NewProgressHandler(
 			s.config.JobRepo,
 			s.config.AssetService,
@@ -198,29 +397,194 @@ func (s *Server) setupRoutes() {
 		uploadHandler := handlers.NewUploadHandler(
 			s.config.S3Service,
 			s.config.AssetsBucket,
+			assetLocator,
 			s.config.Logger,
 		)
 
 		regenerateHandler := handlers.NewRegenerateHandler(
 			s.config.JobRepo,
 			s.config.S3Service,
-			s.config.VeoAdapter,
+			s.config.UsageRepo,
+			s.config.AdapterFactory,
+			s.config.TmpBudgetBytes,
 			s.config.AssetsBucket,
+			assetLocator,
+			s.config.Metrics,
+			s.config.JobEventRepo,
+			s.config.JobLockRepo,
 			s.config.Logger,
 		)
 
 		// Generation routes
-		v1.POST("/generate", generateHandler.Generate)
-		v1.POST("/generate/title", titleHandler.GenerateTitle)
+		v1.POST("/generate", writeLimit("generate"), generateHandler.Generate)
+		v1.POST("/generate/title", writeLimit("title"), titleHandler.GenerateTitle)
+		v1.POST("/generate/estimate", generateHandler.EstimateGenerate)
 
 		// Job routes
 		v1.GET("/jobs/:id", jobsHandler.GetJob)
 		v1.GET("/jobs", jobsHandler.ListJobs)
-		v1.DELETE("/jobs/:id", jobsHandler.DeleteJob)
-		v1.GET("/jobs/:id/progress", progressHandler.GetProgress)                               // SSE streaming endpoint
-		v1.POST("/jobs/:id/scenes/:scene_number/regenerate", regenerateHandler.RegenerateScene) // Scene regeneration
+		v1.PATCH("/jobs/:id", writeLimit("jobs"), jobsHandler.UpdateJob) // Rename a job or edit its note
+		v1.DELETE("/jobs/:id", writeLimit("jobs"), jobsHandler.DeleteJob)
+		v1.POST("/jobs/bulk-delete", writeLimit("jobs"), jobsHandler.BulkDeleteJobs)
+		v1.GET("/jobs/:id/progress", progressHandler.GetProgress)                                                         // SSE streaming endpoint
+		v1.POST("/jobs/:id/approve", writeLimit("generate"), generateHandler.ApproveJob)                                  // Script preview approval
+		v1.POST("/jobs/:id/cancel", writeLimit("cancel"), generateHandler.CancelJob)                                      // Stops a queued or running job
+		v1.POST("/jobs/:id/duplicate", writeLimit("generate"), generateHandler.DuplicateJob)                              // New job with the same settings
+		v1.POST("/jobs/:id/scenes/:scene_number/regenerate", writeLimit("regenerate"), regenerateHandler.RegenerateScene) // Scene regeneration
+		v1.POST("/jobs/:id/scenes/reorder", writeLimit("regenerate"), regenerateHandler.ReorderScenes)                    // Reorder or delete scenes
+		v1.POST("/jobs/:id/scenes/reorder/undo", writeLimit("regenerate"), regenerateHandler.UndoSceneReorder)            // Restore the order before the last reorder
+		v1.PATCH("/jobs/:id/scenes/:scene_number/trim", writeLimit("regenerate"), regenerateHandler.TrimScene)            // Trim a scene's clip
+		v1.POST("/jobs/:id/recompose", writeLimit("regenerate"), regenerateHandler.RecomposeJob)                          // Rerun composition from the stored clips
+
+		// Job audit trails (requires a job events table)
+		if s.config.JobRepo != nil && s.config.JobEventRepo != nil {
+			jobEventsHandler := handlers.NewJobEventsHandler(s.config.JobRepo, s.config.JobEventRepo, s.config.Logger)
+			v1.GET("/jobs/:id/events", jobEventsHandler.ListJobEvents)
+		}
+
+		// Export routes: zip a job's assets into one download
+		if s.config.JobRepo != nil && s.config.S3Service != nil {
+			exportsHandler := handlers.NewExportsHandler(
+				s.config.JobRepo,
+				s.config.S3Service,
+				s.config.AssetsBucket,
+				s.config.Logger,
+			)
+			v1.POST("/jobs/:id/export", writeLimit("export"), exportsHandler.CreateExport)
+			v1.GET("/jobs/:id/exports", exportsHandler.ListExports)
+		}
+
+		// Platform renditions: the final video re-encoded per social platform
+		if s.config.JobRepo != nil && s.config.S3Service != nil {
+			renditionsHandler := handlers.NewRenditionsHandler(
+				s.config.JobRepo,
+				s.config.S3Service,
+				s.config.UsageRepo,
+				s.config.AssetsBucket,
+				s.config.Logger,
+			)
+			v1.POST("/jobs/:id/renditions", writeLimit("renditions"), renditionsHandler.CreateRenditions)
+			v1.GET("/jobs/:id/renditions", renditionsHandler.ListRenditions)
+		}
+
+		// Narrator voices, with previews cached in the assets bucket
+		if s.config.S3Service != nil {
+			voicesHandler := handlers.NewVoicesHandler(
+				ttsRouter,
+				s.config.S3Service,
+				s.config.UsageRepo,
+				s.config.AssetsBucket,
+				s.config.Logger,
+			)
+			v1.GET("/voices", voicesHandler.ListVoices)
+			v1.POST("/voices/preview", writeLimit("voice-preview"), voicesHandler.PreviewVoice)
+		}
+
+		// Usage routes
+		if usageService != nil {
+			usageHandler := handlers.NewUsageHandler(usageService, s.config.Logger)
+			v1.GET("/usage", usageHandler.GetUsage)
+		}
+		if s.config.UsageRepo != nil && s.config.JobRepo != nil {
+			storageHandler := handlers.NewStorageUsageHandler(
+				s.config.JobRepo,
+				s.config.UsageRepo,
+				s.config.S3Service,
+				s.config.AssetsBucket,
+				s.config.InternalAPIToken,
+				s.config.Logger,
+			)
+			v1.GET("/usage/storage", storageHandler.GetStorageUsage)
+
+			// Sizes the assets of jobs from before storage accounting (no JWT - verified by bearer token)
+			if s.config.InternalAPIToken != "" && s.config.S3Service != nil {
+				s.router.POST("/internal/usage/storage-backfill", storageHandler.BackfillStorage)
+			}
+		}
 
 		// Upload routes
-		v1.POST("/upload/presigned-url", uploadHandler.GetPresignedURL)
+		v1.POST("/upload/presigned-url", writeLimit("upload"), uploadHandler.GetPresignedURL)
+
+		// Resumable uploads of large assets, in presigned parts
+		if s.config.S3Service != nil {
+			multipartHandler := handlers.NewMultipartUploadHandler(
+				s.config.S3Service,
+				s.config.AssetsBucket,
+				assetLocator,
+				s.config.Logger,
+			)
+			go multipartHandler.RunJanitor(context.Background())
+
+			v1.POST("/assets/multipart/initiate", writeLimit("upload"), multipartHandler.InitiateUpload)
+			v1.POST("/assets/multipart/parts", writeLimit("upload-parts"), multipartHandler.PresignParts)
+			v1.POST("/assets/multipart/complete", writeLimit("upload-complete"), multipartHandler.CompleteUpload)
+			v1.DELETE("/assets/multipart/abort", writeLimit("upload-abort"), multipartHandler.AbortUpload)
+
+			// The library of the user's uploads, for reuse by later jobs
+			libraryHandler := handlers.NewAssetLibraryHandler(
+				s.config.S3Service,
+				s.config.AssetsBucket,
+				assetLocator,
+				s.config.Logger,
+			)
+			v1.GET("/assets", libraryHandler.ListAssets)
+			v1.DELETE("/assets/*key", writeLimit("assets"), libraryHandler.DeleteAsset)
+		}
+		if assetVerifier != nil {
+			v1.POST("/assets/verify", writeLimit("upload"), assetVerifier.VerifyAsset)
+		}
+
+		// Support staff routes: always behind a real JWT carrying the admin group, even in development
+		if s.config.AdminGroup != "" && s.config.JobRepo != nil && s.config.JWTValidator != nil {
+			adminHandler := handlers.NewAdminJobsHandler(s.config.JobRepo, generateHandler, s.config.Logger)
+			registerAdminRoutes(s.router.Group("/api/v1/admin",
+				auth.JWTAuthMiddleware(s.config.JWTValidator, s.config.Logger),
+				auth.RequireGroup(s.config.AdminGroup, s.config.Logger),
+			), adminHandler)
+		}
+
+		// Script library routes (require a scripts store)
+		if s.config.ScriptRepo != nil {
+			scriptsHandler := handlers.NewScriptsHandler(s.config.ScriptRepo, s.config.Logger)
+			v1.GET("/scripts", scriptsHandler.ListScripts)
+			v1.GET("/scripts/:id", scriptsHandler.GetScript)
+			v1.POST("/generate/from-script/:id", writeLimit("generate"), generateHandler.GenerateFromScript)
+		}
+
+		// Default generation settings (require a preferences store)
+		if s.config.PreferencesRepo != nil {
+			preferencesHandler := handlers.NewPreferencesHandler(s.config.PreferencesRepo, ttsRouter, s.config.Logger)
+			v1.GET("/preferences", preferencesHandler.GetPreferences)
+			v1.PUT("/preferences", writeLimit("preferences"), preferencesHandler.PutPreferences)
+		}
+
+		// Brand guideline routes (require a guidelines store; extraction also needs GPT-4o)
+		if s.config.BrandRepo != nil {
+			var extractionService *service.BrandExtractionService
+			if s.config.GPT4oAdapter != nil {
+				extractionService = service.NewBrandExtractionService(
+					s.config.S3Service,
+					s.config.GPT4oAdapter,
+					s.config.AssetsBucket,
+					assetLocator,
+					s.config.Logger,
+				)
+			}
+			brandHandler := handlers.NewBrandGuidelinesHandler(s.config.BrandRepo, extractionService, assetLocator, s.config.Logger)
+			v1.GET("/brand-guidelines", brandHandler.ListGuidelines)
+			v1.POST("/brand-guidelines", writeLimit("brand-guidelines"), brandHandler.CreateGuidelines)
+			v1.GET("/brand-guidelines/:id", brandHandler.GetGuidelines)
+			v1.POST("/brand-guidelines/:id/activate", writeLimit("brand-guidelines"), brandHandler.ActivateGuidelines)
+			if extractionService != nil {
+				v1.POST("/brand-guidelines/:id/extract", writeLimit("brand-extract"), brandHandler.ExtractGuidelines)
+			}
+		}
 	}
 }
+
+// registerAdminRoutes adds the admin API to a group that already requires the admin group
+func registerAdminRoutes(admin *gin.RouterGroup, h *handlers.AdminJobsHandler) {
+	admin.GET("/jobs", h.ListJobs)
+	admin.GET("/jobs/:id", h.GetJob)
+	admin.POST("/jobs/:id/requeue", h.RequeueJob) // Reruns a failed job as its owner
+}
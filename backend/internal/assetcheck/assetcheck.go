@@ -0,0 +1,291 @@
+// Package assetcheck verifies uploaded assets before they are used in prompts or sent to
+// third-party models: the bytes must match the declared type, stay within size and pixel
+// limits, carry no PDF JavaScript, and pass the malware scanner when one is configured.
+package assetcheck
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"  // Registers GIF for image.DecodeConfig
+	_ "image/jpeg" // Registers JPEG for image.DecodeConfig
+	_ "image/png"  // Registers PNG for image.DecodeConfig
+	"io"
+	"strings"
+
+	"github.com/omnigen/backend/internal/documents"
+)
+
+// Limits on verified assets
+const (
+	MaxImageBytes  = 50 * 1024 * 1024
+	MaxImagePixels = 50_000_000 // Decompression bomb guard; a 50MP photo is about 8700x5800
+	MaxImageSide   = 16384
+	MaxVideoBytes  = 1024 * 1024 * 1024 // Matches the multipart upload limit
+
+	// sniffBytes is how much of the file is read to identify its type
+	sniffBytes = 512
+)
+
+// Reasons an asset is rejected
+const (
+	ReasonUnsupportedType = "unsupported_type" // Declared content type isn't accepted
+	ReasonTypeMismatch    = "type_mismatch"    // Bytes aren't what the content type says
+	ReasonTooLarge        = "too_large"        // File size over the limit for its type
+	ReasonDimensions      = "dimensions"       // Image too large in pixels, or unreadable
+	ReasonPDFJavaScript   = "pdf_javascript"   // PDF carries JavaScript
+	ReasonMalware         = "malware"          // The scanner found a threat
+)
+
+// Rejection explains why an asset failed verification
+type Rejection struct {
+	Reason  string // One of the Reason constants
+	Message string // Shown to the user
+}
+
+// Result is the outcome of verifying one asset. Rejection is nil for a verified asset.
+type Result struct {
+	ContentType string
+	SizeBytes   int64
+	Width       int // Images only
+	Height      int
+	Threat      string // Name the scanner gave the threat, for ReasonMalware
+	Rejection   *Rejection
+}
+
+// Scanner checks content for malware. Scan returns the threat's name, or "" when the content
+// is clean; errors mean the content could not be scanned.
+type Scanner interface {
+	Scan(ctx context.Context, body io.Reader) (threat string, err error)
+}
+
+type kind int
+
+const (
+	kindImage kind = iota
+	kindDocument
+	kindVideo
+)
+
+// contentTypes are the accepted content types, with how each is checked
+var contentTypes = map[string]kind{
+	"image/png":       kindImage,
+	"image/jpeg":      kindImage,
+	"image/webp":      kindImage,
+	"image/gif":       kindImage,
+	"application/pdf": kindDocument,
+	"video/mp4":       kindVideo,
+	"video/quicktime": kindVideo,
+}
+
+// Verifier checks uploaded assets
+type Verifier struct {
+	scanner Scanner // nil skips malware scanning
+}
+
+// NewVerifier creates a verifier; a nil scanner skips malware scanning
+func NewVerifier(scanner Scanner) *Verifier {
+	return &Verifier{scanner: scanner}
+}
+
+// Verify reads an asset declared as contentType and checks it. A rejected asset is reported in
+// the result; errors mean it couldn't be checked (read or scanner failures) and may be retried.
+// Images and documents are read into memory up to their limit; videos are streamed.
+func (v *Verifier) Verify(ctx context.Context, body io.Reader, contentType string) (*Result, error) {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	result := &Result{ContentType: contentType}
+	k, ok := contentTypes[contentType]
+	if !ok {
+		result.Rejection = &Rejection{ReasonUnsupportedType, fmt.Sprintf("Files of type %q can't be used", contentType)}
+		return result, nil
+	}
+
+	limit := int64(MaxImageBytes)
+	switch k {
+	case kindDocument:
+		limit = documents.MaxPDFBytes
+	case kindVideo:
+		limit = MaxVideoBytes
+	}
+	counted := &countingReader{r: io.LimitReader(body, limit+1)}
+	buffered := bufio.NewReaderSize(counted, sniffBytes)
+	header, err := buffered.Peek(sniffBytes)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	if detected := sniff(header); !matches(contentType, detected) {
+		if detected == "" {
+			detected = "not a recognized format"
+		}
+		result.Rejection = &Rejection{ReasonTypeMismatch, fmt.Sprintf("File content (%s) doesn't match its type %s", detected, contentType)}
+		return result, nil
+	}
+
+	// Videos go straight to the scanner; everything else is checked in memory first
+	if k == kindVideo {
+		threat, err := v.scan(ctx, buffered)
+		result.SizeBytes = counted.n
+		if err != nil {
+			return nil, err
+		}
+		if counted.n > limit {
+			result.Rejection = tooLarge(limit)
+			return result, nil
+		}
+		result.Threat = threat
+		if threat != "" {
+			result.Rejection = &Rejection{ReasonMalware, "File failed the malware scan"}
+		}
+		return result, nil
+	}
+
+	data, err := io.ReadAll(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	result.SizeBytes = int64(len(data))
+	if result.SizeBytes > limit {
+		result.Rejection = tooLarge(limit)
+		return result, nil
+	}
+
+	switch k {
+	case kindImage:
+		result.Width, result.Height, ok = ImageSize(data)
+		if !ok {
+			result.Rejection = &Rejection{ReasonDimensions, "Image dimensions could not be read"}
+			return result, nil
+		}
+		if result.Width > MaxImageSide || result.Height > MaxImageSide || result.Width*result.Height > MaxImagePixels {
+			result.Rejection = &Rejection{ReasonDimensions, fmt.Sprintf(
+				"Image is %dx%d; images can be at most %dpx on a side and %d megapixels",
+				result.Width, result.Height, MaxImageSide, MaxImagePixels/1_000_000)}
+			return result, nil
+		}
+	case kindDocument:
+		if documents.HasJavaScript(data) {
+			result.Rejection = &Rejection{ReasonPDFJavaScript, "PDFs containing JavaScript can't be used"}
+			return result, nil
+		}
+	}
+
+	threat, err := v.scan(ctx, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	result.Threat = threat
+	if threat != "" {
+		result.Rejection = &Rejection{ReasonMalware, "File failed the malware scan"}
+	}
+	return result, nil
+}
+
+// scan runs the scanner over body, or drains it when there is no scanner so the size is known
+func (v *Verifier) scan(ctx context.Context, body io.Reader) (string, error) {
+	if v.scanner == nil {
+		_, err := io.Copy(io.Discard, body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read asset: %w", err)
+		}
+		return "", nil
+	}
+	threat, err := v.scanner.Scan(ctx, body)
+	if err != nil {
+		return "", fmt.Errorf("malware scan failed: %w", err)
+	}
+	return threat, nil
+}
+
+func tooLarge(limit int64) *Rejection {
+	return &Rejection{ReasonTooLarge, fmt.Sprintf("File is larger than the %d MB limit for its type", limit/(1024*1024))}
+}
+
+// sniff identifies a file from its first bytes, returning its content type or ""
+func sniff(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(header, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return "image/webp"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return "image/gif"
+	case bytes.HasPrefix(bytes.TrimLeft(header, "\x00\t\r\n "), []byte("%PDF-")):
+		return "application/pdf"
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		if string(header[8:12]) == "qt  " {
+			return "video/quicktime"
+		}
+		return "video/mp4"
+	case len(header) >= 8 && (string(header[4:8]) == "moov" || string(header[4:8]) == "mdat" || string(header[4:8]) == "wide"):
+		return "video/quicktime" // QuickTime files from before the ftyp box
+	}
+	return ""
+}
+
+// matches reports whether a file detected as detected may be used as declared. MP4 and
+// QuickTime share a container, and phones label either as the other.
+func matches(declared, detected string) bool {
+	if declared == detected {
+		return true
+	}
+	isVideo := func(t string) bool { return t == "video/mp4" || t == "video/quicktime" }
+	return isVideo(declared) && isVideo(detected)
+}
+
+// ImageSize reads a PNG, JPEG, GIF or WebP image's dimensions from its header
+func ImageSize(data []byte) (width, height int, ok bool) {
+	if sniff(data) == "image/webp" {
+		return webpSize(data)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// webpSize reads the canvas size of a lossy (VP8), lossless (VP8L) or extended (VP8X) WebP
+func webpSize(data []byte) (width, height int, ok bool) {
+	if len(data) < 30 {
+		return 0, 0, false
+	}
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		width = 1 + int(uint32(chunk[4])|uint32(chunk[5])<<8|uint32(chunk[6])<<16)
+		height = 1 + int(uint32(chunk[7])|uint32(chunk[8])<<8|uint32(chunk[9])<<16)
+	case "VP8 ":
+		if !bytes.Equal(chunk[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, false
+		}
+		width = int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+		height = int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+	case "VP8L":
+		if chunk[0] != 0x2f {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		width = 1 + int(bits&0x3fff)
+		height = 1 + int(bits>>14&0x3fff)
+	default:
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,136 @@
+package assetcheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func openFixture(t *testing.T, name string) io.Reader {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return bytes.NewReader(data)
+}
+
+// fakeScanner reports threat for every scan, and records what it was sent
+type fakeScanner struct {
+	threat  string
+	err     error
+	scanned []byte
+}
+
+func (s *fakeScanner) Scan(_ context.Context, body io.Reader) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	s.scanned = data
+	return s.threat, s.err
+}
+
+// zeros is an endless reader of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestVerify_AcceptsValidAssets(t *testing.T) {
+	tests := []struct {
+		fixture       string
+		contentType   string
+		width, height int
+	}{
+		{"product.png", "image/png", 64, 48},
+		{"product.jpg", "image/jpeg", 64, 48},
+		{"banner.webp", "image/webp", 1200, 628},
+		{"brand.pdf", "application/pdf", 0, 0},
+		{"clip.mp4", "video/mp4", 0, 0},
+		{"clip.mp4", "video/quicktime", 0, 0},
+		{"product.png", "IMAGE/PNG; charset=binary", 64, 48},
+	}
+	for _, tt := range tests {
+		scanner := &fakeScanner{}
+		result, err := NewVerifier(scanner).Verify(context.Background(), openFixture(t, tt.fixture), tt.contentType)
+		if err != nil {
+			t.Fatalf("%s: Verify() error = %v", tt.fixture, err)
+		}
+		if result.Rejection != nil {
+			t.Errorf("%s as %s: rejected with %+v", tt.fixture, tt.contentType, result.Rejection)
+			continue
+		}
+		if result.Width != tt.width || result.Height != tt.height {
+			t.Errorf("%s: size = %dx%d, want %dx%d", tt.fixture, result.Width, result.Height, tt.width, tt.height)
+		}
+		if int64(len(scanner.scanned)) != result.SizeBytes || result.SizeBytes == 0 {
+			t.Errorf("%s: scanned %d bytes of %d", tt.fixture, len(scanner.scanned), result.SizeBytes)
+		}
+	}
+}
+
+func TestVerify_Rejections(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        io.Reader
+		contentType string
+		scanner     Scanner
+		want        string
+	}{
+		{"unsupported type", openFixture(t, "brand.pdf"), "application/zip", nil, ReasonUnsupportedType},
+		{"HTML saved as a PNG", openFixture(t, "not_an_image.png"), "image/png", nil, ReasonTypeMismatch},
+		{"PNG declared as a JPEG", openFixture(t, "product.png"), "image/jpeg", nil, ReasonTypeMismatch},
+		{"image declared as a PDF", openFixture(t, "product.jpg"), "application/pdf", nil, ReasonTypeMismatch},
+		{"PDF declared as a video", openFixture(t, "brand.pdf"), "video/mp4", nil, ReasonTypeMismatch},
+		{"image over the pixel limit", openFixture(t, "oversized.png"), "image/png", nil, ReasonDimensions},
+		{"PDF with JavaScript", openFixture(t, "brand_javascript.pdf"), "application/pdf", nil, ReasonPDFJavaScript},
+		{"malware", openFixture(t, "product.png"), "image/png", &fakeScanner{threat: "Win.Test.EICAR_HDB-1"}, ReasonMalware},
+		{"video malware", openFixture(t, "clip.mp4"), "video/mp4", &fakeScanner{threat: "Win.Test.EICAR_HDB-1"}, ReasonMalware},
+		{
+			"image over the size limit",
+			io.MultiReader(openFixture(t, "product.png"), io.LimitReader(zeros{}, MaxImageBytes)),
+			"image/png", nil, ReasonTooLarge,
+		},
+		{
+			"video over the size limit",
+			io.MultiReader(openFixture(t, "clip.mp4"), io.LimitReader(zeros{}, MaxVideoBytes)),
+			"video/mp4", nil, ReasonTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		result, err := NewVerifier(tt.scanner).Verify(context.Background(), tt.body, tt.contentType)
+		if err != nil {
+			t.Fatalf("%s: Verify() error = %v", tt.name, err)
+		}
+		if result.Rejection == nil || result.Rejection.Reason != tt.want {
+			t.Errorf("%s: rejection = %+v, want %s", tt.name, result.Rejection, tt.want)
+			continue
+		}
+		if result.Rejection.Message == "" {
+			t.Errorf("%s: rejection has no message", tt.name)
+		}
+	}
+}
+
+func TestVerify_ScannerFailure(t *testing.T) {
+	scanner := &fakeScanner{err: errors.New("connection refused")}
+	if _, err := NewVerifier(scanner).Verify(context.Background(), openFixture(t, "product.png"), "image/png"); err == nil {
+		t.Error("expected an error when the scanner is unavailable")
+	}
+
+	// Assets rejected before scanning never reach the scanner
+	scanner = &fakeScanner{err: errors.New("connection refused")}
+	result, err := NewVerifier(scanner).Verify(context.Background(), openFixture(t, "brand_javascript.pdf"), "application/pdf")
+	if err != nil || result.Rejection == nil || result.Rejection.Reason != ReasonPDFJavaScript {
+		t.Errorf("Verify() = %+v, %v; want a pdf_javascript rejection", result, err)
+	}
+	if scanner.scanned != nil {
+		t.Error("rejected PDF was sent to the scanner")
+	}
+}
@@ -0,0 +1,96 @@
+package assetcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeClamd serves INSTREAM, replying with reply(content) to each scan
+func startFakeClamd(t *testing.T, reply func(content []byte) string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, conn, int64(size)); err != nil {
+						return
+					}
+				}
+				io.WriteString(conn, reply(content.Bytes())+"\x00")
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	addr := startFakeClamd(t, func(content []byte) string {
+		switch {
+		case bytes.Contains(content, []byte("infected")):
+			return "stream: Win.Test.EICAR_HDB-1 FOUND"
+		case len(content) > 2*clamdChunkSize:
+			return "INSTREAM size limit exceeded. ERROR"
+		}
+		return "stream: OK"
+	})
+	scanner := NewClamdScanner(addr, 5*time.Second)
+
+	threat, err := scanner.Scan(context.Background(), strings.NewReader("clean product photo"))
+	if err != nil || threat != "" {
+		t.Errorf("clean content: threat = %q, err = %v", threat, err)
+	}
+
+	// Content spanning several chunks is reassembled in order
+	infected := append(bytes.Repeat([]byte("x"), clamdChunkSize+10), []byte("infected")...)
+	threat, err = scanner.Scan(context.Background(), bytes.NewReader(infected))
+	if err != nil || threat != "Win.Test.EICAR_HDB-1" {
+		t.Errorf("infected content: threat = %q, err = %v", threat, err)
+	}
+
+	if _, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 3*clamdChunkSize))); err == nil {
+		t.Error("expected an error for a clamd ERROR reply")
+	}
+}
+
+func TestClamdScanner_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewClamdScanner(addr, time.Second).Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Error("expected an error when clamd is down")
+	}
+}
@@ -3,12 +3,14 @@ package auth
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"math/big"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -32,44 +34,86 @@ type JWKS struct {
 	Keys []JWK `json:"keys"`
 }
 
+const (
+	// jwksRefreshInterval is how often the key set is re-fetched in the background
+	jwksRefreshInterval = 30 * time.Minute
+	// unknownKidRefreshInterval is the minimum time between refreshes forced by tokens
+	// carrying a kid we don't know, so a stream of forged tokens can't hammer Cognito
+	unknownKidRefreshInterval = time.Minute
+)
+
+// jwksSnapshot is one fetched key set. Refreshes build a new snapshot and swap it in whole,
+// so readers never see a half-updated map.
+type jwksSnapshot struct {
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
 // JWTValidator handles JWT token validation
 type JWTValidator struct {
-	jwksURL       string
-	issuer        string
-	clientID      string
-	logger        *zap.Logger
-	keys          map[string]*rsa.PublicKey
-	keysMu        sync.RWMutex
-	lastFetchTime time.Time
-	stopRefresh   chan struct{}
+	jwksURL     string
+	issuer      string
+	clientID    string
+	logger      *zap.Logger
+	keys        atomic.Pointer[jwksSnapshot]
+	refreshMu   sync.Mutex // one JWKS fetch at a time
+	stopRefresh chan struct{}
+
+	// kidRefreshInterval is unknownKidRefreshInterval outside tests; lastKidRefresh is
+	// guarded by refreshMu and counts failed attempts too
+	kidRefreshInterval time.Duration
+	lastKidRefresh     time.Time
+
+	// Set by NewStaticJWTValidator: the one token accepted and the claims it carries
+	staticToken  string
+	staticClaims *domain.UserClaims
 }
 
 // NewJWTValidator creates a new JWT validator
 func NewJWTValidator(jwksURL, issuer, clientID string, logger *zap.Logger) *JWTValidator {
-	v := &JWTValidator{
-		jwksURL:     jwksURL,
-		issuer:      issuer,
-		clientID:    clientID,
-		logger:      logger,
-		keys:        make(map[string]*rsa.PublicKey),
-		stopRefresh: make(chan struct{}),
-	}
+	v := newJWTValidator(jwksURL, issuer, clientID, logger)
 
 	// Start background JWKS refresh goroutine
-	go v.backgroundRefresh()
+	go v.backgroundRefresh(jwksRefreshInterval)
 
 	return v
 }
 
+// newJWTValidator creates a validator without starting the background refresh
+func newJWTValidator(jwksURL, issuer, clientID string, logger *zap.Logger) *JWTValidator {
+	v := &JWTValidator{
+		jwksURL:            jwksURL,
+		issuer:             issuer,
+		clientID:           clientID,
+		logger:             logger,
+		stopRefresh:        make(chan struct{}),
+		kidRefreshInterval: unknownKidRefreshInterval,
+	}
+	v.keys.Store(&jwksSnapshot{keys: map[string]*rsa.PublicKey{}})
+	return v
+}
+
+// NewStaticJWTValidator creates a validator for ENVIRONMENT=local that accepts only token,
+// returning a copy of claims for it. It never contacts Cognito.
+func NewStaticJWTValidator(token string, claims *domain.UserClaims, logger *zap.Logger) *JWTValidator {
+	v := &JWTValidator{
+		logger:       logger,
+		stopRefresh:  make(chan struct{}),
+		staticToken:  token,
+		staticClaims: claims,
+	}
+	v.keys.Store(&jwksSnapshot{keys: map[string]*rsa.PublicKey{}})
+	return v
+}
+
 // backgroundRefresh periodically refreshes JWKS in the background
-func (v *JWTValidator) backgroundRefresh() {
+func (v *JWTValidator) backgroundRefresh(interval time.Duration) {
 	// Initial fetch
 	if err := v.FetchJWKS(); err != nil {
 		v.logger.Error("Initial JWKS fetch failed", zap.Error(err))
 	}
 
-	// Refresh every 30 minutes
-	ticker := time.NewTicker(30 * time.Minute)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -92,8 +136,16 @@ func (v *JWTValidator) Stop() {
 	close(v.stopRefresh)
 }
 
-// FetchJWKS fetches the JWKS from Cognito with retry logic
+// FetchJWKS fetches the JWKS from Cognito with retry logic and swaps it in. Keys missing
+// from the new set stop validating; if the fetch fails the current keys are kept.
 func (v *JWTValidator) FetchJWKS() error {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+	return v.fetchJWKSLocked()
+}
+
+// fetchJWKSLocked does the fetch for FetchJWKS; refreshMu must be held
+func (v *JWTValidator) fetchJWKSLocked() error {
 	v.logger.Info("Fetching JWKS", zap.String("url", v.jwksURL))
 
 	var jwks JWKS
@@ -121,10 +173,8 @@ func (v *JWTValidator) FetchJWKS() error {
 		return err
 	}
 
-	v.keysMu.Lock()
-	defer v.keysMu.Unlock()
-
 	// Convert JWKs to RSA public keys
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
 	for _, key := range jwks.Keys {
 		if key.Kty != "RSA" {
 			continue
@@ -138,11 +188,14 @@ func (v *JWTValidator) FetchJWKS() error {
 			continue
 		}
 
-		v.keys[key.Kid] = pubKey
+		keys[key.Kid] = pubKey
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS contained no usable RSA keys")
 	}
 
-	v.lastFetchTime = time.Now()
-	v.logger.Info("JWKS fetched successfully", zap.Int("key_count", len(v.keys)))
+	v.keys.Store(&jwksSnapshot{keys: keys, fetchedAt: time.Now()})
+	v.logger.Info("JWKS fetched successfully", zap.Int("key_count", len(keys)))
 
 	return nil
 }
@@ -176,37 +229,49 @@ func (v *JWTValidator) jwkToRSAPublicKey(jwk JWK) (*rsa.PublicKey, error) {
 
 // getPublicKey retrieves the public key for the given kid
 func (v *JWTValidator) getPublicKey(kid string) (*rsa.PublicKey, error) {
-	v.keysMu.RLock()
-	key, exists := v.keys[kid]
-	lastFetch := v.lastFetchTime
-	v.keysMu.RUnlock()
-
-	if exists {
+	if key, exists := v.keys.Load().keys[kid]; exists {
 		return key, nil
 	}
+	return v.refreshForKid(kid)
+}
 
-	// Key not found, try refreshing JWKS if it's been a while (fallback for key rotation)
-	// Background refresh should handle most cases, but this covers edge cases
-	if time.Since(lastFetch) > 1*time.Hour {
-		v.logger.Warn("Public key not found, forcing JWKS refresh", zap.String("kid", kid))
-		if err := v.FetchJWKS(); err != nil {
-			return nil, fmt.Errorf("failed to refresh JWKS: %w", err)
-		}
-
-		v.keysMu.RLock()
-		key, exists = v.keys[kid]
-		v.keysMu.RUnlock()
+// refreshForKid re-fetches JWKS for a kid we don't know, which is how a signing key rollover
+// shows up. Concurrent callers queue on refreshMu and find the key the first one fetched
+// instead of fetching again.
+func (v *JWTValidator) refreshForKid(kid string) (*rsa.PublicKey, error) {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
 
-		if exists {
-			return key, nil
-		}
+	snapshot := v.keys.Load()
+	if key, exists := snapshot.keys[kid]; exists {
+		return key, nil
+	}
+	if time.Since(snapshot.fetchedAt) < v.kidRefreshInterval || time.Since(v.lastKidRefresh) < v.kidRefreshInterval {
+		return nil, fmt.Errorf("public key not found for kid: %s", kid)
 	}
 
+	v.logger.Warn("Public key not found, forcing JWKS refresh", zap.String("kid", kid))
+	v.lastKidRefresh = time.Now()
+	if err := v.fetchJWKSLocked(); err != nil {
+		return nil, fmt.Errorf("failed to refresh JWKS: %w", err)
+	}
+	if key, exists := v.keys.Load().keys[kid]; exists {
+		return key, nil
+	}
 	return nil, fmt.Errorf("public key not found for kid: %s", kid)
 }
 
 // ValidateToken validates a JWT token and extracts claims
 func (v *JWTValidator) ValidateToken(tokenString string) (*domain.UserClaims, error) {
+	if v.staticClaims != nil {
+		if subtle.ConstantTimeCompare([]byte(tokenString), []byte(v.staticToken)) != 1 {
+			return nil, fmt.Errorf("invalid token: expected the local development token")
+		}
+		claims := *v.staticClaims
+		claims.Groups = append([]string(nil), v.staticClaims.Groups...)
+		return &claims, nil
+	}
+
 	// Parse token without validation first to get the kid
 	token, err := jwt.ParseWithClaims(tokenString, &domain.UserClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing algorithm
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testIssuer   = "https://cognito-idp.us-east-1.amazonaws.com/pool"
+	testClientID = "client"
+)
+
+// jwksServer serves whichever signing keys are currently published, counting fetches
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{keys: map[string]*rsa.PrivateKey{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var jwks JWKS
+		for kid, key := range s.keys {
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kid: kid,
+				Kty: "RSA",
+				Alg: "RS256",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// publish replaces the published key set
+func (s *jwksServer) publish(keys map[string]*rsa.PrivateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func signToken(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	claims := domain.UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testClientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Sub:      "user-" + kid,
+		TokenUse: "id",
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestValidateToken_KeyRotationMidTraffic(t *testing.T) {
+	oldKey, newKey := generateKey(t), generateKey(t)
+	server := newJWKSServer(t)
+	server.publish(map[string]*rsa.PrivateKey{"old": oldKey})
+
+	v := newJWTValidator(server.URL, testIssuer, testClientID, zap.NewNop())
+	v.kidRefreshInterval = 0
+	require.NoError(t, v.FetchJWKS())
+	require.EqualValues(t, 1, server.fetches.Load())
+
+	oldToken := signToken(t, "old", oldKey)
+	_, err := v.ValidateToken(oldToken)
+	require.NoError(t, err)
+
+	// Cognito starts signing with a new key while requests with old tokens keep coming
+	server.publish(map[string]*rsa.PrivateKey{"old": oldKey, "new": newKey})
+	newToken := signToken(t, "new", newKey)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 40)
+	for i := 0; i < 40; i++ {
+		token := newToken
+		if i%2 == 0 {
+			token = oldToken
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := v.ValidateToken(token)
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 2, server.fetches.Load(), "concurrent unknown-kid tokens share one refresh")
+
+	// Once the old key is retired, the next refresh drops it
+	server.publish(map[string]*rsa.PrivateKey{"new": newKey})
+	require.NoError(t, v.FetchJWKS())
+	_, err = v.ValidateToken(oldToken)
+	require.Error(t, err)
+	_, err = v.ValidateToken(newToken)
+	require.NoError(t, err)
+}
+
+func TestValidateToken_UnknownKidRefreshIsRateLimited(t *testing.T) {
+	key := generateKey(t)
+	server := newJWKSServer(t)
+	server.publish(map[string]*rsa.PrivateKey{"current": key})
+
+	v := newJWTValidator(server.URL, testIssuer, testClientID, zap.NewNop())
+	require.NoError(t, v.FetchJWKS())
+
+	// The keys were just fetched, so a kid nobody published doesn't trigger another fetch
+	for i := 0; i < 5; i++ {
+		_, err := v.ValidateToken(signToken(t, "forged", generateKey(t)))
+		require.Error(t, err)
+	}
+	require.EqualValues(t, 1, server.fetches.Load())
+
+	// A failed refresh keeps the keys we have
+	server.publish(map[string]*rsa.PrivateKey{})
+	require.Error(t, v.FetchJWKS())
+	_, err := v.ValidateToken(signToken(t, "current", key))
+	require.NoError(t, err)
+}
@@ -191,3 +191,35 @@ func RequireSubscriptionTier(minTier string, logger *zap.Logger) gin.HandlerFunc
 		c.Next()
 	}
 }
+
+// RequireGroup creates a middleware that requires membership of a Cognito group
+func RequireGroup(group string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetUserClaims(c)
+		if !ok {
+			logger.Warn("Group check failed: user not authenticated")
+			c.JSON(http.StatusUnauthorized, errors.NewAPIError(
+				errors.ErrUnauthorized,
+				"Authentication required",
+				nil,
+			))
+			c.Abort()
+			return
+		}
+
+		if !claims.HasGroup(group) {
+			logger.Warn("User not in required group",
+				zap.String("user_id", claims.Sub),
+				zap.String("required_group", group))
+			c.JSON(http.StatusForbidden, errors.NewAPIError(
+				errors.ErrForbidden,
+				"This endpoint requires additional permissions",
+				nil,
+			))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
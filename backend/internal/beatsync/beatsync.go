@@ -0,0 +1,143 @@
+// Package beatsync finds beats in decoded music and works out how far to shift the track so a
+// beat lands on a moment of the video. Detection is a peak-energy heuristic: the track is cut
+// into 20ms frames, and an onset is a frame whose energy jumps well above the 100ms before it.
+// That reliably finds drum hits and drops, which are what a script's "beat" sync points ask
+// for, without a spectral onset detector; pads, steady tones and noise have no such jumps.
+package beatsync
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	// frameSeconds is the length of each energy frame; frames overlap by half
+	frameSeconds = 0.02
+
+	// windowSeconds is both the background an onset is measured against and the shortest gap
+	// between onsets, so a rise spread over neighbouring frames is one beat
+	windowSeconds = 0.1
+
+	// onsetThreshold is the rise in dB over the background a frame needs to count as an onset
+	onsetThreshold = 3.0
+
+	// MinConfidence is the rise in dB the aligned onset needs before the track is moved, a
+	// fourfold jump in energy
+	MinConfidence = 6.0
+
+	// Tolerance is how close a beat must be to its target to count as aligned already
+	Tolerance = 0.15
+)
+
+// Onset is a sudden rise in energy, where a beat lands
+type Onset struct {
+	Time     float64 // Seconds from the start of the samples
+	Strength float64 // The rise in dB over the preceding 100ms
+}
+
+// DetectOnsets returns the onsets in mono PCM samples, in time order. It's a pure function of
+// its input; samples are expected in [-1, 1] but the level doesn't matter.
+func DetectOnsets(samples []float32, sampleRate int) []Onset {
+	frame := int(float64(sampleRate) * frameSeconds)
+	if frame < 2 || len(samples) < 2*frame {
+		return nil
+	}
+	hop := frame / 2
+
+	var total float64
+	energies := make([]float64, 0, len(samples)/hop)
+	for start := 0; start+frame <= len(samples); start += hop {
+		var sum float64
+		for _, s := range samples[start : start+frame] {
+			sum += float64(s) * float64(s)
+		}
+		energies = append(energies, sum/float64(frame))
+		total += sum / float64(frame)
+	}
+	if total == 0 {
+		return nil
+	}
+	// A floor 30dB under the track's average keeps a hit out of near-silence from rating as
+	// an infinite rise, and makes hits out of silence compare by their own level
+	floor := total / float64(len(energies)) / 1000
+
+	window := int(math.Ceil(windowSeconds / (float64(hop) / float64(sampleRate))))
+	rises := make([]float64, len(energies))
+	for i := 1; i < len(energies); i++ {
+		var background float64
+		from := max(0, i-window)
+		for _, e := range energies[from:i] {
+			background += e
+		}
+		background /= float64(i - from)
+		rises[i] = 10 * math.Log10((energies[i]+floor)/(background+floor))
+	}
+
+	var onsets []Onset
+	for i := 1; i < len(rises); i++ {
+		if rises[i] < onsetThreshold || !isPeak(rises, i, window) {
+			continue
+		}
+		onsets = append(onsets, Onset{
+			// The frame that rises is the one the hit starts in; its middle is the best estimate
+			Time:     (float64(i*hop) + float64(frame)/2) / float64(sampleRate),
+			Strength: rises[i],
+		})
+	}
+	return onsets
+}
+
+// Alignment is how to shift a track so one of its beats lands on a target time
+type Alignment struct {
+	Beat   Onset
+	Target float64
+	Offset float64 // Seconds to delay the track by; negative trims that much of the intro
+}
+
+// Align picks the strongest onset within maxShift seconds of target and returns the shift that
+// puts it on target. Of onsets about as strong as each other (a steady beat), the one nearest
+// target wins, so the track moves as little as possible. ok is false when no onset is strong
+// enough to trust; an Alignment within Tolerance has an Offset of zero.
+func Align(onsets []Onset, target, maxShift float64) (Alignment, bool) {
+	var candidates []Onset
+	for _, onset := range onsets {
+		if math.Abs(onset.Time-target) <= maxShift {
+			candidates = append(candidates, onset)
+		}
+	}
+	if len(candidates) == 0 {
+		return Alignment{}, false
+	}
+
+	strongest := slices.MaxFunc(candidates, func(a, b Onset) int {
+		return cmp.Compare(a.Strength, b.Strength)
+	})
+	if strongest.Strength < MinConfidence {
+		return Alignment{}, false
+	}
+
+	// Hits within 10% of the strongest are the same beat as far as a listener can tell
+	beat := strongest
+	for _, onset := range candidates {
+		if onset.Strength >= 0.9*strongest.Strength && math.Abs(onset.Time-target) < math.Abs(beat.Time-target) {
+			beat = onset
+		}
+	}
+
+	alignment := Alignment{Beat: beat, Target: target, Offset: target - beat.Time}
+	if math.Abs(alignment.Offset) <= Tolerance {
+		alignment.Offset = 0
+	}
+	return alignment, true
+}
+
+// isPeak reports whether values[i] is the largest within gap frames either side
+func isPeak(values []float64, i, gap int) bool {
+	for j := max(0, i-gap); j <= min(len(values)-1, i+gap); j++ {
+		if values[j] > values[i] || (values[j] == values[i] && j < i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package beatsync
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const testRate = 22050
+
+// clickTrack renders seconds of audio with a 5ms decaying 1kHz click at each time, at the
+// matching gain
+func clickTrack(seconds float64, times, gains []float64) []float32 {
+	samples := make([]float32, int(seconds*testRate))
+	for i, at := range times {
+		start := int(at * testRate)
+		for n := 0; n < testRate/200 && start+n < len(samples); n++ {
+			t := float64(n) / testRate
+			samples[start+n] += float32(gains[i] * math.Exp(-t*800) * math.Sin(2*math.Pi*1000*t))
+		}
+	}
+	return samples
+}
+
+// metronome returns click times every interval seconds from first, all at gain 0.5
+func metronome(first, interval float64, count int) ([]float64, []float64) {
+	var times, gains []float64
+	for i := 0; i < count; i++ {
+		times = append(times, first+float64(i)*interval)
+		gains = append(gains, 0.5)
+	}
+	return times, gains
+}
+
+func TestDetectOnsets_ClickTrack(t *testing.T) {
+	times, gains := metronome(0.25, 0.5, 16) // 120 bpm
+	onsets := DetectOnsets(clickTrack(8.5, times, gains), testRate)
+
+	if len(onsets) != len(times) {
+		t.Fatalf("detected %d onsets, want %d: %+v", len(onsets), len(times), onsets)
+	}
+	for i, onset := range onsets {
+		if math.Abs(onset.Time-times[i]) > 0.02 {
+			t.Errorf("onset %d at %.3fs, want %.3fs", i, onset.Time, times[i])
+		}
+		if onset.Strength < MinConfidence {
+			t.Errorf("onset %d strength %.1f is below MinConfidence", i, onset.Strength)
+		}
+	}
+}
+
+func TestDetectOnsets_ClicksOverAPad(t *testing.T) {
+	times, gains := metronome(0.5, 0.75, 8)
+	samples := clickTrack(6.5, times, gains)
+	for i := range samples {
+		samples[i] += float32(0.03 * math.Sin(2*math.Pi*110*float64(i)/testRate))
+	}
+
+	onsets := DetectOnsets(samples, testRate)
+	if len(onsets) != len(times) {
+		t.Fatalf("detected %d onsets, want %d: %+v", len(onsets), len(times), onsets)
+	}
+	if alignment, ok := Align(onsets, 2.6, 3); !ok || math.Abs(alignment.Beat.Time-2.75) > 0.02 {
+		t.Errorf("Align() = %+v, %v; want the click at 2.75s", alignment, ok)
+	}
+}
+
+func TestDetectOnsets_AccentIsStrongest(t *testing.T) {
+	times, gains := metronome(0.25, 0.5, 12)
+	gains[5] = 1.0 // The drop, at 2.75s
+	onsets := DetectOnsets(clickTrack(6.5, times, gains), testRate)
+
+	strongest := onsets[0]
+	for _, onset := range onsets {
+		if onset.Strength > strongest.Strength {
+			strongest = onset
+		}
+	}
+	if math.Abs(strongest.Time-2.75) > 0.02 {
+		t.Errorf("strongest onset at %.3fs, want the accent at 2.75s", strongest.Time)
+	}
+}
+
+func TestDetectOnsets_NothingToFind(t *testing.T) {
+	tone := make([]float32, 5*testRate)
+	for i := range tone {
+		tone[i] = float32(0.3 * math.Sin(2*math.Pi*220*float64(i)/testRate))
+	}
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]float32, 5*testRate)
+	for i := range noise {
+		noise[i] = float32(0.2 * (rng.Float64()*2 - 1))
+	}
+
+	for name, samples := range map[string][]float32{
+		"silence":   make([]float32, 5*testRate),
+		"tone":      tone,
+		"noise":     noise,
+		"too short": make([]float32, 10),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := Align(DetectOnsets(samples, testRate), 2, 3); ok {
+				t.Error("Align() trusted a beat in audio without any")
+			}
+		})
+	}
+}
+
+func TestAlign(t *testing.T) {
+	accented := func(accent int) []Onset {
+		var onsets []Onset
+		for i := 0; i < 8; i++ {
+			strength := 6.0
+			if i == accent {
+				strength = 12
+			}
+			onsets = append(onsets, Onset{Time: 0.25 + 0.5*float64(i), Strength: strength})
+		}
+		return onsets
+	}
+
+	tests := []struct {
+		name       string
+		onsets     []Onset
+		target     float64
+		wantBeat   float64
+		wantOffset float64
+		wantOK     bool
+	}{
+		{name: "drop after the target trims the intro", onsets: accented(5), target: 2, wantBeat: 2.75, wantOffset: -0.75, wantOK: true},
+		{name: "drop before the target delays the track", onsets: accented(1), target: 2, wantBeat: 0.75, wantOffset: 1.25, wantOK: true},
+		{name: "already within tolerance", onsets: accented(4), target: 2.3, wantBeat: 2.25, wantOffset: 0, wantOK: true},
+		{name: "steady beat moves to the nearest hit", onsets: accented(-1), target: 2.05, wantBeat: 2.25, wantOffset: -0.2, wantOK: true},
+		{name: "drop out of reach", onsets: accented(7), target: 0.5, wantBeat: 0.25, wantOffset: 0.25, wantOK: true},
+		{name: "no onsets near the target", onsets: accented(0), target: 20},
+		{name: "weak onsets", onsets: []Onset{{Time: 1, Strength: 3.5}, {Time: 2, Strength: 5}}, target: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Align(tt.onsets, tt.target, 3)
+			if ok != tt.wantOK {
+				t.Fatalf("Align() ok = %v, want %v (%+v)", ok, tt.wantOK, got)
+			}
+			if !ok {
+				return
+			}
+			if got.Beat.Time != tt.wantBeat || math.Abs(got.Offset-tt.wantOffset) > 1e-9 {
+				t.Errorf("Align() = beat %.2fs offset %.2fs, want beat %.2fs offset %.2fs", got.Beat.Time, got.Offset, tt.wantBeat, tt.wantOffset)
+			}
+		})
+	}
+}
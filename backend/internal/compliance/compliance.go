@@ -0,0 +1,222 @@
+// Package compliance checks generated pharmaceutical ad scripts against the disclosure and
+// fair-balance rules the system prompt asks GPT-4o to follow, since the prompt alone doesn't
+// guarantee them.
+package compliance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// Rules a script can break
+const (
+	RuleSideEffectsVerbatim = "side_effects_verbatim" // Disclosure differs from the user's text
+	RuleSideEffectsTiming   = "side_effects_timing"   // Disclosure starts outside the allowed window
+	RuleRequiredPhrase      = "required_phrase"       // Narration lacks a required phrase
+	RuleBannedClaim         = "banned_claim"          // A scene or the narration makes a banned efficacy claim
+	RuleFinalScene          = "final_scene"           // The last scene doesn't show the product or call to action
+)
+
+// The side effects disclosure must start within this fraction of the video
+const (
+	MinSideEffectsStart = 0.75
+	MaxSideEffectsStart = 0.85
+)
+
+// DefaultRequiredPhrases must appear in every pharmaceutical narration
+var DefaultRequiredPhrases = []string{"ask your doctor"}
+
+// DefaultBannedClaims are efficacy claims FDA reviewers have flagged
+var DefaultBannedClaims = []string{
+	`\binstant(ly)?\s+(relief|cure|results?)\b`,
+	`\bcures?\b`,
+	`\bguaranteed?\b`,
+	`\b100%\s+(effective|safe)\b`,
+	`\bno\s+side\s+effects\b`,
+	`\bmiracle\b`,
+	`\bpermanent(ly)?\s+(cure|relief|fix)\b`,
+	`\brisk[\s-]free\b`,
+}
+
+// finalSceneWords show the last scene features the product even when it isn't named
+var finalSceneWords = []string{"product", "logo", "packaging", "call to action"}
+
+// Config sets the rules a Checker enforces
+type Config struct {
+	Strict          bool     // Fail jobs with issues; otherwise they are stored on the job as warnings
+	RequiredPhrases []string // Case-insensitive; empty uses DefaultRequiredPhrases
+	BannedClaims    []string // Case-insensitive regular expressions; empty uses DefaultBannedClaims
+}
+
+// Checker runs the compliance rules on generated scripts
+type Checker struct {
+	strict   bool
+	required []string
+	banned   []*regexp.Regexp
+}
+
+// NewChecker compiles cfg's rules; a banned claim that isn't a valid regular expression is an error
+func NewChecker(cfg Config) (*Checker, error) {
+	required := cfg.RequiredPhrases
+	if len(required) == 0 {
+		required = DefaultRequiredPhrases
+	}
+	patterns := cfg.BannedClaims
+	if len(patterns) == 0 {
+		patterns = DefaultBannedClaims
+	}
+
+	c := &Checker{strict: cfg.Strict}
+	for _, phrase := range required {
+		if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+			c.required = append(c.required, phrase)
+		}
+	}
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid banned claim pattern %q: %w", pattern, err)
+		}
+		c.banned = append(c.banned, re)
+	}
+	return c, nil
+}
+
+// Strict reports whether jobs with issues should fail
+func (c *Checker) Strict() bool {
+	return c.strict
+}
+
+// IsPharmaceutical reports whether a job gets the compliance checks: it has a narrator voice
+// and a side effects disclosure
+func IsPharmaceutical(voice, sideEffects string) bool {
+	return voice != "" && strings.TrimSpace(sideEffects) != ""
+}
+
+// Input is a generated script and the request it was written for
+type Input struct {
+	Script       *domain.Script
+	SideEffects  string  // Disclosure as the user entered it
+	Duration     float64 // Video length in seconds; 0 uses the script's total duration
+	CallToAction string  // Requested call to action, used when the script doesn't have one
+}
+
+// CheckScript returns every rule the script breaks. Narration is checked only if the script
+// has a narrator script; two-pass narration is written later and checked with CheckNarration.
+func (c *Checker) CheckScript(in Input) []domain.ComplianceIssue {
+	script := in.Script
+	var issues []domain.ComplianceIssue
+	add := func(rule string, scene int, format string, args ...interface{}) {
+		issues = append(issues, domain.ComplianceIssue{Rule: rule, SceneNumber: scene, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(script.AudioSpec.SideEffectsText) != strings.TrimSpace(in.SideEffects) {
+		add(RuleSideEffectsVerbatim, 0, "side_effects_text must match the provided side effects verbatim")
+	}
+
+	duration := in.Duration
+	if duration <= 0 {
+		duration = float64(script.TotalDuration)
+	}
+	if start := script.AudioSpec.SideEffectsStartTime; duration > 0 {
+		if fraction := start / duration; fraction < MinSideEffectsStart || fraction > MaxSideEffectsStart {
+			add(RuleSideEffectsTiming, 0, "side_effects_start_time %.1fs is %.0f%% into a %.0fs video, expected %.0f-%.0f%%",
+				start, fraction*100, duration, MinSideEffectsStart*100, MaxSideEffectsStart*100)
+		}
+	}
+
+	for _, scene := range script.Scenes {
+		for _, text := range []string{scene.Action, scene.GenerationPrompt} {
+			if claim := c.bannedClaim(text); claim != "" {
+				add(RuleBannedClaim, scene.SceneNumber, "scene %d makes a banned claim: %q", scene.SceneNumber, claim)
+				break
+			}
+		}
+	}
+
+	if len(script.Scenes) > 0 {
+		last := script.Scenes[len(script.Scenes)-1]
+		cta := strings.TrimSpace(script.Metadata.CallToAction)
+		if cta == "" {
+			cta = strings.TrimSpace(in.CallToAction)
+		}
+		if !featuresProduct(last.Action+" "+last.GenerationPrompt, script.Metadata.ProductName, cta) {
+			add(RuleFinalScene, last.SceneNumber, "final scene %d must feature the product or call to action", last.SceneNumber)
+		}
+	}
+
+	if script.AudioSpec.NarratorScript != "" {
+		issues = append(issues, c.CheckNarration(script.AudioSpec.NarratorScript)...)
+	}
+	return issues
+}
+
+// CheckNarration returns the rules a narration breaks: missing required phrases and banned claims
+func (c *Checker) CheckNarration(narration string) []domain.ComplianceIssue {
+	var issues []domain.ComplianceIssue
+	lowered := strings.ToLower(strings.Join(strings.Fields(narration), " "))
+	for _, phrase := range c.required {
+		if !strings.Contains(lowered, phrase) {
+			issues = append(issues, domain.ComplianceIssue{
+				Rule:    RuleRequiredPhrase,
+				Message: fmt.Sprintf("narration must include %q", phrase),
+			})
+		}
+	}
+	if claim := c.bannedClaim(narration); claim != "" {
+		issues = append(issues, domain.ComplianceIssue{
+			Rule:    RuleBannedClaim,
+			Message: fmt.Sprintf("narration makes a banned claim: %q", claim),
+		})
+	}
+	return issues
+}
+
+// bannedClaim returns the first banned claim text makes, or ""
+func (c *Checker) bannedClaim(text string) string {
+	for _, re := range c.banned {
+		if match := re.FindString(text); match != "" {
+			return match
+		}
+	}
+	return ""
+}
+
+// featuresProduct reports whether scene text names the product (or one of the distinctive
+// words of its name), includes the call to action, or otherwise refers to the product
+func featuresProduct(text, productName, cta string) bool {
+	lowered := strings.ToLower(text)
+	mentions := append([]string(nil), finalSceneWords...)
+	if name := strings.ToLower(strings.TrimSpace(productName)); name != "" {
+		mentions = append(mentions, name)
+		for _, word := range strings.Fields(name) {
+			if len(word) >= 4 {
+				mentions = append(mentions, word)
+			}
+		}
+	}
+	if cta != "" {
+		mentions = append(mentions, strings.ToLower(cta))
+	}
+	for _, mention := range mentions {
+		if strings.Contains(lowered, mention) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary describes issues in one line, for a failed job's error
+func Summary(issues []domain.ComplianceIssue) string {
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = issue.Message
+	}
+	return fmt.Sprintf("%d compliance check(s) failed: %s", len(issues), strings.Join(messages, "; "))
+}
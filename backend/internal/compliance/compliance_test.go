@@ -0,0 +1,357 @@
+package compliance
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+const sideEffects = "May cause dizziness, nausea and headache. Do not take if pregnant."
+
+func compliantScript() *domain.Script {
+	return &domain.Script{
+		TotalDuration: 30,
+		Scenes: []domain.Scene{
+			{SceneNumber: 1, Action: "A woman wakes up rested", GenerationPrompt: "Sunlit bedroom, woman stretching after a full night's sleep"},
+			{SceneNumber: 2, Action: "She walks her dog in the park", GenerationPrompt: "Morning park, golden hour, woman walking a golden retriever"},
+			{SceneNumber: 3, Action: "The Restura box on the kitchen counter", GenerationPrompt: "Close-up of the Restura packaging on a marble counter, soft light"},
+		},
+		AudioSpec: domain.AudioSpec{
+			NarratorScript:       "Sleep through the night with Restura. Ask your doctor if Restura is right for you.",
+			SideEffectsText:      sideEffects,
+			SideEffectsStartTime: 24,
+		},
+		Metadata: domain.Metadata{ProductName: "Restura", CallToAction: "Talk to your doctor today"},
+	}
+}
+
+func rules(issues []domain.ComplianceIssue) []string {
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.Rule)
+	}
+	return got
+}
+
+func TestNewChecker(t *testing.T) {
+	c, err := NewChecker(Config{})
+	if err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	if c.Strict() {
+		t.Error("default config should warn, not fail")
+	}
+	if len(c.required) != len(DefaultRequiredPhrases) || len(c.banned) != len(DefaultBannedClaims) {
+		t.Errorf("default config should use the default rules, got %d phrases and %d claims", len(c.required), len(c.banned))
+	}
+
+	c, err = NewChecker(Config{Strict: true, RequiredPhrases: []string{" Ask Your Doctor ", ""}, BannedClaims: []string{`\bforever\b`, " "}})
+	if err != nil {
+		t.Fatalf("custom config: %v", err)
+	}
+	if !c.Strict() || !reflect.DeepEqual(c.required, []string{"ask your doctor"}) || len(c.banned) != 1 {
+		t.Errorf("custom config not applied: strict=%v required=%v banned=%d", c.Strict(), c.required, len(c.banned))
+	}
+
+	if _, err := NewChecker(Config{BannedClaims: []string{`(unclosed`}}); err == nil {
+		t.Error("an invalid pattern should be an error")
+	}
+}
+
+func TestIsPharmaceutical(t *testing.T) {
+	tests := []struct {
+		voice, sideEffects string
+		want               bool
+	}{
+		{"female", sideEffects, true},
+		{"", sideEffects, false},
+		{"male", "", false},
+		{"male", "   ", false},
+	}
+	for _, tt := range tests {
+		if got := IsPharmaceutical(tt.voice, tt.sideEffects); got != tt.want {
+			t.Errorf("IsPharmaceutical(%q, %q) = %v, want %v", tt.voice, tt.sideEffects, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(*Input)
+		want     []string
+		scene    int    // Scene number of the first issue
+		contains string // Substring of the first issue's message
+	}{
+		{name: "compliant script", mutate: func(in *Input) {}},
+		{
+			name:   "surrounding whitespace is not a change",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsText = "  " + sideEffects + "\n" },
+		},
+		{
+			name:     "paraphrased side effects",
+			mutate:   func(in *Input) { in.Script.AudioSpec.SideEffectsText = "May cause dizziness or nausea." },
+			want:     []string{RuleSideEffectsVerbatim},
+			contains: "verbatim",
+		},
+		{
+			name:   "shortened side effects",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsText = "May cause dizziness, nausea and headache." },
+			want:   []string{RuleSideEffectsVerbatim},
+		},
+		{
+			name:   "missing side effects",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsText = "" },
+			want:   []string{RuleSideEffectsVerbatim},
+		},
+		{
+			name:   "disclosure at 75% is allowed",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsStartTime = 22.5 },
+		},
+		{
+			name:   "disclosure at 85% is allowed",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsStartTime = 25.5 },
+		},
+		{
+			name:     "disclosure too early",
+			mutate:   func(in *Input) { in.Script.AudioSpec.SideEffectsStartTime = 15 },
+			want:     []string{RuleSideEffectsTiming},
+			contains: "50% into a 30s video",
+		},
+		{
+			name:   "disclosure too late",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsStartTime = 27 },
+			want:   []string{RuleSideEffectsTiming},
+		},
+		{
+			name:   "disclosure missing its start time",
+			mutate: func(in *Input) { in.Script.AudioSpec.SideEffectsStartTime = 0 },
+			want:   []string{RuleSideEffectsTiming},
+		},
+		{
+			name: "timing uses the requested duration",
+			mutate: func(in *Input) {
+				in.Duration = 60
+				in.Script.AudioSpec.SideEffectsStartTime = 48
+			},
+		},
+		{
+			name: "timing falls back to the script duration",
+			mutate: func(in *Input) {
+				in.Duration = 0
+				in.Script.TotalDuration = 20
+				in.Script.AudioSpec.SideEffectsStartTime = 16
+			},
+		},
+		{
+			name:     "narration without the required phrase",
+			mutate:   func(in *Input) { in.Script.AudioSpec.NarratorScript = "Sleep through the night with Restura." },
+			want:     []string{RuleRequiredPhrase},
+			contains: `"ask your doctor"`,
+		},
+		{
+			name:   "required phrase in any case across a line break",
+			mutate: func(in *Input) { in.Script.AudioSpec.NarratorScript = "Restura. ASK YOUR\nDOCTOR today." },
+		},
+		{
+			name:   "two-pass narration is checked later",
+			mutate: func(in *Input) { in.Script.AudioSpec.NarratorScript = "" },
+		},
+		{
+			name:     "banned claim in an action",
+			mutate:   func(in *Input) { in.Script.Scenes[0].Action = "She feels INSTANT RELIEF after one pill" },
+			want:     []string{RuleBannedClaim},
+			scene:    1,
+			contains: `"INSTANT RELIEF"`,
+		},
+		{
+			name: "banned claim in a prompt",
+			mutate: func(in *Input) {
+				in.Script.Scenes[1].GenerationPrompt = "Text overlay: guaranteed to work, woman smiling"
+			},
+			want:  []string{RuleBannedClaim},
+			scene: 2,
+		},
+		{
+			name: "one issue per scene",
+			mutate: func(in *Input) {
+				in.Script.Scenes[0].Action = "A miracle cure"
+				in.Script.Scenes[0].GenerationPrompt = "100% effective, risk-free"
+			},
+			want:  []string{RuleBannedClaim},
+			scene: 1,
+		},
+		{
+			name:   "banned claim in the narration",
+			mutate: func(in *Input) { in.Script.AudioSpec.NarratorScript += " No side effects, ever." },
+			want:   []string{RuleBannedClaim},
+		},
+		{
+			name:   "claims inside other words are allowed",
+			mutate: func(in *Input) { in.Script.Scenes[0].Action = "A secure, curated bedroom" },
+		},
+		{
+			name: "final scene without the product",
+			mutate: func(in *Input) {
+				in.Script.Scenes[2].Action = "She smiles at the sunset"
+				in.Script.Scenes[2].GenerationPrompt = "Beach at sunset, woman smiling, warm tones"
+			},
+			want:     []string{RuleFinalScene},
+			scene:    3,
+			contains: "final scene 3",
+		},
+		{
+			name: "final scene with the call to action",
+			mutate: func(in *Input) {
+				in.Script.Scenes[2].Action = "On-screen text: talk to your doctor today"
+				in.Script.Scenes[2].GenerationPrompt = "Clean white title card"
+			},
+		},
+		{
+			name: "final scene with the requested call to action",
+			mutate: func(in *Input) {
+				in.Script.Metadata.CallToAction = ""
+				in.CallToAction = "Visit restura.com"
+				in.Script.Scenes[2].Action = "End card: visit Restura.com"
+				in.Script.Scenes[2].GenerationPrompt = "Clean white title card"
+			},
+		},
+		{
+			name: "final scene naming part of the product",
+			mutate: func(in *Input) {
+				in.Script.Metadata.ProductName = "Restura Nightly"
+				in.Script.Scenes[2].Action = "A nightly routine by the bed"
+				in.Script.Scenes[2].GenerationPrompt = "Bedside table, warm lamp"
+			},
+		},
+		{
+			name: "final scene showing the logo",
+			mutate: func(in *Input) {
+				in.Script.Scenes[2].Action = "Brand logo on a soft gradient"
+				in.Script.Scenes[2].GenerationPrompt = "Soft gradient background"
+			},
+		},
+		{
+			name: "every broken rule is reported",
+			mutate: func(in *Input) {
+				in.Script.AudioSpec.SideEffectsText = "Mild side effects."
+				in.Script.AudioSpec.SideEffectsStartTime = 5
+				in.Script.AudioSpec.NarratorScript = "Restura cures insomnia."
+				in.Script.Scenes[2].Action = "Sunset"
+				in.Script.Scenes[2].GenerationPrompt = "Sunset over the sea"
+			},
+			want: []string{RuleSideEffectsVerbatim, RuleSideEffectsTiming, RuleFinalScene, RuleRequiredPhrase, RuleBannedClaim},
+		},
+	}
+
+	c, err := NewChecker(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := Input{Script: compliantScript(), SideEffects: sideEffects, Duration: 30}
+			tt.mutate(&in)
+
+			issues := c.CheckScript(in)
+			if got := rules(issues); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("rules = %v, want %v (%+v)", got, tt.want, issues)
+			}
+			if len(issues) == 0 {
+				return
+			}
+			if issues[0].SceneNumber != tt.scene {
+				t.Errorf("scene = %d, want %d", issues[0].SceneNumber, tt.scene)
+			}
+			if tt.contains != "" && !strings.Contains(issues[0].Message, tt.contains) {
+				t.Errorf("message %q should contain %q", issues[0].Message, tt.contains)
+			}
+		})
+	}
+}
+
+func TestCheckNarration(t *testing.T) {
+	c, err := NewChecker(Config{
+		RequiredPhrases: []string{"ask your doctor", "see full prescribing information"},
+		BannedClaims:    []string{`\bforever\b`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		narration string
+		want      []string
+	}{
+		{
+			name:      "compliant",
+			narration: "Ask your doctor about Restura. See full prescribing information.",
+		},
+		{
+			name:      "one phrase missing",
+			narration: "Ask your doctor about Restura.",
+			want:      []string{RuleRequiredPhrase},
+		},
+		{
+			name:      "both phrases missing",
+			narration: "Restura.",
+			want:      []string{RuleRequiredPhrase, RuleRequiredPhrase},
+		},
+		{
+			name:      "custom banned claim",
+			narration: "Ask your doctor. See full prescribing information. Sleep well Forever.",
+			want:      []string{RuleBannedClaim},
+		},
+		{
+			name:      "default claims are replaced",
+			narration: "Ask your doctor. See full prescribing information. A miracle.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(c.CheckNarration(tt.narration)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultBannedClaims(t *testing.T) {
+	c, err := NewChecker(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	banned := []string{
+		"instant relief", "Instantly cures", "cures headaches", "a cure", "guaranteed results",
+		"100% effective", "100% safe", "no side effects", "a miracle pill", "permanent relief", "risk-free", "risk free",
+	}
+	for _, text := range banned {
+		if c.bannedClaim(text) == "" {
+			t.Errorf("%q should be a banned claim", text)
+		}
+	}
+
+	allowed := []string{
+		"secure", "curated", "relief from symptoms", "effective for many adults", "side effects may include nausea", "safety information",
+	}
+	for _, text := range allowed {
+		if claim := c.bannedClaim(text); claim != "" {
+			t.Errorf("%q should be allowed, matched %q", text, claim)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	got := Summary([]domain.ComplianceIssue{
+		{Rule: RuleRequiredPhrase, Message: `narration must include "ask your doctor"`},
+		{Rule: RuleFinalScene, SceneNumber: 3, Message: "final scene 3 must feature the product or call to action"},
+	})
+	want := `2 compliance check(s) failed: narration must include "ask your doctor"; final scene 3 must feature the product or call to action`
+	if got != want {
+		t.Errorf("Summary = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"go.uber.org/zap"
+)
+
+// Final mix levels: music is ducked to a static ratio so narration stays intelligible
+const (
+	musicMixVolume     = 0.3
+	voiceoverMixVolume = 1.0
+)
+
+// audioMix describes the audio tracks muxed into the final video.
+// An empty path means the track is unavailable.
+type audioMix struct {
+	MusicPath       string
+	VoiceoverPath   string
+	VoiceoverOffset float64 // seconds to delay the voiceover from the start of the video
+}
+
+// buildAudioMixArgs returns the ffmpeg arguments that mux the available tracks into videoPath,
+// or nil when there is no audio. Audio is padded with silence so -shortest always ends at the
+// video's length rather than cutting the video when a track is short.
+func buildAudioMixArgs(videoPath, outputPath string, mix audioMix) []string {
+	if mix.MusicPath == "" && mix.VoiceoverPath == "" {
+		return nil
+	}
+
+	args := []string{"-i", videoPath}
+	var filter string
+
+	voiceoverFilter := func(input int) string {
+		if mix.VoiceoverOffset > 0 {
+			ms := int(math.Round(mix.VoiceoverOffset * 1000))
+			return fmt.Sprintf("[%d:a]adelay=delays=%d:all=1,volume=%.1f", input, ms, voiceoverMixVolume)
+		}
+		return fmt.Sprintf("[%d:a]volume=%.1f", input, voiceoverMixVolume)
+	}
+
+	switch {
+	case mix.MusicPath != "" && mix.VoiceoverPath != "":
+		args = append(args, "-i", mix.MusicPath, "-i", mix.VoiceoverPath)
+		filter = fmt.Sprintf("[1:a]volume=%.1f[music];%s[voiceover];[music][voiceover]amix=inputs=2:duration=longest,apad[audio]",
+			musicMixVolume, voiceoverFilter(2))
+	case mix.VoiceoverPath != "":
+		args = append(args, "-i", mix.VoiceoverPath)
+		filter = voiceoverFilter(1) + ",apad[audio]"
+	default:
+		args = append(args, "-i", mix.MusicPath)
+		filter = fmt.Sprintf("[1:a]volume=%.1f,apad[audio]", musicMixVolume)
+	}
+
+	return append(args,
+		"-filter_complex", filter,
+		"-map", "0:v",
+		"-map", "[audio]",
+		"-c:v", "copy",
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-shortest",
+		"-y", outputPath,
+	)
+}
+
+// muxJobAudio downloads the job's music and narrator tracks and mixes them into videoPath.
+// Each track degrades independently: a failed download drops only that track, and a failed
+// mux returns videoPath unchanged so the video is still delivered.
+func muxJobAudio(
+	ctx context.Context,
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	logger *zap.Logger,
+	job *domain.Job,
+	videoPath string,
+	tmpDir string,
+) string {
+	download := func(s3URL, name, label string) string {
+		if s3URL == "" {
+			return ""
+		}
+		path := filepath.Join(tmpDir, name)
+		if err := s3Service.DownloadFile(ctx, assetsBucket, s3util.Key(s3URL), path); err != nil {
+			logger.Warn("Failed to download "+label+", continuing without it",
+				zap.Error(err),
+			)
+			return ""
+		}
+		return path
+	}
+
+	mix := audioMix{
+		MusicPath:     download(job.AudioURL, "background-music.mp3", "background music"),
+		VoiceoverPath: download(job.NarratorAudioURL, "narrator-voiceover.mp3", "narrator audio"),
+	}
+
+	// The downloaded tracks are only needed for this mux
+	defer func() {
+		for _, path := range []string{mix.MusicPath, mix.VoiceoverPath} {
+			if path != "" {
+				os.Remove(path)
+			}
+		}
+	}()
+
+	outputPath := filepath.Join(tmpDir, "video_with_audio.mp4")
+	args := buildAudioMixArgs(videoPath, outputPath, mix)
+	if args == nil {
+		return videoPath
+	}
+
+	logger.Info("Muxing audio into video",
+		zap.Bool("has_music", mix.MusicPath != ""),
+		zap.Bool("has_narrator", mix.VoiceoverPath != ""),
+	)
+
+	if err := ffmpegexec.Exec(ctx, "mux_audio", ffmpegexec.Spec{Args: args, Timeout: ffmpegexec.EncodeTimeout, LowPriority: true}); err != nil {
+		logger.Warn("Audio muxing failed, continuing with video-only output",
+			zap.String("output", ffmpegexec.Stderr(err)),
+			zap.Error(err),
+		)
+		return videoPath
+	}
+
+	logger.Info("Audio muxing complete")
+	return outputPath
+}
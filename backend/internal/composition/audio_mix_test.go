@@ -0,0 +1,87 @@
+package composition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAudioMixArgs(t *testing.T) {
+	outputArgs := []string{
+		"-map", "0:v",
+		"-map", "[audio]",
+		"-c:v", "copy",
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-shortest",
+		"-y", "out.mp4",
+	}
+	withOutput := func(args ...string) []string {
+		return append(args, outputArgs...)
+	}
+
+	tests := []struct {
+		name string
+		mix  audioMix
+		want []string
+	}{
+		{
+			name: "no audio",
+			mix:  audioMix{},
+			want: nil,
+		},
+		{
+			name: "music only",
+			mix:  audioMix{MusicPath: "music.mp3"},
+			want: withOutput(
+				"-i", "video.mp4",
+				"-i", "music.mp3",
+				"-filter_complex", "[1:a]volume=0.3,apad[audio]",
+			),
+		},
+		{
+			name: "voiceover only",
+			mix:  audioMix{VoiceoverPath: "narrator.mp3"},
+			want: withOutput(
+				"-i", "video.mp4",
+				"-i", "narrator.mp3",
+				"-filter_complex", "[1:a]volume=1.0,apad[audio]",
+			),
+		},
+		{
+			name: "music and voiceover",
+			mix:  audioMix{MusicPath: "music.mp3", VoiceoverPath: "narrator.mp3"},
+			want: withOutput(
+				"-i", "video.mp4",
+				"-i", "music.mp3",
+				"-i", "narrator.mp3",
+				"-filter_complex", "[1:a]volume=0.3[music];[2:a]volume=1.0[voiceover];[music][voiceover]amix=inputs=2:duration=longest,apad[audio]",
+			),
+		},
+		{
+			name: "music and offset voiceover",
+			mix:  audioMix{MusicPath: "music.mp3", VoiceoverPath: "narrator.mp3", VoiceoverOffset: 1.25},
+			want: withOutput(
+				"-i", "video.mp4",
+				"-i", "music.mp3",
+				"-i", "narrator.mp3",
+				"-filter_complex", "[1:a]volume=0.3[music];[2:a]adelay=delays=1250:all=1,volume=1.0[voiceover];[music][voiceover]amix=inputs=2:duration=longest,apad[audio]",
+			),
+		},
+		{
+			name: "offset voiceover only",
+			mix:  audioMix{VoiceoverPath: "narrator.mp3", VoiceoverOffset: 0.5},
+			want: withOutput(
+				"-i", "video.mp4",
+				"-i", "narrator.mp3",
+				"-filter_complex", "[1:a]adelay=delays=500:all=1,volume=1.0,apad[audio]",
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, buildAudioMixArgs("video.mp4", "out.mp4", tt.mix))
+		})
+	}
+}
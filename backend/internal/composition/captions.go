@@ -0,0 +1,72 @@
+package composition
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	CaptionMaxLineChars = 42 // Broadcast caption line length
+	CaptionMaxLines     = 2  // Lines per cue
+
+	captionFontSize  = 40.0 // Pixels at 1080p, before shrinking to fit CaptionMaxLineChars
+	captionTopMargin = 0.06 // Gap above captions moved to the top, as a fraction of frame height
+)
+
+// captionsFilter renders the cues as drawtext filters centered above the bottom safe area.
+// Cues on screen while the side effects overlay is shown (overlayStart to overlayEnd) move to
+// the top of the frame so the two don't overlap; pass an empty window when there is no overlay.
+func captionsFilter(cues []domain.CaptionCue, videoWidth, videoHeight int, fontFile string, overlayStart, overlayEnd float64) string {
+	if len(cues) == 0 {
+		return ""
+	}
+	if videoWidth <= 0 {
+		videoWidth = 1920
+	}
+	if videoHeight <= 0 {
+		videoHeight = 1080
+	}
+	layout := textOverlayLayoutFor(videoWidth, videoHeight)
+
+	// Shrink the font on narrow frames so a full caption line still fits
+	scaleFactor := float64(min(videoWidth, videoHeight)) / 1080.0
+	maxLineWidth := float64(videoWidth) * layout.MaxLineWidth
+	fontSize := math.Min(captionFontSize*scaleFactor, maxLineWidth/(CaptionMaxLineChars*overlayCharWidth))
+	fontSize = math.Max(fontSize, minOverlayFontSize)
+
+	style := drawtextStyle{
+		FontFile:     fontFile,
+		FontSize:     fontSize,
+		LineSpacing:  overlayLineSpacing(fontSize),
+		BottomMargin: layout.BottomSafeArea,
+	}
+	top := fmt.Sprintf("h*%.4f", captionTopMargin)
+
+	filters := make([]string, len(cues))
+	for i, cue := range cues {
+		y := ""
+		if cue.Start < overlayEnd && cue.End > overlayStart {
+			y = top
+		}
+		window := overlayPage{Start: cue.Start, End: cue.End}
+		filters[i] = style.filter(cue.Text, y, window.enable(i == len(cues)-1))
+	}
+	return strings.Join(filters, ",")
+}
+
+// burnedCaptionsFilter is the drawtext chain for a job that asked for burned-in captions, or ""
+// otherwise. overlay is the side effects overlay being drawn with it, if any.
+func burnedCaptionsFilter(logger *zap.Logger, job *domain.Job, videoWidth, videoHeight int, overlay *drawtextConfig) string {
+	if !job.BurnCaptions || len(job.Captions) == 0 {
+		return ""
+	}
+	var overlayStart, overlayEnd float64
+	if overlay != nil {
+		overlayStart, overlayEnd = overlay.OverlayStart, overlay.OverlayEnd
+	}
+	return captionsFilter(job.Captions, videoWidth, videoHeight, detectAvailableFont(logger), overlayStart, overlayEnd)
+}
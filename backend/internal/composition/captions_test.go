@@ -0,0 +1,81 @@
+package composition
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestCaptionsFilterMovesCuesClearOfOverlay(t *testing.T) {
+	cues := []domain.CaptionCue{
+		{Start: 0, End: 4, Text: "Don't stop taking it\nunless it's \"OK\" with your doctor."},
+		{Start: 4, End: 8, Text: "Side effects: 1% felt dizzy; some [rarely], sleepy."},
+	}
+	filters := drawtextFilters(captionsFilter(cues, 1080, 1920, "", 6, 10))
+	if len(filters) != 2 {
+		t.Fatalf("expected a drawtext per cue, got %d", len(filters))
+	}
+
+	if !strings.Contains(filters[0], "y='h-text_h-h*0.1500'") {
+		t.Fatalf("expected the first cue above the bottom safe area: %s", filters[0])
+	}
+	if !strings.Contains(filters[1], "y='h*0.0600'") {
+		t.Fatalf("expected the cue during the side effects overlay at the top: %s", filters[1])
+	}
+	for i, cue := range cues {
+		if got := drawtextText(filters[i]); got != cue.Text {
+			t.Fatalf("cue %d text doesn't survive ffmpeg's unescaping:\ngot  %q\nwant %q", i, got, cue.Text)
+		}
+		if !strings.Contains(filters[i], "enable='"+overlayPage{Start: cue.Start, End: cue.End}.enable(i == 1)+"'") {
+			t.Fatalf("cue %d filter lacks its enable window: %s", i, filters[i])
+		}
+	}
+
+	// A full caption line fits the narrow frame
+	fontSize := (1080 * textOverlayLayouts[domain.AspectRatio9x16].MaxLineWidth) / (CaptionMaxLineChars * overlayCharWidth)
+	if !strings.Contains(filters[0], fmt.Sprintf("fontsize=%.2f", fontSize)) {
+		t.Fatalf("expected font size %.2f: %s", fontSize, filters[0])
+	}
+}
+
+func TestBurnedCaptionsFilterRequiresOptIn(t *testing.T) {
+	job := &domain.Job{Captions: []domain.CaptionCue{{Start: 0, End: 1, Text: "Hello."}}}
+	if filter := burnedCaptionsFilter(zap.NewNop(), job, 1920, 1080, nil); filter != "" {
+		t.Fatalf("expected no burned captions without burn_captions, got %s", filter)
+	}
+	job.BurnCaptions = true
+	if filter := burnedCaptionsFilter(zap.NewNop(), job, 1920, 1080, nil); !strings.HasPrefix(filter, "drawtext=") {
+		t.Fatalf("expected a drawtext filter, got %q", filter)
+	}
+}
+
+func TestBurnedCaptionsRenderWithQuotes(t *testing.T) {
+	ensureFfmpegAvailable(t)
+
+	clipPath := createTestClip(t, 1920, 1080, 2.0)
+	cues := []domain.CaptionCue{{Start: 0, End: 1, Text: "Don't stop taking it\nunless it's \"OK\": ask."}}
+	finalPath := filepath.Join(t.TempDir(), "captioned.mp4")
+	cmd := exec.Command(
+		"ffmpeg",
+		"-i", clipPath,
+		"-vf", captionsFilter(cues, 1920, 1080, detectAvailableFont(zap.NewNop()), 0, 0),
+		"-c:v", "libx264",
+		"-y", finalPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg rejected the caption filter: %v (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	stats := analyzeFrame(t, extractFrame(t, finalPath, 0.5), 120)
+	if lines := stats.lineCount(); lines != 2 {
+		t.Fatalf("expected the cue drawn on 2 lines, got %d", lines)
+	}
+	if after := analyzeFrame(t, extractFrame(t, finalPath, 1.5), 80); after.brightPixels != 0 {
+		t.Fatalf("expected no caption after the cue ends, found %d bright pixels", after.brightPixels)
+	}
+}
@@ -0,0 +1,128 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+// ClipAspectPolicy is what happens to a generated clip whose frame doesn't have its job's aspect
+// ratio, which would otherwise be squashed into the final video
+type ClipAspectPolicy string
+
+const (
+	// ClipAspectNormalize re-encodes the clip into the aspect ratio's 1080p frame, fitting it
+	// inside and padding the rest with black
+	ClipAspectNormalize ClipAspectPolicy = "normalize"
+
+	// ClipAspectRegenerate requests the clip once more, normalizing the retry if it is no better
+	ClipAspectRegenerate ClipAspectPolicy = "regenerate"
+)
+
+// ParseClipAspectPolicy validates a configured policy; "" is ClipAspectNormalize
+func ParseClipAspectPolicy(policy string) (ClipAspectPolicy, error) {
+	switch ClipAspectPolicy(policy) {
+	case "", ClipAspectNormalize:
+		return ClipAspectNormalize, nil
+	case ClipAspectRegenerate:
+		return ClipAspectRegenerate, nil
+	}
+	return "", fmt.Errorf("unknown clip aspect policy %q (expected normalize or regenerate)", policy)
+}
+
+// ClipAspectError is a downloaded clip whose frame doesn't have its job's aspect ratio
+type ClipAspectError struct {
+	Width       int
+	Height      int
+	AspectRatio string
+}
+
+func (e *ClipAspectError) Error() string {
+	return fmt.Sprintf("clip is %dx%d, expected %s", e.Width, e.Height, e.AspectRatio)
+}
+
+// checkClipDimensions compares a probed clip's frame with what the job's model returns for its
+// aspect ratio. A frame of the right shape at a size the model doesn't usually return is only
+// logged, as composition scales it with the rest; a frame of another shape is a
+// *ClipAspectError. Clips that couldn't be probed, or jobs without a known aspect ratio, pass.
+func checkClipDimensions(logger *zap.Logger, job *domain.Job, clipNumber int, format ClipFormat) error {
+	if format.Width <= 0 || format.Height <= 0 {
+		return nil
+	}
+	if !hasAspectRatio(format.Width, format.Height, job.AspectRatio) {
+		return &ClipAspectError{Width: format.Width, Height: format.Height, AspectRatio: job.AspectRatio}
+	}
+	model, err := adapters.ParseAdapterType(job.Model)
+	if err == nil && !adapters.IsExpectedResolution(model, job.AspectRatio, format.Width, format.Height) {
+		logger.Info("Clip has an unexpected resolution for its model",
+			zap.Int("clip", clipNumber),
+			zap.String("model", string(model)),
+			zap.String("aspect_ratio", job.AspectRatio),
+			zap.Int("width", format.Width),
+			zap.Int("height", format.Height),
+		)
+	}
+	return nil
+}
+
+// conformClipAspect probes the clip at videoPath and applies policy when its frame doesn't have
+// the job's aspect ratio: ClipAspectRegenerate returns the *ClipAspectError for the caller to
+// request the clip again, and ClipAspectNormalize re-encodes it into tmpDir. Returns the path of
+// the clip to keep.
+func conformClipAspect(
+	ctx context.Context,
+	logger *zap.Logger,
+	job *domain.Job,
+	clipNumber int,
+	videoPath string,
+	tmpDir string,
+	policy ClipAspectPolicy,
+) (string, error) {
+	format, err := ProbeClipFormat(ctx, videoPath)
+	if err != nil {
+		// The download was verified, so this only skips the check
+		logger.Warn("Failed to probe clip dimensions, keeping it as downloaded",
+			zap.Int("clip", clipNumber),
+			zap.Error(err),
+		)
+		return videoPath, nil
+	}
+	mismatch := checkClipDimensions(logger, job, clipNumber, format)
+	if mismatch == nil {
+		return videoPath, nil
+	}
+	logger.Warn("Clip doesn't have the job's aspect ratio",
+		zap.Int("clip", clipNumber),
+		zap.String("model", job.Model),
+		zap.String("aspect_ratio", job.AspectRatio),
+		zap.Int("width", format.Width),
+		zap.Int("height", format.Height),
+		zap.String("policy", string(policy)),
+	)
+	if policy == ClipAspectRegenerate {
+		return "", mismatch
+	}
+
+	target := ClipFormat{FPS: format.FPS, PixFmt: concatPixFmt, Codec: concatCodec}
+	target.Width, target.Height, _ = StandardDimensions(job.AspectRatio)
+	if target.FPS <= 0 {
+		target.FPS = defaultConcatFPS
+	}
+	output := filepath.Join(tmpDir, "video-normalized.mp4")
+	cmd := exec.CommandContext(ctx, "ffmpeg", normalizeClipArgs(videoPath, output, target)...)
+	if out, err := ffmpegexec.CombinedOutput(ctx, "normalize_clip", cmd); err != nil {
+		logger.Error("ffmpeg clip normalization failed",
+			zap.Int("clip", clipNumber),
+			zap.String("output", string(out)),
+			zap.Error(err),
+		)
+		return "", fmt.Errorf("failed to normalize clip %d to %s: %w", clipNumber, job.AspectRatio, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,104 @@
+package composition
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseClipAspectPolicy(t *testing.T) {
+	for input, want := range map[string]ClipAspectPolicy{
+		"":           ClipAspectNormalize,
+		"normalize":  ClipAspectNormalize,
+		"regenerate": ClipAspectRegenerate,
+	} {
+		got, err := ParseClipAspectPolicy(input)
+		require.NoError(t, err)
+		require.Equal(t, want, got, input)
+	}
+	_, err := ParseClipAspectPolicy("crop")
+	require.ErrorContains(t, err, "expected normalize or regenerate")
+}
+
+func TestCheckClipDimensions(t *testing.T) {
+	tests := []struct {
+		name          string
+		job           *domain.Job
+		width, height int
+		wantMismatch  bool
+	}{
+		{"veo 720p landscape", &domain.Job{AspectRatio: domain.AspectRatio16x9}, 1280, 720, false},
+		{"encoder padding keeps the shape", &domain.Job{AspectRatio: domain.AspectRatio16x9}, 1920, 1088, false},
+		{"landscape for a portrait job", &domain.Job{AspectRatio: domain.AspectRatio9x16}, 1920, 1080, true},
+		{"landscape for a square kling job", &domain.Job{AspectRatio: domain.AspectRatio1x1, Model: "kling"}, 1280, 720, true},
+		{"job without an aspect ratio", &domain.Job{}, 640, 480, false},
+		{"clip that couldn't be probed", &domain.Job{AspectRatio: domain.AspectRatio9x16}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClipDimensions(zap.NewNop(), tt.job, 1, ClipFormat{Width: tt.width, Height: tt.height})
+			if !tt.wantMismatch {
+				require.NoError(t, err)
+				return
+			}
+			var mismatch *ClipAspectError
+			require.True(t, errors.As(err, &mismatch))
+			require.Equal(t, &ClipAspectError{Width: tt.width, Height: tt.height, AspectRatio: tt.job.AspectRatio}, mismatch)
+		})
+	}
+}
+
+// servedClip serves a placeholder clip for the download
+func servedClip(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("generated clip"))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestDownloadAndProcessClip_NormalizesWrongAspectRatio(t *testing.T) {
+	job := &domain.Job{JobID: "job-clip-aspect", UserID: "user-1", AspectRatio: domain.AspectRatio9x16}
+	runner := &recordingRunner{fps: "24/1", clipDuration: 8, width: 1280, height: 720}
+	svc := &Service{s3Service: newComposeTestS3(t), assetsBucket: "assets", runner: runner, logger: zap.NewNop()}
+
+	clipURL, _, err := svc.DownloadAndProcessClip(context.Background(), job, 1, 0, servedClip(t), 8, ClipAspectNormalize)
+	require.NoError(t, err)
+	require.Contains(t, clipURL, SceneClipKey(job, 1))
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "clip-1")
+	normalized := filepath.Join(tmpDir, "video-normalized.mp4")
+	args := runner.ffmpegCall(t, normalized)
+	require.Equal(t, filepath.Join(tmpDir, "video.mp4"), args[slices.Index(args, "-i")+1])
+	require.Contains(t, args[slices.Index(args, "-vf")+1], "pad=1080:1920")
+	require.Contains(t, args[slices.Index(args, "-vf")+1], "fps=24")
+
+	frame := runner.ffmpegCall(t, filepath.Join(tmpDir, "last_frame.jpg"))
+	require.Equal(t, normalized, frame[slices.Index(frame, "-i")+1], "the next scene continues from the normalized frame")
+}
+
+func TestDownloadAndProcessClip_ReturnsWrongAspectRatioToRegenerate(t *testing.T) {
+	job := &domain.Job{JobID: "job-clip-aspect-regen", UserID: "user-1", AspectRatio: domain.AspectRatio9x16}
+	runner := &recordingRunner{fps: "24/1", clipDuration: 8, width: 1920, height: 1080}
+	svc := &Service{s3Service: newComposeTestS3(t), assetsBucket: "assets", runner: runner, logger: zap.NewNop()}
+
+	_, _, err := svc.DownloadAndProcessClip(context.Background(), job, 1, 0, servedClip(t), 8, ClipAspectRegenerate)
+	var mismatch *ClipAspectError
+	require.True(t, errors.As(err, &mismatch), "got %v", err)
+	require.Equal(t, 1920, mismatch.Width)
+	require.Empty(t, runner.calls, "nothing is taken from a clip that will be requested again")
+
+	// A clip of the job's shape is kept as downloaded under either policy
+	runner.width, runner.height = 1080, 1920
+	_, _, err = svc.DownloadAndProcessClip(context.Background(), job, 1, 0, servedClip(t), 8, ClipAspectRegenerate)
+	require.NoError(t, err)
+	require.Equal(t, []string{"last_frame.jpg"}, runner.outputs())
+}
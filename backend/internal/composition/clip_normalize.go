@@ -0,0 +1,311 @@
+package composition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+const (
+	// concatCodec and concatPixFmt are what every clip is concatenated as
+	concatCodec  = "h264"
+	concatPixFmt = "yuv420p"
+
+	// concatFPSTolerance absorbs rounding between NTSC and integer rates reported by ffprobe
+	concatFPSTolerance = 0.01
+
+	// aspectRatioTolerance lets encoder padding (1920x1088) count as the intended shape
+	aspectRatioTolerance = 0.01
+
+	defaultConcatFPS = 30.0
+)
+
+// ClipFormat is the stream layout the concat demuxer needs every input to share.
+// A zero value is a clip that couldn't be probed.
+type ClipFormat struct {
+	Width    int
+	Height   int
+	FPS      float64
+	PixFmt   string
+	Codec    string
+	Duration float64 // Seconds; not part of the layout, used for the final duration check
+}
+
+// matches reports whether a clip in format f can be stream-copied into a target video
+func (f ClipFormat) matches(target ClipFormat) bool {
+	return f.Width == target.Width &&
+		f.Height == target.Height &&
+		math.Abs(f.FPS-target.FPS) <= concatFPSTolerance &&
+		f.PixFmt == target.PixFmt &&
+		f.Codec == target.Codec
+}
+
+// StandardDimensions is the 1080p frame for each supported aspect ratio
+func StandardDimensions(aspectRatio string) (int, int, bool) {
+	switch aspectRatio {
+	case domain.AspectRatio16x9:
+		return 1920, 1080, true
+	case domain.AspectRatio9x16:
+		return 1080, 1920, true
+	case domain.AspectRatio1x1:
+		return 1080, 1080, true
+	}
+	return 0, 0, false
+}
+
+// hasAspectRatio reports whether a width x height frame has the shape of aspectRatio.
+// Any shape is accepted for an unknown aspect ratio.
+func hasAspectRatio(width, height int, aspectRatio string) bool {
+	if width <= 0 || height <= 0 {
+		return false
+	}
+	stdWidth, stdHeight, ok := StandardDimensions(aspectRatio)
+	if !ok {
+		return true
+	}
+	want := float64(stdWidth) / float64(stdHeight)
+	return math.Abs(float64(width)/float64(height)-want)/want <= aspectRatioTolerance
+}
+
+// planClipNormalization picks the format the job's clips are concatenated in and returns the
+// indexes of the clips that must be re-encoded to it. The target is the most common layout
+// among clips with the job's aspect ratio (the larger frame on a tie, so a 720p retry is
+// scaled up rather than the rest scaled down); when none has it, the 1080p frame for the
+// aspect ratio at the clips' most common frame rate. Clips already in the target format are
+// stream-copied.
+func planClipNormalization(aspectRatio string, clips []ClipFormat) (ClipFormat, []int) {
+	type layout struct {
+		width, height int
+		fps           float64
+	}
+	var best layout
+	bestCount := 0
+	counts := make(map[layout]int)
+	rates := make(map[layout]float64) // The unrounded rate first seen for each layout
+	fpsCounts := make(map[float64]int)
+	bestFPS, bestFPSCount := defaultConcatFPS, 0
+	for _, clip := range clips {
+		if clip.FPS <= 0 {
+			continue
+		}
+		fps := math.Round(clip.FPS*100) / 100
+		fpsCounts[fps]++
+		if n := fpsCounts[fps]; n > bestFPSCount {
+			bestFPS, bestFPSCount = fps, n
+		}
+		if !hasAspectRatio(clip.Width, clip.Height, aspectRatio) {
+			continue
+		}
+		l := layout{clip.Width, clip.Height, fps}
+		counts[l]++
+		n := counts[l]
+		if _, ok := rates[l]; !ok {
+			rates[l] = clip.FPS
+		}
+		if n > bestCount || (n == bestCount && l.width*l.height > best.width*best.height) {
+			best, bestCount = l, n
+		}
+	}
+
+	target := ClipFormat{PixFmt: concatPixFmt, Codec: concatCodec}
+	if bestCount > 0 {
+		target.Width, target.Height, target.FPS = best.width, best.height, rates[best]
+	} else {
+		width, height, ok := StandardDimensions(aspectRatio)
+		if !ok {
+			width, height, _ = StandardDimensions(domain.AspectRatio16x9)
+		}
+		target.Width, target.Height, target.FPS = width, height, bestFPS
+	}
+
+	var reencode []int
+	for i, clip := range clips {
+		if !clip.matches(target) {
+			reencode = append(reencode, i)
+		}
+	}
+	return target, reencode
+}
+
+// normalizeClipArgs builds the ffmpeg arguments that re-encode input to target, fitting the
+// frame inside it and padding the rest with black so nothing is cropped or stretched. The
+// encoder settings match the end card's.
+func normalizeClipArgs(input, output string, target ClipFormat) []string {
+	filter := strings.Join([]string{
+		fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", target.Width, target.Height),
+		fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=black", target.Width, target.Height),
+		"setsar=1",
+		"fps=" + strconv.FormatFloat(target.FPS, 'f', -1, 64),
+		"format=" + target.PixFmt,
+	}, ",")
+	return []string{
+		"-i", input,
+		"-vf", filter,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-pix_fmt", target.PixFmt,
+		"-an",
+		"-y", output,
+	}
+}
+
+// ProbeClipFormat reads the first video stream's layout and the container duration
+func ProbeClipFormat(ctx context.Context, path string) (ClipFormat, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name,width,height,pix_fmt,r_frame_rate:format=duration",
+		"-of", "json",
+		path,
+	)
+	output, err := ffmpegexec.CommandOutput(ctx, "probe_clip_format", cmd)
+	if err != nil {
+		return ClipFormat{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+			PixFmt    string `json:"pix_fmt"`
+			FrameRate string `json:"r_frame_rate"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return ClipFormat{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return ClipFormat{}, fmt.Errorf("no video stream")
+	}
+
+	stream := probe.Streams[0]
+	format := ClipFormat{
+		Width:  stream.Width,
+		Height: stream.Height,
+		FPS:    parseFrameRate(stream.FrameRate),
+		PixFmt: stream.PixFmt,
+		Codec:  stream.CodecName,
+	}
+	format.Duration, _ = strconv.ParseFloat(strings.TrimSpace(probe.Format.Duration), 64)
+	return format, nil
+}
+
+// parseFrameRate parses ffprobe's "num/den" rate, returning 0 if it can't
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(strings.TrimSpace(rate), "/")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// normalizeClipsForConcat probes the downloaded clips and re-encodes the ones that don't share
+// the job's target format, so the concat demuxer can stream-copy them and transitions can blend
+// them. Mixed inputs (a 720p retry among 1080p clips) otherwise produce a final video that is
+// garbage after the seam. Returns the paths to concatenate, the target format and each clip's
+// duration; a clip that can't be re-encoded fails the composition.
+func normalizeClipsForConcat(
+	ctx context.Context,
+	logger *zap.Logger,
+	job *domain.Job,
+	clips []ClipVideo,
+	clipPaths []string,
+	tmpDir string,
+	ledger *tmpLedger,
+) ([]string, ClipFormat, []float64, error) {
+	formats := make([]ClipFormat, len(clipPaths))
+	durations := make([]float64, len(clipPaths))
+	for i, path := range clipPaths {
+		format, err := ProbeClipFormat(ctx, path)
+		if err != nil {
+			// Re-encoding rewrites whatever the probe couldn't read
+			logger.Warn("Failed to probe clip format, re-encoding it",
+				zap.Int("clip", i+1),
+				zap.Error(err),
+			)
+		}
+		formats[i] = format
+		if format.Duration > 0 {
+			durations[i] = format.Duration
+		} else if i < len(clips) {
+			durations[i] = clips[i].Duration
+		}
+	}
+
+	target, reencode := planClipNormalization(job.AspectRatio, formats)
+	if len(reencode) == 0 {
+		return clipPaths, target, durations, nil
+	}
+	logger.Info("Normalizing clips before concat",
+		zap.Int("num_clips", len(reencode)),
+		zap.Int("target_width", target.Width),
+		zap.Int("target_height", target.Height),
+		zap.Float64("target_fps", target.FPS),
+	)
+
+	normalized := append([]string(nil), clipPaths...)
+	for _, i := range reencode {
+		output := filepath.Join(tmpDir, fmt.Sprintf("clip-%d-normalized.mp4", i+1))
+		cmd := exec.CommandContext(ctx, "ffmpeg", normalizeClipArgs(clipPaths[i], output, target)...)
+		if out, err := ffmpegexec.CombinedOutput(ctx, "normalize_clip", cmd); err != nil {
+			logger.Error("ffmpeg clip normalization failed",
+				zap.Int("clip", i+1),
+				zap.Int("width", formats[i].Width),
+				zap.Int("height", formats[i].Height),
+				zap.Float64("fps", formats[i].FPS),
+				zap.String("output", string(out)),
+				zap.Error(err),
+			)
+			return nil, ClipFormat{}, nil, fmt.Errorf("failed to normalize clip %d: %w", i+1, err)
+		}
+		ledger.consume(output, clipPaths[i])
+		normalized[i] = output
+	}
+	return normalized, target, durations, nil
+}
+
+// verifyConcatDuration fails when the concatenated video isn't within ConcatDurationTolerance
+// of its inputs' total, which is how a bad seam shows up when the demuxer doesn't error
+func verifyConcatDuration(ctx context.Context, path string, expected float64) error {
+	format, err := ProbeClipFormat(ctx, path)
+	if err != nil {
+		return fmt.Errorf("concatenated video is not readable: %w", err)
+	}
+	return checkConcatDuration(format.Duration, expected)
+}
+
+// checkConcatDuration compares the concatenated duration with the inputs' total
+func checkConcatDuration(actual, expected float64) error {
+	if expected <= 0 {
+		return nil
+	}
+	if math.Abs(actual-expected) > ConcatDurationTolerance {
+		return fmt.Errorf("concatenated video is %.2fs, expected about %.2fs", actual, expected)
+	}
+	return nil
+}
+
+// ConcatDurationTolerance is how far (seconds) the concatenated video may be from the sum of its clips
+const ConcatDurationTolerance = 0.5
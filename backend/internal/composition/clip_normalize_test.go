@@ -0,0 +1,134 @@
+package composition
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanClipNormalization(t *testing.T) {
+	hd := ClipFormat{Width: 1920, Height: 1080, FPS: 24, PixFmt: "yuv420p", Codec: "h264"}
+	with := func(f ClipFormat, edit func(*ClipFormat)) ClipFormat {
+		edit(&f)
+		return f
+	}
+	retry720 := with(hd, func(f *ClipFormat) { f.Width, f.Height = 1280, 720 })
+
+	tests := []struct {
+		name        string
+		aspectRatio string
+		clips       []ClipFormat
+		target      ClipFormat
+		reencode    []int
+	}{
+		{
+			name:        "all conforming",
+			aspectRatio: domain.AspectRatio16x9,
+			clips:       []ClipFormat{hd, hd, hd},
+			target:      hd,
+		},
+		{
+			name:        "720p retry among 1080p clips",
+			aspectRatio: domain.AspectRatio16x9,
+			clips:       []ClipFormat{hd, retry720, hd},
+			target:      hd,
+			reencode:    []int{1},
+		},
+		{
+			name:        "tie goes to the larger frame",
+			aspectRatio: domain.AspectRatio16x9,
+			clips:       []ClipFormat{retry720, hd},
+			target:      hd,
+			reencode:    []int{0},
+		},
+		{
+			name:        "all 720p stays 720p",
+			aspectRatio: domain.AspectRatio16x9,
+			clips:       []ClipFormat{retry720, retry720},
+			target:      retry720,
+		},
+		{
+			name:        "frame rate, pixel format and codec",
+			aspectRatio: domain.AspectRatio16x9,
+			clips: []ClipFormat{
+				hd,
+				with(hd, func(f *ClipFormat) { f.FPS = 30 }),
+				with(hd, func(f *ClipFormat) { f.PixFmt = "yuv444p" }),
+				with(hd, func(f *ClipFormat) { f.Codec = "hevc" }),
+				hd,
+			},
+			target:   hd,
+			reencode: []int{1, 2, 3},
+		},
+		{
+			name:        "NTSC rates match within tolerance",
+			aspectRatio: domain.AspectRatio16x9,
+			clips: []ClipFormat{
+				with(hd, func(f *ClipFormat) { f.FPS = 30000.0 / 1001 }),
+				with(hd, func(f *ClipFormat) { f.FPS = 29.97 }),
+			},
+			target: with(hd, func(f *ClipFormat) { f.FPS = 30000.0 / 1001 }),
+		},
+		{
+			name:        "wrong shape uses the standard frame",
+			aspectRatio: domain.AspectRatio9x16,
+			clips:       []ClipFormat{hd, hd},
+			target:      with(hd, func(f *ClipFormat) { f.Width, f.Height = 1080, 1920 }),
+			reencode:    []int{0, 1},
+		},
+		{
+			name:        "unprobed clip is re-encoded",
+			aspectRatio: domain.AspectRatio1x1,
+			clips:       []ClipFormat{{}, with(hd, func(f *ClipFormat) { f.Width = 1080 })},
+			target:      with(hd, func(f *ClipFormat) { f.Width = 1080 }),
+			reencode:    []int{0},
+		},
+		{
+			name:        "nothing probed",
+			aspectRatio: domain.AspectRatio16x9,
+			clips:       []ClipFormat{{}, {}},
+			target:      with(hd, func(f *ClipFormat) { f.FPS = defaultConcatFPS }),
+			reencode:    []int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, reencode := planClipNormalization(tt.aspectRatio, tt.clips)
+			require.Equal(t, tt.target, target)
+			require.Equal(t, tt.reencode, reencode)
+		})
+	}
+}
+
+func TestNormalizeClipArgs(t *testing.T) {
+	target := ClipFormat{Width: 1920, Height: 1080, FPS: 24, PixFmt: "yuv420p", Codec: "h264"}
+	args := normalizeClipArgs("in.mp4", "out.mp4", target)
+
+	require.Equal(t, []string{
+		"-i", "in.mp4",
+		"-vf", "scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1,fps=24,format=yuv420p",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-pix_fmt", "yuv420p",
+		"-an",
+		"-y", "out.mp4",
+	}, args)
+}
+
+func TestCheckConcatDuration(t *testing.T) {
+	require.NoError(t, checkConcatDuration(32.04, 32))
+	require.NoError(t, checkConcatDuration(31.6, 32))
+	require.Error(t, checkConcatDuration(24, 32), "a clip lost at a seam")
+	require.Error(t, checkConcatDuration(0, 32))
+	require.NoError(t, checkConcatDuration(0, 0), "nothing to compare against")
+}
+
+func TestParseFrameRate(t *testing.T) {
+	require.Equal(t, 24.0, parseFrameRate("24/1\n"))
+	require.InDelta(t, 29.97, parseFrameRate("30000/1001"), 0.001)
+	require.Zero(t, parseFrameRate("0/0"))
+	require.Zero(t, parseFrameRate("N/A"))
+}
@@ -0,0 +1,204 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"go.uber.org/zap"
+)
+
+// ClipQualityGate sets when a generated clip is rejected as unusable before it joins its job:
+// entirely black, or frozen on one frame. Zero fields turn their check off.
+type ClipQualityGate struct {
+	// Mean luminance (0-255) every sampled frame must be darker than for the clip to be black.
+	// Black is 16 in limited-range video.
+	BlackLuminance float64
+
+	// Mean luminance difference every pair of consecutive sampled frames must stay below for the
+	// clip to be frozen. Also turns on the check of ffmpeg's freezedetect.
+	MinMotion float64
+}
+
+func (g ClipQualityGate) enabled() bool {
+	return g.BlackLuminance > 0 || g.MinMotion > 0
+}
+
+const (
+	clipQualitySamples = 5   // Frames sampled, evenly spaced over the clip
+	frozenClipFraction = 0.9 // Share of a clip freezedetect must find frozen for it to be frozen
+)
+
+// ClipQualityError is a downloaded clip a ClipQualityGate rejected
+type ClipQualityError struct {
+	Reason string // "black" or "frozen"
+	Detail string // What was measured
+}
+
+func (e *ClipQualityError) Error() string {
+	return fmt.Sprintf("generated clip failed quality check: %s (%s)", e.Reason, e.Detail)
+}
+
+// clipFrameStats is what ffmpeg measured of a clip: signalstats of its sampled frames, and the
+// spans freezedetect found over every frame
+type clipFrameStats struct {
+	Luminance  []float64 // YAVG of each sampled frame
+	Difference []float64 // YDIF of each sampled frame after the first, against the one before
+	Freezes    []clipFreeze
+}
+
+// clipFreeze is a span of a clip in seconds that freezedetect found frozen. End is negative for a
+// freeze still running when the clip ended, which freezedetect never closes.
+type clipFreeze struct {
+	Start float64
+	End   float64
+}
+
+var (
+	// metadata=mode=print logs each sampled frame's keys as "lavfi.signalstats.YAVG=16.25"
+	signalstatsLine = regexp.MustCompile(`lavfi\.signalstats\.(YAVG|YDIF)=(\S+)`)
+	// freezedetect logs its own keys as "lavfi.freezedetect.freeze_start: 0.5"; the copies it
+	// attaches to frames are printed with "=" and left out
+	freezedetectLine = regexp.MustCompile(`lavfi\.freezedetect\.(freeze_start|freeze_end): (\S+)`)
+)
+
+// parseClipFrameStats reads the combined output of the ffmpeg command clipFrameStatsArgs builds.
+// Lines it doesn't recognize, such as the input summary, are skipped.
+func parseClipFrameStats(output []byte) clipFrameStats {
+	var stats clipFrameStats
+	var differences []float64
+	for _, line := range strings.Split(string(output), "\n") {
+		if m := signalstatsLine.FindStringSubmatch(line); m != nil {
+			value, err := strconv.ParseFloat(m[2], 64)
+			if err != nil {
+				continue
+			}
+			if m[1] == "YAVG" {
+				stats.Luminance = append(stats.Luminance, value)
+			} else {
+				differences = append(differences, value)
+			}
+			continue
+		}
+		if m := freezedetectLine.FindStringSubmatch(line); m != nil {
+			value, err := strconv.ParseFloat(m[2], 64)
+			if err != nil {
+				continue
+			}
+			last := len(stats.Freezes) - 1
+			switch {
+			case m[1] == "freeze_start":
+				stats.Freezes = append(stats.Freezes, clipFreeze{Start: value, End: -1})
+			case last >= 0 && stats.Freezes[last].End < 0:
+				stats.Freezes[last].End = value
+			}
+		}
+	}
+	// The first sample has nothing before it to differ from
+	if len(differences) > 1 {
+		stats.Difference = differences[1:]
+	}
+	return stats
+}
+
+// frozenSeconds returns how much of a clip of duration seconds the freezes cover
+func (s clipFrameStats) frozenSeconds(duration float64) float64 {
+	var frozen float64
+	for _, freeze := range s.Freezes {
+		end := freeze.End
+		if end < 0 {
+			end = duration
+		}
+		frozen += max(0, end-freeze.Start)
+	}
+	return frozen
+}
+
+// check returns a *ClipQualityError when stats show a clip of duration seconds is black or frozen
+func (g ClipQualityGate) check(stats clipFrameStats, duration float64) error {
+	if g.BlackLuminance > 0 && len(stats.Luminance) > 0 {
+		if brightest := slices.Max(stats.Luminance); brightest < g.BlackLuminance {
+			return &ClipQualityError{
+				Reason: "black",
+				Detail: fmt.Sprintf("brightest sampled frame has mean luminance %.1f", brightest),
+			}
+		}
+	}
+	if g.MinMotion <= 0 {
+		return nil
+	}
+	if len(stats.Difference) > 0 {
+		if most := slices.Max(stats.Difference); most < g.MinMotion {
+			return &ClipQualityError{
+				Reason: "frozen",
+				Detail: fmt.Sprintf("sampled frames differ by at most %.2f", most),
+			}
+		}
+	}
+	if frozen := stats.frozenSeconds(duration); duration > 0 && frozen >= frozenClipFraction*duration {
+		return &ClipQualityError{
+			Reason: "frozen",
+			Detail: fmt.Sprintf("%.1fs of %.1fs frozen", frozen, duration),
+		}
+	}
+	return nil
+}
+
+// clipFrameStatsArgs builds the ffmpeg arguments that run freezedetect over every frame of the
+// clip at videoPath, then print signalstats of clipQualitySamples frames spread over its duration
+// seconds
+func clipFrameStatsArgs(videoPath string, duration float64) []string {
+	if duration <= 0 {
+		duration = 8
+	}
+	rate := strconv.FormatFloat(clipQualitySamples/duration, 'f', 4, 64)
+	return []string{
+		"-hide_banner", "-nostats",
+		"-i", videoPath,
+		"-vf", "freezedetect=n=-60dB:d=2,fps=" + rate + ",signalstats,metadata=mode=print",
+		"-an", "-f", "null", "-",
+	}
+}
+
+// checkClipQuality samples the clip at videoPath and returns a *ClipQualityError when gate
+// rejects it. A clip ffmpeg can't sample passes: its download was verified, so this only skips
+// the check.
+func checkClipQuality(
+	ctx context.Context,
+	logger *zap.Logger,
+	gate ClipQualityGate,
+	clipNumber int,
+	videoPath string,
+	duration float64,
+) error {
+	if !gate.enabled() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, ffmpegexec.FrameTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "ffmpeg", clipFrameStatsArgs(videoPath, duration)...)
+	out, err := ffmpegexec.CombinedOutput(ctx, "clip_quality", cmd)
+	if err != nil {
+		logger.Warn("Failed to sample clip frames, skipping its quality check",
+			zap.Int("clip", clipNumber),
+			zap.Error(err),
+		)
+		return nil
+	}
+	stats := parseClipFrameStats(out)
+	if err := gate.check(stats, duration); err != nil {
+		logger.Warn("Clip failed quality check",
+			zap.Int("clip", clipNumber),
+			zap.Float64s("luminance", stats.Luminance),
+			zap.Float64s("difference", stats.Difference),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package composition
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readFrameStats returns ffmpeg output captured in testdata
+func readFrameStats(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseClipFrameStats(t *testing.T) {
+	good := parseClipFrameStats(readFrameStats(t, "frame-stats-good.txt"))
+	require.Equal(t, []float64{74.1192, 81.4407, 96.2018, 88.7353, 102.987}, good.Luminance)
+	require.Equal(t, []float64{9.8735, 14.2291, 6.5148, 11.0624}, good.Difference, "the first sample's YDIF is left out")
+	require.Equal(t, []clipFreeze{{Start: 2.541667, End: 4.625}}, good.Freezes)
+
+	frozen := parseClipFrameStats(readFrameStats(t, "frame-stats-frozen.txt"))
+	require.Len(t, frozen.Luminance, 5)
+	require.Equal(t, []clipFreeze{{Start: 0.083333, End: -1}}, frozen.Freezes, "the copy printed with the frame's metadata isn't counted again")
+	require.InDelta(t, 7.916667, frozen.frozenSeconds(8), 1e-6)
+
+	require.Equal(t, clipFrameStats{}, parseClipFrameStats([]byte("Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'video.mp4':\n")))
+}
+
+func TestClipQualityGate_Check(t *testing.T) {
+	gate := ClipQualityGate{BlackLuminance: 20, MinMotion: 0.5}
+	tests := []struct {
+		name       string
+		stats      clipFrameStats
+		gate       ClipQualityGate
+		wantReason string
+	}{
+		{name: "good clip", stats: parseClipFrameStats(readFrameStats(t, "frame-stats-good.txt")), gate: gate},
+		{name: "black clip", stats: parseClipFrameStats(readFrameStats(t, "frame-stats-black.txt")), gate: gate, wantReason: "black"},
+		{name: "frozen clip", stats: parseClipFrameStats(readFrameStats(t, "frame-stats-frozen.txt")), gate: gate, wantReason: "frozen"},
+		{
+			name:  "fade in from black",
+			stats: clipFrameStats{Luminance: []float64{16, 16.4, 58.2, 91.7, 96}, Difference: []float64{0.3, 21.6, 18.4, 4.2}},
+			gate:  gate,
+		},
+		{
+			name:       "samples move but freezedetect found the clip frozen",
+			stats:      clipFrameStats{Luminance: []float64{90, 92}, Difference: []float64{0.8}, Freezes: []clipFreeze{{Start: 0, End: 7.5}}},
+			gate:       gate,
+			wantReason: "frozen",
+		},
+		{
+			name:  "black check off",
+			stats: parseClipFrameStats(readFrameStats(t, "frame-stats-black.txt")),
+			gate:  ClipQualityGate{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.gate.check(tt.stats, 8)
+			if tt.wantReason == "" {
+				require.NoError(t, err)
+				return
+			}
+			var rejected *ClipQualityError
+			require.True(t, errors.As(err, &rejected), "got %v", err)
+			require.Equal(t, tt.wantReason, rejected.Reason)
+			require.ErrorContains(t, err, "generated clip failed quality check")
+		})
+	}
+}
+
+// frameStatsRunner answers the quality check's ffmpeg run with the captured output for the
+// downloaded clip: clips whose body is "black clip" measure as black
+type frameStatsRunner struct {
+	*recordingRunner
+	t *testing.T
+}
+
+func (r *frameStatsRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	measuresFrames := slices.ContainsFunc(cmd.Args, func(arg string) bool { return strings.Contains(arg, "signalstats") })
+	if !measuresFrames {
+		return r.recordingRunner.CombinedOutput(cmd)
+	}
+	clip, err := os.ReadFile(cmd.Args[slices.Index(cmd.Args, "-i")+1])
+	require.NoError(r.t, err)
+	if string(clip) == "black clip" {
+		return readFrameStats(r.t, "frame-stats-black.txt"), nil
+	}
+	return readFrameStats(r.t, "frame-stats-good.txt"), nil
+}
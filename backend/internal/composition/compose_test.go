@@ -1,4 +1,4 @@
-package handlers
+package composition
 
 import (
 	"fmt"
@@ -236,7 +236,7 @@ func TestComposeVideo_TextOverlayScenarios(t *testing.T) {
 		for _, scenario := range textScenarios {
 			name := fmt.Sprintf("%s_%s", ratio.name, scenario.name)
 			t.Run(name, func(t *testing.T) {
-				config, err := buildDrawtextConfig(logger, scenario.text, 4.0, 5.0, ratio.width, ratio.height)
+				config, err := buildDrawtextConfig(logger, scenario.text, 4.0, 5.0, ratio.width, ratio.height, "")
 				if err != nil {
 					t.Fatalf("failed to build drawtext config: %v", err)
 				}
@@ -270,9 +270,14 @@ func TestComposeVideo_TextOverlayScenarios(t *testing.T) {
 					t.Fatalf("expected overlay in frame, found none")
 				}
 
+				// The block sits above the safe area and never takes more than maxOverlayHeight
+				layout := textOverlayLayoutFor(ratio.width, ratio.height)
 				bottomStart := postStats.bottomStartFraction()
-				if bottomStart < 0.6 {
-					t.Fatalf("expected overlay to start near bottom, got %.2f", bottomStart)
+				if minStart := 1 - maxOverlayHeight - layout.BottomSafeArea - 0.02; bottomStart < minStart {
+					t.Fatalf("expected overlay to start near bottom (>= %.2f), got %.2f", minStart, bottomStart)
+				}
+				if bottomEnd := float64(postStats.maxY) / float64(postStats.height); bottomEnd > 1-layout.BottomSafeArea+0.01 {
+					t.Fatalf("expected overlay to stay above the bottom safe area, ends at %.2f", bottomEnd)
 				}
 
 				centerX := postStats.centerX()
@@ -333,8 +338,150 @@ func expectedLineRange(width, height int, scenario string) (int, int) {
 
 func TestEscapeFfmpegTextEscapesSpecialCharacters(t *testing.T) {
 	input := `It's 100% effective: "Don't miss your dose."`
-	want := `It\'s 100\% effective\: \"Don\'t miss your dose.\"`
+	want := `It\\\'s 100% effective\\: "Don\\\'t miss your dose."`
 	if got := escapeFfmpegText(input); got != want {
 		t.Fatalf("expected %q, got %q", want, got)
 	}
 }
+
+func TestEscapeFfmpegTextSurvivesFilterParsing(t *testing.T) {
+	for _, text := range []string{
+		`Don't stop taking it unless your doctor says it's OK.`,
+		`Ask about "Zyloprim": it isn't for everyone.`,
+		`'Quoted' from start to end'`,
+		`1% of patients; some [rarely], felt C:\dizzy\`,
+		"Two\nlines",
+	} {
+		style := drawtextStyle{FontSize: 36, LineSpacing: 8, BottomMargin: 0.08}
+		if got := drawtextText(style.filter(text, "", "between(t,0,1)")); got != text {
+			t.Fatalf("text doesn't survive ffmpeg's unescaping:\ngot  %q\nwant %q", got, text)
+		}
+	}
+}
+
+// fdaDisclosureText is a full-length pharmaceutical disclosure, longer than the scenarios above
+var fdaDisclosureText = strings.Repeat(fdaDisclosureParagraph, 3)
+
+const fdaDisclosureParagraph = "Serious side effects may include allergic reactions, liver problems, " +
+	"low blood sugar, severe stomach pain, kidney problems and vision changes. Tell your doctor about all " +
+	"the medicines you take, including prescription and over-the-counter medicines, vitamins and herbal " +
+	"supplements. Do not take if you are allergic to any of its ingredients. "
+
+func TestWrapTextRespectsLineWidth(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		maxLineWidth float64
+		fontSize     float64
+	}{
+		{"16x9", 1920 * 0.80, 36},
+		{"9x16", 1080 * 0.85, 36},
+		{"1x1", 1080 * 0.85, 28},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			wrapped, longest := wrapText(fdaDisclosureText, tc.maxLineWidth, tc.fontSize)
+			limit := int(tc.maxLineWidth / (tc.fontSize * overlayCharWidth))
+
+			lines := strings.Split(wrapped, "\n")
+			if len(lines) < 2 {
+				t.Fatalf("expected long disclosure to wrap, got one line")
+			}
+			widest := 0
+			for _, line := range lines {
+				if n := len([]rune(line)); n > widest {
+					widest = n
+				}
+			}
+			if widest > limit {
+				t.Fatalf("line of %d runes exceeds limit of %d", widest, limit)
+			}
+			if widest != longest {
+				t.Fatalf("expected longest line %d, wrapText reported %d", widest, longest)
+			}
+			if got, want := strings.Fields(wrapped), strings.Fields(fdaDisclosureText); strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Fatalf("wrapping changed the text")
+			}
+		})
+	}
+}
+
+func TestWrapTextKeepsShortTextAndLongWords(t *testing.T) {
+	short := "May cause drowsiness. Consult your doctor."
+	if wrapped, longest := wrapText(short, 1536, 36); wrapped != short || longest != len(short) {
+		t.Fatalf("expected short text on one line, got %q (%d)", wrapped, longest)
+	}
+
+	word := strings.Repeat("x", 60)
+	wrapped, _ := wrapText("Contains "+word+" extract", 1080*0.85, 36)
+	if lines := strings.Split(wrapped, "\n"); len(lines) != 3 || lines[1] != word || lines[0] == "" {
+		t.Fatalf("expected the long word alone on its own line, got %q", wrapped)
+	}
+}
+
+func TestBuildDrawtextConfigLayout(t *testing.T) {
+	logger := zap.NewNop()
+	texts := map[string]string{
+		"short":  "May cause drowsiness. Consult your doctor.",
+		"medium": "May cause drowsiness, dry mouth, dizziness, or nausea. Do not drive or operate machinery. Avoid alcohol. Consult your doctor if symptoms persist. Not recommended for children under 12.",
+		"fda":    fdaDisclosureText,
+		"fda_x3": strings.Repeat(fdaDisclosureText, 3),
+	}
+
+	for _, ratio := range []struct {
+		name          string
+		width, height int
+		layout        textOverlayLayout
+	}{
+		{"16x9", 1920, 1080, textOverlayLayouts["16:9"]},
+		{"9x16", 1080, 1920, textOverlayLayouts["9:16"]},
+		{"1x1", 1080, 1080, textOverlayLayouts["1:1"]},
+	} {
+		for name, text := range texts {
+			t.Run(ratio.name+"_"+name, func(t *testing.T) {
+				config, err := buildDrawtextConfig(logger, text, 4.0, 5.0, ratio.width, ratio.height, "")
+				if err != nil || config == nil {
+					t.Fatalf("expected drawtext config, got %v (%v)", config, err)
+				}
+
+				if !strings.Contains(config.Filter, ":x=(w-text_w)/2:") {
+					t.Fatalf("expected text centered on its rendered width: %s", config.Filter)
+				}
+				if want := fmt.Sprintf(":y='h-text_h-h*%.4f':", ratio.layout.BottomSafeArea); !strings.Contains(config.Filter, want) {
+					t.Fatalf("expected %q in filter: %s", want, config.Filter)
+				}
+				if limit := float64(ratio.width) * ratio.layout.MaxLineWidth; config.EstimatedWidth > limit {
+					t.Fatalf("widest line %.0fpx exceeds %.0fpx", config.EstimatedWidth, limit)
+				}
+				limit := float64(ratio.height) * maxOverlayHeight
+				if config.Overflow == "" && config.BlockHeight > limit {
+					t.Fatalf("text block %.0fpx exceeds %.0fpx (font %.1f)", config.BlockHeight, limit, config.FontSize)
+				}
+				for _, page := range config.Pages {
+					if h := textBlockHeight(page.Text, config.FontSize); h > limit {
+						t.Fatalf("page of %.0fpx exceeds %.0fpx", h, limit)
+					}
+				}
+				if minSize := minReadableFontSize * float64(min(ratio.width, ratio.height)) / 1080; config.FontSize < minSize {
+					t.Fatalf("font size %.1f below readable minimum %.1f", config.FontSize, minSize)
+				}
+				// The shorter side sets the scale, so vertical text matches square text
+				if config.FontSize > config.BaseFontSize*float64(min(ratio.width, ratio.height))/1080 && config.FontSize > minOverlayFontSize {
+					t.Fatalf("font size %.1f larger than base %.1f scaled to the frame", config.FontSize, config.BaseFontSize)
+				}
+			})
+		}
+	}
+}
+
+func TestBuildDrawtextConfigShrinksToHeightCap(t *testing.T) {
+	// Slightly too tall at the base size, so shrinking is enough
+	config, err := buildDrawtextConfig(zap.NewNop(), strings.Repeat(fdaDisclosureParagraph, 2), 4.0, 5.0, 1080, 1080, "")
+	if err != nil || config == nil {
+		t.Fatalf("expected drawtext config, got %v (%v)", config, err)
+	}
+	if config.FontSize >= config.BaseFontSize {
+		t.Fatalf("expected font to shrink below %.1f for a %d-rune disclosure, got %.1f", config.BaseFontSize, config.RuneCount, config.FontSize)
+	}
+	if config.Overflow != "" || config.BlockHeight > 1080*maxOverlayHeight {
+		t.Fatalf("expected the disclosure to fit at %.1fpx, got %.0fpx block (overflow %q)", config.FontSize, config.BlockHeight, config.Overflow)
+	}
+}
@@ -0,0 +1,739 @@
+// Package composition turns a job's generated clips, audio and overlays into its final videos
+// with ffmpeg: it downloads and checks each clip, plans the composition's keys from its inputs,
+// and runs the encodes within the job's temp space budget.
+package composition
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/internal/trace"
+	"github.com/omnigen/backend/internal/transitions"
+	"go.uber.org/zap"
+)
+
+// Service turns generated clips into job videos: it downloads and stores each clip and
+// composes the final video from them. The generate and regenerate handlers share one, so a
+// recomposed video is built exactly like the original. Where the two used to differ, the
+// original generation's behavior was kept unless it was a bug:
+//   - audio disclaimer overlays start where the spoken disclaimer does, and at the start of the
+//     video when the spec has no audio (recompositions ignored UseAudio)
+//   - videos under 30fps are interpolated to 30fps (recompositions kept the source rate)
+//   - the WebM carries the mixed audio as Opus (recompositions dropped it)
+//   - a failed WebM is recorded as a job warning (recompositions only logged it)
+//   - last frames are presigned in the job's asset bucket (generation used job.AssetBucket,
+//     which legacy jobs leave empty)
+type Service struct {
+	s3Service    *repository.S3AssetRepository
+	assetsBucket string                      // User uploads; generated assets go to each job's own bucket
+	tmpBudget    int64                       // Bytes of /tmp a composition may use; <= 0 disables the check
+	events       repository.JobEventAppender // Records composition warnings; nil records nothing
+	progress     ProgressStore               // Records how far each encode has got on the job; nil records nothing
+	runner       ffmpegexec.Runner           // Runs every ffmpeg and ffprobe command of a clip or composition
+	composed     compositionObserver         // Receives the spec of each verified composition; nil for none
+	clipQuality  ClipQualityGate             // Rejects black or frozen clips after download; zero checks nothing
+	logger       *zap.Logger
+}
+
+// compositionSpec records what a composition ran, so its output can be checked against the job
+// without looking at pixels
+type compositionSpec struct {
+	Duration     float64         // Seconds the final video should last, end card included
+	OverlayText  string          // Side effects text the job's disclaimer settings call for
+	OverlayStart float64         // When it should appear; 0 leaves it to the overlay's default
+	Overlay      *drawtextConfig // The side effects overlay that was built; nil without one
+	Filtergraph  string          // What the overlay pass ran; "" when it didn't run
+}
+
+// compositionObserver receives the spec of a composition whose final video passed verification
+type compositionObserver func(job *domain.Job, spec compositionSpec)
+
+// ProgressStore is the subset of the job repository that records composition progress
+type ProgressStore interface {
+	UpdateJobCompositionProgress(ctx context.Context, jobID string, progress domain.CompositionProgress) error
+}
+
+// compositionProgressStep is how many percent a pass advances between the reports it stores
+const compositionProgressStep = 10
+
+// NewService creates a composition service running ffmpeg directly
+func NewService(
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	tmpBudget int64,
+	jobEvents repository.JobEventAppender,
+	jobProgress ProgressStore,
+	clipQuality ClipQualityGate,
+	logger *zap.Logger,
+) *Service {
+	return &Service{
+		s3Service:    s3Service,
+		assetsBucket: assetsBucket,
+		tmpBudget:    tmpBudget,
+		events:       jobEvents,
+		progress:     jobProgress,
+		runner:       ffmpegexec.ExecRunner{},
+		clipQuality:  clipQuality,
+		logger:       logger,
+	}
+}
+
+// log returns the service's logger with the request and job IDs ctx carries
+func (s *Service) log(ctx context.Context) *zap.Logger {
+	return trace.Logger(ctx, s.logger)
+}
+
+// recordWarning records something that failed without failing job
+func (s *Service) recordWarning(ctx context.Context, job *domain.Job, message string) {
+	repository.RecordJobEvent(ctx, s.events, s.logger, job.JobID, domain.JobEvent{
+		Type:    domain.JobEventWarning,
+		Stage:   job.Stage.String(),
+		Message: message,
+	})
+}
+
+// reportProgress returns a Progress callback storing how far pass has got through a video of
+// duration seconds on job, each time it advances compositionProgressStep percent and when it
+// finishes. It returns nil, so ffmpeg isn't asked for progress, when there is no store.
+func (s *Service) reportProgress(ctx context.Context, job *domain.Job, pass string, duration float64) func(ffmpegexec.Progress) {
+	if s.progress == nil {
+		return nil
+	}
+	total := time.Duration(duration * float64(time.Second))
+	stored := -1
+	return func(p ffmpegexec.Progress) {
+		percent := int(p.Fraction(total) * 100)
+		if stored >= 0 && percent < stored+compositionProgressStep && !p.Done {
+			return
+		}
+		stored = percent
+		if err := s.progress.UpdateJobCompositionProgress(ctx, job.JobID, domain.CompositionProgress{Pass: pass, Percent: percent}); err != nil {
+			s.log(ctx).Warn("Failed to record composition progress",
+				zap.String("pass", pass),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// lastFrameArgs writes the last frame of the video at videoPath to framePath as a JPEG
+func lastFrameArgs(videoPath, framePath string) []string {
+	return []string{
+		"-sseof", "-1",
+		"-i", videoPath,
+		"-update", "1",
+		"-q:v", "2",
+		"-y", framePath,
+	}
+}
+
+// concatArgs stream-copies the clips listed in concatFile into output, video track only
+func concatArgs(concatFile, output string) []string {
+	return []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", concatFile,
+		"-c:v", "copy",
+		"-an", // Explicitly drop audio streams (the job's audio is mixed in afterwards)
+		"-y", output,
+	}
+}
+
+// interpolateArgs re-encodes input at 30fps, for videos the overlay pass didn't already
+func interpolateArgs(input, output string) []string {
+	return []string{
+		"-i", input,
+		"-vf", "fps=30",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-an",
+		"-y", output,
+	}
+}
+
+// webmArgs transcodes the final video to VP9 and Opus for web delivery
+func webmArgs(input, output string) []string {
+	return []string{
+		"-i", input,
+		"-c:v", "libvpx-vp9",
+		"-c:a", "libopus",
+		"-b:a", "128k",
+		"-crf", "30",
+		"-b:v", "0",
+		"-row-mt", "1",
+		"-y", output,
+	}
+}
+
+// ExtractLastFrame writes the last frame of the video at videoPath to framePath as a JPEG
+func ExtractLastFrame(ctx context.Context, videoPath, framePath string) error {
+	return ffmpegexec.Exec(ctx, "last_frame", ffmpegexec.Spec{Args: lastFrameArgs(videoPath, framePath), Timeout: ffmpegexec.FrameTimeout})
+}
+
+// SideEffectsStartTime returns when an audio disclaimer begins in a video of
+// actualDuration seconds: before the music tail, once the disclaimer has been read. Specs
+// without audio return 0.
+func SideEffectsStartTime(actualDuration float64, disclaimerSpec *domain.DisclaimerSpec) float64 {
+	if disclaimerSpec == nil || !disclaimerSpec.UseAudio {
+		return 0 // No audio disclaimer
+	}
+	musicTail := service.CalculateMusicTail(int(actualDuration))
+	return actualDuration - disclaimerSpec.AudioDuration - musicTail
+}
+
+// DownloadAndProcessClip downloads a generated clip, verifies it against expectedDuration
+// (seconds), the service's ClipQualityGate and the job's aspect ratio, extracts its last frame
+// and uploads both to the job's bucket. A black or frozen clip is returned as a
+// *ClipQualityError without being stored. A clip of the wrong shape is handled by aspectPolicy:
+// normalized before anything is taken from it, or returned as a *ClipAspectError without being
+// stored. Versions above 0 are stored under versioned keys, so earlier versions stay intact.
+// Returns: (clipURL, lastFrameURL, error) - lastFrameURL is a presigned URL for the video model,
+// and empty when the frame couldn't be extracted or stored
+func (s *Service) DownloadAndProcessClip(
+	ctx context.Context,
+	job *domain.Job,
+	clipNumber int,
+	version int,
+	videoURL string,
+	expectedDuration float64,
+	aspectPolicy ClipAspectPolicy,
+) (string, string, error) {
+	ctx = ffmpegexec.WithRunner(ctx, s.runner)
+	logger := s.log(ctx)
+	bucket := repository.JobAssetBucket(job, s.assetsBucket)
+
+	// Create temp directory
+	tmpDir := filepath.Join("/tmp", job.JobID, fmt.Sprintf("clip-%d", clipNumber))
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Download video from Replicate URL
+	logger.Info("Downloading video from Replicate",
+		zap.Int("clip", clipNumber),
+		zap.String("url", videoURL),
+	)
+	videoPath := filepath.Join(tmpDir, "video.mp4")
+	if err := downloadVerifiedVideo(ctx, logger, videoURL, videoPath, expectedDuration); err != nil {
+		return "", "", fmt.Errorf("failed to download video: %w", err)
+	}
+	// Checked before normalizing, whose padding would darken the clip
+	if err := checkClipQuality(ctx, logger, s.clipQuality, clipNumber, videoPath, expectedDuration); err != nil {
+		return "", "", err
+	}
+	videoPath, err := conformClipAspect(ctx, logger, job, clipNumber, videoPath, tmpDir, aspectPolicy)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Extract last frame using ffmpeg
+	logger.Info("Extracting last frame with ffmpeg",
+		zap.Int("clip", clipNumber),
+	)
+	lastFramePath := filepath.Join(tmpDir, "last_frame.jpg")
+	if err := ExtractLastFrame(ctx, videoPath, lastFramePath); err != nil {
+		logger.Warn("Failed to extract last frame, continuing without it",
+			zap.Int("clip", clipNumber),
+			zap.Error(err),
+		)
+		lastFramePath = "" // Continue without last frame
+	} else {
+		logger.Info("Last frame extracted successfully",
+			zap.Int("clip", clipNumber),
+		)
+	}
+
+	// Upload video to S3
+	logger.Info("Uploading video to S3",
+		zap.Int("clip", clipNumber),
+	)
+	videoS3Key, lastFrameS3Key := SceneClipKey(job, clipNumber), SceneThumbnailKey(job, clipNumber)
+	if version > 0 {
+		videoS3Key, lastFrameS3Key = VersionedSceneClipKey(job, clipNumber, version), VersionedSceneThumbnailKey(job, clipNumber, version)
+	}
+	videoS3URL, err := s.s3Service.UploadJobAsset(ctx, bucket, videoS3Key, videoPath, "video/mp4")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload video to S3: %w", err)
+	}
+
+	// Upload last frame to S3 (if extracted)
+	var lastFrameS3URL string
+	if lastFramePath != "" {
+		_, err = s.s3Service.UploadJobAsset(ctx, bucket, lastFrameS3Key, lastFramePath, "image/jpeg")
+		if err != nil {
+			logger.Warn("Failed to upload last frame, continuing",
+				zap.Error(err),
+			)
+			lastFrameS3URL = "" // Continue without last frame URL
+		} else {
+			// Generate presigned URL for Veo API access (valid for 1 hour)
+			lastFrameS3URL, err = s.s3Service.GetPresignedURLInBucket(ctx, bucket, lastFrameS3Key, 1*time.Hour)
+			if err != nil {
+				logger.Warn("Failed to generate presigned URL for last frame, continuing",
+					zap.Error(err),
+				)
+				lastFrameS3URL = "" // Continue without last frame URL
+			}
+		}
+	}
+
+	logger.Info("Video processed and uploaded",
+		zap.Int("clip", clipNumber),
+		zap.String("s3_url", videoS3URL),
+	)
+
+	return videoS3URL, lastFrameS3URL, nil
+}
+
+// ComposeFinalVideo concatenates clips into comp's final videos: clips that don't share one
+// format are normalized first, the end card is appended, the disclaimer, captions and logo are
+// drawn in a single overlay pass, and the job's music and narrator are mixed in before the
+// video is encoded to the job's output spec. A WebM is transcoded from the result. Clips of a
+// job that renders transitions are blended rather than stream-copied wherever its script asks.
+// Returns: (mp4Key, webmKey, error) - webmKey may be empty if WebM encoding fails
+func (s *Service) ComposeFinalVideo(
+	ctx context.Context,
+	job *domain.Job,
+	clips []ClipVideo,
+	comp Plan,
+) (string, string, error) {
+	ctx = ffmpegexec.WithRunner(ctx, s.runner)
+	logger := s.log(ctx)
+	jobID := job.JobID
+	bucket := repository.JobAssetBucket(job, s.assetsBucket) // User uploads (logo, start image) stay in s.assetsBucket
+
+	// Blended transitions shorten the video; the end card is part of the timeline the narration
+	// and music were fitted to
+	totalDuration := ClipTimeline(job, clips)
+	contentDuration := totalDuration
+	totalDuration += EndCardDuration(job)
+
+	// Determine text overlay settings based on disclaimer tier
+	var overlayText string
+	var overlayStart float64
+
+	if job.DisclaimerSpec != nil {
+		switch job.DisclaimerSpec.Tier {
+		case domain.DisclaimerTierTextOnly:
+			// Text-only: show abbreviated disclaimer for last 4-5 seconds
+			overlayText = job.DisclaimerSpec.AudioText // Abbreviated version
+			overlayStart = math.Max(0, totalDuration-5.0)
+			logger.Info("Using text-only disclaimer overlay",
+				zap.Float64("overlay_start", overlayStart),
+				zap.String("tier", string(job.DisclaimerSpec.Tier)),
+			)
+
+		case domain.DisclaimerTierShort, domain.DisclaimerTierFull:
+			// Audio disclaimer: show full text starting when audio disclaimer begins
+			overlayText = job.DisclaimerSpec.FullText
+			overlayStart = SideEffectsStartTime(totalDuration, job.DisclaimerSpec)
+			logger.Info("Using audio-synced disclaimer overlay",
+				zap.Float64("overlay_start", overlayStart),
+				zap.String("tier", string(job.DisclaimerSpec.Tier)),
+			)
+		}
+	} else {
+		// Fallback to existing behavior for non-pharmaceutical ads or legacy jobs
+		overlayText = job.SideEffectsText
+		overlayStart = job.SideEffectsStartTime
+	}
+
+	trimmedText := strings.TrimSpace(overlayText)
+	spec := compositionSpec{OverlayText: trimmedText, OverlayStart: overlayStart}
+
+	logger.Info("Composing final video",
+		zap.Int("num_clips", len(clips)),
+		zap.Bool("has_side_effects", trimmedText != ""),
+		zap.Float64("side_effects_start_time", overlayStart),
+		zap.Float64("video_duration_estimate", totalDuration),
+	)
+
+	if overlayStart > 0 && trimmedText == "" {
+		return "", "", fmt.Errorf("side effects text is required when sideEffectsStartTime is provided")
+	}
+
+	// Fail fast before downloading anything if the job can't fit in /tmp
+	if err := preflightCompositionTmp(ctx, s.s3Service, bucket, logger, job, clips, s.tmpBudget); err != nil {
+		return "", "", err
+	}
+
+	tmpDir := filepath.Join("/tmp", jobID, "composition")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ledger := newTmpLedger(s.tmpBudget, logger)
+
+	// Download all clips from S3
+	logger.Info("Downloading clips from S3 for composition",
+		zap.Int("num_clips", len(clips)),
+	)
+	var clipPaths []string
+	for i, clip := range clips {
+		clipPath := filepath.Join(tmpDir, fmt.Sprintf("clip-%d.mp4", i+1))
+		if err := s.s3Service.DownloadFile(ctx, bucket, s3util.Key(clip.VideoURL), clipPath); err != nil {
+			return "", "", fmt.Errorf("failed to download clip %d: %w", i+1, err)
+		}
+		ledger.add(clipPath)
+		clipPaths = append(clipPaths, clipPath)
+	}
+
+	// Clips that don't share one format can't be stream-copied into a single video
+	clipPaths, concatFormat, durations, err := normalizeClipsForConcat(ctx, logger, job, clips, clipPaths, tmpDir, ledger)
+	if err != nil {
+		return "", "", err
+	}
+	// Blends are fitted to the clips as downloaded, which is what they overlap
+	boundaries := SceneBoundaries(job, job.Scenes, durations)
+	concatDuration := SumDurations(durations) - transitions.Overlap(boundaries)
+
+	// The logo is drawn by the overlay pass and can also appear on the end card
+	logoPath := downloadLogo(ctx, s.s3Service, s.assetsBucket, logger, job, tmpDir)
+	if logoPath != "" {
+		ledger.add(logoPath)
+	}
+	endCardPath := ""
+	if len(clipPaths) > 0 {
+		endCardPath = renderEndCard(ctx, s.s3Service, s.assetsBucket, logger, job, clipPaths[0], logoPath, tmpDir)
+	}
+	if endCardPath != "" {
+		ledger.add(endCardPath)
+		clipPaths = append(clipPaths, endCardPath)
+		durations = append(durations, EndCardDuration(job)) // Cut to from the last scene
+		concatDuration += EndCardDuration(job)
+	}
+
+	finalVideo := filepath.Join(tmpDir, "final.mp4")
+	if transitions.HasBlends(boundaries) {
+		if err := s.joinWithTransitions(ctx, job, clipPaths, durations, boundaries, concatFormat.FPS, concatDuration, finalVideo); err != nil {
+			return "", "", err
+		}
+	} else if err := s.concatClips(ctx, job, clipPaths, tmpDir, concatDuration, finalVideo); err != nil {
+		return "", "", err
+	}
+	if err := verifyConcatDuration(ctx, finalVideo, concatDuration); err != nil {
+		logger.Error("Concatenated video failed the duration check",
+			zap.Int("width", concatFormat.Width),
+			zap.Int("height", concatFormat.Height),
+			zap.Error(err),
+		)
+		return "", "", err
+	}
+	// The joined output holds every clip, so the sources can go
+	ledger.consume(finalVideo, clipPaths...)
+
+	// Detect source FPS for interpolation decision
+	sourceFPS := probeVideoFPS(ctx, finalVideo)
+	needsInterpolation := sourceFPS > 0 && sourceFPS < 30
+	logger.Info("Video FPS detected",
+		zap.Float64("source_fps", sourceFPS),
+		zap.Bool("needs_interpolation", needsInterpolation),
+	)
+
+	interpolated := false
+	burnCaptions := job.BurnCaptions && len(job.Captions) > 0
+	if (trimmedText != "" || burnCaptions || logoPath != "") && totalDuration > 0 {
+		videoWidth, videoHeight, err := ProbeVideoDimensions(ctx, finalVideo)
+		if err != nil {
+			logger.Warn("Failed to probe video dimensions, using defaults",
+				zap.Error(err),
+			)
+			videoWidth = 1920
+			videoHeight = 1080
+		}
+
+		var pass overlayPass
+		config, err := buildDrawtextConfig(logger, trimmedText, overlayStart, totalDuration, videoWidth, videoHeight, job.SideEffectsOverflow)
+		if err != nil {
+			return "", "", err
+		}
+		spec.Overlay = config
+		if config != nil {
+			logger.Info("Applying side effects text overlay",
+				zap.Float64("overlay_start", config.OverlayStart),
+				zap.Float64("overlay_end", config.OverlayEnd),
+				zap.Int("rune_count", config.RuneCount),
+				zap.Float64("base_font_size", config.BaseFontSize),
+				zap.String("overflow", config.Overflow),
+			)
+			pass.Drawtext = append(pass.Drawtext, config.Filter)
+		}
+		if captions := burnedCaptionsFilter(logger, job, videoWidth, videoHeight, config); captions != "" {
+			logger.Info("Burning in narrator captions",
+				zap.Int("cues", len(job.Captions)),
+			)
+			pass.Drawtext = append(pass.Drawtext, captions)
+		}
+		if logoPath != "" {
+			pass.Logo = newLogoPlacement(job.LogoOverlay, logoPath, videoWidth, videoHeight)
+			if endCardPath != "" && (pass.Logo.End == 0 || pass.Logo.End > contentDuration) {
+				// The end card has its own logo layout
+				pass.Logo.End = contentDuration
+			}
+			logger.Info("Applying logo overlay",
+				zap.String("position", pass.Logo.Position),
+				zap.Int("width", pass.Logo.Width),
+			)
+		}
+
+		if !pass.empty() {
+			videoWithText := filepath.Join(tmpDir, "video_with_text.mp4")
+			// Combine FPS interpolation with the overlays if needed (single re-encode)
+			if needsInterpolation {
+				pass.FPS = 30
+				logger.Info("Combining FPS interpolation with text overlay")
+			}
+			spec.Filtergraph = pass.filterGraph()
+			if err := ffmpegexec.Exec(ctx, "text_overlay", ffmpegexec.Spec{
+				Args:        pass.args(finalVideo, videoWithText),
+				Timeout:     ffmpegexec.EncodeTimeout,
+				Progress:    s.reportProgress(ctx, job, "text_overlay", concatDuration),
+				LowPriority: true,
+			}); err != nil {
+				logger.Error("ffmpeg text overlay failed",
+					zap.String("output", ffmpegexec.Stderr(err)),
+					zap.Error(err),
+				)
+				return "", "", fmt.Errorf("ffmpeg text overlay failed: %w", err)
+			}
+
+			logger.Info("Text overlay applied successfully")
+			ledger.consume(videoWithText, finalVideo)
+			ledger.release(logoPath)
+			finalVideo = videoWithText
+			interpolated = needsInterpolation
+		}
+	} else if trimmedText == "" {
+		logger.Info("Skipping text overlay (no side effects text)")
+	} else {
+		logger.Warn("Skipping text overlay (unknown video duration)",
+			zap.Float64("video_duration", totalDuration),
+		)
+	}
+
+	// Apply FPS interpolation if needed and not already done via text overlay
+	if needsInterpolation && !interpolated {
+		logger.Info("Applying standalone FPS interpolation to 30fps",
+			zap.Float64("source_fps", sourceFPS),
+		)
+		interpolatedVideo := filepath.Join(tmpDir, "interpolated.mp4")
+		if err := ffmpegexec.Exec(ctx, "interpolate", ffmpegexec.Spec{
+			Args:        interpolateArgs(finalVideo, interpolatedVideo),
+			Timeout:     ffmpegexec.EncodeTimeout,
+			Progress:    s.reportProgress(ctx, job, "interpolate", concatDuration),
+			LowPriority: true,
+		}); err != nil {
+			logger.Warn("FPS interpolation failed, using original video",
+				zap.Float64("source_fps", sourceFPS),
+				zap.String("output", ffmpegexec.Stderr(err)),
+				zap.Error(err),
+			)
+			// Graceful fallback: continue with original FPS video
+		} else {
+			logger.Info("FPS interpolation complete")
+			ledger.consume(interpolatedVideo, finalVideo)
+			finalVideo = interpolatedVideo
+		}
+	}
+
+	// AUDIO MUXING: Download and mix audio tracks into video
+	muxedVideo := muxJobAudio(ctx, s.s3Service, bucket, logger, job, finalVideo, tmpDir)
+	ledger.consume(muxedVideo, finalVideo)
+	finalVideo = muxedVideo
+
+	// Re-encode to the job's output spec, if it has one, and record the final encoding
+	encodedVideo, err := encodeJobOutput(ctx, logger, job, finalVideo, tmpDir)
+	if err != nil {
+		return "", "", err
+	}
+	if encodedVideo != finalVideo {
+		ledger.consume(encodedVideo, finalVideo)
+		finalVideo = encodedVideo
+	}
+
+	spec.Duration = concatDuration
+	if err := verifyComposition(ctx, job, spec, finalVideo); err != nil {
+		logger.Error("Final video failed verification",
+			zap.Float64("expected_duration", spec.Duration),
+			zap.Bool("has_overlay", spec.Overlay != nil),
+			zap.Error(err),
+		)
+		return "", "", err
+	}
+	if s.composed != nil {
+		s.composed(job, spec)
+	}
+
+	// Upload final MP4 video to S3
+	logger.Info("Uploading final MP4 video to S3")
+	mp4S3Key := comp.VideoKey
+	if err := uploadComposedVideo(ctx, s.s3Service, bucket, comp, mp4S3Key, finalVideo, comp.ContentType, concatDuration, job.Encoding); err != nil {
+		return "", "", fmt.Errorf("failed to upload MP4 video: %w", err)
+	}
+
+	// Transcode to WebM (VP9) for web-optimized delivery
+	logger.Info("Transcoding to WebM format")
+	webmVideo := filepath.Join(tmpDir, "final.webm")
+	var webmS3Key string
+	if err := ffmpegexec.Exec(ctx, "webm", ffmpegexec.Spec{
+		Args:        webmArgs(finalVideo, webmVideo),
+		Timeout:     ffmpegexec.EncodeTimeout,
+		Progress:    s.reportProgress(ctx, job, "webm", concatDuration),
+		LowPriority: true,
+	}); err != nil {
+		logger.Warn("WebM transcode failed, MP4 still available",
+			zap.String("output", ffmpegexec.Stderr(err)),
+			zap.Error(err),
+		)
+		s.recordWarning(ctx, job, "WebM transcode failed; only the MP4 is available")
+		// Don't fail - MP4 is still available
+	} else {
+		ledger.add(webmVideo)
+
+		// Upload WebM to S3
+		webmS3Key = comp.WebMKey
+		if err := uploadComposedVideo(ctx, s.s3Service, bucket, comp, webmS3Key, webmVideo, "video/webm", concatDuration, nil); err != nil {
+			logger.Warn("Failed to upload WebM, MP4 still available",
+				zap.Error(err),
+			)
+			s.recordWarning(ctx, job, "WebM upload failed; only the MP4 is available")
+			webmS3Key = "" // Clear key since upload failed
+		} else {
+			logger.Info("WebM uploaded successfully",
+				zap.String("webm_key", webmS3Key),
+			)
+		}
+	}
+
+	logger.Info("Video composition complete",
+		zap.String("mp4_key", mp4S3Key),
+		zap.String("webm_key", webmS3Key),
+		zap.Int64("tmp_peak_bytes", ledger.peak),
+	)
+
+	return mp4S3Key, webmS3Key, nil
+}
+
+// concatClips stream-copies clipPaths into output with the concat demuxer, video track only
+func (s *Service) concatClips(ctx context.Context, job *domain.Job, clipPaths []string, tmpDir string, duration float64, output string) error {
+	logger := s.log(ctx)
+	concatFile := filepath.Join(tmpDir, "concat.txt")
+	f, err := os.Create(concatFile)
+	if err != nil {
+		return fmt.Errorf("failed to create concat file: %w", err)
+	}
+	fmt.Fprint(f, concatList(clipPaths))
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close concat file: %w", err)
+	}
+
+	logger.Info("Concatenating video clips (video track only)",
+		zap.Int("num_clips", len(clipPaths)),
+	)
+	if err := ffmpegexec.Exec(ctx, "concat", ffmpegexec.Spec{
+		Args:        concatArgs(concatFile, output),
+		Timeout:     ffmpegexec.EncodeTimeout,
+		Progress:    s.reportProgress(ctx, job, "concat", duration),
+		LowPriority: true,
+	}); err != nil {
+		logger.Error("ffmpeg concat failed",
+			zap.String("output", ffmpegexec.Stderr(err)),
+			zap.Error(err),
+		)
+		return fmt.Errorf("ffmpeg concat failed: %w", err)
+	}
+	return nil
+}
+
+// joinWithTransitions joins clipPaths, lasting durations, into output with the blends among
+// boundaries, re-encoding the video once; its cuts are concatenated in the same filtergraph
+func (s *Service) joinWithTransitions(
+	ctx context.Context,
+	job *domain.Job,
+	clipPaths []string,
+	durations []float64,
+	boundaries []transitions.Boundary,
+	fps float64,
+	duration float64,
+	output string,
+) error {
+	logger := s.log(ctx)
+	logger.Info("Joining video clips with transitions (video track only)",
+		zap.Int("num_clips", len(clipPaths)),
+		zap.Float64("transition_overlap", transitions.Overlap(boundaries)),
+	)
+	graph := transitions.FilterGraph(durations, boundaries, fps)
+	if err := ffmpegexec.Exec(ctx, "transitions", ffmpegexec.Spec{
+		Args:        transitionArgs(clipPaths, graph, output),
+		Timeout:     ffmpegexec.EncodeTimeout,
+		Progress:    s.reportProgress(ctx, job, "transitions", duration),
+		LowPriority: true,
+	}); err != nil {
+		logger.Error("ffmpeg transitions failed",
+			zap.String("filtergraph", graph),
+			zap.String("output", ffmpegexec.Stderr(err)),
+			zap.Error(err),
+		)
+		return fmt.Errorf("ffmpeg transitions failed: %w", err)
+	}
+	return nil
+}
+
+// requiresDisclaimerOverlay reports whether job is a pharmaceutical ad, whose final video must
+// show its side effects disclosure
+func requiresDisclaimerOverlay(job *domain.Job) bool {
+	return job.SideEffects != "" || job.DisclaimerSpec != nil
+}
+
+// verifyComposition checks the final video at path against the spec of the composition that
+// produced it: it must last about as long as its clips and end card, and a pharmaceutical ad's
+// disclosure must have been drawn from the time the job's disclaimer settings give
+func verifyComposition(ctx context.Context, job *domain.Job, spec compositionSpec, path string) error {
+	format, err := ProbeClipFormat(ctx, path)
+	if err != nil {
+		return fmt.Errorf("final video is not readable: %w", err)
+	}
+	if spec.Duration > 0 && math.Abs(format.Duration-spec.Duration) > ConcatDurationTolerance {
+		return fmt.Errorf("final video is %.2fs, expected about %.2fs", format.Duration, spec.Duration)
+	}
+	if !requiresDisclaimerOverlay(job) {
+		return nil
+	}
+	return checkDisclaimerOverlay(spec)
+}
+
+// checkDisclaimerOverlay checks that a pharmaceutical ad's composition drew its side effects
+// overlay in the filtergraph it ran, starting where planned
+func checkDisclaimerOverlay(spec compositionSpec) error {
+	switch {
+	case spec.OverlayText == "":
+		return fmt.Errorf("pharmaceutical ad has no side effects text to overlay")
+	case spec.Overlay == nil:
+		return fmt.Errorf("side effects overlay was not built (start %.2fs of %.2fs)", spec.OverlayStart, spec.Duration)
+	case spec.OverlayStart > 0 && math.Abs(spec.Overlay.OverlayStart-spec.OverlayStart) > 0.01:
+		return fmt.Errorf("side effects overlay starts at %.2fs, expected %.2fs", spec.Overlay.OverlayStart, spec.OverlayStart)
+	case !strings.Contains(spec.Filtergraph, spec.Overlay.Filter):
+		return fmt.Errorf("side effects overlay is missing from the composed filtergraph")
+	}
+	return nil
+}
+
+// ClipVideo represents a generated video clip
+type ClipVideo struct {
+	VideoURL     string
+	LastFrameURL string
+	Duration     float64
+}
@@ -0,0 +1,576 @@
+package composition
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingRunner stands in for ffmpeg and ffprobe. Each ffmpeg command is recorded and writes a
+// placeholder to its output, unless the output's name is in fail; ffprobe describes every file
+// as a 1080p h264 video at fps, clips lasting clipDuration and everything else total. Source
+// clips are width x height instead when those are set. Commands asking for progress get a
+// report halfway through and a final one.
+type recordingRunner struct {
+	mu           sync.Mutex
+	calls        [][]string // ffmpeg arguments, in order
+	unbounded    []string   // Outputs of ffmpegexec runs without a timeout
+	fps          string
+	clipDuration float64
+	total        float64
+	width        int
+	height       int
+	fail         map[string]bool
+}
+
+var sourceClipName = regexp.MustCompile(`^(clip-\d+\.mp4|video\.mp4)$`)
+
+func (r *recordingRunner) Run(cmd *exec.Cmd) error {
+	_, err := r.CombinedOutput(cmd)
+	return err
+}
+
+func (r *recordingRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	return r.CombinedOutput(cmd)
+}
+
+func (r *recordingRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return r.run(cmd.Args[0], cmd.Args[1:])
+}
+
+func (r *recordingRunner) Exec(ctx context.Context, spec ffmpegexec.Spec) error {
+	name := spec.Name
+	if name == "" {
+		name = "ffmpeg"
+	}
+	if spec.Timeout <= 0 {
+		r.mu.Lock()
+		r.unbounded = append(r.unbounded, filepath.Base(spec.Args[len(spec.Args)-1]))
+		r.mu.Unlock()
+	}
+	if spec.Progress != nil {
+		spec.Progress(ffmpegexec.Progress{OutTime: time.Duration(r.total * float64(time.Second) / 2)})
+	}
+	if _, err := r.run(name, spec.Args); err != nil {
+		return err
+	}
+	if spec.Progress != nil {
+		spec.Progress(ffmpegexec.Progress{OutTime: time.Duration(r.total * float64(time.Second)), Done: true})
+	}
+	return nil
+}
+
+func (r *recordingRunner) run(name string, args []string) ([]byte, error) {
+	path := args[len(args)-1]
+	if name == "ffprobe" {
+		return r.probe(args, path)
+	}
+
+	r.mu.Lock()
+	r.calls = append(r.calls, args)
+	r.mu.Unlock()
+	if r.fail[filepath.Base(path)] {
+		return []byte("encoder failed"), errors.New("exit status 1")
+	}
+	return nil, os.WriteFile(path, []byte("video"), 0o644)
+}
+
+func (r *recordingRunner) probe(args []string, path string) ([]byte, error) {
+	duration, width, height := r.total, 1920, 1080
+	if sourceClipName.MatchString(filepath.Base(path)) {
+		duration = r.clipDuration
+		if r.width > 0 {
+			width, height = r.width, r.height
+		}
+	}
+	entries := args[slices.Index(args, "-show_entries")+1]
+	switch entries {
+	case "stream=r_frame_rate":
+		return []byte(r.fps + "\n"), nil
+	case "stream=width,height":
+		return []byte(fmt.Sprintf("%dx%d\n", width, height)), nil
+	}
+	return json.Marshal(map[string]any{
+		"streams": []map[string]any{{
+			"codec_type":   "video",
+			"codec_name":   "h264",
+			"width":        width,
+			"height":       height,
+			"pix_fmt":      "yuv420p",
+			"r_frame_rate": r.fps,
+		}},
+		"format": map[string]any{"duration": fmt.Sprint(duration)},
+	})
+}
+
+// ffmpegCall returns the recorded command writing output
+func (r *recordingRunner) ffmpegCall(t *testing.T, output string) []string {
+	t.Helper()
+	for _, args := range r.calls {
+		if args[len(args)-1] == output {
+			return args
+		}
+	}
+	t.Fatalf("no ffmpeg command wrote %s; ran %v", output, r.outputs())
+	return nil
+}
+
+// outputs lists the files the recorded commands wrote, by name
+func (r *recordingRunner) outputs() []string {
+	names := make([]string, len(r.calls))
+	for i, args := range r.calls {
+		names[i] = filepath.Base(args[len(args)-1])
+	}
+	return names
+}
+
+// composeWithRunner composes job's two 4s clips with runner standing in for ffmpeg
+func composeWithRunner(t *testing.T, job *domain.Job, runner *recordingRunner, events repository.JobEventAppender) (string, string, error) {
+	t.Helper()
+	return composeWithService(t, job, &Service{assetsBucket: "assets", events: events, runner: runner, logger: zap.NewNop()})
+}
+
+// composeWithService composes job's two 4s clips with svc, storing them in a fresh bucket
+func composeWithService(t *testing.T, job *domain.Job, svc *Service) (string, string, error) {
+	t.Helper()
+	s3Service := newComposeTestS3(t)
+	svc.s3Service = s3Service
+	clips := []ClipVideo{
+		uploadComposeClip(t, s3Service, job, 1, "clip one"),
+		uploadComposeClip(t, s3Service, job, 2, "clip two"),
+	}
+	if job.AudioURL != "" {
+		path := filepath.Join(t.TempDir(), "music.mp3")
+		require.NoError(t, os.WriteFile(path, []byte("music"), 0o644))
+		url, err := s3Service.UploadFile(context.Background(), "assets", AudioKey(job), path, "audio/mpeg")
+		require.NoError(t, err)
+		job.AudioURL = url
+	}
+
+	comp := Plan{Hash: "abc123", VideoKey: FinalVideoKey(job, "abc123"), ContentType: "video/mp4", WebMKey: FinalWebMKey(job, "abc123")}
+	return svc.ComposeFinalVideo(context.Background(), job, clips, comp)
+}
+
+func TestComposeFinalVideo_FFmpegArguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     *domain.Job
+		fps     string
+		outputs []string
+		check   func(t *testing.T, runner *recordingRunner, tmpDir string)
+	}{
+		{
+			name:    "30fps without overlays is stream-copied",
+			job:     &domain.Job{JobID: "job-compose-plain", UserID: "user-1", AspectRatio: domain.AspectRatio16x9},
+			fps:     "30/1",
+			outputs: []string{"final.mp4", "final.webm"},
+			check: func(t *testing.T, runner *recordingRunner, tmpDir string) {
+				require.Equal(t, []string{
+					"-f", "concat",
+					"-safe", "0",
+					"-i", filepath.Join(tmpDir, "concat.txt"),
+					"-c:v", "copy",
+					"-an",
+					"-y", filepath.Join(tmpDir, "final.mp4"),
+				}, runner.ffmpegCall(t, filepath.Join(tmpDir, "final.mp4")))
+				require.Equal(t, []string{
+					"-i", filepath.Join(tmpDir, "final.mp4"),
+					"-c:v", "libvpx-vp9",
+					"-c:a", "libopus",
+					"-b:a", "128k",
+					"-crf", "30",
+					"-b:v", "0",
+					"-row-mt", "1",
+					"-y", filepath.Join(tmpDir, "final.webm"),
+				}, runner.ffmpegCall(t, filepath.Join(tmpDir, "final.webm")))
+			},
+		},
+		{
+			name:    "24fps without overlays is interpolated on its own",
+			job:     &domain.Job{JobID: "job-compose-24fps", UserID: "user-1", AspectRatio: domain.AspectRatio16x9},
+			fps:     "24/1",
+			outputs: []string{"final.mp4", "interpolated.mp4", "final.webm"},
+			check: func(t *testing.T, runner *recordingRunner, tmpDir string) {
+				require.Equal(t, []string{
+					"-i", filepath.Join(tmpDir, "final.mp4"),
+					"-vf", "fps=30",
+					"-c:v", "libx264",
+					"-preset", "medium",
+					"-crf", "21",
+					"-an",
+					"-y", filepath.Join(tmpDir, "interpolated.mp4"),
+				}, runner.ffmpegCall(t, filepath.Join(tmpDir, "interpolated.mp4")))
+				require.Equal(t, filepath.Join(tmpDir, "interpolated.mp4"), runner.ffmpegCall(t, filepath.Join(tmpDir, "final.webm"))[1])
+			},
+		},
+		{
+			name: "24fps with side effects is interpolated by the overlay pass",
+			job: &domain.Job{
+				JobID:                "job-compose-overlay",
+				UserID:               "user-1",
+				AspectRatio:          domain.AspectRatio16x9,
+				SideEffectsText:      "May cause drowsiness.",
+				SideEffectsStartTime: 6,
+			},
+			fps:     "24/1",
+			outputs: []string{"final.mp4", "video_with_text.mp4", "final.webm"},
+			check: func(t *testing.T, runner *recordingRunner, tmpDir string) {
+				args := runner.ffmpegCall(t, filepath.Join(tmpDir, "video_with_text.mp4"))
+				require.Equal(t, []string{"-i", filepath.Join(tmpDir, "final.mp4")}, args[:2])
+				filter := args[slices.Index(args, "-vf")+1]
+				require.Contains(t, filter, "drawtext=")
+				require.Contains(t, filter, "between(t,6.00,8.00)")
+				require.Contains(t, filter, "fps=30")
+				require.Contains(t, strings.Join(args, " "), "-crf 21")
+			},
+		},
+		{
+			name: "audio disclaimer overlay starts with the spoken disclaimer",
+			job: &domain.Job{
+				JobID:       "job-compose-disclaimer",
+				UserID:      "user-1",
+				AspectRatio: domain.AspectRatio16x9,
+				DisclaimerSpec: &domain.DisclaimerSpec{
+					Tier:          domain.DisclaimerTierShort,
+					UseAudio:      true,
+					FullText:      "Side effects include nausea.",
+					AudioDuration: 3,
+				},
+			},
+			fps:     "30/1",
+			outputs: []string{"final.mp4", "video_with_text.mp4", "final.webm"},
+			check: func(t *testing.T, runner *recordingRunner, tmpDir string) {
+				// 8s less the 3s disclaimer and the 1s music tail
+				args := runner.ffmpegCall(t, filepath.Join(tmpDir, "video_with_text.mp4"))
+				filter := args[slices.Index(args, "-vf")+1]
+				require.Contains(t, filter, "between(t,4.00,8.00)")
+				require.NotContains(t, filter, "fps=30")
+			},
+		},
+		{
+			name: "the WebM is transcoded from the video with its audio",
+			job: &domain.Job{
+				JobID:       "job-compose-music",
+				UserID:      "user-1",
+				AspectRatio: domain.AspectRatio16x9,
+				AudioURL:    "pending",
+			},
+			fps:     "30/1",
+			outputs: []string{"final.mp4", "video_with_audio.mp4", "final.webm"},
+			check: func(t *testing.T, runner *recordingRunner, tmpDir string) {
+				webm := runner.ffmpegCall(t, filepath.Join(tmpDir, "final.webm"))
+				require.Equal(t, filepath.Join(tmpDir, "video_with_audio.mp4"), webm[1])
+				require.NotContains(t, webm, "-an")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &recordingRunner{fps: tt.fps, clipDuration: 4, total: 8}
+			mp4Key, webmKey, err := composeWithRunner(t, tt.job, runner, nil)
+			require.NoError(t, err)
+			require.Equal(t, FinalVideoKey(tt.job, "abc123"), mp4Key)
+			require.Equal(t, FinalWebMKey(tt.job, "abc123"), webmKey)
+
+			require.Equal(t, tt.outputs, runner.outputs())
+			require.Empty(t, runner.unbounded, "every composition pass has its own timeout")
+			tt.check(t, runner, filepath.Join("/tmp", tt.job.JobID, "composition"))
+		})
+	}
+}
+
+func TestComposeFinalVideo_RecordsFailedWebM(t *testing.T) {
+	job := &domain.Job{JobID: "job-compose-webm", UserID: "user-1", AspectRatio: domain.AspectRatio16x9, Stage: domain.StageComposing}
+	runner := &recordingRunner{fps: "30/1", clipDuration: 4, total: 8, fail: map[string]bool{"final.webm": true}}
+	events := &fakeJobEventStore{}
+
+	mp4Key, webmKey, err := composeWithRunner(t, job, runner, events)
+	require.NoError(t, err)
+	require.NotEmpty(t, mp4Key)
+	require.Empty(t, webmKey, "the MP4 is still delivered")
+	require.Equal(t, []string{"warning:composing"}, events.types())
+	require.Equal(t, "WebM transcode failed; only the MP4 is available", events.events[0].Message)
+}
+
+func TestComposeFinalVideo_RendersSceneTransitions(t *testing.T) {
+	scenes := []domain.Scene{
+		{SceneNumber: 1, Duration: 4, TransitionIn: domain.TransitionNone, TransitionOut: domain.TransitionCrossFade},
+		{SceneNumber: 2, Duration: 4, TransitionIn: domain.TransitionCut, TransitionOut: domain.TransitionNone},
+	}
+	job := &domain.Job{
+		JobID:                "job-compose-transitions",
+		UserID:               "user-1",
+		AspectRatio:          domain.AspectRatio16x9,
+		Scenes:               scenes,
+		RenderTransitions:    true,
+		SideEffectsText:      "May cause drowsiness.",
+		SideEffectsStartTime: 6,
+	}
+	// The 0.5s cross fade overlaps the two 4s clips
+	runner := &recordingRunner{fps: "30/1", clipDuration: 4, total: 7.5}
+	_, _, err := composeWithRunner(t, job, runner, nil)
+	require.NoError(t, err)
+
+	tmpDir := filepath.Join("/tmp", job.JobID, "composition")
+	require.Equal(t, []string{"final.mp4", "video_with_text.mp4", "final.webm"}, runner.outputs())
+	args := runner.ffmpegCall(t, filepath.Join(tmpDir, "final.mp4"))
+	require.Equal(t, []string{"-i", filepath.Join(tmpDir, "clip-1.mp4"), "-i", filepath.Join(tmpDir, "clip-2.mp4")}, args[:4])
+	require.Equal(t, "[0:v]fps=30,settb=AVTB,setpts=PTS-STARTPTS[c0];"+
+		"[1:v]fps=30,settb=AVTB,setpts=PTS-STARTPTS[c1];"+
+		"[c0][c1]xfade=transition=fade:duration=0.500:offset=3.500[vout]", args[slices.Index(args, "-filter_complex")+1])
+
+	// The disclosure runs to the end of the shortened video
+	overlay := runner.ffmpegCall(t, filepath.Join(tmpDir, "video_with_text.mp4"))
+	require.Contains(t, overlay[slices.Index(overlay, "-vf")+1], "between(t,6.00,7.50)")
+}
+
+func TestComposeFinalVideo_CutsWithoutRenderTransitions(t *testing.T) {
+	scenes := []domain.Scene{
+		{SceneNumber: 1, Duration: 4, TransitionOut: domain.TransitionFade},
+		{SceneNumber: 2, Duration: 4, TransitionIn: domain.TransitionCut},
+	}
+	tests := []struct {
+		name string
+		job  *domain.Job
+	}{
+		{"job created before transitions were rendered", &domain.Job{JobID: "job-compose-legacy", UserID: "user-1", AspectRatio: domain.AspectRatio16x9, Scenes: scenes}},
+		{"script of cuts", &domain.Job{JobID: "job-compose-cuts", UserID: "user-1", AspectRatio: domain.AspectRatio16x9, RenderTransitions: true,
+			Scenes: []domain.Scene{{SceneNumber: 1, Duration: 4, TransitionOut: domain.TransitionCut}, {SceneNumber: 2, Duration: 4, TransitionIn: domain.TransitionCut}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &recordingRunner{fps: "30/1", clipDuration: 4, total: 8}
+			_, _, err := composeWithRunner(t, tt.job, runner, nil)
+			require.NoError(t, err)
+			args := runner.ffmpegCall(t, filepath.Join("/tmp", tt.job.JobID, "composition", "final.mp4"))
+			require.Equal(t, []string{"-f", "concat"}, args[:2], "joined by stream copy")
+		})
+	}
+}
+
+// fakeCompositionProgressStore records the progress reports stored for a job
+type fakeCompositionProgressStore struct {
+	mu      sync.Mutex
+	reports []domain.CompositionProgress
+}
+
+func (f *fakeCompositionProgressStore) UpdateJobCompositionProgress(ctx context.Context, jobID string, progress domain.CompositionProgress) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, progress)
+	return nil
+}
+
+func TestComposeFinalVideo_ReportsPassProgress(t *testing.T) {
+	job := &domain.Job{JobID: "job-compose-progress", UserID: "user-1", AspectRatio: domain.AspectRatio16x9, Stage: domain.StageComposing}
+	runner := &recordingRunner{fps: "30/1", clipDuration: 4, total: 8}
+	store := &fakeCompositionProgressStore{}
+
+	_, _, err := composeWithService(t, job, &Service{assetsBucket: "assets", progress: store, runner: runner, logger: zap.NewNop()})
+	require.NoError(t, err)
+	require.Equal(t, []domain.CompositionProgress{
+		{Pass: "concat", Percent: 50},
+		{Pass: "concat", Percent: 100},
+		{Pass: "webm", Percent: 50},
+		{Pass: "webm", Percent: 100},
+	}, store.reports)
+}
+
+func TestReportProgress_Throttles(t *testing.T) {
+	store := &fakeCompositionProgressStore{}
+	svc := &Service{progress: store, logger: zap.NewNop()}
+	report := svc.reportProgress(context.Background(), &domain.Job{JobID: "job-1"}, "text_overlay", 10)
+	for _, seconds := range []float64{0, 0.5, 1, 1.5, 4, 4.2} {
+		report(ffmpegexec.Progress{OutTime: time.Duration(seconds * float64(time.Second))})
+	}
+	report(ffmpegexec.Progress{Done: true})
+
+	var percents []int
+	for _, r := range store.reports {
+		percents = append(percents, r.Percent)
+	}
+	require.Equal(t, []int{0, 10, 40, 100}, percents)
+
+	require.Nil(t, (&Service{}).reportProgress(context.Background(), &domain.Job{}, "webm", 10), "ffmpeg isn't asked for progress nobody stores")
+}
+
+func TestDownloadAndProcessClip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("generated clip"))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name      string
+		version   int
+		clipKey   func(job *domain.Job) string
+		frameKey  func(job *domain.Job) string
+		failFrame bool
+	}{
+		{
+			name:     "first version",
+			clipKey:  func(job *domain.Job) string { return SceneClipKey(job, 2) },
+			frameKey: func(job *domain.Job) string { return SceneThumbnailKey(job, 2) },
+		},
+		{
+			name:     "regenerated version",
+			version:  3,
+			clipKey:  func(job *domain.Job) string { return VersionedSceneClipKey(job, 2, 3) },
+			frameKey: func(job *domain.Job) string { return VersionedSceneThumbnailKey(job, 2, 3) },
+		},
+		{
+			name:      "without a last frame",
+			clipKey:   func(job *domain.Job) string { return SceneClipKey(job, 2) },
+			failFrame: true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A legacy job without its own bucket stores and presigns in the assets bucket
+			job := &domain.Job{JobID: fmt.Sprintf("job-clip-%d", i), UserID: "user-1"}
+			runner := &recordingRunner{fps: "24/1", clipDuration: 8}
+			if tt.failFrame {
+				runner.fail = map[string]bool{"last_frame.jpg": true}
+			}
+			svc := &Service{s3Service: newComposeTestS3(t), assetsBucket: "assets", runner: runner, logger: zap.NewNop()}
+
+			clipURL, frameURL, err := svc.DownloadAndProcessClip(context.Background(), job, 2, tt.version, server.URL, 8, ClipAspectNormalize)
+			require.NoError(t, err)
+			require.Contains(t, clipURL, tt.clipKey(job))
+
+			tmpDir := filepath.Join("/tmp", job.JobID, "clip-2")
+			require.Equal(t, [][]string{{
+				"-sseof", "-1",
+				"-i", filepath.Join(tmpDir, "video.mp4"),
+				"-update", "1",
+				"-q:v", "2",
+				"-y", filepath.Join(tmpDir, "last_frame.jpg"),
+			}}, runner.calls)
+
+			if tt.failFrame {
+				require.Empty(t, frameURL)
+				return
+			}
+			require.Contains(t, frameURL, "/assets/"+tt.frameKey(job))
+			require.Contains(t, frameURL, "X-Amz-Signature=")
+		})
+	}
+}
+
+func TestDownloadAndProcessClip_RejectsShortClips(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("generated clip"))
+	}))
+	t.Cleanup(server.Close)
+
+	runner := &recordingRunner{fps: "24/1", clipDuration: 2}
+	svc := &Service{s3Service: newComposeTestS3(t), assetsBucket: "assets", runner: runner, logger: zap.NewNop()}
+	_, _, err := svc.DownloadAndProcessClip(context.Background(), &domain.Job{JobID: "job-clip-short", UserID: "user-1"}, 1, 0, server.URL, 8, ClipAspectNormalize)
+	require.ErrorContains(t, err, "expected about 8.00s")
+	require.Empty(t, runner.calls, "nothing is processed from a clip that failed verification")
+}
+
+func TestVerifyComposition(t *testing.T) {
+	pharma := &domain.Job{JobID: "job-verify", SideEffects: pharmaSideEffects}
+	overlay := &drawtextConfig{Filter: "drawtext=text=May cause dizziness:enable='between(t,6.40,8.00)'", OverlayStart: 6.4, OverlayEnd: 8}
+	drawn := compositionSpec{Duration: 8, OverlayText: pharmaSideEffects, OverlayStart: 6.4, Overlay: overlay, Filtergraph: overlay.Filter + ",fps=30"}
+
+	tests := []struct {
+		name    string
+		job     *domain.Job
+		spec    func(spec *compositionSpec)
+		total   float64
+		wantErr string
+	}{
+		{name: "pharma ad with its overlay", job: pharma, total: 8},
+		{name: "short video", job: pharma, total: 6, wantErr: "final video is 6.00s, expected about 8.00s"},
+		{name: "no overlay text", job: pharma, total: 8, spec: func(s *compositionSpec) { s.OverlayText, s.Overlay, s.Filtergraph = "", nil, "" }, wantErr: "no side effects text"},
+		{name: "overlay not built", job: pharma, total: 8, spec: func(s *compositionSpec) { s.Overlay, s.Filtergraph = nil, "" }, wantErr: "overlay was not built"},
+		{name: "overlay moved", job: pharma, total: 8, spec: func(s *compositionSpec) { s.OverlayStart = 5 }, wantErr: "starts at 6.40s, expected 5.00s"},
+		{name: "overlay pass skipped", job: pharma, total: 8, spec: func(s *compositionSpec) { s.Filtergraph = "fps=30" }, wantErr: "missing from the composed filtergraph"},
+		{name: "other ads need no overlay", job: &domain.Job{JobID: "job-verify"}, total: 8, spec: func(s *compositionSpec) { s.Overlay, s.Filtergraph = nil, "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := drawn
+			if tt.spec != nil {
+				tt.spec(&spec)
+			}
+			runner := &recordingRunner{fps: "30/1", total: tt.total}
+			ctx := ffmpegexec.WithRunner(context.Background(), runner)
+			err := verifyComposition(ctx, tt.job, spec, filepath.Join(t.TempDir(), "final.mp4"))
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func newComposeTestS3(t *testing.T) *repository.S3AssetRepository {
+	t.Helper()
+	local, err := repository.StartLocalS3(t.TempDir(), "127.0.0.1:0", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { local.Close() })
+	return repository.NewS3Service(local.Client(), "assets", repository.UploadOptions{PartSize: manager.MinUploadPartSize},
+		repository.PresignCacheOptions{Disabled: true}, zap.NewNop())
+}
+
+// uploadComposeClip uploads data as scene n's clip, returning it as the pipeline would
+func uploadComposeClip(t *testing.T, s3Service *repository.S3AssetRepository, job *domain.Job, n int, data string) ClipVideo {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	url, err := s3Service.UploadFile(context.Background(), "assets", SceneClipKey(job, n), path, "video/mp4")
+	require.NoError(t, err)
+	return ClipVideo{VideoURL: url, Duration: 4}
+}
+
+// fakeJobEventStore records the events appended to it
+type fakeJobEventStore struct {
+	mu     sync.Mutex
+	events []domain.JobEvent
+	err    error
+}
+
+func (f *fakeJobEventStore) AppendJobEvent(ctx context.Context, event domain.JobEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeJobEventStore) types() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	types := make([]string, len(f.events))
+	for i, event := range f.events {
+		types[i] = event.Type + ":" + event.Stage
+	}
+	return types
+}
+
+const pharmaSideEffects = "May cause dizziness and nausea."
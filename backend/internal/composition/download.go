@@ -0,0 +1,109 @@
+package composition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/pkg/download"
+	"github.com/omnigen/backend/pkg/retry"
+	"go.uber.org/zap"
+)
+
+// ClipDurationTolerance is how far (seconds) a downloaded clip may be from the requested scene
+// length. Models snap the request to the lengths they support (Veo 4/6/8s, Kling 5/10s), so
+// this is loose; truncated downloads are usually far shorter or fail to probe at all.
+const ClipDurationTolerance = 2.5
+
+// clipVerifyRetry covers the first download plus two more when the clip fails verification.
+// Transfer errors are already retried (and resumed) by the downloader.
+var clipVerifyRetry = retry.Config{
+	MaxAttempts:  3,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     2 * time.Second,
+	Multiplier:   2.0,
+}
+
+// downloadVerifiedVideo downloads a generated clip and checks it is complete and decodable before
+// it is uploaded, downloading it again if not. expectedDuration <= 0 skips the length check.
+func downloadVerifiedVideo(
+	ctx context.Context,
+	logger *zap.Logger,
+	url string,
+	destPath string,
+	expectedDuration float64,
+) error {
+	attempt := 0
+	return retry.Do(ctx, clipVerifyRetry, func() error {
+		attempt++
+		if err := DownloadFile(ctx, logger, url, destPath); err != nil {
+			return retry.NewNonRetryableError(err)
+		}
+		if err := verifyVideoFile(ctx, destPath, expectedDuration); err != nil {
+			logger.Warn("Downloaded clip failed verification",
+				zap.Int("attempt", attempt),
+				zap.Error(err),
+			)
+			return err
+		}
+		return nil
+	})
+}
+
+// DownloadFile downloads a file from URL to local path, retrying and resuming interrupted
+// transfers
+func DownloadFile(ctx context.Context, logger *zap.Logger, url string, destPath string) error {
+	cfg := download.DefaultConfig()
+	cfg.Logger = logger
+	_, err := download.ToFile(ctx, cfg, url, destPath)
+	return err
+}
+
+// verifyVideoFile runs a header-only ffprobe to confirm path has a video stream and, when
+// expectedDuration > 0, a duration within ClipDurationTolerance of it
+func verifyVideoFile(ctx context.Context, path string, expectedDuration float64) error {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_type:format=duration",
+		"-of", "json",
+		path,
+	)
+	output, err := ffmpegexec.CommandOutput(ctx, "verify_clip", cmd)
+	if err != nil {
+		return fmt.Errorf("clip is not a readable video: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].CodecType != "video" {
+		return fmt.Errorf("clip has no video stream")
+	}
+
+	if expectedDuration <= 0 {
+		return nil
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(probe.Format.Duration), 64)
+	if err != nil {
+		return fmt.Errorf("clip has no duration: %w", err)
+	}
+	if math.Abs(duration-expectedDuration) > ClipDurationTolerance {
+		return fmt.Errorf("clip is %.2fs, expected about %.2fs", duration, expectedDuration)
+	}
+	return nil
+}
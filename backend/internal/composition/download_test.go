@@ -0,0 +1,82 @@
+package composition
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/omnigen/backend/pkg/download"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// clipServer serves body, cutting it in half (while still announcing the full Content-Length)
+// for the first truncated requests
+func clipServer(t *testing.T, body []byte, truncated int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if n <= truncated {
+			w.Write(body[:len(body)/2])
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestDownloadVerifiedVideoGivesUpOnPersistentTruncation(t *testing.T) {
+	server, hits := clipServer(t, make([]byte, 4096), 100)
+	dest := filepath.Join(t.TempDir(), "video.mp4")
+
+	// The downloader's own retries are used up once; a failed transfer isn't probed or re-run
+	err := downloadVerifiedVideo(context.Background(), zap.NewNop(), server.URL, dest, 8)
+	require.ErrorContains(t, err, "connection lost")
+	require.EqualValues(t, download.DefaultConfig().MaxAttempts, hits.Load())
+}
+
+func TestDownloadVerifiedVideoDoesNotRetryClientErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "expired", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := downloadVerifiedVideo(context.Background(), zap.NewNop(), server.URL, filepath.Join(t.TempDir(), "video.mp4"), 8)
+	require.ErrorContains(t, err, "HTTP error: 404")
+	require.EqualValues(t, 1, hits.Load())
+}
+
+func TestDownloadVerifiedVideoRetriesTruncatedClip(t *testing.T) {
+	ensureFfmpegAvailable(t)
+
+	clip, err := os.ReadFile(createTestClip(t, 320, 240, 8))
+	require.NoError(t, err)
+	server, hits := clipServer(t, clip, 2)
+	dest := filepath.Join(t.TempDir(), "video.mp4")
+
+	require.NoError(t, downloadVerifiedVideo(context.Background(), zap.NewNop(), server.URL, dest, 8))
+	require.EqualValues(t, 3, hits.Load())
+}
+
+func TestVerifyVideoFile(t *testing.T) {
+	ensureFfmpegAvailable(t)
+	ctx := context.Background()
+
+	clip := createTestClip(t, 320, 240, 6)
+	require.NoError(t, verifyVideoFile(ctx, clip, 6))
+	require.NoError(t, verifyVideoFile(ctx, clip, 8), "models snap to supported lengths")
+	require.NoError(t, verifyVideoFile(ctx, clip, 0), "no expected duration skips the length check")
+	require.ErrorContains(t, verifyVideoFile(ctx, clip, 10), "expected about 10.00s")
+
+	require.ErrorContains(t, verifyVideoFile(ctx, filepath.Join("testdata", "corrupt-clip.mp4"), 8), "not a readable video")
+}
@@ -0,0 +1,223 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultEndCardDuration is used when the end card doesn't set one
+	DefaultEndCardDuration = 3.0
+
+	endCardCTAFontSize   = 64.0 // Pixels at 1080p
+	endCardLegalFontSize = 24.0 // Pixels at 1080p
+	endCardLogoWidth     = 0.30 // Fraction of frame width
+	endCardLogoTop       = 0.18 // Fraction of frame height
+	endCardBackdropDim   = 0.35 // Black backdrop opacity over a product image, so the CTA stays readable
+)
+
+// EndCardDuration is how long the job's end card adds to the video, or 0 without one
+func EndCardDuration(job *domain.Job) float64 {
+	if job.EndCard == nil {
+		return 0
+	}
+	if job.EndCard.Duration > 0 {
+		return job.EndCard.Duration
+	}
+	return DefaultEndCardDuration
+}
+
+// endCardCTA is the end card's call to action: the script's, or the one the request asked for
+func endCardCTA(job *domain.Job) string {
+	if cta := strings.TrimSpace(job.ScriptMetadata.CallToAction); cta != "" {
+		return cta
+	}
+	return strings.TrimSpace(job.CallToAction)
+}
+
+// endCardSpec is everything needed to render an end card matching the main video
+type endCardSpec struct {
+	Width           int
+	Height          int
+	FPS             float64
+	Duration        float64
+	BackgroundColor string // "#RRGGBB"; ignored with a background image
+	BackgroundImage string // Local path, or "" for a solid color
+	LogoPath        string // Local path, or "" for no logo
+	CTA             string
+	LegalText       string
+	FontFile        string
+}
+
+// args builds the ffmpeg arguments that render the end card to output. The card is encoded
+// like the clips (H.264, yuv420p, square pixels, same size and frame rate) so the concat
+// demuxer can append it without re-encoding.
+func (s endCardSpec) args(output string) []string {
+	rate := strconv.FormatFloat(s.FPS, 'f', -1, 64)
+	duration := fmt.Sprintf("%.3f", s.Duration)
+
+	var args []string
+	background := []string{"setsar=1"}
+	if s.BackgroundImage != "" {
+		args = append(args, "-loop", "1", "-framerate", rate, "-t", duration, "-i", s.BackgroundImage)
+		background = []string{
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase", s.Width, s.Height),
+			fmt.Sprintf("crop=%d:%d", s.Width, s.Height),
+			"setsar=1",
+			fmt.Sprintf("drawbox=c=black@%.2f:t=fill", endCardBackdropDim),
+		}
+	} else {
+		color := strings.TrimPrefix(s.BackgroundColor, "#")
+		if color == "" {
+			color = "000000"
+		}
+		args = append(args, "-f", "lavfi", "-i", fmt.Sprintf("color=c=0x%s:s=%dx%d:r=%s:d=%s", color, s.Width, s.Height, rate, duration))
+	}
+	if s.LogoPath != "" {
+		args = append(args, "-i", s.LogoPath)
+	}
+
+	chain := append(s.textFilters(), "format=yuv420p")
+	var graph string
+	if s.LogoPath != "" {
+		logoWidth := max(1, int(math.Round(float64(s.Width)*endCardLogoWidth)))
+		graph = fmt.Sprintf("[1:v]format=rgba,scale=%d:-1[logo];", logoWidth) +
+			"[0:v]" + strings.Join(background, ",") + "[bg];" +
+			fmt.Sprintf("[bg][logo]overlay=x=(W-w)/2:y=H*%.2f:format=auto,", endCardLogoTop) +
+			strings.Join(chain, ",") + "[out]"
+	} else {
+		graph = "[0:v]" + strings.Join(append(background, chain...), ",") + "[out]"
+	}
+
+	return append(args,
+		"-filter_complex", graph,
+		"-map", "[out]",
+		"-t", duration,
+		"-r", rate,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-pix_fmt", "yuv420p",
+		"-an",
+		"-y", output,
+	)
+}
+
+// textFilters draws the CTA in the middle of the card (below the logo when there is one) and
+// the legal line under it
+func (s endCardSpec) textFilters() []string {
+	scaleFactor := float64(min(s.Width, s.Height)) / 1080.0
+	layout := textOverlayLayoutFor(s.Width, s.Height)
+	maxLineWidth := float64(s.Width) * layout.MaxLineWidth
+
+	var filters []string
+	if s.CTA != "" {
+		fontSize := math.Max(endCardCTAFontSize*scaleFactor, minOverlayFontSize)
+		text, _ := wrapText(s.CTA, maxLineWidth, fontSize)
+		style := drawtextStyle{FontFile: s.FontFile, FontSize: fontSize, LineSpacing: overlayLineSpacing(fontSize)}
+		y := "(h-text_h)/2"
+		if s.LogoPath != "" {
+			y = "h*0.55"
+		}
+		filters = append(filters, style.filter(text, y, "1"))
+	}
+	if s.LegalText != "" {
+		fontSize := math.Max(endCardLegalFontSize*scaleFactor, minOverlayFontSize)
+		text, _ := wrapText(s.LegalText, maxLineWidth, fontSize)
+		style := drawtextStyle{FontFile: s.FontFile, FontSize: fontSize, LineSpacing: overlayLineSpacing(fontSize)}
+		filters = append(filters, style.filter(text, "h*0.70", "1"))
+	}
+	return filters
+}
+
+// concatList is the concat demuxer input joining files in order
+func concatList(paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "file '%s'\n", path)
+	}
+	return b.String()
+}
+
+// renderEndCard renders the job's end card to match referenceClip and returns its path, or ""
+// without an end card. Like the other optional extras it never fails the video: a card that
+// can't be rendered is logged and left off.
+func renderEndCard(
+	ctx context.Context,
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	logger *zap.Logger,
+	job *domain.Job,
+	referenceClip string,
+	logoPath string,
+	tmpDir string,
+) string {
+	card := job.EndCard
+	if card == nil {
+		return ""
+	}
+
+	width, height, err := ProbeVideoDimensions(ctx, referenceClip)
+	if err != nil {
+		logger.Warn("Failed to probe clip dimensions for end card, using defaults",
+			zap.Error(err),
+		)
+		width, height = 1920, 1080
+	}
+	fps := probeVideoFPS(ctx, referenceClip)
+	if fps <= 0 {
+		fps = 30
+	}
+
+	spec := endCardSpec{
+		Width:           width,
+		Height:          height,
+		FPS:             fps,
+		Duration:        EndCardDuration(job),
+		BackgroundColor: card.BackgroundColor,
+		CTA:             endCardCTA(job),
+		LegalText:       strings.TrimSpace(card.LegalText),
+		FontFile:        detectAvailableFont(logger),
+	}
+	if card.ShowLogo {
+		spec.LogoPath = logoPath
+	}
+	if card.UseProductImage && job.StartImage != "" {
+		imagePath := filepath.Join(tmpDir, "end-card-background"+strings.ToLower(filepath.Ext(s3util.Key(job.StartImage))))
+		if err := s3Service.DownloadFile(ctx, assetsBucket, s3util.Key(job.StartImage), imagePath); err != nil {
+			logger.Warn("Failed to download product image for end card, using a solid background",
+				zap.Error(err),
+			)
+		} else {
+			spec.BackgroundImage = imagePath
+		}
+	}
+
+	output := filepath.Join(tmpDir, "end-card.mp4")
+	cmd := exec.CommandContext(ctx, "ffmpeg", spec.args(output)...)
+	if out, err := ffmpegexec.CombinedOutput(ctx, "end_card", cmd); err != nil {
+		logger.Warn("Failed to render end card, composing without it",
+			zap.String("output", string(out)),
+			zap.Error(err),
+		)
+		return ""
+	}
+
+	logger.Info("End card rendered",
+		zap.Float64("duration", spec.Duration),
+		zap.Bool("product_image", spec.BackgroundImage != ""),
+		zap.Bool("logo", spec.LogoPath != ""),
+	)
+	return output
+}
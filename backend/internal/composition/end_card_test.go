@@ -0,0 +1,136 @@
+package composition
+
+import (
+	"context"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func TestEndCardDurationAndCTA(t *testing.T) {
+	job := &domain.Job{CallToAction: "Order today"}
+	if d := EndCardDuration(job); d != 0 {
+		t.Fatalf("expected no end card time without an end card, got %.1f", d)
+	}
+	job.EndCard = &domain.EndCard{}
+	if d := EndCardDuration(job); d != DefaultEndCardDuration {
+		t.Fatalf("expected the default %.1fs, got %.1f", DefaultEndCardDuration, d)
+	}
+	job.EndCard.Duration = 2.5
+	if d := EndCardDuration(job); d != 2.5 {
+		t.Fatalf("expected 2.5s, got %.1f", d)
+	}
+
+	if cta := endCardCTA(job); cta != "Order today" {
+		t.Fatalf("expected the requested CTA without a script, got %q", cta)
+	}
+	job.ScriptMetadata.CallToAction = "Ask your doctor about Zyloprim"
+	if cta := endCardCTA(job); cta != "Ask your doctor about Zyloprim" {
+		t.Fatalf("expected the script's CTA, got %q", cta)
+	}
+}
+
+func TestEndCardSpecArgsSolidBackground(t *testing.T) {
+	spec := endCardSpec{Width: 1920, Height: 1080, FPS: 24, Duration: 3, BackgroundColor: "#1A2B3C", CTA: "Shop Now"}
+	want := []string{
+		"-f", "lavfi", "-i", "color=c=0x1A2B3C:s=1920x1080:r=24:d=3.000",
+		"-filter_complex", "[0:v]setsar=1," +
+			"drawtext=text=Shop Now:expansion=none:fontsize=64.00:fontcolor=white:bordercolor=black:borderw=2:" +
+			"x=(w-text_w)/2:y='(h-text_h)/2':line_spacing=14:enable='1'," +
+			"format=yuv420p[out]",
+		"-map", "[out]",
+		"-t", "3.000",
+		"-r", "24",
+		"-c:v", "libx264", "-preset", "medium", "-crf", "21", "-pix_fmt", "yuv420p", "-an",
+		"-y", "end-card.mp4",
+	}
+	if got := spec.args("end-card.mp4"); !slices.Equal(got, want) {
+		t.Fatalf("unexpected args:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestEndCardSpecArgsProductImageAndLogo(t *testing.T) {
+	spec := endCardSpec{
+		Width: 1080, Height: 1920, FPS: 30000.0 / 1001, Duration: 2,
+		BackgroundImage: "/tmp/job/end-card-background.png", LogoPath: "/tmp/job/logo.png",
+		CTA: "Ask your doctor", LegalText: "Rx only.",
+	}
+	args := spec.args("end-card.mp4")
+
+	// The image is looped for the card's length, then the logo follows as input 1
+	wantInputs := []string{
+		"-loop", "1", "-framerate", "29.97002997002997", "-t", "2.000", "-i", "/tmp/job/end-card-background.png",
+		"-i", "/tmp/job/logo.png",
+		"-filter_complex",
+	}
+	if !slices.Equal(args[:len(wantInputs)], wantInputs) {
+		t.Fatalf("unexpected inputs:\ngot  %q\nwant %q", args[:len(wantInputs)], wantInputs)
+	}
+
+	graph := args[len(wantInputs)]
+	wantPrefix := "[1:v]format=rgba,scale=324:-1[logo];" +
+		"[0:v]scale=1080:1920:force_original_aspect_ratio=increase,crop=1080:1920,setsar=1,drawbox=c=black@0.35:t=fill[bg];" +
+		"[bg][logo]overlay=x=(W-w)/2:y=H*0.18:format=auto,drawtext="
+	if !strings.HasPrefix(graph, wantPrefix) || !strings.HasSuffix(graph, ",format=yuv420p[out]") {
+		t.Fatalf("unexpected graph: %s", graph)
+	}
+	texts := drawtextFilters(strings.TrimSuffix(strings.SplitN(graph, "format=auto,", 2)[1], ",format=yuv420p[out]"))
+	if len(texts) != 2 || drawtextText(texts[0]) != "Ask your doctor" || drawtextText(texts[1]) != "Rx only." {
+		t.Fatalf("expected the CTA then the legal line, got %q", texts)
+	}
+	if !strings.Contains(texts[0], "y='h*0.55'") {
+		t.Fatalf("expected the CTA below the logo: %s", texts[0])
+	}
+}
+
+func TestConcatListAppendsEndCardLast(t *testing.T) {
+	list := concatList([]string{"/tmp/job/clip-1.mp4", "/tmp/job/clip-2.mp4", "/tmp/job/end-card.mp4"})
+	want := "file '/tmp/job/clip-1.mp4'\n" +
+		"file '/tmp/job/clip-2.mp4'\n" +
+		"file '/tmp/job/end-card.mp4'\n"
+	if list != want {
+		t.Fatalf("unexpected concat list:\ngot  %q\nwant %q", list, want)
+	}
+}
+
+func TestEndCardConcatenatesWithClip(t *testing.T) {
+	ensureFfmpegAvailable(t)
+
+	dir := t.TempDir()
+	clipPath := createTestClip(t, 640, 360, 2.0)
+	cardPath := filepath.Join(dir, "end-card.mp4")
+	spec := endCardSpec{Width: 640, Height: 360, FPS: 25, Duration: 2, BackgroundColor: "#FFFFFF", CTA: "Shop Now"}
+	if output, err := exec.Command("ffmpeg", spec.args(cardPath)...).CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg rejected the end card: %v (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	listPath := filepath.Join(dir, "concat.txt")
+	if err := os.WriteFile(listPath, []byte(concatList([]string{clipPath, cardPath})), 0o644); err != nil {
+		t.Fatalf("failed to write concat list: %v", err)
+	}
+	finalPath := filepath.Join(dir, "final.mp4")
+	cmd := exec.Command("ffmpeg", "-f", "concat", "-safe", "0", "-i", listPath, "-c:v", "copy", "-an", "-y", finalPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg concat failed: %v (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	duration, err := ProbeAudioDuration(context.Background(), finalPath)
+	if err != nil {
+		t.Fatalf("failed to probe duration: %v", err)
+	}
+	if math.Abs(duration-4) > 0.1 {
+		t.Fatalf("expected the card appended for a 4s video, got %.2fs", duration)
+	}
+	if stats := analyzeFrame(t, extractFrame(t, finalPath, 3), 700); stats.brightPixels == 0 {
+		t.Fatalf("expected the white end card after the clip")
+	}
+	if stats := analyzeFrame(t, extractFrame(t, finalPath, 1), 120); stats.brightPixels != 0 {
+		t.Fatalf("expected the black clip first, found %d bright pixels", stats.brightPixels)
+	}
+}
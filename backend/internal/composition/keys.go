@@ -0,0 +1,48 @@
+package composition
+
+import (
+	"fmt"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+)
+
+// SceneClipKey returns S3 key for a scene's current clip
+func SceneClipKey(job *domain.Job, sceneNumber int) string {
+	return repository.JobAssetPrefix(job) + fmt.Sprintf("clips/scene-%03d.mp4", sceneNumber)
+}
+
+// SceneThumbnailKey returns S3 key for a scene's current thumbnail
+func SceneThumbnailKey(job *domain.Job, sceneNumber int) string {
+	return repository.JobAssetPrefix(job) + fmt.Sprintf("thumbnails/scene-%03d.jpg", sceneNumber)
+}
+
+// AudioKey returns S3 key for the job's background music
+func AudioKey(job *domain.Job) string {
+	return repository.JobAssetPrefix(job) + "audio/background-music.mp3"
+}
+
+// NarratorAudioKey returns S3 key for the job's narrator voiceover
+func NarratorAudioKey(job *domain.Job) string {
+	return repository.JobAssetPrefix(job) + "audio/narrator-voiceover.mp3"
+}
+
+// FinalVideoKey returns S3 key for the final video composed from the inputs hash identifies
+func FinalVideoKey(job *domain.Job, hash string) string {
+	return repository.JobAssetPrefix(job) + fmt.Sprintf("final/video-%s.mp4", hash)
+}
+
+// FinalWebMKey returns S3 key for the WebM encoded alongside FinalVideoKey
+func FinalWebMKey(job *domain.Job, hash string) string {
+	return repository.JobAssetPrefix(job) + fmt.Sprintf("final/video-%s.webm", hash)
+}
+
+// VersionedSceneClipKey returns S3 key for a specific clip version
+func VersionedSceneClipKey(job *domain.Job, sceneNumber, version int) string {
+	return repository.JobAssetPrefix(job) + fmt.Sprintf("clips/scene-%03d-v%d.mp4", sceneNumber, version)
+}
+
+// VersionedSceneThumbnailKey returns S3 key for a specific scene thumbnail version
+func VersionedSceneThumbnailKey(job *domain.Job, sceneNumber, version int) string {
+	return repository.JobAssetPrefix(job) + fmt.Sprintf("thumbnails/scene-%03d-v%d.jpg", sceneNumber, version)
+}
@@ -0,0 +1,115 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// LocalInputs are the files a composition outside the pipeline starts from. Clips are the
+// job's scenes in order; the rest may be empty.
+type LocalInputs struct {
+	Clips     []string
+	Music     string
+	Narration string
+	Logo      string // Drawn where job.LogoOverlay places it; ignored without one
+}
+
+// WithRunner returns a copy of the service running its ffmpeg and ffprobe commands with runner
+func (s *Service) WithRunner(runner ffmpegexec.Runner) *Service {
+	copied := *s
+	copied.runner = runner
+	return &copied
+}
+
+// ComposeFiles composes job from files on disk, for tools that run a composition outside the
+// pipeline. The inputs are stored under the job's keys in the service's bucket, composed by
+// ComposeFinalVideo and the final videos downloaded into outDir. Returns the paths of the MP4
+// and, if it was encoded, the WebM.
+func (s *Service) ComposeFiles(ctx context.Context, job *domain.Job, inputs LocalInputs, outDir string) (string, string, error) {
+	if len(inputs.Clips) == 0 {
+		return "", "", fmt.Errorf("no clips to compose")
+	}
+	bucket := repository.JobAssetBucket(job, s.assetsBucket)
+
+	clips := make([]ClipVideo, len(inputs.Clips))
+	for i, path := range inputs.Clips {
+		url, err := s.s3Service.UploadFile(ctx, bucket, SceneClipKey(job, i+1), path, "video/mp4")
+		if err != nil {
+			return "", "", fmt.Errorf("failed to store clip %d: %w", i+1, err)
+		}
+		clips[i] = ClipVideo{VideoURL: url, Duration: s.localClipDuration(ctx, job, i, path)}
+	}
+
+	if inputs.Music != "" {
+		url, err := s.s3Service.UploadFile(ctx, bucket, AudioKey(job), inputs.Music, "audio/mpeg")
+		if err != nil {
+			return "", "", fmt.Errorf("failed to store music: %w", err)
+		}
+		job.AudioURL = url
+	}
+	if inputs.Narration != "" {
+		url, err := s.s3Service.UploadFile(ctx, bucket, NarratorAudioKey(job), inputs.Narration, "audio/mpeg")
+		if err != nil {
+			return "", "", fmt.Errorf("failed to store narration: %w", err)
+		}
+		job.NarratorAudioURL = url
+	}
+	if inputs.Logo != "" && job.LogoOverlay != nil {
+		if job.LogoOverlay.Asset == "" {
+			job.LogoOverlay.Asset = repository.JobAssetPrefix(job) + "logo" + filepath.Ext(inputs.Logo)
+		}
+		if _, err := s.s3Service.UploadFile(ctx, s.assetsBucket, job.LogoOverlay.Asset, inputs.Logo, mime.TypeByExtension(filepath.Ext(inputs.Logo))); err != nil {
+			return "", "", fmt.Errorf("failed to store logo: %w", err)
+		}
+	}
+
+	comp, err := NewPlan(ctx, s.s3Service, bucket, job, clips)
+	if err != nil {
+		return "", "", err
+	}
+	mp4Key, webmKey, err := s.ComposeFinalVideo(ctx, job, clips, comp)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create output dir: %w", err)
+	}
+	mp4Path := filepath.Join(outDir, filepath.Base(mp4Key))
+	if err := s.s3Service.DownloadFile(ctx, bucket, mp4Key, mp4Path); err != nil {
+		return "", "", fmt.Errorf("failed to fetch final video: %w", err)
+	}
+	webmPath := ""
+	if webmKey != "" {
+		webmPath = filepath.Join(outDir, filepath.Base(webmKey))
+		if err := s.s3Service.DownloadFile(ctx, bucket, webmKey, webmPath); err != nil {
+			return "", "", fmt.Errorf("failed to fetch final WebM: %w", err)
+		}
+	}
+	return mp4Path, webmPath, nil
+}
+
+// localClipDuration returns how long the clip at path lasts, or its scene's planned duration if
+// it can't be probed
+func (s *Service) localClipDuration(ctx context.Context, job *domain.Job, i int, path string) float64 {
+	format, err := ProbeClipFormat(ffmpegexec.WithRunner(ctx, s.runner), path)
+	if err == nil && format.Duration > 0 {
+		return format.Duration
+	}
+	s.log(ctx).Warn("Failed to probe clip duration, using the scene's",
+		zap.String("clip", path),
+		zap.Error(err),
+	)
+	if i < len(job.Scenes) {
+		return job.Scenes[i].Duration
+	}
+	return 0
+}
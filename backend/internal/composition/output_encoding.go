@@ -0,0 +1,190 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/ffmpegexec"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// CRFs of the output encode when a spec doesn't set one. h265 at 24 looks about like h264 at 21
+// (the overlay pass's quality) at a little over half the size.
+const (
+	defaultH264CRF = 21
+	defaultH265CRF = 24
+)
+
+// OutputPixelFormat is used for every output encode: 8-bit 4:2:0 plays everywhere, including
+// h265 in Safari and social platform ingest
+const OutputPixelFormat = "yuv420p"
+
+// outputResolutionShortSide maps each output resolution to the video's short side in pixels
+var outputResolutionShortSide = map[string]int{
+	domain.OutputResolution720p:  720,
+	domain.OutputResolution1080p: 1080,
+	domain.OutputResolution2160p: 2160,
+}
+
+// outputEncode is an output spec resolved against the composed video
+type outputEncode struct {
+	Codec          string
+	Container      string
+	Width          int // 0 keeps the composed size
+	Height         int
+	CRF            int
+	MaxBitrateKbps int
+}
+
+// resolveOutputEncode fills in a spec's defaults. The resolution sets the short side, so a
+// 9:16 video at 1080p is 1080x1920; the long side keeps the aspect ratio, rounded to even.
+// width and height are the composed video's size, 0 when unknown (no scaling).
+func resolveOutputEncode(spec domain.OutputSpec, width, height int) outputEncode {
+	enc := outputEncode{
+		Codec:          spec.Codec,
+		Container:      spec.Container,
+		CRF:            spec.CRF,
+		MaxBitrateKbps: spec.MaxBitrateKbps,
+	}
+	if enc.Codec == "" {
+		enc.Codec = domain.OutputCodecH264
+	}
+	if enc.Container == "" {
+		enc.Container = domain.OutputContainerMP4
+	}
+	if enc.CRF == 0 {
+		enc.CRF = defaultH264CRF
+		if enc.Codec == domain.OutputCodecH265 {
+			enc.CRF = defaultH265CRF
+		}
+	}
+
+	target, ok := outputResolutionShortSide[spec.Resolution]
+	if !ok || width <= 0 || height <= 0 || min(width, height) == target {
+		return enc
+	}
+	scaleLong := func(long, short int) int {
+		return int(math.Round(float64(long)*float64(target)/float64(short)/2)) * 2
+	}
+	if width <= height {
+		enc.Width, enc.Height = target, scaleLong(height, width)
+	} else {
+		enc.Width, enc.Height = scaleLong(width, height), target
+	}
+	return enc
+}
+
+// buildOutputEncodeArgs returns the ffmpeg arguments of the final encode. Audio is already
+// mixed and is copied. h265 is tagged hvc1, which Apple players need to recognize the track.
+func buildOutputEncodeArgs(input, output string, enc outputEncode) []string {
+	args := []string{"-i", input}
+	if enc.Width > 0 && enc.Height > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=%d:%d:flags=lanczos", enc.Width, enc.Height))
+	}
+
+	switch enc.Codec {
+	case domain.OutputCodecH265:
+		args = append(args,
+			"-c:v", "libx265",
+			"-preset", "medium",
+			"-crf", fmt.Sprint(enc.CRF),
+			"-tag:v", "hvc1",
+			"-x265-params", "log-level=error",
+		)
+	default:
+		args = append(args,
+			"-c:v", "libx264",
+			"-preset", "medium",
+			"-crf", fmt.Sprint(enc.CRF),
+			"-profile:v", "high",
+		)
+	}
+	if enc.MaxBitrateKbps > 0 {
+		// A two-second buffer lets the rate control even out within the cap
+		args = append(args,
+			"-maxrate", fmt.Sprintf("%dk", enc.MaxBitrateKbps),
+			"-bufsize", fmt.Sprintf("%dk", 2*enc.MaxBitrateKbps),
+		)
+	}
+
+	return append(args,
+		"-pix_fmt", OutputPixelFormat,
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		"-y", output,
+	)
+}
+
+// finalVideoObject returns the S3 key and content type of a job's final video composed from
+// the inputs hash identifies, which follow the output spec's container
+func finalVideoObject(job *domain.Job, hash string) (string, string) {
+	if job.OutputSpec != nil && job.OutputSpec.Container == domain.OutputContainerMOV {
+		return repository.JobAssetPrefix(job) + fmt.Sprintf("final/video-%s.mov", hash), "video/quicktime"
+	}
+	return FinalVideoKey(job, hash), "video/mp4"
+}
+
+// encodeJobOutput applies the job's output spec to the composed, muxed video and records how
+// the result is encoded on job.Encoding. Without a spec the video is left as composed.
+func encodeJobOutput(ctx context.Context, logger *zap.Logger, job *domain.Job, videoPath, tmpDir string) (string, error) {
+	width, height, err := ProbeVideoDimensions(ctx, videoPath)
+	if err != nil {
+		logger.Warn("Failed to probe composed video dimensions", zap.Error(err))
+	}
+
+	if job.OutputSpec == nil {
+		encoding := &domain.OutputEncoding{
+			Codec:     domain.OutputCodecH264,
+			Container: domain.OutputContainerMP4,
+			Width:     width,
+			Height:    height,
+		}
+		if info, err := os.Stat(videoPath); err == nil {
+			encoding.SizeBytes = info.Size()
+		}
+		job.Encoding = encoding
+		return videoPath, nil
+	}
+
+	enc := resolveOutputEncode(*job.OutputSpec, width, height)
+	outputPath := filepath.Join(tmpDir, "final_output."+enc.Container)
+	logger.Info("Encoding final video to the output spec",
+		zap.String("codec", enc.Codec),
+		zap.String("container", enc.Container),
+		zap.Int("width", enc.Width),
+		zap.Int("height", enc.Height),
+		zap.Int("crf", enc.CRF),
+		zap.Int("max_bitrate_kbps", enc.MaxBitrateKbps),
+	)
+	if err := ffmpegexec.Exec(ctx, "output_encode", ffmpegexec.Spec{Args: buildOutputEncodeArgs(videoPath, outputPath, enc), Timeout: ffmpegexec.EncodeTimeout, LowPriority: true}); err != nil {
+		logger.Error("ffmpeg output encode failed",
+			zap.String("output", ffmpegexec.Stderr(err)),
+			zap.Error(err),
+		)
+		return "", fmt.Errorf("ffmpeg output encode failed: %w", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat encoded video: %w", err)
+	}
+	if enc.Width > 0 {
+		width, height = enc.Width, enc.Height
+	}
+	job.Encoding = &domain.OutputEncoding{
+		Codec:          enc.Codec,
+		Container:      enc.Container,
+		Width:          width,
+		Height:         height,
+		PixelFormat:    OutputPixelFormat,
+		CRF:            enc.CRF,
+		MaxBitrateKbps: enc.MaxBitrateKbps,
+		SizeBytes:      info.Size(),
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,122 @@
+package composition
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOutputEncode(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          domain.OutputSpec
+		width, height int
+		want          outputEncode
+	}{
+		{
+			name:  "defaults",
+			width: 1920, height: 1080,
+			want: outputEncode{Codec: "h264", Container: "mp4", CRF: 21},
+		},
+		{
+			name:  "h265 default crf",
+			spec:  domain.OutputSpec{Codec: "h265", Container: "mov"},
+			width: 1920, height: 1080,
+			want: outputEncode{Codec: "h265", Container: "mov", CRF: 24},
+		},
+		{
+			name:  "downscale landscape",
+			spec:  domain.OutputSpec{Resolution: "720p", CRF: 26},
+			width: 1920, height: 1080,
+			want: outputEncode{Codec: "h264", Container: "mp4", Width: 1280, Height: 720, CRF: 26},
+		},
+		{
+			name:  "upscale portrait keeps the short side",
+			spec:  domain.OutputSpec{Resolution: "2160p"},
+			width: 1080, height: 1920,
+			want: outputEncode{Codec: "h264", Container: "mp4", Width: 2160, Height: 3840, CRF: 21},
+		},
+		{
+			name:  "odd long side rounds to even",
+			spec:  domain.OutputSpec{Resolution: "720p"},
+			width: 1000, height: 1000 * 16 / 9,
+			want: outputEncode{Codec: "h264", Container: "mp4", Width: 720, Height: 1280, CRF: 21},
+		},
+		{
+			name:  "already at the resolution",
+			spec:  domain.OutputSpec{Resolution: "1080p"},
+			width: 1080, height: 1080,
+			want: outputEncode{Codec: "h264", Container: "mp4", CRF: 21},
+		},
+		{
+			name: "unknown size isn't scaled",
+			spec: domain.OutputSpec{Resolution: "720p", MaxBitrateKbps: 4000},
+			want: outputEncode{Codec: "h264", Container: "mp4", CRF: 21, MaxBitrateKbps: 4000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, resolveOutputEncode(tt.spec, tt.width, tt.height))
+		})
+	}
+}
+
+func TestBuildOutputEncodeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  outputEncode
+		want []string
+	}{
+		{
+			name: "h264 at the composed size",
+			enc:  outputEncode{Codec: "h264", Container: "mp4", CRF: 21},
+			want: []string{
+				"-i", "in.mp4",
+				"-c:v", "libx264", "-preset", "medium", "-crf", "21", "-profile:v", "high",
+				"-pix_fmt", "yuv420p", "-c:a", "copy", "-movflags", "+faststart",
+				"-y", "out.mp4",
+			},
+		},
+		{
+			name: "h265 is tagged hvc1",
+			enc:  outputEncode{Codec: "h265", Container: "mov", Width: 3840, Height: 2160, CRF: 20},
+			want: []string{
+				"-i", "in.mp4",
+				"-vf", "scale=3840:2160:flags=lanczos",
+				"-c:v", "libx265", "-preset", "medium", "-crf", "20", "-tag:v", "hvc1", "-x265-params", "log-level=error",
+				"-pix_fmt", "yuv420p", "-c:a", "copy", "-movflags", "+faststart",
+				"-y", "out.mp4",
+			},
+		},
+		{
+			name: "bitrate cap",
+			enc:  outputEncode{Codec: "h264", Container: "mp4", Width: 720, Height: 1280, CRF: 23, MaxBitrateKbps: 3500},
+			want: []string{
+				"-i", "in.mp4",
+				"-vf", "scale=720:1280:flags=lanczos",
+				"-c:v", "libx264", "-preset", "medium", "-crf", "23", "-profile:v", "high",
+				"-maxrate", "3500k", "-bufsize", "7000k",
+				"-pix_fmt", "yuv420p", "-c:a", "copy", "-movflags", "+faststart",
+				"-y", "out.mp4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, buildOutputEncodeArgs("in.mp4", "out.mp4", tt.enc))
+		})
+	}
+}
+
+func TestFinalVideoObject(t *testing.T) {
+	job := &domain.Job{UserID: "user-1", JobID: "job-1"}
+	key, contentType := finalVideoObject(job, "0123abcd")
+	require.Equal(t, "users/user-1/jobs/job-1/final/video-0123abcd.mp4", key)
+	require.Equal(t, "video/mp4", contentType)
+
+	job.OutputSpec = &domain.OutputSpec{Codec: "h265", Container: "mov"}
+	key, contentType = finalVideoObject(job, "0123abcd")
+	require.Equal(t, "users/user-1/jobs/job-1/final/video-0123abcd.mov", key)
+	require.Equal(t, "video/quicktime", contentType)
+}
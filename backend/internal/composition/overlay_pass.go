@@ -0,0 +1,193 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultLogoSize is the logo width as a percent of the video width when the request has none
+	DefaultLogoSize = 12.0
+
+	// MaxLogoBytes matches the upload size limit
+	MaxLogoBytes = 10 * 1024 * 1024
+)
+
+// downloadLogo fetches the job's logo for composition. A logo that can't be downloaded is
+// logged and left out rather than failing the video.
+func downloadLogo(
+	ctx context.Context,
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	logger *zap.Logger,
+	job *domain.Job,
+	tmpDir string,
+) string {
+	if job.LogoOverlay == nil || job.LogoOverlay.Asset == "" {
+		return ""
+	}
+	path := filepath.Join(tmpDir, "logo"+strings.ToLower(filepath.Ext(job.LogoOverlay.Asset)))
+	if err := s3Service.DownloadFile(ctx, assetsBucket, job.LogoOverlay.Asset, path); err != nil {
+		logger.Warn("Failed to download logo, composing without it",
+			zap.String("s3_key", job.LogoOverlay.Asset),
+			zap.Error(err),
+		)
+		return ""
+	}
+	return path
+}
+
+// logoMargin is the gap between the logo and the frame edges, as a fraction of the shorter side
+const logoMargin = 0.04
+
+// logoPlacement is where and when the logo is drawn, resolved against the video's size
+type logoPlacement struct {
+	Path         string
+	Position     string
+	Width        int     // Pixels; the height follows the logo's aspect ratio
+	Opacity      float64 // 0-1; 1 is opaque
+	Start        float64
+	End          float64 // 0 = until the end of the video
+	Margin       int     // Pixels from the side (and top) edges
+	BottomMargin int     // Pixels from the bottom edge
+}
+
+// newLogoPlacement resolves the job's logo settings for a videoWidth x videoHeight frame.
+// Bottom positions sit on the same safe area line as the text overlays.
+func newLogoPlacement(logo *domain.LogoOverlay, path string, videoWidth, videoHeight int) *logoPlacement {
+	size := logo.Size
+	if size <= 0 {
+		size = DefaultLogoSize
+	}
+	opacity := logo.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	position := logo.Position
+	if position == "" {
+		position = domain.LogoBottomRight
+	}
+	layout := textOverlayLayoutFor(videoWidth, videoHeight)
+
+	return &logoPlacement{
+		Path:         path,
+		Position:     position,
+		Width:        max(1, int(math.Round(float64(videoWidth)*size/100))),
+		Opacity:      opacity,
+		Start:        logo.Start,
+		End:          logo.End,
+		Margin:       int(math.Round(float64(min(videoWidth, videoHeight)) * logoMargin)),
+		BottomMargin: int(math.Round(float64(videoHeight) * layout.BottomSafeArea)),
+	}
+}
+
+// coordinates returns the overlay filter's x and y expressions
+func (p *logoPlacement) coordinates() (string, string) {
+	left := fmt.Sprintf("%d", p.Margin)
+	right := fmt.Sprintf("W-w-%d", p.Margin)
+	top := fmt.Sprintf("%d", p.Margin)
+	bottom := fmt.Sprintf("H-h-%d", p.BottomMargin)
+
+	switch p.Position {
+	case domain.LogoTopLeft:
+		return left, top
+	case domain.LogoTopRight:
+		return right, top
+	case domain.LogoBottomLeft:
+		return left, bottom
+	case domain.LogoBottomCenter:
+		return "(W-w)/2", bottom
+	default:
+		return right, bottom
+	}
+}
+
+// enable returns the overlay's enable expression, or "" when the logo is always shown
+func (p *logoPlacement) enable() string {
+	switch {
+	case p.End > 0:
+		return fmt.Sprintf("between(t,%.3f,%.3f)", p.Start, p.End)
+	case p.Start > 0:
+		return fmt.Sprintf("gte(t,%.3f)", p.Start)
+	default:
+		return ""
+	}
+}
+
+// overlayPass is the single re-encode that draws everything on top of the concatenated clips:
+// an optional frame rate conversion, the logo, then the drawtext chain (side effects text and
+// captions), so text is never hidden under the logo.
+type overlayPass struct {
+	FPS      int // Output frame rate; 0 keeps the source rate
+	Logo     *logoPlacement
+	Drawtext []string // drawtext filters, applied in order
+}
+
+// empty reports whether the pass would leave the video unchanged
+func (p overlayPass) empty() bool {
+	return p.FPS == 0 && p.Logo == nil && len(p.Drawtext) == 0
+}
+
+// filterGraph builds the filter for the pass: a plain -vf chain without a logo, or a
+// -filter_complex graph ending in [out] with one
+func (p overlayPass) filterGraph() string {
+	var chain []string
+	if p.FPS > 0 {
+		chain = append(chain, fmt.Sprintf("fps=%d", p.FPS))
+	}
+	if p.Logo == nil {
+		return strings.Join(append(chain, p.Drawtext...), ",")
+	}
+
+	// format=rgba keeps PNG transparency through the scale and lets JPEGs take an opacity
+	logo := fmt.Sprintf("[1:v]format=rgba,scale=%d:-1", p.Logo.Width)
+	if p.Logo.Opacity < 1 {
+		logo += fmt.Sprintf(",colorchannelmixer=aa=%.2f", p.Logo.Opacity)
+	}
+	graph := []string{logo + "[logo]"}
+
+	base := "[0:v]"
+	if len(chain) > 0 {
+		graph = append(graph, "[0:v]"+strings.Join(chain, ",")+"[base]")
+		base = "[base]"
+	}
+
+	x, y := p.Logo.coordinates()
+	overlay := fmt.Sprintf("overlay=x=%s:y=%s:format=auto", x, y)
+	if enable := p.Logo.enable(); enable != "" {
+		overlay += ":enable='" + enable + "'"
+	}
+	graph = append(graph, base+"[logo]"+strings.Join(append([]string{overlay}, p.Drawtext...), ",")+"[out]")
+	return strings.Join(graph, ";")
+}
+
+// args returns the ffmpeg arguments that run the pass from input to output. Audio is dropped;
+// it is mixed back in afterwards.
+func (p overlayPass) args(input, output string) []string {
+	args := []string{"-i", input}
+	if p.Logo != nil {
+		args = append(args,
+			"-i", p.Logo.Path,
+			"-filter_complex", p.filterGraph(),
+			"-map", "[out]",
+		)
+	} else {
+		args = append(args, "-vf", p.filterGraph())
+	}
+	return append(args,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "21",
+		"-an",
+		"-y", output,
+	)
+}
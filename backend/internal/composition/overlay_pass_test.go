@@ -0,0 +1,193 @@
+package composition
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestOverlayPassWithoutLogoIsPlainChain(t *testing.T) {
+	pass := overlayPass{FPS: 30, Drawtext: []string{"drawtext=text=a", "drawtext=text=b"}}
+	if got, want := pass.filterGraph(), "fps=30,drawtext=text=a,drawtext=text=b"; got != want {
+		t.Fatalf("unexpected chain:\ngot  %s\nwant %s", got, want)
+	}
+
+	want := []string{
+		"-i", "in.mp4",
+		"-vf", "fps=30,drawtext=text=a,drawtext=text=b",
+		"-c:v", "libx264", "-preset", "medium", "-crf", "21", "-an",
+		"-y", "out.mp4",
+	}
+	if got := pass.args("in.mp4", "out.mp4"); !slices.Equal(got, want) {
+		t.Fatalf("unexpected args:\ngot  %q\nwant %q", got, want)
+	}
+	if !(overlayPass{}).empty() || pass.empty() {
+		t.Fatalf("expected only the zero pass to be empty")
+	}
+}
+
+func TestOverlayPassDrawsTextOverLogo(t *testing.T) {
+	logo := &logoPlacement{
+		Path: "logo.png", Position: domain.LogoBottomRight, Width: 230, Opacity: 0.5,
+		Start: 1, End: 4.5, Margin: 43, BottomMargin: 86,
+	}
+	tests := []struct {
+		name string
+		pass overlayPass
+		want string
+	}{
+		{
+			name: "logo only",
+			pass: overlayPass{Logo: logo},
+			want: "[1:v]format=rgba,scale=230:-1,colorchannelmixer=aa=0.50[logo];" +
+				"[0:v][logo]overlay=x=W-w-43:y=H-h-86:format=auto:enable='between(t,1.000,4.500)'[out]",
+		},
+		{
+			name: "logo with text and interpolation",
+			pass: overlayPass{FPS: 30, Logo: logo, Drawtext: []string{"drawtext=text=a", "drawtext=text=b"}},
+			want: "[1:v]format=rgba,scale=230:-1,colorchannelmixer=aa=0.50[logo];" +
+				"[0:v]fps=30[base];" +
+				"[base][logo]overlay=x=W-w-43:y=H-h-86:format=auto:enable='between(t,1.000,4.500)'," +
+				"drawtext=text=a,drawtext=text=b[out]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pass.filterGraph(); got != tt.want {
+				t.Fatalf("unexpected graph:\ngot  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlayPassArgumentOrder(t *testing.T) {
+	pass := overlayPass{
+		Logo:     &logoPlacement{Path: "/tmp/job/logo.png", Position: domain.LogoTopLeft, Width: 100, Opacity: 1, Margin: 10},
+		Drawtext: []string{"drawtext=text=a"},
+	}
+	want := []string{
+		"-i", "in.mp4",
+		"-i", "/tmp/job/logo.png",
+		"-filter_complex", "[1:v]format=rgba,scale=100:-1[logo];[0:v][logo]overlay=x=10:y=10:format=auto,drawtext=text=a[out]",
+		"-map", "[out]",
+		"-c:v", "libx264", "-preset", "medium", "-crf", "21", "-an",
+		"-y", "out.mp4",
+	}
+	if got := pass.args("in.mp4", "out.mp4"); !slices.Equal(got, want) {
+		t.Fatalf("unexpected args:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestLogoPlacementCoordinates(t *testing.T) {
+	tests := []struct {
+		position string
+		x, y     string
+	}{
+		{domain.LogoTopLeft, "20", "20"},
+		{domain.LogoTopRight, "W-w-20", "20"},
+		{domain.LogoBottomLeft, "20", "H-h-60"},
+		{domain.LogoBottomRight, "W-w-20", "H-h-60"},
+		{domain.LogoBottomCenter, "(W-w)/2", "H-h-60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.position, func(t *testing.T) {
+			p := &logoPlacement{Position: tt.position, Margin: 20, BottomMargin: 60}
+			if x, y := p.coordinates(); x != tt.x || y != tt.y {
+				t.Fatalf("got x=%s y=%s, want x=%s y=%s", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
+
+func TestNewLogoPlacement(t *testing.T) {
+	p := newLogoPlacement(&domain.LogoOverlay{Asset: "users/u1/logo.png"}, "logo.png", 1080, 1920)
+	if p.Position != domain.LogoBottomRight || p.Width != 130 || p.Opacity != 1 {
+		t.Fatalf("expected bottom right, 12%% wide and opaque by default, got %+v", p)
+	}
+	if p.Margin != 43 || p.BottomMargin != 288 {
+		t.Fatalf("expected margins of 43px and the 9:16 bottom safe area (288px), got %d and %d", p.Margin, p.BottomMargin)
+	}
+	if enable := p.enable(); enable != "" {
+		t.Fatalf("expected the logo shown throughout, got %s", enable)
+	}
+
+	p = newLogoPlacement(&domain.LogoOverlay{Position: domain.LogoTopLeft, Size: 25, Opacity: 0.8, Start: 2}, "logo.png", 1920, 1080)
+	if p.Width != 480 || p.Opacity != 0.8 || p.enable() != "gte(t,2.000)" {
+		t.Fatalf("unexpected placement %+v (enable %s)", p, p.enable())
+	}
+}
+
+func TestOverlayPassRendersTransparentLogo(t *testing.T) {
+	ensureFfmpegAvailable(t)
+
+	// Left half opaque white, right half fully transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 50; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	logoPath := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(logoPath)
+	if err != nil {
+		t.Fatalf("failed to create logo: %v", err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("failed to encode logo: %v", err)
+	}
+	file.Close()
+
+	clipPath := createTestClip(t, 640, 360, 2.0)
+	logo := newLogoPlacement(&domain.LogoOverlay{Position: domain.LogoTopLeft, Size: 25, End: 1}, logoPath, 640, 360)
+	cues := []domain.CaptionCue{{Start: 0, End: 2, Text: "Ask your doctor."}}
+	pass := overlayPass{
+		Logo:     logo,
+		Drawtext: []string{captionsFilter(cues, 640, 360, detectAvailableFont(zap.NewNop()), 0, 0)},
+	}
+
+	finalPath := filepath.Join(t.TempDir(), "logo.mp4")
+	if output, err := exec.Command("ffmpeg", pass.args(clipPath, finalPath)...).CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg rejected the overlay pass: %v (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	brightness := func(framePath string, x, y int) uint32 {
+		t.Helper()
+		f, err := os.Open(framePath)
+		if err != nil {
+			t.Fatalf("failed to open frame: %v", err)
+		}
+		defer f.Close()
+		frame, err := png.Decode(f)
+		if err != nil {
+			t.Fatalf("failed to decode frame: %v", err)
+		}
+		c := color.RGBAModel.Convert(frame.At(x, y)).(color.RGBA)
+		return uint32(c.R) + uint32(c.G) + uint32(c.B)
+	}
+
+	// The logo is 160px wide at (14, 14)
+	during := extractFrame(t, finalPath, 0.5)
+	if b := brightness(during, logo.Margin+40, logo.Margin+40); b < 600 {
+		t.Fatalf("expected the opaque half of the logo drawn, brightness %d", b)
+	}
+	if b := brightness(during, logo.Margin+120, logo.Margin+40); b > 60 {
+		t.Fatalf("expected the transparent half of the logo to show the video, brightness %d", b)
+	}
+	if stats := analyzeFrame(t, during, 120); stats.maxY < 360/2 {
+		t.Fatalf("expected the caption drawn below the logo, bright pixels end at y=%d", stats.maxY)
+	}
+
+	after := extractFrame(t, finalPath, 1.5)
+	if b := brightness(after, logo.Margin+40, logo.Margin+40); b > 60 {
+		t.Fatalf("expected no logo after its window ends, brightness %d", b)
+	}
+}
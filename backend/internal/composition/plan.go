@@ -0,0 +1,135 @@
+package composition
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+)
+
+const (
+	// compositionHashLength is how many hex characters of the input hash go into the keys
+	compositionHashLength = 16
+
+	// S3 user metadata on a final video. The composition marker is only written with a video
+	// that passed its integrity check, so a marked object is always a complete composition.
+	HashMetadataKey     = "composition"
+	EncodingMetadataKey = "encoding"
+)
+
+// Plan is one composition's identity and where its final videos go
+type Plan struct {
+	Hash        string
+	VideoKey    string // Final MP4 (or MOV, per the output spec)
+	ContentType string
+	WebMKey     string
+}
+
+// compositionInputs is everything a final video is made from. Clips and audio are identified by
+// their ETags as well as their keys: reruns write new content to the same keys.
+type compositionInputs struct {
+	Clips     []compositionSource `json:"clips"`
+	Music     compositionSource   `json:"music"`
+	Narration compositionSource   `json:"narration"`
+	Settings  map[string]any      `json:"settings"`
+}
+
+type compositionSource struct {
+	Key  string `json:"key,omitempty"`
+	ETag string `json:"etag,omitempty"`
+}
+
+// NewPlan hashes what job's clips and audio compose into, and derives the final video
+// keys from it, so composing the same inputs again always targets the same objects
+func NewPlan(ctx context.Context, s3Service *repository.S3AssetRepository, bucket string, job *domain.Job, clips []ClipVideo) (Plan, error) {
+	source := func(url string) (compositionSource, error) {
+		if url == "" {
+			return compositionSource{}, nil
+		}
+		key := s3util.Key(url)
+		info, err := s3Service.ObjectInfo(ctx, bucket, key)
+		if err != nil {
+			return compositionSource{}, fmt.Errorf("failed to read composition input %s: %w", key, err)
+		}
+		return compositionSource{Key: key, ETag: info.ETag}, nil
+	}
+
+	inputs := compositionInputs{Settings: compositionSettings(job)}
+	for _, clip := range clips {
+		clipSource, err := source(clip.VideoURL)
+		if err != nil {
+			return Plan{}, err
+		}
+		inputs.Clips = append(inputs.Clips, clipSource)
+	}
+	var err error
+	if inputs.Music, err = source(job.AudioURL); err != nil {
+		return Plan{}, err
+	}
+	if inputs.Narration, err = source(job.NarratorAudioURL); err != nil {
+		return Plan{}, err
+	}
+
+	encoded, err := json.Marshal(inputs)
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to encode composition inputs: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	hash := hex.EncodeToString(sum[:])[:compositionHashLength]
+
+	videoKey, contentType := finalVideoObject(job, hash)
+	return Plan{
+		Hash:        hash,
+		VideoKey:    videoKey,
+		ContentType: contentType,
+		WebMKey:     FinalWebMKey(job, hash),
+	}, nil
+}
+
+// compositionSettings are the job fields that change the composed video. Logos and end card
+// images are uploads, which are never rewritten, so their keys identify them.
+func compositionSettings(job *domain.Job) map[string]any {
+	return map[string]any{
+		"aspect_ratio":            job.AspectRatio,
+		"side_effects_text":       job.SideEffectsText,
+		"side_effects_start_time": job.SideEffectsStartTime,
+		"side_effects_overflow":   job.SideEffectsOverflow,
+		"disclaimer_spec":         job.DisclaimerSpec,
+		"burn_captions":           job.BurnCaptions,
+		"captions":                job.Captions,
+		"logo_overlay":            job.LogoOverlay,
+		"end_card":                job.EndCard,
+		"call_to_action":          job.CallToAction,
+		"script_metadata":         job.ScriptMetadata,
+		"start_image":             job.StartImage,
+		"output_spec":             job.OutputSpec,
+	}
+}
+
+// uploadComposedVideo checks the composed file at path is a readable video of about expected
+// seconds, then uploads it to key marked as comp's output, along with its encoding when given.
+// Nothing is uploaded when the check fails, so a marked object is never a truncated or corrupt
+// composition.
+func uploadComposedVideo(ctx context.Context, s3Service *repository.S3AssetRepository, bucket string, comp Plan, key, path, contentType string, expected float64, encoding *domain.OutputEncoding) error {
+	format, err := ProbeClipFormat(ctx, path)
+	if err != nil {
+		return fmt.Errorf("composed video is not readable: %w", err)
+	}
+	if err := checkConcatDuration(format.Duration, expected); err != nil {
+		return fmt.Errorf("composed video failed its integrity check: %w", err)
+	}
+
+	metadata := map[string]string{HashMetadataKey: comp.Hash}
+	if encoding != nil {
+		if encoded, err := json.Marshal(encoding); err == nil {
+			metadata[EncodingMetadataKey] = string(encoded)
+		}
+	}
+	_, err = s3Service.UploadJobAssetWithMetadata(ctx, bucket, key, path, contentType, metadata)
+	return err
+}
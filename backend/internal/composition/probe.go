@@ -0,0 +1,99 @@
+package composition
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/ffmpegexec"
+)
+
+// ProbeVideoDimensions returns the width and height of a video file's first video stream
+func ProbeVideoDimensions(ctx context.Context, videoPath string) (int, int, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=p=0:s=x",
+		videoPath,
+	)
+	output, err := ffmpegexec.CommandOutput(ctx, "probe_dimensions", cmd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%dx%d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ffprobe dimensions: %w", err)
+	}
+	return width, height, nil
+}
+
+// probeVideoFPS returns the frame rate of a video file as a float64.
+// Returns 0 on error (caller should handle gracefully).
+func probeVideoFPS(ctx context.Context, videoPath string) float64 {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=r_frame_rate",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		videoPath,
+	)
+	output, err := ffmpegexec.CommandOutput(ctx, "probe_fps", cmd)
+	if err != nil {
+		return 0
+	}
+
+	// Output format is "num/den" (e.g., "24/1" or "30000/1001")
+	return parseFrameRate(string(output))
+}
+
+// MediaInfo is what the pipeline's probes report for a media file. Files without a video
+// stream only have a duration.
+type MediaInfo struct {
+	Codec    string  `json:"codec,omitempty"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	FPS      float64 `json:"fps,omitempty"`
+	PixFmt   string  `json:"pix_fmt,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+// ProbeMedia probes the file at path with runner as the pipeline probes clips, falling back to
+// its audio probe for files without a video stream
+func ProbeMedia(ctx context.Context, runner ffmpegexec.Runner, path string) (MediaInfo, error) {
+	ctx = ffmpegexec.WithRunner(ctx, runner)
+	format, err := ProbeClipFormat(ctx, path)
+	if err != nil {
+		duration, audioErr := ProbeAudioDuration(ctx, path)
+		if audioErr != nil {
+			return MediaInfo{}, err
+		}
+		return MediaInfo{Duration: duration}, nil
+	}
+	return MediaInfo{
+		Codec:    format.Codec,
+		Width:    format.Width,
+		Height:   format.Height,
+		FPS:      format.FPS,
+		PixFmt:   format.PixFmt,
+		Duration: format.Duration,
+	}, nil
+}
+
+// ProbeAudioDuration returns the duration of an audio file in seconds
+func ProbeAudioDuration(ctx context.Context, path string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	output, err := ffmpegexec.CommandOutput(ctx, "probe_audio", cmd)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+}
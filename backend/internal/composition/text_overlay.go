@@ -0,0 +1,395 @@
+package composition
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// detectAvailableFont returns the first available font file path from a prioritized list.
+func detectAvailableFont(logger *zap.Logger) string {
+	fontPaths := []string{
+		// Alpine Linux (Docker container)
+		"/usr/share/fonts/ttf-dejavu/DejaVuSans-Bold.ttf",
+		"/usr/share/fonts/ttf-dejavu/DejaVuSans.ttf",
+		// Debian/Ubuntu
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+		"/usr/share/fonts/truetype/liberation/LiberationSans-Bold.ttf",
+		"/usr/share/fonts/truetype/liberation2/LiberationSans-Bold.ttf",
+	}
+
+	for _, path := range fontPaths {
+		if _, err := os.Stat(path); err == nil {
+			logger.Info("Font detected for text overlay", zap.String("font_path", path))
+			return path
+		}
+	}
+
+	logger.Warn("No preferred fonts found, using ffmpeg default font")
+	return ""
+}
+
+// escapeFfmpegText escapes text for an unquoted drawtext text option in a -vf filtergraph.
+// ffmpeg unescapes the value twice, first parsing the filtergraph and then the filter's
+// options, so option-level escapes are escaped again for the graph. Quoting the value with
+// '...' instead can't carry an apostrophe. Newlines pass through and break lines.
+func escapeFfmpegText(text string) string {
+	option := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(text)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(option)
+}
+
+// textOverlayLayout places the side effects text for one aspect ratio
+type textOverlayLayout struct {
+	MaxLineWidth   float64 // Widest a line may be, as a fraction of frame width
+	BottomSafeArea float64 // Gap kept below the text block, as a fraction of frame height
+}
+
+// textOverlayLayouts are keyed by aspect ratio. Vertical video leaves more room at the bottom
+// because feed apps draw captions and buttons over it.
+var textOverlayLayouts = map[string]textOverlayLayout{
+	domain.AspectRatio16x9: {MaxLineWidth: 0.80, BottomSafeArea: 0.08},
+	domain.AspectRatio9x16: {MaxLineWidth: 0.85, BottomSafeArea: 0.15},
+	domain.AspectRatio1x1:  {MaxLineWidth: 0.85, BottomSafeArea: 0.10},
+}
+
+const (
+	// maxOverlayHeight caps the text block as a fraction of frame height. The font shrinks to
+	// fit, down to minReadableFontSize; longer text is paged or scrolled.
+	maxOverlayHeight = 0.35
+
+	// overlayCharWidth is the average glyph width relative to font size, used to wrap lines.
+	// Positioning uses the rendered width (text_w), so the estimate only has to be close.
+	overlayCharWidth = 0.6
+
+	minOverlayFontSize  = 18.0 // Floor in pixels at any frame size
+	minReadableFontSize = 24.0 // Smallest size the height cap may shrink to, at 1080p
+)
+
+// textOverlayLayoutFor picks the layout for the closest of the supported aspect ratios
+func textOverlayLayoutFor(videoWidth, videoHeight int) textOverlayLayout {
+	ratio := float64(videoWidth) / float64(videoHeight)
+	switch {
+	case ratio < 0.8:
+		return textOverlayLayouts[domain.AspectRatio9x16]
+	case ratio <= 1.25:
+		return textOverlayLayouts[domain.AspectRatio1x1]
+	default:
+		return textOverlayLayouts[domain.AspectRatio16x9]
+	}
+}
+
+type drawtextConfig struct {
+	Filter         string
+	OverlayStart   float64
+	OverlayEnd     float64
+	RuneCount      int
+	BaseFontSize   float64
+	FontSize       float64 // Pixels, after scaling to the frame and fitting the height cap
+	MaxChars       int     // Runes in the longest wrapped line
+	EstimatedWidth float64 // Estimated pixel width of the longest line
+	BlockHeight    float64 // Estimated pixel height of the wrapped text block
+	RenderedText   string
+	Overflow       string        // "" when the text fits, else domain.SideEffectsPaginate or domain.SideEffectsScroll
+	Pages          []overlayPage // Set when paginated
+}
+
+func buildDrawtextConfig(
+	logger *zap.Logger,
+	text string,
+	sideEffectsStartTime float64,
+	totalDuration float64,
+	videoWidth int,
+	videoHeight int,
+	overflow string,
+) (*drawtextConfig, error) {
+	trimmedText := strings.TrimSpace(text)
+	if trimmedText == "" {
+		return nil, nil
+	}
+
+	if totalDuration <= 0 {
+		logger.Warn("Skipping text overlay (unknown video duration)",
+			zap.Float64("video_duration", totalDuration),
+		)
+		return nil, nil
+	}
+
+	overlayStart := sideEffectsStartTime
+	if overlayStart <= 0 {
+		overlayStart = totalDuration * 0.8
+		logger.Warn("Non-positive side effects start time; defaulting to last 20%",
+			zap.Float64("original_start_time", sideEffectsStartTime),
+			zap.Float64("fallback_start_time", overlayStart),
+		)
+	}
+
+	overlayEnd := totalDuration
+	if overlayStart >= overlayEnd {
+		logger.Warn("Skipping text overlay (start time beyond duration)",
+			zap.Float64("side_effects_start_time", overlayStart),
+			zap.Float64("video_duration", overlayEnd),
+		)
+		return nil, nil
+	}
+
+	runeCount := utf8.RuneCountInString(trimmedText)
+	baseFontSize := 36.0
+	if runeCount > 360 {
+		baseFontSize = 32.0
+	}
+	if runeCount > 440 {
+		baseFontSize = 28.0
+	}
+
+	if videoWidth <= 0 {
+		videoWidth = 1920
+	}
+	if videoHeight <= 0 {
+		videoHeight = 1080
+	}
+	layout := textOverlayLayoutFor(videoWidth, videoHeight)
+
+	// Scale by the shorter side so vertical video doesn't get landscape-sized text on a
+	// landscape-width line
+	scaleFactor := float64(min(videoWidth, videoHeight)) / 1080.0
+	fontSizePixels := math.Max(baseFontSize*scaleFactor, minOverlayFontSize)
+
+	maxLineWidth := float64(videoWidth) * layout.MaxLineWidth
+	maxBlockHeight := float64(videoHeight) * maxOverlayHeight
+	minFontSize := math.Min(fontSizePixels, math.Max(minReadableFontSize*scaleFactor, minOverlayFontSize))
+	wrappedText, maxChars := wrapText(trimmedText, maxLineWidth, fontSizePixels)
+	blockHeight := textBlockHeight(wrappedText, fontSizePixels)
+	for blockHeight > maxBlockHeight && fontSizePixels > minFontSize {
+		fontSizePixels = math.Max(fontSizePixels-1, minFontSize)
+		wrappedText, maxChars = wrapText(trimmedText, maxLineWidth, fontSizePixels)
+		blockHeight = textBlockHeight(wrappedText, fontSizePixels)
+	}
+
+	config := &drawtextConfig{
+		OverlayStart:   overlayStart,
+		OverlayEnd:     overlayEnd,
+		RuneCount:      runeCount,
+		BaseFontSize:   baseFontSize,
+		FontSize:       fontSizePixels,
+		MaxChars:       maxChars,
+		EstimatedWidth: math.Min(float64(maxChars)*fontSizePixels*overlayCharWidth, float64(videoWidth)),
+		BlockHeight:    blockHeight,
+		RenderedText:   wrappedText,
+	}
+
+	style := drawtextStyle{
+		FontFile:     detectAvailableFont(logger),
+		FontSize:     fontSizePixels,
+		LineSpacing:  overlayLineSpacing(fontSizePixels),
+		BottomMargin: layout.BottomSafeArea,
+	}
+	if blockHeight <= maxBlockHeight {
+		config.Filter = style.filter(wrappedText, "", fmt.Sprintf("between(t,%.2f,%.2f)", overlayStart, overlayEnd))
+		return config, nil
+	}
+
+	// Too long to read at the smallest readable size: show it in parts instead
+	if overflow == domain.SideEffectsScroll {
+		config.Overflow = domain.SideEffectsScroll
+		windowTop := float64(videoHeight) * (1 - layout.BottomSafeArea - maxOverlayHeight)
+		config.Filter = style.filter(wrappedText,
+			scrollExpression(windowTop, maxBlockHeight, blockHeight, overlayStart, overlayEnd),
+			fmt.Sprintf("between(t,%.2f,%.2f)", overlayStart, overlayEnd))
+	} else {
+		config.Overflow = domain.SideEffectsPaginate
+		lines := strings.Split(wrappedText, "\n")
+		config.Pages = paginateOverlay(lines, overlayLinesPerPage(maxBlockHeight, fontSizePixels), overlayStart, overlayEnd)
+		filters := make([]string, len(config.Pages))
+		for i, page := range config.Pages {
+			filters[i] = style.filter(page.Text, "", page.enable(i == len(config.Pages)-1))
+		}
+		config.Filter = strings.Join(filters, ",")
+	}
+
+	logger.Info("Side effects text exceeds overlay height at minimum readable font size",
+		zap.Int("rune_count", runeCount),
+		zap.Float64("font_size", fontSizePixels),
+		zap.Float64("block_height", blockHeight),
+		zap.Float64("max_block_height", maxBlockHeight),
+		zap.String("overflow", config.Overflow),
+		zap.Int("pages", len(config.Pages)),
+	)
+	return config, nil
+}
+
+// overlayLineSpacing is the gap between wrapped lines, proportional to the font size
+func overlayLineSpacing(fontSize float64) int {
+	return int(math.Round(fontSize * (8.0 / 36.0)))
+}
+
+// textBlockHeight estimates the pixel height drawtext renders wrapped text at
+func textBlockHeight(wrapped string, fontSize float64) float64 {
+	lines := strings.Count(wrapped, "\n") + 1
+	return float64(lines)*fontSize + float64(lines-1)*float64(overlayLineSpacing(fontSize))
+}
+
+// wrapText breaks text into lines of at most maxLineWidth pixels at fontSize, estimating
+// glyph widths with overlayCharWidth. It returns the wrapped text and the rune count of its
+// longest line. Words longer than a line are kept whole.
+func wrapText(text string, maxLineWidth float64, fontSize float64) (string, int) {
+	maxCharsPerLine := int(maxLineWidth / (fontSize * overlayCharWidth))
+	if maxCharsPerLine < 20 {
+		maxCharsPerLine = 20
+	}
+
+	lines := make([]string, 0)
+	for _, rawLine := range strings.Split(text, "\n") {
+		words := strings.Fields(rawLine)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		var currentWords []string
+		currentLen := 0
+		for _, word := range words {
+			wordLen := utf8.RuneCountInString(word)
+			additionalLen := wordLen
+			if currentLen > 0 {
+				additionalLen++ // account for space
+			}
+
+			if currentLen > 0 && currentLen+additionalLen > maxCharsPerLine {
+				lines = append(lines, strings.Join(currentWords, " "))
+				currentWords = []string{word}
+				currentLen = wordLen
+			} else {
+				currentWords = append(currentWords, word)
+				currentLen += additionalLen
+			}
+		}
+
+		if len(currentWords) > 0 {
+			lines = append(lines, strings.Join(currentWords, " "))
+		}
+	}
+
+	longest := 0
+	for _, line := range lines {
+		longest = max(longest, utf8.RuneCountInString(line))
+	}
+	return strings.Join(lines, "\n"), longest
+}
+
+// drawtextStyle holds the drawtext options shared by every filter of one overlay
+type drawtextStyle struct {
+	FontFile     string
+	FontSize     float64
+	LineSpacing  int
+	BottomMargin float64 // Fraction of frame height kept clear below the text
+}
+
+// filter renders text as one drawtext filter shown while enable is true. An empty y anchors
+// the block above the bottom safe area. Text is drawn as is: expansion is off, so "%" needs no
+// escaping.
+func (s drawtextStyle) filter(text, y, enable string) string {
+	if y == "" {
+		y = fmt.Sprintf("h-text_h-h*%.4f", s.BottomMargin)
+	}
+
+	parts := []string{
+		"text=" + escapeFfmpegText(text),
+		"expansion=none",
+		fmt.Sprintf("fontsize=%.2f", s.FontSize),
+	}
+	if s.FontFile != "" {
+		parts = append(parts, fmt.Sprintf("fontfile='%s'", s.FontFile))
+	}
+	parts = append(parts,
+		"fontcolor=white",
+		"bordercolor=black",
+		"borderw=2",
+		"x=(w-text_w)/2",
+		fmt.Sprintf("y='%s'", y),
+		fmt.Sprintf("line_spacing=%d", s.LineSpacing),
+		fmt.Sprintf("enable='%s'", enable),
+	)
+	return "drawtext=" + strings.Join(parts, ":")
+}
+
+// overlayPage is one screenful of a paginated disclosure
+type overlayPage struct {
+	Text  string
+	Start float64 // Seconds
+	End   float64 // Seconds
+}
+
+// enable is the page's drawtext window. Pages own [Start, End) so two never share a frame;
+// the last one includes the end of the overlay.
+func (p overlayPage) enable(last bool) string {
+	if last {
+		return fmt.Sprintf("between(t,%.3f,%.3f)", p.Start, p.End)
+	}
+	return fmt.Sprintf("gte(t,%.3f)*lt(t,%.3f)", p.Start, p.End)
+}
+
+// paginateOverlay groups wrapped lines into pages of linesPerPage and splits the overlay window
+// between them in proportion to each page's character count, so every page gets the same
+// reading speed
+func paginateOverlay(lines []string, linesPerPage int, start, end float64) []overlayPage {
+	if linesPerPage < 1 {
+		linesPerPage = 1
+	}
+
+	var texts []string
+	var runes []int
+	total := 0
+	for i := 0; i < len(lines); {
+		// Don't start a page with a blank line
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		j := min(i+linesPerPage, len(lines))
+		text := strings.Join(lines[i:j], "\n")
+		n := utf8.RuneCountInString(strings.ReplaceAll(text, "\n", ""))
+		texts = append(texts, text)
+		runes = append(runes, n)
+		total += n
+		i = j
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	pages := make([]overlayPage, len(texts))
+	at := start
+	seen := 0
+	for i, text := range texts {
+		seen += runes[i]
+		pageEnd := start + (end-start)*float64(seen)/float64(total)
+		if i == len(texts)-1 {
+			pageEnd = end
+		}
+		pages[i] = overlayPage{Text: text, Start: at, End: pageEnd}
+		at = pageEnd
+	}
+	return pages
+}
+
+// overlayLinesPerPage is how many lines at fontSize fit in maxHeight pixels
+func overlayLinesPerPage(maxHeight, fontSize float64) int {
+	spacing := float64(overlayLineSpacing(fontSize))
+	return max(int((maxHeight+spacing)/(fontSize+spacing)), 1)
+}
+
+// scrollExpression is a drawtext y that crawls a block of blockHeight pixels up through a
+// window of windowHeight pixels starting at windowTop: the first lines are at the top of the
+// window when the overlay starts and the last lines at its bottom when it ends
+func scrollExpression(windowTop, windowHeight, blockHeight, start, end float64) string {
+	speed := (blockHeight - windowHeight) / (end - start) // Pixels per second
+	return fmt.Sprintf("%.2f-(t-%.3f)*%.4f", windowTop, start, speed)
+}
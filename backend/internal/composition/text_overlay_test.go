@@ -0,0 +1,207 @@
+package composition
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestPaginateOverlay(t *testing.T) {
+	lines := []string{
+		"May cause drowsiness and dizziness.",
+		"Do not drive.",
+		"",
+		"Tell your doctor about all the medicines you take.",
+		"Avoid alcohol.",
+		"Stop use if a rash appears.",
+	}
+	pages := paginateOverlay(lines, 2, 10, 20)
+
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d: %+v", len(pages), pages)
+	}
+	if pages[1].Text != "Tell your doctor about all the medicines you take.\nAvoid alcohol." {
+		t.Fatalf("expected the blank line to be skipped at the start of a page, got %q", pages[1].Text)
+	}
+	if pages[0].Start != 10 || pages[len(pages)-1].End != 20 {
+		t.Fatalf("expected pages to span the overlay window, got %.3f-%.3f", pages[0].Start, pages[len(pages)-1].End)
+	}
+
+	total := 0
+	for _, page := range pages {
+		total += utf8.RuneCountInString(strings.ReplaceAll(page.Text, "\n", ""))
+	}
+	for i, page := range pages {
+		if i > 0 && page.Start != pages[i-1].End {
+			t.Fatalf("page %d starts at %.3f, previous ends at %.3f", i, page.Start, pages[i-1].End)
+		}
+		runes := utf8.RuneCountInString(strings.ReplaceAll(page.Text, "\n", ""))
+		want := 10 * float64(runes) / float64(total)
+		if got := page.End - page.Start; math.Abs(got-want) > 1e-9 {
+			t.Fatalf("page %d shown for %.3fs, want %.3fs for %d of %d characters", i, got, want, runes, total)
+		}
+	}
+}
+
+func TestPaginateOverlayEdgeCases(t *testing.T) {
+	if pages := paginateOverlay([]string{"", " "}, 3, 0, 5); pages != nil {
+		t.Fatalf("expected no pages for blank text, got %+v", pages)
+	}
+
+	pages := paginateOverlay([]string{"one", "two"}, 0, 0, 4)
+	if len(pages) != 2 {
+		t.Fatalf("expected a page per line when linesPerPage < 1, got %d", len(pages))
+	}
+}
+
+func TestOverlayPageEnableWindowsDoNotOverlap(t *testing.T) {
+	page := overlayPage{Start: 4, End: 6.5}
+	if got, want := page.enable(false), "gte(t,4.000)*lt(t,6.500)"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if got, want := page.enable(true), "between(t,4.000,6.500)"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOverlayLinesPerPage(t *testing.T) {
+	// Eight lines of 24px text with 5px spacing take 227px
+	if got := overlayLinesPerPage(227, 24); got != 8 {
+		t.Fatalf("expected 8 lines, got %d", got)
+	}
+	if got := overlayLinesPerPage(10, 24); got != 1 {
+		t.Fatalf("expected at least one line, got %d", got)
+	}
+}
+
+func TestScrollExpression(t *testing.T) {
+	// 600px block through a 400px window over 10s crawls 20px/s
+	if got, want := scrollExpression(1000, 400, 600, 5, 15), "1000.00-(t-5.000)*20.0000"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+// drawtextFilters splits a filter chain built by buildDrawtextConfig into its drawtext filters
+func drawtextFilters(chain string) []string {
+	return strings.Split(strings.TrimPrefix(chain, "drawtext="), ",drawtext=")
+}
+
+func TestBuildDrawtextConfigPaginatesLongDisclosure(t *testing.T) {
+	text := strings.Repeat(fdaDisclosureText, 2)
+	config, err := buildDrawtextConfig(zap.NewNop(), text, 20, 30, 1920, 1080, "")
+	if err != nil || config == nil {
+		t.Fatalf("expected drawtext config, got %v (%v)", config, err)
+	}
+
+	if config.Overflow != domain.SideEffectsPaginate {
+		t.Fatalf("expected pagination by default, got %q", config.Overflow)
+	}
+	if config.FontSize < minReadableFontSize {
+		t.Fatalf("font shrank to %.1f, below the readable minimum", config.FontSize)
+	}
+	filters := drawtextFilters(config.Filter)
+	if len(config.Pages) < 2 || len(filters) != len(config.Pages) {
+		t.Fatalf("expected one drawtext per page, got %d filters for %d pages", len(filters), len(config.Pages))
+	}
+	for i, page := range config.Pages {
+		if !strings.Contains(filters[i], "enable='"+page.enable(i == len(config.Pages)-1)+"'") {
+			t.Fatalf("page %d filter lacks its enable window: %s", i, filters[i])
+		}
+	}
+}
+
+func TestBuildDrawtextConfigScrollsWhenRequested(t *testing.T) {
+	text := strings.Repeat(fdaDisclosureText, 2)
+	config, err := buildDrawtextConfig(zap.NewNop(), text, 20, 30, 1080, 1920, domain.SideEffectsScroll)
+	if err != nil || config == nil {
+		t.Fatalf("expected drawtext config, got %v (%v)", config, err)
+	}
+
+	if config.Overflow != domain.SideEffectsScroll || len(config.Pages) != 0 {
+		t.Fatalf("expected a scroll without pages, got %q with %d pages", config.Overflow, len(config.Pages))
+	}
+	if filters := drawtextFilters(config.Filter); len(filters) != 1 {
+		t.Fatalf("expected a single drawtext, got %d", len(filters))
+	}
+	if !strings.Contains(config.Filter, "-(t-20.000)*") {
+		t.Fatalf("expected y to move with t from the overlay start: %s", config.Filter)
+	}
+}
+
+func TestBuildDrawtextConfigOverflowModeIgnoredWhenTextFits(t *testing.T) {
+	config, err := buildDrawtextConfig(zap.NewNop(), "May cause drowsiness.", 4, 5, 1920, 1080, domain.SideEffectsScroll)
+	if err != nil || config == nil {
+		t.Fatalf("expected drawtext config, got %v (%v)", config, err)
+	}
+	if config.Overflow != "" || strings.Contains(config.Filter, "(t-") {
+		t.Fatalf("expected a static overlay for short text, got %q: %s", config.Overflow, config.Filter)
+	}
+}
+
+func TestBuildDrawtextConfigEscapesEveryPage(t *testing.T) {
+	sentence := `Don't take it if you're allergic: 1% of patients "felt dizzy". `
+	config, err := buildDrawtextConfig(zap.NewNop(), strings.Repeat(sentence, 40), 20, 30, 1920, 1080, domain.SideEffectsPaginate)
+	if err != nil || config == nil {
+		t.Fatalf("expected drawtext config, got %v (%v)", config, err)
+	}
+	if len(config.Pages) < 2 {
+		t.Fatalf("expected several pages, got %d", len(config.Pages))
+	}
+
+	for i, filter := range drawtextFilters(config.Filter) {
+		if got := drawtextText(filter); got != config.Pages[i].Text {
+			t.Fatalf("page %d text doesn't survive ffmpeg's unescaping:\ngot  %q\nwant %q", i, got, config.Pages[i].Text)
+		}
+	}
+}
+
+// ffmpegToken reads one token the way ffmpeg's av_get_token does: up to an unescaped character
+// in term, dropping backslash escapes and '...' quoting, trimmed of whitespace
+func ffmpegToken(s, term string) (token, rest string) {
+	var out []byte
+	end := 0
+	s = strings.TrimLeft(s, " \n\t\r")
+	i := 0
+	for i < len(s) && !strings.ContainsRune(term, rune(s[i])) {
+		c := s[i]
+		i++
+		switch {
+		case c == '\\' && i < len(s):
+			out = append(out, s[i])
+			i++
+			end = len(out)
+		case c == '\'':
+			for i < len(s) && s[i] != '\'' {
+				out = append(out, s[i])
+				i++
+			}
+			if i < len(s) {
+				i++
+				end = len(out)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	trimmed := strings.TrimRight(string(out[end:]), " \n\t\r")
+	return string(out[:end]) + trimmed, s[i:]
+}
+
+// drawtextText is the text a drawtext filter, as written into -vf, hands to drawtext: the
+// filtergraph is parsed first, then the filter's options
+func drawtextText(filter string) string {
+	args, _ := ffmpegToken(strings.TrimPrefix(filter, "drawtext="), "[],;")
+	for args != "" {
+		var option string
+		option, args = ffmpegToken(args, ":")
+		args = strings.TrimPrefix(args, ":")
+		if text, ok := strings.CutPrefix(option, "text="); ok {
+			return text
+		}
+	}
+	return ""
+}
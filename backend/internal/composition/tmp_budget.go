@@ -0,0 +1,194 @@
+package composition
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
+	"go.uber.org/zap"
+)
+
+// tmpBudgetHeadroom covers the concat list, thumbnails and container overhead
+const tmpBudgetHeadroom int64 = 16 * 1024 * 1024
+
+// estimateCompositionTmpBytes returns the peak /tmp usage of a composition.
+// Each stage (concat, overlay/fps re-encode, audio mux, WebM transcode) holds its input
+// and an output of about the same size, and inputs are released as soon as they are consumed,
+// so the peak is roughly twice the clips plus audio.
+func estimateCompositionTmpBytes(clipBytes []int64, audioBytes int64) int64 {
+	var video int64
+	for _, size := range clipBytes {
+		video += size
+	}
+
+	peak := 2 * video // downloaded clips + concat output
+	if mux := 2 * (video + audioBytes); mux > peak {
+		peak = mux // concat output + audio tracks + muxed output
+	}
+	return peak + tmpBudgetHeadroom
+}
+
+// checkTmpBudget fails when the estimated usage can't fit; a budget <= 0 disables the check
+func checkTmpBudget(estimate, budget int64) error {
+	if budget <= 0 || estimate <= budget {
+		return nil
+	}
+	return fmt.Errorf("composition needs about %dMB of temp storage but only %dMB is available (TMP_BUDGET_MB)",
+		bytesToMB(estimate), bytesToMB(budget))
+}
+
+// ErrInsufficientTmpSpace fails a composition the temp filesystem has no room for
+var ErrInsufficientTmpSpace = stderrors.New("insufficient temp space")
+
+// tmpFreeBytes returns the bytes free for unprivileged use on the filesystem holding path
+var tmpFreeBytes = func(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// checkTmpSpace fails when the estimated usage is more than the temp filesystem has free, so
+// the job fails with a clear error before ffmpeg runs out of space halfway through
+func checkTmpSpace(estimate, free int64) error {
+	if estimate <= free {
+		return nil
+	}
+	return fmt.Errorf("%w: composition needs about %dMB but %s has %dMB free",
+		ErrInsufficientTmpSpace, bytesToMB(estimate), JobTmpRoot, free/(1024*1024))
+}
+
+func bytesToMB(n int64) int64 {
+	return (n + 1024*1024 - 1) / (1024 * 1024)
+}
+
+// preflightCompositionTmp sizes the job's clips and audio in S3 and fails fast when the
+// composition can't fit in the tmp budget or in the space left on the temp filesystem. Sizing
+// errors skip the checks rather than fail the job.
+func preflightCompositionTmp(
+	ctx context.Context,
+	s3Service *repository.S3AssetRepository,
+	assetsBucket string,
+	logger *zap.Logger,
+	job *domain.Job,
+	clips []ClipVideo,
+	budget int64,
+) error {
+	clipBytes := make([]int64, 0, len(clips))
+	for i, clip := range clips {
+		size, err := s3Service.ObjectSize(ctx, assetsBucket, s3util.Key(clip.VideoURL))
+		if err != nil {
+			logger.Warn("Skipping tmp budget check (failed to size clip)",
+				zap.Int("clip", i+1),
+				zap.Error(err),
+			)
+			return nil
+		}
+		clipBytes = append(clipBytes, size)
+	}
+
+	var audioBytes int64
+	for _, url := range []string{job.AudioURL, job.NarratorAudioURL} {
+		if url == "" {
+			continue
+		}
+		if size, err := s3Service.ObjectSize(ctx, assetsBucket, s3util.Key(url)); err == nil {
+			audioBytes += size
+		}
+	}
+
+	estimate := estimateCompositionTmpBytes(clipBytes, audioBytes)
+	logger.Info("Composition tmp estimate",
+		zap.Int64("estimate_bytes", estimate),
+		zap.Int64("budget_bytes", budget),
+	)
+	if err := checkTmpBudget(estimate, budget); err != nil {
+		return err
+	}
+
+	free, err := tmpFreeBytes(JobTmpRoot)
+	if err != nil {
+		logger.Warn("Skipping temp space check (failed to stat the temp filesystem)", zap.Error(err))
+		return nil
+	}
+	return checkTmpSpace(estimate, free)
+}
+
+// tmpLedger accounts for composition files in /tmp so each intermediate is deleted
+// as soon as the next stage has consumed it
+type tmpLedger struct {
+	budget int64
+	used   int64
+	peak   int64
+	files  map[string]int64
+	logger *zap.Logger // Carries the job's request and job IDs
+}
+
+func newTmpLedger(budget int64, logger *zap.Logger) *tmpLedger {
+	return &tmpLedger{
+		budget: budget,
+		files:  make(map[string]int64),
+		logger: logger,
+	}
+}
+
+// add records a file written to /tmp
+func (l *tmpLedger) add(path string) {
+	if _, ok := l.files[path]; ok {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	l.files[path] = info.Size()
+	l.used += info.Size()
+	if l.used > l.peak {
+		l.peak = l.used
+	}
+	if l.budget > 0 && l.used > l.budget {
+		l.logger.Warn("Composition tmp usage over budget",
+			zap.Int64("used_bytes", l.used),
+			zap.Int64("budget_bytes", l.budget),
+		)
+	}
+}
+
+// release deletes files that are no longer needed and frees their bytes
+func (l *tmpLedger) release(paths ...string) {
+	for _, path := range paths {
+		size, ok := l.files[path]
+		if !ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			l.logger.Warn("Failed to delete composition temp file",
+				zap.String("path", path),
+				zap.Error(err),
+			)
+			continue
+		}
+		delete(l.files, path)
+		l.used -= size
+	}
+}
+
+// consume records output and releases the inputs it was built from.
+// Passing output as one of the inputs (a skipped stage) keeps it.
+func (l *tmpLedger) consume(output string, inputs ...string) {
+	l.add(output)
+	for _, input := range inputs {
+		if input != output {
+			l.release(input)
+		}
+	}
+}
+
+// JobTmpRoot holds each job's working directories, /tmp/{jobID}/...
+const JobTmpRoot = "/tmp"
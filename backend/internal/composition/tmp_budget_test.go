@@ -0,0 +1,120 @@
+package composition
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const mb = 1024 * 1024
+
+func TestEstimateCompositionTmpBytes(t *testing.T) {
+	// 8 x 60MB clips with 2MB of audio: concat output + muxed output dominate
+	clips := []int64{60 * mb, 60 * mb, 60 * mb, 60 * mb, 60 * mb, 60 * mb, 60 * mb, 60 * mb}
+	require.Equal(t, int64(2*(480+2)*mb)+tmpBudgetHeadroom, estimateCompositionTmpBytes(clips, 2*mb))
+
+	// Video only
+	require.Equal(t, int64(200*mb)+tmpBudgetHeadroom, estimateCompositionTmpBytes([]int64{100 * mb}, 0))
+
+	require.Equal(t, tmpBudgetHeadroom, estimateCompositionTmpBytes(nil, 0))
+}
+
+func TestCheckTmpBudget(t *testing.T) {
+	require.NoError(t, checkTmpBudget(400*mb, 512*mb))
+	require.NoError(t, checkTmpBudget(512*mb, 512*mb))
+	require.NoError(t, checkTmpBudget(4096*mb, 0), "zero budget disables the check")
+
+	err := checkTmpBudget(980*mb, 512*mb)
+	require.ErrorContains(t, err, "about 980MB")
+	require.ErrorContains(t, err, "only 512MB")
+}
+
+func TestCheckTmpSpace(t *testing.T) {
+	require.NoError(t, checkTmpSpace(400*mb, 512*mb))
+	require.NoError(t, checkTmpSpace(512*mb, 512*mb))
+
+	err := checkTmpSpace(980*mb, 300*mb)
+	require.ErrorIs(t, err, ErrInsufficientTmpSpace)
+	require.ErrorContains(t, err, "about 980MB")
+	require.ErrorContains(t, err, "300MB free")
+}
+
+func TestPreflightCompositionTmp_FailsWithoutTempSpace(t *testing.T) {
+	s3Service := newComposeTestS3(t)
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	clips := []ClipVideo{uploadComposeClip(t, s3Service, job, 1, "clip")}
+
+	free := tmpFreeBytes
+	t.Cleanup(func() { tmpFreeBytes = free })
+	tmpFreeBytes = func(string) (int64, error) { return 4 * mb, nil }
+
+	// The free space is checked even with the budget disabled
+	err := preflightCompositionTmp(context.Background(), s3Service, "assets", zap.NewNop(), job, clips, 0)
+	require.ErrorIs(t, err, ErrInsufficientTmpSpace)
+
+	tmpFreeBytes = func(string) (int64, error) { return 0, errors.New("statfs failed") }
+	require.NoError(t, preflightCompositionTmp(context.Background(), s3Service, "assets", zap.NewNop(), job, clips, 0))
+}
+
+func writeTmpFile(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	return path
+}
+
+func TestTmpLedger_ReleasesInputsAsTheyAreConsumed(t *testing.T) {
+	dir := t.TempDir()
+	ledger := newTmpLedger(0, zap.NewNop())
+
+	// Clips are downloaded, then concatenated
+	clip1 := writeTmpFile(t, dir, "clip-1.mp4", 100)
+	ledger.add(clip1)
+	clip2 := writeTmpFile(t, dir, "clip-2.mp4", 100)
+	ledger.add(clip2)
+	concat := writeTmpFile(t, dir, "final.mp4", 200)
+	ledger.consume(concat, clip1, clip2)
+
+	require.NoFileExists(t, clip1)
+	require.NoFileExists(t, clip2)
+	require.FileExists(t, concat)
+	require.Equal(t, int64(200), ledger.used)
+	require.Equal(t, int64(400), ledger.peak)
+
+	// Overlay re-encode consumes the concat output
+	overlay := writeTmpFile(t, dir, "video_with_text.mp4", 250)
+	ledger.consume(overlay, concat)
+	require.NoFileExists(t, concat)
+	require.Equal(t, int64(250), ledger.used)
+	require.Equal(t, int64(450), ledger.peak)
+
+	// A skipped stage (mux failed) hands back its input, which must survive
+	ledger.consume(overlay, overlay)
+	require.FileExists(t, overlay)
+	require.Equal(t, int64(250), ledger.used)
+
+	// WebM is added alongside the uploaded MP4
+	webm := writeTmpFile(t, dir, "final.webm", 150)
+	ledger.add(webm)
+	require.Equal(t, int64(400), ledger.used)
+	require.Equal(t, int64(450), ledger.peak)
+}
+
+func TestTmpLedger_IgnoresUntrackedAndMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	ledger := newTmpLedger(0, zap.NewNop())
+
+	ledger.add(filepath.Join(dir, "missing.mp4"))
+	require.Zero(t, ledger.used)
+
+	// Files the ledger didn't record are never deleted
+	untracked := writeTmpFile(t, dir, "concat.txt", 10)
+	ledger.release(untracked)
+	require.FileExists(t, untracked)
+}
@@ -0,0 +1,59 @@
+package composition
+
+import (
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/transitions"
+)
+
+// SceneBoundaries is how the clips of scenes, lasting durations, are joined in job's video: with
+// the script's transitions for a job that renders them, hard cuts otherwise
+func SceneBoundaries(job *domain.Job, scenes []domain.Scene, durations []float64) []transitions.Boundary {
+	if !job.RenderTransitions {
+		return nil
+	}
+	return transitions.Resolve(scenes, durations)
+}
+
+// ClipDurations lists the durations of clips
+func ClipDurations(clips []ClipVideo) []float64 {
+	durations := make([]float64, len(clips))
+	for i, clip := range clips {
+		durations[i] = clip.Duration
+	}
+	return durations
+}
+
+// SumDurations is the length of durations played back to back
+func SumDurations(durations []float64) float64 {
+	var total float64
+	for _, d := range durations {
+		total += d
+	}
+	return total
+}
+
+// ClipTimeline is how long job's scene clips play for in its video: their total less what its
+// blended transitions overlap. The end card comes on top.
+func ClipTimeline(job *domain.Job, clips []ClipVideo) float64 {
+	durations := ClipDurations(clips)
+	return SumDurations(durations) - transitions.Overlap(SceneBoundaries(job, job.Scenes, durations))
+}
+
+// transitionArgs joins clipPaths with graph, a transitions.FilterGraph, in a single encode,
+// video track only
+func transitionArgs(clipPaths []string, graph, output string) []string {
+	var args []string
+	for _, path := range clipPaths {
+		args = append(args, "-i", path)
+	}
+	return append(args,
+		"-filter_complex", graph,
+		"-map", "[vout]",
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "18",
+		"-pix_fmt", "yuv420p",
+		"-an",
+		"-y", output,
+	)
+}
@@ -0,0 +1,63 @@
+package documents
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// HasJavaScript reports whether a PDF carries JavaScript: a /JavaScript or /JS name in the file
+// or inside a Flate-compressed stream, which is where object streams hide actions. Names are
+// compared after decoding #xx escapes, so /J#61vaScript is caught too.
+func HasJavaScript(data []byte) bool {
+	if containsJavaScriptName(data) {
+		return true
+	}
+	for _, loc := range objPattern.FindAllIndex(data, -1) {
+		dict, stream, ok := readStream(data[loc[1]:])
+		if !ok || !bytes.Contains(dict, []byte("/FlateDecode")) {
+			continue
+		}
+		if inflated, err := inflate(stream); err == nil && containsJavaScriptName(inflated) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsJavaScriptName reports whether data has a /JavaScript or /JS name token
+func containsJavaScriptName(data []byte) bool {
+	for i := 0; i < len(data); i++ {
+		if data[i] != '/' {
+			continue
+		}
+		end := i + 1
+		for end < len(data) && !isDelimiter(data[end]) && !bytes.ContainsRune([]byte("()<>[]{}/%"), rune(data[end])) {
+			end++
+		}
+		switch decodeName(data[i+1 : end]) {
+		case "JavaScript", "JS":
+			return true
+		}
+		i = end - 1
+	}
+	return false
+}
+
+// decodeName expands the #xx escapes of a PDF name
+func decodeName(name []byte) string {
+	if !bytes.ContainsRune(name, '#') {
+		return string(name)
+	}
+	var out []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if b, err := strconv.ParseUint(string(name[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(b))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, name[i])
+	}
+	return string(out)
+}
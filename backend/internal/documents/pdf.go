@@ -0,0 +1,349 @@
+// Package documents extracts machine-readable content from uploaded documents.
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limits for uploaded brand documents
+const (
+	MaxPDFBytes  = 25 * 1024 * 1024 // Matches the upload limit for brand documents
+	MaxPDFPages  = 50
+	MaxPDFImages = 10 // Page images sent to vision for scanned documents
+
+	// maxInflatedStreamBytes caps a single decompressed stream (zip bomb guard)
+	maxInflatedStreamBytes = 16 * 1024 * 1024
+)
+
+// PDFContent is the text layer and embedded page images of a PDF
+type PDFContent struct {
+	Text      string
+	PageCount int
+	Images    [][]byte // JPEG (DCTDecode) images, in document order
+}
+
+// HasTextLayer reports whether enough text was extracted to skip vision analysis
+func (c *PDFContent) HasTextLayer() bool {
+	return len(strings.Fields(c.Text)) >= 20
+}
+
+var (
+	pageTypePattern = regexp.MustCompile(`/Type\s*/Page\b`)
+	objPattern      = regexp.MustCompile(`\d+\s+\d+\s+obj\b`)
+)
+
+// ExtractPDF reads the text layer of a PDF and collects embedded JPEG images.
+// Only standard (FlateDecode or unfiltered) content streams are decoded; text
+// drawn with custom font encodings may come out partially garbled.
+func ExtractPDF(data []byte) (*PDFContent, error) {
+	if len(data) > MaxPDFBytes {
+		return nil, fmt.Errorf("document is %d bytes, exceeds %d byte limit", len(data), MaxPDFBytes)
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("document is not a PDF")
+	}
+
+	content := &PDFContent{
+		PageCount: len(pageTypePattern.FindAllIndex(data, -1)),
+	}
+	if content.PageCount > MaxPDFPages {
+		return nil, fmt.Errorf("document has %d pages, exceeds %d page limit", content.PageCount, MaxPDFPages)
+	}
+
+	var text strings.Builder
+	for _, loc := range objPattern.FindAllIndex(data, -1) {
+		dict, stream, ok := readStream(data[loc[1]:])
+		if !ok {
+			continue
+		}
+
+		switch {
+		case isImage(dict):
+			if bytes.Contains(dict, []byte("/DCTDecode")) && len(content.Images) < MaxPDFImages {
+				content.Images = append(content.Images, stream)
+			}
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			inflated, err := inflate(stream)
+			if err != nil {
+				continue
+			}
+			appendText(&text, parseContentStream(inflated))
+		case !bytes.Contains(dict, []byte("/Filter")):
+			appendText(&text, parseContentStream(stream))
+		}
+	}
+
+	content.Text = strings.TrimSpace(text.String())
+	return content, nil
+}
+
+// readStream returns the dictionary and raw data of the stream object starting at obj, if any
+func readStream(obj []byte) (dict, stream []byte, ok bool) {
+	end := bytes.Index(obj, []byte("endobj"))
+	if end < 0 {
+		end = len(obj)
+	}
+	obj = obj[:end]
+
+	start := bytes.Index(obj, []byte("stream"))
+	if start < 0 {
+		return nil, nil, false
+	}
+	dict = obj[:start]
+
+	body := obj[start+len("stream"):]
+	body = bytes.TrimPrefix(body, []byte("\r"))
+	body = bytes.TrimPrefix(body, []byte("\n"))
+
+	stop := bytes.LastIndex(body, []byte("endstream"))
+	if stop < 0 {
+		return nil, nil, false
+	}
+	body = bytes.TrimRight(body[:stop], "\r\n")
+
+	// Prefer the declared /Length when it is a direct value within bounds
+	if m := lengthPattern.FindSubmatch(dict); m != nil {
+		if n, err := strconv.Atoi(string(m[1])); err == nil && n <= len(body) {
+			body = body[:n]
+		}
+	}
+	return dict, body, true
+}
+
+var lengthPattern = regexp.MustCompile(`/Length\s+(\d+)(?:\s*[/>])`)
+
+func isImage(dict []byte) bool {
+	return bytes.Contains(dict, []byte("/Subtype/Image")) || bytes.Contains(dict, []byte("/Subtype /Image"))
+}
+
+func inflate(stream []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(stream))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, maxInflatedStreamBytes))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	// Truncated streams still yield usable text up to the corruption point
+	return out, nil
+}
+
+func appendText(b *strings.Builder, s string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(s)
+}
+
+// parseContentStream extracts strings shown by the Tj, TJ, ' and " text operators
+func parseContentStream(stream []byte) string {
+	var out strings.Builder
+	var operands []string // pending string operands for the next operator
+	var inArray bool
+
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '(':
+			s, n := readLiteralString(stream[i:])
+			operands = append(operands, s)
+			i += n
+		case c == '<' && i+1 < len(stream) && stream[i+1] != '<':
+			s, n := readHexString(stream[i:])
+			operands = append(operands, s)
+			i += n
+		case c == '[':
+			inArray = true
+			operands = operands[:0]
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case isDelimiter(c):
+			i++
+		default:
+			start := i
+			for i < len(stream) && !isDelimiter(stream[i]) && !strings.ContainsRune("()<>[]%", rune(stream[i])) {
+				i++
+			}
+			token := string(stream[start:i])
+
+			if inArray {
+				// Large negative kerning inside TJ arrays usually separates words
+				if n, err := strconv.ParseFloat(token, 64); err == nil && n < -200 {
+					operands = append(operands, " ")
+				}
+				continue
+			}
+
+			switch token {
+			case "Tj", "TJ":
+				out.WriteString(strings.Join(operands, ""))
+			case "'", `"`:
+				out.WriteString("\n")
+				out.WriteString(strings.Join(operands, ""))
+			case "T*", "Td", "TD", "ET":
+				if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+					out.WriteString("\n")
+				}
+			}
+			if isOperator(token) {
+				operands = operands[:0]
+			}
+		}
+	}
+
+	return cleanText(out.String())
+}
+
+func isDelimiter(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// isOperator reports whether token is a content stream operator rather than an operand
+func isOperator(token string) bool {
+	if token == "" {
+		return false
+	}
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return false
+	}
+	return token[0] != '/'
+}
+
+// readLiteralString decodes a (...) string, returning it and the bytes consumed
+func readLiteralString(b []byte) (string, int) {
+	var out strings.Builder
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(b) {
+				return latin1(out.String()), len(b)
+			}
+			i++
+			switch e := b[i]; e {
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(b) && j < i+3 && b[j] >= '0' && b[j] <= '7' {
+						j++
+					}
+					n, _ := strconv.ParseUint(string(b[i:j]), 8, 8)
+					out.WriteByte(byte(n))
+					i = j - 1
+				} else {
+					out.WriteByte(e)
+				}
+			}
+		case '(':
+			if depth > 0 {
+				out.WriteByte(c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return latin1(out.String()), i + 1
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return latin1(out.String()), len(b)
+}
+
+// readHexString decodes a <...> string, returning it and the bytes consumed
+func readHexString(b []byte) (string, int) {
+	end := bytes.IndexByte(b, '>')
+	if end < 0 {
+		return "", len(b)
+	}
+
+	var digits []byte
+	for _, c := range b[1:end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		out = append(out, byte(n))
+	}
+
+	// Two-byte (UTF-16BE / CID) strings: keep the low byte of each pair when the high byte is zero
+	if len(out) >= 2 && len(out)%2 == 0 && out[0] == 0 {
+		narrow := make([]byte, 0, len(out)/2)
+		for i := 0; i < len(out); i += 2 {
+			narrow = append(narrow, out[i+1])
+		}
+		out = narrow
+	}
+	return latin1(string(out)), end + 1
+}
+
+// latin1 decodes single-byte text; WinAnsi and PDFDocEncoding match Latin-1 for common characters
+func latin1(s string) string {
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+// cleanText drops non-printable bytes and collapses repeated blank space
+func cleanText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteRune(r)
+		case r == '\t' || r == '\r':
+			b.WriteRune(' ')
+		case r >= 0x20 && r != 0x7f && r != 0xfffd:
+			b.WriteRune(r)
+		}
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,138 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return data
+}
+
+func TestExtractPDFTextLayer(t *testing.T) {
+	content, err := ExtractPDF(readFixture(t, "brand_text.pdf"))
+	if err != nil {
+		t.Fatalf("ExtractPDF() error = %v", err)
+	}
+
+	if content.PageCount != 2 {
+		t.Errorf("PageCount = %d, want 2", content.PageCount)
+	}
+	if len(content.Images) != 0 {
+		t.Errorf("Images = %d, want 0", len(content.Images))
+	}
+
+	expected := []string{
+		"Acme Brand Guidelines",                                  // Flate-compressed stream
+		"Primary colors: Ocean Blue (#0A84FF) and Sand #F4E1C1.", // escaped parens
+		"Typography: Helvetica Neue for headlines.",              // TJ array with kerning
+		"Brand voice: confident, warm, optimistic.",              // unfiltered stream
+		"Video style: bright, airy lifestyle footage",            // UTF-16 hex string + literal
+	}
+	for _, want := range expected {
+		if !strings.Contains(content.Text, want) {
+			t.Errorf("Text missing %q, got:\n%s", want, content.Text)
+		}
+	}
+	if !content.HasTextLayer() {
+		t.Error("expected HasTextLayer() for text PDF")
+	}
+}
+
+func TestExtractPDFScanned(t *testing.T) {
+	content, err := ExtractPDF(readFixture(t, "brand_scanned.pdf"))
+	if err != nil {
+		t.Fatalf("ExtractPDF() error = %v", err)
+	}
+
+	if content.HasTextLayer() {
+		t.Errorf("scanned PDF should have no text layer, got %q", content.Text)
+	}
+	if len(content.Images) != 1 {
+		t.Fatalf("Images = %d, want 1", len(content.Images))
+	}
+	if !bytes.HasPrefix(content.Images[0], []byte{0xff, 0xd8}) || !bytes.HasSuffix(content.Images[0], []byte{0xff, 0xd9}) {
+		t.Errorf("image is not a complete JPEG: % x", content.Images[0])
+	}
+}
+
+func TestExtractPDFLimits(t *testing.T) {
+	t.Run("not a PDF", func(t *testing.T) {
+		if _, err := ExtractPDF([]byte("PK\x03\x04 zip file")); err == nil {
+			t.Error("expected error for non-PDF input")
+		}
+	})
+
+	t.Run("too many pages", func(t *testing.T) {
+		var b strings.Builder
+		b.WriteString("%PDF-1.4\n")
+		for i := 0; i <= MaxPDFPages; i++ {
+			fmt.Fprintf(&b, "%d 0 obj\n<< /Type /Page >>\nendobj\n", i+1)
+		}
+		_, err := ExtractPDF([]byte(b.String()))
+		if err == nil || !strings.Contains(err.Error(), "page limit") {
+			t.Errorf("expected page limit error, got %v", err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		data := make([]byte, MaxPDFBytes+1)
+		copy(data, "%PDF-1.4\n")
+		_, err := ExtractPDF(data)
+		if err == nil || !strings.Contains(err.Error(), "byte limit") {
+			t.Errorf("expected size limit error, got %v", err)
+		}
+	})
+}
+
+func TestParseContentStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{"simple Tj", "BT (Hello) Tj ET", "Hello"},
+		{"nested parens", `BT (a (nested) b) Tj ET`, "a (nested) b"},
+		{"octal escape", `BT (caf\351 \101) Tj ET`, "café A"},
+		{"quote operator", "BT (one) Tj (two) ' ET", "one\ntwo"},
+		{"lines split by Td", "BT (first) Tj 0 -14 Td (second) Tj ET", "first\nsecond"},
+		{"non text operands ignored", "BT /F1 12 Tf 1 0 0 1 50 50 Tm (x) Tj ET", "x"},
+		{"comment skipped", "% (not shown) Tj\nBT (shown) Tj ET", "shown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseContentStream([]byte(tt.stream)); got != tt.want {
+				t.Errorf("parseContentStream() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasJavaScript(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"text layer PDF", readFixture(t, "brand_text.pdf"), false},
+		{"scanned PDF", readFixture(t, "brand_scanned.pdf"), false},
+		{"action in a compressed object stream", readFixture(t, "brand_javascript.pdf"), true},
+		{"open action", []byte("%PDF-1.4\n1 0 obj\n<</OpenAction <</S/JavaScript/JS(app.alert(1))>>>>\nendobj\n"), true},
+		{"escaped name", []byte("%PDF-1.4\n1 0 obj\n<< /AA << /O << /S /J#61vaScript /J#53 (x) >> >> >>\nendobj\n"), true},
+		{"similar names", []byte("%PDF-1.4\n1 0 obj\n<< /JSON (x) /JavaScripts (y) >>\nendobj\n"), false},
+	}
+	for _, tt := range tests {
+		if got := HasJavaScript(tt.data); got != tt.want {
+			t.Errorf("%s: HasJavaScript() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package domain
+
+// Asset verification statuses
+const (
+	AssetStatusVerified = "verified"
+	AssetStatusRejected = "rejected"
+)
+
+// Asset records the verification of one uploaded asset. Assets without a record haven't been
+// verified yet, and jobs only use verified ones.
+type Asset struct {
+	AssetKey    string `json:"asset_key" dynamodbav:"asset_key"` // S3 key of the upload
+	UserID      string `json:"user_id" dynamodbav:"user_id"`
+	Status      string `json:"status" dynamodbav:"status"`                       // "verified" or "rejected"
+	Reason      string `json:"reason,omitempty" dynamodbav:"reason,omitempty"`   // Why it was rejected, e.g. "type_mismatch"
+	Message     string `json:"message,omitempty" dynamodbav:"message,omitempty"` // Rejection explained for the user
+	ContentType string `json:"content_type" dynamodbav:"content_type"`
+	SizeBytes   int64  `json:"size_bytes" dynamodbav:"size_bytes"`
+	Width       int    `json:"width,omitempty" dynamodbav:"width,omitempty"` // Images only
+	Height      int    `json:"height,omitempty" dynamodbav:"height,omitempty"`
+	Threat      string `json:"-" dynamodbav:"threat,omitempty"`      // Scanner's name for the malware found; kept out of API responses
+	VerifiedAt  int64  `json:"verified_at" dynamodbav:"verified_at"` // Unix timestamp
+}
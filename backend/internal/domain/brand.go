@@ -0,0 +1,22 @@
+package domain
+
+// BrandGuidelines captures a user's brand identity for injection into script generation
+type BrandGuidelines struct {
+	GuidelineID string   `dynamodbav:"guideline_id" json:"guideline_id"`
+	UserID      string   `dynamodbav:"user_id" json:"user_id"`
+	Name        string   `dynamodbav:"name" json:"name"`
+	IsActive    bool     `dynamodbav:"is_active" json:"is_active"`
+	Colors      []string `dynamodbav:"colors,omitempty" json:"colors,omitempty"`           // Palette, e.g. "#0A84FF" or "Ocean blue"
+	Typography  string   `dynamodbav:"typography,omitempty" json:"typography,omitempty"`   // Font family / type treatment
+	BrandVoice  []string `dynamodbav:"brand_voice,omitempty" json:"brand_voice,omitempty"` // Voice adjectives, e.g. "confident", "warm"
+	ImageStyle  string   `dynamodbav:"image_style,omitempty" json:"image_style,omitempty"` // Photography / illustration guidance
+	VideoStyle  string   `dynamodbav:"video_style,omitempty" json:"video_style,omitempty"` // Preferred visual treatment
+	LogoURLs    []string `dynamodbav:"logo_urls,omitempty" json:"logo_urls,omitempty"`     // Logo asset URLs
+
+	// Uploaded brand book (S3 key) and when it was last parsed into the fields above
+	SourceDocument string `dynamodbav:"source_document,omitempty" json:"source_document,omitempty"`
+	ExtractedAt    int64  `dynamodbav:"extracted_at,omitempty" json:"extracted_at,omitempty"`
+
+	CreatedAt int64 `dynamodbav:"created_at" json:"created_at"`
+	UpdatedAt int64 `dynamodbav:"updated_at" json:"updated_at"`
+}
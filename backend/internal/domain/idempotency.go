@@ -0,0 +1,11 @@
+package domain
+
+// IdempotencyRecord maps a client's Idempotency-Key to the job its first request created
+type IdempotencyRecord struct {
+	Key         string `dynamodbav:"idempotency_key"` // user_id#key, so keys are scoped per user
+	UserID      string `dynamodbav:"user_id"`
+	JobID       string `dynamodbav:"job_id"`
+	RequestHash string `dynamodbav:"request_hash"` // SHA-256 of the request body; a different body is a conflict
+	CreatedAt   int64  `dynamodbav:"created_at"`
+	ExpiresAt   int64  `dynamodbav:"ttl"` // DynamoDB TTL; deletion is lazy, so readers check it too
+}
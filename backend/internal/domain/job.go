@@ -1,28 +1,57 @@
 package domain
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Job represents a video generation job
 type Job struct {
-	JobID    string `dynamodbav:"job_id" json:"job_id"`
-	UserID   string `dynamodbav:"user_id" json:"user_id"`
-	ScriptID string `dynamodbav:"script_id,omitempty" json:"script_id,omitempty"`
-	Status   string `dynamodbav:"status" json:"status"`                   // pending, processing, completed, failed
-	Stage    string `dynamodbav:"stage,omitempty" json:"stage,omitempty"` // Granular progress: script_generating, scene_1_complete, etc.
+	JobID    string   `dynamodbav:"job_id" json:"job_id"`
+	UserID   string   `dynamodbav:"user_id" json:"user_id"`
+	ScriptID string   `dynamodbav:"script_id,omitempty" json:"script_id,omitempty"`
+	Status   string   `dynamodbav:"status" json:"status"`                  // pending, queued, processing, script_ready, completed, failed, canceled
+	Stage    JobStage `dynamodbav:"stage,omitempty" json:"stage,omitzero"` // Granular progress, stored as script_generating, scene_1_complete, etc.
+
+	// Where the job's generated assets are stored, frozen when it was created. Jobs without a
+	// bucket predate this and use the legacy layout: the default bucket, no key prefix.
+	AssetBucket string `dynamodbav:"asset_bucket,omitempty" json:"asset_bucket,omitempty"`
+	AssetPrefix string `dynamodbav:"asset_prefix,omitempty" json:"asset_prefix,omitempty"` // e.g. "env/staging/"
 
 	// Progress fields (structured for better API responses)
-	ThumbnailURL     string   `dynamodbav:"thumbnail_url,omitempty" json:"thumbnail_url,omitempty"`
-	AudioURL         string   `dynamodbav:"audio_url,omitempty" json:"audio_url,omitempty"`
-	NarratorAudioURL string   `dynamodbav:"narrator_audio_url,omitempty" json:"narrator_audio_url,omitempty"`
-	ScenesCompleted  int      `dynamodbav:"scenes_completed,omitempty" json:"scenes_completed,omitempty"`
-	SceneVideoURLs   []string `dynamodbav:"scene_video_urls,omitempty" json:"scene_video_urls,omitempty"`
+	ThumbnailURL     string               `dynamodbav:"thumbnail_url,omitempty" json:"thumbnail_url,omitempty"`
+	Thumbnails       []ThumbnailRendition `dynamodbav:"thumbnails,omitempty" json:"thumbnails,omitempty"` // Every rendition of the job thumbnail, including the one at ThumbnailURL
+	AudioURL         string               `dynamodbav:"audio_url,omitempty" json:"audio_url,omitempty"`
+	NarratorAudioURL string               `dynamodbav:"narrator_audio_url,omitempty" json:"narrator_audio_url,omitempty"`
+	ScenesCompleted  int                  `dynamodbav:"scenes_completed,omitempty" json:"scenes_completed,omitempty"`
+	SceneVideoURLs   []string             `dynamodbav:"scene_video_urls,omitempty" json:"scene_video_urls,omitempty"`
 
 	Prompt      string `dynamodbav:"prompt,omitempty" json:"prompt,omitempty"`
 	Title       string `dynamodbav:"title,omitempty" json:"title,omitempty"` // Video title
 	Duration    int    `dynamodbav:"duration,omitempty" json:"duration,omitempty"`
 	AspectRatio string `dynamodbav:"aspect_ratio,omitempty" json:"aspect_ratio,omitempty"`
+	StartImage  string `dynamodbav:"start_image,omitempty" json:"start_image,omitempty"` // Product image used for the final scene
+
+	// Set by the owner with PATCH /jobs/:id. A renamed job keeps its title when the script is written.
+	TitleEdited bool   `dynamodbav:"title_edited,omitempty" json:"title_edited,omitempty"`
+	Note        string `dynamodbav:"note,omitempty" json:"note,omitempty"`
+
+	// Request options a queued job needs to start later
+	StyleReferenceImage string `dynamodbav:"style_reference_image,omitempty" json:"style_reference_image,omitempty"`
+	Preview             bool   `dynamodbav:"preview,omitempty" json:"preview,omitempty"`
 
 	// Pharmaceutical ad configuration
-	Voice       string `dynamodbav:"voice,omitempty" json:"voice,omitempty"`               // "male" or "female"
+	Voice       string `dynamodbav:"voice,omitempty" json:"voice,omitempty"`               // "male", "female" or an ElevenLabs voice ID
 	SideEffects string `dynamodbav:"side_effects,omitempty" json:"side_effects,omitempty"` // User-provided disclosure text
+	TTSProvider string `dynamodbav:"tts_provider,omitempty" json:"tts_provider,omitempty"` // "openai" or "elevenlabs"
+
+	// How a disclosure too long for the screen is shown: "paginate" (default) or "scroll"
+	SideEffectsOverflow string `dynamodbav:"side_effects_overflow,omitempty" json:"side_effects_overflow,omitempty"`
+
+	// BCP-47 tag of the language the narration, voiceovers, title and call to action are written
+	// in, e.g. "es-MX"; empty is English
+	Language string `dynamodbav:"language,omitempty" json:"language,omitempty"`
 
 	// Enhanced prompt options (Phase 1 - all optional)
 	Style             string `dynamodbav:"style,omitempty" json:"style,omitempty"`
@@ -35,6 +64,9 @@ type Job struct {
 	ProCinematography bool   `dynamodbav:"pro_cinematography,omitempty" json:"pro_cinematography,omitempty"`
 	CreativeBoost     bool   `dynamodbav:"creative_boost,omitempty" json:"creative_boost,omitempty"`
 
+	// Brand guidelines applied to script generation (for traceability)
+	BrandGuidelineID string `dynamodbav:"brand_guideline_id,omitempty" json:"brand_guideline_id,omitempty"`
+
 	// Embedded script data
 	Scenes         []Scene   `dynamodbav:"scenes,omitempty" json:"scenes,omitempty"`
 	AudioSpec      AudioSpec `dynamodbav:"audio_spec,omitempty" json:"audio_spec,omitempty"`
@@ -49,13 +81,112 @@ type Job struct {
 	NarrationBudget float64         `dynamodbav:"narration_budget,omitempty" json:"narration_budget,omitempty"`
 	NarrationWords  int             `dynamodbav:"narration_words,omitempty" json:"narration_words,omitempty"`
 
+	// Pharmaceutical compliance problems found in the script; warnings unless the job failed on them
+	ComplianceIssues []ComplianceIssue `dynamodbav:"compliance_issues,omitempty" json:"compliance_issues,omitempty"`
+
+	// Content policy problems found before any clip was generated; the job failed on them
+	ModerationFlags []ModerationFlag `dynamodbav:"moderation_flags,omitempty" json:"moderation_flags,omitempty"`
+
+	// Final narration timing after fitting the voiceover to the video
+	NarrationDuration  float64 `dynamodbav:"narration_duration,omitempty" json:"narration_duration,omitempty"`   // Seconds, after speed-up
+	NarrationSpeed     float64 `dynamodbav:"narration_speed,omitempty" json:"narration_speed,omitempty"`         // Applied atempo factor (1.0 = unchanged)
+	NarrationTruncated bool    `dynamodbav:"narration_truncated,omitempty" json:"narration_truncated,omitempty"` // Script cut at a sentence boundary to fit
+
+	// Finish without background music, rather than fail, if every music provider fails
+	AllowSilentFallback bool `dynamodbav:"allow_silent_fallback,omitempty" json:"allow_silent_fallback,omitempty"`
+
+	// Music model that produced the background track: "minimax", or "musicgen" when Minimax failed
+	MusicProvider string `dynamodbav:"music_provider,omitempty" json:"music_provider,omitempty"`
+
+	// Background music adjustment after fitting the generated track to the video
+	MusicRawURL      string  `dynamodbav:"music_raw_url,omitempty" json:"music_raw_url,omitempty"`           // Track as generated, before loop/trim
+	MusicRawDuration float64 `dynamodbav:"music_raw_duration,omitempty" json:"music_raw_duration,omitempty"` // Seconds, as generated
+	MusicFit         string  `dynamodbav:"music_fit,omitempty" json:"music_fit,omitempty"`                   // "none", "loop" or "trim"
+	MusicLoopCount   int     `dynamodbav:"music_loop_count,omitempty" json:"music_loop_count,omitempty"`     // Copies joined when looped
+	MusicSyncOffset  float64 `dynamodbav:"music_sync_offset,omitempty" json:"music_sync_offset,omitempty"`   // Seconds the track was delayed (negative: intro trimmed) to land a beat on the first beat sync point
+
+	// Measured EBU R128 loudness before and after normalization (for debugging mix levels)
+	MusicLoudness     *LoudnessMeasurement `dynamodbav:"music_loudness,omitempty" json:"music_loudness,omitempty"`
+	NarrationLoudness *LoudnessMeasurement `dynamodbav:"narration_loudness,omitempty" json:"narration_loudness,omitempty"`
+
 	VideoKey     string `dynamodbav:"video_key,omitempty" json:"video_key,omitempty"`           // S3 key (MP4)
 	WebMVideoKey string `dynamodbav:"webm_video_key,omitempty" json:"webm_video_key,omitempty"` // S3 key (WebM)
 	Model        string `dynamodbav:"model,omitempty" json:"model,omitempty"`                   // Video generation model (e.g., "Veo 3.1")
 
+	// Per-scene voiceover clips, scheduled by the frontend on its audio timeline
+	SceneVoiceovers []SceneVoiceover `dynamodbav:"scene_voiceovers,omitempty" json:"scene_voiceovers,omitempty"`
+
+	// Optional sound effects generated from the script's "sfx" sync points
+	GenerateSFX bool      `dynamodbav:"generate_sfx,omitempty" json:"generate_sfx,omitempty"`
+	SFX         []SFXClip `dynamodbav:"sfx,omitempty" json:"sfx,omitempty"`
+
+	// Narrator captions timed to the narration audio, published as WebVTT and optionally burned in
+	BurnCaptions bool         `dynamodbav:"burn_captions,omitempty" json:"burn_captions,omitempty"`
+	Captions     []CaptionCue `dynamodbav:"captions,omitempty" json:"captions,omitempty"`
+	CaptionsKey  string       `dynamodbav:"captions_key,omitempty" json:"captions_key,omitempty"` // S3 key (WebVTT)
+
+	// Hover-scrub preview of the final video: a sprite sheet of frames and its WebVTT index (S3 keys)
+	ScrubSpriteKey string `dynamodbav:"scrub_sprite_key,omitempty" json:"scrub_sprite_key,omitempty"`
+	ScrubVTTKey    string `dynamodbav:"scrub_vtt_key,omitempty" json:"scrub_vtt_key,omitempty"`
+
+	// Optional logo composited over the final video
+	LogoOverlay *LogoOverlay `dynamodbav:"logo_overlay,omitempty" json:"logo_overlay,omitempty"`
+
+	// Scenes are joined with their script transitions (see package transitions) rather than hard
+	// cuts; set on new jobs while the server renders transitions
+	RenderTransitions bool `dynamodbav:"render_transitions,omitempty" json:"render_transitions,omitempty"`
+
+	// Request fields the job's settings were filled into from the user's saved preferences, e.g.
+	// "aspect_ratio"; the job's own fields hold the values that applied
+	PreferencesApplied []string `dynamodbav:"preferences_applied,omitempty" json:"preferences_applied,omitempty"`
+
+	// Product photos GPT-4o may assign to scenes (see Scene.AssignedImageRef)
+	ProductImages []ProductImage `dynamodbav:"product_images,omitempty" json:"product_images,omitempty"`
+
+	// Scenes the user asked for, in order; the script was written to match them exactly
+	ScenePlan []PlannedScene `dynamodbav:"scene_plan,omitempty" json:"scene_plan,omitempty"`
+
+	// How each scene follows on from the last: "frame" (default), "style" or "none"
+	ContinuityMode string `dynamodbav:"continuity_mode,omitempty" json:"continuity_mode,omitempty"`
+	// Visual style GPT-4o read from scene 1's last frame, appended to later prompts in style mode
+	ContinuityStyle string `dynamodbav:"continuity_style,omitempty" json:"continuity_style,omitempty"`
+
+	// Optional static end card appended after the last clip
+	EndCard *EndCard `dynamodbav:"end_card,omitempty" json:"end_card,omitempty"`
+
+	// Optional encoding of the final MP4, and how it was actually encoded
+	OutputSpec *OutputSpec     `dynamodbav:"output_spec,omitempty" json:"output_spec,omitempty"`
+	Encoding   *OutputEncoding `dynamodbav:"encoding,omitempty" json:"encoding,omitempty"`
+
+	// Credits charged when the job was created, and the usage period they were taken from (for refunds)
+	CreditsCharged int    `dynamodbav:"credits_charged,omitempty" json:"credits_charged,omitempty"`
+	CreditPeriod   string `dynamodbav:"credit_period,omitempty" json:"credit_period,omitempty"`
+
+	// Webhook notified when the job completes or fails; the secret signs each delivery and is never returned
+	CallbackURL        string `dynamodbav:"callback_url,omitempty" json:"callback_url,omitempty"`
+	CallbackSecret     string `dynamodbav:"callback_secret,omitempty" json:"-"`
+	WebhookAttempts    int    `dynamodbav:"webhook_attempts,omitempty" json:"webhook_attempts,omitempty"`
+	WebhookDeliveredAt *int64 `dynamodbav:"webhook_delivered_at,omitempty" json:"webhook_delivered_at,omitempty"`
+
+	// Set by POST /jobs/:id/cancel; the pipeline stops at its next stage boundary and marks the job canceled
+	CancelRequested bool   `dynamodbav:"cancel_requested,omitempty" json:"cancel_requested,omitempty"`
+	CanceledAt      *int64 `dynamodbav:"canceled_at,omitempty" json:"canceled_at,omitempty"`
+
+	// Platform renditions of the final video made by POST /jobs/:id/renditions, one per target
+	Renditions []Rendition `dynamodbav:"renditions,omitempty" json:"renditions,omitempty"`
+
+	// Scene orderings replaced by POST /jobs/:id/scenes/reorder, oldest first; undoing restores the last
+	SceneOrderHistory []SceneOrderSnapshot `dynamodbav:"scene_order_history,omitempty" json:"scene_order_history,omitempty"`
+
 	// Scene versioning: maps scene number (1-indexed) to current version
 	SceneVersions map[int]int `dynamodbav:"scene_versions,omitempty" json:"scene_versions,omitempty"`
 
+	// Generation prompt of each clip version, keyed like ClipVersions; "scene-{N}-v0" is the original clip
+	PromptVersions map[string]string `dynamodbav:"prompt_versions,omitempty" json:"prompt_versions,omitempty"`
+
+	// Trimmed clip versions, keyed like ClipVersions, with the cut each was made by
+	ClipTrims map[string]ClipTrim `dynamodbav:"clip_trims,omitempty" json:"clip_trims,omitempty"`
+
 	// All clip versions: maps "scene-{N}-v{V}" to S3 URL
 	ClipVersions map[string]string `dynamodbav:"clip_versions,omitempty" json:"clip_versions,omitempty"`
 	CreatedAt    int64             `dynamodbav:"created_at" json:"created_at"`
@@ -63,6 +194,292 @@ type Job struct {
 	CompletedAt  *int64            `dynamodbav:"completed_at,omitempty" json:"completed_at,omitempty"`
 	ErrorMessage *string           `dynamodbav:"error_message,omitempty" json:"error_message,omitempty"`
 	TTL          int64             `dynamodbav:"ttl" json:"ttl"` // Unix timestamp for auto-deletion
+
+	// Where and why the pipeline failed, for support staff; users only see ErrorMessage
+	FailureStage string `dynamodbav:"failure_stage,omitempty" json:"failure_stage,omitempty"` // Stage being run, e.g. scene_2_generating
+	FailureError string `dynamodbav:"failure_error,omitempty" json:"failure_error,omitempty"` // Raw internal error
+	Requeues     int    `dynamodbav:"requeues,omitempty" json:"requeues,omitempty"`           // Times an admin reran the job after it failed
+
+	// Every provider call made for the job, in the order they finished. Appended as each call
+	// finishes, so a failed job still shows the prediction that failed.
+	Provenance []ProvenanceEntry `dynamodbav:"provenance,omitempty" json:"provenance,omitempty"`
+
+	// Time and credits of each pipeline step that finished, keyed like provenance steps ("script",
+	// "scene_2", "narrator", "music", "composition"). Each step is written as it finishes, so a
+	// failed job keeps the stats of the steps before the failure.
+	StepStats map[string]StepStats `dynamodbav:"step_stats,omitempty" json:"step_stats,omitempty"`
+
+	// How far the composition's current ffmpeg pass has got, while the job is composing
+	CompositionProgress *CompositionProgress `dynamodbav:"composition_progress,omitempty" json:"composition_progress,omitempty"`
+
+	// Estimated seconds left in the job's run as of ETAUpdatedAt (Unix timestamp), estimated as
+	// each step starts and finishes. Only meaningful while the job is processing.
+	ETASeconds   int   `dynamodbav:"eta_seconds,omitempty" json:"eta_seconds,omitempty"`
+	ETAUpdatedAt int64 `dynamodbav:"eta_updated_at,omitempty" json:"eta_updated_at,omitempty"`
+
+	// Replicate models the job submits to instead of the configured ones, keyed by adapter
+	// (veo, kling, gpt4o, minimax). Set from X-Model-Override when the server allows it.
+	ModelOverrides map[string]string `dynamodbav:"model_overrides,omitempty" json:"model_overrides,omitempty"`
+
+	// A/B variants. A parent job writes Variants scripts in one GPT-4o call and generates each as
+	// a child job listed in VariantJobIDs; children link back through ParentJobID and are numbered
+	// from 1 by VariantIndex. The parent's status follows its children once they have started.
+	Variants      int      `dynamodbav:"variants,omitempty" json:"variants,omitempty"`
+	VariantJobIDs []string `dynamodbav:"variant_job_ids,omitempty" json:"variant_job_ids,omitempty"`
+	ParentJobID   string   `dynamodbav:"parent_job_id,omitempty" json:"parent_job_id,omitempty"`
+	VariantIndex  int      `dynamodbav:"variant_index,omitempty" json:"variant_index,omitempty"`
+
+	// The job this one was duplicated from with POST /jobs/:id/duplicate
+	DuplicatedFrom string `dynamodbav:"duplicated_from,omitempty" json:"duplicated_from,omitempty"`
+
+	// When the retention policy expires the job: the retention sweep deletes its assets and
+	// record, and TTL (set a little later) removes any record the sweep missed. 0 keeps it forever.
+	ExpiresAt int64 `dynamodbav:"expires_at,omitempty" json:"expires_at,omitempty"`
+
+	// Byte size of every artifact uploaded for the job, by S3 key. The user's storage total counts
+	// exactly these sizes. Nil means they were never recorded (jobs from before storage accounting).
+	Assets map[string]int64 `dynamodbav:"assets,omitempty" json:"assets,omitempty"`
+
+	// Incremented on every write; UpdateJob only succeeds if the stored version still matches
+	Version int64 `dynamodbav:"version" json:"version"`
+}
+
+// IsVariantParent reports whether the job coordinates A/B variant child jobs
+func (j *Job) IsVariantParent() bool {
+	return len(j.VariantJobIDs) > 0
+}
+
+// ProvenanceEntry records one provider call made for a job, so a result can be traced back to
+// the prediction that produced it
+type ProvenanceEntry struct {
+	Step         string `dynamodbav:"step" json:"step"`                                       // Pipeline step, e.g. "script", "scene_2", "music"
+	Provider     string `dynamodbav:"provider" json:"provider"`                               // "replicate", "openai" or "elevenlabs"
+	Model        string `dynamodbav:"model,omitempty" json:"model,omitempty"`                 // Model and pinned version, e.g. "google/veo-3.1:a552..."
+	PredictionID string `dynamodbav:"prediction_id,omitempty" json:"prediction_id,omitempty"` // Empty for synchronous APIs
+	SubmittedAt  int64  `dynamodbav:"submitted_at" json:"submitted_at"`                       // Unix timestamp
+	CompletedAt  int64  `dynamodbav:"completed_at" json:"completed_at"`                       // Unix timestamp
+	Status       string `dynamodbav:"status" json:"status"`                                   // succeeded, failed, canceled, timeout or abandoned
+	Error        string `dynamodbav:"error,omitempty" json:"error,omitempty"`                 // Raw provider error, truncated
+	Seed         *int64 `dynamodbav:"seed,omitempty" json:"seed,omitempty"`                   // Seed a video clip was generated with
+}
+
+// Pipeline steps that provenance entries and step stats are recorded under. Scene clips are
+// SceneStep(n).
+const (
+	StepScript      = "script"
+	StepNarrator    = "narrator"
+	StepMusic       = "music"
+	StepComposition = "composition"
+)
+
+// SceneStep names the step that generates scene n's clip, e.g. "scene_2"
+func SceneStep(n int) string {
+	return fmt.Sprintf("scene_%d", n)
+}
+
+// SceneStepNumber returns n for a SceneStep(n) step, and false for any other step
+func SceneStepNumber(step string) (int, bool) {
+	digits, ok := strings.CutPrefix(step, "scene_")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	return n, err == nil && n > 0
+}
+
+// StepStats is the time and credits one finished pipeline step took
+type StepStats struct {
+	LatencySeconds float64 `dynamodbav:"latency_seconds" json:"latency_seconds"`     // Submission to terminal status over the step's provider calls; wall-clock time for composition
+	Polls          int     `dynamodbav:"polls,omitempty" json:"polls,omitempty"`     // Prediction status checks
+	Retries        int     `dynamodbav:"retries,omitempty" json:"retries,omitempty"` // Provider calls beyond the first
+	Credits        int     `dynamodbav:"credits" json:"credits"`
+
+	// LLM tokens the step's calls consumed, as the providers reported them
+	PromptTokens     int `dynamodbav:"prompt_tokens,omitempty" json:"prompt_tokens,omitempty"`
+	CompletionTokens int `dynamodbav:"completion_tokens,omitempty" json:"completion_tokens,omitempty"`
+}
+
+// StageTiming is the rolling average of how long one kind of pipeline step has taken, for
+// estimating how long jobs have left
+type StageTiming struct {
+	Seconds float64 `dynamodbav:"seconds" json:"seconds"`
+	Samples int     `dynamodbav:"samples" json:"samples"` // Runs averaged, counting those that have rolled out of the average
+}
+
+// CompositionProgress is one report of a composition pass
+type CompositionProgress struct {
+	Pass    string `dynamodbav:"pass" json:"pass"`       // ffmpeg operation, e.g. "concat", "text_overlay" or "webm"
+	Percent int    `dynamodbav:"percent" json:"percent"` // Of the video the pass has written
+}
+
+// ComplianceIssue is one pharmaceutical compliance rule a generated script broke
+type ComplianceIssue struct {
+	Rule        string `dynamodbav:"rule" json:"rule"`                                     // e.g. "banned_claim", "required_phrase"
+	SceneNumber int    `dynamodbav:"scene_number,omitempty" json:"scene_number,omitempty"` // 0 when the issue isn't about one scene
+	Message     string `dynamodbav:"message" json:"message"`
+}
+
+// ModerationFlag is a prompt that content moderation expects a provider's safety filters to reject
+type ModerationFlag struct {
+	SceneNumber int    `dynamodbav:"scene_number,omitempty" json:"scene_number,omitempty"` // 0 for the user's prompt
+	Category    string `dynamodbav:"category" json:"category"`                             // e.g. "medication_brand", "self-harm"
+	Source      string `dynamodbav:"source" json:"source"`                                 // "denylist" or "openai"
+	Term        string `dynamodbav:"term,omitempty" json:"term,omitempty"`                 // Denylisted term the prompt contains
+}
+
+// SceneVoiceover is a generated voiceover clip for one scene and where it plays in the final video
+type SceneVoiceover struct {
+	SceneNumber int     `dynamodbav:"scene_number" json:"scene_number"`
+	URL         string  `dynamodbav:"url" json:"url"`               // S3 URL (presigned when served)
+	StartTime   float64 `dynamodbav:"start_time" json:"start_time"` // Seconds from the start of the video
+	Duration    float64 `dynamodbav:"duration" json:"duration"`     // Clip length in seconds
+}
+
+// ClipTrim is how a trimmed clip version was cut from the untrimmed clip
+type ClipTrim struct {
+	SourceURL        string  `dynamodbav:"source_url" json:"source_url"`               // Untrimmed clip; later trims cut from it too
+	TrimStart        float64 `dynamodbav:"trim_start" json:"trim_start"`               // Seconds cut from the start
+	TrimEnd          float64 `dynamodbav:"trim_end" json:"trim_end"`                   // Seconds cut from the end
+	OriginalDuration float64 `dynamodbav:"original_duration" json:"original_duration"` // Probed length of the untrimmed clip
+	TrimmedDuration  float64 `dynamodbav:"trimmed_duration" json:"trimmed_duration"`   // Probed length of the trimmed clip
+}
+
+// Rendition is the final video re-encoded for one platform's aspect ratio and limits
+type Rendition struct {
+	Target         string  `dynamodbav:"target" json:"target"`                             // e.g. "tiktok_9x16"
+	Status         string  `dynamodbav:"status" json:"status"`                             // processing, completed or failed
+	SourceVideoKey string  `dynamodbav:"source_video_key" json:"source_video_key"`         // Final video it was made from
+	Key            string  `dynamodbav:"key,omitempty" json:"key,omitempty"`               // S3 key, once completed
+	Fit            string  `dynamodbav:"fit" json:"fit"`                                   // crop or pad
+	FocalBias      float64 `dynamodbav:"focal_bias,omitempty" json:"focal_bias,omitempty"` // Crop offset, -1 (left/top) to 1 (right/bottom)
+	Width          int     `dynamodbav:"width,omitempty" json:"width,omitempty"`
+	Height         int     `dynamodbav:"height,omitempty" json:"height,omitempty"`
+	Duration       float64 `dynamodbav:"duration,omitempty" json:"duration,omitempty"` // Seconds
+	Trimmed        bool    `dynamodbav:"trimmed,omitempty" json:"trimmed,omitempty"`   // Cut to the platform's maximum length
+	SizeBytes      int64   `dynamodbav:"size_bytes,omitempty" json:"size_bytes,omitempty"`
+	Error          string  `dynamodbav:"error,omitempty" json:"error,omitempty"`
+	CreatedAt      int64   `dynamodbav:"created_at" json:"created_at"`
+	CompletedAt    int64   `dynamodbav:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// Rendition statuses
+const (
+	RenditionProcessing = "processing"
+	RenditionCompleted  = "completed"
+	RenditionFailed     = "failed"
+)
+
+// Rendition fits, for a video whose aspect ratio differs from the target's
+const (
+	RenditionFitCrop = "crop" // Fill the frame, cutting the overflow
+	RenditionFitPad  = "pad"  // Fit inside the frame, with black bars
+)
+
+// SceneOrderSnapshot is a job's scenes before a reorder, with everything the reorder retimed
+type SceneOrderSnapshot struct {
+	Scenes               []Scene              `dynamodbav:"scenes" json:"scenes"`
+	SceneVideoURLs       []string             `dynamodbav:"scene_video_urls" json:"scene_video_urls"`
+	SceneVersions        map[int]int          `dynamodbav:"scene_versions,omitempty" json:"scene_versions,omitempty"`
+	PromptVersions       map[string]string    `dynamodbav:"prompt_versions,omitempty" json:"prompt_versions,omitempty"`
+	ClipVersions         map[string]string    `dynamodbav:"clip_versions,omitempty" json:"clip_versions,omitempty"`
+	ClipTrims            map[string]ClipTrim  `dynamodbav:"clip_trims,omitempty" json:"clip_trims,omitempty"`
+	SceneVoiceovers      []SceneVoiceover     `dynamodbav:"scene_voiceovers,omitempty" json:"scene_voiceovers,omitempty"`
+	Duration             int                  `dynamodbav:"duration,omitempty" json:"duration,omitempty"`
+	SideEffectsStartTime float64              `dynamodbav:"side_effects_start_time,omitempty" json:"side_effects_start_time,omitempty"`
+	AudioURL             string               `dynamodbav:"audio_url,omitempty" json:"audio_url,omitempty"` // Music fitted to this ordering's length
+	MusicFit             string               `dynamodbav:"music_fit,omitempty" json:"music_fit,omitempty"`
+	MusicLoopCount       int                  `dynamodbav:"music_loop_count,omitempty" json:"music_loop_count,omitempty"`
+	MusicSyncOffset      float64              `dynamodbav:"music_sync_offset,omitempty" json:"music_sync_offset,omitempty"`
+	MusicLoudness        *LoudnessMeasurement `dynamodbav:"music_loudness,omitempty" json:"music_loudness,omitempty"`
+	ReplacedAt           int64                `dynamodbav:"replaced_at" json:"replaced_at"` // When the reorder replaced it
+}
+
+// SFXClip is a generated sound effect and when it plays in the final video
+type SFXClip struct {
+	Timestamp   float64 `dynamodbav:"timestamp" json:"timestamp"` // Seconds from the start of the video
+	Description string  `dynamodbav:"description" json:"description"`
+	URL         string  `dynamodbav:"url" json:"url"` // S3 URL (presigned when served)
+}
+
+// ThumbnailRendition is one size and format of the job thumbnail
+type ThumbnailRendition struct {
+	Width  int    `dynamodbav:"width" json:"width"`
+	Format string `dynamodbav:"format" json:"format"` // "jpg" or "webp"
+	Key    string `dynamodbav:"key" json:"key"`       // S3 key (presigned when served)
+}
+
+// CaptionCue is one caption of the narrator script and when it is on screen
+type CaptionCue struct {
+	Start float64 `dynamodbav:"start" json:"start"` // Seconds from the start of the video
+	End   float64 `dynamodbav:"end" json:"end"`
+	Text  string  `dynamodbav:"text" json:"text"` // Up to two lines separated by "\n"
+}
+
+// LogoOverlay places a brand logo over the final video
+type LogoOverlay struct {
+	// S3 key (or asset URL) of an uploaded image; filled in from the brand guidelines with UseGuidelineLogo
+	Asset            string  `dynamodbav:"asset,omitempty" json:"asset,omitempty"`
+	UseGuidelineLogo bool    `dynamodbav:"use_guideline_logo,omitempty" json:"use_guideline_logo,omitempty"` // Use the first logo of the applied brand guidelines
+	Position         string  `dynamodbav:"position,omitempty" json:"position,omitempty"`                     // One of the LogoPosition constants (default bottom_right)
+	Size             float64 `dynamodbav:"size,omitempty" json:"size,omitempty"`                             // Logo width as a percent of the video width (default 12)
+	Opacity          float64 `dynamodbav:"opacity,omitempty" json:"opacity,omitempty"`                       // 0-1 (0 or omitted = fully opaque)
+	Start            float64 `dynamodbav:"start,omitempty" json:"start,omitempty"`                           // Seconds; shown from the start by default
+	End              float64 `dynamodbav:"end,omitempty" json:"end,omitempty"`                               // Seconds; 0 = until the end of the video
+}
+
+// ProductImage is an uploaded product photo offered to the script writer as a scene start image
+type ProductImage struct {
+	Asset string `dynamodbav:"asset" json:"asset"`                   // S3 key (or asset URL) of the user's upload
+	Hint  string `dynamodbav:"hint,omitempty" json:"hint,omitempty"` // What the photo shows or where it fits, e.g. "bottle on a kitchen counter"
+	Ref   string `dynamodbav:"ref,omitempty" json:"ref,omitempty"`   // Assigned server-side ("image_1", ...); scenes reference it
+}
+
+// PlannedScene is one scene of a user's scene plan: how long it runs, and optionally what it
+// should show
+type PlannedScene struct {
+	Duration int    `dynamodbav:"duration" json:"duration"`             // Seconds; one of the model's clip lengths
+	Hint     string `dynamodbav:"hint,omitempty" json:"hint,omitempty"` // Location or focus, e.g. "sunlit kitchen, close on the bottle"
+}
+
+// EndCard is a static closing card showing the call to action, rendered after the last clip.
+// The CTA text comes from the script's call_to_action.
+type EndCard struct {
+	Duration        float64 `dynamodbav:"duration,omitempty" json:"duration,omitempty"`                   // Seconds (2-4, default 3)
+	BackgroundColor string  `dynamodbav:"background_color,omitempty" json:"background_color,omitempty"`   // Hex color such as "#1A2B3C" (default black)
+	UseProductImage bool    `dynamodbav:"use_product_image,omitempty" json:"use_product_image,omitempty"` // Use start_image as the background
+	ShowLogo        bool    `dynamodbav:"show_logo,omitempty" json:"show_logo,omitempty"`                 // Show the logo_overlay asset above the CTA
+	LegalText       string  `dynamodbav:"legal_text,omitempty" json:"legal_text,omitempty"`               // Small print below the CTA
+}
+
+// OutputSpec sets how the final video is encoded, e.g. smaller for a platform's upload limit or
+// higher quality for broadcast. Zero fields keep the defaults.
+type OutputSpec struct {
+	Resolution     string `dynamodbav:"resolution,omitempty" json:"resolution,omitempty"`             // One of the OutputResolution constants: the short side in pixels
+	Codec          string `dynamodbav:"codec,omitempty" json:"codec,omitempty"`                       // h264 (default) or h265
+	CRF            int    `dynamodbav:"crf,omitempty" json:"crf,omitempty"`                           // Quality, lower is better (default 21 for h264, 24 for h265)
+	MaxBitrateKbps int    `dynamodbav:"max_bitrate_kbps,omitempty" json:"max_bitrate_kbps,omitempty"` // Caps the video bitrate
+	Container      string `dynamodbav:"container,omitempty" json:"container,omitempty"`               // mp4 (default) or mov
+}
+
+// OutputEncoding records how the final video was encoded
+type OutputEncoding struct {
+	Codec          string `dynamodbav:"codec" json:"codec"`
+	Container      string `dynamodbav:"container" json:"container"`
+	Width          int    `dynamodbav:"width,omitempty" json:"width,omitempty"`
+	Height         int    `dynamodbav:"height,omitempty" json:"height,omitempty"`
+	PixelFormat    string `dynamodbav:"pixel_format,omitempty" json:"pixel_format,omitempty"`
+	CRF            int    `dynamodbav:"crf,omitempty" json:"crf,omitempty"` // 0 when the clips weren't re-encoded for an output spec
+	MaxBitrateKbps int    `dynamodbav:"max_bitrate_kbps,omitempty" json:"max_bitrate_kbps,omitempty"`
+	SizeBytes      int64  `dynamodbav:"size_bytes" json:"size_bytes"`
+}
+
+// LoudnessMeasurement records a two-pass loudnorm run on an audio asset
+type LoudnessMeasurement struct {
+	TargetLUFS     float64 `dynamodbav:"target_lufs" json:"target_lufs"`
+	InputLUFS      float64 `dynamodbav:"input_lufs" json:"input_lufs"`
+	InputTruePeak  float64 `dynamodbav:"input_true_peak" json:"input_true_peak"` // dBTP
+	OutputLUFS     float64 `dynamodbav:"output_lufs" json:"output_lufs"`
+	OutputTruePeak float64 `dynamodbav:"output_true_peak" json:"output_true_peak"` // dBTP
 }
 
 // GenerateRequest represents a video generation request
@@ -88,10 +505,53 @@ type ParsedPrompt struct {
 
 // JobStatus constants
 const (
-	StatusPending    = "pending"
+	StatusPending = "pending"
+	// StatusQueued marks a job waiting for one of the user's active jobs to finish
+	StatusQueued     = "queued"
 	StatusProcessing = "processing"
-	StatusCompleted  = "completed"
-	StatusFailed     = "failed"
+	// StatusScriptReady marks a preview job whose script awaits user approval
+	StatusScriptReady = "script_ready"
+	StatusCompleted   = "completed"
+	StatusFailed      = "failed"
+	// StatusCanceled marks a job the user stopped before it finished
+	StatusCanceled = "canceled"
+)
+
+// Side effects overflow modes, for disclosures that don't fit on screen at a readable size
+const (
+	SideEffectsPaginate = "paginate" // Sequential pages across the overlay window
+	SideEffectsScroll   = "scroll"   // Vertical crawl
+)
+
+// Continuity modes, for how a scene follows on from the one before it
+const (
+	ContinuityFrame = "frame" // Starts on the previous clip's last frame
+	ContinuityStyle = "style" // Starts fresh, prompted with scene 1's visual style
+	ContinuityNone  = "none"  // Starts fresh
+)
+
+// Logo overlay positions
+const (
+	LogoTopLeft      = "top_left"
+	LogoTopRight     = "top_right"
+	LogoBottomLeft   = "bottom_left"
+	LogoBottomRight  = "bottom_right"
+	LogoBottomCenter = "bottom_center"
+)
+
+// Output resolutions, by the video's short side
+const (
+	OutputResolution720p  = "720p"
+	OutputResolution1080p = "1080p"
+	OutputResolution2160p = "2160p"
+)
+
+// Output codecs and containers
+const (
+	OutputCodecH264    = "h264"
+	OutputCodecH265    = "h265"
+	OutputContainerMP4 = "mp4"
+	OutputContainerMOV = "mov"
 )
 
 // AspectRatio constants
@@ -0,0 +1,33 @@
+package domain
+
+// Job event types
+const (
+	JobEventStage     = "stage"     // The pipeline moved to a new stage
+	JobEventCall      = "call"      // A provider call finished
+	JobEventRetry     = "retry"     // The job was rerun after it failed
+	JobEventWarning   = "warning"   // Something failed that the job carried on without
+	JobEventCompleted = "completed" // Final outcomes
+	JobEventFailed    = "failed"
+	JobEventCanceled  = "canceled"
+)
+
+// JobEvent is one entry in a job's audit trail: what the pipeline did and when, so a slow or
+// failed job can be explained without searching the logs. Events are only ever appended.
+type JobEvent struct {
+	JobID      string `json:"job_id" dynamodbav:"job_id"`
+	Sequence   int64  `json:"sequence" dynamodbav:"sequence"` // Order within the job, from 1
+	Time       int64  `json:"time" dynamodbav:"time"`         // Unix milliseconds
+	Type       string `json:"type" dynamodbav:"type"`
+	Stage      string `json:"stage,omitempty" dynamodbav:"stage,omitempty"` // Job stage, or the provider call's pipeline step
+	Message    string `json:"message,omitempty" dynamodbav:"message,omitempty"`
+	Provider   string `json:"provider,omitempty" dynamodbav:"provider,omitempty"`       // Calls only
+	Model      string `json:"model,omitempty" dynamodbav:"model,omitempty"`             // Calls only
+	Status     string `json:"status,omitempty" dynamodbav:"status,omitempty"`           // Calls only: succeeded, failed, canceled, timeout or abandoned
+	DurationMs int64  `json:"duration_ms,omitempty" dynamodbav:"duration_ms,omitempty"` // How long the call or stage took
+	TTL        int64  `json:"-" dynamodbav:"ttl,omitempty"`                             // Unix timestamp for auto-deletion
+}
+
+// IsOutcome reports whether the event ends a run of the job
+func (e JobEvent) IsOutcome() bool {
+	return e.Type == JobEventCompleted || e.Type == JobEventFailed || e.Type == JobEventCanceled
+}
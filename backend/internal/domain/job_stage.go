@@ -0,0 +1,299 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// StagePhase is what a job is doing, without the scene or variant counts a stage may carry
+type StagePhase string
+
+// Job stage phases. The string values are the stored stage names, apart from the counted
+// phases, whose stored names embed their counts (scene_2_generating, variants_1_of_3_finished).
+const (
+	PhaseQueued             StagePhase = "queued"   // Waiting behind the owner's active jobs
+	PhaseRequeued           StagePhase = "requeued" // A failed job rerun, about to start
+	PhaseScriptGenerating   StagePhase = "script_generating"
+	PhaseScriptReady        StagePhase = "script_ready" // A previewed script waiting for approval
+	PhaseScriptComplete     StagePhase = "script_complete"
+	PhaseAwaitingScript     StagePhase = "awaiting_script" // A variant waiting for its parent to write its script
+	PhaseVariantsGenerating StagePhase = "variants_generating"
+	PhaseVariantsFinished   StagePhase = "variants_finished" // Some of a parent's variants have finished
+	PhaseSceneGenerating    StagePhase = "scene_generating"
+	PhaseSceneComplete      StagePhase = "scene_complete"
+	PhaseNarratorGenerating StagePhase = "narrator_generating"
+	PhaseNarratorComplete   StagePhase = "narrator_complete"
+	PhaseAudioGenerating    StagePhase = "audio_generating"
+	PhaseAudioComplete      StagePhase = "audio_complete"
+	PhaseComposing          StagePhase = "composing"
+	PhaseComplete           StagePhase = "complete"
+	PhaseFailed             StagePhase = "failed"
+	PhaseCanceled           StagePhase = "canceled"
+)
+
+// JobStage is a job's granular progress through the pipeline. Scene stages carry the scene
+// they are on, and a variant parent's stage how many of its variants have finished. The zero
+// value is a job that hasn't started a stage yet.
+//
+// Stages are stored and sent as their legacy names, e.g. scene_2_generating, so records
+// written before the type existed read back unchanged.
+type JobStage struct {
+	Phase StagePhase
+	Scene int // Scene phases only, from 1
+
+	// PhaseVariantsFinished only
+	Finished int
+	Total    int
+}
+
+// Stages without counts
+var (
+	StageQueued             = JobStage{Phase: PhaseQueued}
+	StageRequeued           = JobStage{Phase: PhaseRequeued}
+	StageScriptGenerating   = JobStage{Phase: PhaseScriptGenerating}
+	StageScriptReady        = JobStage{Phase: PhaseScriptReady}
+	StageScriptComplete     = JobStage{Phase: PhaseScriptComplete}
+	StageAwaitingScript     = JobStage{Phase: PhaseAwaitingScript}
+	StageVariantsGenerating = JobStage{Phase: PhaseVariantsGenerating}
+	StageNarratorGenerating = JobStage{Phase: PhaseNarratorGenerating}
+	StageNarratorComplete   = JobStage{Phase: PhaseNarratorComplete}
+	StageAudioGenerating    = JobStage{Phase: PhaseAudioGenerating}
+	StageAudioComplete      = JobStage{Phase: PhaseAudioComplete}
+	StageComposing          = JobStage{Phase: PhaseComposing}
+	StageComplete           = JobStage{Phase: PhaseComplete}
+	StageFailed             = JobStage{Phase: PhaseFailed}
+	StageCanceled           = JobStage{Phase: PhaseCanceled}
+)
+
+// SceneGenerating is the stage of a job generating scene (from 1)
+func SceneGenerating(scene int) JobStage {
+	return JobStage{Phase: PhaseSceneGenerating, Scene: scene}
+}
+
+// SceneComplete is the stage of a job that just finished scene (from 1)
+func SceneComplete(scene int) JobStage {
+	return JobStage{Phase: PhaseSceneComplete, Scene: scene}
+}
+
+// VariantsFinished is the stage of a parent with finished of its total variants done. None
+// finished is PhaseVariantsGenerating.
+func VariantsFinished(finished, total int) JobStage {
+	if finished <= 0 {
+		return StageVariantsGenerating
+	}
+	return JobStage{Phase: PhaseVariantsFinished, Finished: finished, Total: total}
+}
+
+// IsZero reports whether the job hasn't started a stage
+func (s JobStage) IsZero() bool {
+	return s == JobStage{}
+}
+
+// IsScene reports whether the stage is working on a scene
+func (s JobStage) IsScene() bool {
+	return s.Phase == PhaseSceneGenerating || s.Phase == PhaseSceneComplete
+}
+
+// IsVariants reports whether the stage says a parent's variants are generating
+func (s JobStage) IsVariants() bool {
+	return s.Phase == PhaseVariantsGenerating || s.Phase == PhaseVariantsFinished
+}
+
+// String is the stage's stored name
+func (s JobStage) String() string {
+	// Without counts a stage can only be an unknown name read back as it was stored
+	switch {
+	case s.Phase == PhaseSceneGenerating && s.Scene > 0:
+		return fmt.Sprintf("scene_%d_generating", s.Scene)
+	case s.Phase == PhaseSceneComplete && s.Scene > 0:
+		return fmt.Sprintf("scene_%d_complete", s.Scene)
+	case s.Phase == PhaseVariantsFinished && s.Finished > 0:
+		return fmt.Sprintf("variants_%d_of_%d_finished", s.Finished, s.Total)
+	default:
+		return string(s.Phase)
+	}
+}
+
+// ErrUnknownStage is returned when parsing a stage name no pipeline writes
+var ErrUnknownStage = errors.New("unknown job stage")
+
+// ParseJobStage reads a stored stage name. Names it doesn't know fail with ErrUnknownStage,
+// and are returned as a stage with that phase so they still read back as they were stored.
+func ParseJobStage(name string) (JobStage, error) {
+	switch phase := StagePhase(name); phase {
+	case "":
+		return JobStage{}, nil
+	case PhaseQueued, PhaseRequeued, PhaseScriptGenerating, PhaseScriptReady, PhaseScriptComplete,
+		PhaseAwaitingScript, PhaseVariantsGenerating, PhaseNarratorGenerating, PhaseNarratorComplete,
+		PhaseAudioGenerating, PhaseAudioComplete, PhaseComposing, PhaseComplete, PhaseFailed, PhaseCanceled:
+		return JobStage{Phase: phase}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(name, "scene_"); ok {
+		number, suffix, _ := strings.Cut(rest, "_")
+		scene, err := strconv.Atoi(number)
+		if err == nil && scene > 0 && number == strconv.Itoa(scene) {
+			switch suffix {
+			case "generating":
+				return SceneGenerating(scene), nil
+			case "complete":
+				return SceneComplete(scene), nil
+			}
+		}
+	}
+	if rest, ok := strings.CutPrefix(name, "variants_"); ok {
+		if counts, ok := strings.CutSuffix(rest, "_finished"); ok {
+			finishedText, totalText, _ := strings.Cut(counts, "_of_")
+			finished, errFinished := strconv.Atoi(finishedText)
+			total, errTotal := strconv.Atoi(totalText)
+			if errFinished == nil && errTotal == nil && finished > 0 && finished <= total {
+				return VariantsFinished(finished, total), nil
+			}
+		}
+	}
+	return JobStage{Phase: StagePhase(name)}, fmt.Errorf("%w: %q", ErrUnknownStage, name)
+}
+
+// MarshalText stores the stage as its name, for JSON
+func (s JobStage) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a stage name, keeping names it doesn't know as they were
+func (s *JobStage) UnmarshalText(text []byte) error {
+	*s, _ = ParseJobStage(string(text))
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue stores the stage as its name; no stage is an empty one
+func (s JobStage) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberS{Value: s.String()}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue reads a stored stage name, keeping names it doesn't know as
+// they were
+func (s *JobStage) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		*s, _ = ParseJobStage(v.Value)
+		return nil
+	case *types.AttributeValueMemberNULL:
+		*s = JobStage{}
+		return nil
+	default:
+		return fmt.Errorf("job stage must be a string, got %T", av)
+	}
+}
+
+// ErrIllegalTransition is returned when a job would move between stages the pipeline never
+// moves between, e.g. from composing back to script_generating
+var ErrIllegalTransition = errors.New("illegal job stage transition")
+
+// StageTransition checks that a job may move from one stage to the next. Staying at a stage is
+// always allowed, since a stage may be saved more than once.
+//
+// The pipeline writes the script, generates the scenes in order, then generates the music and
+// narration together, so the audio stages may come in either order, and composes the video.
+// A job about to start may be queued first. Jobs re-enter the pipeline at script_complete
+// when a stored script is approved or a failed job is rerun from requeued. A variant parent's
+// stage follows its variants, reopening from any finished stage when one of them is rerun.
+// Any unfinished stage may fail or be canceled.
+func StageTransition(from, to JobStage) error {
+	if from == to || legalTransition(from, to) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, describeStage(from), describeStage(to))
+}
+
+// describeStage names a stage in errors
+func describeStage(s JobStage) string {
+	if s.IsZero() {
+		return "no stage"
+	}
+	return s.String()
+}
+
+// terminalPhases are the stages a job ends at
+var terminalPhases = map[StagePhase]bool{PhaseComplete: true, PhaseFailed: true, PhaseCanceled: true}
+
+// stageSuccessors lists the stages each stage may move to, other than failing or being
+// canceled. Where the next stage depends on counts, legalTransition checks it instead.
+var stageSuccessors = map[StagePhase][]StagePhase{
+	"":                      {PhaseQueued, PhaseScriptGenerating, PhaseScriptComplete, PhaseAwaitingScript},
+	PhaseQueued:             {PhaseScriptGenerating, PhaseScriptComplete},
+	PhaseRequeued:           {PhaseQueued, PhaseScriptGenerating, PhaseScriptComplete},
+	PhaseScriptGenerating:   {PhaseQueued, PhaseScriptReady, PhaseScriptComplete, PhaseVariantsGenerating},
+	PhaseScriptReady:        {PhaseScriptComplete},
+	PhaseScriptComplete:     {PhaseQueued},
+	PhaseAwaitingScript:     {PhaseScriptComplete},
+	PhaseVariantsGenerating: nil,
+	PhaseNarratorGenerating: {PhaseNarratorComplete, PhaseAudioGenerating, PhaseAudioComplete},
+	PhaseNarratorComplete:   {PhaseAudioGenerating, PhaseAudioComplete},
+	PhaseAudioGenerating:    {PhaseNarratorGenerating, PhaseNarratorComplete, PhaseAudioComplete},
+	PhaseAudioComplete:      {PhaseComposing},
+	PhaseComposing:          {PhaseComplete},
+	PhaseComplete:           {PhaseVariantsGenerating},
+	PhaseFailed:             {PhaseRequeued, PhaseVariantsGenerating},
+	PhaseCanceled:           {PhaseVariantsGenerating},
+}
+
+// legalTransition reports whether the graph has an edge from one stage to a different one
+func legalTransition(from, to JobStage) bool {
+	if !knownStage(from) || !knownStage(to) {
+		return false
+	}
+	if to.Phase == PhaseFailed || to.Phase == PhaseCanceled {
+		return !terminalPhases[from.Phase]
+	}
+
+	switch from.Phase {
+	case PhaseScriptComplete:
+		if to == SceneGenerating(1) {
+			return true
+		}
+	case PhaseSceneGenerating:
+		return to == SceneComplete(from.Scene)
+	case PhaseSceneComplete:
+		// The audio starts once the last scene is done
+		return to == SceneGenerating(from.Scene+1) ||
+			to.Phase == PhaseAudioGenerating || to.Phase == PhaseNarratorGenerating
+	case PhaseVariantsGenerating:
+		return to.Phase == PhaseVariantsFinished || to.Phase == PhaseComplete
+	case PhaseVariantsFinished:
+		// A rerun variant lowers the count until it finishes again
+		return (to.Phase == PhaseVariantsFinished && to.Total == from.Total) ||
+			to.Phase == PhaseVariantsGenerating || to.Phase == PhaseComplete
+	}
+	for _, next := range stageSuccessors[from.Phase] {
+		if to.Phase == next {
+			return true
+		}
+	}
+	return false
+}
+
+// knownStage reports whether a stage is one the pipeline writes, with counts in range
+func knownStage(s JobStage) bool {
+	switch s.Phase {
+	case PhaseSceneGenerating, PhaseSceneComplete:
+		return s.Scene > 0 && s.Finished == 0 && s.Total == 0
+	case PhaseVariantsFinished:
+		return s.Scene == 0 && s.Finished > 0 && s.Finished <= s.Total
+	}
+	_, ok := stageSuccessors[s.Phase]
+	return ok && s.Scene == 0 && s.Finished == 0 && s.Total == 0
+}
+
+// AdvanceStage moves job to stage, failing with ErrIllegalTransition when its current stage
+// doesn't lead there
+func (j *Job) AdvanceStage(stage JobStage) error {
+	if err := StageTransition(j.Stage, stage); err != nil {
+		return err
+	}
+	j.Stage = stage
+	return nil
+}
@@ -0,0 +1,304 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// everyStage covers each phase, with scene and variant counts at and past the first
+var everyStage = []JobStage{
+	{},
+	StageQueued,
+	StageRequeued,
+	StageScriptGenerating,
+	StageScriptReady,
+	StageScriptComplete,
+	StageAwaitingScript,
+	StageVariantsGenerating,
+	VariantsFinished(1, 3),
+	VariantsFinished(2, 3),
+	VariantsFinished(3, 3),
+	VariantsFinished(1, 2),
+	SceneGenerating(1),
+	SceneComplete(1),
+	SceneGenerating(2),
+	SceneComplete(2),
+	SceneGenerating(3),
+	SceneComplete(3),
+	StageNarratorGenerating,
+	StageNarratorComplete,
+	StageAudioGenerating,
+	StageAudioComplete,
+	StageComposing,
+	StageComplete,
+	StageFailed,
+	StageCanceled,
+}
+
+// legalEdges is the transition graph over everyStage, apart from staying put and from any
+// unfinished stage failing or being canceled
+var legalEdges = map[string][]string{
+	"":                         {"queued", "script_generating", "script_complete", "awaiting_script"},
+	"queued":                   {"script_generating", "script_complete"},
+	"requeued":                 {"queued", "script_generating", "script_complete"},
+	"script_generating":        {"queued", "script_ready", "script_complete", "variants_generating"},
+	"script_ready":             {"script_complete"},
+	"script_complete":          {"queued", "scene_1_generating"},
+	"awaiting_script":          {"script_complete"},
+	"variants_generating":      {"variants_1_of_3_finished", "variants_2_of_3_finished", "variants_3_of_3_finished", "variants_1_of_2_finished", "complete"},
+	"variants_1_of_3_finished": {"variants_generating", "variants_2_of_3_finished", "variants_3_of_3_finished", "complete"},
+	"variants_2_of_3_finished": {"variants_generating", "variants_1_of_3_finished", "variants_3_of_3_finished", "complete"},
+	"variants_3_of_3_finished": {"variants_generating", "variants_1_of_3_finished", "variants_2_of_3_finished", "complete"},
+	"variants_1_of_2_finished": {"variants_generating", "complete"},
+	"scene_1_generating":       {"scene_1_complete"},
+	"scene_1_complete":         {"scene_2_generating", "narrator_generating", "audio_generating"},
+	"scene_2_generating":       {"scene_2_complete"},
+	"scene_2_complete":         {"scene_3_generating", "narrator_generating", "audio_generating"},
+	"scene_3_generating":       {"scene_3_complete"},
+	"scene_3_complete":         {"narrator_generating", "audio_generating"},
+	"narrator_generating":      {"narrator_complete", "audio_generating", "audio_complete"},
+	"narrator_complete":        {"audio_generating", "audio_complete"},
+	"audio_generating":         {"narrator_generating", "narrator_complete", "audio_complete"},
+	"audio_complete":           {"composing"},
+	"composing":                {"complete"},
+	"complete":                 {"variants_generating"},
+	"failed":                   {"requeued", "variants_generating"},
+	"canceled":                 {"variants_generating"},
+}
+
+func TestStageTransition_EveryPair(t *testing.T) {
+	for _, from := range everyStage {
+		finished := from.Phase == PhaseComplete || from.Phase == PhaseFailed || from.Phase == PhaseCanceled
+		legal := map[string]bool{}
+		for _, to := range legalEdges[from.String()] {
+			legal[to] = true
+		}
+		if !finished {
+			legal["failed"], legal["canceled"] = true, true
+		}
+
+		for _, to := range everyStage {
+			want := from == to || legal[to.String()]
+			err := StageTransition(from, to)
+			if want && err != nil {
+				t.Errorf("%q to %q: unexpected error %v", from, to, err)
+			}
+			if !want && !errors.Is(err, ErrIllegalTransition) {
+				t.Errorf("%q to %q: got %v, want ErrIllegalTransition", from, to, err)
+			}
+		}
+	}
+}
+
+func TestStageTransition_ListsOnlyKnownStages(t *testing.T) {
+	known := map[string]bool{}
+	for _, stage := range everyStage {
+		known[stage.String()] = true
+	}
+	for from, edges := range legalEdges {
+		for _, to := range append(edges, from) {
+			if !known[to] {
+				t.Errorf("legalEdges names %q, which everyStage leaves out", to)
+			}
+		}
+	}
+}
+
+func TestStageTransition_RejectsMalformedStages(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to JobStage
+	}{
+		{name: "back to the script after composing", from: StageComposing, to: StageScriptGenerating},
+		{name: "unknown stage", from: StageScriptComplete, to: JobStage{Phase: "scripting"}},
+		{name: "from an unknown stage", from: JobStage{Phase: "scripting"}, to: StageScriptComplete},
+		{name: "scene without a number", from: StageScriptComplete, to: JobStage{Phase: PhaseSceneGenerating}},
+		{name: "skipped scene", from: SceneComplete(1), to: SceneGenerating(3)},
+		{name: "scene started twice", from: SceneComplete(2), to: SceneGenerating(2)},
+		{name: "other scene completed", from: SceneGenerating(2), to: SceneComplete(3)},
+		{name: "fixed stage with a scene", from: StageAudioComplete, to: JobStage{Phase: PhaseComposing, Scene: 1}},
+		{name: "more variants finished than there are", from: StageVariantsGenerating, to: JobStage{Phase: PhaseVariantsFinished, Finished: 4, Total: 3}},
+		{name: "variant total changed", from: VariantsFinished(1, 3), to: VariantsFinished(2, 4)},
+		{name: "finished job rerun without failing", from: StageComplete, to: StageRequeued},
+		{name: "finished job failed", from: StageComplete, to: StageFailed},
+		{name: "canceled job restarted", from: StageCanceled, to: StageScriptComplete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := StageTransition(tt.from, tt.to); !errors.Is(err, ErrIllegalTransition) {
+				t.Errorf("got %v, want ErrIllegalTransition", err)
+			}
+		})
+	}
+}
+
+func TestAdvanceStage(t *testing.T) {
+	job := &Job{Stage: SceneComplete(2)}
+	if err := job.AdvanceStage(SceneGenerating(3)); err != nil {
+		t.Fatalf("AdvanceStage: %v", err)
+	}
+	if job.Stage != SceneGenerating(3) {
+		t.Fatalf("stage = %q, want scene_3_generating", job.Stage)
+	}
+
+	err := job.AdvanceStage(StageComposing)
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("got %v, want ErrIllegalTransition", err)
+	}
+	if job.Stage != SceneGenerating(3) {
+		t.Errorf("an illegal transition moved the job to %q", job.Stage)
+	}
+}
+
+func TestParseJobStage_ReadsEveryStoredName(t *testing.T) {
+	for _, stage := range everyStage {
+		got, err := ParseJobStage(stage.String())
+		if err != nil {
+			t.Errorf("ParseJobStage(%q): %v", stage, err)
+		}
+		if got != stage {
+			t.Errorf("ParseJobStage(%q) = %#v, want %#v", stage, got, stage)
+		}
+	}
+}
+
+func TestParseJobStage_LegacyNames(t *testing.T) {
+	tests := []struct {
+		name string
+		want JobStage
+	}{
+		{name: "scene_1_generating", want: JobStage{Phase: PhaseSceneGenerating, Scene: 1}},
+		{name: "scene_12_complete", want: JobStage{Phase: PhaseSceneComplete, Scene: 12}},
+		{name: "variants_generating", want: JobStage{Phase: PhaseVariantsGenerating}},
+		{name: "variants_2_of_5_finished", want: JobStage{Phase: PhaseVariantsFinished, Finished: 2, Total: 5}},
+		{name: "narrator_complete", want: JobStage{Phase: PhaseNarratorComplete}},
+		{name: "", want: JobStage{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseJobStage(tt.name)
+		if err != nil {
+			t.Errorf("ParseJobStage(%q): %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseJobStage(%q) = %#v, want %#v", tt.name, got, tt.want)
+		}
+		if got.String() != tt.name {
+			t.Errorf("ParseJobStage(%q).String() = %q", tt.name, got.String())
+		}
+	}
+}
+
+func TestParseJobStage_UnknownNames(t *testing.T) {
+	for _, name := range []string{
+		"scripting",
+		"scene_0_generating",
+		"scene_-1_generating",
+		"scene_02_complete",
+		"scene_x_generating",
+		"scene_2_regenerating",
+		"scene__complete",
+		"scene_generating", // A phase, not a stored name
+		"variants_finished",
+		"variants_0_of_3_finished",
+		"variants_4_of_3_finished",
+		"variants_1_of_x_finished",
+		"variants_1_of_3",
+		"Script_Complete",
+	} {
+		got, err := ParseJobStage(name)
+		if !errors.Is(err, ErrUnknownStage) {
+			t.Errorf("ParseJobStage(%q): got %v, want ErrUnknownStage", name, err)
+		}
+		// Kept as stored so a record isn't rewritten with a different stage
+		if got.String() != name {
+			t.Errorf("ParseJobStage(%q).String() = %q", name, got.String())
+		}
+	}
+}
+
+func TestJobStage_JSON(t *testing.T) {
+	data, err := json.Marshal(Job{JobID: "job-1", Stage: SceneGenerating(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["stage"] != "scene_2_generating" {
+		t.Errorf("stage = %v, want scene_2_generating", fields["stage"])
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(`{"job_id":"job-1","stage":"variants_1_of_2_finished"}`), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Stage != VariantsFinished(1, 2) {
+		t.Errorf("stage = %#v", job.Stage)
+	}
+
+	// No stage is left out, as before
+	data, err = json.Marshal(Job{JobID: "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = nil
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["stage"]; ok {
+		t.Errorf("empty stage marshaled as %v", fields["stage"])
+	}
+}
+
+func TestJobStage_DynamoDB(t *testing.T) {
+	item, err := attributevalue.MarshalMap(Job{JobID: "job-1", Stage: SceneComplete(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, ok := item["stage"].(*types.AttributeValueMemberS)
+	if !ok || stage.Value != "scene_3_complete" {
+		t.Fatalf("stage attribute = %#v, want S scene_3_complete", item["stage"])
+	}
+
+	// Records written before the stage was typed read back as the same stage
+	for _, name := range []string{"scene_3_complete", "audio_generating", "variants_2_of_3_finished", "scripting"} {
+		item["stage"] = &types.AttributeValueMemberS{Value: name}
+		var job Job
+		if err := attributevalue.UnmarshalMap(item, &job); err != nil {
+			t.Fatalf("UnmarshalMap(%q): %v", name, err)
+		}
+		if job.Stage.String() != name {
+			t.Errorf("stage %q read back as %q", name, job.Stage)
+		}
+	}
+
+	// No stage is stored as an empty name, and read back from that or a missing attribute
+	item, err = attributevalue.MarshalMap(Job{JobID: "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stored := range []types.AttributeValue{item["stage"], &types.AttributeValueMemberNULL{Value: true}, nil} {
+		item["stage"] = stored
+		if stored == nil {
+			delete(item, "stage")
+		}
+		var job Job
+		if err := attributevalue.UnmarshalMap(item, &job); err != nil {
+			t.Fatalf("UnmarshalMap(%#v): %v", stored, err)
+		}
+		if !job.Stage.IsZero() {
+			t.Errorf("stage %#v read back as %q", stored, job.Stage)
+		}
+	}
+
+	item["stage"] = &types.AttributeValueMemberN{Value: "3"}
+	var job Job
+	if err := attributevalue.UnmarshalMap(item, &job); err == nil {
+		t.Error("a numeric stage read back without an error")
+	}
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Languages a script and its narration can be written in, as BCP-47 primary language subtags.
+// A job without a language is in English.
+const (
+	LanguageEnglish = "en"
+	LanguageSpanish = "es"
+	LanguageGerman  = "de"
+
+	DefaultLanguage = LanguageEnglish
+)
+
+// SupportedLanguages are the BCP-47 tags a job may request: each language and the regional
+// variants the narrator voices can read
+var SupportedLanguages = []string{
+	"en", "en-US", "en-GB",
+	"es", "es-ES", "es-MX", "es-US",
+	"de", "de-DE", "de-AT", "de-CH",
+}
+
+// languageNames are the English names of the supported languages, used in prompts
+var languageNames = map[string]string{
+	LanguageEnglish: "English",
+	LanguageSpanish: "Spanish",
+	LanguageGerman:  "German",
+}
+
+// CanonicalLanguageTag returns tag with BCP-47 casing: lowercase apart from the region, which
+// is uppercase, e.g. "ES-mx" becomes "es-MX"
+func CanonicalLanguageTag(tag string) string {
+	subtags := strings.Split(strings.ToLower(strings.TrimSpace(tag)), "-")
+	for i, subtag := range subtags {
+		if len(subtag) == 1 {
+			break // Extensions and private use follow; none of them is a region
+		}
+		if i > 0 && len(subtag) == 2 {
+			subtags[i] = strings.ToUpper(subtag)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+// BaseLanguage returns the primary language of tag, e.g. "es" for "es-MX"; "" is English
+func BaseLanguage(tag string) string {
+	base, _, _ := strings.Cut(CanonicalLanguageTag(tag), "-")
+	if base == "" {
+		return DefaultLanguage
+	}
+	return base
+}
+
+// LanguageName returns the English name of tag's language, or "" for an unsupported one
+func LanguageName(tag string) string {
+	return languageNames[BaseLanguage(tag)]
+}
+
+// NarrationPacing holds the words per second a narrator speaks at 1.0x, by primary language.
+// Narration word budgets and length checks use it to fit text to the time it has.
+type NarrationPacing map[string]float64
+
+// DefaultNarrationPacing is about 150 words a minute in English. Spanish and German words run
+// longer, so fewer of them fit in the same time.
+var DefaultNarrationPacing = NarrationPacing{
+	LanguageEnglish: 2.5,
+	LanguageSpanish: 2.2,
+	LanguageGerman:  2.0,
+}
+
+// ParseNarrationPacing reads "language:words_per_second" entries, e.g. "de:1.9", over the
+// defaults. Languages not listed keep their default pace.
+func ParseNarrationPacing(entries []string) (NarrationPacing, error) {
+	pacing := maps.Clone(DefaultNarrationPacing)
+	for _, entry := range entries {
+		language, value, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("narration pacing entry %q is not language:words_per_second", entry)
+		}
+		language = BaseLanguage(language)
+		if _, supported := languageNames[language]; !supported {
+			return nil, fmt.Errorf("narration pacing entry %q: unsupported language %q", entry, language)
+		}
+		wordsPerSecond, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || wordsPerSecond <= 0 || wordsPerSecond > 5 {
+			return nil, fmt.Errorf("narration pacing entry %q: words per second must be a number above 0 and at most 5", entry)
+		}
+		pacing[language] = wordsPerSecond
+	}
+	return pacing, nil
+}
+
+// WordsPerSecond returns the pace of tag's language, or English's for a language without one.
+// A nil table uses DefaultNarrationPacing.
+func (p NarrationPacing) WordsPerSecond(tag string) float64 {
+	if p == nil {
+		p = DefaultNarrationPacing
+	}
+	if wordsPerSecond, ok := p[BaseLanguage(tag)]; ok {
+		return wordsPerSecond
+	}
+	if wordsPerSecond, ok := p[DefaultLanguage]; ok {
+		return wordsPerSecond
+	}
+	return DefaultNarrationPacing[DefaultLanguage]
+}
@@ -0,0 +1,100 @@
+package domain
+
+import "testing"
+
+func TestCanonicalLanguageTag(t *testing.T) {
+	tests := map[string]string{
+		"es":         "es",
+		"ES-mx":      "es-MX",
+		" de-de ":    "de-DE",
+		"en-gb":      "en-GB",
+		"":           "",
+		"DE-ch-x-ab": "de-CH-x-ab",
+	}
+	for tag, want := range tests {
+		if got := CanonicalLanguageTag(tag); got != want {
+			t.Errorf("CanonicalLanguageTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	tests := map[string]string{
+		"":      "English",
+		"en-US": "English",
+		"es-MX": "Spanish",
+		"de":    "German",
+		"fr":    "",
+	}
+	for tag, want := range tests {
+		if got := LanguageName(tag); got != want {
+			t.Errorf("LanguageName(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestSupportedLanguagesHaveNamesAndPacing(t *testing.T) {
+	for _, tag := range SupportedLanguages {
+		if CanonicalLanguageTag(tag) != tag {
+			t.Errorf("supported language %q is not canonical", tag)
+		}
+		if LanguageName(tag) == "" {
+			t.Errorf("supported language %q has no name", tag)
+		}
+		if _, ok := DefaultNarrationPacing[BaseLanguage(tag)]; !ok {
+			t.Errorf("supported language %q has no default pacing", tag)
+		}
+	}
+}
+
+func TestNarrationPacingWordsPerSecond(t *testing.T) {
+	tests := []struct {
+		pacing NarrationPacing
+		tag    string
+		want   float64
+	}{
+		{nil, "", 2.5},
+		{nil, "en-GB", 2.5},
+		{nil, "es-MX", 2.2},
+		{nil, "de", 2.0},
+		{nil, "fr", 2.5}, // Unsupported languages pace like English
+		{NarrationPacing{"en": 2.4, "de": 1.8}, "de-AT", 1.8},
+		{NarrationPacing{"en": 2.4, "de": 1.8}, "es", 2.4},
+		{NarrationPacing{"de": 1.8}, "es", 2.5},
+	}
+	for _, tt := range tests {
+		if got := tt.pacing.WordsPerSecond(tt.tag); got != tt.want {
+			t.Errorf("%v.WordsPerSecond(%q) = %v, want %v", tt.pacing, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestParseNarrationPacing(t *testing.T) {
+	pacing, err := ParseNarrationPacing([]string{"de:1.9", " es-MX : 2.3 "})
+	if err != nil {
+		t.Fatalf("ParseNarrationPacing: %v", err)
+	}
+	want := NarrationPacing{"en": 2.5, "es": 2.3, "de": 1.9}
+	if len(pacing) != len(want) {
+		t.Fatalf("ParseNarrationPacing = %v, want %v", pacing, want)
+	}
+	for language, wordsPerSecond := range want {
+		if pacing[language] != wordsPerSecond {
+			t.Errorf("pacing[%q] = %v, want %v", language, pacing[language], wordsPerSecond)
+		}
+	}
+	if DefaultNarrationPacing["de"] != 2.0 {
+		t.Error("ParseNarrationPacing modified DefaultNarrationPacing")
+	}
+
+	defaults, err := ParseNarrationPacing(nil)
+	if err != nil || defaults.WordsPerSecond("es") != 2.2 {
+		t.Errorf("ParseNarrationPacing(nil) = %v, %v; want the defaults", defaults, err)
+	}
+
+	for _, entry := range []string{"de", "fr:2.0", "de:fast", "de:0", "de:-1", "de:9"} {
+		if _, err := ParseNarrationPacing([]string{entry}); err == nil {
+			t.Errorf("ParseNarrationPacing(%q) should fail", entry)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package domain
+
+// Preferences are a user's default generation settings, filled into POST /generate requests that
+// leave them out. An empty field has no preference, so the system default applies.
+type Preferences struct {
+	UserID         string `json:"-" dynamodbav:"user_id"`
+	AspectRatio    string `json:"aspect_ratio,omitempty" dynamodbav:"aspect_ratio,omitempty"`
+	Model          string `json:"model,omitempty" dynamodbav:"model,omitempty"`
+	Style          string `json:"style,omitempty" dynamodbav:"style,omitempty"`
+	Tone           string `json:"tone,omitempty" dynamodbav:"tone,omitempty"`
+	Tempo          string `json:"tempo,omitempty" dynamodbav:"tempo,omitempty"`
+	Platform       string `json:"platform,omitempty" dynamodbav:"platform,omitempty"`
+	Voice          string `json:"voice,omitempty" dynamodbav:"voice,omitempty"`               // Narrator of pharmaceutical ads
+	TTSProvider    string `json:"tts_provider,omitempty" dynamodbav:"tts_provider,omitempty"` // Provider of Voice
+	ContinuityMode string `json:"continuity_mode,omitempty" dynamodbav:"continuity_mode,omitempty"`
+	UpdatedAt      int64  `json:"updated_at,omitempty" dynamodbav:"updated_at"` // Unix timestamp
+}
@@ -24,8 +24,21 @@ type Script struct {
 	UpdatedAt        int64     `json:"updated_at" dynamodbav:"updated_at"`                                   // Unix timestamp
 	Status           string    `json:"status" dynamodbav:"status"`                                           // "draft", "approved", "generating", "completed"
 	ExpiresAt        int64     `json:"expires_at,omitempty" dynamodbav:"expires_at,omitempty"`               // TTL timestamp
+
+	// Job settings the script was written for, reused when it is rerun from the script library
+	SourceJobID string `json:"source_job_id,omitempty" dynamodbav:"source_job_id,omitempty"`
+	Prompt      string `json:"prompt,omitempty" dynamodbav:"prompt,omitempty"`
+	AspectRatio string `json:"aspect_ratio,omitempty" dynamodbav:"aspect_ratio,omitempty"`
+	Model       string `json:"model,omitempty" dynamodbav:"model,omitempty"`
+	StartImage  string `json:"start_image,omitempty" dynamodbav:"start_image,omitempty"`
+	Voice       string `json:"voice,omitempty" dynamodbav:"voice,omitempty"`
+	TTSProvider string `json:"tts_provider,omitempty" dynamodbav:"tts_provider,omitempty"`
+	SideEffects string `json:"side_effects,omitempty" dynamodbav:"side_effects,omitempty"` // As the user entered it
 }
 
+// ScriptStatusGenerated is the status of a script stored after GPT-4o wrote it
+const ScriptStatusGenerated = "generated"
+
 // Scene represents a single shot/scene in the advertisement with cinematography details
 type Scene struct {
 	SceneNumber int     `json:"scene_number"`
@@ -54,8 +67,18 @@ type Scene struct {
 	TransitionOut Transition `json:"transition_out"` // How to exit this scene
 
 	// AI Generation
-	GenerationPrompt string `json:"generation_prompt"`         // Optimized prompt for Veo 3.1
-	StartImageURL    string `json:"start_image_url,omitempty"` // For visual continuity between scenes
+	GenerationPrompt string `json:"generation_prompt"`            // Optimized prompt for Veo 3.1
+	StartImageURL    string `json:"start_image_url,omitempty"`    // For visual continuity between scenes
+	AssignedImageRef string `json:"assigned_image_ref,omitempty"` // Product image (ProductImage.Ref) this scene starts from
+
+	// Generation parameters (optional)
+	NegativePrompt   string             `json:"negative_prompt,omitempty"`   // What the video model should avoid, e.g. "extra fingers, warped text"
+	Seed             *int64             `json:"seed,omitempty"`              // Pins the clip's seed so a regeneration reproduces it
+	GenerationParams map[string]float64 `json:"generation_params,omitempty"` // Provider-neutral parameters, e.g. adapters.ParamGuidance
+
+	// Scene-level voiceover / dialogue (optional)
+	VoiceoverText  string `json:"voiceover_text,omitempty"`  // Line spoken over this scene
+	VoiceoverVoice string `json:"voiceover_voice,omitempty"` // "male" or "female"; defaults to the job voice
 }
 
 // AudioSpec defines the audio requirements for the advertisement
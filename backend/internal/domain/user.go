@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -27,6 +28,7 @@ type UserClaims struct {
 	CognitoUsername  string `json:"cognito:username"`             // Cognito username
 	Name             string `json:"name"`                         // User's full name
 	SubscriptionTier string `json:"custom:subscription_tier"`     // Custom attribute
+	Groups           []string `json:"cognito:groups"`             // Cognito groups the user belongs to
 	TokenUse         string `json:"token_use"`                    // "access" or "id"
 	AuthTime         int64  `json:"auth_time"`                    // Authentication timestamp
 }
@@ -44,6 +46,11 @@ func (uc *UserClaims) ToUser() *User {
 	}
 }
 
+// HasGroup checks if the user belongs to a Cognito group
+func (uc *UserClaims) HasGroup(group string) bool {
+	return slices.Contains(uc.Groups, group)
+}
+
 // IsAccessToken checks if the token is an access token
 func (uc *UserClaims) IsAccessToken() bool {
 	return uc.TokenUse == "access"
@@ -72,6 +79,25 @@ type Usage struct {
 	LastUpdated      time.Time `json:"last_updated" dynamodbav:"last_updated"`
 	MonthlyQuota     int       `json:"monthly_quota" dynamodbav:"monthly_quota"`
 	QuotaRemaining   int       `json:"quota_remaining" dynamodbav:"quota_remaining"`
+
+	// Generation credits for the period; each job's charge is stored as its own item
+	MonthlyCredits   int `json:"monthly_credits" dynamodbav:"monthly_credits"`
+	CreditsRemaining int `json:"credits_remaining" dynamodbav:"credits_remaining"`
+	CreditsUsed      int `json:"credits_used" dynamodbav:"credits_used"` // Net of refunds
+
+	// Characters synthesized outside jobs, e.g. voice previews
+	TTSCharacters int `json:"tts_characters" dynamodbav:"tts_characters"`
+}
+
+// CreditCharge records the credits taken for one job and any amount refunded
+type CreditCharge struct {
+	JobID     string `json:"job_id" dynamodbav:"job_id"`
+	Model     string `json:"model" dynamodbav:"model"`
+	Duration  int    `json:"duration" dynamodbav:"duration"` // Seconds requested
+	Scenes    int    `json:"scenes" dynamodbav:"scenes"`     // Scene count the charge was priced for
+	Credits   int    `json:"credits" dynamodbav:"credits"`
+	Refunded  int    `json:"refunded" dynamodbav:"refunded"`
+	ChargedAt int64  `json:"charged_at" dynamodbav:"charged_at"`
 }
 
 // HasQuotaRemaining checks if user has remaining quota
@@ -0,0 +1,85 @@
+package ffmpegexec
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Kind is why a run failed
+type Kind string
+
+const (
+	KindFailed          Kind = "failed"           // A non-zero exit not classified below
+	KindNoSpace         Kind = "no_space"         // The disk filled up (ENOSPC)
+	KindKilled          Kind = "killed"           // SIGKILL from outside, usually the OOM killer
+	KindInvalidArgument Kind = "invalid_argument" // Bad option, filter or input; rerunning won't help
+	KindTimeout         Kind = "timeout"          // Spec.Timeout elapsed
+	KindCanceled        Kind = "canceled"         // The caller's context ended
+	KindNotStarted      Kind = "not_started"      // The binary couldn't be started
+)
+
+// ffmpeg exits with its error code's low byte: AVERROR(ENOSPC) and AVERROR(EINVAL)
+const (
+	exitNoSpace         = 228
+	exitInvalidArgument = 234
+)
+
+// noSpaceMessages mark a full disk in ffmpeg's stderr
+var noSpaceMessages = []string{
+	"No space left on device",
+}
+
+// invalidArgumentMessages mark a command that will fail the same way every time it runs
+var invalidArgumentMessages = []string{
+	"Invalid argument",
+	"Unrecognized option",
+	"Option not found",
+	"No such filter",
+	"Error parsing",
+	"Error initializing filter",
+	"Invalid data found when processing input",
+	"No such file or directory",
+	"Missing argument for option",
+}
+
+// kindOf classifies a non-zero exit from its status and stderr
+func kindOf(exitErr *exec.ExitError, stderr string) Kind {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGKILL {
+		return KindKilled
+	}
+	switch exitErr.ExitCode() {
+	case exitNoSpace:
+		return KindNoSpace
+	case exitInvalidArgument:
+		return KindInvalidArgument
+	case 137: // 128 + SIGKILL, from a shell wrapping the binary
+		return KindKilled
+	}
+	// A full disk often surfaces as a generic write error, so it's checked first
+	if containsAny(stderr, noSpaceMessages) {
+		return KindNoSpace
+	}
+	if containsAny(stderr, invalidArgumentMessages) {
+		return KindInvalidArgument
+	}
+	return KindFailed
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	s = strings.TrimRight(s, "\r\n\t ")
+	if i := strings.LastIndexAny(s, "\r\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
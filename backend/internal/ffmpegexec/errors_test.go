@@ -0,0 +1,98 @@
+package ffmpegexec
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// exitError runs a shell command and returns the *exec.ExitError it fails with
+func exitError(t *testing.T, script string) *exec.ExitError {
+	t.Helper()
+	err := exec.Command("sh", "-c", script).Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("sh -c %q: got %v, want an exit error", script, err)
+	}
+	return exitErr
+}
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return string(data)
+}
+
+func TestKindOfCapturedStderr(t *testing.T) {
+	failed := exitError(t, "exit 1")
+	tests := []struct {
+		fixture string
+		want    Kind
+	}{
+		{"stderr_enospc.txt", KindNoSpace},
+		{"stderr_invalid_filter.txt", KindInvalidArgument},
+		{"stderr_missing_input.txt", KindInvalidArgument},
+		{"stderr_decode_error.txt", KindFailed},
+	}
+	for _, tt := range tests {
+		if got := kindOf(failed, readFixture(t, tt.fixture)); got != tt.want {
+			t.Errorf("%s: kind = %s, want %s", tt.fixture, got, tt.want)
+		}
+	}
+}
+
+func TestKindOfExitStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		want   Kind
+	}{
+		{"exit 228", KindNoSpace},
+		{"exit 234", KindInvalidArgument},
+		{"exit 137", KindKilled},
+		{"kill -9 $$", KindKilled},
+		{"exit 1", KindFailed},
+	}
+	for _, tt := range tests {
+		if got := kindOf(exitError(t, tt.script), ""); got != tt.want {
+			t.Errorf("%s: kind = %s, want %s", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Name: "ffmpeg", Kind: KindNoSpace, Stderr: readFixture(t, "stderr_enospc.txt"), Err: errors.New("exit status 1")}
+	if got, want := err.Error(), "ffmpeg no_space: exit status 1 (Conversion failed!)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if KindOf(err) != KindNoSpace {
+		t.Errorf("KindOf = %s, want no_space", KindOf(err))
+	}
+	if KindOf(errors.New("other")) != KindFailed {
+		t.Error("KindOf of a foreign error should be failed")
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	buf := newTailBuffer(32)
+	for i := 0; i < 10; i++ {
+		buf.Write([]byte("frame dropped at packet 0000\n"))
+	}
+	buf.Write([]byte("Conversion failed!\n"))
+	got := buf.String()
+	if len(got) > 32 {
+		t.Errorf("kept %d bytes, want at most 32", len(got))
+	}
+	if got != "Conversion failed!\n" {
+		t.Errorf("String() = %q, want the whole last line", got)
+	}
+
+	short := newTailBuffer(64)
+	short.Write([]byte("Conversion failed!\n"))
+	if got := short.String(); got != "Conversion failed!\n" {
+		t.Errorf("String() = %q, want a short stderr kept whole", got)
+	}
+}
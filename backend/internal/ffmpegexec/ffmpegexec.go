@@ -0,0 +1,164 @@
+// Package ffmpegexec runs ffmpeg and ffprobe with the limits and visibility a long encode needs:
+// each run has its own timeout inside the caller's context, can be lowered in CPU and IO
+// priority, reports ffmpeg's -progress output as it goes and, when it fails, returns the tail
+// of its stderr and what kind of failure it was.
+package ffmpegexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// DefaultStderrLimit is how much of a command's stderr is kept for its error when the spec
+// doesn't say
+const DefaultStderrLimit = 16 << 10
+
+// waitDelay bounds how long Run waits for a killed command's pipes to close, in case a child
+// of the command still holds them
+const waitDelay = 2 * time.Second
+
+// Spec describes one invocation
+type Spec struct {
+	Name        string         // Binary to run; "ffmpeg" when empty
+	Args        []string       // Arguments, without the binary
+	Timeout     time.Duration  // Limit on this run within ctx; 0 leaves only ctx's
+	Progress    func(Progress) // Receives each -progress report; nil doesn't ask ffmpeg for them
+	LowPriority bool           // Run at lowered CPU and IO priority (Linux only)
+	Stdin       io.Reader
+	Stdout      io.Writer
+	StderrLimit int // Bytes of stderr kept for the error; DefaultStderrLimit when 0
+}
+
+func (s Spec) name() string {
+	if s.Name == "" {
+		return "ffmpeg"
+	}
+	return s.Name
+}
+
+// Run runs spec to completion. A failure is returned as an *Error; Progress has received its
+// last report by the time Run returns.
+func Run(ctx context.Context, spec Spec) error {
+	runCtx := ctx
+	if spec.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, spec.Timeout)
+		defer cancel()
+	}
+
+	args := spec.Args
+	var progressR *os.File
+	var progressW *os.File
+	if spec.Progress != nil {
+		var err error
+		progressR, progressW, err = os.Pipe()
+		if err != nil {
+			return &Error{Name: spec.name(), Kind: KindNotStarted, ExitCode: -1, Err: err}
+		}
+		defer progressR.Close()
+		// The pipe is the child's first extra file, fd 3; -nostats keeps the same numbers out of stderr
+		args = append([]string{"-nostats", "-progress", "pipe:3"}, args...)
+	}
+
+	limit := spec.StderrLimit
+	if limit <= 0 {
+		limit = DefaultStderrLimit
+	}
+	stderr := newTailBuffer(limit)
+
+	cmd := exec.CommandContext(runCtx, spec.name(), args...)
+	cmd.Stdin = spec.Stdin
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	if progressW != nil {
+		cmd.ExtraFiles = []*os.File{progressW}
+	}
+
+	err := cmd.Start()
+	if progressW != nil {
+		progressW.Close() // The child holds its own copy; the reader sees EOF once it exits
+	}
+	if err != nil {
+		return &Error{Name: spec.name(), Kind: KindNotStarted, ExitCode: -1, Err: err}
+	}
+	if spec.LowPriority {
+		_ = lowerPriority(cmd.Process.Pid) // Best effort: a run at normal priority is still a run
+	}
+
+	parsed := make(chan struct{})
+	if progressR != nil {
+		go func() {
+			defer close(parsed)
+			parseProgress(progressR, spec.Progress)
+		}()
+	} else {
+		close(parsed)
+	}
+
+	err = cmd.Wait()
+	<-parsed
+	if err == nil {
+		return nil
+	}
+	return classify(spec.name(), err, stderr.String(), ctx, runCtx)
+}
+
+// classify builds the Error of a run that ended in err, given its stderr tail, the caller's
+// context and the run's own context
+func classify(name string, err error, stderr string, ctx, runCtx context.Context) *Error {
+	e := &Error{Name: name, Kind: KindFailed, ExitCode: -1, Stderr: stderr, Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		e.Kind = KindCanceled
+		e.Err = ctx.Err()
+	case runCtx.Err() != nil:
+		e.Kind = KindTimeout
+		e.Err = runCtx.Err()
+	case exitErr == nil:
+		e.Kind = KindNotStarted
+	default:
+		e.Kind = kindOf(exitErr, stderr)
+	}
+	return e
+}
+
+// Error is a run that failed
+type Error struct {
+	Name     string // Binary that was run
+	Kind     Kind
+	ExitCode int    // -1 when the command was killed by a signal or never started
+	Stderr   string // Last lines of the command's stderr
+	Err      error  // *exec.ExitError, or the context error of a timeout or cancellation
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s %s: %v", e.Name, e.Kind, e.Err)
+	if line := lastLine(e.Stderr); line != "" {
+		msg += " (" + line + ")"
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of err's *Error, or KindFailed for any other error
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindFailed
+}
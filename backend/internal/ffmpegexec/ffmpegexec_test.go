@@ -0,0 +1,126 @@
+package ffmpegexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBinary writes an executable shell script standing in for ffmpeg and returns its path
+func fakeBinary(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatalf("write fake binary: %v", err)
+	}
+	return path
+}
+
+func TestRunTimeout(t *testing.T) {
+	slow := fakeBinary(t, "exec sleep 30")
+
+	start := time.Now()
+	err := Run(context.Background(), Spec{Name: slow, Args: []string{"-i", "in.mp4", "out.mp4"}, Timeout: 200 * time.Millisecond})
+	if took := time.Since(start); took > 5*time.Second {
+		t.Fatalf("Run took %v, want it stopped at the timeout", took)
+	}
+	if KindOf(err) != KindTimeout {
+		t.Fatalf("kind = %s (%v), want timeout", KindOf(err), err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+}
+
+func TestRunTimeoutWithChildHoldingPipes(t *testing.T) {
+	// sleep outlives the killed shell and keeps stderr open; Run must not wait for it
+	slow := fakeBinary(t, "sleep 30")
+
+	start := time.Now()
+	err := Run(context.Background(), Spec{Name: slow, Timeout: 200 * time.Millisecond})
+	if took := time.Since(start); took > waitDelay+5*time.Second {
+		t.Fatalf("Run took %v, want it bounded by the wait delay", took)
+	}
+	if KindOf(err) != KindTimeout {
+		t.Errorf("kind = %s (%v), want timeout", KindOf(err), err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	slow := fakeBinary(t, "exec sleep 30")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	err := Run(ctx, Spec{Name: slow, Timeout: time.Minute})
+	if KindOf(err) != KindCanceled || !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want canceled", err)
+	}
+}
+
+func TestRunReportsProgress(t *testing.T) {
+	fixture, err := filepath.Abs("testdata/progress_encode.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake checks it was asked for progress on fd 3, as ffmpeg would be
+	bin := fakeBinary(t, `[ "$1 $2 $3" = "-nostats -progress pipe:3" ] || exit 2
+cat "`+fixture+`" >&3`)
+
+	var reports []Progress
+	err = Run(context.Background(), Spec{Name: bin, Args: []string{"-i", "in.mp4", "out.mp4"}, Progress: func(p Progress) { reports = append(reports, p) }})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(reports) != 4 || !reports[3].Done || reports[3].Frame != 240 {
+		t.Errorf("reports = %+v, want the fixture's four", reports)
+	}
+}
+
+func TestRunWithoutProgressPassesArgsThrough(t *testing.T) {
+	bin := fakeBinary(t, `echo "$@"`)
+	var stdout bytes.Buffer
+	if err := Run(context.Background(), Spec{Name: bin, Args: []string{"-i", "in.mp4", "out.mp4"}, Stdout: &stdout, Stdin: strings.NewReader("")}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "-i in.mp4 out.mp4" {
+		t.Errorf("args = %q", got)
+	}
+}
+
+func TestRunFailureKeepsStderrTail(t *testing.T) {
+	fixture, err := filepath.Abs("testdata/stderr_enospc.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := fakeBinary(t, `i=0
+while [ $i -lt 500 ]; do echo "frame=$i fps=30 q=28.0 size=1024kB time=00:00:01.00 bitrate=4211kbits/s" >&2; i=$((i+1)); done
+cat "`+fixture+`" >&2
+exit 1`)
+
+	err = Run(context.Background(), Spec{Name: bin, StderrLimit: 1024})
+	var runErr *Error
+	if !errors.As(err, &runErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if runErr.Kind != KindNoSpace || runErr.ExitCode != 1 {
+		t.Errorf("kind = %s, exit = %d, want no_space, 1", runErr.Kind, runErr.ExitCode)
+	}
+	if len(runErr.Stderr) > 1024 {
+		t.Errorf("kept %d bytes of stderr, want at most 1024", len(runErr.Stderr))
+	}
+	if !strings.HasSuffix(runErr.Stderr, "Conversion failed!\n") {
+		t.Errorf("stderr tail = %q, want it to end with ffmpeg's last line", runErr.Stderr)
+	}
+}
+
+func TestRunNotStarted(t *testing.T) {
+	err := Run(context.Background(), Spec{Name: filepath.Join(t.TempDir(), "missing")})
+	if KindOf(err) != KindNotStarted {
+		t.Errorf("err = %v, want not_started", err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package ffmpegexec
+
+import "syscall"
+
+const (
+	lowPriorityNice = 10 // Of -20 (highest) to 19
+
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// lowerPriority moves the process pid to nice 10 and the idle IO class, so encodes yield the
+// CPU and disk to request handling. It runs just after the process starts, before ffmpeg
+// spawns the encoder threads that inherit it.
+func lowerPriority(pid int) error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, lowPriorityNice); err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), ioprioClassIdle<<ioprioClassShift)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
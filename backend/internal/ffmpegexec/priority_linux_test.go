@@ -0,0 +1,27 @@
+//go:build linux
+
+package ffmpegexec
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunLowPriority(t *testing.T) {
+	// nice prints the niceness it runs at; the sleep lets Run lower it first
+	bin := fakeBinary(t, "sleep 0.2; nice")
+	for _, tt := range []struct {
+		low  bool
+		want string
+	}{{false, "0"}, {true, "10"}} {
+		var stdout bytes.Buffer
+		if err := Run(context.Background(), Spec{Name: bin, LowPriority: tt.low, Stdout: &stdout}); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if got := strings.TrimSpace(stdout.String()); got != tt.want {
+			t.Errorf("LowPriority %v: niceness = %s, want %s", tt.low, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ffmpegexec
+
+// lowerPriority does nothing outside Linux
+func lowerPriority(pid int) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package ffmpegexec
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is one of ffmpeg's -progress reports
+type Progress struct {
+	Frame   int64         // Frames written so far; 0 for audio-only outputs
+	OutTime time.Duration // Position reached in the output
+	Speed   float64       // Multiple of real time; 0 while ffmpeg reports N/A
+	Done    bool          // The final report
+}
+
+// Fraction is how much of an output of total length the report has reached, in [0, 1]. A
+// final report is always 1.
+func (p Progress) Fraction(total time.Duration) float64 {
+	if p.Done {
+		return 1
+	}
+	if total <= 0 || p.OutTime <= 0 {
+		return 0
+	}
+	return min(float64(p.OutTime)/float64(total), 1)
+}
+
+// parseProgress reads key=value lines from r until EOF, calling fn once per report. A report
+// ends at its progress=continue or progress=end line; fields ffmpeg hasn't measured yet (N/A)
+// keep their zero value.
+func parseProgress(r io.Reader, fn func(Progress)) {
+	var p Progress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "frame":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				p.Frame = n
+			}
+		case "out_time_us":
+			// out_time_ms is also microseconds, a long-standing ffmpeg quirk; out_time_us is unambiguous
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				p.OutTime = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			if speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+				p.Speed = speed
+			}
+		case "progress":
+			p.Done = value == "end"
+			fn(p)
+			p = Progress{}
+		}
+	}
+	// A read error means the command went away; Run reports why
+}
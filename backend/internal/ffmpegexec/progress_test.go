@@ -0,0 +1,77 @@
+package ffmpegexec
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string) []Progress {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+	var reports []Progress
+	parseProgress(f, func(p Progress) { reports = append(reports, p) })
+	return reports
+}
+
+func TestParseProgressEncode(t *testing.T) {
+	reports := parseFixture(t, "progress_encode.txt")
+	want := []Progress{
+		{}, // Before the first frame ffmpeg reports N/A
+		{Frame: 92, OutTime: 4013333 * time.Microsecond, Speed: 4.01},
+		{Frame: 193, OutTime: 8033333 * time.Microsecond, Speed: 4.0},
+		{Frame: 240, OutTime: 9996667 * time.Microsecond, Speed: 3.98, Done: true},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("got %d reports, want %d: %+v", len(reports), len(want), reports)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("report %d = %+v, want %+v", i, reports[i], want[i])
+		}
+	}
+}
+
+func TestParseProgressAudio(t *testing.T) {
+	reports := parseFixture(t, "progress_audio.txt")
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	want := Progress{OutTime: 6034286 * time.Microsecond, Speed: 312, Done: true}
+	if reports[0] != want {
+		t.Errorf("report = %+v, want %+v", reports[0], want)
+	}
+}
+
+func TestParseProgressIgnoresIncompleteReport(t *testing.T) {
+	// A command killed mid-report leaves a partial block
+	var reports []Progress
+	parseProgress(strings.NewReader("frame=12\nout_time_us=400000\nprogress=continue\nframe=24\nout_ti"), func(p Progress) { reports = append(reports, p) })
+	if len(reports) != 1 || reports[0].Frame != 12 {
+		t.Errorf("reports = %+v, want only the complete one", reports)
+	}
+}
+
+func TestProgressFraction(t *testing.T) {
+	tests := []struct {
+		p     Progress
+		total time.Duration
+		want  float64
+	}{
+		{Progress{OutTime: 4 * time.Second}, 8 * time.Second, 0.5},
+		{Progress{OutTime: 9 * time.Second}, 8 * time.Second, 1}, // Containers can run past the estimate
+		{Progress{}, 8 * time.Second, 0},
+		{Progress{OutTime: 4 * time.Second}, 0, 0},
+		{Progress{Done: true}, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Fraction(tt.total); got != tt.want {
+			t.Errorf("%+v.Fraction(%v) = %v, want %v", tt.p, tt.total, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package ffmpegexec
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/omnigen/backend/internal/metrics"
+)
+
+// Limits on a single ffmpeg run. Each is far inside the job's context, so a stuck encode fails
+// its step instead of holding the job until the context ends.
+const (
+	EncodeTimeout = 20 * time.Minute // Passes over the whole video
+	FrameTimeout  = 2 * time.Minute  // Single frames, thumbnails and narration audio
+)
+
+// Runner executes the ffmpeg and ffprobe commands a pipeline builds. RunCommand, CommandOutput,
+// CombinedOutput and Exec hand each command to the runner ctx carries, so a composition's
+// runner also covers the helpers it calls; tests swap in one that records the arguments
+// instead of running anything, and cmd/omnigen-cli one that prints them.
+type Runner interface {
+	Run(cmd *exec.Cmd) error
+	Output(cmd *exec.Cmd) ([]byte, error)
+	CombinedOutput(cmd *exec.Cmd) ([]byte, error)
+	Exec(ctx context.Context, spec Spec) error
+}
+
+// ExecRunner runs commands as they are
+type ExecRunner struct{}
+
+func (ExecRunner) Run(cmd *exec.Cmd) error                      { return cmd.Run() }
+func (ExecRunner) Output(cmd *exec.Cmd) ([]byte, error)         { return cmd.Output() }
+func (ExecRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) { return cmd.CombinedOutput() }
+
+func (ExecRunner) Exec(ctx context.Context, spec Spec) error {
+	return Run(ctx, spec)
+}
+
+// Stderr returns the stderr tail a failed Exec kept, for logs
+func Stderr(err error) string {
+	var runErr *Error
+	if errors.As(err, &runErr) {
+		return runErr.Stderr
+	}
+	return ""
+}
+
+type runnerKey struct{}
+
+// WithRunner returns ctx with runner executing its commands
+func WithRunner(ctx context.Context, runner Runner) context.Context {
+	return context.WithValue(ctx, runnerKey{}, runner)
+}
+
+// RunnerFrom returns the runner ctx carries, or ExecRunner
+func RunnerFrom(ctx context.Context) Runner {
+	if runner, ok := ctx.Value(runnerKey{}).(Runner); ok && runner != nil {
+		return runner
+	}
+	return ExecRunner{}
+}
+
+// RunCommand runs an ffmpeg or ffprobe command with ctx's runner, recording its duration and
+// exit code under operation
+func RunCommand(ctx context.Context, operation string, cmd *exec.Cmd) error {
+	start := time.Now()
+	err := RunnerFrom(ctx).Run(cmd)
+	recordCommand(ctx, operation, cmd, start, err)
+	return err
+}
+
+// CommandOutput is RunCommand for cmd.Output
+func CommandOutput(ctx context.Context, operation string, cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := RunnerFrom(ctx).Output(cmd)
+	recordCommand(ctx, operation, cmd, start, err)
+	return output, err
+}
+
+// CombinedOutput is RunCommand for cmd.CombinedOutput
+func CombinedOutput(ctx context.Context, operation string, cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := RunnerFrom(ctx).CombinedOutput(cmd)
+	recordCommand(ctx, operation, cmd, start, err)
+	return output, err
+}
+
+// Exec runs spec like Run, but with ctx's runner, recording its duration and exit code under
+// operation like RunCommand
+func Exec(ctx context.Context, operation string, spec Spec) error {
+	start := time.Now()
+	err := RunnerFrom(ctx).Exec(ctx, spec)
+	tool := spec.Name
+	if tool == "" {
+		tool = "ffmpeg"
+	}
+	recordCommandExit(ctx, filepath.Base(tool), operation, start, err)
+	return err
+}
+
+func recordCommand(ctx context.Context, operation string, cmd *exec.Cmd, start time.Time, err error) {
+	recordCommandExit(ctx, filepath.Base(cmd.Path), operation, start, err)
+}
+
+func recordCommandExit(ctx context.Context, tool, operation string, start time.Time, err error) {
+	rec := metrics.FromContext(ctx)
+	dims := metrics.Dimensions{"tool": tool, "operation": operation}
+	metrics.Since(rec, metrics.CommandDuration, start, dims)
+
+	exitCode := "0"
+	var exitErr *exec.ExitError
+	var runErr *Error
+	switch {
+	case errors.As(err, &runErr):
+		exitCode = strconv.Itoa(runErr.ExitCode) // -1 when killed, e.g. by a timeout
+		if runErr.Kind == KindNotStarted {
+			exitCode = "not_started"
+		}
+	case errors.As(err, &exitErr):
+		exitCode = strconv.Itoa(exitErr.ExitCode()) // -1 when killed, e.g. by a canceled context
+	case err != nil:
+		exitCode = "not_started"
+	}
+	rec.Counter(metrics.CommandExit, 1, metrics.Dimensions{"tool": tool, "operation": operation, "exit_code": exitCode})
+}
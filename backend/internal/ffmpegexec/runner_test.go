@@ -0,0 +1,87 @@
+package ffmpegexec
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/omnigen/backend/internal/metrics"
+)
+
+type recordedMetric struct {
+	name string
+	dims metrics.Dimensions
+}
+
+// fakeRecorder keeps the name and dimensions of every measurement it receives
+type fakeRecorder struct {
+	mu       sync.Mutex
+	recorded []recordedMetric
+}
+
+func (r *fakeRecorder) record(name string, dims metrics.Dimensions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, recordedMetric{name: name, dims: dims})
+}
+
+func (r *fakeRecorder) Counter(name string, _ float64, dims metrics.Dimensions) {
+	r.record(name, dims)
+}
+
+func (r *fakeRecorder) Histogram(name string, _ float64, _ metrics.Unit, dims metrics.Dimensions) {
+	r.record(name, dims)
+}
+
+func (r *fakeRecorder) Gauge(name string, _ float64, _ metrics.Unit, dims metrics.Dimensions) {
+	r.record(name, dims)
+}
+
+func TestCommandMetrics(t *testing.T) {
+	rec := &fakeRecorder{}
+	ctx := metrics.WithRecorder(context.Background(), rec)
+
+	if err := RunCommand(ctx, "concat", exec.CommandContext(ctx, "sh", "-c", "exit 3")); err == nil {
+		t.Fatal("expected the failing command's error")
+	}
+	if _, err := CommandOutput(ctx, "probe_fps", exec.CommandContext(ctx, "sh", "-c", "echo 30/1")); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	want := []recordedMetric{
+		{name: metrics.CommandDuration, dims: metrics.Dimensions{"tool": "sh", "operation": "concat"}},
+		{name: metrics.CommandExit, dims: metrics.Dimensions{"tool": "sh", "operation": "concat", "exit_code": "3"}},
+		{name: metrics.CommandDuration, dims: metrics.Dimensions{"tool": "sh", "operation": "probe_fps"}},
+		{name: metrics.CommandExit, dims: metrics.Dimensions{"tool": "sh", "operation": "probe_fps", "exit_code": "0"}},
+	}
+	if !reflect.DeepEqual(rec.recorded, want) {
+		t.Fatalf("recorded %v, want %v", rec.recorded, want)
+	}
+}
+
+type recordingRunner struct {
+	ExecRunner
+	specs []Spec
+}
+
+func (r *recordingRunner) Exec(ctx context.Context, spec Spec) error {
+	r.specs = append(r.specs, spec)
+	return nil
+}
+
+func TestExecUsesContextRunner(t *testing.T) {
+	runner := &recordingRunner{}
+	ctx := WithRunner(context.Background(), runner)
+
+	if err := Exec(ctx, "concat", Spec{Name: "/missing/ffmpeg", Args: []string{"-version"}}); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if len(runner.specs) != 1 || runner.specs[0].Name != "/missing/ffmpeg" {
+		t.Fatalf("runner got %v, want the spec", runner.specs)
+	}
+	if _, ok := RunnerFrom(context.Background()).(ExecRunner); !ok {
+		t.Fatal("contexts without a runner should run commands as they are")
+	}
+}
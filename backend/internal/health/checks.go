@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+)
+
+// ReplicateAccountURL is the cheapest authenticated Replicate endpoint
+const ReplicateAccountURL = "https://api.replicate.com/v1/account"
+
+// CommandRunner runs a program and returns its combined output
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// ExecCommand runs programs found in PATH
+func ExecCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s not found in PATH", name)
+	}
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// Binary checks that an ffmpeg-style tool runs and reports its version
+func Binary(name string, run CommandRunner) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) (string, error) {
+			output, err := run(ctx, name, "-version")
+			if err != nil {
+				return "", err
+			}
+			version, err := ParseVersion(name, string(output))
+			if err != nil {
+				return "", err
+			}
+			return version, nil
+		},
+	}
+}
+
+// ParseVersion reads the version from the first line of `<tool> -version` output, e.g.
+// "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers"
+func ParseVersion(tool, output string) (string, error) {
+	firstLine, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(firstLine)
+	if len(fields) < 3 || fields[0] != tool || fields[1] != "version" {
+		return "", fmt.Errorf("unrecognized %s -version output: %q", tool, firstLine)
+	}
+	return fields[2], nil
+}
+
+// Pinger is anything with a lightweight connectivity check, such as the DynamoDB and S3
+// repositories' HealthCheck (DescribeTable and HeadBucket)
+type Pinger interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Ping checks a dependency through its HealthCheck
+func Ping(name, detail string, pinger Pinger) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) (string, error) {
+			if err := pinger.HealthCheck(ctx); err != nil {
+				return "", err
+			}
+			return detail, nil
+		},
+	}
+}
+
+// Replicate checks that the Replicate API accepts the API token
+func Replicate(client *http.Client, accountURL, apiToken string) Check {
+	return ReplicateTokens(client, accountURL, adapters.StaticToken(apiToken))
+}
+
+// ReplicateTokens checks that the Replicate API accepts the token tokens currently supplies.
+// A rejected token is reported to tokens, so a rotated key is picked up by the next check.
+func ReplicateTokens(client *http.Client, accountURL string, tokens adapters.TokenSource) Check {
+	return Check{
+		Name: "replicate",
+		Run: func(ctx context.Context) (string, error) {
+			apiToken, err := tokens.Token(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to get API token: %w", err)
+			}
+			if apiToken == "" {
+				return "", errors.New("no API token configured")
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, accountURL, nil)
+			if err != nil {
+				return "", fmt.Errorf("failed to create request: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+apiToken)
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return "", fmt.Errorf("request failed: %w", err)
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+			switch {
+			case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+				if resp.StatusCode == http.StatusUnauthorized {
+					tokens.TokenRejected(ctx, apiToken)
+				}
+				return "", fmt.Errorf("API token rejected (status %d)", resp.StatusCode)
+			case resp.StatusCode != http.StatusOK:
+				return "", fmt.Errorf("API error (status %d)", resp.StatusCode)
+			}
+			return "authenticated", nil
+		},
+	}
+}
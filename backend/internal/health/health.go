@@ -0,0 +1,142 @@
+// Package health runs the dependency checks behind the readiness endpoint
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Dependency statuses
+const (
+	StatusOK        = "ok"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Overall readiness
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// DefaultCacheTTL is how long a readiness report is reused, so load balancer polling doesn't
+// reach the dependencies on every request
+const DefaultCacheTTL = 5 * time.Second
+
+// DefaultCheckTimeout bounds each dependency check
+const DefaultCheckTimeout = 3 * time.Second
+
+// Check verifies one dependency. Run returns a short detail (a version, a table name) on success.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// DependencyStatus is the outcome of one check
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// Report is the outcome of every check
+type Report struct {
+	Status    string                      `json:"status"`
+	Timestamp int64                       `json:"timestamp"`
+	Checks    map[string]DependencyStatus `json:"checks"`
+}
+
+// Ready reports whether every check passed
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+// Checker runs checks concurrently and caches the report
+type Checker struct {
+	checks  []Check
+	ttl     time.Duration
+	timeout time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	report  Report
+	checked time.Time
+}
+
+// NewChecker creates a checker whose reports are reused for ttl and whose checks each get
+// timeout. Non-positive values fall back to DefaultCacheTTL and DefaultCheckTimeout.
+func NewChecker(ttl, timeout time.Duration, checks ...Check) *Checker {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	return &Checker{
+		checks:  checks,
+		ttl:     ttl,
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+// Check returns the cached report, running every check again once it is older than the TTL.
+// Concurrent callers wait for one run rather than each starting their own.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked.IsZero() && c.now().Sub(c.checked) < c.ttl {
+		return c.report
+	}
+	// Detached from the request, so a poller hanging up doesn't cache a failed report
+	c.report = c.run(context.WithoutCancel(ctx))
+	c.checked = c.now()
+	return c.report
+}
+
+func (c *Checker) run(ctx context.Context) Report {
+	statuses := make([]DependencyStatus, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = c.runOne(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    StatusReady,
+		Timestamp: c.now().Unix(),
+		Checks:    make(map[string]DependencyStatus, len(c.checks)),
+	}
+	for i, check := range c.checks {
+		report.Checks[check.Name] = statuses[i]
+		if statuses[i].Status != StatusOK {
+			report.Status = StatusNotReady
+		}
+	}
+	return report
+}
+
+func (c *Checker) runOne(ctx context.Context, check Check) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := c.now()
+	detail, err := check.Run(ctx)
+	status := DependencyStatus{
+		Status:    StatusOK,
+		Detail:    detail,
+		LatencyMs: c.now().Sub(start).Milliseconds(),
+	}
+	if err != nil {
+		status.Status = StatusUnhealthy
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,161 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingCheck counts its runs and fails with err
+func countingCheck(name string, runs *atomic.Int32, err error) Check {
+	return Check{
+		Name: name,
+		Run: func(context.Context) (string, error) {
+			runs.Add(1)
+			return name + " detail", err
+		},
+	}
+}
+
+func TestCheckerReportsEachDependency(t *testing.T) {
+	var runs atomic.Int32
+	checker := NewChecker(time.Second, time.Second,
+		countingCheck("dynamodb", &runs, nil),
+		countingCheck("s3", &runs, errors.New("access denied")),
+	)
+
+	report := checker.Check(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, StatusNotReady, report.Status)
+	require.Equal(t, DependencyStatus{Status: StatusOK, Detail: "dynamodb detail", LatencyMs: report.Checks["dynamodb"].LatencyMs}, report.Checks["dynamodb"])
+	require.Equal(t, StatusUnhealthy, report.Checks["s3"].Status)
+	require.Equal(t, "access denied", report.Checks["s3"].Error)
+	require.Equal(t, int32(2), runs.Load())
+}
+
+func TestCheckerCachesReports(t *testing.T) {
+	var runs atomic.Int32
+	checker := NewChecker(5*time.Second, time.Second, countingCheck("dynamodb", &runs, nil))
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return now }
+
+	require.True(t, checker.Check(context.Background()).Ready())
+	now = now.Add(4 * time.Second)
+	checker.Check(context.Background())
+	require.Equal(t, int32(1), runs.Load(), "reports are reused within the TTL")
+
+	now = now.Add(time.Second)
+	checker.Check(context.Background())
+	require.Equal(t, int32(2), runs.Load())
+}
+
+func TestCheckerTimesOutSlowChecks(t *testing.T) {
+	slow := Check{
+		Name: "replicate",
+		Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+	report := NewChecker(time.Second, 10*time.Millisecond, slow).Check(context.Background())
+	require.Equal(t, StatusUnhealthy, report.Checks["replicate"].Status)
+	require.Contains(t, report.Checks["replicate"].Error, "deadline exceeded")
+}
+
+func TestCheckerIgnoresCanceledRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := NewChecker(time.Second, time.Second, Check{
+		Name: "dynamodb",
+		Run: func(ctx context.Context) (string, error) {
+			return "", ctx.Err()
+		},
+	}).Check(ctx)
+	require.True(t, report.Ready(), "a poller hanging up must not fail the cached report")
+}
+
+func TestCheckerWithoutChecksIsReady(t *testing.T) {
+	report := NewChecker(0, 0).Check(context.Background())
+	require.True(t, report.Ready())
+	require.Empty(t, report.Checks)
+}
+
+func TestParseVersion(t *testing.T) {
+	version, err := ParseVersion("ffmpeg", "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n")
+	require.NoError(t, err)
+	require.Equal(t, "6.1.1-3ubuntu5", version)
+
+	version, err = ParseVersion("ffprobe", "ffprobe version n7.0 Copyright (c) 2007-2024 the FFmpeg developers")
+	require.NoError(t, err)
+	require.Equal(t, "n7.0", version)
+
+	_, err = ParseVersion("ffprobe", "ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers")
+	require.Error(t, err, "the wrong tool answered")
+	_, err = ParseVersion("ffmpeg", "")
+	require.Error(t, err)
+}
+
+func TestBinary(t *testing.T) {
+	var ran []string
+	run := func(_ context.Context, name string, args ...string) ([]byte, error) {
+		ran = append(append(ran, name), args...)
+		return []byte("ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers\n"), nil
+	}
+	version, err := Binary("ffmpeg", run).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "6.1.1", version)
+	require.Equal(t, []string{"ffmpeg", "-version"}, ran)
+
+	missing := func(context.Context, string, ...string) ([]byte, error) {
+		return nil, errors.New("ffprobe not found in PATH")
+	}
+	_, err = Binary("ffprobe", missing).Run(context.Background())
+	require.ErrorContains(t, err, "not found")
+}
+
+type fakePinger struct{ err error }
+
+func (p fakePinger) HealthCheck(context.Context) error { return p.err }
+
+func TestPing(t *testing.T) {
+	detail, err := Ping("dynamodb", "omnigen-jobs", fakePinger{}).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "omnigen-jobs", detail)
+
+	_, err = Ping("s3", "omnigen-assets", fakePinger{err: errors.New("s3 health check failed: forbidden")}).Run(context.Background())
+	require.ErrorContains(t, err, "forbidden")
+}
+
+func TestReplicate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer r8_valid":
+			w.Write([]byte(`{"type":"organization","username":"omnigen"}`))
+		case "Bearer r8_throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	detail, err := Replicate(server.Client(), server.URL, "r8_valid").Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "authenticated", detail)
+
+	_, err = Replicate(server.Client(), server.URL, "r8_revoked").Run(context.Background())
+	require.ErrorContains(t, err, "token rejected")
+
+	_, err = Replicate(server.Client(), server.URL, "r8_throttled").Run(context.Background())
+	require.ErrorContains(t, err, "status 429")
+
+	_, err = Replicate(server.Client(), server.URL, "").Run(context.Background())
+	require.ErrorContains(t, err, "no API token")
+}
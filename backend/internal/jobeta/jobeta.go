@@ -0,0 +1,206 @@
+// Package jobeta estimates how long a running job has left. Each kind of pipeline step keeps a
+// rolling average of how long its runs took, per video model and job duration bucket, and a
+// job's estimate is the expected time of the steps it has still to run. Kinds nothing has timed
+// yet, as on a fresh server, use static estimates.
+package jobeta
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/jobprogress"
+)
+
+// Bounds every estimate is clamped to
+const (
+	MinSeconds = 5       // A job still running always has a little left, however long it overran
+	MaxSeconds = 30 * 60 // Past this an estimate says more about a stuck provider than the job
+)
+
+// window is how many runs a rolling average spans: the first window runs are averaged evenly,
+// and each later one moves the average 1/window of the way towards itself
+const window = 20
+
+// defaultSeconds is how long each kind of step is expected to take before any has been timed.
+// The scene estimate is for one clip.
+var defaultSeconds = map[string]float64{
+	jobprogress.StepScript:      20,
+	jobprogress.StepNarrator:    30,
+	jobprogress.StepScene:       90,
+	jobprogress.StepMusic:       60,
+	jobprogress.StepComposition: 45,
+}
+
+// plannedClipSeconds is the clip length scenes are counted by before the script says how many
+// there are
+const plannedClipSeconds = 8
+
+// Key names the rolling average a kind of step's runs for jobs of model and duration are kept
+// in, e.g. "scene/veo/16-30"
+func Key(kind, model string, duration int) string {
+	return kind + "/" + model + "/" + DurationBucket(duration)
+}
+
+// DurationBucket groups job durations in seconds whose steps take comparably long
+func DurationBucket(duration int) string {
+	switch {
+	case duration <= 15:
+		return "0-15"
+	case duration <= 30:
+		return "16-30"
+	default:
+		return "31-60"
+	}
+}
+
+// StepKind is the kind of a step named as in step stats: "scene_2" is a scene, and the other
+// steps are kinds of their own
+func StepKind(step string) string {
+	if _, ok := domain.SceneStepNumber(step); ok {
+		return jobprogress.StepScene
+	}
+	return step
+}
+
+// addSample returns timing with one more run of seconds averaged in
+func addSample(timing domain.StageTiming, seconds float64) domain.StageTiming {
+	n := min(timing.Samples+1, window)
+	return domain.StageTiming{
+		Seconds: timing.Seconds + (seconds-timing.Seconds)/float64(n),
+		Samples: timing.Samples + 1,
+	}
+}
+
+// Estimator keeps the rolling averages of step durations and estimates jobs from them. It is
+// safe for concurrent use.
+type Estimator struct {
+	mu      sync.Mutex
+	timings map[string]domain.StageTiming
+	changed bool
+}
+
+// NewEstimator creates an estimator that has timed nothing yet
+func NewEstimator() *Estimator {
+	return &Estimator{timings: make(map[string]domain.StageTiming)}
+}
+
+// Record averages a run of one of job's steps (named as in step stats) that took elapsed into
+// the timings
+func (e *Estimator) Record(job *domain.Job, step string, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	key := Key(StepKind(step), job.Model, job.Duration)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timings[key] = addSample(e.timings[key], elapsed.Seconds())
+	e.changed = true
+}
+
+// Load adds timings, e.g. ones stored by an earlier server, for the steps this estimator hasn't
+// timed itself
+func (e *Estimator) Load(timings map[string]domain.StageTiming) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, timing := range timings {
+		if _, ok := e.timings[key]; !ok && timing.Samples > 0 {
+			e.timings[key] = timing
+		}
+	}
+}
+
+// Changed returns a copy of the timings if a run was recorded since it last returned them
+func (e *Estimator) Changed() (map[string]domain.StageTiming, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.changed {
+		return nil, false
+	}
+	e.changed = false
+	timings := make(map[string]domain.StageTiming, len(e.timings))
+	for key, timing := range e.timings {
+		timings[key] = timing
+	}
+	return timings, true
+}
+
+// Estimate returns the seconds job has left, clamped to MinSeconds-MaxSeconds, as of the start
+// of the step it is on. ok is false for a job that isn't running its own steps: queued, waiting
+// for approval or for a variant parent's script, a parent of variants, or finished.
+func (e *Estimator) Estimate(job *domain.Job) (seconds int, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return remaining(job, func(kind string) float64 {
+		if timing, ok := e.timings[Key(kind, job.Model, job.Duration)]; ok {
+			return timing.Seconds
+		}
+		return defaultSeconds[kind]
+	})
+}
+
+// remaining sums the expected seconds of the steps job has still to run. The narrator and
+// music run together, so they count as long as the longer of them.
+func remaining(job *domain.Job, expected func(kind string) float64) (int, bool) {
+	if !running(job) {
+		return 0, false
+	}
+
+	var total, audio float64
+	for _, step := range jobprogress.Checklist(job) {
+		if step.Done {
+			continue
+		}
+		switch step.Kind {
+		case jobprogress.StepScene:
+			total += expected(step.Kind) * float64(pendingScenes(job, step))
+		case jobprogress.StepNarrator, jobprogress.StepMusic:
+			audio = max(audio, expected(step.Kind))
+		default:
+			total += expected(step.Kind)
+		}
+	}
+	return clamp(total + audio), true
+}
+
+// pendingScenes is how many clips a scene step of the checklist stands for: one, or every
+// scene of a job without a script yet, as planned or counted from its duration
+func pendingScenes(job *domain.Job, step jobprogress.Step) int {
+	if step.SceneNumber > 0 {
+		return 1
+	}
+	if n := len(job.ScenePlan); n > 0 {
+		return n
+	}
+	return max(1, int(math.Ceil(float64(job.Duration)/plannedClipSeconds)))
+}
+
+// running reports whether job is working through its own steps
+func running(job *domain.Job) bool {
+	if job.Status != domain.StatusProcessing || job.IsVariantParent() {
+		return false
+	}
+	switch job.Stage.Phase {
+	case domain.PhaseQueued, domain.PhaseScriptReady, domain.PhaseAwaitingScript,
+		domain.PhaseVariantsGenerating, domain.PhaseVariantsFinished:
+		return false
+	}
+	return true
+}
+
+// Countdown returns the seconds job has left now: its last estimate less the time since it was
+// made, which is the time spent on the step it is on. ok is false when the job has no estimate
+// or isn't processing.
+func Countdown(job *domain.Job, now time.Time) (seconds int, ok bool) {
+	if job.Status != domain.StatusProcessing || job.ETAUpdatedAt == 0 {
+		return 0, false
+	}
+	elapsed := now.Sub(time.Unix(job.ETAUpdatedAt, 0)).Seconds()
+	return clamp(float64(job.ETASeconds) - max(elapsed, 0)), true
+}
+
+// clamp rounds seconds up and holds it to MinSeconds-MaxSeconds
+func clamp(seconds float64) int {
+	return min(max(int(math.Ceil(seconds)), MinSeconds), MaxSeconds)
+}
@@ -0,0 +1,250 @@
+package jobeta
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func runningJob(scenes, completed int) *domain.Job {
+	return &domain.Job{
+		Status:          domain.StatusProcessing,
+		Stage:           domain.StageScriptComplete,
+		Model:           "veo",
+		Duration:        24,
+		Scenes:          make([]domain.Scene, scenes),
+		ScenesCompleted: completed,
+	}
+}
+
+// fixed expects every kind of step to take the seconds given, and anything else none
+func fixed(seconds map[string]float64) func(string) float64 {
+	return func(kind string) float64 { return seconds[kind] }
+}
+
+func TestAddSample(t *testing.T) {
+	var timing domain.StageTiming
+	for _, seconds := range []float64{10, 20, 30} {
+		timing = addSample(timing, seconds)
+	}
+	if timing.Samples != 3 || timing.Seconds != 20 {
+		t.Fatalf("first runs are averaged evenly: got %+v", timing)
+	}
+
+	// Once the window is full each run moves the average 1/window of the way
+	timing = domain.StageTiming{Seconds: 100, Samples: window}
+	timing = addSample(timing, 300)
+	if want := 100 + 200.0/window; math.Abs(timing.Seconds-want) > 1e-9 || timing.Samples != window+1 {
+		t.Fatalf("got %+v, want %.2fs over %d runs", timing, want, window+1)
+	}
+	for range 10 * window {
+		timing = addSample(timing, 300)
+	}
+	if math.Abs(timing.Seconds-300) > 1 {
+		t.Fatalf("the average follows a lasting change: got %.2fs", timing.Seconds)
+	}
+}
+
+func TestDurationBucket(t *testing.T) {
+	tests := map[int]string{0: "0-15", 15: "0-15", 16: "16-30", 30: "16-30", 31: "31-60", 60: "31-60"}
+	for duration, want := range tests {
+		if got := DurationBucket(duration); got != want {
+			t.Errorf("DurationBucket(%d) = %q, want %q", duration, got, want)
+		}
+	}
+	if got := Key("scene", "veo", 24); got != "scene/veo/16-30" {
+		t.Errorf("Key = %q", got)
+	}
+}
+
+func TestStepKind(t *testing.T) {
+	tests := map[string]string{"scene_3": "scene", "script": "script", "narrator": "narrator", "composition": "composition"}
+	for step, want := range tests {
+		if got := StepKind(step); got != want {
+			t.Errorf("StepKind(%q) = %q, want %q", step, got, want)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	expected := fixed(map[string]float64{"script": 20, "narrator": 30, "scene": 100, "music": 50, "composition": 40})
+
+	tests := []struct {
+		name string
+		job  func() *domain.Job
+		want int
+	}{
+		{
+			name: "scenes left",
+			job:  func() *domain.Job { return runningJob(3, 1) },
+			want: 2*100 + 50 + 40,
+		},
+		{
+			name: "narrator and music run together",
+			job: func() *domain.Job {
+				job := runningJob(2, 2)
+				job.Voice = "female"
+				job.SideEffectsText = "May cause dizziness."
+				return job
+			},
+			want: 50 + 40,
+		},
+		{
+			name: "audio done",
+			job: func() *domain.Job {
+				job := runningJob(2, 2)
+				job.Stage = domain.StageAudioComplete
+				job.AudioURL = "https://example.com/music.mp3"
+				return job
+			},
+			want: 40,
+		},
+		{
+			name: "scenes counted from the duration before the script",
+			job: func() *domain.Job {
+				job := runningJob(0, 0)
+				job.Stage = domain.StageScriptGenerating
+				return job
+			},
+			want: 20 + 3*100 + 50 + 40,
+		},
+		{
+			name: "scenes counted from the plan before the script",
+			job: func() *domain.Job {
+				job := runningJob(0, 0)
+				job.Stage = domain.StageScriptGenerating
+				job.ScenePlan = make([]domain.PlannedScene, 2)
+				return job
+			},
+			want: 20 + 2*100 + 50 + 40,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := remaining(tt.job(), expected)
+			if !ok || got != tt.want {
+				t.Fatalf("got %d, %v, want %d", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemaining_OnlyRunningJobs(t *testing.T) {
+	expected := fixed(map[string]float64{"scene": 100})
+	tests := map[string]func(*domain.Job){
+		"queued":          func(job *domain.Job) { job.Stage = domain.StageQueued },
+		"awaiting script": func(job *domain.Job) { job.Stage = domain.StageAwaitingScript },
+		"script ready":    func(job *domain.Job) { job.Stage = domain.StageScriptReady },
+		"variant parent":  func(job *domain.Job) { job.VariantJobIDs = []string{"job-variant-1"} },
+		"completed":       func(job *domain.Job) { job.Status = domain.StatusCompleted },
+		"failed":          func(job *domain.Job) { job.Status = domain.StatusFailed },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			job := runningJob(3, 1)
+			change(job)
+			if _, ok := remaining(job, expected); ok {
+				t.Fatal("expected no estimate")
+			}
+		})
+	}
+}
+
+func TestRemaining_Clamped(t *testing.T) {
+	job := runningJob(3, 1)
+	if got, _ := remaining(job, fixed(nil)); got != MinSeconds {
+		t.Errorf("nothing left to run: got %d, want %d", got, MinSeconds)
+	}
+	if got, _ := remaining(job, fixed(map[string]float64{"scene": 3600})); got != MaxSeconds {
+		t.Errorf("hours left: got %d, want %d", got, MaxSeconds)
+	}
+	if got, _ := remaining(job, fixed(map[string]float64{"composition": 30.2})); got != 31 {
+		t.Errorf("fractions round up: got %d, want 31", got)
+	}
+}
+
+func TestEstimator_FallsBackToDefaults(t *testing.T) {
+	e := NewEstimator()
+	job := runningJob(2, 1)
+
+	got, ok := e.Estimate(job)
+	want := int(defaultSeconds["scene"] + defaultSeconds["music"] + defaultSeconds["composition"])
+	if !ok || got != want {
+		t.Fatalf("cold start: got %d, %v, want %d", got, ok, want)
+	}
+
+	e.Record(job, "scene_1", 40*time.Second)
+	got, _ = e.Estimate(job)
+	if want := int(40 + defaultSeconds["music"] + defaultSeconds["composition"]); got != want {
+		t.Fatalf("timed scene: got %d, want %d", got, want)
+	}
+
+	other := runningJob(2, 1)
+	other.Model = "kling"
+	got, _ = e.Estimate(other)
+	if want := int(defaultSeconds["scene"] + defaultSeconds["music"] + defaultSeconds["composition"]); got != want {
+		t.Fatalf("timings are kept per model: got %d, want %d", got, want)
+	}
+}
+
+func TestEstimator_RecordLoadChanged(t *testing.T) {
+	e := NewEstimator()
+	if _, changed := e.Changed(); changed {
+		t.Fatal("nothing recorded yet")
+	}
+
+	job := runningJob(2, 0)
+	e.Record(job, "scene_2", 0)
+	if _, changed := e.Changed(); changed {
+		t.Fatal("runs without a duration aren't recorded")
+	}
+
+	e.Record(job, "scene_2", 60*time.Second)
+	e.Record(job, "scene_1", 80*time.Second)
+	timings, changed := e.Changed()
+	if !changed {
+		t.Fatal("expected the recorded runs")
+	}
+	if got := timings["scene/veo/16-30"]; got.Seconds != 70 || got.Samples != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	if _, changed := e.Changed(); changed {
+		t.Fatal("timings already returned are unchanged")
+	}
+
+	e.Load(map[string]domain.StageTiming{
+		"scene/veo/16-30":       {Seconds: 500, Samples: 40},
+		"composition/veo/16-30": {Seconds: 10, Samples: 3},
+		"music/veo/16-30":       {},
+	})
+	got, _ := e.Estimate(job)
+	if want := 2*70 + int(defaultSeconds["music"]) + 10; got != want {
+		t.Fatalf("loaded timings fill in what wasn't timed here: got %d, want %d", got, want)
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	job := runningJob(2, 1)
+	if _, ok := Countdown(job, now); ok {
+		t.Fatal("no estimate made yet")
+	}
+
+	job.ETASeconds, job.ETAUpdatedAt = 120, now.Add(-45*time.Second).Unix()
+	if got, ok := Countdown(job, now); !ok || got != 75 {
+		t.Fatalf("got %d, %v, want 75", got, ok)
+	}
+	if got, _ := Countdown(job, now.Add(10*time.Minute)); got != MinSeconds {
+		t.Fatalf("an overrunning step: got %d, want %d", got, MinSeconds)
+	}
+	if got, _ := Countdown(job, now.Add(-time.Hour)); got != 120 {
+		t.Fatalf("a clock behind the estimate's: got %d, want 120", got)
+	}
+
+	job.Status = domain.StatusCompleted
+	if _, ok := Countdown(job, now); ok {
+		t.Fatal("finished jobs have nothing left")
+	}
+}
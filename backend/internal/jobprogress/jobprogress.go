@@ -0,0 +1,110 @@
+// Package jobprogress computes how far a job's pipeline has got from what the pipeline stored
+// on the job: its script, completed scenes, narrator and music URLs and final video. Steps that
+// don't apply to a job (the narrator of a job without a voice) carry no weight, and steps are
+// counted complete whatever order they finished in.
+package jobprogress
+
+import "github.com/omnigen/backend/internal/domain"
+
+// Step kinds, in pipeline order
+const (
+	StepScript      = "script"
+	StepNarrator    = "narrator"
+	StepScene       = "scene"
+	StepMusic       = "music"
+	StepComposition = "composition"
+)
+
+// Relative weights of the steps. The scene weight is shared by all of a job's scenes.
+const (
+	scriptWeight      = 5.0
+	narratorWeight    = 5.0
+	scenesWeight      = 70.0
+	musicWeight       = 5.0
+	compositionWeight = 15.0
+)
+
+// Step is one item of a job's checklist
+type Step struct {
+	Kind        string
+	SceneNumber int // 1-based for StepScene; 0 for the single scene step of a job without a script yet
+	Done        bool
+
+	// Progress is the job's percentage once this and every earlier step is done
+	Progress int
+
+	weight float64
+}
+
+// Checklist lists the steps that apply to a job, in pipeline order
+func Checklist(job *domain.Job) []Step {
+	steps := []Step{{Kind: StepScript, Done: len(job.Scenes) > 0, weight: scriptWeight}}
+
+	if needsNarrator(job) {
+		steps = append(steps, Step{Kind: StepNarrator, Done: job.NarratorAudioURL != "", weight: narratorWeight})
+	}
+
+	// Until the script is written the number of scenes isn't known
+	if n := len(job.Scenes); n == 0 {
+		steps = append(steps, Step{Kind: StepScene, weight: scenesWeight})
+	} else {
+		for i := 1; i <= n; i++ {
+			steps = append(steps, Step{Kind: StepScene, SceneNumber: i, Done: i <= job.ScenesCompleted, weight: scenesWeight / float64(n)})
+		}
+	}
+
+	steps = append(steps,
+		Step{Kind: StepMusic, Done: job.AudioURL != "", weight: musicWeight},
+		Step{Kind: StepComposition, Done: job.Status == domain.StatusCompleted, weight: compositionWeight},
+	)
+
+	var total float64
+	for _, step := range steps {
+		total += step.weight
+	}
+	var cumulative float64
+	for i := range steps {
+		cumulative += steps[i].weight
+		steps[i].Progress = int(100 * cumulative / total)
+	}
+	return steps
+}
+
+// Percent is a job's progress: the weight of its completed steps as a percentage of the steps
+// that apply. Only a completed job reaches 100.
+//
+// Steps are only ever marked done within a run, and a step that turns out not to apply (a
+// script without narration) only raises the percentage, so it doesn't decrease while a job
+// runs. Requeueing a failed job clears its progress.
+func Percent(job *domain.Job) int {
+	if job.Status == domain.StatusCompleted {
+		return 100
+	}
+
+	var done, total float64
+	for _, step := range Checklist(job) {
+		total += step.weight
+		if step.Done {
+			done += step.weight
+		}
+	}
+	return min(int(100*done/total), 99)
+}
+
+// Monotonic returns current unless it is below previous, for callers reporting one job's
+// progress repeatedly
+func Monotonic(previous, current int) int {
+	return max(previous, current)
+}
+
+// needsNarrator reports whether the pipeline will narrate the job. Before the script exists a
+// voice is enough; afterwards the script must have narration or the job side effects to read.
+func needsNarrator(job *domain.Job) bool {
+	if job.Voice == "" {
+		return false
+	}
+	if len(job.Scenes) == 0 {
+		return true
+	}
+	return job.AudioSpec.NarratorScript != "" || job.SideEffectsText != ""
+}
@@ -0,0 +1,214 @@
+package jobprogress
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func scriptedJob(scenes int) *domain.Job {
+	return &domain.Job{
+		Status: domain.StatusProcessing,
+		Stage:  domain.StageScriptComplete,
+		Scenes: make([]domain.Scene, scenes),
+	}
+}
+
+func pharmaJob(scenes int) *domain.Job {
+	job := scriptedJob(scenes)
+	job.Voice = "female"
+	job.SideEffectsText = "May cause dizziness."
+	return job
+}
+
+func kinds(steps []Step) []string {
+	out := make([]string, len(steps))
+	for i, step := range steps {
+		out[i] = step.Kind
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshots runs updates against a job in order, returning the percentage after each
+func snapshots(job *domain.Job, updates ...func(*domain.Job)) []int {
+	percents := []int{Percent(job)}
+	for _, update := range updates {
+		update(job)
+		percents = append(percents, Percent(job))
+	}
+	return percents
+}
+
+func requireNonDecreasing(t *testing.T, percents []int) {
+	t.Helper()
+	for i := 1; i < len(percents); i++ {
+		if percents[i] < percents[i-1] {
+			t.Fatalf("progress went backwards: %v", percents)
+		}
+	}
+}
+
+func TestChecklist_Steps(t *testing.T) {
+	tests := []struct {
+		name  string
+		job   *domain.Job
+		kinds []string
+	}{
+		{"new job", &domain.Job{Status: domain.StatusProcessing}, []string{StepScript, StepScene, StepMusic, StepComposition}},
+		{"new job with a voice", &domain.Job{Status: domain.StatusProcessing, Voice: "male"}, []string{StepScript, StepNarrator, StepScene, StepMusic, StepComposition}},
+		{"non-pharma", scriptedJob(2), []string{StepScript, StepScene, StepScene, StepMusic, StepComposition}},
+		{"pharma", pharmaJob(2), []string{StepScript, StepNarrator, StepScene, StepScene, StepMusic, StepComposition}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := Checklist(tt.job)
+			if got := kinds(steps); !equal(got, tt.kinds) {
+				t.Errorf("steps = %v, want %v", got, tt.kinds)
+			}
+			if last := steps[len(steps)-1].Progress; last != 100 {
+				t.Errorf("last step progress = %d, want 100", last)
+			}
+		})
+	}
+
+	// A voice without narration or side effects isn't narrated
+	job := scriptedJob(2)
+	job.Voice = "male"
+	if got := kinds(Checklist(job)); equal(got, tests[3].kinds) {
+		t.Errorf("unnarrated script listed a narrator: %v", got)
+	}
+}
+
+func TestPercent_NonPharma(t *testing.T) {
+	job := &domain.Job{Status: domain.StatusProcessing, Stage: domain.StageScriptGenerating}
+	percents := snapshots(job,
+		func(j *domain.Job) { j.Scenes = make([]domain.Scene, 2); j.Stage = domain.StageScriptComplete },
+		func(j *domain.Job) { j.Stage = domain.SceneGenerating(1) },
+		func(j *domain.Job) { j.ScenesCompleted = 1; j.Stage = domain.SceneComplete(1) },
+		func(j *domain.Job) { j.ScenesCompleted = 2; j.Stage = domain.SceneComplete(2) },
+		func(j *domain.Job) { j.AudioURL = "s3://assets/music.mp3"; j.Stage = domain.StageAudioComplete },
+		func(j *domain.Job) { j.Stage = domain.StageComposing },
+		func(j *domain.Job) { j.Status = domain.StatusCompleted; j.Stage = domain.StageComplete },
+	)
+
+	want := []int{0, 5, 5, 42, 78, 84, 84, 100}
+	if !equalInts(percents, want) {
+		t.Errorf("progress = %v, want %v", percents, want)
+	}
+}
+
+func TestPercent_PharmaNarrator(t *testing.T) {
+	job := pharmaJob(2)
+	if got := Percent(job); got != 5 {
+		t.Errorf("script done = %d, want 5", got)
+	}
+
+	job.ScenesCompleted = 2
+	withoutNarrator := Percent(job)
+	job.NarratorAudioURL = "s3://assets/narrator.mp3"
+	if got := Percent(job); got <= withoutNarrator {
+		t.Errorf("narrator didn't count: %d then %d", withoutNarrator, got)
+	}
+
+	job.AudioURL = "s3://assets/music.mp3"
+	if got := Percent(job); got != 85 {
+		t.Errorf("everything but composition = %d, want 85", got)
+	}
+}
+
+func TestPercent_AudioBeforeScenes(t *testing.T) {
+	// Audio finishing while scenes still generate counts at once, and the stage catching up later doesn't undo it
+	job := pharmaJob(3)
+	percents := snapshots(job,
+		func(j *domain.Job) { j.ScenesCompleted = 1; j.Stage = domain.SceneGenerating(2) },
+		func(j *domain.Job) { j.AudioURL = "s3://assets/music.mp3" },
+		func(j *domain.Job) { j.NarratorAudioURL = "s3://assets/narrator.mp3" },
+		func(j *domain.Job) { j.ScenesCompleted = 3; j.Stage = domain.SceneComplete(3) },
+		func(j *domain.Job) { j.Stage = domain.StageAudioComplete },
+		func(j *domain.Job) { j.Stage = domain.StageComposing },
+	)
+	requireNonDecreasing(t, percents)
+	if percents[2] <= percents[1] {
+		t.Errorf("music finishing mid-scenes didn't count: %v", percents)
+	}
+	if last := percents[len(percents)-1]; last != 85 {
+		t.Errorf("composing = %d, want 85", last)
+	}
+}
+
+func TestPercent_ResumedJob(t *testing.T) {
+	// A queued approved preview kept its script
+	job := pharmaJob(3)
+	job.Status = domain.StatusQueued
+	job.Stage = domain.StageQueued
+	if got := Percent(job); got != 5 {
+		t.Errorf("queued preview = %d, want 5", got)
+	}
+
+	// A job resumed from its stored script picks up from what was stored
+	job.Status = domain.StatusProcessing
+	job.Stage = domain.SceneGenerating(3)
+	job.ScenesCompleted = 2
+	job.NarratorAudioURL = "s3://assets/narrator.mp3"
+	percents := snapshots(job,
+		func(j *domain.Job) { j.ScenesCompleted = 3 },
+		func(j *domain.Job) { j.AudioURL = "s3://assets/music.mp3" },
+		func(j *domain.Job) { j.Status = domain.StatusCompleted },
+	)
+	requireNonDecreasing(t, percents)
+	if percents[0] != 56 {
+		t.Errorf("resumed at %d, want 56", percents[0])
+	}
+
+	// A new job queued behind others hasn't started
+	if got := Percent(&domain.Job{Status: domain.StatusQueued, Stage: domain.StageQueued}); got != 0 {
+		t.Errorf("queued new job = %d, want 0", got)
+	}
+}
+
+func TestPercent_OnlyCompletedJobsReachFull(t *testing.T) {
+	job := scriptedJob(1)
+	job.ScenesCompleted = 1
+	job.AudioURL = "s3://assets/music.mp3"
+	job.Stage = domain.StageComplete
+	if got := Percent(job); got != 84 {
+		t.Errorf("uncomposed job = %d, want 84", got)
+	}
+	job.Status = domain.StatusFailed
+	if got := Percent(job); got != 84 {
+		t.Errorf("failed job = %d, want 84", got)
+	}
+}
+
+func TestMonotonic(t *testing.T) {
+	if got := Monotonic(40, 25); got != 40 {
+		t.Errorf("Monotonic(40, 25) = %d", got)
+	}
+	if got := Monotonic(40, 55); got != 55 {
+		t.Errorf("Monotonic(40, 55) = %d", got)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
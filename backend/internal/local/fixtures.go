@@ -0,0 +1,155 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// fixtureSizes maps aspect ratios to the frame size of sample clips; small frames keep
+// rendering and composition fast
+var fixtureSizes = map[string]string{
+	"16:9": "640x360",
+	"9:16": "360x640",
+	"1:1":  "480x480",
+}
+
+// Fixtures implements adapters.MockMedia with sample clips and tracks rendered by ffmpeg the
+// first time each length is requested. Files are cached in dir and served over HTTP on a
+// loopback port, so the pipeline downloads them exactly as it would Replicate output.
+type Fixtures struct {
+	dir      string
+	listener net.Listener
+	server   *http.Server
+	logger   *zap.Logger
+
+	mu sync.Mutex // serializes rendering so concurrent scenes don't render the same file twice
+}
+
+// StartFixtures serves the fixtures cached in dir, creating it if needed
+func StartFixtures(dir string, logger *zap.Logger) (*Fixtures, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for fixtures: %w", err)
+	}
+
+	f := &Fixtures{
+		dir:      dir,
+		listener: listener,
+		server:   &http.Server{Handler: http.FileServer(http.Dir(dir))},
+		logger:   logger,
+	}
+	go func() {
+		if err := f.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Fixture server stopped", zap.Error(err))
+		}
+	}()
+	return f, nil
+}
+
+// Close stops serving fixtures; cached files are kept for the next run
+func (f *Fixtures) Close() error {
+	return f.server.Close()
+}
+
+// VideoURL returns the URL of a sample clip of the given length and aspect ratio
+func (f *Fixtures) VideoURL(ctx context.Context, seconds int, aspectRatio string) (string, error) {
+	size, ok := fixtureSizes[aspectRatio]
+	if !ok {
+		return "", fmt.Errorf("unsupported aspect ratio %q", aspectRatio)
+	}
+	if seconds <= 0 {
+		return "", fmt.Errorf("clip length must be positive, got %d", seconds)
+	}
+
+	name := fmt.Sprintf("clip-%ds-%s.mp4", seconds, size)
+	duration := strconv.Itoa(seconds)
+	err := f.render(ctx, name,
+		"-f", "lavfi", "-i", "testsrc2=size="+size+":rate=24:duration="+duration,
+		"-f", "lavfi", "-i", "sine=frequency=220:sample_rate=48000:duration="+duration,
+		"-c:v", "libx264", "-preset", "ultrafast", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-shortest", "-movflags", "+faststart",
+	)
+	if err != nil {
+		return "", err
+	}
+	return f.url(name), nil
+}
+
+// AudioURL returns the URL of a sample music track of the given length
+func (f *Fixtures) AudioURL(ctx context.Context, seconds int) (string, error) {
+	if seconds <= 0 {
+		return "", fmt.Errorf("track length must be positive, got %d", seconds)
+	}
+	name := fmt.Sprintf("music-%ds.mp3", seconds)
+	if err := f.renderTone(ctx, name, 440, strconv.Itoa(seconds)); err != nil {
+		return "", err
+	}
+	return f.url(name), nil
+}
+
+// Audio returns a sample voiceover of the given length, to a tenth of a second
+func (f *Fixtures) Audio(ctx context.Context, seconds float64) ([]byte, error) {
+	if seconds <= 0 {
+		return nil, fmt.Errorf("audio length must be positive, got %v", seconds)
+	}
+	duration := strconv.FormatFloat(seconds, 'f', 1, 64)
+	name := "voice-" + duration + "s.mp3"
+	if err := f.renderTone(ctx, name, 660, duration); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(f.dir, name))
+}
+
+// renderTone renders a sine tone of duration seconds as an MP3
+func (f *Fixtures) renderTone(ctx context.Context, name string, frequency int, duration string) error {
+	return f.render(ctx, name,
+		"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=%d:sample_rate=44100:duration=%s", frequency, duration),
+		"-c:a", "libmp3lame", "-b:a", "96k",
+	)
+}
+
+// render runs ffmpeg with args to create name in the fixture directory unless it already exists
+func (f *Fixtures) render(ctx context.Context, name string, args ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dest := filepath.Join(f.dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+
+	// Render beside the destination and rename, so a killed render never leaves a truncated fixture
+	tmp := filepath.Join(f.dir, ".render-"+name)
+	args = append([]string{"-y", "-v", "error"}, args...)
+	args = append(args, "-f", strings.TrimPrefix(filepath.Ext(name), "."), tmp)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to render fixture %s: %w (%s)", name, err, strings.TrimSpace(string(output)))
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save fixture %s: %w", name, err)
+	}
+	f.logger.Debug("Rendered local fixture", zap.String("name", name))
+	return nil
+}
+
+// url returns the address of the fixture called name
+func (f *Fixtures) url(name string) string {
+	return "http://" + f.listener.Addr().String() + "/" + name
+}
@@ -0,0 +1,151 @@
+// Package local assembles the in-process stand-ins used by ENVIRONMENT=local: an in-memory
+// DynamoDB, a filesystem-backed S3, a static-token JWT validator and mock model adapters. The
+// full generation pipeline, including ffmpeg composition, runs against them without AWS
+// credentials or a Replicate token.
+package local
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/auth"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// DefaultDevToken is the bearer token the local JWT validator accepts when none is configured
+const DefaultDevToken = "local-dev-token"
+
+// DevClaims returns the claims of the local development user. The user ID matches the mock
+// user the API injects on /api/v1 outside production, so /auth/me and the admin routes see the
+// same user as the job routes.
+func DevClaims() *domain.UserClaims {
+	return &domain.UserClaims{
+		Sub:              "dev-user-123",
+		Email:            "dev@localhost",
+		EmailVerified:    true,
+		CognitoUsername:  "dev",
+		Name:             "Local Developer",
+		SubscriptionTier: "pro",
+		Groups:           []string{"admin"},
+		TokenUse:         "id",
+	}
+}
+
+// Config configures the local backends
+type Config struct {
+	DataDir              string        // S3 objects and rendered fixtures are kept here between runs
+	S3Addr               string        // host:port the local S3 listens on; port 0 picks a free one
+	DevToken             string        // Bearer token accepted by the JWT validator; empty uses DefaultDevToken
+	MockDelay            time.Duration // How long each mock video, music and TTS call takes
+	AssetsBucket         string
+	JobTable             string
+	UsageTable           string
+	IdempotencyTable     string
+	ScriptsTable         string
+	AssetsTable          string
+	JobEventsTable       string
+	JobLocksTable        string
+	UserBucketsTable     string
+	PreferencesTable     string
+	BrandGuidelinesTable string
+	Upload               repository.UploadOptions
+}
+
+// Backends are the local stand-ins for the API's AWS, Cognito and Replicate dependencies
+type Backends struct {
+	JobRepo         *repository.DynamoDBRepository
+	UsageRepo       *repository.DynamoDBUsageRepository
+	IdempotencyRepo repository.IdempotencyRepository
+	ScriptRepo      repository.ScriptsRepository
+	AssetRepo       repository.AssetsRepository
+	JobEventRepo    repository.JobEventsRepository
+	JobLockRepo     repository.JobLocksRepository
+	UserBucketRepo  repository.UserBucketsRepository
+	PreferencesRepo repository.PreferencesRepository
+	BrandRepo       repository.BrandGuidelinesRepository
+	S3Service       *repository.S3AssetRepository
+	JWTValidator    *auth.JWTValidator
+	ScriptGenerator adapters.ScriptGenerator
+	AdapterFactory  *adapters.AdapterFactory
+	MusicAdapter    adapters.MusicGenerator
+	TTSAdapter      adapters.TTSAdapter
+
+	s3       *repository.LocalS3
+	fixtures *Fixtures
+}
+
+// Start starts the local S3 and fixture servers and creates the local backends. Jobs and
+// usage are kept in memory and lost on exit.
+func Start(cfg Config, logger *zap.Logger) (*Backends, error) {
+	if cfg.DevToken == "" {
+		cfg.DevToken = DefaultDevToken
+	}
+
+	s3, err := repository.StartLocalS3(filepath.Join(cfg.DataDir, "s3"), cfg.S3Addr, logger)
+	if err != nil {
+		return nil, err
+	}
+	fixtures, err := StartFixtures(filepath.Join(cfg.DataDir, "fixtures"), logger)
+	if err != nil {
+		s3.Close()
+		return nil, err
+	}
+
+	dynamo := repository.NewLocalDynamoDB()
+	b := &Backends{
+		JobRepo:   dynamo.JobRepository(cfg.JobTable, logger),
+		UsageRepo: dynamo.UsageRepository(cfg.UsageTable, logger),
+		S3Service: repository.NewS3Service(s3.Client(), cfg.AssetsBucket, cfg.Upload,
+			repository.PresignCacheOptions{Disabled: true}, logger),
+		JWTValidator:    auth.NewStaticJWTValidator(cfg.DevToken, DevClaims(), logger),
+		ScriptGenerator: adapters.NewMockScriptGenerator(logger),
+		AdapterFactory:  adapters.NewMockAdapterFactory(fixtures, cfg.MockDelay, logger),
+		MusicAdapter:    adapters.NewMockMusicAdapter(fixtures, cfg.MockDelay, logger),
+		TTSAdapter:      adapters.NewMockTTSAdapter(fixtures, cfg.MockDelay, logger),
+		s3:              s3,
+		fixtures:        fixtures,
+	}
+	if cfg.IdempotencyTable != "" {
+		b.IdempotencyRepo = dynamo.IdempotencyRepository(cfg.IdempotencyTable, logger)
+	}
+	if cfg.ScriptsTable != "" {
+		b.ScriptRepo = dynamo.ScriptRepository(cfg.ScriptsTable, logger)
+	}
+	if cfg.AssetsTable != "" {
+		b.AssetRepo = dynamo.AssetRepository(cfg.AssetsTable, logger)
+	}
+	if cfg.JobEventsTable != "" {
+		b.JobEventRepo = dynamo.JobEventRepository(cfg.JobEventsTable, logger)
+	}
+	if cfg.JobLocksTable != "" {
+		b.JobLockRepo = dynamo.JobLockRepository(cfg.JobLocksTable, logger)
+	}
+	if cfg.UserBucketsTable != "" {
+		b.UserBucketRepo = dynamo.UserBucketRepository(cfg.UserBucketsTable, logger)
+	}
+	if cfg.PreferencesTable != "" {
+		b.PreferencesRepo = dynamo.PreferencesRepository(cfg.PreferencesTable, logger)
+	}
+	if cfg.BrandGuidelinesTable != "" {
+		b.BrandRepo = dynamo.BrandGuidelinesRepository(cfg.BrandGuidelinesTable, logger)
+	}
+
+	logger.Info("Local backends started",
+		zap.String("data_dir", cfg.DataDir),
+		zap.String("s3_url", s3.URL()),
+	)
+	return b, nil
+}
+
+// Close stops the local S3 and fixture servers
+func (b *Backends) Close() error {
+	fixturesErr := b.fixtures.Close()
+	if err := b.s3.Close(); err != nil {
+		return fmt.Errorf("failed to stop local S3: %w", err)
+	}
+	return fixturesErr
+}
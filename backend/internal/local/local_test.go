@@ -0,0 +1,79 @@
+package local
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStart_BackendsNeedNoCredentials(t *testing.T) {
+	ctx := context.Background()
+	backends, err := Start(Config{
+		DataDir:      t.TempDir(),
+		S3Addr:       "127.0.0.1:0",
+		AssetsBucket: "assets",
+		JobTable:     "jobs",
+		UsageTable:   "usage",
+	}, zap.NewNop())
+	require.NoError(t, err)
+	defer backends.Close()
+
+	require.NoError(t, backends.JobRepo.HealthCheck(ctx))
+	require.NoError(t, backends.S3Service.HealthCheck(ctx))
+	require.Nil(t, backends.IdempotencyRepo, "no idempotency table was configured")
+	require.Nil(t, backends.ScriptRepo, "no scripts table was configured")
+
+	claims, err := backends.JWTValidator.ValidateToken(DefaultDevToken)
+	require.NoError(t, err)
+	require.Equal(t, "dev-user-123", claims.Sub)
+	require.True(t, claims.IsIDToken())
+
+	// Callers get their own copy of the claims
+	claims.Groups[0] = "changed"
+	claims, err = backends.JWTValidator.ValidateToken(DefaultDevToken)
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin"}, claims.Groups)
+
+	_, err = backends.JWTValidator.ValidateToken("not-the-dev-token")
+	require.Error(t, err)
+}
+
+func TestFixtures_RenderOnceAndServe(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	fixtures, err := StartFixtures(dir, zap.NewNop())
+	require.NoError(t, err)
+	defer fixtures.Close()
+
+	url, err := fixtures.VideoURL(ctx, 4, "9:16")
+	require.NoError(t, err)
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A second request reuses the rendered file
+	rendered, err := os.Stat(filepath.Join(dir, "clip-4s-360x640.mp4"))
+	require.NoError(t, err)
+	_, err = fixtures.VideoURL(ctx, 4, "9:16")
+	require.NoError(t, err)
+	again, err := os.Stat(filepath.Join(dir, "clip-4s-360x640.mp4"))
+	require.NoError(t, err)
+	require.Equal(t, rendered.ModTime(), again.ModTime())
+
+	audio, err := fixtures.Audio(ctx, 2.5)
+	require.NoError(t, err)
+	require.NotEmpty(t, audio)
+
+	_, err = fixtures.VideoURL(ctx, 4, "4:3")
+	require.Error(t, err)
+}
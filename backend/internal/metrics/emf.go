@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// privateDimensions are never written, whatever a caller passes: they identify users and
+// would create a CloudWatch metric per job
+var privateDimensions = []string{"user_id", "job_id"}
+
+// EMF writes each measurement as a CloudWatch Embedded Metric Format log line. Lines reaching
+// CloudWatch Logs (the ECS awslogs driver ships stdout) are turned into metrics by CloudWatch.
+type EMF struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+	static    Dimensions // Added to every measurement, e.g. the environment
+	now       func() time.Time
+}
+
+// NewEMF creates a recorder writing EMF lines for namespace to w
+func NewEMF(w io.Writer, namespace string, static Dimensions) *EMF {
+	return &EMF{w: w, namespace: namespace, static: static, now: time.Now}
+}
+
+func (e *EMF) Counter(name string, delta float64, dims Dimensions) {
+	e.write(name, delta, UnitCount, dims)
+}
+
+func (e *EMF) Histogram(name string, value float64, unit Unit, dims Dimensions) {
+	e.write(name, value, unit, dims)
+}
+
+func (e *EMF) Gauge(name string, value float64, unit Unit, dims Dimensions) {
+	e.write(name, value, unit, dims)
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+func (e *EMF) write(name string, value float64, unit Unit, dims Dimensions) {
+	all := maps.Clone(e.static)
+	if all == nil {
+		all = make(Dimensions, len(dims))
+	}
+	maps.Copy(all, dims)
+	for _, private := range privateDimensions {
+		delete(all, private)
+	}
+	delete(all, name) // The metric's own value lives under its name
+	keys := slices.Sorted(maps.Keys(all))
+
+	line := make(map[string]interface{}, len(all)+2)
+	for key, v := range all {
+		line[key] = v
+	}
+	line[name] = value
+	line["_aws"] = emfMetadata{
+		Timestamp: e.now().UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  e.namespace,
+			Dimensions: [][]string{keys},
+			Metrics:    []emfMetric{{Name: name, Unit: unit}},
+		}},
+	}
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return // Only a NaN or Inf value fails to encode; dropping it beats failing a job
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(encoded, '\n'))
+}
@@ -0,0 +1,90 @@
+// Package metrics records pipeline measurements behind a small interface, so the backend
+// (CloudWatch EMF in production, nothing by default) is chosen at startup
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// Metric names
+const (
+	StageDuration     = "pipeline.stage_duration"      // Milliseconds per pipeline stage (dims: stage, model, duration_bucket)
+	JobOutcome        = "pipeline.job_outcome"         // Jobs reaching a terminal status (dims: outcome, failure_stage, model, duration_bucket)
+	PredictionLatency = "replicate.prediction_latency" // Milliseconds from submission to a terminal status (dims: adapter, outcome)
+	PredictionPolls   = "replicate.prediction_polls"   // Status checks per prediction (dims: adapter, outcome)
+	CommandDuration   = "ffmpeg.duration"              // Milliseconds per ffmpeg/ffprobe run (dims: tool, operation)
+	CommandExit       = "ffmpeg.exit"                  // ffmpeg/ffprobe runs by exit code (dims: tool, operation, exit_code)
+	TmpReclaimed      = "tmp.reclaimed_bytes"          // Bytes of orphaned job working directories deleted per janitor sweep
+)
+
+// Unit is the CloudWatch unit of a measurement
+type Unit string
+
+const (
+	UnitNone         Unit = "None"
+	UnitCount        Unit = "Count"
+	UnitMilliseconds Unit = "Milliseconds"
+	UnitBytes        Unit = "Bytes"
+)
+
+// Dimensions label a measurement. Every distinct combination is a separate CloudWatch metric,
+// so values must come from small fixed sets: never user or job IDs.
+type Dimensions map[string]string
+
+// Recorder receives measurements. Implementations must be safe for concurrent use and must
+// not block the pipeline.
+type Recorder interface {
+	// Counter adds delta to a running count
+	Counter(name string, delta float64, dims Dimensions)
+	// Histogram records one observation of a distribution, such as a duration
+	Histogram(name string, value float64, unit Unit, dims Dimensions)
+	// Gauge records the current value of a level, such as a queue depth
+	Gauge(name string, value float64, unit Unit, dims Dimensions)
+}
+
+// Nop discards every measurement
+type Nop struct{}
+
+func (Nop) Counter(string, float64, Dimensions)         {}
+func (Nop) Histogram(string, float64, Unit, Dimensions) {}
+func (Nop) Gauge(string, float64, Unit, Dimensions)     {}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context whose measurements go to rec
+func WithRecorder(ctx context.Context, rec Recorder) context.Context {
+	if rec == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+// FromContext returns the context's recorder, or Nop when none was set
+func FromContext(ctx context.Context) Recorder {
+	if rec, ok := ctx.Value(recorderKey{}).(Recorder); ok {
+		return rec
+	}
+	return Nop{}
+}
+
+// Since records the milliseconds elapsed since start as a histogram observation
+func Since(rec Recorder, name string, start time.Time, dims Dimensions) {
+	rec.Histogram(name, float64(time.Since(start).Milliseconds()), UnitMilliseconds, dims)
+}
+
+// DurationBucket groups a requested video duration (seconds) for use as a dimension
+func DurationBucket(seconds int) string {
+	switch {
+	case seconds <= 15:
+		return "0-15s"
+	case seconds <= 30:
+		return "16-30s"
+	case seconds <= 45:
+		return "31-45s"
+	case seconds <= 60:
+		return "46-60s"
+	default:
+		return "60s+"
+	}
+}
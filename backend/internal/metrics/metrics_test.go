@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContextDefaultsToNop(t *testing.T) {
+	require.Equal(t, Nop{}, FromContext(context.Background()))
+	require.Equal(t, Nop{}, FromContext(WithRecorder(context.Background(), nil)))
+
+	emf := NewEMF(&bytes.Buffer{}, "OmniGen", nil)
+	require.Same(t, emf, FromContext(WithRecorder(context.Background(), emf)))
+}
+
+func TestDurationBucket(t *testing.T) {
+	cases := map[int]string{10: "0-15s", 15: "0-15s", 16: "16-30s", 30: "16-30s", 45: "31-45s", 60: "46-60s", 90: "60s+"}
+	for seconds, bucket := range cases {
+		require.Equal(t, bucket, DurationBucket(seconds), "%d seconds", seconds)
+	}
+}
+
+func decodeEMF(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded))
+		lines = append(lines, decoded)
+	}
+	return lines
+}
+
+func TestEMFWritesOneLinePerMeasurement(t *testing.T) {
+	var out bytes.Buffer
+	emf := NewEMF(&out, "OmniGen", Dimensions{"environment": "production"})
+	emf.now = func() time.Time { return time.UnixMilli(1735689600123) }
+
+	emf.Histogram(StageDuration, 1500, UnitMilliseconds, Dimensions{"stage": "scene", "model": "veo"})
+	emf.Counter(JobOutcome, 1, Dimensions{"outcome": "completed"})
+
+	lines := decodeEMF(t, &out)
+	require.Len(t, lines, 2)
+
+	stage := lines[0]
+	require.Equal(t, 1500.0, stage[StageDuration])
+	require.Equal(t, "scene", stage["stage"])
+	require.Equal(t, "production", stage["environment"])
+	require.Equal(t, map[string]interface{}{
+		"Timestamp": 1735689600123.0,
+		"CloudWatchMetrics": []interface{}{map[string]interface{}{
+			"Namespace":  "OmniGen",
+			"Dimensions": []interface{}{[]interface{}{"environment", "model", "stage"}},
+			"Metrics":    []interface{}{map[string]interface{}{"Name": StageDuration, "Unit": "Milliseconds"}},
+		}},
+	}, stage["_aws"])
+
+	outcome := lines[1]
+	require.Equal(t, 1.0, outcome[JobOutcome])
+	require.Contains(t, out.String(), `"Metrics":[{"Name":"pipeline.job_outcome","Unit":"Count"}]`)
+}
+
+func TestEMFNeverWritesUserIDs(t *testing.T) {
+	var out bytes.Buffer
+	NewEMF(&out, "OmniGen", nil).Counter(JobOutcome, 1, Dimensions{"outcome": "failed", "user_id": "user123", "job_id": "job456"})
+
+	line := decodeEMF(t, &out)[0]
+	require.NotContains(t, line, "user_id")
+	require.NotContains(t, line, "job_id")
+	require.NotContains(t, out.String(), "user123")
+}
@@ -0,0 +1,205 @@
+// Package moderation screens the user's prompt and the generated scene prompts for content the
+// video models' safety filters reject, so a job fails before credits are spent on its script or
+// clips instead of at a late scene with an opaque provider error.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// Denylist categories the video models reject that general moderation doesn't flag
+const (
+	CategoryMedicationBrand   = "medication_brand"   // Real medication brand names shown in the imagery
+	CategoryCelebrityLikeness = "celebrity_likeness" // Real people's likenesses
+)
+
+// Where a flag came from
+const (
+	SourceDenylist = "denylist"
+	SourceOpenAI   = "openai"
+)
+
+// DefaultDenylist holds "category:term" entries for terms the video models are known to reject
+var DefaultDenylist = []string{
+	CategoryMedicationBrand + ":ozempic",
+	CategoryMedicationBrand + ":wegovy",
+	CategoryMedicationBrand + ":viagra",
+	CategoryMedicationBrand + ":cialis",
+	CategoryMedicationBrand + ":xanax",
+	CategoryMedicationBrand + ":adderall",
+	CategoryMedicationBrand + ":oxycontin",
+	CategoryMedicationBrand + ":humira",
+	CategoryCelebrityLikeness + ":celebrity likeness",
+	CategoryCelebrityLikeness + ":lookalike",
+	CategoryCelebrityLikeness + ":look-alike",
+}
+
+// DefaultPharmaAllowlist is medical terminology pharmaceutical ads need, which general
+// moderation can read as self-harm, sexual or drug content
+var DefaultPharmaAllowlist = []string{
+	"suicidal thoughts",
+	"suicidal behavior",
+	"self-injection",
+	"injection",
+	"syringe",
+	"needle",
+	"overdose",
+	"erectile dysfunction",
+	"sexual side effects",
+	"prescription",
+	"dosage",
+	"blood pressure",
+}
+
+// ErrUnavailable wraps a failed moderation request; callers decide whether to go on without it
+var ErrUnavailable = errors.New("moderation unavailable")
+
+// FlaggedError is returned for a prompt that would be rejected
+type FlaggedError struct {
+	Flag domain.ModerationFlag
+}
+
+func (e *FlaggedError) Error() string {
+	where := "prompt"
+	if e.Flag.SceneNumber > 0 {
+		where = fmt.Sprintf("scene %d", e.Flag.SceneNumber)
+	}
+	if e.Flag.Term != "" {
+		return fmt.Sprintf("%s flagged by %s moderation (%s: %q)", where, e.Flag.Source, e.Flag.Category, e.Flag.Term)
+	}
+	return fmt.Sprintf("%s flagged by %s moderation (%s)", where, e.Flag.Source, e.Flag.Category)
+}
+
+// Client is a remote moderation service
+type Client interface {
+	Moderate(ctx context.Context, inputs []string) ([]adapters.ModerationResult, error)
+}
+
+// Config sets the local rules a Checker applies alongside its client
+type Config struct {
+	Denylist        []string // "category:term" entries, case-insensitive; empty uses DefaultDenylist
+	PharmaAllowlist []string // Case-insensitive terms ignored in pharmaceutical jobs; empty uses DefaultPharmaAllowlist
+}
+
+// deniedTerm is a compiled denylist entry
+type deniedTerm struct {
+	category string
+	term     string
+	pattern  *regexp.Regexp
+}
+
+// Checker screens prompts with the denylist, then the client
+type Checker struct {
+	client Client // nil applies only the denylist
+	deny   []deniedTerm
+	allow  []*regexp.Regexp
+}
+
+// NewChecker compiles cfg's rules; a denylist entry without a category is an error
+func NewChecker(client Client, cfg Config) (*Checker, error) {
+	entries := cfg.Denylist
+	if len(entries) == 0 {
+		entries = DefaultDenylist
+	}
+	allowlist := cfg.PharmaAllowlist
+	if len(allowlist) == 0 {
+		allowlist = DefaultPharmaAllowlist
+	}
+
+	c := &Checker{client: client}
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		category, term, ok := strings.Cut(entry, ":")
+		category, term = strings.TrimSpace(category), strings.ToLower(strings.TrimSpace(term))
+		if !ok || category == "" || term == "" {
+			return nil, fmt.Errorf("invalid moderation denylist entry %q (expected category:term)", entry)
+		}
+		c.deny = append(c.deny, deniedTerm{category: category, term: term, pattern: termPattern(term)})
+	}
+	for _, term := range allowlist {
+		if term = strings.TrimSpace(term); term != "" {
+			c.allow = append(c.allow, termPattern(term))
+		}
+	}
+	return c, nil
+}
+
+// termPattern matches term as whole words, ignoring case
+func termPattern(term string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
+}
+
+// CheckPrompt screens the user's prompt. Returns a *FlaggedError when it would be rejected, or
+// an error wrapping ErrUnavailable when the client failed.
+func (c *Checker) CheckPrompt(ctx context.Context, prompt string, pharmaceutical bool) error {
+	return c.check(ctx, []string{prompt}, pharmaceutical, func(int) int { return 0 })
+}
+
+// CheckScenes screens the scenes' generation prompts in one client request, reporting the first
+// scene that would be rejected
+func (c *Checker) CheckScenes(ctx context.Context, scenes []domain.Scene, pharmaceutical bool) error {
+	prompts := make([]string, len(scenes))
+	for i, scene := range scenes {
+		prompts[i] = scene.GenerationPrompt
+	}
+	return c.check(ctx, prompts, pharmaceutical, func(i int) int { return scenes[i].SceneNumber })
+}
+
+// check screens texts, numbering a flag with sceneNumber of the flagged text's index
+func (c *Checker) check(ctx context.Context, texts []string, pharmaceutical bool, sceneNumber func(int) int) error {
+	screened := make([]string, len(texts))
+	for i, text := range texts {
+		screened[i] = text
+		if pharmaceutical {
+			for _, allowed := range c.allow {
+				screened[i] = allowed.ReplaceAllString(screened[i], " ")
+			}
+		}
+	}
+
+	// The denylist is local, so it's applied before paying a round trip for the client
+	for i, text := range screened {
+		for _, denied := range c.deny {
+			if denied.pattern.MatchString(text) {
+				return &FlaggedError{Flag: domain.ModerationFlag{
+					SceneNumber: sceneNumber(i),
+					Category:    denied.category,
+					Source:      SourceDenylist,
+					Term:        denied.term,
+				}}
+			}
+		}
+	}
+
+	if c.client == nil {
+		return nil
+	}
+	results, err := c.client.Moderate(ctx, screened)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	for i, result := range results {
+		if !result.Flagged {
+			continue
+		}
+		category := "flagged"
+		if len(result.Categories) > 0 {
+			category = result.Categories[0]
+		}
+		return &FlaggedError{Flag: domain.ModerationFlag{
+			SceneNumber: sceneNumber(i),
+			Category:    category,
+			Source:      SourceOpenAI,
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// fakeClient flags inputs containing one of its words, recording every request
+type fakeClient struct {
+	flag     map[string]string // word -> category
+	err      error
+	requests [][]string
+}
+
+func (f *fakeClient) Moderate(ctx context.Context, inputs []string) ([]adapters.ModerationResult, error) {
+	f.requests = append(f.requests, inputs)
+	if f.err != nil {
+		return nil, f.err
+	}
+	results := make([]adapters.ModerationResult, len(inputs))
+	for i, input := range inputs {
+		for word, category := range f.flag {
+			if strings.Contains(strings.ToLower(input), word) {
+				results[i] = adapters.ModerationResult{Flagged: true, Categories: []string{category}}
+			}
+		}
+	}
+	return results, nil
+}
+
+func flagOf(t *testing.T, err error) domain.ModerationFlag {
+	t.Helper()
+	var flagged *FlaggedError
+	if !errors.As(err, &flagged) {
+		t.Fatalf("error = %v, want a *FlaggedError", err)
+	}
+	return flagged.Flag
+}
+
+func TestNewChecker_InvalidDenylist(t *testing.T) {
+	for _, entry := range []string{"ozempic", ":ozempic", "medication_brand:"} {
+		if _, err := NewChecker(nil, Config{Denylist: []string{entry}}); err == nil {
+			t.Errorf("NewChecker(%q) expected error, got nil", entry)
+		}
+	}
+}
+
+func TestCheckPrompt(t *testing.T) {
+	client := &fakeClient{flag: map[string]string{"gore": "violence/graphic"}}
+	c, err := NewChecker(client, Config{})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if err := c.CheckPrompt(ctx, "A sunny ad for a new running shoe", false); err != nil {
+		t.Errorf("CheckPrompt(clean) error = %v", err)
+	}
+
+	got := flagOf(t, c.CheckPrompt(ctx, "A gore-soaked horror trailer", false))
+	want := domain.ModerationFlag{Category: "violence/graphic", Source: SourceOpenAI}
+	if got != want {
+		t.Errorf("flag = %+v, want %+v", got, want)
+	}
+
+	requests := len(client.requests)
+	got = flagOf(t, c.CheckPrompt(ctx, "Close-up of an OZEMPIC pen", false))
+	want = domain.ModerationFlag{Category: CategoryMedicationBrand, Source: SourceDenylist, Term: "ozempic"}
+	if got != want {
+		t.Errorf("flag = %+v, want %+v", got, want)
+	}
+	if len(client.requests) != requests {
+		t.Error("a denylisted prompt was still sent to the client")
+	}
+}
+
+func TestCheckScenes_ReportsFlaggedScene(t *testing.T) {
+	c, err := NewChecker(&fakeClient{flag: map[string]string{"blood": "violence/graphic"}}, Config{})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	scenes := []domain.Scene{
+		{SceneNumber: 1, GenerationPrompt: "A runner at dawn on an empty beach"},
+		{SceneNumber: 2, GenerationPrompt: "A runner falls, blood on the pavement"},
+		{SceneNumber: 3, GenerationPrompt: "A celebrity look-alike holds the shoe"},
+	}
+
+	// The denylist runs first, so scene 3's term is found before the client sees scene 2
+	got := flagOf(t, c.CheckScenes(context.Background(), scenes, false))
+	if got.SceneNumber != 3 || got.Category != CategoryCelebrityLikeness {
+		t.Errorf("flag = %+v, want scene 3's celebrity likeness", got)
+	}
+
+	got = flagOf(t, c.CheckScenes(context.Background(), scenes[:2], false))
+	if got.SceneNumber != 2 || got.Source != SourceOpenAI {
+		t.Errorf("flag = %+v, want scene 2 flagged by the client", got)
+	}
+}
+
+func TestCheck_PharmaAllowlist(t *testing.T) {
+	client := &fakeClient{flag: map[string]string{"suicid": "self-harm", "injection": "self-harm"}}
+	c, err := NewChecker(client, Config{})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	prompt := "Patient learns the self-injection pen; may cause suicidal thoughts"
+
+	if err := c.CheckPrompt(context.Background(), prompt, false); err == nil {
+		t.Error("CheckPrompt(non-pharma) expected a flag, got nil")
+	}
+	if err := c.CheckPrompt(context.Background(), prompt, true); err != nil {
+		t.Errorf("CheckPrompt(pharma) error = %v, want medical terms allowed", err)
+	}
+
+	// Allowed terms are only ignored, so the rest of the prompt is still screened
+	if err := c.CheckPrompt(context.Background(), "A syringe next to a Humira box", true); err == nil {
+		t.Error("CheckPrompt(pharma, brand name) expected a flag, got nil")
+	}
+}
+
+func TestCheck_CustomLists(t *testing.T) {
+	c, err := NewChecker(nil, Config{
+		Denylist:        []string{"medication_brand: Zyntra "},
+		PharmaAllowlist: []string{"Zyntra XR"},
+	})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if err := c.CheckPrompt(ctx, "Ozempic on a shelf", false); err != nil {
+		t.Errorf("CheckPrompt() error = %v, want the defaults replaced", err)
+	}
+	if err := c.CheckPrompt(ctx, "A Zyntra bottle", false); err == nil {
+		t.Error("CheckPrompt(custom term) expected a flag, got nil")
+	}
+	if err := c.CheckPrompt(ctx, "zyntra xr tablets by the window", true); err != nil {
+		t.Errorf("CheckPrompt(custom allowlist) error = %v", err)
+	}
+	if err := c.CheckPrompt(ctx, "Zyntrax energy drink", false); err != nil {
+		t.Errorf("CheckPrompt(longer word) error = %v, want whole words only", err)
+	}
+}
+
+func TestCheck_ClientUnavailable(t *testing.T) {
+	client := &fakeClient{err: errors.New("connection refused")}
+	c, err := NewChecker(client, Config{})
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+
+	err = c.CheckPrompt(context.Background(), "A sunny ad for a new running shoe", false)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("CheckPrompt() error = %v, want ErrUnavailable", err)
+	}
+	if !reflect.DeepEqual(client.requests, [][]string{{"A sunny ad for a new running shoe"}}) {
+		t.Errorf("requests = %v", client.requests)
+	}
+}
@@ -0,0 +1,171 @@
+package pricing
+
+import "github.com/omnigen/backend/internal/adapters"
+
+// narratorCharsPerSecond is how much narration TTS speaks per second of video: about 2.5
+// words a second, the narration cap, at six characters a word including the space
+const narratorCharsPerSecond = 15
+
+// secondsRange is how long a pipeline step takes, from a quick run to a slow one
+type secondsRange struct{ min, max int }
+
+// Wall-clock time of each pipeline step, as seen in production. Scenes are generated one
+// after another (each starts from the last frame of the one before); narration and music
+// are generated alongside them, so they add nothing.
+var (
+	scriptSeconds      = secondsRange{10, 45}
+	compositionSeconds = secondsRange{20, 90}
+	clipSeconds        = map[adapters.AdapterType]secondsRange{
+		adapters.AdapterTypeVeo:   {60, 180},
+		adapters.AdapterTypeKling: {90, 300},
+	}
+)
+
+// Line item names
+const (
+	ItemVideoSeconds = "video_seconds" // Per-second rate of the model
+	ItemScenes       = "scenes"        // Fee per clip: the generation call, last-frame extraction and upload
+	ItemScript       = "script"        // GPT-4o script writing; included
+	ItemNarration    = "narration"     // Narrator TTS; included
+	ItemMusic        = "music"         // Background music; included
+)
+
+// Plan is what a job will do, as far as it is known before its script is written
+type Plan struct {
+	Duration    int // Seconds
+	Model       adapters.AdapterType
+	Narration   bool // A narrator voiceover is generated
+	SideEffects int  // Characters of side effects, which the narrator reads verbatim
+	Music       bool // Background music is generated
+	Variants    int  // A/B variants, each generated and charged as its own job; <= 1 is a single job
+	Preview     bool // The job stops after the script for review; it is charged in full when approved
+}
+
+// LineItem is one part of a job's price
+type LineItem struct {
+	Name        string `json:"name"`         // One of the Item constants
+	Quantity    int    `json:"quantity"`     // In units of Unit
+	Unit        string `json:"unit"`         // second, scene, request or character
+	CreditsEach int    `json:"credits_each"` // 0 for parts included in the price
+	Credits     int    `json:"credits"`
+}
+
+// SceneEstimate is the price of one planned scene
+type SceneEstimate struct {
+	Scene    int `json:"scene"`
+	Duration int `json:"duration"` // Seconds
+	Credits  int `json:"credits"`
+}
+
+// WallClock is how long a job is expected to take once it starts
+type WallClock struct {
+	MinSeconds int `json:"min_seconds"`
+	MaxSeconds int `json:"max_seconds"`
+}
+
+// Estimate is the itemized price of a job. Credits is what each job is charged; TotalCredits
+// covers every variant.
+type Estimate struct {
+	Cost          JobCost         `json:"cost"` // As charged per job
+	Scenes        []SceneEstimate `json:"scenes"`
+	LineItems     []LineItem      `json:"line_items"`
+	TTSCharacters int             `json:"tts_characters"`
+	MusicSeconds  int             `json:"music_seconds"`
+	WallClock     WallClock       `json:"wall_clock"` // Variants generate side by side, so this is per job
+	Jobs          int             `json:"jobs"`       // Jobs charged: one, or one per variant
+	TotalCredits  int             `json:"total_credits"`
+}
+
+// EstimateJob itemizes the price of a planned job. It uses the same scene count and QuoteJob
+// the usage service charges with, and calls nothing external.
+func EstimateJob(plan Plan) Estimate {
+	scenes := EstimateSceneCount(plan.Duration, plan.Model)
+	cost := QuoteJob(plan.Duration, scenes, plan.Model)
+	jobs := max(plan.Variants, 1)
+
+	estimate := Estimate{
+		Cost: cost,
+		LineItems: []LineItem{
+			{Name: ItemVideoSeconds, Quantity: plan.Duration, Unit: "second", CreditsEach: cost.CreditsPerSecond, Credits: plan.Duration * cost.CreditsPerSecond},
+			{Name: ItemScenes, Quantity: scenes, Unit: "scene", CreditsEach: cost.CreditsPerScene, Credits: scenes * cost.CreditsPerScene},
+			{Name: ItemScript, Quantity: 1, Unit: "request"},
+		},
+		Jobs:         jobs,
+		TotalCredits: cost.Credits * jobs,
+	}
+	for i, seconds := range planSceneDurations(plan.Duration, scenes, plan.Model) {
+		estimate.Scenes = append(estimate.Scenes, SceneEstimate{
+			Scene:    i + 1,
+			Duration: seconds,
+			Credits:  SceneCredits(seconds, plan.Model),
+		})
+	}
+
+	if plan.Narration {
+		estimate.TTSCharacters = max(plan.Duration*narratorCharsPerSecond, plan.SideEffects)
+		estimate.LineItems = append(estimate.LineItems, LineItem{Name: ItemNarration, Quantity: estimate.TTSCharacters, Unit: "character"})
+	}
+	if plan.Music {
+		estimate.MusicSeconds = plan.Duration
+		estimate.LineItems = append(estimate.LineItems, LineItem{Name: ItemMusic, Quantity: plan.Duration, Unit: "second"})
+	}
+
+	estimate.WallClock = WallClock{MinSeconds: scriptSeconds.min, MaxSeconds: scriptSeconds.max}
+	if !plan.Preview {
+		clip, ok := clipSeconds[plan.Model]
+		if !ok {
+			clip = clipSeconds[adapters.DefaultAdapterType]
+		}
+		estimate.WallClock.MinSeconds += scenes*clip.min + compositionSeconds.min
+		estimate.WallClock.MaxSeconds += scenes*clip.max + compositionSeconds.max
+	}
+	return estimate
+}
+
+// planSceneDurations splits duration into scenes of the model's clip lengths, longest first.
+// When no split fits exactly, the last scene takes the remainder so the total still matches.
+func planSceneDurations(duration, scenes int, model adapters.AdapterType) []int {
+	lengths := adapters.ClipDurations(model)
+	if scenes <= 0 {
+		return nil
+	}
+
+	// fits[n][t] reports whether n clips can add up to t seconds
+	fits := make([][]bool, scenes+1)
+	for n := range fits {
+		fits[n] = make([]bool, max(duration, 0)+1)
+	}
+	fits[0][0] = true
+	for n := 1; n <= scenes; n++ {
+		for t := 0; t <= duration; t++ {
+			for _, d := range lengths {
+				if d <= t && fits[n-1][t-d] {
+					fits[n][t] = true
+					break
+				}
+			}
+		}
+	}
+
+	durations := make([]int, 0, scenes)
+	if duration >= 0 && fits[scenes][duration] {
+		remaining := duration
+		for n := scenes; n > 0; n-- {
+			best := 0
+			for _, d := range lengths {
+				if d <= remaining && fits[n-1][remaining-d] {
+					best = max(best, d)
+				}
+			}
+			durations = append(durations, best)
+			remaining -= best
+		}
+		return durations
+	}
+
+	each := duration / scenes
+	for n := 1; n < scenes; n++ {
+		durations = append(durations, each)
+	}
+	return append(durations, duration-each*(scenes-1))
+}
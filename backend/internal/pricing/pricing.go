@@ -0,0 +1,66 @@
+// Package pricing prices generation jobs in credits. The usage service charges jobs with
+// QuoteJob and POST /generate/estimate itemizes the same quote, so an estimate is exactly
+// what the job will be charged.
+package pricing
+
+import "github.com/omnigen/backend/internal/adapters"
+
+// Credit pricing: every second of video costs the model's rate, plus a fixed fee per scene
+// for the clip generation call, last-frame extraction and upload
+const creditsPerScene = 5
+
+// modelCreditsPerSecond is the per-second rate of each video model
+var modelCreditsPerSecond = map[adapters.AdapterType]int{
+	adapters.AdapterTypeVeo:   2,
+	adapters.AdapterTypeKling: 1,
+}
+
+// JobCost is the credit price of a job and how it was computed
+type JobCost struct {
+	Model            string `json:"model"`
+	Duration         int    `json:"duration"` // Seconds
+	Scenes           int    `json:"scenes"`
+	CreditsPerSecond int    `json:"credits_per_second"`
+	CreditsPerScene  int    `json:"credits_per_scene"`
+	Credits          int    `json:"credits"`
+}
+
+// EstimateSceneCount is the scene count a job is priced for before its script exists:
+// the fewest clips of the model's longest length that cover the duration
+func EstimateSceneCount(duration int, model adapters.AdapterType) int {
+	longest := 0
+	for _, d := range adapters.ClipDurations(model) {
+		longest = max(longest, d)
+	}
+	if longest == 0 || duration <= 0 {
+		return 1
+	}
+	return (duration + longest - 1) / longest
+}
+
+// QuoteJob prices a job of duration seconds with the given scene count and model
+func QuoteJob(duration, scenes int, model adapters.AdapterType) JobCost {
+	perSecond := creditsPerSecond(model)
+	return JobCost{
+		Model:            string(model),
+		Duration:         duration,
+		Scenes:           scenes,
+		CreditsPerSecond: perSecond,
+		CreditsPerScene:  creditsPerScene,
+		Credits:          duration*perSecond + scenes*creditsPerScene,
+	}
+}
+
+// SceneCredits is the share of a job's price one scene of seconds on model costs: its seconds
+// at the model's rate and the scene fee. A job's scenes add up to its QuoteJob price.
+func SceneCredits(seconds int, model adapters.AdapterType) int {
+	return seconds*creditsPerSecond(model) + creditsPerScene
+}
+
+// creditsPerSecond is model's per-second rate, or the default model's for an unknown one
+func creditsPerSecond(model adapters.AdapterType) int {
+	if perSecond, ok := modelCreditsPerSecond[model]; ok {
+		return perSecond
+	}
+	return modelCreditsPerSecond[adapters.DefaultAdapterType]
+}
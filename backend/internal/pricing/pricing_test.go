@@ -0,0 +1,162 @@
+package pricing
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+)
+
+func TestQuoteJob(t *testing.T) {
+	tests := []struct {
+		duration int
+		model    adapters.AdapterType
+		scenes   int
+		credits  int
+	}{
+		{duration: 30, model: adapters.AdapterTypeVeo, scenes: 4, credits: 30*2 + 4*5},
+		{duration: 10, model: adapters.AdapterTypeVeo, scenes: 2, credits: 10*2 + 2*5},
+		{duration: 30, model: adapters.AdapterTypeKling, scenes: 3, credits: 30*1 + 3*5},
+		{duration: 60, model: adapters.AdapterTypeKling, scenes: 6, credits: 60*1 + 6*5},
+	}
+
+	for _, tt := range tests {
+		scenes := EstimateSceneCount(tt.duration, tt.model)
+		if scenes != tt.scenes {
+			t.Errorf("EstimateSceneCount(%d, %s) = %d, want %d", tt.duration, tt.model, scenes, tt.scenes)
+		}
+		cost := QuoteJob(tt.duration, scenes, tt.model)
+		if cost.Credits != tt.credits {
+			t.Errorf("QuoteJob(%d, %d, %s).Credits = %d, want %d", tt.duration, scenes, tt.model, cost.Credits, tt.credits)
+		}
+	}
+}
+
+func TestEstimateJob(t *testing.T) {
+	tests := []struct {
+		name          string
+		plan          Plan
+		scenes        []int // Planned scene durations
+		credits       int   // Per job
+		totalCredits  int
+		ttsCharacters int
+		musicSeconds  int
+		wallClock     WallClock
+	}{
+		{
+			name:         "short veo ad without narration",
+			plan:         Plan{Duration: 10, Model: adapters.AdapterTypeVeo, Music: true},
+			scenes:       []int{6, 4},
+			credits:      10*2 + 2*5,
+			totalCredits: 30,
+			musicSeconds: 10,
+			wallClock:    WallClock{MinSeconds: 10 + 2*60 + 20, MaxSeconds: 45 + 2*180 + 90},
+		},
+		{
+			name:          "60 second veo ad with narration",
+			plan:          Plan{Duration: 60, Model: adapters.AdapterTypeVeo, Narration: true, Music: true},
+			scenes:        []int{8, 8, 8, 8, 8, 8, 8, 4},
+			credits:       60*2 + 8*5,
+			totalCredits:  160,
+			ttsCharacters: 60 * 15,
+			musicSeconds:  60,
+			wallClock:     WallClock{MinSeconds: 10 + 8*60 + 20, MaxSeconds: 45 + 8*180 + 90},
+		},
+		{
+			name:          "pharma kling ad with long side effects",
+			plan:          Plan{Duration: 30, Model: adapters.AdapterTypeKling, Narration: true, SideEffects: 600, Music: true},
+			scenes:        []int{10, 10, 10},
+			credits:       30*1 + 3*5,
+			totalCredits:  45,
+			ttsCharacters: 600,
+			musicSeconds:  30,
+			wallClock:     WallClock{MinSeconds: 10 + 3*90 + 20, MaxSeconds: 45 + 3*300 + 90},
+		},
+		{
+			name:          "pharma veo ad with short side effects",
+			plan:          Plan{Duration: 20, Model: adapters.AdapterTypeVeo, Narration: true, SideEffects: 120, Music: true},
+			scenes:        []int{8, 8, 4},
+			credits:       20*2 + 3*5,
+			totalCredits:  55,
+			ttsCharacters: 20 * 15,
+			musicSeconds:  20,
+			wallClock:     WallClock{MinSeconds: 10 + 3*60 + 20, MaxSeconds: 45 + 3*180 + 90},
+		},
+		{
+			name:         "kling variants are each charged",
+			plan:         Plan{Duration: 15, Model: adapters.AdapterTypeKling, Music: true, Variants: 3},
+			scenes:       []int{10, 5},
+			credits:      15*1 + 2*5,
+			totalCredits: 3 * 25,
+			musicSeconds: 15,
+			wallClock:    WallClock{MinSeconds: 10 + 2*90 + 20, MaxSeconds: 45 + 2*300 + 90},
+		},
+		{
+			name:         "preview stops after the script but is charged in full",
+			plan:         Plan{Duration: 30, Model: adapters.AdapterTypeVeo, Preview: true},
+			scenes:       []int{8, 8, 8, 6},
+			credits:      30*2 + 4*5,
+			totalCredits: 80,
+			wallClock:    WallClock{MinSeconds: 10, MaxSeconds: 45},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimateJob(tt.plan)
+
+			// The estimate is exactly what the usage service charges
+			charged := QuoteJob(tt.plan.Duration, EstimateSceneCount(tt.plan.Duration, tt.plan.Model), tt.plan.Model)
+			if estimate.Cost != charged {
+				t.Errorf("Cost = %+v, want the charged quote %+v", estimate.Cost, charged)
+			}
+			if estimate.Cost.Credits != tt.credits || estimate.TotalCredits != tt.totalCredits {
+				t.Errorf("credits = %d (total %d), want %d (total %d)", estimate.Cost.Credits, estimate.TotalCredits, tt.credits, tt.totalCredits)
+			}
+
+			var durations []int
+			sceneCredits := 0
+			for _, scene := range estimate.Scenes {
+				durations = append(durations, scene.Duration)
+				sceneCredits += scene.Credits
+			}
+			if !slices.Equal(durations, tt.scenes) {
+				t.Errorf("scene durations = %v, want %v", durations, tt.scenes)
+			}
+			itemCredits := 0
+			for _, item := range estimate.LineItems {
+				itemCredits += item.Credits
+			}
+			if sceneCredits != tt.credits || itemCredits != tt.credits {
+				t.Errorf("scenes add up to %d and line items to %d, want %d", sceneCredits, itemCredits, tt.credits)
+			}
+
+			if estimate.TTSCharacters != tt.ttsCharacters || estimate.MusicSeconds != tt.musicSeconds {
+				t.Errorf("tts = %d chars, music = %ds; want %d, %ds", estimate.TTSCharacters, estimate.MusicSeconds, tt.ttsCharacters, tt.musicSeconds)
+			}
+			if estimate.WallClock != tt.wallClock {
+				t.Errorf("WallClock = %+v, want %+v", estimate.WallClock, tt.wallClock)
+			}
+		})
+	}
+}
+
+func TestPlanSceneDurations(t *testing.T) {
+	tests := []struct {
+		duration int
+		scenes   int
+		model    adapters.AdapterType
+		want     []int
+	}{
+		{duration: 24, scenes: 3, model: adapters.AdapterTypeVeo, want: []int{8, 8, 8}},
+		{duration: 18, scenes: 3, model: adapters.AdapterTypeVeo, want: []int{8, 6, 4}},
+		{duration: 60, scenes: 6, model: adapters.AdapterTypeKling, want: []int{10, 10, 10, 10, 10, 10}},
+		// No split of whole clips fits, so the last scene takes the remainder
+		{duration: 11, scenes: 2, model: adapters.AdapterTypeKling, want: []int{5, 6}},
+	}
+	for _, tt := range tests {
+		if got := planSceneDurations(tt.duration, tt.scenes, tt.model); !slices.Equal(got, tt.want) {
+			t.Errorf("planSceneDurations(%d, %d, %s) = %v, want %v", tt.duration, tt.scenes, tt.model, got, tt.want)
+		}
+	}
+}
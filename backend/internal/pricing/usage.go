@@ -0,0 +1,78 @@
+package pricing
+
+import (
+	"sort"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// StepUsage is the time and credits a pipeline step took
+type StepUsage struct {
+	LatencySeconds   float64 `json:"latency_seconds"`
+	Retries          int     `json:"retries"`
+	Credits          int     `json:"credits"`
+	PromptTokens     int     `json:"prompt_tokens,omitempty"` // LLM tokens, for the script and narration
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+}
+
+// SceneUsage is the time and credits of one scene's clip
+type SceneUsage struct {
+	Scene int `json:"scene"`
+	StepUsage
+}
+
+// Usage is where a job's credits, time and LLM tokens went, from the steps that have finished
+// so far. Scripts, narration, music and composition are included in the scene prices, so they
+// show time but no credits.
+type Usage struct {
+	Scenes       []SceneUsage `json:"scenes"` // In scene order
+	Script       StepUsage    `json:"script"`
+	Narration    StepUsage    `json:"narration"`
+	Music        StepUsage    `json:"music"`
+	Composition  StepUsage    `json:"composition"`
+	TotalCredits int          `json:"total_credits"`
+	TotalTokens  int          `json:"total_tokens"` // LLM prompt and completion tokens
+}
+
+// SummarizeUsage itemizes a job's step stats. Steps it doesn't know are counted in the total
+// only.
+func SummarizeUsage(steps map[string]domain.StepStats) Usage {
+	usage := Usage{Scenes: []SceneUsage{}}
+	for step, stats := range steps {
+		item := StepUsage{
+			LatencySeconds:   stats.LatencySeconds,
+			Retries:          stats.Retries,
+			Credits:          stats.Credits,
+			PromptTokens:     stats.PromptTokens,
+			CompletionTokens: stats.CompletionTokens,
+		}
+		usage.TotalCredits += stats.Credits
+		usage.TotalTokens += stats.PromptTokens + stats.CompletionTokens
+
+		switch step {
+		case domain.StepScript:
+			usage.Script = item
+		case domain.StepNarrator:
+			usage.Narration = item
+		case domain.StepMusic:
+			usage.Music = item
+		case domain.StepComposition:
+			usage.Composition = item
+		default:
+			if scene, ok := domain.SceneStepNumber(step); ok {
+				usage.Scenes = append(usage.Scenes, SceneUsage{Scene: scene, StepUsage: item})
+			}
+		}
+	}
+	sort.Slice(usage.Scenes, func(i, j int) bool { return usage.Scenes[i].Scene < usage.Scenes[j].Scene })
+	return usage
+}
+
+// TotalCredits is the credits of every step in steps
+func TotalCredits(steps map[string]domain.StepStats) int {
+	total := 0
+	for _, stats := range steps {
+		total += stats.Credits
+	}
+	return total
+}
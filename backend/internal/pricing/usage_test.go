@@ -0,0 +1,78 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func TestSceneCreditsAddUpToQuote(t *testing.T) {
+	for _, model := range []adapters.AdapterType{adapters.AdapterTypeVeo, adapters.AdapterTypeKling, "unknown"} {
+		durations := []int{8, 8, 6}
+		total := 0
+		for _, seconds := range durations {
+			total += SceneCredits(seconds, model)
+		}
+		if quote := QuoteJob(22, len(durations), model); total != quote.Credits {
+			t.Errorf("%s scenes add up to %d credits, QuoteJob charges %d", model, total, quote.Credits)
+		}
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	usage := SummarizeUsage(map[string]domain.StepStats{
+		domain.SceneStep(2):    {LatencySeconds: 95.5, Polls: 40, Credits: 21},
+		domain.SceneStep(1):    {LatencySeconds: 120, Polls: 52, Retries: 1, Credits: 21},
+		domain.SceneStep(10):   {LatencySeconds: 80, Credits: 17},
+		domain.StepScript:      {LatencySeconds: 12.25, PromptTokens: 3200, CompletionTokens: 1400},
+		domain.StepNarrator:    {LatencySeconds: 4, PromptTokens: 600, CompletionTokens: 150},
+		domain.StepMusic:       {LatencySeconds: 30, Retries: 2},
+		domain.StepComposition: {LatencySeconds: 41},
+		"sfx":                  {LatencySeconds: 3, Credits: 2},
+	})
+
+	if len(usage.Scenes) != 3 {
+		t.Fatalf("scenes = %+v, want 3", usage.Scenes)
+	}
+	for i, want := range []int{1, 2, 10} {
+		if usage.Scenes[i].Scene != want {
+			t.Errorf("scenes[%d] = scene %d, want scene %d", i, usage.Scenes[i].Scene, want)
+		}
+	}
+	if first := usage.Scenes[0]; first.LatencySeconds != 120 || first.Retries != 1 || first.Credits != 21 {
+		t.Errorf("scene 1 = %+v", first)
+	}
+	if usage.Script.LatencySeconds != 12.25 || usage.Narration.LatencySeconds != 4 || usage.Composition.LatencySeconds != 41 {
+		t.Errorf("step totals = script %+v, narration %+v, composition %+v", usage.Script, usage.Narration, usage.Composition)
+	}
+	if usage.Music.Retries != 2 {
+		t.Errorf("music = %+v, want 2 retries", usage.Music)
+	}
+	if usage.TotalCredits != 21+21+17+2 {
+		t.Errorf("total credits = %d, want %d", usage.TotalCredits, 21+21+17+2)
+	}
+	if usage.Script.PromptTokens != 3200 || usage.Script.CompletionTokens != 1400 {
+		t.Errorf("script = %+v, want 3200 prompt and 1400 completion tokens", usage.Script)
+	}
+	if usage.TotalTokens != 3200+1400+600+150 {
+		t.Errorf("total tokens = %d, want %d", usage.TotalTokens, 3200+1400+600+150)
+	}
+}
+
+func TestSummarizeUsageEmpty(t *testing.T) {
+	usage := SummarizeUsage(nil)
+	if usage.Scenes == nil || len(usage.Scenes) != 0 || usage.TotalCredits != 0 {
+		t.Errorf("SummarizeUsage(nil) = %+v, want no scenes and no credits", usage)
+	}
+	if TotalCredits(nil) != 0 {
+		t.Errorf("TotalCredits(nil) = %d", TotalCredits(nil))
+	}
+}
+
+func TestTotalCredits(t *testing.T) {
+	steps := map[string]domain.StepStats{domain.SceneStep(1): {Credits: 21}, domain.SceneStep(2): {Credits: 17}, domain.StepScript: {}}
+	if got := TotalCredits(steps); got != 38 {
+		t.Errorf("TotalCredits = %d, want 38", got)
+	}
+}
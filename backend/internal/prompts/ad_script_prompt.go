@@ -52,7 +52,11 @@ You MUST respond with ONLY valid JSON matching this exact schema. Do not include
       "transition_out": "enum - one of: cut, fade, cross_fade, wipe_left, wipe_right, iris_in, iris_out, match_cut, jump_cut, smash_cut, whip_pan, zoom_transition, none",
 
       "generation_prompt": "string - highly detailed, optimized prompt for Veo 3.1 video generation (150-300 characters)",
-      "start_image_url": "string or empty - leave empty unless continuity required"
+      "negative_prompt": "string or empty - comma-separated artifacts the video model should avoid in this scene (under 200 characters)",
+      "start_image_url": "string or empty - leave empty unless continuity required",
+      "assigned_image_ref": "string or empty - ref of a listed product image this scene starts from (only when PRODUCT IMAGES are provided)",
+      "voiceover_text": "string or empty - line spoken over this scene (must fit within the scene duration, ~2.5 words per second)",
+      "voiceover_voice": "string or empty - male or female"
     }
   ],
   "audio_spec": {
@@ -121,6 +125,7 @@ You MUST respond with ONLY valid JSON matching this exact schema. Do not include
    - Include: Subject, action, setting, lighting, color, camera detail
    - Optimize for Veo 3.1 (works best with concrete, visual descriptions)
    - Avoid abstract concepts, focus on visible elements
+   - Use negative_prompt only for artifacts the scene is prone to, e.g. "extra fingers, distorted hands" for close-ups of hands or "warped text, garbled letters" for labels and signage; leave it empty otherwise
    - **CRITICAL - Content Moderation**: Veo 3.1 will REJECT prompts with:
      * Medical crisis language: "pain", "suffering", "crisis", "emergency", "agony"
      * Negative health terms: "sick", "ill", "disease", "symptoms"
@@ -136,6 +141,8 @@ You MUST respond with ONLY valid JSON matching this exact schema. Do not include
 7. **Audio Sync**:
    - Add sync_points for beat drops, product reveals, text appearances
    - Voiceover should complement, not narrate everything
+   - Put spoken lines in each scene's voiceover_text (only scenes that need one), sized to fit that scene
+   - Leave voiceover_text empty in every scene when a narrator_script is used
    - Music mood must match visual mood
 
 8. **Pharmaceutical Ads** (when narrator_script is requested):
@@ -632,7 +639,13 @@ Optimize your generation_prompts for Kling by:
 - Specifying cause-and-effect relationships in motion
 - Including detailed camera movement instructions
 - Adding mood and atmosphere descriptors
-- For emotional scenes: focus on body language over facial micro-expressions`,
+- For emotional scenes: focus on body language over facial micro-expressions
+
+## SCENE DURATION OVERRIDE (CRITICAL)
+
+Kling generates ONLY 5 or 10 second clips. This REPLACES the 4/6/8 second rule above:
+- Each scene duration MUST be exactly 5 or 10 seconds
+- Plan scene count so the durations sum to the requested total`,
 
 	"minimax": `## VIDEO MODEL OPTIMIZATION: Minimax Hailuo
 
@@ -0,0 +1,60 @@
+package prompts
+
+import "strings"
+
+// BrandGuidelines contains the brand fields injected into the script system prompt
+type BrandGuidelines struct {
+	Name       string
+	Colors     []string // palette, e.g. "#0A84FF" or "ocean blue"
+	Typography string
+	BrandVoice []string // adjectives, e.g. "confident", "warm"
+	VideoStyle string
+	LogoURLs   []string
+}
+
+// BuildBrandGuidelinesSection renders a BRAND GUIDELINES section for the system prompt.
+// Only populated fields are included; returns "" when there is nothing to render.
+func BuildBrandGuidelinesSection(g *BrandGuidelines) string {
+	if g == nil {
+		return ""
+	}
+
+	var lines []string
+	if name := strings.TrimSpace(g.Name); name != "" {
+		lines = append(lines, "- Brand: "+name)
+	}
+	if colors := nonEmpty(g.Colors); len(colors) > 0 {
+		lines = append(lines, "- Color Palette: "+strings.Join(colors, ", ")+
+			" (use these as the dominant colors in wardrobe, props, set design and color grading; reflect them in metadata.brand_palette)")
+	}
+	if typography := strings.TrimSpace(g.Typography); typography != "" {
+		lines = append(lines, "- Typography: "+typography+" (describe any on-screen text in this type treatment)")
+	}
+	if voice := nonEmpty(g.BrandVoice); len(voice) > 0 {
+		lines = append(lines, "- Brand Voice: "+strings.Join(voice, ", ")+" (narration, text overlays and call to action must sound like this)")
+	}
+	if style := strings.TrimSpace(g.VideoStyle); style != "" {
+		lines = append(lines, "- Video Style: "+style)
+	}
+	if logos := nonEmpty(g.LogoURLs); len(logos) > 0 {
+		lines = append(lines, "- Logo: Provided as a style reference. Feature the logo in the final scene and keep it unobstructed")
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "## BRAND GUIDELINES\nThese guidelines are mandatory and override conflicting creative direction.\n" +
+		strings.Join(lines, "\n")
+}
+
+// nonEmpty returns the trimmed, non-blank values
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
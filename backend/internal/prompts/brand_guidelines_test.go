@@ -0,0 +1,63 @@
+package prompts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestBuildBrandGuidelinesSectionFull(t *testing.T) {
+	section := prompts.BuildBrandGuidelinesSection(&prompts.BrandGuidelines{
+		Name:       "Acme",
+		Colors:     []string{"#0A84FF", "Sand"},
+		Typography: "Helvetica Neue, bold headlines",
+		BrandVoice: []string{"confident", "warm"},
+		VideoStyle: "Bright, airy lifestyle footage",
+		LogoURLs:   []string{"https://example.com/logo.png"},
+	})
+
+	expected := []string{
+		"## BRAND GUIDELINES",
+		"- Brand: Acme",
+		"- Color Palette: #0A84FF, Sand",
+		"- Typography: Helvetica Neue, bold headlines",
+		"- Brand Voice: confident, warm",
+		"- Video Style: Bright, airy lifestyle footage",
+		"- Logo:",
+	}
+	for _, element := range expected {
+		if !strings.Contains(section, element) {
+			t.Errorf("brand guidelines section should contain %q, got:\n%s", element, section)
+		}
+	}
+}
+
+func TestBuildBrandGuidelinesSectionPartial(t *testing.T) {
+	section := prompts.BuildBrandGuidelinesSection(&prompts.BrandGuidelines{
+		Colors:     []string{" #FF0000 ", "", "  "},
+		BrandVoice: []string{"playful"},
+		Typography: "   ",
+	})
+
+	if !strings.Contains(section, "- Color Palette: #FF0000 (") {
+		t.Errorf("expected trimmed single color, got:\n%s", section)
+	}
+	if !strings.Contains(section, "- Brand Voice: playful") {
+		t.Errorf("expected brand voice line, got:\n%s", section)
+	}
+	for _, missing := range []string{"- Brand:", "- Typography:", "- Video Style:", "- Logo:"} {
+		if strings.Contains(section, missing) {
+			t.Errorf("section should omit unset field %q, got:\n%s", missing, section)
+		}
+	}
+}
+
+func TestBuildBrandGuidelinesSectionEmpty(t *testing.T) {
+	if section := prompts.BuildBrandGuidelinesSection(nil); section != "" {
+		t.Errorf("nil guidelines should render nothing, got %q", section)
+	}
+	if section := prompts.BuildBrandGuidelinesSection(&prompts.BrandGuidelines{Colors: []string{""}}); section != "" {
+		t.Errorf("blank guidelines should render nothing, got %q", section)
+	}
+}
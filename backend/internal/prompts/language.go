@@ -0,0 +1,31 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language is the language a script's spoken and on-screen copy is written in
+type Language struct {
+	Tag            string  // BCP-47, e.g. "es-MX"
+	Name           string  // English name, e.g. "Spanish"
+	WordsPerSecond float64 // Narrator pace in the language
+}
+
+// BuildLanguageSection renders a LANGUAGE section asking GPT-4o to write what viewers hear and
+// read in lang, while the scene descriptions the video model reads stay in English. Returns ""
+// for English, which the rest of the prompt already assumes.
+func BuildLanguageSection(lang Language) string {
+	if lang.Name == "" || lang.Name == "English" {
+		return ""
+	}
+
+	tag := strings.TrimSpace(lang.Tag)
+	return fmt.Sprintf("## LANGUAGE: %s (%s)\n", lang.Name, tag) +
+		fmt.Sprintf("Write everything viewers hear or read in %s, as a native %s copywriter would rather than as a translation:\n", lang.Name, tag) +
+		"- title, narrator_script and every scene's voiceover_text\n" +
+		"- Any on-screen text and the call to action, including a call to action the user gave in another language\n" +
+		fmt.Sprintf("- Pace narrator_script and voiceover_text at about %.1f words per second; this replaces the English rate above\n", lang.WordsPerSecond) +
+		"Keep every other field in English, since the video and music models read them: scene actions, locations, generation_prompt, camera, lighting and mood fields, and the music description.\n" +
+		"- side_effects_text is the exception to both rules: copy the user's disclosure EXACTLY as given, in the language it was written in. Never translate, paraphrase or shorten it."
+}
@@ -0,0 +1,55 @@
+package prompts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestBuildLanguageSection(t *testing.T) {
+	tests := []struct {
+		lang     prompts.Language
+		expected []string
+	}{
+		{
+			lang: prompts.Language{Tag: "es-MX", Name: "Spanish", WordsPerSecond: 2.2},
+			expected: []string{
+				"## LANGUAGE: Spanish (es-MX)",
+				"hear or read in Spanish, as a native es-MX copywriter",
+				"- title, narrator_script and every scene's voiceover_text",
+				"the call to action",
+				"about 2.2 words per second",
+				"Keep every other field in English",
+				"generation_prompt",
+				"side_effects_text",
+				"Never translate, paraphrase or shorten it",
+			},
+		},
+		{
+			lang: prompts.Language{Tag: "de", Name: "German", WordsPerSecond: 2.0},
+			expected: []string{
+				"## LANGUAGE: German (de)",
+				"hear or read in German",
+				"about 2.0 words per second",
+				"EXACTLY as given",
+			},
+		},
+	}
+	for _, tt := range tests {
+		section := prompts.BuildLanguageSection(tt.lang)
+		for _, element := range tt.expected {
+			if !strings.Contains(section, element) {
+				t.Errorf("%s section should contain %q, got:\n%s", tt.lang.Tag, element, section)
+			}
+		}
+	}
+}
+
+func TestBuildLanguageSectionEnglish(t *testing.T) {
+	for _, lang := range []prompts.Language{{}, {Tag: "en-GB", Name: "English", WordsPerSecond: 2.5}} {
+		if section := prompts.BuildLanguageSection(lang); section != "" {
+			t.Errorf("BuildLanguageSection(%+v) should be empty, got:\n%s", lang, section)
+		}
+	}
+}
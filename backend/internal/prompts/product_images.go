@@ -0,0 +1,37 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProductImage is a product photo the script may assign to a scene
+type ProductImage struct {
+	Ref  string // Identifier the scene's assigned_image_ref must use, e.g. "image_1"
+	Hint string // Optional description from the user
+}
+
+// BuildProductImagesSection renders a PRODUCT IMAGES section asking GPT-4o to start scenes from
+// the user's photos. Returns "" when there are no images.
+func BuildProductImagesSection(images []ProductImage) string {
+	if len(images) == 0 {
+		return ""
+	}
+
+	var lines []string
+	for _, image := range images {
+		line := "- " + image.Ref
+		if hint := strings.TrimSpace(image.Hint); hint != "" {
+			line += ": " + hint
+		}
+		lines = append(lines, line)
+	}
+
+	return "## PRODUCT IMAGES\n" +
+		fmt.Sprintf("The user supplied %d product photos. Build scenes around them: a scene with an assigned image starts from that exact photo, "+
+			"so its generation_prompt must describe motion that begins from what the photo shows.\n", len(images)) +
+		strings.Join(lines, "\n") + "\n" +
+		"- Set a scene's assigned_image_ref to one of the refs above to start it from that photo; use each photo at most once\n" +
+		"- Leave assigned_image_ref empty for scenes that should continue from the previous clip\n" +
+		"- Only use the refs listed above; never invent new ones"
+}
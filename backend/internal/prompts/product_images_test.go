@@ -0,0 +1,34 @@
+package prompts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestBuildProductImagesSection(t *testing.T) {
+	section := prompts.BuildProductImagesSection([]prompts.ProductImage{
+		{Ref: "image_1", Hint: " bottle on a marble counter "},
+		{Ref: "image_2"},
+	})
+
+	expected := []string{
+		"## PRODUCT IMAGES",
+		"The user supplied 2 product photos.",
+		"- image_1: bottle on a marble counter\n",
+		"- image_2\n",
+		"assigned_image_ref",
+	}
+	for _, element := range expected {
+		if !strings.Contains(section, element) {
+			t.Errorf("product images section should contain %q, got:\n%s", element, section)
+		}
+	}
+}
+
+func TestBuildProductImagesSectionEmpty(t *testing.T) {
+	if section := prompts.BuildProductImagesSection(nil); section != "" {
+		t.Errorf("expected empty section without images, got:\n%s", section)
+	}
+}
@@ -0,0 +1,32 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RepeatedScenes is two adjacent scenes of an earlier draft that describe nearly the same shot
+type RepeatedScenes struct {
+	First, Second int     // Scene numbers, 1-based
+	Similarity    float64 // Word overlap of the two scenes, 0 to 1
+}
+
+// BuildRepeatedScenesSection renders a REPEATED SCENES section naming the scene pairs of the
+// previous draft that would play as a stutter, and asking GPT-4o to rewrite them to differ in
+// the progression dimensions. Returns "" when no scenes repeat.
+func BuildRepeatedScenesSection(pairs []RepeatedScenes) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("## REPEATED SCENES\n")
+	b.WriteString("Your previous draft of this script had adjacent scenes describing nearly the same shot, which plays as a stutter:\n")
+	for _, pair := range pairs {
+		fmt.Fprintf(&b, "- Scenes %d and %d (%.0f%% of their wording is shared)\n", pair.First, pair.Second, pair.Similarity*100)
+	}
+	b.WriteString("Write the script again. In each pair above, the later scene MUST differ from the earlier one in AT LEAST 3 of the 5 progression dimensions: " +
+		"CAMERA, ACTION, ENVIRONMENT, LIGHTING and EMOTION. Change the action and location fields and the generation_prompt, not just an adjective. " +
+		"Keep the scene count, durations and the rest of the script's rules unchanged.")
+	return b.String()
+}
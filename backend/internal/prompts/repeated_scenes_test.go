@@ -0,0 +1,35 @@
+package prompts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestBuildRepeatedScenesSection(t *testing.T) {
+	section := prompts.BuildRepeatedScenesSection([]prompts.RepeatedScenes{
+		{First: 2, Second: 3, Similarity: 0.89},
+		{First: 3, Second: 4, Similarity: 0.7},
+	})
+
+	expected := []string{
+		"## REPEATED SCENES",
+		"- Scenes 2 and 3 (89% of their wording is shared)",
+		"- Scenes 3 and 4 (70% of their wording is shared)",
+		"AT LEAST 3 of the 5 progression dimensions",
+		"CAMERA, ACTION, ENVIRONMENT, LIGHTING and EMOTION",
+		"generation_prompt",
+	}
+	for _, element := range expected {
+		if !strings.Contains(section, element) {
+			t.Errorf("repeated scenes section should contain %q, got:\n%s", element, section)
+		}
+	}
+}
+
+func TestBuildRepeatedScenesSectionNoRepeats(t *testing.T) {
+	if section := prompts.BuildRepeatedScenesSection(nil); section != "" {
+		t.Errorf("BuildRepeatedScenesSection(nil) should be empty, got:\n%s", section)
+	}
+}
@@ -0,0 +1,38 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlannedScene is a scene the user planned: its exact length and an optional location or focus
+type PlannedScene struct {
+	Duration int // Seconds
+	Hint     string
+}
+
+// BuildScenePlanSection renders a SCENE PLAN section asking GPT-4o for exactly the user's scenes,
+// in order, at their durations. Returns "" when there is no plan.
+func BuildScenePlanSection(scenes []PlannedScene) string {
+	if len(scenes) == 0 {
+		return ""
+	}
+
+	total := 0
+	var lines []string
+	for i, scene := range scenes {
+		total += scene.Duration
+		line := fmt.Sprintf("- Scene %d: %d seconds", i+1, scene.Duration)
+		if hint := strings.TrimSpace(scene.Hint); hint != "" {
+			line += " - " + hint
+		}
+		lines = append(lines, line)
+	}
+
+	return "## SCENE PLAN\n" +
+		fmt.Sprintf("The user planned the scenes. Write exactly %d scenes, in this order and with these durations:\n", len(scenes)) +
+		strings.Join(lines, "\n") + "\n" +
+		"- Set each scene's duration to its planned seconds exactly; never merge, split, add or drop scenes\n" +
+		"- Build each scene around its hint (a location or what to focus on) when it has one\n" +
+		fmt.Sprintf("- total_duration must be %d", total)
+}
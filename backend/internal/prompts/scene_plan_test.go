@@ -0,0 +1,38 @@
+package prompts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestBuildScenePlanSection(t *testing.T) {
+	section := prompts.BuildScenePlanSection([]prompts.PlannedScene{
+		{Duration: 8, Hint: "sunlit kitchen"},
+		{Duration: 8},
+		{Duration: 8, Hint: "  close on the bottle  "},
+		{Duration: 6},
+	})
+
+	expected := []string{
+		"## SCENE PLAN",
+		"Write exactly 4 scenes",
+		"- Scene 1: 8 seconds - sunlit kitchen\n",
+		"- Scene 2: 8 seconds\n",
+		"- Scene 3: 8 seconds - close on the bottle\n",
+		"- Scene 4: 6 seconds\n",
+		"total_duration must be 30",
+	}
+	for _, element := range expected {
+		if !strings.Contains(section, element) {
+			t.Errorf("scene plan section should contain %q, got:\n%s", element, section)
+		}
+	}
+}
+
+func TestBuildScenePlanSectionNoPlan(t *testing.T) {
+	if section := prompts.BuildScenePlanSection(nil); section != "" {
+		t.Errorf("BuildScenePlanSection(nil) should be empty, got:\n%s", section)
+	}
+}
@@ -0,0 +1,20 @@
+package prompts
+
+import "fmt"
+
+// BuildVariantsSection renders an A/B VARIANTS section asking GPT-4o for count distinct scripts
+// in one response, wrapped in a "variants" array. Returns "" for a single script.
+func BuildVariantsSection(count int) string {
+	if count <= 1 {
+		return ""
+	}
+
+	return "## A/B VARIANTS\n" +
+		fmt.Sprintf("Write %d distinct scripts for the same brief so the ads can be tested against each other. "+
+			"Respond with ONLY this JSON wrapper, where each element is a complete script matching the schema above:\n", count) +
+		`{"variants": [<script 1>, <script 2>, ...]}` + "\n" +
+		fmt.Sprintf("- Return exactly %d scripts, in the order you want them numbered\n", count) +
+		"- Give each script a different hook, scene structure and narrative angle; don't reuse opening shots or taglines\n" +
+		"- Keep the product, total duration, aspect ratio, brand rules and any verbatim text identical across scripts\n" +
+		"- Give each script its own title that names its angle"
+}
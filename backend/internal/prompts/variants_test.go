@@ -0,0 +1,32 @@
+package prompts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/prompts"
+)
+
+func TestBuildVariantsSection(t *testing.T) {
+	section := prompts.BuildVariantsSection(3)
+
+	expected := []string{
+		"## A/B VARIANTS",
+		"Write 3 distinct scripts",
+		`{"variants": [`,
+		"Return exactly 3 scripts",
+	}
+	for _, element := range expected {
+		if !strings.Contains(section, element) {
+			t.Errorf("variants section should contain %q, got:\n%s", element, section)
+		}
+	}
+}
+
+func TestBuildVariantsSectionSingleScript(t *testing.T) {
+	for _, count := range []int{0, 1} {
+		if section := prompts.BuildVariantsSection(count); section != "" {
+			t.Errorf("BuildVariantsSection(%d) should be empty, got:\n%s", count, section)
+		}
+	}
+}
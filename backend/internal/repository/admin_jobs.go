@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// StatusJobsQuery selects one page of every user's jobs in a status, for support staff
+type StatusJobsQuery struct {
+	Limit        int    // Jobs per page (must be positive)
+	Status       string // Required: the StatusJobsIndex partition to read
+	FailureStage string // Exact failure_stage match; empty matches all
+
+	CreatedAfter  int64 // Unix seconds, inclusive; 0 is unbounded
+	CreatedBefore int64 // Unix seconds, inclusive; 0 is unbounded
+
+	ExclusiveStartKey map[string]types.AttributeValue // Previous page's LastEvaluatedKey; nil for the first page
+	MaxScanned        int                             // Items read before giving up on filling the page; 0 uses DefaultMaxScannedJobs
+}
+
+// keyCondition narrows the StatusJobsIndex query to the status and created_at range
+func (q StatusJobsQuery) keyCondition(values map[string]types.AttributeValue) string {
+	condition := "#status = :status"
+	switch {
+	case q.CreatedAfter > 0 && q.CreatedBefore > 0:
+		condition += " AND created_at BETWEEN :created_after AND :created_before"
+	case q.CreatedAfter > 0:
+		condition += " AND created_at >= :created_after"
+	case q.CreatedBefore > 0:
+		condition += " AND created_at <= :created_before"
+	}
+	if q.CreatedAfter > 0 {
+		values[":created_after"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(q.CreatedAfter, 10)}
+	}
+	if q.CreatedBefore > 0 {
+		values[":created_before"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(q.CreatedBefore, 10)}
+	}
+	return condition
+}
+
+// ListJobsByStatus retrieves one page of jobs in a status across all users, newest first.
+// It queries StatusJobsIndex rather than scanning the table, so a status is required; the
+// date range is part of the key condition and the failure stage filter runs inside the query.
+// As with GetJobsByUser, queries continue until the page is full, the partition is exhausted
+// or MaxScanned items have been read.
+func (r *DynamoDBRepository) ListJobsByStatus(ctx context.Context, query StatusJobsQuery) (*JobsPage, error) {
+	if query.Limit < 1 {
+		return nil, fmt.Errorf("limit must be positive, got %d", query.Limit)
+	}
+	if query.Status == "" {
+		return nil, fmt.Errorf("status is required")
+	}
+	if query.ExclusiveStartKey != nil {
+		status, ok := query.ExclusiveStartKey["status"].(*types.AttributeValueMemberS)
+		if !ok || status.Value != query.Status {
+			return nil, ErrInvalidCursor
+		}
+	}
+	maxScanned := query.MaxScanned
+	if maxScanned <= 0 {
+		maxScanned = DefaultMaxScannedJobs
+	}
+
+	values := map[string]types.AttributeValue{
+		":status": &types.AttributeValueMemberS{Value: query.Status},
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(statusJobsIndex),
+		KeyConditionExpression:    aws.String(query.keyCondition(values)),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false), // Newest first
+	}
+	if query.FailureStage != "" {
+		input.FilterExpression = aws.String("failure_stage = :failure_stage")
+		values[":failure_stage"] = &types.AttributeValueMemberS{Value: query.FailureStage}
+	}
+
+	page := &JobsPage{}
+	startKey := query.ExclusiveStartKey
+	for {
+		// Asking only for the jobs still missing means a query never returns more than fit the page
+		input.ExclusiveStartKey = startKey
+		input.Limit = aws.Int32(int32(min(query.Limit-len(page.Jobs), maxScanned-page.Scanned)))
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to query jobs by status",
+				zap.String("status", query.Status),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to query jobs by status: %w", err)
+		}
+		page.Scanned += int(result.ScannedCount)
+
+		var jobs []*domain.Job
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &jobs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal jobs: %w", err)
+		}
+		page.Jobs = append(page.Jobs, jobs...)
+
+		startKey = result.LastEvaluatedKey
+		if len(startKey) == 0 {
+			break
+		}
+		if len(page.Jobs) == query.Limit {
+			page.LastEvaluatedKey = indexKey(startKey, statusJobsIndexKeys)
+			break
+		}
+		if page.Scanned >= maxScanned {
+			page.LastEvaluatedKey = indexKey(startKey, statusJobsIndexKeys)
+			page.Truncated = true
+			break
+		}
+	}
+
+	r.logger.Info("Retrieved jobs by status",
+		zap.String("status", query.Status),
+		zap.String("failure_stage", query.FailureStage),
+		zap.Int("count", len(page.Jobs)),
+		zap.Int("scanned", page.Scanned),
+		zap.Bool("has_more", page.LastEvaluatedKey != nil),
+	)
+
+	return page, nil
+}
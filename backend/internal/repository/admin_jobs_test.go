@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// seedFailedJobs creates n failed jobs spread across three users, newest last; every
+// other job failed generating scenes, and one completed job sits in another partition
+func seedFailedJobs(n int) []*domain.Job {
+	jobs := make([]*domain.Job, 0, n+1)
+	for i := 1; i <= n; i++ {
+		stage := "script_generating"
+		if i%2 == 0 {
+			stage = "scene_2_generating"
+		}
+		jobs = append(jobs, &domain.Job{
+			JobID:        fmt.Sprintf("job-%02d", i),
+			UserID:       fmt.Sprintf("user-%d", i%3),
+			Status:       domain.StatusFailed,
+			FailureStage: stage,
+			CreatedAt:    int64(1000 + i),
+		})
+	}
+	jobs = append(jobs, &domain.Job{JobID: "done", UserID: "user-1", Status: domain.StatusCompleted, CreatedAt: 5000})
+	return jobs
+}
+
+func TestListJobsByStatus_PagesAcrossUsers(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedFailedJobs(7)...))
+	ctx := context.Background()
+
+	page, err := repo.ListJobsByStatus(ctx, StatusJobsQuery{Limit: 4, Status: domain.StatusFailed})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-07", "job-06", "job-05", "job-04"}, jobIDs(page.Jobs))
+	require.NotNil(t, page.LastEvaluatedKey)
+
+	page, err = repo.ListJobsByStatus(ctx, StatusJobsQuery{Limit: 4, Status: domain.StatusFailed, ExclusiveStartKey: page.LastEvaluatedKey})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-03", "job-02", "job-01"}, jobIDs(page.Jobs))
+	require.Nil(t, page.LastEvaluatedKey)
+}
+
+func TestListJobsByStatus_FiltersFailureStageAndDateRange(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedFailedJobs(10)...))
+
+	page, err := repo.ListJobsByStatus(context.Background(), StatusJobsQuery{
+		Limit:         10,
+		Status:        domain.StatusFailed,
+		FailureStage:  "scene_2_generating",
+		CreatedAfter:  1003,
+		CreatedBefore: 1008,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-08", "job-06", "job-04"}, jobIDs(page.Jobs))
+	require.Equal(t, 6, page.Scanned, "only the date range is read")
+}
+
+func TestListJobsByStatus_FilteredPagesAreFull(t *testing.T) {
+	db := newFakeDynamoDB(t, seedFailedJobs(10)...)
+	repo := newTestJobRepository(db)
+
+	page, err := repo.ListJobsByStatus(context.Background(), StatusJobsQuery{Limit: 3, Status: domain.StatusFailed, FailureStage: "script_generating"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-09", "job-07", "job-05"}, jobIDs(page.Jobs))
+	require.Equal(t, "job-05", attrS(page.LastEvaluatedKey, "job_id"))
+	require.Greater(t, db.queries, 1, "the filter shrinks each query's results")
+}
+
+func TestListJobsByStatus_StopsAtMaxScanned(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedFailedJobs(10)...))
+
+	page, err := repo.ListJobsByStatus(context.Background(), StatusJobsQuery{Limit: 3, Status: domain.StatusFailed, FailureStage: "composing", MaxScanned: 4})
+	require.NoError(t, err)
+	require.Empty(t, page.Jobs)
+	require.True(t, page.Truncated)
+	require.Equal(t, 4, page.Scanned)
+	require.Equal(t, "job-07", attrS(page.LastEvaluatedKey, "job_id"))
+}
+
+func TestListJobsByStatus_RejectsCursorOfAnotherStatus(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedFailedJobs(3)...))
+
+	startKey := map[string]types.AttributeValue{
+		"job_id":     &types.AttributeValueMemberS{Value: "done"},
+		"status":     &types.AttributeValueMemberS{Value: domain.StatusCompleted},
+		"created_at": &types.AttributeValueMemberN{Value: "5000"},
+	}
+	_, err := repo.ListJobsByStatus(context.Background(), StatusJobsQuery{Limit: 3, Status: domain.StatusFailed, ExclusiveStartKey: startKey})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = repo.ListJobsByStatus(context.Background(), StatusJobsQuery{Limit: 3})
+	require.Error(t, err, "a status is required")
+}
+
+func TestStatusJobsCursorRoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"job_id":     &types.AttributeValueMemberS{Value: "job-05"},
+		"status":     &types.AttributeValueMemberS{Value: domain.StatusFailed},
+		"created_at": &types.AttributeValueMemberN{Value: "1005"},
+	}
+	cursor, err := EncodeJobsCursor(key)
+	require.NoError(t, err)
+
+	decoded, err := DecodeStatusJobsCursor(cursor)
+	require.NoError(t, err)
+	require.Equal(t, key, decoded)
+
+	_, err = DecodeJobsCursor(cursor)
+	require.ErrorIs(t, err, ErrInvalidCursor, "a status cursor is not a user jobs cursor")
+}
+
+func TestTruncateFailureError(t *testing.T) {
+	short := "replicate: status 500"
+	require.Equal(t, short, truncateFailureError(short))
+
+	// The 2-byte é straddles the limit, so it is dropped whole
+	long := truncateFailureError(strings.Repeat("a", maxFailureErrorLength-1) + "é")
+	require.Equal(t, strings.Repeat("a", maxFailureErrorLength-1)+"...", long)
+}
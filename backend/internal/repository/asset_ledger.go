@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// AssetLedger collects the size of every artifact a pipeline run or scene regeneration uploads
+// for a job. Uploads land in the ledger; the sizes reach the job record with its next save and
+// are only counted against the user's storage total once that save succeeds, so the total never
+// includes uploads the record doesn't know about (and deletions can't subtract them twice).
+type AssetLedger struct {
+	mu        sync.Mutex
+	sizes     map[string]int64 // Every asset the job has, by S3 key
+	persisted int64            // Bytes the job record held after the last successful save
+}
+
+type assetLedgerKey struct{}
+
+// WithAssetLedger returns a context whose uploads are recorded against job, starting from the
+// sizes already on its record
+func WithAssetLedger(ctx context.Context, job *domain.Job) context.Context {
+	sizes := maps.Clone(job.Assets)
+	if sizes == nil {
+		sizes = make(map[string]int64)
+	}
+	return context.WithValue(ctx, assetLedgerKey{}, &AssetLedger{sizes: sizes, persisted: AssetBytes(job.Assets)})
+}
+
+// AssetLedgerFrom returns the context's ledger, or nil when uploads aren't being accounted
+func AssetLedgerFrom(ctx context.Context) *AssetLedger {
+	ledger, _ := ctx.Value(assetLedgerKey{}).(*AssetLedger)
+	return ledger
+}
+
+// Record notes an uploaded asset, replacing the size of an earlier upload to the same key
+func (l *AssetLedger) Record(key string, size int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sizes[key] = size
+}
+
+// Stage copies the recorded sizes onto job for saving and returns their total
+func (l *AssetLedger) Stage(job *domain.Job) int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	job.Assets = maps.Clone(l.sizes)
+	return AssetBytes(job.Assets)
+}
+
+// Commit marks a staged total as saved and returns how much the user's storage total changes
+func (l *AssetLedger) Commit(total int64) int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delta := total - l.persisted
+	l.persisted = total
+	return delta
+}
+
+// AssetBytes sums recorded asset sizes
+func AssetBytes(assets map[string]int64) int64 {
+	var total int64
+	for _, size := range assets {
+		total += size
+	}
+	return total
+}
+
+// UploadJobAsset uploads a job artifact and records its size on the context's asset ledger.
+// Failed uploads are never recorded.
+func (s *S3AssetRepository) UploadJobAsset(ctx context.Context, bucket, key, filePath, contentType string) (string, error) {
+	return s.UploadJobAssetWithMetadata(ctx, bucket, key, filePath, contentType, nil)
+}
+
+// UploadJobAssetWithMetadata uploads a job artifact like UploadJobAsset, storing metadata on
+// the object
+func (s *S3AssetRepository) UploadJobAssetWithMetadata(ctx context.Context, bucket, key, filePath, contentType string, metadata map[string]string) (string, error) {
+	url, err := s.UploadFileWithMetadata(ctx, bucket, key, filePath, contentType, metadata)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		AssetLedgerFrom(ctx).Record(key, info.Size())
+	}
+	return url, nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// AssetLocator decides where a job's generated assets are stored. A new job gets the configured
+// key prefix (e.g. "env/staging/") and its user's bucket from the mapping table, or the default
+// bucket. The location is frozen onto the job, so a config change mid-job doesn't split its
+// assets across buckets. Jobs from before locations were recorded keep the legacy layout: the
+// default bucket, with no prefix.
+type AssetLocator struct {
+	defaultBucket string
+	prefix        string                // "" for none; always ends in "/" otherwise
+	userBuckets   UserBucketsRepository // Optional; nil stores every job in the default bucket
+}
+
+// NewAssetLocator creates a locator for jobs stored under prefix in defaultBucket, unless
+// userBuckets maps their user to another bucket
+func NewAssetLocator(defaultBucket, prefix string, userBuckets UserBucketsRepository) *AssetLocator {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &AssetLocator{defaultBucket: defaultBucket, prefix: prefix, userBuckets: userBuckets}
+}
+
+// Assign freezes the job's asset location. A job that already has one keeps it.
+func (l *AssetLocator) Assign(ctx context.Context, job *domain.Job) error {
+	if job.AssetBucket != "" {
+		return nil
+	}
+
+	bucket := l.defaultBucket
+	if l.userBuckets != nil {
+		userBucket, err := l.userBuckets.GetUserBucket(ctx, job.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to resolve assets bucket: %w", err)
+		}
+		if userBucket != "" {
+			bucket = userBucket
+		}
+	}
+	job.AssetBucket = bucket
+	job.AssetPrefix = l.prefix
+	return nil
+}
+
+// Locate resolves one of the job's assets, named relative to the job's folder (e.g.
+// "clips/scene-001.mp4"), to the bucket and key it is stored at
+func (l *AssetLocator) Locate(job *domain.Job, name string) (bucket, key string) {
+	return JobAssetBucket(job, l.defaultBucket), JobAssetPrefix(job) + name
+}
+
+// JobAssetBucket returns the bucket the job's assets are stored in: the one recorded on it, or
+// defaultBucket for jobs from before buckets were recorded
+func JobAssetBucket(job *domain.Job, defaultBucket string) string {
+	if job.AssetBucket != "" {
+		return job.AssetBucket
+	}
+	return defaultBucket
+}
+
+// UserAssetPrefix returns the key prefix new assets of the user that don't belong to a job
+// (uploads, brand documents) are stored under: the configured key prefix, then users/{userID}/.
+// They stay in the default bucket, where every handler reads uploads from. A nil locator uses
+// the legacy layout.
+func (l *AssetLocator) UserAssetPrefix(userID string) string {
+	return userAssetPrefix(l.keyPrefix(), userID)
+}
+
+// UploadPrefix returns the key prefix the user's new uploads are stored under
+func (l *AssetLocator) UploadPrefix(userID string) string {
+	return l.UserAssetPrefix(userID) + "uploads/"
+}
+
+// UserAssetRoots returns the prefixes every user's assets are stored under: the configured one,
+// then the legacy unprefixed one when it differs, so assets stored before a prefix was set are
+// still found
+func (l *AssetLocator) UserAssetRoots() []string {
+	if prefix := l.keyPrefix(); prefix != "" {
+		return []string{prefix + "users/", "users/"}
+	}
+	return []string{"users/"}
+}
+
+// OwnsAsset reports whether key is one of the user's assets, under the configured or the
+// legacy layout
+func (l *AssetLocator) OwnsAsset(userID, key string) bool {
+	_, ok := l.cutUserFolder(userID, key, "")
+	return ok
+}
+
+// OwnsUpload reports whether key is one of the user's uploads, under the configured or the
+// legacy layout
+func (l *AssetLocator) OwnsUpload(userID, key string) bool {
+	_, ok := l.UploadName(userID, key)
+	return ok
+}
+
+// UploadName returns the part of key after the user's uploads folder, reporting false if key
+// isn't one of their uploads
+func (l *AssetLocator) UploadName(userID, key string) (string, bool) {
+	return l.cutUserFolder(userID, key, "uploads/")
+}
+
+// cutUserFolder strips the user's folder (e.g. "uploads/") under any of the asset roots from key
+func (l *AssetLocator) cutUserFolder(userID, key, folder string) (string, bool) {
+	if userID == "" || strings.Contains(key, "..") {
+		return "", false
+	}
+	for _, root := range l.UserAssetRoots() {
+		if rest, ok := strings.CutPrefix(key, root+userID+"/"+folder); ok {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// keyPrefix returns the configured key prefix, "" for a nil locator
+func (l *AssetLocator) keyPrefix() string {
+	if l == nil {
+		return ""
+	}
+	return l.prefix
+}
+
+// JobAssetPrefix returns the key prefix every asset of the job is stored under: its recorded key
+// prefix, then users/{userID}/jobs/{jobID}/
+func JobAssetPrefix(job *domain.Job) string {
+	return userAssetPrefix(job.AssetPrefix, job.UserID) + "jobs/" + job.JobID + "/"
+}
+
+// userAssetPrefix is the folder of the user's assets under a key prefix
+func userAssetPrefix(prefix, userID string) string {
+	return fmt.Sprintf("%susers/%s/", prefix, userID)
+}
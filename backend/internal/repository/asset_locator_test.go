@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// failingUserBuckets fails every mapping lookup
+type failingUserBuckets struct{}
+
+func (failingUserBuckets) GetUserBucket(context.Context, string) (string, error) {
+	return "", errors.New("table unavailable")
+}
+
+func (failingUserBuckets) PutUserBucket(context.Context, string, string) error {
+	return errors.New("table unavailable")
+}
+
+func TestAssetLocator_AssignPrecedence(t *testing.T) {
+	ctx := context.Background()
+	userBuckets := NewLocalDynamoDB().UserBucketRepository("user-buckets", zap.NewNop())
+	require.NoError(t, userBuckets.PutUserBucket(ctx, "tenant-user", "tenant-assets"))
+	locator := NewAssetLocator("assets", "env/staging", userBuckets)
+
+	t.Run("mapped user gets their bucket", func(t *testing.T) {
+		job := &domain.Job{JobID: "job-1", UserID: "tenant-user"}
+		require.NoError(t, locator.Assign(ctx, job))
+		require.Equal(t, "tenant-assets", job.AssetBucket)
+		require.Equal(t, "env/staging/", job.AssetPrefix)
+	})
+
+	t.Run("unmapped user gets the default bucket", func(t *testing.T) {
+		job := &domain.Job{JobID: "job-2", UserID: "other-user"}
+		require.NoError(t, locator.Assign(ctx, job))
+		require.Equal(t, "assets", job.AssetBucket)
+		require.Equal(t, "env/staging/", job.AssetPrefix)
+	})
+
+	t.Run("a frozen location beats the mapping", func(t *testing.T) {
+		job := &domain.Job{JobID: "job-3", UserID: "tenant-user", AssetBucket: "old-assets", AssetPrefix: "env/old/"}
+		require.NoError(t, locator.Assign(ctx, job))
+		require.Equal(t, "old-assets", job.AssetBucket)
+		require.Equal(t, "env/old/", job.AssetPrefix)
+	})
+
+	t.Run("remapping doesn't move assigned jobs", func(t *testing.T) {
+		job := &domain.Job{JobID: "job-4", UserID: "tenant-user"}
+		require.NoError(t, locator.Assign(ctx, job))
+		require.NoError(t, userBuckets.PutUserBucket(ctx, "tenant-user", "new-tenant-assets"))
+		require.NoError(t, locator.Assign(ctx, job))
+		require.Equal(t, "tenant-assets", job.AssetBucket)
+	})
+}
+
+func TestAssetLocator_AssignWithoutMapping(t *testing.T) {
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	require.NoError(t, NewAssetLocator("assets", "", nil).Assign(context.Background(), job))
+	require.Equal(t, "assets", job.AssetBucket)
+	require.Empty(t, job.AssetPrefix)
+	require.Equal(t, "users/user-1/jobs/job-1/", JobAssetPrefix(job))
+}
+
+func TestAssetLocator_AssignFailsWhenMappingFails(t *testing.T) {
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	require.Error(t, NewAssetLocator("assets", "", failingUserBuckets{}).Assign(context.Background(), job))
+	require.Empty(t, job.AssetBucket, "a job is never stored in a guessed bucket")
+}
+
+func TestNewAssetLocator_NormalizesPrefix(t *testing.T) {
+	for _, prefix := range []string{"env/staging", "env/staging/", "/env/staging/"} {
+		job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+		require.NoError(t, NewAssetLocator("assets", prefix, nil).Assign(context.Background(), job))
+		require.Equal(t, "env/staging/", job.AssetPrefix, prefix)
+	}
+	for _, prefix := range []string{"", "/"} {
+		job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+		require.NoError(t, NewAssetLocator("assets", prefix, nil).Assign(context.Background(), job))
+		require.Empty(t, job.AssetPrefix, prefix)
+	}
+}
+
+func TestAssetLocator_Locate(t *testing.T) {
+	locator := NewAssetLocator("assets", "env/prod", nil)
+
+	// Jobs from before locations were recorded keep the legacy layout
+	legacy := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	bucket, key := locator.Locate(legacy, "clips/scene-001.mp4")
+	require.Equal(t, "assets", bucket)
+	require.Equal(t, "users/user-1/jobs/job-1/clips/scene-001.mp4", key)
+
+	located := &domain.Job{JobID: "job-2", UserID: "user-1", AssetBucket: "tenant-assets", AssetPrefix: "env/staging/"}
+	bucket, key = locator.Locate(located, "clips/scene-001.mp4")
+	require.Equal(t, "tenant-assets", bucket)
+	require.Equal(t, "env/staging/users/user-1/jobs/job-2/clips/scene-001.mp4", key)
+}
+
+func TestAssetLocator_UserAssets(t *testing.T) {
+	locator := NewAssetLocator("assets", "env/prod", nil)
+	require.Equal(t, "env/prod/users/user-1/uploads/", locator.UploadPrefix("user-1"))
+	require.Equal(t, []string{"env/prod/users/", "users/"}, locator.UserAssetRoots())
+
+	// Uploads from before the prefix was set are still the user's
+	require.True(t, locator.OwnsUpload("user-1", "env/prod/users/user-1/uploads/images/1_a.png"))
+	require.True(t, locator.OwnsUpload("user-1", "users/user-1/uploads/images/1_a.png"))
+	require.True(t, locator.OwnsAsset("user-1", "env/prod/users/user-1/brand/bg-1/logo.png"))
+	require.False(t, locator.OwnsUpload("user-1", "env/prod/users/user-1/brand/bg-1/logo.png"))
+	require.False(t, locator.OwnsUpload("user-1", "env/prod/users/user-2/uploads/images/1_a.png"))
+	require.False(t, locator.OwnsUpload("user-1", "env/prod/users/user-1/uploads/../../user-2/uploads/a.png"))
+	require.False(t, locator.OwnsAsset("", "users//uploads/a.png"))
+
+	name, ok := locator.UploadName("user-1", "users/user-1/uploads/images/1_a.png")
+	require.True(t, ok)
+	require.Equal(t, "images/1_a.png", name)
+
+	// Without a locator the legacy layout is used
+	var legacy *AssetLocator
+	require.Equal(t, "users/user-1/uploads/", legacy.UploadPrefix("user-1"))
+	require.Equal(t, []string{"users/"}, legacy.UserAssetRoots())
+	require.True(t, legacy.OwnsUpload("user-1", "users/user-1/uploads/images/1_a.png"))
+	require.False(t, legacy.OwnsUpload("user-1", "env/prod/users/user-1/uploads/images/1_a.png"))
+}
+
+func TestUserBuckets_GetUnmappedUser(t *testing.T) {
+	repo := NewLocalDynamoDB().UserBucketRepository("user-buckets", zap.NewNop())
+	bucket, err := repo.GetUserBucket(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.Empty(t, bucket)
+}
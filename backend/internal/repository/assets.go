@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrAssetNotFound is returned when an asset has no verification record
+var ErrAssetNotFound = errors.New("asset not found")
+
+// DynamoDBAssetRepository stores the verification results of uploaded assets
+type DynamoDBAssetRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewAssetRepository creates a new asset repository
+func NewAssetRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBAssetRepository {
+	return &DynamoDBAssetRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// SaveAsset stores an asset's verification result, replacing any earlier one
+func (r *DynamoDBAssetRepository) SaveAsset(ctx context.Context, asset *domain.Asset) error {
+	item, err := attributevalue.MarshalMap(asset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal asset: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		r.logger.Error("Failed to save asset",
+			zap.String("asset_key", asset.AssetKey),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to save asset: %w", err)
+	}
+	return nil
+}
+
+// GetAsset retrieves an asset's verification result by S3 key
+func (r *DynamoDBAssetRepository) GetAsset(ctx context.Context, assetKey string) (*domain.Asset, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"asset_key": &types.AttributeValueMemberS{Value: assetKey},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get asset", zap.String("asset_key", assetKey), zap.Error(err))
+		return nil, fmt.Errorf("failed to get asset: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrAssetNotFound
+	}
+
+	var asset domain.Asset
+	if err := attributevalue.UnmarshalMap(result.Item, &asset); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal asset: %w", err)
+	}
+	return &asset, nil
+}
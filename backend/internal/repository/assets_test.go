@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAssetRepository_SaveAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().AssetRepository("assets", zap.NewNop())
+	key := "users/user-1/uploads/product_images/1_shoe.png"
+
+	_, err := repo.GetAsset(ctx, key)
+	require.ErrorIs(t, err, ErrAssetNotFound)
+
+	require.NoError(t, repo.SaveAsset(ctx, &domain.Asset{
+		AssetKey:    key,
+		UserID:      "user-1",
+		Status:      domain.AssetStatusRejected,
+		Reason:      "malware",
+		Threat:      "Win.Test.EICAR_HDB-1",
+		ContentType: "image/png",
+		SizeBytes:   68,
+		VerifiedAt:  100,
+	}))
+	asset, err := repo.GetAsset(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, domain.AssetStatusRejected, asset.Status)
+	require.Equal(t, "Win.Test.EICAR_HDB-1", asset.Threat)
+
+	// Re-verifying replaces the earlier result
+	require.NoError(t, repo.SaveAsset(ctx, &domain.Asset{
+		AssetKey: key, UserID: "user-1", Status: domain.AssetStatusVerified, ContentType: "image/png", Width: 64, Height: 48, VerifiedAt: 200,
+	}))
+	asset, err = repo.GetAsset(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, domain.AssetStatusVerified, asset.Status)
+	require.Empty(t, asset.Threat)
+	require.Equal(t, 64, asset.Width)
+}
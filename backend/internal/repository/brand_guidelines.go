@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// userBrandGuidelinesIndex lists a user's brand guidelines by creation time
+const userBrandGuidelinesIndex = "UserBrandGuidelinesIndex"
+
+// DynamoDBBrandGuidelinesRepository stores users' brand guidelines, one item per guideline
+type DynamoDBBrandGuidelinesRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewBrandGuidelinesRepository creates a new brand guidelines repository
+func NewBrandGuidelinesRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBBrandGuidelinesRepository {
+	return &DynamoDBBrandGuidelinesRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// CreateBrandGuidelines stores new guidelines; an existing guideline ID is an error
+func (r *DynamoDBBrandGuidelinesRepository) CreateBrandGuidelines(ctx context.Context, guidelines *domain.BrandGuidelines) error {
+	return r.put(ctx, guidelines, false)
+}
+
+// UpdateBrandGuidelines replaces an existing guidelines record (ErrBrandGuidelinesNotFound if
+// it was deleted)
+func (r *DynamoDBBrandGuidelinesRepository) UpdateBrandGuidelines(ctx context.Context, guidelines *domain.BrandGuidelines) error {
+	return r.put(ctx, guidelines, true)
+}
+
+// put writes guidelines over an existing record if exists, or as a new one otherwise
+func (r *DynamoDBBrandGuidelinesRepository) put(ctx context.Context, guidelines *domain.BrandGuidelines, exists bool) error {
+	if err := CheckRecordSize(guidelines); err != nil {
+		return err
+	}
+	item, err := attributevalue.MarshalMap(guidelines)
+	if err != nil {
+		return fmt.Errorf("failed to marshal brand guidelines: %w", err)
+	}
+
+	condition := "attribute_not_exists(guideline_id)"
+	if exists {
+		condition = "attribute_exists(guideline_id)"
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String(condition),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) && exists {
+			return ErrBrandGuidelinesNotFound
+		}
+		r.logger.Error("Failed to put brand guidelines",
+			zap.String("guideline_id", guidelines.GuidelineID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to put brand guidelines: %w", err)
+	}
+	return nil
+}
+
+// GetBrandGuidelines retrieves guidelines by ID (ErrBrandGuidelinesNotFound if missing)
+func (r *DynamoDBBrandGuidelinesRepository) GetBrandGuidelines(ctx context.Context, guidelineID string) (*domain.BrandGuidelines, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"guideline_id": &types.AttributeValueMemberS{Value: guidelineID},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get brand guidelines", zap.String("guideline_id", guidelineID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get brand guidelines: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrBrandGuidelinesNotFound
+	}
+
+	var guidelines domain.BrandGuidelines
+	if err := attributevalue.UnmarshalMap(result.Item, &guidelines); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal brand guidelines: %w", err)
+	}
+	return &guidelines, nil
+}
+
+// ListBrandGuidelinesByUser returns all of a user's guidelines, newest first
+func (r *DynamoDBBrandGuidelinesRepository) ListBrandGuidelinesByUser(ctx context.Context, userID string) ([]*domain.BrandGuidelines, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(userBrandGuidelinesIndex),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	var all []*domain.BrandGuidelines
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to query brand guidelines by user",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to query brand guidelines by user: %w", err)
+		}
+
+		var page []*domain.BrandGuidelines
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal brand guidelines: %w", err)
+		}
+		all = append(all, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return all, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// GetActiveBrandGuidelines retrieves the user's active guidelines, the newest if several are
+// marked active (ErrBrandGuidelinesNotFound if none)
+func (r *DynamoDBBrandGuidelinesRepository) GetActiveBrandGuidelines(ctx context.Context, userID string) (*domain.BrandGuidelines, error) {
+	all, err := r.ListBrandGuidelinesByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, guidelines := range all {
+		if guidelines.IsActive {
+			return guidelines, nil
+		}
+	}
+	return nil, ErrBrandGuidelinesNotFound
+}
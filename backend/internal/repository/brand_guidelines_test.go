@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBrandGuidelinesRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().BrandGuidelinesRepository("brand-guidelines", zap.NewNop())
+
+	_, err := repo.GetActiveBrandGuidelines(ctx, "user-1")
+	require.ErrorIs(t, err, ErrBrandGuidelinesNotFound)
+
+	old := &domain.BrandGuidelines{GuidelineID: "bg-1", UserID: "user-1", Name: "Acme 2023", IsActive: true, CreatedAt: 100}
+	current := &domain.BrandGuidelines{GuidelineID: "bg-2", UserID: "user-1", Name: "Acme", Colors: []string{"#0A84FF"}, CreatedAt: 200}
+	other := &domain.BrandGuidelines{GuidelineID: "bg-3", UserID: "user-2", Name: "Other", IsActive: true, CreatedAt: 300}
+	for _, g := range []*domain.BrandGuidelines{old, current, other} {
+		require.NoError(t, repo.CreateBrandGuidelines(ctx, g))
+	}
+	require.Error(t, repo.CreateBrandGuidelines(ctx, &domain.BrandGuidelines{GuidelineID: "bg-1", UserID: "user-9"}), "IDs are never reused")
+
+	got, err := repo.GetBrandGuidelines(ctx, "bg-2")
+	require.NoError(t, err)
+	require.Equal(t, current, got)
+	_, err = repo.GetBrandGuidelines(ctx, "bg-missing")
+	require.ErrorIs(t, err, ErrBrandGuidelinesNotFound)
+
+	list, err := repo.ListBrandGuidelinesByUser(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, []*domain.BrandGuidelines{current, old}, list, "newest first, only the user's")
+
+	active, err := repo.GetActiveBrandGuidelines(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "bg-1", active.GuidelineID)
+
+	// Activating the newer record makes it the one applied
+	current.IsActive = true
+	require.NoError(t, repo.UpdateBrandGuidelines(ctx, current))
+	active, err = repo.GetActiveBrandGuidelines(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "bg-2", active.GuidelineID)
+
+	require.ErrorIs(t, repo.UpdateBrandGuidelines(ctx, &domain.BrandGuidelines{GuidelineID: "bg-missing", UserID: "user-1"}), ErrBrandGuidelinesNotFound)
+
+	var tooLarge *ItemTooLargeError
+	huge := &domain.BrandGuidelines{GuidelineID: "bg-2", UserID: "user-1", ImageStyle: strings.Repeat("x", MaxItemBytes)}
+	require.True(t, errors.As(repo.UpdateBrandGuidelines(ctx, huge), &tooLarge))
+	require.Equal(t, "image_style", tooLarge.Field)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded or belongs to another user
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// userJobsIndexKeys are the attributes of a UserJobsIndex LastEvaluatedKey (table key + index key)
+var userJobsIndexKeys = []string{"job_id", "user_id", "created_at"}
+
+// statusJobsIndexKeys are the attributes of a StatusJobsIndex LastEvaluatedKey
+var statusJobsIndexKeys = []string{"job_id", "status", "created_at"}
+
+// cursorValue is the JSON form of a key attribute; only string and number keys exist
+type cursorValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+}
+
+// EncodeJobsCursor turns a LastEvaluatedKey into an opaque URL-safe cursor.
+// A nil key (last page) encodes to an empty string.
+func EncodeJobsCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	values := make(map[string]cursorValue, len(key))
+	for name, attr := range key {
+		switch v := attr.(type) {
+		case *types.AttributeValueMemberS:
+			values[name] = cursorValue{S: &v.Value}
+		case *types.AttributeValueMemberN:
+			values[name] = cursorValue{N: &v.Value}
+		default:
+			return "", errors.New("unsupported key attribute type for " + name)
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeJobsCursor parses a cursor from EncodeJobsCursor back into an ExclusiveStartKey
+func DecodeJobsCursor(cursor string) (map[string]types.AttributeValue, error) {
+	return decodeCursor(cursor, userJobsIndexKeys)
+}
+
+// DecodeStatusJobsCursor parses a cursor of a ListJobsByStatus page back into an ExclusiveStartKey
+func DecodeStatusJobsCursor(cursor string) (map[string]types.AttributeValue, error) {
+	return decodeCursor(cursor, statusJobsIndexKeys)
+}
+
+// decodeCursor parses a cursor holding exactly the key attributes in names
+func decodeCursor(cursor string, names []string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var values map[string]cursorValue
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if len(values) != len(names) {
+		return nil, ErrInvalidCursor
+	}
+
+	key := make(map[string]types.AttributeValue, len(values))
+	for _, name := range names {
+		v, ok := values[name]
+		switch {
+		case !ok:
+			return nil, ErrInvalidCursor
+		case v.S != nil && v.N == nil:
+			key[name] = &types.AttributeValueMemberS{Value: *v.S}
+		case v.N != nil && v.S == nil:
+			key[name] = &types.AttributeValueMemberN{Value: *v.N}
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return key, nil
+}
+
+// userJobsIndexKey extracts the UserJobsIndex key of an item, for resuming after it
+func userJobsIndexKey(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	return indexKey(item, userJobsIndexKeys)
+}
+
+// indexKey extracts the named key attributes of an item
+func indexKey(item map[string]types.AttributeValue, names []string) map[string]types.AttributeValue {
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		if v, ok := item[name]; ok {
+			key[name] = v
+		}
+	}
+	return key
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -17,11 +20,26 @@ import (
 var (
 	// ErrJobNotFound is returned when a job is not found
 	ErrJobNotFound = errors.New("job not found")
+
+	// ErrJobNotProcessing is returned by MarkJobComplete when the job already left processing:
+	// it failed, was canceled or was completed by another run
+	ErrJobNotProcessing = errors.New("job is no longer processing")
 )
 
+// dynamoDBAPI is the subset of the DynamoDB client used by the repository
+type dynamoDBAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
 // DynamoDBRepository handles DynamoDB operations for jobs
 type DynamoDBRepository struct {
-	client    *dynamodb.Client
+	client    dynamoDBAPI
 	tableName string
 	logger    *zap.Logger
 }
@@ -41,11 +59,16 @@ func NewDynamoDBRepository(
 
 // CreateJob creates a new job in DynamoDB
 func (r *DynamoDBRepository) CreateJob(ctx context.Context, job *domain.Job) error {
+	job.Version = 1
 	item, err := attributevalue.MarshalMap(job)
 	if err != nil {
 		r.logger.Error("Failed to marshal job", zap.Error(err))
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
+	if err := CheckItemSize(item); err != nil {
+		r.logger.Error("Job too large to store", zap.String("job_id", job.JobID), zap.Error(err))
+		return fmt.Errorf("failed to create job: %w", err)
+	}
 
 	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(r.tableName),
@@ -90,7 +113,7 @@ func (r *DynamoDBRepository) GetJob(ctx context.Context, jobID string) (*domain.
 func (r *DynamoDBRepository) UpdateJobStageWithMetadata(
 	ctx context.Context,
 	jobID string,
-	stage string,
+	stage domain.JobStage,
 	metadata map[string]interface{},
 ) error {
 	metadataAttr, err := attributevalue.Marshal(metadata)
@@ -104,22 +127,24 @@ func (r *DynamoDBRepository) UpdateJobStageWithMetadata(
 		Key: map[string]types.AttributeValue{
 			"job_id": &types.AttributeValueMemberS{Value: jobID},
 		},
-		UpdateExpression: aws.String("SET #stage = :stage, #metadata = :metadata, #updated_at = :updated_at"),
+		UpdateExpression: aws.String("SET #stage = :stage, #metadata = :metadata, #updated_at = :updated_at" + versionIncrement),
 		ExpressionAttributeNames: map[string]string{
 			"#stage":      "stage",
 			"#metadata":   "metadata",
 			"#updated_at": "updated_at",
+			"#version":    "version",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":stage":      &types.AttributeValueMemberS{Value: stage},
+			":stage":      &types.AttributeValueMemberS{Value: stage.String()},
 			":metadata":   metadataAttr,
 			":updated_at": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", getCurrentTimestamp())},
+			":one":        versionStep,
 		},
 	})
 	if err != nil {
 		r.logger.Error("Failed to update job stage with metadata",
 			zap.String("job_id", jobID),
-			zap.String("stage", stage),
+			zap.Stringer("stage", stage),
 			zap.Error(err),
 		)
 		return fmt.Errorf("failed to update job stage with metadata: %w", err)
@@ -128,7 +153,9 @@ func (r *DynamoDBRepository) UpdateJobStageWithMetadata(
 	return nil
 }
 
-// MarkJobComplete marks a job as completed with video URLs (MP4 and optionally WebM)
+// MarkJobComplete marks a processing job as completed with video URLs (MP4 and optionally
+// WebM). It fails with ErrJobNotProcessing once the job has any other status, so a stale or
+// retried run can't overwrite a newer outcome.
 func (r *DynamoDBRepository) MarkJobComplete(ctx context.Context, jobID string, videoKey string, webmVideoKey ...string) error {
 	now := getCurrentTimestamp()
 
@@ -140,13 +167,16 @@ func (r *DynamoDBRepository) MarkJobComplete(ctx context.Context, jobID string,
 		"#video_key":    "video_key",
 		"#completed_at": "completed_at",
 		"#updated_at":   "updated_at",
+		"#version":      "version",
 	}
 	attrValues := map[string]types.AttributeValue{
+		":processing":   &types.AttributeValueMemberS{Value: domain.StatusProcessing},
 		":status":       &types.AttributeValueMemberS{Value: domain.StatusCompleted},
-		":stage":        &types.AttributeValueMemberS{Value: "complete"},
+		":stage":        &types.AttributeValueMemberS{Value: domain.StageComplete.String()},
 		":video_key":    &types.AttributeValueMemberS{Value: videoKey},
 		":completed_at": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
 		":updated_at":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now)},
+		":one":          versionStep,
 	}
 
 	// Add WebM key if provided and non-empty
@@ -161,10 +191,19 @@ func (r *DynamoDBRepository) MarkJobComplete(ctx context.Context, jobID string,
 		Key: map[string]types.AttributeValue{
 			"job_id": &types.AttributeValueMemberS{Value: jobID},
 		},
-		UpdateExpression:          aws.String(updateExpr),
+		UpdateExpression:          aws.String(updateExpr + versionIncrement),
+		ConditionExpression:       aws.String("#status = :processing"),
 		ExpressionAttributeNames:  attrNames,
 		ExpressionAttributeValues: attrValues,
 	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		r.logger.Warn("Job left processing before it was marked complete",
+			zap.String("job_id", jobID),
+			zap.String("video_key", videoKey),
+		)
+		return ErrJobNotProcessing
+	}
 	if err != nil {
 		r.logger.Error("Failed to mark job complete",
 			zap.String("job_id", jobID),
@@ -181,24 +220,39 @@ func (r *DynamoDBRepository) MarkJobComplete(ctx context.Context, jobID string,
 	return nil
 }
 
-// MarkJobFailed marks a job as failed with error message
-func (r *DynamoDBRepository) MarkJobFailed(ctx context.Context, jobID string, errorMsg string) error {
+// MarkJobFailed marks a job as failed with the message shown to its owner. The stage that
+// failed and the raw error behind the message are stored for support staff (empty skips them).
+func (r *DynamoDBRepository) MarkJobFailed(ctx context.Context, jobID string, errorMsg, failureStage, failureError string) error {
+	update := "SET #status = :status, #error_message = :error_message, #updated_at = :updated_at"
+	names := map[string]string{
+		"#status":        "status",
+		"#error_message": "error_message",
+		"#updated_at":    "updated_at",
+		"#version":       "version",
+	}
+	values := map[string]types.AttributeValue{
+		":status":        &types.AttributeValueMemberS{Value: domain.StatusFailed},
+		":error_message": &types.AttributeValueMemberS{Value: errorMsg},
+		":updated_at":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", getCurrentTimestamp())},
+		":one":           versionStep,
+	}
+	if failureStage != "" {
+		update += ", failure_stage = :failure_stage"
+		values[":failure_stage"] = &types.AttributeValueMemberS{Value: failureStage}
+	}
+	if failureError != "" {
+		update += ", failure_error = :failure_error"
+		values[":failure_error"] = &types.AttributeValueMemberS{Value: truncateFailureError(failureError)}
+	}
+
 	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
 			"job_id": &types.AttributeValueMemberS{Value: jobID},
 		},
-		UpdateExpression: aws.String("SET #status = :status, #error_message = :error_message, #updated_at = :updated_at"),
-		ExpressionAttributeNames: map[string]string{
-			"#status":        "status",
-			"#error_message": "error_message",
-			"#updated_at":    "updated_at",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":status":        &types.AttributeValueMemberS{Value: domain.StatusFailed},
-			":error_message": &types.AttributeValueMemberS{Value: errorMsg},
-			":updated_at":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", getCurrentTimestamp())},
-		},
+		UpdateExpression:          aws.String(update + versionIncrement),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
 	})
 	if err != nil {
 		r.logger.Error("Failed to mark job as failed",
@@ -212,31 +266,127 @@ func (r *DynamoDBRepository) MarkJobFailed(ctx context.Context, jobID string, er
 	r.logger.Warn("Job marked as failed",
 		zap.String("job_id", jobID),
 		zap.String("error", errorMsg),
+		zap.String("failure_stage", failureStage),
 	)
 	return nil
 }
 
-// UpdateJob updates an entire job record in DynamoDB
+// maxFailureErrorLength bounds the raw error stored on a failed job; API error bodies can be long
+const maxFailureErrorLength = 4096
+
+// truncateFailureError cuts an error to maxFailureErrorLength bytes without splitting a character
+func truncateFailureError(s string) string {
+	return truncateError(s, maxFailureErrorLength)
+}
+
+// truncateError cuts s to at most limit bytes without splitting a character
+func truncateError(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+// RecordWebhookDelivery stores how many webhook attempts were made for a job, and when
+// delivery succeeded (0 if every attempt failed)
+func (r *DynamoDBRepository) RecordWebhookDelivery(ctx context.Context, jobID string, attempts int, deliveredAt int64) error {
+	updateExpr := "SET #webhook_attempts = :webhook_attempts"
+	attrNames := map[string]string{
+		"#webhook_attempts": "webhook_attempts",
+		"#version":          "version",
+	}
+	attrValues := map[string]types.AttributeValue{
+		":webhook_attempts": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", attempts)},
+		":one":              versionStep,
+	}
+	if deliveredAt > 0 {
+		updateExpr += ", #webhook_delivered_at = :webhook_delivered_at"
+		attrNames["#webhook_delivered_at"] = "webhook_delivered_at"
+		attrValues[":webhook_delivered_at"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", deliveredAt)}
+	}
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:          aws.String(updateExpr + versionIncrement),
+		ExpressionAttributeNames:  attrNames,
+		ExpressionAttributeValues: attrValues,
+	})
+	if err != nil {
+		r.logger.Error("Failed to record webhook delivery",
+			zap.String("job_id", jobID),
+			zap.Int("attempts", attempts),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// UpdateJob updates an entire job record in DynamoDB. The write only succeeds if the stored
+// version still matches job.Version, which is then incremented; otherwise it fails with
+// ErrVersionConflict (or ErrJobCancelRequested if the job was canceled since it was read).
 func (r *DynamoDBRepository) UpdateJob(ctx context.Context, job *domain.Job) error {
 	// Set updated timestamp
 	job.UpdatedAt = time.Now().Unix()
 
+	expected := job.Version
+	job.Version = expected + 1
+
 	// Marshal job to DynamoDB attributes
 	item, err := attributevalue.MarshalMap(job)
 	if err != nil {
+		job.Version = expected
 		r.logger.Error("Failed to marshal job for update",
 			zap.String("job_id", job.JobID),
 			zap.Error(err),
 		)
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
+	if err := CheckItemSize(item); err != nil {
+		job.Version = expected
+		r.logger.Error("Job too large to store", zap.String("job_id", job.JobID), zap.Error(err))
+		return fmt.Errorf("failed to update job: %w", err)
+	}
 
-	// Use PutItem to replace entire record
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
-	})
+	// Use PutItem to replace entire record, conditional on nobody having written it since it was read
+	condition := "#version = :version"
+	values := map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(expected, 10)},
+	}
+	if expected == 0 {
+		// Jobs written before versioning have no version attribute
+		condition = "attribute_exists(job_id) AND attribute_not_exists(#version)"
+		values = map[string]types.AttributeValue{}
+	}
+	// A copy read before the job was canceled must not clear the cancel request
+	if !job.CancelRequested {
+		condition += " AND (attribute_not_exists(cancel_requested) OR cancel_requested = :false)"
+		values[":false"] = &types.AttributeValueMemberBOOL{Value: false}
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:                           aws.String(r.tableName),
+		Item:                                item,
+		ConditionExpression:                 aws.String(condition),
+		ExpressionAttributeNames:            map[string]string{"#version": "version"},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+	_, err = r.client.PutItem(ctx, input)
 	if err != nil {
+		job.Version = expected
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return r.jobWriteConflict(job, ccf.Item)
+		}
 		r.logger.Error("Failed to update job",
 			zap.String("job_id", job.JobID),
 			zap.Error(err),
@@ -247,7 +397,8 @@ func (r *DynamoDBRepository) UpdateJob(ctx context.Context, job *domain.Job) err
 	r.logger.Info("Job updated successfully",
 		zap.String("job_id", job.JobID),
 		zap.String("status", job.Status),
-		zap.String("stage", job.Stage),
+		zap.Stringer("stage", job.Stage),
+		zap.Int64("version", job.Version),
 	)
 	return nil
 }
@@ -257,52 +408,170 @@ func getCurrentTimestamp() int64 {
 	return time.Now().Unix()
 }
 
-// GetJobsByUser retrieves all jobs for a user, sorted by creation time (newest first)
-func (r *DynamoDBRepository) GetJobsByUser(ctx context.Context, userID string, limit int, status string) ([]*domain.Job, error) {
+// DefaultMaxScannedJobs bounds how many jobs one list request reads while filtering
+const DefaultMaxScannedJobs = 1000
+
+// JobsQuery selects one page of a user's jobs
+type JobsQuery struct {
+	Limit  int    // Jobs per page (must be positive)
+	Status string // Exact status match; empty matches all
+
+	// Search is a case-insensitive substring matched against prompt and title.
+	// DynamoDB's contains() is case-sensitive, so this runs in the repository after each query.
+	Search string
+
+	CreatedAfter  int64 // Unix seconds, inclusive; 0 is unbounded
+	CreatedBefore int64 // Unix seconds, inclusive; 0 is unbounded
+
+	ExclusiveStartKey map[string]types.AttributeValue // Previous page's LastEvaluatedKey; nil for the first page
+	MaxScanned        int                             // Items read before giving up on filling the page; 0 uses DefaultMaxScannedJobs
+}
+
+// matches applies the filters DynamoDB can't evaluate
+func (q JobsQuery) matches(job *domain.Job) bool {
+	if q.Search == "" {
+		return true
+	}
+	search := strings.ToLower(q.Search)
+	return strings.Contains(strings.ToLower(job.Prompt), search) ||
+		strings.Contains(strings.ToLower(job.Title), search)
+}
+
+// userJobsIndex is the GSI keyed by user_id and created_at, used for a user's job history and queue
+const userJobsIndex = "UserJobsIndex"
+
+// keyCondition narrows the UserJobsIndex query to the user and created_at range
+func (q JobsQuery) keyCondition(values map[string]types.AttributeValue) string {
+	condition := "user_id = :user_id"
+	switch {
+	case q.CreatedAfter > 0 && q.CreatedBefore > 0:
+		condition += " AND created_at BETWEEN :created_after AND :created_before"
+	case q.CreatedAfter > 0:
+		condition += " AND created_at >= :created_after"
+	case q.CreatedBefore > 0:
+		condition += " AND created_at <= :created_before"
+	}
+	if q.CreatedAfter > 0 {
+		values[":created_after"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(q.CreatedAfter, 10)}
+	}
+	if q.CreatedBefore > 0 {
+		values[":created_before"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(q.CreatedBefore, 10)}
+	}
+	return condition
+}
+
+// JobsPage is one page of a user's jobs, newest first
+type JobsPage struct {
+	Jobs []*domain.Job
+
+	// LastEvaluatedKey is the ExclusiveStartKey for the next page; nil when there are no more jobs.
+	// A full page may still be followed by an empty one, as with any DynamoDB query.
+	LastEvaluatedKey map[string]types.AttributeValue
+
+	Scanned   int  // Items DynamoDB read for this page
+	Truncated bool // MaxScanned was reached before the page filled; follow LastEvaluatedKey to keep searching
+}
+
+// GetJobsByUser retrieves one page of a user's jobs, sorted by creation time (newest first).
+// The date range is part of the key condition and the status filter runs inside the query;
+// the search filter runs here. Queries continue until Limit matching jobs are found, the
+// partition is exhausted or MaxScanned items have been read, so filtered pages are as full
+// as unfiltered ones.
+func (r *DynamoDBRepository) GetJobsByUser(ctx context.Context, userID string, query JobsQuery) (*JobsPage, error) {
+	if query.Limit < 1 {
+		return nil, fmt.Errorf("limit must be positive, got %d", query.Limit)
+	}
+	if query.ExclusiveStartKey != nil {
+		owner, ok := query.ExclusiveStartKey["user_id"].(*types.AttributeValueMemberS)
+		if !ok || owner.Value != userID {
+			return nil, ErrInvalidCursor
+		}
+	}
+	maxScanned := query.MaxScanned
+	if maxScanned <= 0 {
+		maxScanned = DefaultMaxScannedJobs
+	}
+
+	values := map[string]types.AttributeValue{
+		":user_id": &types.AttributeValueMemberS{Value: userID},
+	}
 	input := &dynamodb.QueryInput{
-		TableName:              aws.String(r.tableName),
-		IndexName:              aws.String("UserJobsIndex"),
-		KeyConditionExpression: aws.String("user_id = :user_id"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":user_id": &types.AttributeValueMemberS{Value: userID},
-		},
-		ScanIndexForward: aws.Bool(false), // Sort by created_at descending (newest first)
-		Limit:            aws.Int32(int32(limit)),
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(userJobsIndex),
+		KeyConditionExpression:    aws.String(query.keyCondition(values)),
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false), // Sort by created_at descending (newest first)
 	}
 
 	// Add status filter if provided
-	if status != "" {
+	if query.Status != "" {
 		input.FilterExpression = aws.String("#status = :status")
 		input.ExpressionAttributeNames = map[string]string{
 			"#status": "status",
 		}
-		input.ExpressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: status}
+		values[":status"] = &types.AttributeValueMemberS{Value: query.Status}
 	}
 
-	result, err := r.client.Query(ctx, input)
-	if err != nil {
-		r.logger.Error("Failed to query jobs by user",
-			zap.String("user_id", userID),
-			zap.Error(err),
-		)
-		return nil, fmt.Errorf("failed to query jobs by user: %w", err)
-	}
+	page := &JobsPage{}
+	startKey := query.ExclusiveStartKey
+	for {
+		// Limit caps items evaluated before the filter, so a filtered query may need several calls
+		input.ExclusiveStartKey = startKey
+		input.Limit = aws.Int32(int32(min(query.Limit, maxScanned-page.Scanned)))
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to query jobs by user",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to query jobs by user: %w", err)
+		}
+		page.Scanned += int(result.ScannedCount)
+
+		var jobs []*domain.Job
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &jobs); err != nil {
+			r.logger.Error("Failed to unmarshal jobs",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to unmarshal jobs: %w", err)
+		}
 
-	var jobs []*domain.Job
-	if err := attributevalue.UnmarshalListOfMaps(result.Items, &jobs); err != nil {
-		r.logger.Error("Failed to unmarshal jobs",
-			zap.String("user_id", userID),
-			zap.Error(err),
-		)
-		return nil, fmt.Errorf("failed to unmarshal jobs: %w", err)
+		full := false
+		for i, job := range jobs {
+			if !query.matches(job) {
+				continue
+			}
+			page.Jobs = append(page.Jobs, job)
+			if len(page.Jobs) == query.Limit {
+				// Resume after this job unless it was the very last one
+				if i < len(jobs)-1 || len(result.LastEvaluatedKey) > 0 {
+					page.LastEvaluatedKey = userJobsIndexKey(result.Items[i])
+				}
+				full = true
+				break
+			}
+		}
+
+		startKey = result.LastEvaluatedKey
+		if full || len(startKey) == 0 {
+			break
+		}
+		if page.Scanned >= maxScanned {
+			page.LastEvaluatedKey = startKey
+			page.Truncated = true
+			break
+		}
 	}
 
 	r.logger.Info("Retrieved user jobs",
 		zap.String("user_id", userID),
-		zap.Int("count", len(jobs)),
+		zap.Int("count", len(page.Jobs)),
+		zap.Int("scanned", page.Scanned),
+		zap.Bool("has_more", page.LastEvaluatedKey != nil),
 	)
 
-	return jobs, nil
+	return page, nil
 }
 
 // DeleteJob deletes a job from DynamoDB
@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeDynamoDB emulates UserJobsIndex and StatusJobsIndex queries: Limit caps the items
+// evaluated, and the status (or failure stage) filter is applied after the limit, as DynamoDB does
+type fakeDynamoDB struct {
+	items   []map[string]types.AttributeValue
+	queries int
+}
+
+func newFakeDynamoDB(t *testing.T, jobs ...*domain.Job) *fakeDynamoDB {
+	t.Helper()
+	db := &fakeDynamoDB{}
+	for _, job := range jobs {
+		item, err := attributevalue.MarshalMap(job)
+		require.NoError(t, err)
+		db.items = append(db.items, item)
+	}
+	return db
+}
+
+func attrS(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func attrN(item map[string]types.AttributeValue, name string) int64 {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	}
+	return 0
+}
+
+func ccfWithItem(item map[string]types.AttributeValue) error {
+	return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed"), Item: item}
+}
+
+func (f *fakeDynamoDB) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.queries++
+	partition, filtered, keys := "user_id", "status", userJobsIndexKeys
+	switch aws.ToString(in.IndexName) {
+	case "UserJobsIndex":
+	case statusJobsIndex:
+		partition, filtered, keys = "status", "failure_stage", statusJobsIndexKeys
+	default:
+		return nil, errors.New("unexpected index")
+	}
+	partitionValue := attrS(in.ExpressionAttributeValues, ":"+partition)
+
+	condition := aws.ToString(in.KeyConditionExpression)
+	after := attrN(in.ExpressionAttributeValues, ":created_after")
+	before := attrN(in.ExpressionAttributeValues, ":created_before")
+
+	var matching []map[string]types.AttributeValue
+	for _, item := range f.items {
+		if attrS(item, partition) != partitionValue {
+			continue
+		}
+		createdAt := attrN(item, "created_at")
+		if strings.Contains(condition, ":created_after") && createdAt < after {
+			continue
+		}
+		if strings.Contains(condition, ":created_before") && createdAt > before {
+			continue
+		}
+		matching = append(matching, item)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return attrN(matching[i], "created_at") > attrN(matching[j], "created_at")
+	})
+
+	start := 0
+	if in.ExclusiveStartKey != nil {
+		start = len(matching)
+		for i, item := range matching {
+			if attrS(item, "job_id") == attrS(in.ExclusiveStartKey, "job_id") {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	end := len(matching)
+	if in.Limit != nil && start+int(*in.Limit) < end {
+		end = start + int(*in.Limit)
+	}
+
+	out := &dynamodb.QueryOutput{ScannedCount: int32(end - start)}
+	for _, item := range matching[start:end] {
+		if in.FilterExpression != nil && attrS(item, filtered) != attrS(in.ExpressionAttributeValues, ":"+filtered) {
+			continue
+		}
+		out.Items = append(out.Items, item)
+	}
+	if end < len(matching) {
+		out.LastEvaluatedKey = indexKey(matching[end-1], keys)
+	}
+	return out, nil
+}
+
+func (f *fakeDynamoDB) PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeDynamoDB) GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeDynamoDB) UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeDynamoDB) DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeDynamoDB) DescribeTable(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeDynamoDB) TransactWriteItems(context.Context, *dynamodb.TransactWriteItemsInput, ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+// seedJobs creates n jobs for user-1, newest last; every third job has failed
+// and every fourth is a kitchen ad
+func seedJobs(n int) []*domain.Job {
+	jobs := make([]*domain.Job, 0, n+1)
+	for i := 1; i <= n; i++ {
+		status := "completed"
+		if i%3 == 0 {
+			status = "failed"
+		}
+		prompt := "Sunny beach resort ad"
+		if i%4 == 0 {
+			prompt = "Modern KITCHEN renovation ad"
+		}
+		jobs = append(jobs, &domain.Job{
+			JobID:     fmt.Sprintf("job-%02d", i),
+			UserID:    "user-1",
+			Status:    status,
+			Prompt:    prompt,
+			Title:     fmt.Sprintf("Spot %d", i),
+			CreatedAt: int64(1000 + i),
+		})
+	}
+	// Another user's job must never appear
+	jobs = append(jobs, &domain.Job{JobID: "other", UserID: "user-2", Status: "completed", CreatedAt: 5000})
+	return jobs
+}
+
+func jobIDs(jobs []*domain.Job) []string {
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.JobID
+	}
+	return ids
+}
+
+func newTestJobRepository(db dynamoDBAPI) *DynamoDBRepository {
+	return &DynamoDBRepository{client: db, tableName: "jobs", logger: zap.NewNop()}
+}
+
+func TestGetJobsByUser_PaginatesThroughAllJobs(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedJobs(7)...))
+	ctx := context.Background()
+
+	var pages [][]string
+	var startKey map[string]types.AttributeValue
+	for {
+		page, err := repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 3, ExclusiveStartKey: startKey})
+		require.NoError(t, err)
+		pages = append(pages, jobIDs(page.Jobs))
+		if page.LastEvaluatedKey == nil {
+			break
+		}
+
+		// Round-trip through the opaque cursor like the handler does
+		cursor, err := EncodeJobsCursor(page.LastEvaluatedKey)
+		require.NoError(t, err)
+		startKey, err = DecodeJobsCursor(cursor)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, [][]string{
+		{"job-07", "job-06", "job-05"},
+		{"job-04", "job-03", "job-02"},
+		{"job-01"},
+	}, pages)
+}
+
+func TestGetJobsByUser_FilteredPagesAreFull(t *testing.T) {
+	db := newFakeDynamoDB(t, seedJobs(12)...)
+	repo := newTestJobRepository(db)
+	ctx := context.Background()
+
+	// Failed jobs are 12, 9, 6, 3: the filter runs inside the query, so a page of two keeps
+	// querying past non-matching jobs instead of returning a short page
+	page, err := repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 2, Status: "failed"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-12", "job-09"}, jobIDs(page.Jobs))
+	require.NotNil(t, page.LastEvaluatedKey)
+	require.Greater(t, db.queries, 1)
+
+	page, err = repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 2, Status: "failed", ExclusiveStartKey: page.LastEvaluatedKey})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-06", "job-03"}, jobIDs(page.Jobs))
+
+	page, err = repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 2, Status: "failed", ExclusiveStartKey: page.LastEvaluatedKey})
+	require.NoError(t, err)
+	require.Empty(t, page.Jobs)
+	require.Nil(t, page.LastEvaluatedKey)
+}
+
+func TestGetJobsByUser_TrimsOverfullQueryAndResumesAfterLastJob(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedJobs(12)...))
+	ctx := context.Background()
+
+	// Page of 3 completed jobs: the first query evaluates 12, 11, 10 (two match), the second
+	// evaluates up to 3 more and may return more matches than the page needs
+	page, err := repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 3, Status: "completed"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-11", "job-10", "job-08"}, jobIDs(page.Jobs))
+	require.Equal(t, "job-08", attrS(page.LastEvaluatedKey, "job_id"))
+
+	page, err = repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 3, Status: "completed", ExclusiveStartKey: page.LastEvaluatedKey})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-07", "job-05", "job-04"}, jobIDs(page.Jobs))
+}
+
+func TestGetJobsByUser_SearchIsCaseInsensitiveAcrossPromptAndTitle(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedJobs(12)...))
+	ctx := context.Background()
+
+	page, err := repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 2, Search: "kitchen"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-12", "job-08"}, jobIDs(page.Jobs))
+	require.NotNil(t, page.LastEvaluatedKey)
+
+	page, err = repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 2, Search: "kitchen", ExclusiveStartKey: page.LastEvaluatedKey})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-04"}, jobIDs(page.Jobs))
+	require.Nil(t, page.LastEvaluatedKey)
+
+	page, err = repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 5, Search: "SPOT 1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-12", "job-11", "job-10", "job-01"}, jobIDs(page.Jobs))
+}
+
+func TestGetJobsByUser_CombinesSearchStatusAndDateRange(t *testing.T) {
+	db := newFakeDynamoDB(t, seedJobs(12)...)
+	repo := newTestJobRepository(db)
+
+	// Kitchen ads are 4, 8, 12; of those only 12 failed, and the range excludes it
+	page, err := repo.GetJobsByUser(context.Background(), "user-1", JobsQuery{
+		Limit:         10,
+		Search:        "kitchen",
+		CreatedAfter:  1004,
+		CreatedBefore: 1011,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-08", "job-04"}, jobIDs(page.Jobs))
+	require.Equal(t, 8, page.Scanned, "only the date range is read")
+
+	page, err = repo.GetJobsByUser(context.Background(), "user-1", JobsQuery{
+		Limit:        10,
+		Status:       "failed",
+		Search:       "kitchen",
+		CreatedAfter: 1010,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-12"}, jobIDs(page.Jobs))
+
+	page, err = repo.GetJobsByUser(context.Background(), "user-1", JobsQuery{Limit: 10, CreatedBefore: 1002})
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-02", "job-01"}, jobIDs(page.Jobs))
+}
+
+func TestGetJobsByUser_StopsAtMaxScanned(t *testing.T) {
+	db := newFakeDynamoDB(t, seedJobs(12)...)
+	repo := newTestJobRepository(db)
+	ctx := context.Background()
+
+	// No job matches, so without the bound the whole partition would be read
+	page, err := repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 3, Search: "nothing matches", MaxScanned: 5})
+	require.NoError(t, err)
+	require.Empty(t, page.Jobs)
+	require.True(t, page.Truncated)
+	require.Equal(t, 5, page.Scanned)
+	require.Equal(t, "job-08", attrS(page.LastEvaluatedKey, "job_id"))
+
+	// The cursor resumes the search where the bound stopped it
+	page, err = repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 3, Search: "nothing matches", MaxScanned: 5, ExclusiveStartKey: page.LastEvaluatedKey})
+	require.NoError(t, err)
+	require.Equal(t, 5, page.Scanned)
+	require.Equal(t, "job-03", attrS(page.LastEvaluatedKey, "job_id"))
+}
+
+func TestGetJobsByUser_RejectsAnotherUsersCursor(t *testing.T) {
+	repo := newTestJobRepository(newFakeDynamoDB(t, seedJobs(3)...))
+
+	startKey := map[string]types.AttributeValue{
+		"job_id":     &types.AttributeValueMemberS{Value: "other"},
+		"user_id":    &types.AttributeValueMemberS{Value: "user-2"},
+		"created_at": &types.AttributeValueMemberN{Value: "5000"},
+	}
+	_, err := repo.GetJobsByUser(context.Background(), "user-1", JobsQuery{Limit: 3, ExclusiveStartKey: startKey})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestJobsCursorRoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"job_id":     &types.AttributeValueMemberS{Value: "job-1"},
+		"user_id":    &types.AttributeValueMemberS{Value: "user-1"},
+		"created_at": &types.AttributeValueMemberN{Value: "1731000000"},
+	}
+	cursor, err := EncodeJobsCursor(key)
+	require.NoError(t, err)
+	require.NotContains(t, cursor, "=")
+
+	decoded, err := DecodeJobsCursor(cursor)
+	require.NoError(t, err)
+	require.Equal(t, key, decoded)
+
+	empty, err := EncodeJobsCursor(nil)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+	decoded, err = DecodeJobsCursor("")
+	require.NoError(t, err)
+	require.Nil(t, decoded)
+}
+
+func TestDecodeJobsCursor_Invalid(t *testing.T) {
+	for name, cursor := range map[string]string{
+		"not base64":   "!!!",
+		"not json":     rawCursor("not json"),
+		"missing keys": rawCursor(`{"job_id":{"S":"a"}}`),
+		"extra keys":   rawCursor(`{"job_id":{"S":"a"},"user_id":{"S":"b"},"created_at":{"N":"1"},"status":{"S":"x"}}`),
+		"both types":   rawCursor(`{"job_id":{"S":"a","N":"1"},"user_id":{"S":"b"},"created_at":{"N":"1"}}`),
+		"wrong key":    rawCursor(`{"job_id":{"S":"a"},"user_id":{"S":"b"},"status":{"S":"x"}}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeJobsCursor(cursor)
+			require.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func rawCursor(raw string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
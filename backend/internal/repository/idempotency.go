@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrIdempotencyKeyInUse is returned by ReserveIdempotencyKey when an unexpired record
+// already holds the key
+var ErrIdempotencyKeyInUse = errors.New("idempotency key already used")
+
+// DynamoDBIdempotencyRepository stores POST /generate idempotency keys
+type DynamoDBIdempotencyRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewIdempotencyRepository creates a new idempotency key repository
+func NewIdempotencyRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBIdempotencyRepository {
+	return &DynamoDBIdempotencyRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// IdempotencyRecordKey is the table key for a user's idempotency key
+func IdempotencyRecordKey(userID, key string) string {
+	return userID + "#" + key
+}
+
+// ReserveIdempotencyKey stores record unless an unexpired record holds its key, in which case
+// it returns that record with ErrIdempotencyKeyInUse. now is compared against the stored
+// expiry because DynamoDB deletes expired items lazily.
+func (r *DynamoDBIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord, now int64) (*domain.IdempotencyRecord, error) {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(idempotency_key) OR #ttl <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err == nil {
+		return nil, nil
+	}
+
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		r.logger.Error("Failed to reserve idempotency key",
+			zap.String("user_id", record.UserID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	var existing domain.IdempotencyRecord
+	if err := attributevalue.UnmarshalMap(ccf.Item, &existing); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+	}
+	return &existing, ErrIdempotencyKeyInUse
+}
+
+// ReleaseIdempotencyKey deletes a key's record if it still points at jobID, so a request that
+// didn't create its job can be retried with the same key
+func (r *DynamoDBIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, recordKey, jobID string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"idempotency_key": &types.AttributeValueMemberS{Value: recordKey},
+		},
+		ConditionExpression: aws.String("job_id = :job_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			// Another request already took the key over
+			return nil
+		}
+		r.logger.Error("Failed to release idempotency key",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeIdempotencyTable applies the reservation and release conditions the way DynamoDB does
+type fakeIdempotencyTable struct {
+	*fakeDynamoDB
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeIdempotencyTable(t *testing.T) *fakeIdempotencyTable {
+	return &fakeIdempotencyTable{fakeDynamoDB: newFakeDynamoDB(t), items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func (f *fakeIdempotencyTable) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	key := attrS(in.Item, "idempotency_key")
+	now, _ := strconv.ParseInt(in.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberN).Value, 10, 64)
+	if existing, ok := f.items[key]; ok && attrN(existing, "ttl") > now {
+		return nil, ccfWithItem(existing)
+	}
+	f.items[key] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeIdempotencyTable) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	key := attrS(in.Key, "idempotency_key")
+	existing, ok := f.items[key]
+	if !ok || attrS(existing, "job_id") != attrS(in.ExpressionAttributeValues, ":job_id") {
+		return nil, ccfWithItem(nil)
+	}
+	delete(f.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func testIdempotencyRecord(jobID string, createdAt int64) *domain.IdempotencyRecord {
+	return &domain.IdempotencyRecord{
+		Key:         IdempotencyRecordKey("user-1", "retry-1"),
+		UserID:      "user-1",
+		JobID:       jobID,
+		RequestHash: "hash",
+		CreatedAt:   createdAt,
+		ExpiresAt:   createdAt + 86400,
+	}
+}
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	db := newFakeIdempotencyTable(t)
+	repo := &DynamoDBIdempotencyRepository{client: db, tableName: "idempotency", logger: zap.NewNop()}
+	ctx := context.Background()
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, testIdempotencyRecord("job-1", 1000), 1000)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+
+	// A repeat within the TTL returns the first reservation
+	existing, err = repo.ReserveIdempotencyKey(ctx, testIdempotencyRecord("job-2", 2000), 2000)
+	require.ErrorIs(t, err, ErrIdempotencyKeyInUse)
+	require.Equal(t, "job-1", existing.JobID)
+	require.Equal(t, "hash", existing.RequestHash)
+
+	// An expired record that TTL hasn't deleted yet is replaced
+	existing, err = repo.ReserveIdempotencyKey(ctx, testIdempotencyRecord("job-3", 1000+86400), 1000+86400)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+
+	var stored domain.IdempotencyRecord
+	require.NoError(t, attributevalue.UnmarshalMap(db.items[IdempotencyRecordKey("user-1", "retry-1")], &stored))
+	require.Equal(t, "job-3", stored.JobID)
+}
+
+func TestReleaseIdempotencyKey_OnlyReleasesOwnJob(t *testing.T) {
+	db := newFakeIdempotencyTable(t)
+	repo := &DynamoDBIdempotencyRepository{client: db, tableName: "idempotency", logger: zap.NewNop()}
+	ctx := context.Background()
+	key := IdempotencyRecordKey("user-1", "retry-1")
+
+	_, err := repo.ReserveIdempotencyKey(ctx, testIdempotencyRecord("job-1", 1000), 1000)
+	require.NoError(t, err)
+
+	// A request that lost the key to a takeover leaves the new owner alone
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, key, "job-0"))
+	require.Contains(t, db.items, key)
+
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, key, "job-1"))
+	require.NotContains(t, db.items, key)
+}
@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/omnigen/backend/internal/domain"
 )
 
+// ErrBrandGuidelinesNotFound is returned when brand guidelines do not exist
+var ErrBrandGuidelinesNotFound = errors.New("brand guidelines not found")
+
 // JobRepository defines the interface for job persistence operations
 type JobRepository interface {
 	// CreateJob creates a new job record
@@ -15,24 +19,83 @@ type JobRepository interface {
 	// GetJob retrieves a job by ID
 	GetJob(ctx context.Context, jobID string) (*domain.Job, error)
 
-	// GetJobsByUser retrieves all jobs for a user, optionally filtered by status
-	GetJobsByUser(ctx context.Context, userID string, limit int, status string) ([]*domain.Job, error)
+	// GetJobsByUser retrieves one page of a user's jobs matching query
+	GetJobsByUser(ctx context.Context, userID string, query JobsQuery) (*JobsPage, error)
+
+	// ListJobsByStatus retrieves one page of every user's jobs in a status, for support staff
+	ListJobsByStatus(ctx context.Context, query StatusJobsQuery) (*JobsPage, error)
 
 	// UpdateJobStageWithMetadata updates stage and metadata atomically
-	UpdateJobStageWithMetadata(ctx context.Context, jobID string, stage string, metadata map[string]interface{}) error
+	UpdateJobStageWithMetadata(ctx context.Context, jobID string, stage domain.JobStage, metadata map[string]interface{}) error
 
-	// MarkJobComplete marks a job as completed with video keys (MP4 required, WebM optional)
+	// MarkJobComplete marks a processing job as completed with video keys (MP4 required, WebM
+	// optional); ErrJobNotProcessing once the job has another status
 	MarkJobComplete(ctx context.Context, jobID string, videoKey string, webmVideoKey ...string) error
 
-	// MarkJobFailed marks a job as failed with error message
-	MarkJobFailed(ctx context.Context, jobID string, errorMsg string) error
+	// MarkJobFailed marks a job as failed with the user-facing message, the failed stage and the raw error
+	MarkJobFailed(ctx context.Context, jobID string, errorMsg, failureStage, failureError string) error
+
+	// AppendJobProvenance records a finished provider call on a job and returns its new version
+	AppendJobProvenance(ctx context.Context, jobID string, entry domain.ProvenanceEntry) (int64, error)
+
+	// RecordJobStepStats stores a finished pipeline step's stats on a job and returns its new version
+	RecordJobStepStats(ctx context.Context, jobID, step string, stats domain.StepStats) (int64, error)
 
-	// UpdateJob updates an entire job record atomically
+	// UpdateJobCompositionProgress records how far a job's current composition pass has got
+	UpdateJobCompositionProgress(ctx context.Context, jobID string, progress domain.CompositionProgress) error
+
+	// RecordWebhookDelivery stores webhook attempts and the delivery time (0 if undelivered)
+	RecordWebhookDelivery(ctx context.Context, jobID string, attempts int, deliveredAt int64) error
+
+	// UpdateJob updates an entire job record atomically. Fails with ErrVersionConflict if the
+	// job was written since it was read, or ErrJobCancelRequested if it was canceled.
 	UpdateJob(ctx context.Context, job *domain.Job) error
 
+	// UpdateJobWithRetry is UpdateJob that reloads the job and re-applies apply on a version conflict
+	UpdateJobWithRetry(ctx context.Context, job *domain.Job, apply func(job *domain.Job)) error
+
+	// UpdateJobStage writes only the job's stage and scenes completed
+	UpdateJobStage(ctx context.Context, job *domain.Job) error
+
 	// DeleteJob deletes a job by ID
 	DeleteJob(ctx context.Context, jobID string) error
 
+	// CountActiveJobs counts a user's processing jobs updated at or after activeSince, not counting variant parents
+	CountActiveJobs(ctx context.Context, userID string, activeSince int64) (int, error)
+
+	// ListVariantJobs returns an A/B variant parent's child jobs, ordered by variant index
+	ListVariantJobs(ctx context.Context, parentJobID string) ([]*domain.Job, error)
+
+	// ListQueuedJobs returns queued jobs oldest first, for one user or all users when userID is empty
+	ListQueuedJobs(ctx context.Context, userID string) ([]*domain.Job, error)
+
+	// ListExpiredJobs returns jobs in status whose retention expired at or before expiredBy
+	ListExpiredJobs(ctx context.Context, status string, expiredBy int64) ([]*domain.Job, error)
+
+	// SetJobAssets replaces the asset sizes recorded on a job, returning the sizes it replaced
+	SetJobAssets(ctx context.Context, jobID string, assets map[string]int64) (map[string]int64, error)
+
+	// BackfillJobAssets records asset sizes on a job with none, failing with ErrAssetsRecorded otherwise
+	BackfillJobAssets(ctx context.Context, jobID string, assets map[string]int64) error
+
+	// ListJobsWithoutAssets returns jobs in status that have no asset sizes recorded
+	ListJobsWithoutAssets(ctx context.Context, status string) ([]*domain.Job, error)
+
+	// ClaimQueuedJob moves a queued job to processing, failing with ErrJobNotQueued if it isn't queued
+	ClaimQueuedJob(ctx context.Context, jobID string) error
+
+	// CancelIdleJob cancels a pending, queued or script_ready job, failing with ErrJobNotCancelable otherwise
+	CancelIdleJob(ctx context.Context, jobID string) error
+
+	// RequestJobCancel flags a processing job for cancellation, failing with ErrJobNotCancelable otherwise
+	RequestJobCancel(ctx context.Context, jobID string) error
+
+	// IsCancelRequested reports whether cancellation was requested for a job
+	IsCancelRequested(ctx context.Context, jobID string) (bool, error)
+
+	// MarkJobCanceled moves a job whose pipeline stopped to the canceled status
+	MarkJobCanceled(ctx context.Context, jobID string) error
+
 	// HealthCheck verifies the repository is operational
 	HealthCheck(ctx context.Context) error
 }
@@ -48,8 +111,8 @@ type AssetRepository interface {
 	// DownloadFile downloads a file from storage
 	DownloadFile(ctx context.Context, bucket, key, destPath string) error
 
-	// DeletePrefix deletes all assets under a prefix (best-effort cleanup)
-	DeletePrefix(ctx context.Context, bucket, prefix string) error
+	// DeletePrefix deletes all assets under a prefix (best-effort cleanup) and returns how many were deleted
+	DeletePrefix(ctx context.Context, bucket, prefix string) (int, error)
 
 	// HealthCheck verifies the repository is operational
 	HealthCheck(ctx context.Context) error
@@ -65,4 +128,122 @@ type UsageRepository interface {
 
 	// IncrementUsage increments usage counters
 	IncrementUsage(ctx context.Context, userID string, videoDuration int) error
+
+	// AddTTSCharacters adds characters synthesized outside a job (e.g. voice previews) to the current period
+	AddTTSCharacters(ctx context.Context, userID string, chars int) error
+
+	// ChargeCredits atomically deducts a job's credits (*InsufficientCreditsError if the balance is too low)
+	ChargeCredits(ctx context.Context, userID, subscriptionTier string, charge domain.CreditCharge) (*domain.Usage, error)
+
+	// RefundCredits returns credits for a job charged in period (ErrChargeNotRefundable if already refunded)
+	RefundCredits(ctx context.Context, userID, period, jobID string, credits int) error
+
+	// ListCharges returns the job charges made in period
+	ListCharges(ctx context.Context, userID, period string) ([]domain.CreditCharge, error)
+
+	// AddStorageBytes atomically adds delta (negative for deletions) to the bytes a user has stored
+	AddStorageBytes(ctx context.Context, userID string, delta int64) error
+
+	// SetStorageBytes overwrites a user's storage total
+	SetStorageBytes(ctx context.Context, userID string, total int64) error
+
+	// GetStorageBytes returns the bytes a user has stored (0 if never recorded)
+	GetStorageBytes(ctx context.Context, userID string) (int64, error)
+}
+
+// BrandGuidelinesRepository defines access to stored brand guidelines
+type BrandGuidelinesRepository interface {
+	// GetBrandGuidelines retrieves guidelines by ID (ErrBrandGuidelinesNotFound if missing)
+	GetBrandGuidelines(ctx context.Context, guidelineID string) (*domain.BrandGuidelines, error)
+
+	// CreateBrandGuidelines stores new guidelines. A record over MaxItemBytes fails with
+	// *ItemTooLargeError before anything is written (see CheckRecordSize).
+	CreateBrandGuidelines(ctx context.Context, guidelines *domain.BrandGuidelines) error
+
+	// ListBrandGuidelinesByUser returns all of a user's guidelines, newest first
+	ListBrandGuidelinesByUser(ctx context.Context, userID string) ([]*domain.BrandGuidelines, error)
+
+	// GetActiveBrandGuidelines retrieves the user's active guidelines (ErrBrandGuidelinesNotFound if none)
+	GetActiveBrandGuidelines(ctx context.Context, userID string) (*domain.BrandGuidelines, error)
+
+	// UpdateBrandGuidelines replaces an existing guidelines record (ErrBrandGuidelinesNotFound if
+	// it is gone). A record over MaxItemBytes fails with *ItemTooLargeError before anything is
+	// written (see CheckRecordSize).
+	UpdateBrandGuidelines(ctx context.Context, guidelines *domain.BrandGuidelines) error
+}
+
+// IdempotencyRepository stores the job created for each POST /generate Idempotency-Key
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey stores a record, or returns the unexpired one holding its key with ErrIdempotencyKeyInUse
+	ReserveIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord, now int64) (*domain.IdempotencyRecord, error)
+
+	// ReleaseIdempotencyKey deletes a key's record if it still points at jobID
+	ReleaseIdempotencyKey(ctx context.Context, recordKey, jobID string) error
+}
+
+// ScriptsRepository stores generated scripts for reuse as new jobs
+type ScriptsRepository interface {
+	// SaveScript stores a script, replacing any with the same ID
+	SaveScript(ctx context.Context, script *domain.Script) error
+
+	// GetScript retrieves an unexpired script by ID (ErrScriptNotFound if missing or expired)
+	GetScript(ctx context.Context, scriptID string) (*domain.Script, error)
+
+	// ListScriptsByUser retrieves one page of a user's unexpired scripts, newest first
+	ListScriptsByUser(ctx context.Context, userID string, query ScriptsQuery) (*ScriptsPage, error)
+}
+
+// AssetsRepository stores the verification results of uploaded assets
+type AssetsRepository interface {
+	// SaveAsset stores an asset's verification result, replacing any earlier one
+	SaveAsset(ctx context.Context, asset *domain.Asset) error
+
+	// GetAsset retrieves an asset's verification result by S3 key (ErrAssetNotFound if it has none)
+	GetAsset(ctx context.Context, assetKey string) (*domain.Asset, error)
+}
+
+// JobEventsRepository stores each job's append-only audit trail
+type JobEventsRepository interface {
+	// AppendJobEvent adds an event after the job's last one (ErrJobEventsFull past the job's limit)
+	AppendJobEvent(ctx context.Context, event domain.JobEvent) error
+
+	// ListJobEvents retrieves one page of a job's events, oldest first
+	ListJobEvents(ctx context.Context, jobID string, query JobEventsQuery) (*JobEventsPage, error)
+}
+
+// JobLocksRepository keeps one expiring lock per job, for work two runs must not do at once
+type JobLocksRepository interface {
+	// AcquireJobLock takes or extends owner's lock on the job (ErrJobLocked while someone else holds it)
+	AcquireJobLock(ctx context.Context, jobID, owner string, ttl time.Duration) error
+
+	// ReleaseJobLock deletes the job's lock if owner still holds it
+	ReleaseJobLock(ctx context.Context, jobID, owner string) error
+}
+
+// PreferencesRepository stores each user's default generation settings
+type PreferencesRepository interface {
+	// GetPreferences returns the user's preferences, or nil for a user who never saved any
+	GetPreferences(ctx context.Context, userID string) (*domain.Preferences, error)
+
+	// PutPreferences replaces the preferences of prefs.UserID
+	PutPreferences(ctx context.Context, prefs *domain.Preferences) error
+}
+
+// StageTimingsRepository stores the rolling averages of step durations job ETAs are estimated
+// from, shared by every server
+type StageTimingsRepository interface {
+	// GetStageTimings returns the stored timings by step key, or nil if none were stored yet
+	GetStageTimings(ctx context.Context) (map[string]domain.StageTiming, error)
+
+	// PutStageTimings replaces the stored timings
+	PutStageTimings(ctx context.Context, timings map[string]domain.StageTiming) error
+}
+
+// UserBucketsRepository maps users to their own assets bucket
+type UserBucketsRepository interface {
+	// GetUserBucket returns the user's bucket, or "" for users without one
+	GetUserBucket(ctx context.Context, userID string) (string, error)
+
+	// PutUserBucket maps the user to bucket
+	PutUserBucket(ctx context.Context, userID, bucket string) error
 }
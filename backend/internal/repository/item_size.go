@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxItemBytes is the largest item written to DynamoDB: its 400KB item limit, less a margin for
+// the estimate ItemSize makes
+const MaxItemBytes = 390 << 10
+
+// ItemTooLargeError is an item refused before it was written because it would exceed
+// MaxItemBytes. Field is its largest top-level attribute, the one to look at first.
+type ItemTooLargeError struct {
+	Size      int
+	Field     string
+	FieldSize int
+}
+
+func (e *ItemTooLargeError) Error() string {
+	return fmt.Sprintf("item is %d bytes, over the %d byte limit; largest field is %s (%d bytes)",
+		e.Size, MaxItemBytes, e.Field, e.FieldSize)
+}
+
+// CheckItemSize returns an *ItemTooLargeError when item is over MaxItemBytes
+func CheckItemSize(item map[string]types.AttributeValue) error {
+	size, largest, largestSize := 0, "", -1
+	for name, value := range item {
+		fieldSize := len(name) + attributeSize(value)
+		size += fieldSize
+		if fieldSize > largestSize {
+			largest, largestSize = name, fieldSize
+		}
+	}
+	if size > MaxItemBytes {
+		return &ItemTooLargeError{Size: size, Field: largest, FieldSize: largestSize}
+	}
+	return nil
+}
+
+// CheckRecordSize marshals v as the repositories do and checks the item with CheckItemSize
+func CheckRecordSize(v interface{}) error {
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	return CheckItemSize(item)
+}
+
+// attributeSize estimates the bytes DynamoDB counts for a value, following its published sizing:
+// strings and binaries by length, numbers at about one byte per two digits, and three bytes of
+// overhead for a list or map plus one per element
+func attributeSize(value types.AttributeValue) int {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return len(v.Value)/2 + 1
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range v.Value {
+			size += len(n)/2 + 1
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, element := range v.Value {
+			size += 1 + attributeSize(element)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		size := 3
+		for name, element := range v.Value {
+			size += 1 + len(name) + attributeSize(element)
+		}
+		return size
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCheckItemSize(t *testing.T) {
+	item, err := attributevalue.MarshalMap(map[string]interface{}{
+		"job_id":   "job-1",                      // 6 + 5
+		"duration": 30,                           // 8 + 2
+		"done":     true,                         // 4 + 1
+		"tags":     []string{"a", "bc"},          // 4 + 3 + 2 + 3
+		"meta":     map[string]string{"k": "vv"}, // 4 + 3 + 1 + 1 + 2
+	})
+	require.NoError(t, err)
+	require.NoError(t, CheckItemSize(item))
+
+	size := 0
+	for name, value := range item {
+		size += len(name) + attributeSize(value)
+	}
+	require.Equal(t, 11+10+5+12+11, size)
+
+	item, err = attributevalue.MarshalMap(&domain.Job{JobID: "job-1", Prompt: strings.Repeat("p", MaxItemBytes)})
+	require.NoError(t, err)
+	var tooLarge *ItemTooLargeError
+	require.True(t, errors.As(CheckItemSize(item), &tooLarge))
+	require.Equal(t, "prompt", tooLarge.Field)
+	require.Greater(t, tooLarge.Size, MaxItemBytes)
+}
+
+func TestJobRepository_RefusesOversizedJobs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+
+	err := repo.CreateJob(ctx, &domain.Job{JobID: "job-huge", UserID: "user-1", Prompt: strings.Repeat("p", 400<<10)})
+	var tooLarge *ItemTooLargeError
+	require.True(t, errors.As(err, &tooLarge), "got %v", err)
+	require.Equal(t, "prompt", tooLarge.Field)
+	require.ErrorContains(t, err, "largest field is prompt")
+	_, err = repo.GetJob(ctx, "job-huge")
+	require.ErrorIs(t, err, ErrJobNotFound, "nothing is written")
+
+	job := &domain.Job{JobID: "job-1", UserID: "user-1", Prompt: "A sunrise run through the city"}
+	require.NoError(t, repo.CreateJob(ctx, job))
+	for i := 0; i < 200; i++ {
+		job.Scenes = append(job.Scenes, domain.Scene{SceneNumber: i + 1, GenerationPrompt: strings.Repeat("s", 2048)})
+	}
+	err = repo.UpdateJob(ctx, job)
+	require.True(t, errors.As(err, &tooLarge), "got %v", err)
+	require.Equal(t, "scenes", tooLarge.Field)
+	require.EqualValues(t, 1, job.Version, "a refused update leaves the version to retry with")
+
+	job.Scenes = job.Scenes[:3]
+	require.NoError(t, repo.UpdateJob(ctx, job))
+	stored, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Len(t, stored.Scenes, 3)
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrAssetsRecorded is returned when backfilling asset sizes for a job that already has them
+var ErrAssetsRecorded = errors.New("job assets already recorded")
+
+// SetJobAssets replaces the asset sizes recorded on a job and returns the sizes it replaced. A
+// nil or empty map records that the job has no assets, which keeps the backfill from revisiting it.
+func (r *DynamoDBRepository) SetJobAssets(ctx context.Context, jobID string, assets map[string]int64) (map[string]int64, error) {
+	return r.setJobAssets(ctx, jobID, assets, "")
+}
+
+// BackfillJobAssets records asset sizes on a job that has none recorded, failing with
+// ErrAssetsRecorded if it does (a pipeline or regeneration recorded them first)
+func (r *DynamoDBRepository) BackfillJobAssets(ctx context.Context, jobID string, assets map[string]int64) error {
+	_, err := r.setJobAssets(ctx, jobID, assets, "attribute_exists(job_id) AND attribute_not_exists(assets)")
+	return err
+}
+
+func (r *DynamoDBRepository) setJobAssets(ctx context.Context, jobID string, assets map[string]int64, condition string) (map[string]int64, error) {
+	value, err := attributevalue.Marshal(assets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job assets: %w", err)
+	}
+	if assets == nil {
+		value = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression: aws.String("SET #assets = :assets, #updated_at = :updated_at" + versionIncrement),
+		ExpressionAttributeNames: map[string]string{
+			"#assets":     "assets",
+			"#updated_at": "updated_at",
+			"#version":    "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":assets":     value,
+			":updated_at": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", getCurrentTimestamp())},
+			":one":        versionStep,
+		},
+		ReturnValues: types.ReturnValueUpdatedOld,
+	}
+	if condition != "" {
+		input.ConditionExpression = aws.String(condition)
+	}
+
+	result, err := r.client.UpdateItem(ctx, input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return nil, ErrAssetsRecorded
+		}
+		r.logger.Error("Failed to record job assets",
+			zap.String("job_id", jobID),
+			zap.Int("assets", len(assets)),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to record job assets: %w", err)
+	}
+
+	var previous map[string]int64
+	if old, ok := result.Attributes["assets"]; ok {
+		if err := attributevalue.Unmarshal(old, &previous); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job assets: %w", err)
+		}
+	}
+	return previous, nil
+}
+
+// ListJobsWithoutAssets returns jobs in status that have no asset sizes recorded
+func (r *DynamoDBRepository) ListJobsWithoutAssets(ctx context.Context, status string) ([]*domain.Job, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(statusJobsIndex),
+		KeyConditionExpression: aws.String("#status = :status"),
+		FilterExpression:       aws.String("attribute_not_exists(assets)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: status},
+		},
+	}
+
+	var jobs []*domain.Job
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to list jobs without assets",
+				zap.String("status", status),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to list jobs without assets: %w", err)
+		}
+
+		var page []*domain.Job
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal jobs without assets: %w", err)
+		}
+		jobs = append(jobs, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return jobs, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
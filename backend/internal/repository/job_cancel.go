@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrJobNotCancelable is returned when a job isn't in a state the cancel applies to
+	ErrJobNotCancelable = errors.New("job cannot be canceled in its current state")
+
+	// ErrJobCancelRequested is returned by UpdateJob when the job was canceled since it was read,
+	// so a running pipeline can't overwrite the cancel request
+	ErrJobCancelRequested = errors.New("job cancel requested")
+)
+
+// CancelIdleJob cancels a job that has no pipeline running: queued, pending or awaiting
+// script approval. Fails with ErrJobNotCancelable once the job has started or finished.
+func (r *DynamoDBRepository) CancelIdleJob(ctx context.Context, jobID string) error {
+	now := fmt.Sprintf("%d", getCurrentTimestamp())
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:    aws.String("SET #status = :canceled, #stage = :canceled, #cancel_requested = :true, #canceled_at = :now, #updated_at = :now" + versionIncrement),
+		ConditionExpression: aws.String("#status IN (:pending, :queued, :script_ready)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":           "status",
+			"#stage":            "stage",
+			"#cancel_requested": "cancel_requested",
+			"#canceled_at":      "canceled_at",
+			"#updated_at":       "updated_at",
+			"#version":          "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":canceled":     &types.AttributeValueMemberS{Value: domain.StatusCanceled},
+			":pending":      &types.AttributeValueMemberS{Value: domain.StatusPending},
+			":queued":       &types.AttributeValueMemberS{Value: domain.StatusQueued},
+			":script_ready": &types.AttributeValueMemberS{Value: domain.StatusScriptReady},
+			":true":         &types.AttributeValueMemberBOOL{Value: true},
+			":now":          &types.AttributeValueMemberN{Value: now},
+			":one":          versionStep,
+		},
+	})
+	if err != nil {
+		return r.cancelError("cancel idle job", jobID, err)
+	}
+
+	r.logger.Info("Idle job canceled", zap.String("job_id", jobID))
+	return nil
+}
+
+// RequestJobCancel flags a processing job for cancellation. The pipeline sees the flag at its
+// next stage boundary. Fails with ErrJobNotCancelable if the job isn't processing.
+func (r *DynamoDBRepository) RequestJobCancel(ctx context.Context, jobID string) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:    aws.String("SET #cancel_requested = :true, #updated_at = :updated_at" + versionIncrement),
+		ConditionExpression: aws.String("#status = :processing"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":           "status",
+			"#cancel_requested": "cancel_requested",
+			"#updated_at":       "updated_at",
+			"#version":          "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":processing": &types.AttributeValueMemberS{Value: domain.StatusProcessing},
+			":true":       &types.AttributeValueMemberBOOL{Value: true},
+			":updated_at": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", getCurrentTimestamp())},
+			":one":        versionStep,
+		},
+	})
+	if err != nil {
+		return r.cancelError("request job cancel", jobID, err)
+	}
+
+	r.logger.Info("Job cancel requested", zap.String("job_id", jobID))
+	return nil
+}
+
+// IsCancelRequested reads a job's cancel flag with a consistent read
+func (r *DynamoDBRepository) IsCancelRequested(ctx context.Context, jobID string) (bool, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		ProjectionExpression:     aws.String("#cancel_requested"),
+		ExpressionAttributeNames: map[string]string{"#cancel_requested": "cancel_requested"},
+		ConsistentRead:           aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to read cancel flag: %w", err)
+	}
+
+	flag, ok := result.Item["cancel_requested"].(*types.AttributeValueMemberBOOL)
+	return ok && flag.Value, nil
+}
+
+// MarkJobCanceled moves a job whose pipeline stopped to the canceled status
+func (r *DynamoDBRepository) MarkJobCanceled(ctx context.Context, jobID string) error {
+	now := fmt.Sprintf("%d", getCurrentTimestamp())
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression: aws.String("SET #status = :canceled, #stage = :canceled, #cancel_requested = :true, #canceled_at = :now, #updated_at = :now" + versionIncrement),
+		ExpressionAttributeNames: map[string]string{
+			"#status":           "status",
+			"#stage":            "stage",
+			"#cancel_requested": "cancel_requested",
+			"#canceled_at":      "canceled_at",
+			"#updated_at":       "updated_at",
+			"#version":          "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":canceled": &types.AttributeValueMemberS{Value: domain.StatusCanceled},
+			":true":     &types.AttributeValueMemberBOOL{Value: true},
+			":now":      &types.AttributeValueMemberN{Value: now},
+			":one":      versionStep,
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to mark job canceled",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to mark job canceled: %w", err)
+	}
+
+	r.logger.Info("Job marked as canceled", zap.String("job_id", jobID))
+	return nil
+}
+
+// cancelError maps a failed conditional cancel update onto ErrJobNotCancelable
+func (r *DynamoDBRepository) cancelError(operation, jobID string, err error) error {
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrJobNotCancelable
+	}
+	r.logger.Error("Failed to "+operation,
+		zap.String("job_id", jobID),
+		zap.Error(err),
+	)
+	return fmt.Errorf("failed to %s: %w", operation, err)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// canceledJobTable holds one job whose cancel flag was set by another request, and rejects
+// writes whose condition doesn't allow for it the way DynamoDB would
+type canceledJobTable struct {
+	*fakeDynamoDB
+	puts []*dynamodb.PutItemInput
+}
+
+func (f *canceledJobTable) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.puts = append(f.puts, in)
+	if strings.Contains(aws.ToString(in.ConditionExpression), "cancel_requested") {
+		return nil, &types.ConditionalCheckFailedException{
+			Message: aws.String("The conditional request failed"),
+			Item: map[string]types.AttributeValue{
+				"job_id":           &types.AttributeValueMemberS{Value: "job-1"},
+				"cancel_requested": &types.AttributeValueMemberBOOL{Value: true},
+			},
+		}
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *canceledJobTable) UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+}
+
+func TestUpdateJob_DoesNotOverwriteCancelRequest(t *testing.T) {
+	db := &canceledJobTable{fakeDynamoDB: newFakeDynamoDB(t)}
+	repo := newTestJobRepository(db)
+
+	// The pipeline's copy of the job predates the cancel
+	job := &domain.Job{JobID: "job-1", UserID: "user-1", Status: domain.StatusProcessing, Stage: domain.SceneGenerating(2)}
+	err := repo.UpdateJob(context.Background(), job)
+	require.ErrorIs(t, err, ErrJobCancelRequested)
+	require.Contains(t, aws.ToString(db.puts[0].ConditionExpression), "cancel_requested")
+
+	// A job that already carries the flag is only checked for its version
+	job.CancelRequested = true
+	require.NoError(t, repo.UpdateJob(context.Background(), job))
+	require.NotContains(t, aws.ToString(db.puts[1].ConditionExpression), "cancel_requested")
+}
+
+func TestCancelJob_ConditionFailuresAreNotCancelable(t *testing.T) {
+	repo := newTestJobRepository(&canceledJobTable{fakeDynamoDB: newFakeDynamoDB(t)})
+
+	require.ErrorIs(t, repo.CancelIdleJob(context.Background(), "job-1"), ErrJobNotCancelable)
+	require.ErrorIs(t, repo.RequestJobCancel(context.Background(), "job-1"), ErrJobNotCancelable)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// UpdateJobCompositionProgress stores the latest report of a job's composition pass. Unlike the
+// pipeline's other targeted writes it leaves the version alone: progress is advisory, and a
+// whole-item write that drops a report costs less than making a regeneration's final write
+// conflict several times a second.
+func (r *DynamoDBRepository) UpdateJobCompositionProgress(ctx context.Context, jobID string, progress domain.CompositionProgress) error {
+	value, err := attributevalue.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal composition progress: %w", err)
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:    aws.String("SET #composition_progress = :progress, #updated_at = :updated_at"),
+		ConditionExpression: aws.String("attribute_exists(job_id)"),
+		ExpressionAttributeNames: map[string]string{
+			"#composition_progress": "composition_progress",
+			"#updated_at":           "updated_at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":progress":   value,
+			":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(getCurrentTimestamp(), 10)},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to update job composition progress",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to update job composition progress: %w", err)
+	}
+	return nil
+}
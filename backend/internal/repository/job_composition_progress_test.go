@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateJobCompositionProgress(t *testing.T) {
+	ctx := context.Background()
+	repo := newStepStatsRepository(t)
+	created, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.UpdateJobCompositionProgress(ctx, "job-1", domain.CompositionProgress{Pass: "text_overlay", Percent: 40}))
+	require.NoError(t, repo.UpdateJobCompositionProgress(ctx, "job-1", domain.CompositionProgress{Pass: "webm", Percent: 10}))
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Equal(t, &domain.CompositionProgress{Pass: "webm", Percent: 10}, job.CompositionProgress)
+	require.Equal(t, created.Version, job.Version, "progress reports don't conflict with whole-item writes")
+}
+
+func TestUpdateJobCompositionProgress_MissingJob(t *testing.T) {
+	repo := newStepStatsRepository(t)
+	err := repo.UpdateJobCompositionProgress(context.Background(), "job-missing", domain.CompositionProgress{Pass: "webm"})
+	require.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// JobDetails holds the fields of a job its owner can edit; nil fields are left unchanged
+type JobDetails struct {
+	Title *string
+	Note  *string // An empty note removes it
+}
+
+// UpdateJobDetails writes a job's title and note and returns the updated job. Only those
+// attributes are written, so the pipeline's progress is never overwritten; the version bump
+// makes a whole-item write from an older copy reload the job instead of reverting the edit.
+func (r *DynamoDBRepository) UpdateJobDetails(ctx context.Context, jobID string, details JobDetails) (*domain.Job, error) {
+	update, names, values := jobDetailsUpdate(details, getCurrentTimestamp())
+	result, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("attribute_exists(job_id)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return nil, ErrJobNotFound
+		}
+		r.logger.Error("Failed to update job details",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to update job details: %w", err)
+	}
+
+	var job domain.Job
+	if err := attributevalue.UnmarshalMap(result.Attributes, &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+	return &job, nil
+}
+
+// jobDetailsUpdate builds the update expression writing details. A new title is marked as the
+// owner's, so the pipeline stops replacing it with the script's.
+func jobDetailsUpdate(details JobDetails, now int64) (string, map[string]string, map[string]types.AttributeValue) {
+	set := []string{"#updated_at = :updated_at"}
+	var remove []string
+	names := map[string]string{
+		"#updated_at": "updated_at",
+		"#version":    "version",
+	}
+	values := map[string]types.AttributeValue{
+		":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		":one":        versionStep,
+	}
+
+	if details.Title != nil {
+		set = append(set, "#title = :title", "#title_edited = :true")
+		names["#title"] = "title"
+		names["#title_edited"] = "title_edited"
+		values[":title"] = &types.AttributeValueMemberS{Value: *details.Title}
+		values[":true"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
+	if details.Note != nil {
+		names["#note"] = "note"
+		if *details.Note == "" {
+			remove = append(remove, "#note")
+		} else {
+			set = append(set, "#note = :note")
+			values[":note"] = &types.AttributeValueMemberS{Value: *details.Note}
+		}
+	}
+
+	update := "SET " + strings.Join(set, ", ") + versionIncrement
+	if len(remove) > 0 {
+		update += " REMOVE " + strings.Join(remove, ", ")
+	}
+	return update, names, values
+}
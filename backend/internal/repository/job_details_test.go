@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJobDetailsUpdate(t *testing.T) {
+	title, note, empty := "Spring launch", "Send to legal", ""
+
+	update, names, values := jobDetailsUpdate(JobDetails{Title: &title, Note: &note}, 1000)
+	require.Equal(t, "SET #updated_at = :updated_at, #title = :title, #title_edited = :true, #note = :note ADD #version :one", update)
+	require.Equal(t, "title_edited", names["#title_edited"])
+	require.Equal(t, &types.AttributeValueMemberS{Value: title}, values[":title"])
+	require.Equal(t, &types.AttributeValueMemberS{Value: note}, values[":note"])
+	require.Equal(t, &types.AttributeValueMemberN{Value: "1000"}, values[":updated_at"])
+
+	// Clearing the note removes it; the title is left alone
+	update, names, values = jobDetailsUpdate(JobDetails{Note: &empty}, 1000)
+	require.Equal(t, "SET #updated_at = :updated_at ADD #version :one REMOVE #note", update)
+	require.NotContains(t, names, "#title")
+	require.NotContains(t, values, ":note")
+}
+
+func TestUpdateJobDetails(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	require.NoError(t, repo.CreateJob(ctx, &domain.Job{
+		JobID:  "job-1",
+		UserID: "user-1",
+		Status: domain.StatusProcessing,
+		Stage:  domain.SceneGenerating(1),
+		Title:  "Morning run",
+		Note:   "First cut",
+	}))
+	pipelineCopy, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+
+	title, empty := "Sunrise 10K", ""
+	job, err := repo.UpdateJobDetails(ctx, "job-1", JobDetails{Title: &title, Note: &empty})
+	require.NoError(t, err)
+	require.Equal(t, "Sunrise 10K", job.Title)
+	require.True(t, job.TitleEdited)
+	require.Empty(t, job.Note)
+	require.Equal(t, domain.SceneGenerating(1), job.Stage)
+	require.Equal(t, pipelineCopy.Version+1, job.Version)
+
+	// The pipeline saves its progress from the copy it read before the rename
+	pipelineCopy.Stage = domain.SceneComplete(1)
+	require.NoError(t, repo.UpdateJobWithRetry(ctx, pipelineCopy, func(fresh *domain.Job) {
+		fresh.Stage = domain.SceneComplete(1)
+	}))
+	stored, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Equal(t, domain.SceneComplete(1), stored.Stage)
+	require.Equal(t, "Sunrise 10K", stored.Title)
+
+	_, err = repo.UpdateJobDetails(ctx, "job-missing", JobDetails{Title: &title})
+	require.ErrorIs(t, err, ErrJobNotFound)
+}
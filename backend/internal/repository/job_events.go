@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/trace"
+	"go.uber.org/zap"
+)
+
+// ErrJobEventsFull is returned by AppendJobEvent when the job already has as many events as
+// it may keep. The dropped event is counted in the job's JobEventsPage.Dropped.
+var ErrJobEventsFull = errors.New("job event limit reached")
+
+// JobEventRetention is how long job events are kept. Jobs have their own retention, but the
+// trail outlives a deleted job for the disputes that follow it.
+const JobEventRetention = 90 * 24 * time.Hour
+
+const (
+	// maxJobEvents bounds the events kept per job. A run of a ten-scene job records around
+	// fifty; the rest is room for regenerations and requeues.
+	maxJobEvents = 500
+
+	// jobEventOutcomeReserve is extra room kept for outcome events, so a job that filled its
+	// trail still records how each later run ended
+	jobEventOutcomeReserve = 20
+
+	// maxJobEventMessageLength bounds each event's message
+	maxJobEventMessageLength = 512
+)
+
+// jobEventKeys are the attributes of a job events LastEvaluatedKey
+var jobEventKeys = []string{"job_id", "sequence"}
+
+// jobEventCounter is the sequence of the item that counts a job's events. Events start at 1,
+// so it never appears in a page.
+const jobEventCounter = 0
+
+// DynamoDBJobEventRepository stores each job's audit trail, one item per event keyed by job ID
+// and sequence
+type DynamoDBJobEventRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewJobEventRepository creates a new job event repository
+func NewJobEventRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBJobEventRepository {
+	return &DynamoDBJobEventRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// JobEventsQuery selects one page of a job's events
+type JobEventsQuery struct {
+	Limit             int                             // Events per page (must be positive)
+	ExclusiveStartKey map[string]types.AttributeValue // Previous page's LastEvaluatedKey; nil for the first page
+}
+
+// JobEventsPage is one page of a job's events, oldest first
+type JobEventsPage struct {
+	Events []*domain.JobEvent
+
+	// Dropped counts events not kept because the job reached its event limit
+	Dropped int
+
+	// LastEvaluatedKey is the ExclusiveStartKey for the next page; nil when there are no more events
+	LastEvaluatedKey map[string]types.AttributeValue
+}
+
+// DecodeJobEventsCursor parses a cursor of a ListJobEvents page back into an ExclusiveStartKey
+func DecodeJobEventsCursor(cursor string) (map[string]types.AttributeValue, error) {
+	return decodeCursor(cursor, jobEventKeys)
+}
+
+// AppendJobEvent adds event to the end of its job's trail, numbering it after the job's last
+// event. Its message is truncated, and past the job's event limit it is only counted, with
+// ErrJobEventsFull.
+func (r *DynamoDBJobEventRepository) AppendJobEvent(ctx context.Context, event domain.JobEvent) error {
+	limit := maxJobEvents
+	if event.IsOutcome() {
+		limit += jobEventOutcomeReserve
+	}
+	ttl := time.Now().Add(JobEventRetention).Unix()
+
+	// The counter hands out sequence numbers atomically, so concurrent steps never collide
+	sequence, err := r.nextJobEventSequence(ctx, event.JobID, limit, ttl)
+	if err != nil {
+		return err
+	}
+
+	event.Sequence = sequence
+	event.Message = truncateError(event.Message, maxJobEventMessageLength)
+	event.TTL = ttl
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job event: %w", err)
+	}
+	if _, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}); err != nil {
+		r.logger.Error("Failed to append job event",
+			zap.String("job_id", event.JobID),
+			zap.String("type", event.Type),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to append job event: %w", err)
+	}
+	return nil
+}
+
+// nextJobEventSequence claims the next sequence number of a job's events, or counts the event
+// as dropped when the job already has limit events
+func (r *DynamoDBJobEventRepository) nextJobEventSequence(ctx context.Context, jobID string, limit int, ttl int64) (int64, error) {
+	key := jobEventCounterKey(jobID)
+	result, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #ttl = :ttl ADD #event_count :one"),
+		ConditionExpression: aws.String("attribute_not_exists(#event_count) OR #event_count < :limit"),
+		ExpressionAttributeNames: map[string]string{
+			"#event_count": "event_count",
+			"#ttl":         "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":   versionStep,
+			":limit": &types.AttributeValueMemberN{Value: strconv.Itoa(limit)},
+			":ttl":   &types.AttributeValueMemberN{Value: strconv.FormatInt(ttl, 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	var ccf *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &ccf):
+		if _, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(r.tableName),
+			Key:                       key,
+			UpdateExpression:          aws.String("ADD #dropped :one"),
+			ExpressionAttributeNames:  map[string]string{"#dropped": "dropped"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": versionStep},
+		}); err != nil {
+			return 0, fmt.Errorf("failed to count dropped job event: %w", err)
+		}
+		return 0, ErrJobEventsFull
+	case err != nil:
+		r.logger.Error("Failed to number job event",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return 0, fmt.Errorf("failed to number job event: %w", err)
+	}
+
+	count, ok := result.Attributes["event_count"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("job event counter returned no count")
+	}
+	return strconv.ParseInt(count.Value, 10, 64)
+}
+
+// ListJobEvents retrieves one page of a job's events in the order they were appended
+func (r *DynamoDBJobEventRepository) ListJobEvents(ctx context.Context, jobID string, query JobEventsQuery) (*JobEventsPage, error) {
+	if query.Limit < 1 {
+		return nil, fmt.Errorf("limit must be positive, got %d", query.Limit)
+	}
+	if query.ExclusiveStartKey != nil {
+		job, ok := query.ExclusiveStartKey["job_id"].(*types.AttributeValueMemberS)
+		if !ok || job.Value != jobID {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("job_id = :job_id AND #sequence > :counter"),
+		ExpressionAttributeNames: map[string]string{
+			"#sequence": "sequence",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":job_id":  &types.AttributeValueMemberS{Value: jobID},
+			":counter": &types.AttributeValueMemberN{Value: strconv.Itoa(jobEventCounter)},
+		},
+		ExclusiveStartKey: query.ExclusiveStartKey,
+		Limit:             aws.Int32(int32(query.Limit)),
+	})
+	if err != nil {
+		r.logger.Error("Failed to query job events",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to query job events: %w", err)
+	}
+
+	page := &JobEventsPage{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &page.Events); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job events: %w", err)
+	}
+	if len(result.LastEvaluatedKey) > 0 {
+		page.LastEvaluatedKey = indexKey(result.LastEvaluatedKey, jobEventKeys)
+	}
+
+	counter, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       jobEventCounterKey(jobID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job event counter: %w", err)
+	}
+	if dropped, ok := counter.Item["dropped"].(*types.AttributeValueMemberN); ok {
+		page.Dropped, _ = strconv.Atoi(dropped.Value)
+	}
+	return page, nil
+}
+
+// jobEventCounterKey is the key of the item counting a job's events
+func jobEventCounterKey(jobID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"job_id":   &types.AttributeValueMemberS{Value: jobID},
+		"sequence": &types.AttributeValueMemberN{Value: strconv.Itoa(jobEventCounter)},
+	}
+}
+
+// JobEventTimeout bounds each event RecordJobEvent writes, so a slow events table can't hold up
+// the pipeline
+const JobEventTimeout = 5 * time.Second
+
+// JobEventAppender is the subset of the job event repository the pipeline appends to
+type JobEventAppender interface {
+	AppendJobEvent(ctx context.Context, event domain.JobEvent) error
+}
+
+// RecordJobEvent adds event to the end of a job's audit trail. It's best-effort: a failed write
+// is logged and the pipeline carries on, and a nil store records nothing.
+func RecordJobEvent(ctx context.Context, store JobEventAppender, logger *zap.Logger, jobID string, event domain.JobEvent) {
+	if store == nil {
+		return
+	}
+	event.JobID = jobID
+	if event.Time == 0 {
+		event.Time = time.Now().UnixMilli()
+	}
+
+	// Detached so a canceled or timed-out job still records how it ended
+	ctx, cancel := context.WithTimeout(trace.Detach(ctx), JobEventTimeout)
+	defer cancel()
+	err := store.AppendJobEvent(ctx, event)
+	if err != nil && !errors.Is(err, ErrJobEventsFull) { // Full trails count what they drop
+		trace.Logger(ctx, logger).Warn("Failed to record job event",
+			zap.String("type", event.Type),
+			zap.String("stage", event.Stage),
+			zap.Error(err),
+		)
+	}
+}
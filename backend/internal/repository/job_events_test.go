@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJobEvents_AppendAndPageInOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobEventRepository("job-events", zap.NewNop())
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, repo.AppendJobEvent(ctx, domain.JobEvent{
+			JobID: "job-1", Type: domain.JobEventStage, Stage: fmt.Sprintf("scene_%d_generating", i),
+		}))
+	}
+	require.NoError(t, repo.AppendJobEvent(ctx, domain.JobEvent{JobID: "job-2", Type: domain.JobEventStage}))
+
+	var stages []string
+	query := JobEventsQuery{Limit: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5)
+		page, err := repo.ListJobEvents(ctx, "job-1", query)
+		require.NoError(t, err)
+		for _, event := range page.Events {
+			require.Equal(t, int64(len(stages)+1), event.Sequence)
+			require.NotZero(t, event.TTL)
+			stages = append(stages, event.Stage)
+		}
+		if page.LastEvaluatedKey == nil {
+			break
+		}
+		cursor, err := EncodeJobsCursor(page.LastEvaluatedKey)
+		require.NoError(t, err)
+		query.ExclusiveStartKey, err = DecodeJobEventsCursor(cursor)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{
+		"scene_1_generating", "scene_2_generating", "scene_3_generating", "scene_4_generating", "scene_5_generating",
+	}, stages)
+
+	// A cursor from one job's events can't page through another's
+	_, err := repo.ListJobEvents(ctx, "job-2", query)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestJobEvents_TruncatesMessages(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobEventRepository("job-events", zap.NewNop())
+
+	require.NoError(t, repo.AppendJobEvent(ctx, domain.JobEvent{
+		JobID: "job-1", Type: domain.JobEventFailed, Message: strings.Repeat("x", 5000),
+	}))
+
+	page, err := repo.ListJobEvents(ctx, "job-1", JobEventsQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	require.Len(t, page.Events[0].Message, maxJobEventMessageLength+len("..."))
+}
+
+func TestJobEvents_BoundsEventsPerJob(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobEventRepository("job-events", zap.NewNop())
+
+	for i := 0; i < maxJobEvents; i++ {
+		require.NoError(t, repo.AppendJobEvent(ctx, domain.JobEvent{JobID: "job-1", Type: domain.JobEventCall}))
+	}
+	require.ErrorIs(t, repo.AppendJobEvent(ctx, domain.JobEvent{JobID: "job-1", Type: domain.JobEventCall}), ErrJobEventsFull)
+	require.ErrorIs(t, repo.AppendJobEvent(ctx, domain.JobEvent{JobID: "job-1", Type: domain.JobEventWarning}), ErrJobEventsFull)
+
+	// The outcome still gets recorded, after everything that was kept
+	require.NoError(t, repo.AppendJobEvent(ctx, domain.JobEvent{JobID: "job-1", Type: domain.JobEventCompleted}))
+
+	page, err := repo.ListJobEvents(ctx, "job-1", JobEventsQuery{Limit: maxJobEvents + jobEventOutcomeReserve})
+	require.NoError(t, err)
+	require.Len(t, page.Events, maxJobEvents+1)
+	require.Equal(t, 2, page.Dropped)
+	last := page.Events[len(page.Events)-1]
+	require.Equal(t, domain.JobEventCompleted, last.Type)
+	require.Equal(t, int64(maxJobEvents+1), last.Sequence)
+
+	// Other jobs have their own limit
+	require.NoError(t, repo.AppendJobEvent(ctx, domain.JobEvent{JobID: "job-2", Type: domain.JobEventCall}))
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// ErrJobLocked is returned by AcquireJobLock while another owner holds an unexpired lock on
+// the job
+var ErrJobLocked = errors.New("job is locked")
+
+// DynamoDBJobLockRepository guards work that must not run twice at once for the same job, one
+// lock item per job that expires on its own if its owner dies
+type DynamoDBJobLockRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewJobLockRepository creates a new job lock repository
+func NewJobLockRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBJobLockRepository {
+	return &DynamoDBJobLockRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// AcquireJobLock takes the job's lock for owner until ttl from now, or extends it if owner
+// already holds it. It fails with ErrJobLocked while someone else holds an unexpired lock; an
+// expired one is taken over, since DynamoDB deletes expired items lazily.
+func (r *DynamoDBJobLockRepository) AcquireJobLock(ctx context.Context, jobID, owner string, ttl time.Duration) error {
+	now := time.Now()
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]types.AttributeValue{
+			"job_id":      &types.AttributeValueMemberS{Value: jobID},
+			"owner":       &types.AttributeValueMemberS{Value: owner},
+			"acquired_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			"ttl":         &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(ttl).Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(job_id) OR #ttl <= :now OR #owner = :owner"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl":   "ttl",
+			"#owner": "owner",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":   &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			":owner": &types.AttributeValueMemberS{Value: owner},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrJobLocked
+	}
+	if err != nil {
+		r.logger.Error("Failed to acquire job lock",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to acquire job lock: %w", err)
+	}
+	return nil
+}
+
+// ReleaseJobLock deletes the job's lock if owner still holds it. A lock that expired and was
+// taken over is left to its new owner.
+func (r *DynamoDBJobLockRepository) ReleaseJobLock(ctx context.Context, jobID, owner string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		ConditionExpression:      aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]string{"#owner": "owner"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: owner},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &ccf) {
+		r.logger.Error("Failed to release job lock",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to release job lock: %w", err)
+	}
+	return nil
+}
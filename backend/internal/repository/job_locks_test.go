@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJobLocks_AcquireContendAndRelease(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobLockRepository("job-locks", zap.NewNop())
+
+	require.NoError(t, repo.AcquireJobLock(ctx, "job-1", "run-a", time.Minute))
+	require.ErrorIs(t, repo.AcquireJobLock(ctx, "job-1", "run-b", time.Minute), ErrJobLocked)
+
+	// The holder can extend its lock, and other jobs aren't affected
+	require.NoError(t, repo.AcquireJobLock(ctx, "job-1", "run-a", time.Minute))
+	require.NoError(t, repo.AcquireJobLock(ctx, "job-2", "run-b", time.Minute))
+
+	// Only the holder releases it
+	require.NoError(t, repo.ReleaseJobLock(ctx, "job-1", "run-b"))
+	require.ErrorIs(t, repo.AcquireJobLock(ctx, "job-1", "run-b", time.Minute), ErrJobLocked)
+
+	require.NoError(t, repo.ReleaseJobLock(ctx, "job-1", "run-a"))
+	require.NoError(t, repo.AcquireJobLock(ctx, "job-1", "run-b", time.Minute))
+}
+
+func TestJobLocks_ExpiredLockIsTakenOver(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobLockRepository("job-locks", zap.NewNop())
+
+	require.NoError(t, repo.AcquireJobLock(ctx, "job-1", "run-a", -time.Minute))
+	require.NoError(t, repo.AcquireJobLock(ctx, "job-1", "run-b", time.Minute))
+
+	// The dead run's late release leaves the new holder's lock alone
+	require.NoError(t, repo.ReleaseJobLock(ctx, "job-1", "run-a"))
+	require.ErrorIs(t, repo.AcquireJobLock(ctx, "job-1", "run-c", time.Minute), ErrJobLocked)
+}
+
+func TestMarkJobComplete_RejectsJobsNoLongerProcessing(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+
+	for _, status := range []string{domain.StatusFailed, domain.StatusCanceled} {
+		jobID := "job-" + status
+		require.NoError(t, repo.CreateJob(ctx, &domain.Job{JobID: jobID, UserID: "user-1", Status: status}))
+
+		require.ErrorIs(t, repo.MarkJobComplete(ctx, jobID, "videos/stale.mp4"), ErrJobNotProcessing)
+
+		stored, err := repo.GetJob(ctx, jobID)
+		require.NoError(t, err)
+		require.Equal(t, status, stored.Status)
+		require.Empty(t, stored.VideoKey)
+	}
+}
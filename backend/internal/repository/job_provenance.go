@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// maxProvenanceErrorLength bounds the provider error kept on each provenance entry. A job makes
+// a dozen or more provider calls, so each keeps less than the job's own failure error.
+const maxProvenanceErrorLength = 1024
+
+// AppendJobProvenance adds a finished provider call to the end of a job's provenance and returns
+// the job's new version. The append happens inside DynamoDB, so pipeline steps running in
+// parallel never drop each other's entries; the version bump makes a whole-item write from an
+// older copy reload the job (and its provenance) instead of overwriting it.
+func (r *DynamoDBRepository) AppendJobProvenance(ctx context.Context, jobID string, entry domain.ProvenanceEntry) (int64, error) {
+	entry.Error = truncateError(entry.Error, maxProvenanceErrorLength)
+	value, err := attributevalue.Marshal([]domain.ProvenanceEntry{entry})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal provenance entry: %w", err)
+	}
+
+	result, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:    aws.String("SET #provenance = list_append(if_not_exists(#provenance, :empty), :entry), #updated_at = :updated_at" + versionIncrement),
+		ConditionExpression: aws.String("attribute_exists(job_id)"),
+		ExpressionAttributeNames: map[string]string{
+			"#provenance": "provenance",
+			"#updated_at": "updated_at",
+			"#version":    "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entry":      value,
+			":empty":      &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(getCurrentTimestamp(), 10)},
+			":one":        versionStep,
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return 0, ErrJobNotFound
+		}
+		r.logger.Error("Failed to record job provenance",
+			zap.String("job_id", jobID),
+			zap.String("step", entry.Step),
+			zap.String("prediction_id", entry.PredictionID),
+			zap.Error(err),
+		)
+		return 0, fmt.Errorf("failed to record job provenance: %w", err)
+	}
+
+	var version int64
+	if v, ok := result.Attributes["version"].(*types.AttributeValueMemberN); ok {
+		version, _ = strconv.ParseInt(v.Value, 10, 64)
+	}
+	return version, nil
+}
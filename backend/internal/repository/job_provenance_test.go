@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendJobProvenance(t *testing.T) {
+	ctx := context.Background()
+	table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Version: 4})
+	repo := newTestJobRepository(table)
+
+	version, err := repo.AppendJobProvenance(ctx, "job-1", domain.ProvenanceEntry{
+		Step: "script", Provider: "replicate", Model: "openai/gpt-4o:ad45", PredictionID: "p-1", Status: "succeeded",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), version)
+
+	version, err = repo.AppendJobProvenance(ctx, "job-1", domain.ProvenanceEntry{
+		Step: "scene_1", Provider: "replicate", PredictionID: "p-2", Status: "failed", Error: strings.Repeat("x", 5000),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(6), version)
+
+	job := storedJob(t, table)
+	require.Len(t, job.Provenance, 2)
+	require.Equal(t, "p-1", job.Provenance[0].PredictionID)
+	require.Equal(t, "openai/gpt-4o:ad45", job.Provenance[0].Model)
+	require.Equal(t, "scene_1", job.Provenance[1].Step)
+	require.Len(t, job.Provenance[1].Error, maxProvenanceErrorLength+len("..."))
+}
+
+func TestAppendJobProvenance_SurvivesStaleWholeItemWrite(t *testing.T) {
+	ctx := context.Background()
+	pipelineCopy := &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Stage: domain.SceneGenerating(1), Version: 1}
+	table := newVersionedJobTable(t, pipelineCopy)
+	repo := newTestJobRepository(table)
+
+	_, err := repo.AppendJobProvenance(ctx, "job-1", domain.ProvenanceEntry{Step: "scene_1", PredictionID: "p-1", Status: "succeeded"})
+	require.NoError(t, err)
+
+	// The pipeline saves its progress from the copy it read before the entry was appended
+	pipelineCopy.Stage = domain.SceneComplete(1)
+	require.NoError(t, repo.UpdateJobWithRetry(ctx, pipelineCopy, func(fresh *domain.Job) {
+		fresh.Stage = domain.SceneComplete(1)
+	}))
+
+	job := storedJob(t, table)
+	require.Equal(t, domain.SceneComplete(1), job.Stage)
+	require.Len(t, job.Provenance, 1)
+	require.Equal(t, "p-1", job.Provenance[0].PredictionID)
+}
+
+func TestAppendJobProvenance_MissingJob(t *testing.T) {
+	repo := newTestJobRepository(newVersionedJobTable(t, nil))
+	_, err := repo.AppendJobProvenance(context.Background(), "gone", domain.ProvenanceEntry{Step: "music"})
+	require.ErrorIs(t, err, ErrJobNotFound)
+}
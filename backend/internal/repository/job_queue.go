@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrJobNotQueued is returned when claiming a job that is no longer queued
+var ErrJobNotQueued = errors.New("job is not queued")
+
+// statusJobsIndex is the GSI keyed by status and created_at, used to find queued jobs across users
+const statusJobsIndex = "StatusJobsIndex"
+
+// CountActiveJobs counts a user's processing jobs updated at or after activeSince (unix seconds).
+// Pipelines that died with their server stop updating their job, so the cutoff keeps them from
+// holding a slot forever. A/B variant parents only wait on their variants, which count on their own.
+// It reads the user's own UserJobsIndex partition, so busy users don't all hit the processing
+// partition of StatusJobsIndex.
+func (r *DynamoDBRepository) CountActiveJobs(ctx context.Context, userID string, activeSince int64) (int, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(userJobsIndex),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		FilterExpression:       aws.String("#status = :status AND #updated_at >= :active_since AND attribute_not_exists(variant_job_ids)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":     "status",
+			"#updated_at": "updated_at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":       &types.AttributeValueMemberS{Value: domain.StatusProcessing},
+			":user_id":      &types.AttributeValueMemberS{Value: userID},
+			":active_since": &types.AttributeValueMemberN{Value: strconv.FormatInt(activeSince, 10)},
+		},
+		Select: types.SelectCount,
+	}
+
+	count := 0
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to count active jobs",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			return 0, fmt.Errorf("failed to count active jobs: %w", err)
+		}
+		count += int(result.Count)
+		if len(result.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// ListQueuedJobs returns queued jobs oldest first, for one user or for everyone when userID is empty.
+// A single user's queue is read from their UserJobsIndex partition; only the periodic sweep over
+// all users reads the queued partition of StatusJobsIndex.
+func (r *DynamoDBRepository) ListQueuedJobs(ctx context.Context, userID string) ([]*domain.Job, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(statusJobsIndex),
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: domain.StatusQueued},
+		},
+		ScanIndexForward: aws.Bool(true), // Oldest first (FIFO)
+	}
+	if userID != "" {
+		input.IndexName = aws.String(userJobsIndex)
+		input.KeyConditionExpression = aws.String("user_id = :user_id")
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeValues[":user_id"] = &types.AttributeValueMemberS{Value: userID}
+	}
+
+	var jobs []*domain.Job
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to list queued jobs",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to list queued jobs: %w", err)
+		}
+
+		var page []*domain.Job
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal queued jobs: %w", err)
+		}
+		jobs = append(jobs, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return jobs, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// ClaimQueuedJob moves a queued job to processing. The conditional update lets exactly one
+// server start the job; everyone else gets ErrJobNotQueued.
+func (r *DynamoDBRepository) ClaimQueuedJob(ctx context.Context, jobID string) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:    aws.String("SET #status = :processing, #updated_at = :updated_at" + versionIncrement),
+		ConditionExpression: aws.String("#status = :queued"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":     "status",
+			"#updated_at": "updated_at",
+			"#version":    "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":processing": &types.AttributeValueMemberS{Value: domain.StatusProcessing},
+			":queued":     &types.AttributeValueMemberS{Value: domain.StatusQueued},
+			":updated_at": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", getCurrentTimestamp())},
+			":one":        versionStep,
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return ErrJobNotQueued
+		}
+		r.logger.Error("Failed to claim queued job",
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to claim queued job: %w", err)
+	}
+
+	r.logger.Info("Queued job claimed", zap.String("job_id", jobID))
+	return nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ListExpiredJobs returns jobs in status whose retention expired at or before expiredBy (unix
+// seconds). Jobs without an expires_at are kept forever and never returned.
+func (r *DynamoDBRepository) ListExpiredJobs(ctx context.Context, status string, expiredBy int64) ([]*domain.Job, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(statusJobsIndex),
+		KeyConditionExpression: aws.String("#status = :status"),
+		FilterExpression:       aws.String("expires_at <= :expired_by"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":     &types.AttributeValueMemberS{Value: status},
+			":expired_by": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiredBy, 10)},
+		},
+	}
+
+	var jobs []*domain.Job
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to list expired jobs",
+				zap.String("status", status),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to list expired jobs: %w", err)
+		}
+
+		var page []*domain.Job
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal expired jobs: %w", err)
+		}
+		jobs = append(jobs, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return jobs, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// RecordJobStepStats stores the stats of a finished pipeline step on a job, replacing any the
+// step recorded before, and returns the job's new version. Only the step's entry is written, so
+// steps finishing in parallel keep each other's stats, and the version bump makes a whole-item
+// write from an older copy reload the job instead of dropping them.
+func (r *DynamoDBRepository) RecordJobStepStats(ctx context.Context, jobID, step string, stats domain.StepStats) (int64, error) {
+	value, err := attributevalue.Marshal(stats)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal step stats: %w", err)
+	}
+
+	// A step's entry can only be set inside an existing map, so the job's first step creates it.
+	// The third attempt covers another step creating the map between the first two.
+	for attempt := 1; attempt <= 3; attempt++ {
+		input := &dynamodb.UpdateItemInput{
+			TableName: aws.String(r.tableName),
+			Key: map[string]types.AttributeValue{
+				"job_id": &types.AttributeValueMemberS{Value: jobID},
+			},
+			ExpressionAttributeNames: map[string]string{
+				"#step_stats": "step_stats",
+				"#updated_at": "updated_at",
+				"#version":    "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(getCurrentTimestamp(), 10)},
+				":one":        versionStep,
+			},
+			ReturnValues: types.ReturnValueUpdatedNew,
+		}
+		if attempt == 2 {
+			input.UpdateExpression = aws.String("SET #step_stats = :step_stats, #updated_at = :updated_at" + versionIncrement)
+			input.ConditionExpression = aws.String("attribute_exists(job_id) AND attribute_not_exists(#step_stats)")
+			input.ExpressionAttributeValues[":step_stats"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{step: value}}
+		} else {
+			input.UpdateExpression = aws.String("SET #step_stats.#step = :stats, #updated_at = :updated_at" + versionIncrement)
+			input.ConditionExpression = aws.String("attribute_exists(#step_stats)")
+			input.ExpressionAttributeNames["#step"] = step
+			input.ExpressionAttributeValues[":stats"] = value
+		}
+
+		result, err := r.client.UpdateItem(ctx, input)
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			continue
+		}
+		if err != nil {
+			r.logger.Error("Failed to record job step stats",
+				zap.String("job_id", jobID),
+				zap.String("step", step),
+				zap.Error(err),
+			)
+			return 0, fmt.Errorf("failed to record job step stats: %w", err)
+		}
+
+		var version int64
+		if v, ok := result.Attributes["version"].(*types.AttributeValueMemberN); ok {
+			version, _ = strconv.ParseInt(v.Value, 10, 64)
+		}
+		return version, nil
+	}
+	return 0, ErrJobNotFound
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newStepStatsRepository(t *testing.T) *DynamoDBRepository {
+	t.Helper()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	require.NoError(t, repo.CreateJob(context.Background(), &domain.Job{
+		JobID:  "job-1",
+		UserID: "user-1",
+		Status: domain.StatusProcessing,
+		Stage:  domain.SceneGenerating(1),
+	}))
+	return repo
+}
+
+func TestRecordJobStepStats(t *testing.T) {
+	ctx := context.Background()
+	repo := newStepStatsRepository(t)
+	created, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+
+	// The first step creates the map, later ones add to it
+	version, err := repo.RecordJobStepStats(ctx, "job-1", domain.StepScript, domain.StepStats{LatencySeconds: 12.5})
+	require.NoError(t, err)
+	require.Equal(t, created.Version+1, version)
+	version, err = repo.RecordJobStepStats(ctx, "job-1", domain.SceneStep(1), domain.StepStats{LatencySeconds: 90, Polls: 31, Retries: 1, Credits: 21})
+	require.NoError(t, err)
+	require.Equal(t, created.Version+2, version)
+
+	// A step that runs again replaces its own entry
+	_, err = repo.RecordJobStepStats(ctx, "job-1", domain.StepScript, domain.StepStats{LatencySeconds: 10})
+	require.NoError(t, err)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]domain.StepStats{
+		domain.StepScript:   {LatencySeconds: 10},
+		domain.SceneStep(1): {LatencySeconds: 90, Polls: 31, Retries: 1, Credits: 21},
+	}, job.StepStats)
+}
+
+func TestRecordJobStepStats_SurvivesStaleWholeItemWrite(t *testing.T) {
+	ctx := context.Background()
+	repo := newStepStatsRepository(t)
+	pipelineCopy, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+
+	_, err = repo.RecordJobStepStats(ctx, "job-1", domain.SceneStep(1), domain.StepStats{Credits: 21})
+	require.NoError(t, err)
+
+	// The pipeline saves its progress from the copy it read before the stats were written
+	pipelineCopy.Stage = domain.SceneComplete(1)
+	require.NoError(t, repo.UpdateJobWithRetry(ctx, pipelineCopy, func(fresh *domain.Job) {
+		fresh.Stage = domain.SceneComplete(1)
+	}))
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Equal(t, domain.SceneComplete(1), job.Stage)
+	require.Equal(t, 21, job.StepStats[domain.SceneStep(1)].Credits)
+}
+
+func TestRecordJobStepStats_MissingJob(t *testing.T) {
+	repo := newStepStatsRepository(t)
+	_, err := repo.RecordJobStepStats(context.Background(), "gone", domain.StepMusic, domain.StepStats{})
+	require.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// parentJobsIndex is the GSI keyed by parent_job_id and created_at, used to find a job's variants
+const parentJobsIndex = "ParentJobsIndex"
+
+// ListVariantJobs returns the child jobs of an A/B variant parent, ordered by variant index
+func (r *DynamoDBRepository) ListVariantJobs(ctx context.Context, parentJobID string) ([]*domain.Job, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(parentJobsIndex),
+		KeyConditionExpression: aws.String("parent_job_id = :parent_job_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":parent_job_id": &types.AttributeValueMemberS{Value: parentJobID},
+		},
+	}
+
+	var jobs []*domain.Job
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to list variant jobs",
+				zap.String("parent_job_id", parentJobID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to list variant jobs: %w", err)
+		}
+
+		var page []*domain.Job
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal variant jobs: %w", err)
+		}
+		jobs = append(jobs, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	// Variants are created together, so created_at rarely tells them apart
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].VariantIndex < jobs[j].VariantIndex
+	})
+	return jobs, nil
+}
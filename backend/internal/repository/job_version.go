@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrVersionConflict is returned by UpdateJob when the job was written by someone else since
+// it was read. Reload the job and apply the change again.
+var ErrVersionConflict = errors.New("job was modified since it was read")
+
+// maxJobWriteAttempts bounds UpdateJobWithRetry's reload-and-retry loop
+const maxJobWriteAttempts = 3
+
+// Targeted updates append versionIncrement to their update expression (with #version and :one
+// bound), so a whole-item write from a copy read before them fails instead of undoing them
+const versionIncrement = " ADD #version :one"
+
+var versionStep = &types.AttributeValueMemberN{Value: "1"}
+
+// UpdateJobStage writes only a job's stage, scenes completed and ETA, for progress updates that
+// don't need a whole-item write. It fails with ErrVersionConflict if the job's status changed
+// since it was read. job.Version is kept in step when no other write happened in between.
+func (r *DynamoDBRepository) UpdateJobStage(ctx context.Context, job *domain.Job) error {
+	now := getCurrentTimestamp()
+	result, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: job.JobID},
+		},
+		UpdateExpression:    aws.String("SET #stage = :stage, #scenes_completed = :scenes_completed, #eta_seconds = :eta_seconds, #eta_updated_at = :eta_updated_at, #updated_at = :updated_at" + versionIncrement),
+		ConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":           "status",
+			"#stage":            "stage",
+			"#scenes_completed": "scenes_completed",
+			"#eta_seconds":      "eta_seconds",
+			"#eta_updated_at":   "eta_updated_at",
+			"#updated_at":       "updated_at",
+			"#version":          "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":           &types.AttributeValueMemberS{Value: job.Status},
+			":stage":            &types.AttributeValueMemberS{Value: job.Stage.String()},
+			":scenes_completed": &types.AttributeValueMemberN{Value: strconv.Itoa(job.ScenesCompleted)},
+			":eta_seconds":      &types.AttributeValueMemberN{Value: strconv.Itoa(job.ETASeconds)},
+			":eta_updated_at":   &types.AttributeValueMemberN{Value: strconv.FormatInt(job.ETAUpdatedAt, 10)},
+			":updated_at":       &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+			":one":              versionStep,
+		},
+		ReturnValues:                        types.ReturnValueUpdatedNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return r.jobWriteConflict(job, ccf.Item)
+		}
+		r.logger.Error("Failed to update job stage",
+			zap.String("job_id", job.JobID),
+			zap.Stringer("stage", job.Stage),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to update job stage: %w", err)
+	}
+
+	job.UpdatedAt = now
+	// Any other jump means someone else wrote the job too; leaving the old version makes the
+	// next UpdateJob reload rather than overwrite their change
+	if version, ok := result.Attributes["version"].(*types.AttributeValueMemberN); ok {
+		if v, err := strconv.ParseInt(version.Value, 10, 64); err == nil && v == job.Version+1 {
+			job.Version = v
+		}
+	}
+	return nil
+}
+
+// UpdateJobWithRetry writes job like UpdateJob. On a version conflict it reloads the stored
+// job, applies apply to it and tries again, up to maxJobWriteAttempts writes. apply should set
+// only the fields the caller owns, so other writers' changes survive. On success job holds
+// what was written.
+func (r *DynamoDBRepository) UpdateJobWithRetry(ctx context.Context, job *domain.Job, apply func(job *domain.Job)) error {
+	current := job
+	for attempt := 1; ; attempt++ {
+		err := r.UpdateJob(ctx, current)
+		if err == nil {
+			if current != job {
+				*job = *current
+			}
+			return nil
+		}
+		if !errors.Is(err, ErrVersionConflict) || attempt == maxJobWriteAttempts {
+			return err
+		}
+
+		r.logger.Info("Job changed since it was read, reloading",
+			zap.String("job_id", job.JobID),
+			zap.Int64("version", current.Version),
+			zap.Int("attempt", attempt),
+		)
+		fresh, err := r.GetJob(ctx, job.JobID)
+		if err != nil {
+			return err
+		}
+		apply(fresh)
+		current = fresh
+	}
+}
+
+// jobWriteConflict explains a failed conditional job write from the item DynamoDB returned
+func (r *DynamoDBRepository) jobWriteConflict(job *domain.Job, stored map[string]types.AttributeValue) error {
+	if len(stored) == 0 {
+		return ErrJobNotFound
+	}
+
+	var current domain.Job
+	if err := attributevalue.UnmarshalMap(stored, &current); err != nil {
+		return fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+	if current.CancelRequested && !job.CancelRequested {
+		return ErrJobCancelRequested
+	}
+
+	r.logger.Info("Job write lost a version race",
+		zap.String("job_id", job.JobID),
+		zap.Int64("expected_version", job.Version),
+		zap.Int64("stored_version", current.Version),
+	)
+	return ErrVersionConflict
+}
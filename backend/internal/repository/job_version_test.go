@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedJobTable holds one job item and evaluates the version, cancel and status conditions
+// job writes use the way DynamoDB would. beforePut runs ahead of each PutItem, standing in for
+// a request that writes the job concurrently.
+type versionedJobTable struct {
+	*fakeDynamoDB
+	mu        sync.Mutex
+	stored    map[string]types.AttributeValue
+	puts      int
+	beforePut func(stored map[string]types.AttributeValue)
+}
+
+func newVersionedJobTable(t *testing.T, job *domain.Job) *versionedJobTable {
+	t.Helper()
+	table := &versionedJobTable{fakeDynamoDB: newFakeDynamoDB(t)}
+	if job != nil {
+		item, err := attributevalue.MarshalMap(job)
+		require.NoError(t, err)
+		table.stored = item
+	}
+	return table
+}
+
+func (f *versionedJobTable) conditionalCheckFailed() error {
+	return &types.ConditionalCheckFailedException{
+		Message: aws.String("The conditional request failed"),
+		Item:    f.stored,
+	}
+}
+
+func (f *versionedJobTable) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts++
+	if f.beforePut != nil {
+		f.beforePut(f.stored)
+	}
+
+	condition := aws.ToString(in.ConditionExpression)
+	if f.stored == nil {
+		return nil, f.conditionalCheckFailed()
+	}
+	_, hasVersion := f.stored["version"]
+	if expected, ok := in.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN); ok {
+		if !hasVersion || strconv.FormatInt(attrN(f.stored, "version"), 10) != expected.Value {
+			return nil, f.conditionalCheckFailed()
+		}
+	} else if hasVersion {
+		return nil, f.conditionalCheckFailed()
+	}
+	if flag, ok := f.stored["cancel_requested"].(*types.AttributeValueMemberBOOL); ok && flag.Value &&
+		strings.Contains(condition, "cancel_requested = :false") {
+		return nil, f.conditionalCheckFailed()
+	}
+
+	f.stored = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *versionedJobTable) GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.stored}, nil
+}
+
+// listAppend matches "#a = list_append(if_not_exists(#a, :empty), :b), " in an update expression
+var listAppend = regexp.MustCompile(`(#\w+) = list_append\(if_not_exists\(#\w+, :\w+\), (:\w+)\)(, )?`)
+
+// UpdateItem applies "SET #a = :a, ... ADD #version :one" expressions guarded by "#status = :status";
+// list_append assignments append to the stored list
+func (f *versionedJobTable) UpdateItem(_ context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stored == nil {
+		return nil, f.conditionalCheckFailed()
+	}
+	if aws.ToString(in.ConditionExpression) == "#status = :status" &&
+		attrS(f.stored, "status") != in.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS).Value {
+		return nil, f.conditionalCheckFailed()
+	}
+
+	updated := make(map[string]types.AttributeValue, len(f.stored))
+	for name, value := range f.stored {
+		updated[name] = value
+	}
+	set, add, _ := strings.Cut(strings.TrimPrefix(aws.ToString(in.UpdateExpression), "SET "), " ADD ")
+	for _, match := range listAppend.FindAllStringSubmatch(set, -1) {
+		var list []types.AttributeValue
+		if stored, ok := updated[in.ExpressionAttributeNames[match[1]]].(*types.AttributeValueMemberL); ok {
+			list = append(list, stored.Value...)
+		}
+		list = append(list, in.ExpressionAttributeValues[match[2]].(*types.AttributeValueMemberL).Value...)
+		updated[in.ExpressionAttributeNames[match[1]]] = &types.AttributeValueMemberL{Value: list}
+	}
+	set = listAppend.ReplaceAllString(set, "")
+	for _, assignment := range strings.Split(set, ", ") {
+		name, value, _ := strings.Cut(assignment, " = ")
+		updated[in.ExpressionAttributeNames[name]] = in.ExpressionAttributeValues[value]
+	}
+	if add != "#version :one" {
+		return nil, fmt.Errorf("update doesn't bump the version: %q", aws.ToString(in.UpdateExpression))
+	}
+	version := strconv.FormatInt(attrN(f.stored, "version")+1, 10)
+	updated["version"] = &types.AttributeValueMemberN{Value: version}
+	f.stored = updated
+
+	return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+		"version": updated["version"],
+	}}, nil
+}
+
+func storedJob(t *testing.T, table *versionedJobTable) domain.Job {
+	t.Helper()
+	var job domain.Job
+	require.NoError(t, attributevalue.UnmarshalMap(table.stored, &job))
+	return job
+}
+
+func TestUpdateJob_Versioning(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("write increments the version", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Version: 1})
+		repo := newTestJobRepository(table)
+
+		job := &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Stage: domain.StageScriptComplete, Version: 1}
+		require.NoError(t, repo.UpdateJob(ctx, job))
+		require.Equal(t, int64(2), job.Version)
+		require.Equal(t, int64(2), storedJob(t, table).Version)
+	})
+
+	t.Run("stale copy gets a version conflict", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Stage: domain.SceneComplete(2), Version: 3})
+		repo := newTestJobRepository(table)
+
+		job := &domain.Job{JobID: "job-1", Stage: domain.SceneComplete(1), Version: 2}
+		require.ErrorIs(t, repo.UpdateJob(ctx, job), ErrVersionConflict)
+		require.Equal(t, int64(2), job.Version, "a failed write must not advance the caller's version")
+		require.Equal(t, domain.SceneComplete(2), storedJob(t, table).Stage)
+	})
+
+	t.Run("legacy job without a version", func(t *testing.T) {
+		table := newVersionedJobTable(t, nil)
+		table.stored = map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: "job-1"}}
+		repo := newTestJobRepository(table)
+
+		job := &domain.Job{JobID: "job-1", Stage: domain.StageComposing}
+		require.NoError(t, repo.UpdateJob(ctx, job))
+		require.Equal(t, int64(1), storedJob(t, table).Version)
+	})
+
+	t.Run("deleted job", func(t *testing.T) {
+		repo := newTestJobRepository(newVersionedJobTable(t, nil))
+		require.ErrorIs(t, repo.UpdateJob(ctx, &domain.Job{JobID: "job-1", Version: 1}), ErrJobNotFound)
+	})
+
+	t.Run("concurrent writers from the same version", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Version: 1})
+		repo := newTestJobRepository(table)
+
+		errs := make([]error, 8)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = repo.UpdateJob(ctx, &domain.Job{JobID: "job-1", Stage: domain.SceneGenerating(i + 1), Version: 1})
+			}()
+		}
+		wg.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				require.ErrorIs(t, err, ErrVersionConflict)
+			}
+		}
+		require.Equal(t, 1, succeeded)
+		require.Equal(t, int64(2), storedJob(t, table).Version)
+	})
+}
+
+func TestUpdateJobWithRetry(t *testing.T) {
+	ctx := context.Background()
+	setStage := func(job *domain.Job) { job.Stage = domain.StageAudioComplete }
+
+	t.Run("reloads and reapplies after a conflict", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Stage: domain.StageAudioGenerating, Version: 4})
+		repo := newTestJobRepository(table)
+		// Another request records a webhook attempt just before the pipeline's first write
+		table.beforePut = func(stored map[string]types.AttributeValue) {
+			stored["webhook_attempts"] = &types.AttributeValueMemberN{Value: "2"}
+			stored["version"] = &types.AttributeValueMemberN{Value: "5"}
+			table.beforePut = nil
+		}
+
+		job := &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Version: 4}
+		setStage(job)
+		require.NoError(t, repo.UpdateJobWithRetry(ctx, job, setStage))
+
+		stored := storedJob(t, table)
+		require.Equal(t, domain.StageAudioComplete, stored.Stage)
+		require.Equal(t, 2, stored.WebhookAttempts, "the other request's write must survive")
+		require.Equal(t, int64(6), stored.Version)
+		require.Equal(t, stored, *job)
+		require.Equal(t, 2, table.puts)
+	})
+
+	t.Run("gives up when every write races", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Version: 1})
+		repo := newTestJobRepository(table)
+		table.beforePut = func(stored map[string]types.AttributeValue) {
+			stored["version"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(attrN(stored, "version")+1, 10)}
+		}
+
+		err := repo.UpdateJobWithRetry(ctx, &domain.Job{JobID: "job-1", Version: 1}, setStage)
+		require.ErrorIs(t, err, ErrVersionConflict)
+		require.Equal(t, maxJobWriteAttempts, table.puts)
+	})
+
+	t.Run("cancel is not retried", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", CancelRequested: true, Version: 2})
+		repo := newTestJobRepository(table)
+
+		err := repo.UpdateJobWithRetry(ctx, &domain.Job{JobID: "job-1", Version: 1}, setStage)
+		require.ErrorIs(t, err, ErrJobCancelRequested)
+		require.Equal(t, 1, table.puts)
+	})
+}
+
+func TestUpdateJobStage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("keeps the caller's version in step", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Version: 1})
+		repo := newTestJobRepository(table)
+
+		job := &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Stage: domain.SceneGenerating(2), ScenesCompleted: 1, ETASeconds: 240, ETAUpdatedAt: 1700000000, Version: 1}
+		require.NoError(t, repo.UpdateJobStage(ctx, job))
+		require.Equal(t, int64(2), job.Version)
+
+		stored := storedJob(t, table)
+		require.Equal(t, domain.SceneGenerating(2), stored.Stage)
+		require.Equal(t, 1, stored.ScenesCompleted)
+		require.Equal(t, 240, stored.ETASeconds)
+		require.Equal(t, int64(1700000000), stored.ETAUpdatedAt)
+
+		// The next whole-item write goes through without a reload
+		require.NoError(t, repo.UpdateJob(ctx, job))
+	})
+
+	t.Run("a write in between leaves the caller stale", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Version: 2})
+		repo := newTestJobRepository(table)
+
+		job := &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Stage: domain.StageComposing, Version: 1}
+		require.NoError(t, repo.UpdateJobStage(ctx, job))
+		require.Equal(t, int64(1), job.Version)
+		require.Equal(t, int64(3), storedJob(t, table).Version)
+		require.ErrorIs(t, repo.UpdateJob(ctx, job), ErrVersionConflict)
+	})
+
+	t.Run("status changed since the job was read", func(t *testing.T) {
+		table := newVersionedJobTable(t, &domain.Job{JobID: "job-1", Status: domain.StatusFailed, Version: 2})
+		repo := newTestJobRepository(table)
+
+		job := &domain.Job{JobID: "job-1", Status: domain.StatusProcessing, Stage: domain.StageAudioGenerating, Version: 1}
+		require.ErrorIs(t, repo.UpdateJobStage(ctx, job), ErrVersionConflict)
+		require.Equal(t, domain.StatusFailed, storedJob(t, table).Status)
+	})
+}
@@ -0,0 +1,758 @@
+package repository
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+// LocalS3 is a filesystem-backed stand-in for S3, for ENVIRONMENT=local. It speaks the part of
+// the S3 REST API the asset repository uses (objects, copies, listings, batch deletes and
+// multipart uploads, including browser uploads of presigned parts) on a loopback port, storing objects as files under root/<bucket>/<key>. Signatures
+// are not checked, so presigned URLs work from the browser and from ffmpeg.
+type LocalS3 struct {
+	root     string
+	listener net.Listener
+	server   *http.Server
+	logger   *zap.Logger
+
+	mu           sync.Mutex
+	contentTypes map[string]string            // bucket/key to Content-Type, for objects written by this process
+	metadata     map[string]map[string]string // bucket/key to user metadata, likewise
+	etags        map[string]string            // bucket/key to the content ETag its write returned, likewise
+	uploadMeta   map[string]map[string]string // Upload ID to the metadata its object gets on completion
+}
+
+// localS3Internal holds in-progress multipart uploads and partial writes; bucket names can't
+// start with a dot, so it never collides with a bucket
+const localS3Internal = ".s3"
+
+// StartLocalS3 serves a local S3 on addr (host:port; port 0 picks a free one) storing objects
+// under root
+func StartLocalS3(root, addr string, logger *zap.Logger) (*LocalS3, error) {
+	if err := os.MkdirAll(filepath.Join(root, localS3Internal, "uploads"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local S3 directory: %w", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for local S3: %w", err)
+	}
+
+	l := &LocalS3{
+		root:         root,
+		listener:     listener,
+		logger:       logger,
+		contentTypes: make(map[string]string),
+		metadata:     make(map[string]map[string]string),
+		etags:        make(map[string]string),
+		uploadMeta:   make(map[string]map[string]string),
+	}
+	l.server = &http.Server{Handler: l, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := l.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Local S3 server stopped", zap.Error(err))
+		}
+	}()
+	logger.Info("Local S3 started", zap.String("url", l.URL()), zap.String("root", root))
+	return l, nil
+}
+
+// URL is the endpoint the local S3 serves on
+func (l *LocalS3) URL() string {
+	return "http://" + l.listener.Addr().String()
+}
+
+// Client returns an S3 client that talks to the local S3 with path-style requests
+func (l *LocalS3) Client() *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(l.URL()),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+		// Plain checksums keep request bodies unchunked over http
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+}
+
+// Close stops the server; stored objects are kept
+func (l *LocalS3) Close() error {
+	return l.server.Close()
+}
+
+// ServeHTTP routes path-style S3 requests: /<bucket> and /<bucket>/<key>
+func (l *LocalS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Presigned uploads come straight from the frontend
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, PUT, POST, DELETE")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" || strings.HasPrefix(bucket, ".") || strings.ContainsAny(bucket, `\`) {
+		writeS3Error(w, http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid.")
+		return
+	}
+	query := r.URL.Query()
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead || r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusOK) // Buckets always exist
+		case r.Method == http.MethodGet && query.Has("uploads"):
+			l.listMultipartUploads(w, bucket, query.Get("prefix"))
+		case r.Method == http.MethodGet:
+			l.listObjects(w, bucket, query.Get("prefix"), query.Get("continuation-token"), query.Get("start-after"), query.Get("max-keys"))
+		case r.Method == http.MethodPost && query.Has("delete"):
+			l.deleteObjects(w, r, bucket)
+		default:
+			writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "The local S3 does not support this bucket operation.")
+		}
+		return
+	}
+
+	objectPath, ok := l.objectPath(bucket, key)
+	if !ok {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "The specified key is not valid.")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		l.createMultipartUpload(w, r, bucket, key)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		l.uploadPart(w, r, query.Get("uploadId"), query.Get("partNumber"))
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		l.listParts(w, bucket, key, query.Get("uploadId"))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		l.completeMultipartUpload(w, r, bucket, key, objectPath, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		l.abortMultipartUpload(w, query.Get("uploadId"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		l.copyObject(w, r, bucket, key, objectPath)
+	case r.Method == http.MethodPut:
+		l.putObject(w, r, bucket, key, objectPath)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		l.getObject(w, r, bucket, key, objectPath)
+	case r.Method == http.MethodDelete:
+		os.Remove(objectPath)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "The local S3 does not support this object operation.")
+	}
+}
+
+// objectPath maps a key to its file, rejecting keys that would escape the bucket directory
+func (l *LocalS3) objectPath(bucket, key string) (string, bool) {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", false
+		}
+	}
+	return filepath.Join(l.root, bucket, filepath.FromSlash(key)), true
+}
+
+func (l *LocalS3) putObject(w http.ResponseWriter, r *http.Request, bucket, key, objectPath string) {
+	etag, err := l.writeFile(objectPath, requestBody(r))
+	if err != nil {
+		l.logger.Error("Local S3 write failed", zap.String("key", key), zap.Error(err))
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	l.setContentType(bucket, key, r.Header.Get("Content-Type"))
+	l.setMetadata(bucket, key, requestMetadata(r))
+	l.setETag(bucket, key, etag)
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+}
+
+// copyObject serves CopyObject. The copy takes the source's content type and metadata, or the
+// request's with a REPLACE metadata directive.
+func (l *LocalS3) copyObject(w http.ResponseWriter, r *http.Request, bucket, key, objectPath string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "The copy source is not valid.")
+		return
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	sourcePath, ok := l.objectPath(sourceBucket, sourceKey)
+	if sourceBucket == "" || strings.HasPrefix(sourceBucket, ".") || !ok {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "The copy source is not valid.")
+		return
+	}
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	defer file.Close()
+
+	contentType := l.contentType(sourceBucket, sourceKey)
+	l.mu.Lock()
+	metadata := l.metadata[sourceBucket+"/"+sourceKey]
+	l.mu.Unlock()
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		contentType = r.Header.Get("Content-Type")
+		metadata = requestMetadata(r)
+	}
+
+	etag, err := l.writeFile(objectPath, file)
+	if err != nil {
+		l.logger.Error("Local S3 copy failed", zap.String("key", key), zap.Error(err))
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	l.setContentType(bucket, key, contentType)
+	l.setMetadata(bucket, key, metadata)
+	l.setETag(bucket, key, etag)
+	writeXML(w, copyObjectResult{ETag: etag, LastModified: time.Now().UTC().Format(time.RFC3339)})
+}
+
+func (l *LocalS3) getObject(w http.ResponseWriter, r *http.Request, bucket, key, objectPath string) {
+	file, err := os.Open(objectPath)
+	if err != nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	w.Header().Set("Content-Type", l.contentType(bucket, key))
+	l.mu.Lock()
+	for name, value := range l.metadata[bucket+"/"+key] {
+		w.Header().Set(localMetadataPrefix+name, value)
+	}
+	l.mu.Unlock()
+	w.Header().Set("ETag", l.objectETag(bucket, key, info))
+	http.ServeContent(w, r, path.Base(key), info.ModTime(), file)
+}
+
+// writeFile stores body at objectPath atomically, returning its ETag
+func (l *LocalS3) writeFile(objectPath string, body io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(l.root, localS3Internal), "put-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+func (l *LocalS3) setContentType(bucket, key, contentType string) {
+	if contentType == "" {
+		return
+	}
+	l.mu.Lock()
+	l.contentTypes[bucket+"/"+key] = contentType
+	l.mu.Unlock()
+}
+
+func (l *LocalS3) setETag(bucket, key, etag string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.etags[bucket+"/"+key] = etag
+}
+
+// objectETag returns the ETag the object's write returned, changing whenever its content does
+// as S3's does. Objects from before this process restarted fall back to their mtime and size.
+func (l *LocalS3) objectETag(bucket, key string, info os.FileInfo) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if etag, ok := l.etags[bucket+"/"+key]; ok {
+		return etag
+	}
+	return fmt.Sprintf(`"%x-%d"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// localMetadataPrefix starts the headers that carry an object's user metadata
+const localMetadataPrefix = "X-Amz-Meta-"
+
+// requestMetadata returns the user metadata a write carries, keys lowercased as S3 stores them
+func requestMetadata(r *http.Request) map[string]string {
+	var metadata map[string]string
+	for name, values := range r.Header {
+		if !strings.HasPrefix(name, localMetadataPrefix) || len(values) == 0 {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[strings.ToLower(strings.TrimPrefix(name, localMetadataPrefix))] = values[0]
+	}
+	return metadata
+}
+
+// setMetadata replaces an object's user metadata, as every S3 write does
+func (l *LocalS3) setMetadata(bucket, key string, metadata map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if metadata == nil {
+		delete(l.metadata, bucket+"/"+key)
+		return
+	}
+	l.metadata[bucket+"/"+key] = metadata
+}
+
+// contentType returns the type an object was written with, or one guessed from its extension
+// for objects written before a restart
+func (l *LocalS3) contentType(bucket, key string) string {
+	l.mu.Lock()
+	contentType, ok := l.contentTypes[bucket+"/"+key]
+	l.mu.Unlock()
+	if ok {
+		return contentType
+	}
+	if contentType, ok := localContentTypes[path.Ext(key)]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// localContentTypes covers the asset types the pipeline writes that Go's built-in table lacks
+var localContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mp3":  "audio/mpeg",
+	".vtt":  "text/vtt",
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []listedObject `xml:"Contents"`
+}
+
+type listedObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// listObjects serves ListObjectsV2; continuation tokens are the last key of the previous page
+func (l *LocalS3) listObjects(w http.ResponseWriter, bucket, prefix, token, startAfter, maxKeysParam string) {
+	maxKeys := 1000
+	if n, err := strconv.Atoi(maxKeysParam); err == nil && n >= 0 && n < maxKeys {
+		maxKeys = n
+	}
+	after := startAfter
+	if token != "" {
+		after = token
+	}
+
+	var objects []listedObject
+	bucketDir := filepath.Join(l.root, bucket)
+	err := filepath.WalkDir(bucketDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(bucketDir, p)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, listedObject{
+			Key:          key,
+			LastModified: info.ModTime().UTC().Format(time.RFC3339),
+			ETag:         l.objectETag(bucket, key, info),
+			Size:         info.Size(),
+			StorageClass: "STANDARD",
+		})
+		return nil
+	})
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	result := listBucketResult{Name: bucket, Prefix: prefix, MaxKeys: maxKeys, ContinuationToken: token}
+	if len(objects) > maxKeys {
+		objects = objects[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = objects[len(objects)-1].Key
+	}
+	result.Contents = objects
+	result.KeyCount = len(objects)
+	writeXML(w, result)
+}
+
+type deleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []struct {
+		Key string `xml:"Key"`
+	} `xml:"Deleted"`
+}
+
+func (l *LocalS3) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	var req deleteRequest
+	if err := xml.NewDecoder(requestBody(r)).Decode(&req); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	var result deleteResult
+	for _, object := range req.Objects {
+		if objectPath, ok := l.objectPath(bucket, object.Key); ok {
+			os.Remove(objectPath)
+		}
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, struct {
+				Key string `xml:"Key"`
+			}{Key: object.Key})
+		}
+	}
+	writeXML(w, result)
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int `xml:"PartNumber"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+type listPartsResult struct {
+	XMLName  xml.Name     `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket   string       `xml:"Bucket"`
+	Key      string       `xml:"Key"`
+	UploadID string       `xml:"UploadId"`
+	Parts    []listedPart `xml:"Part"`
+}
+
+type listedPart struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+type listMultipartUploadsResult struct {
+	XMLName xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket  string         `xml:"Bucket"`
+	Prefix  string         `xml:"Prefix"`
+	Uploads []listedUpload `xml:"Upload"`
+}
+
+type listedUpload struct {
+	Key       string `xml:"Key"`
+	UploadID  string `xml:"UploadId"`
+	Initiated string `xml:"Initiated"`
+}
+
+// localUploadInfo is the file in an upload's directory recording its start time and bucket/key
+const localUploadInfo = "upload"
+
+func (l *LocalS3) uploadDir(uploadID string) (string, bool) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", false
+	}
+	dir := filepath.Join(l.root, localS3Internal, "uploads", uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		return "", false
+	}
+	return dir, true
+}
+
+func (l *LocalS3) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	uploadID := hex.EncodeToString(id)
+	if err := os.MkdirAll(filepath.Join(l.root, localS3Internal, "uploads", uploadID), 0o755); err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	info := time.Now().UTC().Format(time.RFC3339) + "\n" + bucket + "/" + key
+	if err := os.WriteFile(filepath.Join(l.root, localS3Internal, "uploads", uploadID, localUploadInfo), []byte(info), 0o644); err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	l.setContentType(bucket, key, r.Header.Get("Content-Type"))
+	l.mu.Lock()
+	l.uploadMeta[uploadID] = requestMetadata(r) // Applied on completion, so it never labels the object being replaced
+	l.mu.Unlock()
+	writeXML(w, initiateMultipartUploadResult{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (l *LocalS3) uploadPart(w http.ResponseWriter, r *http.Request, uploadID, partNumber string) {
+	dir, ok := l.uploadDir(uploadID)
+	number, err := strconv.Atoi(partNumber)
+	if !ok || err != nil || number < 1 {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+	etag, err := l.writeFile(filepath.Join(dir, strconv.Itoa(number)), requestBody(r))
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (l *LocalS3) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, objectPath, uploadID string) {
+	dir, ok := l.uploadDir(uploadID)
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(requestBody(r)).Decode(&req); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	var parts []io.Reader
+	for _, part := range req.Parts {
+		file, err := os.Open(filepath.Join(dir, strconv.Itoa(part.PartNumber)))
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("Part %d was not uploaded.", part.PartNumber))
+			return
+		}
+		defer file.Close()
+		parts = append(parts, file)
+	}
+	etag, err := l.writeFile(objectPath, io.MultiReader(parts...))
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	os.RemoveAll(dir)
+	l.mu.Lock()
+	metadata := l.uploadMeta[uploadID]
+	delete(l.uploadMeta, uploadID)
+	l.mu.Unlock()
+	l.setMetadata(bucket, key, metadata)
+	l.setETag(bucket, key, etag)
+	writeXML(w, completeMultipartUploadResult{Bucket: bucket, Key: key, ETag: etag})
+}
+
+// listParts serves ListParts in one page; part ETags are the MD5s uploadPart returned
+func (l *LocalS3) listParts(w http.ResponseWriter, bucket, key, uploadID string) {
+	dir, ok := l.uploadDir(uploadID)
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	result := listPartsResult{Bucket: bucket, Key: key, UploadID: uploadID}
+	for _, entry := range entries {
+		number, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		sum := md5.Sum(data)
+		result.Parts = append(result.Parts, listedPart{
+			PartNumber:   number,
+			LastModified: time.Now().UTC().Format(time.RFC3339),
+			ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+			Size:         int64(len(data)),
+		})
+	}
+	sort.Slice(result.Parts, func(i, j int) bool { return result.Parts[i].PartNumber < result.Parts[j].PartNumber })
+	writeXML(w, result)
+}
+
+// listMultipartUploads serves ListMultipartUploads in one page
+func (l *LocalS3) listMultipartUploads(w http.ResponseWriter, bucket, prefix string) {
+	uploadsDir := filepath.Join(l.root, localS3Internal, "uploads")
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	result := listMultipartUploadsResult{Bucket: bucket, Prefix: prefix}
+	for _, entry := range entries {
+		info, err := os.ReadFile(filepath.Join(uploadsDir, entry.Name(), localUploadInfo))
+		if err != nil {
+			continue
+		}
+		initiated, objectKey, _ := strings.Cut(string(info), "\n")
+		uploadBucket, key, _ := strings.Cut(objectKey, "/")
+		if uploadBucket != bucket || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		result.Uploads = append(result.Uploads, listedUpload{Key: key, UploadID: entry.Name(), Initiated: initiated})
+	}
+	writeXML(w, result)
+}
+
+func (l *LocalS3) abortMultipartUpload(w http.ResponseWriter, uploadID string) {
+	if dir, ok := l.uploadDir(uploadID); ok {
+		os.RemoveAll(dir)
+	}
+	l.mu.Lock()
+	delete(l.uploadMeta, uploadID)
+	l.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestBody returns the request's payload, decoding aws-chunked framing if the SDK used it
+func requestBody(r *http.Request) io.Reader {
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") ||
+		strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return &awsChunkedReader{r: bufio.NewReader(r.Body)}
+	}
+	return r.Body
+}
+
+// awsChunkedReader strips the "<hex size>[;chunk-signature=...]\r\n<data>\r\n" framing of
+// streaming uploads, stopping at the zero-length chunk (trailers are ignored)
+type awsChunkedReader struct {
+	r         *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("malformed aws-chunked body: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue // CRLF closing the previous chunk
+		}
+		sizeHex, _, _ := strings.Cut(line, ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("malformed aws-chunked size %q", sizeHex)
+		}
+		if size == 0 {
+			c.done = true
+			return 0, io.EOF
+		}
+		c.remaining = size
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if err == io.EOF && c.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(s3Error{Code: code, Message: message})
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestLocalS3(t *testing.T) (*LocalS3, *S3AssetRepository) {
+	t.Helper()
+	local, err := StartLocalS3(t.TempDir(), "127.0.0.1:0", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { local.Close() })
+
+	service := NewS3Service(local.Client(), "assets", UploadOptions{PartSize: manager.MinUploadPartSize},
+		PresignCacheOptions{Disabled: true}, zap.NewNop())
+	return local, service
+}
+
+func writeLocalFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, data, 0o644))
+	return p
+}
+
+func TestLocalS3_ObjectRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	_, service := newTestLocalS3(t)
+	require.NoError(t, service.HealthCheck(ctx))
+
+	clip := []byte("not really an mp4")
+	_, err := service.UploadFile(ctx, "assets", "users/u1/jobs/j1/clips/scene-001.mp4", writeLocalFile(t, "clip.mp4", clip), "video/mp4")
+	require.NoError(t, err)
+
+	size, err := service.ObjectSize(ctx, "assets", "users/u1/jobs/j1/clips/scene-001.mp4")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(clip)), size)
+
+	body, err := service.OpenObject(ctx, "assets", "users/u1/jobs/j1/clips/scene-001.mp4")
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	require.Equal(t, clip, data)
+
+	// Presigned URLs are plain HTTP the browser and ffmpeg can fetch
+	url, err := service.GetPresignedURL(ctx, "users/u1/jobs/j1/clips/scene-001.mp4", time.Hour)
+	require.NoError(t, err)
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	require.Equal(t, clip, data)
+
+	putURL, err := service.GetPresignedPutURL(ctx, "users/u1/uploads/logo.png", "image/png", time.Hour)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, putURL, bytes.NewReader([]byte("png")))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "image/png")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	sizes, err := service.ListObjectSizes(ctx, "assets", "users/u1/")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{
+		"users/u1/jobs/j1/clips/scene-001.mp4": int64(len(clip)),
+		"users/u1/uploads/logo.png":            3,
+	}, sizes)
+
+	require.NoError(t, service.DeleteFile(ctx, "assets", "users/u1/uploads/logo.png"))
+	_, err = service.ObjectSize(ctx, "assets", "users/u1/uploads/logo.png")
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestLocalS3_ObjectMetadata(t *testing.T) {
+	ctx := context.Background()
+	_, service := newTestLocalS3(t)
+	key := "users/u1/jobs/j1/final/video-abc.mp4"
+
+	_, err := service.UploadFileWithMetadata(ctx, "assets", key, writeLocalFile(t, "video.mp4", []byte("final")), "video/mp4",
+		map[string]string{"Composition": "abc"})
+	require.NoError(t, err)
+
+	info, err := service.ObjectInfo(ctx, "assets", key)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), info.Size)
+	require.NotEmpty(t, info.ETag)
+	require.Equal(t, map[string]string{"composition": "abc"}, info.Metadata)
+
+	// Overwriting the object replaces its metadata and its ETag
+	_, err = service.UploadFile(ctx, "assets", key, writeLocalFile(t, "video.mp4", []byte("other")), "video/mp4")
+	require.NoError(t, err)
+	overwritten, err := service.ObjectInfo(ctx, "assets", key)
+	require.NoError(t, err)
+	require.Empty(t, overwritten.Metadata)
+	require.NotEqual(t, info.ETag, overwritten.ETag)
+
+	_, err = service.ObjectInfo(ctx, "assets", "users/u1/missing.mp4")
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestLocalS3_ListsPagesAndReplacesMetadata(t *testing.T) {
+	ctx := context.Background()
+	_, service := newTestLocalS3(t)
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		_, err := service.UploadFileWithMetadata(ctx, "assets", "users/u1/uploads/product_images/"+name,
+			writeLocalFile(t, name, []byte(name)), "image/png", map[string]string{"source": "upload"})
+		require.NoError(t, err)
+	}
+	_, err := service.UploadFile(ctx, "assets", "users/u2/uploads/product_images/d.png", writeLocalFile(t, "d.png", []byte("d")), "image/png")
+	require.NoError(t, err)
+
+	page, err := service.ListObjectsPage(ctx, "assets", "users/u1/uploads/", "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Objects, 2)
+	require.Equal(t, "users/u1/uploads/product_images/a.png", page.Objects[0].Key)
+	require.Equal(t, int64(5), page.Objects[0].Size)
+	require.NotEmpty(t, page.NextToken)
+
+	page, err = service.ListObjectsPage(ctx, "assets", "users/u1/uploads/", page.NextToken, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Objects, 1)
+	require.Equal(t, "users/u1/uploads/product_images/c.png", page.Objects[0].Key)
+	require.Empty(t, page.NextToken)
+
+	key := "users/u1/uploads/product_images/a.png"
+	require.NoError(t, service.ReplaceObjectMetadata(ctx, "assets", key, "image/png", map[string]string{"source": "upload", "dimensions": "640x480"}))
+	info, err := service.ObjectInfo(ctx, "assets", key)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"source": "upload", "dimensions": "640x480"}, info.Metadata)
+	require.Equal(t, "image/png", info.ContentType)
+	require.Equal(t, int64(5), info.Size, "the content is kept")
+
+	require.Error(t, service.ReplaceObjectMetadata(ctx, "assets", "users/u1/uploads/missing.png", "image/png", nil))
+}
+
+func TestLocalS3_MultipartUploadAndDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	_, service := newTestLocalS3(t)
+
+	// Two and a bit parts at the minimum part size
+	video := bytes.Repeat([]byte("0123456789abcdef"), int(2*manager.MinUploadPartSize+1024)/16)
+	_, err := service.UploadFile(ctx, "assets", "users/u1/jobs/j1/final.mp4", writeLocalFile(t, "final.mp4", video), "video/mp4")
+	require.NoError(t, err)
+
+	dest := filepath.Join(t.TempDir(), "download.mp4")
+	require.NoError(t, service.DownloadFile(ctx, "assets", "users/u1/jobs/j1/final.mp4", dest))
+	downloaded, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(video, downloaded), "multipart upload reassembled the parts out of order")
+
+	_, err = service.UploadFile(ctx, "assets", "users/u1/jobs/j1/thumbnail.jpg", writeLocalFile(t, "thumb.jpg", []byte("jpg")), "image/jpeg")
+	require.NoError(t, err)
+	_, err = service.UploadFile(ctx, "assets", "users/u1/jobs/j2/thumbnail.jpg", writeLocalFile(t, "thumb.jpg", []byte("jpg")), "image/jpeg")
+	require.NoError(t, err)
+
+	deleted, err := service.DeletePrefix(ctx, "assets", "users/u1/jobs/j1/")
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+
+	sizes, err := service.ListObjectSizes(ctx, "assets", "users/u1/")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"users/u1/jobs/j2/thumbnail.jpg": 3}, sizes)
+}
+
+func TestLocalS3_RejectsKeysOutsideTheBucket(t *testing.T) {
+	local, _ := newTestLocalS3(t)
+
+	req, err := http.NewRequest(http.MethodPut, local.URL()+"/assets/a/../../escape.txt", bytes.NewReader([]byte("x")))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,609 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// LocalDynamoDB holds in-memory job, usage, idempotency, script, asset, job event, job lock,
+// preferences and brand guidelines tables for ENVIRONMENT=local, so the repositories run
+// unchanged without AWS. Data lives as long as the process.
+type LocalDynamoDB struct {
+	db *memoryDynamoDB
+}
+
+// NewLocalDynamoDB creates an empty set of in-memory tables
+func NewLocalDynamoDB() *LocalDynamoDB {
+	return &LocalDynamoDB{db: newMemoryDynamoDB()}
+}
+
+// JobRepository returns a job repository backed by an in-memory table with the job table's
+// UserJobsIndex, StatusJobsIndex and ParentJobsIndex
+func (l *LocalDynamoDB) JobRepository(tableName string, logger *zap.Logger) *DynamoDBRepository {
+	l.db.createTable(tableName, keySchema{hash: "job_id"}, map[string]keySchema{
+		userJobsIndex:   {hash: "user_id", rang: "created_at"},
+		statusJobsIndex: {hash: "status", rang: "created_at"},
+		parentJobsIndex: {hash: "parent_job_id", rang: "created_at"},
+	})
+	return &DynamoDBRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// UsageRepository returns a usage repository backed by an in-memory table
+func (l *LocalDynamoDB) UsageRepository(tableName string, logger *zap.Logger) *DynamoDBUsageRepository {
+	l.db.createTable(tableName, keySchema{hash: "user_id", rang: "period"}, nil)
+	return &DynamoDBUsageRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// IdempotencyRepository returns an idempotency key repository backed by an in-memory table
+func (l *LocalDynamoDB) IdempotencyRepository(tableName string, logger *zap.Logger) *DynamoDBIdempotencyRepository {
+	l.db.createTable(tableName, keySchema{hash: "idempotency_key"}, nil)
+	return &DynamoDBIdempotencyRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// ScriptRepository returns a script repository backed by an in-memory table with UserScriptsIndex
+func (l *LocalDynamoDB) ScriptRepository(tableName string, logger *zap.Logger) *DynamoDBScriptRepository {
+	l.db.createTable(tableName, keySchema{hash: "script_id"}, map[string]keySchema{
+		userScriptsIndex: {hash: "user_id", rang: "created_at"},
+	})
+	return &DynamoDBScriptRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// AssetRepository returns an asset repository backed by an in-memory table
+func (l *LocalDynamoDB) AssetRepository(tableName string, logger *zap.Logger) *DynamoDBAssetRepository {
+	l.db.createTable(tableName, keySchema{hash: "asset_key"}, nil)
+	return &DynamoDBAssetRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// JobEventRepository returns a job event repository backed by an in-memory table
+func (l *LocalDynamoDB) JobEventRepository(tableName string, logger *zap.Logger) *DynamoDBJobEventRepository {
+	l.db.createTable(tableName, keySchema{hash: "job_id", rang: "sequence"}, nil)
+	return &DynamoDBJobEventRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// JobLockRepository returns a job lock repository backed by an in-memory table
+func (l *LocalDynamoDB) JobLockRepository(tableName string, logger *zap.Logger) *DynamoDBJobLockRepository {
+	l.db.createTable(tableName, keySchema{hash: "job_id"}, nil)
+	return &DynamoDBJobLockRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// UserBucketRepository returns a user bucket mapping repository backed by an in-memory table
+func (l *LocalDynamoDB) UserBucketRepository(tableName string, logger *zap.Logger) *DynamoDBUserBucketRepository {
+	l.db.createTable(tableName, keySchema{hash: "user_id"}, nil)
+	return &DynamoDBUserBucketRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// PreferencesRepository returns a user preferences repository backed by an in-memory table
+func (l *LocalDynamoDB) PreferencesRepository(tableName string, logger *zap.Logger) *DynamoDBPreferencesRepository {
+	l.db.createTable(tableName, keySchema{hash: "user_id"}, nil)
+	return &DynamoDBPreferencesRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// StageTimingsRepository returns a stage timings repository backed by an in-memory table
+func (l *LocalDynamoDB) StageTimingsRepository(tableName string, logger *zap.Logger) *DynamoDBStageTimingsRepository {
+	l.db.createTable(tableName, keySchema{hash: "timings_id"}, nil)
+	return &DynamoDBStageTimingsRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// BrandGuidelinesRepository returns a brand guidelines repository backed by an in-memory table
+// with UserBrandGuidelinesIndex
+func (l *LocalDynamoDB) BrandGuidelinesRepository(tableName string, logger *zap.Logger) *DynamoDBBrandGuidelinesRepository {
+	l.db.createTable(tableName, keySchema{hash: "guideline_id"}, map[string]keySchema{
+		userBrandGuidelinesIndex: {hash: "user_id", rang: "created_at"},
+	})
+	return &DynamoDBBrandGuidelinesRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// PredictionEventRepository returns a prediction event repository backed by an in-memory table
+func (l *LocalDynamoDB) PredictionEventRepository(tableName string, logger *zap.Logger) *DynamoDBPredictionEventRepository {
+	l.db.createTable(tableName, keySchema{hash: "prediction_id"}, nil)
+	return &DynamoDBPredictionEventRepository{client: l.db, tableName: tableName, logger: logger}
+}
+
+// keySchema names a table's or index's partition key and optional sort key
+type keySchema struct {
+	hash string
+	rang string
+}
+
+// memoryTable is one table: its items by primary key and its global secondary indexes
+type memoryTable struct {
+	key     keySchema
+	indexes map[string]keySchema
+	items   map[string]map[string]types.AttributeValue
+}
+
+// memoryDynamoDB implements dynamoDBAPI in memory, evaluating the expressions the repositories
+// use (see memory_expression.go). Items are copied in and out, as they would be over the wire.
+type memoryDynamoDB struct {
+	mu     sync.Mutex
+	tables map[string]*memoryTable
+}
+
+func newMemoryDynamoDB() *memoryDynamoDB {
+	return &memoryDynamoDB{tables: make(map[string]*memoryTable)}
+}
+
+func (m *memoryDynamoDB) createTable(name string, key keySchema, indexes map[string]keySchema) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.tables[name]; !exists {
+		m.tables[name] = &memoryTable{key: key, indexes: indexes, items: make(map[string]map[string]types.AttributeValue)}
+	}
+}
+
+func (m *memoryDynamoDB) table(name *string) (*memoryTable, error) {
+	table, ok := m.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: " + aws.ToString(name))}
+	}
+	return table, nil
+}
+
+// primaryKey encodes the item's key attributes, failing if any is missing
+func (t *memoryTable) primaryKey(item map[string]types.AttributeValue) (string, error) {
+	parts := []string{}
+	for _, name := range []string{t.key.hash, t.key.rang} {
+		if name == "" {
+			continue
+		}
+		value, ok := item[name]
+		if !ok {
+			return "", fmt.Errorf("ValidationException: missing key attribute %s", name)
+		}
+		parts = append(parts, keyString(value))
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+// keyOf returns the attributes of item that make up schema
+func keyOf(item map[string]types.AttributeValue, schemas ...keySchema) map[string]types.AttributeValue {
+	key := make(map[string]types.AttributeValue)
+	for _, schema := range schemas {
+		for _, name := range []string{schema.hash, schema.rang} {
+			if value, ok := item[name]; ok && name != "" {
+				key[name] = copyValue(value)
+			}
+		}
+	}
+	return key
+}
+
+func keyString(value types.AttributeValue) string {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + v.Value
+	case *types.AttributeValueMemberN:
+		return "N" + v.Value
+	case *types.AttributeValueMemberB:
+		return "B" + string(v.Value)
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// checkCondition evaluates a condition expression against the current item (nil if absent)
+func checkCondition(expr *string, names map[string]string, values map[string]types.AttributeValue, current map[string]types.AttributeValue, returnOld types.ReturnValuesOnConditionCheckFailure) error {
+	if expr == nil {
+		return nil
+	}
+	cond, err := parseCondition(*expr, names, values)
+	if err != nil {
+		return fmt.Errorf("ValidationException: %w", err)
+	}
+	item := current
+	if item == nil {
+		item = map[string]types.AttributeValue{}
+	}
+	if cond(item) {
+		return nil
+	}
+	ccf := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if returnOld == types.ReturnValuesOnConditionCheckFailureAllOld && current != nil {
+		ccf.Item = copyItem(current)
+	}
+	return ccf
+}
+
+func (m *memoryDynamoDB) PutItem(ctx context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := table.primaryKey(in.Item)
+	if err != nil {
+		return nil, err
+	}
+	old := table.items[key]
+	if err := checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, old, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+	table.items[key] = copyItem(in.Item)
+
+	out := &dynamodb.PutItemOutput{}
+	if in.ReturnValues == types.ReturnValueAllOld && old != nil {
+		out.Attributes = copyItem(old)
+	}
+	return out, nil
+}
+
+func (m *memoryDynamoDB) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := table.primaryKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	item, ok := table.items[key]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	projected, err := project(item, in.ProjectionExpression, in.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{Item: projected}, nil
+}
+
+func (m *memoryDynamoDB) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := table.primaryKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := table.items[key]
+	if err := checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, old, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	updated, changed, err := applyUpdate(in.Key, old, in.UpdateExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	table.items[key] = copyItem(updated)
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch in.ReturnValues {
+	case types.ReturnValueAllNew:
+		out.Attributes = copyItem(updated)
+	case types.ReturnValueAllOld:
+		if old != nil {
+			out.Attributes = copyItem(old)
+		}
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = selectAttributes(updated, changed)
+	case types.ReturnValueUpdatedOld:
+		if old != nil {
+			out.Attributes = selectAttributes(old, changed)
+		}
+	}
+	return out, nil
+}
+
+// applyUpdate returns the item an update expression makes of old, and the attributes it set.
+// Updating a missing item creates it from its key, as DynamoDB does.
+func applyUpdate(keyAttrs, old map[string]types.AttributeValue, expr *string, names map[string]string, values map[string]types.AttributeValue) (map[string]types.AttributeValue, []string, error) {
+	updated := copyItem(keyAttrs)
+	if old != nil {
+		updated = copyItem(old)
+	}
+	if expr == nil {
+		return updated, nil, nil
+	}
+	actions, err := parseUpdate(*expr, names, values)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ValidationException: %w", err)
+	}
+	var changed []string
+	before := copyItem(updated)
+	for _, action := range actions {
+		if err := action.apply(before, updated); err != nil {
+			return nil, nil, fmt.Errorf("ValidationException: %w", err)
+		}
+		changed = append(changed, action.attribute)
+	}
+	return updated, changed, nil
+}
+
+func (m *memoryDynamoDB) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := table.primaryKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := table.items[key]
+	if err := checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, old, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+	delete(table.items, key)
+
+	out := &dynamodb.DeleteItemOutput{}
+	if in.ReturnValues == types.ReturnValueAllOld && old != nil {
+		out.Attributes = copyItem(old)
+	}
+	return out, nil
+}
+
+func (m *memoryDynamoDB) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema := table.key
+	if in.IndexName != nil {
+		var ok bool
+		if schema, ok = table.indexes[*in.IndexName]; !ok {
+			return nil, fmt.Errorf("ValidationException: the table does not have the specified index: %s", *in.IndexName)
+		}
+	}
+	if in.KeyConditionExpression == nil {
+		return nil, fmt.Errorf("ValidationException: KeyConditionExpression is required")
+	}
+	keyCond, err := parseCondition(*in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, fmt.Errorf("ValidationException: %w", err)
+	}
+	filter := func(map[string]types.AttributeValue) bool { return true }
+	if in.FilterExpression != nil {
+		if filter, err = parseCondition(*in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+			return nil, fmt.Errorf("ValidationException: %w", err)
+		}
+	}
+
+	// Index entries exist only for items with every index key attribute
+	var matches []map[string]types.AttributeValue
+	for _, item := range table.items {
+		if _, ok := item[schema.hash]; !ok {
+			continue
+		}
+		if _, ok := item[schema.rang]; schema.rang != "" && !ok {
+			continue
+		}
+		if keyCond(item) {
+			matches = append(matches, item)
+		}
+	}
+	forward := in.ScanIndexForward == nil || *in.ScanIndexForward
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if schema.rang != "" {
+			if cmp := compareValues(a[schema.rang], b[schema.rang]); cmp != 0 && cmp != incomparable {
+				return (cmp < 0) == forward
+			}
+		}
+		ka, _ := table.primaryKey(a)
+		kb, _ := table.primaryKey(b)
+		return (ka < kb) == forward
+	})
+
+	if in.ExclusiveStartKey != nil {
+		start, err := table.primaryKey(in.ExclusiveStartKey)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range matches {
+			if key, _ := table.primaryKey(item); key == start {
+				matches = matches[i+1:]
+				break
+			}
+		}
+	}
+
+	// Limit caps the items read, before the filter, as in DynamoDB
+	out := &dynamodb.QueryOutput{}
+	evaluated := matches
+	if in.Limit != nil && int(*in.Limit) < len(matches) {
+		evaluated = matches[:*in.Limit]
+		out.LastEvaluatedKey = keyOf(evaluated[len(evaluated)-1], table.key, schema)
+	}
+	for _, item := range evaluated {
+		if !filter(item) {
+			continue
+		}
+		out.Count++
+		if in.Select == types.SelectCount {
+			continue
+		}
+		projected, err := project(item, in.ProjectionExpression, in.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, projected)
+	}
+	out.ScannedCount = int32(len(evaluated))
+	return out, nil
+}
+
+// TransactWriteItems checks every item's condition before writing any of them. If one fails,
+// nothing is written and the TransactionCanceledException gives each item's reason in order.
+func (m *memoryDynamoDB) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type write struct {
+		table *memoryTable
+		key   string
+		item  map[string]types.AttributeValue // nil deletes the item
+		skip  bool                            // Condition checks write nothing
+	}
+	writes := make([]write, len(in.TransactItems))
+	reasons := make([]types.CancellationReason, len(in.TransactItems))
+	canceled := false
+	for i, ti := range in.TransactItems {
+		var (
+			tableName *string
+			keyAttrs  map[string]types.AttributeValue
+			cond      *string
+			names     map[string]string
+			values    map[string]types.AttributeValue
+			returnOld types.ReturnValuesOnConditionCheckFailure
+		)
+		switch {
+		case ti.Put != nil:
+			tableName, keyAttrs, cond, names, values, returnOld = ti.Put.TableName, ti.Put.Item, ti.Put.ConditionExpression, ti.Put.ExpressionAttributeNames, ti.Put.ExpressionAttributeValues, ti.Put.ReturnValuesOnConditionCheckFailure
+		case ti.Update != nil:
+			tableName, keyAttrs, cond, names, values, returnOld = ti.Update.TableName, ti.Update.Key, ti.Update.ConditionExpression, ti.Update.ExpressionAttributeNames, ti.Update.ExpressionAttributeValues, ti.Update.ReturnValuesOnConditionCheckFailure
+		case ti.Delete != nil:
+			tableName, keyAttrs, cond, names, values, returnOld = ti.Delete.TableName, ti.Delete.Key, ti.Delete.ConditionExpression, ti.Delete.ExpressionAttributeNames, ti.Delete.ExpressionAttributeValues, ti.Delete.ReturnValuesOnConditionCheckFailure
+		case ti.ConditionCheck != nil:
+			tableName, keyAttrs, cond, names, values, returnOld = ti.ConditionCheck.TableName, ti.ConditionCheck.Key, ti.ConditionCheck.ConditionExpression, ti.ConditionCheck.ExpressionAttributeNames, ti.ConditionCheck.ExpressionAttributeValues, ti.ConditionCheck.ReturnValuesOnConditionCheckFailure
+		default:
+			return nil, fmt.Errorf("ValidationException: transaction item %d has no operation", i)
+		}
+
+		table, err := m.table(tableName)
+		if err != nil {
+			return nil, err
+		}
+		key, err := table.primaryKey(keyAttrs)
+		if err != nil {
+			return nil, err
+		}
+		old := table.items[key]
+		reasons[i].Code = aws.String("None")
+		if err := checkCondition(cond, names, values, old, returnOld); err != nil {
+			var ccf *types.ConditionalCheckFailedException
+			if !errors.As(err, &ccf) {
+				return nil, err
+			}
+			reasons[i] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Message: ccf.Message, Item: ccf.Item}
+			canceled = true
+			continue
+		}
+
+		writes[i] = write{table: table, key: key}
+		switch {
+		case ti.Put != nil:
+			writes[i].item = copyItem(ti.Put.Item)
+		case ti.Update != nil:
+			if writes[i].item, _, err = applyUpdate(keyAttrs, old, ti.Update.UpdateExpression, names, values); err != nil {
+				return nil, err
+			}
+		case ti.ConditionCheck != nil:
+			writes[i].skip = true
+		}
+	}
+	if canceled {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, w := range writes {
+		switch {
+		case w.skip:
+		case w.item == nil:
+			delete(w.table.items, w.key)
+		default:
+			w.table.items[w.key] = w.item
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (m *memoryDynamoDB) DescribeTable(ctx context.Context, in *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName:   in.TableName,
+		TableStatus: types.TableStatusActive,
+		ItemCount:   aws.Int64(int64(len(table.items))),
+	}}, nil
+}
+
+// project copies item, keeping only the attributes a projection expression names
+func project(item map[string]types.AttributeValue, expr *string, names map[string]string) (map[string]types.AttributeValue, error) {
+	if expr == nil {
+		return copyItem(item), nil
+	}
+	attributes, err := parseProjection(*expr, names)
+	if err != nil {
+		return nil, fmt.Errorf("ValidationException: %w", err)
+	}
+	return selectAttributes(item, attributes), nil
+}
+
+func selectAttributes(item map[string]types.AttributeValue, attributes []string) map[string]types.AttributeValue {
+	selected := make(map[string]types.AttributeValue)
+	for _, name := range attributes {
+		if value, ok := item[name]; ok {
+			selected[name] = copyValue(value)
+		}
+	}
+	return selected
+}
+
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	copied := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = copyValue(value)
+	}
+	return copied
+}
+
+func copyValue(value types.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), v.Value...)}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		copied := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			copied[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: copied}
+	case *types.AttributeValueMemberL:
+		copied := make([]types.AttributeValue, len(v.Value))
+		for i, element := range v.Value {
+			copied[i] = copyValue(element)
+		}
+		return &types.AttributeValueMemberL{Value: copied}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	}
+	return value
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLocalDynamoDB_JobLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	require.NoError(t, repo.HealthCheck(ctx))
+
+	job := &domain.Job{JobID: "job-1", UserID: "user-1", Status: domain.StatusPending, CreatedAt: 100}
+	require.NoError(t, repo.CreateJob(ctx, job))
+
+	require.NoError(t, repo.UpdateJobStageWithMetadata(ctx, "job-1", domain.StageScriptGenerating, map[string]interface{}{"scenes": 3}))
+	stored, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Equal(t, domain.StageScriptGenerating, stored.Stage)
+	require.Equal(t, int64(2), stored.Version)
+
+	// A stale copy conflicts; the current one writes
+	stale := *stored
+	stale.Version = 1
+	require.ErrorIs(t, repo.UpdateJob(ctx, &stale), ErrVersionConflict)
+	stored.Status = domain.StatusProcessing
+	require.NoError(t, repo.UpdateJob(ctx, stored))
+
+	version, err := repo.AppendJobProvenance(ctx, "job-1", domain.ProvenanceEntry{Step: "scene_1", PredictionID: "p-1"})
+	require.NoError(t, err)
+	version, err = repo.AppendJobProvenance(ctx, "job-1", domain.ProvenanceEntry{Step: "scene_2", PredictionID: "p-2"})
+	require.NoError(t, err)
+
+	require.ErrorIs(t, repo.CancelIdleJob(ctx, "job-1"), ErrJobNotCancelable)
+	require.NoError(t, repo.MarkJobComplete(ctx, "job-1", "videos/job-1.mp4"))
+
+	stored, err = repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusCompleted, stored.Status)
+	require.Equal(t, "videos/job-1.mp4", stored.VideoKey)
+	require.Len(t, stored.Provenance, 2)
+	require.Equal(t, "p-2", stored.Provenance[1].PredictionID)
+	require.Equal(t, version+1, stored.Version)
+
+	require.NoError(t, repo.DeleteJob(ctx, "job-1"))
+	_, err = repo.GetJob(ctx, "job-1")
+	require.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestLocalDynamoDB_QueriesPageThroughIndexes(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	for i, status := range []string{domain.StatusCompleted, domain.StatusFailed, domain.StatusCompleted, domain.StatusQueued, domain.StatusCompleted} {
+		require.NoError(t, repo.CreateJob(ctx, &domain.Job{
+			JobID:     string(rune('a' + i)),
+			UserID:    "user-1",
+			Status:    status,
+			CreatedAt: int64(100 + i),
+			UpdatedAt: int64(100 + i),
+		}))
+	}
+	require.NoError(t, repo.CreateJob(ctx, &domain.Job{JobID: "other", UserID: "user-2", Status: domain.StatusCompleted, CreatedAt: 200}))
+
+	// Newest first, two per page
+	var seen []string
+	query := JobsQuery{Limit: 2, Status: domain.StatusCompleted}
+	for {
+		page, err := repo.GetJobsByUser(ctx, "user-1", query)
+		require.NoError(t, err)
+		for _, job := range page.Jobs {
+			seen = append(seen, job.JobID)
+		}
+		if page.LastEvaluatedKey == nil {
+			break
+		}
+		query.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+	require.Equal(t, []string{"e", "c", "a"}, seen)
+
+	page, err := repo.GetJobsByUser(ctx, "user-1", JobsQuery{Limit: 10, CreatedAfter: 101, CreatedBefore: 103})
+	require.NoError(t, err)
+	require.Len(t, page.Jobs, 3)
+
+	page, err = repo.ListJobsByStatus(ctx, StatusJobsQuery{Limit: 10, Status: domain.StatusCompleted})
+	require.NoError(t, err)
+	require.Len(t, page.Jobs, 4)
+
+	queued, err := repo.ListQueuedJobs(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	require.NoError(t, repo.ClaimQueuedJob(ctx, "d"))
+	require.ErrorIs(t, repo.ClaimQueuedJob(ctx, "d"), ErrJobNotQueued)
+
+	active, err := repo.CountActiveJobs(ctx, "user-1", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, active)
+}
+
+func TestLocalDynamoDB_ListVariantJobs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().JobRepository("jobs", zap.NewNop())
+	require.NoError(t, repo.CreateJob(ctx, &domain.Job{
+		JobID:         "parent",
+		UserID:        "user-1",
+		Status:        domain.StatusProcessing,
+		Variants:      3,
+		VariantJobIDs: []string{"v1", "v2", "v3"},
+		CreatedAt:     100,
+		UpdatedAt:     100,
+	}))
+	for _, index := range []int{3, 1, 2} {
+		require.NoError(t, repo.CreateJob(ctx, &domain.Job{
+			JobID:        fmt.Sprintf("v%d", index),
+			UserID:       "user-1",
+			Status:       domain.StatusProcessing,
+			ParentJobID:  "parent",
+			VariantIndex: index,
+			CreatedAt:    100,
+			UpdatedAt:    100,
+		}))
+	}
+	require.NoError(t, repo.CreateJob(ctx, &domain.Job{JobID: "other", UserID: "user-1", Status: domain.StatusCompleted, ParentJobID: "another", CreatedAt: 100}))
+
+	variants, err := repo.ListVariantJobs(ctx, "parent")
+	require.NoError(t, err)
+	var ids []string
+	for _, job := range variants {
+		ids = append(ids, job.JobID)
+	}
+	require.Equal(t, []string{"v1", "v2", "v3"}, ids)
+
+	// The parent waits on its variants without taking a slot of its own
+	active, err := repo.CountActiveJobs(ctx, "user-1", 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, active)
+}
+
+func TestLocalDynamoDB_CreditsAndStorage(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().UsageRepository("usage", zap.NewNop())
+
+	usage, err := repo.ChargeCredits(ctx, "user-1", "free", domain.CreditCharge{JobID: "job-1", Credits: 400})
+	require.NoError(t, err)
+	require.Equal(t, 600, usage.CreditsRemaining)
+	require.Equal(t, 400, usage.CreditsUsed)
+
+	_, err = repo.ChargeCredits(ctx, "user-1", "free", domain.CreditCharge{JobID: "job-2", Credits: 700})
+	var insufficient *InsufficientCreditsError
+	require.ErrorAs(t, err, &insufficient)
+	require.Equal(t, 600, insufficient.Remaining)
+
+	require.NoError(t, repo.RefundCredits(ctx, "user-1", usage.Period, "job-1", 400))
+	require.ErrorIs(t, repo.RefundCredits(ctx, "user-1", usage.Period, "job-1", 400), ErrChargeNotRefundable)
+	require.ErrorIs(t, repo.RefundCredits(ctx, "user-1", usage.Period, "job-9", 1), ErrChargeNotRefundable)
+
+	usage, err = repo.GetUsage(ctx, "user-1", usage.Period)
+	require.NoError(t, err)
+	require.Equal(t, 1000, usage.CreditsRemaining)
+
+	require.NoError(t, repo.AddStorageBytes(ctx, "user-1", 2048))
+	require.NoError(t, repo.AddStorageBytes(ctx, "user-1", -1024))
+	stored, err := repo.GetStorageBytes(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, int64(1024), stored)
+}
+
+func TestLocalDynamoDB_IdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().IdempotencyRepository("idempotency", zap.NewNop())
+	record := &domain.IdempotencyRecord{Key: "user-1#k", UserID: "user-1", JobID: "job-1", ExpiresAt: 200}
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, record, 100)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+
+	retry := *record
+	retry.JobID = "job-2"
+	existing, err = repo.ReserveIdempotencyKey(ctx, &retry, 150)
+	require.ErrorIs(t, err, ErrIdempotencyKeyInUse)
+	require.Equal(t, "job-1", existing.JobID)
+
+	// An expired record no longer holds the key
+	existing, err = repo.ReserveIdempotencyKey(ctx, &retry, 250)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+
+	// Only the job holding the key may release it
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, "user-1#k", "job-1"))
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, "user-1#k", "job-2"))
+	existing, err = repo.ReserveIdempotencyKey(ctx, record, 250)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+}
+
+func TestMemoryDynamoDB_Expressions(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDynamoDB()
+	db.createTable("t", keySchema{hash: "id"}, nil)
+	key := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}}
+
+	out, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String("t"),
+		Key:              key,
+		UpdateExpression: aws.String("SET tags = list_append(if_not_exists(tags, :empty), :tag), n = :two REMOVE gone ADD c :two"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":empty": &types.AttributeValueMemberL{},
+			":tag":   &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}}},
+			":two":   &types.AttributeValueMemberN{Value: "2"},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	require.NoError(t, err)
+	require.Len(t, out.Attributes["tags"].(*types.AttributeValueMemberL).Value, 1)
+	require.Equal(t, "2", out.Attributes["c"].(*types.AttributeValueMemberN).Value)
+
+	// Operands read the item as it was before the update
+	out, err = db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("t"),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET n = c - :two, c = n + :two"),
+		ConditionExpression:       aws.String("NOT (n > :two) AND c BETWEEN :two AND :two"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":two": &types.AttributeValueMemberN{Value: "2"}},
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "0", out.Attributes["n"].(*types.AttributeValueMemberN).Value)
+	require.Equal(t, "4", out.Attributes["c"].(*types.AttributeValueMemberN).Value)
+	require.NotContains(t, out.Attributes, "tags")
+
+	_, err = db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                           aws.String("t"),
+		Item:                                key,
+		ConditionExpression:                 aws.String("attribute_not_exists(id)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var ccf *types.ConditionalCheckFailedException
+	require.True(t, errors.As(err, &ccf))
+	require.Contains(t, ccf.Item, "tags")
+
+	_, err = db.UpdateItem(ctx, &dynamodb.UpdateItemInput{TableName: aws.String("t"), Key: key, UpdateExpression: aws.String("SET n = :missing")})
+	require.ErrorContains(t, err, "undefined attribute value :missing")
+	_, err = db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("missing"), Key: key})
+	var notFound *types.ResourceNotFoundException
+	require.True(t, errors.As(err, &notFound))
+}
+
+func TestMemoryDynamoDB_TransactWriteItemsIsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDynamoDB()
+	db.createTable("t", keySchema{hash: "id"}, nil)
+	key := func(id string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}}
+	}
+	_, err := db.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("t"), Item: key("1")})
+	require.NoError(t, err)
+
+	put := func(id string) types.TransactWriteItem {
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String("t"),
+			Item:                key(id),
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		}}
+	}
+
+	// The second put fails its condition, so the first one isn't written either
+	_, err = db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{put("2"), put("1")},
+	})
+	var canceled *types.TransactionCanceledException
+	require.True(t, errors.As(err, &canceled))
+	require.Len(t, canceled.CancellationReasons, 2)
+	require.Equal(t, "None", aws.ToString(canceled.CancellationReasons[0].Code))
+	require.Equal(t, "ConditionalCheckFailed", aws.ToString(canceled.CancellationReasons[1].Code))
+
+	out, err := db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("t"), Key: key("2")})
+	require.NoError(t, err)
+	require.Nil(t, out.Item)
+
+	_, err = db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{put("2"), put("3")},
+	})
+	require.NoError(t, err)
+	out, err = db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("t"), Key: key("3")})
+	require.NoError(t, err)
+	require.NotNil(t, out.Item)
+}
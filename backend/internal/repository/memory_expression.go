@@ -0,0 +1,807 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// This file evaluates the subset of DynamoDB expressions the repositories write, for the
+// in-memory tables behind ENVIRONMENT=local: conditions and filters (comparisons, IN, BETWEEN,
+// AND/OR/NOT, attribute_exists, attribute_not_exists, begins_with, contains), updates (SET
+// with +, -, if_not_exists and list_append; ADD; REMOVE) and projections.
+
+// exprToken is one lexical token of an expression
+type exprToken struct {
+	kind  byte // 'i' identifier, '#' name placeholder, ':' value placeholder, 'n' number, else punctuation
+	text  string
+	start int
+}
+
+func tokenizeExpression(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || c == '_' || unicode.IsLetter(c):
+			start := i
+			i++
+			for i < len(expr) && (expr[i] == '_' || unicode.IsLetter(rune(expr[i])) || unicode.IsDigit(rune(expr[i]))) {
+				i++
+			}
+			kind := byte('i')
+			if c == '#' || c == ':' {
+				kind = byte(c)
+				if i == start+1 {
+					return nil, fmt.Errorf("empty placeholder at %d in %q", start, expr)
+				}
+			}
+			tokens = append(tokens, exprToken{kind: kind, text: expr[start:i], start: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(expr) && unicode.IsDigit(rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: 'n', text: expr[start:i], start: start})
+		case strings.HasPrefix(expr[i:], "<>") || strings.HasPrefix(expr[i:], "<=") || strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, exprToken{kind: 'p', text: expr[i : i+2], start: i})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			tokens = append(tokens, exprToken{kind: 'p', text: string(c), start: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d in %q", c, i, expr)
+		}
+	}
+	return tokens, nil
+}
+
+// exprParser walks the tokens of one expression, resolving placeholders as it goes
+type exprParser struct {
+	expr   string
+	tokens []exprToken
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newExprParser(expr string, names map[string]string, values map[string]types.AttributeValue) (*exprParser, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &exprParser{expr: expr, tokens: tokens, names: names, values: values}, nil
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.done() {
+		return exprToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+// peekKeyword reports whether the next token is the (case-insensitive) keyword
+func (p *exprParser) peekKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == 'i' && strings.EqualFold(t.text, keyword)
+}
+
+func (p *exprParser) peekPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == 'p' && t.text == punct
+}
+
+func (p *exprParser) expectPunct(punct string) error {
+	if !p.peekPunct(punct) {
+		return p.errorf("expected %q", punct)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	at := len(p.expr)
+	if !p.done() {
+		at = p.peek().start
+	}
+	return fmt.Errorf("invalid expression %q at %d: %s", p.expr, at, fmt.Sprintf(format, args...))
+}
+
+// pathElement is one step of a document path: a map key, or a list index when index >= 0
+type pathElement struct {
+	name  string
+	index int
+}
+
+// parsePath reads a document path such as charges.#job.refunded or scenes[0]
+func (p *exprParser) parsePath() ([]pathElement, error) {
+	var path []pathElement
+	for {
+		t := p.peek()
+		switch t.kind {
+		case 'i':
+			path = append(path, pathElement{name: t.text, index: -1})
+		case '#':
+			name, ok := p.names[t.text]
+			if !ok {
+				return nil, p.errorf("undefined attribute name %s", t.text)
+			}
+			path = append(path, pathElement{name: name, index: -1})
+		default:
+			return nil, p.errorf("expected an attribute path")
+		}
+		p.pos++
+
+		for p.peekPunct("[") {
+			p.pos++
+			t := p.peek()
+			if t.kind != 'n' {
+				return nil, p.errorf("expected a list index")
+			}
+			index, _ := strconv.Atoi(t.text)
+			p.pos++
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, pathElement{index: index})
+		}
+		if !p.peekPunct(".") {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+// operand is a value read from the item or the expression when evaluated
+type operand func(item map[string]types.AttributeValue) (types.AttributeValue, bool)
+
+// parseOperand reads a path or a value placeholder
+func (p *exprParser) parseOperand() (operand, error) {
+	if t := p.peek(); t.kind == ':' {
+		value, ok := p.values[t.text]
+		if !ok {
+			return nil, p.errorf("undefined attribute value %s", t.text)
+		}
+		p.pos++
+		return func(map[string]types.AttributeValue) (types.AttributeValue, bool) { return value, true }, nil
+	}
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+		return getPath(item, path)
+	}, nil
+}
+
+// condition is a parsed condition, filter or key condition expression
+type condition func(item map[string]types.AttributeValue) bool
+
+// parseCondition parses a whole condition expression
+func parseCondition(expr string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	p, err := newExprParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return cond, nil
+}
+
+func (p *exprParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]types.AttributeValue) bool { return l(item) || right(item) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]types.AttributeValue) bool { return l(item) && right(item) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (condition, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) bool { return !inner(item) }, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *exprParser) parsePredicate() (condition, error) {
+	if p.peekPunct("(") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expectPunct(")")
+	}
+	if t := p.peek(); t.kind == 'i' && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		return p.parseFunction(strings.ToLower(t.text))
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.peekKeyword("BETWEEN"):
+		p.pos++
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN")
+		}
+		p.pos++
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) bool {
+			return compareOperands(item, left, ">=", low) && compareOperands(item, left, "<=", high)
+		}, nil
+	case p.peekKeyword("IN"):
+		p.pos++
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			candidate, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, candidate)
+			if !p.peekPunct(",") {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) bool {
+			for _, candidate := range candidates {
+				if compareOperands(item, left, "=", candidate) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	t := p.peek()
+	switch t.text {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		return nil, p.errorf("expected a comparison")
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) bool {
+		return compareOperands(item, left, t.text, right)
+	}, nil
+}
+
+func (p *exprParser) parseFunction(name string) (condition, error) {
+	p.pos += 2 // name and "("
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	var arg operand
+	switch name {
+	case "attribute_exists", "attribute_not_exists":
+	case "begins_with", "contains":
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+		if arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf("unsupported function %s", name)
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	return func(item map[string]types.AttributeValue) bool {
+		value, exists := getPath(item, path)
+		switch name {
+		case "attribute_exists":
+			return exists
+		case "attribute_not_exists":
+			return !exists
+		}
+		want, ok := arg(item)
+		if !exists || !ok {
+			return false
+		}
+		if name == "begins_with" {
+			v, vok := value.(*types.AttributeValueMemberS)
+			w, wok := want.(*types.AttributeValueMemberS)
+			return vok && wok && strings.HasPrefix(v.Value, w.Value)
+		}
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			w, ok := want.(*types.AttributeValueMemberS)
+			return ok && strings.Contains(v.Value, w.Value)
+		case *types.AttributeValueMemberSS:
+			w, ok := want.(*types.AttributeValueMemberS)
+			return ok && containsString(v.Value, w.Value)
+		case *types.AttributeValueMemberL:
+			for _, element := range v.Value {
+				if reflect.DeepEqual(element, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// compareOperands applies a comparison operator; a missing operand never matches
+func compareOperands(item map[string]types.AttributeValue, left operand, op string, right operand) bool {
+	l, lok := left(item)
+	r, rok := right(item)
+	if !lok || !rok {
+		return false
+	}
+	if op == "=" || op == "<>" {
+		equal := compareValues(l, r) == 0
+		return equal == (op == "=")
+	}
+	cmp := compareValues(l, r)
+	if cmp == incomparable {
+		return false
+	}
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// incomparable is compareValues' result for values of different or unordered types
+const incomparable = 2
+
+// compareValues orders numbers numerically and strings lexically; other values are only
+// equal (0) or incomparable
+func compareValues(a, b types.AttributeValue) int {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberN:
+		bv, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return incomparable
+		}
+		x, errA := strconv.ParseFloat(av.Value, 64)
+		y, errB := strconv.ParseFloat(bv.Value, 64)
+		if errA != nil || errB != nil {
+			return incomparable
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case *types.AttributeValueMemberS:
+		bv, ok := b.(*types.AttributeValueMemberS)
+		if !ok {
+			return incomparable
+		}
+		return strings.Compare(av.Value, bv.Value)
+	}
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+	return incomparable
+}
+
+// updateAction is one action of an update expression. Its operands are read from the item as
+// it was before the update and its result is written to the updated copy.
+type updateAction struct {
+	attribute string // Top-level attribute the action changes, for UPDATED_* return values
+	apply     func(before, updated map[string]types.AttributeValue) error
+}
+
+// parseUpdate parses an update expression into its actions, in order
+func parseUpdate(expr string, names map[string]string, values map[string]types.AttributeValue) ([]updateAction, error) {
+	p, err := newExprParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	var actions []updateAction
+	for !p.done() {
+		t := p.peek()
+		clause := strings.ToUpper(t.text)
+		if t.kind != 'i' || (clause != "SET" && clause != "ADD" && clause != "REMOVE") {
+			return nil, p.errorf("expected SET, ADD or REMOVE")
+		}
+		p.pos++
+		for {
+			action, err := p.parseUpdateAction(clause)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, action)
+			if !p.peekPunct(",") {
+				break
+			}
+			p.pos++
+		}
+	}
+	if len(actions) == 0 {
+		return nil, p.errorf("no update actions")
+	}
+	return actions, nil
+}
+
+func (p *exprParser) parseUpdateAction(clause string) (updateAction, error) {
+	path, err := p.parsePath()
+	if err != nil {
+		return updateAction{}, err
+	}
+	action := updateAction{attribute: path[0].name}
+
+	switch clause {
+	case "REMOVE":
+		action.apply = func(_, updated map[string]types.AttributeValue) error {
+			return removePath(updated, path)
+		}
+	case "ADD":
+		delta, err := p.parseOperand()
+		if err != nil {
+			return updateAction{}, err
+		}
+		action.apply = func(before, updated map[string]types.AttributeValue) error {
+			d, _ := delta(before)
+			current, exists := getPath(before, path)
+			if !exists {
+				return setPath(updated, path, d)
+			}
+			sum, err := addValues(current, d)
+			if err != nil {
+				return err
+			}
+			return setPath(updated, path, sum)
+		}
+	default:
+		if err := p.expectPunct("="); err != nil {
+			return updateAction{}, err
+		}
+		value, err := p.parseSetValue()
+		if err != nil {
+			return updateAction{}, err
+		}
+		action.apply = func(before, updated map[string]types.AttributeValue) error {
+			v, err := value(before)
+			if err != nil {
+				return err
+			}
+			return setPath(updated, path, copyValue(v))
+		}
+	}
+	return action, nil
+}
+
+// setValue computes the value of a SET action from the item before the update
+type setValue func(item map[string]types.AttributeValue) (types.AttributeValue, error)
+
+// parseSetValue reads operand [+|- operand]
+func (p *exprParser) parseSetValue() (setValue, error) {
+	left, err := p.parseSetTerm()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekPunct("+") && !p.peekPunct("-") {
+		return left, nil
+	}
+	op := p.peek().text
+	p.pos++
+	right, err := p.parseSetTerm()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+		l, err := left(item)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(item)
+		if err != nil {
+			return nil, err
+		}
+		if op == "-" {
+			n, ok := r.(*types.AttributeValueMemberN)
+			if !ok {
+				return nil, fmt.Errorf("invalid operand type for -: %T", r)
+			}
+			r = &types.AttributeValueMemberN{Value: negateNumber(n.Value)}
+		}
+		return addNumbers(l, r)
+	}, nil
+}
+
+func (p *exprParser) parseSetTerm() (setValue, error) {
+	t := p.peek()
+	if t.kind == 'i' && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		name := strings.ToLower(t.text)
+		p.pos += 2
+		switch name {
+		case "if_not_exists":
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+				if value, ok := getPath(item, path); ok {
+					return value, nil
+				}
+				return fallback(item)
+			}, nil
+		case "list_append":
+			first, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+				a, err := first(item)
+				if err != nil {
+					return nil, err
+				}
+				b, err := second(item)
+				if err != nil {
+					return nil, err
+				}
+				al, aok := a.(*types.AttributeValueMemberL)
+				bl, bok := b.(*types.AttributeValueMemberL)
+				if !aok || !bok {
+					return nil, fmt.Errorf("list_append needs two lists")
+				}
+				joined := append(append([]types.AttributeValue{}, al.Value...), bl.Value...)
+				return &types.AttributeValueMemberL{Value: joined}, nil
+			}, nil
+		default:
+			return nil, p.errorf("unsupported function %s", name)
+		}
+	}
+
+	value, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+		v, ok := value(item)
+		if !ok {
+			return nil, fmt.Errorf("the provided expression refers to an attribute that does not exist in the item")
+		}
+		return v, nil
+	}, nil
+}
+
+// parseProjection returns the top-level attributes a projection expression selects
+func parseProjection(expr string, names map[string]string) ([]string, error) {
+	p, err := newExprParser(expr, names, nil)
+	if err != nil {
+		return nil, err
+	}
+	var attributes []string
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, path[0].name)
+		if p.done() {
+			return attributes, nil
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getPath reads the value at path, reporting whether it exists
+func getPath(item map[string]types.AttributeValue, path []pathElement) (types.AttributeValue, bool) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for _, element := range path {
+		switch v := current.(type) {
+		case *types.AttributeValueMemberM:
+			if element.index >= 0 {
+				return nil, false
+			}
+			next, ok := v.Value[element.name]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case *types.AttributeValueMemberL:
+			if element.index < 0 || element.index >= len(v.Value) {
+				return nil, false
+			}
+			current = v.Value[element.index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// parentOf returns the map or list holding the last element of path
+func parentOf(item map[string]types.AttributeValue, path []pathElement) (types.AttributeValue, error) {
+	parent, ok := getPath(item, path[:len(path)-1])
+	if !ok {
+		return nil, fmt.Errorf("the document path provided in the update expression is invalid for update")
+	}
+	return parent, nil
+}
+
+func setPath(item map[string]types.AttributeValue, path []pathElement, value types.AttributeValue) error {
+	parent, err := parentOf(item, path)
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.index < 0 {
+			v.Value[last.name] = value
+			return nil
+		}
+	case *types.AttributeValueMemberL:
+		if last.index >= 0 {
+			if last.index < len(v.Value) {
+				v.Value[last.index] = value
+			} else {
+				v.Value = append(v.Value, value)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("the document path provided in the update expression is invalid for update")
+}
+
+func removePath(item map[string]types.AttributeValue, path []pathElement) error {
+	parent, err := parentOf(item, path)
+	if err != nil {
+		return nil // Removing a missing attribute is a no-op
+	}
+	last := path[len(path)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		delete(v.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.index >= 0 && last.index < len(v.Value) {
+			v.Value = append(v.Value[:last.index], v.Value[last.index+1:]...)
+		}
+	}
+	return nil
+}
+
+// addValues implements ADD: numbers are summed and string sets unioned
+func addValues(current, delta types.AttributeValue) (types.AttributeValue, error) {
+	if set, ok := current.(*types.AttributeValueMemberSS); ok {
+		add, ok := delta.(*types.AttributeValueMemberSS)
+		if !ok {
+			return nil, fmt.Errorf("invalid operand type for ADD: %T", delta)
+		}
+		union := append([]string{}, set.Value...)
+		for _, s := range add.Value {
+			if !containsString(union, s) {
+				union = append(union, s)
+			}
+		}
+		return &types.AttributeValueMemberSS{Value: union}, nil
+	}
+	return addNumbers(current, delta)
+}
+
+func addNumbers(a, b types.AttributeValue) (types.AttributeValue, error) {
+	an, aok := a.(*types.AttributeValueMemberN)
+	bn, bok := b.(*types.AttributeValueMemberN)
+	if !aok || !bok {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+	}
+	x, errX := strconv.ParseInt(an.Value, 10, 64)
+	y, errY := strconv.ParseInt(bn.Value, 10, 64)
+	if errX == nil && errY == nil {
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(x+y, 10)}, nil
+	}
+	fx, errX := strconv.ParseFloat(an.Value, 64)
+	fy, errY := strconv.ParseFloat(bn.Value, 64)
+	if errX != nil || errY != nil {
+		return nil, fmt.Errorf("invalid number %q or %q", an.Value, bn.Value)
+	}
+	return &types.AttributeValueMemberN{Value: strconv.FormatFloat(fx+fy, 'f', -1, 64)}, nil
+}
+
+func negateNumber(n string) string {
+	if strings.HasPrefix(n, "-") {
+		return n[1:]
+	}
+	return "-" + n
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
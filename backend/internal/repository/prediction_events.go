@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/adapters"
+	"go.uber.org/zap"
+)
+
+// predictionEventRetention is how long a webhook's event is kept. Waiters give up on a
+// prediction long before this; the rest is room for Replicate's redeliveries.
+const predictionEventRetention = 24 * time.Hour
+
+// DynamoDBPredictionEventRepository shares Replicate prediction webhooks between servers, one
+// item per prediction that expires on its own
+type DynamoDBPredictionEventRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewPredictionEventRepository creates a new prediction event repository
+func NewPredictionEventRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBPredictionEventRepository {
+	return &DynamoDBPredictionEventRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// SavePredictionEvent records a prediction's terminal event, reporting false if it was already
+// recorded, as it is when Replicate redelivers a webhook
+func (r *DynamoDBPredictionEventRepository) SavePredictionEvent(ctx context.Context, event adapters.PredictionEvent) (bool, error) {
+	now := time.Now()
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]types.AttributeValue{
+			"prediction_id": &types.AttributeValueMemberS{Value: event.ID},
+			"status":        &types.AttributeValueMemberS{Value: string(event.Status)},
+			"error":         &types.AttributeValueMemberS{Value: event.Error},
+			"received_at":   &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			"ttl":           &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(predictionEventRetention).Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(prediction_id)"),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to save prediction event",
+			zap.String("prediction_id", event.ID),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to save prediction event: %w", err)
+	}
+	return true, nil
+}
+
+// GetPredictionEvent returns the prediction's recorded event, or nil if its webhook hasn't
+// arrived yet
+func (r *DynamoDBPredictionEventRepository) GetPredictionEvent(ctx context.Context, predictionID string) (*adapters.PredictionEvent, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"prediction_id": &types.AttributeValueMemberS{Value: predictionID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prediction event: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	event := &adapters.PredictionEvent{ID: predictionID}
+	if status, ok := result.Item["status"].(*types.AttributeValueMemberS); ok {
+		event.Status = adapters.PredictionStatus(status.Value)
+	}
+	if providerErr, ok := result.Item["error"].(*types.AttributeValueMemberS); ok {
+		event.Error = providerErr.Value
+	}
+	return event, nil
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPredictionEvents_SaveOnceAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().PredictionEventRepository("prediction-events", zap.NewNop())
+
+	event, err := repo.GetPredictionEvent(ctx, "pred-1")
+	require.NoError(t, err)
+	require.Nil(t, event)
+
+	failed := adapters.PredictionEvent{ID: "pred-1", Status: adapters.PredictionFailed, Error: "nsfw"}
+	fresh, err := repo.SavePredictionEvent(ctx, failed)
+	require.NoError(t, err)
+	require.True(t, fresh)
+
+	// A redelivery doesn't replace the first event
+	fresh, err = repo.SavePredictionEvent(ctx, adapters.PredictionEvent{ID: "pred-1", Status: adapters.PredictionSucceeded})
+	require.NoError(t, err)
+	require.False(t, fresh)
+
+	event, err = repo.GetPredictionEvent(ctx, "pred-1")
+	require.NoError(t, err)
+	require.Equal(t, &failed, event)
+}
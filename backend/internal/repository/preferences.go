@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// DynamoDBPreferencesRepository stores each user's default generation settings, one item per user
+type DynamoDBPreferencesRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewPreferencesRepository creates a new user preferences repository
+func NewPreferencesRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBPreferencesRepository {
+	return &DynamoDBPreferencesRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// GetPreferences returns the user's preferences, or nil for a user who never saved any
+func (r *DynamoDBPreferencesRepository) GetPreferences(ctx context.Context, userID string) (*domain.Preferences, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get preferences",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var prefs domain.Preferences
+	if err := attributevalue.UnmarshalMap(result.Item, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// PutPreferences stores prefs as its user's preferences, replacing the whole document
+func (r *DynamoDBPreferencesRepository) PutPreferences(ctx context.Context, prefs *domain.Preferences) error {
+	item, err := attributevalue.MarshalMap(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal preferences: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		r.logger.Error("Failed to put preferences",
+			zap.String("user_id", prefs.UserID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to put preferences: %w", err)
+	}
+	return nil
+}
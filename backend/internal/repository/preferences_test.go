@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPreferencesRepository_PutAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().PreferencesRepository("preferences", zap.NewNop())
+
+	prefs, err := repo.GetPreferences(ctx, "user-1")
+	require.NoError(t, err)
+	require.Nil(t, prefs, "a user who never saved any has none")
+
+	require.NoError(t, repo.PutPreferences(ctx, &domain.Preferences{UserID: "user-1", AspectRatio: "9:16", Style: "minimal", UpdatedAt: 100}))
+	require.NoError(t, repo.PutPreferences(ctx, &domain.Preferences{UserID: "user-2", Model: "kling", UpdatedAt: 100}))
+
+	prefs, err = repo.GetPreferences(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, &domain.Preferences{UserID: "user-1", AspectRatio: "9:16", Style: "minimal", UpdatedAt: 100}, prefs)
+
+	// A put replaces the whole document
+	require.NoError(t, repo.PutPreferences(ctx, &domain.Preferences{UserID: "user-1", Tone: "edgy", UpdatedAt: 200}))
+	prefs, err = repo.GetPreferences(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, &domain.Preferences{UserID: "user-1", Tone: "edgy", UpdatedAt: 200}, prefs)
+
+	prefs, err = repo.GetPreferences(ctx, "user-2")
+	require.NoError(t, err)
+	require.Equal(t, "kling", prefs.Model)
+	require.Empty(t, prefs.Tone)
+}
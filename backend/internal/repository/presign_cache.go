@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultPresignCacheSize bounds the number of cached presigned URLs
+const DefaultPresignCacheSize = 10000
+
+// presignReuseFraction is the share of a URL's validity during which it is reused
+// before a fresh one is signed, so clients never receive a nearly expired URL
+const presignReuseFraction = 0.8
+
+// PresignCacheOptions configures presigned download URL caching
+type PresignCacheOptions struct {
+	MaxEntries int  // least recently used entries are evicted beyond this; 0 uses DefaultPresignCacheSize
+	Disabled   bool // sign every request (useful in tests)
+}
+
+type presignCacheKey struct {
+	key    string
+	expiry time.Duration
+}
+
+type presignCacheEntry struct {
+	cacheKey   presignCacheKey
+	url        string
+	reuseUntil time.Time
+}
+
+// presignCache is an LRU cache of presigned URLs keyed by (object key, expiry).
+// A nil cache never hits, which is how caching is disabled.
+type presignCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[presignCacheKey]*list.Element
+	order      *list.List // front is most recently used
+	now        func() time.Time
+}
+
+func newPresignCache(opts PresignCacheOptions) *presignCache {
+	if opts.Disabled {
+		return nil
+	}
+	maxEntries := opts.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultPresignCacheSize
+	}
+	return &presignCache{
+		maxEntries: maxEntries,
+		entries:    make(map[presignCacheKey]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// get returns a cached URL for key that is still within its reuse window
+func (c *presignCache) get(key string, expiry time.Duration) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[presignCacheKey{key, expiry}]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*presignCacheEntry)
+	if !c.now().Before(entry.reuseUntil) {
+		c.order.Remove(elem)
+		delete(c.entries, entry.cacheKey)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.url, true
+}
+
+// put stores a URL signed at signedAt, evicting the least recently used entry when full
+func (c *presignCache) put(key string, expiry time.Duration, url string, signedAt time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cacheKey := presignCacheKey{key, expiry}
+	reuseUntil := signedAt.Add(time.Duration(float64(expiry) * presignReuseFraction))
+	if elem, ok := c.entries[cacheKey]; ok {
+		entry := elem.Value.(*presignCacheEntry)
+		entry.url = url
+		entry.reuseUntil = reuseUntil
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[cacheKey] = c.order.PushFront(&presignCacheEntry{
+		cacheKey:   cacheKey,
+		url:        url,
+		reuseUntil: reuseUntil,
+	})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*presignCacheEntry).cacheKey)
+	}
+}
+
+// len reports the number of cached URLs
+func (c *presignCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeClock lets tests move the cache's notion of now
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestPresignCache(maxEntries int) (*presignCache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	cache := newPresignCache(PresignCacheOptions{MaxEntries: maxEntries})
+	cache.now = clock.Now
+	return cache, clock
+}
+
+func TestPresignCache_ReusesUntil80PercentOfValidity(t *testing.T) {
+	cache, clock := newTestPresignCache(10)
+	cache.put("users/u/jobs/j/final/video.mp4", time.Hour, "https://signed/1", clock.now)
+
+	clock.now = clock.now.Add(47 * time.Minute)
+	url, ok := cache.get("users/u/jobs/j/final/video.mp4", time.Hour)
+	require.True(t, ok)
+	require.Equal(t, "https://signed/1", url)
+
+	// At 48 minutes (80% of an hour) the URL is no longer handed out
+	clock.now = clock.now.Add(time.Minute)
+	_, ok = cache.get("users/u/jobs/j/final/video.mp4", time.Hour)
+	require.False(t, ok)
+	require.Zero(t, cache.len(), "expired entries are dropped")
+}
+
+func TestPresignCache_KeyedByExpiry(t *testing.T) {
+	cache, clock := newTestPresignCache(10)
+	cache.put("thumb.jpg", time.Hour, "https://signed/hour", clock.now)
+
+	_, ok := cache.get("thumb.jpg", 15*time.Minute)
+	require.False(t, ok)
+
+	cache.put("thumb.jpg", 15*time.Minute, "https://signed/quarter", clock.now)
+	url, ok := cache.get("thumb.jpg", time.Hour)
+	require.True(t, ok)
+	require.Equal(t, "https://signed/hour", url)
+}
+
+func TestPresignCache_PutRefreshesEntry(t *testing.T) {
+	cache, clock := newTestPresignCache(10)
+	cache.put("clip.mp4", time.Hour, "https://signed/old", clock.now)
+
+	clock.now = clock.now.Add(50 * time.Minute)
+	cache.put("clip.mp4", time.Hour, "https://signed/new", clock.now)
+
+	clock.now = clock.now.Add(30 * time.Minute)
+	url, ok := cache.get("clip.mp4", time.Hour)
+	require.True(t, ok)
+	require.Equal(t, "https://signed/new", url)
+	require.Equal(t, 1, cache.len())
+}
+
+func TestPresignCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache, clock := newTestPresignCache(2)
+	cache.put("a", time.Hour, "url-a", clock.now)
+	cache.put("b", time.Hour, "url-b", clock.now)
+
+	// Touch "a" so "b" becomes the eviction candidate
+	_, ok := cache.get("a", time.Hour)
+	require.True(t, ok)
+
+	cache.put("c", time.Hour, "url-c", clock.now)
+	require.Equal(t, 2, cache.len())
+
+	_, ok = cache.get("b", time.Hour)
+	require.False(t, ok)
+	_, ok = cache.get("a", time.Hour)
+	require.True(t, ok)
+	_, ok = cache.get("c", time.Hour)
+	require.True(t, ok)
+}
+
+func TestPresignCache_Disabled(t *testing.T) {
+	cache := newPresignCache(PresignCacheOptions{Disabled: true})
+	cache.put("a", time.Hour, "url-a", time.Now())
+	_, ok := cache.get("a", time.Hour)
+	require.False(t, ok)
+}
+
+// newCountingS3Service builds a repository with a real presigner (static credentials, no network)
+// and counts how often it signs
+func newCountingS3Service(t testing.TB, cacheOpts PresignCacheOptions) (*S3AssetRepository, *atomic.Int64) {
+	t.Helper()
+	client := s3.New(s3.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+	})
+	repo := NewS3Service(client, "omnigen-assets", UploadOptions{}, cacheOpts, zap.NewNop())
+
+	var calls atomic.Int64
+	sign := repo.presignGet
+	repo.presignGet = func(ctx context.Context, bucket, key string, duration time.Duration) (string, error) {
+		calls.Add(1)
+		return sign(ctx, bucket, key, duration)
+	}
+	return repo, &calls
+}
+
+func TestGetPresignedURL_CachesSignatures(t *testing.T) {
+	repo, calls := newCountingS3Service(t, PresignCacheOptions{})
+	ctx := context.Background()
+
+	first, err := repo.GetPresignedURL(ctx, "users/u/jobs/j/final/video.mp4", time.Hour)
+	require.NoError(t, err)
+	require.Contains(t, first, "X-Amz-Signature=")
+
+	second, err := repo.GetPresignedURL(ctx, "users/u/jobs/j/final/video.mp4", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	require.Equal(t, int64(1), calls.Load())
+
+	uncached, uncachedCalls := newCountingS3Service(t, PresignCacheOptions{Disabled: true})
+	for i := 0; i < 3; i++ {
+		_, err := uncached.GetPresignedURL(ctx, "users/u/jobs/j/final/video.mp4", time.Hour)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(3), uncachedCalls.Load())
+}
+
+// benchmarkListJobsPresign simulates one ListJobs call: 50 jobs with four presigned assets each
+func benchmarkListJobsPresign(b *testing.B, cacheOpts PresignCacheOptions) {
+	repo, calls := newCountingS3Service(b, cacheOpts)
+	ctx := context.Background()
+
+	keys := make([]string, 0, 200)
+	for job := 0; job < 50; job++ {
+		for _, asset := range []string{"final/video.mp4", "final/video.webm", "audio/background-music.mp3", "thumbnails/job.jpg"} {
+			keys = append(keys, fmt.Sprintf("users/u/jobs/job-%02d/%s", job, asset))
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			if _, err := repo.GetPresignedURL(ctx, key, time.Hour); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(calls.Load())/float64(b.N), "presigns/op")
+}
+
+func BenchmarkListJobsPresign_Cached(b *testing.B) {
+	benchmarkListJobsPresign(b, PresignCacheOptions{})
+}
+
+func BenchmarkListJobsPresign_Uncached(b *testing.B) {
+	benchmarkListJobsPresign(b, PresignCacheOptions{Disabled: true})
+}
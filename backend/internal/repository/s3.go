@@ -2,50 +2,83 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"go.uber.org/zap"
 )
 
+// ErrObjectNotFound is returned when an S3 object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// objectDeleteAPI is the subset of the S3 client DeletePrefix and ListObjectSizes use
+type objectDeleteAPI interface {
+	s3.ListObjectsV2APIClient
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
 // S3Service handles S3 operations
 type S3AssetRepository struct {
-	client     *s3.Client
-	bucketName string
-	logger     *zap.Logger
+	client       *s3.Client
+	objects      objectDeleteAPI
+	multipart    multipartAPI
+	uploader     *manager.Uploader
+	presignCache *presignCache // nil when caching is disabled
+	presignGet   func(ctx context.Context, bucket, key string, duration time.Duration) (string, error)
+	bucketName   string
+	logger       *zap.Logger
 }
 
 // NewS3Service creates a new S3 service
 func NewS3Service(
 	client *s3.Client,
 	bucketName string,
+	uploadOpts UploadOptions,
+	cacheOpts PresignCacheOptions,
 	logger *zap.Logger,
 ) *S3AssetRepository {
-	return &S3AssetRepository{
-		client:     client,
-		bucketName: bucketName,
-		logger:     logger,
+	s := &S3AssetRepository{
+		client:       client,
+		objects:      client,
+		multipart:    client,
+		uploader:     newUploader(client, uploadOpts),
+		presignCache: newPresignCache(cacheOpts),
+		bucketName:   bucketName,
+		logger:       logger,
 	}
+	s.presignGet = s.signGetObject
+	return s
 }
 
-// GetPresignedURL generates a presigned URL for downloading a video
+// GetPresignedURL generates a presigned URL for downloading a video.
+// URLs are cached per (bucket, key, duration) and reused until 80% of their validity has elapsed.
 func (s *S3AssetRepository) GetPresignedURL(ctx context.Context, key string, duration time.Duration) (string, error) {
-	presignClient := s3.NewPresignClient(s.client)
+	return s.GetPresignedURLInBucket(ctx, "", key, duration)
+}
 
-	request, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(key),
-	}, func(opts *s3.PresignOptions) {
-		opts.Expires = duration
-	})
+// GetPresignedURLInBucket is GetPresignedURL for a key in bucket; "" is the service bucket
+func (s *S3AssetRepository) GetPresignedURLInBucket(ctx context.Context, bucket, key string, duration time.Duration) (string, error) {
+	if bucket == "" {
+		bucket = s.bucketName
+	}
+	cacheKey := bucket + "/" + key
+	if url, ok := s.presignCache.get(cacheKey, duration); ok {
+		return url, nil
+	}
+
+	signedAt := time.Now()
+	url, err := s.presignGet(ctx, bucket, key, duration)
 	if err != nil {
 		s.logger.Error("Failed to generate presigned URL",
-			zap.String("bucket", s.bucketName),
+			zap.String("bucket", bucket),
 			zap.String("key", key),
 			zap.Error(err),
 		)
@@ -57,6 +90,21 @@ func (s *S3AssetRepository) GetPresignedURL(ctx context.Context, key string, dur
 		zap.Duration("expiration", duration),
 	)
 
+	s.presignCache.put(cacheKey, duration, url, signedAt)
+	return url, nil
+}
+
+// signGetObject signs a GET request for key in bucket
+func (s *S3AssetRepository) signGetObject(ctx context.Context, bucket, key string, duration time.Duration) (string, error) {
+	request, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = duration
+	})
+	if err != nil {
+		return "", err
+	}
 	return request.URL, nil
 }
 
@@ -90,22 +138,17 @@ func (s *S3AssetRepository) GetPresignedPutURL(ctx context.Context, key string,
 	return request.URL, nil
 }
 
-// UploadFile uploads a file to S3
+// UploadFile uploads a file to S3, using a concurrent multipart upload for large files
 func (s *S3AssetRepository) UploadFile(ctx context.Context, bucket, key, filePath string, contentType string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
+	return s.UploadFileWithMetadata(ctx, bucket, key, filePath, contentType, nil)
+}
 
-	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(bucket),
-		Key:         aws.String(key),
-		Body:        file,
-		ContentType: aws.String(contentType),
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to upload file: %w", err)
+// UploadFileWithMetadata uploads a file like UploadFile, storing metadata as the object's user
+// metadata. S3 only makes the object visible once every byte is uploaded, so the metadata never
+// appears on a partial upload.
+func (s *S3AssetRepository) UploadFileWithMetadata(ctx context.Context, bucket, key, filePath, contentType string, metadata map[string]string) (string, error) {
+	if err := uploadFile(ctx, s.uploader, s.logger, bucket, key, filePath, contentType, metadata); err != nil {
+		return "", err
 	}
 
 	// Return S3 URL
@@ -138,6 +181,86 @@ func (s *S3AssetRepository) DownloadFile(ctx context.Context, bucket, key, destP
 	return nil
 }
 
+// OpenObject returns a streaming reader for an S3 object; the caller must close it
+func (s *S3AssetRepository) OpenObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return result.Body, nil
+}
+
+// ObjectSize returns the size of an S3 object in bytes
+func (s *S3AssetRepository) ObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	info, err := s.ObjectInfo(ctx, bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// ObjectInfo is what a HEAD request tells about an S3 object
+type ObjectInfo struct {
+	Size         int64
+	ETag         string // Changes whenever the object is rewritten
+	ContentType  string
+	LastModified time.Time
+	Metadata     map[string]string // User metadata, keys lowercased
+}
+
+// ObjectInfo returns an S3 object's size and user metadata (ErrObjectNotFound if it doesn't exist)
+func (s *S3AssetRepository) ObjectInfo(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to head object %s: %w", key, ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to head object: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Size:         aws.ToInt64(result.ContentLength),
+		ETag:         aws.ToString(result.ETag),
+		ContentType:  aws.ToString(result.ContentType),
+		LastModified: aws.ToTime(result.LastModified),
+		Metadata:     make(map[string]string, len(result.Metadata)),
+	}
+	for name, value := range result.Metadata {
+		info.Metadata[strings.ToLower(name)] = value
+	}
+	return info, nil
+}
+
+// ListObjectSizes returns the size in bytes of every object under a prefix, by key
+func (s *S3AssetRepository) ListObjectSizes(ctx context.Context, bucket, prefix string) (map[string]int64, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.objects, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	sizes := make(map[string]int64)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects for prefix %s: %w", prefix, err)
+		}
+		for _, object := range page.Contents {
+			if object.Key == nil {
+				continue
+			}
+			sizes[*object.Key] = aws.ToInt64(object.Size)
+		}
+	}
+	return sizes, nil
+}
+
 // DeleteFile deletes a file from S3
 func (s *S3AssetRepository) DeleteFile(ctx context.Context, bucket, key string) error {
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -160,21 +283,22 @@ func (s *S3AssetRepository) DeleteFile(ctx context.Context, bucket, key string)
 	return nil
 }
 
-// DeletePrefix deletes all objects under a given prefix (best-effort cleanup).
-func (s *S3AssetRepository) DeletePrefix(ctx context.Context, bucket, prefix string) error {
-	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucket),
-		Prefix: aws.String(prefix),
+// DeletePrefix deletes all objects under a given prefix (best-effort cleanup), a listing page of
+// up to 1000 keys at a time. It returns how many objects were deleted; objects S3 refused to
+// delete are reported in the error once every page has been tried.
+func (s *S3AssetRepository) DeletePrefix(ctx context.Context, bucket, prefix string) (int, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.objects, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(1000), // DeleteObjects accepts at most 1000 keys
 	})
 
+	deleted, failed := 0, 0
+	var firstFailure string
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to list objects for prefix %s: %w", prefix, err)
-		}
-
-		if len(page.Contents) == 0 {
-			continue
+			return deleted, fmt.Errorf("failed to list objects for prefix %s: %w", prefix, err)
 		}
 
 		identifiers := make([]types.ObjectIdentifier, 0, len(page.Contents))
@@ -189,7 +313,7 @@ func (s *S3AssetRepository) DeletePrefix(ctx context.Context, bucket, prefix str
 			continue
 		}
 
-		_, err = s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		result, err := s.objects.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(bucket),
 			Delete: &types.Delete{
 				Objects: identifiers,
@@ -197,15 +321,26 @@ func (s *S3AssetRepository) DeletePrefix(ctx context.Context, bucket, prefix str
 			},
 		})
 		if err != nil {
-			return fmt.Errorf("failed to delete objects for prefix %s: %w", prefix, err)
+			return deleted, fmt.Errorf("failed to delete objects for prefix %s: %w", prefix, err)
+		}
+		// Quiet mode only reports the objects that could not be deleted
+		deleted += len(identifiers) - len(result.Errors)
+		failed += len(result.Errors)
+		if firstFailure == "" && len(result.Errors) > 0 {
+			firstFailure = fmt.Sprintf("%s: %s", aws.ToString(result.Errors[0].Key), aws.ToString(result.Errors[0].Message))
 		}
 	}
 
 	s.logger.Info("Deleted S3 assets for prefix",
 		zap.String("bucket", bucket),
 		zap.String("prefix", prefix),
+		zap.Int("deleted", deleted),
+		zap.Int("failed", failed),
 	)
-	return nil
+	if failed > 0 {
+		return deleted, fmt.Errorf("failed to delete %d objects for prefix %s (first: %s)", failed, prefix, firstFailure)
+	}
+	return deleted, nil
 }
 
 // HealthCheck performs a lightweight health check on S3
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// ListedObject is an object as a bucket listing reports it
+type ListedObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectsPage is one page of a listing, in key order
+type ObjectsPage struct {
+	Objects []ListedObject
+
+	// NextToken continues the listing on the next page; empty when there are no more objects
+	NextToken string
+}
+
+// ListObjectsPage lists up to limit objects under prefix, starting after the page token names
+// ("" for the first page). A token S3 doesn't accept is reported as ErrInvalidCursor.
+func (s *S3AssetRepository) ListObjectsPage(ctx context.Context, bucket, prefix, token string, limit int32) (*ObjectsPage, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(limit),
+	}
+	if token != "" {
+		input.ContinuationToken = aws.String(token)
+	}
+
+	result, err := s.objects.ListObjectsV2(ctx, input)
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if token != "" && errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidArgument" {
+			return nil, ErrInvalidCursor
+		}
+		return nil, fmt.Errorf("failed to list objects for prefix %s: %w", prefix, err)
+	}
+
+	page := &ObjectsPage{Objects: make([]ListedObject, 0, len(result.Contents))}
+	for _, object := range result.Contents {
+		if object.Key == nil {
+			continue
+		}
+		page.Objects = append(page.Objects, ListedObject{
+			Key:          *object.Key,
+			Size:         aws.ToInt64(object.Size),
+			LastModified: aws.ToTime(object.LastModified),
+		})
+	}
+	if aws.ToBool(result.IsTruncated) {
+		page.NextToken = aws.ToString(result.NextContinuationToken)
+	}
+	return page, nil
+}
+
+// ReplaceObjectMetadata rewrites an object's user metadata by copying it onto itself. S3 can't
+// change metadata in place, so the copy also resets the object's last modified time; contentType
+// is kept as given.
+func (s *S3AssetRepository) ReplaceObjectMetadata(ctx context.Context, bucket, key, contentType string, metadata map[string]string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(bucket + "/" + url.PathEscape(key)),
+		ContentType:       aws.String(contentType),
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	if err != nil {
+		s.logger.Warn("Failed to replace object metadata",
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to replace metadata of %s: %w", key, err)
+	}
+	return nil
+}
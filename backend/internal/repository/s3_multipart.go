@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// ErrUploadNotFound is returned when a multipart upload does not exist, or was completed or aborted
+var ErrUploadNotFound = errors.New("multipart upload not found")
+
+// UploadPart is one part of a multipart upload
+type UploadPart struct {
+	PartNumber int32
+	ETag       string // As S3 returns it, quoted
+	Size       int64
+}
+
+// multipartAPI is the subset of the S3 client browser multipart uploads use
+type multipartAPI interface {
+	s3.ListPartsAPIClient
+	s3.ListMultipartUploadsAPIClient
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// CreateMultipartUpload starts a multipart upload to key in the service bucket, for a client
+// that uploads the parts itself through PresignUploadPart URLs. It returns the upload ID.
+func (s *S3AssetRepository) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	result, err := s.multipart.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		s.logger.Error("Failed to create multipart upload",
+			zap.String("bucket", s.bucketName),
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(result.UploadId), nil
+}
+
+// PresignUploadPart generates a presigned URL for uploading one part of a multipart upload
+func (s *S3AssetRepository) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, duration time.Duration) (string, error) {
+	request, err := s3.NewPresignClient(s.client).PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucketName),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = duration
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign part %d: %w", partNumber, err)
+	}
+	return request.URL, nil
+}
+
+// UploadedParts lists the parts S3 has received for a multipart upload, in part number order
+func (s *S3AssetRepository) UploadedParts(ctx context.Context, key, uploadID string) ([]UploadPart, error) {
+	paginator := s3.NewListPartsPaginator(s.multipart, &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	var parts []UploadPart
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, multipartError("list parts of", err)
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, UploadPart{
+				PartNumber: aws.ToInt32(part.PartNumber),
+				ETag:       aws.ToString(part.ETag),
+				Size:       aws.ToInt64(part.Size),
+			})
+		}
+	}
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the given parts, in order, into the upload's object
+func (s *S3AssetRepository) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []UploadPart) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	_, err := s.multipart.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return multipartError("complete", err)
+	}
+
+	s.logger.Info("Multipart upload completed",
+		zap.String("key", key),
+		zap.Int("parts", len(parts)),
+	)
+	return nil
+}
+
+// AbortMultipartUpload abandons a multipart upload, deleting the parts uploaded so far
+func (s *S3AssetRepository) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := s.multipart.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return multipartError("abort", err)
+	}
+	return nil
+}
+
+// AbortStaleMultipartUploads aborts the multipart uploads under prefix started before cutoff,
+// returning how many were aborted. Uploads that fail to abort are retried on the next sweep.
+func (s *S3AssetRepository) AbortStaleMultipartUploads(ctx context.Context, prefix string, cutoff time.Time) (int, error) {
+	paginator := s3.NewListMultipartUploadsPaginator(s.multipart, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(prefix),
+	})
+
+	aborted := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return aborted, fmt.Errorf("failed to list multipart uploads for prefix %s: %w", prefix, err)
+		}
+		for _, upload := range page.Uploads {
+			if upload.Initiated == nil || !upload.Initiated.Before(cutoff) {
+				continue
+			}
+			key, uploadID := aws.ToString(upload.Key), aws.ToString(upload.UploadId)
+			if err := s.AbortMultipartUpload(ctx, key, uploadID); err != nil && !errors.Is(err, ErrUploadNotFound) {
+				s.logger.Warn("Failed to abort stale multipart upload",
+					zap.String("key", key),
+					zap.String("upload_id", uploadID),
+					zap.Error(err),
+				)
+				continue
+			}
+			aborted++
+		}
+	}
+	return aborted, nil
+}
+
+// multipartError wraps a multipart upload failure, mapping unknown upload IDs to ErrUploadNotFound.
+// Only some operations model NoSuchUpload; the rest surface it as a generic API error code.
+func multipartError(action string, err error) error {
+	var noSuchUpload *types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) || strings.Contains(err.Error(), "NoSuchUpload") {
+		return ErrUploadNotFound
+	}
+	return fmt.Errorf("failed to %s multipart upload: %w", action, err)
+}
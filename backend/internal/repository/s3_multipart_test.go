@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func putPart(t *testing.T, url string, body []byte) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp.Header.Get("ETag")
+}
+
+func TestMultipartUpload_PresignedParts(t *testing.T) {
+	ctx := context.Background()
+	_, service := newTestLocalS3(t)
+	key := "users/u1/uploads/product_images/1_shoe.png"
+
+	uploadID, err := service.CreateMultipartUpload(ctx, key, "image/png")
+	require.NoError(t, err)
+
+	// The browser uploads parts in whatever order its connections finish
+	var etags []string
+	for i, body := range [][]byte{[]byte("part one "), []byte("part two")} {
+		url, err := service.PresignUploadPart(ctx, key, uploadID, int32(i+1), time.Hour)
+		require.NoError(t, err)
+		etags = append(etags, putPart(t, url, body))
+	}
+
+	parts, err := service.UploadedParts(ctx, key, uploadID)
+	require.NoError(t, err)
+	require.Equal(t, []UploadPart{
+		{PartNumber: 1, ETag: etags[0], Size: 9},
+		{PartNumber: 2, ETag: etags[1], Size: 8},
+	}, parts)
+
+	require.NoError(t, service.CompleteMultipartUpload(ctx, key, uploadID, parts))
+	object, err := service.OpenObject(ctx, "assets", key)
+	require.NoError(t, err)
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	require.NoError(t, err)
+	require.Equal(t, "part one part two", string(data))
+
+	_, err = service.UploadedParts(ctx, key, uploadID)
+	require.ErrorIs(t, err, ErrUploadNotFound, "a completed upload is gone")
+}
+
+func TestAbortStaleMultipartUploads(t *testing.T) {
+	ctx := context.Background()
+	_, service := newTestLocalS3(t)
+
+	mine, err := service.CreateMultipartUpload(ctx, "users/u1/uploads/product_images/1_a.png", "image/png")
+	require.NoError(t, err)
+	theirs, err := service.CreateMultipartUpload(ctx, "users/u2/uploads/product_images/1_b.png", "image/png")
+	require.NoError(t, err)
+
+	// Nothing started before the cutoff
+	aborted, err := service.AbortStaleMultipartUploads(ctx, "users/", time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, aborted)
+
+	aborted, err = service.AbortStaleMultipartUploads(ctx, "users/u1/", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, aborted)
+
+	_, err = service.UploadedParts(ctx, "users/u1/uploads/product_images/1_a.png", mine)
+	require.ErrorIs(t, err, ErrUploadNotFound)
+	_, err = service.UploadedParts(ctx, "users/u2/uploads/product_images/1_b.png", theirs)
+	require.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubObjectClient lists keys a page at a time and records DeleteObjects batches
+type stubObjectClient struct {
+	keys     []string
+	pageSize int
+	refuse   map[string]bool // Keys DeleteObjects reports as failed
+
+	listCalls int
+	batches   []int
+}
+
+func (c *stubObjectClient) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.listCalls++
+	start := 0
+	if in.ContinuationToken != nil {
+		fmt.Sscanf(*in.ContinuationToken, "%d", &start)
+	}
+	end := min(start+c.pageSize, len(c.keys))
+
+	out := &s3.ListObjectsV2Output{}
+	for _, key := range c.keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(key)))})
+	}
+	if end < len(c.keys) {
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(fmt.Sprint(end))
+	}
+	return out, nil
+}
+
+func (c *stubObjectClient) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	c.batches = append(c.batches, len(in.Delete.Objects))
+	out := &s3.DeleteObjectsOutput{}
+	for _, object := range in.Delete.Objects {
+		if c.refuse[aws.ToString(object.Key)] {
+			out.Errors = append(out.Errors, types.Error{Key: object.Key, Message: aws.String("Access Denied")})
+		}
+	}
+	return out, nil
+}
+
+func jobKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("users/u1/jobs/j1/clips/scene-%04d.mp4", i)
+	}
+	return keys
+}
+
+func TestDeletePrefixPaginates(t *testing.T) {
+	client := &stubObjectClient{keys: jobKeys(2500), pageSize: 1000}
+	repo := &S3AssetRepository{objects: client, logger: zap.NewNop()}
+
+	deleted, err := repo.DeletePrefix(context.Background(), "assets", "users/u1/jobs/j1/")
+	require.NoError(t, err)
+	require.Equal(t, 2500, deleted)
+	require.Equal(t, 3, client.listCalls)
+	require.Equal(t, []int{1000, 1000, 500}, client.batches)
+}
+
+func TestDeletePrefixReportsRefusedObjects(t *testing.T) {
+	keys := jobKeys(1200)
+	client := &stubObjectClient{
+		keys:     keys,
+		pageSize: 1000,
+		refuse:   map[string]bool{keys[3]: true, keys[1100]: true},
+	}
+	repo := &S3AssetRepository{objects: client, logger: zap.NewNop()}
+
+	// Every page is still attempted
+	deleted, err := repo.DeletePrefix(context.Background(), "assets", "users/u1/jobs/j1/")
+	require.ErrorContains(t, err, "failed to delete 2 objects")
+	require.ErrorContains(t, err, keys[3])
+	require.Equal(t, 1198, deleted)
+	require.Equal(t, []int{1000, 200}, client.batches)
+}
+
+func TestDeletePrefixEmpty(t *testing.T) {
+	client := &stubObjectClient{pageSize: 1000}
+	repo := &S3AssetRepository{objects: client, logger: zap.NewNop()}
+
+	deleted, err := repo.DeletePrefix(context.Background(), "assets", "users/u1/jobs/j1/")
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+	require.Empty(t, client.batches)
+}
+
+func TestListObjectSizesPaginates(t *testing.T) {
+	keys := jobKeys(2500)
+	client := &stubObjectClient{keys: keys, pageSize: 1000}
+	repo := &S3AssetRepository{objects: client, logger: zap.NewNop()}
+
+	sizes, err := repo.ListObjectSizes(context.Background(), "assets", "users/u1/jobs/j1/")
+	require.NoError(t, err)
+	require.Len(t, sizes, 2500)
+	require.Equal(t, int64(len(keys[42])), sizes[keys[42]])
+	require.Equal(t, 3, client.listCalls)
+	require.Empty(t, client.batches, "listing sizes deletes nothing")
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+// Upload defaults sized for Veo clips (50-150MB) and 60s final videos
+const (
+	DefaultUploadPartSize    int64 = 16 * 1024 * 1024
+	DefaultUploadConcurrency       = 5
+
+	// uploadProgressStep is how often (bytes) progress is logged for multipart uploads
+	uploadProgressStep int64 = 32 * 1024 * 1024
+)
+
+// UploadOptions configures file uploads. Files larger than PartSize are sent as a
+// concurrent multipart upload; smaller files use a single PutObject.
+// Failed parts are retried by the S3 client's retryer without restarting the upload.
+type UploadOptions struct {
+	PartSize    int64 // bytes per part (S3 minimum is 5 MiB); 0 uses DefaultUploadPartSize
+	Concurrency int   // parts uploaded in parallel; 0 uses DefaultUploadConcurrency
+}
+
+// newUploader builds a multipart-capable uploader for the given client
+func newUploader(client manager.UploadAPIClient, opts UploadOptions) *manager.Uploader {
+	return manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = opts.PartSize
+		if u.PartSize < manager.MinUploadPartSize {
+			u.PartSize = DefaultUploadPartSize
+		}
+		u.Concurrency = opts.Concurrency
+		if u.Concurrency <= 0 {
+			u.Concurrency = DefaultUploadConcurrency
+		}
+	})
+}
+
+// uploadFile streams a local file to S3 through the uploader, logging progress for multipart
+// uploads. metadata is stored as the object's user metadata; nil stores none.
+func uploadFile(
+	ctx context.Context,
+	uploader *manager.Uploader,
+	logger *zap.Logger,
+	bucket, key, filePath, contentType string,
+	metadata map[string]string,
+) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	var body io.Reader = file
+	multipart := info.Size() > uploader.PartSize
+	if multipart {
+		logger.Info("Starting multipart upload",
+			zap.String("key", key),
+			zap.Int64("size_bytes", info.Size()),
+			zap.Int64("part_size", uploader.PartSize),
+			zap.Int("concurrency", uploader.Concurrency),
+		)
+		body = &progressReader{
+			file: file,
+			onProgress: func(read int64) {
+				logger.Info("Upload progress",
+					zap.String("key", key),
+					zap.Int64("bytes_uploaded", read),
+					zap.Int64("size_bytes", info.Size()),
+				)
+			},
+		}
+	}
+
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if multipart {
+		logger.Info("Multipart upload complete",
+			zap.String("key", key),
+			zap.Int64("size_bytes", info.Size()),
+		)
+	}
+	return nil
+}
+
+// UploadStream uploads everything read from body until EOF. Parts are sent as they fill, so
+// memory stays bounded by the uploader's part size and concurrency whatever the total size.
+// tagging is a URL-encoded tag set ("key=value"); empty sets no tags.
+func (s *S3AssetRepository) UploadStream(ctx context.Context, bucket, key string, body io.Reader, contentType, tagging string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	if _, err := s.uploader.Upload(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload stream: %w", err)
+	}
+	return nil
+}
+
+// progressReader counts bytes read by the uploader and reports every uploadProgressStep.
+// It implements io.ReaderAt and io.ReadSeeker so the uploader can still read parts concurrently.
+type progressReader struct {
+	file       *os.File
+	read       atomic.Int64
+	reported   atomic.Int64
+	onProgress func(read int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.file.Read(p)
+	r.add(n)
+	return n, err
+}
+
+func (r *progressReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.file.ReadAt(p, off)
+	r.add(n)
+	return n, err
+}
+
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	return r.file.Seek(offset, whence)
+}
+
+func (r *progressReader) add(n int) {
+	read := r.read.Add(int64(n))
+	reported := r.reported.Load()
+	if read-reported >= uploadProgressStep && r.reported.CompareAndSwap(reported, read) {
+		r.onProgress(read)
+	}
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubUploadClient records which S3 upload calls were made
+type stubUploadClient struct {
+	mu        sync.Mutex
+	puts      int
+	creates   int
+	parts     int
+	completes int
+	aborts    int
+	bytes     int64
+}
+
+func (c *stubUploadClient) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	n, err := io.Copy(io.Discard, in.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.puts++
+	c.bytes += n
+	return &s3.PutObjectOutput{}, err
+}
+
+func (c *stubUploadClient) UploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	n, err := io.Copy(io.Discard, in.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.parts++
+	c.bytes += n
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, err
+}
+
+func (c *stubUploadClient) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.creates++
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (c *stubUploadClient) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completes++
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (c *stubUploadClient) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aborts++
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func writeTestFile(t *testing.T, size int64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	return path
+}
+
+func TestUploadFile_MultipartAboveThreshold(t *testing.T) {
+	client := &stubUploadClient{}
+	uploader := newUploader(client, UploadOptions{PartSize: manager.MinUploadPartSize, Concurrency: 2})
+	size := 2*manager.MinUploadPartSize + 1024
+	path := writeTestFile(t, size)
+
+	err := uploadFile(context.Background(), uploader, zap.NewNop(), "bucket", "users/u/jobs/j/clips/clip-001.mp4", path, "video/mp4", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, 0, client.puts)
+	require.Equal(t, 1, client.creates)
+	require.Equal(t, 3, client.parts)
+	require.Equal(t, 1, client.completes)
+	require.Equal(t, 0, client.aborts)
+	require.Equal(t, size, client.bytes)
+}
+
+func TestUploadFile_SinglePartBelowThreshold(t *testing.T) {
+	client := &stubUploadClient{}
+	uploader := newUploader(client, UploadOptions{PartSize: manager.MinUploadPartSize, Concurrency: 2})
+	size := manager.MinUploadPartSize - 1024
+	path := writeTestFile(t, size)
+
+	err := uploadFile(context.Background(), uploader, zap.NewNop(), "bucket", "users/u/jobs/j/audio/music.mp3", path, "audio/mpeg", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, client.puts)
+	require.Equal(t, 0, client.creates)
+	require.Equal(t, 0, client.parts)
+	require.Equal(t, size, client.bytes)
+}
+
+func TestNewUploader_Defaults(t *testing.T) {
+	uploader := newUploader(&stubUploadClient{}, UploadOptions{})
+	require.Equal(t, DefaultUploadPartSize, uploader.PartSize)
+	require.Equal(t, DefaultUploadConcurrency, uploader.Concurrency)
+
+	// Parts below the S3 minimum fall back to the default
+	uploader = newUploader(&stubUploadClient{}, UploadOptions{PartSize: 1024, Concurrency: 8})
+	require.Equal(t, DefaultUploadPartSize, uploader.PartSize)
+	require.Equal(t, 8, uploader.Concurrency)
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrScriptNotFound is returned when a script does not exist or has expired
+var ErrScriptNotFound = errors.New("script not found")
+
+// ScriptRetention is how long stored scripts are kept, matching the parser Lambda's expiry
+const ScriptRetention = 30 * 24 * time.Hour
+
+// userScriptsIndex lists a user's scripts by creation time
+const userScriptsIndex = "UserScriptsIndex"
+
+// userScriptsIndexKeys are the attributes of a UserScriptsIndex LastEvaluatedKey
+var userScriptsIndexKeys = []string{"script_id", "user_id", "created_at"}
+
+// maxScriptQueries bounds the queries one page may take when expired scripts are filtered out
+const maxScriptQueries = 10
+
+// DynamoDBScriptRepository stores generated scripts so they can be rerun as new jobs
+type DynamoDBScriptRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewScriptRepository creates a new script repository
+func NewScriptRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBScriptRepository {
+	return &DynamoDBScriptRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// ScriptsQuery selects one page of a user's scripts
+type ScriptsQuery struct {
+	Limit             int                             // Scripts per page (must be positive)
+	ExclusiveStartKey map[string]types.AttributeValue // Previous page's LastEvaluatedKey; nil for the first page
+}
+
+// ScriptsPage is one page of a user's scripts, newest first
+type ScriptsPage struct {
+	Scripts []*domain.Script
+
+	// LastEvaluatedKey is the ExclusiveStartKey for the next page; nil when there are no more scripts
+	LastEvaluatedKey map[string]types.AttributeValue
+}
+
+// DecodeScriptsCursor parses a cursor of a ListScriptsByUser page back into an ExclusiveStartKey
+func DecodeScriptsCursor(cursor string) (map[string]types.AttributeValue, error) {
+	return decodeCursor(cursor, userScriptsIndexKeys)
+}
+
+// SaveScript stores a script, replacing any with the same ID
+func (r *DynamoDBScriptRepository) SaveScript(ctx context.Context, script *domain.Script) error {
+	item, err := attributevalue.MarshalMap(script)
+	if err != nil {
+		return fmt.Errorf("failed to marshal script: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		r.logger.Error("Failed to save script",
+			zap.String("script_id", script.ScriptID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to save script: %w", err)
+	}
+	return nil
+}
+
+// GetScript retrieves a script by ID. An expired script is ErrScriptNotFound even before
+// DynamoDB's TTL deletes it, which can take days.
+func (r *DynamoDBScriptRepository) GetScript(ctx context.Context, scriptID string) (*domain.Script, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"script_id": &types.AttributeValueMemberS{Value: scriptID},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get script", zap.String("script_id", scriptID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get script: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrScriptNotFound
+	}
+
+	var script domain.Script
+	if err := attributevalue.UnmarshalMap(result.Item, &script); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal script: %w", err)
+	}
+	if script.ExpiresAt > 0 && script.ExpiresAt <= time.Now().Unix() {
+		return nil, ErrScriptNotFound
+	}
+	return &script, nil
+}
+
+// ListScriptsByUser retrieves one page of a user's unexpired scripts, newest first
+func (r *DynamoDBScriptRepository) ListScriptsByUser(ctx context.Context, userID string, query ScriptsQuery) (*ScriptsPage, error) {
+	if query.Limit < 1 {
+		return nil, fmt.Errorf("limit must be positive, got %d", query.Limit)
+	}
+	if query.ExclusiveStartKey != nil {
+		owner, ok := query.ExclusiveStartKey["user_id"].(*types.AttributeValueMemberS)
+		if !ok || owner.Value != userID {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(userScriptsIndex),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		FilterExpression:       aws.String("attribute_not_exists(expires_at) OR expires_at > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	page := &ScriptsPage{}
+	startKey := query.ExclusiveStartKey
+	for queries := 0; queries < maxScriptQueries; queries++ {
+		// Limit caps items read before the expiry filter, so a page may need several calls
+		input.ExclusiveStartKey = startKey
+		input.Limit = aws.Int32(int32(query.Limit - len(page.Scripts)))
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to query scripts by user",
+				zap.String("user_id", userID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to query scripts by user: %w", err)
+		}
+
+		var scripts []*domain.Script
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &scripts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal scripts: %w", err)
+		}
+		page.Scripts = append(page.Scripts, scripts...)
+
+		startKey = result.LastEvaluatedKey
+		if len(page.Scripts) == query.Limit || len(startKey) == 0 {
+			break
+		}
+	}
+	if len(startKey) > 0 {
+		page.LastEvaluatedKey = indexKey(startKey, userScriptsIndexKeys)
+	}
+	return page, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testScript(id, userID string, createdAt, expiresAt int64) *domain.Script {
+	return &domain.Script{
+		ScriptID:  id,
+		UserID:    userID,
+		Title:     "Morning Run",
+		Scenes:    []domain.Scene{{SceneNumber: 1, Duration: 8}},
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Status:    domain.ScriptStatusGenerated,
+		ExpiresAt: expiresAt,
+		Model:     "veo",
+	}
+}
+
+func TestScriptRepository_SaveAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().ScriptRepository("scripts", zap.NewNop())
+	future := time.Now().Add(ScriptRetention).Unix()
+
+	require.NoError(t, repo.SaveScript(ctx, testScript("script-1", "user-1", 100, future)))
+	script, err := repo.GetScript(ctx, "script-1")
+	require.NoError(t, err)
+	require.Equal(t, "user-1", script.UserID)
+	require.Equal(t, "veo", script.Model)
+	require.Len(t, script.Scenes, 1)
+
+	_, err = repo.GetScript(ctx, "script-missing")
+	require.ErrorIs(t, err, ErrScriptNotFound)
+
+	// DynamoDB deletes expired items lazily; they are treated as gone right away
+	require.NoError(t, repo.SaveScript(ctx, testScript("script-old", "user-1", 50, time.Now().Unix()-1)))
+	_, err = repo.GetScript(ctx, "script-old")
+	require.ErrorIs(t, err, ErrScriptNotFound)
+}
+
+func TestScriptRepository_ListScriptsByUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().ScriptRepository("scripts", zap.NewNop())
+	future := time.Now().Add(ScriptRetention).Unix()
+	past := time.Now().Unix() - 1
+
+	for i := 1; i <= 5; i++ {
+		expiresAt := future
+		if i == 4 {
+			expiresAt = past
+		}
+		require.NoError(t, repo.SaveScript(ctx, testScript(fmt.Sprintf("script-%d", i), "user-1", int64(i*100), expiresAt)))
+	}
+	require.NoError(t, repo.SaveScript(ctx, testScript("script-other", "user-2", 600, future)))
+
+	first, err := repo.ListScriptsByUser(ctx, "user-1", ScriptsQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"script-5", "script-3"}, scriptIDs(first.Scripts), "newest first, expired skipped")
+	require.NotNil(t, first.LastEvaluatedKey)
+
+	cursor, err := EncodeJobsCursor(first.LastEvaluatedKey)
+	require.NoError(t, err)
+	startKey, err := DecodeScriptsCursor(cursor)
+	require.NoError(t, err)
+
+	second, err := repo.ListScriptsByUser(ctx, "user-1", ScriptsQuery{Limit: 2, ExclusiveStartKey: startKey})
+	require.NoError(t, err)
+	require.Equal(t, []string{"script-2", "script-1"}, scriptIDs(second.Scripts))
+
+	_, err = repo.ListScriptsByUser(ctx, "user-2", ScriptsQuery{Limit: 2, ExclusiveStartKey: startKey})
+	require.ErrorIs(t, err, ErrInvalidCursor, "cursors are bound to their user")
+
+	_, err = DecodeJobsCursor(cursor)
+	require.ErrorIs(t, err, ErrInvalidCursor, "a script cursor is not a job cursor")
+	_, err = DecodeScriptsCursor("not-a-cursor")
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func scriptIDs(scripts []*domain.Script) []string {
+	ids := make([]string, len(scripts))
+	for i, script := range scripts {
+		ids[i] = script.ScriptID
+	}
+	return ids
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
+)
+
+// stageTimingsID is the key of the one item the stage timings are kept in
+const stageTimingsID = "stage_timings"
+
+// stageTimingsItem is the stored form of the stage timings
+type stageTimingsItem struct {
+	TimingsID string                        `dynamodbav:"timings_id"`
+	Timings   map[string]domain.StageTiming `dynamodbav:"timings"`
+	UpdatedAt int64                         `dynamodbav:"updated_at"`
+}
+
+// DynamoDBStageTimingsRepository stores the rolling averages of pipeline step durations that
+// job ETAs are estimated from, so a restarted server doesn't begin from static estimates
+type DynamoDBStageTimingsRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewStageTimingsRepository creates a new stage timings repository
+func NewStageTimingsRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBStageTimingsRepository {
+	return &DynamoDBStageTimingsRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// GetStageTimings returns the stored timings by step key, or nil if none were stored yet
+func (r *DynamoDBStageTimingsRepository) GetStageTimings(ctx context.Context) (map[string]domain.StageTiming, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"timings_id": &types.AttributeValueMemberS{Value: stageTimingsID},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get stage timings", zap.Error(err))
+		return nil, fmt.Errorf("failed to get stage timings: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var item stageTimingsItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stage timings: %w", err)
+	}
+	return item.Timings, nil
+}
+
+// PutStageTimings replaces the stored timings with timings
+func (r *DynamoDBStageTimingsRepository) PutStageTimings(ctx context.Context, timings map[string]domain.StageTiming) error {
+	item, err := attributevalue.MarshalMap(stageTimingsItem{
+		TimingsID: stageTimingsID,
+		Timings:   timings,
+		UpdatedAt: getCurrentTimestamp(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal stage timings: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		r.logger.Error("Failed to put stage timings",
+			zap.Int("timings", len(timings)),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to put stage timings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStageTimingsRepository_PutAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalDynamoDB().StageTimingsRepository("stage-timings", zap.NewNop())
+
+	timings, err := repo.GetStageTimings(ctx)
+	require.NoError(t, err)
+	require.Nil(t, timings, "nothing stored yet")
+
+	require.NoError(t, repo.PutStageTimings(ctx, map[string]domain.StageTiming{
+		"scene/veo/16-30":  {Seconds: 84.5, Samples: 12},
+		"script/veo/16-30": {Seconds: 18, Samples: 3},
+	}))
+	require.NoError(t, repo.PutStageTimings(ctx, map[string]domain.StageTiming{
+		"scene/veo/16-30": {Seconds: 80, Samples: 13},
+	}))
+
+	timings, err = repo.GetStageTimings(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]domain.StageTiming{"scene/veo/16-30": {Seconds: 80, Samples: 13}}, timings, "a put replaces the stored timings")
+}
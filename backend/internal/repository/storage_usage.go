@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// storagePeriod is the usage table sort key of a user's running storage total. Storage isn't
+// billed per month, so it lives beside the YYYY-MM credit records rather than in them.
+const storagePeriod = "storage"
+
+// AddStorageBytes atomically adds delta (negative when assets are deleted) to the bytes a user
+// has stored, creating the total on first use
+func (r *DynamoDBUsageRepository) AddStorageBytes(ctx context.Context, userID string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.tableName),
+		Key:              usageKey(userID, storagePeriod),
+		UpdateExpression: aws.String("ADD storage_bytes :delta SET last_updated = :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delta": &types.AttributeValueMemberN{Value: strconv.FormatInt(delta, 10)},
+			":now":   &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to update storage usage",
+			zap.String("user_id", userID),
+			zap.Int64("delta", delta),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to update storage usage: %w", err)
+	}
+	return nil
+}
+
+// SetStorageBytes overwrites a user's storage total, for reconciling it with their job records
+func (r *DynamoDBUsageRepository) SetStorageBytes(ctx context.Context, userID string, total int64) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.tableName),
+		Key:              usageKey(userID, storagePeriod),
+		UpdateExpression: aws.String("SET storage_bytes = :total, last_updated = :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":total": &types.AttributeValueMemberN{Value: strconv.FormatInt(total, 10)},
+			":now":   &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to set storage usage",
+			zap.String("user_id", userID),
+			zap.Int64("total", total),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to set storage usage: %w", err)
+	}
+	return nil
+}
+
+// GetStorageBytes returns the bytes a user has stored; 0 if nothing was ever recorded
+func (r *DynamoDBUsageRepository) GetStorageBytes(ctx context.Context, userID string) (int64, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       usageKey(userID, storagePeriod),
+	})
+	if err != nil {
+		r.logger.Error("Failed to get storage usage",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return 0, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+	return attrInt64(result.Item, "storage_bytes"), nil
+}
+
+// attrInt64 reads a number attribute, treating a missing or malformed one as 0
+func attrInt64(item map[string]types.AttributeValue, name string) int64 {
+	v, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(v.Value, 10, 64)
+	return n
+}
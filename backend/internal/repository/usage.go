@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -19,8 +21,28 @@ var (
 	ErrUsageNotFound = errors.New("usage not found")
 	// ErrQuotaExceeded is returned when user has no remaining quota
 	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInsufficientCredits is returned when a charge exceeds the remaining credit balance
+	ErrInsufficientCredits = errors.New("insufficient credits")
+	// ErrChargeNotRefundable is returned when a job has no charge in the period or was already refunded
+	ErrChargeNotRefundable = errors.New("charge not found or already refunded")
+	// errUsageExists is returned when another request created the period's usage record first
+	errUsageExists = errors.New("usage already exists")
 )
 
+// InsufficientCreditsError reports the balance a charge was rejected against
+type InsufficientCreditsError struct {
+	Required  int
+	Remaining int
+}
+
+func (e *InsufficientCreditsError) Error() string {
+	return fmt.Sprintf("insufficient credits: job costs %d, %d remaining", e.Required, e.Remaining)
+}
+
+func (e *InsufficientCreditsError) Unwrap() error {
+	return ErrInsufficientCredits
+}
+
 // Subscription tier quotas (videos per month)
 var tierQuotas = map[string]int{
 	"free":       10,
@@ -28,9 +50,24 @@ var tierQuotas = map[string]int{
 	"enterprise": 1000,
 }
 
+// Subscription tier credit grants (credits per month)
+var tierCredits = map[string]int{
+	"free":       1000,
+	"pro":        10000,
+	"enterprise": 100000,
+}
+
+// MonthlyCredits returns the credits granted each period for a subscription tier
+func MonthlyCredits(subscriptionTier string) int {
+	if credits, ok := tierCredits[subscriptionTier]; ok {
+		return credits
+	}
+	return tierCredits["free"] // Default to free tier
+}
+
 // DynamoDBUsageRepository handles DynamoDB operations for usage tracking
 type DynamoDBUsageRepository struct {
-	client    *dynamodb.Client
+	client    dynamoDBAPI
 	tableName string
 	logger    *zap.Logger
 }
@@ -61,6 +98,10 @@ func (r *DynamoDBUsageRepository) GetOrCreateUsage(ctx context.Context, userID,
 	// Try to get existing usage record
 	usage, err := r.GetUsage(ctx, userID, period)
 	if err == nil {
+		// Records created before credits existed get this period's grant
+		if usage.MonthlyCredits == 0 {
+			return r.initCredits(ctx, userID, period, MonthlyCredits(subscriptionTier))
+		}
 		return usage, nil
 	}
 
@@ -73,33 +114,67 @@ func (r *DynamoDBUsageRepository) GetOrCreateUsage(ctx context.Context, userID,
 	if quota == 0 {
 		quota = tierQuotas["free"] // Default to free tier
 	}
+	credits := MonthlyCredits(subscriptionTier)
 
 	usage = &domain.Usage{
-		UserID:         userID,
-		Period:         period,
-		RequestCount:   0,
-		VideoGenerated: 0,
-		TotalDuration:  0,
-		MonthlyQuota:   quota,
-		QuotaRemaining: quota,
-		LastUpdated:    time.Now(),
+		UserID:           userID,
+		Period:           period,
+		RequestCount:     0,
+		VideoGenerated:   0,
+		TotalDuration:    0,
+		MonthlyQuota:     quota,
+		QuotaRemaining:   quota,
+		MonthlyCredits:   credits,
+		CreditsRemaining: credits,
+		LastUpdated:      time.Now(),
 	}
 
 	if err := r.CreateUsage(ctx, usage); err != nil {
+		// A concurrent request created the record first; use theirs so no charge is overwritten
+		if errors.Is(err, errUsageExists) {
+			return r.GetUsage(ctx, userID, period)
+		}
 		return nil, err
 	}
 
 	return usage, nil
 }
 
+// initCredits adds the credit attributes to a usage record that predates them
+func (r *DynamoDBUsageRepository) initCredits(ctx context.Context, userID, period string, credits int) (*domain.Usage, error) {
+	result, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       usageKey(userID, period),
+		UpdateExpression: aws.String("SET monthly_credits = if_not_exists(monthly_credits, :credits), " +
+			"credits_remaining = if_not_exists(credits_remaining, :credits), " +
+			"credits_used = if_not_exists(credits_used, :zero)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":credits": &types.AttributeValueMemberN{Value: strconv.Itoa(credits)},
+			":zero":    &types.AttributeValueMemberN{Value: "0"},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil {
+		r.logger.Error("Failed to initialize credits",
+			zap.String("user_id", userID),
+			zap.String("period", period),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to initialize credits: %w", err)
+	}
+
+	var usage domain.Usage
+	if err := attributevalue.UnmarshalMap(result.Attributes, &usage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+	return &usage, nil
+}
+
 // GetUsage retrieves usage record for a specific user and period
 func (r *DynamoDBUsageRepository) GetUsage(ctx context.Context, userID, period string) (*domain.Usage, error) {
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"user_id": &types.AttributeValueMemberS{Value: userID},
-			"period":  &types.AttributeValueMemberS{Value: period},
-		},
+		Key:       usageKey(userID, period),
 	})
 	if err != nil {
 		r.logger.Error("Failed to get usage",
@@ -136,10 +211,15 @@ func (r *DynamoDBUsageRepository) CreateUsage(ctx context.Context, usage *domain
 	}
 
 	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(user_id)"),
 	})
 	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return errUsageExists
+		}
 		r.logger.Error("Failed to create usage",
 			zap.String("user_id", usage.UserID),
 			zap.String("period", usage.Period),
@@ -178,11 +258,8 @@ func (r *DynamoDBUsageRepository) CheckAndDecrementQuota(ctx context.Context, us
 
 	// Decrement quota atomically
 	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"user_id": &types.AttributeValueMemberS{Value: userID},
-			"period":  &types.AttributeValueMemberS{Value: period},
-		},
+		TableName:           aws.String(r.tableName),
+		Key:                 usageKey(userID, period),
 		UpdateExpression:    aws.String("SET quota_remaining = quota_remaining - :dec, last_updated = :now"),
 		ConditionExpression: aws.String("quota_remaining > :zero"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
@@ -222,11 +299,8 @@ func (r *DynamoDBUsageRepository) IncrementUsage(ctx context.Context, userID str
 	period := GetCurrentPeriod()
 
 	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"user_id": &types.AttributeValueMemberS{Value: userID},
-			"period":  &types.AttributeValueMemberS{Value: period},
-		},
+		TableName:        aws.String(r.tableName),
+		Key:              usageKey(userID, period),
 		UpdateExpression: aws.String("ADD request_count :one, video_generated :one, total_duration :duration SET last_updated = :now"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":one":      &types.AttributeValueMemberN{Value: "1"},
@@ -251,3 +325,263 @@ func (r *DynamoDBUsageRepository) IncrementUsage(ctx context.Context, userID str
 	)
 	return nil
 }
+
+// AddTTSCharacters adds characters synthesized outside a job, such as voice previews, to the
+// current period's usage
+func (r *DynamoDBUsageRepository) AddTTSCharacters(ctx context.Context, userID string, chars int) error {
+	if chars <= 0 {
+		return nil
+	}
+	period := GetCurrentPeriod()
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.tableName),
+		Key:              usageKey(userID, period),
+		UpdateExpression: aws.String("ADD tts_characters :chars SET last_updated = :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":chars": &types.AttributeValueMemberN{Value: strconv.Itoa(chars)},
+			":now":   &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to add TTS characters",
+			zap.String("user_id", userID),
+			zap.String("period", period),
+			zap.Int("chars", chars),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to add TTS characters: %w", err)
+	}
+	return nil
+}
+
+// ChargeCredits atomically takes a job's credits from the current period's balance and stores
+// the charge as its own item beside the period's usage record (see chargeKey), so a busy month
+// can't grow the usage record past DynamoDB's item limit. Both are written in one transaction
+// conditional on the balance, so concurrent requests can never overdraw it; a rejected charge
+// returns an *InsufficientCreditsError. The usage returned is the balance read before the
+// charge, less its credits.
+func (r *DynamoDBUsageRepository) ChargeCredits(ctx context.Context, userID, subscriptionTier string, charge domain.CreditCharge) (*domain.Usage, error) {
+	usage, err := r.GetOrCreateUsage(ctx, userID, subscriptionTier)
+	if err != nil {
+		return nil, err
+	}
+
+	chargeItem, err := attributevalue.MarshalMap(charge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credit charge: %w", err)
+	}
+	maps.Copy(chargeItem, chargeKey(userID, usage.Period, charge.JobID))
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName: aws.String(r.tableName),
+				Key:       usageKey(userID, usage.Period),
+				UpdateExpression: aws.String("SET credits_remaining = credits_remaining - :cost, " +
+					"credits_used = credits_used + :cost, last_updated = :now"),
+				ConditionExpression: aws.String("credits_remaining >= :cost"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":cost": &types.AttributeValueMemberN{Value: strconv.Itoa(charge.Credits)},
+					":now":  &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+				},
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(r.tableName),
+				Item:                chargeItem,
+				ConditionExpression: aws.String("attribute_not_exists(user_id)"), // One charge per job
+			}},
+		},
+	})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			remaining := usage.CreditsRemaining
+			if reasons := canceled.CancellationReasons; len(reasons) > 0 && reasons[0].Item != nil {
+				var current domain.Usage
+				if attributevalue.UnmarshalMap(reasons[0].Item, &current) == nil {
+					remaining = current.CreditsRemaining
+				}
+			}
+			r.logger.Warn("Insufficient credits for job",
+				zap.String("user_id", userID),
+				zap.String("job_id", charge.JobID),
+				zap.Int("cost", charge.Credits),
+				zap.Int("credits_remaining", remaining),
+			)
+			return nil, &InsufficientCreditsError{Required: charge.Credits, Remaining: remaining}
+		}
+
+		r.logger.Error("Failed to charge credits",
+			zap.String("user_id", userID),
+			zap.String("job_id", charge.JobID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to charge credits: %w", err)
+	}
+
+	updated := *usage
+	updated.CreditsRemaining -= charge.Credits
+	updated.CreditsUsed += charge.Credits
+
+	r.logger.Info("Credits charged",
+		zap.String("user_id", userID),
+		zap.String("job_id", charge.JobID),
+		zap.Int("cost", charge.Credits),
+		zap.Int("credits_remaining", updated.CreditsRemaining),
+	)
+	return &updated, nil
+}
+
+// RefundCredits returns credits for a job charged in the given period. Each charge can be
+// refunded once, for at most its original amount; other attempts return ErrChargeNotRefundable.
+func (r *DynamoDBUsageRepository) RefundCredits(ctx context.Context, userID, period, jobID string, credits int) error {
+	now := &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)}
+	refund := &types.AttributeValueMemberN{Value: strconv.Itoa(credits)}
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:           aws.String(r.tableName),
+				Key:                 chargeKey(userID, period, jobID),
+				UpdateExpression:    aws.String("SET refunded = :refund"),
+				ConditionExpression: aws.String("attribute_exists(user_id) AND refunded = :zero AND credits >= :refund"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":refund": refund,
+					":zero":   &types.AttributeValueMemberN{Value: "0"},
+				},
+			}},
+			{Update: &types.Update{
+				TableName: aws.String(r.tableName),
+				Key:       usageKey(userID, period),
+				UpdateExpression: aws.String("SET credits_remaining = credits_remaining + :refund, " +
+					"credits_used = credits_used - :refund, last_updated = :now"),
+				ConditionExpression: aws.String("attribute_exists(user_id)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":refund": refund,
+					":now":    now,
+				},
+			}},
+		},
+	})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			// Charges made before they were stored as their own items are in the usage record
+			return r.refundLegacyCharge(ctx, userID, period, jobID, credits)
+		}
+
+		r.logger.Error("Failed to refund credits",
+			zap.String("user_id", userID),
+			zap.String("period", period),
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to refund credits: %w", err)
+	}
+
+	r.logger.Info("Credits refunded",
+		zap.String("user_id", userID),
+		zap.String("period", period),
+		zap.String("job_id", jobID),
+		zap.Int("credits", credits),
+	)
+	return nil
+}
+
+// refundLegacyCharge refunds a charge kept in the usage record's charges map, where charges
+// were stored before they got their own items
+func (r *DynamoDBUsageRepository) refundLegacyCharge(ctx context.Context, userID, period, jobID string, credits int) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       usageKey(userID, period),
+		UpdateExpression: aws.String("SET credits_remaining = credits_remaining + :refund, " +
+			"credits_used = credits_used - :refund, charges.#job.refunded = :refund, last_updated = :now"),
+		ConditionExpression: aws.String("attribute_exists(charges.#job) AND charges.#job.refunded = :zero AND charges.#job.credits >= :refund"),
+		ExpressionAttributeNames: map[string]string{
+			"#job": jobID,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":refund": &types.AttributeValueMemberN{Value: strconv.Itoa(credits)},
+			":zero":   &types.AttributeValueMemberN{Value: "0"},
+			":now":    &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return ErrChargeNotRefundable
+		}
+
+		r.logger.Error("Failed to refund credits",
+			zap.String("user_id", userID),
+			zap.String("period", period),
+			zap.String("job_id", jobID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to refund credits: %w", err)
+	}
+
+	r.logger.Info("Credits refunded",
+		zap.String("user_id", userID),
+		zap.String("period", period),
+		zap.String("job_id", jobID),
+		zap.Int("credits", credits),
+	)
+	return nil
+}
+
+// ListCharges returns the charges made in period, in no particular order
+func (r *DynamoDBUsageRepository) ListCharges(ctx context.Context, userID, period string) ([]domain.CreditCharge, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("user_id = :user_id AND begins_with(period, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+			":prefix":  &types.AttributeValueMemberS{Value: chargePrefix(period)},
+		},
+	}
+
+	charges := []domain.CreditCharge{}
+	for {
+		result, err := r.client.Query(ctx, input)
+		if err != nil {
+			r.logger.Error("Failed to list credit charges",
+				zap.String("user_id", userID),
+				zap.String("period", period),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to list credit charges: %w", err)
+		}
+
+		var page []domain.CreditCharge
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal credit charges: %w", err)
+		}
+		charges = append(charges, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return charges, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// usageKey builds the primary key of a usage record
+func usageKey(userID, period string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id": &types.AttributeValueMemberS{Value: userID},
+		"period":  &types.AttributeValueMemberS{Value: period},
+	}
+}
+
+// chargePrefix is the sort key prefix of the charges made in period
+func chargePrefix(period string) string {
+	return "charge#" + period + "#"
+}
+
+// chargeKey builds the primary key of a job's charge: the user's partition, sorted under
+// charge#<period>#<job ID> beside the period's usage record
+func chargeKey(userID, period, jobID string) map[string]types.AttributeValue {
+	return usageKey(userID, chargePrefix(period)+jobID)
+}
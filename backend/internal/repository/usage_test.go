@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestUsageRepository returns a usage repository over an in-memory table, which applies
+// its conditional writes and transactions under a lock as DynamoDB serializes them
+func newTestUsageRepository() (*DynamoDBUsageRepository, *memoryDynamoDB) {
+	repo := NewLocalDynamoDB().UsageRepository("usage", zap.NewNop())
+	return repo, repo.client.(*memoryDynamoDB)
+}
+
+// storedUsage reads the user's current period usage record
+func storedUsage(t *testing.T, repo *DynamoDBUsageRepository, userID string) *domain.Usage {
+	t.Helper()
+	usage, err := repo.GetUsage(context.Background(), userID, GetCurrentPeriod())
+	require.NoError(t, err)
+	return usage
+}
+
+// storedCharge reads a job's charge in the current period
+func storedCharge(t *testing.T, repo *DynamoDBUsageRepository, userID, jobID string) domain.CreditCharge {
+	t.Helper()
+	charges, err := repo.ListCharges(context.Background(), userID, GetCurrentPeriod())
+	require.NoError(t, err)
+	for _, charge := range charges {
+		if charge.JobID == jobID {
+			return charge
+		}
+	}
+	t.Fatalf("no charge for %s", jobID)
+	return domain.CreditCharge{}
+}
+
+func testCharge(jobID string, credits int) domain.CreditCharge {
+	return domain.CreditCharge{JobID: jobID, Model: "veo", Duration: 30, Scenes: 4, Credits: credits, ChargedAt: 1735689600}
+}
+
+func TestChargeCredits_CreatesRecordAndDeducts(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+
+	usage, err := repo.ChargeCredits(context.Background(), "user-1", "pro", testCharge("job-1", 80))
+	require.NoError(t, err)
+	require.Equal(t, 10000, usage.MonthlyCredits)
+	require.Equal(t, 9920, usage.CreditsRemaining)
+	require.Equal(t, 80, usage.CreditsUsed)
+	require.Equal(t, testCharge("job-1", 80), storedCharge(t, repo, "user-1", "job-1"))
+	require.Equal(t, GetCurrentPeriod(), usage.Period)
+
+	stored := storedUsage(t, repo, "user-1")
+	require.Equal(t, 9920, stored.CreditsRemaining)
+	require.Equal(t, 80, stored.CreditsUsed)
+}
+
+func TestChargeCredits_ChargesAreTheirOwnItems(t *testing.T) {
+	repo, db := newTestUsageRepository()
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, err := repo.ChargeCredits(ctx, "user-1", "pro", testCharge("job-"+strconv.Itoa(i), 10))
+		require.NoError(t, err)
+	}
+	_, err := repo.ChargeCredits(ctx, "user-2", "pro", testCharge("job-other", 10))
+	require.NoError(t, err)
+
+	// However many jobs a month has, the usage record stays the same size
+	result, err := db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("usage"), Key: usageKey("user-1", GetCurrentPeriod())})
+	require.NoError(t, err)
+	require.NotContains(t, result.Item, "charges")
+
+	charges, err := repo.ListCharges(ctx, "user-1", GetCurrentPeriod())
+	require.NoError(t, err)
+	require.Len(t, charges, 5, "only the user's charges in the period")
+	charges, err = repo.ListCharges(ctx, "user-1", "1999-01")
+	require.NoError(t, err)
+	require.Empty(t, charges)
+}
+
+func TestChargeCredits_ConcurrentChargesNeverOverdraw(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+
+	// Free tier has 1000 credits, so only three 300-credit jobs fit
+	const attempts = 20
+	var wg sync.WaitGroup
+	errs := make([]error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = repo.ChargeCredits(context.Background(), "user-1", "free", testCharge("job-"+strconv.Itoa(i), 300))
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		var insufficient *InsufficientCreditsError
+		require.ErrorAs(t, err, &insufficient)
+		require.ErrorIs(t, err, ErrInsufficientCredits)
+		require.Equal(t, 300, insufficient.Required)
+		require.Equal(t, 100, insufficient.Remaining)
+	}
+	require.Equal(t, 3, succeeded)
+
+	usage := storedUsage(t, repo, "user-1")
+	require.Equal(t, 100, usage.CreditsRemaining)
+	require.Equal(t, 900, usage.CreditsUsed)
+	charges, err := repo.ListCharges(context.Background(), "user-1", GetCurrentPeriod())
+	require.NoError(t, err)
+	require.Len(t, charges, 3)
+}
+
+// conditionalFailureTable rejects every charge the way DynamoDB does when another
+// request spent the balance between the read and the conditional write
+type conditionalFailureTable struct {
+	*memoryDynamoDB
+	current map[string]types.AttributeValue
+}
+
+func (f *conditionalFailureTable) TransactWriteItems(_ context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	reason := types.CancellationReason{Code: aws.String("ConditionalCheckFailed")}
+	if in.TransactItems[0].Update.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld {
+		reason.Item = f.current
+	}
+	return nil, &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{reason, {Code: aws.String("None")}}}
+}
+
+func TestChargeCredits_ConditionalWriteFailureReportsCurrentBalance(t *testing.T) {
+	repo, db := newTestUsageRepository()
+	_, err := repo.GetOrCreateUsage(context.Background(), "user-1", "free")
+	require.NoError(t, err)
+
+	// The read sees 1000 credits but a concurrent charge left only 40
+	current := storedUsage(t, repo, "user-1")
+	current.CreditsRemaining = 40
+	currentItem, err := attributevalue.MarshalMap(current)
+	require.NoError(t, err)
+
+	repo.client = &conditionalFailureTable{memoryDynamoDB: db, current: currentItem}
+	_, err = repo.ChargeCredits(context.Background(), "user-1", "free", testCharge("job-1", 80))
+
+	var insufficient *InsufficientCreditsError
+	require.ErrorAs(t, err, &insufficient)
+	require.Equal(t, 80, insufficient.Required)
+	require.Equal(t, 40, insufficient.Remaining)
+}
+
+func TestChargeCredits_RejectsSecondChargeForSameJob(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+
+	_, err := repo.ChargeCredits(context.Background(), "user-1", "free", testCharge("job-1", 80))
+	require.NoError(t, err)
+	_, err = repo.ChargeCredits(context.Background(), "user-1", "free", testCharge("job-1", 80))
+	require.ErrorIs(t, err, ErrInsufficientCredits)
+	require.Equal(t, 920, storedUsage(t, repo, "user-1").CreditsRemaining)
+}
+
+func TestRefundCredits_OnlyOncePerCharge(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+	ctx := context.Background()
+
+	_, err := repo.ChargeCredits(ctx, "user-1", "free", testCharge("job-1", 80))
+	require.NoError(t, err)
+
+	period := GetCurrentPeriod()
+	require.ErrorIs(t, repo.RefundCredits(ctx, "user-1", period, "job-1", 100), ErrChargeNotRefundable, "refund above the charge")
+	require.NoError(t, repo.RefundCredits(ctx, "user-1", period, "job-1", 60))
+	require.ErrorIs(t, repo.RefundCredits(ctx, "user-1", period, "job-1", 60), ErrChargeNotRefundable)
+	require.ErrorIs(t, repo.RefundCredits(ctx, "user-1", period, "job-unknown", 10), ErrChargeNotRefundable)
+
+	usage := storedUsage(t, repo, "user-1")
+	require.Equal(t, 980, usage.CreditsRemaining)
+	require.Equal(t, 20, usage.CreditsUsed)
+	require.Equal(t, 60, storedCharge(t, repo, "user-1", "job-1").Refunded)
+}
+
+func TestRefundCredits_LegacyChargeInUsageRecord(t *testing.T) {
+	repo, db := newTestUsageRepository()
+	ctx := context.Background()
+
+	// A charge made before charges got their own items
+	legacy, err := attributevalue.MarshalMap(map[string]interface{}{
+		"user_id":           "user-1",
+		"period":            GetCurrentPeriod(),
+		"monthly_credits":   1000,
+		"credits_remaining": 920,
+		"credits_used":      80,
+		"charges":           map[string]domain.CreditCharge{"job-1": testCharge("job-1", 80)},
+	})
+	require.NoError(t, err)
+	_, err = db.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("usage"), Item: legacy})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.RefundCredits(ctx, "user-1", GetCurrentPeriod(), "job-1", 80))
+	require.ErrorIs(t, repo.RefundCredits(ctx, "user-1", GetCurrentPeriod(), "job-1", 80), ErrChargeNotRefundable)
+	require.Equal(t, 1000, storedUsage(t, repo, "user-1").CreditsRemaining)
+}
+
+func TestGetOrCreateUsage_BackfillsCreditsOnLegacyRecord(t *testing.T) {
+	repo, db := newTestUsageRepository()
+	legacy, err := attributevalue.MarshalMap(map[string]interface{}{
+		"user_id":         "user-1",
+		"period":          GetCurrentPeriod(),
+		"monthly_quota":   100,
+		"quota_remaining": 97,
+	})
+	require.NoError(t, err)
+	_, err = db.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("usage"), Item: legacy})
+	require.NoError(t, err)
+
+	usage, err := repo.GetOrCreateUsage(context.Background(), "user-1", "pro")
+	require.NoError(t, err)
+	require.Equal(t, 97, usage.QuotaRemaining)
+	require.Equal(t, 10000, usage.MonthlyCredits)
+	require.Equal(t, 10000, usage.CreditsRemaining)
+}
+
+func TestGetOrCreateUsage_ConcurrentCreateKeepsExistingRecord(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+	ctx := context.Background()
+
+	_, err := repo.ChargeCredits(ctx, "user-1", "free", testCharge("job-1", 80))
+	require.NoError(t, err)
+
+	// A request that missed the record on read must not overwrite it on create
+	usage := &domain.Usage{UserID: "user-1", Period: GetCurrentPeriod(), MonthlyCredits: 1000, CreditsRemaining: 1000}
+	require.ErrorIs(t, repo.CreateUsage(ctx, usage), errUsageExists)
+	require.Equal(t, 920, storedUsage(t, repo, "user-1").CreditsRemaining)
+}
+
+func TestStorageBytes(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+	ctx := context.Background()
+
+	total, err := repo.GetStorageBytes(ctx, "user-1")
+	require.NoError(t, err)
+	require.Zero(t, total, "nothing stored yet")
+
+	require.NoError(t, repo.AddStorageBytes(ctx, "user-1", 3000))
+	require.NoError(t, repo.AddStorageBytes(ctx, "user-1", 1500))
+	require.NoError(t, repo.AddStorageBytes(ctx, "user-1", -1000))
+	total, err = repo.GetStorageBytes(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, int64(3500), total)
+
+	require.NoError(t, repo.SetStorageBytes(ctx, "user-1", 200))
+	total, err = repo.GetStorageBytes(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, int64(200), total)
+
+	// Storage lives beside the credit records, not in them
+	_, err = repo.GetUsage(ctx, "user-1", GetCurrentPeriod())
+	require.ErrorIs(t, err, ErrUsageNotFound)
+}
+
+func TestAddTTSCharacters(t *testing.T) {
+	repo, _ := newTestUsageRepository()
+	ctx := context.Background()
+
+	// Previews before the first job create the period's record; the credit grant is added later
+	require.NoError(t, repo.AddTTSCharacters(ctx, "user-1", 120))
+	usage, err := repo.ChargeCredits(ctx, "user-1", "free", testCharge("job-1", 100))
+	require.NoError(t, err)
+	require.Equal(t, 900, usage.CreditsRemaining)
+
+	require.NoError(t, repo.AddTTSCharacters(ctx, "user-1", 80))
+	require.NoError(t, repo.AddTTSCharacters(ctx, "user-1", 0))
+	stored := storedUsage(t, repo, "user-1")
+	require.Equal(t, 200, stored.TTSCharacters)
+	require.Equal(t, 900, stored.CreditsRemaining, "characters don't touch credits")
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+// DynamoDBUserBucketRepository maps users (enterprise tenants) to their own assets bucket, one
+// item per mapped user
+type DynamoDBUserBucketRepository struct {
+	client    dynamoDBAPI
+	tableName string
+	logger    *zap.Logger
+}
+
+// NewUserBucketRepository creates a new user bucket mapping repository
+func NewUserBucketRepository(
+	client *dynamodb.Client,
+	tableName string,
+	logger *zap.Logger,
+) *DynamoDBUserBucketRepository {
+	return &DynamoDBUserBucketRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// GetUserBucket returns the bucket mapped to the user, or "" for users without one
+func (r *DynamoDBUserBucketRepository) GetUserBucket(ctx context.Context, userID string) (string, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get user bucket",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return "", fmt.Errorf("failed to get user bucket: %w", err)
+	}
+	bucket, ok := result.Item["bucket"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", nil
+	}
+	return bucket.Value, nil
+}
+
+// PutUserBucket maps the user to bucket, replacing any previous mapping
+func (r *DynamoDBUserBucketRepository) PutUserBucket(ctx context.Context, userID, bucket string) error {
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]types.AttributeValue{
+			"user_id": &types.AttributeValueMemberS{Value: userID},
+			"bucket":  &types.AttributeValueMemberS{Value: bucket},
+		},
+	})
+	if err != nil {
+		r.logger.Error("Failed to put user bucket",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to put user bucket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package s3util parses references to S3 objects as they appear in job records and requests:
+// s3:// URIs, virtual-hosted-style and path-style HTTPS URLs (optionally presigned), and bare
+// object keys.
+package s3util
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidURL is returned for references that don't name an S3 object
+var ErrInvalidURL = errors.New("not an S3 object reference")
+
+// ParseURL returns the bucket and key an S3 reference points at. Supported forms:
+//
+//	s3://bucket/key
+//	https://bucket.s3.amazonaws.com/key             (also bucket.s3.<region>.amazonaws.com, bucket.s3-<region>.amazonaws.com)
+//	https://s3.amazonaws.com/bucket/key             (also s3.<region>.amazonaws.com, s3-<region>.amazonaws.com)
+//	any of the HTTPS forms with a query string      (presigned URLs; the query is ignored)
+//	users/123/jobs/456/clips/scene-001.mp4          (a bare key; bucket is "")
+//
+// Query strings that were URL-encoded into the path ("%3F") are dropped as well.
+func ParseURL(raw string) (bucket, key string, err error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", "", fmt.Errorf("%w: empty", ErrInvalidURL)
+	}
+	if !strings.Contains(raw, "://") {
+		key = strings.TrimPrefix(cutQuery(raw), "/")
+		if key == "" {
+			return "", "", fmt.Errorf("%w: %q", ErrInvalidURL, raw)
+		}
+		return "", key, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	path := strings.TrimPrefix(cutQuery(u.Path), "/")
+
+	switch u.Scheme {
+	case "s3":
+		bucket, key = u.Host, path
+	case "https", "http":
+		bucket, key, err = parseHTTPS(strings.ToLower(u.Hostname()), path)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %q", err, raw)
+		}
+	default:
+		return "", "", fmt.Errorf("%w: unsupported scheme in %q", ErrInvalidURL, raw)
+	}
+
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("%w: missing bucket or key in %q", ErrInvalidURL, raw)
+	}
+	return bucket, key, nil
+}
+
+// Key returns the object key of an S3 reference, or "" when raw can't be parsed. Callers that
+// presign or download the key treat "" as "no asset".
+func Key(raw string) string {
+	_, key, err := ParseURL(raw)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// MustKey is like Key but panics when raw can't be parsed. Only use it for references built by
+// this service, such as the URLs S3AssetRepository.UploadFile returns.
+func MustKey(raw string) string {
+	_, key, err := ParseURL(raw)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// parseHTTPS splits an S3 endpoint host and URL path into bucket and key
+func parseHTTPS(host, path string) (bucket, key string, err error) {
+	base, ok := strings.CutSuffix(host, ".amazonaws.com")
+	if !ok {
+		base, ok = strings.CutSuffix(host, ".amazonaws.com.cn")
+	}
+	if !ok {
+		return "", "", fmt.Errorf("%w: not an S3 host", ErrInvalidURL)
+	}
+
+	// The first "s3" label (s3, s3-<region>) starts the endpoint; labels before it are the
+	// bucket name, which may itself contain dots
+	labels := strings.Split(base, ".")
+	for i, label := range labels {
+		if label != "s3" && !strings.HasPrefix(label, "s3-") {
+			continue
+		}
+		if i == 0 {
+			// Path-style: the bucket is the first path segment
+			bucket, key, _ = strings.Cut(path, "/")
+			return bucket, key, nil
+		}
+		return strings.Join(labels[:i], "."), path, nil
+	}
+	return "", "", fmt.Errorf("%w: not an S3 host", ErrInvalidURL)
+}
+
+// cutQuery drops a query string that survived as part of the path, either literally or
+// URL-encoded (older job records stored presigned URLs that way)
+func cutQuery(s string) string {
+	for _, marker := range []string{"?", "%3F", "%3f"} {
+		if idx := strings.Index(s, marker); idx != -1 {
+			s = s[:idx]
+		}
+	}
+	return s
+}
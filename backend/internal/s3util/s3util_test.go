@@ -0,0 +1,119 @@
+package s3util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	const key = "users/user123/jobs/job456/clips/scene-001.mp4"
+	const presign = "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIA%2F20250101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Signature=abc"
+
+	tests := []struct {
+		name   string
+		raw    string
+		bucket string
+		key    string
+	}{
+		// s3:// URIs
+		{"s3 uri", "s3://omnigen-assets/" + key, "omnigen-assets", key},
+		{"s3 uri with dotted bucket", "s3://assets.omnigen.io/" + key, "assets.omnigen.io", key},
+
+		// Virtual-hosted-style
+		{"virtual hosted global", "https://omnigen-assets.s3.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted us-east-1", "https://omnigen-assets.s3.us-east-1.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted eu-west-1", "https://omnigen-assets.s3.eu-west-1.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted ap-southeast-2", "https://omnigen-assets.s3.ap-southeast-2.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted legacy dash region", "https://omnigen-assets.s3-us-west-2.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted dualstack", "https://omnigen-assets.s3.dualstack.us-east-2.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted china", "https://omnigen-assets.s3.cn-north-1.amazonaws.com.cn/" + key, "omnigen-assets", key},
+		{"virtual hosted dotted bucket", "https://assets.omnigen.io.s3.us-east-1.amazonaws.com/" + key, "assets.omnigen.io", key},
+		{"virtual hosted uppercase host", "https://Omnigen-Assets.S3.Amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted plain http", "http://omnigen-assets.s3.amazonaws.com/" + key, "omnigen-assets", key},
+		{"virtual hosted escaped key", "https://omnigen-assets.s3.amazonaws.com/users/u1/uploads/my%20logo.png", "omnigen-assets", "users/u1/uploads/my logo.png"},
+
+		// Path-style
+		{"path style global", "https://s3.amazonaws.com/omnigen-assets/" + key, "omnigen-assets", key},
+		{"path style us-east-1", "https://s3.us-east-1.amazonaws.com/omnigen-assets/" + key, "omnigen-assets", key},
+		{"path style eu-central-1", "https://s3.eu-central-1.amazonaws.com/omnigen-assets/" + key, "omnigen-assets", key},
+		{"path style legacy dash region", "https://s3-eu-west-1.amazonaws.com/omnigen-assets/" + key, "omnigen-assets", key},
+		{"path style dualstack", "https://s3.dualstack.us-west-2.amazonaws.com/omnigen-assets/" + key, "omnigen-assets", key},
+		{"path style dotted bucket", "https://s3.us-east-1.amazonaws.com/assets.omnigen.io/" + key, "assets.omnigen.io", key},
+
+		// Presigned
+		{"presigned virtual hosted", "https://omnigen-assets.s3.us-east-1.amazonaws.com/" + key + presign, "omnigen-assets", key},
+		{"presigned path style", "https://s3.us-east-1.amazonaws.com/omnigen-assets/" + key + presign, "omnigen-assets", key},
+		{"presigned path style global", "https://s3.amazonaws.com/omnigen-assets/" + key + presign, "omnigen-assets", key},
+		{"presigned with encoded query", "https://omnigen-assets.s3.amazonaws.com/" + key + "%3FX-Amz-Signature=abc", "omnigen-assets", key},
+		{"presigned with lowercase encoded query", "https://s3.amazonaws.com/omnigen-assets/" + key + "%3fX-Amz-Signature=abc", "omnigen-assets", key},
+		{"fragment", "https://omnigen-assets.s3.amazonaws.com/" + key + "#t=2", "omnigen-assets", key},
+
+		// Bare keys
+		{"bare key", key, "", key},
+		{"bare key with leading slash", "/" + key, "", key},
+		{"bare key with surrounding space", "  " + key + "\n", "", key},
+		{"bare key with query", key + "?versionId=3", "", key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, key, err := ParseURL(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned error: %v", tt.raw, err)
+			}
+			if bucket != tt.bucket || key != tt.key {
+				t.Fatalf("ParseURL(%q) = (%q, %q), want (%q, %q)", tt.raw, bucket, key, tt.bucket, tt.key)
+			}
+			if got := Key(tt.raw); got != tt.key {
+				t.Fatalf("Key(%q) = %q, want %q", tt.raw, got, tt.key)
+			}
+		})
+	}
+}
+
+func TestParseURLRejectsNonS3References(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"whitespace", "   "},
+		{"slash only", "/"},
+		{"other host", "https://replicate.delivery/pbxt/abc/output.mp4"},
+		{"lookalike host", "https://omnigen-assets.s3.amazonaws.com.evil.example/" + "users/u1/a.png"},
+		{"non-s3 aws service", "https://dynamodb.us-east-1.amazonaws.com/table/key"},
+		{"cloudfront", "https://d111111abcdef8.cloudfront.net/users/u1/a.png"},
+		{"virtual hosted without key", "https://omnigen-assets.s3.amazonaws.com/"},
+		{"path style without key", "https://s3.us-east-1.amazonaws.com/omnigen-assets"},
+		{"path style without bucket", "https://s3.amazonaws.com/"},
+		{"s3 uri without key", "s3://omnigen-assets"},
+		{"s3 uri without bucket", "s3:///users/u1/a.png"},
+		{"unsupported scheme", "ftp://omnigen-assets.s3.amazonaws.com/users/u1/a.png"},
+		{"malformed", "https://omnigen-assets.s3.amazonaws.com:badport/key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, key, err := ParseURL(tt.raw)
+			if !errors.Is(err, ErrInvalidURL) {
+				t.Fatalf("ParseURL(%q) = (%q, %q, %v), want ErrInvalidURL", tt.raw, bucket, key, err)
+			}
+			if got := Key(tt.raw); got != "" {
+				t.Fatalf("Key(%q) = %q, want empty", tt.raw, got)
+			}
+		})
+	}
+}
+
+func TestMustKey(t *testing.T) {
+	if got := MustKey("https://omnigen-assets.s3.amazonaws.com/users/u1/a.png"); got != "users/u1/a.png" {
+		t.Fatalf("MustKey returned %q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustKey did not panic on an invalid reference")
+		}
+	}()
+	MustKey("https://replicate.delivery/output.mp4")
+}
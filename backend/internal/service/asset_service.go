@@ -6,6 +6,7 @@ import (
 
 	"github.com/omnigen/backend/internal/domain"
 	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/s3util"
 	"go.uber.org/zap"
 )
 
@@ -49,7 +50,7 @@ func (s *AssetService) GetJobAssets(ctx context.Context, job *domain.Job, durati
 
 	// Generate presigned URLs for scene clips
 	for i, sceneURL := range job.SceneVideoURLs {
-		key := extractS3Key(sceneURL)
+		key := s3util.Key(sceneURL)
 		url, err := s.s3Repo.GetPresignedURL(ctx, key, duration)
 		if err != nil {
 			s.logger.Warn("Failed to generate presigned URL for scene clip",
@@ -65,7 +66,7 @@ func (s *AssetService) GetJobAssets(ctx context.Context, job *domain.Job, durati
 
 	// Generate presigned URL for thumbnail (last scene thumbnail)
 	if job.ThumbnailURL != "" {
-		key := extractS3Key(job.ThumbnailURL)
+		key := s3util.Key(job.ThumbnailURL)
 		url, err := s.s3Repo.GetPresignedURL(ctx, key, duration)
 		if err != nil {
 			s.logger.Warn("Failed to generate presigned URL for thumbnail",
@@ -81,7 +82,7 @@ func (s *AssetService) GetJobAssets(ctx context.Context, job *domain.Job, durati
 
 	// Generate presigned URL for audio
 	if job.AudioURL != "" {
-		key := extractS3Key(job.AudioURL)
+		key := s3util.Key(job.AudioURL)
 		url, err := s.s3Repo.GetPresignedURL(ctx, key, duration)
 		if err != nil {
 			s.logger.Warn("Failed to generate presigned URL for audio",
@@ -118,39 +119,3 @@ func (s *AssetService) GetJobAssets(ctx context.Context, job *domain.Job, durati
 
 	return assets, nil
 }
-
-// extractS3Key extracts the S3 key from a full S3 URL
-// Input: https://bucket.s3.amazonaws.com/users/user123/jobs/job456/clips/scene-001.mp4
-// Output: users/user123/jobs/job456/clips/scene-001.mp4
-func extractS3Key(s3URL string) string {
-	// Strip query parameters first (handles presigned URLs stored in DB)
-	// Check for both literal "?" and URL-encoded "%3F"
-	if idx := findSubstring(s3URL, "?"); idx != -1 {
-		s3URL = s3URL[:idx]
-	}
-	if idx := findSubstring(s3URL, "%3F"); idx != -1 {
-		s3URL = s3URL[:idx]
-	}
-	if idx := findSubstring(s3URL, "%3f"); idx != -1 {
-		s3URL = s3URL[:idx]
-	}
-
-	// Simple implementation: find ".amazonaws.com/" and return everything after it
-	const marker = ".amazonaws.com/"
-	idx := findSubstring(s3URL, marker)
-	if idx == -1 {
-		// Fallback: assume it's already just a key
-		return s3URL
-	}
-	return s3URL[idx+len(marker):]
-}
-
-// findSubstring returns the index of substr in s, or -1 if not found
-func findSubstring(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
-	}
-	return -1
-}
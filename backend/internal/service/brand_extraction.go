@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/documents"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/repository"
+	"github.com/omnigen/backend/internal/validation"
+)
+
+var (
+	// ErrNoSourceDocument is returned when a guideline has no uploaded document to parse
+	ErrNoSourceDocument = errors.New("brand guidelines have no source document")
+
+	// ErrUnreadableDocument is returned when a document is too large, not a PDF, or has no extractable content
+	ErrUnreadableDocument = errors.New("brand document could not be read")
+)
+
+// BrandExtractor turns document content into structured brand guidelines
+type BrandExtractor interface {
+	ExtractBrandGuidelines(ctx context.Context, documentText string, pageImageURLs []string) (*adapters.BrandGuidelinesExtraction, error)
+}
+
+// DocumentStore provides the S3 operations needed to read brand documents
+type DocumentStore interface {
+	DownloadFile(ctx context.Context, bucket, key, destPath string) error
+	UploadFile(ctx context.Context, bucket, key, filePath string, contentType string) (string, error)
+	GetPresignedURL(ctx context.Context, key string, duration time.Duration) (string, error)
+}
+
+// BrandExtractionService parses uploaded brand documents into BrandGuidelines
+type BrandExtractionService struct {
+	store     DocumentStore
+	extractor BrandExtractor
+	bucket    string
+	locator   *repository.AssetLocator // Places scanned page images under the user's assets; nil uses the legacy layout
+	logger    *zap.Logger
+}
+
+// NewBrandExtractionService creates a new brand extraction service
+func NewBrandExtractionService(store DocumentStore, extractor BrandExtractor, bucket string, locator *repository.AssetLocator, logger *zap.Logger) *BrandExtractionService {
+	return &BrandExtractionService{
+		store:     store,
+		extractor: extractor,
+		bucket:    bucket,
+		locator:   locator,
+		logger:    logger,
+	}
+}
+
+// Extract reads the guideline's source document and merges the extracted values into the
+// record without overwriting fields the user already filled in. Returns the fields filled.
+func (s *BrandExtractionService) Extract(ctx context.Context, guidelines *domain.BrandGuidelines) ([]string, error) {
+	if guidelines.SourceDocument == "" {
+		return nil, ErrNoSourceDocument
+	}
+
+	tempDir, err := os.MkdirTemp("", "brand-doc-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	docPath := filepath.Join(tempDir, "document.pdf")
+	if err := s.store.DownloadFile(ctx, s.bucket, guidelines.SourceDocument, docPath); err != nil {
+		return nil, fmt.Errorf("failed to download brand document: %w", err)
+	}
+
+	info, err := os.Stat(docPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat brand document: %w", err)
+	}
+	if info.Size() > documents.MaxPDFBytes {
+		return nil, fmt.Errorf("%w: document is %d MB, limit is %d MB",
+			ErrUnreadableDocument, info.Size()/(1024*1024), documents.MaxPDFBytes/(1024*1024))
+	}
+
+	data, err := os.ReadFile(docPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read brand document: %w", err)
+	}
+
+	content, err := documents.ExtractPDF(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadableDocument, err)
+	}
+
+	s.logger.Info("Parsed brand document",
+		zap.String("guideline_id", guidelines.GuidelineID),
+		zap.Int("pages", content.PageCount),
+		zap.Int("text_length", len(content.Text)),
+		zap.Int("images", len(content.Images)),
+	)
+
+	var extraction *adapters.BrandGuidelinesExtraction
+	switch {
+	case content.HasTextLayer():
+		extraction, err = s.extractor.ExtractBrandGuidelines(ctx, content.Text, nil)
+	case len(content.Images) > 0:
+		// Scanned document: send the page images to the GPT-4o vision path
+		var urls []string
+		urls, err = s.uploadPageImages(ctx, guidelines, content.Images, tempDir)
+		if err == nil {
+			extraction, err = s.extractor.ExtractBrandGuidelines(ctx, content.Text, urls)
+		}
+	default:
+		return nil, fmt.Errorf("%w: no text layer or page images found", ErrUnreadableDocument)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("brand extraction failed: %w", err)
+	}
+
+	filled := MergeBrandExtraction(guidelines, extraction)
+	guidelines.ExtractedAt = time.Now().Unix()
+
+	return filled, nil
+}
+
+// uploadPageImages stores scanned page images in S3 and returns presigned URLs for vision analysis
+func (s *BrandExtractionService) uploadPageImages(ctx context.Context, g *domain.BrandGuidelines, images [][]byte, tempDir string) ([]string, error) {
+	urls := make([]string, 0, len(images))
+	for i, img := range images {
+		path := filepath.Join(tempDir, fmt.Sprintf("page-%03d.jpg", i+1))
+		if err := os.WriteFile(path, img, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write page image: %w", err)
+		}
+
+		key := fmt.Sprintf("%sbrand/%s/pages/page-%03d.jpg", s.locator.UserAssetPrefix(g.UserID), g.GuidelineID, i+1)
+		if _, err := s.store.UploadFile(ctx, s.bucket, key, path, "image/jpeg"); err != nil {
+			return nil, fmt.Errorf("failed to upload page image: %w", err)
+		}
+
+		url, err := s.store.GetPresignedURL(ctx, key, 1*time.Hour)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign page image: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+// MergeBrandExtraction fills empty guideline fields from an extraction and
+// returns the names of the fields it filled. User-entered values are never replaced, and
+// extracted ones are cut to the brand guideline limits in the validation package.
+func MergeBrandExtraction(g *domain.BrandGuidelines, e *adapters.BrandGuidelinesExtraction) []string {
+	var filled []string
+
+	if len(g.Colors) == 0 {
+		if colors := boundedList(uniqueNonEmpty(e.Colors.Primary, e.Colors.Secondary, e.Colors.Accent)); len(colors) > 0 {
+			g.Colors = colors
+			filled = append(filled, "colors")
+		}
+	}
+
+	if strings.TrimSpace(g.Typography) == "" {
+		var parts []string
+		if h := strings.TrimSpace(e.Typography.Headings); h != "" {
+			parts = append(parts, "Headings: "+h)
+		}
+		if b := strings.TrimSpace(e.Typography.Body); b != "" {
+			parts = append(parts, "Body: "+b)
+		}
+		if len(parts) > 0 {
+			g.Typography = truncateText(strings.Join(parts, "; "), validation.MaxBrandTextLength)
+			filled = append(filled, "typography")
+		}
+	}
+
+	if len(g.BrandVoice) == 0 {
+		if voice := boundedList(uniqueNonEmpty(e.BrandVoice.Adjectives)); len(voice) > 0 {
+			g.BrandVoice = voice
+			filled = append(filled, "brand_voice")
+		}
+	}
+
+	if strings.TrimSpace(g.ImageStyle) == "" {
+		if style := strings.TrimSpace(e.ImageStyle.Description); style != "" {
+			g.ImageStyle = truncateText(style, validation.MaxBrandTextLength)
+			filled = append(filled, "image_style")
+		}
+	}
+
+	if strings.TrimSpace(g.VideoStyle) == "" {
+		if style := strings.TrimSpace(e.VideoStyle.Description); style != "" {
+			g.VideoStyle = truncateText(style, validation.MaxBrandTextLength)
+			filled = append(filled, "video_style")
+		}
+	}
+
+	return filled
+}
+
+// uniqueNonEmpty flattens the lists, dropping blanks and case-insensitive duplicates
+func uniqueNonEmpty(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, list := range lists {
+		for _, v := range list {
+			v = strings.TrimSpace(v)
+			key := strings.ToLower(v)
+			if v == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// boundedList keeps the first validation.MaxBrandListItems values short enough to store; longer
+// ones are sentences the model put in a list, not colors or adjectives
+func boundedList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if len(out) == validation.MaxBrandListItems {
+			break
+		}
+		if len(v) <= validation.MaxBrandListItemLength {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// truncateText cuts s to at most max bytes, at a word boundary where there is one and never
+// inside a UTF-8 sequence
+func truncateText(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if space := strings.LastIndexByte(s[:cut], ' '); space > max/2 {
+		cut = space
+	}
+	return strings.TrimSpace(s[:cut])
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/validation"
+)
+
+// fixtureStore serves a local file for every download and records uploads
+type fixtureStore struct {
+	fixture  string
+	uploaded []string
+}
+
+func (f *fixtureStore) DownloadFile(ctx context.Context, bucket, key, destPath string) error {
+	data, err := os.ReadFile(f.fixture)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(destPath, data, 0o600)
+}
+
+func (f *fixtureStore) UploadFile(ctx context.Context, bucket, key, filePath string, contentType string) (string, error) {
+	f.uploaded = append(f.uploaded, key)
+	return "s3://" + bucket + "/" + key, nil
+}
+
+func (f *fixtureStore) GetPresignedURL(ctx context.Context, key string, duration time.Duration) (string, error) {
+	return "https://example.com/" + key, nil
+}
+
+// fakeExtractor returns a canned extraction and records what it was sent
+type fakeExtractor struct {
+	result    *adapters.BrandGuidelinesExtraction
+	gotText   string
+	gotImages []string
+}
+
+func (f *fakeExtractor) ExtractBrandGuidelines(ctx context.Context, documentText string, pageImageURLs []string) (*adapters.BrandGuidelinesExtraction, error) {
+	f.gotText = documentText
+	f.gotImages = pageImageURLs
+	return f.result, nil
+}
+
+func sampleExtraction() *adapters.BrandGuidelinesExtraction {
+	return &adapters.BrandGuidelinesExtraction{
+		Colors:     adapters.ExtractedColors{Primary: []string{"#0A84FF"}, Secondary: []string{"#F4E1C1", "#0a84ff"}},
+		Typography: adapters.ExtractedTypography{Headings: "Helvetica Neue", Body: "Inter"},
+		BrandVoice: adapters.ExtractedBrandVoice{Adjectives: []string{"confident", "warm"}},
+		ImageStyle: adapters.ExtractedStyle{Description: "Natural light photography"},
+		VideoStyle: adapters.ExtractedStyle{Description: "Bright, airy lifestyle footage"},
+	}
+}
+
+func TestBrandExtractionTextDocument(t *testing.T) {
+	store := &fixtureStore{fixture: "../documents/testdata/brand_text.pdf"}
+	extractor := &fakeExtractor{result: sampleExtraction()}
+	svc := NewBrandExtractionService(store, extractor, "assets", nil, zap.NewNop())
+
+	g := &domain.BrandGuidelines{GuidelineID: "bg-1", UserID: "user-123", SourceDocument: "users/user-123/uploads/brand.pdf"}
+	filled, err := svc.Extract(context.Background(), g)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	if !strings.Contains(extractor.gotText, "Acme Brand Guidelines") {
+		t.Errorf("extractor did not receive document text, got %q", extractor.gotText)
+	}
+	if len(extractor.gotImages) != 0 || len(store.uploaded) != 0 {
+		t.Errorf("text document should not use the vision path, images = %v", extractor.gotImages)
+	}
+	if len(filled) != 5 {
+		t.Errorf("filled = %v, want all 5 fields", filled)
+	}
+	if g.ExtractedAt == 0 {
+		t.Error("expected ExtractedAt to be set")
+	}
+}
+
+func TestBrandExtractionScannedDocument(t *testing.T) {
+	store := &fixtureStore{fixture: "../documents/testdata/brand_scanned.pdf"}
+	extractor := &fakeExtractor{result: sampleExtraction()}
+	svc := NewBrandExtractionService(store, extractor, "assets", nil, zap.NewNop())
+
+	g := &domain.BrandGuidelines{GuidelineID: "bg-1", UserID: "user-123", SourceDocument: "users/user-123/uploads/scan.pdf"}
+	if _, err := svc.Extract(context.Background(), g); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	wantKey := "users/user-123/brand/bg-1/pages/page-001.jpg"
+	if !reflect.DeepEqual(store.uploaded, []string{wantKey}) {
+		t.Errorf("uploaded = %v, want [%s]", store.uploaded, wantKey)
+	}
+	if !reflect.DeepEqual(extractor.gotImages, []string{"https://example.com/" + wantKey}) {
+		t.Errorf("extractor images = %v", extractor.gotImages)
+	}
+}
+
+func TestBrandExtractionErrors(t *testing.T) {
+	t.Run("no source document", func(t *testing.T) {
+		svc := NewBrandExtractionService(&fixtureStore{}, &fakeExtractor{}, "assets", nil, zap.NewNop())
+		_, err := svc.Extract(context.Background(), &domain.BrandGuidelines{GuidelineID: "bg-1"})
+		if !errors.Is(err, ErrNoSourceDocument) {
+			t.Errorf("err = %v, want ErrNoSourceDocument", err)
+		}
+	})
+
+	t.Run("not a PDF", func(t *testing.T) {
+		path := t.TempDir() + "/brand.docx"
+		if err := os.WriteFile(path, []byte("PK\x03\x04 word document"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		svc := NewBrandExtractionService(&fixtureStore{fixture: path}, &fakeExtractor{}, "assets", nil, zap.NewNop())
+		_, err := svc.Extract(context.Background(), &domain.BrandGuidelines{GuidelineID: "bg-1", SourceDocument: "brand.docx"})
+		if !errors.Is(err, ErrUnreadableDocument) {
+			t.Errorf("err = %v, want ErrUnreadableDocument", err)
+		}
+	})
+}
+
+func TestMergeBrandExtractionKeepsUserValues(t *testing.T) {
+	g := &domain.BrandGuidelines{
+		Colors:     []string{"#FF0000"},
+		Typography: "Futura",
+		VideoStyle: "Handheld documentary",
+	}
+
+	filled := MergeBrandExtraction(g, sampleExtraction())
+
+	if !reflect.DeepEqual(filled, []string{"brand_voice", "image_style"}) {
+		t.Errorf("filled = %v, want [brand_voice image_style]", filled)
+	}
+	if !reflect.DeepEqual(g.Colors, []string{"#FF0000"}) {
+		t.Errorf("Colors overwritten: %v", g.Colors)
+	}
+	if g.Typography != "Futura" || g.VideoStyle != "Handheld documentary" {
+		t.Errorf("user values overwritten: typography=%q video_style=%q", g.Typography, g.VideoStyle)
+	}
+	if !reflect.DeepEqual(g.BrandVoice, []string{"confident", "warm"}) {
+		t.Errorf("BrandVoice = %v", g.BrandVoice)
+	}
+}
+
+func TestMergeBrandExtractionDedupesColors(t *testing.T) {
+	g := &domain.BrandGuidelines{}
+	MergeBrandExtraction(g, sampleExtraction())
+
+	if !reflect.DeepEqual(g.Colors, []string{"#0A84FF", "#F4E1C1"}) {
+		t.Errorf("Colors = %v", g.Colors)
+	}
+	if g.Typography != "Headings: Helvetica Neue; Body: Inter" {
+		t.Errorf("Typography = %q", g.Typography)
+	}
+}
+
+func TestMergeBrandExtractionBoundsValues(t *testing.T) {
+	e := sampleExtraction()
+	e.ImageStyle.Description = strings.Repeat("soft natural light ", 100)
+	e.BrandVoice.Adjectives = []string{"confident", strings.Repeat("a sentence, not an adjective ", 5)}
+	for i := 0; i < validation.MaxBrandListItems+5; i++ {
+		e.Colors.Accent = append(e.Colors.Accent, fmt.Sprintf("#%06X", i))
+	}
+
+	g := &domain.BrandGuidelines{}
+	MergeBrandExtraction(g, e)
+
+	if n := len(g.ImageStyle); n > validation.MaxBrandTextLength || n < validation.MaxBrandTextLength/2 {
+		t.Errorf("ImageStyle is %d bytes, want at most %d", n, validation.MaxBrandTextLength)
+	}
+	if !strings.HasPrefix(e.ImageStyle.Description, g.ImageStyle+" ") {
+		t.Errorf("ImageStyle should be cut at a word boundary, ends %q", g.ImageStyle[len(g.ImageStyle)-10:])
+	}
+	if len(g.Colors) != validation.MaxBrandListItems {
+		t.Errorf("Colors has %d entries, want %d", len(g.Colors), validation.MaxBrandListItems)
+	}
+	if !reflect.DeepEqual(g.BrandVoice, []string{"confident"}) {
+		t.Errorf("BrandVoice = %v", g.BrandVoice)
+	}
+	if errs := validation.ValidateBrandGuidelines(g); len(errs) != 0 {
+		t.Errorf("merged guidelines fail validation: %v", errs)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	if got := truncateText("short", 10); got != "short" {
+		t.Errorf("truncateText() = %q", got)
+	}
+	if got := truncateText("héllo", 2); got != "h" {
+		t.Errorf("a cut inside a UTF-8 sequence = %q, want %q", got, "h")
+	}
+	if got := truncateText("bright airy footage", 16); got != "bright airy" {
+		t.Errorf("truncateText() = %q, want the last whole word", got)
+	}
+}
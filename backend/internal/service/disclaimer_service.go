@@ -13,29 +13,32 @@ import (
 
 // DisclaimerService handles tiered disclaimer logic and timing calculations.
 type DisclaimerService struct {
-	ttsAdapter *adapters.OpenAITTSAdapter
+	ttsRouter  *adapters.TTSRouter
 	gptAdapter *adapters.GPT4oAdapter
 	logger     *zap.Logger
 }
 
-// NewDisclaimerService creates a new disclaimer service instance.
+// NewDisclaimerService creates a new disclaimer service instance. Disclaimers are voiced by the
+// provider tts resolves for each job, like its narration.
 func NewDisclaimerService(
-	tts *adapters.OpenAITTSAdapter,
+	tts *adapters.TTSRouter,
 	gpt *adapters.GPT4oAdapter,
 	logger *zap.Logger,
 ) *DisclaimerService {
 	return &DisclaimerService{
-		ttsAdapter: tts,
+		ttsRouter:  tts,
 		gptAdapter: gpt,
 		logger:     logger,
 	}
 }
 
 // ComputeDisclaimerSpec determines the appropriate disclaimer tier and generates audio timing.
+// The audio is spoken in voice by the TTS provider the job's narration uses.
 func (s *DisclaimerService) ComputeDisclaimerSpec(
 	ctx context.Context,
 	fullDisclaimerText string,
 	videoDuration int,
+	provider string,
 	voice string,
 ) (*domain.DisclaimerSpec, error) {
 	spec := &domain.DisclaimerSpec{
@@ -81,7 +84,11 @@ func (s *DisclaimerService) ComputeDisclaimerSpec(
 	}
 
 	// Generate TTS and get duration
-	_, duration, err := s.ttsAdapter.GenerateVoiceoverWithDuration(ctx, spec.AudioText, voice, spec.Speed)
+	ttsAdapter, _ := s.ttsRouter.Resolve(provider)
+	if ttsAdapter == nil {
+		return nil, fmt.Errorf("tts adapter not configured")
+	}
+	_, duration, err := ttsAdapter.GenerateVoiceoverWithDuration(ctx, spec.AudioText, voice, spec.Speed)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate disclaimer TTS: %w", err)
 	}
@@ -109,6 +116,7 @@ Generate a shortened audio disclaimer version suitable for 15-20 second ads (12
 Keep ALL contraindications and the 3–4 most common/serious side effects.
 Start with "Do not take..." or "May cause..." phrasing.
 Make it sound natural when spoken fast.
+Write it in the language of the full text; never translate it.
 Output only the short text, nothing else.`, fullText)
 
 	s.logger.Info("Generating short disclaimer via GPT",
@@ -146,13 +154,13 @@ func CalculateMusicTail(videoDuration int) float64 {
 	return tail
 }
 
-// CalculateNarrationBudget computes available time for main narration.
-func CalculateNarrationBudget(videoDuration int, disclaimerDuration float64) (float64, int) {
+// CalculateNarrationBudget computes available time for main narration, and the words a
+// narrator speaking wordsPerSecond fits into it.
+func CalculateNarrationBudget(videoDuration int, disclaimerDuration float64, wordsPerSecond float64) (float64, int) {
 	musicTail := CalculateMusicTail(videoDuration)
 	budgetSeconds := float64(videoDuration) - disclaimerDuration - musicTail
 
-	// ~2.5 words per second
-	budgetWords := int(budgetSeconds * 2.5)
+	budgetWords := int(budgetSeconds * wordsPerSecond)
 
 	return budgetSeconds, budgetWords
 }
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
+	"reflect"
 	"testing"
 
+	"github.com/omnigen/backend/internal/adapters"
 	"github.com/omnigen/backend/internal/domain"
+	"go.uber.org/zap"
 )
 
 func TestCalculateMusicTail(t *testing.T) {
@@ -59,6 +63,7 @@ func TestCalculateNarrationBudget(t *testing.T) {
 		name               string
 		videoDuration      int
 		disclaimerDuration float64
+		language           string // Paced by domain.DefaultNarrationPacing; "" is English
 		expectedSeconds    float64
 		expectedWords      int
 	}{
@@ -91,11 +96,28 @@ func TestCalculateNarrationBudget(t *testing.T) {
 			expectedSeconds:    15.0, // 20 - 4 - 1.0 (music tail, clamped at 1.0)
 			expectedWords:      37,   // 15 * 2.5 = 37.5 (truncated)
 		},
+		{
+			name:               "30s Spanish video with 5s disclaimer",
+			videoDuration:      30,
+			disclaimerDuration: 5.0,
+			language:           "es-MX",
+			expectedSeconds:    24.0,
+			expectedWords:      52, // 24 * 2.2 = 52.8 (truncated)
+		},
+		{
+			name:               "60s German video with 8s disclaimer",
+			videoDuration:      60,
+			disclaimerDuration: 8.0,
+			language:           "de",
+			expectedSeconds:    50.0,
+			expectedWords:      100, // 50 * 2.0
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			seconds, words := CalculateNarrationBudget(tt.videoDuration, tt.disclaimerDuration)
+			wordsPerSecond := domain.DefaultNarrationPacing.WordsPerSecond(tt.language)
+			seconds, words := CalculateNarrationBudget(tt.videoDuration, tt.disclaimerDuration, wordsPerSecond)
 
 			// Allow small tolerance for floating point
 			tolerance := 0.1
@@ -254,3 +276,34 @@ func TestDisclaimerTierConstants(t *testing.T) {
 		t.Errorf("DisclaimerTierTextOnly = %v, want 'text_only'", domain.DisclaimerTierTextOnly)
 	}
 }
+
+// voiceRecordingTTS records the voices it is asked to speak in
+type voiceRecordingTTS struct {
+	adapters.TTSAdapter
+	voices []string
+}
+
+func (r *voiceRecordingTTS) GenerateVoiceoverWithDuration(ctx context.Context, text string, voice string, speed float64) ([]byte, float64, error) {
+	r.voices = append(r.voices, voice)
+	return []byte("audio"), 4, nil
+}
+
+func TestComputeDisclaimerSpec_UsesTheNarrationProvider(t *testing.T) {
+	openAI, elevenLabs := &voiceRecordingTTS{}, &voiceRecordingTTS{}
+	router := adapters.NewTTSRouter(adapters.TTSProviderOpenAI, map[adapters.TTSProvider]adapters.TTSAdapter{
+		adapters.TTSProviderOpenAI:     openAI,
+		adapters.TTSProviderElevenLabs: elevenLabs,
+	}, zap.NewNop())
+	s := NewDisclaimerService(router, nil, zap.NewNop())
+
+	spec, err := s.ComputeDisclaimerSpec(context.Background(), "May cause drowsiness.", 30, "elevenlabs", "21m00Tcm4TlvDq8ikWAM")
+	if err != nil {
+		t.Fatalf("ComputeDisclaimerSpec() error = %v", err)
+	}
+	if spec.AudioDuration != 4 {
+		t.Errorf("AudioDuration = %v, want 4", spec.AudioDuration)
+	}
+	if len(openAI.voices) != 0 || !reflect.DeepEqual(elevenLabs.voices, []string{"21m00Tcm4TlvDq8ikWAM"}) {
+		t.Errorf("voices spoken: openai %v, elevenlabs %v; want the voice ID on ElevenLabs only", openAI.voices, elevenLabs.voices)
+	}
+}
@@ -3,18 +3,23 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"go.uber.org/zap"
 
 	"github.com/omnigen/backend/internal/adapters"
 	"github.com/omnigen/backend/internal/domain"
 	"github.com/omnigen/backend/internal/prompts"
+	"github.com/omnigen/backend/internal/similarity"
 )
 
 // ParserService handles ad script generation
 type ParserService struct {
-	gpt4o  *adapters.GPT4oAdapter
+	gpt4o  adapters.ScriptGenerator
 	logger *zap.Logger
+
+	// Similarity at which adjacent scenes count as repeated; 0 turns the check off
+	sceneSimilarityThreshold float64
 }
 
 // ParseRequest represents user input for script generation - SIMPLE interface
@@ -24,6 +29,7 @@ type ParseRequest struct {
 	Duration    int    `json:"duration"`              // 10-60 seconds (must be multiple of 10)
 	AspectRatio string `json:"aspect_ratio"`          // "16:9", "9:16", or "1:1"
 	StartImage  string `json:"start_image,omitempty"` // Optional starting image URL (first scene only)
+	VideoModel  string `json:"video_model,omitempty"` // Target video model (veo, kling) - defaults to veo
 
 	// Style reference image - analyzed and converted to text description for ALL scenes
 	StyleReferenceImage string `json:"style_reference_image,omitempty"`
@@ -42,19 +48,40 @@ type ParseRequest struct {
 	CallToAction      string
 	ProCinematography bool
 	CreativeBoost     bool
+
+	// Brand guidelines (optional) - injected into the system prompt
+	BrandGuidelines *domain.BrandGuidelines
+
+	// Product photos (optional) - GPT-4o assigns them to scenes by ref
+	ProductImages []domain.ProductImage
+
+	// Scene plan (optional) - the script must have exactly these scenes and durations
+	ScenePlan []domain.PlannedScene
+
+	// Language (optional) - BCP-47 tag of the narration, title and call to action, and the
+	// narrator's words per second in it
+	Language                string
+	NarrationWordsPerSecond float64
 }
 
 // NewParserService creates a new script parser service
 func NewParserService(
-	gpt4o *adapters.GPT4oAdapter,
+	gpt4o adapters.ScriptGenerator,
 	logger *zap.Logger,
 ) *ParserService {
 	return &ParserService{
-		gpt4o:  gpt4o,
-		logger: logger,
+		gpt4o:                    gpt4o,
+		logger:                   logger,
+		sceneSimilarityThreshold: similarity.DefaultThreshold,
 	}
 }
 
+// SetSceneSimilarityThreshold sets the word overlap, from 0 to 1, at which two adjacent scenes
+// count as repeated. 0 or less turns the check and its retry off.
+func (s *ParserService) SetSceneSimilarityThreshold(threshold float64) {
+	s.sceneSimilarityThreshold = threshold
+}
+
 // GenerateScript creates a new ad script using GPT-4o
 func (s *ParserService) GenerateScript(ctx context.Context, req ParseRequest) (*domain.Script, error) {
 	s.logger.Info("Generating script with GPT-4o",
@@ -67,6 +94,93 @@ func (s *ParserService) GenerateScript(ctx context.Context, req ParseRequest) (*
 		return nil, fmt.Errorf("invalid request: %w", err)
 	}
 
+	gpt4oReq := s.scriptRequest(req)
+	script, err := s.gpt4o.GenerateScript(ctx, gpt4oReq)
+	if err != nil {
+		return nil, fmt.Errorf("GPT-4o generation failed: %w", err)
+	}
+
+	// Adjacent scenes that describe the same shot play as a stutter; ask once for a rewrite
+	// that names them, and keep the rewrite unless it repeats more
+	if repeats := s.RepeatedScenes(script); len(repeats) > 0 {
+		s.logger.Warn("Script has near-duplicate adjacent scenes, retrying with a differentiation hint",
+			zap.Int("num_pairs", len(repeats)))
+
+		retryReq := *gpt4oReq
+		retryReq.RepeatedScenes = repeatedScenesHint(repeats)
+		retried, err := s.gpt4o.GenerateScript(ctx, &retryReq)
+		switch {
+		case err != nil:
+			s.logger.Warn("Script retry failed, keeping the first draft", zap.Error(err))
+		case len(s.RepeatedScenes(retried)) > len(repeats):
+			s.logger.Warn("Script retry repeated more scenes, keeping the first draft")
+		default:
+			script = retried
+		}
+	}
+
+	// Script will be embedded in Job - no separate persistence needed
+	s.logger.Info("Script generated successfully",
+		zap.Int("num_scenes", len(script.Scenes)),
+		zap.String("title", script.Title))
+
+	return script, nil
+}
+
+// GenerateScriptVariants creates count distinct scripts for the same request in one GPT-4o call,
+// for A/B variant jobs
+func (s *ParserService) GenerateScriptVariants(ctx context.Context, req ParseRequest, count int) ([]*domain.Script, error) {
+	s.logger.Info("Generating script variants with GPT-4o",
+		zap.String("user_id", req.UserID),
+		zap.Int("duration", req.Duration),
+		zap.Int("variants", count))
+
+	if err := s.validateParseRequest(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	gpt4oReq := s.scriptRequest(req)
+	gpt4oReq.Variants = count
+	scripts, err := s.gpt4o.GenerateScriptVariants(ctx, gpt4oReq)
+	if err != nil {
+		return nil, fmt.Errorf("GPT-4o generation failed: %w", err)
+	}
+	if len(scripts) != count {
+		return nil, fmt.Errorf("GPT-4o generation failed: expected %d script variants, got %d", count, len(scripts))
+	}
+
+	for i, script := range scripts {
+		s.logger.Info("Script variant generated successfully",
+			zap.Int("variant", i+1),
+			zap.Int("num_scenes", len(script.Scenes)),
+			zap.String("title", script.Title))
+	}
+	return scripts, nil
+}
+
+// RepeatedScenes returns the adjacent scenes of script whose action and generation prompt are
+// at least the configured threshold similar, earliest first
+func (s *ParserService) RepeatedScenes(script *domain.Script) []similarity.Pair {
+	texts := make([]string, len(script.Scenes))
+	for i, scene := range script.Scenes {
+		// The style description appended to every prompt is the same in each scene
+		prompt, _, _ := strings.Cut(scene.GenerationPrompt, ". Style: ")
+		texts[i] = scene.Action + " " + prompt
+	}
+	return similarity.AdjacentDuplicates(texts, s.sceneSimilarityThreshold)
+}
+
+// repeatedScenesHint names repeated scene pairs by scene number for the retry prompt
+func repeatedScenesHint(pairs []similarity.Pair) []prompts.RepeatedScenes {
+	hint := make([]prompts.RepeatedScenes, len(pairs))
+	for i, pair := range pairs {
+		hint[i] = prompts.RepeatedScenes{First: pair.First + 1, Second: pair.First + 2, Similarity: pair.Similarity}
+	}
+	return hint
+}
+
+// scriptRequest maps a parse request onto the GPT-4o adapter's input
+func (s *ParserService) scriptRequest(req ParseRequest) *adapters.ScriptGenerationRequest {
 	// Build enhanced prompt options if any are provided
 	var enhancedOptions *prompts.EnhancedPromptOptions
 	if req.Style != "" || req.Tone != "" || req.Tempo != "" || req.Platform != "" ||
@@ -84,29 +198,53 @@ func (s *ParserService) GenerateScript(ctx context.Context, req ParseRequest) (*
 		}
 	}
 
-	// Call GPT-4o adapter - GPT-4o will extract product info from prompt
-	gpt4oReq := &adapters.ScriptGenerationRequest{
+	// Logo assets double as the style reference when the user did not supply one
+	styleReferenceImage := req.StyleReferenceImage
+	var brandGuidelines *prompts.BrandGuidelines
+	if g := req.BrandGuidelines; g != nil {
+		brandGuidelines = &prompts.BrandGuidelines{
+			Name:       g.Name,
+			Colors:     g.Colors,
+			Typography: g.Typography,
+			BrandVoice: g.BrandVoice,
+			VideoStyle: g.VideoStyle,
+			LogoURLs:   g.LogoURLs,
+		}
+		if styleReferenceImage == "" && len(g.LogoURLs) > 0 {
+			styleReferenceImage = g.LogoURLs[0]
+		}
+	}
+
+	var productImages []prompts.ProductImage
+	for _, image := range req.ProductImages {
+		productImages = append(productImages, prompts.ProductImage{Ref: image.Ref, Hint: image.Hint})
+	}
+
+	var scenePlan []prompts.PlannedScene
+	for _, scene := range req.ScenePlan {
+		scenePlan = append(scenePlan, prompts.PlannedScene{Duration: scene.Duration, Hint: scene.Hint})
+	}
+
+	// GPT-4o will extract product info from prompt
+	return &adapters.ScriptGenerationRequest{
 		Prompt:              req.Prompt,
 		Duration:            req.Duration,
 		AspectRatio:         req.AspectRatio,
 		StartImage:          req.StartImage,
-		StyleReferenceImage: req.StyleReferenceImage,
+		StyleReferenceImage: styleReferenceImage,
 		Voice:               req.Voice,
 		SideEffects:         req.SideEffects,
 		EnhancedOptions:     enhancedOptions,
+		VideoModel:          req.VideoModel,
+		BrandGuidelines:     brandGuidelines,
+		ProductImages:       productImages,
+		ScenePlan:           scenePlan,
+		Language: prompts.Language{
+			Tag:            req.Language,
+			Name:           domain.LanguageName(req.Language),
+			WordsPerSecond: req.NarrationWordsPerSecond,
+		},
 	}
-
-	script, err := s.gpt4o.GenerateScript(ctx, gpt4oReq)
-	if err != nil {
-		return nil, fmt.Errorf("GPT-4o generation failed: %w", err)
-	}
-
-	// Script will be embedded in Job - no separate persistence needed
-	s.logger.Info("Script generated successfully",
-		zap.Int("num_scenes", len(script.Scenes)),
-		zap.String("title", script.Title))
-
-	return script, nil
 }
 
 // validateParseRequest validates the parse request
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// sequenceScriptGenerator returns its results in order, one per GenerateScript call, and
+// records each request
+type sequenceScriptGenerator struct {
+	results []scriptResult
+	reqs    []*adapters.ScriptGenerationRequest
+}
+
+type scriptResult struct {
+	script *domain.Script
+	err    error
+}
+
+func (g *sequenceScriptGenerator) GenerateScript(ctx context.Context, req *adapters.ScriptGenerationRequest) (*domain.Script, error) {
+	result := g.results[len(g.reqs)]
+	g.reqs = append(g.reqs, req)
+	return result.script, result.err
+}
+
+func (g *sequenceScriptGenerator) GenerateScriptVariants(ctx context.Context, req *adapters.ScriptGenerationRequest) ([]*domain.Script, error) {
+	script, err := g.GenerateScript(ctx, req)
+	return []*domain.Script{script}, err
+}
+
+func (g *sequenceScriptGenerator) AnalyzeStyleReference(ctx context.Context, imageURL string) (string, error) {
+	return "", nil
+}
+
+// stutteringScript repeats its second scene as its third, as GPT-4o drafts sometimes do
+func stutteringScript() *domain.Script {
+	return scriptWithScenes(
+		"Maria winces as she reaches for a coffee mug before dawn",
+		"Maria stands at the kitchen counter in the morning, slowly pouring coffee into her mug",
+		"Maria stands at the kitchen counter in the morning, carefully pouring coffee into her mug",
+		"Maria laughs as she teaches her granddaughter to plant tomatoes",
+	)
+}
+
+// progressingScript is stutteringScript with its third scene rewritten
+func progressingScript() *domain.Script {
+	return scriptWithScenes(
+		"Maria winces as she reaches for a coffee mug before dawn",
+		"Maria stands at the kitchen counter in the morning, slowly pouring coffee into her mug",
+		"Maria strolls through a sunny farmers market at noon, lifting a heavy basket with ease",
+		"Maria laughs as she teaches her granddaughter to plant tomatoes",
+	)
+}
+
+func scriptWithScenes(actions ...string) *domain.Script {
+	script := &domain.Script{Title: "Restura"}
+	for i, action := range actions {
+		script.Scenes = append(script.Scenes, domain.Scene{
+			SceneNumber:      i + 1,
+			Duration:         8,
+			Action:           action,
+			GenerationPrompt: action + ". Style: soft film grain, warm palette",
+		})
+	}
+	return script
+}
+
+func scriptParseRequest() ParseRequest {
+	return ParseRequest{UserID: "user-1", Prompt: "Restura arthritis ad", Duration: 32, AspectRatio: "16:9"}
+}
+
+func TestGenerateScript_RetriesRepeatedScenes(t *testing.T) {
+	corrected := progressingScript()
+	generator := &sequenceScriptGenerator{results: []scriptResult{{script: stutteringScript()}, {script: corrected}}}
+	parser := NewParserService(generator, zap.NewNop())
+
+	script, err := parser.GenerateScript(context.Background(), scriptParseRequest())
+	if err != nil {
+		t.Fatalf("GenerateScript: %v", err)
+	}
+	if script != corrected {
+		t.Error("GenerateScript should return the corrected retry")
+	}
+	if len(generator.reqs) != 2 {
+		t.Fatalf("GPT-4o calls = %d, want 2", len(generator.reqs))
+	}
+	if len(generator.reqs[0].RepeatedScenes) != 0 {
+		t.Errorf("first request should carry no repeated scenes, got %+v", generator.reqs[0].RepeatedScenes)
+	}
+	hint := generator.reqs[1].RepeatedScenes
+	if len(hint) != 1 || hint[0].First != 2 || hint[0].Second != 3 || hint[0].Similarity < parser.sceneSimilarityThreshold {
+		t.Errorf("retry should name scenes 2 and 3, got %+v", hint)
+	}
+	if generator.reqs[1].Prompt != generator.reqs[0].Prompt {
+		t.Error("retry should keep the original brief")
+	}
+	if pairs := parser.RepeatedScenes(script); len(pairs) != 0 {
+		t.Errorf("corrected script still repeats %+v", pairs)
+	}
+}
+
+func TestGenerateScript_RetryStillRepeating(t *testing.T) {
+	retried := stutteringScript()
+	generator := &sequenceScriptGenerator{results: []scriptResult{{script: stutteringScript()}, {script: retried}}}
+	parser := NewParserService(generator, zap.NewNop())
+
+	script, err := parser.GenerateScript(context.Background(), scriptParseRequest())
+	if err != nil {
+		t.Fatalf("GenerateScript: %v", err)
+	}
+	if script != retried {
+		t.Error("GenerateScript should proceed with the retry when it repeats no more than the first draft")
+	}
+	if len(generator.reqs) != 2 {
+		t.Errorf("GPT-4o calls = %d, want a single retry", len(generator.reqs))
+	}
+	if pairs := parser.RepeatedScenes(script); len(pairs) != 1 || pairs[0].First != 1 {
+		t.Errorf("RepeatedScenes = %+v, want scenes 2 and 3 for the job warning", pairs)
+	}
+}
+
+func TestGenerateScript_RetryFailureKeepsFirstDraft(t *testing.T) {
+	first := stutteringScript()
+	generator := &sequenceScriptGenerator{results: []scriptResult{{script: first}, {err: errors.New("replicate timed out")}}}
+	parser := NewParserService(generator, zap.NewNop())
+
+	script, err := parser.GenerateScript(context.Background(), scriptParseRequest())
+	if err != nil {
+		t.Fatalf("GenerateScript: %v", err)
+	}
+	if script != first {
+		t.Error("GenerateScript should keep the first draft when the retry fails")
+	}
+}
+
+func TestGenerateScript_NoRetryWithoutRepeats(t *testing.T) {
+	generator := &sequenceScriptGenerator{results: []scriptResult{{script: progressingScript()}}}
+	parser := NewParserService(generator, zap.NewNop())
+
+	if _, err := parser.GenerateScript(context.Background(), scriptParseRequest()); err != nil {
+		t.Fatalf("GenerateScript: %v", err)
+	}
+	if len(generator.reqs) != 1 {
+		t.Errorf("GPT-4o calls = %d, want 1", len(generator.reqs))
+	}
+}
+
+func TestGenerateScript_SimilarityCheckDisabled(t *testing.T) {
+	generator := &sequenceScriptGenerator{results: []scriptResult{{script: stutteringScript()}}}
+	parser := NewParserService(generator, zap.NewNop())
+	parser.SetSceneSimilarityThreshold(0)
+
+	if _, err := parser.GenerateScript(context.Background(), scriptParseRequest()); err != nil {
+		t.Fatalf("GenerateScript: %v", err)
+	}
+	if len(generator.reqs) != 1 {
+		t.Errorf("GPT-4o calls = %d, want 1 with the check off", len(generator.reqs))
+	}
+}
+
+func TestRepeatedScenesIgnoresStyleSuffix(t *testing.T) {
+	// Distinct scenes share the long style description; it must not make them look alike
+	script := scriptWithScenes("Maria winces at dawn", "James jogs along the river")
+	for i := range script.Scenes {
+		script.Scenes[i].GenerationPrompt += " with cinematic anamorphic flares, teal and orange grade, 35mm film texture, shallow depth of field"
+	}
+	parser := NewParserService(nil, zap.NewNop())
+	if pairs := parser.RepeatedScenes(script); len(pairs) != 0 {
+		t.Errorf("RepeatedScenes = %+v, want none", pairs)
+	}
+}
@@ -5,35 +5,95 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/omnigen/backend/internal/adapters"
 	"go.uber.org/zap"
 )
 
-// SecretsService handles Secrets Manager operations
+const (
+	// DefaultSecretTTL is how long a fetched secret is served before it is fetched again
+	DefaultSecretTTL = 5 * time.Minute
+
+	// secretRejectRefreshInterval is the minimum time between re-fetches forced by a provider
+	// rejecting a key, so a key that is simply wrong doesn't send every request to Secrets Manager
+	secretRejectRefreshInterval = 30 * time.Second
+
+	// secretRetryInterval is how long the previous value keeps being served after a
+	// failed refresh before the refresh is tried again
+	secretRetryInterval = 30 * time.Second
+)
+
+// secretsManagerAPI is the subset of the Secrets Manager client used by the service
+type secretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// secretValue is one fetched value of a secret
+type secretValue struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// cachedSecret holds the current value of one secret. Readers load it without locking and
+// refreshes swap in a new value; mu lets only one caller fetch at a time.
+type cachedSecret struct {
+	id      string
+	current atomic.Pointer[secretValue]
+	mu      sync.Mutex
+}
+
+// SecretsService handles Secrets Manager operations. Values are cached for a TTL and
+// re-fetched when it expires or when a provider rejects an API key, so rotating a secret
+// doesn't need a restart.
 type SecretsService struct {
-	client             *secretsmanager.Client
+	client             secretsManagerAPI
 	replicateSecretARN string
 	openaiSecretARN    string
+	ttl                time.Duration
 	logger             *zap.Logger
+
+	apiKeys   *cachedSecret
+	replicate *cachedSecret
+	openai    *cachedSecret
+
+	// rejectRefreshInterval is secretRejectRefreshInterval outside tests
+	rejectRefreshInterval time.Duration
 }
 
-// NewSecretsService creates a new Secrets Manager service
+// NewSecretsService creates a new Secrets Manager service that caches values for ttl;
+// zero uses DefaultSecretTTL
 func NewSecretsService(
-	client *secretsmanager.Client,
+	client secretsManagerAPI,
 	replicateSecretARN string,
 	openaiSecretARN string,
+	ttl time.Duration,
 	logger *zap.Logger,
 ) *SecretsService {
+	if ttl <= 0 {
+		ttl = DefaultSecretTTL
+	}
 	return &SecretsService{
-		client:             client,
-		replicateSecretARN: replicateSecretARN,
-		openaiSecretARN:    openaiSecretARN,
-		logger:             logger,
+		client:                client,
+		replicateSecretARN:    replicateSecretARN,
+		openaiSecretARN:       openaiSecretARN,
+		ttl:                   ttl,
+		logger:                logger,
+		apiKeys:               &cachedSecret{id: apiKeysSecretName},
+		replicate:             &cachedSecret{id: replicateSecretARN},
+		openai:                &cachedSecret{id: openaiSecretARN},
+		rejectRefreshInterval: secretRejectRefreshInterval,
 	}
 }
 
+// For MVP, we'll use a hardcoded secret name for API keys
+// In production, this should be configurable
+const apiKeysSecretName = "omnigen/api-keys"
+
 // APIKeysSecret represents the structure of API keys in Secrets Manager
 type APIKeysSecret struct {
 	APIKeys []string `json:"api_keys"`
@@ -41,17 +101,7 @@ type APIKeysSecret struct {
 
 // GetAPIKeys retrieves API keys from Secrets Manager
 func (s *SecretsService) GetAPIKeys(ctx context.Context) ([]string, error) {
-	// For MVP, we'll use a hardcoded secret name for API keys
-	// In production, this should be configurable
-	secretName := "omnigen/api-keys"
-
-	s.logger.Info("Retrieving API keys from Secrets Manager",
-		zap.String("secret_name", secretName),
-	)
-
-	result, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
-	})
+	value, err := s.get(ctx, s.apiKeys)
 	if err != nil {
 		s.logger.Warn("Failed to retrieve API keys from Secrets Manager, using default",
 			zap.Error(err),
@@ -61,7 +111,7 @@ func (s *SecretsService) GetAPIKeys(ctx context.Context) ([]string, error) {
 	}
 
 	var secret APIKeysSecret
-	if err := json.Unmarshal([]byte(*result.SecretString), &secret); err != nil {
+	if err := json.Unmarshal([]byte(value), &secret); err != nil {
 		s.logger.Error("Failed to unmarshal secret", zap.Error(err))
 		return nil, fmt.Errorf("failed to unmarshal secret: %w", err)
 	}
@@ -83,19 +133,12 @@ func (s *SecretsService) GetReplicateAPIKey(ctx context.Context) (string, error)
 		return "", fmt.Errorf("REPLICATE_API_KEY environment variable not set and REPLICATE_SECRET_ARN not configured")
 	}
 
-	s.logger.Info("Retrieving Replicate API key from Secrets Manager",
-		zap.String("secret_arn", s.replicateSecretARN),
-	)
-
-	result, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(s.replicateSecretARN),
-	})
+	apiKey, err := s.get(ctx, s.replicate)
 	if err != nil {
 		s.logger.Error("Failed to retrieve Replicate API key", zap.Error(err))
 		return "", fmt.Errorf("failed to retrieve replicate API key: %w", err)
 	}
-
-	return *result.SecretString, nil
+	return apiKey, nil
 }
 
 // GetOpenAIAPIKey retrieves the OpenAI API key
@@ -111,17 +154,103 @@ func (s *SecretsService) GetOpenAIAPIKey(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("OPENAI_API_KEY environment variable not set and OPENAI_SECRET_ARN not configured")
 	}
 
-	s.logger.Info("Retrieving OpenAI API key from Secrets Manager",
-		zap.String("secret_arn", s.openaiSecretARN),
-	)
+	apiKey, err := s.get(ctx, s.openai)
+	if err != nil {
+		s.logger.Error("Failed to retrieve OpenAI API key", zap.Error(err))
+		return "", fmt.Errorf("failed to retrieve OpenAI API key: %w", err)
+	}
+	return apiKey, nil
+}
 
+// ReplicateTokenSource returns the Replicate API key as a token source that follows
+// rotations of the secret. A REPLICATE_API_KEY environment variable is used as-is.
+func (s *SecretsService) ReplicateTokenSource() adapters.TokenSource {
+	if apiKey := os.Getenv("REPLICATE_API_KEY"); apiKey != "" || s.replicateSecretARN == "" {
+		return adapters.StaticToken(apiKey)
+	}
+	return &secretTokenSource{service: s, secret: s.replicate}
+}
+
+// OpenAITokenSource returns the OpenAI API key as a token source that follows rotations of
+// the secret. An OPENAI_API_KEY environment variable is used as-is.
+func (s *SecretsService) OpenAITokenSource() adapters.TokenSource {
+	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" || s.openaiSecretARN == "" {
+		return adapters.StaticToken(apiKey)
+	}
+	return &secretTokenSource{service: s, secret: s.openai}
+}
+
+// get returns secret's cached value, fetching it when there is none or it is older than the TTL
+func (s *SecretsService) get(ctx context.Context, secret *cachedSecret) (string, error) {
+	if cached := secret.current.Load(); cached != nil && time.Since(cached.fetchedAt) < s.ttl {
+		return cached.value, nil
+	}
+	return s.refresh(ctx, secret, func(cached *secretValue) bool {
+		return time.Since(cached.fetchedAt) >= s.ttl
+	})
+}
+
+// rejected re-fetches secret after a provider rejected token, reporting whether a different
+// value is now available
+func (s *SecretsService) rejected(ctx context.Context, secret *cachedSecret, token string) bool {
+	if cached := secret.current.Load(); cached != nil && cached.value != token {
+		// Another request already picked up the rotated value
+		return true
+	}
+	value, err := s.refresh(ctx, secret, func(cached *secretValue) bool {
+		return cached.value == token && time.Since(cached.fetchedAt) >= s.rejectRefreshInterval
+	})
+	return err == nil && value != token
+}
+
+// refresh fetches secret from Secrets Manager. Callers queue on the secret's lock, and
+// stale decides whether the value a caller ahead of them stored still needs replacing. If
+// the fetch fails and there is a previous value, it keeps being served.
+func (s *SecretsService) refresh(ctx context.Context, secret *cachedSecret, stale func(*secretValue) bool) (string, error) {
+	secret.mu.Lock()
+	defer secret.mu.Unlock()
+
+	cached := secret.current.Load()
+	if cached != nil && !stale(cached) {
+		return cached.value, nil
+	}
+
+	s.logger.Info("Retrieving secret from Secrets Manager", zap.String("secret_id", secret.id))
 	result, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(s.openaiSecretARN),
+		SecretId: aws.String(secret.id),
 	})
 	if err != nil {
-		s.logger.Error("Failed to retrieve OpenAI API key", zap.Error(err))
-		return "", fmt.Errorf("failed to retrieve OpenAI API key: %w", err)
+		if cached == nil {
+			return "", err
+		}
+		s.logger.Warn("Failed to refresh secret, serving the cached value",
+			zap.String("secret_id", secret.id),
+			zap.Error(err),
+		)
+		secret.current.Store(&secretValue{value: cached.value, fetchedAt: time.Now().Add(secretRetryInterval - s.ttl)})
+		return cached.value, nil
 	}
 
-	return *result.SecretString, nil
+	value := aws.ToString(result.SecretString)
+	if cached != nil && cached.value != value {
+		s.logger.Info("Secret rotated", zap.String("secret_id", secret.id))
+	}
+	secret.current.Store(&secretValue{value: value, fetchedAt: time.Now()})
+	return value, nil
+}
+
+// secretTokenSource serves an API key from the secrets cache
+type secretTokenSource struct {
+	service *SecretsService
+	secret  *cachedSecret
+}
+
+// Token returns the cached key, fetching it if the TTL has expired
+func (t *secretTokenSource) Token(ctx context.Context) (string, error) {
+	return t.service.get(ctx, t.secret)
+}
+
+// TokenRejected re-fetches the key after a provider answered 401 to token
+func (t *secretTokenSource) TokenRejected(ctx context.Context, token string) bool {
+	return t.service.rejected(ctx, t.secret, token)
 }
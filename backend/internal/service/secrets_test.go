@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"go.uber.org/zap"
+)
+
+// fakeSecretsManager serves one value per secret ID, counting fetches
+type fakeSecretsManager struct {
+	mu      sync.Mutex
+	values  map[string]string
+	err     error
+	fetches int
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(f.values[*params.SecretId])}, nil
+}
+
+// rotate replaces the value of id
+func (f *fakeSecretsManager) rotate(id, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[id] = value
+}
+
+func (f *fakeSecretsManager) fetchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+const testReplicateARN = "arn:aws:secretsmanager:us-east-1:123456789012:secret:replicate"
+
+func newTestSecretsService(t *testing.T, ttl time.Duration) (*SecretsService, *fakeSecretsManager) {
+	t.Setenv("REPLICATE_API_KEY", "")
+	client := &fakeSecretsManager{values: map[string]string{testReplicateARN: "r8_old"}}
+	return NewSecretsService(client, testReplicateARN, "", ttl, zap.NewNop()), client
+}
+
+func TestSecretsService_CachesUntilTTL(t *testing.T) {
+	s, client := newTestSecretsService(t, 50*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		key, err := s.GetReplicateAPIKey(ctx)
+		if err != nil || key != "r8_old" {
+			t.Fatalf("GetReplicateAPIKey() = %q, %v", key, err)
+		}
+	}
+	if client.fetchCount() != 1 {
+		t.Errorf("fetches = %d, want 1 within the TTL", client.fetchCount())
+	}
+
+	client.rotate(testReplicateARN, "r8_new")
+	time.Sleep(60 * time.Millisecond)
+	key, err := s.ReplicateTokenSource().Token(ctx)
+	if err != nil || key != "r8_new" {
+		t.Errorf("Token() after TTL = %q, %v; want the rotated key", key, err)
+	}
+	if client.fetchCount() != 2 {
+		t.Errorf("fetches = %d, want 2", client.fetchCount())
+	}
+}
+
+func TestSecretsService_RotationMidTraffic(t *testing.T) {
+	s, client := newTestSecretsService(t, time.Hour)
+	s.rejectRefreshInterval = 0
+	tokens := s.ReplicateTokenSource()
+	ctx := context.Background()
+
+	if _, err := tokens.Token(ctx); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	// The key is rotated while requests carrying the old one are in flight; every one of
+	// them gets a 401
+	client.rotate(testReplicateARN, "r8_new")
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	retried := make(chan bool, 30)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			retried <- tokens.TokenRejected(ctx, "r8_old")
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(retried)
+	for ok := range retried {
+		if !ok {
+			t.Fatal("TokenRejected() = false, want true once the key was rotated")
+		}
+	}
+	if client.fetchCount() != 2 {
+		t.Errorf("fetches = %d, want one refresh shared by all rejected requests", client.fetchCount())
+	}
+	if key, _ := tokens.Token(ctx); key != "r8_new" {
+		t.Errorf("Token() = %q, want r8_new", key)
+	}
+}
+
+func TestSecretsService_RejectedKeyThatWasNotRotated(t *testing.T) {
+	s, client := newTestSecretsService(t, time.Hour)
+	tokens := s.ReplicateTokenSource()
+	ctx := context.Background()
+
+	if _, err := tokens.Token(ctx); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	// The key was fetched moments ago, so a 401 doesn't send every request to Secrets Manager
+	for i := 0; i < 5; i++ {
+		if tokens.TokenRejected(ctx, "r8_old") {
+			t.Fatal("TokenRejected() = true, but there is no other key")
+		}
+	}
+	if client.fetchCount() != 1 {
+		t.Errorf("fetches = %d, want 1", client.fetchCount())
+	}
+}
+
+func TestSecretsService_FailedRefreshServesCachedValue(t *testing.T) {
+	s, client := newTestSecretsService(t, 10*time.Millisecond)
+	ctx := context.Background()
+
+	if _, err := s.GetReplicateAPIKey(ctx); err != nil {
+		t.Fatalf("GetReplicateAPIKey() error = %v", err)
+	}
+	client.mu.Lock()
+	client.err = errors.New("throttled")
+	client.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+
+	key, err := s.GetReplicateAPIKey(ctx)
+	if err != nil || key != "r8_old" {
+		t.Errorf("GetReplicateAPIKey() = %q, %v; want the cached key", key, err)
+	}
+
+	// Without a cached value the error is returned
+	fresh, failing := newTestSecretsService(t, time.Hour)
+	failing.err = errors.New("access denied")
+	if _, err := fresh.GetReplicateAPIKey(ctx); err == nil {
+		t.Error("GetReplicateAPIKey() expected error, got nil")
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+)
+
+// CreditStore provides the usage table operations needed to charge and refund jobs
+type CreditStore interface {
+	GetOrCreateUsage(ctx context.Context, userID, subscriptionTier string) (*domain.Usage, error)
+	ChargeCredits(ctx context.Context, userID, subscriptionTier string, charge domain.CreditCharge) (*domain.Usage, error)
+	RefundCredits(ctx context.Context, userID, period, jobID string, credits int) error
+	ListCharges(ctx context.Context, userID, period string) ([]domain.CreditCharge, error)
+}
+
+// UsageSummary is a user's credit balance and this period's charges, newest first
+type UsageSummary struct {
+	Period           string                `json:"period"` // YYYY-MM
+	MonthlyCredits   int                   `json:"monthly_credits"`
+	CreditsRemaining int                   `json:"credits_remaining"`
+	CreditsUsed      int                   `json:"credits_used"` // Net of refunds
+	Jobs             []domain.CreditCharge `json:"jobs"`
+}
+
+// UsageService prices generation jobs and keeps the user's credit balance in step with them
+type UsageService struct {
+	store  CreditStore
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewUsageService creates a new usage service
+func NewUsageService(store CreditStore, logger *zap.Logger) *UsageService {
+	return &UsageService{
+		store:  store,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// RefundAmount is what a failed job gets back: everything when no scene was generated,
+// otherwise the share of the charge covering the scenes that were never generated
+func RefundAmount(charged, totalScenes, scenesCompleted int) int {
+	if charged <= 0 {
+		return 0
+	}
+	if scenesCompleted <= 0 || totalScenes <= 0 {
+		return charged
+	}
+	if scenesCompleted >= totalScenes {
+		return 0
+	}
+	return charged * (totalScenes - scenesCompleted) / totalScenes
+}
+
+// ChargeJob takes the job's cost from the user's balance and records the charge on the job.
+// It returns a *repository.InsufficientCreditsError when the balance is too low.
+func (s *UsageService) ChargeJob(ctx context.Context, job *domain.Job, subscriptionTier string, cost pricing.JobCost) (*domain.Usage, error) {
+	usage, err := s.store.ChargeCredits(ctx, job.UserID, subscriptionTier, domain.CreditCharge{
+		JobID:     job.JobID,
+		Model:     cost.Model,
+		Duration:  cost.Duration,
+		Scenes:    cost.Scenes,
+		Credits:   cost.Credits,
+		ChargedAt: s.now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job.CreditsCharged = cost.Credits
+	job.CreditPeriod = usage.Period
+	return usage, nil
+}
+
+// RefundJob returns credits for a failed job based on how many scenes it generated.
+// Refund failures are logged rather than returned so they never mask the job's own error.
+func (s *UsageService) RefundJob(ctx context.Context, job *domain.Job) {
+	if job.CreditsCharged <= 0 || job.CreditPeriod == "" {
+		return
+	}
+
+	refund := RefundAmount(job.CreditsCharged, len(job.Scenes), job.ScenesCompleted)
+	if refund == 0 {
+		return
+	}
+
+	err := s.store.RefundCredits(ctx, job.UserID, job.CreditPeriod, job.JobID, refund)
+	if errors.Is(err, repository.ErrChargeNotRefundable) {
+		s.logger.Warn("Job credits already refunded",
+			zap.String("job_id", job.JobID),
+			zap.Int("refund", refund),
+		)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to refund job credits",
+			zap.String("job_id", job.JobID),
+			zap.String("user_id", job.UserID),
+			zap.Int("refund", refund),
+			zap.Error(err),
+		)
+		return
+	}
+
+	s.logger.Info("Refunded job credits",
+		zap.String("job_id", job.JobID),
+		zap.Int("charged", job.CreditsCharged),
+		zap.Int("refund", refund),
+		zap.Int("scenes_completed", job.ScenesCompleted),
+		zap.Int("total_scenes", len(job.Scenes)),
+	)
+}
+
+// Summary returns the user's balance, this period's consumption and per-job charges
+func (s *UsageService) Summary(ctx context.Context, userID, subscriptionTier string) (*UsageSummary, error) {
+	usage, err := s.store.GetOrCreateUsage(ctx, userID, subscriptionTier)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.store.ListCharges(ctx, userID, usage.Period)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].ChargedAt != jobs[j].ChargedAt {
+			return jobs[i].ChargedAt > jobs[j].ChargedAt
+		}
+		return jobs[i].JobID < jobs[j].JobID
+	})
+
+	return &UsageSummary{
+		Period:           usage.Period,
+		MonthlyCredits:   usage.MonthlyCredits,
+		CreditsRemaining: usage.CreditsRemaining,
+		CreditsUsed:      usage.CreditsUsed,
+		Jobs:             jobs,
+	}, nil
+}
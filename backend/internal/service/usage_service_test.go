@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/pricing"
+	"github.com/omnigen/backend/internal/repository"
+)
+
+// recordingCreditStore records charges and refunds
+type recordingCreditStore struct {
+	usage     *domain.Usage
+	listed    []domain.CreditCharge // What ListCharges returns
+	chargeErr error
+	refundErr error
+	charges   []domain.CreditCharge
+	refunds   []int
+}
+
+func (s *recordingCreditStore) GetOrCreateUsage(ctx context.Context, userID, subscriptionTier string) (*domain.Usage, error) {
+	return s.usage, nil
+}
+
+func (s *recordingCreditStore) ChargeCredits(ctx context.Context, userID, subscriptionTier string, charge domain.CreditCharge) (*domain.Usage, error) {
+	if s.chargeErr != nil {
+		return nil, s.chargeErr
+	}
+	s.charges = append(s.charges, charge)
+	return s.usage, nil
+}
+
+func (s *recordingCreditStore) RefundCredits(ctx context.Context, userID, period, jobID string, credits int) error {
+	if s.refundErr != nil {
+		return s.refundErr
+	}
+	s.refunds = append(s.refunds, credits)
+	return nil
+}
+
+func (s *recordingCreditStore) ListCharges(ctx context.Context, userID, period string) ([]domain.CreditCharge, error) {
+	return s.listed, nil
+}
+
+func TestRefundAmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		charged   int
+		total     int
+		completed int
+		want      int
+	}{
+		{name: "failed before script", charged: 80, total: 0, completed: 0, want: 80},
+		{name: "failed at first scene", charged: 80, total: 4, completed: 0, want: 80},
+		{name: "failed after one of four scenes", charged: 80, total: 4, completed: 1, want: 60},
+		{name: "rounds down", charged: 45, total: 3, completed: 2, want: 15},
+		{name: "odd split rounds down", charged: 50, total: 3, completed: 1, want: 33},
+		{name: "failed after every scene", charged: 80, total: 4, completed: 4, want: 0},
+		{name: "nothing charged", charged: 0, total: 4, completed: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := RefundAmount(tt.charged, tt.total, tt.completed); got != tt.want {
+			t.Errorf("%s: RefundAmount(%d, %d, %d) = %d, want %d", tt.name, tt.charged, tt.total, tt.completed, got, tt.want)
+		}
+	}
+}
+
+func TestChargeJobRecordsChargeOnJob(t *testing.T) {
+	store := &recordingCreditStore{usage: &domain.Usage{Period: "2025-01", CreditsRemaining: 920}}
+	svc := NewUsageService(store, zap.NewNop())
+	svc.now = func() time.Time { return time.Unix(1735689600, 0) }
+
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	if _, err := svc.ChargeJob(context.Background(), job, "free", pricing.QuoteJob(30, 4, adapters.AdapterTypeVeo)); err != nil {
+		t.Fatalf("ChargeJob: %v", err)
+	}
+
+	if job.CreditsCharged != 80 || job.CreditPeriod != "2025-01" {
+		t.Errorf("job charge = %d in %q, want 80 in 2025-01", job.CreditsCharged, job.CreditPeriod)
+	}
+	want := domain.CreditCharge{JobID: "job-1", Model: "veo", Duration: 30, Scenes: 4, Credits: 80, ChargedAt: 1735689600}
+	if len(store.charges) != 1 || store.charges[0] != want {
+		t.Errorf("charges = %+v, want [%+v]", store.charges, want)
+	}
+}
+
+func TestChargeJobLeavesJobUnchargedOnInsufficientCredits(t *testing.T) {
+	store := &recordingCreditStore{chargeErr: &repository.InsufficientCreditsError{Required: 80, Remaining: 10}}
+	svc := NewUsageService(store, zap.NewNop())
+
+	job := &domain.Job{JobID: "job-1", UserID: "user-1"}
+	_, err := svc.ChargeJob(context.Background(), job, "free", pricing.QuoteJob(30, 4, adapters.AdapterTypeVeo))
+	if !errors.Is(err, repository.ErrInsufficientCredits) {
+		t.Fatalf("err = %v, want ErrInsufficientCredits", err)
+	}
+	if job.CreditsCharged != 0 || job.CreditPeriod != "" {
+		t.Errorf("job charge = %d in %q, want none", job.CreditsCharged, job.CreditPeriod)
+	}
+}
+
+func TestRefundJob(t *testing.T) {
+	newJob := func(completed int) *domain.Job {
+		return &domain.Job{
+			JobID:           "job-1",
+			UserID:          "user-1",
+			CreditsCharged:  80,
+			CreditPeriod:    "2025-01",
+			Scenes:          make([]domain.Scene, 4),
+			ScenesCompleted: completed,
+		}
+	}
+
+	store := &recordingCreditStore{}
+	svc := NewUsageService(store, zap.NewNop())
+	svc.RefundJob(context.Background(), newJob(0))
+	svc.RefundJob(context.Background(), newJob(3))
+	svc.RefundJob(context.Background(), newJob(4))                   // Every scene generated: nothing to refund
+	svc.RefundJob(context.Background(), &domain.Job{JobID: "job-2"}) // Never charged
+	if len(store.refunds) != 2 || store.refunds[0] != 80 || store.refunds[1] != 20 {
+		t.Errorf("refunds = %v, want [80 20]", store.refunds)
+	}
+
+	// A duplicate refund is logged, not propagated
+	store.refundErr = repository.ErrChargeNotRefundable
+	svc.RefundJob(context.Background(), newJob(0))
+}
+
+func TestUsageSummaryListsNewestChargesFirst(t *testing.T) {
+	store := &recordingCreditStore{usage: &domain.Usage{
+		Period:           "2025-01",
+		MonthlyCredits:   1000,
+		CreditsRemaining: 860,
+		CreditsUsed:      140,
+	}, listed: []domain.CreditCharge{
+		{JobID: "job-old", Credits: 80, ChargedAt: 100},
+		{JobID: "job-new", Credits: 80, Refunded: 20, ChargedAt: 200},
+	}}
+
+	summary, err := NewUsageService(store, zap.NewNop()).Summary(context.Background(), "user-1", "free")
+	if err != nil {
+		t.Fatalf("Summary: %v", err)
+	}
+	if summary.CreditsRemaining != 860 || summary.CreditsUsed != 140 || summary.MonthlyCredits != 1000 {
+		t.Errorf("summary balance = %+v", summary)
+	}
+	if len(summary.Jobs) != 2 || summary.Jobs[0].JobID != "job-new" || summary.Jobs[1].JobID != "job-old" {
+		t.Errorf("jobs = %+v, want job-new then job-old", summary.Jobs)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/omnigen/backend/internal/concurrency"
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/retry"
+	"go.uber.org/zap"
+)
+
+// Webhook request headers
+const (
+	WebhookSignatureHeader = "X-OmniGen-Signature" // "sha256=" + hex HMAC-SHA256 of the body
+	WebhookEventHeader     = "X-OmniGen-Event"
+)
+
+// Webhook events
+const (
+	WebhookEventJobCompleted = "job.completed"
+	WebhookEventJobFailed    = "job.failed"
+	WebhookEventJobCanceled  = "job.canceled"
+)
+
+// maxConcurrentWebhooks bounds in-flight deliveries so a slow receiver can't pile up goroutines
+const maxConcurrentWebhooks = 50
+
+// WebhookPayload is the JSON body POSTed to a job's callback URL
+type WebhookPayload struct {
+	Event        string  `json:"event"`
+	JobID        string  `json:"job_id"`
+	Status       string  `json:"status"`
+	VideoURL     string  `json:"video_url,omitempty"`     // Presigned MP4 URL
+	ThumbnailURL string  `json:"thumbnail_url,omitempty"` // Presigned thumbnail URL
+	ErrorMessage *string `json:"error_message,omitempty"`
+	Timestamp    int64   `json:"timestamp"` // Unix seconds when the delivery was first attempted
+}
+
+// WebhookDeliveryStore records delivery status on the job
+type WebhookDeliveryStore interface {
+	RecordWebhookDelivery(ctx context.Context, jobID string, attempts int, deliveredAt int64) error
+}
+
+// WebhookConfig controls webhook delivery
+type WebhookConfig struct {
+	Timeout time.Duration // Per attempt, from connecting to the receiver's host to reading its response
+	Retry   retry.Config
+
+	// AllowPrivateNetworks lets deliveries reach loopback and private addresses (local development only)
+	AllowPrivateNetworks bool
+}
+
+// DefaultWebhookConfig retries up to 5 attempts over roughly 15 seconds
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Timeout: 10 * time.Second,
+		Retry: retry.Config{
+			MaxAttempts:  5,
+			InitialDelay: 1 * time.Second,
+			MaxDelay:     30 * time.Second,
+			Multiplier:   2.0,
+		},
+	}
+}
+
+// WebhookService delivers signed job notifications to customer callback URLs
+type WebhookService struct {
+	client    *http.Client
+	store     WebhookDeliveryStore
+	retry     retry.Config
+	semaphore *concurrency.Semaphore
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+// NewWebhookService creates a webhook service. Connections to non-public addresses are
+// refused after DNS resolution, so a hostname can't be pointed at an internal service.
+func NewWebhookService(store WebhookDeliveryStore, cfg WebhookConfig, logger *zap.Logger) *WebhookService {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = refusePrivateAddresses
+	}
+
+	return &WebhookService{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				Proxy:               nil, // A proxy would make the dial check see the proxy's address instead
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// Redirects are not followed: the target was validated, its redirects weren't
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		store:     store,
+		retry:     cfg.Retry,
+		semaphore: concurrency.NewSemaphore(maxConcurrentWebhooks),
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// refusePrivateAddresses is a net.Dialer Control hook that runs after DNS resolution
+func refusePrivateAddresses(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %q: %w", address, err)
+	}
+	if !validation.IsPublicIP(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", validation.ErrPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the signature header value for body: "sha256=" followed by the
+// hex HMAC-SHA256 of the raw body keyed with secret
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue delivers the payload in the background. Failures are logged and recorded on the job.
+func (s *WebhookService) Enqueue(callbackURL, secret string, payload WebhookPayload) {
+	go func() {
+		ctx := context.Background()
+		if err := s.semaphore.Acquire(ctx); err != nil {
+			return
+		}
+		defer s.semaphore.Release()
+
+		s.Deliver(ctx, callbackURL, secret, payload)
+	}()
+}
+
+// Deliver POSTs the payload, retrying with exponential backoff, and records the outcome on the job.
+// It returns the number of attempts made and the final error, if delivery failed.
+func (s *WebhookService) Deliver(ctx context.Context, callbackURL, secret string, payload WebhookPayload) (int, error) {
+	if payload.Timestamp == 0 {
+		payload.Timestamp = s.now().Unix()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	attempts := 0
+	err = retry.Do(ctx, s.retry, func() error {
+		attempts++
+		return s.post(ctx, callbackURL, secret, payload.Event, body)
+	})
+
+	var deliveredAt int64
+	if err == nil {
+		deliveredAt = s.now().Unix()
+		s.logger.Info("Webhook delivered",
+			zap.String("job_id", payload.JobID),
+			zap.String("event", payload.Event),
+			zap.Int("attempts", attempts),
+		)
+	} else {
+		s.logger.Warn("Webhook delivery failed",
+			zap.String("job_id", payload.JobID),
+			zap.String("event", payload.Event),
+			zap.Int("attempts", attempts),
+			zap.Error(err),
+		)
+	}
+
+	if recordErr := s.store.RecordWebhookDelivery(ctx, payload.JobID, attempts, deliveredAt); recordErr != nil {
+		s.logger.Warn("Failed to record webhook delivery",
+			zap.String("job_id", payload.JobID),
+			zap.Error(recordErr),
+		)
+	}
+	return attempts, err
+}
+
+// post makes a single delivery attempt. Client errors other than 408 and 429 aren't retried,
+// and neither are redirects or refused private addresses.
+func (s *WebhookService) post(ctx context.Context, callbackURL, secret, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return retry.NewNonRetryableError(fmt.Errorf("invalid callback URL: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OmniGen-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, event)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if errors.Is(err, validation.ErrPrivateAddress) {
+			return retry.NewNonRetryableError(err)
+		}
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024)) // Drain so the connection can be reused
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	statusErr := fmt.Errorf("webhook receiver returned status %d", resp.StatusCode)
+	if isRetryableStatus(resp.StatusCode) {
+		return statusErr
+	}
+	return retry.NewNonRetryableError(statusErr)
+}
+
+// isRetryableStatus reports whether a non-2xx response may succeed on a later attempt
+func isRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/omnigen/backend/internal/validation"
+	"github.com/omnigen/backend/pkg/retry"
+)
+
+// recordingDeliveryStore records webhook delivery outcomes
+type recordingDeliveryStore struct {
+	mu          sync.Mutex
+	attempts    int
+	deliveredAt int64
+}
+
+func (s *recordingDeliveryStore) RecordWebhookDelivery(ctx context.Context, jobID string, attempts int, deliveredAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts, s.deliveredAt = attempts, deliveredAt
+	return nil
+}
+
+// testWebhookConfig retries quickly and allows the loopback httptest server
+func testWebhookConfig() WebhookConfig {
+	cfg := DefaultWebhookConfig()
+	cfg.Timeout = 2 * time.Second
+	cfg.Retry = retry.Config{MaxAttempts: 5, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2}
+	cfg.AllowPrivateNetworks = true
+	return cfg
+}
+
+// statusSequenceServer answers with statuses in order, repeating the last one
+func statusSequenceServer(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		status := statuses[min(calls, len(statuses)-1)]
+		calls++
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// HMAC-SHA256 test vector
+	got := SignWebhookPayload("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Errorf("SignWebhookPayload = %s, want %s", got, want)
+	}
+
+	if SignWebhookPayload("other-key", []byte("body")) == SignWebhookPayload("key", []byte("body")) {
+		t.Error("signatures with different secrets should differ")
+	}
+}
+
+func TestWebhookRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		attempts  int
+		delivered bool
+	}{
+		{name: "delivered first time", statuses: []int{http.StatusOK}, attempts: 1, delivered: true},
+		{name: "recovers from server errors", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusNoContent}, attempts: 3, delivered: true},
+		{name: "retries rate limiting", statuses: []int{http.StatusTooManyRequests, http.StatusAccepted}, attempts: 2, delivered: true},
+		{name: "gives up after five attempts", statuses: []int{http.StatusInternalServerError}, attempts: 5},
+		{name: "client error is not retried", statuses: []int{http.StatusBadRequest}, attempts: 1},
+		{name: "redirect is not followed", statuses: []int{http.StatusFound}, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		server, calls := statusSequenceServer(t, tt.statuses...)
+		store := &recordingDeliveryStore{}
+		svc := NewWebhookService(store, testWebhookConfig(), zap.NewNop())
+
+		attempts, err := svc.Deliver(context.Background(), server.URL, "0123456789abcdef", WebhookPayload{JobID: "job-1", Event: WebhookEventJobCompleted})
+		if (err == nil) != tt.delivered {
+			t.Errorf("%s: err = %v, want delivered %v", tt.name, err, tt.delivered)
+		}
+		if attempts != tt.attempts || *calls != tt.attempts {
+			t.Errorf("%s: attempts = %d (receiver saw %d), want %d", tt.name, attempts, *calls, tt.attempts)
+		}
+		if store.attempts != tt.attempts || (store.deliveredAt != 0) != tt.delivered {
+			t.Errorf("%s: recorded attempts = %d delivered_at = %d", tt.name, store.attempts, store.deliveredAt)
+		}
+	}
+}
+
+func TestWebhookBackoffDoubles(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := testWebhookConfig()
+	cfg.Retry.MaxAttempts = 3
+	cfg.Retry.InitialDelay = 20 * time.Millisecond
+	cfg.Retry.MaxDelay = time.Second
+	svc := NewWebhookService(&recordingDeliveryStore{}, cfg, zap.NewNop())
+	svc.Deliver(context.Background(), server.URL, "", WebhookPayload{JobID: "job-1"})
+
+	if len(times) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(times))
+	}
+	if first, second := times[1].Sub(times[0]), times[2].Sub(times[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Errorf("delays = %v, %v, want >= 20ms then >= 40ms", first, second)
+	}
+}
+
+func TestWebhookRefusesPrivateAddresses(t *testing.T) {
+	server, calls := statusSequenceServer(t, http.StatusOK)
+
+	cfg := testWebhookConfig()
+	cfg.AllowPrivateNetworks = false
+	store := &recordingDeliveryStore{}
+	svc := NewWebhookService(store, cfg, zap.NewNop())
+
+	// The httptest server listens on loopback, which the dialer must refuse after resolving the host
+	attempts, err := svc.Deliver(context.Background(), server.URL, "", WebhookPayload{JobID: "job-1"})
+	if !errors.Is(err, validation.ErrPrivateAddress) {
+		t.Fatalf("err = %v, want ErrPrivateAddress", err)
+	}
+	if attempts != 1 || *calls != 0 {
+		t.Errorf("attempts = %d, receiver calls = %d, want 1 attempt and no request", attempts, *calls)
+	}
+	if store.attempts != 1 || store.deliveredAt != 0 {
+		t.Errorf("recorded attempts = %d delivered_at = %d, want 1 and 0", store.attempts, store.deliveredAt)
+	}
+}
@@ -0,0 +1,88 @@
+// Package similarity measures how alike two scene descriptions are, to catch a script whose
+// adjacent scenes describe the same shot and would play as a stutter. Similarity is the Jaccard
+// index of the texts' word sets: the words both use over the words either uses. Case,
+// punctuation and filler words are ignored, so two prompts that differ only in a location or
+// an adjective score close to 1, while scenes that change camera, action, setting, light and
+// emotion share little beyond the subject's name and score well under DefaultThreshold.
+package similarity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultThreshold is the similarity at which two adjacent scenes count as repeated. Scenes of
+// the same patient in different moments share their character description and stay below it;
+// drafts that only swap a room or an adjective are above it.
+const DefaultThreshold = 0.6
+
+// stopwords carry no visual meaning, so they would only dilute the measure
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "by": true, "for": true,
+	"from": true, "her": true, "his": true, "in": true, "into": true, "is": true, "it": true,
+	"its": true, "of": true, "on": true, "or": true, "she": true, "he": true, "that": true,
+	"the": true, "their": true, "they": true, "this": true, "to": true, "while": true,
+	"with": true,
+}
+
+// Tokens returns the lowercase words of text, without stopwords. A word is a run of letters
+// and digits; anything else separates words.
+func Tokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if !stopwords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// Jaccard returns the similarity of a and b in [0, 1]: the share of their distinct words the
+// two have in common. Texts without words are similar to nothing.
+func Jaccard(a, b string) float64 {
+	setA := wordSet(a)
+	setB := wordSet(b)
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range setA {
+		if setB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(setA)+len(setB)-shared)
+}
+
+func wordSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, token := range Tokens(text) {
+		set[token] = true
+	}
+	return set
+}
+
+// Pair is two neighbouring texts whose similarity reached the threshold
+type Pair struct {
+	First      int // Index of the earlier text; the later one is First+1
+	Similarity float64
+}
+
+// AdjacentDuplicates returns every pair of consecutive texts at least threshold similar, in
+// order. A threshold of 0 or less finds nothing.
+func AdjacentDuplicates(texts []string, threshold float64) []Pair {
+	if threshold <= 0 {
+		return nil
+	}
+	var pairs []Pair
+	for i := 1; i < len(texts); i++ {
+		if similarity := Jaccard(texts[i-1], texts[i]); similarity >= threshold {
+			pairs = append(pairs, Pair{First: i - 1, Similarity: similarity})
+		}
+	}
+	return pairs
+}
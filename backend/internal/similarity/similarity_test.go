@@ -0,0 +1,123 @@
+package similarity
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// fixture is a script draft GPT-4o returned, reduced to the fields the similarity check reads,
+// with the indices of the scenes that repeat the next one
+type fixture struct {
+	Description string `json:"description"`
+	Repeated    []int  `json:"repeated"`
+	Scenes      []struct {
+		Action           string `json:"action"`
+		GenerationPrompt string `json:"generation_prompt"`
+	} `json:"scenes"`
+}
+
+func loadFixture(t *testing.T, name string) ([]string, []int) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("parse fixture %s: %v", name, err)
+	}
+	texts := make([]string, len(f.Scenes))
+	for i, scene := range f.Scenes {
+		texts[i] = scene.Action + " " + scene.GenerationPrompt
+	}
+	return texts, f.Repeated
+}
+
+func TestTokens(t *testing.T) {
+	got := Tokens("Close-up of Maria, 52, in the dim pre-dawn KITCHEN!")
+	want := []string{"close", "up", "maria", "52", "dim", "pre", "dawn", "kitchen"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens = %q, want %q", got, want)
+	}
+	if got := Tokens(" ,.; "); len(got) != 0 {
+		t.Errorf("Tokens of punctuation = %q, want none", got)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"maria pours coffee", "maria pours coffee", 1},
+		{"Maria pours coffee.", "MARIA, pours the coffee", 1}, // Case, punctuation and stopwords don't count
+		{"maria pours coffee", "james walks dog", 0},
+		{"maria pours coffee", "maria drinks coffee", 0.5},
+		{"coffee coffee coffee", "coffee", 1}, // Repeated words count once
+		{"", "maria", 0},
+		{"the of a", "the of a", 0},
+	}
+	for _, tt := range tests {
+		if got := Jaccard(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Jaccard(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got, reverse := Jaccard(tt.a, tt.b), Jaccard(tt.b, tt.a); got != reverse {
+			t.Errorf("Jaccard(%q, %q) = %v but reversed = %v", tt.a, tt.b, got, reverse)
+		}
+	}
+}
+
+func TestAdjacentDuplicatesFixtures(t *testing.T) {
+	for _, name := range []string{"repetitive_kitchen.json", "repetitive_walk.json", "progression.json"} {
+		t.Run(name, func(t *testing.T) {
+			texts, repeated := loadFixture(t, name)
+			pairs := AdjacentDuplicates(texts, DefaultThreshold)
+
+			got := []int{}
+			for _, pair := range pairs {
+				got = append(got, pair.First)
+				if pair.Similarity < DefaultThreshold || pair.Similarity > 1 {
+					t.Errorf("pair %d similarity %v outside [%v, 1]", pair.First, pair.Similarity, DefaultThreshold)
+				}
+			}
+			if !reflect.DeepEqual(got, repeated) {
+				for i := 1; i < len(texts); i++ {
+					t.Logf("scenes %d-%d: %.2f", i, i+1, Jaccard(texts[i-1], texts[i]))
+				}
+				t.Errorf("repeated scenes = %v, want %v", got, repeated)
+			}
+		})
+	}
+}
+
+func TestAdjacentDuplicatesProgressionStaysClearOfThreshold(t *testing.T) {
+	// A well-formed progression shares the character description between scenes; it must stay
+	// well under the threshold so ordinary scripts never trigger a retry
+	texts, _ := loadFixture(t, "progression.json")
+	for i := 1; i < len(texts); i++ {
+		if similarity := Jaccard(texts[i-1], texts[i]); similarity > DefaultThreshold-0.2 {
+			t.Errorf("scenes %d and %d are %.2f similar, too close to the threshold", i, i+1, similarity)
+		}
+	}
+}
+
+func TestAdjacentDuplicatesThreshold(t *testing.T) {
+	texts := []string{"maria pours coffee", "maria drinks coffee", "james walks dog"}
+	if pairs := AdjacentDuplicates(texts, 0.5); len(pairs) != 1 || pairs[0].First != 0 || pairs[0].Similarity != 0.5 {
+		t.Errorf("AdjacentDuplicates at 0.5 = %+v, want scenes 0 and 1 at 0.5", pairs)
+	}
+	if pairs := AdjacentDuplicates(texts, 0.51); len(pairs) != 0 {
+		t.Errorf("AdjacentDuplicates at 0.51 = %+v, want none", pairs)
+	}
+	for _, threshold := range []float64{0, -1} {
+		if pairs := AdjacentDuplicates([]string{"same", "same"}, threshold); pairs != nil {
+			t.Errorf("AdjacentDuplicates at %v = %+v, want disabled", threshold, pairs)
+		}
+	}
+	if pairs := AdjacentDuplicates([]string{"only"}, DefaultThreshold); pairs != nil {
+		t.Errorf("AdjacentDuplicates of one scene = %+v, want none", pairs)
+	}
+}
@@ -0,0 +1,99 @@
+// Package trace carries a request's correlation ID, and the job it concerns, through contexts
+// so the request log, the job's pipeline logs and the adapter logs can be matched up
+package trace
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Header carries the correlation ID on incoming requests, responses and outbound API calls
+const Header = "X-Request-ID"
+
+// maxRequestIDLength bounds an ID accepted from a client
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+type jobIDKey struct{}
+
+// NewRequestID generates a correlation ID for a request that arrived without one
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ValidRequestID reports whether a client-supplied ID is safe to log and forward: short, and
+// only letters, digits and . _ : -
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == ':', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns a context carrying a request's correlation ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the context's correlation ID, or "" when it has none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithJobID returns a context carrying the ID of the job being worked on
+func WithJobID(ctx context.Context, jobID string) context.Context {
+	if jobID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, jobIDKey{}, jobID)
+}
+
+// JobID returns the context's job ID, or "" when it has none
+func JobID(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// Detach returns a fresh context carrying only ctx's IDs, for work that outlives the request
+// ctx belongs to
+func Detach(ctx context.Context) context.Context {
+	return WithJobID(WithRequestID(context.Background(), RequestID(ctx)), JobID(ctx))
+}
+
+// Logger returns base with request_id and job_id fields for the IDs ctx carries
+func Logger(ctx context.Context, base *zap.Logger) *zap.Logger {
+	var fields []zap.Field
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if id := JobID(ctx); id != "" {
+		fields = append(fields, zap.String("job_id", id))
+	}
+	if len(fields) == 0 {
+		return base
+	}
+	return base.With(fields...)
+}
+
+// SetHeader sends the correlation ID of req's context with an outbound request
+func SetHeader(req *http.Request) {
+	if id := RequestID(req.Context()); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
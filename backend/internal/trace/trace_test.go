@@ -0,0 +1,69 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContextIDs(t *testing.T) {
+	ctx := context.Background()
+	require.Empty(t, RequestID(ctx))
+	require.Empty(t, JobID(ctx))
+
+	ctx = WithJobID(WithRequestID(ctx, "req-1"), "job-1")
+	require.Equal(t, "req-1", RequestID(ctx))
+	require.Equal(t, "job-1", JobID(ctx))
+
+	require.Equal(t, "req-1", RequestID(WithRequestID(ctx, "")), "an empty ID keeps the current one")
+}
+
+func TestDetachKeepsIDsWithoutCancellation(t *testing.T) {
+	parent, cancel := context.WithCancel(WithJobID(WithRequestID(context.Background(), "req-1"), "job-1"))
+	cancel()
+
+	detached := Detach(parent)
+	require.NoError(t, detached.Err())
+	require.Equal(t, "req-1", RequestID(detached))
+	require.Equal(t, "job-1", JobID(detached))
+}
+
+func TestLoggerAddsContextFields(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	base := zap.New(core)
+
+	Logger(context.Background(), base).Info("no ids")
+	Logger(WithJobID(WithRequestID(context.Background(), "req-1"), "job-1"), base).Info("both ids")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	require.Empty(t, entries[0].ContextMap())
+	require.Equal(t, map[string]interface{}{"request_id": "req-1", "job_id": "job-1"}, entries[1].ContextMap())
+}
+
+func TestSetHeader(t *testing.T) {
+	req, err := http.NewRequestWithContext(WithRequestID(context.Background(), "req-1"), http.MethodGet, "https://api.replicate.com/v1/predictions/p1", nil)
+	require.NoError(t, err)
+	SetHeader(req)
+	require.Equal(t, "req-1", req.Header.Get(Header))
+
+	req, err = http.NewRequest(http.MethodGet, "https://api.replicate.com/v1/predictions/p1", nil)
+	require.NoError(t, err)
+	SetHeader(req)
+	require.Empty(t, req.Header.Values(Header))
+}
+
+func TestValidRequestID(t *testing.T) {
+	require.True(t, ValidRequestID("3f2b8c1e-9a4d-4e7b-8c2a-1d5e6f7a8b9c"))
+	require.True(t, ValidRequestID("frontend:42.retry_1"))
+	require.False(t, ValidRequestID(""))
+	require.False(t, ValidRequestID("id with spaces"))
+	require.False(t, ValidRequestID("forged\nlog line"))
+	require.False(t, ValidRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+	require.True(t, ValidRequestID(NewRequestID()))
+}
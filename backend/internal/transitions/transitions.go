@@ -0,0 +1,174 @@
+// Package transitions plans how a video's clips are joined: which scene boundaries blend the
+// clips with ffmpeg's xfade filter and for how long, where each clip lands in the joined video
+// and the filtergraph that renders it. Blends overlap the clips they join, so a video with
+// blended boundaries is shorter than its clips' total by their Overlap.
+package transitions
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// blends are the xfade transition and the nominal length in seconds of each script transition
+// rendered as a blend. Everything else, including match, jump and smash cuts, is a hard cut.
+var blends = map[domain.Transition]struct {
+	xfade    string
+	duration float64
+}{
+	domain.TransitionFade:      {"fadeblack", 0.6},
+	domain.TransitionCrossFade: {"fade", 0.5},
+	domain.TransitionWipeLeft:  {"wipeleft", 0.5},
+	domain.TransitionWipeRight: {"wiperight", 0.5},
+	domain.TransitionIrisIn:    {"circleopen", 0.6},
+	domain.TransitionIrisOut:   {"circleclose", 0.6},
+	domain.TransitionWhip:      {"slideleft", 0.3},
+	domain.TransitionZoom:      {"zoomin", 0.4},
+}
+
+const (
+	// MaxClipShare is the largest part of either clip a blend may overlap, so a clip blended at
+	// both ends still shows a third of itself on its own
+	MaxClipShare = 1.0 / 3
+	// MinBlendDuration is the shortest blend rendered; one that has to be shorter to fit its
+	// clips is cut instead
+	MinBlendDuration = 0.1
+)
+
+// Boundary is how one clip is joined to the next
+type Boundary struct {
+	Transition domain.Transition // The script's transition; "" for a cut
+	XFade      string            // xfade transition rendering it; "" for a hard cut
+	Duration   float64           // Seconds the two clips overlap; 0 for a cut
+}
+
+// Blended reports whether the boundary blends its clips rather than cutting
+func (b Boundary) Blended() bool {
+	return b.XFade != ""
+}
+
+// Resolve decides the boundaries between consecutive scenes whose clips last durations. A
+// boundary uses the outgoing scene's transition_out, or its incoming scene's transition_in when
+// the outgoing one cuts. A blend is shortened to MaxClipShare of the shorter clip it joins, and
+// cut when that leaves less than MinBlendDuration. Scenes past the end of durations are ignored.
+func Resolve(scenes []domain.Scene, durations []float64) []Boundary {
+	n := min(len(scenes), len(durations))
+	if n < 2 {
+		return nil
+	}
+	boundaries := make([]Boundary, n-1)
+	for i := range boundaries {
+		transition := scenes[i].TransitionOut
+		if _, ok := blends[transition]; !ok {
+			transition = scenes[i+1].TransitionIn
+		}
+		blend, ok := blends[transition]
+		if !ok {
+			continue
+		}
+		duration := math.Min(blend.duration, MaxClipShare*math.Min(durations[i], durations[i+1]))
+		if duration < MinBlendDuration {
+			continue
+		}
+		boundaries[i] = Boundary{Transition: transition, XFade: blend.xfade, Duration: roundMillis(duration)}
+	}
+	return boundaries
+}
+
+// HasBlends reports whether any of boundaries blends its clips; clips joined by cuts alone can
+// be stream-copied
+func HasBlends(boundaries []Boundary) bool {
+	for _, b := range boundaries {
+		if b.Blended() {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlap is the seconds the blends among boundaries take off the clips' total duration
+func Overlap(boundaries []Boundary) float64 {
+	var overlap float64
+	for _, b := range boundaries {
+		overlap += b.Duration
+	}
+	return roundMillis(overlap)
+}
+
+// Shares returns each clip's part of the joined video: its duration less the blend out of it,
+// so a clip's share is the time from its start to the next clip's. The shares add up to the
+// joined video's duration. A clip without a boundary after it is cut to the next.
+func Shares(durations []float64, boundaries []Boundary) []float64 {
+	shares := make([]float64, len(durations))
+	for i, duration := range durations {
+		shares[i] = duration
+		if i < len(boundaries) {
+			shares[i] = roundMillis(duration - boundaries[i].Duration)
+		}
+	}
+	return shares
+}
+
+// Offsets returns when each clip after the first starts in the joined video, which is the
+// xfade offset of a blended boundary: the clips before it, less the blends between them and
+// the blend into it
+func Offsets(durations []float64, boundaries []Boundary) []float64 {
+	shares := Shares(durations, boundaries)
+	offsets := make([]float64, max(len(durations)-1, 0))
+	var start float64
+	for i := range offsets {
+		start += shares[i]
+		offsets[i] = roundMillis(start)
+	}
+	return offsets
+}
+
+// FilterGraph joins inputs 0 to len(durations)-1, lasting durations, into the video labeled
+// [vout]: blended boundaries are xfaded at their offsets and cuts concatenated. Every input is
+// first set to start at zero on a common timebase, and to fps when it's above 0, as xfade
+// needs both of its inputs alike.
+func FilterGraph(durations []float64, boundaries []Boundary, fps float64) string {
+	var filters []string
+	prep := "settb=AVTB,setpts=PTS-STARTPTS"
+	if fps > 0 {
+		prep = "fps=" + strconv.FormatFloat(fps, 'f', -1, 64) + "," + prep
+	}
+	for i := range durations {
+		filters = append(filters, fmt.Sprintf("[%d:v]%s[c%d]", i, prep, i))
+	}
+	if len(durations) == 1 {
+		return strings.Join(append(filters, "[c0]null[vout]"), ";")
+	}
+
+	offsets := Offsets(durations, boundaries)
+	joined := "[c0]"
+	for i := 1; i < len(durations); i++ {
+		out := fmt.Sprintf("[j%d]", i)
+		if i == len(durations)-1 {
+			out = "[vout]"
+		}
+		var boundary Boundary
+		if i-1 < len(boundaries) {
+			boundary = boundaries[i-1]
+		}
+		if boundary.Blended() {
+			filters = append(filters, fmt.Sprintf("%s[c%d]xfade=transition=%s:duration=%s:offset=%s%s",
+				joined, i, boundary.XFade, formatSeconds(boundary.Duration), formatSeconds(offsets[i-1]), out))
+		} else {
+			filters = append(filters, fmt.Sprintf("%s[c%d]concat=n=2:v=1:a=0%s", joined, i, out))
+		}
+		joined = out
+	}
+	return strings.Join(filters, ";")
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(roundMillis(seconds), 'f', 3, 64)
+}
+
+func roundMillis(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
@@ -0,0 +1,154 @@
+package transitions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func scenes(transitions ...[2]domain.Transition) []domain.Scene {
+	out := make([]domain.Scene, len(transitions))
+	for i, t := range transitions {
+		out[i] = domain.Scene{SceneNumber: i + 1, TransitionIn: t[0], TransitionOut: t[1]}
+	}
+	return out
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		scenes    []domain.Scene
+		durations []float64
+		want      []Boundary
+	}{
+		{
+			name:      "cuts everywhere",
+			scenes:    scenes([2]domain.Transition{"none", "cut"}, [2]domain.Transition{"cut", "match_cut"}, [2]domain.Transition{"jump_cut", "none"}),
+			durations: []float64{8, 8, 8},
+			want:      []Boundary{{}, {}},
+		},
+		{
+			name:      "transition out wins",
+			scenes:    scenes([2]domain.Transition{"none", "cross_fade"}, [2]domain.Transition{"wipe_left", "none"}),
+			durations: []float64{8, 8},
+			want:      []Boundary{{Transition: domain.TransitionCrossFade, XFade: "fade", Duration: 0.5}},
+		},
+		{
+			name:      "transition in used after a cut out",
+			scenes:    scenes([2]domain.Transition{"none", "smash_cut"}, [2]domain.Transition{"fade", "none"}),
+			durations: []float64{8, 8},
+			want:      []Boundary{{Transition: domain.TransitionFade, XFade: "fadeblack", Duration: 0.6}},
+		},
+		{
+			name:      "blend shortened to fit a short clip",
+			scenes:    scenes([2]domain.Transition{"none", "fade"}, [2]domain.Transition{"cut", "none"}),
+			durations: []float64{8, 1.2},
+			want:      []Boundary{{Transition: domain.TransitionFade, XFade: "fadeblack", Duration: 0.4}},
+		},
+		{
+			name:      "blend that can't fit is cut",
+			scenes:    scenes([2]domain.Transition{"none", "zoom_transition"}, [2]domain.Transition{"cut", "none"}),
+			durations: []float64{8, 0.25},
+			want:      []Boundary{{}},
+		},
+		{
+			name:      "unknown transition cuts",
+			scenes:    scenes([2]domain.Transition{"none", "glitch"}, [2]domain.Transition{"", "none"}),
+			durations: []float64{8, 8},
+			want:      []Boundary{{}},
+		},
+		{
+			name:      "scenes without clips ignored",
+			scenes:    scenes([2]domain.Transition{"none", "wipe_right"}, [2]domain.Transition{"cut", "iris_out"}, [2]domain.Transition{"cut", "none"}),
+			durations: []float64{6, 6},
+			want:      []Boundary{{Transition: domain.TransitionWipeRight, XFade: "wiperight", Duration: 0.5}},
+		},
+		{
+			name:      "single scene",
+			scenes:    scenes([2]domain.Transition{"fade", "fade"}),
+			durations: []float64{8},
+			want:      nil,
+		},
+	}
+	for _, tt := range tests {
+		if got := Resolve(tt.scenes, tt.durations); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Resolve() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDurationAccounting(t *testing.T) {
+	durations := []float64{8, 6, 8, 3}
+	boundaries := []Boundary{
+		{Transition: domain.TransitionCrossFade, XFade: "fade", Duration: 0.5},
+		{},
+		{Transition: domain.TransitionWhip, XFade: "slideleft", Duration: 0.3},
+	}
+
+	if !HasBlends(boundaries) || HasBlends([]Boundary{{}, {}}) || HasBlends(nil) {
+		t.Error("HasBlends should report only blended boundaries")
+	}
+	if got := Overlap(boundaries); got != 0.8 {
+		t.Errorf("Overlap() = %v, want 0.8", got)
+	}
+
+	shares := Shares(durations, boundaries)
+	if want := []float64{7.5, 6, 7.7, 3}; !reflect.DeepEqual(shares, want) {
+		t.Errorf("Shares() = %v, want %v", shares, want)
+	}
+	var total float64
+	for _, share := range shares {
+		total += share
+	}
+	if total != 25-Overlap(boundaries) {
+		t.Errorf("shares add up to %v, want the clips less the overlap, %v", total, 25-Overlap(boundaries))
+	}
+
+	if got, want := Offsets(durations, boundaries), []float64{7.5, 13.5, 21.2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Offsets() = %v, want %v", got, want)
+	}
+	// An appended clip without a boundary, such as the end card, is cut to
+	if got, want := Offsets(append(durations, 3), boundaries), []float64{7.5, 13.5, 21.2, 24.2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Offsets() with an extra clip = %v, want %v", got, want)
+	}
+	if got := Offsets(nil, nil); len(got) != 0 {
+		t.Errorf("Offsets(nil) = %v, want none", got)
+	}
+}
+
+func TestFilterGraph(t *testing.T) {
+	durations := []float64{8, 6, 8, 3}
+	boundaries := []Boundary{
+		{Transition: domain.TransitionCrossFade, XFade: "fade", Duration: 0.5},
+		{},
+		{Transition: domain.TransitionWhip, XFade: "slideleft", Duration: 0.3},
+	}
+	want := "[0:v]fps=30,settb=AVTB,setpts=PTS-STARTPTS[c0];" +
+		"[1:v]fps=30,settb=AVTB,setpts=PTS-STARTPTS[c1];" +
+		"[2:v]fps=30,settb=AVTB,setpts=PTS-STARTPTS[c2];" +
+		"[3:v]fps=30,settb=AVTB,setpts=PTS-STARTPTS[c3];" +
+		"[c0][c1]xfade=transition=fade:duration=0.500:offset=7.500[j1];" +
+		"[j1][c2]concat=n=2:v=1:a=0[j2];" +
+		"[j2][c3]xfade=transition=slideleft:duration=0.300:offset=21.200[vout]"
+	if got := FilterGraph(durations, boundaries, 30); got != want {
+		t.Errorf("FilterGraph() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFilterGraphEdgeCases(t *testing.T) {
+	if got, want := FilterGraph([]float64{8}, nil, 0), "[0:v]settb=AVTB,setpts=PTS-STARTPTS[c0];[c0]null[vout]"; got != want {
+		t.Errorf("one clip = %q, want %q", got, want)
+	}
+
+	// The end card follows the last scene with a cut
+	got := FilterGraph([]float64{8, 8, 2}, []Boundary{{XFade: "fadeblack", Duration: 0.6}}, 23.976)
+	want := "[0:v]fps=23.976,settb=AVTB,setpts=PTS-STARTPTS[c0];" +
+		"[1:v]fps=23.976,settb=AVTB,setpts=PTS-STARTPTS[c1];" +
+		"[2:v]fps=23.976,settb=AVTB,setpts=PTS-STARTPTS[c2];" +
+		"[c0][c1]xfade=transition=fadeblack:duration=0.600:offset=7.400[j1];" +
+		"[j1][c2]concat=n=2:v=1:a=0[vout]"
+	if got != want {
+		t.Errorf("with an end card =\n%s\nwant\n%s", got, want)
+	}
+}
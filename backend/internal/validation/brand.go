@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// Limits for brand guideline fields. A guideline at every limit is a few tens of KB, well under
+// DynamoDB's item limit.
+const (
+	MaxBrandNameLength     = 100
+	MaxBrandTextLength     = 1000 // Typography, image style and video style
+	MaxBrandListItems      = 20   // Colors, brand voice adjectives and logo URLs
+	MaxBrandListItemLength = 100  // One color or brand voice adjective
+)
+
+// ValidateBrandGuidelines checks guideline fields against their limits before they are stored
+func ValidateBrandGuidelines(g *domain.BrandGuidelines) Errors {
+	var errs Errors
+	errs.maxLength("name", g.Name, MaxBrandNameLength)
+	errs.maxLength("typography", g.Typography, MaxBrandTextLength)
+	errs.maxLength("image_style", g.ImageStyle, MaxBrandTextLength)
+	errs.maxLength("video_style", g.VideoStyle, MaxBrandTextLength)
+	validateBrandList(&errs, "colors", g.Colors, MaxBrandListItemLength)
+	validateBrandList(&errs, "brand_voice", g.BrandVoice, MaxBrandListItemLength)
+	validateBrandList(&errs, "logo_urls", g.LogoURLs, MaxURLLength)
+	return errs
+}
+
+// validateBrandList checks a list field's length and that of each of its items
+func validateBrandList(errs *Errors, field string, items []string, maxItemLength int) {
+	if len(items) > MaxBrandListItems {
+		errs.Add(field, fmt.Sprintf("At most %d %s are allowed (got %d)", MaxBrandListItems, field, len(items)))
+		return
+	}
+	for i, item := range items {
+		errs.maxLength(fmt.Sprintf("%s[%d]", field, i), item, maxItemLength)
+	}
+}
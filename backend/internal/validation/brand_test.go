@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func TestValidateBrandGuidelines(t *testing.T) {
+	full := &domain.BrandGuidelines{
+		Name:       strings.Repeat("n", MaxBrandNameLength),
+		Typography: strings.Repeat("t", MaxBrandTextLength),
+		ImageStyle: strings.Repeat("i", MaxBrandTextLength),
+		VideoStyle: strings.Repeat("v", MaxBrandTextLength),
+		Colors:     make([]string, MaxBrandListItems),
+		BrandVoice: []string{strings.Repeat("w", MaxBrandListItemLength)},
+		LogoURLs:   []string{"https://example.com/" + strings.Repeat("l", MaxURLLength-20)},
+	}
+	if errs := ValidateBrandGuidelines(full); len(errs) != 0 {
+		t.Fatalf("guidelines at every limit: errors = %v", errs)
+	}
+
+	errs := ValidateBrandGuidelines(&domain.BrandGuidelines{
+		Name:       strings.Repeat("n", MaxBrandNameLength+1),
+		Typography: strings.Repeat("t", MaxBrandTextLength+1),
+		ImageStyle: strings.Repeat("i", MaxBrandTextLength+1),
+		VideoStyle: strings.Repeat("v", MaxBrandTextLength+1),
+		Colors:     make([]string, MaxBrandListItems+1),
+		BrandVoice: []string{"warm", strings.Repeat("w", MaxBrandListItemLength+1)},
+		LogoURLs:   []string{"https://example.com/" + strings.Repeat("l", MaxURLLength)},
+	})
+	for _, field := range []string{"name", "typography", "image_style", "video_style", "colors", "brand_voice[1]", "logo_urls[0]"} {
+		if _, ok := errs.Field(field); !ok {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+	if len(errs) != 7 {
+		t.Errorf("errors = %v, want one per field", errs)
+	}
+	if fe, _ := errs.Field("colors"); fe.Message != "At most 20 colors are allowed (got 21)" {
+		t.Errorf("colors message = %q", fe.Message)
+	}
+}
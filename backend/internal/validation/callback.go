@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Limits for webhook callback fields
+const (
+	MaxCallbackURLLength    = 2048
+	MinCallbackSecretLength = 16
+	MaxCallbackSecretLength = 256
+)
+
+// ErrPrivateAddress is returned for callback targets on loopback, private or otherwise internal networks
+var ErrPrivateAddress = errors.New("callback URL must not point to a private or loopback address")
+
+// Ranges IsPublicIP rejects in addition to the netip classifications
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach IPv4 internal hosts
+}
+
+// IsPublicIP reports whether ip is a globally routable unicast address. Loopback, private,
+// link-local (including the 169.254.169.254 metadata endpoint) and reserved ranges are not.
+func IsPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateCallbackURL checks that raw is an absolute http(s) URL that doesn't name an internal
+// host. Hostnames are resolved at delivery time, where each connection is checked again.
+func ValidateCallbackURL(raw string) error {
+	if len(raw) > MaxCallbackURLLength {
+		return fmt.Errorf("callback URL cannot exceed %d characters", MaxCallbackURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.New("callback URL must be an absolute URL")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return errors.New("callback URL must use http or https")
+	}
+	if u.User != nil {
+		return errors.New("callback URL must not contain credentials")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return ErrPrivateAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !IsPublicIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// validateCallback checks the optional webhook fields of a generate request
+func validateCallback(errs *Errors, callbackURL, callbackSecret string) {
+	if callbackURL == "" {
+		if callbackSecret != "" {
+			errs.Add("callback_secret", "callback_secret requires a callback_url")
+		}
+		return
+	}
+	if err := ValidateCallbackURL(callbackURL); err != nil {
+		errs.Add("callback_url", err.Error())
+	}
+	if n := len(callbackSecret); n > 0 && (n < MinCallbackSecretLength || n > MaxCallbackSecretLength) {
+		errs.Add("callback_secret", fmt.Sprintf("callback_secret must be %d-%d characters", MinCallbackSecretLength, MaxCallbackSecretLength))
+	}
+}
@@ -0,0 +1,699 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+// Limits for generate request fields. Together they keep a job's DynamoDB item far below the
+// 400KB limit, and every request body below the API's body size limit.
+const (
+	MinPromptLength       = 10
+	MaxPromptLength       = 2000
+	MinDuration           = 10
+	MaxDuration           = 60
+	MinSideEffectsLength  = 10
+	MaxSideEffectsLength  = 500
+	MaxTitleLength        = 100
+	MaxAudienceLength     = 200
+	MaxCallToActionLength = 100
+	MinLogoSize           = 1  // Percent of video width
+	MaxLogoSize           = 50 // Percent of video width
+	MinEndCardDuration    = 2
+	MaxEndCardDuration    = 4
+	MaxEndCardLegalLength = 200
+	MaxProductImages      = 8
+	MaxProductImageHint   = 200
+	MaxScenePlanHint      = 200
+	MaxVariants           = 3
+	MaxSceneActionLength  = 500
+	MaxNoteLength         = 1000
+	MaxURLLength          = 2048 // Image URLs and asset keys
+	MinOutputCRF          = 16
+	MaxOutputCRF          = 35
+	MinOutputBitrateKbps  = 500
+	MaxOutputBitrateKbps  = 50000
+	MinReorderedScenes    = 2 // Scenes a reorder must keep
+	MinTrimmedClipSeconds = 2 // Seconds a trimmed clip must keep
+)
+
+// Allowed values for enum fields
+var (
+	AspectRatios = []string{domain.AspectRatio16x9, domain.AspectRatio9x16, domain.AspectRatio1x1}
+	Models       = []string{string(adapters.AdapterTypeVeo), string(adapters.AdapterTypeKling)}
+	Voices       = []string{"male", "female"}
+	TTSProviders = []string{string(adapters.TTSProviderOpenAI), string(adapters.TTSProviderElevenLabs)}
+	Languages    = domain.SupportedLanguages
+	Styles       = []string{"cinematic", "documentary", "energetic", "minimal", "dramatic", "playful"}
+	Tones        = []string{"premium", "friendly", "edgy", "inspiring", "humorous"}
+	Tempos       = []string{"slow", "medium", "fast"}
+	Platforms    = []string{"instagram", "tiktok", "youtube", "facebook"}
+	Goals        = []string{"awareness", "sales", "engagement", "signups"}
+
+	SceneMoods = []string{
+		string(domain.MoodEnergetic), string(domain.MoodCalm), string(domain.MoodDramatic), string(domain.MoodInspiring),
+		string(domain.MoodMysterious), string(domain.MoodPlayful), string(domain.MoodSophisticated), string(domain.MoodNostalgic),
+		string(domain.MoodUrgent), string(domain.MoodLuxurious), string(domain.MoodIntimate), string(domain.MoodEpic),
+	}
+	CameraMoves = []string{
+		string(domain.MoveStatic), string(domain.MovePanLeft), string(domain.MovePanRight), string(domain.MoveTiltUp),
+		string(domain.MoveTiltDown), string(domain.MoveDollyIn), string(domain.MoveDollyOut), string(domain.MoveDollyLeft),
+		string(domain.MoveDollyRight), string(domain.MoveZoomIn), string(domain.MoveZoomOut), string(domain.MoveHandheld),
+		string(domain.MoveSteadycam), string(domain.MoveArc), string(domain.MoveTracking), string(domain.MoveCrane),
+		string(domain.MoveCraneDown), string(domain.MoveDrone),
+	}
+
+	SideEffectsOverflowModes = []string{domain.SideEffectsPaginate, domain.SideEffectsScroll}
+	ContinuityModes          = []string{domain.ContinuityFrame, domain.ContinuityStyle, domain.ContinuityNone}
+	LogoPositions            = []string{domain.LogoTopLeft, domain.LogoTopRight, domain.LogoBottomLeft, domain.LogoBottomRight, domain.LogoBottomCenter}
+	OutputResolutions        = []string{domain.OutputResolution720p, domain.OutputResolution1080p, domain.OutputResolution2160p}
+	OutputCodecs             = []string{domain.OutputCodecH264, domain.OutputCodecH265}
+	OutputContainers         = []string{domain.OutputContainerMP4, domain.OutputContainerMOV}
+)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// GenerateInput holds the user-supplied fields of a video generation request.
+// Callers should trim whitespace before validating.
+type GenerateInput struct {
+	Prompt              string
+	Duration            int
+	AspectRatio         string
+	Model               string
+	Voice               string
+	SideEffects         string
+	SideEffectsOverflow string
+	TTSProvider         string
+	NarratorProvider    string // The provider that will narrate: TTSProvider, the server default or its fallback
+	Language            string // BCP-47 tag in canonical case
+	StartImage          string
+	StyleReferenceImage string
+	Title               string
+	Style               string
+	Tone                string
+	Tempo               string
+	Platform            string
+	Audience            string
+	Goal                string
+	CallToAction        string
+	CallbackURL         string
+	CallbackSecret      string
+	LogoOverlay         *domain.LogoOverlay
+	EndCard             *domain.EndCard
+	Output              *domain.OutputSpec
+	ProductImages       []domain.ProductImage
+	ScenePlan           []domain.PlannedScene
+	ContinuityMode      string
+	BrandGuidelines     bool // The request applies brand guidelines (use_brand_guidelines or guideline_id)
+	Preview             bool
+	Variants            int // Scripts to generate as A/B variants; 0 means 1
+}
+
+// IsPharmaceutical reports whether the request asks for a pharmaceutical ad
+// (any narrator voice or side effects disclosure makes it one)
+func (in GenerateInput) IsPharmaceutical() bool {
+	return in.Voice != "" || in.SideEffects != ""
+}
+
+// ValidateGenerate checks a generate request and returns every violation found
+func ValidateGenerate(in GenerateInput) Errors {
+	var errs Errors
+
+	switch n := len(in.Prompt); {
+	case n == 0:
+		errs.Add("prompt", "Prompt is required")
+	case n < MinPromptLength:
+		errs.Add("prompt", fmt.Sprintf("Prompt must be at least %d characters", MinPromptLength))
+	case n > MaxPromptLength:
+		errs.Add("prompt", fmt.Sprintf("Prompt cannot exceed %d characters (currently: %d)", MaxPromptLength, n))
+	}
+
+	if in.AspectRatio == "" {
+		errs.Add("aspect_ratio", "Aspect ratio is required", AspectRatios...)
+	} else {
+		errs.oneOf("aspect_ratio", in.AspectRatio, AspectRatios)
+	}
+
+	adapterType, err := adapters.ParseAdapterType(in.Model)
+	if err != nil {
+		errs.Add("model", "Invalid video model. Choose 'veo' or 'kling'", Models...)
+	} else {
+		validateDuration(&errs, in.Duration, adapterType)
+		validateScenePlan(&errs, in.ScenePlan, in.Duration, adapterType)
+	}
+
+	errs.oneOf("tts_provider", in.TTSProvider, TTSProviders)
+	errs.oneOf("language", in.Language, Languages)
+	errs.oneOf("side_effects_overflow", in.SideEffectsOverflow, SideEffectsOverflowModes)
+	errs.oneOf("continuity_mode", in.ContinuityMode, ContinuityModes)
+	errs.oneOf("style", in.Style, Styles)
+	errs.oneOf("tone", in.Tone, Tones)
+	errs.oneOf("tempo", in.Tempo, Tempos)
+	errs.oneOf("platform", in.Platform, Platforms)
+	errs.oneOf("goal", in.Goal, Goals)
+
+	errs.maxLength("title", in.Title, MaxTitleLength)
+	errs.maxLength("audience", in.Audience, MaxAudienceLength)
+	errs.maxLength("call_to_action", in.CallToAction, MaxCallToActionLength)
+
+	validateImageRef(&errs, "start_image", in.StartImage)
+	validateImageRef(&errs, "style_reference_image", in.StyleReferenceImage)
+	validateCallback(&errs, in.CallbackURL, in.CallbackSecret)
+	validateLogoOverlay(&errs, in)
+	validateEndCard(&errs, in)
+	validateOutputSpec(&errs, in.Output)
+	validateProductImages(&errs, in.ProductImages)
+	validateVariants(&errs, in)
+
+	if in.IsPharmaceutical() {
+		validatePharmaceutical(&errs, in)
+	}
+
+	return errs
+}
+
+// DurationMessage describes the durations a model can produce,
+// e.g. "achievable with 4, 6, or 8 second clips"
+func DurationMessage(adapterType adapters.AdapterType) string {
+	durations := adapters.ClipDurations(adapterType)
+	parts := make([]string, len(durations))
+	for i, d := range durations {
+		parts[i] = strconv.Itoa(d)
+	}
+
+	var clips string
+	switch len(parts) {
+	case 1:
+		clips = parts[0]
+	case 2:
+		clips = parts[0] + " or " + parts[1]
+	default:
+		clips = strings.Join(parts[:len(parts)-1], ", ") + ", or " + parts[len(parts)-1]
+	}
+	return fmt.Sprintf("Duration must be between %d-%d seconds and achievable with %s second clips", MinDuration, MaxDuration, clips)
+}
+
+// SupportedDurations lists every total duration the model can produce within the allowed range
+func SupportedDurations(adapterType adapters.AdapterType) []int {
+	var durations []int
+	for d := MinDuration; d <= MaxDuration; d++ {
+		if adapters.IsAchievableDuration(adapterType, d) {
+			durations = append(durations, d)
+		}
+	}
+	return durations
+}
+
+// ValidateSceneNumber checks a 1-based scene number against the number of scenes in a job
+func ValidateSceneNumber(errs *Errors, field string, sceneNumber, totalScenes int) bool {
+	if sceneNumber < 1 || sceneNumber > totalScenes {
+		errs.Add(field, fmt.Sprintf("Invalid scene number %d. Job has %d scenes.", sceneNumber, totalScenes))
+		return false
+	}
+	return true
+}
+
+// ValidateSceneOrder checks a reorder's scene numbers: each of the job's totalScenes at most
+// once, keeping at least MinReorderedScenes
+func ValidateSceneOrder(errs *Errors, field string, order []int, totalScenes int) bool {
+	seen := make(map[int]bool, len(order))
+	for i, sceneNumber := range order {
+		entry := fmt.Sprintf("%s[%d]", field, i)
+		if !ValidateSceneNumber(errs, entry, sceneNumber, totalScenes) {
+			return false
+		}
+		if seen[sceneNumber] {
+			errs.Add(entry, fmt.Sprintf("Scene %d is listed more than once", sceneNumber))
+			return false
+		}
+		seen[sceneNumber] = true
+	}
+	if len(order) < MinReorderedScenes {
+		errs.Add(field, fmt.Sprintf("At least %d scenes must remain (got %d)", MinReorderedScenes, len(order)))
+		return false
+	}
+	return true
+}
+
+// ValidateSceneTrim checks the seconds cut from the start and end of a clip of duration seconds
+func ValidateSceneTrim(trimStart, trimEnd, duration float64) Errors {
+	var errs Errors
+	if trimStart < 0 {
+		errs.Add("trim_start", "trim_start cannot be negative")
+	}
+	if trimEnd < 0 {
+		errs.Add("trim_end", "trim_end cannot be negative")
+	}
+	if len(errs) == 0 && duration-trimStart-trimEnd < MinTrimmedClipSeconds {
+		errs.Add("trim_end", fmt.Sprintf("Trimming %ss from the %ss clip leaves less than the minimum of %ds",
+			formatTrimSeconds(trimStart+trimEnd), formatTrimSeconds(duration), MinTrimmedClipSeconds))
+	}
+	return errs
+}
+
+// formatTrimSeconds renders seconds to the millisecond, without trailing zeros
+func formatTrimSeconds(seconds float64) string {
+	return strconv.FormatFloat(math.Round(seconds*1000)/1000, 'f', -1, 64)
+}
+
+// ValidateScenePrompt applies the same checks used for GPT-4o generated scene prompts
+func ValidateScenePrompt(errs *Errors, field string, sceneNumber int, prompt string) bool {
+	if n := len(prompt); n > MaxPromptLength {
+		errs.Add(field, fmt.Sprintf("Scene %d prompt cannot exceed %d characters (currently: %d)", sceneNumber, MaxPromptLength, n))
+		return false
+	}
+	if err := adapters.ValidateGenerationPrompt(prompt); err != nil {
+		errs.Add(field, fmt.Sprintf("Scene %d prompt %s", sceneNumber, err))
+		return false
+	}
+	return true
+}
+
+// SceneEditInput holds a user's changes to one stored scene; nil fields are left unchanged.
+// Callers should trim whitespace before validating.
+type SceneEditInput struct {
+	GenerationPrompt *string
+	Action           *string
+	Mood             *string
+	Camera           *string
+	NegativePrompt   *string
+	Seed             *int64
+}
+
+// ValidateSceneEdit checks edits made to a scene before it is regenerated. The generation
+// prompt gets the same checks as GPT-4o generated prompts.
+func ValidateSceneEdit(sceneNumber int, in SceneEditInput) Errors {
+	var errs Errors
+	if in.GenerationPrompt != nil {
+		ValidateScenePrompt(&errs, "generation_prompt", sceneNumber, *in.GenerationPrompt)
+	}
+	if in.Action != nil {
+		if *in.Action == "" {
+			errs.Add("action", "Action cannot be empty")
+		}
+		errs.maxLength("action", *in.Action, MaxSceneActionLength)
+	}
+	if in.Mood != nil {
+		if *in.Mood == "" {
+			errs.Add("mood", "Mood cannot be empty", SceneMoods...)
+		}
+		errs.oneOf("mood", *in.Mood, SceneMoods)
+	}
+	if in.Camera != nil {
+		if *in.Camera == "" {
+			errs.Add("camera", "Camera cannot be empty", CameraMoves...)
+		}
+		errs.oneOf("camera", *in.Camera, CameraMoves)
+	}
+	if in.NegativePrompt != nil {
+		errs.maxLength("negative_prompt", *in.NegativePrompt, adapters.MaxNegativePromptLength)
+	}
+	if in.Seed != nil && (*in.Seed < 0 || *in.Seed > adapters.MaxSeed) {
+		errs.Add("seed", fmt.Sprintf("Seed must be between 0 and %d", adapters.MaxSeed))
+	}
+	return errs
+}
+
+// FromScriptInput holds the settings of a job rerun from a stored script, overrides applied
+type FromScriptInput struct {
+	AspectRatio    string
+	Model          string
+	StartImage     string
+	Pharmaceutical bool      // The script was written with a voice or side effects
+	SceneDurations []float64 // Seconds of each stored scene, in order
+}
+
+// ValidateFromScript checks a job rerun from a stored script. Scene lengths are fixed by the
+// script, so each is rechecked against the chosen model's clip durations.
+func ValidateFromScript(in FromScriptInput) Errors {
+	var errs Errors
+	errs.oneOf("aspect_ratio", in.AspectRatio, AspectRatios)
+	validateURL(&errs, "start_image", in.StartImage)
+	if in.Pharmaceutical && in.StartImage == "" {
+		errs.Add("start_image", "Product image is required for pharmaceutical ads")
+	}
+
+	adapterType, err := adapters.ParseAdapterType(in.Model)
+	if err != nil {
+		errs.Add("model", "Invalid video model. Choose 'veo' or 'kling'", Models...)
+		return errs
+	}
+	if len(in.SceneDurations) == 0 {
+		errs.Add("scenes", "Script has no scenes")
+	}
+	durations := adapters.ClipDurations(adapterType)
+	allowed := make([]string, len(durations))
+	for i, d := range durations {
+		allowed[i] = strconv.Itoa(d)
+	}
+	for i, d := range in.SceneDurations {
+		if !adapters.IsValidClipDuration(adapterType, d) {
+			errs.Add(fmt.Sprintf("scenes[%d].duration", i),
+				fmt.Sprintf("Scene %d is %g seconds, which %s can't generate", i+1, d, adapterType), allowed...)
+		}
+	}
+	return errs
+}
+
+// PreferencesInput holds a user's default generation settings; empty fields have no preference.
+// Callers should trim whitespace before validating.
+type PreferencesInput struct {
+	AspectRatio      string
+	Model            string
+	Style            string
+	Tone             string
+	Tempo            string
+	Platform         string
+	Voice            string
+	TTSProvider      string
+	NarratorProvider string // The provider that would narrate with TTSProvider: it, the server default or its fallback
+	ContinuityMode   string
+}
+
+// ValidatePreferences checks a user's default generation settings against the values a generate
+// request allows. An ElevenLabs voice ID can be preferred while ElevenLabs would narrate it.
+// Should the server's default provider change later, generate requests the voice is filled into
+// are rejected with the same error.
+func ValidatePreferences(in PreferencesInput) Errors {
+	var errs Errors
+	errs.oneOf("aspect_ratio", in.AspectRatio, AspectRatios)
+	if _, err := adapters.ParseAdapterType(in.Model); err != nil {
+		errs.Add("model", "Invalid video model. Choose 'veo' or 'kling'", Models...)
+	}
+	errs.oneOf("style", in.Style, Styles)
+	errs.oneOf("tone", in.Tone, Tones)
+	errs.oneOf("tempo", in.Tempo, Tempos)
+	errs.oneOf("platform", in.Platform, Platforms)
+	errs.oneOf("tts_provider", in.TTSProvider, TTSProviders)
+	switch {
+	case !adapters.IsElevenLabsVoiceID(in.Voice):
+		errs.oneOf("voice", in.Voice, Voices)
+	case in.NarratorProvider != string(adapters.TTSProviderElevenLabs):
+		errs.Add("voice", "ElevenLabs voices are not available. Choose 'male' or 'female'", Voices...)
+	}
+	errs.oneOf("continuity_mode", in.ContinuityMode, ContinuityModes)
+	return errs
+}
+
+// JobDetailsInput holds the edits to a job's title and note; nil fields are unchanged.
+// Callers should trim whitespace before validating.
+type JobDetailsInput struct {
+	Title *string
+	Note  *string
+}
+
+// ValidateJobDetails checks edits made to a job's title and note. A title can be changed but
+// not removed; an empty note clears it.
+func ValidateJobDetails(in JobDetailsInput) Errors {
+	var errs Errors
+	if in.Title == nil && in.Note == nil {
+		errs.Add("title", "Provide a title or note to update")
+		return errs
+	}
+	if in.Title != nil {
+		if *in.Title == "" {
+			errs.Add("title", "Title cannot be empty")
+		}
+		errs.maxLength("title", *in.Title, MaxTitleLength)
+	}
+	if in.Note != nil {
+		errs.maxLength("note", *in.Note, MaxNoteLength)
+	}
+	return errs
+}
+
+// RecomposeInput holds the overlay changes of a job's recomposed video; nil fields are
+// unchanged. Callers should trim whitespace before validating.
+type RecomposeInput struct {
+	SideEffectsText      *string
+	SideEffectsStartTime *float64
+	SideEffectsOverflow  *string
+	BurnCaptions         *bool
+	LogoOverlay          *domain.LogoOverlay
+	HasSideEffectsText   bool    // The job shows side effects text, before these changes
+	Pharmaceutical       bool    // The job was made with a side effects disclosure
+	DisclaimerTimed      bool    // The job's disclaimer spec sets when the side effects show
+	HasCaptions          bool    // The job has narrator captions to burn in
+	VideoDuration        float64 // Seconds, end card included
+}
+
+// ValidateRecompose checks the overlay changes of a recomposed video. A pharmaceutical ad keeps
+// its disclosure, and a logo must be an upload since the brand guidelines aren't reread.
+func ValidateRecompose(in RecomposeInput) Errors {
+	var errs Errors
+	if in.SideEffectsText != nil {
+		switch n := len(*in.SideEffectsText); {
+		case n == 0 && in.Pharmaceutical:
+			errs.Add("side_effects_text", "Side effects disclosure is required for pharmaceutical ads")
+		case n > 0 && n < MinSideEffectsLength:
+			errs.Add("side_effects_text", fmt.Sprintf("Side effects text must be at least %d characters", MinSideEffectsLength))
+		case n > MaxSideEffectsLength:
+			errs.Add("side_effects_text",
+				fmt.Sprintf("Side effects text cannot exceed %d characters (currently: %d)", MaxSideEffectsLength, n))
+		}
+	}
+	if start := in.SideEffectsStartTime; start != nil {
+		shown := in.HasSideEffectsText
+		if in.SideEffectsText != nil {
+			shown = *in.SideEffectsText != ""
+		}
+		switch {
+		case !shown:
+			errs.Add("side_effects_start_time", "Side effects start time requires side effects text")
+		case in.DisclaimerTimed:
+			errs.Add("side_effects_start_time", "Side effects are timed to the job's disclaimer and can't be moved")
+		case *start < 0 || *start >= in.VideoDuration:
+			errs.Add("side_effects_start_time", fmt.Sprintf("Side effects must start within the video's %g seconds", in.VideoDuration))
+		}
+	}
+	if in.SideEffectsOverflow != nil {
+		errs.oneOf("side_effects_overflow", *in.SideEffectsOverflow, SideEffectsOverflowModes)
+	}
+	if in.BurnCaptions != nil && *in.BurnCaptions && !in.HasCaptions {
+		errs.Add("burn_captions", "Job has no captions to burn in")
+	}
+	if in.LogoOverlay != nil && in.LogoOverlay.UseGuidelineLogo {
+		errs.Add("logo_overlay.use_guideline_logo", "Recomposing takes an uploaded logo asset")
+	} else {
+		validateLogoOverlay(&errs, GenerateInput{LogoOverlay: in.LogoOverlay})
+	}
+	return errs
+}
+
+func validateDuration(errs *Errors, duration int, adapterType adapters.AdapterType) {
+	if duration >= MinDuration && duration <= MaxDuration && adapters.IsAchievableDuration(adapterType, duration) {
+		return
+	}
+
+	supported := SupportedDurations(adapterType)
+	allowed := make([]string, len(supported))
+	for i, d := range supported {
+		allowed[i] = strconv.Itoa(d)
+	}
+	errs.Add("duration", DurationMessage(adapterType), allowed...)
+}
+
+func validateURL(errs *Errors, field, value string) {
+	if value == "" {
+		return
+	}
+	if len(value) > MaxURLLength {
+		errs.maxLength(field, value, MaxURLLength)
+		return
+	}
+	u, err := url.ParseRequestURI(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		errs.Add(field, fmt.Sprintf("%s must be a valid URL", field))
+	}
+}
+
+// validateImageRef checks an image given as a URL or as the key of an upload
+// ("users/{id}/uploads/...", after the asset key prefix if one is configured). Whether the
+// upload exists and is the user's can only be checked against storage, so that is left to the
+// handler.
+func validateImageRef(errs *Errors, field, value string) {
+	if len(value) > MaxURLLength {
+		errs.maxLength(field, value, MaxURLLength)
+		return
+	}
+	if value == "" || isUploadKey(value) {
+		return
+	}
+	u, err := url.ParseRequestURI(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		errs.Add(field, fmt.Sprintf("%s must be a valid URL or the key of an uploaded asset", field))
+	}
+}
+
+// isUploadKey reports whether value looks like the key of a user's asset rather than a URL
+func isUploadKey(value string) bool {
+	return !strings.Contains(value, "://") &&
+		(strings.HasPrefix(value, "users/") || strings.Contains(value, "/users/"))
+}
+
+// validateLogoOverlay checks the logo's placement. Whether the asset exists and is a still
+// image can only be checked against storage, so that is left to the handler.
+func validateLogoOverlay(errs *Errors, in GenerateInput) {
+	logo := in.LogoOverlay
+	if logo == nil {
+		return
+	}
+
+	switch {
+	case logo.Asset == "" && !logo.UseGuidelineLogo:
+		errs.Add("logo_overlay.asset", "Logo asset is required (an uploaded asset or use_guideline_logo)")
+	case logo.Asset != "" && logo.UseGuidelineLogo:
+		errs.Add("logo_overlay.asset", "Choose either a logo asset or use_guideline_logo, not both")
+	case logo.UseGuidelineLogo && !in.BrandGuidelines:
+		errs.Add("logo_overlay.use_guideline_logo", "use_guideline_logo requires use_brand_guidelines or guideline_id")
+	}
+
+	errs.maxLength("logo_overlay.asset", logo.Asset, MaxURLLength)
+	errs.oneOf("logo_overlay.position", logo.Position, LogoPositions)
+	if logo.Size != 0 && (logo.Size < MinLogoSize || logo.Size > MaxLogoSize) {
+		errs.Add("logo_overlay.size", fmt.Sprintf("Logo size must be between %d and %d percent of the video width", MinLogoSize, MaxLogoSize))
+	}
+	if logo.Opacity < 0 || logo.Opacity > 1 {
+		errs.Add("logo_overlay.opacity", "Logo opacity must be between 0 and 1")
+	}
+	if logo.Start < 0 {
+		errs.Add("logo_overlay.start", "Logo start time cannot be negative")
+	}
+	if logo.End != 0 && logo.End <= logo.Start {
+		errs.Add("logo_overlay.end", "Logo end time must be after its start time")
+	}
+}
+
+// validateEndCard checks the end card's duration, background and the assets it depends on
+func validateEndCard(errs *Errors, in GenerateInput) {
+	card := in.EndCard
+	if card == nil {
+		return
+	}
+
+	if card.Duration != 0 && (card.Duration < MinEndCardDuration || card.Duration > MaxEndCardDuration) {
+		errs.Add("end_card.duration", fmt.Sprintf("End card duration must be between %d and %d seconds", MinEndCardDuration, MaxEndCardDuration))
+	}
+	switch {
+	case card.BackgroundColor != "" && card.UseProductImage:
+		errs.Add("end_card.background_color", "Choose either a background color or use_product_image, not both")
+	case card.BackgroundColor != "" && !hexColorPattern.MatchString(card.BackgroundColor):
+		errs.Add("end_card.background_color", "End card background color must be a hex color such as #1A2B3C")
+	case card.UseProductImage && in.StartImage == "":
+		errs.Add("end_card.use_product_image", "use_product_image requires start_image")
+	}
+	if card.ShowLogo && in.LogoOverlay == nil {
+		errs.Add("end_card.show_logo", "show_logo requires logo_overlay")
+	}
+	errs.maxLength("end_card.legal_text", card.LegalText, MaxEndCardLegalLength)
+}
+
+// validateOutputSpec checks the final encode settings against what the composer supports
+func validateOutputSpec(errs *Errors, spec *domain.OutputSpec) {
+	if spec == nil {
+		return
+	}
+
+	errs.oneOf("output.resolution", spec.Resolution, OutputResolutions)
+	errs.oneOf("output.codec", spec.Codec, OutputCodecs)
+	errs.oneOf("output.container", spec.Container, OutputContainers)
+	if spec.CRF != 0 && (spec.CRF < MinOutputCRF || spec.CRF > MaxOutputCRF) {
+		errs.Add("output.crf", fmt.Sprintf("CRF must be between %d and %d", MinOutputCRF, MaxOutputCRF))
+	}
+	if spec.MaxBitrateKbps != 0 && (spec.MaxBitrateKbps < MinOutputBitrateKbps || spec.MaxBitrateKbps > MaxOutputBitrateKbps) {
+		errs.Add("output.max_bitrate_kbps", fmt.Sprintf("Max bitrate must be between %d and %d kbps", MinOutputBitrateKbps, MaxOutputBitrateKbps))
+	}
+}
+
+// validateProductImages checks the image list. Ownership and resolution are checked against
+// storage by the handler.
+func validateProductImages(errs *Errors, images []domain.ProductImage) {
+	if len(images) > MaxProductImages {
+		errs.Add("product_images", fmt.Sprintf("At most %d product images are allowed (got %d)", MaxProductImages, len(images)))
+		return
+	}
+	for i, image := range images {
+		field := fmt.Sprintf("product_images[%d]", i)
+		if strings.TrimSpace(image.Asset) == "" {
+			errs.Add(field+".asset", "Product image asset is required")
+		}
+		errs.maxLength(field+".asset", image.Asset, MaxURLLength)
+		errs.maxLength(field+".hint", image.Hint, MaxProductImageHint)
+	}
+}
+
+// validateScenePlan checks that every planned scene is a clip length the model can generate,
+// and that together they fill the requested duration exactly
+func validateScenePlan(errs *Errors, plan []domain.PlannedScene, duration int, adapterType adapters.AdapterType) {
+	if len(plan) == 0 {
+		return
+	}
+
+	durations := adapters.ClipDurations(adapterType)
+	allowed := make([]string, len(durations))
+	for i, d := range durations {
+		allowed[i] = strconv.Itoa(d)
+	}
+	total := 0
+	for i, scene := range plan {
+		field := fmt.Sprintf("scene_plan[%d]", i)
+		if !adapters.IsValidClipDuration(adapterType, float64(scene.Duration)) {
+			errs.Add(field+".duration",
+				fmt.Sprintf("Scene %d is %d seconds, which %s can't generate", i+1, scene.Duration, adapterType), allowed...)
+		}
+		errs.maxLength(field+".hint", scene.Hint, MaxScenePlanHint)
+		total += scene.Duration
+	}
+	if total != duration {
+		errs.Add("scene_plan", fmt.Sprintf("Scene durations add up to %d seconds, but duration is %d", total, duration))
+	}
+}
+
+// validateVariants checks the variant count. Previews hold a single script for approval, so
+// they can't be split into variants.
+func validateVariants(errs *Errors, in GenerateInput) {
+	switch {
+	case in.Variants < 0 || in.Variants > MaxVariants:
+		errs.Add("variants", fmt.Sprintf("Variants must be between 1 and %d", MaxVariants))
+	case in.Variants > 1 && in.Preview:
+		errs.Add("variants", "Variants can't be combined with preview")
+	}
+}
+
+// validatePharmaceutical enforces the voice, side effects and product image pairing
+// required for pharmaceutical ads
+func validatePharmaceutical(errs *Errors, in GenerateInput) {
+	switch in.Voice {
+	case "":
+		errs.Add("voice", "Please select a narrator voice (male or female)", Voices...)
+	case "male", "female":
+	default:
+		switch {
+		case !adapters.IsElevenLabsVoiceID(in.Voice):
+			errs.Add("voice", "Invalid voice selection. Choose 'male' or 'female'", Voices...)
+		case in.NarratorProvider != string(adapters.TTSProviderElevenLabs):
+			errs.Add("voice", "ElevenLabs voices are not available. Choose 'male' or 'female'", Voices...)
+		}
+	}
+
+	switch n := len(in.SideEffects); {
+	case n == 0:
+		errs.Add("side_effects", "Side effects disclosure is required for pharmaceutical ads")
+	case n < MinSideEffectsLength:
+		errs.Add("side_effects", fmt.Sprintf("Side effects text must be at least %d characters", MinSideEffectsLength))
+	case n > MaxSideEffectsLength:
+		errs.Add("side_effects",
+			fmt.Sprintf("Side effects text cannot exceed %d characters (currently: %d)", MaxSideEffectsLength, n))
+	}
+
+	if in.StartImage == "" {
+		errs.Add("start_image", "Product image is required for pharmaceutical ads")
+	}
+}
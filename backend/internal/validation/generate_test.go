@@ -0,0 +1,894 @@
+package validation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omnigen/backend/internal/adapters"
+	"github.com/omnigen/backend/internal/domain"
+)
+
+func validInput() GenerateInput {
+	return GenerateInput{
+		Prompt:      "A sunrise run through the city for a new sneaker launch",
+		Duration:    30,
+		AspectRatio: "16:9",
+	}
+}
+
+func validPharmaInput() GenerateInput {
+	in := validInput()
+	in.Voice = "female"
+	in.SideEffects = "May cause dizziness and nausea."
+	in.StartImage = "https://example.com/product.png"
+	return in
+}
+
+func TestValidateGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    func() GenerateInput
+		mutate  func(*GenerateInput)
+		field   string // empty = expect no errors
+		message string
+		allowed []string
+	}{
+		{name: "valid minimal request", base: validInput, mutate: func(in *GenerateInput) {}},
+		{name: "valid pharma request", base: validPharmaInput, mutate: func(in *GenerateInput) {}},
+		{
+			name: "valid fully specified request",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.Model, in.Duration = "kling", 20
+				in.Style, in.Tone, in.Tempo = "cinematic", "premium", "fast"
+				in.Platform, in.Goal = "tiktok", "sales"
+				in.Title = "Launch"
+				in.StyleReferenceImage = "https://example.com/style.jpg"
+			},
+		},
+		{
+			name:    "missing prompt",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Prompt = "" },
+			field:   "prompt",
+			message: "Prompt is required",
+		},
+		{
+			name:    "prompt too short",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Prompt = "too short" },
+			field:   "prompt",
+			message: "Prompt must be at least 10 characters",
+		},
+		{
+			name:    "prompt too long",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Prompt = strings.Repeat("a", 2001) },
+			field:   "prompt",
+			message: "Prompt cannot exceed 2000 characters (currently: 2001)",
+		},
+		{
+			name:    "missing aspect ratio",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.AspectRatio = "" },
+			field:   "aspect_ratio",
+			message: "Aspect ratio is required",
+			allowed: []string{"16:9", "9:16", "1:1"},
+		},
+		{
+			name:    "unsupported aspect ratio",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.AspectRatio = "4:3" },
+			field:   "aspect_ratio",
+			message: "Invalid aspect_ratio '4:3'. Choose one of: 16:9, 9:16, 1:1",
+			allowed: []string{"16:9", "9:16", "1:1"},
+		},
+		{
+			name:    "unknown model",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Model = "sora" },
+			field:   "model",
+			message: "Invalid video model. Choose 'veo' or 'kling'",
+			allowed: []string{"veo", "kling"},
+		},
+		{
+			name:    "duration below range",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Duration = 8 },
+			field:   "duration",
+			message: "Duration must be between 10-60 seconds and achievable with 4, 6, or 8 second clips",
+		},
+		{
+			name:    "duration above range",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Duration = 62 },
+			field:   "duration",
+			message: "Duration must be between 10-60 seconds and achievable with 4, 6, or 8 second clips",
+		},
+		{
+			name:    "duration not achievable with Veo clips",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Duration = 33 },
+			field:   "duration",
+			message: "Duration must be between 10-60 seconds and achievable with 4, 6, or 8 second clips",
+		},
+		{
+			name:    "duration not achievable with Kling clips",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Model, in.Duration = "kling", 12 },
+			field:   "duration",
+			message: "Duration must be between 10-60 seconds and achievable with 5 or 10 second clips",
+			allowed: []string{"10", "15", "20", "25", "30", "35", "40", "45", "50", "55", "60"},
+		},
+		{
+			name:    "invalid style",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Style = "noir" },
+			field:   "style",
+			message: "Invalid style 'noir'. Choose one of: cinematic, documentary, energetic, minimal, dramatic, playful",
+			allowed: Styles,
+		},
+		{
+			name:    "invalid tone",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Tone = "angry" },
+			field:   "tone",
+			message: "Invalid tone 'angry'. Choose one of: premium, friendly, edgy, inspiring, humorous",
+			allowed: Tones,
+		},
+		{
+			name:    "invalid tempo",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Tempo = "presto" },
+			field:   "tempo",
+			message: "Invalid tempo 'presto'. Choose one of: slow, medium, fast",
+			allowed: Tempos,
+		},
+		{
+			name:    "invalid side effects overflow",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.SideEffectsOverflow = "marquee" },
+			field:   "side_effects_overflow",
+			message: "Invalid side_effects_overflow 'marquee'. Choose one of: paginate, scroll",
+			allowed: SideEffectsOverflowModes,
+		},
+		{
+			name:    "invalid continuity mode",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.ContinuityMode = "blend" },
+			field:   "continuity_mode",
+			message: "Invalid continuity_mode 'blend'. Choose one of: frame, style, none",
+			allowed: ContinuityModes,
+		},
+		{
+			name: "valid logo overlay",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.LogoOverlay = &domain.LogoOverlay{Asset: "users/u1/uploads/product_images/1_logo.png", Position: "top_right", Size: 15, Opacity: 0.8, Start: 2, End: 10}
+			},
+		},
+		{
+			name: "valid guideline logo",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.BrandGuidelines = true
+				in.LogoOverlay = &domain.LogoOverlay{UseGuidelineLogo: true}
+			},
+		},
+		{
+			name:    "logo overlay without asset",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.LogoOverlay = &domain.LogoOverlay{Position: "top_left"} },
+			field:   "logo_overlay.asset",
+			message: "Logo asset is required (an uploaded asset or use_guideline_logo)",
+		},
+		{
+			name:    "guideline logo without brand guidelines",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.LogoOverlay = &domain.LogoOverlay{UseGuidelineLogo: true} },
+			field:   "logo_overlay.use_guideline_logo",
+			message: "use_guideline_logo requires use_brand_guidelines or guideline_id",
+		},
+		{
+			name: "invalid logo position",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.LogoOverlay = &domain.LogoOverlay{Asset: "users/u1/uploads/logo.png", Position: "center"}
+			},
+			field:   "logo_overlay.position",
+			message: "Invalid logo_overlay.position 'center'. Choose one of: top_left, top_right, bottom_left, bottom_right, bottom_center",
+			allowed: LogoPositions,
+		},
+		{
+			name: "logo too large",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.LogoOverlay = &domain.LogoOverlay{Asset: "users/u1/uploads/logo.png", Size: 80}
+			},
+			field:   "logo_overlay.size",
+			message: "Logo size must be between 1 and 50 percent of the video width",
+		},
+		{
+			name: "logo opacity out of range",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.LogoOverlay = &domain.LogoOverlay{Asset: "users/u1/uploads/logo.png", Opacity: 1.5}
+			},
+			field:   "logo_overlay.opacity",
+			message: "Logo opacity must be between 0 and 1",
+		},
+		{
+			name: "logo window ends before it starts",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.LogoOverlay = &domain.LogoOverlay{Asset: "users/u1/uploads/logo.png", Start: 8, End: 4}
+			},
+			field:   "logo_overlay.end",
+			message: "Logo end time must be after its start time",
+		},
+		{
+			name: "valid end card",
+			base: validPharmaInput,
+			mutate: func(in *GenerateInput) {
+				in.LogoOverlay = &domain.LogoOverlay{Asset: "users/u1/uploads/logo.png"}
+				in.EndCard = &domain.EndCard{Duration: 3.5, UseProductImage: true, ShowLogo: true, LegalText: "Rx only."}
+			},
+		},
+		{
+			name:    "end card too long",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.EndCard = &domain.EndCard{Duration: 6} },
+			field:   "end_card.duration",
+			message: "End card duration must be between 2 and 4 seconds",
+		},
+		{
+			name:    "end card color not hex",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.EndCard = &domain.EndCard{BackgroundColor: "navy"} },
+			field:   "end_card.background_color",
+			message: "End card background color must be a hex color such as #1A2B3C",
+		},
+		{
+			name: "end card color and product image",
+			base: validPharmaInput,
+			mutate: func(in *GenerateInput) {
+				in.EndCard = &domain.EndCard{BackgroundColor: "#000000", UseProductImage: true}
+			},
+			field:   "end_card.background_color",
+			message: "Choose either a background color or use_product_image, not both",
+		},
+		{
+			name:    "end card product image without start image",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.EndCard = &domain.EndCard{UseProductImage: true} },
+			field:   "end_card.use_product_image",
+			message: "use_product_image requires start_image",
+		},
+		{
+			name:    "end card logo without logo overlay",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.EndCard = &domain.EndCard{ShowLogo: true} },
+			field:   "end_card.show_logo",
+			message: "show_logo requires logo_overlay",
+		},
+		{
+			name: "valid product images",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ProductImages = []domain.ProductImage{{Asset: "users/u1/uploads/bottle.png", Hint: "bottle on a counter"}, {Asset: "users/u1/uploads/box.jpg"}}
+			},
+		},
+		{
+			name: "too many product images",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ProductImages = make([]domain.ProductImage, 9)
+			},
+			field:   "product_images",
+			message: "At most 8 product images are allowed (got 9)",
+		},
+		{
+			name: "product image without asset",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ProductImages = []domain.ProductImage{{Asset: "users/u1/uploads/bottle.png"}, {Hint: "box"}}
+			},
+			field:   "product_images[1].asset",
+			message: "Product image asset is required",
+		},
+		{
+			name: "valid scene plan",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ScenePlan = []domain.PlannedScene{{Duration: 8, Hint: "sunlit kitchen"}, {Duration: 8}, {Duration: 8}, {Duration: 6}}
+			},
+		},
+		{
+			name: "scene plan with a clip length the model can't generate",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ScenePlan = []domain.PlannedScene{{Duration: 8}, {Duration: 8}, {Duration: 4}, {Duration: 10}}
+			},
+			field:   "scene_plan[3].duration",
+			message: "Scene 4 is 10 seconds, which veo can't generate",
+			allowed: []string{"4", "6", "8"},
+		},
+		{
+			name: "scene plan not adding up to the duration",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ScenePlan = []domain.PlannedScene{{Duration: 8}, {Duration: 8}, {Duration: 8}}
+			},
+			field:   "scene_plan",
+			message: "Scene durations add up to 24 seconds, but duration is 30",
+		},
+		{
+			name: "scene plan hint too long",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ScenePlan = []domain.PlannedScene{{Duration: 10, Hint: strings.Repeat("a", 201)}, {Duration: 10}, {Duration: 10}}
+				in.Model = "kling"
+			},
+			field:   "scene_plan[0].hint",
+			message: "scene_plan[0].hint cannot exceed 200 characters (currently: 201)",
+		},
+		{
+			name:   "three variants",
+			base:   validInput,
+			mutate: func(in *GenerateInput) { in.Variants = 3 },
+		},
+		{
+			name:    "too many variants",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Variants = 4 },
+			field:   "variants",
+			message: "Variants must be between 1 and 3",
+		},
+		{
+			name: "variants with preview",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.Variants = 2
+				in.Preview = true
+			},
+			field:   "variants",
+			message: "Variants can't be combined with preview",
+		},
+		{
+			name:    "invalid platform",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Platform = "myspace" },
+			field:   "platform",
+			message: "Invalid platform 'myspace'. Choose one of: instagram, tiktok, youtube, facebook",
+			allowed: Platforms,
+		},
+		{
+			name:    "invalid goal",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Goal = "fame" },
+			field:   "goal",
+			message: "Invalid goal 'fame'. Choose one of: awareness, sales, engagement, signups",
+			allowed: Goals,
+		},
+		{
+			name:    "title too long",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Title = strings.Repeat("t", 101) },
+			field:   "title",
+			message: "title cannot exceed 100 characters (currently: 101)",
+		},
+		{
+			name:    "audience too long",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Audience = strings.Repeat("a", 201) },
+			field:   "audience",
+			message: "audience cannot exceed 200 characters (currently: 201)",
+		},
+		{
+			name:    "call to action too long",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.CallToAction = strings.Repeat("c", 101) },
+			field:   "call_to_action",
+			message: "call_to_action cannot exceed 100 characters (currently: 101)",
+		},
+		{
+			name:   "start image stored under an asset key prefix",
+			base:   validInput,
+			mutate: func(in *GenerateInput) { in.StartImage = "env/staging/users/u1/uploads/product_images/1_a.png" },
+		},
+		{
+			name:    "start image not a URL",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.StartImage = "product.png" },
+			field:   "start_image",
+			message: "start_image must be a valid URL or the key of an uploaded asset",
+		},
+		{
+			name:    "style reference not a URL",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.StyleReferenceImage = "not a url" },
+			field:   "style_reference_image",
+			message: "style_reference_image must be a valid URL or the key of an uploaded asset",
+		},
+		{
+			name:    "side effects without voice",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.Voice = "" },
+			field:   "voice",
+			message: "Please select a narrator voice (male or female)",
+			allowed: Voices,
+		},
+		{
+			name:    "invalid voice",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.Voice = "robotic" },
+			field:   "voice",
+			message: "Invalid voice selection. Choose 'male' or 'female'",
+			allowed: Voices,
+		},
+		{
+			name: "elevenlabs voice id",
+			base: validPharmaInput,
+			mutate: func(in *GenerateInput) {
+				in.TTSProvider, in.NarratorProvider, in.Voice = "elevenlabs", "elevenlabs", "21m00Tcm4TlvDq8ikWAM"
+			},
+		},
+		{
+			name: "voice id with elevenlabs as the server default",
+			base: validPharmaInput,
+			mutate: func(in *GenerateInput) {
+				in.NarratorProvider, in.Voice = "elevenlabs", "21m00Tcm4TlvDq8ikWAM"
+			},
+		},
+		{
+			name:    "voice id without elevenlabs",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.Voice = "21m00Tcm4TlvDq8ikWAM" },
+			field:   "voice",
+			message: "ElevenLabs voices are not available. Choose 'male' or 'female'",
+			allowed: Voices,
+		},
+		{
+			name: "voice id with elevenlabs requested but not configured",
+			base: validPharmaInput,
+			mutate: func(in *GenerateInput) {
+				in.TTSProvider, in.NarratorProvider, in.Voice = "elevenlabs", "openai", "21m00Tcm4TlvDq8ikWAM"
+			},
+			field:   "voice",
+			message: "ElevenLabs voices are not available. Choose 'male' or 'female'",
+			allowed: Voices,
+		},
+		{
+			name:    "invalid tts provider",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.TTSProvider = "polly" },
+			field:   "tts_provider",
+			message: "Invalid tts_provider 'polly'. Choose one of: openai, elevenlabs",
+			allowed: TTSProviders,
+		},
+		{
+			name:   "regional language",
+			base:   validPharmaInput,
+			mutate: func(in *GenerateInput) { in.Language = "es-MX" },
+		},
+		{
+			name:    "unsupported language",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Language = "fr" },
+			field:   "language",
+			message: "Invalid language 'fr'. Choose one of: en, en-US, en-GB, es, es-ES, es-MX, es-US, de, de-DE, de-AT, de-CH",
+			allowed: Languages,
+		},
+		{
+			name:    "voice without side effects",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.SideEffects = "" },
+			field:   "side_effects",
+			message: "Side effects disclosure is required for pharmaceutical ads",
+		},
+		{
+			name:    "side effects too short",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.SideEffects = "short" },
+			field:   "side_effects",
+			message: "Side effects text must be at least 10 characters",
+		},
+		{
+			name:    "side effects too long",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.SideEffects = strings.Repeat("b", 501) },
+			field:   "side_effects",
+			message: "Side effects text cannot exceed 500 characters (currently: 501)",
+		},
+		{
+			name: "valid output spec",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.Output = &domain.OutputSpec{Resolution: "2160p", Codec: "h265", CRF: 20, MaxBitrateKbps: 40000, Container: "mov"}
+			},
+		},
+		{
+			name:    "output codec not allowed",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Output = &domain.OutputSpec{Codec: "vp9"} },
+			field:   "output.codec",
+			message: "Invalid output.codec 'vp9'. Choose one of: h264, h265",
+			allowed: OutputCodecs,
+		},
+		{
+			name:    "output resolution not allowed",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Output = &domain.OutputSpec{Resolution: "1440p"} },
+			field:   "output.resolution",
+			message: "Invalid output.resolution '1440p'. Choose one of: 720p, 1080p, 2160p",
+			allowed: OutputResolutions,
+		},
+		{
+			name:    "output crf out of range",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Output = &domain.OutputSpec{CRF: 51} },
+			field:   "output.crf",
+			message: "CRF must be between 16 and 35",
+		},
+		{
+			name:    "output bitrate out of range",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.Output = &domain.OutputSpec{MaxBitrateKbps: 100} },
+			field:   "output.max_bitrate_kbps",
+			message: "Max bitrate must be between 500 and 50000 kbps",
+		},
+		{
+			name:    "start image URL too long",
+			base:    validInput,
+			mutate:  func(in *GenerateInput) { in.StartImage = "https://example.com/" + strings.Repeat("a", MaxURLLength) },
+			field:   "start_image",
+			message: "start_image cannot exceed 2048 characters (currently: 2068)",
+		},
+		{
+			name: "product image asset too long",
+			base: validInput,
+			mutate: func(in *GenerateInput) {
+				in.ProductImages = []domain.ProductImage{{Asset: "users/u/uploads/" + strings.Repeat("a", MaxURLLength)}}
+			},
+			field:   "product_images[0].asset",
+			message: "product_images[0].asset cannot exceed 2048 characters (currently: 2064)",
+		},
+		{
+			name:    "pharma without product image",
+			base:    validPharmaInput,
+			mutate:  func(in *GenerateInput) { in.StartImage = "" },
+			field:   "start_image",
+			message: "Product image is required for pharmaceutical ads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.base()
+			tt.mutate(&in)
+
+			errs := ValidateGenerate(in)
+
+			if tt.field == "" {
+				if len(errs) != 0 {
+					t.Fatalf("ValidateGenerate() = %v, want no errors", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("ValidateGenerate() = %v, want exactly one error on %s", errs, tt.field)
+			}
+			if errs[0].Field != tt.field {
+				t.Errorf("field = %q, want %q", errs[0].Field, tt.field)
+			}
+			if errs[0].Message != tt.message {
+				t.Errorf("message = %q, want %q", errs[0].Message, tt.message)
+			}
+			if tt.allowed != nil && !reflect.DeepEqual(errs[0].AllowedValues, tt.allowed) {
+				t.Errorf("allowed_values = %v, want %v", errs[0].AllowedValues, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestValidateGenerateCollectsAllErrors(t *testing.T) {
+	errs := ValidateGenerate(GenerateInput{
+		Prompt:      "short",
+		Duration:    7,
+		AspectRatio: "21:9",
+		Model:       "kling",
+		Goal:        "fame",
+		Voice:       "male",
+	})
+
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field)
+	}
+	want := []string{"prompt", "aspect_ratio", "duration", "goal", "side_effects", "start_image"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+}
+
+func TestSupportedDurations(t *testing.T) {
+	veo := SupportedDurations(adapters.AdapterTypeVeo)
+	if veo[0] != 10 || veo[len(veo)-1] != 60 || len(veo) != 26 {
+		t.Errorf("Veo durations = %v, want every even duration 10-60", veo)
+	}
+
+	kling := SupportedDurations(adapters.AdapterTypeKling)
+	want := []int{10, 15, 20, 25, 30, 35, 40, 45, 50, 55, 60}
+	if !reflect.DeepEqual(kling, want) {
+		t.Errorf("Kling durations = %v, want %v", kling, want)
+	}
+}
+
+func TestErrorsAPIError(t *testing.T) {
+	var errs Errors
+	errs.Add("prompt", "Prompt is required")
+
+	apiErr := errs.APIError()
+	if apiErr.Status != 422 || apiErr.Code != "VALIDATION_FAILED" {
+		t.Errorf("APIError() = %d %s, want 422 VALIDATION_FAILED", apiErr.Status, apiErr.Code)
+	}
+	if apiErr.Message != "Prompt is required" {
+		t.Errorf("single error message = %q", apiErr.Message)
+	}
+
+	errs.Add("duration", "Duration is invalid")
+	if got := errs.APIError().Message; got != "Prompt is required (and 1 more)" {
+		t.Errorf("multi error message = %q", got)
+	}
+}
+
+func TestValidateScenePrompt(t *testing.T) {
+	var errs Errors
+	good := "Close-up of a ceramic mug on a marble counter, soft morning light, steam rising slowly"
+
+	if !ValidateScenePrompt(&errs, "scenes[0].generation_prompt", 1, good) {
+		t.Fatalf("valid prompt rejected: %v", errs)
+	}
+	if ValidateScenePrompt(&errs, "scenes[1].generation_prompt", 2, strings.Repeat("a", 2001)) {
+		t.Fatal("expected overlong prompt to be rejected")
+	}
+	if ValidateScenePrompt(&errs, "scenes[2].generation_prompt", 3, "tiny") {
+		t.Fatal("expected short prompt to be rejected")
+	}
+	if len(errs) != 2 || errs[0].Field != "scenes[1].generation_prompt" || errs[1].Field != "scenes[2].generation_prompt" {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestValidateSceneOrder(t *testing.T) {
+	for _, order := range [][]int{{1, 2, 3}, {3, 1, 2}, {2, 3}, {3, 1}} {
+		var errs Errors
+		if !ValidateSceneOrder(&errs, "order", order, 3) {
+			t.Errorf("order %v rejected: %v", order, errs)
+		}
+	}
+
+	tests := []struct {
+		order []int
+		field string
+		want  string
+	}{
+		{order: []int{1, 4, 2}, field: "order[1]", want: "Invalid scene number 4. Job has 3 scenes."},
+		{order: []int{0, 1}, field: "order[0]", want: "Invalid scene number 0. Job has 3 scenes."},
+		{order: []int{2, 1, 2}, field: "order[2]", want: "Scene 2 is listed more than once"},
+		{order: []int{3}, field: "order", want: "At least 2 scenes must remain (got 1)"},
+		{order: nil, field: "order", want: "At least 2 scenes must remain (got 0)"},
+	}
+	for _, tt := range tests {
+		var errs Errors
+		if ValidateSceneOrder(&errs, "order", tt.order, 3) {
+			t.Errorf("order %v accepted", tt.order)
+			continue
+		}
+		if fe, ok := errs.Field(tt.field); !ok || fe.Message != tt.want {
+			t.Errorf("order %v: errors = %v, want %s: %q", tt.order, errs, tt.field, tt.want)
+		}
+	}
+}
+
+func TestValidateSceneTrim(t *testing.T) {
+	for _, trim := range [][2]float64{{0, 0}, {1, 0}, {0.5, 1.25}, {3, 3}} {
+		if errs := ValidateSceneTrim(trim[0], trim[1], 8); len(errs) != 0 {
+			t.Errorf("trim %v: errors = %v", trim, errs)
+		}
+	}
+
+	tests := []struct {
+		start, end float64
+		field      string
+		want       string
+	}{
+		{start: -0.5, end: 0, field: "trim_start", want: "trim_start cannot be negative"},
+		{start: 0, end: -1, field: "trim_end", want: "trim_end cannot be negative"},
+		{start: 3, end: 3.04, field: "trim_end", want: "Trimming 6.04s from the 8s clip leaves less than the minimum of 2s"},
+		{start: 8, end: 0, field: "trim_end", want: "Trimming 8s from the 8s clip leaves less than the minimum of 2s"},
+	}
+	for _, tt := range tests {
+		fe, ok := ValidateSceneTrim(tt.start, tt.end, 8).Field(tt.field)
+		if !ok || fe.Message != tt.want {
+			t.Errorf("trim %v/%v: error = %+v, want %s: %q", tt.start, tt.end, fe, tt.field, tt.want)
+		}
+	}
+}
+
+func TestValidateSceneEdit(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	good := "Close-up of a ceramic mug on a marble counter, soft morning light, steam rising slowly"
+
+	if errs := ValidateSceneEdit(2, SceneEditInput{}); len(errs) != 0 {
+		t.Errorf("no edits: errors = %v", errs)
+	}
+	if errs := ValidateSceneEdit(2, SceneEditInput{GenerationPrompt: ptr(good), Action: ptr("She lifts the mug"), Mood: ptr("calm"), Camera: ptr("dolly_in")}); len(errs) != 0 {
+		t.Errorf("valid edits: errors = %v", errs)
+	}
+
+	errs := ValidateSceneEdit(2, SceneEditInput{
+		GenerationPrompt: ptr("A product shot of [insert product name] on a kitchen counter at golden hour"),
+		Action:           ptr(strings.Repeat("a", MaxSceneActionLength+1)),
+		Mood:             ptr("gloomy"),
+		Camera:           ptr(""),
+	})
+	for _, field := range []string{"generation_prompt", "action", "mood", "camera"} {
+		if _, ok := errs.Field(field); !ok {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+	if fe, _ := errs.Field("generation_prompt"); !strings.Contains(fe.Message, "placeholder") {
+		t.Errorf("generation_prompt message = %q", fe.Message)
+	}
+	seed := func(n int64) *int64 { return &n }
+	for _, n := range []int64{0, 4242, adapters.MaxSeed} {
+		if errs := ValidateSceneEdit(2, SceneEditInput{Seed: seed(n)}); len(errs) != 0 {
+			t.Errorf("seed %d: errors = %v", n, errs)
+		}
+	}
+	for _, n := range []int64{-1, adapters.MaxSeed + 1} {
+		if _, ok := ValidateSceneEdit(2, SceneEditInput{Seed: seed(n)}).Field("seed"); !ok {
+			t.Errorf("seed %d: expected an out-of-range error", n)
+		}
+	}
+	if _, ok := ValidateSceneEdit(2, SceneEditInput{NegativePrompt: ptr(strings.Repeat("x", adapters.MaxNegativePromptLength+1))}).Field("negative_prompt"); !ok {
+		t.Error("expected an error for an over-long negative_prompt")
+	}
+}
+
+func TestValidateFromScript(t *testing.T) {
+	veoScenes := []float64{8, 8, 4}
+
+	if errs := ValidateFromScript(FromScriptInput{AspectRatio: "9:16", Model: "veo", SceneDurations: veoScenes}); len(errs) != 0 {
+		t.Errorf("valid input: errors = %v", errs)
+	}
+
+	errs := ValidateFromScript(FromScriptInput{AspectRatio: "9:16", Model: "kling", SceneDurations: veoScenes})
+	for _, field := range []string{"scenes[0].duration", "scenes[1].duration", "scenes[2].duration"} {
+		fe, ok := errs.Field(field)
+		if !ok {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+			continue
+		}
+		if !reflect.DeepEqual(fe.AllowedValues, []string{"5", "10"}) {
+			t.Errorf("%s allowed values = %v", field, fe.AllowedValues)
+		}
+	}
+
+	errs = ValidateFromScript(FromScriptInput{AspectRatio: "4:3", Model: "sora", StartImage: "not a url", Pharmaceutical: true, SceneDurations: veoScenes})
+	for _, field := range []string{"aspect_ratio", "model", "start_image"} {
+		if _, ok := errs.Field(field); !ok {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+
+	if _, ok := ValidateFromScript(FromScriptInput{AspectRatio: "16:9", Pharmaceutical: true, SceneDurations: veoScenes}).Field("start_image"); !ok {
+		t.Error("expected pharmaceutical scripts to require a start image")
+	}
+	if _, ok := ValidateFromScript(FromScriptInput{AspectRatio: "16:9"}).Field("scenes"); !ok {
+		t.Error("expected an error for a script without scenes")
+	}
+}
+
+func TestValidatePreferences(t *testing.T) {
+	for _, in := range []PreferencesInput{
+		{},
+		{AspectRatio: "9:16", Model: "kling", Style: "playful", Tone: "friendly", Tempo: "fast", Platform: "tiktok", Voice: "female", ContinuityMode: "style"},
+		{Voice: "21m00Tcm4TlvDq8ikWAM", TTSProvider: "elevenlabs", NarratorProvider: "elevenlabs"},
+		{Voice: "21m00Tcm4TlvDq8ikWAM", NarratorProvider: "elevenlabs"},
+	} {
+		if errs := ValidatePreferences(in); len(errs) != 0 {
+			t.Errorf("valid input %+v: errors = %v", in, errs)
+		}
+	}
+
+	errs := ValidatePreferences(PreferencesInput{
+		AspectRatio: "4:3", Model: "sora", Style: "noir", Tone: "grim", Tempo: "glacial",
+		Platform: "myspace", TTSProvider: "polly", ContinuityMode: "blend",
+	})
+	for _, field := range []string{"aspect_ratio", "model", "style", "tone", "tempo", "platform", "tts_provider", "continuity_mode"} {
+		if _, ok := errs.Field(field); !ok {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+	if fe, ok := ValidatePreferences(PreferencesInput{AspectRatio: "21:9"}).Field("aspect_ratio"); !ok || !reflect.DeepEqual(fe.AllowedValues, AspectRatios) {
+		t.Errorf("aspect ratio error = %+v, want the allowed ratios", fe)
+	}
+	if _, ok := ValidatePreferences(PreferencesInput{Voice: "21m00Tcm4TlvDq8ikWAM", NarratorProvider: "openai"}).Field("voice"); !ok {
+		t.Error("expected an ElevenLabs voice ID OpenAI would narrate to be rejected")
+	}
+	if _, ok := ValidatePreferences(PreferencesInput{Voice: "robotic", NarratorProvider: "elevenlabs"}).Field("voice"); !ok {
+		t.Error("expected a voice that is no ElevenLabs voice ID to be rejected")
+	}
+}
+
+func TestValidateJobDetails(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	for _, in := range []JobDetailsInput{
+		{Title: ptr("Spring launch")},
+		{Note: ptr("")},
+		{Title: ptr(strings.Repeat("t", MaxTitleLength)), Note: ptr(strings.Repeat("n", MaxNoteLength))},
+	} {
+		if errs := ValidateJobDetails(in); len(errs) != 0 {
+			t.Errorf("valid input %+v: errors = %v", in, errs)
+		}
+	}
+
+	errs := ValidateJobDetails(JobDetailsInput{Title: ptr(strings.Repeat("t", MaxTitleLength+1)), Note: ptr(strings.Repeat("n", MaxNoteLength+1))})
+	for _, field := range []string{"title", "note"} {
+		if _, ok := errs.Field(field); !ok {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+	if fe, ok := ValidateJobDetails(JobDetailsInput{Title: ptr("")}).Field("title"); !ok || fe.Message != "Title cannot be empty" {
+		t.Errorf("empty title: error = %+v", fe)
+	}
+	if _, ok := ValidateJobDetails(JobDetailsInput{}).Field("title"); !ok {
+		t.Error("expected an error when nothing is updated")
+	}
+}
+
+func TestValidateRecompose(t *testing.T) {
+	text := func(s string) *string { return &s }
+	seconds := func(f float64) *float64 { return &f }
+	yes := true
+
+	for _, in := range []RecomposeInput{
+		{},
+		{SideEffectsText: text(strings.Repeat("s", MaxSideEffectsLength)), SideEffectsStartTime: seconds(12), Pharmaceutical: true, VideoDuration: 16},
+		{SideEffectsStartTime: seconds(0), HasSideEffectsText: true, VideoDuration: 16},
+		{SideEffectsText: text(""), HasSideEffectsText: true},
+		{SideEffectsOverflow: text("scroll"), BurnCaptions: &yes, HasCaptions: true},
+		{LogoOverlay: &domain.LogoOverlay{Asset: "users/u/uploads/logo.png", Position: "top_left"}},
+	} {
+		if errs := ValidateRecompose(in); len(errs) != 0 {
+			t.Errorf("valid input %+v: errors = %v", in, errs)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		in    RecomposeInput
+		field string
+	}{
+		{"disclosure removed", RecomposeInput{SideEffectsText: text(""), Pharmaceutical: true}, "side_effects_text"},
+		{"disclosure too short", RecomposeInput{SideEffectsText: text("rash")}, "side_effects_text"},
+		{"start after the video", RecomposeInput{SideEffectsStartTime: seconds(16), HasSideEffectsText: true, VideoDuration: 16}, "side_effects_start_time"},
+		{"negative start", RecomposeInput{SideEffectsStartTime: seconds(-1), HasSideEffectsText: true, VideoDuration: 16}, "side_effects_start_time"},
+		{"start set by the disclaimer", RecomposeInput{SideEffectsStartTime: seconds(4), HasSideEffectsText: true, DisclaimerTimed: true, VideoDuration: 16}, "side_effects_start_time"},
+		{"start without text", RecomposeInput{SideEffectsStartTime: seconds(4), VideoDuration: 16}, "side_effects_start_time"},
+		{"start with the text removed", RecomposeInput{SideEffectsText: text(""), SideEffectsStartTime: seconds(4), HasSideEffectsText: true, VideoDuration: 16}, "side_effects_start_time"},
+		{"unknown overflow", RecomposeInput{SideEffectsOverflow: text("marquee")}, "side_effects_overflow"},
+		{"no captions", RecomposeInput{BurnCaptions: &yes}, "burn_captions"},
+		{"guideline logo", RecomposeInput{LogoOverlay: &domain.LogoOverlay{UseGuidelineLogo: true}}, "logo_overlay.use_guideline_logo"},
+		{"logo without asset", RecomposeInput{LogoOverlay: &domain.LogoOverlay{}}, "logo_overlay.asset"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateRecompose(tt.in).Field(tt.field); !ok {
+			t.Errorf("%s: expected an error for %s", tt.name, tt.field)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package validation checks request semantics and reports every violation at once
+// as field-level errors, so clients can highlight all invalid inputs together.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/omnigen/backend/pkg/errors"
+)
+
+// FieldError describes a single invalid request field
+type FieldError struct {
+	Field         string   `json:"field"`
+	Message       string   `json:"message"`
+	AllowedValues []string `json:"allowed_values,omitempty"`
+}
+
+// Errors is the list of field errors found while validating a request
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records a field error
+func (e *Errors) Add(field, message string, allowedValues ...string) {
+	*e = append(*e, FieldError{Field: field, Message: message, AllowedValues: allowedValues})
+}
+
+// Field returns the first error reported for field, if any
+func (e Errors) Field(field string) (FieldError, bool) {
+	for _, fe := range e {
+		if fe.Field == field {
+			return fe, true
+		}
+	}
+	return FieldError{}, false
+}
+
+// APIError converts the errors into a 422 response body. The message is the first
+// error's message so clients that only read the message still get a useful hint.
+func (e Errors) APIError() *errors.APIError {
+	message := errors.ErrValidationFailed.Message
+	if len(e) == 1 {
+		message = e[0].Message
+	} else if len(e) > 1 {
+		message = fmt.Sprintf("%s (and %d more)", e[0].Message, len(e)-1)
+	}
+	return errors.NewAPIError(errors.ErrValidationFailed, message, map[string]interface{}{
+		"errors": e,
+	})
+}
+
+// oneOf adds an error when value is set but not in allowed
+func (e *Errors) oneOf(field, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	e.Add(field, fmt.Sprintf("Invalid %s '%s'. Choose one of: %s", field, value, strings.Join(allowed, ", ")), allowed...)
+}
+
+// maxLength adds an error when value exceeds max characters
+func (e *Errors) maxLength(field, value string, max int) {
+	if n := len(value); n > max {
+		e.Add(field, fmt.Sprintf("%s cannot exceed %d characters (currently: %d)", field, max, n))
+	}
+}
@@ -0,0 +1,276 @@
+// Package download fetches remote files (generated clips, music, sound effects) to local disk,
+// retrying transient failures and resuming interrupted transfers with HTTP Range requests.
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrTooLarge is returned when a file exceeds Config.MaxBytes
+var ErrTooLarge = errors.New("download exceeds size limit")
+
+// Config holds download and retry configuration
+type Config struct {
+	MaxAttempts  int           // Requests made before giving up, including resumed ones
+	InitialDelay time.Duration // Backoff before the second attempt; doubles each attempt
+	MaxDelay     time.Duration
+	MaxBytes     int64        // Largest accepted file; <= 0 disables the limit
+	Client       *http.Client // nil uses http.DefaultClient
+	Logger       *zap.Logger  // nil disables logging
+}
+
+// DefaultConfig returns a configuration suitable for Replicate outputs
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:  4,
+		InitialDelay: 500 * time.Millisecond,
+		MaxDelay:     8 * time.Second,
+		MaxBytes:     1 << 30, // 1 GiB; generated clips are tens of MB
+	}
+}
+
+// Result describes a finished download
+type Result struct {
+	Bytes    int64
+	Attempts int
+	Resumes  int // Attempts that asked the server to continue from the bytes already written
+	Elapsed  time.Duration
+}
+
+// permanentError stops the retry loop
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// ToFile downloads rawURL to destPath. Failed attempts are retried with exponential backoff and
+// jitter; when the server supports Range requests an interrupted body is continued from the
+// bytes already written, otherwise the file is downloaded again from the start. 4xx responses
+// and ErrTooLarge are not retried.
+func ToFile(ctx context.Context, cfg Config, rawURL, destPath string) (Result, error) {
+	start := time.Now()
+	result := Result{}
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return result, err
+	}
+	defer out.Close()
+
+	var lastErr error
+	for result.Attempts < max(cfg.MaxAttempts, 1) {
+		if result.Attempts > 0 {
+			if err := sleep(ctx, backoff(cfg, result.Attempts)); err != nil {
+				lastErr = err
+				break
+			}
+		}
+		result.Attempts++
+		if result.Bytes > 0 {
+			result.Resumes++
+		}
+
+		lastErr = fetch(ctx, cfg, rawURL, out, &result.Bytes)
+		if lastErr == nil {
+			break
+		}
+		var permanent *permanentError
+		if errors.As(lastErr, &permanent) || ctx.Err() != nil {
+			break
+		}
+	}
+	result.Elapsed = time.Since(start)
+
+	if cfg.Logger != nil {
+		fields := []zap.Field{
+			zap.String("url", redact(rawURL)),
+			zap.Int64("bytes", result.Bytes),
+			zap.Int("attempts", result.Attempts),
+			zap.Int("resumes", result.Resumes),
+			zap.Duration("elapsed", result.Elapsed),
+			zap.Float64("throughput_mbps", throughputMbps(result)),
+		}
+		if lastErr != nil {
+			cfg.Logger.Warn("Download failed", append(fields, zap.Error(lastErr))...)
+		} else {
+			cfg.Logger.Info("Download finished", fields...)
+		}
+	}
+
+	if lastErr != nil {
+		var permanent *permanentError
+		if errors.As(lastErr, &permanent) {
+			lastErr = permanent.err
+		}
+		return result, fmt.Errorf("download failed after %d attempts: %w", result.Attempts, lastErr)
+	}
+	return result, nil
+}
+
+// fetch makes one request, continuing from *written bytes when possible, and appends the body
+// to out. *written is kept up to date with the bytes on disk.
+func fetch(ctx context.Context, cfg Config, rawURL string, out *os.File, written *int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return &permanentError{err}
+	}
+	if *written > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", *written))
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Total size of the file, or -1 when the server doesn't say
+	total := int64(-1)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && *written > 0:
+		first, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || first != *written {
+			// Not the range we asked for; start over on the next attempt
+			if err := truncate(out, written); err != nil {
+				return &permanentError{err}
+			}
+			return fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		total = size
+	case resp.StatusCode == http.StatusOK:
+		// Either the first request or a server that ignores Range: (re)write the whole file
+		if err := truncate(out, written); err != nil {
+			return &permanentError{err}
+		}
+		total = resp.ContentLength
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		if err := truncate(out, written); err != nil {
+			return &permanentError{err}
+		}
+		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &permanentError{fmt.Errorf("HTTP error: %d", resp.StatusCode)}
+	default:
+		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+
+	if cfg.MaxBytes > 0 && total > cfg.MaxBytes {
+		return &permanentError{fmt.Errorf("%w: %d bytes (limit %d)", ErrTooLarge, total, cfg.MaxBytes)}
+	}
+
+	body := io.Reader(resp.Body)
+	if cfg.MaxBytes > 0 {
+		// One byte past the limit is enough to tell the file is too large
+		body = io.LimitReader(resp.Body, cfg.MaxBytes-*written+1)
+	}
+	n, err := io.Copy(out, body)
+	*written += n
+	if cfg.MaxBytes > 0 && *written > cfg.MaxBytes {
+		return &permanentError{fmt.Errorf("%w: more than %d bytes", ErrTooLarge, cfg.MaxBytes)}
+	}
+	if err != nil {
+		return fmt.Errorf("connection lost after %d bytes: %w", *written, err)
+	}
+	if total >= 0 && *written != total {
+		return fmt.Errorf("incomplete download: got %d of %d bytes", *written, total)
+	}
+	return nil
+}
+
+// truncate empties out so the file can be written again from the start
+func truncate(out *os.File, written *int64) error {
+	*written = 0
+	if err := out.Truncate(0); err != nil {
+		return err
+	}
+	_, err := out.Seek(0, io.SeekStart)
+	return err
+}
+
+// parseContentRange parses "bytes first-last/total"; total is -1 for "*"
+func parseContentRange(header string) (first, total int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	firstStr, _, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseInt(firstStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if size == "*" {
+		return first, -1, true
+	}
+	total, err = strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return first, total, true
+}
+
+// backoff returns the delay before the given attempt: exponential, capped at MaxDelay, with
+// the upper half randomized so parallel downloads don't retry in lockstep
+func backoff(cfg Config, attempt int) time.Duration {
+	delay := time.Duration(float64(cfg.InitialDelay) * math.Pow(2, float64(attempt-1)))
+	if cfg.MaxDelay > 0 && delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func throughputMbps(r Result) float64 {
+	seconds := r.Elapsed.Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) * 8 / 1e6 / seconds
+}
+
+// redact drops the query string, which carries presigned URL credentials
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid URL"
+	}
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,175 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() Config {
+	return Config{MaxAttempts: 4, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+func testBody() []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), 1024)
+}
+
+// dropFirst closes the connection halfway through the first response body
+func dropFirst(hits *atomic.Int32, body []byte, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+			return
+		}
+		next(w, r)
+	}
+}
+
+func TestToFileResumesWithRange(t *testing.T) {
+	body := testBody()
+	var hits atomic.Int32
+	var ranges []string
+	server := httptest.NewServer(dropFirst(&hits, body, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "clip.mp4", time.Time{}, bytes.NewReader(body))
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "clip.mp4")
+	result, err := ToFile(context.Background(), testConfig(), server.URL, dest)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Attempts)
+	require.Equal(t, 1, result.Resumes)
+	require.EqualValues(t, len(body), result.Bytes)
+	require.Equal(t, []string{"bytes=" + strconv.Itoa(len(body)/2) + "-"}, ranges)
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, body, got)
+}
+
+func TestToFileRestartsWhenServerIgnoresRange(t *testing.T) {
+	body := testBody()
+	var hits atomic.Int32
+	server := httptest.NewServer(dropFirst(&hits, body, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body) // 200 with the whole file regardless of Range
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "clip.mp4")
+	result, err := ToFile(context.Background(), testConfig(), server.URL, dest)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Attempts)
+
+	// The partial first attempt is discarded rather than prepended
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, body, got)
+}
+
+func TestToFileGivesUpAfterMaxAttempts(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	result, err := ToFile(context.Background(), testConfig(), server.URL, filepath.Join(t.TempDir(), "clip.mp4"))
+	require.ErrorContains(t, err, "download failed after 4 attempts: HTTP error: 502")
+	require.Equal(t, 4, result.Attempts)
+	require.EqualValues(t, 4, hits.Load())
+}
+
+func TestToFileDoesNotRetryClientErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := ToFile(context.Background(), testConfig(), server.URL, filepath.Join(t.TempDir(), "clip.mp4"))
+	require.ErrorContains(t, err, "HTTP error: 403")
+	require.EqualValues(t, 1, hits.Load())
+}
+
+func TestToFileEnforcesMaxBytes(t *testing.T) {
+	body := testBody()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush() // No Content-Length, so the limit is hit while copying
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.MaxBytes = 1000
+	for _, url := range []string{server.URL, server.URL + "?chunked=1"} {
+		result, err := ToFile(context.Background(), cfg, url, filepath.Join(t.TempDir(), "clip.mp4"))
+		require.True(t, errors.Is(err, ErrTooLarge), "%s: %v", url, err)
+		require.Equal(t, 1, result.Attempts, url)
+	}
+}
+
+func TestToFileStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.InitialDelay = time.Hour
+	cfg.MaxDelay = time.Hour
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ToFile(ctx, cfg, server.URL, filepath.Join(t.TempDir(), "clip.mp4"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not stop after the context was canceled")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		first, total int64
+		ok           bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */200", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, total, ok := parseContentRange(tt.header)
+		require.Equal(t, tt.ok, ok, tt.header)
+		if ok {
+			require.Equal(t, tt.first, first, tt.header)
+			require.Equal(t, tt.total, total, tt.header)
+		}
+	}
+}
@@ -68,6 +68,13 @@ var (
 		Status:  http.StatusUnauthorized,
 	}
 
+	// Payment errors (402)
+	ErrInsufficientCredits = &APIError{
+		Code:    "INSUFFICIENT_CREDITS",
+		Message: "Not enough credits to generate this video",
+		Status:  http.StatusPaymentRequired,
+	}
+
 	// Authorization errors (403)
 	ErrForbidden = &APIError{
 		Code:    "FORBIDDEN",
@@ -82,12 +89,52 @@ var (
 		Status:  http.StatusNotFound,
 	}
 
+	ErrScriptNotFound = &APIError{
+		Code:    "SCRIPT_NOT_FOUND",
+		Message: "Script not found",
+		Status:  http.StatusNotFound,
+	}
+
 	ErrNotFound = &APIError{
 		Code:    "NOT_FOUND",
 		Message: "Resource not found",
 		Status:  http.StatusNotFound,
 	}
 
+	// Conflict errors (409)
+	ErrConflict = &APIError{
+		Code:    "CONFLICT",
+		Message: "The resource is not in a state that allows this operation",
+		Status:  http.StatusConflict,
+	}
+
+	// Semantic validation errors (422)
+	ErrValidationFailed = &APIError{
+		Code:    "VALIDATION_FAILED",
+		Message: "Request validation failed",
+		Status:  http.StatusUnprocessableEntity,
+	}
+
+	ErrAssetNotVerified = &APIError{
+		Code:    "ASSET_NOT_VERIFIED",
+		Message: "One or more assets failed verification",
+		Status:  http.StatusUnprocessableEntity,
+	}
+
+	// Payload errors (413)
+	ErrRequestTooLarge = &APIError{
+		Code:    "REQUEST_TOO_LARGE",
+		Message: "Request body size exceeds maximum allowed limit",
+		Status:  http.StatusRequestEntityTooLarge,
+	}
+
+	// Rate limit errors (429)
+	ErrRateLimited = &APIError{
+		Code:    "RATE_LIMITED",
+		Message: "Too many requests, please retry later",
+		Status:  http.StatusTooManyRequests,
+	}
+
 	// Not implemented (501)
 	ErrNotImplemented = &APIError{
 		Code:    "NOT_IMPLEMENTED",
@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/api"
+	"github.com/omnigen/backend/internal/api/handlers"
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/local"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestLocalPipeline_GeneratesVideo drives a job through the real pipeline, including ffmpeg
+// composition, against the ENVIRONMENT=local backends
+func TestLocalPipeline_GeneratesVideo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("renders and composes real video")
+	}
+	for _, binary := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(binary); err != nil {
+			t.Skipf("%s not installed", binary)
+		}
+	}
+
+	logger := zap.NewNop()
+	backends, err := local.Start(local.Config{
+		DataDir:      t.TempDir(),
+		S3Addr:       "127.0.0.1:0",
+		MockDelay:    10 * time.Millisecond,
+		AssetsBucket: "assets",
+		JobTable:     "jobs",
+		UsageTable:   "usage",
+	}, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { backends.Close() })
+
+	server := api.NewServer(&api.ServerConfig{
+		Environment:    "local",
+		Logger:         logger,
+		JobRepo:        backends.JobRepo,
+		S3Service:      backends.S3Service,
+		UsageRepo:      backends.UsageRepo,
+		ParserService:  service.NewParserService(backends.ScriptGenerator, logger),
+		AdapterFactory: backends.AdapterFactory,
+		MinimaxAdapter: backends.MusicAdapter,
+		TTSAdapter:     backends.TTSAdapter,
+		TTSProvider:    "openai",
+		Audio: handlers.AudioConfig{
+			SFXMaxDuration: 3,
+			MusicLUFS:      -23,
+			NarrationLUFS:  -16,
+		},
+		AssetsBucket: "assets",
+		JWTValidator: backends.JWTValidator,
+	})
+	ts := httptest.NewServer(server.Router())
+	t.Cleanup(ts.Close)
+
+	// The static dev token signs in as the dev user
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/auth/me", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+local.DefaultDevToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"prompt":       "A sleek water bottle on a mountain trail at sunrise",
+		"duration":     10,
+		"aspect_ratio": "16:9",
+		"model":        "kling",
+	})
+	resp, err = http.Post(ts.URL+"/api/v1/generate", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var generated handlers.GenerateResponse
+	decodeJSON(t, resp, http.StatusAccepted, &generated)
+	require.NotEmpty(t, generated.JobID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	var job handlers.JobResponse
+	for {
+		resp, err := http.Get(fmt.Sprintf("%s/api/v1/jobs/%s", ts.URL, generated.JobID))
+		require.NoError(t, err)
+		decodeJSON(t, resp, http.StatusOK, &job)
+		if job.Status == domain.StatusCompleted || job.Status == domain.StatusFailed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("job still %s (stage %q) after %v", job.Status, job.Stage, 3*time.Minute)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	require.Equal(t, domain.StatusCompleted, job.Status, "job failed: %v", job.ErrorMessage)
+	require.NotNil(t, job.VideoURL)
+
+	// The final video is served by the local S3 and is as long as requested
+	resp, err = http.Get(*job.VideoURL)
+	require.NoError(t, err)
+	video, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEmpty(t, video)
+
+	stored, err := backends.JobRepo.GetJob(ctx, generated.JobID)
+	require.NoError(t, err)
+	require.InDelta(t, 10, probeDuration(t, *job.VideoURL), 1.5)
+	require.NotEmpty(t, stored.Provenance, "mock predictions are recorded like Replicate ones")
+}
+
+// decodeJSON checks resp has status and decodes its body into v
+func decodeJSON(t *testing.T, resp *http.Response, status int, v interface{}) {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, status, resp.StatusCode, string(data))
+	require.NoError(t, json.Unmarshal(data, v))
+}
+
+// probeDuration returns the duration ffprobe reports for url
+func probeDuration(t *testing.T, url string) float64 {
+	t.Helper()
+	output, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", url).Output()
+	require.NoError(t, err)
+	var seconds float64
+	_, err = fmt.Sscanf(string(output), "%f", &seconds)
+	require.NoError(t, err)
+	return seconds
+}
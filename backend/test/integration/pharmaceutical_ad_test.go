@@ -364,7 +364,7 @@ func buildMockJob(t *testing.T, scenario pipelineScenario) *domain.Job {
 		UserID:               "user-123",
 		ScriptID:             "script-" + scenario.name,
 		Status:               domain.StatusCompleted,
-		Stage:                "complete",
+		Stage:                domain.StageComplete,
 		ThumbnailURL:         buildThumbnailURL(scenario.name),
 		AudioURL:             buildAudioURL(scenario.name),
 		NarratorAudioURL:     buildNarratorURL(scenario.name),
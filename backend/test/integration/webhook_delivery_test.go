@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omnigen/backend/internal/domain"
+	"github.com/omnigen/backend/internal/service"
+	"github.com/omnigen/backend/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// deliveryRecorder stands in for the job repository
+type deliveryRecorder struct {
+	mu          sync.Mutex
+	jobID       string
+	attempts    int
+	deliveredAt int64
+	done        chan struct{}
+}
+
+func (r *deliveryRecorder) RecordWebhookDelivery(ctx context.Context, jobID string, attempts int, deliveredAt int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobID, r.attempts, r.deliveredAt = jobID, attempts, deliveredAt
+	close(r.done)
+	return nil
+}
+
+// receivedWebhook is what the customer's receiver saw
+type receivedWebhook struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+func TestWebhookDelivery_EndToEnd(t *testing.T) {
+	const secret = "whsec_0123456789abcdef"
+
+	// The receiver rejects the first attempt, then verifies the signature like a customer would
+	var mu sync.Mutex
+	var received []receivedWebhook
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mu.Lock()
+		received = append(received, receivedWebhook{
+			event:     r.Header.Get(service.WebhookEventHeader),
+			signature: r.Header.Get(service.WebhookSignatureHeader),
+			body:      body,
+		})
+		first := len(received) == 1
+		mu.Unlock()
+
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(expected), []byte(r.Header.Get(service.WebhookSignatureHeader))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	store := &deliveryRecorder{done: make(chan struct{})}
+	cfg := service.DefaultWebhookConfig()
+	cfg.Retry = retry.Config{MaxAttempts: 5, InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 2}
+	cfg.AllowPrivateNetworks = true // httptest listens on loopback
+	webhooks := service.NewWebhookService(store, cfg, zap.NewNop())
+
+	webhooks.Enqueue(receiver.URL+"/omnigen/webhook", secret, service.WebhookPayload{
+		Event:        service.WebhookEventJobCompleted,
+		JobID:        "job-123",
+		Status:       domain.StatusCompleted,
+		VideoURL:     "https://assets.example.com/videos/job-123.mp4?X-Amz-Signature=abc",
+		ThumbnailURL: "https://assets.example.com/thumbnails/job-123.jpg?X-Amz-Signature=def",
+	})
+
+	select {
+	case <-store.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook delivery was not recorded")
+	}
+
+	assert.Equal(t, "job-123", store.jobID)
+	assert.Equal(t, 2, store.attempts)
+	assert.NotZero(t, store.deliveredAt)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 2)
+	assert.Equal(t, received[0].body, received[1].body, "retries must resend the same signed body")
+
+	final := received[1]
+	assert.Equal(t, service.WebhookEventJobCompleted, final.event)
+	assert.Equal(t, service.SignWebhookPayload(secret, final.body), final.signature)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(final.body, &payload))
+	assert.Equal(t, "job-123", payload["job_id"])
+	assert.Equal(t, "completed", payload["status"])
+	assert.Equal(t, "https://assets.example.com/videos/job-123.mp4?X-Amz-Signature=abc", payload["video_url"])
+	assert.Equal(t, "https://assets.example.com/thumbnails/job-123.jpg?X-Amz-Signature=def", payload["thumbnail_url"])
+	assert.NotContains(t, payload, "error_message")
+	assert.NotZero(t, payload["timestamp"])
+}
@@ -27,7 +27,7 @@ const STATUS_VARIANTS = [
 const normalizeStatus = (status) => (status || "").toLowerCase();
 const isProcessingStatus = (status) => {
   const normalized = normalizeStatus(status);
-  return normalized === "processing" || normalized === "pending";
+  return normalized === "processing" || normalized === "pending" || normalized === "queued";
 };
 const isCompletedStatus = (status) => {
   const normalized = normalizeStatus(status);
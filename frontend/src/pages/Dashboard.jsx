@@ -27,7 +27,7 @@ function Dashboard() {
       setError(null);
 
       // Fetch all jobs for stats
-      const jobsResponse = await jobsAPI.list({ page: 1, page_size: 50 });
+      const jobsResponse = await jobsAPI.list({ page_size: 50 });
       const allJobs = jobsResponse.jobs || [];
       setJobs(allJobs);
 
@@ -42,7 +42,7 @@ function Workspace() {
   const normalizeStatus = (status) => (status || "").toLowerCase();
   const isProcessingStatus = (status) => {
     const normalized = normalizeStatus(status);
-    return normalized === "processing" || normalized === "pending";
+    return normalized === "processing" || normalized === "pending" || normalized === "queued";
   };
   const isCompletedStatus = (status) => {
     const normalized = normalizeStatus(status);
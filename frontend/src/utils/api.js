@@ -288,16 +288,26 @@ export const jobs = {
   /**
    * Get all jobs for the current user
    * @param {Object} params - Query parameters
-   * @param {number} params.page - Page number
+   * @param {string} params.cursor - next_cursor from the previous page
    * @param {number} params.page_size - Page size
    * @param {string} params.status - Filter by status
-   * @returns {Promise<{jobs: Array, total_count: number, page: number, page_size: number}>}
+   * @param {string} params.q - Case-insensitive search over prompt and title
+   * @param {number|string} params.created_after - Unix seconds or RFC3339
+   * @param {number|string} params.created_before - Unix seconds or RFC3339
+   * @param {string} params.sort - created_at (default), duration or status
+   * @returns {Promise<{jobs: Array, count: number, page_size: number, next_cursor?: string, truncated?: boolean, filters: Object}>}
    */
   list: (params = {}) => {
     const queryParams = new URLSearchParams();
-    if (params.page) queryParams.append("page", params.page);
+    if (params.cursor) queryParams.append("cursor", params.cursor);
     if (params.page_size) queryParams.append("page_size", params.page_size);
     if (params.status) queryParams.append("status", params.status);
+    if (params.q) queryParams.append("q", params.q);
+    if (params.created_after)
+      queryParams.append("created_after", params.created_after);
+    if (params.created_before)
+      queryParams.append("created_before", params.created_before);
+    if (params.sort) queryParams.append("sort", params.sort);
 
     const query = queryParams.toString();
     return apiRequest(`/api/v1/jobs${query ? `?${query}` : ""}`);
@@ -327,6 +337,16 @@ export const jobs = {
       method: "DELETE",
     }),
 
+  /**
+   * Cancel a queued or in-progress job
+   * @param {string} id - Job ID
+   * @returns {Promise<{job_id: string, status: string, cancel_requested: boolean}>} status is "canceled" when the job stopped immediately, "processing" while a running job is stopping
+   */
+  cancel: (id) =>
+    apiRequest(`/api/v1/jobs/${id}/cancel`, {
+      method: "POST",
+    }),
+
   /**
    * Regenerate a specific scene
    * @param {string} jobId - Job ID
@@ -354,12 +374,15 @@ export const generate = {
    * @param {string} params.aspect_ratio - Aspect ratio (16:9, 9:16, 1:1)
    * @param {string} params.style - Visual style
    * @param {string} params.title - Optional video title
+   * @param {Object} options - Request options
+   * @param {string} options.idempotencyKey - Reuse the same key when retrying so the retry returns the original job
    * @returns {Promise<{job_id: string, status: string}>}
    */
-  create: (params) =>
+  create: (params, { idempotencyKey } = {}) =>
     apiRequest("/api/v1/generate", {
       method: "POST",
       body: JSON.stringify(params),
+      headers: idempotencyKey ? { "Idempotency-Key": idempotencyKey } : {},
     }),
 
   /**
@@ -440,6 +463,17 @@ export const presets = {
   list: () => apiRequest("/api/v1/presets"),
 };
 
+/**
+ * Usage API endpoints
+ */
+export const usage = {
+  /**
+   * Get the credit balance, this month's consumption and per-job charges
+   * @returns {Promise<{period: string, monthly_credits: number, credits_remaining: number, credits_used: number, jobs: Array}>}
+   */
+  get: () => apiRequest("/api/v1/usage"),
+};
+
 /**
  * Health check endpoint (no auth required)
  */
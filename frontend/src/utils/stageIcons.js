@@ -68,6 +68,11 @@ export function getStageIcon(stage) {
       icon: XCircle,
       color: '#ef4444', // Red
       emoji: '❌'
+    },
+    'canceled': {
+      icon: XCircle,
+      color: '#6b7280', // Gray
+      emoji: '⏹️'
     }
   };
 
@@ -115,7 +120,8 @@ export function getStageDisplayName(stage) {
     'audio_complete': 'Audio Ready',
     'composing': 'Composing Final Video',
     'complete': 'Complete',
-    'failed': 'Failed'
+    'failed': 'Failed',
+    'canceled': 'Canceled'
   };
 
   return displayNames[stage] || stage.replace(/_/g, ' ').replace(/\b\w/g, l => l.toUpperCase());
@@ -28,14 +28,24 @@ module "networking" {
 module "iam" {
   source = "./modules/iam"
 
-  project_name             = var.project_name
-  assets_bucket_arn        = module.storage.assets_bucket_arn
-  frontend_bucket_arn      = module.storage.frontend_bucket_arn
-  dynamodb_table_arn       = module.storage.dynamodb_table_arn
-  dynamodb_usage_table_arn = module.storage.dynamodb_usage_table_arn
-  replicate_secret_arn     = var.replicate_api_key_secret_arn
-  openai_secret_arn        = var.openai_api_key_secret_arn
-  ecr_repository_arn       = module.compute.ecr_repository_arn
+  project_name                         = var.project_name
+  assets_bucket_arn                    = module.storage.assets_bucket_arn
+  frontend_bucket_arn                  = module.storage.frontend_bucket_arn
+  dynamodb_table_arn                   = module.storage.dynamodb_table_arn
+  dynamodb_usage_table_arn             = module.storage.dynamodb_usage_table_arn
+  dynamodb_idempotency_table_arn       = module.storage.dynamodb_idempotency_table_arn
+  dynamodb_scripts_table_arn           = module.storage.dynamodb_scripts_table_arn
+  dynamodb_assets_table_arn            = module.storage.dynamodb_assets_table_arn
+  dynamodb_job_events_table_arn        = module.storage.dynamodb_job_events_table_arn
+  dynamodb_job_locks_table_arn         = module.storage.dynamodb_job_locks_table_arn
+  dynamodb_user_buckets_table_arn      = module.storage.dynamodb_user_buckets_table_arn
+  dynamodb_preferences_table_arn       = module.storage.dynamodb_preferences_table_arn
+  dynamodb_stage_timings_table_arn     = module.storage.dynamodb_stage_timings_table_arn
+  dynamodb_brand_guidelines_table_arn  = module.storage.dynamodb_brand_guidelines_table_arn
+  dynamodb_prediction_events_table_arn = module.storage.dynamodb_prediction_events_table_arn
+  replicate_secret_arn                 = var.replicate_api_key_secret_arn
+  openai_secret_arn                    = var.openai_api_key_secret_arn
+  ecr_repository_arn                   = module.compute.ecr_repository_arn
 }
 
 # Storage Module - S3 Buckets and DynamoDB Table
@@ -64,33 +74,43 @@ module "monitoring" {
 module "compute" {
   source = "./modules/compute"
 
-  project_name              = var.project_name
-  environment               = var.environment
-  vpc_id                    = module.networking.vpc_id
-  private_subnet_ids        = [module.networking.private_subnet_id]
-  ecs_security_group_id     = module.networking.ecs_security_group_id
-  alb_target_group_arn      = module.loadbalancer.target_group_arn
-  task_execution_role_arn   = module.iam.ecs_task_execution_role_arn
-  task_role_arn             = module.iam.ecs_task_role_arn
-  cpu                       = var.ecs_cpu
-  memory                    = var.ecs_memory
-  min_tasks                 = var.ecs_min_tasks
-  max_tasks                 = var.ecs_max_tasks
-  target_cpu_utilization    = var.ecs_target_cpu_utilization
-  container_name            = local.container_name
-  container_port            = local.container_port
-  log_group_name            = module.monitoring.ecs_log_group_name
-  aws_region                = var.aws_region
-  assets_bucket_name        = module.storage.assets_bucket_name
-  dynamodb_table_name       = module.storage.dynamodb_table_name
-  dynamodb_usage_table_name = module.storage.dynamodb_usage_table_name
-  replicate_secret_arn      = var.replicate_api_key_secret_arn
-  openai_secret_arn         = var.openai_api_key_secret_arn
-  cognito_user_pool_id      = module.auth.user_pool_id
-  cognito_client_id         = module.auth.client_id
-  jwt_issuer                = module.auth.issuer_url
-  cognito_domain            = module.auth.hosted_ui_domain
-  cloudfront_domain         = module.cdn.cloudfront_domain_name
+  project_name                          = var.project_name
+  environment                           = var.environment
+  vpc_id                                = module.networking.vpc_id
+  private_subnet_ids                    = [module.networking.private_subnet_id]
+  ecs_security_group_id                 = module.networking.ecs_security_group_id
+  alb_target_group_arn                  = module.loadbalancer.target_group_arn
+  task_execution_role_arn               = module.iam.ecs_task_execution_role_arn
+  task_role_arn                         = module.iam.ecs_task_role_arn
+  cpu                                   = var.ecs_cpu
+  memory                                = var.ecs_memory
+  min_tasks                             = var.ecs_min_tasks
+  max_tasks                             = var.ecs_max_tasks
+  target_cpu_utilization                = var.ecs_target_cpu_utilization
+  container_name                        = local.container_name
+  container_port                        = local.container_port
+  log_group_name                        = module.monitoring.ecs_log_group_name
+  aws_region                            = var.aws_region
+  assets_bucket_name                    = module.storage.assets_bucket_name
+  dynamodb_table_name                   = module.storage.dynamodb_table_name
+  dynamodb_usage_table_name             = module.storage.dynamodb_usage_table_name
+  dynamodb_idempotency_table_name       = module.storage.dynamodb_idempotency_table_name
+  dynamodb_scripts_table_name           = module.storage.dynamodb_scripts_table_name
+  dynamodb_assets_table_name            = module.storage.dynamodb_assets_table_name
+  dynamodb_job_events_table_name        = module.storage.dynamodb_job_events_table_name
+  dynamodb_job_locks_table_name         = module.storage.dynamodb_job_locks_table_name
+  dynamodb_user_buckets_table_name      = module.storage.dynamodb_user_buckets_table_name
+  dynamodb_preferences_table_name       = module.storage.dynamodb_preferences_table_name
+  dynamodb_stage_timings_table_name     = module.storage.dynamodb_stage_timings_table_name
+  dynamodb_brand_guidelines_table_name  = module.storage.dynamodb_brand_guidelines_table_name
+  dynamodb_prediction_events_table_name = module.storage.dynamodb_prediction_events_table_name
+  replicate_secret_arn                  = var.replicate_api_key_secret_arn
+  openai_secret_arn                     = var.openai_api_key_secret_arn
+  cognito_user_pool_id                  = module.auth.user_pool_id
+  cognito_client_id                     = module.auth.client_id
+  jwt_issuer                            = module.auth.issuer_url
+  cognito_domain                        = module.auth.hosted_ui_domain
+  cloudfront_domain                     = module.cdn.cloudfront_domain_name
 
   depends_on = [module.monitoring, module.auth]
 }
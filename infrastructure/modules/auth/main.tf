@@ -138,6 +138,13 @@ resource "aws_cognito_user_pool_client" "api_client" {
   write_attributes = ["email", "name"]
 }
 
+# Support staff; members' tokens carry cognito:groups = ["admin"], which unlocks /api/v1/admin
+resource "aws_cognito_user_group" "admin" {
+  name         = "admin"
+  user_pool_id = aws_cognito_user_pool.main.id
+  description  = "Support staff with access to the admin API"
+}
+
 # Cognito Domain for Hosted UI (optional but recommended for quick setup)
 resource "aws_cognito_user_pool_domain" "main" {
   domain       = "${var.project_name}-${data.aws_caller_identity.current.account_id}"
@@ -49,6 +49,46 @@ resource "aws_ecs_task_definition" "api" {
           name  = "USAGE_TABLE"
           value = var.dynamodb_usage_table_name
         },
+        {
+          name  = "IDEMPOTENCY_TABLE"
+          value = var.dynamodb_idempotency_table_name
+        },
+        {
+          name  = "SCRIPTS_TABLE"
+          value = var.dynamodb_scripts_table_name
+        },
+        {
+          name  = "ASSETS_TABLE"
+          value = var.dynamodb_assets_table_name
+        },
+        {
+          name  = "JOB_EVENTS_TABLE"
+          value = var.dynamodb_job_events_table_name
+        },
+        {
+          name  = "JOB_LOCKS_TABLE"
+          value = var.dynamodb_job_locks_table_name
+        },
+        {
+          name  = "USER_BUCKETS_TABLE"
+          value = var.dynamodb_user_buckets_table_name
+        },
+        {
+          name  = "PREFERENCES_TABLE"
+          value = var.dynamodb_preferences_table_name
+        },
+        {
+          name  = "STAGE_TIMINGS_TABLE"
+          value = var.dynamodb_stage_timings_table_name
+        },
+        {
+          name  = "BRAND_GUIDELINES_TABLE"
+          value = var.dynamodb_brand_guidelines_table_name
+        },
+        {
+          name  = "PREDICTION_EVENTS_TABLE"
+          value = var.dynamodb_prediction_events_table_name
+        },
         {
           name  = "REPLICATE_SECRET_ARN"
           value = var.replicate_secret_arn
@@ -89,7 +129,7 @@ resource "aws_ecs_task_definition" "api" {
       }
 
       healthCheck = {
-        command     = ["CMD-SHELL", "curl -f http://localhost:${var.container_port}/health || exit 1"]
+        command     = ["CMD-SHELL", "curl -f http://localhost:${var.container_port}/healthz || exit 1"]
         interval    = 30
         timeout     = 5
         retries     = 3
@@ -94,9 +94,13 @@ resource "aws_iam_role_policy" "ecs_task" {
         Effect = "Allow"
         Action = [
           "s3:PutObject",
+          "s3:PutObjectTagging", # Export archives are tagged for early expiry
           "s3:GetObject",
           "s3:ListBucket",
-          "s3:DeleteObject"
+          "s3:DeleteObject",
+          "s3:ListMultipartUploadParts", # Resumable uploads of large assets
+          "s3:ListBucketMultipartUploads",
+          "s3:AbortMultipartUpload"
         ]
         Resource = [
           var.assets_bucket_arn,
@@ -117,7 +121,19 @@ resource "aws_iam_role_policy" "ecs_task" {
         Resource = [
           var.dynamodb_table_arn,
           "${var.dynamodb_table_arn}/index/*",
-          var.dynamodb_usage_table_arn
+          var.dynamodb_usage_table_arn,
+          var.dynamodb_idempotency_table_arn,
+          var.dynamodb_scripts_table_arn,
+          "${var.dynamodb_scripts_table_arn}/index/*",
+          var.dynamodb_assets_table_arn,
+          var.dynamodb_job_events_table_arn,
+          var.dynamodb_job_locks_table_arn,
+          var.dynamodb_user_buckets_table_arn,
+          var.dynamodb_preferences_table_arn,
+          var.dynamodb_stage_timings_table_arn,
+          var.dynamodb_brand_guidelines_table_arn,
+          "${var.dynamodb_brand_guidelines_table_arn}/index/*",
+          var.dynamodb_prediction_events_table_arn
         ]
       },
       {
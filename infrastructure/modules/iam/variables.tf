@@ -23,6 +23,56 @@ variable "dynamodb_usage_table_arn" {
   type        = string
 }
 
+variable "dynamodb_idempotency_table_arn" {
+  description = "ARN of the DynamoDB idempotency key table"
+  type        = string
+}
+
+variable "dynamodb_scripts_table_arn" {
+  description = "ARN of the DynamoDB script library table"
+  type        = string
+}
+
+variable "dynamodb_assets_table_arn" {
+  description = "ARN of the DynamoDB asset verification table"
+  type        = string
+}
+
+variable "dynamodb_job_events_table_arn" {
+  description = "ARN of the DynamoDB job events table"
+  type        = string
+}
+
+variable "dynamodb_job_locks_table_arn" {
+  description = "ARN of the DynamoDB job locks table"
+  type        = string
+}
+
+variable "dynamodb_user_buckets_table_arn" {
+  description = "ARN of the DynamoDB user buckets table"
+  type        = string
+}
+
+variable "dynamodb_preferences_table_arn" {
+  description = "ARN of the DynamoDB user preferences table"
+  type        = string
+}
+
+variable "dynamodb_stage_timings_table_arn" {
+  description = "ARN of the DynamoDB stage timings table"
+  type        = string
+}
+
+variable "dynamodb_brand_guidelines_table_arn" {
+  description = "ARN of the DynamoDB brand guidelines table"
+  type        = string
+}
+
+variable "dynamodb_prediction_events_table_arn" {
+  description = "ARN of the DynamoDB prediction events table"
+  type        = string
+}
+
 variable "replicate_secret_arn" {
   description = "ARN of the Replicate API key secret"
   type        = string
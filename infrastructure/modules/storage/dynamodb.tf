@@ -20,7 +20,17 @@ resource "aws_dynamodb_table" "jobs" {
     type = "N"
   }
 
-  # Global Secondary Index for querying by user
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  attribute {
+    name = "parent_job_id"
+    type = "S"
+  }
+
+  # Global Secondary Index for querying by user, including their active and queued jobs
   global_secondary_index {
     name            = "UserJobsIndex"
     hash_key        = "user_id"
@@ -28,6 +38,22 @@ resource "aws_dynamodb_table" "jobs" {
     projection_type = "ALL"
   }
 
+  # Queued jobs across all users for the dispatcher sweep, plus the admin job list
+  global_secondary_index {
+    name            = "StatusJobsIndex"
+    hash_key        = "status"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # Child jobs of an A/B variant parent
+  global_secondary_index {
+    name            = "ParentJobsIndex"
+    hash_key        = "parent_job_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
   # Time To Live configuration
   ttl {
     attribute_name = "ttl"
@@ -86,3 +112,286 @@ resource "aws_dynamodb_table" "usage" {
     Name = "${var.project_name}-usage"
   }
 }
+
+# DynamoDB Table for POST /generate idempotency keys
+resource "aws_dynamodb_table" "idempotency" {
+  name         = "${var.project_name}-idempotency"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "idempotency_key" # user_id#key
+
+  attribute {
+    name = "idempotency_key"
+    type = "S"
+  }
+
+  # Keys expire 24 hours after the first request
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-idempotency"
+  }
+}
+
+# DynamoDB Table for the script library (scripts kept for reuse in new jobs)
+resource "aws_dynamodb_table" "scripts" {
+  name         = "${var.project_name}-scripts"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "script_id"
+
+  attribute {
+    name = "script_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "N"
+  }
+
+  # A user's scripts, newest first
+  global_secondary_index {
+    name            = "UserScriptsIndex"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # Scripts expire 30 days after they are written
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-scripts"
+  }
+}
+
+# DynamoDB Table for upload verification results (one record per uploaded asset)
+resource "aws_dynamodb_table" "assets" {
+  name         = "${var.project_name}-assets"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "asset_key"
+
+  attribute {
+    name = "asset_key"
+    type = "S"
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-assets"
+  }
+}
+
+# DynamoDB Table for job audit trails (one record per event, plus a counter per job)
+resource "aws_dynamodb_table" "job_events" {
+  name         = "${var.project_name}-job-events"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "job_id"
+  range_key    = "sequence"
+
+  attribute {
+    name = "job_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "sequence"
+    type = "N"
+  }
+
+  # Events expire 90 days after they're recorded
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-job-events"
+  }
+}
+
+# DynamoDB Table for job locks (one record per job while a run composes its final video)
+resource "aws_dynamodb_table" "job_locks" {
+  name         = "${var.project_name}-job-locks"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "job_id"
+
+  attribute {
+    name = "job_id"
+    type = "S"
+  }
+
+  # Locks expire on their own if the run holding them dies
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-job-locks"
+  }
+}
+
+# DynamoDB Table mapping users (enterprise tenants) to their own assets bucket
+resource "aws_dynamodb_table" "user_buckets" {
+  name         = "${var.project_name}-user-buckets"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "user_id"
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-user-buckets"
+  }
+}
+
+# DynamoDB Table for each user's default generation settings (GET/PUT /preferences)
+resource "aws_dynamodb_table" "preferences" {
+  name         = "${var.project_name}-preferences"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "user_id"
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-preferences"
+  }
+}
+
+# DynamoDB Table for the rolling step timings job ETAs are estimated from (one item)
+resource "aws_dynamodb_table" "stage_timings" {
+  name         = "${var.project_name}-stage-timings"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "timings_id"
+
+  attribute {
+    name = "timings_id"
+    type = "S"
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-stage-timings"
+  }
+}
+
+# DynamoDB Table for users' brand guidelines (colors, voice, logos) applied to their scripts
+resource "aws_dynamodb_table" "brand_guidelines" {
+  name         = "${var.project_name}-brand-guidelines"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "guideline_id"
+
+  attribute {
+    name = "guideline_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "N"
+  }
+
+  # A user's guidelines, newest first
+  global_secondary_index {
+    name            = "UserBrandGuidelinesIndex"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-brand-guidelines"
+  }
+}
+
+# DynamoDB Table for Replicate prediction webhooks, so the server waiting on a prediction sees
+# its webhook whichever server the load balancer delivered it to
+resource "aws_dynamodb_table" "prediction_events" {
+  name         = "${var.project_name}-prediction-events"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "prediction_id"
+
+  attribute {
+    name = "prediction_id"
+    type = "S"
+  }
+
+  # Events are only needed while someone waits on the prediction
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
+  # Server-side encryption
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${var.project_name}-prediction-events"
+  }
+}
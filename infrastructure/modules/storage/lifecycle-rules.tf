@@ -32,6 +32,22 @@ resource "aws_s3_bucket_lifecycle_configuration" "assets" {
     }
   }
 
+  rule {
+    id     = "expire-exports"
+    status = "Enabled"
+
+    filter {
+      tag {
+        key   = "omnigen-export"
+        value = "true"
+      }
+    }
+
+    expiration {
+      days = var.assets_export_expiration_days
+    }
+  }
+
   rule {
     id     = "delete-incomplete-uploads"
     status = "Enabled"
@@ -52,3 +52,103 @@ output "dynamodb_usage_table_arn" {
   description = "ARN of the DynamoDB usage table"
   value       = aws_dynamodb_table.usage.arn
 }
+
+output "dynamodb_idempotency_table_name" {
+  description = "Name of the DynamoDB idempotency key table"
+  value       = aws_dynamodb_table.idempotency.name
+}
+
+output "dynamodb_idempotency_table_arn" {
+  description = "ARN of the DynamoDB idempotency key table"
+  value       = aws_dynamodb_table.idempotency.arn
+}
+
+output "dynamodb_scripts_table_name" {
+  description = "Name of the DynamoDB script library table"
+  value       = aws_dynamodb_table.scripts.name
+}
+
+output "dynamodb_scripts_table_arn" {
+  description = "ARN of the DynamoDB script library table"
+  value       = aws_dynamodb_table.scripts.arn
+}
+
+output "dynamodb_assets_table_name" {
+  description = "Name of the DynamoDB asset verification table"
+  value       = aws_dynamodb_table.assets.name
+}
+
+output "dynamodb_assets_table_arn" {
+  description = "ARN of the DynamoDB asset verification table"
+  value       = aws_dynamodb_table.assets.arn
+}
+
+output "dynamodb_job_events_table_name" {
+  description = "Name of the DynamoDB job events table"
+  value       = aws_dynamodb_table.job_events.name
+}
+
+output "dynamodb_job_events_table_arn" {
+  description = "ARN of the DynamoDB job events table"
+  value       = aws_dynamodb_table.job_events.arn
+}
+
+output "dynamodb_job_locks_table_name" {
+  description = "Name of the DynamoDB job locks table"
+  value       = aws_dynamodb_table.job_locks.name
+}
+
+output "dynamodb_job_locks_table_arn" {
+  description = "ARN of the DynamoDB job locks table"
+  value       = aws_dynamodb_table.job_locks.arn
+}
+
+output "dynamodb_user_buckets_table_name" {
+  description = "Name of the DynamoDB user buckets table"
+  value       = aws_dynamodb_table.user_buckets.name
+}
+
+output "dynamodb_user_buckets_table_arn" {
+  description = "ARN of the DynamoDB user buckets table"
+  value       = aws_dynamodb_table.user_buckets.arn
+}
+
+output "dynamodb_preferences_table_name" {
+  description = "Name of the DynamoDB user preferences table"
+  value       = aws_dynamodb_table.preferences.name
+}
+
+output "dynamodb_preferences_table_arn" {
+  description = "ARN of the DynamoDB user preferences table"
+  value       = aws_dynamodb_table.preferences.arn
+}
+
+output "dynamodb_stage_timings_table_name" {
+  description = "Name of the DynamoDB stage timings table"
+  value       = aws_dynamodb_table.stage_timings.name
+}
+
+output "dynamodb_stage_timings_table_arn" {
+  description = "ARN of the DynamoDB stage timings table"
+  value       = aws_dynamodb_table.stage_timings.arn
+}
+
+output "dynamodb_brand_guidelines_table_name" {
+  description = "Name of the DynamoDB brand guidelines table"
+  value       = aws_dynamodb_table.brand_guidelines.name
+}
+
+output "dynamodb_brand_guidelines_table_arn" {
+  description = "ARN of the DynamoDB brand guidelines table"
+  value       = aws_dynamodb_table.brand_guidelines.arn
+}
+
+output "dynamodb_prediction_events_table_name" {
+  description = "Name of the DynamoDB prediction events table"
+  value       = aws_dynamodb_table.prediction_events.name
+}
+
+output "dynamodb_prediction_events_table_arn" {
+  description = "ARN of the DynamoDB prediction events table"
+  value       = aws_dynamodb_table.prediction_events.arn
+}
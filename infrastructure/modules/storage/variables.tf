@@ -21,6 +21,12 @@ variable "assets_lifecycle_expiration_days" {
   default     = 365
 }
 
+variable "assets_export_expiration_days" {
+  description = "Days before deleting ZIP exports of a job's assets"
+  type        = number
+  default     = 7
+}
+
 variable "dynamodb_ttl_days" {
   description = "Days before DynamoDB items expire via TTL"
   type        = number